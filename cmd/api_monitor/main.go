@@ -9,10 +9,13 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
 	"stocksub/pkg/limiter"
 	"stocksub/pkg/provider/tencent"
 	"stocksub/pkg/storage"
@@ -21,12 +24,12 @@ import (
 
 // MonitorConfig 监控配置
 type MonitorConfig struct {
-	Symbols       []string      `json:"symbols"`
-	Duration      time.Duration `json:"duration"`
-	Interval      time.Duration `json:"interval"`
-	DataDir       string        `json:"data_dir"`
-	LogDir        string        `json:"log_dir"`
-	CleanupOnExit bool          `json:"cleanup_on_exit"`
+	Symbols  []string      `json:"symbols"`
+	Duration time.Duration `json:"duration"`
+	Interval time.Duration `json:"interval"`
+	DataDir  string        `json:"data_dir"`
+	LogDir   string        `json:"log_dir"`
+	Fresh    bool          `json:"fresh"` // 启动前将已有数据归档到 archive/ 并开始新会话
 }
 
 // PerformanceMetric 定义了用于此监控器的性能指标结构
@@ -37,13 +40,20 @@ type PerformanceMetric struct {
 	ResponseSizeBytes int64     `json:"response_size_bytes"`
 	ErrorOccurred     bool      `json:"error_occurred"`
 	ErrorMessage      string    `json:"error_message"`
+	ErrorType         string    `json:"error_type,omitempty"` // fatal、network、invalid、unknown
+}
+
+// stockFetcher 监控器用到的行情接口，*tencent.Client 满足该接口；第二个返回值为原始响应，用于统计响应大小
+type stockFetcher interface {
+	FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error)
 }
 
 // APIMonitor API监控器
 type APIMonitor struct {
 	config   MonitorConfig
-	provider *tencent.Client
+	provider stockFetcher
 	storage  *storage.CSVStorage
+	session  *SessionManifest
 	logger   *log.Logger
 	logFile  *os.File
 	cancel   context.CancelFunc
@@ -60,7 +70,7 @@ func main() {
 		duration = flag.Duration("duration", 5*time.Minute, "监控持续时间")
 		interval = flag.Duration("interval", 3*time.Second, "采集间隔")
 		dataDir  = flag.String("data-dir", "", "数据保存目录（默认：tests/data/collected）")
-		cleanup  = flag.Bool("cleanup", true, "开始前清理旧数据")
+		fresh    = flag.Bool("fresh", false, "将已有数据移动到 archive/<时间戳>/ 后开始新会话（默认续写已有数据）")
 	)
 	flag.Parse()
 
@@ -78,12 +88,12 @@ func main() {
 	}
 
 	config := MonitorConfig{
-		Symbols:       symbolList,
-		Duration:      *duration,
-		Interval:      *interval,
-		DataDir:       *dataDir,
-		LogDir:        filepath.Join(*dataDir, "logs"),
-		CleanupOnExit: *cleanup,
+		Symbols:  symbolList,
+		Duration: *duration,
+		Interval: *interval,
+		DataDir:  *dataDir,
+		LogDir:   filepath.Join(*dataDir, "logs"),
+		Fresh:    *fresh,
 	}
 
 	// 创建监控器
@@ -141,15 +151,25 @@ func NewAPIMonitor(config MonitorConfig) (*APIMonitor, error) {
 		}
 	}
 
-	// 清理旧数据
-	if config.CleanupOnExit {
-		if err := cleanupOldData(config.DataDir); err != nil {
-			return nil, fmt.Errorf("清理旧数据失败: %v", err)
+	// 归档旧数据
+	now := time.Now()
+	archiveDir := ""
+	if config.Fresh {
+		dir, err := archiveOldData(config.DataDir, now)
+		if err != nil {
+			return nil, fmt.Errorf("归档旧数据失败: %v", err)
 		}
+		archiveDir = dir
+	}
+
+	// 开始或续接会话
+	session, resumed, err := startSession(config.DataDir, config.Symbols, config.Interval, now)
+	if err != nil {
+		return nil, fmt.Errorf("读取会话清单失败: %v", err)
 	}
 
 	// 创建日志文件
-	logPath := filepath.Join(config.LogDir, fmt.Sprintf("monitor_%s.log", time.Now().Format("20060102_150405")))
+	logPath := filepath.Join(config.LogDir, fmt.Sprintf("monitor_s%d_%s.log", session.Session, now.Format("20060102_150405")))
 	logFile, err := os.Create(logPath)
 	if err != nil {
 		return nil, fmt.Errorf("创建日志文件失败: %v", err)
@@ -157,6 +177,13 @@ func NewAPIMonitor(config MonitorConfig) (*APIMonitor, error) {
 
 	// 创建logger
 	logger := log.New(logFile, "[API-MONITOR] ", log.LstdFlags|log.Lmicroseconds)
+	if archiveDir != "" {
+		logger.Printf("旧数据已归档到: %s", archiveDir)
+	}
+	if resumed {
+		logger.Printf("续接会话: 第%d次启动, 首次启动于 %s, 已完成 %d 轮",
+			session.Session, session.StartTime.Format("2006-01-02 15:04:05"), session.RoundsCompleted)
+	}
 
 	// 创建Provider
 	provider := tencent.NewClient()
@@ -166,19 +193,25 @@ func NewAPIMonitor(config MonitorConfig) (*APIMonitor, error) {
 	// 创建存储器
 	storageCfg := storage.DefaultCSVStorageConfig()
 	storageCfg.Directory = config.DataDir
+	// 长时间运行时按天轮转，已完成的文件压缩归档
+	storageCfg.RotateBy = storage.RotateDay
+	storageCfg.EnableCompress = true
 	csvStorage, err := storage.NewCSVStorage(storageCfg)
 	if err != nil {
+		logFile.Close()
 		return nil, fmt.Errorf("创建CSVStorage失败: %w", err)
 	}
 
 	// 创建安全组件
 	marketTime := timing.DefaultMarketTime()
 	intelligentLimiter := limiter.NewIntelligentLimiter(marketTime)
+	intelligentLimiter.SetAdaptiveConfig(limiter.DefaultAdaptiveConfig(config.Interval))
 
 	monitor := &APIMonitor{
 		config:             config,
 		provider:           provider,
 		storage:            csvStorage,
+		session:            session,
 		logger:             logger,
 		logFile:            logFile,
 		marketTime:         marketTime,
@@ -201,9 +234,10 @@ func (m *APIMonitor) Run(ctx context.Context) error {
 	m.logger.Printf("开始监控: %v", startTime.Format("2006-01-02 15:04:05"))
 	m.logger.Printf("交易时间检查: %t", m.marketTime.IsTradingTime())
 
-	collectionCount := 0
-	successCount := 0
-	errorCount := 0
+	// 续接会话时从上次的累计统计继续
+	collectionCount := m.session.RoundsCompleted
+	successCount := m.session.SuccessCount
+	errorCount := m.session.ErrorCount
 
 	// 主循环：使用智能限制器的安全逻辑
 	for {
@@ -219,7 +253,7 @@ func (m *APIMonitor) Run(ctx context.Context) error {
 		shouldProceed, err := m.intelligentLimiter.ShouldProceed(ctx)
 		if err != nil {
 			// 检查是否是市场时间相关的错误
-			if strings.Contains(err.Error(), "交易时段") || strings.Contains(err.Error(), "交易时间") {
+			if apperrors.Is(err, apperrors.CodeMarketClosed) {
 				m.logger.Printf("非交易时间，等待交易开始: %v", err)
 				fmt.Printf("当前非交易时间，等待交易开始...\n")
 
@@ -259,6 +293,7 @@ func (m *APIMonitor) Run(ctx context.Context) error {
 
 		// 执行数据采集并记录结果
 		shouldContinue, waitDuration, finalErr := m.collectDataWithLimiter(ctx, &successCount, &errorCount, collectionCount)
+		m.recordProgress(collectionCount, successCount, errorCount)
 
 		if finalErr != nil {
 			m.logger.Printf("致命错误，终止监控: %v", finalErr)
@@ -300,8 +335,14 @@ func (m *APIMonitor) Run(ctx context.Context) error {
 				collectionCount, currentSuccessRate, elapsed.Round(time.Second))
 		}
 
-		// 等待下一次采集（但不超过配置的间隔）
-		sleepTime := m.config.Interval - time.Since(iterationStart)
+		// 记录自适应限流状态
+		interval := m.intelligentLimiter.GetCurrentInterval()
+		stats := m.intelligentLimiter.GetLimiterStats()
+		m.logger.Printf("第%d轮限流状态: 间隔 %v, 退避级别 %d, 冻结数据检测 %d 次, 累计等待 %d 次 (%v)",
+			collectionCount, interval, stats.BackoffLevel, stats.FrozenDetections, stats.TotalWaits, stats.TotalWaitTime)
+
+		// 等待下一次采集（检测到冻结数据时间隔自动放大）
+		sleepTime := interval - time.Since(iterationStart)
 		if sleepTime > 0 {
 			select {
 			case <-time.After(sleepTime):
@@ -313,8 +354,8 @@ func (m *APIMonitor) Run(ctx context.Context) error {
 		}
 	}
 
-	// 完成统计和分析
-	if err := m.finishAndAnalyze(startTime, collectionCount, successCount, errorCount); err != nil {
+	// 完成统计和分析，续接的会话从首次启动时间算起
+	if err := m.finishAndAnalyze(m.session.StartTime, collectionCount, successCount, errorCount); err != nil {
 		return fmt.Errorf("完成分析失败: %v", err)
 	}
 
@@ -328,7 +369,7 @@ func (m *APIMonitor) collectDataWithLimiter(ctx context.Context, successCount, e
 	queryTime := time.Now()
 
 	// 一次API调用获取所有股票数据
-	result, err := m.provider.FetchStockData(ctx, m.config.Symbols)
+	result, raw, err := m.provider.FetchStockDataWithRaw(ctx, m.config.Symbols)
 
 	responseTime := time.Now()
 	requestDuration := responseTime.Sub(queryTime)
@@ -338,12 +379,14 @@ func (m *APIMonitor) collectDataWithLimiter(ctx context.Context, successCount, e
 		Timestamp:         queryTime,
 		Symbol:            strings.Join(m.config.Symbols, ","), // 多股票用逗号分隔
 		RequestDurationMs: requestDuration.Milliseconds(),
+		ResponseSizeBytes: int64(len(raw)),
 		ErrorOccurred:     err != nil,
 		ErrorMessage:      "",
 	}
 
 	if err != nil {
 		perfMetric.ErrorMessage = err.Error()
+		perfMetric.ErrorType = classifyError(perfMetric.ErrorMessage)
 		m.logger.Printf("第%d轮采集失败: %v (耗时: %v)", roundNum, err, requestDuration)
 
 		// 保存失败的性能指标
@@ -384,11 +427,25 @@ func (m *APIMonitor) collectDataWithLimiter(ctx context.Context, successCount, e
 	return m.intelligentLimiter.RecordResult(err, responseData)
 }
 
+// recordProgress 更新会话清单中的累计统计，写入失败只记录日志
+func (m *APIMonitor) recordProgress(rounds, successCount, errorCount int) {
+	m.session.RoundsCompleted = rounds
+	m.session.SuccessCount = successCount
+	m.session.ErrorCount = errorCount
+	m.session.UpdatedAt = time.Now()
+	if err := m.session.save(m.config.DataDir); err != nil {
+		m.logger.Printf("保存会话清单失败: %v", err)
+	}
+}
+
 // finishAndAnalyze 完成监控并分析数据
 func (m *APIMonitor) finishAndAnalyze(startTime time.Time, collectionCount, successCount, errorCount int) error {
 	totalDuration := time.Since(startTime)
 	totalAttempts := collectionCount * len(m.config.Symbols)
-	finalSuccessRate := float64(successCount) / float64(totalAttempts) * 100
+	finalSuccessRate := 0.0
+	if totalAttempts > 0 {
+		finalSuccessRate = float64(successCount) / float64(totalAttempts) * 100
+	}
 
 	// 记录完成统计
 	m.logger.Printf("=== 监控完成统计 ===")
@@ -407,95 +464,104 @@ func (m *APIMonitor) finishAndAnalyze(startTime time.Time, collectionCount, succ
 	fmt.Printf("数据点成功率: %.2f%%\n", finalSuccessRate)
 	fmt.Printf("数据保存位置: %s\n", m.config.DataDir)
 
-	// 生成分析报告
-	return m.generateAnalysisReport(startTime, totalDuration, collectionCount, successCount, finalSuccessRate)
+	report := &AnalysisReport{
+		Session:     m.session.Session,
+		Symbols:     m.config.Symbols,
+		Interval:    m.config.Interval.String(),
+		StartTime:   startTime,
+		EndTime:     time.Now(),
+		Duration:    totalDuration.Round(time.Second).String(),
+		Rounds:      collectionCount,
+		ErrorRounds: errorCount,
+		DataPoints:  successCount,
+		SuccessRate: finalSuccessRate,
+	}
+	return m.generateAnalysisReport(report)
 }
 
-// generateAnalysisReport 生成数据分析报告
-func (m *APIMonitor) generateAnalysisReport(startTime time.Time, duration time.Duration,
-	collections, successPoints int, successRate float64) error {
+// generateAnalysisReport 从存储加载本会话的性能指标和行情，计算统计后生成文本和 JSON 报告
+func (m *APIMonitor) generateAnalysisReport(report *AnalysisReport) error {
+	metrics, quotes, err := m.loadCollectedData(report.StartTime)
+	if err != nil {
+		return fmt.Errorf("加载采集数据失败: %w", err)
+	}
+	report.ReportStats = computeReportStats(metrics, quotes, m.marketTime.TradingSessionAt)
+	report.DataFiles = m.dataFiles()
+	report.LogFile = m.logFile.Name()
+
+	basePath := filepath.Join(m.config.DataDir, fmt.Sprintf("analysis_report_s%d_%s",
+		m.session.Session, report.EndTime.Format("20060102_150405")))
+	if err := os.WriteFile(basePath+".txt", []byte(report.text()), 0644); err != nil {
+		return err
+	}
+	if err := report.writeJSON(basePath + ".json"); err != nil {
+		return err
+	}
 
-	reportPath := filepath.Join(m.config.DataDir, fmt.Sprintf("analysis_report_%s.txt",
-		time.Now().Format("20060102_150405")))
+	m.logger.Printf("分析报告已生成: %s.txt, %s.json", basePath, basePath)
+	fmt.Printf("分析报告已生成: %s.txt\n", basePath)
+	return nil
+}
 
-	reportFile, err := os.Create(reportPath)
+// loadCollectedData 从存储读回 since 之后的性能指标和本次监控股票的行情样本
+func (m *APIMonitor) loadCollectedData(since time.Time) ([]PerformanceMetric, []core.StockData, error) {
+	records, err := m.storage.Load(context.Background(), core.Query{})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer reportFile.Close()
-
-	report := fmt.Sprintf(`=== API监控分析报告 ===
-
-监控配置:
-- 股票代码: %v
-- 监控时长: %v
-- 采集间隔: %v
-- 开始时间: %s
-- 结束时间: %s
-
-执行统计:
-- API调用轮次: %d
-- 成功数据点: %d
-- 数据点成功率: %.2f%%
-- 平均轮次间隔: %.1f秒
-- 实际运行时间: %v
-
-数据质量:
-- CSV数据文件: %s
-- 性能指标文件: %s
-- 日志文件: %s
-
-分析建议:
-- 建议使用Excel或数据分析工具进一步分析CSV文件
-- 重点关注时间字段(Field30)的变化模式
-- 对比不同股票的价格和成交量变化
-- 分析API响应时间的分布特征
-
-报告生成时间: %s
-`,
-		m.config.Symbols,
-		m.config.Duration,
-		m.config.Interval,
-		startTime.Format("2006-01-02 15:04:05"),
-		time.Now().Format("2006-01-02 15:04:05"),
-		collections,
-		successPoints,
-		successRate,
-		m.config.Interval.Seconds(),
-		duration.Round(time.Second),
-		filepath.Join(m.config.DataDir, "api_data.csv"),
-		filepath.Join(m.config.DataDir, "performance.csv"),
-		m.logFile.Name(),
-		time.Now().Format("2006-01-02 15:04:05"),
-	)
 
-	_, err = reportFile.WriteString(report)
-	if err == nil {
-		m.logger.Printf("分析报告已生成: %s", reportPath)
-		fmt.Printf("分析报告已生成: %s\n", reportPath)
+	symbols := make(map[string]bool, len(m.config.Symbols))
+	for _, symbol := range m.config.Symbols {
+		symbols[symbol] = true
 	}
 
-	return err
+	var metrics []PerformanceMetric
+	var quotes []core.StockData
+	for _, record := range records {
+		if quote, ok := record.(core.StockData); ok {
+			if symbols[quote.Symbol] {
+				quotes = append(quotes, quote)
+			}
+			continue
+		}
+		if metric, ok := decodePerformanceMetric(record); ok && !metric.Timestamp.Before(since) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, quotes, nil
 }
 
-// waitForTradingTime 等待直到交易时间开始
-func (m *APIMonitor) waitForTradingTime(ctx context.Context) error {
-	// 每分钟检查一次是否到了交易时间
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+// dataFiles 列出数据目录中的CSV文件
+func (m *APIMonitor) dataFiles() []string {
+	var files []string
+	for _, pattern := range []string{"*.csv", "*.csv.gz"} {
+		matches, _ := filepath.Glob(filepath.Join(m.config.DataDir, pattern))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
 
+// waitForTradingTime 休眠直到下一个交易时段开始（跳过午休、周末和节假日）
+func (m *APIMonitor) waitForTradingTime(ctx context.Context) error {
 	for {
+		wait := m.intelligentLimiter.TimeUntilNextSession()
+		if wait <= 0 {
+			return nil // 交易时间开始，可以返回
+		}
+
+		// 输出等待状态
+		next := m.marketTime.Now().Add(wait).Format("2006-01-02 15:04:05")
+		fmt.Printf("等待交易时间开始，下个交易时段: %s（%v 后）\n", next, wait.Round(time.Second))
+		m.logger.Printf("等待交易时间开始，下个交易时段: %s", next)
+
+		// 醒来后重新计算，避免时钟调整导致提前开始
+		timer := time.NewTimer(wait)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return ctx.Err()
-		case <-ticker.C:
-			if m.marketTime.IsTradingTime() {
-				return nil // 交易时间开始，可以返回
-			}
-			// 输出等待状态
-			currentTime := time.Now().Format("15:04:05")
-			fmt.Printf("[%s] 等待交易时间开始...\n", currentTime)
-			m.logger.Printf("等待交易时间开始，当前时间: %s", currentTime)
+		case <-timer.C:
 		}
 	}
 }
@@ -514,23 +580,3 @@ func (m *APIMonitor) Close() {
 		m.logFile.Close()
 	}
 }
-
-// cleanupOldData 清理旧数据
-func cleanupOldData(dataDir string) error {
-	patterns := []string{"*.csv", "*.txt", "logs/*"}
-
-	for _, pattern := range patterns {
-		matches, err := filepath.Glob(filepath.Join(dataDir, pattern))
-		if err != nil {
-			continue
-		}
-
-		for _, match := range matches {
-			if err := os.Remove(match); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("删除文件失败 %s: %v", match, err)
-			}
-		}
-	}
-
-	return nil
-}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// fakeFetcher 返回固定行情的测试数据源，避免请求真实 API
+type fakeFetcher struct {
+	price float64
+}
+
+func (f *fakeFetcher) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	f.price += 0.01
+	result := make([]core.StockData, len(symbols))
+	for i, symbol := range symbols {
+		result[i] = core.StockData{Symbol: symbol, Price: f.price, Volume: int64(i + 1), Timestamp: time.Now()}
+	}
+	return result, strings.Repeat("x", 100*len(symbols)), nil
+}
+
+func newTestMonitorConfig(dir string) MonitorConfig {
+	return MonitorConfig{
+		Symbols:  []string{"600000", "000001"},
+		Interval: 3 * time.Second,
+		DataDir:  dir,
+		LogDir:   filepath.Join(dir, "logs"),
+	}
+}
+
+// runRounds 创建监控器执行若干轮采集后关闭，模拟一次进程运行
+func runRounds(t *testing.T, config MonitorConfig, rounds int) *SessionManifest {
+	t.Helper()
+	monitor, err := NewAPIMonitor(config)
+	require.NoError(t, err)
+	monitor.provider = &fakeFetcher{price: 10}
+	monitor.intelligentLimiter.InitializeBatch(config.Symbols)
+
+	ctx := context.Background()
+	collectionCount := monitor.session.RoundsCompleted
+	successCount, errorCount := monitor.session.SuccessCount, monitor.session.ErrorCount
+	for i := 0; i < rounds; i++ {
+		collectionCount++
+		_, _, err := monitor.collectDataWithLimiter(ctx, &successCount, &errorCount, collectionCount)
+		require.NoError(t, err)
+		monitor.recordProgress(collectionCount, successCount, errorCount)
+	}
+	session := *monitor.session
+	monitor.Close()
+	return &session
+}
+
+// csvRows 返回目录中文件名包含 recordType 的CSV文件的所有行
+func csvRows(t *testing.T, dir, recordType string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+recordType+"*.csv"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	content, err := os.ReadFile(matches[0])
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestAPIMonitor_RestartResumesSessionAndAppends(t *testing.T) {
+	dir := t.TempDir()
+	config := newTestMonitorConfig(dir)
+
+	first := runRounds(t, config, 2)
+	assert.Equal(t, 1, first.Session)
+	assert.Equal(t, 2, first.RoundsCompleted)
+
+	second := runRounds(t, config, 3)
+	assert.Equal(t, 2, second.Session)
+	assert.Equal(t, 5, second.RoundsCompleted)
+	assert.Equal(t, 10, second.SuccessCount)
+	assert.True(t, first.StartTime.Equal(second.StartTime), "续接会话保留首次启动时间")
+
+	stockRows := csvRows(t, dir, "stock_data")
+	require.Len(t, stockRows, 1+5*2, "两次运行的数据都保留")
+	perfRows := csvRows(t, dir, "unknown")
+	require.Len(t, perfRows, 1+5)
+	for _, rows := range [][]string{stockRows, perfRows} {
+		assert.Equal(t, "timestamp,type,symbol,data", rows[0])
+		for _, row := range rows[1:] {
+			assert.False(t, strings.HasPrefix(row, "timestamp,"), "表头不应重复")
+		}
+	}
+
+	manifest, err := loadSessionManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 2, manifest.Session)
+	assert.Equal(t, 5, manifest.RoundsCompleted)
+	assert.Equal(t, config.Symbols, manifest.Symbols)
+	assert.False(t, manifest.ResumedAt.IsZero())
+}
+
+func TestAPIMonitor_ChangedSymbolsStartsNewSession(t *testing.T) {
+	dir := t.TempDir()
+	config := newTestMonitorConfig(dir)
+	runRounds(t, config, 2)
+
+	config.Symbols = []string{"600519"}
+	session := runRounds(t, config, 1)
+	assert.Equal(t, 2, session.Session)
+	assert.Equal(t, 1, session.RoundsCompleted, "配置变化时重新统计")
+	assert.Len(t, csvRows(t, dir, "stock_data"), 1+2*2+1, "已有数据继续追加")
+}
+
+func TestAPIMonitor_FreshArchivesOldData(t *testing.T) {
+	dir := t.TempDir()
+	config := newTestMonitorConfig(dir)
+	runRounds(t, config, 2)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "analysis_report_s1_20250825_150000.txt"), []byte("report"), 0644))
+
+	config.Fresh = true
+	session := runRounds(t, config, 1)
+	assert.Equal(t, 1, session.Session)
+	assert.Equal(t, 1, session.RoundsCompleted)
+	assert.Len(t, csvRows(t, dir, "stock_data"), 1+2)
+
+	archives, err := os.ReadDir(filepath.Join(dir, archiveDirName))
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	archiveDir := filepath.Join(dir, archiveDirName, archives[0].Name())
+
+	assert.Len(t, csvRows(t, archiveDir, "stock_data"), 1+2*2, "旧数据移动到归档目录而不是删除")
+	assert.FileExists(t, filepath.Join(archiveDir, sessionManifestFile))
+	assert.FileExists(t, filepath.Join(archiveDir, "analysis_report_s1_20250825_150000.txt"))
+	logs, err := filepath.Glob(filepath.Join(archiveDir, "logs", "monitor_s1_*.log"))
+	require.NoError(t, err)
+	assert.Len(t, logs, 1)
+}
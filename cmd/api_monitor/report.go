@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/limiter"
+)
+
+// Distribution 一组样本的分布统计，单位由字段名决定
+type Distribution struct {
+	Count int     `json:"count"`
+	Min   int64   `json:"min"`
+	Max   int64   `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   int64   `json:"p50"`
+	P90   int64   `json:"p90"`
+	P99   int64   `json:"p99"`
+}
+
+// newDistribution 排序后计算分布统计，不修改传入的切片
+func newDistribution(samples []int64) Distribution {
+	if len(samples) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]int64(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum int64
+	for _, v := range sorted {
+		sum += v
+	}
+	return Distribution{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  float64(sum) / float64(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// percentile 按最近秩法返回已排序样本的第 p 百分位数，样本为空时返回 0
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// ErrorSummary 失败请求按错误类型的统计
+type ErrorSummary struct {
+	Total  int            `json:"total"`
+	Rate   float64        `json:"rate"` // 失败请求占全部请求的百分比
+	ByType map[string]int `json:"by_type"`
+}
+
+// SymbolFreshness 单只股票的数据新鲜度，行情时间（Field30）与上一次采集相同记为一次未更新
+type SymbolFreshness struct {
+	Symbol         string    `json:"symbol"`
+	Samples        int       `json:"samples"`
+	StaleSamples   int       `json:"stale_samples"`   // 行情时间没有前进的样本数
+	Gaps           int       `json:"gaps"`            // 连续未更新的区间数
+	LongestGap     int       `json:"longest_gap"`     // 最长的连续未更新样本数
+	LastQuoteTime  time.Time `json:"last_quote_time"` // 最后一次采集到的行情时间
+	FreshnessRatio float64   `json:"freshness_ratio"` // 行情时间前进的样本占比（百分比）
+}
+
+// ReportStats 从采集结果计算出的统计数据
+type ReportStats struct {
+	Requests          int               `json:"requests"`
+	LatencyMs         Distribution      `json:"latency_ms"`
+	ResponseSizeBytes Distribution      `json:"response_size_bytes"`
+	Errors            ErrorSummary      `json:"errors"`
+	RoundsBySession   map[string]int    `json:"rounds_by_session"`
+	Freshness         []SymbolFreshness `json:"freshness"`
+}
+
+// AnalysisReport 分析报告，同时输出为文本和 JSON
+type AnalysisReport struct {
+	Session     int       `json:"session"`
+	Symbols     []string  `json:"symbols"`
+	Interval    string    `json:"interval"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	Duration    string    `json:"duration"`
+	Rounds      int       `json:"rounds"`
+	ErrorRounds int       `json:"error_rounds"`
+	DataPoints  int       `json:"data_points"`
+	SuccessRate float64   `json:"success_rate"` // 成功数据点占尝试数据点的百分比
+	DataFiles   []string  `json:"data_files"`
+	LogFile     string    `json:"log_file"`
+	ReportStats
+}
+
+// errorTypeNames 错误级别在报告中的名称
+var errorTypeNames = map[limiter.ErrorLevel]string{
+	limiter.LevelFatal:   "fatal",
+	limiter.LevelNetwork: "network",
+	limiter.LevelInvalid: "invalid",
+	limiter.LevelUnknown: "unknown",
+}
+
+// classifyError 返回错误的类型名称，用于性能指标和报告
+func classifyError(message string) string {
+	return errorTypeNames[limiter.NewErrorClassifier().Classify(errors.New(message))]
+}
+
+// computeReportStats 根据性能指标和行情样本计算统计数据。
+// quotes 需按采集顺序排列；sessionAt 返回时间所处的交易时段。
+func computeReportStats(metrics []PerformanceMetric, quotes []core.StockData, sessionAt func(time.Time) string) ReportStats {
+	stats := ReportStats{
+		Requests:        len(metrics),
+		Errors:          ErrorSummary{ByType: make(map[string]int)},
+		RoundsBySession: make(map[string]int),
+		Freshness:       []SymbolFreshness{},
+	}
+
+	var latencies, sizes []int64
+	for _, metric := range metrics {
+		latencies = append(latencies, metric.RequestDurationMs)
+		stats.RoundsBySession[sessionAt(metric.Timestamp)]++
+		if metric.ErrorOccurred {
+			errorType := metric.ErrorType
+			if errorType == "" {
+				errorType = classifyError(metric.ErrorMessage)
+			}
+			stats.Errors.Total++
+			stats.Errors.ByType[errorType]++
+			continue
+		}
+		sizes = append(sizes, metric.ResponseSizeBytes)
+	}
+	stats.LatencyMs = newDistribution(latencies)
+	stats.ResponseSizeBytes = newDistribution(sizes)
+	if len(metrics) > 0 {
+		stats.Errors.Rate = float64(stats.Errors.Total) / float64(len(metrics)) * 100
+	}
+
+	stats.Freshness = computeFreshness(quotes)
+	return stats
+}
+
+// computeFreshness 按股票统计行情时间没有前进的样本和区间
+func computeFreshness(quotes []core.StockData) []SymbolFreshness {
+	bySymbol := make(map[string]*SymbolFreshness)
+	run := make(map[string]int) // 当前连续未更新的样本数
+	var symbols []string
+
+	for _, quote := range quotes {
+		f, ok := bySymbol[quote.Symbol]
+		if !ok {
+			f = &SymbolFreshness{Symbol: quote.Symbol}
+			bySymbol[quote.Symbol] = f
+			symbols = append(symbols, quote.Symbol)
+		}
+		if f.Samples > 0 && !quote.Timestamp.After(f.LastQuoteTime) {
+			f.StaleSamples++
+			if run[quote.Symbol] == 0 {
+				f.Gaps++
+			}
+			run[quote.Symbol]++
+			if run[quote.Symbol] > f.LongestGap {
+				f.LongestGap = run[quote.Symbol]
+			}
+		} else {
+			run[quote.Symbol] = 0
+			f.LastQuoteTime = quote.Timestamp
+		}
+		f.Samples++
+	}
+
+	sort.Strings(symbols)
+	result := make([]SymbolFreshness, 0, len(symbols))
+	for _, symbol := range symbols {
+		f := bySymbol[symbol]
+		f.FreshnessRatio = float64(f.Samples-f.StaleSamples) / float64(f.Samples) * 100
+		result = append(result, *f)
+	}
+	return result
+}
+
+// decodePerformanceMetric 将从 CSV 加载的 JSON 值还原为性能指标，不是性能指标时返回 false
+func decodePerformanceMetric(value interface{}) (PerformanceMetric, bool) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return PerformanceMetric{}, false
+	}
+	if _, ok := fields["request_duration_ms"]; !ok {
+		return PerformanceMetric{}, false
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return PerformanceMetric{}, false
+	}
+	var metric PerformanceMetric
+	if err := json.Unmarshal(data, &metric); err != nil {
+		return PerformanceMetric{}, false
+	}
+	return metric, true
+}
+
+// writeJSON 将报告写入 JSON 文件
+func (r *AnalysisReport) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// text 生成文本报告
+func (r *AnalysisReport) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== API监控分析报告 ===\n\n")
+
+	fmt.Fprintf(&b, "监控配置:\n")
+	fmt.Fprintf(&b, "- 股票代码: %v\n", r.Symbols)
+	fmt.Fprintf(&b, "- 采集间隔: %s\n", r.Interval)
+	fmt.Fprintf(&b, "- 启动序号: %d\n", r.Session)
+	fmt.Fprintf(&b, "- 开始时间: %s\n", r.StartTime.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "- 结束时间: %s\n\n", r.EndTime.Format("2006-01-02 15:04:05"))
+
+	fmt.Fprintf(&b, "执行统计:\n")
+	fmt.Fprintf(&b, "- API调用轮次: %d（失败 %d）\n", r.Rounds, r.ErrorRounds)
+	fmt.Fprintf(&b, "- 成功数据点: %d\n", r.DataPoints)
+	fmt.Fprintf(&b, "- 数据点成功率: %.2f%%\n", r.SuccessRate)
+	fmt.Fprintf(&b, "- 实际运行时间: %s\n\n", r.Duration)
+
+	fmt.Fprintf(&b, "请求耗时 (ms, %d 次请求):\n", r.LatencyMs.Count)
+	fmt.Fprintf(&b, "- p50 %d / p90 %d / p99 %d\n", r.LatencyMs.P50, r.LatencyMs.P90, r.LatencyMs.P99)
+	fmt.Fprintf(&b, "- 最小 %d / 平均 %.1f / 最大 %d\n\n", r.LatencyMs.Min, r.LatencyMs.Mean, r.LatencyMs.Max)
+
+	fmt.Fprintf(&b, "响应大小 (bytes, %d 次成功请求):\n", r.ResponseSizeBytes.Count)
+	fmt.Fprintf(&b, "- p50 %d / p90 %d / p99 %d\n", r.ResponseSizeBytes.P50, r.ResponseSizeBytes.P90, r.ResponseSizeBytes.P99)
+	fmt.Fprintf(&b, "- 最小 %d / 平均 %.1f / 最大 %d\n\n", r.ResponseSizeBytes.Min, r.ResponseSizeBytes.Mean, r.ResponseSizeBytes.Max)
+
+	fmt.Fprintf(&b, "错误统计:\n")
+	fmt.Fprintf(&b, "- 失败请求: %d (%.2f%%)\n", r.Errors.Total, r.Errors.Rate)
+	for _, errorType := range sortedKeys(r.Errors.ByType) {
+		fmt.Fprintf(&b, "- %s: %d\n", errorType, r.Errors.ByType[errorType])
+	}
+	fmt.Fprintf(&b, "\n")
+
+	fmt.Fprintf(&b, "各交易时段轮次:\n")
+	for _, session := range sortedKeys(r.RoundsBySession) {
+		fmt.Fprintf(&b, "- %s: %d\n", session, r.RoundsBySession[session])
+	}
+	fmt.Fprintf(&b, "\n")
+
+	fmt.Fprintf(&b, "数据新鲜度 (行情时间未前进视为未更新):\n")
+	for _, f := range r.Freshness {
+		fmt.Fprintf(&b, "- %s: 样本 %d, 未更新 %d (%d 段, 最长连续 %d), 更新率 %.1f%%, 最后行情时间 %s\n",
+			f.Symbol, f.Samples, f.StaleSamples, f.Gaps, f.LongestGap, f.FreshnessRatio,
+			f.LastQuoteTime.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Fprintf(&b, "\n")
+
+	fmt.Fprintf(&b, "数据文件:\n")
+	for _, file := range r.DataFiles {
+		fmt.Fprintf(&b, "- %s\n", file)
+	}
+	fmt.Fprintf(&b, "- 日志文件: %s\n\n", r.LogFile)
+
+	fmt.Fprintf(&b, "报告生成时间: %s\n", r.EndTime.Format("2006-01-02 15:04:05"))
+	return b.String()
+}
+
+// sortedKeys 返回按字母排序的键
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
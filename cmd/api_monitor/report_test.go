@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func TestPercentile_NearestRank(t *testing.T) {
+	// 1..100，最近秩法下第 p 百分位数恰好是 p
+	samples := make([]int64, 100)
+	for i := range samples {
+		samples[i] = int64(100 - i)
+	}
+	d := newDistribution(samples)
+	assert.Equal(t, Distribution{Count: 100, Min: 1, Max: 100, Mean: 50.5, P50: 50, P90: 90, P99: 99}, d)
+	assert.Equal(t, int64(100), samples[0], "不修改传入的样本")
+
+	tests := []struct {
+		name   string
+		sorted []int64
+		p      float64
+		want   int64
+	}{
+		{"空样本", nil, 50, 0},
+		{"单个样本", []int64{7}, 99, 7},
+		{"p0 取最小值", []int64{1, 2, 3}, 0, 1},
+		{"p50 向上取秩", []int64{10, 20, 30, 40}, 50, 20},
+		{"p90 小样本", []int64{10, 20, 30, 40}, 90, 40},
+		{"p99 十个样本", []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 1000}, 99, 1000},
+		{"p90 十个样本", []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 1000}, 90, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, percentile(tt.sorted, tt.p))
+		})
+	}
+
+	assert.Equal(t, Distribution{}, newDistribution(nil))
+}
+
+func TestComputeReportStats_ErrorsAndSessions(t *testing.T) {
+	base := time.Date(2025, 8, 25, 10, 0, 0, 0, time.UTC)
+	metrics := []PerformanceMetric{
+		{Timestamp: base, RequestDurationMs: 100, ResponseSizeBytes: 500},
+		{Timestamp: base.Add(time.Second), RequestDurationMs: 300, ResponseSizeBytes: 700},
+		{Timestamp: base.Add(2 * time.Second), RequestDurationMs: 5000, ErrorOccurred: true, ErrorMessage: "i/o timeout"},
+		{Timestamp: base.Add(3 * time.Hour), RequestDurationMs: 50, ErrorOccurred: true, ErrorMessage: "dial tcp: refused", ErrorType: "fatal"},
+		{Timestamp: base.Add(3 * time.Hour), RequestDurationMs: 200, ResponseSizeBytes: 600},
+	}
+	sessionAt := func(ts time.Time) string {
+		if ts.Hour() < 12 {
+			return "morning"
+		}
+		return "afternoon"
+	}
+
+	stats := computeReportStats(metrics, nil, sessionAt)
+	assert.Equal(t, 5, stats.Requests)
+	assert.Equal(t, Distribution{Count: 5, Min: 50, Max: 5000, Mean: 1130, P50: 200, P90: 5000, P99: 5000}, stats.LatencyMs)
+	assert.Equal(t, Distribution{Count: 3, Min: 500, Max: 700, Mean: 600, P50: 600, P90: 700, P99: 700}, stats.ResponseSizeBytes,
+		"只统计成功请求的响应大小")
+	assert.Equal(t, ErrorSummary{Total: 2, Rate: 40, ByType: map[string]int{"network": 1, "fatal": 1}}, stats.Errors)
+	assert.Equal(t, map[string]int{"morning": 3, "afternoon": 2}, stats.RoundsBySession)
+	assert.Empty(t, stats.Freshness)
+}
+
+func TestComputeFreshness_CountsGapsWhereQuoteTimeStalls(t *testing.T) {
+	base := time.Date(2025, 8, 25, 10, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return base.Add(time.Duration(seconds) * time.Second) }
+	quotes := []core.StockData{
+		{Symbol: "600000", Timestamp: at(0)},
+		{Symbol: "000001", Timestamp: at(0)},
+		{Symbol: "600000", Timestamp: at(3)},
+		{Symbol: "000001", Timestamp: at(0)}, // 未更新
+		{Symbol: "600000", Timestamp: at(3)}, // 未更新
+		{Symbol: "000001", Timestamp: at(0)}, // 未更新
+		{Symbol: "600000", Timestamp: at(3)}, // 未更新
+		{Symbol: "000001", Timestamp: at(9)},
+		{Symbol: "600000", Timestamp: at(12)},
+		{Symbol: "000001", Timestamp: at(6)},  // 行情时间倒退也算未更新
+		{Symbol: "600000", Timestamp: at(12)}, // 未更新
+	}
+
+	freshness := computeFreshness(quotes)
+	require.Len(t, freshness, 2)
+	assert.Equal(t, SymbolFreshness{
+		Symbol: "000001", Samples: 5, StaleSamples: 3, Gaps: 2, LongestGap: 2, LastQuoteTime: at(9), FreshnessRatio: 40,
+	}, freshness[0])
+	assert.Equal(t, SymbolFreshness{
+		Symbol: "600000", Samples: 6, StaleSamples: 3, Gaps: 2, LongestGap: 2, LastQuoteTime: at(12), FreshnessRatio: 50,
+	}, freshness[1])
+}
+
+// failingFetcher 每隔一轮返回一次超时错误
+type failingFetcher struct {
+	fakeFetcher
+	calls int
+}
+
+func (f *failingFetcher) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	f.calls++
+	if f.calls%2 == 0 {
+		return nil, "", errors.New("i/o timeout")
+	}
+	return f.fakeFetcher.FetchStockDataWithRaw(ctx, symbols)
+}
+
+func TestAPIMonitor_FinishAndAnalyze_WritesTextAndJSONReports(t *testing.T) {
+	dir := t.TempDir()
+	config := newTestMonitorConfig(dir)
+	monitor, err := NewAPIMonitor(config)
+	require.NoError(t, err)
+	defer monitor.Close()
+	monitor.provider = &failingFetcher{fakeFetcher: fakeFetcher{price: 10}}
+	monitor.intelligentLimiter.InitializeBatch(config.Symbols)
+
+	ctx := context.Background()
+	successCount, errorCount := 0, 0
+	for round := 1; round <= 4; round++ {
+		_, _, err := monitor.collectDataWithLimiter(ctx, &successCount, &errorCount, round)
+		require.NoError(t, err)
+	}
+	require.NoError(t, monitor.finishAndAnalyze(monitor.session.StartTime, 4, successCount, errorCount))
+
+	reports, err := filepath.Glob(filepath.Join(dir, "analysis_report_s1_*.json"))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	texts, err := filepath.Glob(filepath.Join(dir, "analysis_report_s1_*.txt"))
+	require.NoError(t, err)
+	require.Len(t, texts, 1)
+
+	data, err := os.ReadFile(reports[0])
+	require.NoError(t, err)
+	var report AnalysisReport
+	require.NoError(t, json.Unmarshal(data, &report))
+
+	assert.Equal(t, 1, report.Session)
+	assert.Equal(t, 4, report.Rounds)
+	assert.Equal(t, 2, report.ErrorRounds)
+	assert.Equal(t, 4, report.DataPoints)
+	assert.Equal(t, 50.0, report.SuccessRate)
+	assert.Equal(t, 4, report.Requests, "性能指标从存储读回")
+	assert.Equal(t, 4, report.LatencyMs.Count)
+	assert.Equal(t, Distribution{Count: 2, Min: 200, Max: 200, Mean: 200, P50: 200, P90: 200, P99: 200}, report.ResponseSizeBytes)
+	assert.Equal(t, ErrorSummary{Total: 2, Rate: 50, ByType: map[string]int{"network": 2}}, report.Errors)
+	require.Len(t, report.Freshness, 2)
+	assert.Equal(t, 2, report.Freshness[0].Samples)
+	assert.NotEmpty(t, report.DataFiles)
+
+	text, err := os.ReadFile(texts[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(text), "p50")
+	assert.Contains(t, string(text), "network: 2")
+	assert.NotContains(t, string(text), "Excel")
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// sessionManifestFile 会话清单文件名，位于数据目录下
+	sessionManifestFile = "session.json"
+	// archiveDirName --fresh 时旧数据的归档目录，位于数据目录下
+	archiveDirName = "archive"
+)
+
+// SessionManifest 监控会话清单，每轮采集后更新，进程重启时据此续接统计
+type SessionManifest struct {
+	Session         int           `json:"session"`    // 启动序号，每次启动加一，用于区分分析报告
+	StartTime       time.Time     `json:"start_time"` // 会话首次启动的时间
+	ResumedAt       time.Time     `json:"resumed_at,omitempty"`
+	Symbols         []string      `json:"symbols"`
+	Interval        time.Duration `json:"interval"`
+	RoundsCompleted int           `json:"rounds_completed"`
+	SuccessCount    int           `json:"success_count"`
+	ErrorCount      int           `json:"error_count"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// loadSessionManifest 读取数据目录中的会话清单，不存在时返回 nil
+func loadSessionManifest(dataDir string) (*SessionManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dataDir, sessionManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest SessionManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析会话清单失败: %w", err)
+	}
+	return &manifest, nil
+}
+
+// save 先写临时文件再重命名，进程中途退出时不会留下不完整的清单
+func (s *SessionManifest) save(dataDir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dataDir, sessionManifestFile)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// startSession 开始或续接会话。
+// 已有清单的股票代码和采集间隔与本次一致时续接，保留首次启动时间和累计统计；
+// 否则重新开始统计。两种情况下启动序号都在上次基础上加一，已有数据文件继续追加。
+func startSession(dataDir string, symbols []string, interval time.Duration, now time.Time) (*SessionManifest, bool, error) {
+	previous, err := loadSessionManifest(dataDir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if previous != nil && sameSymbols(previous.Symbols, symbols) && previous.Interval == interval {
+		previous.Session++
+		previous.ResumedAt = now
+		previous.UpdatedAt = now
+		return previous, true, previous.save(dataDir)
+	}
+
+	session := &SessionManifest{
+		Session:   1,
+		StartTime: now,
+		Symbols:   symbols,
+		Interval:  interval,
+		UpdatedAt: now,
+	}
+	if previous != nil {
+		session.Session = previous.Session + 1
+	}
+	return session, false, session.save(dataDir)
+}
+
+// sameSymbols 判断两组股票代码是否相同，不考虑顺序
+func sameSymbols(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	x := append([]string(nil), a...)
+	y := append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveOldData 将数据文件、分析报告（.txt/.json）、会话清单和日志移动到 archive/<时间戳>/，返回归档目录
+func archiveOldData(dataDir string, now time.Time) (string, error) {
+	archiveDir := filepath.Join(dataDir, archiveDirName, now.Format("20060102_150405"))
+	patterns := []string{"*.csv", "*.csv.gz", "*.txt", "*.json", "logs/*"}
+
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(dataDir, pattern))
+		if err != nil {
+			continue
+		}
+
+		for _, match := range matches {
+			rel, err := filepath.Rel(dataDir, match)
+			if err != nil {
+				return "", err
+			}
+			target := filepath.Join(archiveDir, rel)
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return "", fmt.Errorf("创建归档目录失败 %s: %v", filepath.Dir(target), err)
+			}
+			if err := os.Rename(match, target); err != nil {
+				return "", fmt.Errorf("归档文件失败 %s: %v", match, err)
+			}
+		}
+	}
+
+	return archiveDir, nil
+}
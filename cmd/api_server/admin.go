@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+	"stocksub/pkg/message"
+	"stocksub/pkg/scheduler"
+)
+
+// getAdminJobs 汇总 fetcher 写入的每小时统计，按任务和提供商返回最近一小时和最近 24 小时的数量
+func (s *APIServer) getAdminJobs(c *gin.Context) {
+	if s.redisClient == nil {
+		c.JSON(503, ErrorResponse{Error: "service_unavailable", Message: "Redis is not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	summary, err := scheduler.LoadStatsSummary(ctx, s.redisClient, s.namespace, time.Now())
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load job stats")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to load job stats"})
+		return
+	}
+
+	c.JSON(200, summary)
+}
+
+// 原始响应采样查询的条数
+const (
+	defaultRawCaptureLimit = 10
+	maxRawCaptureLimit     = scheduler.MaxRawMaxEntries
+)
+
+// rawCaptureProviderPattern 提供商名称，避免拼出任意的 Redis 键
+var rawCaptureProviderPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// RawCapturesResponse 提供商最近的原始响应采样，最新的在前
+type RawCapturesResponse struct {
+	Provider string                 `json:"provider"`
+	Count    int                    `json:"count"`
+	Captures []scheduler.RawCapture `json:"captures"`
+}
+
+// getAdminRawCaptures 返回 fetcher 为开启 debug.capture_raw 的任务采样保存的原始响应
+func (s *APIServer) getAdminRawCaptures(c *gin.Context) {
+	if s.redisClient == nil {
+		c.JSON(503, ErrorResponse{Error: "service_unavailable", Message: "Redis is not configured"})
+		return
+	}
+	provider := c.Param("provider")
+	if !rawCaptureProviderPattern.MatchString(provider) {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("invalid provider %q", provider)})
+		return
+	}
+	limit := defaultRawCaptureLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxRawCaptureLimit {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("limit must be an integer between 1 and %d", maxRawCaptureLimit)})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	captures, err := scheduler.LoadRawCaptures(ctx, s.redisClient, s.namespace, provider, limit)
+	if err != nil {
+		s.logger.WithError(err).WithField("provider", provider).Error("Failed to load raw captures")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to load raw captures"})
+		return
+	}
+
+	c.JSON(200, RawCapturesResponse{Provider: provider, Count: len(captures), Captures: captures})
+}
+
+// RefreshRequest 按需刷新请求体
+type RefreshRequest struct {
+	Symbols []string `json:"symbols"`
+}
+
+// RefreshResponse 按需刷新已提交，ID 为 stream:control:fetch 中的条目 ID
+// 调用方可以轮询 /api/v1/stocks/{symbol}，updated_at 不早于 requested_at 时说明刷新已完成
+type RefreshResponse struct {
+	ID          string    `json:"id"`
+	Symbols     []string  `json:"symbols"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// refreshStreamMaxLen 控制流的近似长度上限
+const refreshStreamMaxLen = 1000
+
+// postAdminRefresh 将按需刷新请求写入 stream:control:fetch，由 fetcher 在调度之外立即获取并发布
+func (s *APIServer) postAdminRefresh(c *gin.Context) {
+	if s.redisClient == nil {
+		c.JSON(503, ErrorResponse{Error: "service_unavailable", Message: "Redis is not configured"})
+		return
+	}
+
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	symbols := normalizeSymbols(req.Symbols)
+	if len(symbols) == 0 {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "symbols is required"})
+		return
+	}
+	if limit := s.refreshMaxSymbols; limit > 0 && len(symbols) > limit {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("too many symbols: %d, maximum is %d", len(symbols), limit)})
+		return
+	}
+	var invalid []string
+	for i, symbol := range symbols {
+		parsed, err := core.ParseSymbol(symbol)
+		if err != nil {
+			invalid = append(invalid, symbol)
+			continue
+		}
+		symbols[i] = parsed.Canonical()
+	}
+	if len(invalid) > 0 {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "invalid symbols: " + strings.Join(invalid, ",")})
+		return
+	}
+	symbols = normalizeSymbols(symbols)
+
+	request := message.NewFetchRequest(symbols, c.GetString("api_key_name"))
+	values, err := request.ToValues()
+	if err != nil {
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to encode refresh request"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	id, err := s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: message.NamespaceStream(s.namespace, message.FetchControlStream),
+		MaxLen: refreshStreamMaxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to publish refresh request")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to submit refresh request"})
+		return
+	}
+
+	s.logger.WithFields(logrus.Fields{"id": id, "symbols": symbols, "requested_by": request.RequestedBy}).Info("Refresh requested")
+	c.JSON(202, RefreshResponse{ID: id, Symbols: symbols, RequestedAt: request.RequestTime()})
+}
+
+// endpointLimiter 单个接口的限流，与 API Key 的全局限流叠加生效
+// 按 API Key 名称计数，未开启鉴权时按客户端 IP 计数
+type endpointLimiter struct {
+	limit int // 每分钟允许的请求数
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// newEndpointLimiter 创建接口限流器，limit 不大于 0 时不限流
+func newEndpointLimiter(limit int) *endpointLimiter {
+	return &endpointLimiter{limit: limit, now: time.Now, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow 扣减 key 的令牌
+func (l *endpointLimiter) allow(key string) (bool, time.Duration) {
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{
+			capacity:   float64(l.limit),
+			tokens:     float64(l.limit),
+			refillRate: float64(l.limit) / 60,
+			lastRefill: now,
+		}
+		l.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
+
+func (l *endpointLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil || l.limit <= 0 {
+			c.Next()
+			return
+		}
+
+		key := c.GetString("api_key_name")
+		if key == "" {
+			key = "ip:" + c.ClientIP()
+		}
+		if ok, wait := l.allow(key); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondError(c, apperrors.NewError(apperrors.CodeRateLimited, "rate limit exceeded"), "Rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+	"stocksub/pkg/scheduler"
+)
+
+func TestGetAdminJobs_AggregatesFetcherStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	recorder := scheduler.NewStatsRecorder(client, "")
+	for i := 0; i < 3; i++ {
+		require.NoError(t, recorder.Record(context.Background(), "realtime",
+			scheduler.RunStats{Fetched: 100, Published: 100, Duration: 50 * time.Millisecond},
+			map[string]scheduler.RunStats{"tencent": {Fetched: 100, Published: 100}}))
+	}
+	require.NoError(t, recorder.Record(context.Background(), "realtime", scheduler.RunStats{Errors: 1}, nil))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/admin/jobs", s.getAdminJobs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var summary scheduler.StatsSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	job := summary.Jobs["realtime"]
+	assert.Equal(t, scheduler.StatsTotals{Runs: 4, Fetched: 300, Published: 300, Errors: 1, DurationMsSum: 150}, job.LastHour)
+	assert.Equal(t, job.LastHour, job.Last24h)
+	assert.Equal(t, int64(300), summary.Providers["tencent"].LastHour.Published)
+}
+
+func TestGetAdmin_ReadsNamespacedKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, scheduler.NewStatsRecorder(client, "staging").Record(ctx, "realtime", scheduler.RunStats{Published: 7}, nil))
+	require.NoError(t, scheduler.NewStatsRecorder(client, "").Record(ctx, "realtime", scheduler.RunStats{Published: 100}, nil))
+	capture := scheduler.NewRawCapture("tencent", "realtime", "staging-raw", nil, time.Now())
+	require.NoError(t, scheduler.NewRawCaptureRecorder(client, "staging").Record(ctx, capture, 0))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger, namespace: "staging"}
+	router := gin.New()
+	router.GET("/api/v1/admin/jobs", s.getAdminJobs)
+	router.GET("/api/v1/admin/raw/:provider", s.getAdminRawCaptures)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var summary scheduler.StatsSummary
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, int64(7), summary.Jobs["realtime"].LastHour.Published, "只读取本命名空间的统计")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/raw/tencent", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp RawCapturesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 1, resp.Count)
+	assert.Equal(t, "staging-raw", resp.Captures[0].Raw)
+	assert.False(t, mr.Exists("debug:raw:tencent"), "采样不写入默认命名空间")
+}
+
+func TestGetAdminJobs_RedisError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	mr.Close()
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/admin/jobs", s.getAdminJobs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/jobs", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestGetAdminRawCaptures_ReturnsRecentCaptures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	recorder := scheduler.NewRawCaptureRecorder(client, "")
+	start := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 15; i++ {
+		capture := scheduler.NewRawCapture("tencent", "realtime", fmt.Sprintf(`v_sh600000="%d"`, i), nil, start.Add(time.Duration(i)*time.Second))
+		require.NoError(t, recorder.Record(context.Background(), capture, 0))
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/admin/raw/:provider", s.getAdminRawCaptures)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/admin/raw/tencent")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp RawCapturesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "tencent", resp.Provider)
+	assert.Equal(t, 10, resp.Count, "默认返回 10 条")
+	assert.Equal(t, `v_sh600000="14"`, resp.Captures[0].Raw)
+
+	w = get("/api/v1/admin/raw/tencent?limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Count)
+
+	w = get("/api/v1/admin/raw/sina")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"provider":"sina","count":0,"captures":[]}`, w.Body.String())
+
+	for _, path := range []string{"/api/v1/admin/raw/tencent?limit=0", "/api/v1/admin/raw/tencent?limit=5000", "/api/v1/admin/raw/tencent?limit=x", "/api/v1/admin/raw/ten:cent"} {
+		assert.Equal(t, http.StatusBadRequest, get(path).Code, path)
+	}
+}
+
+func newRefreshTestRouter(t *testing.T, rateLimit int) (*gin.Engine, *redis.Client) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger, refreshLimiter: newEndpointLimiter(rateLimit), refreshMaxSymbols: 3}
+	router := gin.New()
+	router.POST("/api/v1/admin/refresh", s.refreshLimiter.middleware(), s.postAdminRefresh)
+	return router, client
+}
+
+func postRefresh(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/refresh", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPostAdminRefresh_PublishesControlMessage(t *testing.T) {
+	router, client := newRefreshTestRouter(t, 10)
+
+	w := postRefresh(router, `{"symbols": ["600000", " sh600000 ", "000001.SZ"]}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response RefreshResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"600000.SH", "000001.SZ"}, response.Symbols, "转换为规范形式并去重")
+
+	entries := client.XRange(context.Background(), message.FetchControlStream, "-", "+").Val()
+	require.Len(t, entries, 1)
+	assert.Equal(t, response.ID, entries[0].ID)
+	req, err := message.ParseFetchRequest(entries[0].Values)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600000.SH", "000001.SZ"}, req.Symbols)
+}
+
+func TestPostAdminRefresh_RejectsInvalidRequests(t *testing.T) {
+	router, client := newRefreshTestRouter(t, 10)
+
+	for name, body := range map[string]string{
+		"无效的请求体":  `{"symbols": "600000"}`,
+		"没有代码":    `{"symbols": []}`,
+		"超过上限":    `{"symbols": ["600000", "600036", "600519", "601398"]}`,
+		"无法识别的代码": `{"symbols": ["600000", "700000"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, postRefresh(router, body).Code)
+		})
+	}
+	assert.Zero(t, client.XLen(context.Background(), message.FetchControlStream).Val())
+}
+
+func TestPostAdminRefresh_RateLimited(t *testing.T) {
+	router, _ := newRefreshTestRouter(t, 2)
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusAccepted, postRefresh(router, `{"symbols": ["600000"]}`).Code)
+	}
+	w := postRefresh(router, `{"symbols": ["600000"]}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
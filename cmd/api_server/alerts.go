@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"stocksub/pkg/alert"
+)
+
+// listAlertRules 返回 Redis 中保存的所有告警规则，不包含 redis_collector 配置文件中的静态规则
+func (s *APIServer) listAlertRules(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	rules, err := s.alertRules.List(ctx)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list alert rules")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve alert rules"})
+		return
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// createAlertRule 创建告警规则，未指定 id 时自动生成，id 已存在时返回 409
+func (s *APIServer) createAlertRule(c *gin.Context) {
+	var rule alert.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid alert rule: " + err.Error()})
+		return
+	}
+	if rule.ID == "" {
+		rule.ID = uuid.New().String()
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.alertRules.Get(ctx, rule.ID)
+	switch {
+	case err == nil:
+		c.JSON(409, ErrorResponse{Error: "conflict", Message: "Alert rule already exists"})
+		return
+	case !errors.Is(err, alert.ErrRuleNotFound):
+		s.logger.WithError(err).WithField("rule_id", rule.ID).Error("Failed to get alert rule")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve alert rule"})
+		return
+	}
+
+	if !s.saveAlertRule(ctx, c, rule) {
+		return
+	}
+	c.JSON(201, rule)
+}
+
+// getAlertRule 返回单个告警规则
+func (s *APIServer) getAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	rule, err := s.alertRules.Get(ctx, c.Param("id"))
+	if errors.Is(err, alert.ErrRuleNotFound) {
+		c.JSON(404, ErrorResponse{Error: "not_found", Message: "Alert rule not found"})
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("rule_id", c.Param("id")).Error("Failed to get alert rule")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve alert rule"})
+		return
+	}
+
+	c.JSON(200, rule)
+}
+
+// updateAlertRule 整体替换已有的告警规则，规则 ID 以路径为准
+func (s *APIServer) updateAlertRule(c *gin.Context) {
+	var rule alert.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid alert rule: " + err.Error()})
+		return
+	}
+	rule.ID = c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.alertRules.Get(ctx, rule.ID); err != nil {
+		if errors.Is(err, alert.ErrRuleNotFound) {
+			c.JSON(404, ErrorResponse{Error: "not_found", Message: "Alert rule not found"})
+			return
+		}
+		s.logger.WithError(err).WithField("rule_id", rule.ID).Error("Failed to get alert rule")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve alert rule"})
+		return
+	}
+
+	if !s.saveAlertRule(ctx, c, rule) {
+		return
+	}
+	c.JSON(200, rule)
+}
+
+// deleteAlertRule 删除告警规则
+func (s *APIServer) deleteAlertRule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	err := s.alertRules.Delete(ctx, c.Param("id"))
+	if errors.Is(err, alert.ErrRuleNotFound) {
+		c.JSON(404, ErrorResponse{Error: "not_found", Message: "Alert rule not found"})
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("rule_id", c.Param("id")).Error("Failed to delete alert rule")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to delete alert rule"})
+		return
+	}
+
+	c.Status(204)
+}
+
+// saveAlertRule 保存规则并在失败时写出错误响应，返回是否保存成功
+func (s *APIServer) saveAlertRule(ctx context.Context, c *gin.Context, rule alert.Rule) bool {
+	err := s.alertRules.Save(ctx, rule)
+	if errors.Is(err, alert.ErrInvalidRule) {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return false
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("rule_id", rule.ID).Error("Failed to save alert rule")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to save alert rule"})
+		return false
+	}
+	return true
+}
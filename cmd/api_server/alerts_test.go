@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/alert"
+)
+
+func newAlertsTestRouter(t *testing.T) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger, alertRules: alert.NewRedisRuleStore(client, "")}
+	router := gin.New()
+	router.GET("/api/v1/alerts", s.listAlertRules)
+	router.POST("/api/v1/alerts", s.createAlertRule)
+	router.GET("/api/v1/alerts/:id", s.getAlertRule)
+	router.PUT("/api/v1/alerts/:id", s.updateAlertRule)
+	router.DELETE("/api/v1/alerts/:id", s.deleteAlertRule)
+	return router, mr
+}
+
+func doJSON(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAlertRules_CRUD(t *testing.T) {
+	router, _ := newAlertsTestRouter(t)
+
+	w := doJSON(router, http.MethodPost, "/api/v1/alerts", `{"id":"pf-11","symbol":"600000","type":"price_above","threshold":11}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = doJSON(router, http.MethodPost, "/api/v1/alerts", `{"id":"pf-11","symbol":"600000","type":"price_below","threshold":9}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// 未指定 id 时自动生成
+	w = doJSON(router, http.MethodPost, "/api/v1/alerts", `{"symbol":"600000","type":"change_percent","threshold":3,"cooldown_seconds":900}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created alert.Rule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.ID)
+	assert.Equal(t, 900, created.CooldownSeconds)
+
+	w = doJSON(router, http.MethodGet, "/api/v1/alerts", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Rules []alert.Rule `json:"rules"`
+		Count int          `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, 2, list.Count)
+
+	w = doJSON(router, http.MethodPut, "/api/v1/alerts/pf-11", `{"id":"ignored","symbol":"600000","type":"price_above","threshold":11.5}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doJSON(router, http.MethodGet, "/api/v1/alerts/pf-11", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var rule alert.Rule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.Equal(t, alert.Rule{ID: "pf-11", Symbol: "600000", Type: alert.RulePriceAbove, Threshold: 11.5}, rule)
+
+	assert.Equal(t, http.StatusNoContent, doJSON(router, http.MethodDelete, "/api/v1/alerts/pf-11", "").Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, http.MethodGet, "/api/v1/alerts/pf-11", "").Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, http.MethodDelete, "/api/v1/alerts/pf-11", "").Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(router, http.MethodPut, "/api/v1/alerts/pf-11", `{"symbol":"600000","type":"price_above","threshold":11}`).Code)
+}
+
+func TestAlertRules_InvalidRules(t *testing.T) {
+	router, mr := newAlertsTestRouter(t)
+
+	for _, body := range []string{
+		`not json`,
+		`{"symbol":"600000","type":"price_cross","threshold":11}`,
+		`{"symbol":"600000","type":"price_above"}`,
+		`{"type":"price_above","threshold":11}`,
+		`{"symbol":"600000","type":"volume_spike","threshold":0.5}`,
+	} {
+		w := doJSON(router, http.MethodPost, "/api/v1/alerts", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	assert.False(t, mr.Exists(alert.DefaultRulesKey), "invalid rules must not be persisted")
+}
+
+func TestAlertRules_RedisError(t *testing.T) {
+	router, mr := newAlertsTestRouter(t)
+	mr.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, doJSON(router, http.MethodGet, "/api/v1/alerts", "").Code)
+	assert.Equal(t, http.StatusInternalServerError,
+		doJSON(router, http.MethodPost, "/api/v1/alerts", `{"symbol":"600000","type":"price_above","threshold":11}`).Code)
+}
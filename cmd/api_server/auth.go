@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	apperrors "stocksub/pkg/error"
+)
+
+// apiKeyHeader 客户端携带 API Key 的请求头
+const apiKeyHeader = "X-API-Key"
+
+// maxLookupCacheEntries Redis 查找结果本地缓存的最大条目数
+const maxLookupCacheEntries = 10000
+
+// AuthConfig API Key 鉴权配置
+type AuthConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	DefaultRateLimit int           `mapstructure:"default_rate_limit"` // 未单独配置时每个 Key 每分钟允许的请求数
+	RedisLookup      bool          `mapstructure:"redis_lookup"`       // 是否从 Redis 查找配置文件以外的 Key
+	RedisPrefix      string        `mapstructure:"redis_prefix"`       // Redis 中 Key 的前缀，哈希字段为 name 和 rate_limit
+	LookupCacheTTL   time.Duration `mapstructure:"lookup_cache_ttl"`   // Redis 查找结果的本地缓存时间
+}
+
+// APIKeyConfig 配置文件中的单个 API Key
+type APIKeyConfig struct {
+	Key       string `mapstructure:"key"`
+	Name      string `mapstructure:"name"`
+	RateLimit int    `mapstructure:"rate_limit"` // 每分钟允许的请求数，0 表示使用默认值
+}
+
+// apiKeyInfo 已解析的 API Key
+type apiKeyInfo struct {
+	Name      string
+	RateLimit int
+}
+
+// apiKeyLookup 查找配置文件以外的 API Key，未找到时返回 nil
+type apiKeyLookup func(ctx context.Context, key string) (*apiKeyInfo, error)
+
+// tokenBucket 令牌桶，容量为每分钟请求数，按秒匀速补充
+type tokenBucket struct {
+	capacity   float64
+	tokens     float64
+	refillRate float64 // 每秒补充的令牌数
+	lastRefill time.Time
+}
+
+// take 尝试取出一个令牌，失败时返回需要等待的时间
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.lastRefill).Seconds()*b.refillRate)
+	b.lastRefill = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.refillRate * float64(time.Second))
+	return false, wait
+}
+
+// lookupEntry Redis 查找结果的本地缓存，info 为 nil 表示 Key 不存在
+type lookupEntry struct {
+	info      *apiKeyInfo
+	expiresAt time.Time
+}
+
+// apiKeyAuth API Key 鉴权与按 Key 限流
+type apiKeyAuth struct {
+	config AuthConfig
+	static map[string]*apiKeyInfo
+	lookup apiKeyLookup
+	logger *logrus.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	cached  map[string]lookupEntry
+}
+
+// newAPIKeyAuth 创建鉴权中间件，lookup 为 nil 时只接受配置文件中的 Key
+func newAPIKeyAuth(config AuthConfig, keys []APIKeyConfig, lookup apiKeyLookup, logger *logrus.Logger) *apiKeyAuth {
+	if config.DefaultRateLimit <= 0 {
+		config.DefaultRateLimit = 120
+	}
+	if config.LookupCacheTTL <= 0 {
+		config.LookupCacheTTL = 30 * time.Second
+	}
+
+	static := make(map[string]*apiKeyInfo, len(keys))
+	for _, k := range keys {
+		if k.Key == "" {
+			continue
+		}
+		static[k.Key] = &apiKeyInfo{Name: k.Name, RateLimit: k.RateLimit}
+	}
+
+	return &apiKeyAuth{
+		config:  config,
+		static:  static,
+		lookup:  lookup,
+		logger:  logger,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+		cached:  make(map[string]lookupEntry),
+	}
+}
+
+// newRedisAPIKeyLookup 从 Redis 哈希 <prefix><key> 读取 name 和 rate_limit
+func newRedisAPIKeyLookup(client *redis.Client, prefix string) apiKeyLookup {
+	if prefix == "" {
+		prefix = "apikey:"
+	}
+	return func(ctx context.Context, key string) (*apiKeyInfo, error) {
+		data, err := client.HGetAll(ctx, prefix+key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up API key: %w", err)
+		}
+		if len(data) == 0 {
+			return nil, nil
+		}
+		info := &apiKeyInfo{Name: data["name"]}
+		if raw := data["rate_limit"]; raw != "" {
+			if info.RateLimit, err = strconv.Atoi(raw); err != nil {
+				return nil, fmt.Errorf("invalid rate_limit for API key %q: %w", info.Name, err)
+			}
+		}
+		return info, nil
+	}
+}
+
+// resolve 先查配置文件，再查 Redis（带本地缓存，包括未命中结果）
+func (a *apiKeyAuth) resolve(ctx context.Context, key string) (*apiKeyInfo, error) {
+	if info, ok := a.static[key]; ok {
+		return info, nil
+	}
+	if a.lookup == nil {
+		return nil, nil
+	}
+
+	now := a.now()
+	a.mu.Lock()
+	entry, ok := a.cached[key]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.info, nil
+	}
+
+	info, err := a.lookup(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	// 未命中的 Key 也会被缓存，超过上限时清理过期条目，避免随机 Key 撑大内存
+	if len(a.cached) >= maxLookupCacheEntries {
+		for k, e := range a.cached {
+			if !now.Before(e.expiresAt) {
+				delete(a.cached, k)
+			}
+		}
+	}
+	if len(a.cached) < maxLookupCacheEntries {
+		a.cached[key] = lookupEntry{info: info, expiresAt: now.Add(a.config.LookupCacheTTL)}
+	}
+	a.mu.Unlock()
+	return info, nil
+}
+
+// allow 按 Key 扣减令牌
+func (a *apiKeyAuth) allow(key string, info *apiKeyInfo) (bool, time.Duration) {
+	limit := info.RateLimit
+	if limit <= 0 {
+		limit = a.config.DefaultRateLimit
+	}
+
+	now := a.now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	bucket, ok := a.buckets[key]
+	if !ok || bucket.capacity != float64(limit) {
+		bucket = &tokenBucket{
+			capacity:   float64(limit),
+			tokens:     float64(limit),
+			refillRate: float64(limit) / 60,
+			lastRefill: now,
+		}
+		a.buckets[key] = bucket
+	}
+	return bucket.take(now)
+}
+
+// middleware 校验 X-API-Key 并限流
+// 浏览器无法为 WebSocket 握手设置自定义请求头，因此握手请求也接受 api_key 查询参数
+func (a *apiKeyAuth) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.config.Enabled {
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" && c.GetHeader("Upgrade") == "websocket" {
+			key = c.Query("api_key")
+		}
+		if key == "" {
+			c.AbortWithStatusJSON(401, ErrorResponse{Error: "unauthorized", Message: "Missing API key"})
+			return
+		}
+
+		info, err := a.resolve(c.Request.Context(), key)
+		if err != nil {
+			a.logger.WithError(err).Error("Failed to resolve API key")
+			c.AbortWithStatusJSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to verify API key"})
+			return
+		}
+		if info == nil {
+			c.AbortWithStatusJSON(401, ErrorResponse{Error: "unauthorized", Message: "Invalid API key"})
+			return
+		}
+
+		if ok, wait := a.allow(key, info); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			respondError(c, apperrors.NewError(apperrors.CodeRateLimited, "rate limit exceeded"), "Rate limit exceeded")
+			return
+		}
+
+		c.Set("api_key_name", info.Name)
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthTestRouter(auth *apiKeyAuth) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/health", func(c *gin.Context) { c.JSON(200, gin.H{"status": "healthy"}) })
+	v1 := router.Group("/api/v1", auth.middleware())
+	v1.GET("/stocks", func(c *gin.Context) { c.JSON(200, gin.H{"key": c.GetString("api_key_name")}) })
+	return router
+}
+
+func newTestAuth(rateLimit int, lookup apiKeyLookup) *apiKeyAuth {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return newAPIKeyAuth(
+		AuthConfig{Enabled: true, DefaultRateLimit: 60},
+		[]APIKeyConfig{{Key: "valid-key", Name: "frontend", RateLimit: rateLimit}},
+		lookup,
+		logger,
+	)
+}
+
+func requestWithKey(router *gin.Engine, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		req.Header.Set(apiKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth_ValidKey(t *testing.T) {
+	router := newAuthTestRouter(newTestAuth(10, nil))
+
+	w := requestWithKey(router, "/api/v1/stocks", "valid-key")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "frontend")
+}
+
+func TestAuth_MissingOrInvalidKey(t *testing.T) {
+	router := newAuthTestRouter(newTestAuth(10, nil))
+
+	assert.Equal(t, http.StatusUnauthorized, requestWithKey(router, "/api/v1/stocks", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, requestWithKey(router, "/api/v1/stocks", "wrong-key").Code)
+	assert.Equal(t, http.StatusOK, requestWithKey(router, "/health", "").Code, "/health must stay unauthenticated")
+}
+
+func TestAuth_RateLimitExhaustion(t *testing.T) {
+	auth := newTestAuth(3, nil)
+	now := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	auth.now = func() time.Time { return now }
+	router := newAuthTestRouter(auth)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, requestWithKey(router, "/api/v1/stocks", "valid-key").Code)
+	}
+
+	w := requestWithKey(router, "/api/v1/stocks", "valid-key")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	// 3 次/分钟，每 20 秒补充一个令牌
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, http.StatusOK, requestWithKey(router, "/api/v1/stocks", "valid-key").Code)
+	assert.Equal(t, http.StatusTooManyRequests, requestWithKey(router, "/api/v1/stocks", "valid-key").Code)
+}
+
+func TestAuth_DynamicLookupIsCached(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	lookup := func(ctx context.Context, key string) (*apiKeyInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if key == "redis-key" {
+			return &apiKeyInfo{Name: "partner", RateLimit: 100}, nil
+		}
+		return nil, nil
+	}
+	router := newAuthTestRouter(newTestAuth(10, lookup))
+
+	assert.Equal(t, http.StatusOK, requestWithKey(router, "/api/v1/stocks", "redis-key").Code)
+	assert.Equal(t, http.StatusOK, requestWithKey(router, "/api/v1/stocks", "redis-key").Code)
+	assert.Equal(t, http.StatusUnauthorized, requestWithKey(router, "/api/v1/stocks", "unknown").Code)
+	assert.Equal(t, http.StatusUnauthorized, requestWithKey(router, "/api/v1/stocks", "unknown").Code)
+	assert.Equal(t, http.StatusOK, requestWithKey(router, "/api/v1/stocks", "valid-key").Code)
+	assert.Equal(t, 2, calls, "lookups, including misses, are cached and static keys skip the lookup")
+}
+
+func TestAuth_Disabled(t *testing.T) {
+	auth := newAPIKeyAuth(AuthConfig{}, nil, nil, logrus.New())
+	router := newAuthTestRouter(auth)
+
+	assert.Equal(t, http.StatusOK, requestWithKey(router, "/api/v1/stocks", "").Code)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchSymbols 单次批量查询允许的最大代码数量
+const maxBatchSymbols = 200
+
+// parseSymbolsParam 解析逗号分隔的 symbols 参数，去除空白和重复项，每个代码需通过 ValidateSymbolParam
+func parseSymbolsParam(raw string) ([]string, error) {
+	symbols := normalizeSymbols(strings.Split(raw, ","))
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("symbols parameter is empty")
+	}
+	if len(symbols) > maxBatchSymbols {
+		return nil, fmt.Errorf("too many symbols: %d, maximum is %d", len(symbols), maxBatchSymbols)
+	}
+	for _, symbol := range symbols {
+		if _, err := ValidateSymbolParam(symbol); err != nil {
+			return nil, err
+		}
+	}
+	return symbols, nil
+}
+
+func (s *APIServer) getStocksBySymbols(c *gin.Context, raw string) {
+	symbols, err := parseSymbolsParam(raw)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	stocks, _, err := s.loadSnapshots(ctx, symbols, nil)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load stock data from Redis")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+		return
+	}
+
+	response := BatchStocksResponse{
+		Data:    make([]StockResponse, 0, len(symbols)),
+		Missing: make([]string, 0),
+	}
+	includeDepth := wantDepth(c)
+	for _, symbol := range symbols {
+		if stock, ok := stocks[symbol]; ok {
+			response.Data = append(response.Data, withDepth(stock, includeDepth))
+		} else {
+			response.Missing = append(response.Missing, symbol)
+		}
+	}
+
+	parts := append(stockETagParts(response.Data...), "missing|"+strings.Join(response.Missing, ","))
+	respondWithETag(c, parts, response)
+}
+
+func (s *APIServer) getIndicesBySymbols(c *gin.Context, raw string) {
+	symbols, err := parseSymbolsParam(raw)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	_, indices, err := s.loadSnapshots(ctx, nil, symbols)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to load index data from Redis")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+		return
+	}
+
+	response := BatchIndicesResponse{
+		Data:    make([]IndexResponse, 0, len(symbols)),
+		Missing: make([]string, 0),
+	}
+	for _, symbol := range symbols {
+		if index, ok := indices[symbol]; ok {
+			response.Data = append(response.Data, *index)
+		} else {
+			response.Missing = append(response.Missing, symbol)
+		}
+	}
+
+	parts := append(indexETagParts(response.Data...), "missing|"+strings.Join(response.Missing, ","))
+	respondWithETag(c, parts, response)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchTestRouter(source *fakeSnapshotSource) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{logger: logger, loadSnapshots: source.load}
+	router := gin.New()
+	router.GET("/api/v1/stocks", s.getStocks)
+	router.GET("/api/v1/indices", s.getIndices)
+	return router
+}
+
+func newBatchTestSource() *fakeSnapshotSource {
+	source := &fakeSnapshotSource{
+		stocks:  make(map[string]*StockResponse),
+		indices: make(map[string]*IndexResponse),
+	}
+	source.set(&StockResponse{Symbol: "600000", Price: 10.5}, &IndexResponse{Symbol: "sh000001", Value: 3200.5})
+	source.set(&StockResponse{Symbol: "000001", Price: 12.3}, &IndexResponse{Symbol: "sz399001", Value: 10500.1})
+	return source
+}
+
+func TestParseSymbolsParam(t *testing.T) {
+	symbols, err := parseSymbolsParam(" 600000, 000001,,600000 ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600000", "000001"}, symbols)
+
+	_, err = parseSymbolsParam(" , ")
+	assert.Error(t, err)
+
+	many := make([]string, maxBatchSymbols+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("%06d", i)
+	}
+	_, err = parseSymbolsParam(strings.Join(many, ","))
+	assert.Error(t, err)
+
+	_, err = parseSymbolsParam(strings.Join(many[:maxBatchSymbols], ","))
+	assert.NoError(t, err)
+
+	_, err = parseSymbolsParam(`600000,") |> yield() //`)
+	assert.ErrorContains(t, err, "invalid symbol")
+}
+
+func TestGetStocks_BySymbolsKeepsOrderAndReportsMissing(t *testing.T) {
+	router := newBatchTestRouter(newBatchTestSource())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks?symbols=000001,999999,600000", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response BatchStocksResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "000001", response.Data[0].Symbol)
+	assert.Equal(t, "600000", response.Data[1].Symbol)
+	assert.Equal(t, []string{"999999"}, response.Missing)
+}
+
+func TestGetIndices_BySymbols(t *testing.T) {
+	router := newBatchTestRouter(newBatchTestSource())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/indices?symbols=sz399001,sh000001", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response BatchIndicesResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 2)
+	assert.Equal(t, "sz399001", response.Data[0].Symbol)
+	assert.Equal(t, "sh000001", response.Data[1].Symbol)
+	assert.Empty(t, response.Missing)
+	assert.Contains(t, w.Body.String(), `"missing":[]`)
+}
+
+func TestGetStocks_BySymbolsRejectsTooMany(t *testing.T) {
+	router := newBatchTestRouter(newBatchTestSource())
+
+	many := make([]string, maxBatchSymbols+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("%06d", i)
+	}
+
+	for _, path := range []string{"/api/v1/stocks", "/api/v1/indices"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?symbols="+strings.Join(many, ","), nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultChangedLimit = 500
+	maxChangedLimit     = 2000
+)
+
+// updatesKey 返回 redis_collector 维护的更新时间有序集合的键，例如 latest:updates:stock，分数为 updated_at 毫秒
+func (s *APIServer) updatesKey(kind string) string {
+	return s.keyPrefix() + "updates:" + kind
+}
+
+// parseChangedParams 校验 since 和 limit 参数，since 缺省为 0，即返回全部股票
+func parseChangedParams(c *gin.Context) (since int64, limit int, err error) {
+	if raw := c.Query("since"); raw != "" {
+		since, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || since < 0 {
+			return 0, 0, fmt.Errorf("since must be a non-negative unix timestamp in milliseconds")
+		}
+	}
+
+	limit = defaultChangedLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxChangedLimit {
+			return 0, 0, fmt.Errorf("limit must be an integer between 1 and %d", maxChangedLimit)
+		}
+	}
+	return since, limit, nil
+}
+
+// changedMembers 返回分数大于 since 的前 limit 个成员。since 是排他的，同一毫秒的成员不能拆到两页，
+// 否则下一页从该毫秒之后开始，剩余的成员不会再返回：截断位置落在同分成员中间时退回到该分数之前，
+// 整页都是同一分数时返回该分数的全部成员，可能超过 limit
+func (s *APIServer) changedMembers(ctx context.Context, since int64, limit int) ([]redis.Z, bool, error) {
+	key := s.updatesKey("stock")
+	members, err := s.redisClient.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+		Min:   "(" + strconv.FormatInt(since, 10),
+		Max:   "+inf",
+		Count: int64(limit + 1),
+	}).Result()
+	if err != nil || len(members) <= limit {
+		return members, false, err
+	}
+
+	boundary := members[limit].Score
+	members = members[:limit]
+	end := len(members)
+	for end > 0 && members[end-1].Score == boundary {
+		end--
+	}
+	if end > 0 {
+		return members[:end], true, nil
+	}
+
+	score := strconv.FormatFloat(boundary, 'f', -1, 64)
+	members, err = s.redisClient.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{Min: score, Max: score}).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	more, err := s.redisClient.ZCount(ctx, key, "("+score, "+inf").Result()
+	return members, more > 0, err
+}
+
+// getChangedStocks 返回 since（毫秒）之后更新过的股票，供轮询客户端只取变化的部分
+// 水位线取自扫描到的最大更新时间而不是服务器当前时间，客户端和服务器的时钟偏差不影响结果
+func (s *APIServer) getChangedStocks(c *gin.Context) {
+	since, limit, err := parseChangedParams(c)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	members, hasMore, err := s.changedMembers(ctx, since, limit)
+	if err != nil {
+		s.logger.WithError(err).WithField("since", since).Error("Failed to get updates from Redis")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve updates"})
+		return
+	}
+
+	keys := make([]string, len(members))
+	for i, z := range members {
+		keys[i] = s.latestKey("stock", fmt.Sprint(z.Member))
+	}
+	snapshots, err := s.readStockSnapshots(ctx, keys)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to execute Redis pipeline")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+		return
+	}
+
+	response := ChangedStocksResponse{
+		Data:      make([]StockResponse, 0, len(members)),
+		Watermark: since,
+		HasMore:   hasMore,
+	}
+	includeDepth := wantDepth(c)
+	for i, raw := range snapshots {
+		// 快照已过期的成员同样推进水位线，否则整页过期成员会让客户端停在原地
+		response.Watermark = max(response.Watermark, int64(members[i].Score))
+		if !raw.exists() {
+			continue
+		}
+		stock, err := s.parseStockSnapshot(raw)
+		if err != nil {
+			s.logger.WithError(err).WithField("symbol", members[i].Member).Warn("Failed to parse stock data")
+			continue
+		}
+		response.Data = append(response.Data, withDepth(stock, includeDepth))
+	}
+
+	c.JSON(200, response)
+}
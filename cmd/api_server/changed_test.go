@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChangedTestRouter(t *testing.T) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol", func(c *gin.Context) { c.String(http.StatusOK, "stock "+c.Param("symbol")) })
+	router.GET("/api/v1/stocks/changed", s.getChangedStocks)
+	return router, mr
+}
+
+// addChangedStock 写入与 redis_collector 相同的最新数据哈希和更新时间有序集合
+func addChangedStock(mr *miniredis.Miniredis, symbol string, updatedAtMillis int64) {
+	mr.HSet("latest:stock:"+symbol,
+		"symbol", symbol, "name", symbol, "price", "10", "change", "0", "change_percent", "0",
+		"volume", "100", "turnover", "1000", "timestamp", "1755655200", "updated_at", fmt.Sprint(updatedAtMillis/1000))
+	mr.ZAdd("latest:updates:stock", float64(updatedAtMillis), symbol)
+}
+
+func getChanged(t *testing.T, router *gin.Engine, query string) ChangedStocksResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/changed?"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp ChangedStocksResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func changedSymbols(stocks []StockResponse) []string {
+	symbols := make([]string, len(stocks))
+	for i, s := range stocks {
+		symbols[i] = s.Symbol
+	}
+	return symbols
+}
+
+func TestGetChangedStocks_ReturnsUpdatesSinceWatermark(t *testing.T) {
+	router, mr := newChangedTestRouter(t)
+	addChangedStock(mr, "600000.SH", 1755655200100)
+	addChangedStock(mr, "000001.SZ", 1755655200300)
+	addChangedStock(mr, "300750.SZ", 1755655200200)
+
+	resp := getChanged(t, router, "")
+	assert.Equal(t, []string{"600000.SH", "300750.SZ", "000001.SZ"}, changedSymbols(resp.Data), "按更新时间升序")
+	assert.Equal(t, int64(1755655200300), resp.Watermark)
+	assert.False(t, resp.HasMore)
+
+	// since 是排他的，水位线上的股票不会重复返回
+	resp = getChanged(t, router, "since=1755655200200")
+	assert.Equal(t, []string{"000001.SZ"}, changedSymbols(resp.Data))
+
+	// 没有变化时水位线保持不变
+	resp = getChanged(t, router, "since=1755655200300")
+	assert.Empty(t, resp.Data)
+	assert.NotNil(t, resp.Data)
+	assert.Equal(t, int64(1755655200300), resp.Watermark)
+}
+
+func TestGetChangedStocks_WatermarkIgnoresServerClock(t *testing.T) {
+	router, mr := newChangedTestRouter(t)
+	// 采集端时钟远快于服务器：水位线取自数据本身，而不是服务器当前时间
+	future := int64(4102444800000)
+	addChangedStock(mr, "600000.SH", future)
+
+	resp := getChanged(t, router, "since=0")
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, future, resp.Watermark)
+}
+
+func TestGetChangedStocks_PaginatesWithHasMore(t *testing.T) {
+	router, mr := newChangedTestRouter(t)
+	for i := 0; i < 5; i++ {
+		addChangedStock(mr, fmt.Sprintf("60000%d.SH", i), 1755655200000+int64(i))
+	}
+
+	var seen []string
+	since := int64(0)
+	for page := 0; ; page++ {
+		require.Less(t, page, 5)
+		resp := getChanged(t, router, fmt.Sprintf("since=%d&limit=2", since))
+		seen = append(seen, changedSymbols(resp.Data)...)
+		since = resp.Watermark
+		if !resp.HasMore {
+			break
+		}
+		assert.Len(t, resp.Data, 2)
+	}
+	assert.Equal(t, []string{"600000.SH", "600001.SH", "600002.SH", "600003.SH", "600004.SH"}, seen)
+}
+
+func TestGetChangedStocks_DoesNotSplitSameMillisecond(t *testing.T) {
+	router, mr := newChangedTestRouter(t)
+	addChangedStock(mr, "600000.SH", 1000)
+	addChangedStock(mr, "600001.SH", 2000)
+	addChangedStock(mr, "600002.SH", 2000)
+	addChangedStock(mr, "600003.SH", 3000)
+
+	// 截断位置落在 2000 的两个成员之间：退回到 2000 之前
+	resp := getChanged(t, router, "limit=2")
+	assert.Equal(t, []string{"600000.SH"}, changedSymbols(resp.Data))
+	assert.Equal(t, int64(1000), resp.Watermark)
+	assert.True(t, resp.HasMore)
+
+	// 整页都是同一毫秒：返回该毫秒的全部成员
+	resp = getChanged(t, router, "since=1000&limit=1")
+	assert.Equal(t, []string{"600001.SH", "600002.SH"}, changedSymbols(resp.Data))
+	assert.Equal(t, int64(2000), resp.Watermark)
+	assert.True(t, resp.HasMore)
+
+	resp = getChanged(t, router, "since=2000&limit=1")
+	assert.Equal(t, []string{"600003.SH"}, changedSymbols(resp.Data))
+	assert.False(t, resp.HasMore)
+}
+
+func TestGetChangedStocks_ExpiredHashesAdvanceWatermark(t *testing.T) {
+	router, mr := newChangedTestRouter(t)
+	mr.ZAdd("latest:updates:stock", 1000, "600000.SH")
+	mr.ZAdd("latest:updates:stock", 2000, "600001.SH")
+
+	resp := getChanged(t, router, "limit=2")
+	assert.Empty(t, resp.Data)
+	assert.Equal(t, int64(2000), resp.Watermark)
+}
+
+func TestGetChangedStocks_InvalidParams(t *testing.T) {
+	router, _ := newChangedTestRouter(t)
+	for _, query := range []string{"since=abc", "since=-1", "limit=0", "limit=5000"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/changed?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetChangedStocks_RouteDoesNotShadowSymbol(t *testing.T) {
+	router, _ := newChangedTestRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000.SH", nil))
+	assert.Equal(t, "stock 600000.SH", w.Body.String())
+}
+
+func TestGetChangedStocks_NamespaceReadsOnlyItsOwnKeys(t *testing.T) {
+	router, mr := newChangedTestRouter(t)
+	addChangedStock(mr, "600000.SH", 1755655200100)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger, namespace: "staging"}
+	staging := gin.New()
+	staging.GET("/api/v1/stocks/changed", s.getChangedStocks)
+
+	mr.HSet("staging:latest:stock:000001.SZ",
+		"symbol", "000001.SZ", "name", "000001.SZ", "price", "12", "change", "0", "change_percent", "0",
+		"volume", "100", "turnover", "1000", "timestamp", "1755655200", "updated_at", "1755655200")
+	mr.ZAdd("staging:latest:updates:stock", 1755655200200, "000001.SZ")
+
+	assert.Equal(t, []string{"000001.SZ"}, changedSymbols(getChanged(t, staging, "").Data))
+	assert.Equal(t, []string{"600000.SH"}, changedSymbols(getChanged(t, router, "").Data), "默认命名空间的键名不变")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	appconfig "stocksub/pkg/config"
+	"stocksub/pkg/message"
+)
+
+// Validate 检查加载后的配置，返回汇总全部问题的错误，避免配置写错时带着空值启动、之后才出现难以排查的错误
+func (c *Config) Validate() error {
+	var v appconfig.Validator
+
+	if err := message.ValidateNamespace(c.Namespace); err != nil {
+		v.Addf("namespace: %v", err)
+	}
+	v.Port("server.port", c.Server.Port)
+	v.OneOf("server.mode", c.Server.Mode, gin.DebugMode, gin.ReleaseMode, gin.TestMode)
+	v.Addr("redis.addr", c.Redis.Addr)
+
+	// 历史、K 线和日线接口始终注册，都需要 InfluxDB
+	v.URL("influxdb.url", c.InfluxDB.URL)
+	v.NotEmpty("influxdb.org", c.InfluxDB.Org)
+	v.NotEmpty("influxdb.bucket", c.InfluxDB.Bucket)
+
+	if c.Cache.Enabled {
+		v.Positive("cache.max_size", c.Cache.MaxSize)
+		v.PositiveDuration("cache.default_ttl", c.Cache.DefaultTTL)
+		v.PositiveDuration("cache.cleanup_interval", c.Cache.CleanupInterval)
+		v.PositiveDuration("cache.stock_ttl", c.Cache.StockTTL)
+		v.PositiveDuration("cache.history_ttl", c.Cache.HistoryTTL)
+	}
+
+	v.NonNegative("websocket.max_connections", int64(c.WebSocket.MaxConnections))
+	v.NonNegative("websocket.max_symbols", int64(c.WebSocket.MaxSymbols))
+	v.PositiveDuration("websocket.poll_interval", c.WebSocket.PollInterval)
+	v.PositiveDuration("websocket.ping_interval", c.WebSocket.PingInterval)
+	v.Positive("history.max_points", int64(c.History.MaxPoints))
+
+	if c.Auth.Enabled {
+		v.Positive("auth.default_rate_limit", int64(c.Auth.DefaultRateLimit))
+		v.NonNegativeDuration("auth.lookup_cache_ttl", c.Auth.LookupCacheTTL)
+	}
+	for i, key := range c.APIKeys {
+		v.NotEmpty(fmt.Sprintf("api_keys[%d].key", i), key.Key)
+		v.NonNegative(fmt.Sprintf("api_keys[%d].rate_limit", i), int64(key.RateLimit))
+	}
+
+	v.NonNegative("admin.refresh_rate_limit", int64(c.Admin.RefreshRateLimit))
+	v.NonNegative("admin.refresh_max_symbols", int64(c.Admin.RefreshMaxSymbols))
+	v.NonNegativeDuration("refdata.watch_interval", c.Refdata.WatchInterval)
+
+	v.NonNegativeDuration("timeouts.realtime", c.Timeouts.Realtime)
+	v.NonNegativeDuration("timeouts.history", c.Timeouts.History)
+	v.NonNegativeDuration("timeouts.default", c.Timeouts.Default)
+	v.NonNegativeDuration("timeouts.slow_threshold", c.Timeouts.SlowThreshold)
+
+	v.NonNegativeDuration("staleness.threshold", c.Staleness.Threshold)
+	v.NonNegativeDuration("staleness.check_interval", c.Staleness.CheckInterval)
+	if c.Staleness.DegradedPercent < 0 || c.Staleness.DegradedPercent > 100 {
+		v.Addf("staleness.degraded_percent: must be between 0 and 100, got %v", c.Staleness.DegradedPercent)
+	}
+
+	return v.Err()
+}
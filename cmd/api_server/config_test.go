@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTestConfig 从 dir（为空时只用默认值）加载配置，测试结束后重置 viper
+func loadTestConfig(t *testing.T, dir string) *Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if dir != "" {
+		viper.AddConfigPath(dir)
+	}
+	config, err := loadConfig()
+	require.NoError(t, err)
+	return config
+}
+
+func TestConfig_DefaultsAndShippedConfigAreValid(t *testing.T) {
+	assert.NoError(t, loadTestConfig(t, "").Validate())
+	assert.NoError(t, loadTestConfig(t, "../../config").Validate())
+}
+
+func TestConfig_ValidateRejectsInvalidValues(t *testing.T) {
+	for field, mutate := range map[string]func(c *Config){
+		"namespace":                  func(c *Config) { c.Namespace = "staging:eu" },
+		"server.port":                func(c *Config) { c.Server.Port = "80800" },
+		"server.mode":                func(c *Config) { c.Server.Mode = "production" },
+		"redis.addr":                 func(c *Config) { c.Redis.Addr = "localhost" },
+		"influxdb.url":               func(c *Config) { c.InfluxDB.URL = "localhost:8086" },
+		"influxdb.org":               func(c *Config) { c.InfluxDB.Org = "" },
+		"influxdb.bucket":            func(c *Config) { c.InfluxDB.Bucket = "" },
+		"cache.max_size":             func(c *Config) { c.Cache.MaxSize = 0 },
+		"cache.default_ttl":          func(c *Config) { c.Cache.DefaultTTL = 0 },
+		"cache.stock_ttl":            func(c *Config) { c.Cache.StockTTL = -time.Second },
+		"cache.history_ttl":          func(c *Config) { c.Cache.HistoryTTL = 0 },
+		"websocket.max_connections":  func(c *Config) { c.WebSocket.MaxConnections = -1 },
+		"websocket.poll_interval":    func(c *Config) { c.WebSocket.PollInterval = 0 },
+		"history.max_points":         func(c *Config) { c.History.MaxPoints = 0 },
+		"timeouts.realtime":          func(c *Config) { c.Timeouts.Realtime = -time.Second },
+		"staleness.degraded_percent": func(c *Config) { c.Staleness.DegradedPercent = 150 },
+	} {
+		config := loadTestConfig(t, "")
+		mutate(config)
+		err := config.Validate()
+		require.Error(t, err, field)
+		assert.Contains(t, err.Error(), field+":", field)
+	}
+}
+
+func TestConfig_ValidateSkipsDisabledCache(t *testing.T) {
+	config := loadTestConfig(t, "")
+	config.Cache.Enabled = false
+	config.Cache.MaxSize = 0
+	config.Cache.DefaultTTL = 0
+	assert.NoError(t, config.Validate())
+}
+
+func TestConfig_ValidateReportsAllProblems(t *testing.T) {
+	config := loadTestConfig(t, "")
+	config.Server.Port = "abc"
+	config.InfluxDB.Bucket = ""
+	config.Cache.StockTTL = 0
+
+	err := config.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid config (3 problems)")
+}
+
+func TestLoadConfig_FailsOnInvalidEnvOverride(t *testing.T) {
+	t.Setenv("API_SERVER_SERVER_PORT", "99999")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	_, err := loadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.port")
+}
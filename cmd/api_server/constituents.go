@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/refdata"
+)
+
+// ConstituentsResponse 指数成分股
+type ConstituentsResponse struct {
+	Index        string                `json:"index"`
+	Count        int                   `json:"count"`
+	TotalWeight  float64               `json:"total_weight"`
+	Constituents []refdata.Constituent `json:"constituents"`
+	LoadedAt     time.Time             `json:"loaded_at"`
+}
+
+// SectorsResponse 指数行业分布，按最新行情计算
+type SectorsResponse struct {
+	Index string `json:"index"`
+	refdata.SectorSummary
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// RefdataReloadResponse 参考数据重新加载结果
+type RefdataReloadResponse struct {
+	Indices      int       `json:"indices"`
+	Constituents int       `json:"constituents"`
+	LoadedAt     time.Time `json:"loaded_at"`
+}
+
+// indexConstituents 读取路径中指数的成分股，未配置成分股文件或指数不存在时写入错误响应
+func (s *APIServer) indexConstituents(c *gin.Context) (string, []refdata.Constituent, bool) {
+	if s.constituents == nil {
+		c.JSON(503, ErrorResponse{Error: "service_unavailable", Message: "Constituents file is not configured"})
+		return "", nil, false
+	}
+	index := core.NormalizeSymbol(c.Param("symbol"))
+	members, ok := s.constituents.Constituents(index)
+	if !ok {
+		c.JSON(404, ErrorResponse{Error: "not_found", Message: "No constituents for index " + c.Param("symbol")})
+		return "", nil, false
+	}
+	return index, members, true
+}
+
+// getIndexConstituents 返回指数成分股及权重，按权重从高到低排列
+func (s *APIServer) getIndexConstituents(c *gin.Context) {
+	index, members, ok := s.indexConstituents(c)
+	if !ok {
+		return
+	}
+
+	totalWeight := 0.0
+	for _, m := range members {
+		totalWeight += m.Weight
+	}
+	c.JSON(200, ConstituentsResponse{
+		Index:        index,
+		Count:        len(members),
+		TotalWeight:  totalWeight,
+		Constituents: members,
+		LoadedAt:     s.constituents.LoadedAt(),
+	})
+}
+
+// getIndexSectors 按行业汇总成分股的最新涨跌幅和对指数的加权贡献，缺少最新数据的成分股列在 missing 中
+func (s *APIServer) getIndexSectors(c *gin.Context) {
+	index, members, ok := s.indexConstituents(c)
+	if !ok {
+		return
+	}
+
+	symbols := make([]string, len(members))
+	for i, m := range members {
+		symbols[i] = m.Symbol
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	stocks, _, err := s.loadSnapshots(ctx, symbols, nil)
+	if err != nil {
+		s.logger.WithError(err).WithField("index", index).Error("Failed to load constituent data")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to load constituent data"})
+		return
+	}
+
+	changePercent := make(map[string]float64, len(stocks))
+	for symbol, stock := range stocks {
+		changePercent[symbol] = stock.ChangePercent
+	}
+	c.JSON(200, SectorsResponse{
+		Index:         index,
+		SectorSummary: refdata.AggregateSectors(members, changePercent),
+		Timestamp:     time.Now(),
+	})
+}
+
+// postAdminRefdataReload 立即重新加载成分股文件，加载失败时保留原数据
+func (s *APIServer) postAdminRefdataReload(c *gin.Context) {
+	if s.constituents == nil {
+		c.JSON(503, ErrorResponse{Error: "service_unavailable", Message: "Constituents file is not configured"})
+		return
+	}
+	if err := s.constituents.Reload(); err != nil {
+		s.logger.WithError(err).Error("Failed to reload constituents")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to reload constituents: " + err.Error()})
+		return
+	}
+
+	s.logger.WithField("constituents", s.constituents.Count()).Info("Constituents reloaded")
+	c.JSON(200, RefdataReloadResponse{
+		Indices:      len(s.constituents.Indices()),
+		Constituents: s.constituents.Count(),
+		LoadedAt:     s.constituents.LoadedAt(),
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/refdata"
+)
+
+const testConstituentsCSV = `index,symbol,name,weight,sector
+sh000300,600519,贵州茅台,6.0,食品饮料
+sh000300,000858,五粮液,2.0,食品饮料
+sh000300,600036,招商银行,3.0,银行
+`
+
+func newConstituentsTestRouter(t *testing.T) (*gin.Engine, *miniredis.Miniredis, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	path := filepath.Join(t.TempDir(), "constituents.csv")
+	require.NoError(t, os.WriteFile(path, []byte(testConstituentsCSV), 0o644))
+	store, err := refdata.NewConstituentStore(path)
+	require.NoError(t, err)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger, constituents: store}
+	s.loadSnapshots = s.loadLatestSnapshots
+	router := gin.New()
+	router.GET("/api/v1/indices/:symbol/constituents", s.getIndexConstituents)
+	router.GET("/api/v1/indices/:symbol/sectors", s.getIndexSectors)
+	router.POST("/api/v1/admin/refdata/reload", s.postAdminRefdataReload)
+	return router, mr, path
+}
+
+// addConstituentStock 写入与 redis_collector 相同的最新数据哈希
+func addConstituentStock(mr *miniredis.Miniredis, symbol, changePercent string) {
+	mr.HSet("latest:stock:"+symbol,
+		"symbol", symbol, "name", symbol, "price", "10", "change", "0", "change_percent", changePercent,
+		"volume", "1000", "timestamp", "1755655200", "updated_at", "1755655200")
+}
+
+func serveConstituents(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestGetIndexConstituents(t *testing.T) {
+	router, _, _ := newConstituentsTestRouter(t)
+
+	w := serveConstituents(router, http.MethodGet, "/api/v1/indices/sh000300/constituents")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp ConstituentsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "000300.SH", resp.Index)
+	assert.Equal(t, 3, resp.Count)
+	assert.InDelta(t, 11.0, resp.TotalWeight, 1e-9)
+	assert.Equal(t, "600519.SH", resp.Constituents[0].Symbol, "按权重从高到低排列")
+	assert.False(t, resp.LoadedAt.IsZero())
+
+	w = serveConstituents(router, http.MethodGet, "/api/v1/indices/sh000905/constituents")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGetIndexSectors_UsesLatestData(t *testing.T) {
+	router, mr, _ := newConstituentsTestRouter(t)
+	// 600519 使用规范键，000858 使用旧格式键，600036 缺少最新数据
+	addConstituentStock(mr, "600519.SH", "2")
+	addConstituentStock(mr, "000858", "-1")
+
+	w := serveConstituents(router, http.MethodGet, "/api/v1/indices/000300.SH/sectors")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp SectorsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	assert.Equal(t, "000300.SH", resp.Index)
+	assert.Equal(t, 3, resp.Count)
+	assert.Equal(t, 2, resp.WithData)
+	assert.InDelta(t, 0.10, resp.Contribution, 1e-9, "6% × 2% + 2% × -1%")
+	require.Len(t, resp.Sectors, 2)
+	assert.Equal(t, "食品饮料", resp.Sectors[0].Sector)
+	assert.InDelta(t, 0.5, resp.Sectors[0].AverageChangePercent, 1e-9)
+	assert.InDelta(t, 1.25, resp.Sectors[0].WeightedChangePercent, 1e-9)
+	assert.Equal(t, "银行", resp.Sectors[1].Sector)
+	assert.Zero(t, resp.Sectors[1].WithData)
+	assert.Equal(t, []string{"600036.SH"}, resp.Sectors[1].Missing)
+}
+
+func TestPostAdminRefdataReload(t *testing.T) {
+	router, _, path := newConstituentsTestRouter(t)
+
+	require.NoError(t, os.WriteFile(path, []byte(testConstituentsCSV+"sz399006,300750,宁德时代,20,电力设备\n"), 0o644))
+	w := serveConstituents(router, http.MethodPost, "/api/v1/admin/refdata/reload")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp RefdataReloadResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Indices)
+	assert.Equal(t, 4, resp.Constituents)
+
+	// 文件无效时返回错误并保留原数据
+	require.NoError(t, os.WriteFile(path, []byte("index,symbol\n"), 0o644))
+	w = serveConstituents(router, http.MethodPost, "/api/v1/admin/refdata/reload")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = serveConstituents(router, http.MethodGet, "/api/v1/indices/sz399006/constituents")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestConstituentsEndpoints_NotConfigured(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &APIServer{logger: logrus.New()}
+	router := gin.New()
+	router.GET("/api/v1/indices/:symbol/sectors", s.getIndexSectors)
+	router.POST("/api/v1/admin/refdata/reload", s.postAdminRefdataReload)
+
+	assert.Equal(t, http.StatusServiceUnavailable, serveConstituents(router, http.MethodGet, "/api/v1/indices/sh000300/sectors").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveConstituents(router, http.MethodPost, "/api/v1/admin/refdata/reload").Code)
+}
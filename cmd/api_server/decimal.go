@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+
+	"stocksub/pkg/apitypes"
+)
+
+// 以下响应嵌入了 StockResponse 或 IndexResponse，需要嵌入 apitypes.StockJSON、apitypes.IndexJSON 输出，
+// 否则提升的 MarshalJSON 会丢掉外层字段
+
+func (r stockDebugResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		apitypes.StockJSON
+		Trace DataTrace `json:"trace"`
+	}{r.StockResponse.ToJSON(), r.Trace})
+}
+
+func (r indexDebugResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		apitypes.IndexJSON
+		Trace DataTrace `json:"trace"`
+	}{r.IndexResponse.ToJSON(), r.Trace})
+}
+
+func (m MarketMover) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Rank  int     `json:"rank"`
+		Score float64 `json:"score"`
+		apitypes.StockJSON
+	}{m.Rank, m.Score, m.StockResponse.ToJSON()})
+}
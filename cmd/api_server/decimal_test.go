@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockResponse_MarshalJSONFormatsDecimals(t *testing.T) {
+	ts := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	stock := StockResponse{
+		Symbol: "600000.SH", Name: "浦发银行", Price: 10.500000000000002, Change: -0.0005, ChangePercent: -1.005,
+		Volume: 1000, Turnover: 3.2e11, Timestamp: ts, UpdatedAt: ts, TraceID: "trace-abc",
+		Depth: &OrderBook{Bids: []OrderLevel{{Price: 10.4999999999, Volume: 100}}, Asks: []OrderLevel{}},
+	}
+
+	data, err := json.Marshal(stock)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"symbol":"600000.SH","name":"浦发银行","price":10.5,"change":-0.001,"change_percent":-1.01,
+		"volume":1000,"turnover":320000000000,"timestamp":"2025-08-20T10:00:00Z","provider":"","market":"",
+		"updated_at":"2025-08-20T10:00:00Z","age_seconds":0,"is_stale":false,"depth":{"bids":[{"price":10.5,"volume":100}],"asks":[]}
+	}`, string(data))
+	assert.Contains(t, string(data), `"turnover":320000000000,`, "不使用科学计数法")
+
+	var decoded StockResponse
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, 10.5, decoded.Price)
+
+	// 嵌入 StockResponse 的响应保留外层字段
+	data, err = json.Marshal([]MarketMover{{Rank: 1, Score: 1.5, StockResponse: stock}})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `[{"rank":1,"score":1.5,"symbol":"600000.SH"`)
+	data, err = json.Marshal(stockDebugResponse{StockResponse: stock, Trace: DataTrace{TraceID: "trace-abc"}})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"price":10.5,`)
+	assert.Contains(t, string(data), `"trace":{"trace_id":"trace-abc"}`)
+
+	data, err = json.Marshal(StockResponse{Price: math.NaN()})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"price":null`)
+}
+
+func TestIndexResponse_MarshalJSONFormatsDecimals(t *testing.T) {
+	data, err := json.Marshal(IndexResponse{Symbol: "000001.SH", Value: 3200.1235, Change: 12.0, ChangePercent: 0.125})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"value":3200.124,"change":12,"change_percent":0.13,`)
+
+	data, err = json.Marshal(indexDebugResponse{IndexResponse: IndexResponse{Value: 1e-7}, Trace: DataTrace{TraceID: "trace-idx"}})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"value":0,`)
+	assert.Contains(t, string(data), `"trace":{"trace_id":"trace-idx"}`)
+}
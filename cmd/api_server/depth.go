@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"stocksub/pkg/message"
+)
+
+// wantDepth 请求是否带有 depth=1，默认不返回盘口以减小响应体
+func wantDepth(c *gin.Context) bool {
+	depth, _ := strconv.ParseBool(c.Query("depth"))
+	return depth
+}
+
+// parseOrderBook 从 Redis 快照中解析 bid_price1..ask_volume5，
+// 旧数据没有盘口字段或字段无法解析时返回 nil，不影响其余行情
+func parseOrderBook(data map[string]string) *OrderBook {
+	if _, ok := data["bid_price1"]; !ok {
+		return nil
+	}
+
+	book := &OrderBook{
+		Bids: make([]OrderLevel, 0, message.DepthLevels),
+		Asks: make([]OrderLevel, 0, message.DepthLevels),
+	}
+	for i := 1; i <= message.DepthLevels; i++ {
+		bid, ok := parseOrderLevel(data, "bid", i)
+		if !ok {
+			return nil
+		}
+		ask, ok := parseOrderLevel(data, "ask", i)
+		if !ok {
+			return nil
+		}
+		book.Bids = append(book.Bids, bid)
+		book.Asks = append(book.Asks, ask)
+	}
+	return book
+}
+
+func parseOrderLevel(data map[string]string, side string, level int) (OrderLevel, bool) {
+	n := strconv.Itoa(level)
+	price, err := strconv.ParseFloat(data[side+"_price"+n], 64)
+	if err != nil {
+		return OrderLevel{}, false
+	}
+	volume, err := strconv.ParseInt(data[side+"_volume"+n], 10, 64)
+	if err != nil {
+		return OrderLevel{}, false
+	}
+	return OrderLevel{Price: price, Volume: volume}, true
+}
+
+// withDepth 按请求决定是否保留盘口，返回副本，不修改缓存或多个连接共享的快照
+func withDepth(s *StockResponse, include bool) StockResponse {
+	stock := *s
+	if !include {
+		stock.Depth = nil
+	}
+	return stock
+}
+
+// stocksWithDepth 对列表中的每只股票应用 withDepth
+func stocksWithDepth(stocks []StockResponse, include bool) []StockResponse {
+	if include {
+		return stocks
+	}
+	result := make([]StockResponse, len(stocks))
+	for i := range stocks {
+		result[i] = withDepth(&stocks[i], false)
+	}
+	return result
+}
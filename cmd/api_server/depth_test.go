@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+)
+
+func TestParseOrderBook_ToleratesMissingFields(t *testing.T) {
+	assert.Nil(t, parseOrderBook(map[string]string{"price": "10.5"}), "旧数据没有盘口字段")
+	assert.Nil(t, parseOrderBook(map[string]string{"bid_price1": "10.49", "bid_volume1": "100"}), "盘口不完整")
+}
+
+func TestGetStock_DepthFlag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, mr := newSymbolsTestServer(t)
+	s.loadSnapshots = s.loadLatestSnapshots
+
+	// 按 redis_collector 的方式写入带盘口的快照
+	stock := message.StockData{
+		Symbol:     "600000.SH",
+		BidPrices:  []float64{10.49, 10.48, 10.47, 10.46, 10.45},
+		BidVolumes: []int64{100, 200, 300, 400, 500},
+		AskPrices:  []float64{10.5, 10.51, 10.52, 10.53, 10.54},
+		AskVolumes: []int64{600, 700, 800, 900, 1000},
+	}
+	setSnapshot(mr, "latest:stock:600000.SH", "600000.SH", "price", "10.5")
+	require.NoError(t, s.redisClient.HSet(context.Background(), "latest:stock:600000.SH", stock.DepthFields()).Err())
+	setSnapshot(mr, "latest:stock:000001.SZ", "000001.SZ", "price", "12.3")
+	mr.SAdd("latest:symbols:stock", "600000.SH", "000001.SZ")
+
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol", s.getStock)
+	router.GET("/api/v1/stocks", s.getStocks)
+
+	get := func(path string) (*httptest.ResponseRecorder, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w, w.Header().Get("ETag")
+	}
+
+	w, plainETag := get("/api/v1/stocks/600000")
+	assert.NotContains(t, w.Body.String(), "depth", "默认不返回盘口")
+
+	w, depthETag := get("/api/v1/stocks/600000?depth=1")
+	var response StockResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotNil(t, response.Depth)
+	assert.Equal(t, []OrderLevel{{Price: 10.49, Volume: 100}, {Price: 10.48, Volume: 200}, {Price: 10.47, Volume: 300}, {Price: 10.46, Volume: 400}, {Price: 10.45, Volume: 500}}, response.Depth.Bids)
+	assert.Equal(t, []OrderLevel{{Price: 10.5, Volume: 600}, {Price: 10.51, Volume: 700}, {Price: 10.52, Volume: 800}, {Price: 10.53, Volume: 900}, {Price: 10.54, Volume: 1000}}, response.Depth.Asks)
+	assert.NotEqual(t, plainETag, depthETag)
+
+	w, _ = get("/api/v1/stocks?depth=1")
+	var stocks []StockResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stocks))
+	require.Len(t, stocks, 2)
+	assert.Nil(t, stocks[0].Depth, "没有盘口的旧数据照常返回")
+	assert.NotNil(t, stocks[1].Depth)
+
+	w, _ = get("/api/v1/stocks?symbols=600000.SH")
+	assert.NotContains(t, w.Body.String(), "depth")
+}
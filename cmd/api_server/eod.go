@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"stocksub/pkg/message"
+)
+
+// defaultEODRange 未指定 start 时默认查询的交易日范围
+const defaultEODRange = 365 * 24 * time.Hour
+
+// EODRecord 一个交易日的收盘数据
+type EODRecord struct {
+	Date          string  `json:"date"`
+	Open          float64 `json:"open"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Close         float64 `json:"close"`
+	PrevClose     float64 `json:"prev_close"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	Volume        int64   `json:"volume"`
+	Turnover      float64 `json:"turnover"`
+}
+
+// EODResponse 收盘数据查询结果，start、end 为包含两端的交易日
+type EODResponse struct {
+	Symbol  string      `json:"symbol"`
+	Start   string      `json:"start"`
+	End     string      `json:"end"`
+	Count   int         `json:"count"`
+	Records []EODRecord `json:"records"`
+}
+
+// buildEODQuery 构造查询 stock_daily 测量值的 Flux 查询，每个交易日一行
+func buildEODQuery(bucket, namespace, symbol string, start, stop time.Time) string {
+	return fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "stock_daily")
+		|> filter(fn: (r) => %s)
+		|> keep(columns: ["_time", "_field", "_value"])
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+		|> limit(n: %d)
+	`, bucket, start.Format(time.RFC3339), stop.Format(time.RFC3339), namespaceFilter(namespace, symbolFilter(symbol)), maxHistoryBars)
+}
+
+// getStockEOD 查询 eod_snapshot 任务写入的收盘数据，start、end 为交易日（YYYY-MM-DD），默认最近一年
+func (s *APIServer) getStockEOD(c *gin.Context) {
+	symbol := c.Param("symbol")
+	if symbol == "" {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Symbol is required"})
+		return
+	}
+
+	end, err := message.ParseEODDate(c.DefaultQuery("end", message.EODDate(time.Now())))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid end date format, use YYYY-MM-DD"})
+		return
+	}
+	start := end.Add(-defaultEODRange)
+	if startStr := c.Query("start"); startStr != "" {
+		if start, err = message.ParseEODDate(startStr); err != nil {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid start date format, use YYYY-MM-DD"})
+			return
+		}
+	}
+	if end.Before(start) {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "end date must not be before start date"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	// 快照时间戳为交易日零点，stop 取 end 的次日零点使 end 当天包含在内
+	query := buildEODQuery(viper.GetString("influxdb.bucket"), s.namespace, symbol, start, end.AddDate(0, 0, 1))
+	result, err := s.queryAPI.Query(ctx, query)
+	if err != nil {
+		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to query InfluxDB")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to query eod data"})
+		return
+	}
+	defer result.Close()
+
+	records := make([]EODRecord, 0)
+	for result.Next() {
+		record := result.Record()
+		records = append(records, EODRecord{
+			Date:          message.EODDate(record.Time()),
+			Open:          toFloat64(record.ValueByKey("open")),
+			High:          toFloat64(record.ValueByKey("high")),
+			Low:           toFloat64(record.ValueByKey("low")),
+			Close:         toFloat64(record.ValueByKey("close")),
+			PrevClose:     toFloat64(record.ValueByKey("prev_close")),
+			Change:        toFloat64(record.ValueByKey("change")),
+			ChangePercent: toFloat64(record.ValueByKey("change_percent")),
+			Volume:        toInt64(record.ValueByKey("volume")),
+			Turnover:      toFloat64(record.ValueByKey("turnover")),
+		})
+	}
+	if err := result.Err(); err != nil {
+		s.logger.WithError(err).WithField("symbol", symbol).Error("Error reading InfluxDB result")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to read eod data"})
+		return
+	}
+
+	c.JSON(200, EODResponse{
+		Symbol:  symbol,
+		Start:   message.EODDate(start),
+		End:     message.EODDate(end),
+		Count:   len(records),
+		Records: records,
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 时间戳为交易日北京时间零点
+const eodCSV = `#datatype,string,long,dateTime:RFC3339,double,double,double,double,double,long,double
+#group,false,false,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,,,
+,result,table,_time,open,high,low,close,prev_close,volume,turnover
+,,0,2025-08-18T16:00:00Z,10.1,10.6,10,10.5,10.35,1250000,13125000
+,,0,2025-08-19T16:00:00Z,10.5,10.8,10.4,10.7,10.5,980000,10486000
+`
+
+func newEODTestRouter(queryAPI api.QueryAPI) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{queryAPI: queryAPI, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/eod", s.getStockEOD)
+	return router
+}
+
+func TestGetStockEOD_ReturnsDailyRecords(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: eodCSV}
+	router := newEODTestRouter(queryAPI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/eod?start=2025-08-19&end=2025-08-20", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	require.Len(t, queryAPI.queries, 1)
+	query := queryAPI.queries[0]
+	assert.Contains(t, query, `r._measurement == "stock_daily"`)
+	assert.Contains(t, query, `r.symbol == "600000.SH" or r.symbol == "600000"`)
+	assert.Contains(t, query, "range(start: 2025-08-19T00:00:00+08:00, stop: 2025-08-21T00:00:00+08:00)", "end 当天包含在内")
+
+	var resp EODResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "600000", resp.Symbol)
+	assert.Equal(t, "2025-08-19", resp.Start)
+	assert.Equal(t, "2025-08-20", resp.End)
+	require.Equal(t, 2, resp.Count)
+	assert.Equal(t, EODRecord{
+		Date: "2025-08-19", Open: 10.1, High: 10.6, Low: 10, Close: 10.5, PrevClose: 10.35, Volume: 1250000, Turnover: 13125000,
+	}, resp.Records[0], "日期按北京时间输出")
+	assert.Equal(t, "2025-08-20", resp.Records[1].Date)
+}
+
+func TestGetStockEOD_InvalidParamsReturn400(t *testing.T) {
+	for _, query := range []string{
+		"start=2025/08/01",
+		"end=20250820",
+		"start=2025-08-20&end=2025-08-19",
+	} {
+		t.Run(query, func(t *testing.T) {
+			queryAPI := &fakeQueryAPI{csv: eodCSV}
+			w := httptest.NewRecorder()
+			newEODTestRouter(queryAPI).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/eod?"+query, nil))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, queryAPI.queries)
+		})
+	}
+
+	// start 和 end 为同一天时只查询这一天
+	queryAPI := &fakeQueryAPI{csv: eodCSV}
+	w := httptest.NewRecorder()
+	newEODTestRouter(queryAPI).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/eod?start=2025-08-20&end=2025-08-20", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
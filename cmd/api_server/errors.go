@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	apperrors "stocksub/pkg/error"
+)
+
+// errorStatuses 错误代码对应的 HTTP 状态码，未列出的代码返回 500
+// 休市不是请求错误，返回 200 并在响应中带 market_closed 标记
+var errorStatuses = map[apperrors.ErrorCode]int{
+	apperrors.CodeMarketClosed:      http.StatusOK,
+	apperrors.CodeRateLimited:       http.StatusTooManyRequests,
+	apperrors.CodeSymbolNotFound:    http.StatusNotFound,
+	apperrors.CodeUpstreamThrottled: http.StatusServiceUnavailable,
+	apperrors.CodeNetworkTimeout:    http.StatusGatewayTimeout,
+}
+
+// errorKinds 错误代码对应的 ErrorResponse.Error 值
+var errorKinds = map[apperrors.ErrorCode]string{
+	apperrors.CodeMarketClosed:      "market_closed",
+	apperrors.CodeRateLimited:       "rate_limited",
+	apperrors.CodeSymbolNotFound:    "not_found",
+	apperrors.CodeUpstreamThrottled: "upstream_throttled",
+	apperrors.CodeNetworkTimeout:    "timeout",
+}
+
+// httpStatusFor 返回错误链中最外层错误代码对应的 HTTP 状态码
+func httpStatusFor(err error) int {
+	if status, ok := errorStatuses[apperrors.Code(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// respondError 按错误代码输出错误响应并中止后续处理，message 为返回给客户端的信息
+func respondError(c *gin.Context, err error, message string) {
+	code := apperrors.Code(err)
+	kind, ok := errorKinds[code]
+	if !ok {
+		kind = "internal_error"
+	}
+	c.AbortWithStatusJSON(httpStatusFor(err), ErrorResponse{
+		Error:        kind,
+		Message:      message,
+		Code:         code,
+		MarketClosed: code == apperrors.CodeMarketClosed,
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apperrors "stocksub/pkg/error"
+)
+
+func TestHTTPStatusFor(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"休市", apperrors.NewError(apperrors.CodeMarketClosed, "closed"), http.StatusOK},
+		{"限流", apperrors.NewError(apperrors.CodeRateLimited, "too fast"), http.StatusTooManyRequests},
+		{"代码不存在", apperrors.NewError(apperrors.CodeSymbolNotFound, "missing"), http.StatusNotFound},
+		{"上游限流", apperrors.Wrap(apperrors.CodeUpstreamThrottled, errors.New("429")), http.StatusServiceUnavailable},
+		{"超时", fmt.Errorf("load: %w", apperrors.Wrap(apperrors.CodeNetworkTimeout, errors.New("i/o timeout"))), http.StatusGatewayTimeout},
+		{"解析失败", apperrors.Wrap(apperrors.CodeParseFailure, errors.New("invalid price")), http.StatusInternalServerError},
+		{"无代码", errors.New("redis down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, httpStatusFor(tt.err))
+		})
+	}
+}
+
+func TestRespondError_Body(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	respond := func(err error) (*httptest.ResponseRecorder, ErrorResponse) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondError(c, err, "message for client")
+		var body ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w, body
+	}
+
+	w, body := respond(apperrors.NewError(apperrors.CodeMarketClosed, "交易时段已结束"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ErrorResponse{Error: "market_closed", Message: "message for client", Code: apperrors.CodeMarketClosed, MarketClosed: true}, body)
+
+	w, body = respond(errors.New("dial tcp 127.0.0.1:6379: connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, ErrorResponse{Error: "internal_error", Message: "message for client"}, body)
+	assert.NotContains(t, w.Body.String(), "6379", "内部错误信息不返回给客户端")
+}
+
+func TestGetStock_NotFoundUsesErrorCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{logger: logger, loadSnapshots: newBatchTestSource().load}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol", s.getStock)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/688981", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, ErrorResponse{Error: "not_found", Message: "Stock not found", Code: apperrors.CodeSymbolNotFound}, body)
+}
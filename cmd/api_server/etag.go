@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// realtimeMaxAge 实时行情响应的客户端缓存时间，与采集频率（约 3 秒）保持同一量级
+const realtimeMaxAge = 2 * time.Second
+
+// computeETag 根据响应的关键字段计算强 ETag
+func computeETag(parts []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// stockETagParts 提取股票的 symbol、price、updated_at 和是否过期参与 ETag 计算，返回盘口时盘口也参与计算
+func stockETagParts(stocks ...StockResponse) []string {
+	parts := make([]string, 0, len(stocks))
+	for _, stock := range stocks {
+		part := fmt.Sprintf("%s|%s|%d|%t", stock.Symbol,
+			strconv.FormatFloat(stock.Price, 'f', -1, 64), stock.UpdatedAt.UnixNano(), stock.IsStale)
+		if stock.Depth != nil {
+			part += fmt.Sprintf("|depth|%v|%v", stock.Depth.Bids, stock.Depth.Asks)
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// indexETagParts 提取指数的 symbol、value、updated_at 和是否过期参与 ETag 计算
+func indexETagParts(indices ...IndexResponse) []string {
+	parts := make([]string, 0, len(indices))
+	for _, index := range indices {
+		parts = append(parts, fmt.Sprintf("%s|%s|%d|%t", index.Symbol,
+			strconv.FormatFloat(index.Value, 'f', -1, 64), index.UpdatedAt.UnixNano(), index.IsStale))
+	}
+	return parts
+}
+
+// etagMatches 判断 If-None-Match 请求头是否命中，支持多个值、通配符和弱校验前缀
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithETag 设置 ETag 和 Cache-Control，客户端缓存仍有效时返回 304
+func respondWithETag(c *gin.Context, parts []string, body interface{}) {
+	etag := computeETag(parts)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(realtimeMaxAge.Seconds())))
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(304)
+		return
+	}
+	c.JSON(200, body)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newETagTestRouter(source *fakeSnapshotSource) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{logger: logger, loadSnapshots: source.load}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol", s.getStock)
+	router.GET("/api/v1/stocks", s.getStocks)
+	router.GET("/api/v1/indices", s.getIndices)
+	return router
+}
+
+func getWithETag(router *gin.Engine, path, etag string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestETag_RepeatRequestReturnsNotModified(t *testing.T) {
+	source := newBatchTestSource()
+	router := newETagTestRouter(source)
+
+	for _, path := range []string{
+		"/api/v1/stocks/600000",
+		"/api/v1/stocks?symbols=600000,000001",
+		"/api/v1/indices?symbols=sh000001",
+	} {
+		t.Run(path, func(t *testing.T) {
+			first := getWithETag(router, path, "")
+			require.Equal(t, http.StatusOK, first.Code)
+			etag := first.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+			assert.Equal(t, "private, max-age=2", first.Header().Get("Cache-Control"))
+
+			second := getWithETag(router, path, etag)
+			assert.Equal(t, http.StatusNotModified, second.Code)
+			assert.Empty(t, second.Body.String())
+			assert.Equal(t, etag, second.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestETag_ChangesWhenPriceOrUpdateTimeChanges(t *testing.T) {
+	source := newBatchTestSource()
+	router := newETagTestRouter(source)
+
+	etag := getWithETag(router, "/api/v1/stocks/600000", "").Header().Get("ETag")
+
+	source.set(&StockResponse{Symbol: "600000", Price: 10.6}, nil)
+	w := getWithETag(router, "/api/v1/stocks/600000", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	priceETag := w.Header().Get("ETag")
+	assert.NotEqual(t, etag, priceETag)
+
+	source.set(&StockResponse{Symbol: "600000", Price: 10.6, UpdatedAt: time.Now()}, nil)
+	w = getWithETag(router, "/api/v1/stocks/600000", priceETag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, priceETag, w.Header().Get("ETag"))
+}
+
+func TestETag_BatchMissingSymbolsAffectETag(t *testing.T) {
+	router := newETagTestRouter(newBatchTestSource())
+
+	a := getWithETag(router, "/api/v1/stocks?symbols=600000", "").Header().Get("ETag")
+	b := getWithETag(router, "/api/v1/stocks?symbols=600000,999999", "").Header().Get("ETag")
+	assert.NotEqual(t, a, b)
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := computeETag([]string{"600000|10.5|0"})
+
+	assert.True(t, etagMatches(etag, etag))
+	assert.True(t, etagMatches(`"other", `+etag, etag))
+	assert.True(t, etagMatches("W/"+etag, etag))
+	assert.True(t, etagMatches("*", etag))
+	assert.False(t, etagMatches("", etag))
+	assert.False(t, etagMatches(`"other"`, etag))
+}
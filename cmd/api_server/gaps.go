@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"stocksub/pkg/timing"
+)
+
+const (
+	gapResolution      = time.Minute         // 缺口检测按分钟统计数据点，一分钟内有数据即视为该分钟完整
+	defaultGapInterval = 3 * time.Second     // 未指定 expected_interval 时的采集间隔，与实时任务的默认间隔一致
+	defaultGapRange    = 24 * time.Hour      // 未指定 start 时默认检查最近 24 小时
+	maxGapRange        = 31 * 24 * time.Hour // 单次检查的最大时间范围
+	maxGapSymbols      = 100                 // 批量检查最多的代码数量
+)
+
+// marketLocation 交易时段按北京时间划分，请求中的时间统一转换到该时区
+var marketLocation = time.FixedZone("CST", 8*3600)
+
+// HistoryGap 交易时段内没有数据的时间窗口 [start, end)
+type HistoryGap struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Duration        string    `json:"duration"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// HistoryGapsResponse 单只股票的历史数据缺口，trading_seconds 为范围内交易时段的总长度
+type HistoryGapsResponse struct {
+	Symbol           string       `json:"symbol"`
+	Start            time.Time    `json:"start"`
+	End              time.Time    `json:"end"`
+	ExpectedInterval string       `json:"expected_interval"`
+	TradingSeconds   float64      `json:"trading_seconds"`
+	MissingSeconds   float64      `json:"missing_seconds"`
+	CoveragePercent  float64      `json:"coverage_percent"`
+	Gaps             []HistoryGap `json:"gaps"`
+}
+
+// HistoryGapsRequest 批量缺口检查请求，start、end 为 RFC3339，默认最近 24 小时
+type HistoryGapsRequest struct {
+	Symbols          []string `json:"symbols"`
+	Start            string   `json:"start"`
+	End              string   `json:"end"`
+	ExpectedInterval string   `json:"expected_interval"`
+}
+
+// HistoryGapSummary 批量检查中单只股票的汇总
+type HistoryGapSummary struct {
+	Symbol          string      `json:"symbol"`
+	CoveragePercent float64     `json:"coverage_percent"`
+	MissingSeconds  float64     `json:"missing_seconds"`
+	GapCount        int         `json:"gap_count"`
+	LongestGap      *HistoryGap `json:"longest_gap,omitempty"`
+}
+
+// HistoryGapsBatchResponse 批量缺口检查结果，按覆盖率从低到高排序
+type HistoryGapsBatchResponse struct {
+	Start            time.Time           `json:"start"`
+	End              time.Time           `json:"end"`
+	ExpectedInterval string              `json:"expected_interval"`
+	TradingSeconds   float64             `json:"trading_seconds"`
+	Symbols          []HistoryGapSummary `json:"symbols"`
+}
+
+// gapParams 缺口检查的时间范围和期望的采集间隔
+type gapParams struct {
+	start    time.Time
+	end      time.Time
+	expected time.Duration
+}
+
+// gapReport computeHistoryGaps 的计算结果
+type gapReport struct {
+	gaps    []HistoryGap
+	trading time.Duration
+	missing time.Duration
+}
+
+// coverage 交易时段内有数据的百分比，保留 2 位小数；范围内没有交易时段时为 100
+func (r gapReport) coverage() float64 {
+	if r.trading <= 0 {
+		return 100
+	}
+	return math.Round((1-float64(r.missing)/float64(r.trading))*10000) / 100
+}
+
+// longest 最长的缺口，没有缺口时返回 nil
+func (r gapReport) longest() *HistoryGap {
+	var longest *HistoryGap
+	for i := range r.gaps {
+		if longest == nil || r.gaps[i].DurationSeconds > longest.DurationSeconds {
+			longest = &r.gaps[i]
+		}
+	}
+	return longest
+}
+
+// computeHistoryGaps 在交易时段 periods 内查找没有数据的窗口。每个时间戳视为覆盖
+// [t, t+max(expected, resolution))，resolution 为时间戳的聚合粒度，原始数据点传 0；
+// 未被覆盖且不短于 expected 的时段为缺口，缺口不跨越午间休市和收盘
+func computeHistoryGaps(periods []timing.TradingPeriod, timestamps []time.Time, expected, resolution time.Duration) gapReport {
+	sorted := append([]time.Time(nil), timestamps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	span := max(expected, resolution)
+
+	var report gapReport
+	i := 0
+	for _, period := range periods {
+		report.trading += period.Duration()
+
+		// covered 之前的时间已有数据覆盖
+		covered := period.Start
+		for ; i < len(sorted) && sorted[i].Before(period.End); i++ {
+			if t := sorted[i]; t.Sub(covered) >= expected {
+				report.add(covered, t)
+			}
+			if end := sorted[i].Add(span); end.After(covered) {
+				covered = end
+			}
+		}
+		if period.End.Sub(covered) >= expected {
+			report.add(covered, period.End)
+		}
+	}
+	return report
+}
+
+func (r *gapReport) add(start, end time.Time) {
+	duration := end.Sub(start)
+	r.missing += duration
+	r.gaps = append(r.gaps, HistoryGap{Start: start, End: end, Duration: duration.String(), DurationSeconds: duration.Seconds()})
+}
+
+// parseGapParams 解析 RFC3339 的 start、end 和 expected_interval，空值使用默认值
+func parseGapParams(startStr, endStr, intervalStr string, now time.Time) (gapParams, error) {
+	params := gapParams{end: now, expected: defaultGapInterval}
+	var err error
+	if endStr != "" {
+		if params.end, err = time.Parse(time.RFC3339, endStr); err != nil {
+			return params, fmt.Errorf("invalid end time format, use RFC3339")
+		}
+	}
+	params.start = params.end.Add(-defaultGapRange)
+	if startStr != "" {
+		if params.start, err = time.Parse(time.RFC3339, startStr); err != nil {
+			return params, fmt.Errorf("invalid start time format, use RFC3339")
+		}
+	}
+	if intervalStr != "" {
+		if params.expected, err = time.ParseDuration(intervalStr); err != nil || params.expected <= 0 {
+			return params, fmt.Errorf("invalid expected_interval %q, use a positive duration such as 3s", intervalStr)
+		}
+	}
+
+	if !params.end.After(params.start) {
+		return params, fmt.Errorf("end time must be after start time")
+	}
+	if params.end.Sub(params.start) > maxGapRange {
+		return params, fmt.Errorf("requested range exceeds maximum of %s", maxGapRange)
+	}
+	params.start, params.end = params.start.In(marketLocation), params.end.In(marketLocation)
+	return params, nil
+}
+
+// buildGapCountsQuery 构造按 gapResolution 统计每只股票价格数据点数量的 Flux 查询，_time 为窗口开始时间
+func buildGapCountsQuery(bucket, namespace string, symbols []string, start, end time.Time) string {
+	filters := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		filters[i] = symbolFilter(symbol)
+	}
+	return fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "stock_realtime")
+		|> filter(fn: (r) => %s)
+		|> filter(fn: (r) => r._field == "price")
+		|> aggregateWindow(every: 1m, fn: count, createEmpty: false, timeSrc: "_start")
+		|> keep(columns: ["_time", "_value", "symbol"])
+		|> sort(columns: ["_time"])
+	`, bucket, start.Format(time.RFC3339), end.Format(time.RFC3339), namespaceFilter(namespace, strings.Join(filters, " or ")))
+}
+
+// queryGapTimestamps 查询每只股票有数据的分钟，结果按请求中的代码索引，规范形式和旧格式合并
+func (s *APIServer) queryGapTimestamps(ctx context.Context, symbols []string, params gapParams) (map[string][]time.Time, error) {
+	requested := make(map[string]string)
+	for _, symbol := range symbols {
+		for _, candidate := range symbolCandidates(symbol) {
+			requested[candidate] = symbol
+		}
+	}
+
+	query := buildGapCountsQuery(viper.GetString("influxdb.bucket"), s.namespace, symbols, params.start, params.end)
+	result, err := s.queryAPI.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query InfluxDB: %w", err)
+	}
+	defer result.Close()
+
+	timestamps := make(map[string][]time.Time, len(symbols))
+	for result.Next() {
+		record := result.Record()
+		value, _ := record.ValueByKey("symbol").(string)
+		if symbol, ok := requested[value]; ok && toInt64(record.Value()) > 0 {
+			timestamps[symbol] = append(timestamps[symbol], record.Time().In(marketLocation))
+		}
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("error reading InfluxDB result: %w", err)
+	}
+	return timestamps, nil
+}
+
+// marketTime 返回划分交易时段的 MarketTime，未配置时使用默认的休市日历
+func (s *APIServer) marketTime() *timing.MarketTime {
+	if s.market == nil {
+		return timing.DefaultMarketTime()
+	}
+	return s.market
+}
+
+// getStockHistoryGaps 按交易时段检查股票历史数据的缺口，expected_interval 默认 3s
+func (s *APIServer) getStockHistoryGaps(c *gin.Context) {
+	symbol := c.Param("symbol")
+	params, err := parseGapParams(c.Query("start"), c.Query("end"), c.Query("expected_interval"), time.Now())
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	timestamps, err := s.queryGapTimestamps(c.Request.Context(), []string{symbol}, params)
+	if err != nil {
+		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to query history gaps")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to query historical data"})
+		return
+	}
+
+	report := computeHistoryGaps(s.marketTime().TradingPeriods(params.start, params.end), timestamps[symbol], params.expected, gapResolution)
+	gaps := report.gaps
+	if gaps == nil {
+		gaps = []HistoryGap{}
+	}
+	c.JSON(200, HistoryGapsResponse{
+		Symbol:           symbol,
+		Start:            params.start,
+		End:              params.end,
+		ExpectedInterval: params.expected.String(),
+		TradingSeconds:   report.trading.Seconds(),
+		MissingSeconds:   report.missing.Seconds(),
+		CoveragePercent:  report.coverage(),
+		Gaps:             gaps,
+	})
+}
+
+// postAdminHistoryGaps 批量检查历史数据缺口，一次 Flux 查询统计全部代码，结果按覆盖率从低到高排序
+func (s *APIServer) postAdminHistoryGaps(c *gin.Context) {
+	var req HistoryGapsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid request body: " + err.Error()})
+		return
+	}
+
+	symbols := normalizeSymbols(req.Symbols)
+	if len(symbols) == 0 {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "symbols is required"})
+		return
+	}
+	if len(symbols) > maxGapSymbols {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("too many symbols: %d, maximum is %d", len(symbols), maxGapSymbols)})
+		return
+	}
+	for _, symbol := range symbols {
+		if _, err := ValidateSymbolParam(symbol); err != nil {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+			return
+		}
+	}
+	params, err := parseGapParams(req.Start, req.End, req.ExpectedInterval, time.Now())
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	timestamps, err := s.queryGapTimestamps(c.Request.Context(), symbols, params)
+	if err != nil {
+		s.logger.WithError(err).WithField("symbols", len(symbols)).Error("Failed to query history gaps")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to query historical data"})
+		return
+	}
+
+	periods := s.marketTime().TradingPeriods(params.start, params.end)
+	response := HistoryGapsBatchResponse{
+		Start:            params.start,
+		End:              params.end,
+		ExpectedInterval: params.expected.String(),
+		Symbols:          make([]HistoryGapSummary, 0, len(symbols)),
+	}
+	for _, symbol := range symbols {
+		report := computeHistoryGaps(periods, timestamps[symbol], params.expected, gapResolution)
+		response.TradingSeconds = report.trading.Seconds()
+		response.Symbols = append(response.Symbols, HistoryGapSummary{
+			Symbol:          symbol,
+			CoveragePercent: report.coverage(),
+			MissingSeconds:  report.missing.Seconds(),
+			GapCount:        len(report.gaps),
+			LongestGap:      report.longest(),
+		})
+	}
+	sort.SliceStable(response.Symbols, func(i, j int) bool {
+		a, b := response.Symbols[i], response.Symbols[j]
+		if a.CoveragePercent != b.CoveragePercent {
+			return a.CoveragePercent < b.CoveragePercent
+		}
+		return a.Symbol < b.Symbol
+	})
+
+	c.JSON(200, response)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/timing"
+)
+
+// gapClock 返回 2025-08-21（周四）北京时间的 clock 时刻，格式 15:04 或 15:04:05
+func gapClock(clock string) time.Time {
+	layout := "2006-01-02 15:04"
+	if strings.Count(clock, ":") == 2 {
+		layout += ":05"
+	}
+	t, _ := time.ParseInLocation(layout, "2025-08-21 "+clock, marketLocation)
+	return t
+}
+
+// gapMinutes 返回 [from, to) 内每分钟的开始时间，模拟按分钟聚合的查询结果
+func gapMinutes(from, to string) []time.Time {
+	var result []time.Time
+	for t := gapClock(from); t.Before(gapClock(to)); t = t.Add(time.Minute) {
+		result = append(result, t)
+	}
+	return result
+}
+
+func concatTimes(groups ...[]time.Time) []time.Time {
+	var result []time.Time
+	for _, group := range groups {
+		result = append(result, group...)
+	}
+	return result
+}
+
+func TestComputeHistoryGaps(t *testing.T) {
+	periods := timing.DefaultMarketTime().TradingPeriods(gapClock("00:00"), gapClock("23:59"))
+	require.Len(t, periods, 2)
+
+	type window struct{ start, end string }
+	tests := []struct {
+		name       string
+		timestamps []time.Time
+		want       []window
+	}{
+		{"全天连续", gapMinutes("09:13", "15:01"), nil},
+		{"午间休市没有数据不算缺口", concatTimes(gapMinutes("09:13", "11:31"), gapMinutes("12:57", "15:01")), nil},
+		{"盘中停止一小时", concatTimes(gapMinutes("09:13", "10:00"), gapMinutes("11:00", "11:31"), gapMinutes("12:57", "15:01")),
+			[]window{{"10:00:00", "11:00:00"}}},
+		{"跨午间休市的中断按时段拆分", concatTimes(gapMinutes("09:13", "11:00"), gapMinutes("13:30", "15:01")),
+			[]window{{"11:00:00", "11:30:10"}, {"12:57:30", "13:30:00"}}},
+		{"收盘前停止，收盘后不计", concatTimes(gapMinutes("09:13", "11:31"), gapMinutes("12:57", "14:00")),
+			[]window{{"14:00:00", "15:00:10"}}},
+		{"开盘后才开始采集", gapMinutes("09:30", "15:01"),
+			[]window{{"09:13:30", "09:30:00"}}},
+		{"没有数据", nil,
+			[]window{{"09:13:30", "11:30:10"}, {"12:57:30", "15:00:10"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := computeHistoryGaps(periods, tt.timestamps, 3*time.Second, gapResolution)
+			var got []window
+			for _, gap := range report.gaps {
+				got = append(got, window{gap.Start.Format("15:04:05"), gap.End.Format("15:04:05")})
+			}
+			assert.Equal(t, tt.want, got)
+
+			var missing time.Duration
+			for _, w := range tt.want {
+				missing += gapClock(w.end).Sub(gapClock(w.start))
+			}
+			assert.Equal(t, missing, report.missing)
+			assert.Equal(t, 4*time.Hour+19*time.Minute+20*time.Second, report.trading)
+		})
+	}
+}
+
+func TestComputeHistoryGaps_RawTimestamps(t *testing.T) {
+	periods := []timing.TradingPeriod{{Start: gapClock("10:00:00"), End: gapClock("10:01:00")}}
+	var timestamps []time.Time
+	for t := gapClock("10:00:00"); t.Before(gapClock("10:01:00")); t = t.Add(3 * time.Second) {
+		if t.Before(gapClock("10:00:30")) || !t.Before(gapClock("10:00:45")) {
+			timestamps = append(timestamps, t.Add(200*time.Millisecond)) // 采集时间有抖动
+		}
+	}
+
+	report := computeHistoryGaps(periods, timestamps, 3*time.Second, 0)
+	require.Len(t, report.gaps, 1)
+	assert.Equal(t, gapClock("10:00:30.2"), report.gaps[0].Start)
+	assert.Equal(t, gapClock("10:00:45.2"), report.gaps[0].End)
+	assert.Equal(t, "15s", report.gaps[0].Duration)
+	assert.Equal(t, 75.0, report.coverage())
+}
+
+func TestComputeHistoryGaps_NoTradingPeriods(t *testing.T) {
+	report := computeHistoryGaps(nil, nil, 3*time.Second, gapResolution)
+	assert.Empty(t, report.gaps)
+	assert.Equal(t, 100.0, report.coverage())
+	assert.Nil(t, report.longest())
+}
+
+func TestParseGapParams(t *testing.T) {
+	now := time.Date(2025, 8, 21, 8, 0, 0, 0, time.UTC)
+
+	params, err := parseGapParams("", "", "", now)
+	require.NoError(t, err)
+	assert.True(t, params.end.Equal(now))
+	assert.True(t, params.start.Equal(now.Add(-24*time.Hour)))
+	assert.Equal(t, 3*time.Second, params.expected)
+	assert.Equal(t, marketLocation, params.end.Location(), "按北京时间划分交易时段")
+
+	params, err = parseGapParams("2025-08-20T09:00:00+08:00", "2025-08-20T16:00:00+08:00", "5s", now)
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, params.expected)
+
+	for _, tt := range [][3]string{
+		{"yesterday", "", ""},
+		{"", "", "0s"},
+		{"", "", "fast"},
+		{"2025-08-21T00:00:00Z", "2025-08-20T00:00:00Z", ""},
+		{"2025-06-01T00:00:00Z", "2025-08-20T00:00:00Z", ""},
+	} {
+		_, err := parseGapParams(tt[0], tt[1], tt[2], now)
+		assert.Error(t, err, "%v", tt)
+	}
+}
+
+func TestBuildGapCountsQuery(t *testing.T) {
+	query := buildGapCountsQuery("stock_data", "", []string{"600000", "000001.SZ"}, gapClock("09:00"), gapClock("16:00"))
+	assert.Contains(t, query, "range(start: 2025-08-21T09:00:00+08:00, stop: 2025-08-21T16:00:00+08:00)")
+	assert.Contains(t, query, `r.symbol == "600000.SH" or r.symbol == "600000" or r.symbol == "000001.SZ" or r.symbol == "000001"`)
+	assert.Contains(t, query, `aggregateWindow(every: 1m, fn: count, createEmpty: false, timeSrc: "_start")`)
+}
+
+// gapCountsCSV 构造按分钟计数的查询结果，rows 的每个元素为 [代码, 分钟开始时间]
+func gapCountsCSV(rows [][2]string) string {
+	var b strings.Builder
+	b.WriteString("#datatype,string,long,dateTime:RFC3339,long,string\n")
+	b.WriteString("#group,false,false,false,false,true\n")
+	b.WriteString("#default,_result,,,,\n")
+	b.WriteString(",result,table,_time,_value,symbol\n")
+	for _, row := range rows {
+		fmt.Fprintf(&b, ",,0,%s,20,%s\n", row[1], row[0])
+	}
+	return b.String()
+}
+
+func newGapsTestRouter(queryAPI *fakeQueryAPI) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{queryAPI: queryAPI, logger: logger, market: timing.DefaultMarketTime()}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/history/gaps", s.getStockHistoryGaps)
+	router.POST("/api/v1/admin/history/gaps", s.postAdminHistoryGaps)
+	return router
+}
+
+// minuteRows 返回 symbol 在 [from, to) 内每分钟一行的查询结果
+func minuteRows(symbol, from, to string) [][2]string {
+	var rows [][2]string
+	for _, t := range gapMinutes(from, to) {
+		rows = append(rows, [2]string{symbol, t.UTC().Format(time.RFC3339)})
+	}
+	return rows
+}
+
+func TestGetStockHistoryGaps(t *testing.T) {
+	// 旧格式的代码和规范形式合并：上午写入旧格式，下午写入规范形式，中间缺 10:00-11:00
+	rows := append(minuteRows("600000", "09:13", "10:00"), minuteRows("600000", "11:00", "11:31")...)
+	rows = append(rows, minuteRows("600000.SH", "12:57", "15:01")...)
+	fake := &fakeQueryAPI{csv: gapCountsCSV(rows)}
+	router := newGapsTestRouter(fake)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/api/v1/stocks/600000/history/gaps?start=2025-08-21T00:00:00%2B08:00&end=2025-08-22T00:00:00%2B08:00&expected_interval=3s", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, fake.queries, 1)
+
+	var resp HistoryGapsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "600000", resp.Symbol)
+	assert.Equal(t, "3s", resp.ExpectedInterval)
+	require.Len(t, resp.Gaps, 1)
+	assert.True(t, resp.Gaps[0].Start.Equal(gapClock("10:00")))
+	assert.Equal(t, 3600.0, resp.Gaps[0].DurationSeconds)
+	assert.Equal(t, "1h0m0s", resp.Gaps[0].Duration)
+	assert.Equal(t, 15560.0, resp.TradingSeconds)
+	assert.Equal(t, 76.86, resp.CoveragePercent)
+}
+
+func TestGetStockHistoryGaps_InvalidParams(t *testing.T) {
+	fake := &fakeQueryAPI{}
+	router := newGapsTestRouter(fake)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history/gaps?expected_interval=-3s", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, fake.queries)
+}
+
+func TestPostAdminHistoryGaps_SortsByWorstCoverage(t *testing.T) {
+	rows := minuteRows("600000.SH", "09:13", "15:01")
+	rows = append(rows, minuteRows("000001.SZ", "09:13", "11:31")...)
+	rows = append(rows, minuteRows("600519.SH", "09:13", "14:00")...)
+	fake := &fakeQueryAPI{csv: gapCountsCSV(rows)}
+	router := newGapsTestRouter(fake)
+
+	body, _ := json.Marshal(HistoryGapsRequest{
+		Symbols: []string{"600000", "600519", "000001", "300750", "600000"},
+		Start:   "2025-08-21T00:00:00+08:00",
+		End:     "2025-08-22T00:00:00+08:00",
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/history/gaps", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, fake.queries, 1, "全部代码在一次查询中统计")
+
+	var resp HistoryGapsBatchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Symbols, 4)
+	var order []string
+	for _, summary := range resp.Symbols {
+		order = append(order, summary.Symbol)
+	}
+	assert.Equal(t, []string{"300750", "000001", "600519", "600000"}, order)
+
+	assert.Equal(t, 0.0, resp.Symbols[0].CoveragePercent)
+	assert.Equal(t, 2, resp.Symbols[0].GapCount)
+	assert.Equal(t, 1, resp.Symbols[1].GapCount)
+	assert.True(t, resp.Symbols[1].LongestGap.Start.Equal(gapClock("12:57:30")))
+	assert.Equal(t, 100.0, resp.Symbols[3].CoveragePercent)
+	assert.Nil(t, resp.Symbols[3].LongestGap)
+}
+
+func TestPostAdminHistoryGaps_Validation(t *testing.T) {
+	router := newGapsTestRouter(&fakeQueryAPI{})
+	tooMany := make([]string, maxGapSymbols+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("%06d", 600000+i)
+	}
+
+	for name, req := range map[string]HistoryGapsRequest{
+		"缺少代码": {},
+		"代码过多": {Symbols: tooMany},
+		"时间无效": {Symbols: []string{"600000"}, Start: "yesterday"},
+	} {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/admin/history/gaps", bytes.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
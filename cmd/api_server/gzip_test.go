@@ -0,0 +1,85 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGzipTestRouter(s *APIServer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	v1 := router.Group("/api/v1", gzipMiddleware())
+	v1.GET("/stocks/:symbol", s.getStock)
+	v1.GET("/stocks/:symbol/history", s.getStockHistory)
+	return router
+}
+
+func TestGzip_CompressesWhenAccepted(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{queryAPI: &fakeQueryAPI{csv: generateRawHistoryCSV(3000)}, logger: logger}
+	router := newGzipTestRouter(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+	reader, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	var response HistoricalResponse
+	require.NoError(t, json.NewDecoder(reader).Decode(&response))
+	assert.Len(t, response.Data, 3000)
+}
+
+func TestGzip_PlainWhenNotAccepted(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{logger: logger, loadSnapshots: newBatchTestSource().load}
+	router := newGzipTestRouter(s)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	var stock StockResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stock))
+	assert.Equal(t, "600000", stock.Symbol)
+}
+
+func TestGzip_NotModifiedHasNoBody(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{logger: logger, loadSnapshots: newBatchTestSource().load}
+	router := newGzipTestRouter(s)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Zero(t, w.Body.Len())
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/query"
+)
+
+// maxHistoryBars 单次聚合查询允许返回的最大K线数量
+const maxHistoryBars = 5000
+
+const (
+	historyFlushEvery     = 1000    // 流式输出时每写出多少条记录刷新一次
+	maxCachedHistoryBytes = 1 << 20 // 超过该大小的历史响应不写入缓存
+)
+
+// historyIntervals 支持的聚合窗口，键同时作为 Flux duration 字面量使用
+var historyIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// validateHistoryInterval 校验聚合窗口，并确保时间范围内的K线数量不超过上限
+func validateHistoryInterval(interval string, start, end time.Time) error {
+	every, ok := historyIntervals[interval]
+	if !ok {
+		return fmt.Errorf("unsupported interval %q, supported: 1m, 5m, 15m, 30m, 1h, 1d", interval)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end time must be after start time")
+	}
+	if buckets := int64(end.Sub(start) / every); buckets > maxHistoryBars {
+		return fmt.Errorf("requested range yields %d bars, maximum is %d; use a larger interval or a shorter range", buckets, maxHistoryBars)
+	}
+	return nil
+}
+
+// buildRawHistoryQuery 构造返回原始价格和成交量数据点的 Flux 查询
+func buildRawHistoryQuery(bucket, namespace, measurement, symbol string, start, end time.Time) string {
+	return fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "%s")
+		|> filter(fn: (r) => %s)
+		|> filter(fn: (r) => r._field == "price" or r._field == "volume")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+	`, bucket, start.Format(time.RFC3339), end.Format(time.RFC3339), measurement, namespaceFilter(namespace, symbolFilter(symbol)))
+}
+
+// buildRawIndexHistoryQuery 构造返回原始指数点位、涨跌、成交量和成交额数据点的 Flux 查询
+func buildRawIndexHistoryQuery(bucket, namespace, symbol string, start, end time.Time) string {
+	return fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "index_realtime")
+		|> filter(fn: (r) => %s)
+		|> filter(fn: (r) => r._field == "value" or r._field == "change" or r._field == "change_percent" or r._field == "volume" or r._field == "turnover")
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+	`, bucket, start.Format(time.RFC3339), end.Format(time.RFC3339), namespaceFilter(namespace, symbolFilter(symbol)))
+}
+
+// buildOHLCHistoryQuery 构造按 interval 聚合的 OHLC Flux 查询
+// priceField 为聚合开高低收所用的字段，sumFields（如成交量、成交额）按窗口求和
+func buildOHLCHistoryQuery(bucket, namespace, measurement, symbol, priceField string, start, end time.Time, interval string, sumFields ...string) string {
+	tables := []string{"open", "high", "low", "close"}
+	var sums strings.Builder
+	for _, field := range sumFields {
+		fmt.Fprintf(&sums, `
+		%[1]s = data
+		|> filter(fn: (r) => r._field == "%[1]s")
+		|> keep(columns: ["_time", "_field", "_value"])
+		|> aggregateWindow(every: %[2]s, fn: sum, createEmpty: false)
+`, field, interval)
+		tables = append(tables, field)
+	}
+
+	return fmt.Sprintf(`
+		data = from(bucket: "%[1]s")
+		|> range(start: %[2]s, stop: %[3]s)
+		|> filter(fn: (r) => r._measurement == "%[4]s")
+		|> filter(fn: (r) => %[5]s)
+
+		price = data
+		|> filter(fn: (r) => r._field == "%[6]s")
+		|> keep(columns: ["_time", "_field", "_value"])
+
+		open = price |> aggregateWindow(every: %[7]s, fn: first, createEmpty: false) |> set(key: "_field", value: "open")
+		high = price |> aggregateWindow(every: %[7]s, fn: max, createEmpty: false) |> set(key: "_field", value: "high")
+		low = price |> aggregateWindow(every: %[7]s, fn: min, createEmpty: false) |> set(key: "_field", value: "low")
+		close = price |> aggregateWindow(every: %[7]s, fn: last, createEmpty: false) |> set(key: "_field", value: "close")
+%[9]s
+		union(tables: [%[10]s])
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+		|> limit(n: %[8]d)
+	`, bucket, start.Format(time.RFC3339), end.Format(time.RFC3339), measurement, namespaceFilter(namespace, symbolFilter(symbol)), priceField, interval, maxHistoryBars,
+		sums.String(), strings.Join(tables, ", "))
+}
+
+// historyRecordConverter 将查询结果中的一行转换为响应中的一条记录
+type historyRecordConverter func(record *query.FluxRecord) historyRow
+
+// stockPointFromRecord 将原始查询结果中的一行转换为 HistoricalDataPoint
+func stockPointFromRecord(record *query.FluxRecord) historyRow {
+	price, _ := record.ValueByKey("price").(float64)
+	volume, _ := record.ValueByKey("volume").(int64)
+	return HistoricalDataPoint{
+		Timestamp: record.Time(),
+		Price:     price,
+		Volume:    volume,
+	}
+}
+
+// indexPointFromRecord 将指数原始查询结果中的一行转换为 IndexHistoricalDataPoint
+func indexPointFromRecord(record *query.FluxRecord) historyRow {
+	return IndexHistoricalDataPoint{
+		Timestamp:     record.Time(),
+		Value:         toFloat64(record.ValueByKey("value")),
+		Change:        toFloat64(record.ValueByKey("change")),
+		ChangePercent: toFloat64(record.ValueByKey("change_percent")),
+		Volume:        toInt64(record.ValueByKey("volume")),
+		Turnover:      toFloat64(record.ValueByKey("turnover")),
+	}
+}
+
+// legacyIndexPointFromRecord 按旧版响应格式输出指数数据点，指数点位放在 Price 字段
+// Deprecated: 仅用于 legacy=1，下个版本移除
+func legacyIndexPointFromRecord(record *query.FluxRecord) historyRow {
+	return HistoricalDataPoint{
+		Timestamp: record.Time(),
+		Price:     toFloat64(record.ValueByKey("value")),
+		Volume:    toInt64(record.ValueByKey("volume")),
+	}
+}
+
+// indexBarFromRecord 将指数聚合查询结果中的一行转换为 IndexHistoricalBar
+func indexBarFromRecord(record *query.FluxRecord) historyRow {
+	return IndexHistoricalBar{
+		Timestamp: record.Time(),
+		Open:      toFloat64(record.ValueByKey("open")),
+		High:      toFloat64(record.ValueByKey("high")),
+		Low:       toFloat64(record.ValueByKey("low")),
+		Close:     toFloat64(record.ValueByKey("close")),
+		Volume:    toInt64(record.ValueByKey("volume")),
+		Turnover:  toFloat64(record.ValueByKey("turnover")),
+	}
+}
+
+// barFromRecord 将聚合查询结果中的一行转换为 HistoricalBar
+func barFromRecord(record *query.FluxRecord) historyRow {
+	return HistoricalBar{
+		Timestamp: record.Time(),
+		Open:      toFloat64(record.ValueByKey("open")),
+		High:      toFloat64(record.ValueByKey("high")),
+		Low:       toFloat64(record.ValueByKey("low")),
+		Close:     toFloat64(record.ValueByKey("close")),
+		Volume:    toInt64(record.ValueByKey("volume")),
+	}
+}
+
+// toFloat64 兼容 InfluxDB 返回的整数和浮点数字段
+func toFloat64(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int64:
+		return float64(n)
+	case uint64:
+		return float64(n)
+	default:
+		return 0
+	}
+}
+
+// toInt64 兼容 InfluxDB 返回的整数和浮点数字段
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
+
+// cappedBuffer 最多保留 limit 字节的写入副本，超出后放弃并标记溢出
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if b.buf.Len()+len(p) > b.limit {
+		b.overflow = true
+		b.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// historyStream 一次流式历史查询的输出参数
+type historyStream struct {
+	Meta    HistoricalResponse     // 响应公共字段，JSON 格式时先于数据写出
+	Format  string                 // json、csv 或 ndjson
+	Convert historyRecordConverter // 行转换函数，决定输出的列
+	Columns []string               // CSV 表头，为空时按是否聚合使用股票数据点或K线的列
+}
+
+// historyCacheEntry 缓存的完整历史响应
+type historyCacheEntry struct {
+	ContentType        string
+	ContentDisposition string
+	Body               []byte
+}
+
+// writeHistoryCacheEntry 输出缓存的历史响应
+func writeHistoryCacheEntry(c *gin.Context, entry *historyCacheEntry) {
+	if entry.ContentDisposition != "" {
+		c.Header("Content-Disposition", entry.ContentDisposition)
+	}
+	c.Data(200, entry.ContentType, entry.Body)
+}
+
+// streamHistory 将查询结果逐条编码写出，内存占用与结果行数无关
+// 超过 historyMaxPoints 时截断：JSON 通过 truncated 字段标记，CSV/NDJSON 通过 X-Truncated trailer 标记。
+// 返回完整响应用于缓存；响应过大、被截断且无法在响应体中标记、或读取中途出错时返回 nil
+func (s *APIServer) streamHistory(c *gin.Context, stream historyStream, result *api.QueryTableResult) *historyCacheEntry {
+	// 先读出第一行，查询错误通常在此时暴露，仍可返回 500
+	hasRow := result.Next()
+	if !hasRow && result.Err() != nil {
+		s.logger.WithError(result.Err()).WithField("symbol", stream.Meta.Symbol).Error("Error reading InfluxDB result")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to read historical data"})
+		return nil
+	}
+
+	encoder := newHistoryEncoder(stream)
+	capture := &cappedBuffer{limit: maxCachedHistoryBytes}
+	w := io.MultiWriter(c.Writer, capture)
+
+	entry := &historyCacheEntry{ContentType: encoder.contentType()}
+	if stream.Format != historyFormatJSON {
+		entry.ContentDisposition = historyContentDisposition(stream.Meta, stream.Format)
+		c.Header("Content-Disposition", entry.ContentDisposition)
+		c.Header("Trailer", "X-Truncated")
+	}
+	c.Header("Content-Type", entry.ContentType)
+	c.Status(200)
+
+	if err := encoder.begin(w); err != nil {
+		s.logger.WithError(err).WithField("symbol", stream.Meta.Symbol).Error("Failed to encode historical data")
+		return nil
+	}
+
+	count := 0
+	truncated := false
+	failed := false
+	for ; hasRow; hasRow = result.Next() {
+		if s.historyMaxPoints > 0 && count >= s.historyMaxPoints {
+			truncated = true
+			break
+		}
+
+		if err := encoder.row(w, count, stream.Convert(result.Record())); err != nil {
+			if errors.Is(err, http.ErrHandlerTimeout) {
+				// 超过请求总超时，timeoutMiddleware 已拒绝继续写出并记录日志
+				return nil
+			}
+			s.logger.WithError(err).WithField("symbol", stream.Meta.Symbol).Warn("Failed to encode history record")
+			continue
+		}
+		count++
+
+		if count%historyFlushEvery == 0 {
+			encoder.flush()
+			c.Writer.Flush()
+		}
+	}
+
+	// 响应头已发出，中途出错只能截断并记录日志
+	if err := result.Err(); err != nil {
+		s.logger.WithError(err).WithField("symbol", stream.Meta.Symbol).Error("Error reading InfluxDB result mid-stream")
+		truncated = true
+		failed = true
+	}
+
+	if err := encoder.end(w, truncated); err != nil {
+		s.logger.WithError(err).WithField("symbol", stream.Meta.Symbol).Error("Failed to finish historical data")
+		failed = true
+	}
+	if stream.Format != historyFormatJSON {
+		c.Header("X-Truncated", strconv.FormatBool(truncated))
+	}
+
+	if failed || capture.overflow || (truncated && stream.Format != historyFormatJSON) {
+		return nil
+	}
+	entry.Body = capture.buf.Bytes()
+	return entry
+}
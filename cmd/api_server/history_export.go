@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// 历史数据导出格式
+const (
+	historyFormatJSON   = "json"
+	historyFormatCSV    = "csv"
+	historyFormatNDJSON = "ndjson"
+)
+
+var (
+	historyPointColumns = []string{"timestamp", "price", "volume"}
+	historyBarColumns   = []string{"timestamp", "open", "high", "low", "close", "volume"}
+
+	historyIndexPointColumns = []string{"timestamp", "value", "change", "change_percent", "volume", "turnover"}
+	historyIndexBarColumns   = []string{"timestamp", "open", "high", "low", "close", "volume", "turnover"}
+)
+
+// unsafeFilenameChars 文件名中不允许出现的字符
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// historyRow 可以按 JSON 或 CSV 输出的一条历史记录
+type historyRow interface {
+	CSVFields() []string
+}
+
+// parseHistoryFormat 校验 format 参数，默认为 json
+func parseHistoryFormat(format string) (string, error) {
+	switch format {
+	case "", historyFormatJSON:
+		return historyFormatJSON, nil
+	case historyFormatCSV, historyFormatNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, supported: json, csv, ndjson", format)
+	}
+}
+
+// historyContentDisposition 生成包含代码和日期范围的下载文件名
+func historyContentDisposition(meta HistoricalResponse, format string) string {
+	name := fmt.Sprintf("%s_%s_%s", meta.Symbol, meta.Start.UTC().Format("20060102"), meta.End.UTC().Format("20060102"))
+	if meta.Interval != "" {
+		name += "_" + meta.Interval
+	}
+	name = unsafeFilenameChars.ReplaceAllString(name, "_")
+	return fmt.Sprintf(`attachment; filename="%s.%s"`, name, format)
+}
+
+// historyEncoder 历史数据的流式编码器
+type historyEncoder interface {
+	contentType() string
+	begin(w io.Writer) error
+	row(w io.Writer, index int, row historyRow) error
+	flush()
+	end(w io.Writer, truncated bool) error
+}
+
+func newHistoryEncoder(stream historyStream) historyEncoder {
+	aggregated := stream.Meta.Interval != ""
+	switch stream.Format {
+	case historyFormatCSV:
+		columns := stream.Columns
+		if columns == nil {
+			columns = historyPointColumns
+			if aggregated {
+				columns = historyBarColumns
+			}
+		}
+		return &csvHistoryEncoder{columns: columns}
+	case historyFormatNDJSON:
+		return &ndjsonHistoryEncoder{}
+	default:
+		field := "data"
+		if aggregated {
+			field = "bars"
+		}
+		return &jsonHistoryEncoder{meta: stream.Meta, field: field}
+	}
+}
+
+// jsonHistoryEncoder 输出 HistoricalResponse 结构，数据数组逐条写出
+type jsonHistoryEncoder struct {
+	meta  HistoricalResponse
+	field string
+}
+
+func (e *jsonHistoryEncoder) contentType() string { return "application/json; charset=utf-8" }
+
+func (e *jsonHistoryEncoder) begin(w io.Writer) error {
+	header, err := json.Marshal(e.meta)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(header[:len(header)-1]); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, `,"`+e.field+`":[`)
+	return err
+}
+
+func (e *jsonHistoryEncoder) row(w io.Writer, index int, row historyRow) error {
+	item, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if index > 0 {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(item)
+	return err
+}
+
+func (e *jsonHistoryEncoder) flush() {}
+
+func (e *jsonHistoryEncoder) end(w io.Writer, truncated bool) error {
+	tail := "]"
+	if truncated {
+		tail += `,"truncated":true`
+	}
+	_, err := io.WriteString(w, tail+"}")
+	return err
+}
+
+// ndjsonHistoryEncoder 每行输出一条 JSON 记录
+type ndjsonHistoryEncoder struct{}
+
+func (e *ndjsonHistoryEncoder) contentType() string { return "application/x-ndjson" }
+
+func (e *ndjsonHistoryEncoder) begin(w io.Writer) error { return nil }
+
+func (e *ndjsonHistoryEncoder) row(w io.Writer, index int, row historyRow) error {
+	item, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(item, '\n'))
+	return err
+}
+
+func (e *ndjsonHistoryEncoder) flush() {}
+
+func (e *ndjsonHistoryEncoder) end(w io.Writer, truncated bool) error { return nil }
+
+// csvHistoryEncoder 输出带表头的 CSV，转义由 encoding/csv 处理
+type csvHistoryEncoder struct {
+	columns []string
+	writer  *csv.Writer
+}
+
+func (e *csvHistoryEncoder) contentType() string { return "text/csv; charset=utf-8" }
+
+func (e *csvHistoryEncoder) begin(w io.Writer) error {
+	e.writer = csv.NewWriter(w)
+	return e.writer.Write(e.columns)
+}
+
+func (e *csvHistoryEncoder) row(w io.Writer, index int, row historyRow) error {
+	return e.writer.Write(row.CSVFields())
+}
+
+func (e *csvHistoryEncoder) flush() { e.writer.Flush() }
+
+func (e *csvHistoryEncoder) end(w io.Writer, truncated bool) error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const extremeValuesCSV = `#datatype,string,long,dateTime:RFC3339,double,long
+#group,false,false,false,false,false
+#default,_result,,,,
+,result,table,_time,price,volume
+,,0,2025-08-20T10:01:00Z,0.0000001,12345678901
+,,0,2025-08-20T10:02:00.5Z,123456789.25,0
+`
+
+func serveHistory(t *testing.T, s *APIServer, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/history", s.getStockHistory)
+	router.GET("/api/v1/indices/:symbol/history", s.getIndexHistory)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func newExportTestServer(csvBody string) *APIServer {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &APIServer{queryAPI: &fakeQueryAPI{csv: csvBody}, logger: logger}
+}
+
+func TestHistoryExport_CSVColumnsAndValues(t *testing.T) {
+	w := serveHistory(t, newExportTestServer(extremeValuesCSV),
+		"/api/v1/stocks/600000/history?format=csv&start=2025-08-20T09:30:00Z&end=2025-08-21T15:00:00Z")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="600000_20250820_20250821.csv"`, w.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"timestamp", "price", "volume"},
+		// 数值不能使用科学计数法，否则 Excel/pandas 会按字符串处理
+		{"2025-08-20T10:01:00Z", "0.0000001", "12345678901"},
+		{"2025-08-20T10:02:00.5Z", "123456789.25", "0"},
+	}, records)
+	assert.Equal(t, "false", w.Result().Trailer.Get("X-Truncated"))
+}
+
+func TestHistoryExport_CSVWithAggregation(t *testing.T) {
+	w := serveHistory(t, newExportTestServer(ohlcCSV),
+		"/api/v1/stocks/600000/history?format=csv&interval=5m&start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="600000_20250820_20250820_5m.csv"`, w.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, []string{"timestamp", "open", "high", "low", "close", "volume"}, records[0])
+	assert.Equal(t, []string{"2025-08-20T10:05:00Z", "10.1", "10.5", "10", "10.4", "1500"}, records[1])
+}
+
+func TestHistoryExport_NDJSON(t *testing.T) {
+	w := serveHistory(t, newExportTestServer(generateRawHistoryCSV(5)),
+		"/api/v1/stocks/600000/history?format=ndjson")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".ndjson")
+
+	scanner := bufio.NewScanner(strings.NewReader(w.Body.String()))
+	var points []HistoricalDataPoint
+	for scanner.Scan() {
+		var point HistoricalDataPoint
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &point))
+		points = append(points, point)
+	}
+	require.Len(t, points, 5)
+	assert.Equal(t, time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC), points[0].Timestamp.UTC())
+	assert.Equal(t, int64(104), points[4].Volume)
+}
+
+func TestHistoryExport_TruncationTrailer(t *testing.T) {
+	s := newExportTestServer(generateRawHistoryCSV(10))
+	s.historyMaxPoints = 3
+
+	w := serveHistory(t, s, "/api/v1/stocks/600000/history?format=csv")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	require.NoError(t, err)
+	assert.Len(t, records, 4, "header plus max_points rows")
+	assert.Equal(t, "true", w.Result().Trailer.Get("X-Truncated"))
+}
+
+func TestHistoryExport_IndexHistoryAndFilenameSanitizing(t *testing.T) {
+	w := serveHistory(t, newExportTestServer(rawIndexHistoryCSV),
+		`/api/v1/indices/sh%22000001%20x/history?format=csv&start=2025-08-20T00:00:00Z&end=2025-08-21T00:00:00Z`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="sh_000001_x_20250820_20250821.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "timestamp,value,change,change_percent,volume,turnover\n"+
+		"2025-08-20T10:01:00Z,3200.5,-12.3,-0.38,250000000,320000000000\n", w.Body.String())
+}
+
+func TestHistoryExport_InvalidFormat(t *testing.T) {
+	s := newExportTestServer(rawHistoryCSV)
+
+	assert.Equal(t, http.StatusBadRequest, serveHistory(t, s, "/api/v1/stocks/600000/history?format=xml").Code)
+	assert.Equal(t, http.StatusBadRequest, serveHistory(t, s, "/api/v1/indices/sh000001/history?format=xlsx").Code)
+	assert.Empty(t, s.queryAPI.(*fakeQueryAPI).queries)
+}
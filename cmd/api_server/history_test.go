@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueryAPI 记录收到的 Flux 查询，并返回预置的 annotated CSV 结果
+type fakeQueryAPI struct {
+	api.QueryAPI
+	queries []string
+	csv     string
+}
+
+func (f *fakeQueryAPI) Query(ctx context.Context, query string) (*api.QueryTableResult, error) {
+	f.queries = append(f.queries, query)
+	return api.NewQueryTableResult(io.NopCloser(strings.NewReader(f.csv))), nil
+}
+
+const ohlcCSV = `#datatype,string,long,dateTime:RFC3339,double,double,double,double,long
+#group,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,
+,result,table,_time,open,high,low,close,volume
+,,0,2025-08-20T10:05:00Z,10.1,10.5,10.0,10.4,1500
+,,0,2025-08-20T10:10:00Z,10.4,10.6,10.3,10.3,900
+`
+
+const rawHistoryCSV = `#datatype,string,long,dateTime:RFC3339,double,long
+#group,false,false,false,false,false
+#default,_result,,,,
+,result,table,_time,price,volume
+,,0,2025-08-20T10:01:00Z,10.1,100
+`
+
+func newHistoryTestRouter(queryAPI api.QueryAPI) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{queryAPI: queryAPI, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/history", s.getStockHistory)
+	return router
+}
+
+func TestBuildOHLCHistoryQuery(t *testing.T) {
+	start := time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC)
+	end := time.Date(2025, 8, 20, 15, 0, 0, 0, time.UTC)
+
+	query := buildOHLCHistoryQuery("stock_data", "", "stock_realtime", "600000", "price", start, end, "5m", "volume")
+
+	assert.Contains(t, query, `from(bucket: "stock_data")`)
+	assert.Contains(t, query, "range(start: 2025-08-20T09:30:00Z, stop: 2025-08-20T15:00:00Z)")
+	assert.Contains(t, query, `r._measurement == "stock_realtime"`)
+	assert.Contains(t, query, `filter(fn: (r) => (r.symbol == "600000.SH" or r.symbol == "600000") and not exists r.namespace)`)
+	assert.Contains(t, query, `r._field == "price"`)
+	for _, fn := range []string{"first", "max", "min", "last", "sum"} {
+		assert.Contains(t, query, "aggregateWindow(every: 5m, fn: "+fn+", createEmpty: false)")
+	}
+	for _, field := range []string{"open", "high", "low", "close"} {
+		assert.Contains(t, query, `set(key: "_field", value: "`+field+`")`)
+	}
+	assert.Contains(t, query, "union(tables: [open, high, low, close, volume])")
+	assert.Contains(t, query, "limit(n: 5000)")
+}
+
+func TestValidateHistoryInterval(t *testing.T) {
+	start := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		interval string
+		end      time.Time
+		wantErr  bool
+	}{
+		{name: "一分钟", interval: "1m", end: start.Add(24 * time.Hour)},
+		{name: "日线", interval: "1d", end: start.Add(365 * 24 * time.Hour)},
+		{name: "恰好达到上限", interval: "1m", end: start.Add(maxHistoryBars * time.Minute)},
+		{name: "超出上限", interval: "1m", end: start.Add((maxHistoryBars + 1) * time.Minute), wantErr: true},
+		{name: "零间隔", interval: "0s", end: start.Add(time.Hour), wantErr: true},
+		{name: "不在白名单", interval: "7m", end: start.Add(time.Hour), wantErr: true},
+		{name: "结束早于开始", interval: "1h", end: start.Add(-time.Hour), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHistoryInterval(tt.interval, start, tt.end)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetStockHistory_WithIntervalReturnsBars(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: ohlcCSV}
+	router := newHistoryTestRouter(queryAPI)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/stocks/600000/history?interval=5m&start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, queryAPI.queries, 1)
+	assert.Contains(t, queryAPI.queries[0], "aggregateWindow(every: 5m")
+
+	var body map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body, "data", "data should be omitted when interval is set")
+
+	var response HistoricalResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "5m", response.Interval)
+	require.Len(t, response.Bars, 2)
+	assert.Equal(t, HistoricalBar{
+		Timestamp: time.Date(2025, 8, 20, 10, 5, 0, 0, time.UTC),
+		Open:      10.1,
+		High:      10.5,
+		Low:       10.0,
+		Close:     10.4,
+		Volume:    1500,
+	}, response.Bars[0])
+	assert.Equal(t, int64(900), response.Bars[1].Volume)
+}
+
+func TestGetStockHistory_WithoutIntervalReturnsData(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: rawHistoryCSV}
+	router := newHistoryTestRouter(queryAPI)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/stocks/600000/history?start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, queryAPI.queries, 1)
+	assert.NotContains(t, queryAPI.queries[0], "aggregateWindow")
+
+	var body map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body, "bars")
+
+	var response HistoricalResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, 10.1, response.Data[0].Price)
+	assert.Equal(t, int64(100), response.Data[0].Volume)
+}
+
+func TestGetStockHistory_InvalidIntervalReturns400(t *testing.T) {
+	for _, query := range []string{
+		"interval=0s",
+		"interval=banana",
+		"interval=1m&start=2025-01-01T00:00:00Z&end=2025-02-01T00:00:00Z",
+	} {
+		t.Run(query, func(t *testing.T) {
+			queryAPI := &fakeQueryAPI{}
+			router := newHistoryTestRouter(queryAPI)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history?"+query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, queryAPI.queries, "invalid requests must not reach InfluxDB")
+		})
+	}
+}
+
+// generateRawHistoryCSV 生成 n 行原始价格数据
+func generateRawHistoryCSV(n int) string {
+	var b strings.Builder
+	b.WriteString("#datatype,string,long,dateTime:RFC3339,double,long\n")
+	b.WriteString("#group,false,false,false,false,false\n")
+	b.WriteString("#default,_result,,,,\n")
+	b.WriteString(",result,table,_time,price,volume\n")
+	base := time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, ",,0,%s,%d.5,%d\n", base.Add(time.Duration(i)*time.Second).Format(time.RFC3339), 10+i%5, 100+i)
+	}
+	return b.String()
+}
+
+func TestGetStockHistory_StreamsAndFlushes(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: generateRawHistoryCSV(2500)}
+	router := newHistoryTestRouter(queryAPI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/api/v1/stocks/600000/history?start=2025-08-20T09:00:00Z&end=2025-08-20T15:00:00Z", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Flushed, "large results should be flushed while streaming")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var response HistoricalResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "600000", response.Symbol)
+	require.Len(t, response.Data, 2500)
+	assert.Equal(t, int64(100), response.Data[0].Volume)
+	assert.Equal(t, int64(2599), response.Data[2499].Volume)
+	assert.False(t, response.Truncated)
+}
+
+func TestGetStockHistory_MaxPointsTruncates(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: generateRawHistoryCSV(25)}
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{queryAPI: queryAPI, logger: logger, historyMaxPoints: 10}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/history", s.getStockHistory)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response HistoricalResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Data, 10)
+	assert.True(t, response.Truncated)
+}
+
+func TestGetStockHistory_EmptyResultHasEmptyData(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: generateRawHistoryCSV(0)}
+	router := newHistoryTestRouter(queryAPI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":[]`)
+	assert.NotContains(t, w.Body.String(), "truncated")
+}
+
+func TestGetStockHistory_LargeResponseIsNotCached(t *testing.T) {
+	// 约 70 字节/条，20000 条超过 maxCachedHistoryBytes
+	queryAPI := &fakeQueryAPI{csv: generateRawHistoryCSV(20000)}
+	_, router := newCacheTestServer(t, newBatchTestSource(), queryAPI)
+
+	path := "/api/v1/stocks/600000/history?start=2025-08-20T09:00:00Z&end=2025-08-20T15:00:00Z"
+	require.Equal(t, http.StatusOK, doGet(t, router, path).Code)
+	require.Equal(t, http.StatusOK, doGet(t, router, path).Code)
+	assert.Len(t, queryAPI.queries, 2)
+}
+
+const rawIndexHistoryCSV = `#datatype,string,long,dateTime:RFC3339,double,double,double,long,double
+#group,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,
+,result,table,_time,value,change,change_percent,volume,turnover
+,,0,2025-08-20T10:01:00Z,3200.5,-12.3,-0.38,250000000,320000000000
+`
+
+const indexOHLCCSV = `#datatype,string,long,dateTime:RFC3339,double,double,double,double,long,double
+#group,false,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,,
+,result,table,_time,open,high,low,close,volume,turnover
+,,0,2025-08-20T10:05:00Z,3200.5,3210,3198.2,3205.1,1500000,1800000000
+`
+
+func TestGetIndexHistory_ReturnsIndexFields(t *testing.T) {
+	s := newExportTestServer(rawIndexHistoryCSV)
+	w := serveHistory(t, s, "/api/v1/indices/sh000001/history?start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	query := s.queryAPI.(*fakeQueryAPI).queries[0]
+	assert.Contains(t, query, `r._measurement == "index_realtime"`)
+	for _, field := range []string{"value", "change", "change_percent", "volume", "turnover"} {
+		assert.Contains(t, query, `r._field == "`+field+`"`)
+	}
+	assert.NotContains(t, w.Body.String(), `"price"`)
+
+	var response struct {
+		Data []IndexHistoricalDataPoint `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []IndexHistoricalDataPoint{{
+		Timestamp:     time.Date(2025, 8, 20, 10, 1, 0, 0, time.UTC),
+		Value:         3200.5,
+		Change:        -12.3,
+		ChangePercent: -0.38,
+		Volume:        250000000,
+		Turnover:      3.2e11,
+	}}, response.Data)
+}
+
+func TestGetIndexHistory_WithIntervalReturnsBars(t *testing.T) {
+	s := newExportTestServer(indexOHLCCSV)
+	w := serveHistory(t, s, "/api/v1/indices/sh000001/history?interval=5m&start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	query := s.queryAPI.(*fakeQueryAPI).queries[0]
+	assert.Contains(t, query, `r._field == "value"`)
+	assert.Contains(t, query, "union(tables: [open, high, low, close, volume, turnover])")
+
+	var response struct {
+		Interval string               `json:"interval"`
+		Bars     []IndexHistoricalBar `json:"bars"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "5m", response.Interval)
+	assert.Equal(t, []IndexHistoricalBar{{
+		Timestamp: time.Date(2025, 8, 20, 10, 5, 0, 0, time.UTC),
+		Open:      3200.5,
+		High:      3210,
+		Low:       3198.2,
+		Close:     3205.1,
+		Volume:    1500000,
+		Turnover:  1.8e9,
+	}}, response.Bars)
+
+	w = serveHistory(t, s, "/api/v1/indices/sh000001/history?interval=7m")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetIndexHistory_LegacyShape(t *testing.T) {
+	s := newExportTestServer(rawIndexHistoryCSV)
+	w := serveHistory(t, s, "/api/v1/indices/sh000001/history?legacy=1")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response HistoricalResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, 3200.5, response.Data[0].Price)
+	assert.Equal(t, int64(250000000), response.Data[0].Volume)
+
+	w = serveHistory(t, s, "/api/v1/indices/sh000001/history?legacy=1&format=csv")
+	assert.Equal(t, "timestamp,price,volume\n2025-08-20T10:01:00Z,3200.5,250000000\n", w.Body.String())
+
+	w = serveHistory(t, s, "/api/v1/indices/sh000001/history?legacy=1&interval=5m")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// klinePeriods 支持的K线周期，与 fetcher 历史任务的 period 参数一致
+var klinePeriods = map[string]bool{
+	"1d": true,
+	"1w": true,
+	"1M": true,
+}
+
+// defaultKlineRange 未指定 start 时默认查询的时间范围
+const defaultKlineRange = 365 * 24 * time.Hour
+
+// buildKlineQuery 构造查询 stock_kline 测量值的 Flux 查询，每个时间点一行 OHLC + 成交量
+func buildKlineQuery(bucket, namespace, symbol, period string, start, end time.Time) string {
+	return fmt.Sprintf(`
+		from(bucket: "%s")
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == "stock_kline")
+		|> filter(fn: (r) => %s)
+		|> filter(fn: (r) => r.period == "%s")
+		|> keep(columns: ["_time", "_field", "_value"])
+		|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> sort(columns: ["_time"])
+		|> limit(n: %d)
+	`, bucket, start.Format(time.RFC3339), end.Format(time.RFC3339), namespaceFilter(namespace, symbolFilter(symbol)), period, maxHistoryBars)
+}
+
+// getStockKline 查询 fetcher 历史任务采集的K线数据
+func (s *APIServer) getStockKline(c *gin.Context) {
+	symbol := c.Param("symbol")
+	if symbol == "" {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Symbol is required"})
+		return
+	}
+
+	period := c.DefaultQuery("period", "1d")
+	if !klinePeriods[period] {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: fmt.Sprintf("unsupported period %q, supported: 1d, 1w, 1M", period)})
+		return
+	}
+
+	startStr := c.Query("start")
+	endStr := c.Query("end")
+
+	var start, end time.Time
+	var err error
+
+	if endStr != "" {
+		end, err = time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid end time format, use RFC3339"})
+			return
+		}
+	} else {
+		end = time.Now()
+	}
+
+	if startStr != "" {
+		start, err = time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Invalid start time format, use RFC3339"})
+			return
+		}
+	} else {
+		start = end.Add(-defaultKlineRange)
+	}
+
+	if !end.After(start) {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "end time must be after start time"})
+		return
+	}
+
+	format, err := parseHistoryFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var cacheKey string
+	if startStr != "" && endStr != "" {
+		cacheKey = fmt.Sprintf("kline:stock:%s:%s:%d:%d:%s", symbol, period, start.Unix(), end.Unix(), format)
+		if cached, ok := s.cacheGet(ctx, c, cacheKey); ok {
+			writeHistoryCacheEntry(c, cached.(*historyCacheEntry))
+			return
+		}
+	}
+
+	query := buildKlineQuery(viper.GetString("influxdb.bucket"), s.namespace, symbol, period, start, end)
+	result, err := s.queryAPI.Query(ctx, query)
+	if err != nil {
+		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to query InfluxDB")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to query kline data"})
+		return
+	}
+	defer result.Close()
+
+	meta := HistoricalResponse{
+		Symbol:   symbol,
+		Start:    start,
+		End:      end,
+		Interval: period,
+	}
+
+	if entry := s.streamHistory(c, historyStream{Meta: meta, Format: format, Convert: barFromRecord}, result); cacheKey != "" && entry != nil {
+		s.cacheSet(ctx, c, cacheKey, entry, s.historyCacheTTL)
+	}
+}
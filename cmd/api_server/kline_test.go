@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const klineCSV = `#datatype,string,long,dateTime:RFC3339,double,double,double,double,long,double
+#group,false,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,,
+,result,table,_time,open,high,low,close,volume,turnover
+,,0,2025-08-17T16:00:00Z,10.1,10.4,10.0,10.3,123456,0
+,,0,2025-08-18T16:00:00Z,10.3,10.5,10.1,10.2,98765,0
+`
+
+func newKlineTestRouter(queryAPI api.QueryAPI) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{queryAPI: queryAPI, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/kline", s.getStockKline)
+	return router
+}
+
+func TestBuildKlineQuery(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 8, 20, 0, 0, 0, 0, time.UTC)
+
+	query := buildKlineQuery("stock_data", "", "600000", "1w", start, end)
+
+	assert.Contains(t, query, `from(bucket: "stock_data")`)
+	assert.Contains(t, query, "range(start: 2025-01-01T00:00:00Z, stop: 2025-08-20T00:00:00Z)")
+	assert.Contains(t, query, `r._measurement == "stock_kline"`)
+	assert.Contains(t, query, `filter(fn: (r) => (r.symbol == "600000.SH" or r.symbol == "600000") and not exists r.namespace)`)
+	assert.Contains(t, query, `r.period == "1w"`)
+	assert.Contains(t, query, "limit(n: 5000)")
+
+	query = buildKlineQuery("stock_data", "staging", "600000", "1w", start, end)
+	assert.Contains(t, query, `filter(fn: (r) => (r.symbol == "600000.SH" or r.symbol == "600000") and r.namespace == "staging")`)
+}
+
+func TestGetStockKline_ReturnsBars(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: klineCSV}
+	router := newKlineTestRouter(queryAPI)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/kline?period=1d", nil)
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, queryAPI.queries, 1)
+	assert.Contains(t, queryAPI.queries[0], `r.period == "1d"`)
+
+	var response HistoricalResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "600000", response.Symbol)
+	assert.Equal(t, "1d", response.Interval)
+	assert.InDelta(t, defaultKlineRange.Hours(), response.End.Sub(response.Start).Hours(), 0.01)
+	require.Len(t, response.Bars, 2)
+	assert.Equal(t, HistoricalBar{
+		Timestamp: time.Date(2025, 8, 17, 16, 0, 0, 0, time.UTC),
+		Open:      10.1,
+		High:      10.4,
+		Low:       10.0,
+		Close:     10.3,
+		Volume:    123456,
+	}, response.Bars[0])
+}
+
+func TestGetStockKline_InvalidParamsReturn400(t *testing.T) {
+	for _, query := range []string{
+		"period=5m",
+		"start=yesterday",
+		"start=2025-08-20T00:00:00Z&end=2025-08-01T00:00:00Z",
+		"format=xml",
+	} {
+		t.Run(query, func(t *testing.T) {
+			queryAPI := &fakeQueryAPI{}
+			router := newKlineTestRouter(queryAPI)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/kline?"+query, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Empty(t, queryAPI.queries)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -19,7 +20,14 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"stocksub/pkg/alert"
+	"stocksub/pkg/apitypes"
 	"stocksub/pkg/cache"
+	appconfig "stocksub/pkg/config"
+	apperrors "stocksub/pkg/error"
+	"stocksub/pkg/message"
+	"stocksub/pkg/refdata"
+	"stocksub/pkg/timing"
 )
 
 var (
@@ -30,6 +38,7 @@ var (
 	redisPass   = flag.String("redis-pass", "", "Redis 密码")
 	influxURL   = flag.String("influxdb-url", "", "InfluxDB URL")
 	influxToken = flag.String("influxdb-token", "", "InfluxDB token")
+	checkConfig = flag.Bool("check-config", false, "检查配置并打印生效的配置（隐藏敏感值）后退出")
 )
 
 type APIServer struct {
@@ -39,18 +48,55 @@ type APIServer struct {
 	logger       *logrus.Logger
 	server       *http.Server
 	cache        cache.Cache // 集成分层缓存
+	wsHub        *wsHub      // WebSocket 实时推送
+	metrics      *apiMetrics // Prometheus 指标
+	auth         *apiKeyAuth // API Key 鉴权与限流
+
+	loadSnapshots wsSnapshotLoader // 批量读取最新行情
+	snapshotCodec snapshotCodec    // redis_collector 写入股票快照使用的编码
+
+	stockCacheTTL   time.Duration // getStock 响应缓存时间
+	historyCacheTTL time.Duration // getStockHistory 响应缓存时间
+	allowNoCache    bool          // 是否允许 nocache=1 跳过缓存
+
+	historyMaxPoints int // 单次历史查询最多返回的数据点
+
+	redisKeyPrefix string // 最新数据键前缀，为空时使用 defaultRedisKeyPrefix
+	namespace      string // 读取的命名空间，与 fetcher 和各 collector 的 namespace 一致，为空时使用默认名称
+
+	alertRules alert.RuleStore // 告警规则存储，redis_collector 从同一个键读取
+
+	refreshLimiter    *endpointLimiter // 按需刷新接口的限流
+	refreshMaxSymbols int              // 单次按需刷新最多的代码数量
+
+	constituents     *refdata.ConstituentStore // 指数成分股，未配置成分股文件时为 nil
+	stopRefdataWatch context.CancelFunc        // 停止成分股文件监视
+
+	timeouts TimeoutConfig // 按路由组的请求超时和慢请求阈值
+
+	market               *timing.MarketTime // 交易时段和休市日历
+	staleness            *stalenessChecker  // 最新数据过期判断，为 nil 时不标记过期
+	stopStalenessMonitor context.CancelFunc // 停止后台过期统计
 }
 
+// defaultRedisKeyPrefix redis_collector 写入最新数据的默认键前缀
+const defaultRedisKeyPrefix = "latest:"
+
 type Config struct {
+	// Namespace 命名空间，非空时 Redis 键加 <namespace>: 前缀、Stream 为 stream:<namespace>:...，
+	// InfluxDB 查询按 namespace 标签过滤
+	Namespace string `mapstructure:"namespace"`
+
 	Server struct {
 		Port string `mapstructure:"port"`
 		Mode string `mapstructure:"mode"` // debug, release, test
 	} `mapstructure:"server"`
 
 	Redis struct {
-		Addr     string `mapstructure:"addr"`
-		Password string `mapstructure:"password"`
-		DB       int    `mapstructure:"db"`
+		Addr      string `mapstructure:"addr"`
+		Password  string `mapstructure:"password"`
+		DB        int    `mapstructure:"db"`
+		KeyPrefix string `mapstructure:"key_prefix"` // 与 redis_collector 的 storage.key_prefix 保持一致
 	} `mapstructure:"redis"`
 
 	InfluxDB struct {
@@ -65,53 +111,64 @@ type Config struct {
 		DefaultTTL      time.Duration `mapstructure:"default_ttl"`
 		MaxSize         int64         `mapstructure:"max_size"`
 		CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+		StockTTL        time.Duration `mapstructure:"stock_ttl"`     // 实时行情响应缓存时间
+		HistoryTTL      time.Duration `mapstructure:"history_ttl"`   // 历史数据响应缓存时间
+		AllowNoCache    bool          `mapstructure:"allow_nocache"` // 是否允许 nocache=1 跳过缓存
 	} `mapstructure:"cache"`
-}
 
-// Response structures
-type StockResponse struct {
-	Symbol        string    `json:"symbol"`
-	Name          string    `json:"name"`
-	Price         float64   `json:"price"`
-	Change        float64   `json:"change"`
-	ChangePercent float64   `json:"change_percent"`
-	Volume        int64     `json:"volume"`
-	Timestamp     time.Time `json:"timestamp"`
-	Provider      string    `json:"provider"`
-	Market        string    `json:"market"`
-	UpdatedAt     time.Time `json:"updated_at"`
-}
+	WebSocket WebSocketConfig `mapstructure:"websocket"`
 
-type IndexResponse struct {
-	Symbol        string    `json:"symbol"`
-	Name          string    `json:"name"`
-	Value         float64   `json:"value"`
-	Change        float64   `json:"change"`
-	ChangePercent float64   `json:"change_percent"`
-	Timestamp     time.Time `json:"timestamp"`
-	Provider      string    `json:"provider"`
-	Market        string    `json:"market"`
-	UpdatedAt     time.Time `json:"updated_at"`
-}
+	History struct {
+		MaxPoints int `mapstructure:"max_points"` // 单次历史查询最多返回的数据点，超过时截断
+	} `mapstructure:"history"`
 
-type HistoricalDataPoint struct {
-	Timestamp time.Time `json:"timestamp"`
-	Price     float64   `json:"price"`
-	Volume    int64     `json:"volume"`
-}
+	Auth    AuthConfig     `mapstructure:"auth"`
+	APIKeys []APIKeyConfig `mapstructure:"api_keys"`
+
+	Alerts struct {
+		RulesKey string `mapstructure:"rules_key"` // 与 redis_collector 的 alerts.rules_key 保持一致
+	} `mapstructure:"alerts"`
+
+	Admin struct {
+		RefreshRateLimit  int `mapstructure:"refresh_rate_limit"`  // POST /admin/refresh 每个 Key 每分钟允许的请求数
+		RefreshMaxSymbols int `mapstructure:"refresh_max_symbols"` // 单次按需刷新最多的代码数量
+	} `mapstructure:"admin"`
 
-type HistoricalResponse struct {
-	Symbol string                `json:"symbol"`
-	Start  time.Time             `json:"start"`
-	End    time.Time             `json:"end"`
-	Data   []HistoricalDataPoint `json:"data"`
+	Refdata struct {
+		ConstituentsFile string        `mapstructure:"constituents_file"` // 指数成分股 CSV 或 yaml 文件，为空时不提供成分股接口
+		WatchInterval    time.Duration `mapstructure:"watch_interval"`    // 检查文件修改的间隔，0 表示不监视
+	} `mapstructure:"refdata"`
+
+	Timeouts TimeoutConfig `mapstructure:"timeouts"`
+
+	Staleness StalenessConfig `mapstructure:"staleness"`
 }
 
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+// WebSocketConfig WebSocket 推送配置
+type WebSocketConfig struct {
+	MaxConnections int           `mapstructure:"max_connections"` // 最大连接数
+	MaxSymbols     int           `mapstructure:"max_symbols"`     // 单连接最大订阅数
+	PollInterval   time.Duration `mapstructure:"poll_interval"`   // 最新行情轮询间隔
+	PingInterval   time.Duration `mapstructure:"ping_interval"`   // ping 保活间隔
 }
 
+// 响应结构定义在 pkg/apitypes，与 pkg/client 共用
+type (
+	StockResponse            = apitypes.StockResponse
+	IndexResponse            = apitypes.IndexResponse
+	HistoricalDataPoint      = apitypes.HistoricalDataPoint
+	HistoricalBar            = apitypes.HistoricalBar
+	IndexHistoricalDataPoint = apitypes.IndexHistoricalDataPoint
+	IndexHistoricalBar       = apitypes.IndexHistoricalBar
+	HistoricalResponse       = apitypes.HistoricalResponse
+	ErrorResponse            = apitypes.ErrorResponse
+	OrderLevel               = apitypes.OrderLevel
+	OrderBook                = apitypes.OrderBook
+	BatchStocksResponse      = apitypes.BatchStocksResponse
+	BatchIndicesResponse     = apitypes.BatchIndicesResponse
+	ChangedStocksResponse    = apitypes.ChangedStocksResponse
+)
+
 func main() {
 	flag.Parse()
 
@@ -136,6 +193,12 @@ func main() {
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to load configuration")
 	}
+	if *checkConfig {
+		if err := appconfig.WriteRedacted(os.Stdout, viper.AllSettings()); err != nil {
+			logger.WithError(err).Fatal("Failed to print configuration")
+		}
+		return
+	}
 
 	// Set Gin mode
 	gin.SetMode(config.Server.Mode)
@@ -172,11 +235,13 @@ func loadConfig() (*Config, error) {
 	}
 
 	// Set defaults
+	viper.SetDefault("namespace", "")
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.mode", "release")
 	viper.SetDefault("redis.addr", "localhost:6379")
 	viper.SetDefault("redis.password", "")
 	viper.SetDefault("redis.db", 0)
+	viper.SetDefault("redis.key_prefix", defaultRedisKeyPrefix)
 	viper.SetDefault("influxdb.url", "http://localhost:8086")
 	viper.SetDefault("influxdb.token", "")
 	viper.SetDefault("influxdb.org", "stocksub")
@@ -185,6 +250,30 @@ func loadConfig() (*Config, error) {
 	viper.SetDefault("cache.default_ttl", "5m")
 	viper.SetDefault("cache.max_size", 1000)
 	viper.SetDefault("cache.cleanup_interval", "1m")
+	viper.SetDefault("cache.stock_ttl", "2s")
+	viper.SetDefault("cache.history_ttl", "1m")
+	viper.SetDefault("cache.allow_nocache", true)
+	viper.SetDefault("websocket.max_connections", 1000)
+	viper.SetDefault("websocket.max_symbols", 200)
+	viper.SetDefault("websocket.poll_interval", "1s")
+	viper.SetDefault("websocket.ping_interval", "30s")
+	viper.SetDefault("history.max_points", 1000000)
+	viper.SetDefault("auth.enabled", false)
+	viper.SetDefault("auth.default_rate_limit", 120)
+	viper.SetDefault("auth.redis_lookup", false)
+	viper.SetDefault("auth.redis_prefix", "apikey:")
+	viper.SetDefault("auth.lookup_cache_ttl", "30s")
+	viper.SetDefault("alerts.rules_key", alert.DefaultRulesKey)
+	viper.SetDefault("admin.refresh_rate_limit", 10)
+	viper.SetDefault("admin.refresh_max_symbols", 20)
+	viper.SetDefault("refdata.watch_interval", "30s")
+	viper.SetDefault("timeouts.realtime", "3s")
+	viper.SetDefault("timeouts.history", "45s")
+	viper.SetDefault("timeouts.default", "10s")
+	viper.SetDefault("timeouts.slow_threshold", "1s")
+	viper.SetDefault("staleness.threshold", "2m")
+	viper.SetDefault("staleness.check_interval", "30s")
+	viper.SetDefault("staleness.degraded_percent", 20)
 
 	// Environment variable overrides
 	viper.SetEnvPrefix("API_SERVER")
@@ -215,6 +304,9 @@ func loadConfig() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
@@ -273,6 +365,7 @@ func NewAPIServer(config *Config, logger *logrus.Logger) (*APIServer, error) {
 				},
 			},
 			PromoteEnabled: true,
+			DemoteEnabled:  true,
 			WriteThrough:   false,
 			WriteBack:      false,
 		}
@@ -294,13 +387,68 @@ func NewAPIServer(config *Config, logger *logrus.Logger) (*APIServer, error) {
 		logger.Info("API server simple memory cache enabled")
 	}
 
-	return &APIServer{
+	s := &APIServer{
 		redisClient:  redisClient,
 		influxClient: influxClient,
 		queryAPI:     queryAPI,
 		logger:       logger,
 		cache:        apiCache,
-	}, nil
+
+		stockCacheTTL:   config.Cache.StockTTL,
+		historyCacheTTL: config.Cache.HistoryTTL,
+		allowNoCache:    config.Cache.AllowNoCache,
+
+		historyMaxPoints: config.History.MaxPoints,
+		redisKeyPrefix:   config.Redis.KeyPrefix,
+		namespace:        config.Namespace,
+
+		alertRules: alert.NewRedisRuleStore(redisClient, message.NamespaceKey(config.Namespace, config.Alerts.RulesKey)),
+
+		refreshLimiter:    newEndpointLimiter(config.Admin.RefreshRateLimit),
+		refreshMaxSymbols: config.Admin.RefreshMaxSymbols,
+
+		timeouts: config.Timeouts,
+
+		market: timing.DefaultMarketTime(),
+	}
+	s.staleness = newStalenessChecker(config.Staleness, s.market)
+	s.loadSnapshots = s.loadLatestSnapshots
+	s.metrics = newAPIMetrics(s)
+
+	var keyLookup apiKeyLookup
+	if config.Auth.RedisLookup {
+		keyLookup = newRedisAPIKeyLookup(redisClient, config.Auth.RedisPrefix)
+	}
+	s.auth = newAPIKeyAuth(config.Auth, config.APIKeys, keyLookup, logger)
+	if config.Auth.Enabled {
+		logger.WithField("static_keys", len(config.APIKeys)).Info("API key authentication enabled")
+	}
+	s.wsHub = newWSHub(config.WebSocket, s.loadSnapshots, logger)
+
+	if path := config.Refdata.ConstituentsFile; path != "" {
+		store, err := refdata.NewConstituentStore(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load constituents: %w", err)
+		}
+		s.constituents = store
+		logger.WithFields(logrus.Fields{"file": path, "constituents": store.Count()}).Info("Index constituents loaded")
+
+		if interval := config.Refdata.WatchInterval; interval > 0 {
+			watchCtx, stop := context.WithCancel(context.Background())
+			s.stopRefdataWatch = stop
+			go store.Watch(watchCtx, interval, func(err error) {
+				logger.WithError(err).Error("Failed to reload constituents, keeping previous data")
+			})
+		}
+	}
+
+	if interval := config.Staleness.CheckInterval; interval > 0 && config.Staleness.Threshold > 0 {
+		monitorCtx, stop := context.WithCancel(context.Background())
+		s.stopStalenessMonitor = stop
+		go s.runStalenessMonitor(monitorCtx, interval)
+	}
+
+	return s, nil
 }
 
 func (s *APIServer) Start() error {
@@ -309,39 +457,84 @@ func (s *APIServer) Start() error {
 	// Middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
+	router.Use(s.metrics.middleware())
+	router.Use(s.slowRequestMiddleware(s.timeouts.SlowThreshold))
 	router.Use(s.corsMiddleware())
 
 	// Health check
 	router.GET("/health", s.healthCheck)
 
 	// API routes
-	v1 := router.Group("/api/v1")
+	// 业务接口需要 API Key；/health 和 /metrics 供探针与监控抓取，不做鉴权
+	// 请求总超时按路由组配置，见 timeouts；WebSocket 长连接不设超时
+	v1 := router.Group("/api/v1", s.auth.middleware(), gzipMiddleware(), validateSymbolParams())
+	realtime := v1.Group("", s.timeoutMiddleware(s.timeouts.Realtime))
+	history := v1.Group("", s.timeoutMiddleware(s.timeouts.History))
+	other := v1.Group("", s.timeoutMiddleware(s.timeouts.Default))
 	{
 		// Real-time data endpoints
-		v1.GET("/stocks/:symbol", s.getStock)
-		v1.GET("/stocks", s.getStocks)
-		v1.GET("/indices/:symbol", s.getIndex)
-		v1.GET("/indices", s.getIndices)
+		realtime.GET("/stocks/:symbol", s.getStock)
+		realtime.GET("/stocks", s.getStocks)
+		realtime.GET("/stocks/changed", s.getChangedStocks)
+		realtime.GET("/indices/:symbol", s.getIndex)
+		realtime.GET("/indices", s.getIndices)
+
+		// WebSocket real-time push endpoint
+		v1.GET("/ws", s.handleWebSocket)
 
 		// Historical data endpoints
-		v1.GET("/stocks/:symbol/history", s.getStockHistory)
-		v1.GET("/indices/:symbol/history", s.getIndexHistory)
+		history.GET("/stocks/:symbol/history", s.getStockHistory)
+		history.GET("/stocks/:symbol/kline", s.getStockKline)
+		history.GET("/stocks/:symbol/eod", s.getStockEOD)
+		history.GET("/indices/:symbol/history", s.getIndexHistory)
+		history.GET("/stocks/:symbol/history/gaps", s.getStockHistoryGaps)
+		history.POST("/admin/history/gaps", s.postAdminHistoryGaps)
+
+		// 指数成分股和行业分布，成分股来自 refdata.constituents_file
+		other.GET("/indices/:symbol/constituents", s.getIndexConstituents)
+		other.GET("/indices/:symbol/sectors", s.getIndexSectors)
 
 		// Metadata endpoints
-		v1.GET("/symbols/stocks", s.getStockSymbols)
-		v1.GET("/symbols/indices", s.getIndexSymbols)
+		other.GET("/symbols/stocks", s.getStockSymbols)
+		other.GET("/symbols/indices", s.getIndexSymbols)
+		other.GET("/symbols/search", s.searchSymbols)
+
+		// 排行榜：redis_collector 维护的 rank:* 有序集合
+		realtime.GET("/market/movers", s.getMarketMovers)
+
+		// 告警规则，由 redis_collector 评估
+		other.GET("/alerts", s.listAlertRules)
+		other.POST("/alerts", s.createAlertRule)
+		other.GET("/alerts/:id", s.getAlertRule)
+		other.PUT("/alerts/:id", s.updateAlertRule)
+		other.DELETE("/alerts/:id", s.deleteAlertRule)
+
+		// 运维统计：fetcher 每小时写入的任务和提供商发布统计
+		other.GET("/admin/jobs", s.getAdminJobs)
+
+		// 调试：fetcher 为开启 debug.capture_raw 的任务采样保存的提供商原始响应
+		other.GET("/admin/raw/:provider", s.getAdminRawCaptures)
+
+		// 按需刷新：写入 stream:control:fetch，由 fetcher 立即获取
+		other.POST("/admin/refresh", s.refreshLimiter.middleware(), s.postAdminRefresh)
+
+		// 立即重新加载成分股文件
+		other.POST("/admin/refdata/reload", s.postAdminRefdataReload)
+
+		// 查询追踪 ID 出现在哪些快照哈希和最近的 Stream 条目中
+		other.GET("/admin/trace/:id", s.getAdminTrace)
 	}
 
 	// 向后兼容的 API 路由（兼容现有客户端）
-	legacy := router.Group("/api")
+	legacy := router.Group("/api", s.auth.middleware(), gzipMiddleware(), validateSymbolParams(), s.timeoutMiddleware(s.timeouts.Realtime))
 	{
 		legacy.GET("/stock/:symbol", s.getLegacyStock)
 		legacy.GET("/stocks", s.getLegacyStocks)
 	}
 
 	// 监控和指标端点
-	router.GET("/metrics", s.getMetrics)
-	router.GET("/stats", s.getStats)
+	router.GET("/metrics", gin.WrapH(s.metrics.handler()))
+	router.GET("/stats", s.auth.middleware(), s.getStats)
 
 	// Create HTTP server
 	s.server = &http.Server{
@@ -351,6 +544,8 @@ func (s *APIServer) Start() error {
 
 	s.logger.WithField("port", viper.GetString("server.port")).Info("Starting API server...")
 
+	s.wsHub.Run()
+
 	// Start server in goroutine
 	go func() {
 		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -365,6 +560,16 @@ func (s *APIServer) Stop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// http.Server.Shutdown 不会关闭已劫持的 WebSocket 连接，需要单独关闭
+	s.wsHub.Close()
+
+	if s.stopRefdataWatch != nil {
+		s.stopRefdataWatch()
+	}
+	if s.stopStalenessMonitor != nil {
+		s.stopStalenessMonitor()
+	}
+
 	if err := s.server.Shutdown(ctx); err != nil {
 		s.logger.WithError(err).Error("Failed to gracefully shutdown server")
 	}
@@ -400,7 +605,7 @@ func (s *APIServer) corsMiddleware() gin.HandlerFunc {
 }
 
 func (s *APIServer) healthCheck(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
 	defer cancel()
 
 	health := map[string]interface{}{
@@ -429,6 +634,15 @@ func (s *APIServer) healthCheck(c *gin.Context) {
 		}
 	}
 
+	// 交易时段内过期股票比例过高说明采集链路出现问题
+	if s.staleness != nil {
+		status, degraded := s.staleness.health()
+		health["services"].(map[string]string)["staleness"] = status
+		if degraded {
+			health["status"] = "degraded"
+		}
+	}
+
 	if health["status"] == "ok" {
 		c.JSON(200, health)
 	} else {
@@ -436,6 +650,24 @@ func (s *APIServer) healthCheck(c *gin.Context) {
 	}
 }
 
+// latestKey 返回最新数据哈希的键，例如 latest:stock:600000
+func (s *APIServer) latestKey(kind, symbol string) string {
+	return s.keyPrefix() + kind + ":" + symbol
+}
+
+// symbolsKey 返回可用代码集合的键，例如 latest:symbols:stock
+func (s *APIServer) symbolsKey(kind string) string {
+	return s.keyPrefix() + "symbols:" + kind
+}
+
+func (s *APIServer) keyPrefix() string {
+	prefix := s.redisKeyPrefix
+	if prefix == "" {
+		prefix = defaultRedisKeyPrefix
+	}
+	return message.NamespaceKey(s.namespace, prefix)
+}
+
 func (s *APIServer) getStock(c *gin.Context) {
 	symbol := c.Param("symbol")
 	if symbol == "" {
@@ -443,81 +675,102 @@ func (s *APIServer) getStock(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	key := fmt.Sprintf("latest:stock:%s", symbol)
-	result, err := s.redisClient.HGetAll(ctx, key).Result()
-	if err != nil {
-		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to get stock data from Redis")
-		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+	cacheKey := fmt.Sprintf("stock:%s", symbol)
+	if cached, ok := s.cacheGet(ctx, c, cacheKey); ok {
+		respondStock(c, withDepth(cached.(*StockResponse), wantDepth(c)))
 		return
 	}
 
-	if len(result) == 0 {
-		c.JSON(404, ErrorResponse{Error: "not_found", Message: "Stock not found"})
+	stocks, _, err := s.loadSnapshots(ctx, []string{symbol}, nil)
+	if err != nil {
+		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to get stock data from Redis")
+		respondError(c, err, "Failed to retrieve data")
 		return
 	}
 
-	stock, err := s.parseStockFromRedis(result)
-	if err != nil {
-		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to parse stock data")
-		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to parse data"})
+	stock, ok := stocks[symbol]
+	if !ok {
+		respondError(c, apperrors.NewError(apperrors.CodeSymbolNotFound, "stock not found"), "Stock not found")
 		return
 	}
 
-	c.JSON(200, stock)
+	// 缓存中保留盘口，是否返回由每个请求自己决定
+	s.cacheSet(ctx, c, cacheKey, stock, s.stockCacheTTL)
+	respondStock(c, withDepth(stock, wantDepth(c)))
 }
 
 func (s *APIServer) getStocks(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if raw, ok := c.GetQuery("symbols"); ok {
+		s.getStocksBySymbols(c, raw)
+		return
+	}
+
+	params, paged, err := parseStockListParams(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
 	// Get all stock symbols
-	symbols, err := s.redisClient.SMembers(ctx, "symbols:stock").Result()
+	symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey("stock")).Result()
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get stock symbols from Redis")
 		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve symbols"})
 		return
 	}
+	symbols = dedupeLegacyMembers(symbols)
 
 	if len(symbols) == 0 {
-		c.JSON(200, []StockResponse{})
+		if paged {
+			s.respondStockPage(c, paginateStocks(nil, params), params)
+			return
+		}
+		respondWithETag(c, nil, []StockResponse{})
 		return
 	}
 
 	// Get data for all symbols
-	pipe := s.redisClient.Pipeline()
-	cmds := make(map[string]*redis.StringStringMapCmd)
-
-	for _, symbol := range symbols {
-		key := fmt.Sprintf("latest:stock:%s", symbol)
-		cmds[symbol] = pipe.HGetAll(ctx, key)
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = s.latestKey("stock", symbol)
 	}
-
-	if _, err := pipe.Exec(ctx); err != nil {
+	snapshots, err := s.readStockSnapshots(ctx, keys)
+	if err != nil {
 		s.logger.WithError(err).Error("Failed to execute Redis pipeline")
 		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
 		return
 	}
 
+	includeDepth := wantDepth(c)
 	stocks := make([]StockResponse, 0, len(symbols))
-	for symbol, cmd := range cmds {
-		result, err := cmd.Result()
-		if err != nil || len(result) == 0 {
+	for i, raw := range snapshots {
+		if !raw.exists() {
 			continue
 		}
 
-		stock, err := s.parseStockFromRedis(result)
+		stock, err := s.parseStockSnapshot(raw)
 		if err != nil {
-			s.logger.WithError(err).WithField("symbol", symbol).Warn("Failed to parse stock data")
+			s.logger.WithError(err).WithField("symbol", symbols[i]).Warn("Failed to parse stock data")
 			continue
 		}
 
-		stocks = append(stocks, *stock)
+		stocks = append(stocks, withDepth(stock, includeDepth))
 	}
 
-	c.JSON(200, stocks)
+	if paged {
+		s.respondStockPage(c, paginateStocks(stocks, params), params)
+		return
+	}
+
+	// 按代码排序，保证相同数据的响应体与 ETag 一致
+	sort.Slice(stocks, func(i, j int) bool { return stocks[i].Symbol < stocks[j].Symbol })
+	respondWithETag(c, stockETagParts(stocks...), stocks)
 }
 
 func (s *APIServer) getIndex(c *gin.Context) {
@@ -527,46 +780,59 @@ func (s *APIServer) getIndex(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	key := fmt.Sprintf("latest:index:%s", symbol)
-	result, err := s.redisClient.HGetAll(ctx, key).Result()
-	if err != nil {
-		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to get index data from Redis")
-		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
-		return
+	// 规范形式的键不存在时回退到旧格式的键
+	var result map[string]string
+	for _, candidate := range symbolCandidates(symbol) {
+		data, err := s.redisClient.HGetAll(ctx, s.latestKey("index", candidate)).Result()
+		if err != nil {
+			s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to get index data from Redis")
+			c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+			return
+		}
+		if len(data) > 0 {
+			result = data
+			break
+		}
 	}
 
 	if len(result) == 0 {
-		c.JSON(404, ErrorResponse{Error: "not_found", Message: "Index not found"})
+		respondError(c, apperrors.NewError(apperrors.CodeSymbolNotFound, "index not found"), "Index not found")
 		return
 	}
 
 	index, err := s.parseIndexFromRedis(result)
 	if err != nil {
 		s.logger.WithError(err).WithField("symbol", symbol).Error("Failed to parse index data")
-		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to parse data"})
+		respondError(c, apperrors.Wrap(apperrors.CodeParseFailure, err, "symbol", symbol), "Failed to parse data")
 		return
 	}
 
-	c.JSON(200, index)
+	respondIndex(c, *index)
 }
 
 func (s *APIServer) getIndices(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if raw, ok := c.GetQuery("symbols"); ok {
+		s.getIndicesBySymbols(c, raw)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
 	// Get all index symbols
-	symbols, err := s.redisClient.SMembers(ctx, "symbols:index").Result()
+	symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey("index")).Result()
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get index symbols from Redis")
 		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve symbols"})
 		return
 	}
+	symbols = dedupeLegacyMembers(symbols)
 
 	if len(symbols) == 0 {
-		c.JSON(200, []IndexResponse{})
+		respondWithETag(c, nil, []IndexResponse{})
 		return
 	}
 
@@ -575,7 +841,7 @@ func (s *APIServer) getIndices(c *gin.Context) {
 	cmds := make(map[string]*redis.StringStringMapCmd)
 
 	for _, symbol := range symbols {
-		key := fmt.Sprintf("latest:index:%s", symbol)
+		key := s.latestKey("index", symbol)
 		cmds[symbol] = pipe.HGetAll(ctx, key)
 	}
 
@@ -601,7 +867,8 @@ func (s *APIServer) getIndices(c *gin.Context) {
 		indices = append(indices, *index)
 	}
 
-	c.JSON(200, indices)
+	sort.Slice(indices, func(i, j int) bool { return indices[i].Symbol < indices[j].Symbol })
+	respondWithETag(c, indexETagParts(indices...), indices)
 }
 
 func (s *APIServer) getStockHistory(c *gin.Context) {
@@ -638,19 +905,41 @@ func (s *APIServer) getStockHistory(c *gin.Context) {
 		end = time.Now()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	interval := c.Query("interval")
+	if interval != "" {
+		if err := validateHistoryInterval(interval, start, end); err != nil {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+			return
+		}
+	}
+
+	format, err := parseHistoryFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
+	// 相对时间窗口每次请求都在变化，只缓存显式指定起止时间的查询
+	var cacheKey string
+	if startStr != "" && endStr != "" {
+		cacheKey = fmt.Sprintf("history:stock:%s:%d:%d:%s:%s", symbol, start.Unix(), end.Unix(), interval, format)
+		if cached, ok := s.cacheGet(ctx, c, cacheKey); ok {
+			writeHistoryCacheEntry(c, cached.(*historyCacheEntry))
+			return
+		}
+	}
+
 	// Query InfluxDB
-	query := fmt.Sprintf(`
-		from(bucket: "%s")
-		|> range(start: %s, stop: %s)
-		|> filter(fn: (r) => r._measurement == "stock_realtime")
-		|> filter(fn: (r) => r.symbol == "%s")
-		|> filter(fn: (r) => r._field == "price" or r._field == "volume")
-		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
-		|> sort(columns: ["_time"])
-	`, viper.GetString("influxdb.bucket"), start.Format(time.RFC3339), end.Format(time.RFC3339), symbol)
+	bucket := viper.GetString("influxdb.bucket")
+	var query string
+	if interval != "" {
+		query = buildOHLCHistoryQuery(bucket, s.namespace, "stock_realtime", symbol, "price", start, end, interval, "volume")
+	} else {
+		query = buildRawHistoryQuery(bucket, s.namespace, "stock_realtime", symbol, start, end)
+	}
 
 	result, err := s.queryAPI.Query(ctx, query)
 	if err != nil {
@@ -660,35 +949,21 @@ func (s *APIServer) getStockHistory(c *gin.Context) {
 	}
 	defer result.Close()
 
-	var dataPoints []HistoricalDataPoint
-	for result.Next() {
-		record := result.Record()
-
-		timestamp := record.Time()
-		price, _ := record.ValueByKey("price").(float64)
-		volume, _ := record.ValueByKey("volume").(int64)
-
-		dataPoints = append(dataPoints, HistoricalDataPoint{
-			Timestamp: timestamp,
-			Price:     price,
-			Volume:    volume,
-		})
+	meta := HistoricalResponse{
+		Symbol:   symbol,
+		Start:    start,
+		End:      end,
+		Interval: interval,
 	}
 
-	if result.Err() != nil {
-		s.logger.WithError(result.Err()).WithField("symbol", symbol).Error("Error reading InfluxDB result")
-		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to read historical data"})
-		return
+	stream := historyStream{Meta: meta, Format: format, Convert: stockPointFromRecord}
+	if interval != "" {
+		stream.Convert = barFromRecord
 	}
 
-	response := HistoricalResponse{
-		Symbol: symbol,
-		Start:  start,
-		End:    end,
-		Data:   dataPoints,
+	if entry := s.streamHistory(c, stream, result); cacheKey != "" && entry != nil {
+		s.cacheSet(ctx, c, cacheKey, entry, s.historyCacheTTL)
 	}
-
-	c.JSON(200, response)
 }
 
 func (s *APIServer) getIndexHistory(c *gin.Context) {
@@ -725,18 +1000,38 @@ func (s *APIServer) getIndexHistory(c *gin.Context) {
 		end = time.Now()
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// legacy=1 保留旧版 {timestamp, price, volume} 响应格式，下个版本移除
+	legacy := c.Query("legacy") == "1"
+
+	interval := c.Query("interval")
+	if interval != "" {
+		if legacy {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: "interval is not supported with legacy=1"})
+			return
+		}
+		if err := validateHistoryInterval(interval, start, end); err != nil {
+			c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+			return
+		}
+	}
+
+	format, err := parseHistoryFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
 	// Query InfluxDB
-	query := fmt.Sprintf(`
-		from(bucket: "%s")
-		|> range(start: %s, stop: %s)
-		|> filter(fn: (r) => r._measurement == "index_realtime")
-		|> filter(fn: (r) => r.symbol == "%s")
-		|> filter(fn: (r) => r._field == "value")
-		|> sort(columns: ["_time"])
-	`, viper.GetString("influxdb.bucket"), start.Format(time.RFC3339), end.Format(time.RFC3339), symbol)
+	bucket := viper.GetString("influxdb.bucket")
+	var query string
+	if interval != "" {
+		query = buildOHLCHistoryQuery(bucket, s.namespace, "index_realtime", symbol, "value", start, end, interval, "volume", "turnover")
+	} else {
+		query = buildRawIndexHistoryQuery(bucket, s.namespace, symbol, start, end)
+	}
 
 	result, err := s.queryAPI.Query(ctx, query)
 	if err != nil {
@@ -746,46 +1041,35 @@ func (s *APIServer) getIndexHistory(c *gin.Context) {
 	}
 	defer result.Close()
 
-	var dataPoints []HistoricalDataPoint
-	for result.Next() {
-		record := result.Record()
-
-		timestamp := record.Time()
-		value, _ := record.Value().(float64)
-
-		dataPoints = append(dataPoints, HistoricalDataPoint{
-			Timestamp: timestamp,
-			Price:     value, // Use Price field for index value
-			Volume:    0,     // Indices don't have volume
-		})
+	meta := HistoricalResponse{
+		Symbol:   symbol,
+		Start:    start,
+		End:      end,
+		Interval: interval,
 	}
 
-	if result.Err() != nil {
-		s.logger.WithError(result.Err()).WithField("symbol", symbol).Error("Error reading InfluxDB result")
-		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to read historical data"})
-		return
+	stream := historyStream{Meta: meta, Format: format, Convert: indexPointFromRecord, Columns: historyIndexPointColumns}
+	switch {
+	case legacy:
+		stream.Convert, stream.Columns = legacyIndexPointFromRecord, historyPointColumns
+	case interval != "":
+		stream.Convert, stream.Columns = indexBarFromRecord, historyIndexBarColumns
 	}
 
-	response := HistoricalResponse{
-		Symbol: symbol,
-		Start:  start,
-		End:    end,
-		Data:   dataPoints,
-	}
-
-	c.JSON(200, response)
+	s.streamHistory(c, stream, result)
 }
 
 func (s *APIServer) getStockSymbols(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	symbols, err := s.redisClient.SMembers(ctx, "symbols:stock").Result()
+	symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey("stock")).Result()
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get stock symbols from Redis")
 		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve symbols"})
 		return
 	}
+	symbols = dedupeLegacyMembers(symbols)
 
 	c.JSON(200, map[string]interface{}{
 		"type":    "stock",
@@ -795,15 +1079,16 @@ func (s *APIServer) getStockSymbols(c *gin.Context) {
 }
 
 func (s *APIServer) getIndexSymbols(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
-	symbols, err := s.redisClient.SMembers(ctx, "symbols:index").Result()
+	symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey("index")).Result()
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get index symbols from Redis")
 		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve symbols"})
 		return
 	}
+	symbols = dedupeLegacyMembers(symbols)
 
 	c.JSON(200, map[string]interface{}{
 		"type":    "index",
@@ -833,6 +1118,14 @@ func (s *APIServer) parseStockFromRedis(data map[string]string) (*StockResponse,
 		return nil, fmt.Errorf("invalid volume: %w", err)
 	}
 
+	// 旧版 redis_collector 不写入成交额，缺失时按 0 处理
+	var turnover float64
+	if raw, ok := data["turnover"]; ok {
+		if turnover, err = strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("invalid turnover: %w", err)
+		}
+	}
+
 	timestamp, err := strconv.ParseInt(data["timestamp"], 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("invalid timestamp: %w", err)
@@ -843,18 +1136,23 @@ func (s *APIServer) parseStockFromRedis(data map[string]string) (*StockResponse,
 		return nil, fmt.Errorf("invalid updated_at: %w", err)
 	}
 
-	return &StockResponse{
+	stock := &StockResponse{
 		Symbol:        data["symbol"],
 		Name:          data["name"],
 		Price:         price,
 		Change:        change,
 		ChangePercent: changePercent,
 		Volume:        volume,
+		Turnover:      turnover,
 		Timestamp:     time.Unix(timestamp, 0),
 		Provider:      data["provider"],
 		Market:        data["market"],
 		UpdatedAt:     time.Unix(updatedAt, 0),
-	}, nil
+		Depth:         parseOrderBook(data),
+		TraceID:       data["trace_id"],
+	}
+	s.staleness.annotateStock(stock)
+	return stock, nil
 }
 
 func (s *APIServer) parseIndexFromRedis(data map[string]string) (*IndexResponse, error) {
@@ -883,7 +1181,7 @@ func (s *APIServer) parseIndexFromRedis(data map[string]string) (*IndexResponse,
 		return nil, fmt.Errorf("invalid updated_at: %w", err)
 	}
 
-	return &IndexResponse{
+	index := &IndexResponse{
 		Symbol:        data["symbol"],
 		Name:          data["name"],
 		Value:         value,
@@ -893,7 +1191,10 @@ func (s *APIServer) parseIndexFromRedis(data map[string]string) (*IndexResponse,
 		Provider:      data["provider"],
 		Market:        data["market"],
 		UpdatedAt:     time.Unix(updatedAt, 0),
-	}, nil
+		TraceID:       data["trace_id"],
+	}
+	s.staleness.annotateIndex(index)
+	return index, nil
 }
 
 // getLegacyStock 向后兼容的单个股票查询端点
@@ -908,13 +1209,15 @@ func (s *APIServer) getLegacyStocks(c *gin.Context) {
 	s.getStocks(c)
 }
 
-// getMetrics 获取系统指标
-func (s *APIServer) getMetrics(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+// getStats 获取系统统计信息
+func (s *APIServer) getStats(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
 	defer cancel()
 
-	metrics := map[string]interface{}{
+	stats := map[string]interface{}{
 		"timestamp": time.Now(),
+		"version":   "1.0.0",
+		"uptime":    time.Since(time.Now().Add(-1 * time.Hour)), // 简单示例
 		"cache":     map[string]interface{}{},
 		"redis":     map[string]interface{}{},
 		"influxdb":  map[string]interface{}{},
@@ -923,7 +1226,7 @@ func (s *APIServer) getMetrics(c *gin.Context) {
 	// 获取缓存统计
 	if s.cache != nil {
 		cacheStats := s.cache.Stats()
-		metrics["cache"] = map[string]interface{}{
+		stats["cache"] = map[string]interface{}{
 			"size":       cacheStats.Size,
 			"max_size":   cacheStats.MaxSize,
 			"hit_count":  cacheStats.HitCount,
@@ -935,12 +1238,12 @@ func (s *APIServer) getMetrics(c *gin.Context) {
 	// 获取Redis信息
 	if s.redisClient != nil {
 		if err := s.redisClient.Ping(ctx).Err(); err == nil {
-			metrics["redis"] = map[string]interface{}{
+			stats["redis"] = map[string]interface{}{
 				"status": "connected",
 				"info":   "available",
 			}
 		} else {
-			metrics["redis"] = map[string]interface{}{
+			stats["redis"] = map[string]interface{}{
 				"status": "error",
 				"error":  err.Error(),
 			}
@@ -950,36 +1253,22 @@ func (s *APIServer) getMetrics(c *gin.Context) {
 	// 获取InfluxDB健康状态
 	if s.influxClient != nil {
 		if health, err := s.influxClient.Health(ctx); err == nil {
-			metrics["influxdb"] = map[string]interface{}{
+			stats["influxdb"] = map[string]interface{}{
 				"status": string(health.Status),
 				"name":   health.Name,
 			}
 		} else {
-			metrics["influxdb"] = map[string]interface{}{
+			stats["influxdb"] = map[string]interface{}{
 				"status": "error",
 				"error":  err.Error(),
 			}
 		}
 	}
 
-	c.JSON(200, metrics)
-}
-
-// getStats 获取系统统计信息
-func (s *APIServer) getStats(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-
-	stats := map[string]interface{}{
-		"timestamp": time.Now(),
-		"version":   "1.0.0",
-		"uptime":    time.Since(time.Now().Add(-1 * time.Hour)), // 简单示例
-	}
-
 	// 获取Redis键统计
 	if s.redisClient != nil {
-		stockCount, _ := s.redisClient.SCard(ctx, "symbols:stock").Result()
-		indexCount, _ := s.redisClient.SCard(ctx, "symbols:index").Result()
+		stockCount, _ := s.redisClient.SCard(ctx, s.symbolsKey("stock")).Result()
+		indexCount, _ := s.redisClient.SCard(ctx, s.symbolsKey("index")).Result()
 
 		stats["data"] = map[string]interface{}{
 			"stock_symbols": stockCount,
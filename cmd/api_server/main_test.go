@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIServer_RedisKeys(t *testing.T) {
+	s := &APIServer{}
+	assert.Equal(t, "latest:stock:600000", s.latestKey("stock", "600000"))
+	assert.Equal(t, "latest:symbols:index", s.symbolsKey("index"))
+
+	s.redisKeyPrefix = "dev:latest:"
+	assert.Equal(t, "dev:latest:index:sh000001", s.latestKey("index", "sh000001"))
+	assert.Equal(t, "dev:latest:symbols:stock", s.symbolsKey("stock"))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"stocksub/pkg/cache"
+)
+
+// metricsNamespace Prometheus 指标前缀
+const metricsNamespace = "stocksub_api"
+
+// probeTimeout 每次抓取时健康探测的超时时间
+const probeTimeout = 2 * time.Second
+
+// apiMetrics api_server 的 Prometheus 指标
+type apiMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// newAPIMetrics 创建独立的指标注册表，缓存和依赖健康状态在抓取时实时采集
+func newAPIMetrics(s *APIServer) *apiMetrics {
+	m := &apiMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Total number of HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP handler latency in seconds by method and route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		&serverCollector{server: s},
+	)
+	return m
+}
+
+// middleware 记录每个请求的路由、状态码和耗时，未匹配的路由统一归为 unmatched 以控制标签基数
+func (m *apiMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.latency.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// handler 返回 Prometheus 文本格式的抓取端点
+func (m *apiMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+var (
+	cacheHitRateDesc = prometheus.NewDesc(metricsNamespace+"_cache_hit_rate", "Response cache hit rate.", nil, nil)
+	cacheSizeDesc    = prometheus.NewDesc(metricsNamespace+"_cache_size", "Number of entries in the response cache.", nil, nil)
+	cacheHitsDesc    = prometheus.NewDesc(metricsNamespace+"_cache_hits_total", "Total response cache hits.", nil, nil)
+	cacheMissesDesc  = prometheus.NewDesc(metricsNamespace+"_cache_misses_total", "Total response cache misses.", nil, nil)
+	cacheEvictsDesc  = prometheus.NewDesc(metricsNamespace+"_cache_evictions_total", "Total response cache entries removed by reason (capacity or ttl).", []string{"reason"}, nil)
+	cacheBytesDesc   = prometheus.NewDesc(metricsNamespace+"_cache_memory_bytes", "Approximate bytes held by response cache entries.", nil, nil)
+	cachePromoteDesc = prometheus.NewDesc(metricsNamespace+"_cache_promotions_total", "Total entries promoted to an upper cache layer.", nil, nil)
+	cacheDemoteDesc  = prometheus.NewDesc(metricsNamespace+"_cache_demotions_total", "Total entries demoted to a lower cache layer.", nil, nil)
+	layerSizeDesc    = prometheus.NewDesc(metricsNamespace+"_cache_layer_size", "Number of entries in each response cache layer.", []string{"layer"}, nil)
+	layerBytesDesc   = prometheus.NewDesc(metricsNamespace+"_cache_layer_memory_bytes", "Approximate bytes held by each response cache layer.", []string{"layer"}, nil)
+	layerEvictsDesc  = prometheus.NewDesc(metricsNamespace+"_cache_layer_evictions_total", "Total entries removed from each response cache layer by reason.", []string{"layer", "reason"}, nil)
+	dependencyUpDesc = prometheus.NewDesc(metricsNamespace+"_dependency_up", "Whether a backing dependency passed its health probe (1) or not (0).", []string{"dependency"}, nil)
+)
+
+// serverCollector 在抓取时读取缓存统计并探测 Redis / InfluxDB 健康状态
+type serverCollector struct {
+	server *APIServer
+}
+
+func (sc *serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitRateDesc
+	ch <- cacheSizeDesc
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEvictsDesc
+	ch <- cacheBytesDesc
+	ch <- cachePromoteDesc
+	ch <- cacheDemoteDesc
+	ch <- layerSizeDesc
+	ch <- layerBytesDesc
+	ch <- layerEvictsDesc
+	ch <- dependencyUpDesc
+}
+
+func (sc *serverCollector) Collect(ch chan<- prometheus.Metric) {
+	s := sc.server
+
+	if s.cache != nil {
+		stats := s.cache.Stats()
+		ch <- prometheus.MustNewConstMetric(cacheHitRateDesc, prometheus.GaugeValue, stats.HitRate)
+		ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(stats.Size))
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(stats.HitCount))
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(stats.MissCount))
+		ch <- prometheus.MustNewConstMetric(cacheEvictsDesc, prometheus.CounterValue, float64(stats.Evictions), string(cache.EvictionCapacity))
+		ch <- prometheus.MustNewConstMetric(cacheEvictsDesc, prometheus.CounterValue, float64(stats.Expirations), string(cache.EvictionTTL))
+		ch <- prometheus.MustNewConstMetric(cacheBytesDesc, prometheus.GaugeValue, float64(stats.MemoryBytes))
+		ch <- prometheus.MustNewConstMetric(cachePromoteDesc, prometheus.CounterValue, float64(stats.Promotions))
+		ch <- prometheus.MustNewConstMetric(cacheDemoteDesc, prometheus.CounterValue, float64(stats.Demotions))
+
+		// 分层缓存按层输出
+		for i, layerStats := range stats.Layers {
+			layer := strconv.Itoa(i)
+			ch <- prometheus.MustNewConstMetric(layerSizeDesc, prometheus.GaugeValue, float64(layerStats.Size), layer)
+			ch <- prometheus.MustNewConstMetric(layerBytesDesc, prometheus.GaugeValue, float64(layerStats.MemoryBytes), layer)
+			ch <- prometheus.MustNewConstMetric(layerEvictsDesc, prometheus.CounterValue, float64(layerStats.Evictions), layer, string(cache.EvictionCapacity))
+			ch <- prometheus.MustNewConstMetric(layerEvictsDesc, prometheus.CounterValue, float64(layerStats.Expirations), layer, string(cache.EvictionTTL))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	if s.redisClient != nil {
+		up := 0.0
+		if err := s.redisClient.Ping(ctx).Err(); err == nil {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(dependencyUpDesc, prometheus.GaugeValue, up, "redis")
+	}
+
+	if s.influxClient != nil {
+		up := 0.0
+		if health, err := s.influxClient.Health(ctx); err == nil && health.Status == "pass" {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(dependencyUpDesc, prometheus.GaugeValue, up, "influxdb")
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/cache"
+)
+
+func newMetricsTestRouter(source *fakeSnapshotSource, apiCache cache.Cache) (*APIServer, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	s := &APIServer{
+		logger:        logger,
+		cache:         apiCache,
+		loadSnapshots: source.load,
+		stockCacheTTL: time.Minute,
+	}
+	s.metrics = newAPIMetrics(s)
+
+	router := gin.New()
+	router.Use(s.metrics.middleware())
+	router.GET("/api/v1/stocks/:symbol", s.getStock)
+	router.GET("/metrics", gin.WrapH(s.metrics.handler()))
+	return s, router
+}
+
+func TestMetrics_CountsRequestsByRouteAndStatus(t *testing.T) {
+	s, router := newMetricsTestRouter(newBatchTestSource(), nil)
+
+	doGet(t, router, "/api/v1/stocks/600000")
+	doGet(t, router, "/api/v1/stocks/000001")
+	doGet(t, router, "/api/v1/stocks/999999")
+	doGet(t, router, "/no/such/route")
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(s.metrics.requests.WithLabelValues("GET", "/api/v1/stocks/:symbol", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.requests.WithLabelValues("GET", "/api/v1/stocks/:symbol", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(s.metrics.requests.WithLabelValues("GET", "unmatched", "404")))
+}
+
+func TestMetrics_ExpositionFormat(t *testing.T) {
+	memCache := cache.NewMemoryCache(cache.MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute})
+	defer memCache.Close()
+	_, router := newMetricsTestRouter(newBatchTestSource(), memCache)
+
+	doGet(t, router, "/api/v1/stocks/600000")
+	doGet(t, router, "/api/v1/stocks/600000")
+
+	w := doGet(t, router, "/metrics")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+
+	body := w.Body.String()
+	assert.Contains(t, body, `stocksub_api_http_requests_total{method="GET",route="/api/v1/stocks/:symbol",status="200"} 2`)
+	assert.Contains(t, body, "stocksub_api_http_request_duration_seconds_bucket")
+	assert.Contains(t, body, "stocksub_api_cache_hits_total 1")
+	assert.Contains(t, body, "stocksub_api_cache_misses_total 1")
+	assert.Contains(t, body, "stocksub_api_cache_hit_rate 0.5")
+	assert.Contains(t, body, "stocksub_api_cache_size 1")
+	assert.Contains(t, body, `stocksub_api_cache_evictions_total{reason="capacity"} 0`)
+	assert.Contains(t, body, "stocksub_api_cache_memory_bytes")
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestMetrics_LayeredCacheBreakdown(t *testing.T) {
+	layered, err := cache.NewLayeredCache(cache.LayeredCacheConfig{
+		Layers: []cache.LayerConfig{
+			{Type: cache.LayerMemory, MaxSize: 1, TTL: time.Minute, Enabled: true, Policy: cache.PolicyLRU, CleanupInterval: time.Minute},
+			{Type: cache.LayerMemory, MaxSize: 10, TTL: time.Minute, Enabled: true, Policy: cache.PolicyLFU, CleanupInterval: time.Minute},
+		},
+		DemoteEnabled: true,
+	})
+	require.NoError(t, err)
+	defer layered.Close()
+	_, router := newMetricsTestRouter(newBatchTestSource(), layered)
+
+	// 第一层只能容纳一个条目，第二个请求把第一个条目降级到第二层
+	doGet(t, router, "/api/v1/stocks/600000")
+	doGet(t, router, "/api/v1/stocks/000001")
+
+	body := doGet(t, router, "/metrics").Body.String()
+	assert.Contains(t, body, "stocksub_api_cache_demotions_total 1")
+	assert.Contains(t, body, `stocksub_api_cache_layer_evictions_total{layer="0",reason="capacity"} 1`)
+	assert.Contains(t, body, `stocksub_api_cache_layer_size{layer="0"} 1`)
+	assert.Contains(t, body, `stocksub_api_cache_layer_size{layer="1"} 1`)
+	assert.Contains(t, body, `stocksub_api_cache_layer_memory_bytes{layer="1"}`)
+}
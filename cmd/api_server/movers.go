@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/core"
+)
+
+const (
+	defaultMoversLimit = 20
+	maxMoversLimit     = 100
+)
+
+// moverMetrics 支持的排行指标，与 redis_collector 写入的 rank:<指标> 有序集合一致
+var moverMetrics = map[string]bool{
+	"change_percent": true,
+	"volume":         true,
+	"turnover":       true,
+}
+
+// MarketMover 排行榜中的一只股票，Score 为排行指标的值
+type MarketMover struct {
+	Rank  int     `json:"rank"`
+	Score float64 `json:"score"`
+	StockResponse
+}
+
+// MarketSummary 按 change_percent 有序集合统计的涨跌家数
+type MarketSummary struct {
+	Advancers int64 `json:"advancers"`
+	Decliners int64 `json:"decliners"`
+	Unchanged int64 `json:"unchanged"`
+	Total     int64 `json:"total"`
+}
+
+// MarketMoversResponse 排行榜响应
+type MarketMoversResponse struct {
+	By        string        `json:"by"`
+	Direction string        `json:"direction"`
+	Movers    []MarketMover `json:"movers"`
+	Summary   MarketSummary `json:"summary"`
+}
+
+// rankKey 返回排行榜有序集合的键，例如 latest:rank:change_percent
+func (s *APIServer) rankKey(metric string) string {
+	return s.keyPrefix() + "rank:" + metric
+}
+
+// parseMoversParams 校验 by、direction 和 limit 参数
+func parseMoversParams(c *gin.Context) (by, direction string, limit int, err error) {
+	by = c.DefaultQuery("by", "change_percent")
+	if !moverMetrics[by] {
+		return "", "", 0, fmt.Errorf("unsupported by %q, supported: change_percent, volume, turnover", by)
+	}
+
+	direction = c.DefaultQuery("direction", "desc")
+	if direction != "desc" && direction != "asc" {
+		return "", "", 0, fmt.Errorf("unsupported direction %q, supported: desc, asc", direction)
+	}
+
+	limit = defaultMoversLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxMoversLimit {
+			return "", "", 0, fmt.Errorf("limit must be an integer between 1 and %d", maxMoversLimit)
+		}
+	}
+	return by, direction, limit, nil
+}
+
+// getMarketMovers 返回涨幅、成交量或成交额排行，并附带涨跌家数统计
+// 排行从有序集合读取，再用一个 pipeline 从最新数据哈希补全行情
+func (s *APIServer) getMarketMovers(c *gin.Context) {
+	by, direction, limit, err := parseMoversParams(c)
+	if err != nil {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	// 哈希过期后成员可能仍留在有序集合中，多取一些以便跳过后仍能凑满 limit
+	fetch := int64(limit * 2)
+	var ranked []redis.Z
+	if direction == "desc" {
+		ranked, err = s.redisClient.ZRevRangeWithScores(ctx, s.rankKey(by), 0, fetch-1).Result()
+	} else {
+		ranked, err = s.redisClient.ZRangeWithScores(ctx, s.rankKey(by), 0, fetch-1).Result()
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("by", by).Error("Failed to get rank from Redis")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve rank"})
+		return
+	}
+
+	keys := make([]string, len(ranked))
+	for i, z := range ranked {
+		keys[i] = s.latestKey("stock", fmt.Sprint(z.Member))
+	}
+	snapshots, err := s.readStockSnapshots(ctx, keys)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to execute Redis pipeline")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+		return
+	}
+
+	pipe := s.redisClient.Pipeline()
+	changeKey := s.rankKey("change_percent")
+	advancers := pipe.ZCount(ctx, changeKey, "(0", "+inf")
+	decliners := pipe.ZCount(ctx, changeKey, "-inf", "(0")
+	total := pipe.ZCard(ctx, changeKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		s.logger.WithError(err).Error("Failed to execute Redis pipeline")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+		return
+	}
+
+	includeDepth := wantDepth(c)
+	movers := make([]MarketMover, 0, limit)
+	seen := make(map[string]struct{}, len(snapshots))
+	for i, raw := range snapshots {
+		if len(movers) == limit {
+			break
+		}
+		if !raw.exists() {
+			continue
+		}
+		stock, err := s.parseStockSnapshot(raw)
+		if err != nil {
+			s.logger.WithError(err).WithField("symbol", ranked[i].Member).Warn("Failed to parse stock data")
+			continue
+		}
+		// 过渡期内同一股票可能同时以旧格式和规范形式出现在有序集合中
+		symbol := core.NormalizeSymbol(stock.Symbol)
+		if _, ok := seen[symbol]; ok {
+			continue
+		}
+		seen[symbol] = struct{}{}
+		movers = append(movers, MarketMover{Rank: len(movers) + 1, Score: ranked[i].Score, StockResponse: withDepth(stock, includeDepth)})
+	}
+
+	summary := MarketSummary{
+		Advancers: advancers.Val(),
+		Decliners: decliners.Val(),
+		Total:     total.Val(),
+	}
+	summary.Unchanged = summary.Total - summary.Advancers - summary.Decliners
+
+	c.JSON(200, MarketMoversResponse{
+		By:        by,
+		Direction: direction,
+		Movers:    movers,
+		Summary:   summary,
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMoversTestRouter(t *testing.T) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/market/movers", s.getMarketMovers)
+	return router, mr
+}
+
+// addMoverStock 写入与 redis_collector 相同的最新数据哈希和排行有序集合
+func addMoverStock(mr *miniredis.Miniredis, symbol string, changePercent float64, volume int64) {
+	mr.HSet("latest:stock:"+symbol,
+		"symbol", symbol, "name", symbol, "price", "10", "change", "0",
+		"change_percent", fmt.Sprint(changePercent), "volume", fmt.Sprint(volume), "turnover", fmt.Sprint(volume*10),
+		"timestamp", "1755655200", "updated_at", "1755655200")
+	mr.ZAdd("latest:rank:change_percent", changePercent, symbol)
+	mr.ZAdd("latest:rank:volume", float64(volume), symbol)
+	mr.ZAdd("latest:rank:turnover", float64(volume*10), symbol)
+}
+
+func getMovers(t *testing.T, router *gin.Engine, query string) MarketMoversResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/market/movers?"+query, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp MarketMoversResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func moverSymbols(movers []MarketMover) []string {
+	symbols := make([]string, len(movers))
+	for i, m := range movers {
+		symbols[i] = m.Symbol
+	}
+	return symbols
+}
+
+func TestGetMarketMovers_RanksAndSummarizes(t *testing.T) {
+	router, mr := newMoversTestRouter(t)
+	addMoverStock(mr, "600000", 5.2, 100)
+	addMoverStock(mr, "600036", 1.1, 900)
+	addMoverStock(mr, "000001", -2.3, 500)
+	addMoverStock(mr, "000002", 0, 50)
+	addMoverStock(mr, "300750", -0.4, 300)
+
+	resp := getMovers(t, router, "")
+	assert.Equal(t, "change_percent", resp.By)
+	assert.Equal(t, "desc", resp.Direction)
+	assert.Equal(t, []string{"600000", "600036", "000002", "300750", "000001"}, moverSymbols(resp.Movers))
+	assert.Equal(t, 1, resp.Movers[0].Rank)
+	assert.Equal(t, 5.2, resp.Movers[0].Score)
+	assert.Equal(t, 5.2, resp.Movers[0].ChangePercent)
+	assert.Equal(t, 1000.0, resp.Movers[0].Turnover)
+	assert.Equal(t, MarketSummary{Advancers: 2, Decliners: 2, Unchanged: 1, Total: 5}, resp.Summary)
+
+	resp = getMovers(t, router, "by=change_percent&direction=asc&limit=2")
+	assert.Equal(t, []string{"000001", "300750"}, moverSymbols(resp.Movers))
+
+	resp = getMovers(t, router, "by=volume&limit=3")
+	assert.Equal(t, []string{"600036", "000001", "300750"}, moverSymbols(resp.Movers))
+	assert.Equal(t, 900.0, resp.Movers[0].Score)
+}
+
+func TestGetMarketMovers_SkipsExpiredHashes(t *testing.T) {
+	router, mr := newMoversTestRouter(t)
+	addMoverStock(mr, "600000", 5.2, 100)
+	addMoverStock(mr, "600036", 1.1, 900)
+	addMoverStock(mr, "000001", -2.3, 500)
+	mr.Del("latest:stock:600000")
+
+	resp := getMovers(t, router, "limit=2")
+	assert.Equal(t, []string{"600036", "000001"}, moverSymbols(resp.Movers))
+	assert.Equal(t, 1, resp.Movers[0].Rank)
+}
+
+func TestGetMarketMovers_Empty(t *testing.T) {
+	router, _ := newMoversTestRouter(t)
+
+	resp := getMovers(t, router, "")
+	assert.Empty(t, resp.Movers)
+	assert.Equal(t, MarketSummary{}, resp.Summary)
+}
+
+func TestGetMarketMovers_InvalidParams(t *testing.T) {
+	router, _ := newMoversTestRouter(t)
+	for _, query := range []string{"by=price", "direction=up", "limit=0", "limit=101", "limit=abc"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/market/movers?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStockListLimit = 50   // 分页默认条数
+	maxStockListLimit     = 1000 // 分页最大条数
+)
+
+// stockSortFields 支持的排序字段
+var stockSortFields = map[string]func(a, b *StockResponse) bool{
+	"symbol":         func(a, b *StockResponse) bool { return a.Symbol < b.Symbol },
+	"price":          func(a, b *StockResponse) bool { return a.Price < b.Price },
+	"change_percent": func(a, b *StockResponse) bool { return a.ChangePercent < b.ChangePercent },
+	"volume":         func(a, b *StockResponse) bool { return a.Volume < b.Volume },
+}
+
+// stockListParams 股票列表的分页和排序参数
+type stockListParams struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Order  string
+}
+
+// StockListResponse 分页后的股票列表
+type StockListResponse struct {
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+	Items  []StockResponse `json:"items"`
+}
+
+// parseStockListParams 解析分页和排序参数
+// 未提供任何分页参数时 paged 为 false，调用方应保持原有的数组响应
+func parseStockListParams(query url.Values) (params stockListParams, paged bool, err error) {
+	params = stockListParams{Limit: defaultStockListLimit, Sort: "symbol", Order: "asc"}
+
+	for _, key := range []string{"limit", "offset", "sort", "order"} {
+		if _, ok := query[key]; ok {
+			paged = true
+		}
+	}
+	if !paged {
+		return params, false, nil
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		params.Limit, err = strconv.Atoi(raw)
+		if err != nil || params.Limit <= 0 || params.Limit > maxStockListLimit {
+			return params, true, fmt.Errorf("limit must be between 1 and %d", maxStockListLimit)
+		}
+	}
+	if raw := query.Get("offset"); raw != "" {
+		params.Offset, err = strconv.Atoi(raw)
+		if err != nil || params.Offset < 0 {
+			return params, true, fmt.Errorf("offset must be a non-negative integer")
+		}
+	}
+	if raw := query.Get("sort"); raw != "" {
+		if _, ok := stockSortFields[raw]; !ok {
+			return params, true, fmt.Errorf("unsupported sort field %q, supported: symbol, price, change_percent, volume", raw)
+		}
+		params.Sort = raw
+	}
+	if raw := query.Get("order"); raw != "" {
+		if raw != "asc" && raw != "desc" {
+			return params, true, fmt.Errorf("order must be asc or desc")
+		}
+		params.Order = raw
+	}
+
+	return params, true, nil
+}
+
+// paginateStocks 按参数排序并截取一页，相同值按代码升序保证结果稳定
+func paginateStocks(stocks []StockResponse, params stockListParams) StockListResponse {
+	less := stockSortFields[params.Sort]
+	sort.SliceStable(stocks, func(i, j int) bool {
+		a, b := &stocks[i], &stocks[j]
+		if less(a, b) {
+			return params.Order == "asc"
+		}
+		if less(b, a) {
+			return params.Order == "desc"
+		}
+		return a.Symbol < b.Symbol
+	})
+
+	response := StockListResponse{
+		Total:  len(stocks),
+		Limit:  params.Limit,
+		Offset: params.Offset,
+		Items:  []StockResponse{},
+	}
+	if params.Offset < len(stocks) {
+		end := params.Offset + params.Limit
+		if end > len(stocks) {
+			end = len(stocks)
+		}
+		response.Items = stocks[params.Offset:end]
+	}
+	return response
+}
+
+// respondStockPage 输出分页响应，ETag 同时覆盖分页参数和当前页数据
+func (s *APIServer) respondStockPage(c *gin.Context, page StockListResponse, params stockListParams) {
+	parts := append([]string{fmt.Sprintf("page|%d|%d|%d|%s|%s", page.Total, page.Limit, page.Offset, params.Sort, params.Order)},
+		stockETagParts(page.Items...)...)
+	respondWithETag(c, parts, page)
+}
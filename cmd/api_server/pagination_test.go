@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStockList() []StockResponse {
+	return []StockResponse{
+		{Symbol: "600000", Price: 10.5, ChangePercent: 1.2, Volume: 3000},
+		{Symbol: "000001", Price: 12.3, ChangePercent: -0.8, Volume: 5000},
+		{Symbol: "300750", Price: 180.0, ChangePercent: 5.6, Volume: 1000},
+		{Symbol: "601398", Price: 5.1, ChangePercent: 1.2, Volume: 9000},
+	}
+}
+
+func symbolsOf(stocks []StockResponse) []string {
+	result := make([]string, 0, len(stocks))
+	for _, stock := range stocks {
+		result = append(result, stock.Symbol)
+	}
+	return result
+}
+
+func TestParseStockListParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantPaged bool
+		wantErr   bool
+		want      stockListParams
+	}{
+		{name: "无参数保持原响应", query: "", wantPaged: false, want: stockListParams{Limit: 50, Sort: "symbol", Order: "asc"}},
+		{name: "完整参数", query: "limit=20&offset=40&sort=change_percent&order=desc", wantPaged: true,
+			want: stockListParams{Limit: 20, Offset: 40, Sort: "change_percent", Order: "desc"}},
+		{name: "仅排序", query: "sort=volume", wantPaged: true, want: stockListParams{Limit: 50, Sort: "volume", Order: "asc"}},
+		{name: "非法排序字段", query: "sort=name", wantErr: true},
+		{name: "非法顺序", query: "order=up", wantErr: true},
+		{name: "limit为零", query: "limit=0", wantErr: true},
+		{name: "limit超限", query: "limit=1001", wantErr: true},
+		{name: "负偏移", query: "offset=-1", wantErr: true},
+		{name: "非数字偏移", query: "offset=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			params, paged, err := parseStockListParams(query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPaged, paged)
+			assert.Equal(t, tt.want, params)
+		})
+	}
+}
+
+func TestPaginateStocks_TopGainers(t *testing.T) {
+	response := paginateStocks(testStockList(), stockListParams{Limit: 2, Sort: "change_percent", Order: "desc"})
+
+	assert.Equal(t, 4, response.Total)
+	assert.Equal(t, 2, response.Limit)
+	assert.Equal(t, []string{"300750", "600000"}, symbolsOf(response.Items), "ties are broken by symbol")
+}
+
+func TestPaginateStocks_SortAndSlice(t *testing.T) {
+	response := paginateStocks(testStockList(), stockListParams{Limit: 2, Offset: 1, Sort: "price", Order: "asc"})
+	assert.Equal(t, []string{"600000", "000001"}, symbolsOf(response.Items))
+
+	response = paginateStocks(testStockList(), stockListParams{Limit: 10, Sort: "volume", Order: "desc"})
+	assert.Equal(t, []string{"601398", "000001", "600000", "300750"}, symbolsOf(response.Items))
+
+	response = paginateStocks(testStockList(), stockListParams{Limit: 10, Offset: 10, Sort: "symbol", Order: "asc"})
+	assert.Equal(t, 4, response.Total)
+	assert.NotNil(t, response.Items)
+	assert.Empty(t, response.Items)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachedResponse 响应缓存条目
+// 分层缓存在数据提升时会按各层默认 TTL 重新写入，因此条目自带过期时间，读取时再次校验
+type cachedResponse struct {
+	Value     interface{}
+	ExpiresAt time.Time
+}
+
+// cacheBypassed 请求是否通过 nocache=1 跳过缓存（需在配置中开启 allow_nocache）
+func (s *APIServer) cacheBypassed(c *gin.Context) bool {
+	return s.allowNoCache && c.Query("nocache") == "1"
+}
+
+// cacheGet 读取未过期的缓存响应，过期条目会被删除并视为未命中
+func (s *APIServer) cacheGet(ctx context.Context, c *gin.Context, key string) (interface{}, bool) {
+	if s.cache == nil || s.cacheBypassed(c) {
+		return nil, false
+	}
+
+	value, err := s.cache.Get(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+
+	entry, ok := value.(cachedResponse)
+	if !ok || time.Now().After(entry.ExpiresAt) {
+		if err := s.cache.Delete(ctx, key); err != nil {
+			s.logger.WithError(err).WithField("key", key).Debug("Failed to delete stale cache entry")
+		}
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// cacheSet 写入缓存响应，ttl 不大于 0 时不缓存
+func (s *APIServer) cacheSet(ctx context.Context, c *gin.Context, key string, value interface{}, ttl time.Duration) {
+	if s.cache == nil || ttl <= 0 || s.cacheBypassed(c) {
+		return
+	}
+
+	entry := cachedResponse{Value: value, ExpiresAt: time.Now().Add(ttl)}
+	if err := s.cache.Set(ctx, key, entry, ttl); err != nil {
+		s.logger.WithError(err).WithField("key", key).Warn("Failed to write response cache")
+	}
+}
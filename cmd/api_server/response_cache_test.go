@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/cache"
+)
+
+func newCacheTestServer(t *testing.T, source *fakeSnapshotSource, queryAPI *fakeQueryAPI) (*APIServer, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	memCache := cache.NewMemoryCache(cache.MemoryCacheConfig{
+		MaxSize:    100,
+		DefaultTTL: time.Minute,
+	})
+	t.Cleanup(func() { memCache.Close() })
+
+	s := &APIServer{
+		queryAPI:        queryAPI,
+		logger:          logger,
+		cache:           memCache,
+		loadSnapshots:   source.load,
+		stockCacheTTL:   time.Minute,
+		historyCacheTTL: time.Minute,
+		allowNoCache:    true,
+	}
+
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol", s.getStock)
+	router.GET("/api/v1/stocks/:symbol/history", s.getStockHistory)
+	router.GET("/stats", s.getStats)
+	return s, router
+}
+
+func doGet(t *testing.T, router *gin.Engine, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestGetStock_SecondRequestServedFromCache(t *testing.T) {
+	source := newBatchTestSource()
+	_, router := newCacheTestServer(t, source, nil)
+
+	first := doGet(t, router, "/api/v1/stocks/600000")
+	second := doGet(t, router, "/api/v1/stocks/600000")
+
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, 1, source.loadCalls(), "second request should not hit Redis")
+
+	w := doGet(t, router, "/stats")
+	var metrics struct {
+		Cache struct {
+			HitCount  int64 `json:"hit_count"`
+			MissCount int64 `json:"miss_count"`
+		} `json:"cache"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &metrics))
+	assert.Equal(t, int64(1), metrics.Cache.HitCount)
+	assert.Equal(t, int64(1), metrics.Cache.MissCount)
+}
+
+func TestGetStock_NoCacheBypassesCache(t *testing.T) {
+	source := newBatchTestSource()
+	s, router := newCacheTestServer(t, source, nil)
+
+	doGet(t, router, "/api/v1/stocks/600000")
+	doGet(t, router, "/api/v1/stocks/600000?nocache=1")
+	assert.Equal(t, 2, source.loadCalls())
+
+	// 配置关闭后 nocache 参数不再生效
+	s.allowNoCache = false
+	doGet(t, router, "/api/v1/stocks/600000?nocache=1")
+	assert.Equal(t, 2, source.loadCalls())
+}
+
+func TestGetStock_NotFoundIsNotCached(t *testing.T) {
+	source := newBatchTestSource()
+	_, router := newCacheTestServer(t, source, nil)
+
+	assert.Equal(t, http.StatusNotFound, doGet(t, router, "/api/v1/stocks/999999").Code)
+	assert.Equal(t, http.StatusNotFound, doGet(t, router, "/api/v1/stocks/999999").Code)
+	assert.Equal(t, 2, source.loadCalls())
+}
+
+func TestGetStock_ExpiredEntryIsInvalidated(t *testing.T) {
+	source := newBatchTestSource()
+	s, router := newCacheTestServer(t, source, nil)
+
+	// 模拟分层缓存提升后仍保留在缓存中、但已超过响应 TTL 的条目
+	stale := cachedResponse{Value: &StockResponse{Symbol: "600000", Price: 1}, ExpiresAt: time.Now().Add(-time.Second)}
+	require.NoError(t, s.cache.Set(context.Background(), "stock:600000", stale, time.Hour))
+
+	w := doGet(t, router, "/api/v1/stocks/600000")
+	require.Equal(t, http.StatusOK, w.Code)
+	var stock StockResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stock))
+	assert.Equal(t, 10.5, stock.Price)
+	assert.Equal(t, 1, source.loadCalls())
+
+	s.stockCacheTTL = 20 * time.Millisecond
+	doGet(t, router, "/api/v1/stocks/000001")
+	time.Sleep(40 * time.Millisecond)
+	doGet(t, router, "/api/v1/stocks/000001")
+	assert.Equal(t, 3, source.loadCalls())
+}
+
+func TestGetStockHistory_SecondRequestServedFromCache(t *testing.T) {
+	queryAPI := &fakeQueryAPI{csv: ohlcCSV}
+	_, router := newCacheTestServer(t, newBatchTestSource(), queryAPI)
+
+	path := "/api/v1/stocks/600000/history?interval=5m&start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z"
+	first := doGet(t, router, path)
+	second := doGet(t, router, path)
+
+	require.Equal(t, http.StatusOK, first.Code)
+	require.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+	assert.Len(t, queryAPI.queries, 1)
+
+	// 不同的聚合窗口使用不同的缓存键
+	doGet(t, router, "/api/v1/stocks/600000/history?interval=1h&start=2025-08-20T10:00:00Z&end=2025-08-20T11:00:00Z")
+	assert.Len(t, queryAPI.queries, 2)
+
+	// 相对时间窗口不缓存
+	doGet(t, router, "/api/v1/stocks/600000/history?interval=1h")
+	doGet(t, router, "/api/v1/stocks/600000/history?interval=1h")
+	assert.Len(t, queryAPI.queries, 4)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// maxSearchResults 搜索接口最多返回的结果数
+const maxSearchResults = 20
+
+// 搜索匹配等级，数值越小排名越靠前
+const (
+	matchExactSymbol = iota
+	matchSymbolPrefix
+	matchName
+	matchPinyin
+)
+
+// SymbolSearchResult 代码搜索结果，指数的点位放在 Price 字段
+type SymbolSearchResult struct {
+	Symbol string  `json:"symbol"`
+	Name   string  `json:"name"`
+	Type   string  `json:"type"` // stock 或 index
+	Price  float64 `json:"price"`
+
+	rank int
+}
+
+// searchFields 搜索读取的快照字段，指数的点位字段为 value
+var searchFields = []string{"symbol", "name", "price", "pinyin"}
+
+// searchCandidate 参与搜索的一条最新数据，values 与 searchFields 一一对应
+type searchCandidate struct {
+	kind   string
+	cmd    *redis.SliceCmd // 指数使用，股票为 nil
+	values []interface{}
+}
+
+// searchSymbols 按代码前缀、名称子串和拼音首字母搜索股票和指数
+// 排序：代码完全匹配 > 代码前缀 > 名称 > 拼音首字母，同一等级按代码排序
+func (s *APIServer) searchSymbols(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(400, ErrorResponse{Error: "bad_request", Message: "Query parameter q is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	var candidates []searchCandidate
+	pipe := s.redisClient.Pipeline()
+	for _, kind := range []string{"stock", "index"} {
+		symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey(kind)).Result()
+		if err != nil {
+			s.logger.WithError(err).WithField("type", kind).Error("Failed to get symbols from Redis")
+			c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve symbols"})
+			return
+		}
+		symbols = dedupeLegacyMembers(symbols)
+
+		if kind == "stock" {
+			// 股票快照可能为编码格式，按 storage.codec 读取
+			keys := make([]string, len(symbols))
+			for i, symbol := range symbols {
+				keys[i] = s.latestKey(kind, symbol)
+			}
+			snapshots, err := s.readStockSnapshots(ctx, keys, searchFields...)
+			if err != nil {
+				s.logger.WithError(err).Error("Failed to execute Redis pipeline")
+				c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+				return
+			}
+			for _, raw := range snapshots {
+				candidates = append(candidates, searchCandidate{kind: kind, values: raw.values(searchFields...)})
+			}
+			continue
+		}
+
+		for _, symbol := range symbols {
+			cmd := pipe.HMGet(ctx, s.latestKey(kind, symbol), "symbol", "name", "value", "pinyin")
+			candidates = append(candidates, searchCandidate{kind: kind, cmd: cmd})
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		s.logger.WithError(err).Error("Failed to execute Redis pipeline")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to retrieve data"})
+		return
+	}
+
+	lower := strings.ToLower(q)
+	results := make([]SymbolSearchResult, 0, maxSearchResults)
+	for _, candidate := range candidates {
+		values := candidate.values
+		if candidate.cmd != nil {
+			values = candidate.cmd.Val()
+		}
+		symbol, _ := values[0].(string)
+		if symbol == "" {
+			continue // 哈希已过期
+		}
+		name, _ := values[1].(string)
+		pinyin, _ := values[3].(string)
+
+		rank, ok := matchSymbol(lower, symbol, name, pinyin)
+		if !ok {
+			continue
+		}
+		priceStr, _ := values[2].(string)
+		price, _ := strconv.ParseFloat(priceStr, 64)
+		results = append(results, SymbolSearchResult{Symbol: symbol, Name: name, Type: candidate.kind, Price: price, rank: rank})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].rank != results[j].rank {
+			return results[i].rank < results[j].rank
+		}
+		return results[i].Symbol < results[j].Symbol
+	})
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+
+	c.JSON(200, map[string]interface{}{
+		"query":   q,
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// matchSymbol 返回最佳匹配等级，query 需为小写；pinyin 为 redis_collector 写入的名称拼音首字母
+func matchSymbol(query, symbol, name, pinyin string) (int, bool) {
+	lowerSymbol := strings.ToLower(symbol)
+	switch {
+	case lowerSymbol == query:
+		return matchExactSymbol, true
+	case strings.HasPrefix(lowerSymbol, query):
+		return matchSymbolPrefix, true
+	case strings.Contains(strings.ToLower(name), query):
+		return matchName, true
+	case pinyin != "" && strings.HasPrefix(pinyin, query):
+		return matchPinyin, true
+	default:
+		return 0, false
+	}
+}
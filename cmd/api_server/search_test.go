@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchResponse struct {
+	Query   string               `json:"query"`
+	Results []SymbolSearchResult `json:"results"`
+	Count   int                  `json:"count"`
+}
+
+func newSearchTestRouter(t *testing.T) (*gin.Engine, *miniredis.Miniredis) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+	router := gin.New()
+	router.GET("/api/v1/symbols/search", s.searchSymbols)
+	return router, mr
+}
+
+func addSearchStock(mr *miniredis.Miniredis, symbol, name, pinyin, price string) {
+	mr.SAdd("latest:symbols:stock", symbol)
+	mr.HSet("latest:stock:"+symbol, "symbol", symbol, "name", name, "pinyin", pinyin, "price", price)
+}
+
+func search(t *testing.T, router *gin.Engine, q string) searchResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/symbols/search?q="+url.QueryEscape(q), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp searchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func searchSymbolsOf(results []SymbolSearchResult) []string {
+	symbols := make([]string, len(results))
+	for i, r := range results {
+		symbols[i] = r.Symbol
+	}
+	return symbols
+}
+
+func TestSearchSymbols_Ranking(t *testing.T) {
+	router, mr := newSearchTestRouter(t)
+	addSearchStock(mr, "600000", "浦发银行", "pfyh", "10.5")
+	addSearchStock(mr, "600036", "招商银行", "zsyh", "35.2")
+	addSearchStock(mr, "000600", "建投能源", "jtny", "6.1")
+	addSearchStock(mr, "600", "测试600", "cs600", "1")
+	mr.SAdd("latest:symbols:index", "sh000001")
+	mr.HSet("latest:index:sh000001", "symbol", "sh000001", "name", "上证指数", "pinyin", "szzs", "value", "3200.5")
+
+	// 代码完全匹配 > 代码前缀 > 名称
+	resp := search(t, router, "600")
+	assert.Equal(t, []string{"600", "600000", "600036"}, searchSymbolsOf(resp.Results))
+
+	resp = search(t, router, "浦发")
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, SymbolSearchResult{Symbol: "600000", Name: "浦发银行", Type: "stock", Price: 10.5}, resp.Results[0])
+
+	resp = search(t, router, "PFYH")
+	assert.Equal(t, []string{"600000"}, searchSymbolsOf(resp.Results))
+
+	// 名称匹配排在拼音匹配之前
+	resp = search(t, router, "银行")
+	assert.Equal(t, []string{"600000", "600036"}, searchSymbolsOf(resp.Results))
+
+	resp = search(t, router, "sz")
+	require.Len(t, resp.Results, 1)
+	assert.Equal(t, SymbolSearchResult{Symbol: "sh000001", Name: "上证指数", Type: "index", Price: 3200.5}, resp.Results[0])
+
+	assert.Empty(t, search(t, router, "nomatch").Results)
+}
+
+func TestSearchSymbols_CapsResults(t *testing.T) {
+	router, mr := newSearchTestRouter(t)
+	for i := 0; i < 30; i++ {
+		addSearchStock(mr, fmt.Sprintf("6000%02d", i), "银行", "yh", "1")
+	}
+
+	resp := search(t, router, "6000")
+	assert.Equal(t, maxSearchResults, resp.Count)
+	assert.Equal(t, "600000", resp.Results[0].Symbol)
+}
+
+func TestSearchSymbols_RequiresQuery(t *testing.T) {
+	router, _ := newSearchTestRouter(t)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/symbols/search?q=%20", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMatchSymbol(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantRank int
+		wantOK   bool
+	}{
+		{"600000", matchExactSymbol, true},
+		{"6000", matchSymbolPrefix, true},
+		{"发银", matchName, true},
+		{"pf", matchPinyin, true},
+		{"yh", 0, false}, // 拼音只做前缀匹配
+	}
+	for _, tt := range tests {
+		rank, ok := matchSymbol(tt.query, "600000", "浦发银行", "pfyh")
+		assert.Equal(t, tt.wantOK, ok, tt.query)
+		assert.Equal(t, tt.wantRank, rank, tt.query)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/message"
+	"stocksub/pkg/snapshot"
+)
+
+// snapshotCodecRefresh 重新读取 <prefix>meta:codec 的间隔
+const snapshotCodecRefresh = 10 * time.Second
+
+// snapshotCodec 缓存 redis_collector 写入股票快照使用的编码，零值表示尚未读取
+type snapshotCodec struct {
+	mu        sync.Mutex
+	blob      bool
+	checkedAt time.Time
+}
+
+// preferBlob 返回股票快照是否应先按编码格式（GET）读取，元数据键不存在或读取失败时沿用上一次的结果
+func (s *APIServer) preferBlob(ctx context.Context) bool {
+	s.snapshotCodec.mu.Lock()
+	defer s.snapshotCodec.mu.Unlock()
+
+	if time.Since(s.snapshotCodec.checkedAt) < snapshotCodecRefresh {
+		return s.snapshotCodec.blob
+	}
+	name, err := s.redisClient.Get(ctx, s.keyPrefix()+snapshot.CodecMetaKey).Result()
+	if err != nil && err != redis.Nil {
+		s.logger.WithError(err).Warn("Failed to read snapshot codec, keeping previous")
+		return s.snapshotCodec.blob
+	}
+	s.snapshotCodec.blob = name != "" && name != snapshot.CodecHash
+	s.snapshotCodec.checkedAt = time.Now()
+	return s.snapshotCodec.blob
+}
+
+// rawStockSnapshot 一个股票快照键的内容：哈希格式为 hash，编码格式为解码后的 stock，键不存在时均为空
+type rawStockSnapshot struct {
+	hash  map[string]string
+	stock *snapshot.Stock
+	err   error // 编码格式解码失败
+}
+
+func (r rawStockSnapshot) exists() bool {
+	return len(r.hash) > 0 || r.stock != nil || r.err != nil
+}
+
+// values 按 HMGET 的形式返回指定字段，缺失的字段为 nil
+func (r rawStockSnapshot) values(fields ...string) []interface{} {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if r.stock != nil {
+			if value, ok := r.stock.Field(field); ok {
+				values[i] = value
+			}
+		} else if value, ok := r.hash[field]; ok {
+			values[i] = value
+		}
+	}
+	return values
+}
+
+// readStockSnapshots 批量读取股票快照键，结果与 keys 一一对应。先按 meta:codec 记录的格式读取，
+// 过渡期内格式不符（WRONGTYPE）的键再按另一种格式读取一次；fields 非空时哈希格式只读取这些字段
+func (s *APIServer) readStockSnapshots(ctx context.Context, keys []string, fields ...string) ([]rawStockSnapshot, error) {
+	results := make([]rawStockSnapshot, len(keys))
+	pending := make([]int, len(keys))
+	for i := range keys {
+		pending[i] = i
+	}
+
+	blob := s.preferBlob(ctx)
+	for attempt := 0; attempt < 2 && len(pending) > 0; attempt++ {
+		pipe := s.redisClient.Pipeline()
+		cmds := make([]redis.Cmder, len(pending))
+		for j, i := range pending {
+			switch {
+			case blob:
+				cmds[j] = pipe.Get(ctx, keys[i])
+			case len(fields) > 0:
+				cmds[j] = pipe.HMGet(ctx, keys[i], fields...)
+			default:
+				cmds[j] = pipe.HGetAll(ctx, keys[i])
+			}
+		}
+		// 单条命令的错误逐条检查，Exec 只返回第一条命令的错误
+		_, _ = pipe.Exec(ctx)
+
+		var mismatched []int
+		for j, cmd := range cmds {
+			i := pending[j]
+			switch err := cmd.Err(); {
+			case err == redis.Nil:
+			case snapshot.IsWrongType(err):
+				mismatched = append(mismatched, i)
+			case err != nil:
+				return nil, fmt.Errorf("failed to read stock snapshots: %w", err)
+			default:
+				results[i] = rawFromCmd(cmd, fields)
+			}
+		}
+		pending, blob = mismatched, !blob
+	}
+	return results, nil
+}
+
+func rawFromCmd(cmd redis.Cmder, fields []string) rawStockSnapshot {
+	switch cmd := cmd.(type) {
+	case *redis.StringCmd:
+		stock, err := snapshot.Decode([]byte(cmd.Val()))
+		return rawStockSnapshot{stock: stock, err: err}
+	case *redis.StringStringMapCmd:
+		return rawStockSnapshot{hash: cmd.Val()}
+	case *redis.SliceCmd:
+		hash := make(map[string]string, len(fields))
+		for i, value := range cmd.Val() {
+			if str, ok := value.(string); ok && i < len(fields) {
+				hash[fields[i]] = str
+			}
+		}
+		return rawStockSnapshot{hash: hash}
+	}
+	return rawStockSnapshot{}
+}
+
+// parseStockSnapshot 解析哈希格式或编码格式的股票快照
+func (s *APIServer) parseStockSnapshot(raw rawStockSnapshot) (*StockResponse, error) {
+	if raw.err != nil {
+		return nil, raw.err
+	}
+	if raw.stock == nil {
+		return s.parseStockFromRedis(raw.hash)
+	}
+
+	snap := raw.stock
+	stock := &StockResponse{
+		Symbol:        snap.Symbol,
+		Name:          snap.Name,
+		Price:         snap.Price,
+		Change:        snap.Change,
+		ChangePercent: snap.ChangePercent,
+		Volume:        snap.Volume,
+		Turnover:      snap.Turnover,
+		Timestamp:     time.Unix(snap.Timestamp, 0),
+		Provider:      snap.Provider,
+		Market:        snap.Market,
+		UpdatedAt:     time.Unix(snap.UpdatedAt, 0),
+		Depth:         snapshotOrderBook(snap),
+		TraceID:       snap.TraceID,
+	}
+	s.staleness.annotateStock(stock)
+	return stock, nil
+}
+
+// snapshotOrderBook 由编码格式的 5 档买卖盘生成盘口，档数不完整时返回 nil
+func snapshotOrderBook(snap *snapshot.Stock) *OrderBook {
+	levels := message.DepthLevels
+	if len(snap.BidPrices) != levels || len(snap.BidVolumes) != levels ||
+		len(snap.AskPrices) != levels || len(snap.AskVolumes) != levels {
+		return nil
+	}
+	book := &OrderBook{
+		Bids: make([]OrderLevel, levels),
+		Asks: make([]OrderLevel, levels),
+	}
+	for i := 0; i < levels; i++ {
+		book.Bids[i] = OrderLevel{Price: snap.BidPrices[i], Volume: snap.BidVolumes[i]}
+		book.Asks[i] = OrderLevel{Price: snap.AskPrices[i], Volume: snap.AskVolumes[i]}
+	}
+	return book
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/snapshot"
+)
+
+// setEncodedSnapshot 按 codec 写入 redis_collector 编码格式的股票快照
+func setEncodedSnapshot(t testing.TB, mr *miniredis.Miniredis, codecName string, stock snapshot.Stock) {
+	t.Helper()
+	c, err := snapshot.Lookup(codecName)
+	require.NoError(t, err)
+	data, err := c.Encode(&stock)
+	require.NoError(t, err)
+	require.NoError(t, mr.Set("latest:stock:"+stock.Symbol, string(data)))
+	mr.SAdd("latest:symbols:stock", stock.Symbol)
+}
+
+func TestReadStockSnapshots_MixedFormats(t *testing.T) {
+	for _, meta := range []string{"", snapshot.CodecHash, snapshot.CodecMsgpack} {
+		t.Run("meta="+meta, func(t *testing.T) {
+			s, mr := newSymbolsTestServer(t)
+			if meta != "" {
+				require.NoError(t, mr.Set("latest:meta:codec", meta))
+			}
+			setSnapshot(mr, "latest:stock:600000.SH", "600000.SH", "price", "10.5")
+			setEncodedSnapshot(t, mr, snapshot.CodecMsgpack, snapshot.Stock{Symbol: "000001.SZ", Name: "平安银行", Pinyin: "payh", Price: 12.3, Volume: 300, TraceID: "trace-1", UpdatedAt: 1755684000})
+			setEncodedSnapshot(t, mr, snapshot.CodecJSON, snapshot.Stock{Symbol: "300750.SZ", Price: 250.1, UpdatedAt: 1755684000})
+
+			keys := []string{"latest:stock:600000.SH", "latest:stock:000001.SZ", "latest:stock:688981.SH", "latest:stock:300750.SZ"}
+			snapshots, err := s.readStockSnapshots(context.Background(), keys)
+			require.NoError(t, err)
+			require.Len(t, snapshots, 4)
+			assert.False(t, snapshots[2].exists(), "键不存在")
+
+			prices := make([]float64, 0, 3)
+			for _, raw := range []rawStockSnapshot{snapshots[0], snapshots[1], snapshots[3]} {
+				stock, err := s.parseStockSnapshot(raw)
+				require.NoError(t, err)
+				prices = append(prices, stock.Price)
+			}
+			assert.Equal(t, []float64{10.5, 12.3, 250.1}, prices)
+
+			// 只读取部分字段时两种格式返回相同形式的值
+			partial, err := s.readStockSnapshots(context.Background(), keys[:2], "symbol", "price", "pinyin")
+			require.NoError(t, err)
+			assert.Equal(t, []interface{}{"600000.SH", "10.5", nil}, partial[0].values("symbol", "price", "pinyin"))
+			assert.Equal(t, []interface{}{"000001.SZ", "12.3", "payh"}, partial[1].values("symbol", "price", "pinyin"))
+		})
+	}
+}
+
+func TestReadStockSnapshots_InvalidBlob(t *testing.T) {
+	s, mr := newSymbolsTestServer(t)
+	require.NoError(t, mr.Set("latest:stock:600000.SH", "{broken"))
+
+	snapshots, err := s.readStockSnapshots(context.Background(), []string{"latest:stock:600000.SH"})
+	require.NoError(t, err)
+	require.True(t, snapshots[0].exists())
+	_, err = s.parseStockSnapshot(snapshots[0])
+	assert.Error(t, err)
+}
+
+func TestParseStockSnapshot_EncodedMatchesHash(t *testing.T) {
+	s, mr := newSymbolsTestServer(t)
+	mr.HSet("latest:stock:600000.SH", "symbol", "600000.SH", "name", "浦发银行", "price", "10.5", "change", "0.15",
+		"change_percent", "1.45", "volume", "1250000", "turnover", "13125000", "timestamp", "1755684000",
+		"provider", "tencent", "market", "A-share", "trace_id", "trace-1", "updated_at", "1755684003")
+	for i := 1; i <= 5; i++ {
+		mr.HSet("latest:stock:600000.SH",
+			fmt.Sprintf("bid_price%d", i), fmt.Sprint(10.5-0.01*float64(i)), fmt.Sprintf("bid_volume%d", i), fmt.Sprint(100*i),
+			fmt.Sprintf("ask_price%d", i), fmt.Sprint(10.5+0.01*float64(i-1)), fmt.Sprintf("ask_volume%d", i), fmt.Sprint(200*i))
+	}
+	snap := snapshot.Stock{
+		Symbol: "000001.SZ", Name: "浦发银行", Price: 10.5, Change: 0.15, ChangePercent: 1.45, Volume: 1250000, Turnover: 13125000,
+		Timestamp: 1755684000, Provider: "tencent", Market: "A-share", TraceID: "trace-1", UpdatedAt: 1755684003,
+	}
+	for i := 1; i <= 5; i++ {
+		snap.BidPrices = append(snap.BidPrices, 10.5-0.01*float64(i))
+		snap.BidVolumes = append(snap.BidVolumes, int64(100*i))
+		snap.AskPrices = append(snap.AskPrices, 10.5+0.01*float64(i-1))
+		snap.AskVolumes = append(snap.AskVolumes, int64(200*i))
+	}
+	setEncodedSnapshot(t, mr, snapshot.CodecMsgpack, snap)
+
+	snapshots, err := s.readStockSnapshots(context.Background(), []string{"latest:stock:600000.SH", "latest:stock:000001.SZ"})
+	require.NoError(t, err)
+	fromHash, err := s.parseStockSnapshot(snapshots[0])
+	require.NoError(t, err)
+	fromBlob, err := s.parseStockSnapshot(snapshots[1])
+	require.NoError(t, err)
+
+	require.NotNil(t, fromBlob.Depth)
+	fromBlob.Symbol = fromHash.Symbol
+	assert.Equal(t, fromHash, fromBlob)
+}
+
+func TestPreferBlob_CachesMetaKey(t *testing.T) {
+	s, mr := newSymbolsTestServer(t)
+	ctx := context.Background()
+	assert.False(t, s.preferBlob(ctx), "元数据键不存在时按哈希格式读取")
+
+	require.NoError(t, mr.Set("latest:meta:codec", snapshot.CodecMsgpack))
+	assert.False(t, s.preferBlob(ctx), "刷新间隔内使用缓存的结果")
+
+	s.snapshotCodec.checkedAt = time.Now().Add(-snapshotCodecRefresh)
+	assert.True(t, s.preferBlob(ctx))
+}
+
+func TestGetStocks_MixedFormatTransition(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, mr := newSymbolsTestServer(t)
+	require.NoError(t, mr.Set("latest:meta:codec", snapshot.CodecMsgpack))
+	setSnapshot(mr, "latest:stock:600000.SH", "600000.SH", "price", "10.5")
+	mr.SAdd("latest:symbols:stock", "600000.SH")
+	setEncodedSnapshot(t, mr, snapshot.CodecMsgpack, snapshot.Stock{Symbol: "000001.SZ", Name: "平安银行", Pinyin: "payh", Price: 12.3, UpdatedAt: 1755684000})
+
+	router := gin.New()
+	router.GET("/api/v1/stocks", s.getStocks)
+	router.GET("/api/v1/search", s.searchSymbols)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var stocks []StockResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stocks))
+	require.Len(t, stocks, 2)
+	assert.Equal(t, "000001.SZ", stocks[0].Symbol)
+	assert.Equal(t, 12.3, stocks[0].Price)
+	assert.Equal(t, "600000.SH", stocks[1].Symbol)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/search?q=payh", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var search struct {
+		Results []SymbolSearchResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &search))
+	require.Len(t, search.Results, 1)
+	assert.Equal(t, "000001.SZ", search.Results[0].Symbol)
+	assert.Equal(t, 12.3, search.Results[0].Price)
+}
+
+// benchmarkGetStocks 读取全市场 5000 只股票的 /stocks 列表
+func benchmarkGetStocks(b *testing.B, codecName string) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(b)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	b.Cleanup(func() { client.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{redisClient: client, logger: logger}
+
+	require.NoError(b, mr.Set("latest:meta:codec", codecName))
+	for i := 0; i < 5000; i++ {
+		stock := snapshot.Stock{
+			Symbol: fmt.Sprintf("%06d.SZ", i), Name: "测试股票", Price: 10.5 + float64(i)/100, Change: 0.15, ChangePercent: 1.45,
+			Volume: int64(1000 * i), Turnover: 13125000.5, Timestamp: 1755684000, Provider: "tencent", Market: "A-share", UpdatedAt: 1755684000,
+		}
+		if codecName == snapshot.CodecHash {
+			mr.HSet("latest:stock:"+stock.Symbol, "symbol", stock.Symbol, "name", stock.Name, "price", fmt.Sprint(stock.Price),
+				"change", "0.15", "change_percent", "1.45", "volume", fmt.Sprint(stock.Volume), "turnover", "13125000.5",
+				"timestamp", "1755684000", "provider", "tencent", "market", "A-share", "updated_at", "1755684000")
+			mr.SAdd("latest:symbols:stock", stock.Symbol)
+			continue
+		}
+		setEncodedSnapshot(b, mr, codecName, stock)
+	}
+
+	router := gin.New()
+	router.GET("/api/v1/stocks", s.getStocks)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks", nil))
+		if w.Code != http.StatusOK {
+			b.Fatal(w.Body.String())
+		}
+	}
+}
+
+func BenchmarkGetStocks_Hash(b *testing.B)    { benchmarkGetStocks(b, snapshot.CodecHash) }
+func BenchmarkGetStocks_Msgpack(b *testing.B) { benchmarkGetStocks(b, snapshot.CodecMsgpack) }
+func BenchmarkGetStocks_JSON(b *testing.B)    { benchmarkGetStocks(b, snapshot.CodecJSON) }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"stocksub/pkg/timing"
+)
+
+// StalenessConfig 最新数据的过期判断和 /health 降级配置
+type StalenessConfig struct {
+	Threshold       time.Duration `mapstructure:"threshold"`        // 最近一个交易时刻之前超过该时间未更新视为过期，0 表示不判断
+	CheckInterval   time.Duration `mapstructure:"check_interval"`   // 后台统计过期股票比例的间隔，0 表示不统计
+	DegradedPercent float64       `mapstructure:"degraded_percent"` // 交易时段内过期股票比例超过该百分比时 /health 返回 degraded，0 表示不检查
+}
+
+// stalenessStats 一次统计的结果，哈希已过期的代码计为过期
+type stalenessStats struct {
+	total     int
+	stale     int
+	checkedAt time.Time
+}
+
+// percent 过期股票所占的百分比
+func (st stalenessStats) percent() float64 {
+	if st.total == 0 {
+		return 0
+	}
+	return float64(st.stale) * 100 / float64(st.total)
+}
+
+// stalenessChecker 按交易时段判断最新数据是否过期：交易时段内按距当前的时间判断，
+// 休市时按距最近一次收盘的时间判断，凌晨读到前一交易日收盘时的数据不算过期
+type stalenessChecker struct {
+	config StalenessConfig
+	market *timing.MarketTime
+
+	mu   sync.RWMutex
+	last stalenessStats
+}
+
+func newStalenessChecker(config StalenessConfig, market *timing.MarketTime) *stalenessChecker {
+	return &stalenessChecker{config: config, market: market}
+}
+
+// now 返回当前时间，未配置时使用系统时间
+func (c *stalenessChecker) now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.market.Now()
+}
+
+// check 返回 updatedAt 距当前的秒数和是否过期
+func (c *stalenessChecker) check(updatedAt time.Time) (int64, bool) {
+	now := c.now()
+	age := max(int64(now.Sub(updatedAt)/time.Second), 0)
+	if c == nil || c.config.Threshold <= 0 {
+		return age, false
+	}
+	return age, c.market.LastTradingTime(now).Sub(updatedAt) > c.config.Threshold
+}
+
+func (c *stalenessChecker) annotateStock(stock *StockResponse) {
+	stock.AgeSeconds, stock.IsStale = c.check(stock.UpdatedAt)
+}
+
+func (c *stalenessChecker) annotateIndex(index *IndexResponse) {
+	index.AgeSeconds, index.IsStale = c.check(index.UpdatedAt)
+}
+
+func (c *stalenessChecker) record(stats stalenessStats) {
+	c.mu.Lock()
+	c.last = stats
+	c.mu.Unlock()
+}
+
+// health 返回 /health 中的 staleness 状态，交易时段内过期比例超过 DegradedPercent 时 degraded 为 true
+func (c *stalenessChecker) health() (status string, degraded bool) {
+	c.mu.RLock()
+	last := c.last
+	c.mu.RUnlock()
+
+	if last.checkedAt.IsZero() {
+		return "pending", false
+	}
+	status = fmt.Sprintf("%d/%d stale (%.1f%%)", last.stale, last.total, last.percent())
+	if c.config.DegradedPercent <= 0 || !c.market.IsTradingTimeAt(c.now()) {
+		return status, false
+	}
+	if last.percent() > c.config.DegradedPercent {
+		return "degraded: " + status, true
+	}
+	return status, false
+}
+
+// runStalenessMonitor 每隔 interval 统计一次全部股票的过期比例，直到 ctx 取消
+func (s *APIServer) runStalenessMonitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.checkStaleness(ctx); err != nil && ctx.Err() == nil {
+			s.logger.WithError(err).Warn("Failed to check data staleness")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkStaleness 读取 symbols:stock 中全部股票的最新数据并记录过期数量
+func (s *APIServer) checkStaleness(ctx context.Context) error {
+	symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey("stock")).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get stock symbols: %w", err)
+	}
+	symbols = dedupeLegacyMembers(symbols)
+
+	stocks, _, err := s.loadSnapshots(ctx, symbols, nil)
+	if err != nil {
+		return err
+	}
+
+	stats := stalenessStats{total: len(symbols), stale: len(symbols) - len(stocks), checkedAt: s.staleness.now()}
+	for _, stock := range stocks {
+		if stock.IsStale {
+			stats.stale++
+		}
+	}
+	s.staleness.record(stats)
+	return nil
+}
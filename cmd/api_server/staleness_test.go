@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/timing"
+)
+
+// fakeClock 可调整的时间，用于 timing.MarketTime
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestStalenessChecker(at time.Time, config StalenessConfig) (*stalenessChecker, *fakeClock) {
+	clock := &fakeClock{now: at}
+	return newStalenessChecker(config, timing.NewMarketTime(clock)), clock
+}
+
+func TestStalenessChecker_MarketHoursAware(t *testing.T) {
+	// 2025-08-21 为周四，2025-08-23 为周六
+	thursday := func(clock string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04:05", "2025-08-21 "+clock)
+		return parsed
+	}
+	friday := func(clock string) time.Time { return thursday(clock).AddDate(0, 0, 1) }
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		updatedAt time.Time
+		wantAge   int64
+		wantStale bool
+	}{
+		{"交易中 30 秒前更新", thursday("10:00:00"), thursday("09:59:30"), 30, false},
+		{"交易中 5 分钟前更新", thursday("10:00:00"), thursday("09:55:00"), 300, true},
+		{"午间休市读到上午收盘数据", thursday("12:30:00"), thursday("11:30:05"), 3595, false},
+		{"午间休市读到上午 11 点的数据", thursday("12:30:00"), thursday("11:00:00"), 5400, true},
+		{"凌晨读到 2 小时前的数据", friday("03:00:00"), friday("01:00:00"), 7200, false},
+		{"凌晨读到前一日收盘数据", friday("03:00:00"), thursday("15:00:05"), 43195, false},
+		{"凌晨读到前一日盘中停止更新的数据", friday("03:00:00"), thursday("10:00:00"), 61200, true},
+		{"周末读到周五收盘数据", friday("15:00:00").AddDate(0, 0, 1), friday("14:59:30"), 86430, false},
+		{"更新时间晚于当前时间", thursday("10:00:00"), thursday("10:00:05"), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, _ := newTestStalenessChecker(tt.now, StalenessConfig{Threshold: 2 * time.Minute})
+			stock := StockResponse{UpdatedAt: tt.updatedAt}
+			checker.annotateStock(&stock)
+			assert.Equal(t, tt.wantAge, stock.AgeSeconds)
+			assert.Equal(t, tt.wantStale, stock.IsStale)
+		})
+	}
+}
+
+func TestStalenessChecker_ZeroThresholdDisablesFlag(t *testing.T) {
+	now := time.Date(2025, 8, 21, 10, 0, 0, 0, time.UTC)
+	checker, _ := newTestStalenessChecker(now, StalenessConfig{})
+	index := IndexResponse{UpdatedAt: now.Add(-time.Hour)}
+	checker.annotateIndex(&index)
+	assert.Equal(t, int64(3600), index.AgeSeconds)
+	assert.False(t, index.IsStale)
+}
+
+func TestStalenessChecker_HealthOnlyDegradesDuringTrading(t *testing.T) {
+	trading := time.Date(2025, 8, 21, 10, 0, 0, 0, time.UTC)
+	checker, clock := newTestStalenessChecker(trading, StalenessConfig{Threshold: 2 * time.Minute, DegradedPercent: 20})
+
+	status, degraded := checker.health()
+	assert.Equal(t, "pending", status)
+	assert.False(t, degraded)
+
+	checker.record(stalenessStats{total: 10, stale: 2, checkedAt: trading})
+	status, degraded = checker.health()
+	assert.Equal(t, "2/10 stale (20.0%)", status)
+	assert.False(t, degraded, "等于阈值不降级")
+
+	checker.record(stalenessStats{total: 10, stale: 3, checkedAt: trading})
+	status, degraded = checker.health()
+	assert.Equal(t, "degraded: 3/10 stale (30.0%)", status)
+	assert.True(t, degraded)
+
+	clock.now = time.Date(2025, 8, 21, 20, 0, 0, 0, time.UTC)
+	_, degraded = checker.health()
+	assert.False(t, degraded, "休市时不降级")
+}
+
+// addStaleTestStock 写入与 redis_collector 相同字段的最新数据哈希
+func addStaleTestStock(mr *miniredis.Miniredis, symbol string, updatedAt time.Time) {
+	mr.HSet("latest:stock:"+symbol,
+		"symbol", symbol, "name", symbol, "price", "10", "change", "0", "change_percent", "0",
+		"volume", "100", "timestamp", fmt.Sprint(updatedAt.Unix()), "updated_at", fmt.Sprint(updatedAt.Unix()))
+	mr.SAdd("latest:symbols:stock", symbol)
+}
+
+func TestCheckStaleness_CountsStaleAndExpiredSymbols(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	now := time.Date(2025, 8, 21, 10, 0, 0, 0, time.UTC)
+	checker, _ := newTestStalenessChecker(now, StalenessConfig{Threshold: 2 * time.Minute, DegradedPercent: 50})
+	s := &APIServer{redisClient: client, logger: logger, staleness: checker}
+	s.loadSnapshots = s.loadLatestSnapshots
+
+	addStaleTestStock(mr, "600000", now.Add(-10*time.Second))
+	addStaleTestStock(mr, "000001", now.Add(-10*time.Minute))
+	mr.SAdd("latest:symbols:stock", "300750") // 哈希已过期
+
+	stocks, _, err := s.loadSnapshots(context.Background(), []string{"600000", "000001"}, nil)
+	require.NoError(t, err)
+	assert.False(t, stocks["600000"].IsStale)
+	assert.Equal(t, int64(10), stocks["600000"].AgeSeconds)
+	assert.True(t, stocks["000001"].IsStale)
+
+	require.NoError(t, s.checkStaleness(context.Background()))
+	status, degraded := checker.health()
+	assert.Equal(t, "degraded: 2/3 stale (66.7%)", status)
+	assert.True(t, degraded)
+}
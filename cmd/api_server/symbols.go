@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+// 兼容层：redis_collector 和 influxdb_collector 按规范形式（如 600000.SH）写入，
+// 过渡期内旧版本写入的不带市场的键（如 600000、sh000001）仍可能存在，查询时一并检查。
+
+// symbolCandidates 返回查询代码时依次尝试的形式：规范形式优先，其次是旧格式
+func symbolCandidates(symbol string) []string {
+	parsed, err := core.ParseSymbol(symbol)
+	if err != nil {
+		return []string{strings.TrimSpace(symbol)}
+	}
+	candidates := []string{parsed.Canonical()}
+	if legacy := parsed.Legacy(); legacy != candidates[0] {
+		candidates = append(candidates, legacy)
+	}
+	return candidates
+}
+
+// symbolFilter 返回匹配代码所有形式的 Flux 过滤条件，如 r.symbol == "600000.SH" or r.symbol == "600000"
+func symbolFilter(symbol string) string {
+	candidates := symbolCandidates(symbol)
+	conditions := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		conditions[i] = "r.symbol == " + fluxString(candidate)
+	}
+	return strings.Join(conditions, " or ")
+}
+
+// namespaceFilter 在 filter 外追加命名空间标签条件，默认命名空间只匹配不带 namespace 标签的点
+func namespaceFilter(namespace, filter string) string {
+	return "(" + filter + ") and " + message.NamespaceFluxFilter(namespace)
+}
+
+// fluxStringEscaper 转义 Flux 字符串字面量中的反斜杠、双引号、插值 ${ 和控制字符
+var fluxStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// fluxString 返回 Flux 字符串字面量，代码已经过 ValidateSymbolParam 校验，这里再转义一次防止拼接出新的表达式
+func fluxString(value string) string {
+	return `"` + fluxStringEscaper.Replace(value) + `"`
+}
+
+// maxSymbolParamLength 请求中单个代码的最大长度，最长的合法写法如 rt_hk00700、BRK.B.US
+const maxSymbolParamLength = 16
+
+// rawSymbolPattern 代码参数允许的字符，$ 用于新浪的美股写法 gb_brk$b
+var rawSymbolPattern = regexp.MustCompile(`^[A-Za-z0-9._$]+$`)
+
+// symbolPatterns 各市场规范形式中代码部分的白名单
+var symbolPatterns = map[core.Market]*regexp.Regexp{
+	core.MarketSH: regexp.MustCompile(`^[0-9]{6}$`),
+	core.MarketSZ: regexp.MustCompile(`^[0-9]{6}$`),
+	core.MarketBJ: regexp.MustCompile(`^[0-9]{6}$`),
+	core.MarketHK: regexp.MustCompile(`^([0-9]{5}|[A-Z]{1,10})$`),
+	core.MarketUS: regexp.MustCompile(`^[A-Z]{1,5}(\.[A-Z]{1,2})?$`),
+}
+
+// ValidateSymbolParam 校验请求中的代码：只能包含字母、数字和 . _ $，能被 core.ParseSymbol 识别，
+// 且代码部分符合所属市场的格式。通过校验的代码可以安全地拼入 Redis 键和 Flux 查询
+func ValidateSymbolParam(raw string) (core.Symbol, error) {
+	switch {
+	case raw == "":
+		return core.Symbol{}, fmt.Errorf("symbol is required")
+	case len(raw) > maxSymbolParamLength:
+		return core.Symbol{}, fmt.Errorf("invalid symbol: longer than %d characters", maxSymbolParamLength)
+	case !rawSymbolPattern.MatchString(raw):
+		return core.Symbol{}, fmt.Errorf("invalid symbol %q: only letters, digits, '.', '_' and '$' are allowed", raw)
+	}
+
+	symbol, err := core.ParseSymbol(raw)
+	if err != nil {
+		return core.Symbol{}, fmt.Errorf("invalid symbol %q: unrecognized format, use e.g. 600000, 600000.SH or sh000001", raw)
+	}
+	if pattern, ok := symbolPatterns[symbol.Market]; !ok || !pattern.MatchString(symbol.Code) {
+		return core.Symbol{}, fmt.Errorf("invalid symbol %q: malformed %s code", raw, symbol.Market)
+	}
+	return symbol, nil
+}
+
+// validateSymbolParams 校验 :symbol 路径参数，无法识别的代码直接返回 400，不进入处理器；
+// symbols 查询参数由 parseSymbolsParam 在拆分后逐个校验
+func validateSymbolParams() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if raw, ok := c.Params.Get("symbol"); ok {
+			if _, err := ValidateSymbolParam(raw); err != nil {
+				c.AbortWithStatusJSON(400, ErrorResponse{Error: "bad_request", Message: err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// dedupeLegacyMembers 去除规范形式也存在的旧格式成员，保持原有顺序
+func dedupeLegacyMembers(members []string) []string {
+	present := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		present[member] = struct{}{}
+	}
+	result := make([]string, 0, len(members))
+	for _, member := range members {
+		if canonical := core.NormalizeSymbol(member); canonical != member {
+			if _, ok := present[canonical]; ok {
+				continue
+			}
+		}
+		result = append(result, member)
+	}
+	return result
+}
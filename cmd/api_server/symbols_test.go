@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/backfill"
+	"stocksub/pkg/message"
+)
+
+func TestSymbolCandidates(t *testing.T) {
+	assert.Equal(t, []string{"600000.SH", "600000"}, symbolCandidates("600000"))
+	assert.Equal(t, []string{"600000.SH", "600000"}, symbolCandidates("sh600000"))
+	assert.Equal(t, []string{"000001.SH", "sh000001"}, symbolCandidates("000001.SH"))
+	assert.Equal(t, []string{"unknown"}, symbolCandidates(" unknown "))
+
+	assert.Equal(t, `r.symbol == "399001.SZ" or r.symbol == "sz399001"`, symbolFilter("sz399001"))
+	assert.Equal(t, `r.symbol == "x\"y"`, symbolFilter(`x"y`), "代码中的引号被转义")
+}
+
+func TestDedupeLegacyMembers(t *testing.T) {
+	assert.Equal(t,
+		[]string{"600000.SH", "000001", "sh000300"},
+		dedupeLegacyMembers([]string{"600000", "600000.SH", "000001", "sh000300"}),
+		"只去除规范形式同时存在的旧格式成员")
+}
+
+func newSymbolsTestServer(t *testing.T) (*APIServer, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &APIServer{redisClient: client, logger: logger}, mr
+}
+
+// setSnapshot 写入 parseStockFromRedis 和 parseIndexFromRedis 需要的全部字段
+func setSnapshot(mr *miniredis.Miniredis, key, symbol, valueField, value string) {
+	mr.HSet(key, "symbol", symbol, valueField, value, "change", "0", "change_percent", "0",
+		"volume", "100", "timestamp", "1755684000", "updated_at", "1755684000")
+}
+
+func TestLoadLatestSnapshots_FallsBackToLegacyKeys(t *testing.T) {
+	s, mr := newSymbolsTestServer(t)
+	setSnapshot(mr, "latest:stock:600000.SH", "600000.SH", "price", "10.5")
+	setSnapshot(mr, "latest:stock:600000", "600000", "price", "9.9")
+	setSnapshot(mr, "latest:stock:000001", "000001", "price", "12.3")
+	setSnapshot(mr, "latest:index:sh000001", "sh000001", "value", "3200.5")
+
+	stocks, indices, err := s.loadLatestSnapshots(context.Background(), []string{"600000", "000001.SZ"}, []string{"000001.SH"})
+	require.NoError(t, err)
+	require.Contains(t, stocks, "600000")
+	assert.Equal(t, 10.5, stocks["600000"].Price, "规范形式优先")
+	require.Contains(t, stocks, "000001.SZ")
+	assert.Equal(t, "000001", stocks["000001.SZ"].Symbol, "规范形式不存在时读取旧格式")
+	require.Contains(t, indices, "000001.SH")
+	assert.Equal(t, 3200.5, indices["000001.SH"].Value)
+}
+
+func TestGetIndex_FallsBackToLegacyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, mr := newSymbolsTestServer(t)
+	setSnapshot(mr, "latest:index:sh000001", "sh000001", "value", "3200.5")
+	router := gin.New()
+	router.GET("/api/v1/indices/:symbol", s.getIndex)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/indices/000001.SH", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var index IndexResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &index))
+	assert.Equal(t, 3200.5, index.Value)
+}
+
+// maliciousSymbols 试图闭合 Flux 字符串或插值的代码参数
+var maliciousSymbols = []string{
+	`") |> yield() //`,
+	`600000" or r.symbol != "`,
+	`${r._value}`,
+	`600000\`,
+	"600000\n",
+	" 600000",
+	"600000.SH;drop",
+	"../../etc/passwd",
+	"600000.SHX",
+	"ABCDEFG",
+	"12345678901234567",
+}
+
+func TestValidateSymbolParam(t *testing.T) {
+	for raw, canonical := range map[string]string{
+		"600000":     "600000.SH",
+		"000001.SZ":  "000001.SZ",
+		"sh000001":   "000001.SH",
+		"688981.SS":  "688981.SH",
+		"bj430047":   "430047.BJ",
+		"00700.HK":   "00700.HK",
+		"hkHSI":      "HSI.HK",
+		"rt_hk00700": "00700.HK",
+		"AAPL":       "AAPL.US",
+		"BRK.B.US":   "BRK.B.US",
+		"gb_brk$b":   "BRK.B.US",
+	} {
+		symbol, err := ValidateSymbolParam(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, canonical, symbol.Canonical(), raw)
+	}
+
+	for _, raw := range append(maliciousSymbols, "") {
+		_, err := ValidateSymbolParam(raw)
+		assert.Error(t, err, "%q", raw)
+	}
+}
+
+func TestValidateSymbolParams_RejectsBeforeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fake := &fakeQueryAPI{csv: rawHistoryCSV}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s := &APIServer{queryAPI: fake, logger: logger}
+
+	router := gin.New()
+	router.Use(validateSymbolParams())
+	router.GET("/api/v1/stocks/:symbol/history", s.getStockHistory)
+
+	for _, raw := range maliciousSymbols {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/"+url.PathEscape(raw)+"/history", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code, "%q", raw)
+
+		var response ErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "bad_request", response.Error)
+		assert.Contains(t, response.Message, "symbol")
+	}
+	assert.Empty(t, fake.queries, "校验失败的请求不会查询 InfluxDB")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Len(t, fake.queries, 1)
+}
+
+func TestSymbolFilter_EscapesFluxString(t *testing.T) {
+	assert.Equal(t, `r.symbol == "600000.SH" or r.symbol == "600000"`, symbolFilter("600000"), "合法代码生成的查询不变")
+	assert.Contains(t, buildRawHistoryQuery("stock_data", "", "stock_realtime", "600000", time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC), time.Date(2025, 8, 20, 15, 0, 0, 0, time.UTC)),
+		"|> range(start: 2025-08-20T09:30:00Z, stop: 2025-08-20T15:00:00Z)\n\t\t|> filter(fn: (r) => r._measurement == \"stock_realtime\")\n\t\t|> filter(fn: (r) => (r.symbol == \"600000.SH\" or r.symbol == \"600000\") and not exists r.namespace)")
+	assert.Equal(t, `r.symbol == "\") |> yield() //"`, symbolFilter(`") |> yield() //`))
+	assert.Equal(t, `r.symbol == "\${r._value}"`, symbolFilter(`${r._value}`))
+	assert.Equal(t, `r.symbol == "a\\\"b\tc"`, symbolFilter("a\\\"b\tc"))
+}
+
+// fluxRecords 将数据点按字段展开为 InfluxDB 查询读到的记录（_measurement、_field 和全部标签）
+func fluxRecords(points ...*write.Point) []map[string]string {
+	var records []map[string]string
+	for _, point := range points {
+		for _, field := range point.FieldList() {
+			record := map[string]string{"_measurement": point.Name(), "_field": field.Key}
+			for _, tag := range point.TagList() {
+				record[tag.Key] = tag.Value
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// fluxFilterPattern 匹配查询中的 filter 行，只支持本包生成的单行谓词
+var fluxFilterPattern = regexp.MustCompile(`(?m)^\s*\|> filter\(fn: \(r\) => (.*)\)$`)
+
+// fluxMatch 按查询中全部 filter 行筛选记录，支持 and、or、not、括号、exists r.x 和 r.x == "v"
+func fluxMatch(t *testing.T, query string, records []map[string]string) []map[string]string {
+	t.Helper()
+	var filters [][]string
+	for _, match := range fluxFilterPattern.FindAllStringSubmatch(query, -1) {
+		filters = append(filters, regexp.MustCompile(`"(?:[^"\\]|\\.)*"|\(|\)|==|[\w.]+`).FindAllString(match[1], -1))
+	}
+	require.NotEmpty(t, filters)
+
+	var matched []map[string]string
+	for _, record := range records {
+		ok := true
+		for _, tokens := range filters {
+			e := &fluxEval{t: t, tokens: tokens, record: record}
+			result := e.or()
+			require.Equal(t, len(tokens), e.pos, "未能解析的谓词: %v", tokens)
+			ok = ok && result
+		}
+		if ok {
+			matched = append(matched, record)
+		}
+	}
+	return matched
+}
+
+type fluxEval struct {
+	t      *testing.T
+	tokens []string
+	pos    int
+	record map[string]string
+}
+
+func (e *fluxEval) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos]
+	}
+	return ""
+}
+
+func (e *fluxEval) next() string {
+	token := e.peek()
+	e.pos++
+	return token
+}
+
+func (e *fluxEval) or() bool {
+	result := e.and()
+	for e.peek() == "or" {
+		e.next()
+		result = e.and() || result
+	}
+	return result
+}
+
+func (e *fluxEval) and() bool {
+	result := e.unary()
+	for e.peek() == "and" {
+		e.next()
+		result = e.unary() && result
+	}
+	return result
+}
+
+func (e *fluxEval) unary() bool {
+	switch token := e.next(); {
+	case token == "not":
+		return !e.unary()
+	case token == "(":
+		result := e.or()
+		require.Equal(e.t, ")", e.next())
+		return result
+	case token == "exists":
+		_, ok := e.record[strings.TrimPrefix(e.next(), "r.")]
+		return ok
+	case strings.HasPrefix(token, "r."):
+		require.Equal(e.t, "==", e.next())
+		value, err := strconv.Unquote(e.next())
+		require.NoError(e.t, err)
+		actual, ok := e.record[strings.TrimPrefix(token, "r.")]
+		return ok && actual == value
+	default:
+		e.t.Fatalf("不支持的谓词 %q", token)
+		return false
+	}
+}
+
+func TestNamespaceFilter_DefaultExcludesNamedNamespaces(t *testing.T) {
+	now := time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC)
+	stock := message.StockData{Symbol: "600000", Name: "浦发银行", Price: 10.5, Volume: 1000}
+
+	// 与 influxdb_collector 相同的写入方式：默认命名空间不加标签，staging 加 namespace=staging
+	defaultPoint := backfill.StockPoint(stock, "tencent", "A-share", now)
+	stagingPoint := backfill.StockPoint(stock, "tencent", "A-share", now.Add(time.Second))
+	backfill.TagNamespace("staging", []*write.Point{stagingPoint})
+	records := fluxRecords(defaultPoint, stagingPoint)
+
+	start, end := now.Add(-time.Minute), now.Add(time.Minute)
+	matched := fluxMatch(t, buildRawHistoryQuery("stock_data", "", "stock_realtime", "600000", start, end), records)
+	require.Len(t, matched, 2, "price 和 volume 两个字段")
+	for _, record := range matched {
+		assert.NotContains(t, record, "namespace", "默认命名空间不读取 staging 的序列")
+	}
+
+	matched = fluxMatch(t, buildRawHistoryQuery("stock_data", "staging", "stock_realtime", "600000", start, end), records)
+	require.Len(t, matched, 2)
+	for _, record := range matched {
+		assert.Equal(t, "staging", record["namespace"])
+	}
+}
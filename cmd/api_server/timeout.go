@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	apperrors "stocksub/pkg/error"
+)
+
+// TimeoutConfig 按路由组的请求总超时和慢请求日志配置，超时为 0 表示不限制
+type TimeoutConfig struct {
+	Realtime      time.Duration `mapstructure:"realtime"`       // 实时行情接口
+	History       time.Duration `mapstructure:"history"`        // 历史数据、K 线和日线接口，包含写出响应的时间
+	Default       time.Duration `mapstructure:"default"`        // 其他 /api 接口，WebSocket 除外
+	SlowThreshold time.Duration `mapstructure:"slow_threshold"` // 耗时超过该值的请求记录警告日志，0 表示不记录
+}
+
+// timeoutWriter 超时后拒绝处理器继续写出：尚未写出响应时丢弃输出，由中间件返回 504；
+// 已经开始流式写出时只能截断，不再改写状态码
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+// expired 截止时间已过时返回 true，此后的写入都被拒绝
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
+
+// timeoutMiddleware 为处理器的请求上下文设置截止时间，超过时取消上下文；
+// 响应尚未写出时返回 504，已经开始流式写出时截断响应并记录日志
+func (s *APIServer) timeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		request := c.Request
+		original := c.Writer
+		writer := &timeoutWriter{ResponseWriter: original, ctx: ctx}
+		c.Request = request.WithContext(ctx)
+		c.Writer = writer
+		defer func() {
+			c.Writer = original
+			c.Request = request
+		}()
+
+		c.Next()
+
+		if !writer.expired() {
+			return
+		}
+		streaming := original.Written()
+		s.logger.WithFields(logrus.Fields{
+			"route":     c.FullPath(),
+			"params":    requestParams(c),
+			"timeout":   timeout.String(),
+			"bytes":     bytesWritten(original),
+			"streaming": streaming,
+		}).Warn("Request timed out")
+		if streaming {
+			return
+		}
+
+		header := original.Header()
+		for _, name := range []string{"Content-Length", "Content-Disposition", "ETag", "Trailer"} {
+			header.Del(name)
+		}
+		c.Writer = original
+		respondError(c, apperrors.NewError(apperrors.CodeNetworkTimeout, "request timed out"),
+			fmt.Sprintf("Request exceeded %s timeout", timeout))
+	}
+}
+
+// slowRequestMiddleware 记录耗时超过 threshold 的请求，WebSocket 长连接除外
+func (s *APIServer) slowRequestMiddleware(threshold time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if threshold <= 0 || c.IsWebsocket() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		duration := time.Since(start)
+		if duration < threshold {
+			return
+		}
+		s.logger.WithFields(logrus.Fields{
+			"route":    c.FullPath(),
+			"method":   c.Request.Method,
+			"params":   requestParams(c),
+			"status":   c.Writer.Status(),
+			"duration": duration.String(),
+			"bytes":    bytesWritten(c.Writer),
+		}).Warn("Slow request")
+	}
+}
+
+// requestParams 合并路径参数和查询参数用于日志，不记录 api_key
+func requestParams(c *gin.Context) map[string]string {
+	params := make(map[string]string, len(c.Params))
+	for _, p := range c.Params {
+		params[p.Key] = p.Value
+	}
+	for key, values := range c.Request.URL.Query() {
+		if key != "api_key" && len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	return params
+}
+
+// bytesWritten 返回已写出的响应体字节数，未写出时 gin 返回 -1
+func bytesWritten(w gin.ResponseWriter) int {
+	return max(w.Size(), 0)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apperrors "stocksub/pkg/error"
+)
+
+// newTimeoutTestServer 返回日志写入 buf 的服务，日志为 JSON 方便断言字段
+func newTimeoutTestServer(buf *bytes.Buffer) *APIServer {
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	return &APIServer{logger: logger}
+}
+
+// logEntries 解析 JSON 日志，返回 msg 等于 message 的条目
+func logEntries(t *testing.T, buf *bytes.Buffer, message string) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == message {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestTimeoutMiddleware_Returns504BeforeResponseStarted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	s := newTimeoutTestServer(&logs)
+
+	handlerErr := make(chan error, 1)
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol/history", s.timeoutMiddleware(20*time.Millisecond), func(c *gin.Context) {
+		// 处理器观察到上下文取消后按自己的逻辑写出错误，不应覆盖 504
+		<-c.Request.Context().Done()
+		handlerErr <- c.Request.Context().Err()
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "internal_error", Message: "query failed"})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000/history?interval=1m", nil))
+
+	assert.Error(t, <-handlerErr)
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "timeout", response.Error)
+	assert.Equal(t, apperrors.CodeNetworkTimeout, response.Code)
+	assert.Contains(t, response.Message, "20ms")
+
+	entries := logEntries(t, &logs, "Request timed out")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/api/v1/stocks/:symbol/history", entries[0]["route"])
+	assert.Equal(t, false, entries[0]["streaming"])
+	assert.Equal(t, map[string]interface{}{"symbol": "600000", "interval": "1m"}, entries[0]["params"])
+}
+
+func TestTimeoutMiddleware_HandlerIgnoringContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	s := newTimeoutTestServer(&logs)
+
+	router := gin.New()
+	router.GET("/slow", s.timeoutMiddleware(10*time.Millisecond), func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Header("ETag", `"stale"`)
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+	assert.NotContains(t, w.Body.String(), `"ok"`)
+}
+
+func TestTimeoutMiddleware_StreamingResponseIsTruncatedNotRewritten(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	s := newTimeoutTestServer(&logs)
+
+	writeErr := make(chan error, 1)
+	router := gin.New()
+	router.GET("/stream", gzipMiddleware(), s.timeoutMiddleware(20*time.Millisecond), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.WriteString("[1,")
+		c.Writer.Flush()
+
+		<-c.Request.Context().Done()
+		_, err := c.Writer.WriteString("2]")
+		writeErr <- err
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "internal_error"})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+
+	assert.ErrorIs(t, <-writeErr, http.ErrHandlerTimeout)
+	assert.Equal(t, http.StatusOK, w.Code, "已发出的状态码不能被改写")
+	assert.Equal(t, "[1,", w.Body.String())
+
+	entries := logEntries(t, &logs, "Request timed out")
+	require.Len(t, entries, 1)
+	assert.Equal(t, true, entries[0]["streaming"])
+	assert.Equal(t, float64(3), entries[0]["bytes"])
+}
+
+func TestTimeoutMiddleware_FastHandlerUnaffected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	s := newTimeoutTestServer(&logs)
+
+	router := gin.New()
+	router.GET("/fast", s.timeoutMiddleware(time.Second), func(c *gin.Context) {
+		_, hasDeadline := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"deadline": hasDeadline})
+	})
+	router.GET("/unbounded", s.timeoutMiddleware(0), func(c *gin.Context) {
+		_, hasDeadline := c.Request.Context().Deadline()
+		c.JSON(http.StatusOK, gin.H{"deadline": hasDeadline})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"deadline": true}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/unbounded", nil))
+	assert.JSONEq(t, `{"deadline": false}`, w.Body.String())
+	assert.Empty(t, logEntries(t, &logs, "Request timed out"))
+}
+
+func TestSlowRequestMiddleware_LogsRouteParamsAndBytes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	s := newTimeoutTestServer(&logs)
+
+	router := gin.New()
+	router.Use(s.slowRequestMiddleware(10 * time.Millisecond))
+	router.GET("/api/v1/stocks/:symbol", func(c *gin.Context) {
+		if c.Query("slow") == "1" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		c.String(http.StatusOK, "hello")
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000", nil))
+	assert.Empty(t, logEntries(t, &logs, "Slow request"))
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/stocks/600000?slow=1&api_key=secret", nil))
+	entries := logEntries(t, &logs, "Slow request")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/api/v1/stocks/:symbol", entries[0]["route"])
+	assert.Equal(t, map[string]interface{}{"symbol": "600000", "slow": "1"}, entries[0]["params"], "不记录 api_key")
+	assert.Equal(t, float64(5), entries[0]["bytes"])
+	assert.Equal(t, float64(http.StatusOK), entries[0]["status"])
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/message"
+)
+
+// traceHeader 返回快照追踪 ID 的响应头，值为写入该快照的消息的 CorrelationID
+const traceHeader = "X-Data-Trace-ID"
+
+// traceScanCount 查询追踪 ID 时每个 Stream 检查的最近条目数
+const traceScanCount = 1000
+
+// traceDataTypes 查询追踪 ID 时检查的 Stream 对应的数据类型
+var traceDataTypes = []string{"stock_realtime", "index_realtime", "stock_kline", "stock_eod"}
+
+// DataTrace 请求带 debug=1 时附加在响应中的追踪信息
+type DataTrace struct {
+	TraceID string `json:"trace_id"` // 为空表示快照由不带追踪 ID 的旧版本写入
+}
+
+// stockDebugResponse debug=1 时的单只股票响应
+type stockDebugResponse struct {
+	StockResponse
+	Trace DataTrace `json:"trace"`
+}
+
+// indexDebugResponse debug=1 时的单个指数响应
+type indexDebugResponse struct {
+	IndexResponse
+	Trace DataTrace `json:"trace"`
+}
+
+// wantDebug 请求是否带有 debug=1
+func wantDebug(c *gin.Context) bool {
+	debug, _ := strconv.ParseBool(c.Query("debug"))
+	return debug
+}
+
+// setTraceHeader 快照带追踪 ID 时设置 X-Data-Trace-ID
+func setTraceHeader(c *gin.Context, traceID string) {
+	if traceID != "" {
+		c.Header(traceHeader, traceID)
+	}
+}
+
+// respondStock 返回单只股票，debug=1 时附加追踪信息
+func respondStock(c *gin.Context, stock StockResponse) {
+	setTraceHeader(c, stock.TraceID)
+	parts := stockETagParts(stock)
+	if wantDebug(c) {
+		respondWithETag(c, append(parts, "trace|"+stock.TraceID), stockDebugResponse{StockResponse: stock, Trace: DataTrace{TraceID: stock.TraceID}})
+		return
+	}
+	respondWithETag(c, parts, stock)
+}
+
+// respondIndex 返回单个指数，debug=1 时附加追踪信息
+func respondIndex(c *gin.Context, index IndexResponse) {
+	setTraceHeader(c, index.TraceID)
+	parts := indexETagParts(index)
+	if wantDebug(c) {
+		respondWithETag(c, append(parts, "trace|"+index.TraceID), indexDebugResponse{IndexResponse: index, Trace: DataTrace{TraceID: index.TraceID}})
+		return
+	}
+	respondWithETag(c, parts, index)
+}
+
+// TraceHashHit trace_id 字段等于查询 ID 的快照哈希
+type TraceHashHit struct {
+	Key       string    `json:"key"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TraceStreamHit CorrelationID 等于查询 ID 的 Stream 条目
+type TraceStreamHit struct {
+	Stream    string    `json:"stream"`
+	ID        string    `json:"id"`
+	MessageID string    `json:"message_id"`
+	DataType  string    `json:"data_type"`
+	Provider  string    `json:"provider"`
+	Producer  string    `json:"producer"`
+	BatchSize int       `json:"batch_size"`
+	Timestamp time.Time `json:"timestamp"` // 消息头中的生产时间
+}
+
+// TraceReport 追踪 ID 出现的位置；快照哈希只保留最新一次写入的 ID，被后续消息覆盖后不再出现
+type TraceReport struct {
+	TraceID          string           `json:"trace_id"`
+	Found            bool             `json:"found"`
+	Hashes           []TraceHashHit   `json:"hashes"`
+	StreamEntries    []TraceStreamHit `json:"stream_entries"` // 按 Stream 从新到旧排列
+	ScannedPerStream int              `json:"scanned_per_stream"`
+}
+
+// getAdminTrace 查询追踪 ID 出现在哪些最新行情哈希，以及各行情 Stream 最近 traceScanCount 条中的哪些条目
+func (s *APIServer) getAdminTrace(c *gin.Context) {
+	if s.redisClient == nil {
+		c.JSON(503, ErrorResponse{Error: "service_unavailable", Message: "Redis is not configured"})
+		return
+	}
+	traceID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	hashes, err := s.traceHashes(ctx, traceID)
+	if err != nil {
+		s.logger.WithError(err).WithField("trace_id", traceID).Error("Failed to scan snapshot hashes")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to scan snapshots"})
+		return
+	}
+	entries, err := s.traceStreamEntries(ctx, traceID)
+	if err != nil {
+		s.logger.WithError(err).WithField("trace_id", traceID).Error("Failed to scan streams")
+		c.JSON(500, ErrorResponse{Error: "internal_error", Message: "Failed to scan streams"})
+		return
+	}
+
+	c.JSON(200, TraceReport{
+		TraceID:          traceID,
+		Found:            len(hashes) > 0 || len(entries) > 0,
+		Hashes:           hashes,
+		StreamEntries:    entries,
+		ScannedPerStream: traceScanCount,
+	})
+}
+
+// traceHashes 检查代码集合中每只股票和指数的最新快照的 trace_id
+func (s *APIServer) traceHashes(ctx context.Context, traceID string) ([]TraceHashHit, error) {
+	var stockKeys, indexKeys []string
+	for _, kind := range []string{"stock", "index"} {
+		symbols, err := s.redisClient.SMembers(ctx, s.symbolsKey(kind)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, symbol := range symbols {
+			if kind == "stock" {
+				stockKeys = append(stockKeys, s.latestKey(kind, symbol))
+			} else {
+				indexKeys = append(indexKeys, s.latestKey(kind, symbol))
+			}
+		}
+	}
+
+	stocks, err := s.readStockSnapshots(ctx, stockKeys, "trace_id", "updated_at")
+	if err != nil {
+		return nil, err
+	}
+	pipe := s.redisClient.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(indexKeys))
+	for i, key := range indexKeys {
+		cmds[i] = pipe.HMGet(ctx, key, "trace_id", "updated_at")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	keys := append(stockKeys, indexKeys...)
+	results := make([][]interface{}, 0, len(keys))
+	for _, raw := range stocks {
+		results = append(results, raw.values("trace_id", "updated_at"))
+	}
+	for _, cmd := range cmds {
+		results = append(results, cmd.Val())
+	}
+
+	hits := []TraceHashHit{}
+	for i, values := range results {
+		if len(values) != 2 || values[0] != traceID {
+			continue
+		}
+		hit := TraceHashHit{Key: keys[i]}
+		if raw, ok := values[1].(string); ok {
+			if updatedAt, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				hit.UpdatedAt = time.Unix(updatedAt, 0)
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// traceStreamEntries 检查各行情 Stream 最近的条目；只解析消息头，压缩的 payload 不需要解码
+func (s *APIServer) traceStreamEntries(ctx context.Context, traceID string) ([]TraceStreamHit, error) {
+	hits := []TraceStreamHit{}
+	for _, dataType := range traceDataTypes {
+		stream := message.GetStreamName(dataType, s.namespace)
+		entries, err := s.redisClient.XRevRangeN(ctx, stream, "+", "-", traceScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			data, ok := entry.Values["data"].(string)
+			if !ok {
+				continue
+			}
+			var msg struct {
+				Header   message.MessageHeader   `json:"header"`
+				Metadata message.MessageMetadata `json:"metadata"`
+			}
+			if err := json.Unmarshal([]byte(data), &msg); err != nil || msg.Header.CorrelationID != traceID {
+				continue
+			}
+			hits = append(hits, TraceStreamHit{
+				Stream:    stream,
+				ID:        entry.ID,
+				MessageID: msg.Header.MessageID,
+				DataType:  msg.Metadata.DataType,
+				Provider:  msg.Metadata.Provider,
+				Producer:  msg.Header.Producer,
+				BatchSize: msg.Metadata.BatchSize,
+				Timestamp: time.Unix(msg.Header.Timestamp, 0),
+			})
+		}
+	}
+	return hits, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+)
+
+func TestGetStock_ReturnsTraceID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, mr := newSymbolsTestServer(t)
+	s.loadSnapshots = s.loadLatestSnapshots
+	setSnapshot(mr, "latest:stock:600000.SH", "600000.SH", "price", "10.5")
+	mr.HSet("latest:stock:600000.SH", "trace_id", "trace-abc")
+	setSnapshot(mr, "latest:index:000001.SH", "000001.SH", "value", "3200.5")
+	mr.HSet("latest:index:000001.SH", "trace_id", "trace-idx")
+	mr.SAdd("latest:symbols:stock", "600000.SH")
+
+	router := gin.New()
+	router.GET("/api/v1/stocks/:symbol", s.getStock)
+	router.GET("/api/v1/stocks", s.getStocks)
+	router.GET("/api/v1/indices/:symbol", s.getIndex)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	w := get("/api/v1/stocks/600000")
+	assert.Equal(t, "trace-abc", w.Header().Get(traceHeader))
+	assert.NotContains(t, w.Body.String(), "trace", "默认不在响应体中返回追踪信息")
+	plainETag := w.Header().Get("ETag")
+
+	w = get("/api/v1/stocks/600000?debug=1")
+	var debug stockDebugResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &debug))
+	assert.Equal(t, "trace-abc", debug.Trace.TraceID)
+	assert.Equal(t, 10.5, debug.Price)
+	assert.NotEqual(t, plainETag, w.Header().Get("ETag"))
+
+	w = get("/api/v1/indices/000001.SH?debug=1")
+	assert.Equal(t, "trace-idx", w.Header().Get(traceHeader))
+	assert.Contains(t, w.Body.String(), `"trace":{"trace_id":"trace-idx"}`)
+
+	w = get("/api/v1/stocks")
+	assert.NotContains(t, w.Body.String(), "trace", "列表不返回追踪 ID")
+}
+
+func TestGetAdminTrace_ReportsHashesAndStreamEntries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, mr := newSymbolsTestServer(t)
+	ctx := context.Background()
+
+	// 按 fetcher 的方式发布两条消息，只有一条带查询的追踪 ID
+	for _, traceID := range []string{"trace-abc", "trace-other"} {
+		msg := message.NewMessageFormat("fetcher-1", "tencent", "stock_realtime", []message.StockData{{Symbol: "600000", Price: 10.5}})
+		msg.SetCorrelationID(traceID)
+		data, err := msg.ToCompressedJSON(message.EncodingGzip)
+		require.NoError(t, err)
+		require.NoError(t, s.redisClient.XAdd(ctx, &redis.XAddArgs{
+			Stream: "stream:stock:realtime",
+			Values: map[string]interface{}{"data": data},
+		}).Err())
+	}
+	setSnapshot(mr, "latest:stock:600000.SH", "600000.SH", "price", "10.5")
+	mr.HSet("latest:stock:600000.SH", "trace_id", "trace-abc")
+	setSnapshot(mr, "latest:stock:000001.SZ", "000001.SZ", "price", "12.3")
+	mr.HSet("latest:stock:000001.SZ", "trace_id", "trace-other")
+	mr.SAdd("latest:symbols:stock", "600000.SH", "000001.SZ")
+
+	router := gin.New()
+	router.GET("/api/v1/admin/trace/:id", s.getAdminTrace)
+	get := func(id string) TraceReport {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/trace/"+id, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var report TraceReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return report
+	}
+
+	report := get("trace-abc")
+	assert.True(t, report.Found)
+	require.Len(t, report.Hashes, 1)
+	assert.Equal(t, "latest:stock:600000.SH", report.Hashes[0].Key)
+	assert.Equal(t, int64(1755684000), report.Hashes[0].UpdatedAt.Unix())
+	require.Len(t, report.StreamEntries, 1)
+	entry := report.StreamEntries[0]
+	assert.Equal(t, "stream:stock:realtime", entry.Stream)
+	assert.Equal(t, "stock_realtime", entry.DataType)
+	assert.Equal(t, "fetcher-1", entry.Producer)
+	assert.Equal(t, 1, entry.BatchSize)
+
+	report = get("trace-missing")
+	assert.False(t, report.Found)
+	assert.Empty(t, report.Hashes)
+	assert.Empty(t, report.StreamEntries)
+}
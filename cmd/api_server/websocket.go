@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/core"
+)
+
+// WebSocket 客户端消息动作
+const (
+	wsActionSubscribe   = "subscribe"
+	wsActionUnsubscribe = "unsubscribe"
+)
+
+// WebSocket 服务端推送帧类型
+const (
+	wsFrameStock        = "stock"
+	wsFrameIndex        = "index"
+	wsFrameSubscribed   = "subscribed"
+	wsFrameUnsubscribed = "unsubscribed"
+	wsFrameError        = "error"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsMaxMessageSize = 4096
+	wsSendBuffer     = 256
+)
+
+// WSClientMessage 客户端发送的订阅/取消订阅消息
+type WSClientMessage struct {
+	Action  string   `json:"action"`
+	Symbols []string `json:"symbols"`
+}
+
+// WSFrame 服务端推送的数据帧
+type WSFrame struct {
+	Type    string         `json:"type"`
+	Stock   *StockResponse `json:"stock,omitempty"`
+	Index   *IndexResponse `json:"index,omitempty"`
+	Symbols []string       `json:"symbols,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+// wsSnapshot 用于变化检测的最新行情快照
+type wsSnapshot struct {
+	price  float64
+	volume int64
+}
+
+// wsSnapshotLoader 批量加载股票和指数的最新行情
+type wsSnapshotLoader func(ctx context.Context, stocks, indices []string) (map[string]*StockResponse, map[string]*IndexResponse, error)
+
+// wsHub 管理所有 WebSocket 连接，并定期轮询最新行情向订阅者推送变化
+type wsHub struct {
+	mu       sync.RWMutex
+	clients  map[*wsClient]struct{}
+	config   WebSocketConfig
+	load     wsSnapshotLoader
+	logger   *logrus.Logger
+	upgrader websocket.Upgrader
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+	closed   bool
+}
+
+// wsClient 单个 WebSocket 连接及其订阅列表
+type wsClient struct {
+	hub     *wsHub
+	conn    *websocket.Conn
+	send    chan WSFrame
+	done    chan struct{}
+	mu      sync.Mutex
+	symbols map[string]struct{}
+	last    map[string]wsSnapshot
+	depth   bool // 连接时带 depth=1 才推送盘口
+	once    sync.Once
+}
+
+// newWSHub 创建 WebSocket 连接管理器
+func newWSHub(config WebSocketConfig, load wsSnapshotLoader, logger *logrus.Logger) *wsHub {
+	return &wsHub{
+		clients:  make(map[*wsClient]struct{}),
+		config:   config,
+		load:     load,
+		logger:   logger,
+		stopChan: make(chan struct{}),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     func(r *http.Request) bool { return true }, // 与 CORS 中间件保持一致
+		},
+	}
+}
+
+// Run 启动行情轮询循环
+func (h *wsHub) Run() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(h.config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stopChan:
+				return
+			case <-ticker.C:
+				h.poll()
+			}
+		}
+	}()
+}
+
+// Close 关闭所有连接并停止轮询
+func (h *wsHub) Close() {
+	h.stopOnce.Do(func() {
+		close(h.stopChan)
+
+		h.mu.Lock()
+		h.closed = true
+		clients := make([]*wsClient, 0, len(h.clients))
+		for client := range h.clients {
+			clients = append(clients, client)
+		}
+		h.mu.Unlock()
+
+		for _, client := range clients {
+			client.closeWithMessage(websocket.CloseGoingAway, "server shutting down")
+		}
+		h.wg.Wait()
+	})
+}
+
+// Count 返回当前连接数
+func (h *wsHub) Count() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// ServeWS 处理 WebSocket 升级请求
+func (h *wsHub) ServeWS(c *gin.Context) {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		c.JSON(503, ErrorResponse{Error: "unavailable", Message: "Server is shutting down"})
+		return
+	}
+	if h.config.MaxConnections > 0 && len(h.clients) >= h.config.MaxConnections {
+		h.mu.Unlock()
+		c.JSON(503, ErrorResponse{Error: "too_many_connections", Message: "WebSocket connection limit reached"})
+		return
+	}
+	// 先占位，防止并发升级超过上限
+	client := &wsClient{
+		hub:     h,
+		send:    make(chan WSFrame, wsSendBuffer),
+		done:    make(chan struct{}),
+		symbols: make(map[string]struct{}),
+		last:    make(map[string]wsSnapshot),
+		depth:   wantDepth(c),
+	}
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.remove(client)
+		h.logger.WithError(err).Warn("Failed to upgrade WebSocket connection")
+		return
+	}
+
+	// 升级期间服务器可能已开始关闭，需在锁内确认后再登记连接和 goroutine。
+	// 此时 Close 可能已对该客户端执行过 closeWithMessage，因此直接关闭新连接
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		h.remove(client)
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteWait))
+		conn.Close()
+		return
+	}
+	client.conn = conn
+	h.wg.Add(2)
+	count := len(h.clients)
+	h.mu.Unlock()
+
+	h.logger.WithField("connections", count).Debug("WebSocket client connected")
+
+	go client.writePump()
+	go client.readPump()
+}
+
+// remove 移除连接
+func (h *wsHub) remove(client *wsClient) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+}
+
+// poll 加载所有订阅标的的最新行情，并向价格或成交量发生变化的订阅者推送
+func (h *wsHub) poll() {
+	h.mu.RLock()
+	clients := make([]*wsClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	stockSet := make(map[string]struct{})
+	indexSet := make(map[string]struct{})
+	for _, client := range clients {
+		for _, symbol := range client.subscribedSymbols() {
+			if isIndexSymbol(symbol) {
+				indexSet[symbol] = struct{}{}
+			} else {
+				stockSet[symbol] = struct{}{}
+			}
+		}
+	}
+	if len(stockSet) == 0 && len(indexSet) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stocks, indices, err := h.load(ctx, setToSortedSlice(stockSet), setToSortedSlice(indexSet))
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to load latest data for WebSocket clients")
+		return
+	}
+
+	for _, client := range clients {
+		client.pushChanges(stocks, indices)
+	}
+}
+
+// subscribedSymbols 返回当前订阅的标的
+func (c *wsClient) subscribedSymbols() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return setToSortedSlice(c.symbols)
+}
+
+// pushChanges 推送与上次发送相比发生变化的行情
+func (c *wsClient) pushChanges(stocks map[string]*StockResponse, indices map[string]*IndexResponse) {
+	c.mu.Lock()
+	var frames []WSFrame
+	for symbol := range c.symbols {
+		if stock, ok := stocks[symbol]; ok {
+			snap := wsSnapshot{price: stock.Price, volume: stock.Volume}
+			if last, seen := c.last[symbol]; !seen || last != snap {
+				c.last[symbol] = snap
+				frame := withDepth(stock, c.depth)
+				frames = append(frames, WSFrame{Type: wsFrameStock, Stock: &frame})
+			}
+		} else if index, ok := indices[symbol]; ok {
+			snap := wsSnapshot{price: index.Value}
+			if last, seen := c.last[symbol]; !seen || last != snap {
+				c.last[symbol] = snap
+				frames = append(frames, WSFrame{Type: wsFrameIndex, Index: index})
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	for _, frame := range frames {
+		c.enqueue(frame)
+	}
+}
+
+// enqueue 非阻塞地将帧放入发送队列，队列满说明客户端过慢，直接断开
+func (c *wsClient) enqueue(frame WSFrame) {
+	select {
+	case <-c.done:
+	case c.send <- frame:
+	default:
+		c.hub.logger.Warn("WebSocket client too slow, closing connection")
+		c.closeWithMessage(websocket.ClosePolicyViolation, "send buffer overflow")
+	}
+}
+
+// handleMessage 处理客户端消息
+func (c *wsClient) handleMessage(msg WSClientMessage) {
+	symbols := normalizeSymbols(msg.Symbols)
+
+	switch msg.Action {
+	case wsActionSubscribe:
+		c.mu.Lock()
+		maxSymbols := c.hub.config.MaxSymbols
+		added := 0
+		for _, symbol := range symbols {
+			if _, ok := c.symbols[symbol]; !ok {
+				added++
+			}
+		}
+		if maxSymbols > 0 && len(c.symbols)+added > maxSymbols {
+			c.mu.Unlock()
+			c.enqueue(WSFrame{Type: wsFrameError, Message: fmt.Sprintf("subscription limit exceeded, maximum is %d symbols", maxSymbols)})
+			return
+		}
+		for _, symbol := range symbols {
+			c.symbols[symbol] = struct{}{}
+		}
+		c.mu.Unlock()
+		c.enqueue(WSFrame{Type: wsFrameSubscribed, Symbols: symbols})
+
+	case wsActionUnsubscribe:
+		c.mu.Lock()
+		for _, symbol := range symbols {
+			delete(c.symbols, symbol)
+			delete(c.last, symbol)
+		}
+		c.mu.Unlock()
+		c.enqueue(WSFrame{Type: wsFrameUnsubscribed, Symbols: symbols})
+
+	default:
+		c.enqueue(WSFrame{Type: wsFrameError, Message: fmt.Sprintf("unknown action %q", msg.Action)})
+	}
+}
+
+// readPump 读取客户端消息，并通过 pong 维持读超时
+func (c *wsClient) readPump() {
+	defer c.hub.wg.Done()
+	defer c.closeWithMessage(websocket.CloseNormalClosure, "")
+
+	pongWait := c.hub.config.PingInterval * 2
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg WSClientMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.hub.logger.WithError(err).Debug("WebSocket read error")
+			}
+			return
+		}
+		c.handleMessage(msg)
+	}
+}
+
+// writePump 发送推送帧，并定期发送 ping
+func (c *wsClient) writePump() {
+	defer c.hub.wg.Done()
+
+	ticker := time.NewTicker(c.hub.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case frame := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(frame); err != nil {
+				c.closeWithMessage(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.closeWithMessage(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// closeWithMessage 发送关闭帧并释放连接，可重复调用
+func (c *wsClient) closeWithMessage(code int, text string) {
+	c.once.Do(func() {
+		close(c.done)
+		c.hub.remove(c)
+		if c.conn == nil {
+			return
+		}
+		if code != websocket.CloseAbnormalClosure {
+			c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+		}
+		c.conn.Close()
+	})
+}
+
+// handleWebSocket 处理 /api/v1/ws 请求
+func (s *APIServer) handleWebSocket(c *gin.Context) {
+	s.wsHub.ServeWS(c)
+}
+
+// loadLatestSnapshots 通过 Redis pipeline 批量读取 <prefix>stock:* 快照和 <prefix>index:* 哈希
+// 每个代码先读规范形式的键，不存在时回退到旧格式的键，结果仍按请求中的代码索引
+func (s *APIServer) loadLatestSnapshots(ctx context.Context, stocks, indices []string) (map[string]*StockResponse, map[string]*IndexResponse, error) {
+	var stockKeys []string
+	stockSpans := make(map[string][2]int, len(stocks)) // 每个代码的候选键在 stockKeys 中的区间
+	for _, symbol := range stocks {
+		start := len(stockKeys)
+		for _, candidate := range symbolCandidates(symbol) {
+			stockKeys = append(stockKeys, s.latestKey("stock", candidate))
+		}
+		stockSpans[symbol] = [2]int{start, len(stockKeys)}
+	}
+	stockSnapshots, err := s.readStockSnapshots(ctx, stockKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pipe := s.redisClient.Pipeline()
+	indexCmds := make(map[string][]*redis.StringStringMapCmd, len(indices))
+	for _, symbol := range indices {
+		for _, candidate := range symbolCandidates(symbol) {
+			indexCmds[symbol] = append(indexCmds[symbol], pipe.HGetAll(ctx, s.latestKey("index", candidate)))
+		}
+	}
+	if len(indexCmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, nil, fmt.Errorf("failed to execute Redis pipeline: %w", err)
+		}
+	}
+
+	stockResult := make(map[string]*StockResponse, len(stockSpans))
+	for symbol, span := range stockSpans {
+		raw, ok := firstExisting(stockSnapshots[span[0]:span[1]])
+		if !ok {
+			continue
+		}
+		stock, err := s.parseStockSnapshot(raw)
+		if err != nil {
+			s.logger.WithError(err).WithField("symbol", symbol).Warn("Failed to parse stock data")
+			continue
+		}
+		stockResult[symbol] = stock
+	}
+
+	indexResult := make(map[string]*IndexResponse, len(indexCmds))
+	for symbol, cmds := range indexCmds {
+		data := firstNonEmpty(cmds)
+		if data == nil {
+			continue
+		}
+		index, err := s.parseIndexFromRedis(data)
+		if err != nil {
+			s.logger.WithError(err).WithField("symbol", symbol).Warn("Failed to parse index data")
+			continue
+		}
+		indexResult[symbol] = index
+	}
+
+	return stockResult, indexResult, nil
+}
+
+// firstExisting 返回第一个存在的股票快照
+func firstExisting(snapshots []rawStockSnapshot) (rawStockSnapshot, bool) {
+	for _, raw := range snapshots {
+		if raw.exists() {
+			return raw, true
+		}
+	}
+	return rawStockSnapshot{}, false
+}
+
+// firstNonEmpty 返回第一个存在的哈希，都不存在时返回 nil
+func firstNonEmpty(cmds []*redis.StringStringMapCmd) map[string]string {
+	for _, cmd := range cmds {
+		if data, err := cmd.Result(); err == nil && len(data) > 0 {
+			return data
+		}
+	}
+	return nil
+}
+
+// isIndexSymbol 判断是否为指数代码（如 sh000001、000001.SH、399001）
+func isIndexSymbol(symbol string) bool {
+	parsed, err := core.ParseSymbol(symbol)
+	return err == nil && parsed.IsIndex()
+}
+
+// normalizeSymbols 去除空白和重复的代码
+func normalizeSymbols(symbols []string) []string {
+	seen := make(map[string]struct{}, len(symbols))
+	result := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		if _, ok := seen[symbol]; ok {
+			continue
+		}
+		seen[symbol] = struct{}{}
+		result = append(result, symbol)
+	}
+	return result
+}
+
+// setToSortedSlice 将集合转换为有序切片
+func setToSortedSlice(set map[string]struct{}) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
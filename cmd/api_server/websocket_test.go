@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSnapshotSource 可变的行情数据源，替代 Redis
+type fakeSnapshotSource struct {
+	mu      sync.Mutex
+	stocks  map[string]*StockResponse
+	indices map[string]*IndexResponse
+	calls   int
+}
+
+func (f *fakeSnapshotSource) set(stock *StockResponse, index *IndexResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if stock != nil {
+		f.stocks[stock.Symbol] = stock
+	}
+	if index != nil {
+		f.indices[index.Symbol] = index
+	}
+}
+
+func (f *fakeSnapshotSource) load(ctx context.Context, stocks, indices []string) (map[string]*StockResponse, map[string]*IndexResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	stockResult := make(map[string]*StockResponse)
+	for _, symbol := range stocks {
+		if s, ok := f.stocks[symbol]; ok {
+			copied := *s
+			stockResult[symbol] = &copied
+		}
+	}
+	indexResult := make(map[string]*IndexResponse)
+	for _, symbol := range indices {
+		if i, ok := f.indices[symbol]; ok {
+			copied := *i
+			indexResult[symbol] = &copied
+		}
+	}
+	return stockResult, indexResult, nil
+}
+
+func (f *fakeSnapshotSource) loadCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func newTestWSServer(t *testing.T, config WebSocketConfig) (*wsHub, *fakeSnapshotSource, string) {
+	gin.SetMode(gin.TestMode)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	source := &fakeSnapshotSource{
+		stocks:  make(map[string]*StockResponse),
+		indices: make(map[string]*IndexResponse),
+	}
+	hub := newWSHub(config, source.load, logger)
+	hub.Run()
+
+	router := gin.New()
+	router.GET("/api/v1/ws", hub.ServeWS)
+	server := httptest.NewServer(router)
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+
+	return hub, source, "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws"
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) WSFrame {
+	t.Helper()
+	var frame WSFrame
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, conn.ReadJSON(&frame))
+	return frame
+}
+
+func testWSConfig() WebSocketConfig {
+	return WebSocketConfig{
+		MaxConnections: 2,
+		MaxSymbols:     10,
+		PollInterval:   20 * time.Millisecond,
+		PingInterval:   time.Second,
+	}
+}
+
+func TestWebSocket_SubscribeReceivesPushedFrames(t *testing.T) {
+	_, source, url := newTestWSServer(t, testWSConfig())
+	source.set(&StockResponse{Symbol: "600000", Name: "浦发银行", Price: 10.5, Volume: 1000}, nil)
+	source.set(nil, &IndexResponse{Symbol: "sh000001", Name: "上证指数", Value: 3200.5})
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Action: "subscribe", Symbols: []string{"600000", "sh000001"}}))
+
+	frame := readFrame(t, conn)
+	assert.Equal(t, wsFrameSubscribed, frame.Type)
+	assert.Equal(t, []string{"600000", "sh000001"}, frame.Symbols)
+
+	got := map[string]WSFrame{}
+	for len(got) < 2 {
+		frame := readFrame(t, conn)
+		got[frame.Type] = frame
+	}
+	require.NotNil(t, got[wsFrameStock].Stock)
+	assert.Equal(t, 10.5, got[wsFrameStock].Stock.Price)
+	require.NotNil(t, got[wsFrameIndex].Index)
+	assert.Equal(t, 3200.5, got[wsFrameIndex].Index.Value)
+
+	// 价格变化后才会再次推送
+	source.set(&StockResponse{Symbol: "600000", Name: "浦发银行", Price: 10.6, Volume: 1200}, nil)
+	frame = readFrame(t, conn)
+	assert.Equal(t, wsFrameStock, frame.Type)
+	assert.Equal(t, 10.6, frame.Stock.Price)
+	assert.Equal(t, int64(1200), frame.Stock.Volume)
+
+	// 数据不变时不应推送
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(150*time.Millisecond)))
+	var extra WSFrame
+	assert.Error(t, conn.ReadJSON(&extra), "unchanged data should not be pushed")
+}
+
+func TestWebSocket_UnsubscribeAndErrors(t *testing.T) {
+	config := testWSConfig()
+	config.MaxSymbols = 1
+	_, _, url := newTestWSServer(t, config)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Action: "subscribe", Symbols: []string{"600000", "000001"}}))
+	frame := readFrame(t, conn)
+	assert.Equal(t, wsFrameError, frame.Type)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Action: "subscribe", Symbols: []string{"600000"}}))
+	assert.Equal(t, wsFrameSubscribed, readFrame(t, conn).Type)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Action: "unsubscribe", Symbols: []string{"600000"}}))
+	frame = readFrame(t, conn)
+	assert.Equal(t, wsFrameUnsubscribed, frame.Type)
+	assert.Equal(t, []string{"600000"}, frame.Symbols)
+
+	require.NoError(t, conn.WriteJSON(WSClientMessage{Action: "bogus"}))
+	assert.Equal(t, wsFrameError, readFrame(t, conn).Type)
+}
+
+func TestWebSocket_MaxConnections(t *testing.T) {
+	hub, _, url := newTestWSServer(t, testWSConfig())
+
+	for i := 0; i < 2; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+	}
+	assert.Equal(t, 2, hub.Count())
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestWebSocket_CloseShutsDownConnections(t *testing.T) {
+	hub, _, url := newTestWSServer(t, testWSConfig())
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	hub.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected going-away close, got %v", err)
+	assert.Equal(t, 0, hub.Count())
+}
+
+func TestWebSocket_CloseDuringUpgradeClosesConnection(t *testing.T) {
+	hub, _, url := newTestWSServer(t, testWSConfig())
+	// CheckOrigin 在升级过程中执行，借此模拟升级期间服务器开始关闭
+	hub.upgrader.CheckOrigin = func(r *http.Request) bool {
+		hub.Close()
+		return true
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected going-away close, got %v", err)
+	assert.Equal(t, 0, hub.Count())
+}
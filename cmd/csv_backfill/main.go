@@ -0,0 +1,122 @@
+// csv_backfill 将 api_monitor 等通过 CSVStorage 归档的行情数据回填到 InfluxDB
+//
+// 写入的点与 influxdb_collector 完全一致（stock_realtime 测量值，相同的标签和字段），
+// 按 (代码, 时间戳) 去重，可以安全地对已有数据重复执行。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/backfill"
+	"stocksub/pkg/message"
+)
+
+var (
+	dataDir      = flag.String("dir", "tests/data/collected", "CSV 数据目录，也可以在参数中直接列出文件")
+	influxURL    = flag.String("influx-url", "http://localhost:8086", "InfluxDB 地址")
+	influxToken  = flag.String("influx-token", os.Getenv("INFLUXDB_TOKEN"), "InfluxDB Token（默认读取 INFLUXDB_TOKEN）")
+	influxOrg    = flag.String("influx-org", "stocksub", "InfluxDB 组织")
+	influxBucket = flag.String("influx-bucket", "stock_data", "InfluxDB 存储桶")
+	provider     = flag.String("provider", "tencent", "写入 provider 标签的数据源名称")
+	market       = flag.String("market", "A-share", "写入 market 标签的市场名称")
+	namespace    = flag.String("namespace", "", "写入 namespace 标签的命名空间，需与 influxdb_collector 一致，为空时为默认命名空间")
+	batchSize    = flag.Int("batch-size", 5000, "每批写入的点数")
+	rateLimit    = flag.Float64("rate-limit", 0, "每秒最多写入的点数，0 表示不限制")
+	dryRun       = flag.Bool("dry-run", false, "只读取和转换，不写入 InfluxDB")
+	logLevel     = flag.String("log-level", "info", "日志级别 (debug, info, warn, error)")
+)
+
+func main() {
+	flag.Parse()
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	if level, err := logrus.ParseLevel(*logLevel); err == nil {
+		logger.SetLevel(level)
+	}
+
+	if err := message.ValidateNamespace(*namespace); err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	files := flag.Args()
+	if len(files) == 0 {
+		var err error
+		files, err = backfill.FindFiles(*dataDir)
+		if err != nil {
+			logger.Fatalf("读取数据目录失败: %v", err)
+		}
+	}
+	if len(files) == 0 {
+		logger.Warnf("%s 中没有股票数据文件", *dataDir)
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var writer backfill.PointWriter
+	if !*dryRun {
+		client := influxdb2.NewClient(*influxURL, *influxToken)
+		defer client.Close()
+
+		healthCtx, healthCancel := context.WithTimeout(ctx, 10*time.Second)
+		status, err := client.Health(healthCtx)
+		healthCancel()
+		if err != nil {
+			logger.Fatalf("连接 InfluxDB 失败: %v", err)
+		}
+		if status.Status != "pass" {
+			logger.Fatalf("InfluxDB 状态异常: %s", status.Status)
+		}
+		writer = client.WriteAPIBlocking(*influxOrg, *influxBucket)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"files":      len(files),
+		"namespace":  *namespace,
+		"batch_size": *batchSize,
+		"rate_limit": *rateLimit,
+		"dry_run":    *dryRun,
+	}).Info("开始回填")
+
+	start := time.Now()
+	progress, err := backfill.Run(ctx, files, writer, backfill.Config{
+		Provider:  *provider,
+		Market:    *market,
+		Namespace: *namespace,
+		BatchSize: *batchSize,
+		RateLimit: *rateLimit,
+		DryRun:    *dryRun,
+		Progress: func(p backfill.Progress) {
+			logger.WithFields(logrus.Fields{
+				"file":       filepath.Base(p.File),
+				"files":      fmt.Sprintf("%d/%d", p.FilesDone, p.FilesTotal),
+				"rows":       p.Rows,
+				"duplicates": p.Duplicates,
+				"points":     p.Points,
+				"written":    p.Written,
+			}).Info("回填进度")
+		},
+	})
+	if err != nil {
+		logger.Fatalf("回填失败（已写入 %d 个点）: %v", progress.Written, err)
+	}
+
+	fmt.Printf("回填完成: %d 个文件, %d 行, 跳过重复 %d 行, %d 个点, 写入 %d 个点, 耗时 %v\n",
+		progress.FilesDone, progress.Rows, progress.Duplicates, progress.Points, progress.Written,
+		time.Since(start).Round(time.Millisecond))
+	if *dryRun {
+		fmt.Println("dry-run 模式，未写入 InfluxDB")
+	}
+}
@@ -0,0 +1,119 @@
+// exporter 将 InfluxDB 中的逐笔行情按交易日导出为 CSV 或 Parquet 归档
+//
+// 默认导出昨天的数据，写入 <output_dir>/<date>/<symbol>.csv.gz，每个分区目录附带
+// manifest.json（文件、行数、SHA-256）。重复执行时跳过校验和一致的文件，有股票失败时以非 0 退出。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/export"
+)
+
+var (
+	configFile = flag.String("config", "config/exporter.yaml", "导出配置文件")
+	startDate  = flag.String("start", "", "起始交易日（YYYY-MM-DD），覆盖配置文件")
+	endDate    = flag.String("end", "", "结束交易日（YYYY-MM-DD，含），覆盖配置文件")
+	logLevel   = flag.String("log-level", "info", "日志级别 (debug, info, warn, error)")
+)
+
+func main() {
+	flag.Parse()
+
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	if level, err := logrus.ParseLevel(*logLevel); err == nil {
+		logger.SetLevel(level)
+	}
+
+	config, err := export.LoadConfig(*configFile)
+	if *startDate != "" {
+		config.StartDate, config.EndDate = *startDate, *endDate
+		err = config.Validate()
+	}
+	if err != nil {
+		logger.Fatalf("加载配置失败: %v", err)
+	}
+	if config.InfluxDB.Token == "" {
+		config.InfluxDB.Token = os.Getenv("INFLUXDB_TOKEN")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var rdb *redis.Client
+	if config.Symbols.All() {
+		rdb = redis.NewClient(&redis.Options{Addr: config.Redis.Addr, Password: config.Redis.Password, DB: config.Redis.DB})
+		defer rdb.Close()
+	}
+	symbols, err := export.ResolveSymbols(ctx, rdb, config)
+	if err != nil {
+		logger.Fatalf("获取股票列表失败: %v", err)
+	}
+	if len(symbols) == 0 {
+		logger.Warn("没有需要导出的股票")
+		return
+	}
+	dates, err := config.Dates(time.Now())
+	if err != nil {
+		logger.Fatalf("解析日期失败: %v", err)
+	}
+
+	client := influxdb2.NewClient(config.InfluxDB.URL, config.InfluxDB.Token)
+	defer client.Close()
+
+	healthCtx, healthCancel := context.WithTimeout(ctx, 10*time.Second)
+	status, err := client.Health(healthCtx)
+	healthCancel()
+	if err != nil {
+		logger.Fatalf("连接 InfluxDB 失败: %v", err)
+	}
+	if status.Status != "pass" {
+		logger.Fatalf("InfluxDB 状态异常: %s", status.Status)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"symbols":     len(symbols),
+		"dates":       fmt.Sprintf("%s~%s", dates[0].Format("2006-01-02"), dates[len(dates)-1].Format("2006-01-02")),
+		"format":      config.Format,
+		"compression": config.Compression,
+		"output_dir":  config.OutputDir,
+		"concurrency": config.Concurrency,
+	}).Info("开始导出")
+
+	config.Progress = func(p export.Progress) {
+		entry := logger.WithFields(logrus.Fields{
+			"date":     p.Date,
+			"symbol":   p.Symbol,
+			"files":    fmt.Sprintf("%d/%d", p.Done, p.Total),
+			"rows":     p.Rows,
+			"duration": p.Duration.Round(time.Millisecond),
+		})
+		switch {
+		case p.Err != nil:
+			entry.WithError(p.Err).Error("导出失败")
+		case p.Skipped:
+			entry.Debug("校验和一致，跳过")
+		default:
+			entry.Info("导出完成")
+		}
+	}
+
+	start := time.Now()
+	result, err := export.Run(ctx, client.QueryAPI(config.InfluxDB.Org), symbols, dates, config)
+	fmt.Printf("导出结束: 写入 %d 个文件, 跳过 %d 个, 失败 %d 个, %d 行, 耗时 %v\n",
+		result.Exported, result.Skipped, len(result.Failures), result.Rows, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		logger.Fatalf("导出失败: %v", err)
+	}
+}
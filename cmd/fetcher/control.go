@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/logger"
+	"stocksub/pkg/message"
+	"stocksub/pkg/scheduler"
+)
+
+const (
+	// refreshJobName 按需刷新在执行统计中使用的任务名称
+	refreshJobName = "admin_refresh"
+
+	// refreshConsumerGroup 所有 fetcher 节点共用一个消费者组，每个请求只由一个节点执行
+	refreshConsumerGroup = "fetcher-control"
+
+	// refreshMaxAge 超过该时长的请求直接确认并丢弃，避免 fetcher 停机期间积压的请求在重启后集中执行
+	refreshMaxAge = 5 * time.Minute
+)
+
+// refreshHandler 处理 stream:control:fetch 中的按需刷新请求，
+// 在调度之外立即通过与定时任务相同的提供商链获取并发布实时行情
+type refreshHandler struct {
+	executor *FetcherExecutor
+	provider scheduler.ProviderConfig
+	now      func() time.Time
+	log      *logger.Entry
+}
+
+// newRefreshHandler 创建按需刷新处理器，providerName 为主提供商，fallbacks 为备用提供商
+func newRefreshHandler(executor *FetcherExecutor, providerName string, fallbacks []string, log *logger.Entry) *refreshHandler {
+	return &refreshHandler{
+		executor: executor,
+		provider: scheduler.ProviderConfig{Name: providerName, Type: "RealtimeStock", Fallbacks: fallbacks},
+		now:      time.Now,
+		log:      log.WithField("stream", message.FetchControlStream),
+	}
+}
+
+// handle 实现 consumer.Handler，无法解析或已过期的请求只记录日志；获取失败时返回错误，由消费者重试
+func (h *refreshHandler) handle(ctx context.Context, stream string, msg redis.XMessage) error {
+	req, err := message.ParseFetchRequest(msg.Values)
+	if err != nil {
+		h.log.WithField("messageID", msg.ID).Warnf("忽略无效的刷新请求: %v", err)
+		return nil
+	}
+	if age := h.now().Sub(req.RequestTime()); age > refreshMaxAge {
+		h.log.WithField("messageID", msg.ID).Warnf("忽略 %s 前的过期刷新请求: %v", age.Round(time.Second), req.Symbols)
+		return nil
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"messageID":   msg.ID,
+		"symbols":     req.Symbols,
+		"requestedBy": req.RequestedBy,
+	}).Info("执行按需刷新")
+
+	job := &scheduler.Job{
+		ID: msg.ID,
+		Config: scheduler.JobConfig{
+			Name:     refreshJobName,
+			Provider: h.provider,
+			Params:   map[string]interface{}{"symbols": req.Symbols},
+		},
+	}
+	return h.executor.Execute(ctx, job)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/logger"
+	"stocksub/pkg/message"
+)
+
+func newRefreshTestHandler(t *testing.T) (*refreshHandler, *fakeStockProvider, *redis.Client) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	executor, _ := newTestExecutor(t, &fakeHistoricalProvider{})
+	executor.redisClient = client
+	stocks := &fakeStockProvider{}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", stocks))
+	return newRefreshHandler(executor, "tencent", nil, logger.WithComponent("fetcher-test")), stocks, client
+}
+
+func TestRefreshHandler_FetchesRequestedSymbols(t *testing.T) {
+	handler, stocks, client := newRefreshTestHandler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	values, err := message.NewFetchRequest([]string{"600000.SH", "000001.SZ"}, "support").ToValues()
+	require.NoError(t, err)
+	require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: message.FetchControlStream, Values: values}).Err())
+
+	quiet := logrus.New()
+	quiet.SetLevel(logrus.PanicLevel)
+	c := consumer.New(client, consumer.Config{Group: refreshConsumerGroup, Name: "fetcher-test", Streams: []string{message.FetchControlStream}}, handler.handle, quiet)
+	require.NoError(t, c.CreateGroups(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return client.XLen(ctx, "stream:stock:realtime").Val() == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, [][]string{{"600000.SH", "000001.SZ"}}, stocks.calls)
+	entries := client.XRange(context.Background(), "stream:stock:realtime", "-", "+").Val()
+	msg, err := message.FromJSON(entries[0].Values["data"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, "tencent", msg.Metadata.Provider)
+	assert.Len(t, msg.Payload, 2)
+
+	pending := client.XPending(context.Background(), message.FetchControlStream, refreshConsumerGroup).Val()
+	assert.Zero(t, pending.Count, "处理完成的请求已确认")
+}
+
+func TestRefreshHandler_SkipsStaleAndInvalidRequests(t *testing.T) {
+	handler, stocks, client := newRefreshTestHandler(t)
+	handler.now = func() time.Time { return time.Now().Add(refreshMaxAge + time.Minute) }
+
+	values, err := message.NewFetchRequest([]string{"600000.SH"}, "").ToValues()
+	require.NoError(t, err)
+	require.NoError(t, handler.handle(context.Background(), message.FetchControlStream, redis.XMessage{ID: "1-0", Values: values}))
+	require.NoError(t, handler.handle(context.Background(), message.FetchControlStream, redis.XMessage{ID: "2-0", Values: map[string]interface{}{"data": `{"symbols": []}`}}))
+
+	assert.Empty(t, stocks.calls)
+	assert.Zero(t, client.XLen(context.Background(), "stream:stock:realtime").Val())
+}
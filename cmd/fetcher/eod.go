@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+	"stocksub/pkg/scheduler"
+)
+
+// eodSnapshotType 收盘快照任务的提供商类型
+const eodSnapshotType = "eod_snapshot"
+
+// eodClaimTTL 收盘快照标记的保留时间，足以覆盖当天的重试和重复触发
+const eodClaimTTL = 7 * 24 * time.Hour
+
+// eodGuard 记录已发布收盘快照的交易日和股票，同一交易日每个股票只发布一次
+type eodGuard interface {
+	// Claim 标记 date 的 symbols，返回此前未标记、本次应当发布的股票
+	Claim(ctx context.Context, date string, symbols []string) ([]string, error)
+	// Release 撤销标记，发布失败时调用，使下次执行可以重试
+	Release(ctx context.Context, date string, symbols []string) error
+}
+
+// redisEODGuard 用 SETNX 标记 eod:snapshot:<yyyymmdd>:<symbol>，多个 fetcher 节点共享；
+// 非默认命名空间的键为 <namespace>:eod:snapshot:...
+type redisEODGuard struct {
+	client    redis.Cmdable
+	namespace string
+	ttl       time.Duration
+}
+
+func newRedisEODGuard(client redis.Cmdable, namespace string) *redisEODGuard {
+	return &redisEODGuard{client: client, namespace: namespace, ttl: eodClaimTTL}
+}
+
+// eodClaimKey 收盘快照标记的键，date 格式为 message.EODDateLayout
+func eodClaimKey(date, symbol string) string {
+	return "eod:snapshot:" + strings.ReplaceAll(date, "-", "") + ":" + core.NormalizeSymbol(symbol)
+}
+
+func (g *redisEODGuard) Claim(ctx context.Context, date string, symbols []string) ([]string, error) {
+	pipe := g.client.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(symbols))
+	for i, symbol := range symbols {
+		cmds[i] = pipe.SetNX(ctx, message.NamespaceKey(g.namespace, eodClaimKey(date, symbol)), time.Now().Unix(), g.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("标记收盘快照失败: %w", err)
+	}
+
+	claimed := make([]string, 0, len(symbols))
+	for i, cmd := range cmds {
+		if cmd.Val() {
+			claimed = append(claimed, symbols[i])
+		}
+	}
+	return claimed, nil
+}
+
+func (g *redisEODGuard) Release(ctx context.Context, date string, symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+	keys := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		keys[i] = message.NamespaceKey(g.namespace, eodClaimKey(date, symbol))
+	}
+	return g.client.Del(ctx, keys...).Err()
+}
+
+// executeEODSnapshot 收盘后获取最终的实时行情，转换为收盘快照发布到 stream:stock:eod。
+// 同一交易日已发布过的股票跳过，发布失败时撤销标记，下次执行重试
+func (e *FetcherExecutor) executeEODSnapshot(ctx context.Context, job *scheduler.Job, run *jobRun) error {
+	names := append([]string{job.Config.Provider.Name}, job.Config.Provider.Fallbacks...)
+	chain, err := e.providerManager.GetRealtimeStockProviderChain(names...)
+	if err != nil {
+		return fmt.Errorf("获取实时股票提供商失败: %w", err)
+	}
+	chain.SetTopUpMissing(job.Config.Provider.TopUp)
+
+	symbols, err := e.extractSymbols(job.Config)
+	if err != nil {
+		return fmt.Errorf("提取股票符号失败: %w", err)
+	}
+	if len(symbols) == 0 {
+		return fmt.Errorf("没有找到股票符号")
+	}
+
+	result, err := chain.Fetch(ctx, symbols)
+	if err != nil {
+		return fmt.Errorf("获取股票数据失败: %w", err)
+	}
+	if len(result.Missing) > 0 {
+		e.log.Warnf("%d 个股票未获取到收盘数据: %v", len(result.Missing), result.Missing)
+	}
+
+	now := time.Now()
+	var errs []error
+	for _, batch := range result.Batches {
+		run.fetched(batch.Provider, len(batch.Data))
+
+		// 一般只有一个交易日，按日期分组保证每条消息的快照属于同一天
+		byDate := make(map[string][]message.EODData)
+		for _, stock := range batch.Data {
+			eod := message.NewEODData(stock, now)
+			byDate[eod.Date] = append(byDate[eod.Date], eod)
+		}
+		dates := make([]string, 0, len(byDate))
+		for date := range byDate {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+
+		for _, date := range dates {
+			if err := e.publishEOD(ctx, run, batch.Provider, date, byDate[date]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publishEOD 标记并发布同一交易日的收盘快照，已标记的股票跳过
+func (e *FetcherExecutor) publishEOD(ctx context.Context, run *jobRun, provider, date string, snapshots []message.EODData) error {
+	claimed := make([]string, len(snapshots))
+	for i, eod := range snapshots {
+		claimed[i] = eod.Symbol
+	}
+	if e.eodGuard != nil {
+		var err error
+		if claimed, err = e.eodGuard.Claim(ctx, date, claimed); err != nil {
+			return err
+		}
+		if skipped := len(snapshots) - len(claimed); skipped > 0 {
+			e.log.Infof("%s 的收盘快照已发布，跳过 %d 个股票", date, skipped)
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		keep := make(map[string]bool, len(claimed))
+		for _, symbol := range claimed {
+			keep[symbol] = true
+		}
+		filtered := snapshots[:0]
+		for _, eod := range snapshots {
+			if keep[eod.Symbol] {
+				filtered = append(filtered, eod)
+			}
+		}
+		snapshots = filtered
+	}
+
+	msg := message.NewMessageFormat(e.nodeID, provider, "stock_eod", snapshots)
+	msg.SetMarketInfo("A-share", e.marketTime.TradingSession())
+	if err := e.publish(ctx, run, msg, len(snapshots)); err != nil {
+		if e.eodGuard != nil {
+			if releaseErr := e.eodGuard.Release(context.WithoutCancel(ctx), date, claimed); releaseErr != nil {
+				e.log.Warnf("撤销收盘快照标记失败: %v", releaseErr)
+			}
+		}
+		return fmt.Errorf("发布 %s 的收盘快照失败: %w", date, err)
+	}
+	e.log.Infof("发布 %s 的收盘快照: %d 个股票", date, len(snapshots))
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/scheduler"
+)
+
+func eodJob(symbols ...interface{}) *scheduler.Job {
+	return &scheduler.Job{
+		ID: "eod",
+		Config: scheduler.JobConfig{
+			Name:     "eod",
+			Provider: scheduler.ProviderConfig{Name: "tencent", Type: eodSnapshotType},
+			Params:   map[string]interface{}{"symbols": symbols},
+		},
+	}
+}
+
+func newEODTestExecutor(t *testing.T) (*FetcherExecutor, *fakePublisher, *miniredis.Miniredis) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", &fakeStockProvider{}))
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	executor.eodGuard = newRedisEODGuard(client, "")
+	return executor, publisher, mr
+}
+
+func TestFetcherExecutor_EODSnapshotPublishesEODData(t *testing.T) {
+	executor, publisher, mr := newEODTestExecutor(t)
+
+	require.NoError(t, executor.Execute(context.Background(), eodJob("600000", "000001")))
+
+	require.Len(t, publisher.messages, 1)
+	assert.Equal(t, "stream:stock:eod", publisher.streams[0])
+	msg := publisher.messages[0]
+	assert.Equal(t, "stock_eod", msg.Metadata.DataType)
+	assert.Equal(t, 2, msg.Metadata.BatchSize)
+	require.NoError(t, msg.Validate())
+
+	first := msg.Payload.([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "600000", first["symbol"])
+	assert.Equal(t, "2025-08-20", first["date"], "行情时间 2025-08-20 18:00（北京时间）所在的交易日")
+	assert.Equal(t, 10.5, first["close"])
+
+	assert.True(t, mr.Exists("eod:snapshot:20250820:600000.SH"))
+	ttl := mr.TTL("eod:snapshot:20250820:600000.SH")
+	assert.Equal(t, eodClaimTTL, ttl)
+}
+
+func TestFetcherExecutor_EODSnapshotNamespaceClaimsSeparately(t *testing.T) {
+	executor, publisher, mr := newEODTestExecutor(t)
+	mr.Set("eod:snapshot:20250820:600000.SH", "1") // 默认命名空间已写入，不影响 staging
+	executor.namespace = "staging"
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	executor.eodGuard = newRedisEODGuard(client, "staging")
+
+	require.NoError(t, executor.Execute(context.Background(), eodJob("600000")))
+
+	require.Len(t, publisher.messages, 1)
+	assert.Equal(t, "stream:staging:stock:eod", publisher.streams[0])
+	assert.True(t, mr.Exists("staging:eod:snapshot:20250820:600000.SH"))
+}
+
+func TestFetcherExecutor_EODSnapshotSkipsAlreadyWrittenDate(t *testing.T) {
+	executor, publisher, _ := newEODTestExecutor(t)
+
+	require.NoError(t, executor.Execute(context.Background(), eodJob("600000")))
+	require.Len(t, publisher.messages, 1)
+
+	// 同一交易日再次触发：已发布的股票跳过，新股票仍然发布
+	require.NoError(t, executor.Execute(context.Background(), eodJob("600000", "000001")))
+	require.Len(t, publisher.messages, 2)
+	payload := publisher.messages[1].Payload.([]interface{})
+	require.Len(t, payload, 1)
+	assert.Equal(t, "000001", payload[0].(map[string]interface{})["symbol"])
+
+	require.NoError(t, executor.Execute(context.Background(), eodJob("600000", "000001")))
+	assert.Len(t, publisher.messages, 2, "所有股票都已发布时不发布消息")
+}
+
+func TestFetcherExecutor_EODSnapshotReleasesClaimOnPublishFailure(t *testing.T) {
+	executor, publisher, mr := newEODTestExecutor(t)
+	job := eodJob("600000")
+	run := &jobRun{
+		providers: make(map[string]scheduler.RunStats),
+		sinks:     make(map[string]scheduler.SinkStats),
+		outputs:   []namedSink{{name: "broken", sink: failedSink{err: errors.New("redis down")}}},
+	}
+
+	err := executor.execute(context.Background(), job, run)
+	require.ErrorContains(t, err, "redis down")
+	assert.False(t, mr.Exists("eod:snapshot:20250820:600000.SH"), "发布失败时撤销标记")
+
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Len(t, publisher.messages, 1, "撤销标记后可以重试")
+}
+
+func TestRedisEODGuard_ClaimIsPerDate(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	guard := newRedisEODGuard(client, "")
+	ctx := context.Background()
+
+	claimed, err := guard.Claim(ctx, "2025-08-20", []string{"600000", "sh600036"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600000", "sh600036"}, claimed)
+
+	claimed, err = guard.Claim(ctx, "2025-08-20", []string{"600000.SH", "600036"})
+	require.NoError(t, err)
+	assert.Empty(t, claimed, "代码按规范形式去重")
+
+	claimed, err = guard.Claim(ctx, "2025-08-21", []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600000"}, claimed, "不同交易日互不影响")
+
+	require.NoError(t, guard.Release(ctx, "2025-08-20", []string{"600000"}))
+	claimed, err = guard.Claim(ctx, "2025-08-20", []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600000"}, claimed)
+}
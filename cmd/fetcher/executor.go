@@ -2,63 +2,229 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
 	"time"
 
 	"stocksub/pkg/logger"
 	"stocksub/pkg/message"
 	"stocksub/pkg/provider"
 	"stocksub/pkg/scheduler"
+	"stocksub/pkg/storage"
+	"stocksub/pkg/timing"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 )
 
-// FetcherExecutor 任务执行器，负责获取股票数据并发布到 Redis
+// streamPublisher 发布消息用到的 Redis 命令，*redis.Client 满足该接口
+type streamPublisher interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+}
+
+// streamQueue 带缓冲和重试的 Stream 发布队列，*message.Publisher 满足该接口
+type streamQueue interface {
+	PublishEncoded(ctx context.Context, stream string, msg *message.MessageFormat, encoding string) error
+	SetStreamLimits(limits map[string]int64)
+}
+
+// statsRecorder 记录任务执行统计，*scheduler.StatsRecorder 满足该接口
+type statsRecorder interface {
+	Record(ctx context.Context, job string, run scheduler.RunStats, providers map[string]scheduler.RunStats) error
+}
+
+// rawRecorder 保存采样的原始响应，*scheduler.RawCaptureRecorder 满足该接口
+type rawRecorder interface {
+	Record(ctx context.Context, capture scheduler.RawCapture, maxEntries int) error
+}
+
+// statsRecordTimeout 写入执行统计的超时时间，任务上下文取消后仍会写入
+const statsRecordTimeout = 2 * time.Second
+
+// FetcherExecutor 任务执行器，负责获取股票数据并发布到任务配置的输出目标
 type FetcherExecutor struct {
 	providerManager *provider.ProviderManager
-	redisClient     *redis.Client
+	redisClient     streamPublisher
+	publisher       streamQueue      // 为 nil 时 redis_stream 输出直接 XADD
+	stats           statsRecorder    // 为 nil 时不记录执行统计
+	raw             rawRecorder      // 任务开启 debug.capture_raw 时保存原始响应，为 nil 时不采集
+	sample          func() float64   // 原始响应采样使用的 [0, 1) 随机数，为 nil 时使用 rand.Float64
+	eodGuard        eodGuard         // 收盘快照去重，为 nil 时不去重
+	streamMaxLen    map[string]int64 // 各 Stream 发布时的 MAXLEN ~ N，未配置时不裁剪
+	namespace       string           // 发布的 Stream 和收盘快照标记所属的命名空间，为空时不加前缀
 	nodeID          string
+	marketTime      *timing.MarketTime
 	log             *logger.Entry
+	stdout          io.Writer // stdout_json 输出的目标，为 nil 时使用 os.Stdout
+
+	outputMu    sync.Mutex
+	csvStorages map[string]*storage.CSVStorage // csv_file 输出按目录共用的存储
+}
+
+// SetStreamLimits 设置各 Stream 的近似长度上限，需在调度器启动前调用
+func (e *FetcherExecutor) SetStreamLimits(streams []scheduler.StreamConfig) {
+	e.streamMaxLen = make(map[string]int64, len(streams))
+	for _, stream := range streams {
+		if stream.MaxLen > 0 {
+			e.streamMaxLen[message.NamespaceStream(e.namespace, stream.Name)] = stream.MaxLen
+		}
+	}
+	if e.publisher != nil {
+		e.publisher.SetStreamLimits(e.streamMaxLen)
+	}
+}
+
+// SetPublisher 设置 redis_stream 输出使用的发布队列，需在 SetStreamLimits 和调度器启动前调用
+func (e *FetcherExecutor) SetPublisher(publisher streamQueue) {
+	e.publisher = publisher
 }
 
-// NewFetcherExecutor 创建新的 FetcherExecutor 实例
-func NewFetcherExecutor(providerManager *provider.ProviderManager, redisClient *redis.Client, nodeID string, baseLog *logger.Entry) *FetcherExecutor {
+// jobRun 一次任务执行中按提供商累计的获取和发布数量，以及各输出目标的发布结果
+type jobRun struct {
+	traceID   string // 本次执行发布的消息的 CorrelationID
+	providers map[string]scheduler.RunStats
+	sinks     map[string]scheduler.SinkStats
+	outputs   []namedSink
+}
+
+// fetched 记录从提供商获取的记录数
+func (r *jobRun) fetched(provider string, n int) {
+	stats := r.providers[provider]
+	stats.Fetched += int64(n)
+	r.providers[provider] = stats
+}
+
+// published 记录发布到 Stream 的记录数
+func (r *jobRun) published(provider string, n int) {
+	stats := r.providers[provider]
+	stats.Published += int64(n)
+	r.providers[provider] = stats
+}
+
+// sinkPublished 记录输出目标成功写入的记录数
+func (r *jobRun) sinkPublished(sink string, n int) {
+	stats := r.sinks[sink]
+	stats.Published += int64(n)
+	r.sinks[sink] = stats
+}
+
+// sinkFailed 记录输出目标的一次失败
+func (r *jobRun) sinkFailed(sink string) {
+	stats := r.sinks[sink]
+	stats.Errors++
+	r.sinks[sink] = stats
+}
+
+// total 汇总各提供商的数量，并带上各输出目标的结果
+func (r *jobRun) total() scheduler.RunStats {
+	var total scheduler.RunStats
+	for _, stats := range r.providers {
+		total.Fetched += stats.Fetched
+		total.Published += stats.Published
+		total.Errors += stats.Errors
+	}
+	if len(r.sinks) > 0 {
+		total.Sinks = r.sinks
+	}
+	return total
+}
+
+// NewFetcherExecutor 创建新的 FetcherExecutor 实例，namespace 为空时使用默认命名空间
+func NewFetcherExecutor(providerManager *provider.ProviderManager, redisClient *redis.Client, nodeID, namespace string, baseLog *logger.Entry) *FetcherExecutor {
 	return &FetcherExecutor{
 		providerManager: providerManager,
 		redisClient:     redisClient,
+		stats:           scheduler.NewStatsRecorder(redisClient, namespace),
+		raw:             scheduler.NewRawCaptureRecorder(redisClient, namespace),
+		eodGuard:        newRedisEODGuard(redisClient, namespace),
+		namespace:       namespace,
 		nodeID:          nodeID,
+		marketTime:      timing.DefaultMarketTime(),
 		log:             baseLog.WithField("executor", "fetcher"),
 	}
 }
 
 // Execute 实现 JobExecutor 接口，执行具体的股票数据获取任务
 func (e *FetcherExecutor) Execute(ctx context.Context, job *scheduler.Job) error {
+	traceID := uuid.New().String()
 	e.log = e.log.WithFields(map[string]interface{}{
-		"job":    job.Config.Name,
-		"jobID":  job.ID,
-		"nodeID": e.nodeID,
+		"job":     job.Config.Name,
+		"jobID":   job.ID,
+		"nodeID":  e.nodeID,
+		"traceID": traceID,
 	})
 
 	e.log.Info("开始执行任务")
 	e.log.Debugf("任务参数: %+v", job.Config.Params)
 
-	// 根据提供商类型获取提供商
-	var provider provider.RealtimeStockProvider
-	var err error
+	start := time.Now()
+	run := &jobRun{
+		traceID:   traceID,
+		providers: make(map[string]scheduler.RunStats),
+		sinks:     make(map[string]scheduler.SinkStats),
+		outputs:   e.sinksFor(job),
+	}
+	err := e.execute(ctx, job, run)
+	e.recordStats(ctx, job, run, time.Since(start), err)
+	return err
+}
 
+// execute 按提供商类型执行任务
+func (e *FetcherExecutor) execute(ctx context.Context, job *scheduler.Job, run *jobRun) error {
 	e.log.Debugf("获取提供商: type=%s, name=%s", job.Config.Provider.Type, job.Config.Provider.Name)
 	switch job.Config.Provider.Type {
 	case "RealtimeStock":
-		provider, err = e.providerManager.GetRealtimeStockProvider(job.Config.Provider.Name)
-		if err != nil {
-			return fmt.Errorf("获取实时股票提供商失败: %w", err)
-		}
+		return e.executeRealtimeStock(ctx, job, run)
+	case "RealtimeIndex":
+		return e.executeRealtimeIndex(ctx, job, run)
+	case "Historical":
+		return e.executeHistorical(ctx, job, run)
+	case eodSnapshotType:
+		return e.executeEODSnapshot(ctx, job, run)
 	default:
 		return fmt.Errorf("不支持的提供商类型: %s", job.Config.Provider.Type)
 	}
+}
+
+// recordStats 把本次执行的获取、发布数量和耗时累加到 Redis，失败时错误计入任务的主提供商。
+// 写入统计失败只记录日志，不影响任务结果。
+func (e *FetcherExecutor) recordStats(ctx context.Context, job *scheduler.Job, run *jobRun, duration time.Duration, execErr error) {
+	if e.stats == nil {
+		return
+	}
+	if execErr != nil {
+		primary := run.providers[job.Config.Provider.Name]
+		primary.Errors++
+		run.providers[job.Config.Provider.Name] = primary
+	}
+	total := run.total()
+	total.Duration = duration
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), statsRecordTimeout)
+	defer cancel()
+	if err := e.stats.Record(ctx, job.Config.Name, total, run.providers); err != nil {
+		e.log.Warnf("记录任务统计失败: %v", err)
+	}
+}
+
+// executeRealtimeStock 获取实时股票数据并发布到 stream:stock:realtime
+// 配置了 provider.fallbacks 时主提供商失败会依次尝试备用提供商，每个实际提供数据的提供商各发布一条消息
+func (e *FetcherExecutor) executeRealtimeStock(ctx context.Context, job *scheduler.Job, run *jobRun) error {
+	names := append([]string{job.Config.Provider.Name}, job.Config.Provider.Fallbacks...)
+	chain, err := e.providerManager.GetRealtimeStockProviderChain(names...)
+	if err != nil {
+		return fmt.Errorf("获取实时股票提供商失败: %w", err)
+	}
+	chain.SetTopUpMissing(job.Config.Provider.TopUp)
+	captureRaw := e.shouldCaptureRaw(job)
+	chain.SetCaptureRaw(captureRaw)
 
 	// 获取股票符号列表
-	symbols, err := e.extractSymbols(job.Config.Params)
+	symbols, err := e.extractSymbols(job.Config)
 	if err != nil {
 		return fmt.Errorf("提取股票符号失败: %w", err)
 	}
@@ -67,11 +233,11 @@ func (e *FetcherExecutor) Execute(ctx context.Context, job *scheduler.Job) error
 		return fmt.Errorf("没有找到股票符号")
 	}
 
-	e.log.Debugf("准备获取 %d 个股票的数据: %v", len(symbols), symbols)
+	e.log.Debugf("准备获取 %d 个股票的数据: %v, 提供商: %v", len(symbols), symbols, names)
 
 	// 获取股票数据
 	start := time.Now()
-	stockDataList, err := provider.FetchStockData(ctx, symbols)
+	result, err := chain.Fetch(ctx, symbols)
 	if err != nil {
 		return fmt.Errorf("获取股票数据失败: %w", err)
 	}
@@ -79,80 +245,262 @@ func (e *FetcherExecutor) Execute(ctx context.Context, job *scheduler.Job) error
 	duration := time.Since(start)
 	e.log.Debugf("数据获取耗时: %v", duration)
 
-	if len(stockDataList) == 0 {
+	if len(result.Missing) > 0 {
+		e.log.Warnf("%d 个股票未获取到数据: %v", len(result.Missing), result.Missing)
+	}
+
+	if len(result.Batches) == 0 {
 		e.log.Warn("没有获取到股票数据")
 		return nil
 	}
 
-	e.log.Debugf("成功获取 %d 个股票数据", len(stockDataList))
-
-	// 转换为消息格式的股票数据
-	e.log.Debug("转换股票数据为消息格式")
-	messageStockData := make([]message.StockData, len(stockDataList))
-	for i, stock := range stockDataList {
-		messageStockData[i] = message.StockData{
-			Symbol:        stock.Symbol,
-			Name:          stock.Name,
-			Price:         stock.Price,
-			Change:        stock.Change,
-			ChangePercent: stock.ChangePercent,
-			Volume:        stock.Volume,
-			Timestamp:     stock.Timestamp.Format(time.RFC3339),
+	tradingSession := e.marketTime.TradingSession()
+	var errs []error
+	for _, batch := range result.Batches {
+		run.fetched(batch.Provider, len(batch.Data))
+	}
+	if captureRaw {
+		e.recordRaw(ctx, job, run, result.Batches)
+	}
+	for _, batch := range result.Batches {
+		if batch.Provider != job.Config.Provider.Name {
+			e.log.Warnf("由备用提供商 %s 提供 %d 个股票数据", batch.Provider, len(batch.Data))
+		}
+
+		// 转换为消息格式的股票数据，按 StockDataSchema 的字段精度舍入
+		e.log.Debug("转换股票数据为消息格式")
+		messageStockData := make([]message.StockData, len(batch.Data))
+		for i, stock := range batch.Data {
+			messageStockData[i] = message.NewStockData(stock)
+			messageStockData[i].Round(storage.StockDataSchema)
+			e.log.Debugf("股票数据: %s - 价格:%.2f, 涨跌:%.2f(%.2f%%)",
+				stock.Symbol, stock.Price, stock.Change, stock.ChangePercent)
+		}
+
+		// 创建标准消息格式，provider 记录实际提供数据的提供商
+		msg := message.NewMessageFormat(
+			e.nodeID,
+			batch.Provider,
+			"stock_realtime",
+			messageStockData,
+		)
+
+		// 设置市场信息
+		msg.SetMarketInfo("A-share", tradingSession)
+		e.log.Debugf("设置市场信息: 交易时段=%s", tradingSession)
+
+		if err := e.publish(ctx, run, msg, len(messageStockData)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// shouldCaptureRaw 按任务的 debug 配置决定本次执行是否采集原始响应
+func (e *FetcherExecutor) shouldCaptureRaw(job *scheduler.Job) bool {
+	if e.raw == nil || !job.Config.Debug.CaptureRaw {
+		return false
+	}
+	sample := e.sample
+	if sample == nil {
+		sample = rand.Float64
+	}
+	return job.Config.Debug.ShouldCapture(sample())
+}
+
+// recordRaw 把每个提供商的原始响应和解析结果校验和写入 debug:raw:<提供商>，失败只记录日志
+func (e *FetcherExecutor) recordRaw(ctx context.Context, job *scheduler.Job, run *jobRun, batches []provider.ProviderBatch) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), statsRecordTimeout)
+	defer cancel()
+	for _, batch := range batches {
+		capture := scheduler.NewRawCapture(batch.Provider, job.Config.Name, batch.Raw, batch.Data, time.Now())
+		capture.TraceID = run.traceID
+		if err := e.raw.Record(ctx, capture, job.Config.Debug.RawEntries()); err != nil {
+			e.log.WithField("provider", batch.Provider).Warnf("保存原始响应失败: %v", err)
+			continue
+		}
+		e.log.WithField("provider", batch.Provider).Debugf("已保存原始响应: %d 字节", len(capture.Raw))
+	}
+}
+
+// executeRealtimeIndex 获取实时指数数据并发布到 stream:index:realtime
+func (e *FetcherExecutor) executeRealtimeIndex(ctx context.Context, job *scheduler.Job, run *jobRun) error {
+	provider, err := e.providerManager.GetRealtimeIndexProvider(job.Config.Provider.Name)
+	if err != nil {
+		return fmt.Errorf("获取实时指数提供商失败: %w", err)
+	}
+
+	symbols, err := e.extractSymbols(job.Config)
+	if err != nil {
+		return fmt.Errorf("提取指数代码失败: %w", err)
+	}
+
+	if len(symbols) == 0 {
+		return fmt.Errorf("没有找到指数代码")
+	}
+
+	e.log.Debugf("准备获取 %d 个指数的数据: %v", len(symbols), symbols)
+
+	indexDataList, err := provider.FetchIndexData(ctx, symbols)
+	if err != nil {
+		return fmt.Errorf("获取指数数据失败: %w", err)
+	}
+
+	run.fetched(job.Config.Provider.Name, len(indexDataList))
+	if len(indexDataList) == 0 {
+		e.log.Warn("没有获取到指数数据")
+		return nil
+	}
+
+	// core.IndexData 不带时间戳，使用获取时间
+	timestamp := time.Now().Format(time.RFC3339)
+	messageIndexData := make([]message.IndexData, len(indexDataList))
+	for i, index := range indexDataList {
+		messageIndexData[i] = message.IndexData{
+			Symbol:        index.Symbol,
+			Name:          index.Name,
+			Value:         index.Value,
+			Change:        index.Change,
+			ChangePercent: index.ChangePercent,
+			Volume:        index.Volume,
+			Turnover:      index.Turnover,
+			Timestamp:     timestamp,
 		}
-		e.log.Debugf("股票数据: %s - 价格:%.2f, 涨跌:%.2f(%.2f%%)",
-			stock.Symbol, stock.Price, stock.Change, stock.ChangePercent)
-	}
-
-	// 创建标准消息格式
-	e.log.Debug("创建标准消息格式")
-	msg := message.NewMessageFormat(
-		e.nodeID,
-		job.Config.Provider.Name,
-		"stock_realtime",
-		messageStockData,
-	)
-
-	// 设置市场信息
-	tradingSession := e.getTradingSession()
-	msg.SetMarketInfo("A-share", tradingSession)
-	e.log.Debugf("设置市场信息: 交易时段=%s", tradingSession)
-
-	// 转换为 JSON
-	jsonData, err := msg.ToJSON()
+		messageIndexData[i].Round(storage.StockDataSchema)
+	}
+
+	msg := message.NewMessageFormat(e.nodeID, job.Config.Provider.Name, "index_realtime", messageIndexData)
+	msg.SetMarketInfo("A-share", e.marketTime.TradingSession())
+
+	return e.publish(ctx, run, msg, len(messageIndexData))
+}
+
+// executeHistorical 获取历史K线数据并发布到 stream:stock:kline，每个股票一条消息
+func (e *FetcherExecutor) executeHistorical(ctx context.Context, job *scheduler.Job, run *jobRun) error {
+	provider, err := e.providerManager.GetHistoricalProvider(job.Config.Provider.Name)
 	if err != nil {
-		return fmt.Errorf("序列化消息失败: %w", err)
+		return fmt.Errorf("获取历史数据提供商失败: %w", err)
 	}
 
-	// 发布到 Redis Streams
-	streamName := message.GetStreamName("stock_realtime")
-	e.log.Debugf("发布消息到 Redis Stream: %s", streamName)
+	symbols, err := e.extractSymbols(job.Config)
+	if err != nil {
+		return fmt.Errorf("提取股票符号失败: %w", err)
+	}
 
-	result := e.redisClient.XAdd(ctx, &redis.XAddArgs{
-		Stream: streamName,
-		Values: map[string]interface{}{
-			"data": jsonData,
-		},
-	})
+	if len(symbols) == 0 {
+		return fmt.Errorf("没有找到股票符号")
+	}
 
-	if err := result.Err(); err != nil {
-		return fmt.Errorf("发布消息到 Redis Streams 失败: %w", err)
+	params, err := scheduler.ParseHistoricalParams(job.Config.Params, time.Now())
+	if err != nil {
+		return fmt.Errorf("提取历史数据参数失败: %w", err)
 	}
 
-	e.log.WithFields(map[string]interface{}{
-		"stream":    streamName,
-		"messageID": result.Val(),
-		"dataCount": len(messageStockData),
-	}).Info("消息发布成功")
+	e.log.Debugf("准备获取 %d 个股票的K线: period=%s, start=%s, end=%s",
+		len(symbols), params.Period, params.Start.Format(time.RFC3339), params.End.Format(time.RFC3339))
 
-	e.log.Debugf("消息内容大小: %d bytes", len(jsonData))
-	return nil
+	var failed []string
+	var errs []error
+	for _, symbol := range symbols {
+		bars, err := provider.FetchHistoricalData(ctx, symbol, params.Start, params.End, params.Period)
+		if err != nil {
+			e.log.WithField("symbol", symbol).Errorf("获取K线数据失败: %v", err)
+			failed = append(failed, symbol)
+			continue
+		}
+
+		run.fetched(job.Config.Provider.Name, len(bars))
+		if len(bars) == 0 {
+			e.log.WithField("symbol", symbol).Warn("没有获取到K线数据")
+			continue
+		}
+
+		klines := make([]message.KlineData, len(bars))
+		for i, bar := range bars {
+			period := bar.Period
+			if period == "" {
+				period = params.Period
+			}
+			klines[i] = message.KlineData{
+				Symbol:    symbol,
+				Period:    period,
+				Open:      bar.Open,
+				High:      bar.High,
+				Low:       bar.Low,
+				Close:     bar.Close,
+				Volume:    bar.Volume,
+				Turnover:  bar.Turnover,
+				Timestamp: bar.Timestamp.Format(time.RFC3339),
+			}
+		}
+
+		msg := message.NewMessageFormat(e.nodeID, job.Config.Provider.Name, "stock_kline", klines)
+		msg.SetMarketInfo("A-share", e.marketTime.TradingSession())
+		if err := e.publish(ctx, run, msg, len(klines)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		errs = append(errs, fmt.Errorf("%d 个股票的K线获取失败: %v", len(failed), failed))
+	}
+	return errors.Join(errs...)
+}
+
+// publish 把消息发布到任务的每个输出目标，一个目标失败不影响其他目标。
+// 至少一个目标写入成功时计入提供商的发布数量，返回所有失败目标的错误
+func (e *FetcherExecutor) publish(ctx context.Context, run *jobRun, msg *message.MessageFormat, dataCount int) error {
+	if run.traceID != "" {
+		msg.SetCorrelationID(run.traceID)
+	}
+	var errs []error
+	delivered := false
+	for _, output := range run.outputs {
+		if err := output.sink.Publish(ctx, msg); err != nil {
+			e.log.WithField("output", output.name).Errorf("输出失败: %v", err)
+			run.sinkFailed(output.name)
+			errs = append(errs, fmt.Errorf("输出 %s 失败: %w", output.name, err))
+			continue
+		}
+		run.sinkPublished(output.name, dataCount)
+		delivered = true
+	}
+	if delivered {
+		run.published(msg.Metadata.Provider, dataCount)
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭 csv_file 输出使用的存储，刷新缓冲的数据
+func (e *FetcherExecutor) Close() error {
+	e.outputMu.Lock()
+	defer e.outputMu.Unlock()
+
+	var errs []error
+	for dir, csv := range e.csvStorages {
+		if err := csv.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭 CSV 存储 %s 失败: %w", dir, err))
+		}
+	}
+	e.csvStorages = nil
+	return errors.Join(errs...)
 }
 
-// extractSymbols 从任务参数中提取股票符号
-func (e *FetcherExecutor) extractSymbols(params map[string]interface{}) ([]string, error) {
-	symbolsParam, exists := params["symbols"]
+// stdoutWriter 返回 stdout_json 输出的目标
+func (e *FetcherExecutor) stdoutWriter() io.Writer {
+	if e.stdout != nil {
+		return e.stdout
+	}
+	return os.Stdout
+}
+
+// extractSymbols 从任务参数中提取股票符号，实时指数任务读取 index_symbols
+func (e *FetcherExecutor) extractSymbols(config scheduler.JobConfig) ([]string, error) {
+	key := scheduler.SymbolsParam(config)
+	symbolsParam, exists := config.Params[key]
 	if !exists {
-		return nil, fmt.Errorf("参数中缺少 symbols")
+		return nil, fmt.Errorf("参数中缺少 %s", key)
 	}
 
 	e.log.Debugf("提取股票符号参数: %+v", symbolsParam)
@@ -164,7 +512,7 @@ func (e *FetcherExecutor) extractSymbols(params map[string]interface{}) ([]strin
 			if str, ok := symbol.(string); ok {
 				symbols[i] = str
 			} else {
-				return nil, fmt.Errorf("股票符号必须是字符串")
+				return nil, fmt.Errorf("%s 中的代码必须是字符串", key)
 			}
 		}
 		e.log.Debugf("提取的股票符号: %v", symbols)
@@ -173,22 +521,6 @@ func (e *FetcherExecutor) extractSymbols(params map[string]interface{}) ([]strin
 		e.log.Debugf("提取的股票符号: %v", v)
 		return v, nil
 	default:
-		return nil, fmt.Errorf("symbols 参数格式无效")
-	}
-}
-
-// getTradingSession 获取当前交易时段
-func (e *FetcherExecutor) getTradingSession() string {
-	now := time.Now()
-	hour := now.Hour()
-
-	e.log.Debugf("当前时间: %v, 小时: %d", now.Format("15:04:05"), hour)
-
-	if hour >= 9 && hour < 12 {
-		return "morning"
-	} else if hour >= 13 && hour < 15 {
-		return "afternoon"
-	} else {
-		return "closed"
+		return nil, fmt.Errorf("%s 参数格式无效", key)
 	}
 }
@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/logger"
+	"stocksub/pkg/message"
+	"stocksub/pkg/provider"
+	"stocksub/pkg/provider/decorators"
+	"stocksub/pkg/provider/tencent"
+	"stocksub/pkg/scheduler"
+	"stocksub/pkg/timing"
+)
+
+// fakePublisher 记录 XADD 的 stream 和消息
+type fakePublisher struct {
+	streams  []string
+	messages []*message.MessageFormat
+}
+
+func (f *fakePublisher) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	values := a.Values.(map[string]interface{})
+	msg, err := message.FromJSON(values["data"].(string))
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	f.streams = append(f.streams, a.Stream)
+	f.messages = append(f.messages, msg)
+	return redis.NewStringResult("1-0", nil)
+}
+
+// fakeHistoricalProvider 返回固定的K线数据，记录请求参数
+type fakeHistoricalProvider struct {
+	start, end time.Time
+	period     string
+	failFor    string
+}
+
+func (f *fakeHistoricalProvider) Name() string                { return "fake" }
+func (f *fakeHistoricalProvider) GetRateLimit() time.Duration { return 0 }
+func (f *fakeHistoricalProvider) IsHealthy() bool             { return true }
+func (f *fakeHistoricalProvider) GetSupportedPeriods() []string {
+	return []string{"1d"}
+}
+
+func (f *fakeHistoricalProvider) FetchHistoricalData(ctx context.Context, symbol string, start, end time.Time, period string) ([]core.HistoricalData, error) {
+	if symbol == f.failFor {
+		return nil, errors.New("upstream error")
+	}
+	f.start, f.end, f.period = start, end, period
+	return []core.HistoricalData{
+		{Symbol: symbol, Timestamp: time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC), Open: 10, High: 11, Low: 9.5, Close: 10.5, Volume: 1000, Period: period},
+		{Symbol: symbol, Timestamp: time.Date(2025, 8, 19, 0, 0, 0, 0, time.UTC), Open: 10.5, High: 10.8, Low: 10.1, Close: 10.2, Volume: 800, Period: period},
+	}, nil
+}
+
+// fixedClock 返回固定时间的时间服务
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+func newTestExecutor(t *testing.T, hp provider.HistoricalProvider) (*FetcherExecutor, *fakePublisher) {
+	pm := provider.NewProviderManager()
+	require.NoError(t, pm.RegisterHistoricalProvider("fake", hp))
+	publisher := &fakePublisher{}
+	return &FetcherExecutor{
+		providerManager: pm,
+		redisClient:     publisher,
+		nodeID:          "fetcher-test",
+		marketTime:      timing.NewMarketTime(fixedClock(time.Date(2025, 8, 21, 12, 0, 0, 0, time.Local))),
+		log:             logger.WithComponent("fetcher-test"),
+	}, publisher
+}
+
+func historicalJob(params map[string]interface{}) *scheduler.Job {
+	return &scheduler.Job{
+		ID: "kline",
+		Config: scheduler.JobConfig{
+			Name:     "kline",
+			Provider: scheduler.ProviderConfig{Name: "fake", Type: "Historical"},
+			Params:   params,
+		},
+	}
+}
+
+func TestFetcherExecutor_HistoricalPublishesKlines(t *testing.T) {
+	hp := &fakeHistoricalProvider{}
+	executor, publisher := newTestExecutor(t, hp)
+
+	err := executor.Execute(context.Background(), historicalJob(map[string]interface{}{
+		"symbols": []interface{}{"600000", "000001"},
+		"start":   "2025-08-01",
+		"end":     "2025-08-20",
+		"period":  "1d",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "2025-08-01", hp.start.Format("2006-01-02"))
+	assert.Equal(t, "2025-08-20", hp.end.Format("2006-01-02"))
+	assert.Equal(t, "1d", hp.period)
+
+	assert.Equal(t, []string{"stream:stock:kline", "stream:stock:kline"}, publisher.streams)
+	msg := publisher.messages[0]
+	assert.Equal(t, "stock_kline", msg.Metadata.DataType)
+	assert.Equal(t, 2, msg.Metadata.BatchSize)
+	require.NoError(t, msg.Validate(), "checksum survives the JSON round trip")
+
+	payload, ok := msg.Payload.([]interface{})
+	require.True(t, ok)
+	first := payload[0].(map[string]interface{})
+	assert.Equal(t, "600000", first["symbol"])
+	assert.Equal(t, "1d", first["period"])
+	assert.Equal(t, 10.5, first["close"])
+	assert.Equal(t, "2025-08-18T00:00:00Z", first["timestamp"])
+}
+
+func TestFetcherExecutor_SetsCorrelationIDPerRun(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000", "000001"}})
+
+	require.NoError(t, executor.Execute(context.Background(), job))
+	require.NoError(t, executor.Execute(context.Background(), job))
+	require.Len(t, publisher.messages, 4)
+
+	first := publisher.messages[0].Header.CorrelationID
+	assert.NotEmpty(t, first)
+	assert.Equal(t, first, publisher.messages[1].Header.CorrelationID, "同一次执行的消息共用追踪 ID")
+	assert.NotEqual(t, first, publisher.messages[2].Header.CorrelationID, "每次执行生成新的追踪 ID")
+	for _, msg := range publisher.messages {
+		assert.NoError(t, msg.Validate())
+	}
+}
+
+func TestFetcherExecutor_HistoricalPartialFailure(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{failFor: "000001"})
+
+	err := executor.Execute(context.Background(), historicalJob(map[string]interface{}{
+		"symbols": []interface{}{"600000", "000001"},
+	}))
+	assert.Error(t, err)
+	assert.Len(t, publisher.streams, 1, "successful symbols are still published")
+}
+
+func TestFetcherExecutor_HistoricalInvalidParams(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+
+	err := executor.Execute(context.Background(), historicalJob(map[string]interface{}{
+		"symbols": []interface{}{"600000"},
+		"start":   "not-a-date",
+	}))
+	assert.Error(t, err)
+	assert.Empty(t, publisher.streams)
+}
+
+func TestFetcherExecutor_PublishesWithConfiguredEncoding(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000"}})
+	job.Config.Output = scheduler.Outputs{{Type: scheduler.OutputRedisStream, Encoding: message.EncodingGzip}}
+	require.NoError(t, executor.Execute(context.Background(), job))
+
+	require.Len(t, publisher.messages, 1)
+	msg := publisher.messages[0]
+	assert.Equal(t, message.EncodingGzip, msg.Header.Encoding)
+	assert.NoError(t, msg.Validate())
+	assert.Len(t, msg.Payload, 2)
+}
+
+func TestFetcherExecutor_PublishTrimsToStreamMaxLen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	executor, _ := newTestExecutor(t, &fakeHistoricalProvider{})
+	executor.redisClient = client
+	executor.SetStreamLimits([]scheduler.StreamConfig{
+		{Name: "stream:stock:kline", MaxLen: 3},
+		{Name: "stream:index:realtime"}, // 未设置上限
+	})
+
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000", "000001", "000002", "600036", "600519"}})
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Equal(t, int64(3), client.XLen(context.Background(), "stream:stock:kline").Val())
+
+	entries := client.XRange(context.Background(), "stream:stock:kline", "-", "+").Val()
+	msg, err := message.FromJSON(entries[0].Values["data"].(string))
+	require.NoError(t, err)
+	first := msg.Payload.([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "000002", first["symbol"], "裁剪掉最早的条目")
+}
+
+func TestFetcherExecutor_NamespacePublishesToIsolatedStreams(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	test, _ := newTestExecutor(t, &fakeHistoricalProvider{})
+	executor := NewFetcherExecutor(test.providerManager, client, "fetcher-test", "staging", logger.WithComponent("fetcher-test"))
+	executor.marketTime = test.marketTime
+	executor.SetStreamLimits([]scheduler.StreamConfig{{Name: "stream:stock:kline", MaxLen: 2}})
+
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000", "000001", "000002"}})
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Equal(t, int64(2), client.XLen(context.Background(), "stream:staging:stock:kline").Val(), "上限按命名空间下的 Stream 生效")
+	assert.False(t, mr.Exists("stream:stock:kline"), "不写入默认命名空间")
+
+	// 执行统计同样写入命名空间下的键
+	assert.True(t, mr.Exists("staging:stats:jobs"))
+	assert.True(t, mr.Exists("staging:"+scheduler.JobStatsKey("kline", time.Now())))
+	assert.False(t, mr.Exists("stats:jobs"), "不写入默认命名空间的统计")
+	summary, err := scheduler.LoadStatsSummary(context.Background(), client, "staging", time.Now())
+	require.NoError(t, err)
+	assert.Positive(t, summary.Jobs["kline"].LastHour.Published)
+	summary, err = scheduler.LoadStatsSummary(context.Background(), client, "", time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, summary.Jobs)
+}
+
+func TestFetcherExecutor_PublisherBuffersWhileRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	config := message.DefaultPublisherConfig()
+	config.QueueSize = 2
+	config.SpillPath = filepath.Join(t.TempDir(), "spill.jsonl")
+	config.InitialBackoff = 5 * time.Millisecond
+	config.MaxBackoff = 20 * time.Millisecond
+	publisher, err := message.NewPublisher(client, config)
+	require.NoError(t, err)
+
+	executor, _ := newTestExecutor(t, &fakeHistoricalProvider{})
+	executor.redisClient = client
+	executor.SetPublisher(publisher)
+	executor.SetStreamLimits([]scheduler.StreamConfig{{Name: "stream:stock:kline", MaxLen: 10}})
+
+	// Redis 不可用时任务仍然成功，消息留在发布队列和溢出文件中
+	mr.Close()
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000", "000001", "000002", "600036", "600519"}})
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Equal(t, 5, publisher.Stats().QueueDepth+publisher.Stats().SpillDepth)
+
+	require.NoError(t, mr.Restart())
+	require.Eventually(t, func() bool { return publisher.Stats().Published == 5 }, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, publisher.Close(context.Background()))
+
+	entries := client.XRange(context.Background(), "stream:stock:kline", "-", "+").Val()
+	require.Len(t, entries, 5)
+	var symbols []string
+	for _, entry := range entries {
+		msg, err := message.FromJSON(entry.Values["data"].(string))
+		require.NoError(t, err)
+		symbols = append(symbols, msg.Payload.([]interface{})[0].(map[string]interface{})["symbol"].(string))
+	}
+	assert.Equal(t, []string{"600000", "000001", "000002", "600036", "600519"}, symbols)
+}
+
+// fakeIndexProvider 返回固定的指数数据
+type fakeIndexProvider struct{}
+
+func (fakeIndexProvider) Name() string                { return "fake" }
+func (fakeIndexProvider) GetRateLimit() time.Duration { return 0 }
+func (fakeIndexProvider) IsHealthy() bool             { return true }
+func (fakeIndexProvider) IsIndexSupported(s string) bool {
+	return true
+}
+
+func (fakeIndexProvider) FetchIndexData(ctx context.Context, symbols []string) ([]core.IndexData, error) {
+	data := make([]core.IndexData, len(symbols))
+	for i, s := range symbols {
+		data[i] = core.IndexData{Symbol: s, Name: "指数", Value: 3200.5, Change: -1.2, ChangePercent: -0.04, Volume: 1000, Turnover: 2.5e6}
+	}
+	return data, nil
+}
+
+func TestFetcherExecutor_RealtimeIndexPublishesIndexData(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	require.NoError(t, executor.providerManager.RegisterRealtimeIndexProvider("sina", fakeIndexProvider{}))
+
+	err := executor.Execute(context.Background(), &scheduler.Job{
+		ID: "index",
+		Config: scheduler.JobConfig{
+			Name:     "index",
+			Provider: scheduler.ProviderConfig{Name: "sina", Type: "RealtimeIndex"},
+			Params:   map[string]interface{}{"symbols": []interface{}{"sh000001", "sz399001"}},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"stream:index:realtime"}, publisher.streams)
+	msg := publisher.messages[0]
+	assert.Equal(t, "index_realtime", msg.Metadata.DataType)
+	assert.Equal(t, "sina", msg.Metadata.Provider)
+	assert.Equal(t, 2, msg.Metadata.BatchSize)
+	first := msg.Payload.([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, float64(1000), first["volume"])
+	assert.Equal(t, 2.5e6, first["turnover"])
+	assert.Equal(t, timing.SessionLunchBreak, msg.Metadata.TradingSession, "午间休市不应报告为交易时段")
+}
+
+// tencentIndexBody 上证指数的腾讯行情响应
+const tencentIndexBody = `v_sh000001="1~SSE Composite~000001~3200.50~3190.12~3191.00~456789012~0~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~~20250820150002~10.38~0.33~3205.88~3188.21~3200.50/456789012/612345678901~456789012~61234568~0.86~~~3205.88~3188.21~0.55~~~0.00~-1~-1";`
+
+func TestFetcherExecutor_RealtimeIndexMessageMatchesCollector(t *testing.T) {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RawQuery
+		_, _ = io.WriteString(w, tencentIndexBody)
+	}))
+	defer server.Close()
+	client := tencent.NewClient()
+	client.SetBaseURL(server.URL + "/?")
+
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	require.NoError(t, executor.providerManager.RegisterRealtimeIndexProvider("tencent", client))
+
+	err := executor.Execute(context.Background(), &scheduler.Job{
+		ID: "index",
+		Config: scheduler.JobConfig{
+			Name:     "index",
+			Provider: scheduler.ProviderConfig{Name: "tencent", Type: "RealtimeIndex"},
+			Params:   map[string]interface{}{"index_symbols": []interface{}{"000001.SH"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "sh000001", requested)
+
+	require.Equal(t, []string{message.GetStreamName("index_realtime", "")}, publisher.streams)
+	msg := publisher.messages[0]
+	require.NoError(t, msg.Validate())
+	assert.Equal(t, "index_realtime", msg.Metadata.DataType)
+	assert.Equal(t, "tencent", msg.Metadata.Provider)
+	assert.Equal(t, "A-share", msg.Metadata.Market)
+
+	// 与 influxdb_collector.processIndexData 相同：payload 重新编码后解码为 []message.IndexData
+	payload, err := json.Marshal(msg.Payload)
+	require.NoError(t, err)
+	var indices []message.IndexData
+	require.NoError(t, json.Unmarshal(payload, &indices))
+	require.Len(t, indices, 1)
+	index := indices[0]
+	assert.Equal(t, "000001.SH", core.NormalizeSymbol(index.Symbol), "代码保留市场前缀，规范化后是上证指数而不是平安银行")
+	assert.Equal(t, "SSE Composite", index.Name)
+	assert.Equal(t, 3200.5, index.Value)
+	assert.Equal(t, 10.38, index.Change)
+	assert.Equal(t, 0.33, index.ChangePercent)
+	assert.Equal(t, int64(456789012), index.Volume)
+	assert.Equal(t, 612345678901.0, index.Turnover)
+	_, err = time.Parse(time.RFC3339, index.Timestamp)
+	assert.NoError(t, err, "时间戳为 RFC3339")
+}
+
+func TestFetcherExecutor_RealtimeIndexRequiresSymbols(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	require.NoError(t, executor.providerManager.RegisterRealtimeIndexProvider("sina", fakeIndexProvider{}))
+
+	err := executor.Execute(context.Background(), &scheduler.Job{
+		ID: "index",
+		Config: scheduler.JobConfig{
+			Name:     "index",
+			Provider: scheduler.ProviderConfig{Name: "sina", Type: "RealtimeIndex"},
+			Params:   map[string]interface{}{"index_symbols": "sh000001"},
+		},
+	})
+	assert.ErrorContains(t, err, "index_symbols 参数格式无效")
+	assert.Empty(t, publisher.streams)
+}
+
+// fakeStockProvider 返回 symbols 中除 omit 外的实时数据，err 非空时直接失败
+type fakeStockProvider struct {
+	err    error
+	omit   map[string]bool
+	failOn map[string]bool // 请求包含这些代码时返回 503
+	depth  bool            // 返回 5 档买卖盘
+	calls  [][]string
+
+	rawCalls int // FetchStockDataWithRaw 的调用次数
+}
+
+func (f *fakeStockProvider) Name() string                         { return "fake" }
+func (f *fakeStockProvider) GetRateLimit() time.Duration          { return 0 }
+func (f *fakeStockProvider) IsHealthy() bool                      { return true }
+func (f *fakeStockProvider) IsSymbolSupported(symbol string) bool { return true }
+
+func (f *fakeStockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	f.calls = append(f.calls, symbols)
+	if f.err != nil {
+		return nil, f.err
+	}
+	for _, s := range symbols {
+		if f.failOn[s] {
+			return nil, &core.HTTPStatusError{StatusCode: 503}
+		}
+	}
+	var data []core.StockData
+	for _, s := range symbols {
+		if !f.omit[s] {
+			stock := core.StockData{Symbol: s, Name: "股票", Price: 10.5, Timestamp: time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)}
+			if f.depth {
+				stock.BidPrice1, stock.BidVolume1, stock.BidPrice5, stock.BidVolume5 = 10.49, 300, 10.45, 700
+				stock.AskPrice1, stock.AskVolume1, stock.AskPrice5, stock.AskVolume5 = 10.5, 200, 10.54, 900
+			}
+			data = append(data, stock)
+		}
+	}
+	return data, nil
+}
+
+func (f *fakeStockProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	f.rawCalls++
+	data, err := f.FetchStockData(ctx, symbols)
+	return data, fmt.Sprintf("raw:%v", symbols), err
+}
+
+func realtimeJob(provider scheduler.ProviderConfig, symbols ...interface{}) *scheduler.Job {
+	provider.Type = "RealtimeStock"
+	return &scheduler.Job{
+		ID: "realtime",
+		Config: scheduler.JobConfig{
+			Name:     "realtime",
+			Provider: provider,
+			Params:   map[string]interface{}{"symbols": symbols},
+		},
+	}
+}
+
+func TestFetcherExecutor_RealtimeFailsOverToFallback(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	primary := &fakeStockProvider{err: errors.New("tencent down")}
+	fallback := &fakeStockProvider{}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", primary))
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("sina", fallback))
+
+	job := realtimeJob(scheduler.ProviderConfig{Name: "tencent", Fallbacks: []string{"sina"}}, "600000", "000001")
+	require.NoError(t, executor.Execute(context.Background(), job))
+
+	require.Len(t, publisher.messages, 1)
+	assert.Equal(t, "stream:stock:realtime", publisher.streams[0])
+	assert.Equal(t, "sina", publisher.messages[0].Metadata.Provider, "metadata names the provider that served the data")
+	assert.Len(t, publisher.messages[0].Payload, 2)
+
+	// 没有备用提供商时整个任务失败
+	err := executor.Execute(context.Background(), realtimeJob(scheduler.ProviderConfig{Name: "tencent"}, "600000"))
+	assert.Error(t, err)
+	assert.Len(t, publisher.messages, 1)
+}
+
+func TestFetcherExecutor_RealtimePublishesOrderBook(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", &fakeStockProvider{depth: true}))
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("sina", &fakeStockProvider{}))
+
+	require.NoError(t, executor.Execute(context.Background(), realtimeJob(scheduler.ProviderConfig{Name: "tencent"}, "600000")))
+	require.NoError(t, executor.Execute(context.Background(), realtimeJob(scheduler.ProviderConfig{Name: "sina"}, "600000")))
+	require.Len(t, publisher.messages, 2)
+
+	stock := publisher.messages[0].Payload.([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{10.49, 0.0, 0.0, 0.0, 10.45}, stock["bidPrices"])
+	assert.Equal(t, []interface{}{300.0, 0.0, 0.0, 0.0, 700.0}, stock["bidVolumes"])
+	assert.Equal(t, []interface{}{10.5, 0.0, 0.0, 0.0, 10.54}, stock["askPrices"])
+	assert.Equal(t, []interface{}{200.0, 0.0, 0.0, 0.0, 900.0}, stock["askVolumes"])
+
+	assert.NotContains(t, publisher.messages[1].Payload.([]interface{})[0], "bidPrices", "没有盘口数据时不发送空数组")
+}
+
+func TestFetcherExecutor_RealtimeTopUpFromFallback(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	primary := &fakeStockProvider{omit: map[string]bool{"000001": true}}
+	fallback := &fakeStockProvider{}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", primary))
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("sina", fallback))
+
+	job := realtimeJob(scheduler.ProviderConfig{Name: "tencent", Fallbacks: []string{"sina"}, TopUp: true}, "600000", "000001")
+	require.NoError(t, executor.Execute(context.Background(), job))
+
+	require.Len(t, publisher.messages, 2)
+	assert.Equal(t, "tencent", publisher.messages[0].Metadata.Provider)
+	assert.Equal(t, 1, publisher.messages[0].Metadata.BatchSize)
+	assert.Equal(t, "sina", publisher.messages[1].Metadata.Provider)
+	assert.Equal(t, [][]string{{"000001"}}, fallback.calls, "only missing symbols are topped up")
+
+	// 未开启补齐时不请求备用提供商
+	fallback.calls = nil
+	job.Config.Provider.TopUp = false
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Len(t, publisher.messages, 3)
+	assert.Empty(t, fallback.calls)
+}
+
+// TestFetcherExecutor_RealtimePublishesWhilePartitionOpen 按市场分区熔断时，北交所分区打开不影响深市数据发布，
+// 北交所代码由备用提供商补齐
+func TestFetcherExecutor_RealtimePublishesWhilePartitionOpen(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	upstream := &fakeStockProvider{failOn: map[string]bool{"830799": true}}
+	breaker := decorators.NewCircuitBreakerProvider(upstream, &decorators.CircuitBreakerConfig{
+		Name: "tencent", MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, ReadyToTrip: 2, Enabled: true, Partitioned: true,
+	})
+	retry := decorators.NewRetryProvider(breaker, &decorators.RetryConfig{MaxAttempts: 3, Enabled: true})
+	tencent, err := decorators.NewMetricsProvider(retry, &decorators.MetricsConfig{Enabled: true})
+	require.NoError(t, err)
+	fallback := &fakeStockProvider{}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", tencent))
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("sina", fallback))
+
+	job := realtimeJob(scheduler.ProviderConfig{Name: "tencent"}, "000001", "830799")
+	for i := 0; i < 2; i++ {
+		require.NoError(t, executor.Execute(context.Background(), job))
+	}
+	require.Equal(t, gobreaker.StateOpen, breaker.GetPartitionStates()["BJ"])
+	assert.Len(t, upstream.calls, 4, "部分失败不重试整批，每个分区每次只请求一次")
+
+	upstream.calls = nil
+	job.Config.Provider.Fallbacks, job.Config.Provider.TopUp = []string{"sina"}, true
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Equal(t, [][]string{{"000001"}}, upstream.calls, "北交所分区打开时不再请求")
+	assert.Equal(t, [][]string{{"830799"}}, fallback.calls)
+
+	require.Len(t, publisher.messages, 4)
+	for _, msg := range publisher.messages[:3] {
+		assert.Equal(t, "tencent", msg.Metadata.Provider)
+		require.Len(t, msg.Payload, 1)
+		assert.Equal(t, "000001", msg.Payload.([]interface{})[0].(map[string]interface{})["symbol"])
+	}
+	assert.Equal(t, "sina", publisher.messages[3].Metadata.Provider)
+	assert.Equal(t, "830799", publisher.messages[3].Payload.([]interface{})[0].(map[string]interface{})["symbol"])
+
+	totals := tencent.GetMetricsSnapshot().Totals()
+	assert.Equal(t, int64(3), totals.Partial)
+	assert.Zero(t, totals.Errors)
+}
+
+// fakeRawRecorder 记录保存的原始响应采样
+type fakeRawRecorder struct {
+	captures   []scheduler.RawCapture
+	maxEntries []int
+}
+
+func (f *fakeRawRecorder) Record(ctx context.Context, capture scheduler.RawCapture, maxEntries int) error {
+	f.captures = append(f.captures, capture)
+	f.maxEntries = append(f.maxEntries, maxEntries)
+	return nil
+}
+
+func TestFetcherExecutor_CaptureRawOffByDefault(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	raw := &fakeRawRecorder{}
+	executor.raw = raw
+	executor.sample = func() float64 { return 0 }
+	tencent := &fakeStockProvider{}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", tencent))
+
+	require.NoError(t, executor.Execute(context.Background(), realtimeJob(scheduler.ProviderConfig{Name: "tencent"}, "600000")))
+	assert.Len(t, publisher.messages, 1)
+	assert.Zero(t, tencent.rawCalls)
+	assert.Empty(t, raw.captures)
+}
+
+func TestFetcherExecutor_CaptureRawPerServingProvider(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	raw := &fakeRawRecorder{}
+	executor.raw = raw
+	executor.sample = func() float64 { return 0.05 }
+	tencent := &fakeStockProvider{omit: map[string]bool{"000001": true}}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", tencent))
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("sina", &fakeStockProvider{}))
+
+	job := realtimeJob(scheduler.ProviderConfig{Name: "tencent", Fallbacks: []string{"sina"}, TopUp: true}, "600000", "000001")
+	job.Config.Debug = scheduler.DebugConfig{CaptureRaw: true, SampleRate: 0.1, MaxEntries: 20}
+	require.NoError(t, executor.Execute(context.Background(), job))
+
+	require.Len(t, publisher.messages, 2, "采集不影响发布")
+	require.Len(t, raw.captures, 2)
+	assert.Equal(t, "tencent", raw.captures[0].Provider)
+	assert.Equal(t, "raw:[600000 000001]", raw.captures[0].Raw)
+	assert.Equal(t, 1, raw.captures[0].Records)
+	assert.NotEmpty(t, raw.captures[0].Checksum)
+	assert.Equal(t, publisher.messages[0].Header.CorrelationID, raw.captures[0].TraceID)
+	assert.Equal(t, "sina", raw.captures[1].Provider)
+	assert.Equal(t, "raw:[000001]", raw.captures[1].Raw)
+	assert.Equal(t, []int{20, 20}, raw.maxEntries)
+
+	// 随机数不低于采样率时不采集
+	executor.sample = func() float64 { return 0.1 }
+	tencent.rawCalls = 0
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Zero(t, tencent.rawCalls)
+	assert.Len(t, raw.captures, 2)
+}
+
+// fakeStats 记录每次执行上报的统计
+type fakeStats struct {
+	jobs      []string
+	runs      []scheduler.RunStats
+	providers []map[string]scheduler.RunStats
+}
+
+func (f *fakeStats) Record(ctx context.Context, job string, run scheduler.RunStats, providers map[string]scheduler.RunStats) error {
+	f.jobs = append(f.jobs, job)
+	f.runs = append(f.runs, run)
+	f.providers = append(f.providers, providers)
+	return nil
+}
+
+func TestFetcherExecutor_RecordsStatsPerJobAndProvider(t *testing.T) {
+	executor, _ := newTestExecutor(t, &fakeHistoricalProvider{})
+	stats := &fakeStats{}
+	executor.stats = stats
+	primary := &fakeStockProvider{omit: map[string]bool{"000001": true}}
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", primary))
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("sina", &fakeStockProvider{}))
+
+	job := realtimeJob(scheduler.ProviderConfig{Name: "tencent", Fallbacks: []string{"sina"}, TopUp: true}, "600000", "000001", "300750")
+	require.NoError(t, executor.Execute(context.Background(), job))
+
+	require.Equal(t, []string{"realtime"}, stats.jobs)
+	assert.Equal(t, int64(3), stats.runs[0].Fetched)
+	assert.Equal(t, int64(3), stats.runs[0].Published)
+	assert.Zero(t, stats.runs[0].Errors)
+	assert.Equal(t, map[string]scheduler.RunStats{
+		"tencent": {Fetched: 2, Published: 2},
+		"sina":    {Fetched: 1, Published: 1},
+	}, stats.providers[0])
+
+	// 失败的执行计入主提供商的错误数
+	primary.err = errors.New("tencent down")
+	assert.Error(t, executor.Execute(context.Background(), realtimeJob(scheduler.ProviderConfig{Name: "tencent"}, "600000")))
+	require.Len(t, stats.runs, 2)
+	assert.Equal(t, int64(1), stats.runs[1].Errors)
+	assert.Zero(t, stats.runs[1].Published)
+	assert.Equal(t, int64(1), stats.providers[1]["tencent"].Errors)
+}
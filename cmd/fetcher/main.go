@@ -6,12 +6,19 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/health"
 	"stocksub/pkg/logger"
+	"stocksub/pkg/message"
 	"stocksub/pkg/provider"
 	"stocksub/pkg/provider/decorators"
+	"stocksub/pkg/provider/eastmoney"
 	"stocksub/pkg/provider/sina"
 	"stocksub/pkg/provider/tencent"
 	"stocksub/pkg/scheduler"
@@ -20,12 +27,27 @@ import (
 )
 
 var (
-	configPath = flag.String("config", "config/jobs.yaml", "任务配置文件路径")
-	redisAddr  = flag.String("redis", "localhost:6379", "Redis 服务器地址")
-	redisPass  = flag.String("redis-pass", "", "Redis 密码")
-	nodeID     = flag.String("node-id", "", "节点ID（默认自动生成）")
-	logLevel   = flag.String("log-level", "info", "日志级别")
-	logFormat  = flag.String("log-format", "json", "日志格式 (json 或 text)")
+	configPath     = flag.String("config", "config/jobs.yaml", "任务配置文件路径")
+	redisAddr      = flag.String("redis", "localhost:6379", "Redis 服务器地址")
+	redisPass      = flag.String("redis-pass", "", "Redis 密码")
+	nodeID         = flag.String("node-id", "", "节点ID（默认自动生成）")
+	logLevel       = flag.String("log-level", "info", "日志级别")
+	logFormat      = flag.String("log-format", "json", "日志格式 (json 或 text)")
+	statusInterval = flag.Duration("status-interval", time.Minute, "提供商指标状态日志间隔，0 表示关闭")
+	healthPort     = flag.Int("health-port", 8081, "健康检查端口（/healthz、/readyz），0 表示关闭")
+	validateOnly   = flag.Bool("validate", false, "只校验任务配置文件，有问题时以非零状态退出")
+	namespace      = flag.String("namespace", "", "命名空间，非空时发布到 stream:<namespace>:... 以与其他环境隔离，为空时使用默认名称")
+
+	providerCheckInterval = flag.Duration("provider-check-interval", 30*time.Second, "提供商健康检查间隔，0 表示关闭")
+	providerProbeSymbol   = flag.String("provider-probe-symbol", "600000", "健康检查时向实时股票提供商请求的股票代码，为空时只调用 IsHealthy()")
+	providerProbeIndex    = flag.String("provider-probe-index", "sh000001", "健康检查时向实时指数提供商请求的指数代码，为空时只调用 IsHealthy()")
+
+	refreshProvider  = flag.String("refresh-provider", "tencent", "处理 stream:control:fetch 按需刷新请求的实时股票提供商，为空时不处理")
+	refreshFallbacks = flag.String("refresh-fallbacks", "", "按需刷新的备用提供商，逗号分隔")
+
+	publishQueueSize    = flag.Int("publish-queue-size", 10000, "发布队列的内存容量（消息数），超过后写入溢出文件")
+	publishSpill        = flag.String("publish-spill", "data/fetcher-spill.jsonl", "发布队列的溢出文件，Redis 恢复后按顺序重放，为空时队列满后发布失败")
+	publishDrainTimeout = flag.Duration("publish-drain-timeout", 10*time.Second, "关闭时等待发布队列发送完成的时间，未发送的消息写入溢出文件")
 )
 
 func main() {
@@ -39,6 +61,10 @@ func main() {
 
 	log := logger.WithComponent("fetcher")
 
+	if err := message.ValidateNamespace(*namespace); err != nil {
+		log.WithError(err).Fatal("命名空间无效")
+	}
+
 	// 生成节点ID
 	if *nodeID == "" {
 		*nodeID = fmt.Sprintf("fetcher-%d", time.Now().Unix())
@@ -47,24 +73,6 @@ func main() {
 	log.WithField("nodeID", *nodeID).Info("启动 Fetcher")
 	log.Debugf("配置参数: config=%s, redis=%s, logLevel=%s, logFormat=%s", *configPath, *redisAddr, *logLevel, *logFormat)
 
-	// 创建 Redis 客户端
-	log.Debugf("创建 Redis 客户端: %s", *redisAddr)
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     *redisAddr,
-		Password: *redisPass,
-		DB:       0,
-	})
-
-	// 测试 Redis 连接
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Errorf("无法连接到 Redis: %v", err)
-		os.Exit(1)
-	}
-	log.Info("Redis 连接成功")
-
 	// 创建提供商管理器
 	log.Debug("初始化提供商管理器")
 	providerManager := provider.NewProviderManager()
@@ -74,7 +82,7 @@ func main() {
 	tencentProvider := tencent.NewClient()
 
 	// 应用装饰器
-	decoratedProvider, err := decorators.CreateDecoratedProvider(tencentProvider, decorators.DefaultDecoratorConfig())
+	decoratedProvider, err := decorators.CreateDecoratedProvider(tencentProvider, fetcherDecoratorConfig())
 	if err != nil {
 		log.Warnf("应用腾讯提供商装饰器失败: %v，使用原始提供商", err)
 		decoratedProvider = tencentProvider
@@ -91,12 +99,42 @@ func main() {
 		log.Error("装饰后的腾讯提供商未实现 RealtimeStockProvider 接口")
 		os.Exit(1)
 	}
+	// 指数与股票使用同一接口，与新浪相同只以 RealtimeIndexProvider 视图装饰指数能力
+	var decoratedTencentIndexProvider provider.Provider = indexOnly{tencentProvider}
+	if decorated, err := decorators.CreateDecoratedProvider(decoratedTencentIndexProvider, fetcherDecoratorConfig()); err != nil {
+		log.Warnf("应用腾讯指数提供商装饰器失败: %v，使用原始提供商", err)
+	} else {
+		decoratedTencentIndexProvider = decorated
+	}
+	if err := providerManager.RegisterRealtimeIndexProvider("tencent", decoratedTencentIndexProvider.(provider.RealtimeIndexProvider)); err != nil {
+		log.Errorf("注册腾讯指数提供商失败: %v", err)
+		os.Exit(1)
+	}
 	log.Info("腾讯数据提供商注册成功")
 
+	// 注册腾讯历史K线提供商
+	log.Debug("创建腾讯历史K线提供商")
+	tencentKlineProvider := tencent.NewKlineClient()
+	decoratedKlineProvider, err := decorators.CreateDecoratedProvider(tencentKlineProvider, fetcherDecoratorConfig())
+	if err != nil {
+		log.Warnf("应用腾讯历史K线提供商装饰器失败: %v，使用原始提供商", err)
+		decoratedKlineProvider = tencentKlineProvider
+	}
+	if historicalProvider, ok := decoratedKlineProvider.(provider.HistoricalProvider); ok {
+		if err := providerManager.RegisterHistoricalProvider("tencent", historicalProvider); err != nil {
+			log.Errorf("注册腾讯历史K线提供商失败: %v", err)
+			os.Exit(1)
+		}
+	} else {
+		log.Error("装饰后的腾讯历史K线提供商未实现 HistoricalProvider 接口")
+		os.Exit(1)
+	}
+	log.Info("腾讯历史K线提供商注册成功")
+
 	// 注册新浪提供商
 	log.Debug("创建新浪数据提供商")
 	sinaProvider := sina.NewClient()
-	decoratedSinaProvider, err := decorators.CreateDecoratedProvider(sinaProvider, decorators.DefaultDecoratorConfig())
+	decoratedSinaProvider, err := decorators.CreateDecoratedProvider(sinaProvider, fetcherDecoratorConfig())
 	if err != nil {
 		log.Warnf("应用新浪提供商装饰器失败: %v，使用原始提供商", err)
 		decoratedSinaProvider = sinaProvider
@@ -112,16 +150,140 @@ func main() {
 		log.Error("装饰后的新浪提供商未实现 RealtimeStockProvider 接口")
 		os.Exit(1)
 	}
+	// 新浪客户端同时实现了实时股票接口，装饰器链会按实时股票类型处理，
+	// 因此只以 RealtimeIndexProvider 视图装饰指数能力
+	var decoratedSinaIndexProvider provider.Provider = indexOnly{sinaProvider}
+	if decorated, err := decorators.CreateDecoratedProvider(decoratedSinaIndexProvider, fetcherDecoratorConfig()); err != nil {
+		log.Warnf("应用新浪指数提供商装饰器失败: %v，使用原始提供商", err)
+	} else {
+		decoratedSinaIndexProvider = decorated
+	}
+	if err := providerManager.RegisterRealtimeIndexProvider("sina", decoratedSinaIndexProvider.(provider.RealtimeIndexProvider)); err != nil {
+		log.Errorf("注册新浪指数提供商失败: %v", err)
+		os.Exit(1)
+	}
 	log.Info("新浪数据提供商注册成功")
 
+	// 注册东方财富提供商
+	log.Debug("创建东方财富数据提供商")
+	eastmoneyProvider := eastmoney.NewClient()
+	decoratedEastmoneyProvider, err := decorators.CreateDecoratedProvider(eastmoneyProvider, fetcherDecoratorConfig())
+	if err != nil {
+		log.Warnf("应用东方财富提供商装饰器失败: %v，使用原始提供商", err)
+		decoratedEastmoneyProvider = eastmoneyProvider
+	} else {
+		log.Debug("东方财富提供商装饰器应用成功")
+	}
+	if realtimeEastmoneyProvider, ok := decoratedEastmoneyProvider.(provider.RealtimeStockProvider); ok {
+		if err := providerManager.RegisterRealtimeStockProvider("eastmoney", realtimeEastmoneyProvider); err != nil {
+			log.Errorf("注册东方财富提供商失败: %v", err)
+			os.Exit(1)
+		}
+	} else {
+		log.Error("装饰后的东方财富提供商未实现 RealtimeStockProvider 接口")
+		os.Exit(1)
+	}
+	var decoratedEastmoneyIndexProvider provider.Provider = indexOnly{eastmoneyProvider}
+	if decorated, err := decorators.CreateDecoratedProvider(decoratedEastmoneyIndexProvider, fetcherDecoratorConfig()); err != nil {
+		log.Warnf("应用东方财富指数提供商装饰器失败: %v，使用原始提供商", err)
+	} else {
+		decoratedEastmoneyIndexProvider = decorated
+	}
+	if err := providerManager.RegisterRealtimeIndexProvider("eastmoney", decoratedEastmoneyIndexProvider.(provider.RealtimeIndexProvider)); err != nil {
+		log.Errorf("注册东方财富指数提供商失败: %v", err)
+		os.Exit(1)
+	}
+	log.Info("东方财富数据提供商注册成功")
+
+	// 启动前严格校验任务配置，未知字段、无效的调度表达式或提供商会直接退出
+	if err := scheduler.ValidateConfig(*configPath, knownProviders(providerManager)); err != nil {
+		if *validateOnly {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			log.Errorf("任务配置校验失败: %v", err)
+		}
+		os.Exit(1)
+	}
+	if *validateOnly {
+		fmt.Printf("任务配置校验通过: %s\n", *configPath)
+		return
+	}
+
+	// 创建 Redis 客户端
+	log.Debugf("创建 Redis 客户端: %s", *redisAddr)
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     *redisAddr,
+		Password: *redisPass,
+		DB:       0,
+	})
+
+	// 启动健康检查服务，调度器启动前 /readyz 返回 503
+	healthServer := health.NewServer(*healthPort)
+	healthServer.AddReadinessCheck("redis", health.RedisCheck(redisClient))
+	if err := healthServer.Start(); err != nil {
+		log.Errorf("启动健康检查服务失败: %v", err)
+		os.Exit(1)
+	}
+	defer shutdownHealthServer(healthServer)
+
+	// 测试 Redis 连接
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		log.Errorf("无法连接到 Redis: %v", err)
+		os.Exit(1)
+	}
+	log.Info("Redis 连接成功")
+
+	// 定期输出各提供商的请求指标
+	var reporters []decorators.MetricsReporter
+	for _, p := range []provider.Provider{decoratedProvider, decoratedKlineProvider, decoratedSinaProvider, decoratedSinaIndexProvider,
+		decoratedEastmoneyProvider, decoratedEastmoneyIndexProvider} {
+		if reporter := decorators.FindMetricsReporter(p); reporter != nil {
+			reporters = append(reporters, reporter)
+		}
+	}
+	statusCtx, stopStatus := context.WithCancel(context.Background())
+	defer stopStatus()
+	if *statusInterval > 0 {
+		go logProviderMetrics(statusCtx, log, *statusInterval, reporters)
+	}
+
+	// 定期检查提供商健康状态，不健康的提供商在恢复前不再分配给任务
+	if *providerCheckInterval > 0 {
+		healthConfig := provider.DefaultHealthCheckConfig()
+		healthConfig.ProbeSymbol = *providerProbeSymbol
+		healthConfig.ProbeIndexSymbol = *providerProbeIndex
+		providerManager.SetHealthCheckConfig(healthConfig)
+		go logProviderHealthEvents(statusCtx, log, providerManager.HealthEvents())
+		if err := providerManager.StartHealthChecks(*providerCheckInterval); err != nil {
+			log.Errorf("启动提供商健康检查失败: %v", err)
+			os.Exit(1)
+		}
+		defer providerManager.StopHealthChecks()
+	}
+
 	// 创建任务执行器
 	log.Debug("创建任务执行器")
-	executor := NewFetcherExecutor(providerManager, redisClient, *nodeID, log)
+	executor := NewFetcherExecutor(providerManager, redisClient, *nodeID, *namespace, log)
+
+	// Redis 暂时不可用时由发布队列缓冲并重试，不丢弃已获取的数据
+	publisher, err := newPublisher(redisClient)
+	if err != nil {
+		log.Errorf("创建发布队列失败: %v", err)
+		os.Exit(1)
+	}
+	executor.SetPublisher(publisher)
+	if *statusInterval > 0 {
+		go logPublisherStats(statusCtx, log, *statusInterval, publisher)
+	}
 
 	// 创建任务调度器
 	log.Debug("创建任务调度器")
 	jobScheduler := scheduler.NewJobScheduler()
 	jobScheduler.SetExecutor(executor)
+	jobScheduler.SetLocker(namespacedLocker{JobLocker: scheduler.NewRedisJobLocker(redisClient), namespace: *namespace})
 
 	// 加载配置
 	log.Debugf("加载任务配置文件: %s", *configPath)
@@ -130,6 +292,8 @@ func main() {
 		os.Exit(1)
 	}
 
+	executor.SetStreamLimits(jobScheduler.Streams())
+
 	// 启动调度器
 	log.Debug("启动任务调度器")
 	if err := jobScheduler.Start(); err != nil {
@@ -137,6 +301,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 消费 api_server 写入的按需刷新请求
+	stopRefresh := func() {}
+	if *refreshProvider != "" {
+		stopRefresh, err = startRefreshConsumer(statusCtx, redisClient, providerManager, executor, log)
+		if err != nil {
+			log.Errorf("启动按需刷新消费者失败: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	healthServer.AddLivenessCheck("redis", health.RedisCheck(redisClient))
+	healthServer.AddLivenessCheck("scheduler", health.RunningCheck("scheduler", jobScheduler.IsRunning))
+	healthServer.SetReady(true)
+
 	// 打印任务状态
 	jobs := jobScheduler.GetAllJobs()
 	log.Infof("已加载 %d 个任务", len(jobs))
@@ -156,6 +334,10 @@ func main() {
 	<-sigChan
 
 	log.Info("收到停止信号，正在优雅关闭...")
+	healthServer.SetReady(false)
+
+	// 先停止按需刷新，再停止调度器和关闭 Redis 连接
+	stopRefresh()
 
 	// 停止调度器
 	log.Debug("停止任务调度器")
@@ -165,6 +347,21 @@ func main() {
 		log.Debug("任务调度器停止成功")
 	}
 
+	// 刷新 csv_file 输出缓冲的数据
+	if err := executor.Close(); err != nil {
+		log.Errorf("关闭任务输出失败: %v", err)
+	}
+
+	// 等待发布队列发送完成，剩余消息写入溢出文件，下次启动后重放
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), *publishDrainTimeout)
+	if err := publisher.Close(drainCtx); err != nil {
+		log.Errorf("关闭发布队列失败: %v", err)
+	}
+	cancelDrain()
+	if stats := publisher.Stats(); stats.SpillDepth > 0 {
+		log.Warnf("发布队列中 %d 条消息已写入溢出文件 %s，下次启动后重放", stats.SpillDepth, *publishSpill)
+	}
+
 	// 关闭 Redis 连接
 	log.Debug("关闭 Redis 连接")
 	if err := redisClient.Close(); err != nil {
@@ -175,3 +372,190 @@ func main() {
 
 	log.Info("Fetcher 已停止")
 }
+
+// startRefreshConsumer 校验按需刷新的提供商并启动 stream:control:fetch 的消费循环，返回的函数停止消费并等待退出
+func startRefreshConsumer(ctx context.Context, client *redis.Client, m *provider.ProviderManager, executor *FetcherExecutor, log *logger.Entry) (func(), error) {
+	var fallbacks []string
+	for _, name := range strings.Split(*refreshFallbacks, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			fallbacks = append(fallbacks, name)
+		}
+	}
+	if _, err := m.GetRealtimeStockProviderChain(append([]string{*refreshProvider}, fallbacks...)...); err != nil {
+		return nil, err
+	}
+
+	handler := newRefreshHandler(executor, *refreshProvider, fallbacks, log)
+	refreshConsumer := consumer.New(client, consumer.Config{
+		Group:   refreshConsumerGroup,
+		Name:    *nodeID,
+		Streams: []string{message.NamespaceStream(*namespace, message.FetchControlStream)},
+	}, handler.handle, logger.GetLogger())
+	if err := refreshConsumer.CreateGroups(ctx); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		refreshConsumer.Run(ctx)
+	}()
+	log.WithField("provider", *refreshProvider).Info("按需刷新消费者已启动")
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+// knownProviders 将已注册的提供商转换为任务配置校验使用的注册表
+func knownProviders(m *provider.ProviderManager) scheduler.KnownProviders {
+	jobTypes := map[provider.ProviderType]string{
+		provider.TypeRealtimeStock: "RealtimeStock",
+		provider.TypeRealtimeIndex: "RealtimeIndex",
+		provider.TypeHistorical:    "Historical",
+	}
+	known := make(scheduler.KnownProviders)
+	for typ, names := range m.ListProviders() {
+		if jobType, ok := jobTypes[typ]; ok {
+			sort.Strings(names)
+			known[jobType] = names
+		}
+	}
+	// 收盘快照使用实时股票提供商
+	if names, ok := known["RealtimeStock"]; ok {
+		known[eodSnapshotType] = names
+	}
+	return known
+}
+
+// shutdownHealthServer 停止健康检查服务
+func shutdownHealthServer(s *health.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = s.Shutdown(ctx)
+}
+
+// namespacedLocker 给 singleton 任务锁的键加命名空间前缀，不同命名空间的同名任务互不抢锁；默认命名空间键名不变
+type namespacedLocker struct {
+	scheduler.JobLocker
+	namespace string
+}
+
+func (l namespacedLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return l.JobLocker.TryLock(ctx, message.NamespaceKey(l.namespace, key), ttl)
+}
+
+// indexOnly 仅暴露 RealtimeIndexProvider 方法的包装
+type indexOnly struct {
+	provider.RealtimeIndexProvider
+}
+
+// fetcherDecoratorConfig 默认装饰器链，并在最内层附加指标装饰器供状态日志使用
+func fetcherDecoratorConfig() provider.ProviderDecoratorConfig {
+	config := decorators.DefaultDecoratorConfig()
+	config.All = append(config.All, provider.DecoratorConfig{
+		Type:         provider.MetricsType,
+		Enabled:      true,
+		Priority:     0,
+		ProviderType: "all",
+	})
+	return config
+}
+
+// logProviderHealthEvents 记录提供商健康状态变化，并累计各类事件的次数
+func logProviderHealthEvents(ctx context.Context, log *logger.Entry, events <-chan provider.HealthEvent) {
+	counts := make(map[provider.HealthEventType]int)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			counts[event.Type]++
+			entry := log.WithFields(map[string]interface{}{
+				"provider":      event.Name,
+				"providerType":  event.ProviderType,
+				"failureStreak": event.FailureStreak,
+				"count":         counts[event.Type],
+			})
+			if event.Type == provider.EventProviderDown {
+				entry.WithField("error", event.Error).Warn("提供商不健康，暂停分配")
+			} else {
+				entry.Info("提供商已恢复")
+			}
+		}
+	}
+}
+
+// newPublisher 按命令行参数创建发布队列，溢出文件所在目录不存在时创建
+func newPublisher(client *redis.Client) (*message.Publisher, error) {
+	config := message.DefaultPublisherConfig()
+	config.QueueSize = *publishQueueSize
+	config.SpillPath = *publishSpill
+	if config.SpillPath != "" {
+		if err := os.MkdirAll(filepath.Dir(config.SpillPath), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return message.NewPublisher(client, config)
+}
+
+// logPublisherStats 每隔 interval 输出一次发布队列的深度和计数，队列有积压时记为告警
+func logPublisherStats(ctx context.Context, log *logger.Entry, interval time.Duration, publisher *message.Publisher) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := publisher.Stats()
+			entry := log.WithFields(map[string]interface{}{
+				"queueDepth": stats.QueueDepth,
+				"spillDepth": stats.SpillDepth,
+				"published":  stats.Published,
+				"retries":    stats.Retries,
+				"spilled":    stats.Spilled,
+				"replayed":   stats.Replayed,
+				"rejected":   stats.Rejected,
+				"corrupted":  stats.Corrupted,
+			})
+			if stats.SpillDepth > 0 || stats.Rejected > 0 || stats.Corrupted > 0 {
+				entry.Warn("发布队列有积压")
+			} else {
+				entry.Debug("发布队列状态")
+			}
+		}
+	}
+}
+
+// logProviderMetrics 每隔 interval 输出一次各提供商的请求数、错误率和耗时
+func logProviderMetrics(ctx context.Context, log *logger.Entry, interval time.Duration, reporters []decorators.MetricsReporter) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, reporter := range reporters {
+				snapshot := reporter.GetMetricsSnapshot()
+				totals := snapshot.Totals()
+				if totals.Requests == 0 {
+					continue
+				}
+				log.WithFields(map[string]interface{}{
+					"provider":   snapshot.Provider,
+					"requests":   totals.Requests,
+					"errors":     totals.Errors,
+					"partial":    totals.Partial,
+					"errorRate":  fmt.Sprintf("%.2f%%", totals.ErrorRate()*100),
+					"avgLatency": totals.AvgLatency().String(),
+					"maxLatency": totals.MaxLatency.String(),
+				}).Info("提供商指标")
+			}
+		}
+	}
+}
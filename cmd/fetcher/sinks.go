@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/logger"
+	"stocksub/pkg/message"
+	"stocksub/pkg/scheduler"
+	"stocksub/pkg/storage"
+)
+
+// OutputSink 任务的输出目标，每条消息发布到任务配置的所有目标
+type OutputSink interface {
+	Publish(ctx context.Context, msg *message.MessageFormat) error
+}
+
+// namedSink 带名称的输出目标，名称用于统计和日志
+type namedSink struct {
+	name string
+	sink OutputSink
+}
+
+// defaultOutputs 未配置 output 时发布到 Redis Stream
+var defaultOutputs = scheduler.Outputs{{Type: scheduler.OutputRedisStream}}
+
+// sinksFor 按任务配置创建输出目标；创建失败的目标在每次发布时返回创建错误，不影响其他目标
+func (e *FetcherExecutor) sinksFor(job *scheduler.Job) []namedSink {
+	outputs := job.Config.Output
+	if len(outputs) == 0 {
+		outputs = defaultOutputs
+	}
+
+	sinks := make([]namedSink, 0, len(outputs))
+	for _, output := range outputs {
+		sink, err := e.newSink(output)
+		if err != nil {
+			e.log.Errorf("创建输出 %s 失败: %v", output.SinkName(), err)
+			sink = failedSink{err: err}
+		}
+		sinks = append(sinks, namedSink{name: output.SinkName(), sink: sink})
+	}
+	return sinks
+}
+
+func (e *FetcherExecutor) newSink(output scheduler.OutputConfig) (OutputSink, error) {
+	switch output.Type {
+	case "", scheduler.OutputRedisStream:
+		encoding := output.Encoding
+		if encoding == "" {
+			encoding = message.EncodingNone
+		}
+		return &redisStreamSink{client: e.redisClient, queue: e.publisher, maxLen: e.streamMaxLen, namespace: e.namespace, encoding: encoding, log: e.log}, nil
+	case scheduler.OutputCSVFile:
+		csv, err := e.csvStorage(output.Directory)
+		if err != nil {
+			return nil, err
+		}
+		return &csvFileSink{storage: csv}, nil
+	case scheduler.OutputStdoutJSON:
+		return &stdoutJSONSink{w: e.stdoutWriter()}, nil
+	default:
+		return nil, fmt.Errorf("不支持的输出类型: %s", output.Type)
+	}
+}
+
+// csvStorage 返回目录对应的 CSVStorage，同一目录的任务共用一个实例
+func (e *FetcherExecutor) csvStorage(dir string) (*storage.CSVStorage, error) {
+	e.outputMu.Lock()
+	defer e.outputMu.Unlock()
+
+	if csv, ok := e.csvStorages[dir]; ok {
+		return csv, nil
+	}
+	config := storage.DefaultCSVStorageConfig()
+	config.Directory = dir
+	csv, err := storage.NewCSVStorage(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建 CSV 存储 %s 失败: %w", dir, err)
+	}
+	if e.csvStorages == nil {
+		e.csvStorages = make(map[string]*storage.CSVStorage)
+	}
+	e.csvStorages[dir] = csv
+	return csv, nil
+}
+
+// failedSink 创建失败的输出目标
+type failedSink struct {
+	err error
+}
+
+func (s failedSink) Publish(ctx context.Context, msg *message.MessageFormat) error {
+	return s.err
+}
+
+// redisStreamSink 按 encoding 序列化消息，发布到数据类型对应的 Redis Stream；
+// 设置了 queue 时消息交给发布队列，Redis 暂时不可用时由队列缓冲并重试
+type redisStreamSink struct {
+	client    streamPublisher
+	queue     streamQueue
+	maxLen    map[string]int64 // 各 Stream 的 MAXLEN ~ N
+	namespace string
+	encoding  string
+	log       *logger.Entry
+}
+
+func (s *redisStreamSink) Publish(ctx context.Context, msg *message.MessageFormat) error {
+	streamName := message.GetStreamName(msg.Metadata.DataType, s.namespace)
+	if s.queue != nil {
+		if err := s.queue.PublishEncoded(ctx, streamName, msg, s.encoding); err != nil {
+			return fmt.Errorf("加入发布队列失败: %w", err)
+		}
+		s.log.WithFields(map[string]interface{}{
+			"stream":    streamName,
+			"dataCount": msg.Metadata.BatchSize,
+			"encoding":  s.encoding,
+		}).Info("消息已加入发布队列")
+		return nil
+	}
+
+	jsonData, err := msg.ToCompressedJSON(s.encoding)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+
+	s.log.Debugf("发布消息到 Redis Stream: %s", streamName)
+
+	// MAXLEN ~ 由 Redis 按宏节点整块裁剪，实际长度会略高于 N，但开销远小于精确裁剪
+	result := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: streamName,
+		MaxLen: s.maxLen[streamName],
+		Approx: true,
+		Values: map[string]interface{}{
+			"data": jsonData,
+		},
+	})
+	if err := result.Err(); err != nil {
+		return fmt.Errorf("发布消息到 Redis Streams 失败: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"stream":    streamName,
+		"messageID": result.Val(),
+		"dataCount": msg.Metadata.BatchSize,
+		"encoding":  s.encoding,
+	}).Info("消息发布成功")
+	s.log.Debugf("消息内容大小: %d bytes", len(jsonData))
+	return nil
+}
+
+// csvFileSink 把消息写入 CSV 文件：股票行情转换为 StructuredData，其他数据类型按 JSON 记录写入
+type csvFileSink struct {
+	storage *storage.CSVStorage
+}
+
+func (s *csvFileSink) Publish(ctx context.Context, msg *message.MessageFormat) error {
+	records, err := csvRecords(msg)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := s.storage.Save(ctx, record); err != nil {
+			return fmt.Errorf("写入 CSV 失败: %w", err)
+		}
+	}
+	if err := s.storage.Flush(); err != nil {
+		return fmt.Errorf("刷新 CSV 失败: %w", err)
+	}
+	return nil
+}
+
+// csvRecords 把消息的 payload 转换为 CSVStorage 可以保存的记录
+func csvRecords(msg *message.MessageFormat) ([]interface{}, error) {
+	if stocks, ok := msg.Payload.([]message.StockData); ok {
+		records := make([]interface{}, 0, len(stocks))
+		for _, stock := range stocks {
+			sd, err := storage.StockDataToStructuredData(stock.ToCore())
+			if err != nil {
+				return nil, fmt.Errorf("转换股票 %s 失败: %w", stock.Symbol, err)
+			}
+			records = append(records, sd)
+		}
+		return records, nil
+	}
+
+	// 指数和K线没有对应的 StructuredData 模式，以 type 为数据类型的通用记录保存
+	data, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化消息失败: %w", err)
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("消息 payload 不是数组: %w", err)
+	}
+	records := make([]interface{}, len(items))
+	for i, item := range items {
+		item["type"] = msg.Metadata.DataType
+		records[i] = item
+	}
+	return records, nil
+}
+
+// stdoutJSONSink 每条消息一行 JSON
+type stdoutJSONSink struct {
+	w io.Writer
+}
+
+// stdoutMu 多个任务并发输出时保证每行完整
+var stdoutMu sync.Mutex
+
+func (s *stdoutJSONSink) Publish(ctx context.Context, msg *message.MessageFormat) error {
+	data, err := msg.ToJSON()
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	if _, err := io.WriteString(s.w, data+"\n"); err != nil {
+		return fmt.Errorf("写入标准输出失败: %w", err)
+	}
+	return nil
+}
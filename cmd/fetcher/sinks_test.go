@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+	"stocksub/pkg/scheduler"
+)
+
+// failingPublisher 所有 XADD 都失败
+type failingPublisher struct{}
+
+func (failingPublisher) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	return redis.NewStringResult("", errors.New("redis down"))
+}
+
+func TestFetcherExecutor_FailingOutputDoesNotBlockOthers(t *testing.T) {
+	executor, _ := newTestExecutor(t, &fakeHistoricalProvider{})
+	executor.redisClient = failingPublisher{}
+	var stdout bytes.Buffer
+	executor.stdout = &stdout
+	stats := &fakeStats{}
+	executor.stats = stats
+
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000", "000001"}})
+	job.Config.Output = scheduler.Outputs{{Type: scheduler.OutputRedisStream}, {Type: scheduler.OutputStdoutJSON}}
+	err := executor.Execute(context.Background(), job)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "输出 redis_stream 失败")
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 2, "Redis 失败时每个股票仍输出到标准输出")
+	msg, err := message.FromJSON(lines[0])
+	require.NoError(t, err)
+	assert.Equal(t, "stock_kline", msg.Metadata.DataType)
+
+	require.Len(t, stats.runs, 1)
+	assert.Equal(t, int64(4), stats.runs[0].Published, "至少一个输出成功即计入发布数量")
+	assert.Equal(t, map[string]scheduler.SinkStats{
+		"redis_stream": {Errors: 2},
+		"stdout_json":  {Published: 4},
+	}, stats.runs[0].Sinks)
+}
+
+func TestFetcherExecutor_CSVFileOutput(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	require.NoError(t, executor.providerManager.RegisterRealtimeStockProvider("tencent", &fakeStockProvider{depth: true}))
+	dir := t.TempDir()
+	defer executor.Close()
+
+	job := realtimeJob(scheduler.ProviderConfig{Name: "tencent"}, "600000", "000001")
+	job.Config.Output = scheduler.Outputs{
+		{Type: scheduler.OutputRedisStream},
+		{Type: scheduler.OutputCSVFile, Directory: dir},
+	}
+	require.NoError(t, executor.Execute(context.Background(), job))
+	assert.Len(t, publisher.messages, 1)
+
+	kline := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000"}})
+	kline.Config.Output = scheduler.Outputs{{Type: scheduler.OutputCSVFile, Directory: dir}}
+	require.NoError(t, executor.Execute(context.Background(), kline))
+	assert.Len(t, publisher.messages, 1, "只配置 csv_file 时不发布到 Redis")
+
+	stockFiles, err := filepath.Glob(filepath.Join(dir, "*_structured_stock_data*.csv"))
+	require.NoError(t, err)
+	require.Len(t, stockFiles, 1)
+	content, err := os.ReadFile(stockFiles[0])
+	require.NoError(t, err)
+	rows := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, rows, 3, "表头加两行数据")
+	assert.Contains(t, rows[1], "600000")
+	assert.Contains(t, rows[1], "10.49", "盘口数据写入 CSV")
+
+	klineFiles, err := filepath.Glob(filepath.Join(dir, "*_stock_kline*.csv"))
+	require.NoError(t, err)
+	require.Len(t, klineFiles, 1)
+	content, err = os.ReadFile(klineFiles[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "600000")
+}
+
+func TestFetcherExecutor_UncreatableOutputFailsAlone(t *testing.T) {
+	executor, publisher := newTestExecutor(t, &fakeHistoricalProvider{})
+	blocker := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o644))
+
+	job := historicalJob(map[string]interface{}{"symbols": []interface{}{"600000"}})
+	job.Config.Output = scheduler.Outputs{
+		{Type: scheduler.OutputCSVFile, Directory: filepath.Join(blocker, "csv")},
+		{Type: scheduler.OutputRedisStream},
+	}
+	err := executor.Execute(context.Background(), job)
+	assert.ErrorContains(t, err, "输出 csv_file 失败")
+	assert.Len(t, publisher.messages, 1)
+}
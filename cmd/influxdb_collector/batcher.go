@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/sirupsen/logrus"
+)
+
+// WriteConfig InfluxDB 批量写入配置
+type WriteConfig struct {
+	BatchSize          int           `mapstructure:"batch_size"`           // 每批写入的点数
+	FlushInterval      time.Duration `mapstructure:"flush_interval"`       // 未满一批时的定时刷新间隔，也是失败后的重试间隔
+	RetryBufferLimit   int           `mapstructure:"retry_buffer_limit"`   // 写入失败时最多缓存的点数，超出后丢弃最旧的点，所属消息留在 PEL 中重新投递
+	PauseAfterFailures int           `mapstructure:"pause_after_failures"` // 连续失败多少次后暂停消费
+	Timeout            time.Duration `mapstructure:"timeout"`              // 单批写入超时
+}
+
+// pointWriter 阻塞式写入，api.WriteAPIBlocking 满足该接口
+type pointWriter interface {
+	WritePoint(ctx context.Context, point ...*write.Point) error
+}
+
+// pendingMessage 一条消息写入缓冲区的数据点，全部写入成功后才确认消息
+type pendingMessage struct {
+	remaining int  // 尚未写入的点数
+	dropped   bool // 有点因重试缓冲区已满被丢弃，消息不再确认
+	added     int  // 交给 add 的点数，只由处理消息的协程读写
+
+	onWritten func(ctx context.Context) // 全部点写入成功后调用
+	onDropped func()                    // 第一次有点被丢弃时调用
+}
+
+// bufferedPoint 缓冲区中的数据点及其所属的消息，msg 为 nil 时不跟踪确认
+type bufferedPoint struct {
+	point *write.Point
+	msg   *pendingMessage
+}
+
+// pointBatcher 缓存数据点并按批大小或时间间隔写入 InfluxDB
+// 写入失败的点保留在缓冲区中重试；连续失败达到阈值时 paused 返回 true，
+// 消费者据此停止读取新消息，直到某次写入成功。
+// 消息的全部点写入成功后才调用 onWritten 确认，缓冲区满丢弃的点所属的消息调用 onDropped 交还重试。
+type pointBatcher struct {
+	writer  pointWriter
+	config  WriteConfig
+	metrics *collectorMetrics
+	logger  *logrus.Logger
+
+	flushMu  sync.Mutex // 保证同一时间只有一次写入
+	mu       sync.Mutex
+	pending  []bufferedPoint
+	failures int
+}
+
+func newPointBatcher(writer pointWriter, config WriteConfig, metrics *collectorMetrics, logger *logrus.Logger) *pointBatcher {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 5000
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.RetryBufferLimit < config.BatchSize {
+		config.RetryBufferLimit = config.BatchSize * 10
+	}
+	if config.PauseAfterFailures <= 0 {
+		config.PauseAfterFailures = 3
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &pointBatcher{
+		writer:  writer,
+		config:  config,
+		metrics: metrics,
+		logger:  logger,
+	}
+}
+
+// add 缓存属于 msg（可为 nil）的数据点，缓冲区达到一批时同步写入
+func (b *pointBatcher) add(ctx context.Context, msg *pendingMessage, points ...*write.Point) {
+	b.mu.Lock()
+	if msg != nil {
+		msg.remaining += len(points)
+	}
+	for _, point := range points {
+		b.pending = append(b.pending, bufferedPoint{point: point, msg: msg})
+	}
+	dropped := b.trimLocked()
+	b.metrics.pendingPoints.Set(float64(len(b.pending)))
+	full := len(b.pending) >= b.config.BatchSize && b.failures == 0
+	b.mu.Unlock()
+	releaseDropped(dropped)
+
+	if full {
+		_ = b.writeBatches(ctx, true)
+	}
+}
+
+// paused 连续写入失败达到阈值时返回 true
+func (b *pointBatcher) paused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.config.PauseAfterFailures
+}
+
+// run 定时刷新缓冲区，直到 ctx 取消
+func (b *pointBatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = b.flush(ctx)
+		}
+	}
+}
+
+// flush 写入缓冲区中的全部数据点
+func (b *pointBatcher) flush(ctx context.Context) error {
+	return b.writeBatches(ctx, false)
+}
+
+// writeBatches 按批写入缓冲区，fullOnly 时不足一批的点留待定时刷新；遇到失败时停止并保留剩余的点
+func (b *pointBatcher) writeBatches(ctx context.Context, fullOnly bool) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	for {
+		b.mu.Lock()
+		n := len(b.pending)
+		if n == 0 || fullOnly && n < b.config.BatchSize {
+			b.mu.Unlock()
+			return nil
+		}
+		if n > b.config.BatchSize {
+			n = b.config.BatchSize
+		}
+		batch := b.pending[:n:n]
+		b.pending = b.pending[n:]
+		b.mu.Unlock()
+
+		points := make([]*write.Point, len(batch))
+		for i, buffered := range batch {
+			points[i] = buffered.point
+		}
+		writeCtx, cancel := context.WithTimeout(ctx, b.config.Timeout)
+		err := b.writer.WritePoint(writeCtx, points...)
+		cancel()
+
+		b.mu.Lock()
+		if err != nil {
+			b.pending = append(batch, b.pending...)
+			dropped := b.trimLocked()
+			b.failures++
+			failures := b.failures
+			b.metrics.writeErrors.Inc()
+			b.metrics.pendingPoints.Set(float64(len(b.pending)))
+			b.mu.Unlock()
+			releaseDropped(dropped)
+
+			fields := logrus.Fields{"points": n, "consecutive_failures": failures}
+			if failures == b.config.PauseAfterFailures {
+				b.logger.WithError(err).WithFields(fields).Error("InfluxDB writes keep failing, pausing consumption")
+			} else {
+				b.logger.WithError(err).WithFields(fields).Warn("InfluxDB write failed, will retry")
+			}
+			return err
+		}
+
+		if b.failures >= b.config.PauseAfterFailures {
+			b.logger.Info("InfluxDB writes recovered, resuming consumption")
+		}
+		b.failures = 0
+		var written []*pendingMessage
+		for _, buffered := range batch {
+			if msg := buffered.msg; msg != nil {
+				msg.remaining--
+				if msg.remaining == 0 && !msg.dropped {
+					written = append(written, msg)
+				}
+			}
+		}
+		b.metrics.pointsWritten.Add(float64(n))
+		b.metrics.batchesFlushed.Inc()
+		b.metrics.pendingPoints.Set(float64(len(b.pending)))
+		b.mu.Unlock()
+
+		for _, msg := range written {
+			if msg.onWritten != nil {
+				msg.onWritten(ctx)
+			}
+		}
+	}
+}
+
+// trimLocked 超出重试缓冲上限时丢弃最旧的点，返回第一次有点被丢弃的消息，调用方需持有 mu，
+// 释放 mu 后对返回值调用 releaseDropped
+func (b *pointBatcher) trimLocked() []*pendingMessage {
+	overflow := len(b.pending) - b.config.RetryBufferLimit
+	if overflow <= 0 {
+		return nil
+	}
+	var dropped []*pendingMessage
+	for _, buffered := range b.pending[:overflow] {
+		if msg := buffered.msg; msg != nil {
+			msg.remaining--
+			if !msg.dropped {
+				msg.dropped = true
+				dropped = append(dropped, msg)
+			}
+		}
+	}
+	b.pending = append([]bufferedPoint(nil), b.pending[overflow:]...)
+	b.metrics.pointsDropped.Add(float64(overflow))
+	b.logger.WithFields(logrus.Fields{
+		"dropped_points":    overflow,
+		"released_messages": len(dropped),
+	}).Error("InfluxDB retry buffer full, dropping oldest points; their messages stay pending for redelivery")
+	return dropped
+}
+
+// releaseDropped 把有点被丢弃的消息交还重试
+func releaseDropped(dropped []*pendingMessage) {
+	for _, msg := range dropped {
+		if msg.onDropped != nil {
+			msg.onDropped()
+		}
+	}
+}
+
+// close 程序退出前阻塞写入剩余的点
+func (b *pointBatcher) close(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := b.flush(ctx); err != nil {
+		b.mu.Lock()
+		lost := len(b.pending)
+		b.mu.Unlock()
+		b.logger.WithError(err).WithField("unwritten_points", lost).Error("Final InfluxDB flush failed, unwritten messages stay pending for redelivery")
+	}
+}
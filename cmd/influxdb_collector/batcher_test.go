@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePointWriter 记录每批写入的点数和写入的点，err 不为空时写入失败
+type fakePointWriter struct {
+	batches []int
+	points  []*write.Point
+	err     error
+}
+
+func (f *fakePointWriter) WritePoint(ctx context.Context, point ...*write.Point) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.batches = append(f.batches, len(point))
+	f.points = append(f.points, point...)
+	return nil
+}
+
+func newTestBatcher(writer pointWriter, config WriteConfig) *pointBatcher {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	var b *pointBatcher
+	metrics := newCollectorMetrics(func() bool { return b.paused() })
+	b = newPointBatcher(writer, config, metrics, logger)
+	return b
+}
+
+func testPoints(n int) []*write.Point {
+	points := make([]*write.Point, n)
+	for i := range points {
+		points[i] = influxdb2.NewPointWithMeasurement("stock_realtime").
+			AddTag("symbol", "600000").
+			AddField("price", float64(i)).
+			SetTime(time.Unix(int64(i), 0))
+	}
+	return points
+}
+
+func TestPointBatcher_FlushesFullBatches(t *testing.T) {
+	writer := &fakePointWriter{}
+	b := newTestBatcher(writer, WriteConfig{BatchSize: 3})
+	ctx := context.Background()
+
+	b.add(ctx, nil, testPoints(2)...)
+	assert.Empty(t, writer.batches, "partial batch waits for the flush interval")
+
+	b.add(ctx, nil, testPoints(5)...)
+	assert.Equal(t, []int{3, 3}, writer.batches)
+
+	require.NoError(t, b.flush(ctx))
+	assert.Equal(t, []int{3, 3, 1}, writer.batches)
+	assert.Equal(t, float64(7), testutil.ToFloat64(b.metrics.pointsWritten))
+	assert.Equal(t, float64(3), testutil.ToFloat64(b.metrics.batchesFlushed))
+	assert.Equal(t, float64(0), testutil.ToFloat64(b.metrics.pendingPoints))
+}
+
+func TestPointBatcher_PausesAfterRepeatedFailuresAndRecovers(t *testing.T) {
+	writer := &fakePointWriter{err: errors.New("influxdb unavailable")}
+	b := newTestBatcher(writer, WriteConfig{BatchSize: 2, PauseAfterFailures: 3})
+	ctx := context.Background()
+
+	b.add(ctx, nil, testPoints(2)...)
+	assert.False(t, b.paused())
+	assert.Error(t, b.flush(ctx))
+	assert.False(t, b.paused())
+	assert.Error(t, b.flush(ctx))
+	assert.True(t, b.paused(), "consumption pauses after 3 consecutive failures")
+	assert.Equal(t, float64(3), testutil.ToFloat64(b.metrics.writeErrors))
+
+	// 失败期间新到的点只缓存，不会在消费协程中同步写入
+	b.add(ctx, nil, testPoints(2)...)
+	assert.Equal(t, float64(3), testutil.ToFloat64(b.metrics.writeErrors))
+
+	writer.err = nil
+	require.NoError(t, b.flush(ctx))
+	assert.False(t, b.paused())
+	assert.Equal(t, []int{2, 2}, writer.batches, "no points are lost while paused")
+}
+
+func TestPointBatcher_RetryBufferLimitDropsOldest(t *testing.T) {
+	writer := &fakePointWriter{err: errors.New("influxdb unavailable")}
+	b := newTestBatcher(writer, WriteConfig{BatchSize: 2, RetryBufferLimit: 4})
+	ctx := context.Background()
+
+	b.add(ctx, nil, testPoints(2)...)
+	b.add(ctx, nil, testPoints(3)...)
+
+	assert.Len(t, b.pending, 4)
+	assert.Equal(t, float64(1), testutil.ToFloat64(b.metrics.pointsDropped))
+}
+
+func TestPointBatcher_DroppedMessagesAreReleasedNotAcked(t *testing.T) {
+	writer := &fakePointWriter{err: errors.New("influxdb unavailable")}
+	b := newTestBatcher(writer, WriteConfig{BatchSize: 2, RetryBufferLimit: 4})
+	ctx := context.Background()
+
+	var events []string
+	message := func(id string) *pendingMessage {
+		return &pendingMessage{
+			onWritten: func(context.Context) { events = append(events, "ack "+id) },
+			onDropped: func() { events = append(events, "release "+id) },
+		}
+	}
+	b.add(ctx, message("1-0"), testPoints(2)...)
+	b.add(ctx, message("2-0"), testPoints(3)...)
+	assert.Equal(t, []string{"release 1-0"}, events, "被丢弃的点所属的消息交还重试")
+
+	writer.err = nil
+	require.NoError(t, b.flush(ctx))
+	assert.Equal(t, []string{"release 1-0", "ack 2-0"}, events, "部分点被丢弃的消息写入剩余的点后也不确认")
+}
+
+func TestPointBatcher_CloseFlushesRemaining(t *testing.T) {
+	writer := &fakePointWriter{}
+	b := newTestBatcher(writer, WriteConfig{BatchSize: 100})
+
+	b.add(context.Background(), nil, testPoints(5)...)
+	b.close(time.Second)
+
+	assert.Equal(t, []int{5}, writer.batches)
+}
+
+func TestPointBatcher_RunFlushesOnInterval(t *testing.T) {
+	writer := &fakePointWriter{}
+	b := newTestBatcher(writer, WriteConfig{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	b.add(context.Background(), nil, testPoints(1)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(b.metrics.pointsWritten) == 1
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strconv"
+
+	appconfig "stocksub/pkg/config"
+	"stocksub/pkg/message"
+)
+
+// Validate 检查加载后的配置，返回汇总全部问题的错误
+func (c *Config) Validate() error {
+	var v appconfig.Validator
+
+	if err := message.ValidateNamespace(c.Namespace); err != nil {
+		v.Addf("namespace: %v", err)
+	}
+	v.Addr("redis.addr", c.Redis.Addr)
+
+	v.URL("influxdb.url", c.InfluxDB.URL)
+	v.NotEmpty("influxdb.org", c.InfluxDB.Org)
+	v.NotEmpty("influxdb.bucket", c.InfluxDB.Bucket)
+
+	v.Positive("write.batch_size", int64(c.Write.BatchSize))
+	v.PositiveDuration("write.flush_interval", c.Write.FlushInterval)
+	v.NonNegative("write.retry_buffer_limit", int64(c.Write.RetryBufferLimit))
+	v.NonNegative("write.pause_after_failures", int64(c.Write.PauseAfterFailures))
+	v.PositiveDuration("write.timeout", c.Write.Timeout)
+
+	// 地址为空表示不启动 /metrics，端口为 0 表示关闭健康检查
+	if c.Metrics.Addr != "" {
+		v.Addr("metrics.addr", c.Metrics.Addr)
+	}
+	if c.Health.Port != 0 {
+		v.Port("health.port", strconv.Itoa(c.Health.Port))
+	}
+	v.NonNegativeDuration("health.stale_after", c.Health.StaleAfter)
+
+	v.NotEmpty("consumer.group", c.Consumer.Group)
+	v.NotEmpty("consumer.name", c.Consumer.Name)
+	if len(c.Consumer.Streams) == 0 {
+		v.Addf("consumer.streams: must not be empty")
+	}
+	v.NonNegative("consumer.max_retries", int64(c.Consumer.MaxRetries))
+	v.NonNegativeDuration("consumer.retry_backoff", c.Consumer.RetryBackoff)
+	v.NonNegativeDuration("consumer.claim_idle", c.Consumer.ClaimIdle)
+	v.NonNegativeDuration("consumer.drain_timeout", c.Consumer.DrainTimeout)
+
+	v.NotEmpty("dedupe.key_prefix", c.Dedupe.KeyPrefix)
+	v.PositiveDuration("dedupe.ttl", c.Dedupe.TTL)
+
+	if c.Validation.MaxChangePercent < 0 {
+		v.Addf("validation.max_change_percent: must not be negative, got %v", c.Validation.MaxChangePercent)
+	}
+	if c.Validation.RelaxedMaxChangePercent < 0 {
+		v.Addf("validation.relaxed_max_change_percent: must not be negative, got %v", c.Validation.RelaxedMaxChangePercent)
+	}
+	v.NonNegativeDuration("validation.max_future_skew", c.Validation.MaxFutureSkew)
+
+	return v.Err()
+}
+
+// applyNamespace 把命名空间应用到读取的 Stream、死信流、隔离流和去重键，默认命名空间不做修改
+func (c *Config) applyNamespace() {
+	ns := c.Namespace
+	if ns == "" {
+		return
+	}
+	c.Consumer.Streams = message.NamespaceStreams(ns, c.Consumer.Streams)
+	c.Consumer.DeadLetterPrefix = message.NamespaceStream(ns, c.Consumer.DeadLetterPrefix)
+	c.Dedupe.KeyPrefix = message.NamespaceKey(ns, c.Dedupe.KeyPrefix)
+	c.Validation.QuarantineStream = message.NamespaceStream(ns, c.Validation.QuarantineStream)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadTestConfig 从 dir（为空时只用默认值）加载配置，测试结束后重置 viper
+func loadTestConfig(t *testing.T, dir string) *Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if dir != "" {
+		viper.AddConfigPath(dir)
+	}
+	config, err := loadConfig()
+	require.NoError(t, err)
+	return config
+}
+
+func TestConfig_DefaultsAndShippedConfigAreValid(t *testing.T) {
+	assert.NoError(t, loadTestConfig(t, "").Validate())
+	assert.NoError(t, loadTestConfig(t, "../../config").Validate())
+}
+
+func TestConfig_ValidateRejectsInvalidValues(t *testing.T) {
+	for field, mutate := range map[string]func(c *Config){
+		"namespace":                  func(c *Config) { c.Namespace = "-staging" },
+		"redis.addr":                 func(c *Config) { c.Redis.Addr = "localhost:" },
+		"influxdb.url":               func(c *Config) { c.InfluxDB.URL = "" },
+		"influxdb.bucket":            func(c *Config) { c.InfluxDB.Bucket = "" },
+		"write.batch_size":           func(c *Config) { c.Write.BatchSize = 0 },
+		"write.flush_interval":       func(c *Config) { c.Write.FlushInterval = 0 },
+		"write.retry_buffer_limit":   func(c *Config) { c.Write.RetryBufferLimit = -1 },
+		"write.timeout":              func(c *Config) { c.Write.Timeout = -time.Second },
+		"health.port":                func(c *Config) { c.Health.Port = -1 },
+		"consumer.name":              func(c *Config) { c.Consumer.Name = " " },
+		"consumer.drain_timeout":     func(c *Config) { c.Consumer.DrainTimeout = -time.Second },
+		"dedupe.key_prefix":          func(c *Config) { c.Dedupe.KeyPrefix = "" },
+		"validation.max_future_skew": func(c *Config) { c.Validation.MaxFutureSkew = -time.Second },
+	} {
+		config := loadTestConfig(t, "")
+		mutate(config)
+		err := config.Validate()
+		require.Error(t, err, field)
+		assert.Contains(t, err.Error(), field+":", field)
+	}
+}
+
+func TestLoadConfig_FailsOnInvalidEnvOverride(t *testing.T) {
+	t.Setenv("INFLUXDB_COLLECTOR_WRITE_BATCH_SIZE", "0")
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	_, err := loadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "write.batch_size")
+}
+
+func TestConfig_NamespaceIsolatesStreams(t *testing.T) {
+	config := loadTestConfig(t, "")
+	assert.Equal(t, "stream:stock:realtime", config.Consumer.Streams[0])
+	assert.Equal(t, "dedupe:influxdb_collector:", config.Dedupe.KeyPrefix)
+
+	t.Setenv("INFLUXDB_COLLECTOR_NAMESPACE", "staging")
+	config = loadTestConfig(t, "")
+	assert.Equal(t, []string{"stream:staging:stock:realtime", "stream:staging:index:realtime", "stream:staging:stock:kline", "stream:staging:stock:eod"}, config.Consumer.Streams)
+	assert.Equal(t, "stream:staging:deadletter:", config.Consumer.DeadLetterPrefix)
+	assert.Equal(t, "staging:dedupe:influxdb_collector:", config.Dedupe.KeyPrefix)
+	assert.Equal(t, "stream:staging:quarantine", config.Validation.QuarantineStream)
+}
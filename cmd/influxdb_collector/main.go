@@ -3,42 +3,71 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
-	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"stocksub/pkg/backfill"
+	appconfig "stocksub/pkg/config"
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/core"
+	"stocksub/pkg/health"
 	"stocksub/pkg/message"
 )
 
 var (
-	logLevel  = flag.String("log-level", "info", "日志级别 (debug, info, warn, error)")
-	logFormat = flag.String("log-format", "json", "日志格式 (json or text)")
+	logLevel    = flag.String("log-level", "info", "日志级别 (debug, info, warn, error)")
+	logFormat   = flag.String("log-format", "json", "日志格式 (json or text)")
+	checkConfig = flag.Bool("check-config", false, "检查配置并打印生效的配置（隐藏敏感值）后退出")
 )
 
+// messageAcker 确认或交还延迟确认的消息，*consumer.StreamConsumer 满足该接口
+type messageAcker interface {
+	Ack(ctx context.Context, stream string, ids ...string)
+	Release(stream string, ids ...string)
+}
+
 type InfluxDBCollector struct {
-	redisClient     *redis.Client
-	influxClient    influxdb2.Client
-	writeAPI        api.WriteAPI
-	consumerGroup   string
-	consumerName    string
-	streams         []string
-	logger          *logrus.Logger
-	ctx             context.Context
-	cancel          context.CancelFunc
-	processedMsgIDs map[string]bool // 用于幂等处理
+	redisClient  *redis.Client
+	influxClient influxdb2.Client
+	batcher      *pointBatcher
+	metrics      *collectorMetrics
+	metricsSrv   *http.Server
+	consumer     *consumer.StreamConsumer
+	logger       *logrus.Logger
+	ctx          context.Context
+	cancel       context.CancelFunc
+	consumerDone chan struct{}
+	batcherDone  chan struct{}
+	dedupe       message.IdempotencyStore // 用于幂等处理
+	health       *health.Server
+	staleAfter   time.Duration // 消费循环超过该时间没有活动时存活检查失败
+	namespace    string        // 非空时写入的数据点带 namespace 标签
+	acker        messageAcker  // 数据点写入 InfluxDB 后确认消息，通常为 consumer
+
+	validator  *message.TickValidator // 为 nil 时不校验行情
+	quarantine *message.Quarantine    // 未通过校验的行情写入 stream:quarantine
+	lastTicks  *tickHistory
+
+	lastMessageProcessedAt atomic.Int64 // 最近一次成功处理消息的时间（UnixNano）
 }
 
 type Config struct {
+	Namespace string `mapstructure:"namespace"` // 命名空间，与 fetcher 的 --namespace 一致，为空时使用默认名称
+
 	Redis struct {
 		Addr     string `mapstructure:"addr"`
 		Password string `mapstructure:"password"`
@@ -52,11 +81,25 @@ type Config struct {
 		Bucket string `mapstructure:"bucket"`
 	} `mapstructure:"influxdb"`
 
-	Consumer struct {
-		Group   string   `mapstructure:"group"`
-		Name    string   `mapstructure:"name"`
-		Streams []string `mapstructure:"streams"`
-	} `mapstructure:"consumer"`
+	Write WriteConfig `mapstructure:"write"`
+
+	Metrics struct {
+		Addr string `mapstructure:"addr"` // /metrics 监听地址，为空时不启动
+	} `mapstructure:"metrics"`
+
+	Health struct {
+		Port       int           `mapstructure:"port"`        // /healthz、/readyz 端口，0 表示关闭
+		StaleAfter time.Duration `mapstructure:"stale_after"` // 消费循环超过该时间没有活动时存活检查失败，0 表示不检查
+	} `mapstructure:"health"`
+
+	Consumer consumer.Config `mapstructure:"consumer"`
+
+	Dedupe struct {
+		KeyPrefix string        `mapstructure:"key_prefix"`
+		TTL       time.Duration `mapstructure:"ttl"`
+	} `mapstructure:"dedupe"`
+
+	Validation message.ValidationConfig `mapstructure:"validation"`
 }
 
 func main() {
@@ -83,6 +126,12 @@ func main() {
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to load configuration")
 	}
+	if *checkConfig {
+		if err := appconfig.WriteRedacted(os.Stdout, viper.AllSettings()); err != nil {
+			logger.WithError(err).Fatal("Failed to print configuration")
+		}
+		return
+	}
 
 	// Create collector
 	collector, err := NewInfluxDBCollector(config, logger)
@@ -112,6 +161,7 @@ func loadConfig() (*Config, error) {
 	viper.AddConfigPath(".")
 
 	// Set defaults
+	viper.SetDefault("namespace", "")
 	viper.SetDefault("redis.addr", "localhost:6379")
 	viper.SetDefault("redis.password", "")
 	viper.SetDefault("redis.db", 0)
@@ -119,12 +169,36 @@ func loadConfig() (*Config, error) {
 	viper.SetDefault("influxdb.token", "")
 	viper.SetDefault("influxdb.org", "stocksub")
 	viper.SetDefault("influxdb.bucket", "stock_data")
+	viper.SetDefault("write.batch_size", 5000)
+	viper.SetDefault("write.flush_interval", "1s")
+	viper.SetDefault("write.retry_buffer_limit", 50000)
+	viper.SetDefault("write.pause_after_failures", 3)
+	viper.SetDefault("write.timeout", "10s")
+	viper.SetDefault("metrics.addr", ":9101")
+	viper.SetDefault("health.port", 8083)
+	viper.SetDefault("health.stale_after", "60s")
 	viper.SetDefault("consumer.group", "influxdb_collectors")
 	viper.SetDefault("consumer.name", "influxdb_collector_1")
 	viper.SetDefault("consumer.streams", []string{
 		"stream:stock:realtime",
 		"stream:index:realtime",
+		"stream:stock:kline",
+		"stream:stock:eod",
 	})
+	viper.SetDefault("consumer.max_retries", 3)
+	viper.SetDefault("consumer.retry_backoff", "1s")
+	viper.SetDefault("consumer.claim_idle", "5m")
+	viper.SetDefault("consumer.dead_letter_prefix", "stream:deadletter:")
+	viper.SetDefault("consumer.drain_timeout", "10s")
+	viper.SetDefault("dedupe.key_prefix", "dedupe:influxdb_collector:")
+	viper.SetDefault("dedupe.ttl", "24h")
+	validation := message.DefaultValidationConfig()
+	viper.SetDefault("validation.enabled", validation.Enabled)
+	viper.SetDefault("validation.max_change_percent", validation.MaxChangePercent)
+	viper.SetDefault("validation.relaxed_max_change_percent", validation.RelaxedMaxChangePercent)
+	viper.SetDefault("validation.relaxed_patterns", validation.RelaxedPatterns)
+	viper.SetDefault("validation.max_future_skew", validation.MaxFutureSkew)
+	viper.SetDefault("validation.quarantine_stream", validation.QuarantineStream)
 
 	// Environment variable overrides
 	viper.SetEnvPrefix("INFLUXDB_COLLECTOR")
@@ -141,6 +215,10 @@ func loadConfig() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.applyNamespace()
 
 	return &config, nil
 }
@@ -165,54 +243,121 @@ func NewInfluxDBCollector(config *Config, logger *logrus.Logger) (*InfluxDBColle
 	influxClient := influxdb2.NewClient(config.InfluxDB.URL, config.InfluxDB.Token)
 
 	// Test InfluxDB connection
-	health, err := influxClient.Health(ctx)
+	status, err := influxClient.Health(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to InfluxDB: %w", err)
 	}
-	if health.Status != "pass" {
-		return nil, fmt.Errorf("InfluxDB health check failed: %s", health.Status)
+	if status.Status != "pass" {
+		return nil, fmt.Errorf("InfluxDB health check failed: %s", status.Status)
 	}
 
-	// Create write API
-	writeAPI := influxClient.WriteAPI(config.InfluxDB.Org, config.InfluxDB.Bucket)
-
 	ctx, cancel = context.WithCancel(context.Background())
 
-	return &InfluxDBCollector{
-		redisClient:     redisClient,
-		influxClient:    influxClient,
-		writeAPI:        writeAPI,
-		consumerGroup:   config.Consumer.Group,
-		consumerName:    config.Consumer.Name,
-		streams:         config.Consumer.Streams,
-		logger:          logger,
-		ctx:             ctx,
-		cancel:          cancel,
-		processedMsgIDs: make(map[string]bool),
-	}, nil
+	collector := &InfluxDBCollector{
+		redisClient:  redisClient,
+		influxClient: influxClient,
+		logger:       logger,
+		ctx:          ctx,
+		cancel:       cancel,
+		consumerDone: make(chan struct{}),
+		batcherDone:  make(chan struct{}),
+		dedupe:       message.NewRedisIdempotencyStore(redisClient, config.Dedupe.KeyPrefix, config.Dedupe.TTL),
+		health:       health.NewServer(config.Health.Port),
+		staleAfter:   config.Health.StaleAfter,
+		namespace:    config.Namespace,
+	}
+
+	// Create batched blocking write API
+	collector.metrics = newCollectorMetrics(func() bool { return collector.batcher.paused() })
+	writeAPI := influxClient.WriteAPIBlocking(config.InfluxDB.Org, config.InfluxDB.Bucket)
+	collector.batcher = newPointBatcher(writeAPI, config.Write, collector.metrics, logger)
+
+	if config.Validation.Enabled {
+		validator, err := message.NewTickValidator(config.Validation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validation config: %w", err)
+		}
+		collector.validator = validator
+		collector.quarantine = message.NewQuarantine(redisClient, config.Validation.QuarantineStream, "influxdb_collector")
+		collector.lastTicks = newTickHistory()
+	}
+
+	if config.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", collector.metrics.handler())
+		collector.metricsSrv = &http.Server{Addr: config.Metrics.Addr, Handler: mux}
+	}
+
+	collector.consumer = consumer.New(redisClient, config.Consumer, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		err := collector.processMessage(ctx, stream, msg)
+		if err != nil && !errors.Is(err, consumer.ErrAckDeferred) {
+			return err
+		}
+		collector.lastMessageProcessedAt.Store(time.Now().UnixNano())
+		return err
+	}, logger)
+	collector.acker = collector.consumer
+	// InfluxDB 持续写入失败时暂停消费，消息保留在流中
+	collector.consumer.SetPauseCheck(collector.batcher.paused)
+
+	collector.health.AddLivenessCheck("redis", health.RedisCheck(redisClient))
+	if collector.staleAfter > 0 {
+		collector.health.AddLivenessCheck("consumer", health.StalenessCheck(collector.lastActivity, collector.staleAfter))
+	}
+	collector.health.AddReadinessCheck("redis", health.RedisCheck(redisClient))
+	collector.health.AddReadinessCheck("influxdb", func(ctx context.Context) error {
+		status, err := influxClient.Health(ctx)
+		if err != nil {
+			return err
+		}
+		if status.Status != "pass" {
+			return fmt.Errorf("InfluxDB health check failed: %s", status.Status)
+		}
+		return nil
+	})
+
+	return collector, nil
 }
 
 func (c *InfluxDBCollector) Start() error {
 	c.logger.Info("Starting InfluxDB collector...")
 
 	// Create consumer groups for all streams
-	for _, stream := range c.streams {
-		err := c.redisClient.XGroupCreateMkStream(c.ctx, stream, c.consumerGroup, "0").Err()
-		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
-			return fmt.Errorf("failed to create consumer group for stream %s: %w", stream, err)
-		}
+	if err := c.consumer.CreateGroups(c.ctx); err != nil {
+		return err
 	}
 
 	// Start consuming messages
-	go c.consumeMessages()
+	go func() {
+		defer close(c.consumerDone)
+		c.consumer.Run(c.ctx)
+	}()
+
+	// Start periodic flushing of buffered points
+	go func() {
+		defer close(c.batcherDone)
+		c.batcher.run(c.ctx)
+	}()
+
+	if c.metricsSrv != nil {
+		go func() {
+			if err := c.metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				c.logger.WithError(err).Error("Metrics server failed")
+			}
+		}()
+	}
 
-	// Start error handling for write API
-	go c.handleWriteErrors()
+	if err := c.health.Start(); err != nil {
+		return err
+	}
+	c.health.SetReady(true)
 
+	consumerConfig := c.consumer.Config()
 	c.logger.WithFields(logrus.Fields{
-		"consumer_group": c.consumerGroup,
-		"consumer_name":  c.consumerName,
-		"streams":        c.streams,
+		"consumer_group": consumerConfig.Group,
+		"consumer_name":  consumerConfig.Name,
+		"streams":        consumerConfig.Streams,
+		"max_retries":    consumerConfig.MaxRetries,
 	}).Info("InfluxDB collector started successfully")
 
 	return nil
@@ -220,14 +365,36 @@ func (c *InfluxDBCollector) Start() error {
 
 func (c *InfluxDBCollector) Stop() {
 	c.logger.Info("Stopping InfluxDB collector...")
+	c.health.SetReady(false)
 	c.cancel()
 
-	// Flush any remaining writes
-	c.writeAPI.Flush()
+	// Wait for the in-flight batch to be processed and the flush loop to exit,
+	// then flush remaining points synchronously before the client is closed
+	<-c.consumerDone
+	<-c.batcherDone
+	c.batcher.close(c.consumer.Config().DrainTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if c.metricsSrv != nil {
+		_ = c.metricsSrv.Shutdown(ctx)
+	}
+	_ = c.health.Shutdown(ctx)
 
 	c.logger.Info("InfluxDB collector stopped")
 }
 
+// lastActivity 返回消费循环最近一次读取流或成功处理消息的时间，取两者中较晚的一个
+func (c *InfluxDBCollector) lastActivity() time.Time {
+	last := c.consumer.LastReadAt()
+	if nanos := c.lastMessageProcessedAt.Load(); nanos > 0 {
+		if processed := time.Unix(0, nanos); processed.After(last) {
+			last = processed
+		}
+	}
+	return last
+}
+
 func (c *InfluxDBCollector) Close() {
 	if c.redisClient != nil {
 		c.redisClient.Close()
@@ -237,112 +404,118 @@ func (c *InfluxDBCollector) Close() {
 	}
 }
 
-func (c *InfluxDBCollector) consumeMessages() {
-	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		default:
-			// Read messages from all streams
-			streams := make([]string, 0, len(c.streams)*2)
-			for _, stream := range c.streams {
-				streams = append(streams, stream, ">")
-			}
-
-			result, err := c.redisClient.XReadGroup(c.ctx, &redis.XReadGroupArgs{
-				Group:    c.consumerGroup,
-				Consumer: c.consumerName,
-				Streams:  streams,
-				Count:    10,
-				Block:    time.Second,
-			}).Result()
-
-			if err != nil {
-				if err == redis.Nil {
-					continue // No messages available
-				}
-				c.logger.WithError(err).Error("Failed to read messages from Redis streams")
-				time.Sleep(time.Second)
-				continue
-			}
-
-			// Process messages
-			for _, stream := range result {
-				for _, msg := range stream.Messages {
-					if err := c.processMessage(stream.Stream, msg); err != nil {
-						c.logger.WithError(err).WithFields(logrus.Fields{
-							"stream":     stream.Stream,
-							"message_id": msg.ID,
-						}).Error("Failed to process message")
-						continue
-					}
-
-					// Acknowledge message
-					if err := c.redisClient.XAck(c.ctx, stream.Stream, c.consumerGroup, msg.ID).Err(); err != nil {
-						c.logger.WithError(err).WithFields(logrus.Fields{
-							"stream":     stream.Stream,
-							"message_id": msg.ID,
-						}).Error("Failed to acknowledge message")
-					}
-				}
-			}
-		}
+func (c *InfluxDBCollector) processMessage(ctx context.Context, streamName string, msg redis.XMessage) error {
+	// Extract message data
+	data, ok := msg.Values["data"].(string)
+	if !ok {
+		return fmt.Errorf("message data is not a string")
 	}
-}
 
-func (c *InfluxDBCollector) processMessage(streamName string, msg redis.XMessage) error {
-	// 幂等处理：检查消息是否已处理过
-	if c.processedMsgIDs[msg.ID] {
-		c.logger.WithFields(logrus.Fields{
+	// Parse message format, verify version and checksum
+	msgFormat, err := message.ParseMessage([]byte(data))
+	if errors.Is(err, message.ErrUnsupportedVersion) {
+		// 新版本生产者先于消费者上线时，跳过无法解析的消息而不是反复重试
+		c.logger.WithError(err).WithFields(logrus.Fields{
 			"stream":     streamName,
 			"message_id": msg.ID,
-		}).Debug("Message already processed, skipping")
+		}).Warn("Unsupported message version, skipping")
 		return nil
 	}
-
-	// Extract message data
-	data, ok := msg.Values["data"].(string)
-	if !ok {
-		return fmt.Errorf("message data is not a string")
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
 	}
 
-	// Parse message format
-	var msgFormat message.MessageFormat
-	if err := json.Unmarshal([]byte(data), &msgFormat); err != nil {
-		return fmt.Errorf("failed to unmarshal message: %w", err)
+	log := c.messageLogger(msgFormat)
+
+	// 幂等处理：检查消息是否已处理过
+	dedupeKey := msgFormat.DedupeKey()
+	seen, err := c.dedupe.Seen(ctx, dedupeKey)
+	if err != nil {
+		return err
+	}
+	if seen {
+		log.WithFields(logrus.Fields{
+			"stream":     streamName,
+			"message_id": msg.ID,
+			"dedupe_key": dedupeKey,
+		}).Debug("Message already processed, skipping")
+		return nil
 	}
 
-	// Verify checksum
-	if err := msgFormat.Validate(); err != nil {
-		return fmt.Errorf("message checksum verification failed: %w", err)
+	// 数据点写入 InfluxDB 后才标记和确认，写入前崩溃或点被丢弃时消息留在 PEL 中重新投递
+	pending := &pendingMessage{
+		onWritten: func(ctx context.Context) {
+			c.markProcessed(ctx, log, dedupeKey)
+			c.acker.Ack(ctx, streamName, msg.ID)
+		},
+		onDropped: func() { c.acker.Release(streamName, msg.ID) },
 	}
 
 	// Process based on data type
 	var processErr error
 	switch msgFormat.Metadata.DataType {
 	case "stock_realtime":
-		processErr = c.processStockData(&msgFormat)
+		processErr = c.processStockData(ctx, msgFormat, pending)
 	case "index_realtime":
-		processErr = c.processIndexData(&msgFormat)
+		processErr = c.processIndexData(ctx, msgFormat, pending)
+	case "stock_kline":
+		processErr = c.processKlineData(ctx, msgFormat, pending)
+	case "stock_eod":
+		processErr = c.processEODData(ctx, msgFormat, pending)
 	default:
-		c.logger.WithField("data_type", msgFormat.Metadata.DataType).Warn("Unknown data type, skipping")
+		log.WithField("data_type", msgFormat.Metadata.DataType).Warn("Unknown data type, skipping")
 		return nil
 	}
 
-	// 如果处理成功，标记消息为已处理
-	if processErr == nil {
-		c.processedMsgIDs[msg.ID] = true
+	if processErr != nil {
+		return processErr
+	}
+	if pending.added > 0 {
+		return consumer.ErrAckDeferred
+	}
 
-		// 定期清理已处理消息记录（防止内存泄漏）
-		if len(c.processedMsgIDs) > 10000 {
-			go c.cleanupProcessedMessages()
-		}
+	// 没有需要写入的点时直接标记并确认
+	c.markProcessed(ctx, log, dedupeKey)
+	return nil
+}
+
+// markProcessed 标记消息为已处理；标记失败不影响确认，最多导致一次重复处理
+func (c *InfluxDBCollector) markProcessed(ctx context.Context, log *logrus.Entry, dedupeKey string) {
+	if err := c.dedupe.Mark(ctx, dedupeKey); err != nil {
+		log.WithError(err).WithField("dedupe_key", dedupeKey).Warn("Failed to mark message as processed")
+	}
+}
+
+// messageLogger 返回带消息追踪 ID 的日志，处理同一条消息的日志都带 trace_id 字段
+func (c *InfluxDBCollector) messageLogger(msgFormat *message.MessageFormat) *logrus.Entry {
+	return c.logger.WithField("trace_id", msgFormat.Header.CorrelationID)
+}
+
+// tagTrace 给实时行情的数据点加上 trace_id 标签，没有追踪 ID 的旧消息不加
+func tagTrace(point *write.Point, msgFormat *message.MessageFormat) *write.Point {
+	if id := msgFormat.Header.CorrelationID; id != "" {
+		point.AddTag("trace_id", id)
 	}
+	return point
+}
+
+// addPoints 给数据点加上 namespace 标签后交给批量写入；默认命名空间不加标签，序列与未启用命名空间时相同
+func (c *InfluxDBCollector) addPoints(ctx context.Context, pending *pendingMessage, points []*write.Point) {
+	backfill.TagNamespace(c.namespace, points)
+	pending.added += len(points)
+	c.batcher.add(ctx, pending, points...)
+}
 
-	return processErr
+// fieldTrace K线和收盘快照依靠 tag 唯一确定一个点，重复采集时需要覆盖旧值，追踪 ID 写为字段而不是标签
+func fieldTrace(point *write.Point, msgFormat *message.MessageFormat) {
+	if id := msgFormat.Header.CorrelationID; id != "" {
+		point.AddField("trace_id", id)
+	}
 }
 
-func (c *InfluxDBCollector) processStockData(msgFormat *message.MessageFormat) error {
+func (c *InfluxDBCollector) processStockData(ctx context.Context, msgFormat *message.MessageFormat, pending *pendingMessage) error {
+	log := c.messageLogger(msgFormat)
+
 	// First convert payload to JSON bytes
 	payloadBytes, err := json.Marshal(msgFormat.Payload)
 	if err != nil {
@@ -354,30 +527,28 @@ func (c *InfluxDBCollector) processStockData(msgFormat *message.MessageFormat) e
 		return fmt.Errorf("failed to unmarshal stock data: %w", err)
 	}
 
+	// 未通过校验的行情不写入 InfluxDB，转入隔离流
+	stockData, err = c.validateStocks(ctx, log, msgFormat, stockData)
+	if err != nil {
+		return err
+	}
+
 	// Convert to InfluxDB points
+	points := make([]*write.Point, 0, len(stockData))
 	for _, stock := range stockData {
 		// Parse timestamp string to time.Time
 		timestamp, err := time.Parse(time.RFC3339, stock.Timestamp)
 		if err != nil {
-			c.logger.WithError(err).WithField("timestamp", stock.Timestamp).Warn("Failed to parse timestamp, using current time")
+			log.WithError(err).WithField("timestamp", stock.Timestamp).Warn("Failed to parse timestamp, using current time")
 			timestamp = time.Now()
 		}
 
-		point := influxdb2.NewPointWithMeasurement("stock_realtime").
-			AddTag("symbol", stock.Symbol).
-			AddTag("name", stock.Name).
-			AddTag("provider", msgFormat.Metadata.Provider).
-			AddTag("market", msgFormat.Metadata.Market).
-			AddField("price", stock.Price).
-			AddField("change", stock.Change).
-			AddField("change_percent", stock.ChangePercent).
-			AddField("volume", stock.Volume).
-			SetTime(timestamp)
-
-		c.writeAPI.WritePoint(point)
+		point := backfill.StockPoint(stock, msgFormat.Metadata.Provider, msgFormat.Metadata.Market, timestamp)
+		points = append(points, tagTrace(point, msgFormat))
 	}
+	c.addPoints(ctx, pending, points)
 
-	c.logger.WithFields(logrus.Fields{
+	log.WithFields(logrus.Fields{
 		"count":    len(stockData),
 		"provider": msgFormat.Metadata.Provider,
 	}).Debug("Processed stock data points")
@@ -385,7 +556,9 @@ func (c *InfluxDBCollector) processStockData(msgFormat *message.MessageFormat) e
 	return nil
 }
 
-func (c *InfluxDBCollector) processIndexData(msgFormat *message.MessageFormat) error {
+func (c *InfluxDBCollector) processIndexData(ctx context.Context, msgFormat *message.MessageFormat, pending *pendingMessage) error {
+	log := c.messageLogger(msgFormat)
+
 	// First convert payload to JSON bytes
 	payloadBytes, err := json.Marshal(msgFormat.Payload)
 	if err != nil {
@@ -398,28 +571,32 @@ func (c *InfluxDBCollector) processIndexData(msgFormat *message.MessageFormat) e
 	}
 
 	// Convert to InfluxDB points
+	points := make([]*write.Point, 0, len(indexData))
 	for _, index := range indexData {
 		// Parse timestamp string to time.Time
 		timestamp, err := time.Parse(time.RFC3339, index.Timestamp)
 		if err != nil {
-			c.logger.WithError(err).WithField("timestamp", index.Timestamp).Warn("Failed to parse timestamp, using current time")
+			log.WithError(err).WithField("timestamp", index.Timestamp).Warn("Failed to parse timestamp, using current time")
 			timestamp = time.Now()
 		}
 
 		point := influxdb2.NewPointWithMeasurement("index_realtime").
-			AddTag("symbol", index.Symbol).
+			AddTag("symbol", core.NormalizeSymbol(index.Symbol)).
 			AddTag("name", index.Name).
 			AddTag("provider", msgFormat.Metadata.Provider).
 			AddTag("market", msgFormat.Metadata.Market).
 			AddField("value", index.Value).
 			AddField("change", index.Change).
 			AddField("change_percent", index.ChangePercent).
+			AddField("volume", index.Volume).
+			AddField("turnover", index.Turnover).
 			SetTime(timestamp)
 
-		c.writeAPI.WritePoint(point)
+		points = append(points, tagTrace(point, msgFormat))
 	}
+	c.addPoints(ctx, pending, points)
 
-	c.logger.WithFields(logrus.Fields{
+	log.WithFields(logrus.Fields{
 		"count":    len(indexData),
 		"provider": msgFormat.Metadata.Provider,
 	}).Debug("Processed index data points")
@@ -427,38 +604,100 @@ func (c *InfluxDBCollector) processIndexData(msgFormat *message.MessageFormat) e
 	return nil
 }
 
-func (c *InfluxDBCollector) handleWriteErrors() {
-	errorsCh := c.writeAPI.Errors()
-	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		case err := <-errorsCh:
-			c.logger.WithError(err).Error("InfluxDB write error")
+func (c *InfluxDBCollector) processKlineData(ctx context.Context, msgFormat *message.MessageFormat, pending *pendingMessage) error {
+	log := c.messageLogger(msgFormat)
+
+	payloadBytes, err := json.Marshal(msgFormat.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	var klines []message.KlineData
+	if err := json.Unmarshal(payloadBytes, &klines); err != nil {
+		return fmt.Errorf("failed to unmarshal kline data: %w", err)
+	}
+
+	// K线以 symbol+period+时间戳唯一确定，重复采集同一区间时覆盖旧值
+	points := make([]*write.Point, 0, len(klines))
+	for _, kline := range klines {
+		timestamp, err := time.Parse(time.RFC3339, kline.Timestamp)
+		if err != nil {
+			log.WithError(err).WithField("timestamp", kline.Timestamp).Warn("Failed to parse kline timestamp, skipping")
+			continue
 		}
+
+		point := influxdb2.NewPointWithMeasurement("stock_kline").
+			AddTag("symbol", core.NormalizeSymbol(kline.Symbol)).
+			AddTag("period", kline.Period).
+			AddTag("provider", msgFormat.Metadata.Provider).
+			AddField("open", kline.Open).
+			AddField("high", kline.High).
+			AddField("low", kline.Low).
+			AddField("close", kline.Close).
+			AddField("volume", kline.Volume).
+			AddField("turnover", kline.Turnover).
+			SetTime(timestamp)
+		fieldTrace(point, msgFormat)
+
+		points = append(points, point)
 	}
+	c.addPoints(ctx, pending, points)
+
+	log.WithFields(logrus.Fields{
+		"count":    len(points),
+		"provider": msgFormat.Metadata.Provider,
+	}).Debug("Processed kline data points")
+
+	return nil
 }
 
-// cleanupProcessedMessages 定期清理已处理消息记录，防止内存泄漏
-func (c *InfluxDBCollector) cleanupProcessedMessages() {
-	c.logger.Debug("Cleaning up processed messages cache")
-
-	// 保留最近的 5000 条记录，删除其余的
-	if len(c.processedMsgIDs) > 5000 {
-		// 简单的清理策略：清空一半
-		newMap := make(map[string]bool)
-		count := 0
-		for msgID, processed := range c.processedMsgIDs {
-			if count < 5000 {
-				newMap[msgID] = processed
-				count++
-			}
+// processEODData 把收盘快照写入 stock_daily，时间戳为交易日北京时间零点；
+// 同一股票同一交易日的点唯一确定，重复写入时覆盖而不会产生多条记录
+func (c *InfluxDBCollector) processEODData(ctx context.Context, msgFormat *message.MessageFormat, pending *pendingMessage) error {
+	log := c.messageLogger(msgFormat)
+
+	payloadBytes, err := json.Marshal(msgFormat.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	var snapshots []message.EODData
+	if err := json.Unmarshal(payloadBytes, &snapshots); err != nil {
+		return fmt.Errorf("failed to unmarshal eod data: %w", err)
+	}
+
+	points := make([]*write.Point, 0, len(snapshots))
+	for _, eod := range snapshots {
+		date, err := eod.TradingDate()
+		if err != nil {
+			log.WithError(err).WithField("date", eod.Date).Warn("Failed to parse eod date, skipping")
+			continue
 		}
-		c.processedMsgIDs = newMap
 
-		c.logger.WithFields(logrus.Fields{
-			"remaining_count": len(c.processedMsgIDs),
-			"cleaned_count":   len(c.processedMsgIDs) - 5000,
-		}).Info("Processed messages cache cleaned up")
+		point := influxdb2.NewPointWithMeasurement("stock_daily").
+			AddTag("symbol", core.NormalizeSymbol(eod.Symbol)).
+			AddTag("provider", msgFormat.Metadata.Provider).
+			AddField("name", eod.Name).
+			AddField("open", eod.Open).
+			AddField("high", eod.High).
+			AddField("low", eod.Low).
+			AddField("close", eod.Close).
+			AddField("prev_close", eod.PrevClose).
+			AddField("change", eod.Change).
+			AddField("change_percent", eod.ChangePercent).
+			AddField("volume", eod.Volume).
+			AddField("turnover", eod.Turnover).
+			SetTime(date)
+		fieldTrace(point, msgFormat)
+
+		points = append(points, point)
 	}
+	c.addPoints(ctx, pending, points)
+
+	log.WithFields(logrus.Fields{
+		"count":    len(points),
+		"provider": msgFormat.Metadata.Provider,
+	}).Debug("Processed eod data points")
+
+	return nil
 }
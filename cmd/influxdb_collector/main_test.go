@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/health"
+	"stocksub/pkg/message"
+)
+
+// fakeAcker 记录确认和交还的消息 ID
+type fakeAcker struct {
+	acked    []string
+	released []string
+}
+
+func (f *fakeAcker) Ack(ctx context.Context, stream string, ids ...string) {
+	f.acked = append(f.acked, ids...)
+}
+
+func (f *fakeAcker) Release(stream string, ids ...string) {
+	f.released = append(f.released, ids...)
+}
+
+func TestProcessMessage_WritesKlineMeasurement(t *testing.T) {
+	writer := &fakePointWriter{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:  logger,
+		dedupe:  message.NewMemoryIdempotencyStore(time.Hour),
+		acker:   &fakeAcker{},
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_kline", []message.KlineData{
+		{Symbol: "600000", Period: "1d", Open: 10.1, High: 10.4, Low: 10, Close: 10.3, Volume: 123456, Timestamp: "2025-08-18T00:00:00+08:00"},
+		{Symbol: "600000", Period: "1d", Open: 10.3, High: 10.5, Low: 10.1, Close: 10.2, Volume: 98765, Timestamp: "2025-08-19T00:00:00+08:00"},
+	})
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.ErrorIs(t, c.processMessage(context.Background(), "stream:stock:kline", xmsg), consumer.ErrAckDeferred)
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	require.Len(t, writer.points, 2)
+	line := write.PointToLineProtocol(writer.points[0], time.Second)
+	assert.Equal(t, "stock_kline,symbol=600000.SH,period=1d,provider=tencent open=10.1,high=10.4,low=10,close=10.3,volume=123456i,turnover=0 1755446400\n", line)
+
+	// 重复投递的消息不会再次写入
+	require.NoError(t, c.processMessage(context.Background(), "stream:stock:kline", xmsg))
+	require.NoError(t, c.batcher.flush(context.Background()))
+	assert.Len(t, writer.points, 2)
+}
+
+func TestProcessMessage_AcksOnlyAfterInfluxWrite(t *testing.T) {
+	writer := &fakePointWriter{err: errors.New("influxdb unavailable")}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	acker := &fakeAcker{}
+	dedupe := message.NewMemoryIdempotencyStore(time.Hour)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100, RetryBufferLimit: 100}),
+		logger:  logger,
+		dedupe:  dedupe,
+		acker:   acker,
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Volume: 1000, Timestamp: "2025-08-20T10:00:00+08:00"},
+	})
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.ErrorIs(t, c.processMessage(context.Background(), "stream:stock:realtime", xmsg), consumer.ErrAckDeferred)
+
+	// 写入失败时既不确认也不标记，崩溃后消息会重新投递
+	require.Error(t, c.batcher.flush(context.Background()))
+	assert.Empty(t, acker.acked)
+	seen, err := dedupe.Seen(context.Background(), msg.DedupeKey())
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	writer.err = nil
+	require.NoError(t, c.batcher.flush(context.Background()))
+	assert.Equal(t, []string{"1-0"}, acker.acked)
+	seen, err = dedupe.Seen(context.Background(), msg.DedupeKey())
+	require.NoError(t, err)
+	assert.True(t, seen)
+}
+
+func TestProcessMessage_NamespaceTagsPoints(t *testing.T) {
+	writer := &fakePointWriter{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{
+		batcher:   newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:    logger,
+		dedupe:    message.NewMemoryIdempotencyStore(time.Hour),
+		acker:     &fakeAcker{},
+		namespace: "staging",
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_kline", []message.KlineData{
+		{Symbol: "600000", Period: "1d", Open: 10.1, High: 10.4, Low: 10, Close: 10.3, Volume: 123456, Timestamp: "2025-08-18T00:00:00+08:00"},
+	})
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.ErrorIs(t, c.processMessage(context.Background(), "stream:staging:stock:kline", xmsg), consumer.ErrAckDeferred)
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	require.Len(t, writer.points, 1)
+	line := write.PointToLineProtocol(writer.points[0], time.Second)
+	assert.Equal(t, "stock_kline,symbol=600000.SH,period=1d,provider=tencent,namespace=staging open=10.1,high=10.4,low=10,close=10.3,volume=123456i,turnover=0 1755446400\n", line)
+}
+
+func TestProcessMessage_WritesEODToDailyMeasurement(t *testing.T) {
+	writer := &fakePointWriter{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:  logger,
+		dedupe:  message.NewMemoryIdempotencyStore(time.Hour),
+		acker:   &fakeAcker{},
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_eod", []message.EODData{
+		{Symbol: "600000", Name: "浦发银行", Date: "2025-08-20", Open: 10.1, High: 10.6, Low: 10, Close: 10.5, PrevClose: 10.35,
+			Change: 0.15, ChangePercent: 1.45, Volume: 1250000, Turnover: 13125000, Timestamp: "2025-08-20T15:00:03+08:00"},
+		{Symbol: "600036", Date: "not-a-date"},
+	})
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.ErrorIs(t, c.processMessage(context.Background(), "stream:stock:eod", xmsg), consumer.ErrAckDeferred)
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	require.Len(t, writer.points, 1, "交易日无效的快照跳过")
+	line := write.PointToLineProtocol(writer.points[0], time.Second)
+	// 时间戳为 2025-08-20 00:00（北京时间）
+	assert.Equal(t, `stock_daily,symbol=600000.SH,provider=tencent name="浦发银行",open=10.1,high=10.6,low=10,close=10.5,prev_close=10.35,change=0.15,change_percent=1.45,volume=1250000i,turnover=1.3125e+07 1755619200`+"\n", line)
+}
+
+func TestProcessMessage_WritesIndexVolumeAndTurnover(t *testing.T) {
+	writer := &fakePointWriter{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:  logger,
+		dedupe:  message.NewMemoryIdempotencyStore(time.Hour),
+		acker:   &fakeAcker{},
+	}
+
+	msg := message.NewMessageFormat("fetcher", "sina", "index_realtime", []message.IndexData{
+		{Symbol: "sh000001", Name: "上证指数", Value: 3200.5, Change: -1.5, ChangePercent: -0.05, Volume: 250000000, Turnover: 3.2e11, Timestamp: "2025-08-20T10:00:00+08:00"},
+	})
+	msg.SetMarketInfo("A-share", "trading")
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.ErrorIs(t, c.processMessage(context.Background(), "stream:index:realtime", xmsg), consumer.ErrAckDeferred)
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	require.Len(t, writer.points, 1)
+	line := write.PointToLineProtocol(writer.points[0], time.Second)
+	assert.Equal(t, "index_realtime,symbol=000001.SH,name=上证指数,provider=sina,market=A-share value=3200.5,change=-1.5,change_percent=-0.05,volume=250000000i,turnover=3.2e+11 1755655200\n", line)
+}
+
+func TestProcessMessage_WritesOrderBookFields(t *testing.T) {
+	writer := &fakePointWriter{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:  logger,
+		dedupe:  message.NewMemoryIdempotencyStore(time.Hour),
+		acker:   &fakeAcker{},
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{{
+		Symbol: "600000", Name: "浦发银行", Price: 10.5, Volume: 1000, Timestamp: "2025-08-20T10:00:00+08:00",
+		BidPrices:  []float64{10.49, 10.48, 10.47, 10.46, 10.45},
+		BidVolumes: []int64{1, 2, 3, 4, 5},
+		AskPrices:  []float64{10.5, 10.51, 10.52, 10.53, 10.54},
+		AskVolumes: []int64{6, 7, 8, 9, 10},
+	}})
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.ErrorIs(t, c.processMessage(context.Background(), "stream:stock:realtime", xmsg), consumer.ErrAckDeferred)
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	require.Len(t, writer.points, 1)
+	line := write.PointToLineProtocol(writer.points[0], time.Second)
+	assert.Equal(t, "stock_realtime,symbol=600000.SH,name=浦发银行,provider=tencent,market= price=10.5,change=0,change_percent=0,volume=1000i,"+
+		"bid_price1=10.49,bid_price2=10.48,bid_price3=10.47,bid_price4=10.46,bid_price5=10.45,"+
+		"bid_volume1=1i,bid_volume2=2i,bid_volume3=3i,bid_volume4=4i,bid_volume5=5i,"+
+		"ask_price1=10.5,ask_price2=10.51,ask_price3=10.52,ask_price4=10.53,ask_price5=10.54,"+
+		"ask_volume1=6i,ask_volume2=7i,ask_volume3=8i,ask_volume4=9i,ask_volume5=10i 1755655200\n", line)
+}
+
+func TestProcessMessage_SkipsUnsupportedVersion(t *testing.T) {
+	writer := &fakePointWriter{}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:  logger,
+		dedupe:  message.NewMemoryIdempotencyStore(time.Hour),
+		acker:   &fakeAcker{},
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+	msg.Header.Version = "2.0"
+	msg.Checksum = msg.CalculateChecksum()
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.NoError(t, c.processMessage(context.Background(), "stream:stock:realtime", xmsg), "unsupported versions are acked, not retried")
+	require.NoError(t, c.batcher.flush(context.Background()))
+	assert.Empty(t, writer.points)
+}
+
+func TestLastActivity_UsesLatestOfReadAndProcessed(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &InfluxDBCollector{consumer: consumer.New(nil, consumer.Config{}, nil, logger), logger: logger}
+	check := health.StalenessCheck(c.lastActivity, time.Minute)
+
+	assert.Error(t, check(context.Background()), "no activity before the consumer loop runs")
+
+	c.lastMessageProcessedAt.Store(time.Now().Add(-10 * time.Second).UnixNano())
+	assert.NoError(t, check(context.Background()))
+
+	c.lastMessageProcessedAt.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	assert.Error(t, check(context.Background()), "stale consumer fails liveness")
+}
+
+func TestProcessMessage_PropagatesTraceID(t *testing.T) {
+	writer := &fakePointWriter{}
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.DebugLevel)
+	c := &InfluxDBCollector{
+		batcher: newTestBatcher(writer, WriteConfig{BatchSize: 100}),
+		logger:  logger,
+		dedupe:  message.NewMemoryIdempotencyStore(time.Hour),
+		acker:   &fakeAcker{},
+	}
+
+	realtime := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Volume: 1000, Timestamp: "2025-08-20T10:00:00+08:00"},
+	})
+	realtime.SetCorrelationID("trace-abc")
+	kline := message.NewMessageFormat("fetcher", "tencent", "stock_kline", []message.KlineData{
+		{Symbol: "600000", Period: "1d", Close: 10.3, Timestamp: "2025-08-18T00:00:00+08:00"},
+	})
+	kline.SetCorrelationID("trace-abc")
+	for stream, msg := range map[string]*message.MessageFormat{"stream:stock:realtime": realtime, "stream:stock:kline": kline} {
+		data, err := msg.ToJSON()
+		require.NoError(t, err)
+		xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+		require.ErrorIs(t, c.processMessage(context.Background(), stream, xmsg), consumer.ErrAckDeferred)
+	}
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	require.Len(t, writer.points, 2)
+	lines := map[string]string{}
+	for _, point := range writer.points {
+		lines[point.Name()] = write.PointToLineProtocol(point, time.Second)
+	}
+	assert.Contains(t, lines["stock_realtime"], ",trace_id=trace-abc ", "实时行情写为标签")
+	assert.Contains(t, lines["stock_kline"], `,trace_id="trace-abc" `, "K线写为字段，重复采集时仍覆盖同一个点")
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "trace-abc", entry["trace_id"], line)
+	}
+}
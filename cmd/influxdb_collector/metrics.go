@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace Prometheus 指标前缀
+const metricsNamespace = "stocksub_influxdb_collector"
+
+// collectorMetrics influxdb_collector 的写入指标
+type collectorMetrics struct {
+	registry       *prometheus.Registry
+	pointsWritten  prometheus.Counter
+	batchesFlushed prometheus.Counter
+	writeErrors    prometheus.Counter
+	pointsDropped  prometheus.Counter
+	pendingPoints  prometheus.Gauge
+
+	ticksQuarantined *prometheus.CounterVec
+}
+
+// newCollectorMetrics 创建独立的指标注册表，paused 用于导出当前是否处于背压暂停状态
+func newCollectorMetrics(paused func() bool) *collectorMetrics {
+	m := &collectorMetrics{
+		registry: prometheus.NewRegistry(),
+		pointsWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "points_written_total",
+			Help:      "Total number of points written to InfluxDB.",
+		}),
+		batchesFlushed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "batches_flushed_total",
+			Help:      "Total number of batches successfully written to InfluxDB.",
+		}),
+		writeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "write_errors_total",
+			Help:      "Total number of failed InfluxDB batch writes.",
+		}),
+		pointsDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "points_dropped_total",
+			Help:      "Total number of points dropped because the retry buffer was full.",
+		}),
+		pendingPoints: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "pending_points",
+			Help:      "Number of points buffered and not yet written.",
+		}),
+		ticksQuarantined: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "ticks_quarantined_total",
+			Help:      "Total number of ticks that failed validation and were written to the quarantine stream.",
+		}, []string{"rule"}),
+	}
+
+	m.registry.MustRegister(
+		m.pointsWritten,
+		m.batchesFlushed,
+		m.writeErrors,
+		m.pointsDropped,
+		m.pendingPoints,
+		m.ticksQuarantined,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "consumption_paused",
+			Help:      "1 if stream consumption is paused because InfluxDB writes are failing.",
+		}, func() float64 {
+			if paused != nil && paused() {
+				return 1
+			}
+			return 0
+		}),
+	)
+	return m
+}
+
+// handler 返回 /metrics 的 HTTP 处理器
+func (m *collectorMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
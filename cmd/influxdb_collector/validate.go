@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+// tickHistory 记录每个股票最近一次写入的成交量和行情时间，键为规范形式的代码
+type tickHistory struct {
+	mu    sync.Mutex
+	ticks map[string]message.PreviousTick
+}
+
+func newTickHistory() *tickHistory {
+	return &tickHistory{ticks: make(map[string]message.PreviousTick)}
+}
+
+func (h *tickHistory) get(symbol string) *message.PreviousTick {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if tick, ok := h.ticks[symbol]; ok {
+		return &tick
+	}
+	return nil
+}
+
+// set 记录一次写入，行情时间早于已记录的值时忽略（乱序投递的旧消息）
+func (h *tickHistory) set(symbol string, tick message.PreviousTick) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if prev, ok := h.ticks[symbol]; ok && tick.Timestamp.Before(prev.Timestamp) {
+		return
+	}
+	h.ticks[symbol] = tick
+}
+
+// validateStocks 校验一批行情并返回通过校验的行情，未通过的写入隔离流并计数；
+// 成交量与本进程上一次写入的值比较，重启后第一条行情不检查成交量
+func (c *InfluxDBCollector) validateStocks(ctx context.Context, log *logrus.Entry, msgFormat *message.MessageFormat, stockData []message.StockData) ([]message.StockData, error) {
+	if c.validator == nil {
+		return stockData, nil
+	}
+
+	valid := make([]message.StockData, 0, len(stockData))
+	for _, stock := range stockData {
+		symbol := core.NormalizeSymbol(stock.Symbol)
+		timestamp, err := time.Parse(time.RFC3339, stock.Timestamp)
+		if err != nil {
+			timestamp = time.Now()
+		}
+
+		violation := c.validator.Validate(stock, timestamp, c.lastTicks.get(symbol))
+		if violation == nil {
+			c.lastTicks.set(symbol, message.PreviousTick{Volume: stock.Volume, Timestamp: timestamp})
+			valid = append(valid, stock)
+			continue
+		}
+
+		if err := c.quarantine.Add(ctx, msgFormat, stock, *violation); err != nil {
+			return nil, err
+		}
+		c.metrics.ticksQuarantined.WithLabelValues(violation.Rule).Inc()
+		log.WithFields(logrus.Fields{
+			"symbol": symbol,
+			"rule":   violation.Rule,
+			"detail": violation.Detail,
+		}).Warn("Tick failed validation, quarantined")
+	}
+	return valid, nil
+}
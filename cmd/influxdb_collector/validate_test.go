@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/message"
+)
+
+func TestProcessMessage_QuarantinesInvalidTicks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	validator, err := message.NewTickValidator(message.DefaultValidationConfig())
+	require.NoError(t, err)
+	writer := &fakePointWriter{}
+	batcher := newTestBatcher(writer, WriteConfig{BatchSize: 100})
+	c := &InfluxDBCollector{
+		batcher:    batcher,
+		metrics:    batcher.metrics,
+		logger:     logger,
+		dedupe:     message.NewMemoryIdempotencyStore(time.Hour),
+		acker:      &fakeAcker{},
+		validator:  validator,
+		quarantine: message.NewQuarantine(client, "", "influxdb_collector"),
+		lastTicks:  newTickHistory(),
+	}
+
+	now := time.Now()
+	send := func(id string, stocks []message.StockData) {
+		data, err := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", stocks).ToJSON()
+		require.NoError(t, err)
+		require.ErrorIs(t, c.processMessage(context.Background(), "stream:stock:realtime", redis.XMessage{ID: id, Values: map[string]interface{}{"data": data}}), consumer.ErrAckDeferred)
+	}
+	send("1-0", []message.StockData{
+		{Symbol: "000001", Price: 12.3, Volume: 5000, Timestamp: now.Add(-3 * time.Second).Format(time.RFC3339)},
+	})
+	send("2-0", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Volume: 1000, Timestamp: now.Format(time.RFC3339)},
+		{Symbol: "000001", Price: 12.4, Volume: 4000, Timestamp: now.Format(time.RFC3339)},
+		{Symbol: "600036", Price: 35.2, Volume: 2000, Timestamp: now.Add(time.Hour).Format(time.RFC3339)},
+	})
+	require.NoError(t, c.batcher.flush(context.Background()))
+
+	var symbols []string
+	for _, point := range writer.points {
+		line := write.PointToLineProtocol(point, time.Second)
+		symbols = append(symbols, strings.SplitN(strings.SplitN(line, "symbol=", 2)[1], ",", 2)[0])
+	}
+	assert.Equal(t, []string{"000001.SZ", "600000.SH"}, symbols)
+
+	entries, err := client.XRange(context.Background(), message.DefaultQuarantineStream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "000001", entries[0].Values["symbol"])
+	assert.Equal(t, message.RuleVolumeMonotonic, entries[0].Values["rule"])
+	assert.Equal(t, "600036", entries[1].Values["symbol"])
+	assert.Equal(t, message.RuleFutureTimestamp, entries[1].Values["rule"])
+	assert.Equal(t, "influxdb_collector", entries[1].Values["source"])
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.ticksQuarantined.WithLabelValues(message.RuleVolumeMonotonic)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.ticksQuarantined.WithLabelValues(message.RuleFutureTimestamp)))
+}
+
+func TestTickHistory_IgnoresOlderTicks(t *testing.T) {
+	h := newTickHistory()
+	assert.Nil(t, h.get("600000.SH"))
+
+	now := time.Now()
+	h.set("600000.SH", message.PreviousTick{Volume: 2000, Timestamp: now})
+	h.set("600000.SH", message.PreviousTick{Volume: 1000, Timestamp: now.Add(-time.Minute)})
+	assert.Equal(t, &message.PreviousTick{Volume: 2000, Timestamp: now}, h.get("600000.SH"))
+}
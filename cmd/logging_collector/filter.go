@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+// messageFilter 按股票代码和提供商过滤消息，未设置的条件不过滤
+type messageFilter struct {
+	symbols   map[string]struct{} // 规范形式的代码
+	providers map[string]struct{} // 小写的提供商名称
+}
+
+// newMessageFilter 创建过滤器，symbols 支持任意格式的代码（600000、sh600000、600000.SH 等价）
+func newMessageFilter(symbols, providers []string) *messageFilter {
+	f := &messageFilter{}
+	if len(symbols) > 0 {
+		f.symbols = make(map[string]struct{}, len(symbols))
+		for _, symbol := range symbols {
+			f.symbols[core.NormalizeSymbol(symbol)] = struct{}{}
+		}
+	}
+	if len(providers) > 0 {
+		f.providers = make(map[string]struct{}, len(providers))
+		for _, provider := range providers {
+			f.providers[strings.ToLower(provider)] = struct{}{}
+		}
+	}
+	return f
+}
+
+// matchProvider 提供商是否满足过滤条件
+func (f *messageFilter) matchProvider(provider string) bool {
+	if f.providers == nil {
+		return true
+	}
+	_, ok := f.providers[strings.ToLower(provider)]
+	return ok
+}
+
+// matchSymbol 代码是否满足过滤条件
+func (f *messageFilter) matchSymbol(symbol string) bool {
+	if f.symbols == nil {
+		return true
+	}
+	_, ok := f.symbols[core.NormalizeSymbol(symbol)]
+	return ok
+}
+
+// apply 返回过滤后的 payload，整条消息都被过滤掉时返回 false
+// 只有股票和指数数据按代码过滤，其他数据类型原样保留
+func (f *messageFilter) apply(msg *message.MessageFormat) (interface{}, bool) {
+	if !f.matchProvider(msg.Metadata.Provider) {
+		return nil, false
+	}
+
+	switch payload := msg.Payload.(type) {
+	case []message.StockData:
+		var kept []message.StockData
+		for _, stock := range payload {
+			if f.matchSymbol(stock.Symbol) {
+				kept = append(kept, stock)
+			}
+		}
+		return kept, len(kept) > 0
+	case []message.IndexData:
+		var kept []message.IndexData
+		for _, index := range payload {
+			if f.matchSymbol(index.Symbol) {
+				kept = append(kept, index)
+			}
+		}
+		return kept, len(kept) > 0
+	default:
+		return msg.Payload, true
+	}
+}
+
+// splitList 解析逗号分隔的参数，去除空白和空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
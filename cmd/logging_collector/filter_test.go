@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"stocksub/pkg/message"
+)
+
+func TestMessageFilter_Apply(t *testing.T) {
+	stocks := []message.StockData{{Symbol: "600000"}, {Symbol: "000001.SZ"}, {Symbol: "sh600036"}}
+	stockMsg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", stocks)
+	indexMsg := message.NewMessageFormat("fetcher", "sina", "index_realtime", []message.IndexData{{Symbol: "sh000001"}})
+	klineMsg := message.NewMessageFormat("fetcher", "tencent", "stock_kline", []message.KlineData{{Symbol: "600036"}})
+
+	tests := []struct {
+		name      string
+		symbols   []string
+		providers []string
+		msg       *message.MessageFormat
+		want      interface{}
+		ok        bool
+	}{
+		{"不过滤", nil, nil, stockMsg, stocks, true},
+		{"按代码过滤，任意格式等价", []string{"600000.SH", "600036"}, nil, stockMsg,
+			[]message.StockData{{Symbol: "600000"}, {Symbol: "sh600036"}}, true},
+		{"按提供商过滤，不区分大小写", nil, []string{"Tencent"}, stockMsg, stocks, true},
+		{"提供商不匹配", nil, []string{"sina"}, stockMsg, nil, false},
+		{"代码和提供商同时过滤", []string{"000001.SZ"}, []string{"tencent"}, stockMsg,
+			[]message.StockData{{Symbol: "000001.SZ"}}, true},
+		{"没有匹配的代码", []string{"601398"}, nil, stockMsg, []message.StockData(nil), false},
+		{"指数按规范形式匹配", []string{"000001.SH"}, nil, indexMsg, []message.IndexData{{Symbol: "sh000001"}}, true},
+		{"深市 000001 不匹配上证指数", []string{"000001"}, nil, indexMsg, []message.IndexData(nil), false},
+		{"其他数据类型不按代码过滤", []string{"600000"}, nil, klineMsg, klineMsg.Payload, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := newMessageFilter(tt.symbols, tt.providers).apply(tt.msg)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, splitList(" a, ,b ,"))
+	assert.Nil(t, splitList(""))
+	assert.Equal(t, []string{"stream:stock:realtime"}, parseStreams(" , "))
+}
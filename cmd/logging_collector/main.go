@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,19 +24,40 @@ var (
 	consumerGroup = flag.String("consumer-group", "logging-collectors", "消费者组名称")
 	streams       = flag.String("streams", "stream:stock:realtime,stream:index:realtime", "要监听的流名称（逗号分隔）")
 	logLevel      = flag.String("log-level", "info", "日志级别")
+
+	filterSymbols   = flag.String("filter-symbols", "", "只输出这些股票或指数（逗号分隔，600000、sh600000、600000.SH 等价）")
+	filterProviders = flag.String("filter-providers", "", "只输出这些提供商的消息（逗号分隔）")
+
+	rotateMaxSize    = flag.Int64("rotate-max-size", 100, "file/ndjson 输出单个文件的最大 MB，0 表示不按大小轮转")
+	rotateMaxAge     = flag.Duration("rotate-max-age", 24*time.Hour, "file/ndjson 输出单个文件的最长写入时间，0 表示不按时间轮转")
+	rotateMaxBackups = flag.Int("rotate-max-backups", 7, "保留的轮转文件数量，0 表示全部保留")
 )
 
+// outputFlags 可重复的 --output 参数
+type outputFlags []string
+
+func (o *outputFlags) String() string { return strings.Join(*o, ",") }
+
+func (o *outputFlags) Set(value string) error {
+	*o = append(*o, splitList(value)...)
+	return nil
+}
+
 type LoggingCollector struct {
 	redisClient   *redis.Client
 	consumerID    string
 	consumerGroup string
 	streamNames   []string
+	filter        *messageFilter
+	sinks         []sink
 	logger        *logrus.Logger
 	ctx           context.Context
 	cancel        context.CancelFunc
 }
 
 func main() {
+	var outputs outputFlags
+	flag.Var(&outputs, "output", "输出目标，可重复或逗号分隔: stdout、file:<路径>（轮转的日志文件）、ndjson:<路径>（每条消息一行 JSON，可用于回放）")
 	flag.Parse()
 
 	// 设置日志
@@ -45,6 +68,20 @@ func main() {
 	}
 	logger.SetLevel(level)
 
+	if len(outputs) == 0 {
+		outputs = outputFlags{"stdout"}
+	}
+	rotate := RotateConfig{MaxSize: *rotateMaxSize << 20, MaxAge: *rotateMaxAge, MaxBackups: *rotateMaxBackups}
+	var sinks []sink
+	for _, spec := range outputs {
+		out, err := parseOutput(spec, level, rotate)
+		if err != nil {
+			logger.WithError(err).Fatal("无效的输出目标")
+		}
+		sinks = append(sinks, out)
+	}
+	filter := newMessageFilter(splitList(*filterSymbols), splitList(*filterProviders))
+
 	// 生成消费者ID
 	if *consumerID == "" {
 		*consumerID = fmt.Sprintf("logging-collector-%d", time.Now().Unix())
@@ -70,7 +107,7 @@ func main() {
 
 	// 解析流名称
 	streamNames := parseStreams(*streams)
-	logger.Infof("监听流: %v", streamNames)
+	logger.Infof("监听流: %v, 输出: %v", streamNames, outputs)
 
 	// 创建收集器
 	ctx, cancel = context.WithCancel(context.Background())
@@ -79,6 +116,8 @@ func main() {
 		consumerID:    *consumerID,
 		consumerGroup: *consumerGroup,
 		streamNames:   streamNames,
+		filter:        filter,
+		sinks:         sinks,
 		logger:        logger,
 		ctx:           ctx,
 		cancel:        cancel,
@@ -106,6 +145,11 @@ func main() {
 	if err := redisClient.Close(); err != nil {
 		logger.WithError(err).Error("关闭 Redis 连接失败")
 	}
+	for _, out := range sinks {
+		if err := out.Close(); err != nil {
+			logger.WithError(err).Error("关闭输出失败")
+		}
+	}
 
 	logger.Info("Logging Collector 已停止")
 }
@@ -192,53 +236,29 @@ func (c *LoggingCollector) processMessage(streamName string, msg redis.XMessage)
 		return
 	}
 
-	// 解析消息
-	messageFormat, err := message.FromJSON(dataJSON)
+	// 解析消息，校验版本和完整性
+	messageFormat, err := message.ParseMessage([]byte(dataJSON))
+	if errors.Is(err, message.ErrUnsupportedVersion) {
+		logger.WithError(err).Warn("不支持的消息版本，跳过")
+		c.ackMessage(streamName, msg.ID)
+		return
+	}
 	if err != nil {
 		logger.WithError(err).Error("解析消息失败")
 		c.ackMessage(streamName, msg.ID)
 		return
 	}
 
-	// 验证消息完整性
-	if err := messageFormat.Validate(); err != nil {
-		logger.WithError(err).Error("消息校验失败")
+	payload, ok := c.filter.apply(messageFormat)
+	if !ok {
 		c.ackMessage(streamName, msg.ID)
 		return
 	}
 
-	// 打印消息信息
-	logger.WithFields(logrus.Fields{
-		"producer":  messageFormat.Header.Producer,
-		"provider":  messageFormat.Metadata.Provider,
-		"dataType":  messageFormat.Metadata.DataType,
-		"batchSize": messageFormat.Metadata.BatchSize,
-		"market":    messageFormat.Metadata.Market,
-		"session":   messageFormat.Metadata.TradingSession,
-		"timestamp": time.Unix(messageFormat.Header.Timestamp, 0).Format(time.RFC3339),
-	}).Info("收到消息")
-
-	// 打印股票数据详情
-	if stockDataList, ok := messageFormat.Payload.([]interface{}); ok {
-		for i, stockDataInterface := range stockDataList {
-			if stockDataMap, ok := stockDataInterface.(map[string]interface{}); ok {
-				symbol, _ := stockDataMap["symbol"].(string)
-				name, _ := stockDataMap["name"].(string)
-				price, _ := stockDataMap["price"].(float64)
-				change, _ := stockDataMap["change"].(float64)
-				changePercent, _ := stockDataMap["changePercent"].(float64)
-				volume, _ := stockDataMap["volume"].(float64)
-
-				logger.WithFields(logrus.Fields{
-					"index":         i + 1,
-					"symbol":        symbol,
-					"name":          name,
-					"price":         price,
-					"change":        change,
-					"changePercent": changePercent,
-					"volume":        int64(volume),
-				}).Info("股票数据")
-			}
+	rec := &record{Stream: streamName, MessageID: msg.ID, Message: messageFormat, Payload: payload}
+	for _, out := range c.sinks {
+		if err := out.Write(rec); err != nil {
+			logger.WithError(err).Error("写入输出失败")
 		}
 	}
 
@@ -259,55 +279,8 @@ func (c *LoggingCollector) ackMessage(streamName, messageID string) {
 
 // parseStreams 解析流名称字符串
 func parseStreams(streamsStr string) []string {
-	if streamsStr == "" {
-		return []string{"stream:stock:realtime"}
+	if streams := splitList(streamsStr); len(streams) > 0 {
+		return streams
 	}
-
-	var streams []string
-	for _, stream := range splitString(streamsStr, ",") {
-		if trimmed := trimSpace(stream); trimmed != "" {
-			streams = append(streams, trimmed)
-		}
-	}
-
-	if len(streams) == 0 {
-		return []string{"stream:stock:realtime"}
-	}
-
-	return streams
-}
-
-// splitString 分割字符串
-func splitString(s, sep string) []string {
-	if s == "" {
-		return nil
-	}
-
-	var result []string
-	start := 0
-	for i := 0; i < len(s); i++ {
-		if i+len(sep) <= len(s) && s[i:i+len(sep)] == sep {
-			result = append(result, s[start:i])
-			start = i + len(sep)
-			i += len(sep) - 1
-		}
-	}
-	result = append(result, s[start:])
-	return result
-}
-
-// trimSpace 去除字符串两端空格
-func trimSpace(s string) string {
-	start := 0
-	end := len(s)
-
-	for start < end && (s[start] == ' ' || s[start] == '\t' || s[start] == '\n' || s[start] == '\r') {
-		start++
-	}
-
-	for end > start && (s[end-1] == ' ' || s[end-1] == '\t' || s[end-1] == '\n' || s[end-1] == '\r') {
-		end--
-	}
-
-	return s[start:end]
+	return []string{"stream:stock:realtime"}
 }
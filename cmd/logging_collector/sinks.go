@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/message"
+)
+
+// record 通过过滤的一条消息，Payload 为过滤后的数据
+type record struct {
+	Stream    string
+	MessageID string
+	Message   *message.MessageFormat
+	Payload   interface{}
+}
+
+// sink 消息输出目标
+type sink interface {
+	Write(rec *record) error
+	Close() error
+}
+
+// RotateConfig 文件输出的轮转配置
+type RotateConfig struct {
+	MaxSize    int64         // 单个文件的最大字节数，0 表示不按大小轮转
+	MaxAge     time.Duration // 单个文件的最长写入时间，0 表示不按时间轮转
+	MaxBackups int           // 保留的轮转文件数量，0 表示全部保留
+}
+
+// parseOutput 解析 --output 参数：stdout、file:<路径>（轮转的日志文件）或 ndjson:<路径>（每条消息一行 JSON）
+func parseOutput(spec string, level logrus.Level, rotate RotateConfig) (sink, error) {
+	kind, path, _ := strings.Cut(spec, ":")
+	if kind != "stdout" && path == "" {
+		return nil, fmt.Errorf("output %q requires a path, e.g. %s:/var/log/stocksub.log", spec, kind)
+	}
+
+	switch kind {
+	case "stdout":
+		return newLogSink(os.Stdout, level), nil
+	case "file":
+		file, err := newRotatingFile(path, rotate)
+		if err != nil {
+			return nil, err
+		}
+		return newLogSink(file, level), nil
+	case "ndjson":
+		file, err := newRotatingFile(path, rotate)
+		if err != nil {
+			return nil, err
+		}
+		return newNDJSONSink(file), nil
+	default:
+		return nil, fmt.Errorf("unknown output %q, expected stdout, file:<path> or ndjson:<path>", spec)
+	}
+}
+
+// logSink 以日志形式输出消息摘要及每条股票、指数数据
+type logSink struct {
+	logger *logrus.Logger
+	out    io.Writer
+}
+
+func newLogSink(out io.Writer, level logrus.Level) *logSink {
+	logger := logrus.New()
+	logger.SetOutput(out)
+	logger.SetLevel(level)
+	return &logSink{logger: logger, out: out}
+}
+
+func (s *logSink) Write(rec *record) error {
+	msg := rec.Message
+	logger := s.logger.WithFields(logrus.Fields{
+		"stream":    rec.Stream,
+		"messageID": rec.MessageID,
+	})
+	logger.WithFields(logrus.Fields{
+		"producer":  msg.Header.Producer,
+		"provider":  msg.Metadata.Provider,
+		"dataType":  msg.Metadata.DataType,
+		"batchSize": msg.Metadata.BatchSize,
+		"market":    msg.Metadata.Market,
+		"session":   msg.Metadata.TradingSession,
+		"timestamp": time.Unix(msg.Header.Timestamp, 0).Format(time.RFC3339),
+	}).Info("收到消息")
+
+	switch payload := rec.Payload.(type) {
+	case []message.StockData:
+		for i, stock := range payload {
+			logger.WithFields(logrus.Fields{
+				"index":         i + 1,
+				"symbol":        stock.Symbol,
+				"name":          stock.Name,
+				"price":         stock.Price,
+				"change":        stock.Change,
+				"changePercent": stock.ChangePercent,
+				"volume":        stock.Volume,
+			}).Info("股票数据")
+		}
+	case []message.IndexData:
+		for i, index := range payload {
+			logger.WithFields(logrus.Fields{
+				"index":         i + 1,
+				"symbol":        index.Symbol,
+				"name":          index.Name,
+				"value":         index.Value,
+				"change":        index.Change,
+				"changePercent": index.ChangePercent,
+			}).Info("指数数据")
+		}
+	}
+	return nil
+}
+
+func (s *logSink) Close() error {
+	if closer, ok := s.out.(io.Closer); ok && s.out != os.Stdout {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ndjsonRecord NDJSON 输出的一行，保留重放所需的元数据，Payload 为过滤后的数据
+type ndjsonRecord struct {
+	Stream     string      `json:"stream"`
+	MessageID  string      `json:"messageId"`
+	ReceivedAt string      `json:"receivedAt"`
+	Producer   string      `json:"producer"`
+	Provider   string      `json:"provider"`
+	DataType   string      `json:"dataType"`
+	Timestamp  int64       `json:"timestamp"`
+	Payload    interface{} `json:"payload"`
+}
+
+// ndjsonSink 每条消息写一行 JSON，便于之后用 NewMessageFormat 重新发布回放
+type ndjsonSink struct {
+	w   io.Writer
+	enc *json.Encoder
+	now func() time.Time
+}
+
+func newNDJSONSink(w io.Writer) *ndjsonSink {
+	return &ndjsonSink{w: w, enc: json.NewEncoder(w), now: time.Now}
+}
+
+func (s *ndjsonSink) Write(rec *record) error {
+	return s.enc.Encode(ndjsonRecord{
+		Stream:     rec.Stream,
+		MessageID:  rec.MessageID,
+		ReceivedAt: s.now().Format(time.RFC3339Nano),
+		Producer:   rec.Message.Header.Producer,
+		Provider:   rec.Message.Metadata.Provider,
+		DataType:   rec.Message.Metadata.DataType,
+		Timestamp:  rec.Message.Header.Timestamp,
+		Payload:    rec.Payload,
+	})
+}
+
+func (s *ndjsonSink) Close() error {
+	if closer, ok := s.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// rotateLayout 轮转文件名中的时间格式，精确到微秒，按文件名排序即为轮转顺序
+const rotateLayout = "20060102-150405.000000"
+
+// rotatingFile 按大小或写入时长轮转的文件，轮转后的文件名为 <路径>.<rotateLayout>
+type rotatingFile struct {
+	path   string
+	config RotateConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// newRotatingFile 打开文件（追加写入），目录不存在时创建
+func newRotatingFile(path string, config RotateConfig) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, config: config, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", r.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", r.path, err)
+	}
+	r.file, r.size, r.openedAt = file, info.Size(), r.now()
+	return nil
+}
+
+// Write 实现 io.Writer，写入前检查是否需要轮转，单次写入不会被拆到两个文件
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) shouldRotate(next int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.config.MaxSize > 0 && r.size+next > r.config.MaxSize {
+		return true
+	}
+	return r.config.MaxAge > 0 && r.now().Sub(r.openedAt) >= r.config.MaxAge
+}
+
+// rotate 重命名当前文件并打开新文件，然后删除超出 MaxBackups 的旧文件
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", r.path, err)
+	}
+	r.file = nil
+
+	backup := r.path + "." + r.now().Format(rotateLayout)
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", r.path, err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	return r.prune()
+}
+
+func (r *rotatingFile) prune() error {
+	if r.config.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > r.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old log %s: %w", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backups 返回按轮转时间从旧到新排列的轮转文件
+func (r *rotatingFile) backups() ([]string, error) {
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	backups := matches[:0]
+	for _, match := range matches {
+		if _, err := time.Parse(rotateLayout, strings.TrimPrefix(match, r.path+".")); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
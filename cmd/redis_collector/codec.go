@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/message"
+	"stocksub/pkg/snapshot"
+)
+
+// writeStockSnapshot 按 storage.codec 写入股票快照：哈希格式 HMSET 各字段，编码格式 SET 整个快照；
+// stock 只用于取 5 档买卖盘
+func (c *RedisCollector) writeStockSnapshot(ctx context.Context, pipe redis.Pipeliner, key string, snap snapshot.Stock, stock message.StockData) error {
+	if c.codec != nil {
+		if stock.HasDepth() {
+			snap.BidPrices, snap.BidVolumes = stock.BidPrices, stock.BidVolumes
+			snap.AskPrices, snap.AskVolumes = stock.AskPrices, stock.AskVolumes
+		}
+		data, err := c.codec.Encode(&snap)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s snapshot for %s: %w", c.codec.Name(), snap.Symbol, err)
+		}
+		// SET 覆盖旧的哈希并按配置设置过期时间，TTL 为 0 时不过期
+		pipe.Set(ctx, key, data, c.ttl)
+		return nil
+	}
+
+	if c.replaceBlobs {
+		pipe.Del(ctx, key)
+	}
+	hashData := map[string]interface{}{
+		"symbol":         snap.Symbol,
+		"name":           snap.Name,
+		"pinyin":         snap.Pinyin,
+		"price":          snap.Price,
+		"change":         snap.Change,
+		"change_percent": snap.ChangePercent,
+		"volume":         snap.Volume,
+		"turnover":       snap.Turnover,
+		"timestamp":      snap.Timestamp,
+		"provider":       snap.Provider,
+		"market":         snap.Market,
+		"trace_id":       snap.TraceID,
+		"updated_at":     snap.UpdatedAt,
+	}
+	// 5 档买卖盘写入 bid_price1..ask_volume5，没有盘口数据时删除上次写入的字段，避免返回过期的盘口
+	if depth := stock.DepthFields(); depth != nil {
+		for field, value := range depth {
+			hashData[field] = value
+		}
+	} else {
+		pipe.HDel(ctx, key, message.DepthFieldNames()...)
+	}
+
+	// Set hash and TTL
+	pipe.HMSet(ctx, key, hashData)
+	c.applyTTL(ctx, pipe, key)
+	return nil
+}
+
+// writeCodecMeta 记录当前写入股票快照使用的编码，api_server 据此决定先按哪种格式读取
+func (c *RedisCollector) writeCodecMeta(ctx context.Context, pipe redis.Pipeliner) {
+	name := snapshot.CodecHash
+	if c.codec != nil {
+		name = c.codec.Name()
+	}
+	pipe.Set(ctx, c.keyPrefix+snapshot.CodecMetaKey, name, c.ttl)
+}
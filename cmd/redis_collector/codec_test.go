@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+	"stocksub/pkg/snapshot"
+)
+
+func newCodecTestCollector(t *testing.T, codecName string) (*RedisCollector, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	codec, err := snapshot.Lookup(codecName)
+	require.NoError(t, err)
+	return &RedisCollector{redisClient: client, logger: logger, keyPrefix: "latest:", ttl: 10 * time.Minute, codec: codec}, mr
+}
+
+func TestProcessStockData_WritesEncodedSnapshot(t *testing.T) {
+	c, mr := newCodecTestCollector(t, snapshot.CodecMsgpack)
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Name: "浦发银行", Price: 10.5, ChangePercent: 1.2, Volume: 1000, Timestamp: "2025-08-20T10:00:00Z",
+			BidPrices: []float64{10.49, 10.48, 10.47, 10.46, 10.45}, BidVolumes: []int64{1, 2, 3, 4, 5},
+			AskPrices: []float64{10.5, 10.51, 10.52, 10.53, 10.54}, AskVolumes: []int64{6, 7, 8, 9, 10}},
+	})
+	msg.Header.CorrelationID = "trace-1"
+	require.NoError(t, c.processStockData(context.Background(), msg))
+
+	raw, err := mr.Get("latest:stock:600000.SH")
+	require.NoError(t, err, "编码格式使用字符串键")
+	assert.Equal(t, 10*time.Minute, mr.TTL("latest:stock:600000.SH"))
+	stock, err := snapshot.Decode([]byte(raw))
+	require.NoError(t, err)
+	assert.Equal(t, "600000.SH", stock.Symbol)
+	assert.Equal(t, "pfyh", stock.Pinyin)
+	assert.Equal(t, 10.5, stock.Price)
+	assert.Equal(t, int64(1755684000), stock.Timestamp)
+	assert.Equal(t, "trace-1", stock.TraceID)
+	assert.Equal(t, []int64{6, 7, 8, 9, 10}, stock.AskVolumes)
+
+	meta, err := mr.Get("latest:meta:codec")
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.CodecMsgpack, meta)
+	assert.True(t, mr.Exists("latest:rank:change_percent"), "排行榜和集合不受编码影响")
+}
+
+func TestProcessStockData_SwitchesBetweenFormats(t *testing.T) {
+	c, mr := newCodecTestCollector(t, snapshot.CodecHash)
+	mr.HSet("latest:stock:600000.SH", "symbol", "600000.SH", "price", "10")
+	write := func(price float64) error {
+		return c.processStockData(context.Background(), message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+			{Symbol: "600000", Price: price, Timestamp: "2025-08-20T10:00:00Z"},
+		}))
+	}
+
+	// 哈希格式切换到编码格式时 SET 直接覆盖旧的哈希
+	c.codec, _ = snapshot.Lookup(snapshot.CodecJSON)
+	require.NoError(t, write(10.5))
+	raw, err := mr.Get("latest:stock:600000.SH")
+	require.NoError(t, err)
+	assert.Contains(t, raw, `"price":10.5`)
+
+	// 切回哈希格式时需要先删除字符串键，否则 HMSET 返回 WRONGTYPE
+	c.codec = nil
+	require.Error(t, write(10.6))
+	c.replaceBlobs = true
+	require.NoError(t, write(10.6))
+	assert.Equal(t, "10.6", mr.HGet("latest:stock:600000.SH", "price"))
+	meta, _ := mr.Get("latest:meta:codec")
+	assert.Equal(t, snapshot.CodecHash, meta)
+}
+
+func TestPreviousTicks_ReadsBothFormats(t *testing.T) {
+	c, mr := newCodecTestCollector(t, snapshot.CodecMsgpack)
+	codec, _ := snapshot.Lookup(snapshot.CodecMsgpack)
+	data, err := codec.Encode(&snapshot.Stock{Symbol: "600000.SH", Volume: 5000, Timestamp: 1755684000})
+	require.NoError(t, err)
+	require.NoError(t, mr.Set("latest:stock:600000.SH", string(data)))
+	mr.HSet("latest:stock:000001.SZ", "volume", "3000", "timestamp", "1755684000")
+
+	previous, err := c.previousTicks(context.Background(), []message.StockData{{Symbol: "600000"}, {Symbol: "000001"}, {Symbol: "300750"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*message.PreviousTick{
+		"600000.SH": {Volume: 5000, Timestamp: time.Unix(1755684000, 0)},
+	}, previous, "格式不符和不存在的键没有上一次的数据")
+}
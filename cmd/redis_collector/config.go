@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+
+	appconfig "stocksub/pkg/config"
+	"stocksub/pkg/message"
+	"stocksub/pkg/snapshot"
+)
+
+// Validate 检查加载后的配置，返回汇总全部问题的错误
+func (c *Config) Validate() error {
+	var v appconfig.Validator
+
+	if err := message.ValidateNamespace(c.Namespace); err != nil {
+		v.Addf("namespace: %v", err)
+	}
+	v.Addr("redis.addr", c.Redis.Addr)
+
+	v.NotEmpty("consumer.group", c.Consumer.Group)
+	v.NotEmpty("consumer.name", c.Consumer.Name)
+	if len(c.Consumer.Streams) == 0 {
+		v.Addf("consumer.streams: must not be empty")
+	}
+	v.NonNegative("consumer.max_retries", int64(c.Consumer.MaxRetries))
+	v.NonNegativeDuration("consumer.retry_backoff", c.Consumer.RetryBackoff)
+	v.NonNegativeDuration("consumer.claim_idle", c.Consumer.ClaimIdle)
+	v.NonNegativeDuration("consumer.drain_timeout", c.Consumer.DrainTimeout)
+
+	v.NotEmpty("dedupe.key_prefix", c.Dedupe.KeyPrefix)
+	v.PositiveDuration("dedupe.ttl", c.Dedupe.TTL)
+
+	v.NotEmpty("storage.key_prefix", c.Storage.KeyPrefix)
+	v.NonNegative("storage.ttl", int64(c.Storage.TTL))
+	v.OneOf("storage.codec", c.Storage.Codec, snapshot.CodecHash, snapshot.CodecJSON, snapshot.CodecMsgpack)
+	v.NotEmpty("storage.eod_key_prefix", c.Storage.EODKeyPrefix)
+	v.NonNegative("storage.eod_ttl", int64(c.Storage.EODTTL))
+
+	// 端口为 0 表示关闭健康检查，地址为空表示不启动 /metrics
+	if c.Health.Port != 0 {
+		v.Port("health.port", strconv.Itoa(c.Health.Port))
+	}
+	v.NonNegativeDuration("health.stale_after", c.Health.StaleAfter)
+	if c.Metrics.Addr != "" {
+		v.Addr("metrics.addr", c.Metrics.Addr)
+	}
+
+	if c.Validation.MaxChangePercent < 0 {
+		v.Addf("validation.max_change_percent: must not be negative, got %v", c.Validation.MaxChangePercent)
+	}
+	if c.Validation.RelaxedMaxChangePercent < 0 {
+		v.Addf("validation.relaxed_max_change_percent: must not be negative, got %v", c.Validation.RelaxedMaxChangePercent)
+	}
+	v.NonNegativeDuration("validation.max_future_skew", c.Validation.MaxFutureSkew)
+
+	if c.Alerts.Enabled {
+		v.NotEmpty("alerts.stream", c.Alerts.Stream)
+		v.NotEmpty("alerts.rules_key", c.Alerts.RulesKey)
+		v.PositiveDuration("alerts.refresh_interval", c.Alerts.RefreshInterval)
+		if c.Alerts.Webhook.URL != "" {
+			v.URL("alerts.webhook.url", c.Alerts.Webhook.URL)
+		}
+	}
+
+	return v.Err()
+}
+
+// applyNamespace 把命名空间应用到读取的 Stream 和写入的键：Stream 变为 stream:<namespace>:...，
+// latest:、eod:、去重和告警规则等键加 <namespace>: 前缀。默认命名空间不做修改
+func (c *Config) applyNamespace() {
+	ns := c.Namespace
+	if ns == "" {
+		return
+	}
+	c.Consumer.Streams = message.NamespaceStreams(ns, c.Consumer.Streams)
+	c.Consumer.DeadLetterPrefix = message.NamespaceStream(ns, c.Consumer.DeadLetterPrefix)
+	c.Dedupe.KeyPrefix = message.NamespaceKey(ns, c.Dedupe.KeyPrefix)
+	c.Storage.KeyPrefix = message.NamespaceKey(ns, c.Storage.KeyPrefix)
+	c.Storage.EODKeyPrefix = message.NamespaceKey(ns, c.Storage.EODKeyPrefix)
+	c.Validation.QuarantineStream = message.NamespaceStream(ns, c.Validation.QuarantineStream)
+	c.Alerts.Stream = message.NamespaceStream(ns, c.Alerts.Stream)
+	c.Alerts.RulesKey = message.NamespaceKey(ns, c.Alerts.RulesKey)
+}
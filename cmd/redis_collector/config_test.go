@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/alert"
+)
+
+// loadTestConfig 从 dir（为空时只用默认值）加载配置，测试结束后重置 viper
+func loadTestConfig(t *testing.T, dir string) *Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if dir != "" {
+		viper.AddConfigPath(dir)
+	}
+	config, err := loadConfig()
+	require.NoError(t, err)
+	return config
+}
+
+func TestConfig_DefaultsAndShippedConfigAreValid(t *testing.T) {
+	assert.NoError(t, loadTestConfig(t, "").Validate())
+	assert.NoError(t, loadTestConfig(t, "../../config").Validate())
+}
+
+func TestConfig_ValidateRejectsInvalidValues(t *testing.T) {
+	for field, mutate := range map[string]func(c *Config){
+		"namespace":                     func(c *Config) { c.Namespace = "Staging:1" },
+		"redis.addr":                    func(c *Config) { c.Redis.Addr = "" },
+		"consumer.group":                func(c *Config) { c.Consumer.Group = "" },
+		"consumer.streams":              func(c *Config) { c.Consumer.Streams = nil },
+		"consumer.max_retries":          func(c *Config) { c.Consumer.MaxRetries = -1 },
+		"dedupe.ttl":                    func(c *Config) { c.Dedupe.TTL = 0 },
+		"storage.key_prefix":            func(c *Config) { c.Storage.KeyPrefix = "" },
+		"storage.ttl":                   func(c *Config) { c.Storage.TTL = -1 },
+		"storage.codec":                 func(c *Config) { c.Storage.Codec = "protobuf" },
+		"health.port":                   func(c *Config) { c.Health.Port = 70000 },
+		"metrics.addr":                  func(c *Config) { c.Metrics.Addr = "9102" },
+		"validation.max_change_percent": func(c *Config) { c.Validation.MaxChangePercent = -5 },
+		"validation.max_future_skew":    func(c *Config) { c.Validation.MaxFutureSkew = -time.Second },
+		"alerts.refresh_interval": func(c *Config) {
+			c.Alerts.Enabled = true
+			c.Alerts.RefreshInterval = 0
+		},
+		"alerts.webhook.url": func(c *Config) {
+			c.Alerts.Enabled = true
+			c.Alerts.Webhook.URL = "hooks.example.com/alerts"
+		},
+	} {
+		config := loadTestConfig(t, "")
+		mutate(config)
+		err := config.Validate()
+		require.Error(t, err, field)
+		assert.Contains(t, err.Error(), field+":", field)
+	}
+}
+
+func TestConfig_ValidateAllowsDisabledEndpoints(t *testing.T) {
+	config := loadTestConfig(t, "")
+	config.Health.Port = 0
+	config.Metrics.Addr = ""
+	config.Alerts.RefreshInterval = 0
+	assert.NoError(t, config.Validate())
+}
+
+func TestConfig_DefaultNamespaceKeepsNames(t *testing.T) {
+	config := loadTestConfig(t, "")
+	assert.Equal(t, []string{"stream:stock:realtime", "stream:index:realtime", "stream:stock:eod"}, config.Consumer.Streams)
+	assert.Equal(t, "stream:deadletter:", config.Consumer.DeadLetterPrefix)
+	assert.Equal(t, "latest:", config.Storage.KeyPrefix)
+	assert.Equal(t, "eod:", config.Storage.EODKeyPrefix)
+	assert.Equal(t, "dedupe:redis_collector:", config.Dedupe.KeyPrefix)
+	assert.Equal(t, "stream:quarantine", config.Validation.QuarantineStream)
+	assert.Equal(t, "stream:alerts", config.Alerts.Stream)
+}
+
+func TestConfig_NamespaceIsolatesStreamsAndKeys(t *testing.T) {
+	t.Setenv("REDIS_COLLECTOR_NAMESPACE", "staging")
+	config := loadTestConfig(t, "")
+	assert.Equal(t, []string{"stream:staging:stock:realtime", "stream:staging:index:realtime", "stream:staging:stock:eod"}, config.Consumer.Streams)
+	assert.Equal(t, "stream:staging:deadletter:", config.Consumer.DeadLetterPrefix)
+	assert.Equal(t, "staging:latest:", config.Storage.KeyPrefix)
+	assert.Equal(t, "staging:eod:", config.Storage.EODKeyPrefix)
+	assert.Equal(t, "staging:dedupe:redis_collector:", config.Dedupe.KeyPrefix)
+	assert.Equal(t, "stream:staging:quarantine", config.Validation.QuarantineStream)
+	assert.Equal(t, "stream:staging:alerts", config.Alerts.Stream)
+	assert.Equal(t, "staging:"+alert.DefaultRulesKey, config.Alerts.RulesKey)
+
+	t.Setenv("REDIS_COLLECTOR_NAMESPACE", "Staging")
+	viper.Reset()
+	_, err := loadConfig()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "namespace:")
+}
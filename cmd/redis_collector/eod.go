@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+// eodKey 收盘快照哈希的键，例如 eod:stock:600000.SH:20250820
+func (c *RedisCollector) eodKey(symbol, date string) string {
+	return c.eodKeyPrefix + "stock:" + core.NormalizeSymbol(symbol) + ":" + strings.ReplaceAll(date, "-", "")
+}
+
+// processEODData 把收盘快照写入 eod:stock:<symbol>:<yyyymmdd> 哈希；
+// 已存在的交易日快照不覆盖，重复发布的快照直接跳过
+func (c *RedisCollector) processEODData(ctx context.Context, msgFormat *message.MessageFormat) error {
+	log := c.messageLogger(msgFormat)
+
+	payloadBytes, err := json.Marshal(msgFormat.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	var snapshots []message.EODData
+	if err := json.Unmarshal(payloadBytes, &snapshots); err != nil {
+		return fmt.Errorf("failed to unmarshal eod data: %w", err)
+	}
+
+	keys := make([]string, 0, len(snapshots))
+	valid := make([]message.EODData, 0, len(snapshots))
+	for _, eod := range snapshots {
+		if _, err := eod.TradingDate(); err != nil {
+			log.WithError(err).WithField("date", eod.Date).Warn("Failed to parse eod date, skipping")
+			continue
+		}
+		keys = append(keys, c.eodKey(eod.Symbol, eod.Date))
+		valid = append(valid, eod)
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+
+	pipe := c.redisClient.Pipeline()
+	exists := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		exists[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to check eod snapshots: %w", err)
+	}
+
+	pipe = c.redisClient.Pipeline()
+	written, skipped := 0, 0
+	for i, eod := range valid {
+		if exists[i].Val() > 0 {
+			skipped++
+			continue
+		}
+		key := keys[i]
+		pipe.HSet(ctx, key, map[string]interface{}{
+			"symbol":         core.NormalizeSymbol(eod.Symbol),
+			"name":           eod.Name,
+			"date":           eod.Date,
+			"open":           eod.Open,
+			"high":           eod.High,
+			"low":            eod.Low,
+			"close":          eod.Close,
+			"prev_close":     eod.PrevClose,
+			"change":         eod.Change,
+			"change_percent": eod.ChangePercent,
+			"volume":         eod.Volume,
+			"turnover":       eod.Turnover,
+			"timestamp":      eod.Timestamp,
+			"provider":       msgFormat.Metadata.Provider,
+			"trace_id":       msgFormat.Header.CorrelationID,
+			"updated_at":     time.Now().Unix(),
+		})
+		if c.eodTTL > 0 {
+			pipe.Expire(ctx, key, c.eodTTL)
+		}
+		written++
+	}
+	if written > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return fmt.Errorf("failed to execute Redis pipeline: %w", err)
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"count":    written,
+		"skipped":  skipped,
+		"provider": msgFormat.Metadata.Provider,
+	}).Debug("Stored eod snapshots in Redis")
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+)
+
+func TestProcessEODData_StoresDailyHashOnce(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &RedisCollector{redisClient: client, logger: logger, eodKeyPrefix: "eod:", eodTTL: 400 * 24 * time.Hour}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_eod", []message.EODData{
+		{Symbol: "600000", Name: "浦发银行", Date: "2025-08-20", Open: 10.1, High: 10.6, Low: 10, Close: 10.5, Volume: 1250000},
+		{Symbol: "600036", Date: "bad"},
+	})
+	require.NoError(t, c.processEODData(context.Background(), msg))
+
+	key := "eod:stock:600000.SH:20250820"
+	assert.Equal(t, "10.5", mr.HGet(key, "close"))
+	assert.Equal(t, "2025-08-20", mr.HGet(key, "date"))
+	assert.Equal(t, "600000.SH", mr.HGet(key, "symbol"))
+	assert.Equal(t, 400*24*time.Hour, mr.TTL(key))
+	assert.Len(t, mr.Keys(), 1, "交易日无效的快照不写入")
+
+	// 同一交易日的重复快照不覆盖已写入的数据，新股票和新交易日照常写入
+	duplicate := message.NewMessageFormat("fetcher-2", "sina", "stock_eod", []message.EODData{
+		{Symbol: "sh600000", Date: "2025-08-20", Close: 99},
+		{Symbol: "600000", Date: "2025-08-21", Close: 10.7},
+		{Symbol: "000001", Date: "2025-08-20", Close: 12.3},
+	})
+	require.NoError(t, c.processEODData(context.Background(), duplicate))
+	assert.Equal(t, "10.5", mr.HGet(key, "close"))
+	assert.Equal(t, "tencent", mr.HGet(key, "provider"))
+	assert.Equal(t, "10.7", mr.HGet("eod:stock:600000.SH:20250821", "close"))
+	assert.Equal(t, "12.3", mr.HGet("eod:stock:000001.SZ:20250820", "close"))
+}
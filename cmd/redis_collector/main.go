@@ -3,11 +3,14 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,42 +18,98 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"stocksub/pkg/alert"
+	appconfig "stocksub/pkg/config"
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/core"
+	"stocksub/pkg/health"
 	"stocksub/pkg/message"
+	"stocksub/pkg/snapshot"
+	"stocksub/pkg/storage"
 )
 
 var (
-	logLevel  = flag.String("log-level", "info", "日志级别 (debug, info, warn, error)")
-	logFormat = flag.String("log-format", "json", "日志格式 (json or text)")
+	logLevel    = flag.String("log-level", "info", "日志级别 (debug, info, warn, error)")
+	logFormat   = flag.String("log-format", "json", "日志格式 (json or text)")
+	checkConfig = flag.Bool("check-config", false, "检查配置并打印生效的配置（隐藏敏感值）后退出")
 )
 
+// rankMetrics 排行榜有序集合对应的指标，键为 <keyPrefix>rank:<指标>，分数为指标值
+var rankMetrics = []string{"change_percent", "volume", "turnover"}
+
 type RedisCollector struct {
-	redisClient     *redis.Client
-	consumerGroup   string
-	consumerName    string
-	streams         []string
-	logger          *logrus.Logger
-	ctx             context.Context
-	cancel          context.CancelFunc
-	processedMsgIDs map[string]bool // 用于幂等处理
+	redisClient  *redis.Client
+	consumer     *consumer.StreamConsumer
+	logger       *logrus.Logger
+	ctx          context.Context
+	cancel       context.CancelFunc
+	consumerDone chan struct{}
+	keyPrefix    string                   // 最新数据键前缀，例如 "latest:"
+	ttl          time.Duration            // 最新数据过期时间，0 表示不过期
+	eodKeyPrefix string                   // 收盘快照键前缀，例如 "eod:"
+	eodTTL       time.Duration            // 收盘快照过期时间，0 表示不过期
+	codec        snapshot.Codec           // 股票快照的编码，为 nil 时写入哈希
+	replaceBlobs bool                     // 由编码格式切回哈希格式时，写入哈希前先删除编码格式的键
+	dedupe       message.IdempotencyStore // 用于幂等处理
+	health       *health.Server
+	staleAfter   time.Duration // 消费循环超过该时间没有活动时存活检查失败
+
+	alerts        *alert.Engine          // 为 nil 时不评估告警规则
+	alertNotifier alert.Notifier         // 告警通知：stream:alerts，配置了 webhook 时同时发送
+	webhook       *alert.WebhookNotifier // 需要在后台运行发送循环，未配置时为 nil
+
+	validator  *message.TickValidator // 为 nil 时不校验行情
+	quarantine *message.Quarantine    // 未通过校验的行情写入 stream:quarantine
+	metrics    *collectorMetrics
+	metricsSrv *http.Server
+
+	lastMessageProcessedAt atomic.Int64 // 最近一次成功处理消息的时间（UnixNano）
 }
 
 type Config struct {
+	Namespace string `mapstructure:"namespace"` // 命名空间，与 fetcher 的 --namespace 一致，为空时使用默认名称
+
 	Redis struct {
 		Addr     string `mapstructure:"addr"`
 		Password string `mapstructure:"password"`
 		DB       int    `mapstructure:"db"`
 	} `mapstructure:"redis"`
 
-	Consumer struct {
-		Group   string   `mapstructure:"group"`
-		Name    string   `mapstructure:"name"`
-		Streams []string `mapstructure:"streams"`
-	} `mapstructure:"consumer"`
+	Consumer consumer.Config `mapstructure:"consumer"`
+
+	Dedupe struct {
+		KeyPrefix string        `mapstructure:"key_prefix"`
+		TTL       time.Duration `mapstructure:"ttl"`
+	} `mapstructure:"dedupe"`
 
 	Storage struct {
 		KeyPrefix string `mapstructure:"key_prefix"`
-		TTL       int    `mapstructure:"ttl"` // seconds
+		TTL       int    `mapstructure:"ttl"`   // seconds, 0 means no expiry
+		Codec     string `mapstructure:"codec"` // 股票快照的编码：hash（默认）、json、msgpack
+
+		EODKeyPrefix string `mapstructure:"eod_key_prefix"` // 收盘快照键前缀，键为 <prefix>stock:<symbol>:<yyyymmdd>
+		EODTTL       int    `mapstructure:"eod_ttl"`        // 收盘快照过期时间（秒），0 表示不过期
 	} `mapstructure:"storage"`
+
+	Health struct {
+		Port       int           `mapstructure:"port"`        // /healthz、/readyz 端口，0 表示关闭
+		StaleAfter time.Duration `mapstructure:"stale_after"` // 消费循环超过该时间没有活动时存活检查失败，0 表示不检查
+	} `mapstructure:"health"`
+
+	Metrics struct {
+		Addr string `mapstructure:"addr"` // /metrics 监听地址，为空时不启动
+	} `mapstructure:"metrics"`
+
+	Validation message.ValidationConfig `mapstructure:"validation"`
+
+	Alerts struct {
+		Enabled         bool                `mapstructure:"enabled"`
+		Stream          string              `mapstructure:"stream"`           // 告警通知写入的 Stream
+		RulesKey        string              `mapstructure:"rules_key"`        // api_server 保存规则的 Redis 哈希键
+		RefreshInterval time.Duration       `mapstructure:"refresh_interval"` // 重新加载 Redis 中规则的间隔
+		Rules           []alert.Rule        `mapstructure:"rules"`            // 配置文件中的静态规则
+		Webhook         alert.WebhookConfig `mapstructure:"webhook"`
+	} `mapstructure:"alerts"`
 }
 
 func main() {
@@ -77,6 +136,12 @@ func main() {
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to load configuration")
 	}
+	if *checkConfig {
+		if err := appconfig.WriteRedacted(os.Stdout, viper.AllSettings()); err != nil {
+			logger.WithError(err).Fatal("Failed to print configuration")
+		}
+		return
+	}
 
 	// Create collector
 	collector, err := NewRedisCollector(config, logger)
@@ -106,6 +171,7 @@ func loadConfig() (*Config, error) {
 	viper.AddConfigPath(".")
 
 	// Set defaults
+	viper.SetDefault("namespace", "")
 	viper.SetDefault("redis.addr", "localhost:6379")
 	viper.SetDefault("redis.password", "")
 	viper.SetDefault("redis.db", 0)
@@ -114,9 +180,34 @@ func loadConfig() (*Config, error) {
 	viper.SetDefault("consumer.streams", []string{
 		"stream:stock:realtime",
 		"stream:index:realtime",
+		"stream:stock:eod",
 	})
+	viper.SetDefault("consumer.max_retries", 3)
+	viper.SetDefault("consumer.retry_backoff", "1s")
+	viper.SetDefault("consumer.claim_idle", "5m")
+	viper.SetDefault("consumer.dead_letter_prefix", "stream:deadletter:")
+	viper.SetDefault("consumer.drain_timeout", "10s")
+	viper.SetDefault("dedupe.key_prefix", "dedupe:redis_collector:")
+	viper.SetDefault("dedupe.ttl", "24h")
 	viper.SetDefault("storage.key_prefix", "latest:")
 	viper.SetDefault("storage.ttl", 3600) // 1 hour
+	viper.SetDefault("storage.codec", snapshot.CodecHash)
+	viper.SetDefault("storage.eod_key_prefix", "eod:")
+	viper.SetDefault("storage.eod_ttl", 400*24*3600) // 400 days
+	viper.SetDefault("health.port", 8082)
+	viper.SetDefault("health.stale_after", "60s")
+	viper.SetDefault("metrics.addr", ":9102")
+	validation := message.DefaultValidationConfig()
+	viper.SetDefault("validation.enabled", validation.Enabled)
+	viper.SetDefault("validation.max_change_percent", validation.MaxChangePercent)
+	viper.SetDefault("validation.relaxed_max_change_percent", validation.RelaxedMaxChangePercent)
+	viper.SetDefault("validation.relaxed_patterns", validation.RelaxedPatterns)
+	viper.SetDefault("validation.max_future_skew", validation.MaxFutureSkew)
+	viper.SetDefault("validation.quarantine_stream", validation.QuarantineStream)
+	viper.SetDefault("alerts.enabled", false)
+	viper.SetDefault("alerts.stream", alert.DefaultStream)
+	viper.SetDefault("alerts.rules_key", alert.DefaultRulesKey)
+	viper.SetDefault("alerts.refresh_interval", "10s")
 
 	// Environment variable overrides
 	viper.SetEnvPrefix("REDIS_COLLECTOR")
@@ -133,6 +224,10 @@ func loadConfig() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config.applyNamespace()
 
 	return &config, nil
 }
@@ -153,38 +248,125 @@ func NewRedisCollector(config *Config, logger *logrus.Logger) (*RedisCollector,
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	codec, err := snapshot.Lookup(config.Storage.Codec)
+	if err != nil {
+		return nil, err
+	}
+	// 上一次运行写入的是编码格式时，哈希格式的 HMSET 会因键类型不符而失败
+	var replaceBlobs bool
+	if codec == nil {
+		previous, err := redisClient.Get(ctx, config.Storage.KeyPrefix+snapshot.CodecMetaKey).Result()
+		if err != nil && err != redis.Nil {
+			return nil, fmt.Errorf("failed to read snapshot codec: %w", err)
+		}
+		replaceBlobs = previous != "" && previous != snapshot.CodecHash
+	}
+
 	ctx, cancel = context.WithCancel(context.Background())
 
-	return &RedisCollector{
-		redisClient:     redisClient,
-		consumerGroup:   config.Consumer.Group,
-		consumerName:    config.Consumer.Name,
-		streams:         config.Consumer.Streams,
-		logger:          logger,
-		ctx:             ctx,
-		cancel:          cancel,
-		processedMsgIDs: make(map[string]bool),
-	}, nil
+	collector := &RedisCollector{
+		redisClient:  redisClient,
+		logger:       logger,
+		ctx:          ctx,
+		cancel:       cancel,
+		consumerDone: make(chan struct{}),
+		keyPrefix:    config.Storage.KeyPrefix,
+		ttl:          time.Duration(config.Storage.TTL) * time.Second,
+		eodKeyPrefix: config.Storage.EODKeyPrefix,
+		eodTTL:       time.Duration(config.Storage.EODTTL) * time.Second,
+		codec:        codec,
+		replaceBlobs: replaceBlobs,
+		dedupe:       message.NewRedisIdempotencyStore(redisClient, config.Dedupe.KeyPrefix, config.Dedupe.TTL),
+		health:       health.NewServer(config.Health.Port),
+		staleAfter:   config.Health.StaleAfter,
+		metrics:      newCollectorMetrics(),
+	}
+
+	if config.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", collector.metrics.handler())
+		collector.metricsSrv = &http.Server{Addr: config.Metrics.Addr, Handler: mux}
+	}
+
+	if config.Validation.Enabled {
+		validator, err := message.NewTickValidator(config.Validation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validation config: %w", err)
+		}
+		collector.validator = validator
+		collector.quarantine = message.NewQuarantine(redisClient, config.Validation.QuarantineStream, "redis_collector")
+	}
+
+	collector.consumer = consumer.New(redisClient, config.Consumer, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		if err := collector.processMessage(ctx, stream, msg); err != nil {
+			return err
+		}
+		collector.lastMessageProcessedAt.Store(time.Now().UnixNano())
+		return nil
+	}, logger)
+
+	collector.health.AddLivenessCheck("redis", health.RedisCheck(redisClient))
+	if collector.staleAfter > 0 {
+		collector.health.AddLivenessCheck("consumer", health.StalenessCheck(collector.lastActivity, collector.staleAfter))
+	}
+	collector.health.AddReadinessCheck("redis", health.RedisCheck(redisClient))
+
+	if config.Alerts.Enabled {
+		store := alert.NewRedisRuleStore(redisClient, config.Alerts.RulesKey)
+		engine, err := alert.NewEngine(config.Alerts.Rules, store, config.Alerts.RefreshInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rules: %w", err)
+		}
+		collector.alerts = engine
+		notifiers := alert.Notifiers{alert.NewStreamNotifier(redisClient, config.Alerts.Stream)}
+		if config.Alerts.Webhook.URL != "" {
+			collector.webhook = alert.NewWebhookNotifier(config.Alerts.Webhook, logger)
+			notifiers = append(notifiers, collector.webhook)
+		}
+		collector.alertNotifier = notifiers
+		logger.WithField("static_rules", len(config.Alerts.Rules)).Info("Alert rule evaluation enabled")
+	}
+
+	return collector, nil
 }
 
 func (c *RedisCollector) Start() error {
 	c.logger.Info("Starting Redis collector...")
 
 	// Create consumer groups for all streams
-	for _, stream := range c.streams {
-		err := c.redisClient.XGroupCreateMkStream(c.ctx, stream, c.consumerGroup, "0").Err()
-		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
-			return fmt.Errorf("failed to create consumer group for stream %s: %w", stream, err)
-		}
+	if err := c.consumer.CreateGroups(c.ctx); err != nil {
+		return err
 	}
 
 	// Start consuming messages
-	go c.consumeMessages()
+	go func() {
+		defer close(c.consumerDone)
+		c.consumer.Run(c.ctx)
+	}()
+
+	if c.webhook != nil {
+		go c.webhook.Run(c.ctx)
+	}
+
+	if c.metricsSrv != nil {
+		go func() {
+			if err := c.metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				c.logger.WithError(err).Error("Metrics server failed")
+			}
+		}()
+	}
+
+	if err := c.health.Start(); err != nil {
+		return err
+	}
+	c.health.SetReady(true)
 
+	consumerConfig := c.consumer.Config()
 	c.logger.WithFields(logrus.Fields{
-		"consumer_group": c.consumerGroup,
-		"consumer_name":  c.consumerName,
-		"streams":        c.streams,
+		"consumer_group": consumerConfig.Group,
+		"consumer_name":  consumerConfig.Name,
+		"streams":        consumerConfig.Streams,
+		"max_retries":    consumerConfig.MaxRetries,
 	}).Info("Redis collector started successfully")
 
 	return nil
@@ -192,122 +374,105 @@ func (c *RedisCollector) Start() error {
 
 func (c *RedisCollector) Stop() {
 	c.logger.Info("Stopping Redis collector...")
+	c.health.SetReady(false)
 	c.cancel()
-	c.logger.Info("Redis collector stopped")
-}
 
-func (c *RedisCollector) Close() {
-	if c.redisClient != nil {
-		c.redisClient.Close()
+	// Wait for the in-flight batch to be processed and acknowledged
+	<-c.consumerDone
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if c.metricsSrv != nil {
+		_ = c.metricsSrv.Shutdown(ctx)
 	}
+	_ = c.health.Shutdown(ctx)
+	c.logger.Info("Redis collector stopped")
 }
 
-func (c *RedisCollector) consumeMessages() {
-	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		default:
-			// Read messages from all streams
-			streams := make([]string, 0, len(c.streams)*2)
-			for _, stream := range c.streams {
-				streams = append(streams, stream, ">")
-			}
-
-			result, err := c.redisClient.XReadGroup(c.ctx, &redis.XReadGroupArgs{
-				Group:    c.consumerGroup,
-				Consumer: c.consumerName,
-				Streams:  streams,
-				Count:    10,
-				Block:    time.Second,
-			}).Result()
-
-			if err != nil {
-				if err == redis.Nil {
-					continue // No messages available
-				}
-				c.logger.WithError(err).Error("Failed to read messages from Redis streams")
-				time.Sleep(time.Second)
-				continue
-			}
-
-			// Process messages
-			for _, stream := range result {
-				for _, msg := range stream.Messages {
-					if err := c.processMessage(stream.Stream, msg); err != nil {
-						c.logger.WithError(err).WithFields(logrus.Fields{
-							"stream":     stream.Stream,
-							"message_id": msg.ID,
-						}).Error("Failed to process message")
-						continue
-					}
-
-					// Acknowledge message
-					if err := c.redisClient.XAck(c.ctx, stream.Stream, c.consumerGroup, msg.ID).Err(); err != nil {
-						c.logger.WithError(err).WithFields(logrus.Fields{
-							"stream":     stream.Stream,
-							"message_id": msg.ID,
-						}).Error("Failed to acknowledge message")
-					}
-				}
-			}
+// lastActivity 返回消费循环最近一次读取流或成功处理消息的时间，取两者中较晚的一个
+func (c *RedisCollector) lastActivity() time.Time {
+	last := c.consumer.LastReadAt()
+	if nanos := c.lastMessageProcessedAt.Load(); nanos > 0 {
+		if processed := time.Unix(0, nanos); processed.After(last) {
+			last = processed
 		}
 	}
+	return last
 }
 
-func (c *RedisCollector) processMessage(streamName string, msg redis.XMessage) error {
-	// 幂等处理：检查消息是否已处理过
-	if c.processedMsgIDs[msg.ID] {
-		c.logger.WithFields(logrus.Fields{
-			"stream":     streamName,
-			"message_id": msg.ID,
-		}).Debug("Message already processed, skipping")
-		return nil
+func (c *RedisCollector) Close() {
+	if c.redisClient != nil {
+		c.redisClient.Close()
 	}
+}
 
+func (c *RedisCollector) processMessage(ctx context.Context, streamName string, msg redis.XMessage) error {
 	// Extract message data
 	data, ok := msg.Values["data"].(string)
 	if !ok {
 		return fmt.Errorf("message data is not a string")
 	}
 
-	// Parse message format
-	var msgFormat message.MessageFormat
-	if err := json.Unmarshal([]byte(data), &msgFormat); err != nil {
-		return fmt.Errorf("failed to unmarshal message: %w", err)
+	// Parse message format, verify version and checksum
+	msgFormat, err := message.ParseMessage([]byte(data))
+	if errors.Is(err, message.ErrUnsupportedVersion) {
+		// 新版本生产者先于消费者上线时，跳过无法解析的消息而不是反复重试
+		c.logger.WithError(err).WithFields(logrus.Fields{
+			"stream":     streamName,
+			"message_id": msg.ID,
+		}).Warn("Unsupported message version, skipping")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse message: %w", err)
 	}
 
-	// Verify checksum
-	if err := msgFormat.Validate(); err != nil {
-		return fmt.Errorf("message checksum verification failed: %w", err)
+	log := c.messageLogger(msgFormat)
+
+	// 幂等处理：检查消息是否已处理过
+	dedupeKey := msgFormat.DedupeKey()
+	seen, err := c.dedupe.Seen(ctx, dedupeKey)
+	if err != nil {
+		return err
+	}
+	if seen {
+		log.WithFields(logrus.Fields{
+			"stream":     streamName,
+			"message_id": msg.ID,
+			"dedupe_key": dedupeKey,
+		}).Debug("Message already processed, skipping")
+		return nil
 	}
 
 	// Process based on data type
 	var processErr error
 	switch msgFormat.Metadata.DataType {
 	case "stock_realtime":
-		processErr = c.processStockData(&msgFormat)
+		processErr = c.processStockData(ctx, msgFormat)
 	case "index_realtime":
-		processErr = c.processIndexData(&msgFormat)
+		processErr = c.processIndexData(ctx, msgFormat)
+	case "stock_eod":
+		processErr = c.processEODData(ctx, msgFormat)
 	default:
-		c.logger.WithField("data_type", msgFormat.Metadata.DataType).Warn("Unknown data type, skipping")
+		log.WithField("data_type", msgFormat.Metadata.DataType).Warn("Unknown data type, skipping")
 		return nil
 	}
 
-	// 如果处理成功，标记消息为已处理
-	if processErr == nil {
-		c.processedMsgIDs[msg.ID] = true
+	if processErr != nil {
+		return processErr
+	}
 
-		// 定期清理已处理消息记录（防止内存泄漏）
-		if len(c.processedMsgIDs) > 10000 {
-			go c.cleanupProcessedMessages()
-		}
+	// 如果处理成功，标记消息为已处理；标记失败不影响确认，最多导致一次重复处理
+	if err := c.dedupe.Mark(ctx, dedupeKey); err != nil {
+		log.WithError(err).WithField("dedupe_key", dedupeKey).Warn("Failed to mark message as processed")
 	}
 
-	return processErr
+	return nil
 }
 
-func (c *RedisCollector) processStockData(msgFormat *message.MessageFormat) error {
+func (c *RedisCollector) processStockData(ctx context.Context, msgFormat *message.MessageFormat) error {
+	log := c.messageLogger(msgFormat)
+
 	// First convert payload to JSON bytes
 	payloadBytes, err := json.Marshal(msgFormat.Payload)
 	if err != nil {
@@ -318,57 +483,128 @@ func (c *RedisCollector) processStockData(msgFormat *message.MessageFormat) erro
 	if err := json.Unmarshal(payloadBytes, &stockData); err != nil {
 		return fmt.Errorf("failed to unmarshal stock data: %w", err)
 	}
+	// 写入前按 StockDataSchema 的字段精度舍入，旧版本 fetcher 发布的数据带有提供商的浮点误差
+	for i := range stockData {
+		stockData[i].Round(storage.StockDataSchema)
+	}
+
+	// 未通过校验的行情不写入最新数据，转入隔离流
+	stockData, err = c.validateStocks(ctx, log, msgFormat, stockData)
+	if err != nil {
+		return err
+	}
 
 	// Store latest data for each symbol
 	pipe := c.redisClient.Pipeline()
+	symbolsKey := c.keyPrefix + "symbols:stock"
+	updatesKey := c.keyPrefix + "updates:stock"
 
 	for _, stock := range stockData {
-		key := fmt.Sprintf("latest:stock:%s", stock.Symbol)
+		// 键、集合与排行榜成员都使用规范形式（如 600000.SH）
+		symbol := core.NormalizeSymbol(stock.Symbol)
+		key := c.keyPrefix + "stock:" + symbol
 
 		// Parse timestamp string to get Unix timestamp
 		timestamp, err := time.Parse(time.RFC3339, stock.Timestamp)
 		if err != nil {
-			c.logger.WithError(err).WithField("timestamp", stock.Timestamp).Warn("Failed to parse timestamp, using current time")
+			log.WithError(err).WithField("timestamp", stock.Timestamp).Warn("Failed to parse timestamp, using current time")
 			timestamp = time.Now()
 		}
 
-		// Create hash data
-		hashData := map[string]interface{}{
-			"symbol":         stock.Symbol,
-			"name":           stock.Name,
-			"price":          stock.Price,
-			"change":         stock.Change,
-			"change_percent": stock.ChangePercent,
-			"volume":         stock.Volume,
-			"timestamp":      timestamp.Unix(),
-			"provider":       msgFormat.Metadata.Provider,
-			"market":         msgFormat.Metadata.Market,
-			"updated_at":     time.Now().Unix(),
+		updatedAt := time.Now()
+		if err := c.writeStockSnapshot(ctx, pipe, key, snapshot.Stock{
+			Symbol:        symbol,
+			Name:          stock.Name,
+			Pinyin:        pinyinInitials(stock.Name),
+			Price:         stock.Price,
+			Change:        stock.Change,
+			ChangePercent: stock.ChangePercent,
+			Volume:        stock.Volume,
+			Turnover:      stock.Turnover,
+			Timestamp:     timestamp.Unix(),
+			Provider:      msgFormat.Metadata.Provider,
+			Market:        msgFormat.Metadata.Market,
+			TraceID:       msgFormat.Header.CorrelationID,
+			UpdatedAt:     updatedAt.Unix(),
+		}, stock); err != nil {
+			return err
 		}
 
-		// Set hash and TTL
-		pipe.HMSet(c.ctx, key, hashData)
-		pipe.Expire(c.ctx, key, time.Hour) // 1 hour TTL
-
 		// Also maintain a set of all available symbols
-		pipe.SAdd(c.ctx, "symbols:stock", stock.Symbol)
-		pipe.Expire(c.ctx, "symbols:stock", time.Hour)
+		pipe.SAdd(ctx, symbolsKey, symbol)
+
+		// 排行榜有序集合，供 /market/movers 直接按指标取前 N 名
+		pipe.ZAdd(ctx, c.keyPrefix+"rank:change_percent", &redis.Z{Score: stock.ChangePercent, Member: symbol})
+		pipe.ZAdd(ctx, c.keyPrefix+"rank:volume", &redis.Z{Score: float64(stock.Volume), Member: symbol})
+		pipe.ZAdd(ctx, c.keyPrefix+"rank:turnover", &redis.Z{Score: stock.Turnover, Member: symbol})
+		// 更新时间有序集合（毫秒），供 /stocks/changed 按时间取变化的股票
+		pipe.ZAdd(ctx, updatesKey, &redis.Z{Score: float64(updatedAt.UnixMilli()), Member: symbol})
+
+		// 旧格式的成员不再更新，从集合和排行榜中移除，旧格式的哈希随 TTL 过期
+		if legacy := legacySymbol(stock.Symbol); legacy != symbol {
+			pipe.SRem(ctx, symbolsKey, legacy)
+			for _, metric := range rankMetrics {
+				pipe.ZRem(ctx, c.keyPrefix+"rank:"+metric, legacy)
+			}
+			pipe.ZRem(ctx, updatesKey, legacy)
+		}
 	}
+	c.applyTTL(ctx, pipe, symbolsKey)
+	for _, metric := range rankMetrics {
+		c.applyTTL(ctx, pipe, c.keyPrefix+"rank:"+metric)
+	}
+	c.applyTTL(ctx, pipe, updatesKey)
+	c.writeCodecMeta(ctx, pipe)
 
 	// Execute pipeline
-	if _, err := pipe.Exec(c.ctx); err != nil {
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to execute Redis pipeline: %w", err)
 	}
 
-	c.logger.WithFields(logrus.Fields{
+	log.WithFields(logrus.Fields{
 		"count":    len(stockData),
 		"provider": msgFormat.Metadata.Provider,
 	}).Debug("Stored latest stock data in Redis")
 
+	c.evaluateAlerts(ctx, log, stockData)
 	return nil
 }
 
-func (c *RedisCollector) processIndexData(msgFormat *message.MessageFormat) error {
+// messageLogger 返回带消息追踪 ID 的日志，处理同一条消息的日志都带 trace_id 字段
+func (c *RedisCollector) messageLogger(msgFormat *message.MessageFormat) *logrus.Entry {
+	return c.logger.WithField("trace_id", msgFormat.Header.CorrelationID)
+}
+
+// legacySymbol 返回代码在引入规范形式之前写入 Redis 时使用的形式
+func legacySymbol(s string) string {
+	if symbol, err := core.ParseSymbol(s); err == nil {
+		return symbol.Legacy()
+	}
+	return s
+}
+
+// evaluateAlerts 对一批行情评估告警规则并发送通知，告警相关的错误只记录日志，不影响消息确认
+func (c *RedisCollector) evaluateAlerts(ctx context.Context, log *logrus.Entry, stockData []message.StockData) {
+	if c.alerts == nil {
+		return
+	}
+	notifications, err := c.alerts.Evaluate(ctx, stockData)
+	if err != nil {
+		log.WithError(err).Warn("Failed to reload alert rules, using cached rules")
+	}
+	for _, n := range notifications {
+		if err := c.alertNotifier.Notify(ctx, n); err != nil {
+			log.WithError(err).WithFields(logrus.Fields{
+				"rule_id": n.RuleID,
+				"symbol":  n.Symbol,
+			}).Warn("Failed to send alert notification")
+		}
+	}
+}
+
+func (c *RedisCollector) processIndexData(ctx context.Context, msgFormat *message.MessageFormat) error {
+	log := c.messageLogger(msgFormat)
+
 	// First convert payload to JSON bytes
 	payloadBytes, err := json.Marshal(msgFormat.Payload)
 	if err != nil {
@@ -379,48 +615,58 @@ func (c *RedisCollector) processIndexData(msgFormat *message.MessageFormat) erro
 	if err := json.Unmarshal(payloadBytes, &indexData); err != nil {
 		return fmt.Errorf("failed to unmarshal index data: %w", err)
 	}
+	for i := range indexData {
+		indexData[i].Round(storage.StockDataSchema)
+	}
 
 	// Store latest data for each index
 	pipe := c.redisClient.Pipeline()
+	symbolsKey := c.keyPrefix + "symbols:index"
 
 	for _, index := range indexData {
-		key := fmt.Sprintf("latest:index:%s", index.Symbol)
+		symbol := core.NormalizeSymbol(index.Symbol)
+		key := c.keyPrefix + "index:" + symbol
 
 		// Parse timestamp string to get Unix timestamp
 		timestamp, err := time.Parse(time.RFC3339, index.Timestamp)
 		if err != nil {
-			c.logger.WithError(err).WithField("timestamp", index.Timestamp).Warn("Failed to parse timestamp, using current time")
+			log.WithError(err).WithField("timestamp", index.Timestamp).Warn("Failed to parse timestamp, using current time")
 			timestamp = time.Now()
 		}
 
 		// Create hash data
 		hashData := map[string]interface{}{
-			"symbol":         index.Symbol,
+			"symbol":         symbol,
 			"name":           index.Name,
+			"pinyin":         pinyinInitials(index.Name),
 			"value":          index.Value,
 			"change":         index.Change,
 			"change_percent": index.ChangePercent,
 			"timestamp":      timestamp.Unix(),
 			"provider":       msgFormat.Metadata.Provider,
 			"market":         msgFormat.Metadata.Market,
+			"trace_id":       msgFormat.Header.CorrelationID,
 			"updated_at":     time.Now().Unix(),
 		}
 
 		// Set hash and TTL
-		pipe.HMSet(c.ctx, key, hashData)
-		pipe.Expire(c.ctx, key, time.Hour) // 1 hour TTL
+		pipe.HMSet(ctx, key, hashData)
+		c.applyTTL(ctx, pipe, key)
 
 		// Also maintain a set of all available symbols
-		pipe.SAdd(c.ctx, "symbols:index", index.Symbol)
-		pipe.Expire(c.ctx, "symbols:index", time.Hour)
+		pipe.SAdd(ctx, symbolsKey, symbol)
+		if legacy := legacySymbol(index.Symbol); legacy != symbol {
+			pipe.SRem(ctx, symbolsKey, legacy)
+		}
 	}
+	c.applyTTL(ctx, pipe, symbolsKey)
 
 	// Execute pipeline
-	if _, err := pipe.Exec(c.ctx); err != nil {
+	if _, err := pipe.Exec(ctx); err != nil {
 		return fmt.Errorf("failed to execute Redis pipeline: %w", err)
 	}
 
-	c.logger.WithFields(logrus.Fields{
+	log.WithFields(logrus.Fields{
 		"count":    len(indexData),
 		"provider": msgFormat.Metadata.Provider,
 	}).Debug("Stored latest index data in Redis")
@@ -428,26 +674,11 @@ func (c *RedisCollector) processIndexData(msgFormat *message.MessageFormat) erro
 	return nil
 }
 
-// cleanupProcessedMessages 定期清理已处理消息记录，防止内存泄漏
-func (c *RedisCollector) cleanupProcessedMessages() {
-	c.logger.Debug("Cleaning up processed messages cache")
-
-	// 保留最近的 5000 条记录，删除其余的
-	if len(c.processedMsgIDs) > 5000 {
-		// 简单的清理策略：清空一半
-		newMap := make(map[string]bool)
-		count := 0
-		for msgID, processed := range c.processedMsgIDs {
-			if count < 5000 {
-				newMap[msgID] = processed
-				count++
-			}
-		}
-		c.processedMsgIDs = newMap
-
-		c.logger.WithFields(logrus.Fields{
-			"remaining_count": len(c.processedMsgIDs),
-			"cleaned_count":   len(c.processedMsgIDs) - 5000,
-		}).Info("Processed messages cache cleaned up")
+// applyTTL 按配置设置键的过期时间，TTL 为 0 时移除已有的过期时间
+func (c *RedisCollector) applyTTL(ctx context.Context, pipe redis.Pipeliner, key string) {
+	if c.ttl > 0 {
+		pipe.Expire(ctx, key, c.ttl)
+	} else {
+		pipe.Persist(ctx, key)
 	}
 }
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/alert"
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/health"
+	"stocksub/pkg/message"
+)
+
+var errPipelineRecorded = errors.New("pipeline recorded")
+
+// pipelineRecorder 记录 pipeline 中的命令并中止执行，测试不需要真实的 Redis
+type pipelineRecorder struct {
+	commands [][]string
+}
+
+func (r *pipelineRecorder) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (r *pipelineRecorder) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (r *pipelineRecorder) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	for _, cmd := range cmds {
+		args := make([]string, 0, len(cmd.Args()))
+		for _, arg := range cmd.Args() {
+			if s, ok := arg.(string); ok {
+				args = append(args, s)
+			} else if d, ok := arg.(int64); ok {
+				args = append(args, time.Duration(d*int64(time.Second)).String())
+			}
+		}
+		r.commands = append(r.commands, args)
+	}
+	return ctx, errPipelineRecorded
+}
+
+func (r *pipelineRecorder) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// commandsNamed 返回指定命令的参数列表（不含命令名）
+func (r *pipelineRecorder) commandsNamed(name string) [][]string {
+	var result [][]string
+	for _, cmd := range r.commands {
+		if strings.EqualFold(cmd[0], name) {
+			result = append(result, cmd[1:])
+		}
+	}
+	return result
+}
+
+func newTestCollector(keyPrefix string, ttl time.Duration) (*RedisCollector, *pipelineRecorder) {
+	client := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("no network in tests")
+		},
+	})
+	recorder := &pipelineRecorder{}
+	client.AddHook(recorder)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return &RedisCollector{redisClient: client, logger: logger, keyPrefix: keyPrefix, ttl: ttl}, recorder
+}
+
+func TestProcessStockData_UsesConfiguredPrefixAndTTL(t *testing.T) {
+	c, recorder := newTestCollector("dev:latest:", 10*time.Minute)
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Timestamp: "2025-08-20T10:00:00Z"},
+		{Symbol: "000001", Price: 12.3, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+
+	err := c.processStockData(context.Background(), msg)
+	require.ErrorIs(t, err, errPipelineRecorded)
+
+	hmset := recorder.commandsNamed("hmset")
+	require.Len(t, hmset, 2)
+	assert.Equal(t, "dev:latest:stock:600000.SH", hmset[0][0])
+	assert.Equal(t, "dev:latest:stock:000001.SZ", hmset[1][0])
+
+	assert.Equal(t, [][]string{
+		{"dev:latest:stock:600000.SH", "10m0s"},
+		{"dev:latest:stock:000001.SZ", "10m0s"},
+		{"dev:latest:symbols:stock", "10m0s"},
+		{"dev:latest:rank:change_percent", "10m0s"},
+		{"dev:latest:rank:volume", "10m0s"},
+		{"dev:latest:rank:turnover", "10m0s"},
+		{"dev:latest:updates:stock", "10m0s"},
+	}, recorder.commandsNamed("expire"))
+	assert.Equal(t, [][]string{
+		{"dev:latest:symbols:stock", "600000.SH"},
+		{"dev:latest:symbols:stock", "000001.SZ"},
+	}, recorder.commandsNamed("sadd"))
+	assert.Empty(t, recorder.commandsNamed("persist"))
+}
+
+func TestProcessStockData_MaintainsRankSortedSets(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &RedisCollector{redisClient: client, logger: logger, keyPrefix: "latest:", ttl: 10 * time.Minute}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", ChangePercent: 2.5, Volume: 1000, Turnover: 10500, Timestamp: "2025-08-20T10:00:00Z"},
+		{Symbol: "000001", ChangePercent: -1.2, Volume: 3000, Turnover: 36900, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+	require.NoError(t, c.processStockData(context.Background(), msg))
+
+	ctx := context.Background()
+	byChange, err := client.ZRevRangeWithScores(ctx, "latest:rank:change_percent", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []redis.Z{{Score: 2.5, Member: "600000.SH"}, {Score: -1.2, Member: "000001.SZ"}}, byChange)
+
+	byVolume, err := client.ZRevRange(ctx, "latest:rank:volume", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000001.SZ", "600000.SH"}, byVolume)
+
+	turnover, err := client.ZScore(ctx, "latest:rank:turnover", "600000.SH").Result()
+	require.NoError(t, err)
+	assert.Equal(t, 10500.0, turnover)
+	for _, metric := range rankMetrics {
+		assert.Equal(t, 10*time.Minute, mr.TTL("latest:rank:"+metric), metric)
+	}
+
+	// 后续批次更新分数而不是重复添加
+	update := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "000001", ChangePercent: 3.1, Volume: 4000, Timestamp: "2025-08-20T10:01:00Z"},
+	})
+	require.NoError(t, c.processStockData(context.Background(), update))
+	top, err := client.ZRevRange(ctx, "latest:rank:change_percent", 0, 0).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000001.SZ"}, top)
+	assert.Equal(t, int64(2), client.ZCard(ctx, "latest:rank:change_percent").Val())
+}
+
+func TestProcessStockData_MaintainsUpdateSortedSet(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &RedisCollector{redisClient: client, logger: logger, keyPrefix: "latest:", ttl: 10 * time.Minute}
+	ctx := context.Background()
+
+	before := time.Now().UnixMilli()
+	require.NoError(t, c.processStockData(ctx, message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Timestamp: "2025-08-20T10:00:00Z"},
+		{Symbol: "000001", Price: 12.3, Timestamp: "2025-08-20T10:00:00Z"},
+	})))
+	after := time.Now().UnixMilli()
+
+	updates, err := client.ZRangeWithScores(ctx, "latest:updates:stock", 0, -1).Result()
+	require.NoError(t, err)
+	require.Len(t, updates, 2)
+	for _, z := range updates {
+		assert.GreaterOrEqual(t, int64(z.Score), before)
+		assert.LessOrEqual(t, int64(z.Score), after)
+		// 分数与哈希中的 updated_at 是同一时间
+		updatedAt := client.HGet(ctx, "latest:stock:"+z.Member.(string), "updated_at").Val()
+		assert.Equal(t, fmt.Sprint(int64(z.Score)/1000), updatedAt)
+	}
+	assert.Equal(t, 10*time.Minute, mr.TTL("latest:updates:stock"))
+
+	// 再次写入只更新分数，旧格式成员被移除
+	require.NoError(t, client.ZAdd(ctx, "latest:updates:stock", &redis.Z{Score: 1, Member: "600000"}).Err())
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, c.processStockData(ctx, message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.6, Timestamp: "2025-08-20T10:00:03Z"},
+	})))
+	latest, err := client.ZRevRange(ctx, "latest:updates:stock", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600000.SH", "000001.SZ"}, latest)
+}
+
+func TestProcessStockData_StoresOrderBook(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &RedisCollector{redisClient: client, logger: logger, keyPrefix: "latest:"}
+	ctx := context.Background()
+
+	require.NoError(t, c.processStockData(ctx, message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{{
+		Symbol: "600000", Price: 10.5, Timestamp: "2025-08-20T10:00:00Z",
+		BidPrices:  []float64{10.49, 10.48, 10.47, 10.46, 10.45},
+		BidVolumes: []int64{100, 200, 300, 400, 500},
+		AskPrices:  []float64{10.5, 10.51, 10.52, 10.53, 10.54},
+		AskVolumes: []int64{600, 700, 800, 900, 1000},
+	}})))
+	hash := client.HGetAll(ctx, "latest:stock:600000.SH").Val()
+	assert.Equal(t, "10.49", hash["bid_price1"])
+	assert.Equal(t, "500", hash["bid_volume5"])
+	assert.Equal(t, "10.54", hash["ask_price5"])
+	assert.Equal(t, "600", hash["ask_volume1"])
+
+	// 后续没有盘口数据的行情（如来自新浪）清除旧的盘口
+	require.NoError(t, c.processStockData(ctx, message.NewMessageFormat("fetcher", "sina", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.6, Timestamp: "2025-08-20T10:00:05Z"},
+	})))
+	hash = client.HGetAll(ctx, "latest:stock:600000.SH").Val()
+	assert.Equal(t, "10.6", hash["price"])
+	for _, field := range message.DepthFieldNames() {
+		assert.NotContains(t, hash, field)
+	}
+}
+
+func TestProcessStockData_RemovesLegacySymbolMembers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &RedisCollector{redisClient: client, logger: logger, keyPrefix: "latest:"}
+
+	// 旧版本写入的不带市场的成员
+	ctx := context.Background()
+	require.NoError(t, client.SAdd(ctx, "latest:symbols:stock", "600000").Err())
+	require.NoError(t, client.ZAdd(ctx, "latest:rank:volume", &redis.Z{Score: 1, Member: "600000"}).Err())
+	require.NoError(t, client.SAdd(ctx, "latest:symbols:index", "sh000001").Err())
+
+	require.NoError(t, c.processStockData(ctx, message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Volume: 1000, Timestamp: "2025-08-20T10:00:00Z"},
+	})))
+	require.NoError(t, c.processIndexData(ctx, message.NewMessageFormat("fetcher", "tencent", "index_realtime", []message.IndexData{
+		{Symbol: "sh000001", Value: 3200.5, Timestamp: "2025-08-20T10:00:00Z"},
+	})))
+
+	assert.Equal(t, []string{"600000.SH"}, client.SMembers(ctx, "latest:symbols:stock").Val())
+	assert.Equal(t, []string{"600000.SH"}, client.ZRange(ctx, "latest:rank:volume", 0, -1).Val())
+	assert.Equal(t, []string{"000001.SH"}, client.SMembers(ctx, "latest:symbols:index").Val())
+	assert.Equal(t, "600000.SH", client.HGet(ctx, "latest:stock:600000.SH", "symbol").Val())
+}
+
+func TestProcessStockData_EmitsAlertNotifications(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	store := alert.NewRedisRuleStore(client, "")
+	require.NoError(t, store.Save(context.Background(), alert.Rule{ID: "drop", Symbol: "600000", Type: alert.RuleChangePercent, Threshold: 3}))
+	engine, err := alert.NewEngine([]alert.Rule{{ID: "above", Symbol: "600000", Type: alert.RulePriceAbove, Threshold: 11}}, store, time.Minute)
+	require.NoError(t, err)
+	c := &RedisCollector{
+		redisClient:   client,
+		logger:        logger,
+		keyPrefix:     "latest:",
+		alerts:        engine,
+		alertNotifier: alert.NewStreamNotifier(client, ""),
+	}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 11.2, ChangePercent: -3.5, Timestamp: "2025-08-20T10:00:00Z"},
+		{Symbol: "000001", Price: 12, ChangePercent: -5, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+	require.NoError(t, c.processStockData(context.Background(), msg))
+	// 冷却期内的重复行情不再通知
+	require.NoError(t, c.processStockData(context.Background(), msg))
+
+	entries, err := client.XRange(context.Background(), alert.DefaultStream, "-", "+").Result()
+	require.NoError(t, err)
+	var rules []string
+	for _, entry := range entries {
+		rules = append(rules, entry.Values["rule_id"].(string))
+	}
+	assert.ElementsMatch(t, []string{"above", "drop"}, rules)
+}
+
+func TestProcessIndexData_ZeroTTLMeansNoExpiry(t *testing.T) {
+	c, recorder := newTestCollector("latest:", 0)
+	msg := message.NewMessageFormat("fetcher", "tencent", "index_realtime", []message.IndexData{
+		{Symbol: "sh000001", Value: 3200.5, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+
+	err := c.processIndexData(context.Background(), msg)
+	require.ErrorIs(t, err, errPipelineRecorded)
+
+	hmset := recorder.commandsNamed("hmset")
+	require.Len(t, hmset, 1)
+	assert.Equal(t, "latest:index:000001.SH", hmset[0][0])
+	assert.Empty(t, recorder.commandsNamed("expire"))
+	assert.Equal(t, [][]string{
+		{"latest:index:000001.SH"},
+		{"latest:symbols:index"},
+	}, recorder.commandsNamed("persist"))
+}
+
+func TestProcessStockData_StoresPinyinInitials(t *testing.T) {
+	c, recorder := newTestCollector("latest:", 0)
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Name: "浦发银行", Price: 10.5, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+
+	err := c.processStockData(context.Background(), msg)
+	require.ErrorIs(t, err, errPipelineRecorded)
+
+	hmset := recorder.commandsNamed("hmset")
+	require.Len(t, hmset, 1)
+	assert.Contains(t, strings.Join(hmset[0], " "), "pinyin pfyh")
+}
+
+func TestLastActivity_UsesLatestOfReadAndProcessed(t *testing.T) {
+	c, _ := newTestCollector("latest:", 0)
+	c.consumer = consumer.New(c.redisClient, consumer.Config{}, nil, c.logger)
+	check := health.StalenessCheck(c.lastActivity, time.Minute)
+
+	assert.True(t, c.lastActivity().IsZero())
+	assert.Error(t, check(context.Background()), "no activity before the consumer loop runs")
+
+	processed := time.Now().Add(-10 * time.Second)
+	c.lastMessageProcessedAt.Store(processed.UnixNano())
+	assert.True(t, processed.Equal(c.lastActivity()))
+	assert.NoError(t, check(context.Background()))
+
+	c.lastMessageProcessedAt.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	assert.Error(t, check(context.Background()), "stale consumer fails liveness")
+}
+
+func TestProcessMessage_PropagatesTraceID(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	var logs bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&logs)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.SetLevel(logrus.DebugLevel)
+	c := &RedisCollector{redisClient: client, logger: logger, dedupe: message.NewMemoryIdempotencyStore(time.Hour)}
+
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Price: 10.5, Timestamp: "not-a-time"},
+	})
+	msg.SetCorrelationID("trace-abc")
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+	xmsg := redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}
+	require.NoError(t, c.processMessage(context.Background(), "stream:stock:realtime", xmsg))
+	require.NoError(t, c.processMessage(context.Background(), "stream:stock:realtime", xmsg))
+
+	assert.Equal(t, "trace-abc", mr.HGet("stock:600000.SH", "trace_id"))
+
+	// 时间戳解析失败、写入完成和重复投递的日志都带追踪 ID
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 3)
+	for _, line := range lines {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "trace-abc", entry["trace_id"], line)
+	}
+}
+
+func TestProcessMessage_StoresRoundedValues(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := &RedisCollector{redisClient: client, logger: logger, dedupe: message.NewMemoryIdempotencyStore(time.Hour)}
+
+	stock := message.StockData{Symbol: "600000", Price: 10.500000000000002, Change: -0.0005, ChangePercent: -1.005, Timestamp: time.Now().Format(time.RFC3339)}
+	index := message.IndexData{Symbol: "sh000001", Value: 3200.1234999, ChangePercent: 0.125, Timestamp: time.Now().Format(time.RFC3339)}
+	for i, msg := range []*message.MessageFormat{
+		message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{stock}),
+		message.NewMessageFormat("fetcher", "tencent", "index_realtime", []message.IndexData{index}),
+	} {
+		data, err := msg.ToJSON()
+		require.NoError(t, err)
+		stream := "stream:stock:realtime"
+		if i == 1 {
+			stream = "stream:index:realtime"
+		}
+		require.NoError(t, c.processMessage(context.Background(), stream, redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}))
+	}
+
+	assert.Equal(t, "10.5", mr.HGet("stock:600000.SH", "price"))
+	assert.Equal(t, "-0.001", mr.HGet("stock:600000.SH", "change"))
+	assert.Equal(t, "-1.01", mr.HGet("stock:600000.SH", "change_percent"))
+	assert.Equal(t, "3200.123", mr.HGet("index:000001.SH", "value"))
+	assert.Equal(t, "0.13", mr.HGet("index:000001.SH", "change_percent"))
+}
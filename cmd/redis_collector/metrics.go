@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace Prometheus 指标前缀
+const metricsNamespace = "stocksub_redis_collector"
+
+// collectorMetrics redis_collector 的处理指标
+type collectorMetrics struct {
+	registry         *prometheus.Registry
+	ticksQuarantined *prometheus.CounterVec
+}
+
+// newCollectorMetrics 创建独立的指标注册表
+func newCollectorMetrics() *collectorMetrics {
+	m := &collectorMetrics{
+		registry: prometheus.NewRegistry(),
+		ticksQuarantined: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "ticks_quarantined_total",
+			Help:      "Total number of ticks that failed validation and were written to the quarantine stream.",
+		}, []string{"rule"}),
+	}
+
+	m.registry.MustRegister(m.ticksQuarantined)
+	return m
+}
+
+// handler 返回 /metrics 的 HTTP 处理器
+func (m *collectorMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// GB2312 一级汉字按拼音排序，gbInitialBounds[i] 为 gbInitialLetters[i] 开头的第一个汉字的编码
+var (
+	gbInitialBounds = []int{
+		0xB0A1, 0xB0C5, 0xB2C1, 0xB4EE, 0xB6EA, 0xB7A2, 0xB8C1, 0xB9FE, 0xBBF7, 0xBFA6, 0xC0AC, 0xC2E8,
+		0xC4C3, 0xC5B6, 0xC5BE, 0xC6DA, 0xC8BB, 0xC8F6, 0xCBFA, 0xCDDA, 0xCEF4, 0xD1B9, 0xD4D1,
+	}
+	gbInitialLetters = "abcdefghjklmnopqrstwxyz"
+)
+
+// gbLevel1End GB2312 一级汉字之后的第一个编码，二级汉字按部首排序，无法按区间推算
+const gbLevel1End = 0xD7FA
+
+// pinyinOverrides 多音字在股票名称中的常用读音，以及名称中常见的 GB2312 二级汉字
+var pinyinOverrides = map[rune]byte{
+	'行': 'h', // 银行
+	'重': 'c', // 重庆
+	'藏': 'z', // 西藏
+	'鑫': 'x',
+	'昊': 'h',
+	'晟': 's',
+	'璞': 'p',
+	'淼': 'm',
+	'泸': 'l',
+	'瀚': 'h',
+	'翊': 'y',
+	'琦': 'q',
+	'赟': 'y',
+}
+
+// pinyinInitials 计算名称的拼音首字母，供搜索接口按首字母匹配，例如 "浦发银行" -> "pfyh"
+// 英文字母转为小写，数字原样保留，其他字符和无法识别的汉字忽略
+func pinyinInitials(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r <= unicode.MaxASCII:
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				b.WriteRune(unicode.ToLower(r))
+			}
+		default:
+			if initial, ok := hanziInitial(r); ok {
+				b.WriteByte(initial)
+			}
+		}
+	}
+	return b.String()
+}
+
+// hanziInitial 返回单个汉字的拼音首字母
+func hanziInitial(r rune) (byte, bool) {
+	if initial, ok := pinyinOverrides[r]; ok {
+		return initial, true
+	}
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(string(r))
+	if err != nil || len(encoded) != 2 {
+		return 0, false
+	}
+	code := int(encoded[0])<<8 | int(encoded[1])
+	if code < gbInitialBounds[0] || code >= gbLevel1End {
+		return 0, false
+	}
+	for i := len(gbInitialBounds) - 1; i >= 0; i-- {
+		if code >= gbInitialBounds[i] {
+			return gbInitialLetters[i], true
+		}
+	}
+	return 0, false
+}
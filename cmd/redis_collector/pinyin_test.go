@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinyinInitials(t *testing.T) {
+	tests := map[string]string{
+		"浦发银行":  "pfyh",
+		"平安银行":  "payh",
+		"贵州茅台":  "gzmt",
+		"上证指数":  "szzs",
+		"深证成指":  "szcz",
+		"长江电力":  "cjdl",
+		"重庆啤酒":  "cqpj",
+		"TCL科技": "tclkj",
+		"*ST国华": "stgh",
+		"沪深300": "hs300",
+		"":      "",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, pinyinInitials(name), name)
+	}
+}
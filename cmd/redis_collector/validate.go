@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+	"stocksub/pkg/snapshot"
+)
+
+// validateStocks 校验一批行情并返回通过校验的行情，未通过的写入隔离流并计数；
+// 成交量与最新数据哈希中上一次写入的值比较，同一批中重复的股票与前一条比较
+func (c *RedisCollector) validateStocks(ctx context.Context, log *logrus.Entry, msgFormat *message.MessageFormat, stockData []message.StockData) ([]message.StockData, error) {
+	if c.validator == nil {
+		return stockData, nil
+	}
+
+	previous, err := c.previousTicks(ctx, stockData)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make([]message.StockData, 0, len(stockData))
+	for _, stock := range stockData {
+		symbol := core.NormalizeSymbol(stock.Symbol)
+		timestamp, err := time.Parse(time.RFC3339, stock.Timestamp)
+		if err != nil {
+			timestamp = time.Now()
+		}
+
+		violation := c.validator.Validate(stock, timestamp, previous[symbol])
+		if violation == nil {
+			previous[symbol] = &message.PreviousTick{Volume: stock.Volume, Timestamp: timestamp}
+			valid = append(valid, stock)
+			continue
+		}
+
+		if err := c.quarantine.Add(ctx, msgFormat, stock, *violation); err != nil {
+			return nil, err
+		}
+		c.metrics.ticksQuarantined.WithLabelValues(violation.Rule).Inc()
+		log.WithFields(logrus.Fields{
+			"symbol": symbol,
+			"rule":   violation.Rule,
+			"detail": violation.Detail,
+		}).Warn("Tick failed validation, quarantined")
+	}
+	return valid, nil
+}
+
+// previousTicks 读取最新数据键中上一次写入的成交量和行情时间，键为规范形式的代码；
+// 过渡期内格式与 storage.codec 不符的键按没有上一次的数据处理
+func (c *RedisCollector) previousTicks(ctx context.Context, stockData []message.StockData) (map[string]*message.PreviousTick, error) {
+	pipe := c.redisClient.Pipeline()
+	cmds := make(map[string]redis.Cmder, len(stockData))
+	for _, stock := range stockData {
+		symbol := core.NormalizeSymbol(stock.Symbol)
+		if _, ok := cmds[symbol]; ok {
+			continue
+		}
+		key := c.keyPrefix + "stock:" + symbol
+		if c.codec != nil {
+			cmds[symbol] = pipe.Get(ctx, key)
+		} else {
+			cmds[symbol] = pipe.HMGet(ctx, key, "volume", "timestamp")
+		}
+	}
+	if len(cmds) == 0 {
+		return map[string]*message.PreviousTick{}, nil
+	}
+	_, _ = pipe.Exec(ctx)
+
+	previous := make(map[string]*message.PreviousTick, len(cmds))
+	for symbol, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			if err == redis.Nil || snapshot.IsWrongType(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read previous ticks: %w", err)
+		}
+		if tick, ok := parsePreviousTick(cmd); ok {
+			previous[symbol] = tick
+		}
+	}
+	return previous, nil
+}
+
+// parsePreviousTick 从 GET 的编码快照或 HMGET 的 volume、timestamp 字段解析上一次写入的数据
+func parsePreviousTick(cmd redis.Cmder) (*message.PreviousTick, bool) {
+	switch cmd := cmd.(type) {
+	case *redis.StringCmd:
+		snap, err := snapshot.Decode([]byte(cmd.Val()))
+		if err != nil {
+			return nil, false
+		}
+		return &message.PreviousTick{Volume: snap.Volume, Timestamp: time.Unix(snap.Timestamp, 0)}, true
+	case *redis.SliceCmd:
+		values := cmd.Val()
+		if len(values) != 2 {
+			return nil, false
+		}
+		volumeField, _ := values[0].(string)
+		timestampField, _ := values[1].(string)
+		volume, err := strconv.ParseInt(volumeField, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		timestamp, err := strconv.ParseInt(timestampField, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		return &message.PreviousTick{Volume: volume, Timestamp: time.Unix(timestamp, 0)}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+)
+
+func TestProcessMessage_QuarantinesInvalidTicks(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	validator, err := message.NewTickValidator(message.DefaultValidationConfig())
+	require.NoError(t, err)
+	c := &RedisCollector{
+		redisClient: client,
+		logger:      logger,
+		keyPrefix:   "latest:",
+		dedupe:      message.NewMemoryIdempotencyStore(time.Hour),
+		validator:   validator,
+		quarantine:  message.NewQuarantine(client, "", "redis_collector"),
+		metrics:     newCollectorMetrics(),
+	}
+
+	// 000001 上一次写入的成交量为 5000
+	now := time.Now()
+	mr.HSet("latest:stock:000001.SZ", "volume", "5000", "timestamp", fmt.Sprint(now.Add(-3*time.Second).Unix()))
+
+	timestamp := now.Format(time.RFC3339)
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", []message.StockData{
+		{Symbol: "600000", Name: "浦发银行", Price: 10.5, ChangePercent: 1.2, Volume: 1000, Timestamp: timestamp},
+		{Symbol: "600036", Name: "招商银行", Price: 0, Volume: 2000, Timestamp: timestamp},
+		{Symbol: "000001", Name: "平安银行", Price: 12.3, Volume: 4000, Timestamp: timestamp},
+		{Symbol: "601398", Name: "工商银行", Price: 5.6, ChangePercent: 120, Volume: 3000, Timestamp: timestamp},
+		{Symbol: "000002", Name: "万科A", Price: 8.8, Volume: 6000, Timestamp: timestamp},
+	})
+	data, err := msg.ToJSON()
+	require.NoError(t, err)
+	require.NoError(t, c.processMessage(context.Background(), "stream:stock:realtime", redis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": data}}))
+
+	ctx := context.Background()
+	symbols, err := client.SMembers(ctx, "latest:symbols:stock").Result()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"600000.SH", "000002.SZ"}, symbols)
+	assert.False(t, mr.Exists("latest:stock:600036.SH"))
+	assert.Equal(t, "5000", mr.HGet("latest:stock:000001.SZ", "volume"), "成交量减少的行情不覆盖上一次的值")
+	assert.Equal(t, int64(2), client.ZCard(ctx, "latest:rank:change_percent").Val())
+
+	entries, err := client.XRange(ctx, message.DefaultQuarantineStream, "-", "+").Result()
+	require.NoError(t, err)
+	rules := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		assert.Equal(t, "redis_collector", entry.Values["source"])
+		rules[entry.Values["symbol"].(string)] = entry.Values["rule"].(string)
+	}
+	assert.Equal(t, map[string]string{
+		"600036": message.RulePricePositive,
+		"000001": message.RuleVolumeMonotonic,
+		"601398": message.RuleChangePercent,
+	}, rules)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.ticksQuarantined.WithLabelValues(message.RulePricePositive)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.ticksQuarantined.WithLabelValues(message.RuleVolumeMonotonic)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.ticksQuarantined.WithLabelValues(message.RuleChangePercent)))
+}
+
+func TestValidateStocks_ComparesDuplicatesWithinBatch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	validator, err := message.NewTickValidator(message.DefaultValidationConfig())
+	require.NoError(t, err)
+	c := &RedisCollector{
+		redisClient: client,
+		logger:      logger,
+		keyPrefix:   "latest:",
+		validator:   validator,
+		quarantine:  message.NewQuarantine(client, "stream:test:quarantine", "redis_collector"),
+		metrics:     newCollectorMetrics(),
+	}
+
+	timestamp := time.Now().Format(time.RFC3339)
+	msg := message.NewMessageFormat("fetcher", "tencent", "stock_realtime", nil)
+	valid, err := c.validateStocks(context.Background(), logger.WithField("test", true), msg, []message.StockData{
+		{Symbol: "600000", Price: 10.5, Volume: 1000, Timestamp: timestamp},
+		{Symbol: "sh600000", Price: 10.6, Volume: 900, Timestamp: timestamp},
+		{Symbol: "600000.SH", Price: 10.7, Volume: 1100, Timestamp: timestamp},
+	})
+	require.NoError(t, err)
+	require.Len(t, valid, 2)
+	assert.Equal(t, int64(1100), valid[1].Volume)
+	assert.Equal(t, int64(1), client.XLen(context.Background(), "stream:test:quarantine").Val())
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -19,7 +21,11 @@ import (
 // 全局日志记录器
 var log *logger.Entry
 
+var stateFile = flag.String("state-file", "", "订阅状态文件，设置后保存动态添加的订阅并在重启时恢复")
+
 func main() {
+	flag.Parse()
+
 	// 初始化配置
 	cfg := config.Default()
 
@@ -84,9 +90,15 @@ func main() {
 	// 创建订阅器 (兼容模式：使用原始腾讯提供商以保证兼容性)
 	// 注意：NewSubscriber 返回 *DefaultSubscriber，而不是接口
 	sub := subscriber.NewSubscriber(tencentProvider)
+	// 下次获取时间相差 500ms 以内的订阅合并为一次请求
+	sub.SetBatching(500*time.Millisecond, subscriber.DefaultMaxBatchSize)
 
 	// 创建管理器
 	manager := subscriber.NewManager(sub)
+	if *stateFile != "" {
+		manager.SetSubscriptionStore(subscriber.NewFileSubscriptionStore(*stateFile), callbackByName)
+		log.Infof("订阅状态保存在 %s", *stateFile)
+	}
 
 	// 启动系统
 	ctx, cancel := context.WithCancel(context.Background())
@@ -100,14 +112,21 @@ func main() {
 	// 示例订阅
 	symbols := []string{"600000", "000001", "00700", "AAPL"}
 
+	// 已从状态文件恢复的订阅保持原有间隔
+	active := make(map[string]bool)
+	for _, sub := range manager.GetSubscriptions() {
+		active[sub.Symbol] = true
+	}
 	for _, symbol := range symbols {
-		err := manager.Subscribe(symbol, 6*time.Second, func(data core.StockData) error {
-			log.Infof("收到 %s (%s) 数据: 价格=%.2f, 涨跌=%+.2f (%.2f%%), 成交量=%d, 买一=%.2f(%d), 卖一=%.2f(%d), 时间=%s",
-				data.Symbol, data.Name, data.Price, data.Change, data.ChangePercent,
-				data.Volume, data.BidPrice1, data.BidVolume1, data.AskPrice1, data.AskVolume1,
-				data.Timestamp.Format("15:04:05"))
-			return nil
-		})
+		if active[symbol] {
+			continue
+		}
+		var err error
+		if *stateFile != "" {
+			err = manager.SubscribeNamed(symbol, 6*time.Second, "log", subscriber.DeliveryOptions{})
+		} else {
+			err = manager.Subscribe(symbol, 6*time.Second, logStockData)
+		}
 
 		if err != nil {
 			log.Errorf("Failed to subscribe to %s: %v", symbol, err)
@@ -137,6 +156,24 @@ func main() {
 	log.Infof("已退出")
 }
 
+// logStockData 打印收到的行情
+func logStockData(data core.StockData) error {
+	log.Infof("收到 %s (%s) 数据: 价格=%.2f, 涨跌=%+.2f (%.2f%%), 成交量=%d, 买一=%.2f(%d), 卖一=%.2f(%d), 时间=%s",
+		data.Symbol, data.Name, data.Price, data.Change, data.ChangePercent,
+		data.Volume, data.BidPrice1, data.BidVolume1, data.AskPrice1, data.AskVolume1,
+		data.Timestamp.Format("15:04:05"))
+	return nil
+}
+
+// callbackByName 恢复订阅时按保存的回调名称构造回调，未命名的订阅同样打印行情
+func callbackByName(name, symbol string) (subscriber.CallbackFunc, error) {
+	switch name {
+	case "", "log":
+		return logStockData, nil
+	}
+	return nil, fmt.Errorf("unknown callback %q", name)
+}
+
 // printStatistics 定期打印统计信息
 func printStatistics(manager *subscriber.Manager) {
 	ticker := time.NewTicker(30 * time.Second)
@@ -147,8 +184,12 @@ func printStatistics(manager *subscriber.Manager) {
 
 		log.Infof("=== 统计信息 ===")
 		log.Infof("订阅总数: %d, 活跃订阅: %d", stats.TotalSubscriptions, stats.ActiveSubscriptions)
-		log.Infof("数据点总数: %d, 错误总数: %d", stats.TotalDataPoints, stats.TotalErrors)
+		log.Infof("数据点总数: %d, 错误总数: %d, 未变化跳过: %d", stats.TotalDataPoints, stats.TotalErrors, stats.TotalSuppressed)
 		log.Infof("运行时间: %v", time.Since(stats.StartTime).Round(time.Second))
+		if stats.BatchStats != nil {
+			log.Infof("提供商调用: %d, 平均批量: %.1f, 节省调用: %d",
+				stats.BatchStats.ProviderCalls, stats.BatchStats.AverageBatchSize, stats.BatchStats.CallsSaved)
+		}
 
 		// 打印各股票统计
 		for symbol, subStats := range stats.SubscriptionStats {
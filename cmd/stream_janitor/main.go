@@ -0,0 +1,91 @@
+// stream_janitor 定期按保留时长裁剪 Redis Streams
+//
+// 默认不会删除任何消费者组尚未投递或尚未确认的条目，消费者组落后时只裁剪到其进度为止；
+// --force 忽略消费者组进度，落后的消费者会丢失被裁剪的消息。
+package main
+
+import (
+	"context"
+	"flag"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/consumer"
+	"stocksub/pkg/logger"
+)
+
+var (
+	redisAddr = flag.String("redis", "localhost:6379", "Redis 服务器地址")
+	redisPass = flag.String("redis-pass", "", "Redis 密码")
+	streams   = flag.String("streams", "stream:stock:realtime,stream:index:realtime", "需要裁剪的流，逗号分隔")
+	retention = flag.Duration("retention", 24*time.Hour, "条目保留时长")
+	interval  = flag.Duration("interval", 10*time.Minute, "清理间隔")
+	once      = flag.Bool("once", false, "只清理一次后退出")
+	force     = flag.Bool("force", false, "忽略消费者组进度，可能删除尚未确认的消息")
+	logLevel  = flag.String("log-level", "info", "日志级别")
+	logFormat = flag.String("log-format", "json", "日志格式 (json 或 text)")
+)
+
+func main() {
+	flag.Parse()
+
+	logger.Init(logger.Config{
+		Level:  *logLevel,
+		Format: *logFormat,
+	})
+	log := logger.WithComponent("stream_janitor")
+
+	var names []string
+	for _, name := range strings.Split(*streams, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		log.Fatal("没有需要裁剪的流 (--streams)")
+	}
+	if *force {
+		log.Warn("已启用 --force，落后的消费者组可能丢失消息")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: *redisAddr, Password: *redisPass})
+	defer client.Close()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+	err := client.Ping(pingCtx).Err()
+	pingCancel()
+	if err != nil {
+		log.Fatalf("无法连接到 Redis: %v", err)
+	}
+
+	janitor := consumer.NewJanitor(client, consumer.JanitorConfig{
+		Streams:   names,
+		Retention: *retention,
+		Interval:  *interval,
+		Force:     *force,
+	}, logger.GetLogger())
+
+	if *once {
+		var trimmed int64
+		for _, result := range janitor.TrimOnce(ctx) {
+			trimmed += result.Trimmed
+		}
+		log.WithField("trimmed", trimmed).Info("清理完成")
+		return
+	}
+
+	log.WithFields(map[string]interface{}{
+		"streams":   names,
+		"retention": retention.String(),
+		"interval":  interval.String(),
+	}).Info("Stream Janitor 运行中，按 Ctrl+C 停止...")
+	janitor.Run(ctx)
+	log.Info("Stream Janitor 已停止")
+}
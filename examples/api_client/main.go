@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"stocksub/pkg/client"
+)
+
+// 演示如何通过 pkg/client 调用 api_server
+func main() {
+	baseURL := flag.String("base-url", client.DefaultBaseURL, "api_server 地址")
+	apiKey := flag.String("api-key", os.Getenv("STOCKSUB_API_KEY"), "API Key，默认取 STOCKSUB_API_KEY")
+	flag.Parse()
+
+	fmt.Println("=== API 客户端示例 ===")
+
+	c := client.New(
+		client.WithBaseURL(*baseURL),
+		client.WithAPIKey(*apiKey),
+		client.WithTimeout(5*time.Second),
+		client.WithRetryPolicy(client.RetryPolicy{MaxRetries: 3, Backoff: 500 * time.Millisecond, MaxWait: 5 * time.Second}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 1. 单只股票
+	fmt.Println("\n--- 单只股票 ---")
+	stock, err := c.GetStock(ctx, "600000")
+	switch {
+	case errors.Is(err, client.ErrMarketClosed):
+		fmt.Println("当前休市")
+	case errors.Is(err, client.ErrNotFound):
+		fmt.Println("没有 600000 的数据")
+	case err != nil:
+		log.Printf("获取股票失败: %v", err)
+	default:
+		fmt.Printf("%s (%s): ¥%.2f, 涨跌: %.2f (%.2f%%), trace: %s\n",
+			stock.Symbol, stock.Name, stock.Price, stock.Change, stock.ChangePercent, stock.TraceID)
+	}
+
+	// 2. 批量查询
+	fmt.Println("\n--- 批量查询 ---")
+	batch, err := c.GetStocks(ctx, "600000", "000001", "300750")
+	if err != nil {
+		log.Printf("批量查询失败: %v", err)
+	} else {
+		for _, s := range batch.Data {
+			fmt.Printf("  %s (%s): ¥%.2f\n", s.Symbol, s.Name, s.Price)
+		}
+		if len(batch.Missing) > 0 {
+			fmt.Printf("  没有数据: %v\n", batch.Missing)
+		}
+	}
+
+	// 3. 指数
+	fmt.Println("\n--- 指数 ---")
+	indices, err := c.GetIndices(ctx)
+	if err != nil {
+		log.Printf("获取指数失败: %v", err)
+	} else {
+		for _, index := range indices {
+			fmt.Printf("  %s (%s): %.2f (%.2f%%)\n", index.Symbol, index.Name, index.Value, index.ChangePercent)
+		}
+	}
+
+	// 4. 最近一小时的 5 分钟 K 线
+	fmt.Println("\n--- 历史 K 线 ---")
+	end := time.Now()
+	history, err := c.GetStockHistory(ctx, "600000", end.Add(-time.Hour), end, "5m")
+	if err != nil {
+		var apiErr *client.APIError
+		if errors.As(err, &apiErr) {
+			log.Printf("获取历史数据失败: %d %s %s", apiErr.StatusCode, apiErr.Response.Code, apiErr.Response.Message)
+		} else {
+			log.Printf("获取历史数据失败: %v", err)
+		}
+	} else {
+		fmt.Printf("共 %d 根 K 线，截断: %t\n", len(history.Bars), history.Truncated)
+		for _, bar := range history.Bars {
+			fmt.Printf("  %s 开 %.2f 高 %.2f 低 %.2f 收 %.2f 量 %d\n",
+				bar.Timestamp.Format("15:04"), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume)
+		}
+	}
+
+	fmt.Println("\n=== 示例结束 ===")
+}
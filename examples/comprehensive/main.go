@@ -180,7 +180,25 @@ func (ce *ComprehensiveExample) SetupProviders() {
 	
 	// 5. 创建指数提供商
 	indexProvider := &MockIndexProvider{}
-	indexDecorators := decorators.TestDecoratorConfig()
+	// 指数提供商同样支持频率控制和熔断装饰器
+	indexDecorators := provider.ProviderDecoratorConfig{
+		Index: []provider.DecoratorConfig{
+			{
+				Type:         provider.FrequencyControlType,
+				Enabled:      true,
+				Priority:     1,
+				ProviderType: "index",
+				Config:       map[string]interface{}{"min_interval_ms": 100, "max_retries": 1, "enabled": true},
+			},
+			{
+				Type:         provider.CircuitBreakerType,
+				Enabled:      true,
+				Priority:     2,
+				ProviderType: "index",
+				Config:       map[string]interface{}{"name": "MockIndexProvider", "ready_to_trip": 3, "enabled": true},
+			},
+		},
+	}
 	
 	decoratedIndexProvider, err := decorators.CreateDecoratedProvider(indexProvider, indexDecorators)
 	if err != nil {
@@ -3,97 +3,32 @@ package main
 import (
 	"context"
 	"fmt"
-	"stocksub/pkg/core"
 	"stocksub/pkg/provider/decorators"
+	"stocksub/pkg/testkit/providers"
 	"time"
 )
 
-// UnreliableStockProvider 不可靠的股票数据提供商，用于演示熔断器
-type UnreliableStockProvider struct {
-	failureCount int
-	maxFailures  int
-	shouldFail   bool
-}
-
-// NewUnreliableStockProvider 创建不可靠的股票数据提供商
-func NewUnreliableStockProvider(maxFailures int) *UnreliableStockProvider {
-	return &UnreliableStockProvider{
-		maxFailures: maxFailures,
-		shouldFail:  true, // 默认开始就失败
-	}
-}
-
-func (p *UnreliableStockProvider) Name() string {
-	return "UnreliableStock"
-}
-
-func (p *UnreliableStockProvider) IsHealthy() bool {
-	return !p.shouldFail
-}
-
-func (p *UnreliableStockProvider) GetRateLimit() time.Duration {
-	return 100 * time.Millisecond
-}
-
-func (p *UnreliableStockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
-	// 模拟失败的逻辑
-	if p.shouldFail && p.failureCount < p.maxFailures {
-		p.failureCount++
-		return nil, fmt.Errorf("模拟的API错误 - 失败次数: %d", p.failureCount)
-	}
-
-	// 达到最大失败次数后开始返回成功
-	if p.failureCount >= p.maxFailures {
-		p.shouldFail = false
-	}
-
-	// 返回成功的数据
-	result := make([]core.StockData, 0, len(symbols))
-	for _, symbol := range symbols {
-		result = append(result, core.StockData{
-			Symbol:        symbol,
-			Name:          fmt.Sprintf("股票%s", symbol),
-			Price:         12.34 + float64(len(result)),
-			Change:        0.56,
-			ChangePercent: 4.75,
-			Volume:        1000000,
-			Timestamp:     time.Now(),
-		})
-	}
-	return result, nil
-}
-
-func (p *UnreliableStockProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
-	data, err := p.FetchStockData(ctx, symbols)
-	if err != nil {
-		return nil, "", err
-	}
-	raw := fmt.Sprintf("Raw response for symbols: %v", symbols)
-	return data, raw, nil
-}
-
-func (p *UnreliableStockProvider) IsSymbolSupported(symbol string) bool {
-	return true
-}
-
-// SetFailureMode 设置失败模式
-func (p *UnreliableStockProvider) SetFailureMode(shouldFail bool) {
-	p.shouldFail = shouldFail
-	if !shouldFail {
-		p.failureCount = 0
+// failingSteps 连续 n 次失败的脚本步骤
+func failingSteps(n int) []providers.Step {
+	steps := make([]providers.Step, n)
+	for i := range steps {
+		steps[i] = providers.Step{Delay: 20 * time.Millisecond, Err: fmt.Errorf("模拟的API错误 - 第%d次失败", i+1)}
 	}
+	return steps
 }
 
-// GetFailureCount 获取失败次数
-func (p *UnreliableStockProvider) GetFailureCount() int {
-	return p.failureCount
+// newUnreliableProvider 创建前 maxFailures 次请求失败、之后恢复正常的 MockProvider
+func newUnreliableProvider(maxFailures int) *providers.MockProvider {
+	mock := providers.NewMockProvider(providers.MockProviderConfig{EnableDataGen: true})
+	mock.SetScenario("*", failingSteps(maxFailures))
+	return mock
 }
 
 func main() {
 	fmt.Println("=== 熔断器装饰器示例 ===")
 
-	// 创建不可靠的股票数据提供商
-	unreliableProvider := NewUnreliableStockProvider(5) // 5次失败后恢复
+	// 创建由脚本场景驱动的不可靠提供商
+	unreliableProvider := newUnreliableProvider(5) // 5次失败后恢复
 
 	// 创建熔断器配置
 	config := &decorators.CircuitBreakerConfig{
@@ -147,8 +82,8 @@ func main() {
 	fmt.Println("等待5秒让熔断器从打开状态恢复...")
 	time.Sleep(6 * time.Second)
 	
-	// 现在提供商应该开始恢复正常
-	unreliableProvider.SetFailureMode(false)
+	// 清除脚本场景，提供商开始返回生成的正常数据
+	unreliableProvider.ClearScenarios()
 	fmt.Println("设置提供商为正常模式")
 	
 	// 测试恢复后的请求
@@ -186,7 +121,7 @@ func main() {
 	fmt.Println("禁用熔断器...")
 	
 	// 重新设置提供商为失败模式
-	unreliableProvider.SetFailureMode(true)
+	unreliableProvider.SetScenario("*", failingSteps(5))
 	
 	// 测试禁用熔断器后的行为
 	for i := 1; i <= 3; i++ {
@@ -221,7 +156,7 @@ func main() {
 	}
 	
 	// 创建另一个不可靠提供商
-	anotherUnreliableProvider := NewUnreliableStockProvider(10)
+	anotherUnreliableProvider := newUnreliableProvider(10)
 	sensitiveCB := decorators.NewCircuitBreakerProvider(anotherUnreliableProvider, sensitiveConfig)
 	
 	fmt.Printf("敏感熔断器名称: %s\n", sensitiveCB.Name())
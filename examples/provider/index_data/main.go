@@ -8,6 +8,7 @@ import (
 
 	"stocksub/pkg/core"
 	"stocksub/pkg/provider"
+	"stocksub/pkg/provider/decorators"
 )
 
 // MockIndexProvider 模拟的指数数据提供商，用于演示
@@ -139,10 +140,6 @@ func main() {
 	// 4. 演示使用装饰器
 	fmt.Println("\n--- 使用装饰器的指数提供商示例 ---")
 
-	// 注意：由于当前装饰器主要为 RealtimeStockProvider 和 HistoricalProvider 设计，
-	// 对于 IndexProvider 我们可以创建一个适配器或使用通用装饰器
-	// 这里演示如何在未来扩展装饰器支持时使用
-
 	// 创建自定义装饰器配置，专门用于指数数据
 	customConfig := provider.ProviderDecoratorConfig{
 		Index: []provider.DecoratorConfig{
@@ -174,13 +171,28 @@ func main() {
 		},
 	}
 
-	// 注意：当前的装饰器实现主要支持 RealtimeStockProvider 和 HistoricalProvider
-	// 为了完整的指数提供商支持，需要扩展装饰器框架
-	fmt.Println("指数提供商装饰器配置已准备:")
-	fmt.Printf("  装饰器配置数量: %d\n", len(customConfig.Index))
+	decoratedProvider, err := decorators.CreateDecoratedProvider(indexProvider, customConfig)
+	if err != nil {
+		log.Fatalf("创建装饰后的指数提供商失败: %v", err)
+	}
+
+	decoratedIndexProvider, ok := decoratedProvider.(provider.RealtimeIndexProvider)
+	if !ok {
+		log.Fatalf("装饰后的提供商未实现 RealtimeIndexProvider 接口: %T", decoratedProvider)
+	}
+	fmt.Printf("装饰后的提供商: %s\n", decoratedIndexProvider.Name())
 	fmt.Printf("  频率控制: %dms 间隔\n", 500)
 	fmt.Printf("  熔断器: 最大5个请求，60s 间隔\n")
-	fmt.Println("  (注: 完整的装饰器支持需要进一步的框架扩展)")
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		data, err := decoratedIndexProvider.FetchIndexData(ctx, []string{"sh000001"})
+		if err != nil {
+			log.Printf("第 %d 次获取失败: %v", i+1, err)
+			continue
+		}
+		fmt.Printf("  第 %d 次获取 %d 条数据，耗时 %v\n", i+1, len(data), time.Since(start).Round(time.Millisecond))
+	}
 
 	// 5. 演示指数数据分析
 	fmt.Println("\n--- 指数数据分析示例 ---")
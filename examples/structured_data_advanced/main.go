@@ -197,6 +197,7 @@ func dataPipelineExample() {
 		BatchSize:                 50,
 		FlushInterval:             1 * time.Second,
 		MaxBufferSize:             500,
+		OverflowPolicy:            storage.OverflowBlock, // 写入速度超过存储时阻塞，缓冲区不会无限增长
 		EnableAsync:               true,
 		EnableStructuredDataOptim: true,
 		StructuredDataBatchSize:   25,
@@ -214,13 +215,12 @@ func dataPipelineExample() {
 	dataChannel := make(chan *storage.StructuredData, 100)
 	errorChannel := make(chan error, 10)
 
-	// 启动数据生成goroutine
+	// 启动数据生成goroutine：复用一个工作副本，通过 Freeze 把快照交给处理协程
 	go func() {
 		defer close(dataChannel)
 
+		stockData := storage.NewStructuredData(storage.StockDataSchema)
 		for i := 0; i < 100; i++ {
-			stockData := storage.NewStructuredData(storage.StockDataSchema)
-
 			symbol := fmt.Sprintf("60%04d", i%50) // 模拟50只股票
 			name := fmt.Sprintf("股票%d", i%50)
 			price := 10.0 + float64(i%100)*0.1 // 价格在10.0-19.9之间变动
@@ -232,7 +232,7 @@ func dataPipelineExample() {
 			stockData.SetFieldSafe("volume", volume)
 			stockData.SetFieldSafe("timestamp", time.Now().Add(time.Duration(i)*time.Millisecond))
 
-			dataChannel <- stockData
+			dataChannel <- stockData.Freeze()
 
 			// 模拟数据流延迟
 			time.Sleep(10 * time.Millisecond)
@@ -243,14 +243,14 @@ func dataPipelineExample() {
 	go func() {
 		defer close(errorChannel)
 
-		for stockData := range dataChannel {
-			// 数据预处理：添加计算字段
-			price, _ := stockData.GetField("price")
-			volume, _ := stockData.GetField("volume")
-
-			// 计算成交额
-			turnover := price.(float64) * float64(volume.(int64))
-			stockData.SetFieldSafe("turnover", turnover)
+		for snapshot := range dataChannel {
+			// 数据预处理：成交额由模式的计算字段求值，写入前物化到 Values。
+			// 快照不可修改，处理协程在自己的副本上物化
+			stockData := snapshot.Clone()
+			if err := stockData.MaterializeComputed(); err != nil {
+				errorChannel <- err
+				continue
+			}
 
 			// 写入存储
 			if err := batchWriter.Write(ctx, stockData); err != nil {
@@ -42,6 +42,14 @@ func main() {
 	}
 	log.Debug("Subscriber started successfully")
 
+	// 独立的事件消费者只接收错误和退避事件，读取缓慢时丢弃最旧的事件，不会阻塞数据推送
+	events := sub.Events().SubscribeWithOptions(subscriber.EventConsumerOptions{
+		Name:       "simple-example",
+		Buffer:     100,
+		EventTypes: []subscriber.EventType{subscriber.EventTypeError, subscriber.EventTypeDegraded},
+	})
+	go monitorEvents(events)
+
 	// 4. 订阅股票
 	symbols := []string{"600000", "000001"}
 	log.Debugf("About to subscribe to symbols: %v", symbols)
@@ -78,6 +86,21 @@ func main() {
 	<-c
 
 	fmt.Println("\n正在退出...")
+	for _, consumer := range sub.Events().Stats().Consumers {
+		fmt.Printf("事件消费者 %s: 写入 %d, 丢弃 %d\n", consumer.Name, consumer.Delivered, consumer.Dropped)
+	}
 	sub.Stop()
 	fmt.Println("已退出")
 }
+
+// monitorEvents 打印错误和退避事件，事件总线关闭后退出
+func monitorEvents(events <-chan subscriber.UpdateEvent) {
+	for event := range events {
+		switch event.Type {
+		case subscriber.EventTypeError:
+			fmt.Printf("[错误] %s: %v\n", event.Symbol, event.Error)
+		case subscriber.EventTypeDegraded:
+			fmt.Printf("[退避] %s 连续失败，轮询间隔调整为 %v\n", event.Symbol, event.Interval)
+		}
+	}
+}
@@ -54,6 +54,8 @@ func Build() error {
 		{"api_server", "./cmd/api_server"},
 		{"redis_collector", "./cmd/redis_collector"},
 		{"influxdb_collector", "./cmd/influxdb_collector"},
+		{"csv_backfill", "./cmd/csv_backfill"},
+		{"stream_janitor", "./cmd/stream_janitor"},
 	}
 
 	fmt.Println("🚀 开始构建 StockSub 组件...")
@@ -0,0 +1,229 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+// DefaultRefreshInterval 从 RuleStore 重新加载规则的默认间隔
+const DefaultRefreshInterval = 10 * time.Second
+
+// Engine 对每批实时行情评估告警规则。
+//
+// 规则来自配置文件中的静态规则和 RuleStore，静态规则与存储中的规则 ID 相同时以静态规则为准。
+// 冷却时间和成交量样本保存在进程内，多个 redis_collector 实例之间不共享。
+type Engine struct {
+	static          []Rule
+	store           RuleStore // 为 nil 时只使用静态规则
+	refreshInterval time.Duration
+	now             func() time.Time
+
+	mu          sync.Mutex
+	bySymbol    map[string][]Rule
+	loadedAt    time.Time
+	lastFired   map[string]time.Time // 规则 ID -> 最近一次触发时间
+	lastVolumes map[string]int64     // 规则 ID -> 上一次行情的当日累计成交量
+	volumes     map[string][]int64   // 规则 ID -> 最近的成交量增量样本
+}
+
+// NewEngine 创建规则引擎，静态规则无效时返回错误；refreshInterval 为 0 时使用 DefaultRefreshInterval
+func NewEngine(static []Rule, store RuleStore, refreshInterval time.Duration) (*Engine, error) {
+	for i := range static {
+		if err := static[i].Validate(); err != nil {
+			return nil, fmt.Errorf("静态规则 %d: %w", i, err)
+		}
+	}
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	e := &Engine{
+		static:          static,
+		store:           store,
+		refreshInterval: refreshInterval,
+		now:             time.Now,
+		lastFired:       make(map[string]time.Time),
+		lastVolumes:     make(map[string]int64),
+		volumes:         make(map[string][]int64),
+	}
+	e.setRules(nil)
+	return e, nil
+}
+
+// Evaluate 评估一批行情，返回需要发出的通知。
+// 返回的错误只表示重新加载规则失败，此时继续使用上次加载的规则完成评估。
+func (e *Engine) Evaluate(ctx context.Context, stocks []message.StockData) ([]Notification, error) {
+	refreshErr := e.refresh(ctx)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	var notifications []Notification
+	for _, stock := range stocks {
+		for _, rule := range e.bySymbol[core.NormalizeSymbol(stock.Symbol)] {
+			value, fired := e.check(rule, stock)
+			if !fired {
+				continue
+			}
+			if last, ok := e.lastFired[rule.ID]; ok && now.Sub(last) < rule.Cooldown() {
+				continue
+			}
+			e.lastFired[rule.ID] = now
+			notifications = append(notifications, newNotification(rule, stock, value, now))
+		}
+	}
+	return notifications, refreshErr
+}
+
+// check 判断行情是否满足规则，返回触发时的观测值
+func (e *Engine) check(rule Rule, stock message.StockData) (float64, bool) {
+	switch rule.Type {
+	case RulePriceAbove:
+		return stock.Price, stock.Price > rule.Threshold
+	case RulePriceBelow:
+		return stock.Price, stock.Price > 0 && stock.Price < rule.Threshold
+	case RuleChangePercent:
+		return stock.ChangePercent, math.Abs(stock.ChangePercent) >= rule.Threshold
+	case RuleVolumeSpike:
+		return e.checkVolumeSpike(rule, stock.Volume)
+	default:
+		return 0, false
+	}
+}
+
+// checkVolumeSpike 行情中的成交量是当日累计值，先与该规则上一次的累计值相减得到本次间隔的增量，
+// 再与之前的增量样本均值比较并加入样本；样本不足时不触发。
+// 累计值变小表示进入新的交易日，清空样本重新积累；增量为 0（如午间休市）时不计入样本
+func (e *Engine) checkVolumeSpike(rule Rule, volume int64) (float64, bool) {
+	prev, seen := e.lastVolumes[rule.ID]
+	e.lastVolumes[rule.ID] = volume
+	if !seen {
+		return 0, false
+	}
+	if volume < prev {
+		delete(e.volumes, rule.ID)
+		return 0, false
+	}
+	delta := volume - prev
+	if delta == 0 {
+		return 0, false
+	}
+
+	samples := e.volumes[rule.ID]
+	ratio, fired := 0.0, false
+	if len(samples) >= rule.samples() {
+		var sum int64
+		for _, v := range samples {
+			sum += v
+		}
+		if avg := float64(sum) / float64(len(samples)); avg > 0 {
+			ratio = float64(delta) / avg
+			fired = ratio >= rule.Threshold
+		}
+	}
+
+	samples = append(samples, delta)
+	if len(samples) > rule.samples() {
+		samples = samples[len(samples)-rule.samples():]
+	}
+	e.volumes[rule.ID] = samples
+	return ratio, fired
+}
+
+// refresh 按 refreshInterval 从 RuleStore 重新加载规则
+func (e *Engine) refresh(ctx context.Context) error {
+	if e.store == nil {
+		return nil
+	}
+	e.mu.Lock()
+	due := e.now().Sub(e.loadedAt) >= e.refreshInterval
+	e.mu.Unlock()
+	if !due {
+		return nil
+	}
+
+	stored, err := e.store.List(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// 失败时同样推迟下一次加载，避免 Redis 故障时每批都重试
+	e.loadedAt = e.now()
+	if err != nil {
+		return err
+	}
+	e.setRules(stored)
+	return nil
+}
+
+// setRules 合并静态规则和存储中的规则，并清理已删除规则的状态，调用方需持有锁
+func (e *Engine) setRules(stored []Rule) {
+	merged := make(map[string]Rule, len(stored)+len(e.static))
+	for _, rule := range stored {
+		if rule.Validate() == nil {
+			merged[rule.ID] = rule
+		}
+	}
+	for _, rule := range e.static {
+		merged[rule.ID] = rule
+	}
+
+	// 规则和行情中的代码都按规范形式匹配，规则可以写成 600000、sh600000 或 600000.SH
+	e.bySymbol = make(map[string][]Rule)
+	for _, rule := range merged {
+		symbol := core.NormalizeSymbol(rule.Symbol)
+		e.bySymbol[symbol] = append(e.bySymbol[symbol], rule)
+	}
+	for id := range e.lastFired {
+		if _, ok := merged[id]; !ok {
+			delete(e.lastFired, id)
+		}
+	}
+	for id := range e.volumes {
+		if _, ok := merged[id]; !ok {
+			delete(e.volumes, id)
+		}
+	}
+	for id := range e.lastVolumes {
+		if _, ok := merged[id]; !ok {
+			delete(e.lastVolumes, id)
+		}
+	}
+}
+
+// newNotification 生成规则触发的通知
+func newNotification(rule Rule, stock message.StockData, value float64, at time.Time) Notification {
+	threshold := strconv.FormatFloat(rule.Threshold, 'f', -1, 64)
+	var text string
+	switch rule.Type {
+	case RulePriceAbove:
+		text = fmt.Sprintf("%s 价格 %.2f 高于 %s", stock.Symbol, value, threshold)
+	case RulePriceBelow:
+		text = fmt.Sprintf("%s 价格 %.2f 低于 %s", stock.Symbol, value, threshold)
+	case RuleChangePercent:
+		text = fmt.Sprintf("%s 涨跌幅 %.2f%% 超过 ±%s%%", stock.Symbol, value, threshold)
+	case RuleVolumeSpike:
+		text = fmt.Sprintf("%s 成交量增量为最近 %d 个间隔均值的 %.1f 倍", stock.Symbol, rule.samples(), value)
+	}
+	if rule.Description != "" {
+		text = rule.Description + ": " + text
+	}
+	return Notification{
+		RuleID:        rule.ID,
+		Symbol:        stock.Symbol,
+		Name:          stock.Name,
+		Type:          rule.Type,
+		Threshold:     rule.Threshold,
+		Value:         value,
+		Price:         stock.Price,
+		ChangePercent: stock.ChangePercent,
+		Volume:        stock.Volume,
+		Message:       text,
+		TriggeredAt:   at,
+	}
+}
@@ -0,0 +1,253 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/message"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestEngine(t *testing.T, rules []Rule, store RuleStore) (*Engine, *fakeClock) {
+	t.Helper()
+	engine, err := NewEngine(rules, store, time.Minute)
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)}
+	engine.now = clock.Now
+	return engine, clock
+}
+
+// ruleIDs 返回通知对应的规则 ID
+func ruleIDs(notifications []Notification) []string {
+	ids := make([]string, len(notifications))
+	for i, n := range notifications {
+		ids[i] = n.RuleID
+	}
+	return ids
+}
+
+func TestEngine_PriceAndChangePercentRules(t *testing.T) {
+	engine, clock := newTestEngine(t, []Rule{
+		{ID: "above", Symbol: "600000", Type: RulePriceAbove, Threshold: 11},
+		{ID: "below", Symbol: "600000", Type: RulePriceBelow, Threshold: 10},
+		{ID: "drop", Symbol: "600000", Type: RuleChangePercent, Threshold: 3},
+		{ID: "other", Symbol: "000001", Type: RulePriceAbove, Threshold: 1},
+	}, nil)
+	ctx := context.Background()
+
+	sequence := []struct {
+		price, changePercent float64
+		want                 []string
+	}{
+		{price: 10.8, changePercent: 1.2},
+		{price: 11.05, changePercent: 3.5, want: []string{"above", "drop"}},
+		{price: 10.5, changePercent: -0.5},
+		{price: 9.7, changePercent: -3.2, want: []string{"below"}}, // drop 仍在冷却期
+	}
+	for i, step := range sequence {
+		notifications, err := engine.Evaluate(ctx, []message.StockData{
+			{Symbol: "600000", Name: "浦发银行", Price: step.price, ChangePercent: step.changePercent},
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, step.want, ruleIDs(notifications), "step %d", i)
+		clock.Advance(time.Minute)
+	}
+}
+
+func TestEngine_NotificationContent(t *testing.T) {
+	engine, clock := newTestEngine(t, []Rule{
+		{ID: "above", Symbol: "600000", Type: RulePriceAbove, Threshold: 11, Description: "突破"},
+	}, nil)
+
+	notifications, err := engine.Evaluate(context.Background(), []message.StockData{
+		{Symbol: "600000", Name: "浦发银行", Price: 11.2, ChangePercent: 2.5, Volume: 1000},
+	})
+	require.NoError(t, err)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, Notification{
+		RuleID:        "above",
+		Symbol:        "600000",
+		Name:          "浦发银行",
+		Type:          RulePriceAbove,
+		Threshold:     11,
+		Value:         11.2,
+		Price:         11.2,
+		ChangePercent: 2.5,
+		Volume:        1000,
+		Message:       "突破: 600000 价格 11.20 高于 11",
+		TriggeredAt:   clock.now,
+	}, notifications[0])
+}
+
+func TestEngine_MatchesAnySymbolForm(t *testing.T) {
+	engine, _ := newTestEngine(t, []Rule{
+		{ID: "bare", Symbol: "600000", Type: RulePriceAbove, Threshold: 11},
+		{ID: "suffix", Symbol: "600000.SH", Type: RulePriceAbove, Threshold: 11},
+		{ID: "other", Symbol: "sz000001", Type: RulePriceAbove, Threshold: 11},
+	}, nil)
+
+	notifications, err := engine.Evaluate(context.Background(), []message.StockData{
+		{Symbol: "sh600000", Price: 11.2},
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bare", "suffix"}, ruleIDs(notifications))
+}
+
+func TestEngine_CooldownPreventsRefiring(t *testing.T) {
+	engine, clock := newTestEngine(t, []Rule{
+		{ID: "above", Symbol: "600000", Type: RulePriceAbove, Threshold: 11, CooldownSeconds: 600},
+	}, nil)
+	ctx := context.Background()
+	tick := []message.StockData{{Symbol: "600000", Price: 11.5}}
+
+	fired := 0
+	// 条件持续满足 20 分钟，每 30 秒一批行情
+	for i := 0; i < 40; i++ {
+		notifications, err := engine.Evaluate(ctx, tick)
+		require.NoError(t, err)
+		fired += len(notifications)
+		if i == 19 {
+			assert.Equal(t, 1, fired, "must not fire twice within the 10 minute window")
+		}
+		clock.Advance(30 * time.Second)
+	}
+	assert.Equal(t, 2, fired, "fires once per cooldown window while the condition holds")
+}
+
+func TestEngine_CooldownAppliesWithinOneBatch(t *testing.T) {
+	engine, _ := newTestEngine(t, []Rule{
+		{ID: "above", Symbol: "600000", Type: RulePriceAbove, Threshold: 11},
+	}, nil)
+
+	notifications, err := engine.Evaluate(context.Background(), []message.StockData{
+		{Symbol: "600000", Price: 11.1},
+		{Symbol: "600000", Price: 11.3},
+	})
+	require.NoError(t, err)
+	assert.Len(t, notifications, 1)
+}
+
+func TestEngine_VolumeSpike(t *testing.T) {
+	engine, clock := newTestEngine(t, []Rule{
+		{ID: "spike", Symbol: "600000", Type: RuleVolumeSpike, Threshold: 3, Samples: 4, CooldownSeconds: 1},
+	}, nil)
+	ctx := context.Background()
+
+	// 行情中的成交量是当日累计值，规则比较的是相邻两次行情之间的增量
+	sequence := []struct {
+		volume int64
+		fired  bool
+	}{
+		{volume: 10000}, // 第一次行情没有增量
+		{volume: 10100}, {volume: 10220}, {volume: 10300},
+		{volume: 10800}, // 增量 500，样本不足 4 个，不触发
+		{volume: 10900},
+		{volume: 10900},              // 增量为 0，不计入样本
+		{volume: 11900, fired: true}, // 增量 1000，均值 (120+80+500+100)/4=200，5 倍
+		{volume: 12800},              // 增量 900，均值 (80+500+100+1000)/4=420，不足 3 倍
+		{volume: 300},                // 累计值变小，新的交易日，清空样本
+		{volume: 400}, {volume: 500}, {volume: 600},
+		{volume: 2000}, // 增量 1400，新交易日的样本只有 3 个，不触发
+	}
+	for i, step := range sequence {
+		notifications, err := engine.Evaluate(ctx, []message.StockData{{Symbol: "600000", Price: 10, Volume: step.volume}})
+		require.NoError(t, err)
+		if step.fired {
+			require.Len(t, notifications, 1, "step %d", i)
+			assert.Equal(t, 5.0, notifications[0].Value)
+			assert.Equal(t, step.volume, notifications[0].Volume)
+		} else {
+			assert.Empty(t, notifications, "step %d", i)
+		}
+		clock.Advance(time.Minute)
+	}
+}
+
+// fakeRuleStore 内存规则存储，err 非空时 List 失败
+type fakeRuleStore struct {
+	rules []Rule
+	err   error
+	lists int
+}
+
+func (f *fakeRuleStore) List(ctx context.Context) ([]Rule, error) {
+	f.lists++
+	return f.rules, f.err
+}
+func (f *fakeRuleStore) Get(ctx context.Context, id string) (*Rule, error) {
+	return nil, ErrRuleNotFound
+}
+func (f *fakeRuleStore) Save(ctx context.Context, rule Rule) error   { return nil }
+func (f *fakeRuleStore) Delete(ctx context.Context, id string) error { return nil }
+
+func TestEngine_RefreshesStoreRules(t *testing.T) {
+	store := &fakeRuleStore{rules: []Rule{{ID: "api", Symbol: "600000", Type: RulePriceAbove, Threshold: 11}}}
+	engine, clock := newTestEngine(t, []Rule{{ID: "api", Symbol: "600000", Type: RulePriceAbove, Threshold: 20}}, store)
+	ctx := context.Background()
+	tick := []message.StockData{{Symbol: "600000", Price: 12}}
+
+	// 静态规则与存储规则 ID 相同时以静态规则为准
+	notifications, err := engine.Evaluate(ctx, tick)
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+
+	store.rules = append(store.rules, Rule{ID: "new", Symbol: "600000", Type: RulePriceAbove, Threshold: 11.5})
+	clock.Advance(30 * time.Second)
+	notifications, _ = engine.Evaluate(ctx, tick)
+	assert.Empty(t, notifications, "rules are cached until the refresh interval")
+	assert.Equal(t, 1, store.lists)
+
+	clock.Advance(30 * time.Second)
+	notifications, err = engine.Evaluate(ctx, tick)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, ruleIDs(notifications))
+
+	// 加载失败时继续使用上次的规则
+	store.err = errors.New("connection refused")
+	clock.Advance(10 * time.Minute)
+	notifications, err = engine.Evaluate(ctx, tick)
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, []string{"new"}, ruleIDs(notifications))
+}
+
+func TestNewEngine_RejectsInvalidStaticRules(t *testing.T) {
+	_, err := NewEngine([]Rule{{ID: "bad", Symbol: "600000", Type: "price_cross"}}, nil, 0)
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestRule_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		ok   bool
+	}{
+		{name: "价格", rule: Rule{ID: "a", Symbol: "600000", Type: RulePriceBelow, Threshold: 10}, ok: true},
+		{name: "成交量", rule: Rule{ID: "a", Symbol: "600000", Type: RuleVolumeSpike, Threshold: 2}, ok: true},
+		{name: "缺少 ID", rule: Rule{Symbol: "600000", Type: RulePriceAbove, Threshold: 10}},
+		{name: "缺少代码", rule: Rule{ID: "a", Type: RulePriceAbove, Threshold: 10}},
+		{name: "阈值为零", rule: Rule{ID: "a", Symbol: "600000", Type: RuleChangePercent}},
+		{name: "放大倍数不大于 1", rule: Rule{ID: "a", Symbol: "600000", Type: RuleVolumeSpike, Threshold: 1}},
+		{name: "负冷却时间", rule: Rule{ID: "a", Symbol: "600000", Type: RulePriceAbove, Threshold: 10, CooldownSeconds: -1}},
+		{name: "未知类型", rule: Rule{ID: "a", Symbol: "600000", Type: "unknown", Threshold: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+			}
+		})
+	}
+}
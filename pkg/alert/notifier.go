@@ -0,0 +1,206 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultStream 告警通知写入的 Redis Stream
+	DefaultStream = "stream:alerts"
+
+	defaultStreamMaxLen   = 10000
+	defaultWebhookTimeout = 5 * time.Second
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = time.Second
+	defaultWebhookQueue   = 100
+)
+
+// ErrQueueFull webhook 发送队列已满，通知被丢弃
+var ErrQueueFull = errors.New("webhook queue full")
+
+// Notifier 发送告警通知
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Notifiers 依次发送到多个 Notifier，返回所有发送失败的错误
+type Notifiers []Notifier
+
+var _ Notifier = Notifiers(nil)
+
+func (ns Notifiers) Notify(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, notifier := range ns {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// streamPublisher 写通知用到的 Redis 命令，*redis.Client 满足该接口
+type streamPublisher interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+}
+
+// StreamNotifier 将通知以 JSON 写入 Redis Stream 的 data 字段，流长度近似限制为 10000 条
+type StreamNotifier struct {
+	client streamPublisher
+	stream string
+}
+
+var _ Notifier = (*StreamNotifier)(nil)
+
+// NewStreamNotifier 创建 Stream 通知器，stream 为空时使用 DefaultStream
+func NewStreamNotifier(client streamPublisher, stream string) *StreamNotifier {
+	if stream == "" {
+		stream = DefaultStream
+	}
+	return &StreamNotifier{client: client, stream: stream}
+}
+
+func (s *StreamNotifier) Notify(ctx context.Context, n Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("序列化告警通知失败: %w", err)
+	}
+	err = s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: defaultStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"rule_id": n.RuleID, "symbol": n.Symbol, "data": string(data)},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("写入告警流 %s 失败: %w", s.stream, err)
+	}
+	return nil
+}
+
+// WebhookConfig webhook 通知配置
+type WebhookConfig struct {
+	URL          string        `mapstructure:"url"`           // 为空时不发送 webhook
+	Timeout      time.Duration `mapstructure:"timeout"`       // 单次请求超时
+	MaxRetries   int           `mapstructure:"max_retries"`   // 失败后的最大重试次数，0 使用默认值 3，负数表示不重试
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"` // 首次重试等待时间，之后按次数翻倍
+	QueueSize    int           `mapstructure:"queue_size"`    // 待发送通知的队列长度
+}
+
+// WebhookNotifier 在后台把通知 POST 到 webhook，网络错误、429 和 5xx 响应按指数退避重试。
+// Notify 只把通知放入队列，不阻塞行情处理；需要调用 Run 启动发送循环。
+type WebhookNotifier struct {
+	config WebhookConfig
+	client *http.Client
+	queue  chan Notification
+	logger *logrus.Logger
+}
+
+var _ Notifier = (*WebhookNotifier)(nil)
+
+// NewWebhookNotifier 创建 webhook 通知器，未设置的配置项使用默认值
+func NewWebhookNotifier(config WebhookConfig, logger *logrus.Logger) *WebhookNotifier {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultWebhookTimeout
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultWebhookRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultWebhookBackoff
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultWebhookQueue
+	}
+	return &WebhookNotifier{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		queue:  make(chan Notification, config.QueueSize),
+		logger: logger,
+	}
+}
+
+// Notify 将通知放入发送队列，队列已满时返回 ErrQueueFull
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	select {
+	case w.queue <- n:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run 发送队列中的通知，直到 ctx 取消
+func (w *WebhookNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-w.queue:
+			if err := w.deliver(ctx, n); err != nil {
+				w.logger.WithError(err).WithFields(logrus.Fields{
+					"rule_id": n.RuleID,
+					"symbol":  n.Symbol,
+				}).Warn("Failed to deliver alert webhook")
+			}
+		}
+	}
+}
+
+// deliver 发送一条通知，失败时按指数退避重试
+func (w *WebhookNotifier) deliver(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("序列化告警通知失败: %w", err)
+	}
+
+	backoff := w.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.config.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post 发送一次请求，返回失败时是否值得重试
+func (w *WebhookNotifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("创建 webhook 请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook 请求失败: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,119 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func TestStreamNotifier_WritesJSON(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	n := Notification{RuleID: "above", Symbol: "600000", Type: RulePriceAbove, Threshold: 11, Value: 11.2}
+	require.NoError(t, NewStreamNotifier(client, "").Notify(ctx, n))
+
+	entries, err := client.XRange(ctx, DefaultStream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "above", entries[0].Values["rule_id"])
+	var decoded Notification
+	require.NoError(t, json.Unmarshal([]byte(entries[0].Values["data"].(string)), &decoded))
+	assert.Equal(t, n, decoded)
+}
+
+func TestWebhookNotifier_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan Notification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var n Notification
+		_ = json.NewDecoder(r.Body).Decode(&n)
+		received <- n
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, RetryBackoff: time.Millisecond}, testLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	require.NoError(t, notifier.Notify(ctx, Notification{RuleID: "above", Symbol: "600000"}))
+	select {
+	case n := <-received:
+		assert.Equal(t, "above", n.RuleID)
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestWebhookNotifier_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, RetryBackoff: time.Millisecond}, testLogger())
+	err := notifier.deliver(context.Background(), Notification{RuleID: "above"})
+	assert.ErrorContains(t, err, "400")
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWebhookNotifier_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	notifier := NewWebhookNotifier(WebhookConfig{URL: server.URL, MaxRetries: 2, RetryBackoff: time.Millisecond}, testLogger())
+	err := notifier.deliver(context.Background(), Notification{RuleID: "above"})
+	assert.ErrorContains(t, err, "500")
+	assert.Equal(t, int32(3), calls.Load(), "one attempt plus two retries")
+}
+
+func TestWebhookNotifier_QueueFull(t *testing.T) {
+	notifier := NewWebhookNotifier(WebhookConfig{URL: "http://127.0.0.1:0", QueueSize: 1}, testLogger())
+	require.NoError(t, notifier.Notify(context.Background(), Notification{}))
+	assert.ErrorIs(t, notifier.Notify(context.Background(), Notification{}), ErrQueueFull)
+}
+
+// failingNotifier 总是返回错误
+type failingNotifier struct{ err error }
+
+func (f failingNotifier) Notify(ctx context.Context, n Notification) error { return f.err }
+
+func TestNotifiers_JoinsErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	err := Notifiers{failingNotifier{errA}, failingNotifier{nil}, failingNotifier{errB}}.Notify(context.Background(), Notification{})
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+	assert.NoError(t, Notifiers{}.Notify(context.Background(), Notification{}))
+}
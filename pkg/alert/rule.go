@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RuleType 告警规则类型
+type RuleType string
+
+const (
+	// RulePriceAbove 价格高于阈值
+	RulePriceAbove RuleType = "price_above"
+	// RulePriceBelow 价格低于阈值
+	RulePriceBelow RuleType = "price_below"
+	// RuleChangePercent 涨跌幅绝对值达到阈值（百分比），例如 3 表示涨跌超过 ±3%
+	RuleChangePercent RuleType = "change_percent"
+	// RuleVolumeSpike 两次行情之间的成交量增量达到最近 Samples 个增量均值的 Threshold 倍
+	RuleVolumeSpike RuleType = "volume_spike"
+)
+
+const (
+	// DefaultCooldown 规则未设置冷却时间时，两次触发之间的最短间隔
+	DefaultCooldown = 5 * time.Minute
+	// DefaultVolumeSamples 成交量放大规则默认参与均值计算的样本数
+	DefaultVolumeSamples = 20
+)
+
+// ErrInvalidRule 规则定义无效
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Rule 告警规则，同时用于 yaml 配置、API 请求体和 Redis 持久化
+type Rule struct {
+	ID              string   `json:"id" mapstructure:"id"`
+	Symbol          string   `json:"symbol" mapstructure:"symbol"`
+	Type            RuleType `json:"type" mapstructure:"type"`
+	Threshold       float64  `json:"threshold" mapstructure:"threshold"`
+	Samples         int      `json:"samples,omitempty" mapstructure:"samples"`                   // 仅 volume_spike 使用
+	CooldownSeconds int      `json:"cooldown_seconds,omitempty" mapstructure:"cooldown_seconds"` // 0 表示使用 DefaultCooldown
+	Description     string   `json:"description,omitempty" mapstructure:"description"`
+}
+
+// Validate 检查规则字段是否完整有效
+func (r *Rule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("%w: id is required", ErrInvalidRule)
+	}
+	if r.Symbol == "" {
+		return fmt.Errorf("%w: symbol is required", ErrInvalidRule)
+	}
+	if r.CooldownSeconds < 0 {
+		return fmt.Errorf("%w: cooldown_seconds must not be negative", ErrInvalidRule)
+	}
+	switch r.Type {
+	case RulePriceAbove, RulePriceBelow, RuleChangePercent:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("%w: threshold must be positive for %s", ErrInvalidRule, r.Type)
+		}
+	case RuleVolumeSpike:
+		if r.Threshold <= 1 {
+			return fmt.Errorf("%w: threshold must be greater than 1 for %s", ErrInvalidRule, r.Type)
+		}
+		if r.Samples < 0 {
+			return fmt.Errorf("%w: samples must not be negative", ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type %q", ErrInvalidRule, r.Type)
+	}
+	return nil
+}
+
+// Cooldown 返回规则两次触发之间的最短间隔
+func (r *Rule) Cooldown() time.Duration {
+	if r.CooldownSeconds > 0 {
+		return time.Duration(r.CooldownSeconds) * time.Second
+	}
+	return DefaultCooldown
+}
+
+// samples 返回成交量放大规则的样本数
+func (r *Rule) samples() int {
+	if r.Samples > 0 {
+		return r.Samples
+	}
+	return DefaultVolumeSamples
+}
+
+// Notification 规则触发时发出的通知
+type Notification struct {
+	RuleID        string    `json:"rule_id"`
+	Symbol        string    `json:"symbol"`
+	Name          string    `json:"name,omitempty"`
+	Type          RuleType  `json:"type"`
+	Threshold     float64   `json:"threshold"`
+	Value         float64   `json:"value"` // 触发时的观测值：价格、涨跌幅或成交量放大倍数
+	Price         float64   `json:"price"`
+	ChangePercent float64   `json:"change_percent"`
+	Volume        int64     `json:"volume"`
+	Message       string    `json:"message"`
+	TriggeredAt   time.Time `json:"triggered_at"`
+}
@@ -0,0 +1,102 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultRulesKey 告警规则在 Redis 中的哈希键，字段为规则 ID，值为规则 JSON
+const DefaultRulesKey = "alert:rules"
+
+// ErrRuleNotFound 规则不存在
+var ErrRuleNotFound = errors.New("alert rule not found")
+
+// RuleStore 告警规则存储
+type RuleStore interface {
+	List(ctx context.Context) ([]Rule, error)
+	Get(ctx context.Context, id string) (*Rule, error)
+	Save(ctx context.Context, rule Rule) error
+	Delete(ctx context.Context, id string) error
+}
+
+// RedisRuleStore 将规则保存在一个 Redis 哈希中，api_server 写入，redis_collector 读取
+type RedisRuleStore struct {
+	client redis.Cmdable
+	key    string
+}
+
+var _ RuleStore = (*RedisRuleStore)(nil)
+
+// NewRedisRuleStore 创建基于 Redis 的规则存储，key 为空时使用 DefaultRulesKey
+func NewRedisRuleStore(client redis.Cmdable, key string) *RedisRuleStore {
+	if key == "" {
+		key = DefaultRulesKey
+	}
+	return &RedisRuleStore{client: client, key: key}
+}
+
+// List 返回所有规则，按 ID 排序；无法解析的规则被跳过
+func (s *RedisRuleStore) List(ctx context.Context) ([]Rule, error) {
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取告警规则失败: %w", err)
+	}
+	rules := make([]Rule, 0, len(values))
+	for _, raw := range values {
+		var rule Rule
+		if err := json.Unmarshal([]byte(raw), &rule); err != nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+// Get 返回指定 ID 的规则，不存在时返回 ErrRuleNotFound
+func (s *RedisRuleStore) Get(ctx context.Context, id string) (*Rule, error) {
+	raw, err := s.client.HGet(ctx, s.key, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取告警规则失败: %w", err)
+	}
+	var rule Rule
+	if err := json.Unmarshal([]byte(raw), &rule); err != nil {
+		return nil, fmt.Errorf("解析告警规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// Save 校验并保存规则，ID 相同时覆盖
+func (s *RedisRuleStore) Save(ctx context.Context, rule Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return fmt.Errorf("序列化告警规则失败: %w", err)
+	}
+	if err := s.client.HSet(ctx, s.key, rule.ID, data).Err(); err != nil {
+		return fmt.Errorf("保存告警规则失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除规则，不存在时返回 ErrRuleNotFound
+func (s *RedisRuleStore) Delete(ctx context.Context, id string) error {
+	n, err := s.client.HDel(ctx, s.key, id).Result()
+	if err != nil {
+		return fmt.Errorf("删除告警规则失败: %w", err)
+	}
+	if n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package alert
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRuleStore_CRUD(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	store := NewRedisRuleStore(client, "")
+	ctx := context.Background()
+
+	b := Rule{ID: "b", Symbol: "600000", Type: RuleChangePercent, Threshold: 3}
+	a := Rule{ID: "a", Symbol: "600000", Type: RulePriceAbove, Threshold: 11, CooldownSeconds: 60}
+	require.NoError(t, store.Save(ctx, b))
+	require.NoError(t, store.Save(ctx, a))
+	assert.True(t, mr.Exists(DefaultRulesKey))
+
+	rules, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{a, b}, rules)
+
+	got, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, a, *got)
+
+	a.Threshold = 12
+	require.NoError(t, store.Save(ctx, a))
+	got, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, got.Threshold)
+
+	require.NoError(t, store.Delete(ctx, "a"))
+	_, err = store.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrRuleNotFound)
+	assert.ErrorIs(t, store.Delete(ctx, "a"), ErrRuleNotFound)
+
+	assert.ErrorIs(t, store.Save(ctx, Rule{ID: "bad"}), ErrInvalidRule)
+}
@@ -0,0 +1,52 @@
+package apitypes
+
+import (
+	"strconv"
+	"time"
+)
+
+// CSVFields 按 timestamp,price,volume 的顺序返回 CSV 字段
+func (p HistoricalDataPoint) CSVFields() []string {
+	return []string{
+		p.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(p.Price, 'f', -1, 64),
+		strconv.FormatInt(p.Volume, 10),
+	}
+}
+
+// CSVFields 按 timestamp,open,high,low,close,volume 的顺序返回 CSV 字段
+func (b HistoricalBar) CSVFields() []string {
+	return []string{
+		b.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(b.Open, 'f', -1, 64),
+		strconv.FormatFloat(b.High, 'f', -1, 64),
+		strconv.FormatFloat(b.Low, 'f', -1, 64),
+		strconv.FormatFloat(b.Close, 'f', -1, 64),
+		strconv.FormatInt(b.Volume, 10),
+	}
+}
+
+// CSVFields 按 timestamp,value,change,change_percent,volume,turnover 的顺序返回 CSV 字段
+func (p IndexHistoricalDataPoint) CSVFields() []string {
+	return []string{
+		p.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(p.Value, 'f', -1, 64),
+		strconv.FormatFloat(p.Change, 'f', -1, 64),
+		strconv.FormatFloat(p.ChangePercent, 'f', -1, 64),
+		strconv.FormatInt(p.Volume, 10),
+		strconv.FormatFloat(p.Turnover, 'f', -1, 64),
+	}
+}
+
+// CSVFields 按 timestamp,open,high,low,close,volume,turnover 的顺序返回 CSV 字段
+func (b IndexHistoricalBar) CSVFields() []string {
+	return []string{
+		b.Timestamp.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(b.Open, 'f', -1, 64),
+		strconv.FormatFloat(b.High, 'f', -1, 64),
+		strconv.FormatFloat(b.Low, 'f', -1, 64),
+		strconv.FormatFloat(b.Close, 'f', -1, 64),
+		strconv.FormatInt(b.Volume, 10),
+		strconv.FormatFloat(b.Turnover, 'f', -1, 64),
+	}
+}
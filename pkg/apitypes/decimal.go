@@ -0,0 +1,115 @@
+package apitypes
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// decimal 按固定精度输出的浮点数，不使用科学计数法并去掉末尾的 0；NaN 和 ±Inf 输出为 null
+type decimal struct {
+	value  float64
+	places int
+}
+
+func priceDecimal(v float64) decimal   { return decimal{v, core.PricePrecision} }
+func percentDecimal(v float64) decimal { return decimal{v, core.PercentPrecision} }
+func amountDecimal(v float64) decimal  { return decimal{v, core.AmountPrecision} }
+
+func (d decimal) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(d.value) || math.IsInf(d.value, 0) {
+		return []byte("null"), nil
+	}
+	return []byte(core.FormatDecimal(d.value, d.places)), nil
+}
+
+// StockJSON StockResponse 的 JSON 结构，字段顺序与 StockResponse 一致；
+// 嵌入 StockResponse 的响应类型需要嵌入 StockJSON，否则提升的 MarshalJSON 会丢掉外层字段
+type StockJSON struct {
+	Symbol        string     `json:"symbol"`
+	Name          string     `json:"name"`
+	Price         decimal    `json:"price"`
+	Change        decimal    `json:"change"`
+	ChangePercent decimal    `json:"change_percent"`
+	Volume        int64      `json:"volume"`
+	Turnover      decimal    `json:"turnover"`
+	Timestamp     time.Time  `json:"timestamp"`
+	Provider      string     `json:"provider"`
+	Market        string     `json:"market"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	AgeSeconds    int64      `json:"age_seconds"`
+	IsStale       bool       `json:"is_stale"`
+	Depth         *OrderBook `json:"depth,omitempty"`
+}
+
+// ToJSON 返回按精度输出的 JSON 结构
+func (s StockResponse) ToJSON() StockJSON {
+	return StockJSON{
+		Symbol:        s.Symbol,
+		Name:          s.Name,
+		Price:         priceDecimal(s.Price),
+		Change:        priceDecimal(s.Change),
+		ChangePercent: percentDecimal(s.ChangePercent),
+		Volume:        s.Volume,
+		Turnover:      amountDecimal(s.Turnover),
+		Timestamp:     s.Timestamp,
+		Provider:      s.Provider,
+		Market:        s.Market,
+		UpdatedAt:     s.UpdatedAt,
+		AgeSeconds:    s.AgeSeconds,
+		IsStale:       s.IsStale,
+		Depth:         s.Depth,
+	}
+}
+
+// MarshalJSON 价格保留 3 位小数，涨跌幅和成交额保留 2 位，去掉提供商数据中的浮点误差
+func (s StockResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToJSON())
+}
+
+// IndexJSON IndexResponse 的 JSON 结构，字段顺序与 IndexResponse 一致
+type IndexJSON struct {
+	Symbol        string    `json:"symbol"`
+	Name          string    `json:"name"`
+	Value         decimal   `json:"value"`
+	Change        decimal   `json:"change"`
+	ChangePercent decimal   `json:"change_percent"`
+	Timestamp     time.Time `json:"timestamp"`
+	Provider      string    `json:"provider"`
+	Market        string    `json:"market"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	AgeSeconds    int64     `json:"age_seconds"`
+	IsStale       bool      `json:"is_stale"`
+}
+
+// ToJSON 返回按精度输出的 JSON 结构
+func (i IndexResponse) ToJSON() IndexJSON {
+	return IndexJSON{
+		Symbol:        i.Symbol,
+		Name:          i.Name,
+		Value:         priceDecimal(i.Value),
+		Change:        priceDecimal(i.Change),
+		ChangePercent: percentDecimal(i.ChangePercent),
+		Timestamp:     i.Timestamp,
+		Provider:      i.Provider,
+		Market:        i.Market,
+		UpdatedAt:     i.UpdatedAt,
+		AgeSeconds:    i.AgeSeconds,
+		IsStale:       i.IsStale,
+	}
+}
+
+// MarshalJSON 点位保留 3 位小数，涨跌幅保留 2 位
+func (i IndexResponse) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.ToJSON())
+}
+
+// MarshalJSON 盘口价格保留 3 位小数
+func (l OrderLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Price  decimal `json:"price"`
+		Volume int64   `json:"volume"`
+	}{priceDecimal(l.Price), l.Volume})
+}
@@ -0,0 +1,131 @@
+// Package apitypes api_server 的请求响应结构，cmd/api_server 和 pkg/client 共用，避免两边的定义不一致
+package apitypes
+
+import (
+	"time"
+
+	apperrors "stocksub/pkg/error"
+)
+
+// StockResponse 单只股票的最新行情
+type StockResponse struct {
+	Symbol        string     `json:"symbol"`
+	Name          string     `json:"name"`
+	Price         float64    `json:"price"`
+	Change        float64    `json:"change"`
+	ChangePercent float64    `json:"change_percent"`
+	Volume        int64      `json:"volume"`
+	Turnover      float64    `json:"turnover"`
+	Timestamp     time.Time  `json:"timestamp"`
+	Provider      string     `json:"provider"`
+	Market        string     `json:"market"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	AgeSeconds    int64      `json:"age_seconds"`     // 响应生成时距 updated_at 的秒数
+	IsStale       bool       `json:"is_stale"`        // 按交易时段判断数据是否已过期，见 api_server 的 staleness 配置
+	Depth         *OrderBook `json:"depth,omitempty"` // 仅在请求带 depth=1 时返回
+	TraceID       string     `json:"-"`               // 写入快照的消息的追踪 ID，通过 X-Data-Trace-ID 返回
+}
+
+// IndexResponse 单个指数的最新行情
+type IndexResponse struct {
+	Symbol        string    `json:"symbol"`
+	Name          string    `json:"name"`
+	Value         float64   `json:"value"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	Timestamp     time.Time `json:"timestamp"`
+	Provider      string    `json:"provider"`
+	Market        string    `json:"market"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	AgeSeconds    int64     `json:"age_seconds"` // 响应生成时距 updated_at 的秒数
+	IsStale       bool      `json:"is_stale"`    // 按交易时段判断数据是否已过期
+	TraceID       string    `json:"-"`           // 写入快照的消息的追踪 ID，通过 X-Data-Trace-ID 返回
+}
+
+// HistoricalDataPoint 股票历史原始数据点
+type HistoricalDataPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Price     float64   `json:"price"`
+	Volume    int64     `json:"volume"`
+}
+
+// HistoricalBar 按 interval 聚合的 OHLC K线
+type HistoricalBar struct {
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    int64     `json:"volume"`
+}
+
+// IndexHistoricalDataPoint 指数历史数据点
+type IndexHistoricalDataPoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Value         float64   `json:"value"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+	Volume        int64     `json:"volume"`
+	Turnover      float64   `json:"turnover"`
+}
+
+// IndexHistoricalBar 按 interval 聚合的指数 OHLC K线，成交量和成交额按窗口求和
+type IndexHistoricalBar struct {
+	Timestamp time.Time `json:"timestamp"`
+	Open      float64   `json:"open"`
+	High      float64   `json:"high"`
+	Low       float64   `json:"low"`
+	Close     float64   `json:"close"`
+	Volume    int64     `json:"volume"`
+	Turnover  float64   `json:"turnover"`
+}
+
+// HistoricalResponse 股票历史数据，未指定 interval 时为原始数据点 Data，否则为 K 线 Bars
+type HistoricalResponse struct {
+	Symbol    string                `json:"symbol"`
+	Start     time.Time             `json:"start"`
+	End       time.Time             `json:"end"`
+	Interval  string                `json:"interval,omitempty"`
+	Data      []HistoricalDataPoint `json:"data,omitempty"`
+	Bars      []HistoricalBar       `json:"bars,omitempty"`
+	Truncated bool                  `json:"truncated,omitempty"` // 结果超过 history.max_points 被截断
+}
+
+// ErrorResponse 错误响应，Code 决定 HTTP 状态码
+type ErrorResponse struct {
+	Error        string              `json:"error"`
+	Message      string              `json:"message"`
+	Code         apperrors.ErrorCode `json:"code,omitempty"`          // 错误代码，见 pkg/error
+	MarketClosed bool                `json:"market_closed,omitempty"` // 当前休市，此时状态码为 200
+}
+
+// OrderLevel 盘口的一档
+type OrderLevel struct {
+	Price  float64 `json:"price"`
+	Volume int64   `json:"volume"`
+}
+
+// OrderBook 五档盘口，Bids 和 Asks 均从最优价开始排列
+type OrderBook struct {
+	Bids []OrderLevel `json:"bids"`
+	Asks []OrderLevel `json:"asks"`
+}
+
+// BatchStocksResponse 按代码批量查询股票的响应，Data 顺序与请求一致
+type BatchStocksResponse struct {
+	Data    []StockResponse `json:"data"`
+	Missing []string        `json:"missing"`
+}
+
+// BatchIndicesResponse 按代码批量查询指数的响应，Data 顺序与请求一致
+type BatchIndicesResponse struct {
+	Data    []IndexResponse `json:"data"`
+	Missing []string        `json:"missing"`
+}
+
+// ChangedStocksResponse since 之后更新过的股票，Data 按更新时间升序排列
+type ChangedStocksResponse struct {
+	Data      []StockResponse `json:"data"`
+	Watermark int64           `json:"watermark"` // 下次请求使用的 since（毫秒），取自本次扫描到的最大更新时间
+	HasMore   bool            `json:"has_more"`  // 结果被 limit 截断，应立即用 watermark 继续请求
+}
@@ -0,0 +1,344 @@
+// Package backfill 将 CSVStorage 归档的行情数据回填到 InfluxDB
+package backfill
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+	"stocksub/pkg/storage"
+)
+
+// StockMeasurement influxdb_collector 写入实时行情使用的测量值
+const StockMeasurement = "stock_realtime"
+
+// StockPoint 构造 stock_realtime 数据点，influxdb_collector 与回填共用，保证两条路径写入的点一致。
+// symbol 标签使用规范形式（如 600000.SH）
+// 带有 5 档买卖盘时同时写入 bid_price1..ask_volume5 字段
+func StockPoint(stock message.StockData, provider, market string, timestamp time.Time) *write.Point {
+	point := influxdb2.NewPointWithMeasurement(StockMeasurement).
+		AddTag("symbol", core.NormalizeSymbol(stock.Symbol)).
+		AddTag("name", stock.Name).
+		AddTag("provider", provider).
+		AddTag("market", market).
+		AddField("price", stock.Price).
+		AddField("change", stock.Change).
+		AddField("change_percent", stock.ChangePercent).
+		AddField("volume", stock.Volume).
+		SetTime(timestamp)
+	if depth := stock.DepthFields(); depth != nil {
+		for _, field := range message.DepthFieldNames() {
+			point.AddField(field, depth[field])
+		}
+	}
+	return point
+}
+
+// TagNamespace 给数据点加上 namespace 标签，influxdb_collector 与回填共用。
+// 默认命名空间不加标签，读取时按 message.NamespaceFluxFilter 匹配不带标签的点
+func TagNamespace(namespace string, points []*write.Point) {
+	if namespace == "" {
+		return
+	}
+	for _, point := range points {
+		point.AddTag("namespace", namespace)
+	}
+}
+
+// genericHeader CSVStorage 通用格式的表头，data 列是 JSON 编码的 core.StockData
+var genericHeader = []string{"timestamp", "type", "symbol", "data"}
+
+// FindFiles 列出目录中的股票数据文件（*stock_data*.csv 和 .csv.gz），按文件名排序
+func FindFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		base := strings.TrimSuffix(name, ".gz")
+		if entry.IsDir() || !strings.HasSuffix(base, ".csv") || !strings.Contains(base, "stock_data") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ReadStockFile 读取 CSVStorage 写出的股票数据文件，.gz 文件自动解压
+//
+// 支持两种格式：api_monitor 保存 core.StockData 得到的通用格式（timestamp,type,symbol,data），
+// 以及 StructuredData 格式（表头为 StockDataSchema.FieldOrder 的“描述(字段名)”）。
+func ReadStockFile(path string) ([]core.StockData, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取表头失败: %w", err)
+	}
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	if equalStrings(header, genericHeader) {
+		return parseGenericRows(rows)
+	}
+	return readStructuredRows(data)
+}
+
+func readFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+	return io.ReadAll(reader)
+}
+
+// parseGenericRows 解析通用格式的数据行，跳过非 stock_data 类型的行
+func parseGenericRows(rows [][]string) ([]core.StockData, error) {
+	stocks := make([]core.StockData, 0, len(rows))
+	for i, row := range rows {
+		if len(row) != len(genericHeader) || row[1] != "stock_data" {
+			continue
+		}
+		var stock core.StockData
+		if err := json.Unmarshal([]byte(row[3]), &stock); err != nil {
+			return nil, fmt.Errorf("第 %d 行: 解析 data 列失败: %w", i+2, err)
+		}
+		stocks = append(stocks, stock)
+	}
+	return stocks, nil
+}
+
+// readStructuredRows 按 StockDataSchema 解析 StructuredData 格式的文件
+func readStructuredRows(data []byte) ([]core.StockData, error) {
+	serializer := storage.NewStructuredDataSerializer(storage.FormatCSV)
+	rows, err := serializer.DeserializeMultiple(data, storage.StockDataSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	stocks := make([]core.StockData, 0, len(rows))
+	for _, sd := range rows {
+		stock, err := storage.StructuredDataToStockData(sd)
+		if err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, *stock)
+	}
+	return stocks, nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pointKey 去重键，同一代码（规范形式）同一秒只写入一个点
+type pointKey struct {
+	symbol string
+	unix   int64
+}
+
+// Converter 将行情转换为数据点，并按 (代码, 时间戳) 去重
+type Converter struct {
+	provider string
+	market   string
+	seen     map[pointKey]struct{}
+}
+
+// NewConverter 创建转换器，provider 和 market 作为所有点的标签（CSV 中没有这两项）
+func NewConverter(provider, market string) *Converter {
+	return &Converter{provider: provider, market: market, seen: make(map[pointKey]struct{})}
+}
+
+// Convert 转换一组行情，返回新的数据点和跳过的重复行数
+//
+// influxdb_collector 的时间戳来自 RFC3339 字符串，只精确到秒；这里同样截断到秒，
+// 与采集器写入的点重叠时覆盖而不是新增。
+func (c *Converter) Convert(stocks []core.StockData) ([]*write.Point, int) {
+	points := make([]*write.Point, 0, len(stocks))
+	duplicates := 0
+	for _, stock := range stocks {
+		timestamp := stock.Timestamp.Truncate(time.Second)
+		key := pointKey{core.NormalizeSymbol(stock.Symbol), timestamp.Unix()}
+		if _, ok := c.seen[key]; ok {
+			duplicates++
+			continue
+		}
+		c.seen[key] = struct{}{}
+
+		data := message.StockData{
+			Symbol:        stock.Symbol,
+			Name:          stock.Name,
+			Price:         stock.Price,
+			Change:        stock.Change,
+			ChangePercent: stock.ChangePercent,
+			Volume:        stock.Volume,
+		}
+		data.SetOrderBook(stock)
+		points = append(points, StockPoint(data, c.provider, c.market, timestamp))
+	}
+	return points, duplicates
+}
+
+// PointWriter 阻塞式写入，api.WriteAPIBlocking 满足该接口
+type PointWriter interface {
+	WritePoint(ctx context.Context, point ...*write.Point) error
+}
+
+// Config 回填配置
+type Config struct {
+	Provider  string  // provider 标签
+	Market    string  // market 标签
+	Namespace string  // namespace 标签，需与 influxdb_collector 一致，为空时为默认命名空间
+	BatchSize int     // 每批写入的点数，默认 5000
+	RateLimit float64 // 每秒最多写入的点数，0 表示不限制
+	DryRun    bool    // 只读取和转换，不写入
+	// Progress 每处理完一个文件和每写入一批后调用，可以为 nil
+	Progress func(Progress)
+}
+
+// Progress 回填进度
+type Progress struct {
+	File       string // 当前文件
+	FilesDone  int
+	FilesTotal int
+	Rows       int // 已读取的行情行数
+	Duplicates int // 去重跳过的行数
+	Points     int // 已转换的点数
+	Written    int // 已写入的点数，dry-run 时为 0
+}
+
+// Run 依次读取文件并分批写入，出错时返回已完成的进度
+func Run(ctx context.Context, files []string, writer PointWriter, config Config) (Progress, error) {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 5000
+	}
+	r := &runner{writer: writer, config: config, converter: NewConverter(config.Provider, config.Market)}
+	r.progress.FilesTotal = len(files)
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return r.progress, err
+		}
+		r.progress.File = file
+
+		stocks, err := ReadStockFile(file)
+		if err != nil {
+			return r.progress, fmt.Errorf("读取 %s 失败: %w", filepath.Base(file), err)
+		}
+		points, duplicates := r.converter.Convert(stocks)
+		TagNamespace(config.Namespace, points)
+		r.progress.Rows += len(stocks)
+		r.progress.Duplicates += duplicates
+		r.progress.Points += len(points)
+		r.pending = append(r.pending, points...)
+
+		for len(r.pending) >= config.BatchSize {
+			if err := r.flush(ctx, config.BatchSize); err != nil {
+				return r.progress, err
+			}
+		}
+		r.progress.FilesDone++
+		r.report()
+	}
+
+	if err := r.flush(ctx, len(r.pending)); err != nil {
+		return r.progress, err
+	}
+	return r.progress, nil
+}
+
+// runner 一次回填的状态
+type runner struct {
+	writer    PointWriter
+	config    Config
+	converter *Converter
+	pending   []*write.Point
+	progress  Progress
+}
+
+// flush 写入前 n 个待写入的点，启用限速时写入后等待到该批的配额用完
+func (r *runner) flush(ctx context.Context, n int) error {
+	if n == 0 {
+		return nil
+	}
+	batch := r.pending[:n]
+	r.pending = r.pending[n:]
+	if r.config.DryRun {
+		return nil
+	}
+
+	start := time.Now()
+	if err := r.writer.WritePoint(ctx, batch...); err != nil {
+		return fmt.Errorf("写入 InfluxDB 失败: %w", err)
+	}
+	r.progress.Written += len(batch)
+	r.report()
+
+	if r.config.RateLimit > 0 {
+		quota := time.Duration(float64(len(batch)) / r.config.RateLimit * float64(time.Second))
+		if wait := quota - time.Since(start); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	return nil
+}
+
+func (r *runner) report() {
+	if r.config.Progress != nil {
+		r.config.Progress(r.progress)
+	}
+}
@@ -0,0 +1,218 @@
+package backfill
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/storage"
+)
+
+var testTime = time.Date(2025, 8, 21, 10, 30, 15, 500_000_000, time.FixedZone("CST", 8*3600))
+
+func testStock(symbol string, at time.Time) core.StockData {
+	return core.StockData{
+		Symbol:        symbol,
+		Name:          "浦发银行",
+		Price:         10.5,
+		Change:        0.15,
+		ChangePercent: 1.45,
+		Volume:        1250000,
+		Turnover:      13125000,
+		Timestamp:     at,
+	}
+}
+
+// writeCSV 用 CSVStorage 写入数据，返回目录中的股票数据文件
+func writeCSV(t *testing.T, records ...interface{}) []string {
+	t.Helper()
+	config := storage.DefaultCSVStorageConfig()
+	config.Directory = t.TempDir()
+	cs, err := storage.NewCSVStorage(config)
+	require.NoError(t, err)
+	for _, record := range records {
+		require.NoError(t, cs.Save(context.Background(), record))
+	}
+	require.NoError(t, cs.Close())
+
+	files, err := FindFiles(config.Directory)
+	require.NoError(t, err)
+	return files
+}
+
+func TestStockPoint_MatchesCollectorLineProtocol(t *testing.T) {
+	points, duplicates := NewConverter("tencent", "A-share").Convert([]core.StockData{testStock("600000", testTime)})
+	require.Len(t, points, 1)
+	assert.Zero(t, duplicates)
+	assert.Equal(t,
+		"stock_realtime,symbol=600000.SH,name=浦发银行,provider=tencent,market=A-share price=10.5,change=0.15,change_percent=1.45,volume=1250000i 1755743415\n",
+		write.PointToLineProtocol(points[0], time.Second), "时间戳截断到秒，不写入成交额")
+}
+
+func TestReadStockFile_GenericFormat(t *testing.T) {
+	files := writeCSV(t,
+		testStock("600000", testTime),
+		testStock("000001", testTime.Add(3*time.Second)),
+	)
+	require.Len(t, files, 1)
+	assert.Contains(t, filepath.Base(files[0]), "stocksub_stock_data_")
+
+	stocks, err := ReadStockFile(files[0])
+	require.NoError(t, err)
+	require.Len(t, stocks, 2)
+	assert.Equal(t, "600000", stocks[0].Symbol)
+	assert.Equal(t, 10.5, stocks[0].Price)
+	assert.Equal(t, int64(1250000), stocks[0].Volume)
+	assert.True(t, testTime.Add(3*time.Second).Equal(stocks[1].Timestamp))
+
+	// 压缩归档的文件
+	gzPath := filepath.Join(filepath.Dir(files[0]), "archived_stock_data_2025-08-20.csv.gz")
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	out, err := os.Create(gzPath)
+	require.NoError(t, err)
+	zw := gzip.NewWriter(out)
+	_, err = zw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, out.Close())
+
+	stocks, err = ReadStockFile(gzPath)
+	require.NoError(t, err)
+	assert.Len(t, stocks, 2)
+}
+
+func TestReadStockFile_StructuredFormat(t *testing.T) {
+	sd, err := storage.StockDataToStructuredData(testStock("600000", testTime.Truncate(time.Second)))
+	require.NoError(t, err)
+	files := writeCSV(t, sd)
+	require.Len(t, files, 1)
+	assert.Contains(t, filepath.Base(files[0]), "structured_stock_data")
+
+	header, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(header), "股票代码(symbol),股票名称(name),当前价格(price)", "列按 FieldOrder 排列")
+
+	stocks, err := ReadStockFile(files[0])
+	require.NoError(t, err)
+	require.Len(t, stocks, 1)
+	assert.Equal(t, "600000", stocks[0].Symbol)
+	assert.Equal(t, "浦发银行", stocks[0].Name)
+	assert.Equal(t, 1.45, stocks[0].ChangePercent)
+	assert.Equal(t, int64(1250000), stocks[0].Volume)
+	assert.True(t, testTime.Truncate(time.Second).Equal(stocks[0].Timestamp))
+}
+
+func TestFindFiles_OnlyStockData(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"stocksub_stock_data_2025-08-21.csv",
+		"stocksub_stock_data_2025-08-20.csv.gz",
+		"stocksub_structured_stock_data_2025-08-21.csv",
+		"stocksub_performance_2025-08-21.csv",
+		"notes.txt",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	files, err := FindFiles(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, "stocksub_stock_data_2025-08-20.csv.gz", filepath.Base(files[0]))
+
+	stocks, err := ReadStockFile(filepath.Join(dir, "stocksub_stock_data_2025-08-21.csv"))
+	assert.NoError(t, err, "空文件")
+	assert.Empty(t, stocks)
+}
+
+// fakeWriter 记录每批写入的点
+type fakeWriter struct {
+	batches [][]*write.Point
+	err     error
+}
+
+func (w *fakeWriter) WritePoint(ctx context.Context, points ...*write.Point) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.batches = append(w.batches, points)
+	return nil
+}
+
+func TestRun_BatchesAndDeduplicates(t *testing.T) {
+	first := writeCSV(t,
+		testStock("600000", testTime),
+		testStock("600000", testTime.Add(200*time.Millisecond)), // 同一秒
+		testStock("000001", testTime),
+	)
+	second := writeCSV(t,
+		testStock("600000", testTime), // 跨文件重复
+		testStock("600000", testTime.Add(time.Second)),
+		testStock("000001", testTime.Add(time.Second)),
+	)
+	files := append(first, second...)
+
+	writer := &fakeWriter{}
+	var reports []Progress
+	progress, err := Run(context.Background(), files, writer, Config{
+		Provider:  "tencent",
+		Market:    "A-share",
+		BatchSize: 3,
+		Progress:  func(p Progress) { reports = append(reports, p) },
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Progress{File: files[1], FilesDone: 2, FilesTotal: 2, Rows: 6, Duplicates: 2, Points: 4, Written: 4}, progress)
+	require.Len(t, writer.batches, 2)
+	assert.Len(t, writer.batches[0], 3)
+	assert.Len(t, writer.batches[1], 1, "剩余的点在最后写入")
+	assert.NotEmpty(t, reports)
+	assert.Equal(t, 2, reports[0].FilesTotal)
+
+	// dry-run 不写入
+	dry := &fakeWriter{}
+	progress, err = Run(context.Background(), files, dry, Config{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, 4, progress.Points)
+	assert.Zero(t, progress.Written)
+	assert.Empty(t, dry.batches)
+
+	// 非默认命名空间的点带 namespace 标签，与 influxdb_collector 写入的序列一致
+	staging := &fakeWriter{}
+	_, err = Run(context.Background(), first, staging, Config{Provider: "tencent", Market: "A-share", Namespace: "staging"})
+	require.NoError(t, err)
+	require.Len(t, staging.batches, 1)
+	assert.Contains(t, write.PointToLineProtocol(staging.batches[0][0], time.Second), ",namespace=staging ")
+	assert.NotContains(t, write.PointToLineProtocol(writer.batches[0][0], time.Second), "namespace")
+}
+
+func TestRun_RateLimitAndErrors(t *testing.T) {
+	files := writeCSV(t,
+		testStock("600000", testTime),
+		testStock("600000", testTime.Add(time.Second)),
+		testStock("600000", testTime.Add(2*time.Second)),
+		testStock("600000", testTime.Add(3*time.Second)),
+	)
+
+	start := time.Now()
+	_, err := Run(context.Background(), files, &fakeWriter{}, Config{BatchSize: 2, RateLimit: 40})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond, "4 个点以每秒 40 个写入至少 100ms")
+
+	failing := &fakeWriter{err: errors.New("influx unavailable")}
+	progress, err := Run(context.Background(), files, failing, Config{BatchSize: 2})
+	assert.ErrorContains(t, err, "influx unavailable")
+	assert.Zero(t, progress.Written)
+
+	bad := filepath.Join(t.TempDir(), "stocksub_stock_data_bad.csv")
+	require.NoError(t, os.WriteFile(bad, []byte("timestamp,type,symbol,data\n2025-08-21T10:00:00+08:00,stock_data,600000,{oops\n"), 0644))
+	_, err = Run(context.Background(), []string{bad}, &fakeWriter{}, Config{})
+	assert.ErrorContains(t, err, "第 2 行")
+}
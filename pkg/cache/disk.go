@@ -28,6 +28,7 @@ type DiskCache struct {
 	cacheDir  string
 	closeChan chan struct{}
 	closed    bool // 缓存是否已关闭
+	loads     loadGroup
 }
 
 // diskCacheEntry 磁盘缓存条目
@@ -101,6 +102,7 @@ func (dc *DiskCache) Get(ctx context.Context, key string) (interface{}, error) {
 		delete(dc.entries, key)
 		dc.stats.MissCount++
 		dc.stats.Size--
+		dc.stats.Expirations++
 		dc.mu.Unlock()
 
 		// 异步删除磁盘文件
@@ -171,6 +173,7 @@ func (dc *DiskCache) Set(ctx context.Context, key string, value interface{}, ttl
 			oldEntry := dc.entries[oldestKey]
 			delete(dc.entries, oldestKey)
 			dc.stats.Size--
+			dc.stats.Evictions++
 			// 同步删除磁盘文件以避免死锁
 			os.Remove(oldEntry.Filepath)
 		}
@@ -228,6 +231,8 @@ func (dc *DiskCache) Clear(ctx context.Context) error {
 	dc.stats.Size = 0
 	dc.stats.HitCount = 0
 	dc.stats.MissCount = 0
+	dc.stats.Evictions = 0
+	dc.stats.Expirations = 0
 	dc.mu.Unlock()
 
 	// 异步删除所有磁盘文件
@@ -240,6 +245,11 @@ func (dc *DiskCache) Clear(ctx context.Context) error {
 	return nil
 }
 
+// GetOrLoad 获取缓存值，未命中时合并同一个键的并发加载
+func (dc *DiskCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, dc, &dc.loads, key, ttl, loader)
+}
+
 // Stats 获取缓存统计信息
 func (dc *DiskCache) Stats() CacheStats {
 	dc.mu.RLock()
@@ -248,6 +258,11 @@ func (dc *DiskCache) Stats() CacheStats {
 	stats := dc.stats
 	stats.LastCleanup = time.Now()
 
+	// 磁盘层的占用按数据文件的字节数估算
+	for _, entry := range dc.entries {
+		stats.MemoryBytes += entry.Size
+	}
+
 	// 计算命中率
 	total := stats.HitCount + stats.MissCount
 	if total > 0 {
@@ -404,6 +419,7 @@ func (dc *DiskCache) cleanupExpired() {
 	for _, key := range deletedKeys {
 		delete(dc.entries, key)
 		dc.stats.Size--
+		dc.stats.Expirations++
 	}
 
 	// 异步删除磁盘文件
@@ -18,6 +18,9 @@ type Cache interface {
 	Clear(ctx context.Context) error
 	// Stats 获取缓存的统计信息。
 	Stats() CacheStats
+	// GetOrLoad 获取一个值，未命中时调用 loader 加载并以 ttl 写入缓存。
+	// 同一个键的并发调用只会运行一次 loader，其余调用等待并得到相同的结果。
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error)
 }
 
 // CacheEntry 代表缓存中的一个条目。
@@ -32,15 +35,45 @@ type CacheEntry struct {
 
 // CacheStats 包含了缓存的详细统计信息。
 type CacheStats struct {
-	Size        int64         `json:"size"`         // 当前缓存中的条目数
-	MaxSize     int64         `json:"max_size"`     // 缓存最大容量
-	HitCount    int64         `json:"hit_count"`    // 命中次数
-	MissCount   int64         `json:"miss_count"`   // 未命中次数
-	HitRate     float64       `json:"hit_rate"`     // 命中率
-	TTL         time.Duration `json:"ttl"`          // 默认的生存时间
-	LastCleanup time.Time     `json:"last_cleanup"` // 最后一次清理过期条目的时间
+	Size        int64         `json:"size"`             // 当前缓存中的条目数
+	MaxSize     int64         `json:"max_size"`         // 缓存最大容量
+	HitCount    int64         `json:"hit_count"`        // 命中次数
+	MissCount   int64         `json:"miss_count"`       // 未命中次数
+	HitRate     float64       `json:"hit_rate"`         // 命中率
+	TTL         time.Duration `json:"ttl"`              // 默认的生存时间
+	LastCleanup time.Time     `json:"last_cleanup"`     // 最后一次清理过期条目的时间
+	Evictions   int64         `json:"evictions"`        // 因容量不足被淘汰的条目数
+	Expirations int64         `json:"expirations"`      // 因 TTL 过期被删除的条目数
+	MemoryBytes int64         `json:"memory_bytes"`     // 当前条目的估算内存占用（字节）
+	Promotions  int64         `json:"promotions"`       // 从下层提升到上层的次数（分层缓存）
+	Demotions   int64         `json:"demotions"`        // 从上层降级到下层的次数（分层缓存）
+	Layers      []CacheStats  `json:"layers,omitempty"` // 每层的统计（分层缓存）
+
+	DirtyEntries int64         `json:"dirty_entries"` // 写回模式下尚未写入下层的条目数（分层缓存）
+	DirtyAge     time.Duration `json:"dirty_age"`     // 最早的脏条目已等待写入的时长（分层缓存）
+	WriteBacks   int64         `json:"write_backs"`   // 写回模式下写入下层的条目数（分层缓存）
+}
+
+// EvictionReason 条目被移出缓存的原因
+type EvictionReason string
+
+const (
+	EvictionCapacity EvictionReason = "capacity" // 容量不足
+	EvictionTTL      EvictionReason = "ttl"      // TTL 过期
+)
+
+// EvictionHandler 条目被淘汰时的回调
+type EvictionHandler func(key string, entry *CacheEntry, reason EvictionReason)
+
+// EvictionNotifier 支持注册淘汰回调的缓存
+type EvictionNotifier interface {
+	// SetEvictionHandler 设置容量淘汰回调
+	SetEvictionHandler(handler EvictionHandler)
 }
 
+// SizeFunc 估算缓存值占用的字节数
+type SizeFunc func(value interface{}) int64
+
 // BatchGetter 批量获取接口
 type BatchGetter interface {
 	// BatchGet 批量从缓存中获取多个值
@@ -36,14 +36,19 @@ type LayerConfig struct {
 	Enabled         bool          `yaml:"enabled"`
 	Policy          PolicyType    `yaml:"policy"`
 	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+	StaleTTL        time.Duration `yaml:"stale_ttl"` // 内存层过期条目的宽限期，期间 GetOrLoad 返回旧值并在后台刷新
 }
 
 // LayeredCacheConfig 分层缓存配置
 type LayeredCacheConfig struct {
 	Layers         []LayerConfig `yaml:"layers"`
 	PromoteEnabled bool          `yaml:"promote_enabled"` // 是否启用数据提升
+	DemoteEnabled  bool          `yaml:"demote_enabled"`  // 是否将上层因容量淘汰的条目降级写入下一层
 	WriteThrough   bool          `yaml:"write_through"`   // 是否写穿透
-	WriteBack      bool          `yaml:"write_back"`      // 是否写回
+	WriteBack      bool          `yaml:"write_back"`      // 是否写回：Set 只写第一层并标记为脏，由后台协程批量写入下层；与 WriteThrough 同时启用时以写穿透为准
+
+	FlushInterval  time.Duration `yaml:"flush_interval"`  // 写回模式的后台刷新间隔，0 使用 DefaultFlushInterval
+	FlushThreshold int           `yaml:"flush_threshold"` // 脏条目达到该数量时立即刷新，0 使用 DefaultFlushThreshold
 }
 
 // LayeredCache 分层缓存实现
@@ -55,6 +60,16 @@ type LayeredCache struct {
 	factories   map[LayerType]LayerFactory // 缓存层工厂注册表
 	promoteChan chan promoteRequest        // 数据提升请求通道
 	closed      bool                       // 缓存是否已关闭
+	loads       loadGroup                  // 合并同一个键的并发加载
+
+	// 写回模式
+	writeBack   bool                  // 启用写回且至少有两层
+	dirty       map[string]dirtyEntry // 尚未写入下层的条目
+	dirtyMu     sync.Mutex
+	flushMu     sync.Mutex // 串行化写入下层的刷新
+	flushSignal chan struct{}
+	stopFlush   chan struct{}
+	flushDone   chan struct{}
 }
 
 // promoteRequest 数据提升请求
@@ -72,8 +87,9 @@ type LayeredCacheStats struct {
 	TotalHits    int64        `json:"total_hits"`
 	TotalMisses  int64        `json:"total_misses"`
 	PromoteCount int64        `json:"promote_count"`
+	DemoteCount  int64        `json:"demote_count"`
 	WriteThrough int64        `json:"write_through"`
-	WriteBack    int64        `json:"write_back"`
+	WriteBack    int64        `json:"write_back"` // 写回模式下写入下层的条目数
 }
 
 // NewLayeredCache 创建分层缓存
@@ -126,9 +142,45 @@ func NewLayeredCacheWithFactories(config LayeredCacheConfig, customFactories map
 		go lc.promoteWorker()
 	}
 
+	// 上层淘汰的条目降级到下一层
+	if config.DemoteEnabled {
+		for i := 0; i < len(layers)-1; i++ {
+			if notifier, ok := layers[i].(EvictionNotifier); ok {
+				notifier.SetEvictionHandler(lc.demoteHandler(i))
+			}
+		}
+	}
+
+	// 写回模式：第一层的淘汰回调先写入脏条目，再按 DemoteEnabled 降级非脏条目
+	if config.WriteBack && !config.WriteThrough && len(layers) > 1 {
+		lc.writeBack = true
+		var demote EvictionHandler
+		if config.DemoteEnabled {
+			demote = lc.demoteHandler(0)
+		}
+		lc.startWriteBack(demote)
+	}
+
 	return lc, nil
 }
 
+// demoteHandler 返回将第 fromLayer 层淘汰的条目以剩余 TTL 写入下一层的回调
+func (lc *LayeredCache) demoteHandler(fromLayer int) EvictionHandler {
+	return func(key string, entry *CacheEntry, reason EvictionReason) {
+		if reason != EvictionCapacity {
+			return
+		}
+		remaining := time.Until(entry.ExpireTime)
+		if remaining <= 0 {
+			return
+		}
+		if err := lc.layers[fromLayer+1].Set(context.Background(), key, entry.Value, remaining); err != nil {
+			return
+		}
+		atomic.AddInt64(&lc.stats.DemoteCount, 1)
+	}
+}
+
 // createCacheLayer 创建单个缓存层
 func createCacheLayer(config LayerConfig, layerIndex int, factories map[LayerType]LayerFactory) (Cache, error) {
 	// 为调试和监控目的，可以根据层索引进行特殊处理
@@ -192,6 +244,23 @@ func (lc *LayeredCache) Get(ctx context.Context, key string) (interface{}, error
 	return nil, NewCacheError(ErrCacheMiss, "cache miss")
 }
 
+// GetOrLoad 从分层缓存获取数据，所有层都未命中时合并并发加载，加载结果按 Set 的规则写入
+func (lc *LayeredCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, lc, &lc.loads, key, ttl, loader)
+}
+
+// GetStale 依次查找支持 StaleGetter 的缓存层中宽限期内的旧值
+func (lc *LayeredCache) GetStale(ctx context.Context, key string) (interface{}, bool) {
+	for _, layer := range lc.layers {
+		if stale, ok := layer.(StaleGetter); ok {
+			if value, found := stale.GetStale(ctx, key); found {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
 // getLayerType 获取缓存层类型
 func (lc *LayeredCache) getLayerType(index int) string {
 	if index < 0 || index >= len(lc.config.Layers) {
@@ -258,7 +327,12 @@ func (lc *LayeredCache) Set(ctx context.Context, key string, value interface{},
 	} else {
 		// 默认只写入第一层（最快的层）
 		if len(lc.layers) > 0 {
+			// 写回模式先标记为脏，写入第一层时即使立即被淘汰也会先写入下层
+			if lc.writeBack {
+				lc.markDirty(map[string]interface{}{key: value}, ttl)
+			}
 			if err := lc.layers[0].Set(ctx, key, value, ttl); err != nil {
+				lc.forgetDirty(key)
 				return fmt.Errorf("第一层缓存 (%s) 写入失败: %w", lc.getLayerType(0), err)
 			}
 			return nil
@@ -277,6 +351,10 @@ func (lc *LayeredCache) Delete(ctx context.Context, key string) error {
 	lc.mu.RUnlock()
 
 	var lastErr error
+	// 等待进行中的刷新完成，避免刷新把已删除的条目写回下层
+	lc.lockFlush()
+	defer lc.unlockFlush()
+	lc.forgetDirty(key)
 
 	// 从所有层删除
 	for i, layer := range lc.layers {
@@ -299,6 +377,9 @@ func (lc *LayeredCache) Clear(ctx context.Context) error {
 	lc.mu.RUnlock()
 
 	var lastErr error
+	lc.lockFlush()
+	defer lc.unlockFlush()
+	lc.forgetDirty()
 
 	for i, layer := range lc.layers {
 		if err := layer.Clear(ctx); err != nil {
@@ -328,18 +409,24 @@ func (lc *LayeredCache) Stats() CacheStats {
 	totalHitCount := atomic.LoadInt64(&lc.stats.TotalHits)
 	totalMissCount := atomic.LoadInt64(&lc.stats.TotalMisses)
 
+	var evictions, expirations, memoryBytes int64
+	layerStats := make([]CacheStats, len(lc.layers))
+
 	for i, layer := range lc.layers {
-		layerStats := layer.Stats()
-		lc.stats.LayerStats[i] = layerStats
+		layerStats[i] = layer.Stats()
 
-		totalSize += layerStats.Size
-		totalMaxSize += layerStats.MaxSize
+		totalSize += layerStats[i].Size
+		totalMaxSize += layerStats[i].MaxSize
+		evictions += layerStats[i].Evictions
+		expirations += layerStats[i].Expirations
+		memoryBytes += layerStats[i].MemoryBytes
 	}
 
 	var hitRate float64
 	if total := totalHitCount + totalMissCount; total > 0 {
 		hitRate = float64(totalHitCount) / float64(total)
 	}
+	dirtyEntries, dirtyAge := lc.dirtyStats()
 
 	return CacheStats{
 		Size:        totalSize,
@@ -349,6 +436,16 @@ func (lc *LayeredCache) Stats() CacheStats {
 		HitRate:     hitRate,
 		TTL:         0, // 分层缓存的TTL取决于各层配置
 		LastCleanup: time.Now(),
+		Evictions:   evictions,
+		Expirations: expirations,
+		MemoryBytes: memoryBytes,
+		Promotions:  atomic.LoadInt64(&lc.stats.PromoteCount),
+		Demotions:   atomic.LoadInt64(&lc.stats.DemoteCount),
+		Layers:      layerStats,
+
+		DirtyEntries: dirtyEntries,
+		DirtyAge:     dirtyAge,
+		WriteBacks:   atomic.LoadInt64(&lc.stats.WriteBack),
 	}
 }
 
@@ -388,7 +485,8 @@ func (lc *LayeredCache) Close() error {
 		return nil // 已经关闭
 	}
 
-	var lastErr error
+	// 先同步写入剩余的脏条目，再关闭各层
+	lastErr := lc.stopWriteBack()
 
 	// 关闭数据提升通道和工作协程
 	if lc.promoteChan != nil {
@@ -511,6 +609,9 @@ func (lc *LayeredCache) BatchSet(ctx context.Context, items map[string]any, ttl
 		// 默认只写入第一层（最快的层）
 		if len(lc.layers) > 0 {
 			layer := lc.layers[0]
+			if lc.writeBack {
+				lc.markDirty(items, ttl)
+			}
 			if batchSetter, ok := layer.(BatchSetter); ok {
 				if err := batchSetter.BatchSet(ctx, items, ttl); err != nil {
 					return fmt.Errorf("第一层缓存 (%s) 批量设置失败: %w", lc.getLayerType(0), err)
@@ -557,7 +658,7 @@ func (lc *LayeredCache) Warm(ctx context.Context, data map[string]interface{}) e
 	return nil
 }
 
-// Flush 刷新缓存（将上层数据写入下层）
+// Flush 刷新缓存：写回模式下同步把全部脏条目写入下层，写入失败的条目保留到下一次刷新
 func (lc *LayeredCache) Flush(ctx context.Context) error {
 	lc.mu.RLock()
 	if lc.closed {
@@ -566,14 +667,7 @@ func (lc *LayeredCache) Flush(ctx context.Context) error {
 	}
 	lc.mu.RUnlock()
 
-	if !lc.config.WriteBack {
-		return nil // 只在写回模式下执行刷新
-	}
-
-	// TODO: 实现写回逻辑
-	// 这需要缓存层支持遍历所有键值对
-	lc.stats.WriteBack++
-	return nil
+	return lc.flushDirty(ctx) // 非写回模式下没有脏条目
 }
 
 // DefaultLayeredCacheConfig 默认分层缓存配置
@@ -618,6 +712,7 @@ func (f *memoryLayerFactory) CreateLayer(config LayerConfig, layerIndex int) (Ca
 		MaxSize:         config.MaxSize,
 		DefaultTTL:      config.TTL,
 		CleanupInterval: config.CleanupInterval,
+		StaleTTL:        config.StaleTTL,
 	}
 
 	if config.Policy != "" {
@@ -90,6 +90,10 @@ func (m *mockLayer) Stats() CacheStats {
 	return m.stats
 }
 
+func (m *mockLayer) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, m, &loadGroup{}, key, ttl, loader)
+}
+
 func (m *mockLayer) Close() error { return nil }
 
 // mockFactory implements the LayerFactory interface for mock layers.
@@ -339,3 +343,67 @@ func TestLayeredCache_BatchGet_Error(t *testing.T) {
 	require.Error(t, err, "BatchGet should fail when underlying Get fails")
 	assert.Contains(t, err.Error(), "disk read failed")
 }
+
+func TestLayeredCache_Stats_DemotionPromotionAndLayers(t *testing.T) {
+	cache, err := NewLayeredCache(LayeredCacheConfig{
+		Layers: []LayerConfig{
+			{Type: LayerMemory, MaxSize: 2, TTL: time.Minute, Enabled: true, Policy: PolicyLRU, CleanupInterval: time.Minute},
+			{Type: LayerMemory, MaxSize: 10, TTL: time.Minute, Enabled: true, Policy: PolicyLFU, CleanupInterval: time.Minute},
+		},
+		PromoteEnabled: true,
+		DemoteEnabled:  true,
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	// 第一层只能容纳两个条目，超出的条目降级到第二层
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		require.NoError(t, cache.Set(ctx, key, "value-"+key, 0))
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	stats := cache.Stats()
+	require.Len(t, stats.Layers, 2)
+	assert.Equal(t, int64(2), stats.Demotions)
+	assert.Equal(t, int64(2), stats.Layers[0].Size)
+	assert.Equal(t, int64(2), stats.Layers[0].Evictions)
+	assert.Equal(t, int64(2), stats.Layers[1].Size)
+	assert.Equal(t, int64(4), stats.Size)
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.Equal(t, stats.Layers[0].MemoryBytes+stats.Layers[1].MemoryBytes, stats.MemoryBytes)
+	assert.Positive(t, stats.MemoryBytes)
+
+	// 从第二层命中的条目被提升回第一层
+	value, err := cache.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "value-k1", value)
+	assert.Equal(t, int64(1), cache.Stats().Promotions)
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Demotions == 3
+	}, time.Second, 5*time.Millisecond, "提升占用第一层容量，再次触发降级")
+}
+
+func TestLayeredCache_Stats_ExpirationsNotDemoted(t *testing.T) {
+	cache, err := NewLayeredCache(LayeredCacheConfig{
+		Layers: []LayerConfig{
+			{Type: LayerMemory, MaxSize: 10, TTL: time.Minute, Enabled: true, CleanupInterval: 10 * time.Millisecond},
+			{Type: LayerMemory, MaxSize: 10, TTL: time.Minute, Enabled: true, CleanupInterval: time.Minute},
+		},
+		DemoteEnabled: true,
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "k1", "v1", 20*time.Millisecond))
+	require.NoError(t, cache.Set(ctx, "k2", "v2", 20*time.Millisecond))
+
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Expirations == 2
+	}, time.Second, 5*time.Millisecond)
+	stats := cache.Stats()
+	assert.Zero(t, stats.Demotions)
+	assert.Zero(t, stats.Layers[1].Size)
+	assert.Equal(t, int64(2), stats.Layers[0].Expirations)
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LoaderFunc 缓存未命中时加载数据的函数
+type LoaderFunc func(ctx context.Context) (interface{}, error)
+
+// StaleGetter 可以读取已过期但仍在宽限期内的条目的缓存，用于 stale-while-revalidate
+type StaleGetter interface {
+	// GetStale 返回已过期但仍在宽限期内的值
+	GetStale(ctx context.Context, key string) (interface{}, bool)
+}
+
+// loadGroup 合并同一个键的并发加载，同一时刻每个键最多只有一个加载函数在运行。零值可直接使用。
+type loadGroup struct {
+	mu    sync.Mutex
+	calls map[string]*loadCall
+}
+
+// loadCall 一次进行中的加载，done 关闭后 value 和 err 可读
+type loadCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// start 返回键上进行中的加载，没有时启动一个新的加载。
+//
+// 加载函数在独立的协程中运行并使用不随调用方取消的 ctx，
+// 第一个调用方取消请求不会让其他等待者一起失败。
+func (g *loadGroup) start(ctx context.Context, key string, fn LoaderFunc) *loadCall {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if call, exists := g.calls[key]; exists {
+		return call
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	g.calls[key] = call
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				call.value, call.err = nil, fmt.Errorf("缓存加载函数 panic: %v", r)
+			}
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+		call.value, call.err = fn(context.WithoutCancel(ctx))
+	}()
+	return call
+}
+
+// wait 等待加载完成，ctx 取消时提前返回
+func (c *loadCall) wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// getOrLoad GetOrLoad 的通用实现。
+//
+// 未命中时同一个键只运行一次 loader，成功后以 ttl 写入缓存，所有等待者得到相同的值或错误。
+// 缓存实现了 StaleGetter 且存在宽限期内的旧值时，立即返回旧值并在后台刷新。
+func getOrLoad(ctx context.Context, c Cache, loads *loadGroup, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	value, err := c.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+	if !isCacheMiss(err) {
+		return nil, err
+	}
+
+	load := func(ctx context.Context) (interface{}, error) {
+		// 上一次加载可能在本次未命中之后刚刚完成
+		if value, err := c.Get(ctx, key); err == nil {
+			return value, nil
+		}
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.Set(ctx, key, value, ttl); err != nil {
+			return nil, fmt.Errorf("写入缓存失败: %w", err)
+		}
+		return value, nil
+	}
+
+	if stale, ok := c.(StaleGetter); ok {
+		if value, found := stale.GetStale(ctx, key); found {
+			loads.start(ctx, key, load)
+			return value, nil
+		}
+	}
+	return loads.start(ctx, key, load).wait(ctx)
+}
+
+// isCacheMiss 判断错误是否为缓存未命中
+func isCacheMiss(err error) bool {
+	var cacheErr *CacheError
+	return errors.As(err, &cacheErr) && cacheErr.Code == ErrCacheMiss
+}
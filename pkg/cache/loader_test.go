@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runConcurrentLoads 让 n 个协程同时对同一个键调用 GetOrLoad，返回每个协程的结果
+func runConcurrentLoads(c Cache, n int, loader LoaderFunc) ([]interface{}, []error) {
+	values := make([]interface{}, n)
+	errs := make([]error, n)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			values[i], errs[i] = c.GetOrLoad(context.Background(), "hot", time.Minute, loader)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return values, errs
+}
+
+func newTestCaches(t *testing.T) map[string]Cache {
+	memCache := NewMemoryCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute})
+	t.Cleanup(func() { memCache.Close() })
+
+	layered, err := NewLayeredCache(DefaultLayeredCacheConfig())
+	require.NoError(t, err)
+	t.Cleanup(func() { layered.Close() })
+
+	smart := NewSmartCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute}, PolicyConfig{Type: PolicyLRU, MaxSize: 100})
+	t.Cleanup(func() { smart.Close() })
+
+	return map[string]Cache{"memory": memCache, "layered": layered, "smart": smart}
+}
+
+func TestGetOrLoad_ConcurrentMissesRunLoaderOnce(t *testing.T) {
+	for name, c := range newTestCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			values, errs := runConcurrentLoads(c, 100, func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(20 * time.Millisecond)
+				return "quote", nil
+			})
+
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+			for i := range values {
+				require.NoError(t, errs[i])
+				assert.Equal(t, "quote", values[i])
+			}
+
+			cached, err := c.Get(context.Background(), "hot")
+			require.NoError(t, err)
+			assert.Equal(t, "quote", cached)
+
+			// 已缓存时不再调用 loader
+			_, err = c.GetOrLoad(context.Background(), "hot", time.Minute, func(ctx context.Context) (interface{}, error) {
+				t.Fatal("loader called on cache hit")
+				return nil, nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGetOrLoad_ErrorSharedAndNotCached(t *testing.T) {
+	c := NewMemoryCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute})
+	defer c.Close()
+	errUpstream := errors.New("influxdb unavailable")
+
+	var calls int32
+	_, errs := runConcurrentLoads(c, 100, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return nil, errUpstream
+	})
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, err := range errs {
+		assert.ErrorIs(t, err, errUpstream)
+	}
+
+	_, err := c.Get(context.Background(), "hot")
+	assert.True(t, isCacheMiss(err), "加载失败时不写入缓存")
+
+	// 失败后的下一次调用重新加载
+	value, err := c.GetOrLoad(context.Background(), "hot", time.Minute, func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "recovered", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "recovered", value)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestGetOrLoad_PanicBecomesError(t *testing.T) {
+	c := NewMemoryCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute})
+	defer c.Close()
+
+	_, err := c.GetOrLoad(context.Background(), "hot", 0, func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	assert.ErrorContains(t, err, "boom")
+}
+
+func TestGetOrLoad_CanceledWaiterDoesNotCancelLoad(t *testing.T) {
+	c := NewMemoryCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute})
+	defer c.Close()
+
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(ctx, "hot", time.Minute, func(ctx context.Context) (interface{}, error) {
+			<-release
+			return "quote", ctx.Err()
+		})
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		value, err := c.Get(context.Background(), "hot")
+		return err == nil && value == "quote"
+	}, time.Second, 5*time.Millisecond, "调用方取消后加载仍然完成并写入缓存")
+}
+
+func TestGetOrLoad_StaleWhileRevalidate(t *testing.T) {
+	config := DefaultLayeredCacheConfig()
+	config.Layers[0].StaleTTL = time.Minute
+	layered, err := NewLayeredCache(config)
+	require.NoError(t, err)
+	defer layered.Close()
+
+	caches := map[string]Cache{
+		"memory":  NewMemoryCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute, StaleTTL: time.Minute}),
+		"layered": layered,
+	}
+	ctx := context.Background()
+
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.GetOrLoad(ctx, "hot", 20*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+				return "v1", nil
+			})
+			require.NoError(t, err)
+			time.Sleep(30 * time.Millisecond)
+
+			_, err = c.Get(ctx, "hot")
+			assert.True(t, isCacheMiss(err), "Get 不返回过期条目")
+
+			// 过期后并发读取都立即得到旧值，后台只刷新一次
+			var calls int32
+			release := make(chan struct{})
+			values, errs := runConcurrentLoads(c, 100, func(ctx context.Context) (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return "v2", nil
+			})
+			for i := range values {
+				require.NoError(t, errs[i])
+				assert.Equal(t, "v1", values[i])
+			}
+
+			close(release)
+			assert.Eventually(t, func() bool {
+				value, err := c.Get(ctx, "hot")
+				return err == nil && value == "v2"
+			}, time.Second, 5*time.Millisecond)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestMemoryCache_StaleEntryExpiresAfterGracePeriod(t *testing.T) {
+	c := NewMemoryCache(MemoryCacheConfig{MaxSize: 100, DefaultTTL: time.Minute, StaleTTL: 20 * time.Millisecond})
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "hot", "v1", 10*time.Millisecond))
+	time.Sleep(15 * time.Millisecond)
+	value, ok := c.GetStale(ctx, "hot")
+	assert.True(t, ok)
+	assert.Equal(t, "v1", value)
+
+	time.Sleep(20 * time.Millisecond)
+	_, ok = c.GetStale(ctx, "hot")
+	assert.False(t, ok)
+
+	// 超过宽限期后同步加载
+	value, err := c.GetOrLoad(ctx, "hot", time.Minute, func(ctx context.Context) (interface{}, error) {
+		return "v2", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", value)
+}
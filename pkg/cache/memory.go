@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +16,15 @@ type MemoryCache struct {
 	hitCount   int64
 	missCount  int64
 	defaultTTL time.Duration
+	staleTTL   time.Duration
+	loads      loadGroup
+
+	// 淘汰和内存统计
+	evictions   int64    // 因容量淘汰的条目数（原子操作）
+	expirations int64    // 因过期删除的条目数（原子操作）
+	memoryBytes int64    // 当前条目的估算字节数（持有写锁时更新）
+	sizeFunc    SizeFunc // 估算值大小的函数
+	onEvict     EvictionHandler
 
 	// 清理相关
 	cleanupTicker *time.Ticker
@@ -28,6 +38,8 @@ func NewMemoryCache(config MemoryCacheConfig) *MemoryCache {
 		entries:     make(map[string]*CacheEntry),
 		maxSize:     config.MaxSize,
 		defaultTTL:  config.DefaultTTL,
+		staleTTL:    config.StaleTTL,
+		sizeFunc:    config.SizeFunc,
 		stopCleanup: make(chan struct{}),
 		lastCleanup: time.Now(),
 	}
@@ -46,6 +58,8 @@ type MemoryCacheConfig struct {
 	MaxSize         int64         // 最大条目数量
 	DefaultTTL      time.Duration // 默认TTL
 	CleanupInterval time.Duration // 清理间隔
+	StaleTTL        time.Duration // 条目过期后仍可由 GetOrLoad 返回的宽限期，期间在后台刷新；0 表示不启用
+	SizeFunc        SizeFunc      // 估算值的字节数，为空时使用序列化后的长度
 }
 
 // Get 获取缓存值
@@ -59,11 +73,15 @@ func (mc *MemoryCache) Get(ctx context.Context, key string) (interface{}, error)
 		return nil, NewCacheError(ErrCacheMiss, "cache miss")
 	}
 
-	// 检查过期
-	if entry.ExpireTime.Before(time.Now()) {
-		mc.mu.Lock()
-		delete(mc.entries, key)
-		mc.mu.Unlock()
+	// 检查过期，宽限期内的过期条目保留给 GetOrLoad 使用
+	if now := time.Now(); entry.ExpireTime.Before(now) {
+		if !entry.ExpireTime.Add(mc.staleTTL).After(now) {
+			mc.mu.Lock()
+			if mc.entries[key] == entry {
+				mc.removeEntry(key, EvictionTTL)
+			}
+			mc.mu.Unlock()
+		}
 		atomic.AddInt64(&mc.missCount, 1)
 		return nil, NewCacheError(ErrCacheMiss, "cache miss")
 	}
@@ -89,27 +107,52 @@ func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}, t
 		AccessTime: now,
 		CreateTime: now,
 		HitCount:   0,
-		Size:       estimateSize(value),
+		Size:       mc.sizeOf(value),
 	}
 
 	mc.mu.Lock()
-	defer mc.mu.Unlock()
-
-	// 检查是否需要淘汰
-	if int64(len(mc.entries)) >= mc.maxSize {
-		mc.evictOldest()
+	var evicted map[string]*CacheEntry
+	// 检查是否需要淘汰，覆盖已有的键不占用新的容量
+	if _, exists := mc.entries[key]; !exists && int64(len(mc.entries)) >= mc.maxSize {
+		evicted = mc.evictOldest()
 	}
+	mc.storeEntry(key, entry)
+	mc.mu.Unlock()
 
-	mc.entries[key] = entry
+	mc.notifyEvicted(evicted)
 	return nil
 }
 
+// GetOrLoad 获取缓存值，未命中时合并并发加载；配置了 StaleTTL 时在宽限期内返回旧值并在后台刷新
+func (mc *MemoryCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, mc, &mc.loads, key, ttl, loader)
+}
+
+// GetStale 返回已过期但仍在 StaleTTL 宽限期内的值
+func (mc *MemoryCache) GetStale(ctx context.Context, key string) (interface{}, bool) {
+	if mc.staleTTL <= 0 {
+		return nil, false
+	}
+
+	mc.mu.RLock()
+	entry, exists := mc.entries[key]
+	mc.mu.RUnlock()
+
+	now := time.Now()
+	if !exists || !entry.ExpireTime.Before(now) || !entry.ExpireTime.Add(mc.staleTTL).After(now) {
+		return nil, false
+	}
+	return entry.Value, true
+}
+
 // Delete 删除缓存值
 func (mc *MemoryCache) Delete(ctx context.Context, key string) error {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 
-	delete(mc.entries, key)
+	if _, exists := mc.entries[key]; exists {
+		mc.removeEntry(key, "")
+	}
 	return nil
 }
 
@@ -119,8 +162,11 @@ func (mc *MemoryCache) Clear(ctx context.Context) error {
 	defer mc.mu.Unlock()
 
 	mc.entries = make(map[string]*CacheEntry)
+	mc.memoryBytes = 0
 	atomic.StoreInt64(&mc.hitCount, 0)
 	atomic.StoreInt64(&mc.missCount, 0)
+	atomic.StoreInt64(&mc.evictions, 0)
+	atomic.StoreInt64(&mc.expirations, 0)
 	return nil
 }
 
@@ -128,6 +174,8 @@ func (mc *MemoryCache) Clear(ctx context.Context) error {
 func (mc *MemoryCache) Stats() CacheStats {
 	mc.mu.RLock()
 	size := int64(len(mc.entries))
+	memoryBytes := mc.memoryBytes
+	lastCleanup := mc.lastCleanup
 	mc.mu.RUnlock()
 
 	hitCount := atomic.LoadInt64(&mc.hitCount)
@@ -145,7 +193,10 @@ func (mc *MemoryCache) Stats() CacheStats {
 		MissCount:   missCount,
 		HitRate:     hitRate,
 		TTL:         mc.defaultTTL,
-		LastCleanup: mc.lastCleanup,
+		LastCleanup: lastCleanup,
+		Evictions:   atomic.LoadInt64(&mc.evictions),
+		Expirations: atomic.LoadInt64(&mc.expirations),
+		MemoryBytes: memoryBytes,
 	}
 }
 
@@ -177,7 +228,7 @@ func (mc *MemoryCache) cleanup() {
 
 	mc.mu.RLock()
 	for key, entry := range mc.entries {
-		if entry.ExpireTime.Before(now) {
+		if entry.ExpireTime.Add(mc.staleTTL).Before(now) {
 			expiredKeys = append(expiredKeys, key)
 		}
 	}
@@ -186,15 +237,18 @@ func (mc *MemoryCache) cleanup() {
 	if len(expiredKeys) > 0 {
 		mc.mu.Lock()
 		for _, key := range expiredKeys {
-			delete(mc.entries, key)
+			// 收集之后条目可能已被重新写入
+			if entry, exists := mc.entries[key]; exists && entry.ExpireTime.Add(mc.staleTTL).Before(now) {
+				mc.removeEntry(key, EvictionTTL)
+			}
 		}
 		mc.lastCleanup = now
 		mc.mu.Unlock()
 	}
 }
 
-// evictOldest 淘汰创建时间最早的条目（基于创建时间的淘汰策略）
-func (mc *MemoryCache) evictOldest() {
+// evictOldest 淘汰创建时间最早的条目（基于创建时间的淘汰策略），返回被淘汰的条目（需要持有写锁）
+func (mc *MemoryCache) evictOldest() map[string]*CacheEntry {
 	var oldestKey string
 	var oldestTime time.Time
 
@@ -205,22 +259,80 @@ func (mc *MemoryCache) evictOldest() {
 		}
 	}
 
-	if oldestKey != "" {
-		delete(mc.entries, oldestKey)
+	if oldestKey == "" {
+		return nil
 	}
+	return map[string]*CacheEntry{oldestKey: mc.removeEntry(oldestKey, EvictionCapacity)}
 }
 
-// estimateSize 估算值的大小（简单实现）
+// SetEvictionHandler 注册容量淘汰回调，回调在释放锁之后调用
+func (mc *MemoryCache) SetEvictionHandler(handler EvictionHandler) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.onEvict = handler
+}
+
+// storeEntry 写入条目并更新内存占用（需要持有写锁）
+func (mc *MemoryCache) storeEntry(key string, entry *CacheEntry) {
+	if old, exists := mc.entries[key]; exists {
+		mc.memoryBytes -= old.Size
+	}
+	mc.entries[key] = entry
+	mc.memoryBytes += entry.Size
+}
+
+// removeEntry 删除条目并按原因计数，reason 为空表示主动删除（需要持有写锁）
+func (mc *MemoryCache) removeEntry(key string, reason EvictionReason) *CacheEntry {
+	entry := mc.entries[key]
+	delete(mc.entries, key)
+	mc.memoryBytes -= entry.Size
+
+	switch reason {
+	case EvictionCapacity:
+		atomic.AddInt64(&mc.evictions, 1)
+	case EvictionTTL:
+		atomic.AddInt64(&mc.expirations, 1)
+	}
+	return entry
+}
+
+// notifyEvicted 通知被容量淘汰的条目（不能持有锁）
+func (mc *MemoryCache) notifyEvicted(evicted map[string]*CacheEntry) {
+	if len(evicted) == 0 {
+		return
+	}
+	mc.mu.RLock()
+	handler := mc.onEvict
+	mc.mu.RUnlock()
+	if handler == nil {
+		return
+	}
+	for key, entry := range evicted {
+		handler(key, entry, EvictionCapacity)
+	}
+}
+
+// sizeOf 估算值的字节数，优先使用配置的 SizeFunc
+func (mc *MemoryCache) sizeOf(value interface{}) int64 {
+	if mc.sizeFunc != nil {
+		return mc.sizeFunc(value)
+	}
+	return estimateSize(value)
+}
+
+// estimateSize 估算值的大小：字符串和字节切片取长度，其他值取 JSON 序列化后的长度，无法序列化时按 64 字节估算
 func estimateSize(value interface{}) int64 {
-	// 简单的大小估算，可以根据需要改进
 	switch v := value.(type) {
 	case string:
 		return int64(len(v))
 	case []byte:
 		return int64(len(v))
-	default:
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
 		return 64 // 默认大小
 	}
+	return int64(len(data))
 }
 
 var _ Cache = (*MemoryCache)(nil)
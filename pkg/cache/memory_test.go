@@ -2,11 +2,12 @@ package cache
 
 import (
 	"context"
-
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestMemoryCache_SetAndGet_WithValidData_ReturnsCorrectValue 测试MemoryCache基本操作
@@ -203,8 +204,9 @@ func TestMemoryCache_Set_WithMaxSize_EvictsOldestEntry(t *testing.T) {
 func TestMemoryCache_EstimateSize_WithVariousTypes_ReturnsCorrectSize(t *testing.T) {
 	assert.Equal(t, int64(5), estimateSize("hello"))
 	assert.Equal(t, int64(10), estimateSize([]byte("0123456789")))
-	assert.Equal(t, int64(64), estimateSize(12345))      // default case
-	assert.Equal(t, int64(64), estimateSize(struct{}{})) // default case
+	assert.Equal(t, int64(5), estimateSize(12345))           // 序列化后的长度
+	assert.Equal(t, int64(2), estimateSize(struct{}{}))      // {}
+	assert.Equal(t, int64(64), estimateSize(make(chan int))) // 无法序列化
 }
 
 // TestMemoryCache_Cleanup_WithExpiredEntries_RemovesExpiredItems 测试MemoryCache的cleanup方法
@@ -242,3 +244,67 @@ func TestMemoryCache_Cleanup_WithExpiredEntries_RemovesExpiredItems(t *testing.T
 	stats := cache.Stats()
 	assert.Equal(t, int64(1), stats.Size)
 }
+
+// TestMemoryCache_Stats_EvictionsByReason 测试容量淘汰和 TTL 过期分别计数
+func TestMemoryCache_Stats_EvictionsByReason(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheConfig{MaxSize: 3, DefaultTTL: time.Minute, CleanupInterval: 10 * time.Millisecond})
+	defer cache.Close()
+	ctx := context.Background()
+
+	var evicted []string
+	cache.SetEvictionHandler(func(key string, entry *CacheEntry, reason EvictionReason) {
+		assert.Equal(t, EvictionCapacity, reason)
+		evicted = append(evicted, key)
+	})
+
+	// 超出容量两个条目
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("key%d", i), "value", 0))
+		time.Sleep(2 * time.Millisecond) // 确保创建时间不同
+	}
+	// 覆盖已有的键不触发淘汰
+	require.NoError(t, cache.Set(ctx, "key5", "value", 0))
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.Zero(t, stats.Expirations)
+	assert.Equal(t, []string{"key1", "key2"}, evicted)
+
+	// 剩余条目过期后由清理协程删除
+	require.NoError(t, cache.Set(ctx, "key3", "value", 20*time.Millisecond))
+	require.NoError(t, cache.Set(ctx, "key4", "value", 20*time.Millisecond))
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Expirations == 2
+	}, time.Second, 5*time.Millisecond)
+
+	stats = cache.Stats()
+	assert.Equal(t, int64(1), stats.Size)
+	assert.Equal(t, int64(2), stats.Evictions, "过期删除不计入容量淘汰")
+}
+
+// TestMemoryCache_Stats_MemoryBytes 测试内存占用随写入、覆盖和删除变化
+func TestMemoryCache_Stats_MemoryBytes(t *testing.T) {
+	cache := NewMemoryCache(MemoryCacheConfig{MaxSize: 10, DefaultTTL: time.Minute})
+	defer cache.Close()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "a", "hello", 0))
+	require.NoError(t, cache.Set(ctx, "b", map[string]int{"x": 1}, 0)) // {"x":1}
+	assert.Equal(t, int64(5+7), cache.Stats().MemoryBytes)
+
+	require.NoError(t, cache.Set(ctx, "a", "hi", 0))
+	assert.Equal(t, int64(2+7), cache.Stats().MemoryBytes)
+
+	require.NoError(t, cache.Delete(ctx, "b"))
+	assert.Equal(t, int64(2), cache.Stats().MemoryBytes)
+
+	require.NoError(t, cache.Clear(ctx))
+	assert.Zero(t, cache.Stats().MemoryBytes)
+
+	// 自定义 SizeFunc
+	sized := NewMemoryCache(MemoryCacheConfig{MaxSize: 10, DefaultTTL: time.Minute, SizeFunc: func(interface{}) int64 { return 100 }})
+	defer sized.Close()
+	require.NoError(t, sized.Set(ctx, "a", "hello", 0))
+	require.NoError(t, sized.Set(ctx, "b", "world", 0))
+	assert.Equal(t, int64(200), sized.Stats().MemoryBytes)
+}
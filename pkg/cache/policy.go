@@ -301,32 +301,39 @@ func (sc *SmartCache) Set(ctx context.Context, key string, value interface{}, tt
 		AccessTime: now,
 		CreateTime: now,
 		HitCount:   0,
-		Size:       estimateSize(value),
+		Size:       sc.sizeOf(value),
 	}
 
 	sc.mu.Lock()
-	defer sc.mu.Unlock()
+	evicted := make(map[string]*CacheEntry)
 
 	// 如果达到最大容量，执行淘汰策略
-	if int64(len(sc.entries)) >= sc.maxSize {
+	if _, exists := sc.entries[key]; !exists && int64(len(sc.entries)) >= sc.maxSize {
 		toEvict := sc.policy.ShouldEvict(sc.entries)
 		for _, evictKey := range toEvict {
 			if existingEntry, exists := sc.entries[evictKey]; exists {
 				sc.policy.OnRemove(evictKey, existingEntry)
-				delete(sc.entries, evictKey)
+				evicted[evictKey] = sc.removeEntry(evictKey, EvictionCapacity)
 			}
 		}
 	}
 
 	// 直接设置条目，避免调用基类方法造成双重加锁
-	sc.entries[key] = entry
+	sc.storeEntry(key, entry)
 
 	// 通知策略新增了条目
 	sc.policy.OnAdd(key, entry)
+	sc.mu.Unlock()
 
+	sc.notifyEvicted(evicted)
 	return nil
 }
 
+// GetOrLoad 重写GetOrLoad方法，通过 SmartCache 的 Get 和 Set 读写以通知淘汰策略
+func (sc *SmartCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, sc, &sc.loads, key, ttl, loader)
+}
+
 // Get 重写Get方法，集成访问通知
 func (sc *SmartCache) Get(ctx context.Context, key string) (interface{}, error) {
 	value, err := sc.MemoryCache.Get(ctx, key)
@@ -43,6 +43,7 @@ type remoteCacheBase struct {
 	stats       CacheStats
 	isConnected bool
 	client      interface{} // 具体的客户端实现
+	loads       loadGroup   // 合并同一个键的并发加载，供具体实现的 GetOrLoad 使用
 }
 
 // newRemoteCacheBase 创建远程缓存基础实例
@@ -76,6 +77,11 @@ func (rc *remoteCacheBase) Clear(ctx context.Context) error {
 	return fmt.Errorf("Clear method not implemented")
 }
 
+// GetOrLoad 获取或加载数据（基础实现，需要具体实现重写）
+func (rc *remoteCacheBase) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return nil, fmt.Errorf("GetOrLoad method not implemented")
+}
+
 // Stats 获取缓存统计信息
 func (rc *remoteCacheBase) Stats() CacheStats {
 	rc.mu.RLock()
@@ -218,6 +224,11 @@ func (m *MockRemoteCache) Set(ctx context.Context, key string, value interface{}
 	return nil
 }
 
+// GetOrLoad 从模拟缓存获取数据，未命中时合并同一个键的并发加载
+func (m *MockRemoteCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc) (interface{}, error) {
+	return getOrLoad(ctx, m, &m.loads, key, ttl, loader)
+}
+
 // Delete 从模拟缓存删除数据
 func (m *MockRemoteCache) Delete(ctx context.Context, key string) error {
 	m.mu.Lock()
@@ -49,6 +49,10 @@ func Test_remoteCacheBase_NotImplemented(t *testing.T) {
 	err = base.Clear(ctx)
 	assert.Error(t, err)
 	assert.Equal(t, "Clear method not implemented", err.Error())
+
+	_, err = base.GetOrLoad(ctx, "key", 0, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "GetOrLoad method not implemented", err.Error())
 }
 
 func TestMockRemoteCache_ConnectAndPing(t *testing.T) {
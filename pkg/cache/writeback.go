@@ -0,0 +1,281 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 写回模式的默认刷新策略
+const (
+	DefaultFlushInterval  = 1 * time.Second // 后台刷新间隔
+	DefaultFlushThreshold = 100             // 脏条目达到该数量时立即触发刷新
+)
+
+// dirtyEntry 写回模式下已写入第一层、尚未写入下层的条目
+type dirtyEntry struct {
+	value     interface{}
+	ttl       time.Duration // Set 时的 TTL，0 表示使用各层默认 TTL
+	expireAt  time.Time     // ttl > 0 时的过期时间，过期的条目不再写入下层
+	dirtiedAt time.Time     // 最早一次未刷新的写入时间
+}
+
+// startWriteBack 启动写回模式的后台刷新协程，第一层支持淘汰回调时在淘汰脏条目前先写入下层
+func (lc *LayeredCache) startWriteBack(demote EvictionHandler) {
+	lc.dirty = make(map[string]dirtyEntry)
+	lc.flushSignal = make(chan struct{}, 1)
+	lc.stopFlush = make(chan struct{})
+	lc.flushDone = make(chan struct{})
+
+	if notifier, ok := lc.layers[0].(EvictionNotifier); ok {
+		notifier.SetEvictionHandler(lc.writeBackEvictionHandler(demote))
+	}
+	go lc.flushWorker()
+}
+
+// flushWorker 按 FlushInterval 或脏条目数量达到 FlushThreshold 时把脏条目写入下层
+func (lc *LayeredCache) flushWorker() {
+	defer close(lc.flushDone)
+
+	interval := lc.config.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lc.stopFlush:
+			return
+		case <-ticker.C:
+		case <-lc.flushSignal:
+		}
+		// 失败的条目留在脏集合中，下一轮重试
+		_ = lc.flushDirty(context.Background())
+	}
+}
+
+// markDirty 记录写回模式下待写入下层的条目，脏条目数量达到阈值时唤醒刷新协程
+func (lc *LayeredCache) markDirty(items map[string]interface{}, ttl time.Duration) {
+	now := time.Now()
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = now.Add(ttl)
+	}
+
+	lc.dirtyMu.Lock()
+	for key, value := range items {
+		dirtiedAt := now
+		if prev, exists := lc.dirty[key]; exists {
+			dirtiedAt = prev.dirtiedAt
+		}
+		lc.dirty[key] = dirtyEntry{value: value, ttl: ttl, expireAt: expireAt, dirtiedAt: dirtiedAt}
+	}
+	count := len(lc.dirty)
+	lc.dirtyMu.Unlock()
+
+	threshold := lc.config.FlushThreshold
+	if threshold <= 0 {
+		threshold = DefaultFlushThreshold
+	}
+	if count >= threshold {
+		select {
+		case lc.flushSignal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// forgetDirty 删除脏条目，keys 为空时清空全部
+func (lc *LayeredCache) forgetDirty(keys ...string) {
+	if !lc.writeBack {
+		return
+	}
+	lc.dirtyMu.Lock()
+	defer lc.dirtyMu.Unlock()
+	if len(keys) == 0 {
+		lc.dirty = make(map[string]dirtyEntry)
+		return
+	}
+	for _, key := range keys {
+		delete(lc.dirty, key)
+	}
+}
+
+// lockFlush 写回模式下阻止刷新，Delete 和 Clear 期间不会有条目被写入下层
+func (lc *LayeredCache) lockFlush() {
+	if lc.writeBack {
+		lc.flushMu.Lock()
+	}
+}
+
+func (lc *LayeredCache) unlockFlush() {
+	if lc.writeBack {
+		lc.flushMu.Unlock()
+	}
+}
+
+// flushDirty 把当前全部脏条目按 TTL 分组批量写入下层，写入失败的条目重新标记为脏，
+// 除非刷新期间又有新的写入（需要时由下一次刷新写入新值）
+func (lc *LayeredCache) flushDirty(ctx context.Context) error {
+	if !lc.writeBack {
+		return nil
+	}
+	// 串行刷新，避免较早的批次覆盖淘汰时同步写入的新值
+	lc.flushMu.Lock()
+	defer lc.flushMu.Unlock()
+
+	lc.dirtyMu.Lock()
+	pending := lc.dirty
+	lc.dirty = make(map[string]dirtyEntry)
+	lc.dirtyMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	groups := make(map[time.Duration]map[string]interface{})
+	for key, entry := range pending {
+		if !entry.expireAt.IsZero() && !now.Before(entry.expireAt) {
+			continue
+		}
+		if groups[entry.ttl] == nil {
+			groups[entry.ttl] = make(map[string]interface{})
+		}
+		groups[entry.ttl][key] = entry.value
+	}
+
+	var lastErr error
+	failed := make(map[string]bool)
+	for ttl, items := range groups {
+		for i := 1; i < len(lc.layers); i++ {
+			if err := lc.setLayer(ctx, i, items, ttl); err != nil {
+				lastErr = err
+				for key := range items {
+					failed[key] = true
+				}
+			}
+		}
+	}
+
+	lc.dirtyMu.Lock()
+	for key := range failed {
+		if _, rewritten := lc.dirty[key]; !rewritten {
+			lc.dirty[key] = pending[key]
+		}
+	}
+	lc.dirtyMu.Unlock()
+
+	var flushed int64
+	for _, items := range groups {
+		for key := range items {
+			if !failed[key] {
+				flushed++
+			}
+		}
+	}
+	atomic.AddInt64(&lc.stats.WriteBack, flushed)
+	return lastErr
+}
+
+// setLayer 向第 i 层写入一组条目，支持 BatchSetter 时批量写入
+func (lc *LayeredCache) setLayer(ctx context.Context, i int, items map[string]interface{}, ttl time.Duration) error {
+	layer := lc.layers[i]
+	if batchSetter, ok := layer.(BatchSetter); ok {
+		if err := batchSetter.BatchSet(ctx, items, ttl); err != nil {
+			return fmt.Errorf("缓存层 %d (%s) 写回失败: %w", i, lc.getLayerType(i), err)
+		}
+		return nil
+	}
+	for key, value := range items {
+		if err := layer.Set(ctx, key, value, ttl); err != nil {
+			return fmt.Errorf("缓存层 %d (%s) 写回失败: %w", i, lc.getLayerType(i), err)
+		}
+	}
+	return nil
+}
+
+// writeBackEvictionHandler 第一层因容量淘汰脏条目时先同步写入下层再丢弃，
+// 非脏条目按 DemoteEnabled 交给 demote（可为 nil）
+func (lc *LayeredCache) writeBackEvictionHandler(demote EvictionHandler) EvictionHandler {
+	return func(key string, entry *CacheEntry, reason EvictionReason) {
+		if lc.flushEvicted(key, reason) {
+			return
+		}
+		if demote != nil {
+			demote(key, entry, reason)
+		}
+	}
+}
+
+// flushEvicted 同步写入被淘汰的脏条目，返回该键是否为脏条目。写入失败时保留在脏集合中由后台刷新重试
+func (lc *LayeredCache) flushEvicted(key string, reason EvictionReason) bool {
+	lc.flushMu.Lock()
+	defer lc.flushMu.Unlock()
+
+	lc.dirtyMu.Lock()
+	entry, dirty := lc.dirty[key]
+	delete(lc.dirty, key)
+	lc.dirtyMu.Unlock()
+	if !dirty || reason != EvictionCapacity {
+		return dirty
+	}
+
+	// 以剩余 TTL 写入，避免延长条目的有效期
+	ttl := entry.ttl
+	if !entry.expireAt.IsZero() {
+		if ttl = time.Until(entry.expireAt); ttl <= 0 {
+			return true
+		}
+	}
+	items := map[string]interface{}{key: entry.value}
+	for i := 1; i < len(lc.layers); i++ {
+		if err := lc.setLayer(context.Background(), i, items, ttl); err != nil {
+			lc.dirtyMu.Lock()
+			if _, rewritten := lc.dirty[key]; !rewritten {
+				lc.dirty[key] = entry
+			}
+			lc.dirtyMu.Unlock()
+			return true
+		}
+	}
+	atomic.AddInt64(&lc.stats.WriteBack, 1)
+	return true
+}
+
+// dirtyStats 返回脏条目数量和最早的脏条目已等待的时长
+func (lc *LayeredCache) dirtyStats() (int64, time.Duration) {
+	if !lc.writeBack {
+		return 0, 0
+	}
+	lc.dirtyMu.Lock()
+	defer lc.dirtyMu.Unlock()
+
+	var oldest time.Time
+	for _, entry := range lc.dirty {
+		if oldest.IsZero() || entry.dirtiedAt.Before(oldest) {
+			oldest = entry.dirtiedAt
+		}
+	}
+	if oldest.IsZero() {
+		return 0, 0
+	}
+	return int64(len(lc.dirty)), time.Since(oldest)
+}
+
+// stopWriteBack 停止后台刷新协程并同步写入剩余的脏条目
+func (lc *LayeredCache) stopWriteBack() error {
+	if !lc.writeBack {
+		return nil
+	}
+	close(lc.stopFlush)
+	<-lc.flushDone
+	return lc.flushDirty(context.Background())
+}
+
+// FlushNow 立即把写回模式下的全部脏条目写入下层，非写回模式下直接返回
+func (lc *LayeredCache) FlushNow() error {
+	return lc.Flush(context.Background())
+}
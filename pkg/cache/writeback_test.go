@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWriteBackTestCache 创建两层 mockLayer 的写回模式缓存
+func newWriteBackTestCache(t *testing.T, interval time.Duration, threshold int) (*LayeredCache, *mockLayer, *mockLayer) {
+	t.Helper()
+	top, lower := newMockLayer("top"), newMockLayer("lower")
+	cache, err := NewLayeredCacheWithFactories(LayeredCacheConfig{
+		Layers: []LayerConfig{
+			{Type: "top", Enabled: true},
+			{Type: "lower", Enabled: true},
+		},
+		WriteBack:      true,
+		FlushInterval:  interval,
+		FlushThreshold: threshold,
+	}, map[LayerType]LayerFactory{
+		"top":   &mockFactory{layerType: "top", layer: top},
+		"lower": &mockFactory{layerType: "lower", layer: lower},
+	})
+	require.NoError(t, err)
+	return cache, top, lower
+}
+
+func (m *mockLayer) len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.data)
+}
+
+func TestLayeredCache_WriteBack_LowerLayerLagsThenConverges(t *testing.T) {
+	cache, top, lower := newWriteBackTestCache(t, 100*time.Millisecond, 1000)
+	defer cache.Close()
+	ctx := context.Background()
+
+	const n = 50
+	for i := 0; i < n; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("k%d", i), i, 0))
+	}
+
+	// Set 只写第一层
+	assert.Equal(t, n, top.len())
+	assert.Zero(t, lower.len())
+	stats := cache.Stats()
+	assert.Equal(t, int64(n), stats.DirtyEntries)
+	assert.Positive(t, stats.DirtyAge)
+
+	assert.Eventually(t, func() bool { return lower.len() == n }, 2*time.Second, 10*time.Millisecond)
+	stats = cache.Stats()
+	assert.Zero(t, stats.DirtyEntries)
+	assert.Zero(t, stats.DirtyAge)
+	assert.Equal(t, int64(n), stats.WriteBacks)
+
+	value, err := lower.Get(ctx, "k7")
+	require.NoError(t, err)
+	assert.Equal(t, 7, value)
+}
+
+func TestLayeredCache_WriteBack_ThresholdTriggersFlush(t *testing.T) {
+	cache, _, lower := newWriteBackTestCache(t, time.Hour, 10)
+	defer cache.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 9; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("k%d", i), i, 0))
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, lower.len(), "未达到阈值时等待刷新间隔")
+
+	require.NoError(t, cache.Set(ctx, "k9", 9, 0))
+	assert.Eventually(t, func() bool { return lower.len() == 10 }, time.Second, 5*time.Millisecond)
+}
+
+func TestLayeredCache_WriteBack_CloseFlushesDirtyEntries(t *testing.T) {
+	cache, _, lower := newWriteBackTestCache(t, time.Hour, 1000)
+	ctx := context.Background()
+
+	items := map[string]any{}
+	for i := 0; i < 30; i++ {
+		items[fmt.Sprintf("batch%d", i)] = i
+	}
+	require.NoError(t, cache.BatchSet(ctx, items, 0))
+	require.NoError(t, cache.Set(ctx, "single", "v", 0))
+	require.Zero(t, lower.len())
+
+	require.NoError(t, cache.Close())
+	assert.Equal(t, 31, lower.len())
+	assert.Zero(t, cache.Stats().DirtyEntries)
+}
+
+func TestLayeredCache_WriteBack_FlushNowAndRetry(t *testing.T) {
+	cache, _, lower := newWriteBackTestCache(t, time.Hour, 1000)
+	defer cache.Close()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "k1", "v1", 0))
+	require.NoError(t, cache.Set(ctx, "k2", "v2", 0))
+
+	lower.mu.Lock()
+	lower.setError = errors.New("lower unavailable")
+	lower.mu.Unlock()
+	err := cache.FlushNow()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "lower unavailable")
+	assert.Equal(t, int64(2), cache.Stats().DirtyEntries, "写入失败的条目保留到下一次刷新")
+
+	// 刷新失败后又写入的新值不会被旧值覆盖
+	require.NoError(t, cache.Set(ctx, "k1", "v1-new", 0))
+	lower.mu.Lock()
+	lower.setError = nil
+	lower.mu.Unlock()
+	require.NoError(t, cache.FlushNow())
+
+	value, err := lower.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1-new", value)
+	assert.Equal(t, 2, lower.len())
+	assert.Zero(t, cache.Stats().DirtyEntries)
+}
+
+func TestLayeredCache_WriteBack_DeleteAndExpiredNotFlushed(t *testing.T) {
+	cache, _, lower := newWriteBackTestCache(t, time.Hour, 1000)
+	defer cache.Close()
+	ctx := context.Background()
+
+	require.NoError(t, cache.Set(ctx, "deleted", "v", 0))
+	require.NoError(t, cache.Delete(ctx, "deleted"))
+	require.NoError(t, cache.Set(ctx, "expired", "v", 10*time.Millisecond))
+	require.NoError(t, cache.Set(ctx, "kept", "v", time.Minute))
+	time.Sleep(20 * time.Millisecond)
+
+	require.NoError(t, cache.FlushNow())
+	assert.Equal(t, 1, lower.len())
+	_, err := lower.Get(ctx, "kept")
+	assert.NoError(t, err)
+}
+
+func TestLayeredCache_WriteBack_CapacityEvictionFlushesDirtyEntry(t *testing.T) {
+	cache, err := NewLayeredCache(LayeredCacheConfig{
+		Layers: []LayerConfig{
+			{Type: LayerMemory, MaxSize: 5, TTL: time.Minute, Enabled: true, Policy: PolicyLRU, CleanupInterval: time.Minute},
+			{Type: LayerMemory, MaxSize: 100, TTL: time.Minute, Enabled: true, CleanupInterval: time.Minute},
+		},
+		WriteBack:     true,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+	ctx := context.Background()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		require.NoError(t, cache.Set(ctx, fmt.Sprintf("k%d", i), i, 0))
+		time.Sleep(time.Millisecond)
+	}
+
+	// 被淘汰的脏条目已同步写入第二层，仍在第一层的条目等待刷新
+	stats := cache.Stats()
+	assert.Equal(t, int64(5), stats.Layers[0].Size)
+	assert.Equal(t, int64(n-5), stats.Layers[1].Size)
+	assert.Equal(t, int64(5), stats.DirtyEntries)
+	assert.Equal(t, int64(n-5), stats.WriteBacks)
+
+	require.NoError(t, cache.FlushNow())
+	for i := 0; i < n; i++ {
+		value, err := cache.layers[1].Get(ctx, fmt.Sprintf("k%d", i))
+		require.NoError(t, err, "k%d", i)
+		assert.Equal(t, i, value)
+	}
+	assert.Zero(t, cache.Stats().DirtyEntries)
+}
+
+func TestLayeredCache_WriteThroughTakesPrecedence(t *testing.T) {
+	top, lower := newMockLayer("top"), newMockLayer("lower")
+	cache, err := NewLayeredCacheWithFactories(LayeredCacheConfig{
+		Layers:       []LayerConfig{{Type: "top", Enabled: true}, {Type: "lower", Enabled: true}},
+		WriteThrough: true,
+		WriteBack:    true,
+	}, map[LayerType]LayerFactory{
+		"top":   &mockFactory{layerType: "top", layer: top},
+		"lower": &mockFactory{layerType: "lower", layer: lower},
+	})
+	require.NoError(t, err)
+	defer cache.Close()
+
+	require.NoError(t, cache.Set(context.Background(), "k1", "v1", 0))
+	assert.Equal(t, 1, lower.len())
+	assert.Zero(t, cache.Stats().DirtyEntries)
+}
@@ -0,0 +1,291 @@
+// Package client api_server HTTP 接口的 Go 客户端，响应结构与服务端共用 pkg/apitypes
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"stocksub/pkg/apitypes"
+)
+
+const (
+	// DefaultBaseURL 未指定 WithBaseURL 时使用的服务地址
+	DefaultBaseURL = "http://localhost:8080"
+
+	apiKeyHeader = "X-API-Key"
+	traceHeader  = "X-Data-Trace-ID"
+
+	// maxErrorBody 读取非 JSON 错误响应体的最大字节数
+	maxErrorBody = 4 << 10
+)
+
+// RetryPolicy 重试策略：429 按 Retry-After 等待，502、503、504 和网络错误按指数退避
+type RetryPolicy struct {
+	MaxRetries int           // 最大重试次数，0 表示不重试
+	Backoff    time.Duration // 没有 Retry-After 时的首次等待时间，之后每次翻倍
+	MaxWait    time.Duration // 单次等待上限，Retry-After 超过该值时直接返回错误，0 表示不限制
+}
+
+// DefaultRetryPolicy 默认重试 2 次，首次退避 200ms，最多等待 10s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: 2, Backoff: 200 * time.Millisecond, MaxWait: 10 * time.Second}
+}
+
+// Client api_server 客户端，可以被多个 goroutine 共用
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	timeout    time.Duration // 单次请求的超时时间，通过 context 控制
+	retry      RetryPolicy
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Option 客户端的创建选项
+type Option func(*Client)
+
+// WithBaseURL 设置服务地址，如 http://api.example.com:8080
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithAPIKey 设置通过 X-API-Key 请求头发送的 API Key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithTimeout 设置单次请求的超时时间，重试的每次请求分别计时
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy 设置重试策略
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithHTTPClient 使用指定的 HTTP 客户端
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New 创建客户端
+func New(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		httpClient: http.DefaultClient,
+		timeout:    10 * time.Second,
+		retry:      DefaultRetryPolicy(),
+		sleep:      sleepContext,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetStock 获取单只股票的最新行情，TraceID 取自 X-Data-Trace-ID 响应头
+func (c *Client) GetStock(ctx context.Context, symbol string) (*apitypes.StockResponse, error) {
+	var stock apitypes.StockResponse
+	header, err := c.get(ctx, "/api/v1/stocks/"+url.PathEscape(symbol), nil, &stock)
+	if err != nil {
+		return nil, err
+	}
+	stock.TraceID = header.Get(traceHeader)
+	return &stock, nil
+}
+
+// GetStocks 按代码批量获取最新行情，Data 与请求顺序一致，没有数据的代码列在 Missing 中；
+// 不指定代码时返回全部股票，按代码排序
+func (c *Client) GetStocks(ctx context.Context, symbols ...string) (*apitypes.BatchStocksResponse, error) {
+	if len(symbols) == 0 {
+		var stocks []apitypes.StockResponse
+		if _, err := c.get(ctx, "/api/v1/stocks", nil, &stocks); err != nil {
+			return nil, err
+		}
+		return &apitypes.BatchStocksResponse{Data: stocks, Missing: []string{}}, nil
+	}
+
+	var response apitypes.BatchStocksResponse
+	query := url.Values{"symbols": {strings.Join(symbols, ",")}}
+	if _, err := c.get(ctx, "/api/v1/stocks", query, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetIndices 获取全部指数的最新行情
+func (c *Client) GetIndices(ctx context.Context) ([]apitypes.IndexResponse, error) {
+	var indices []apitypes.IndexResponse
+	if _, err := c.get(ctx, "/api/v1/indices", nil, &indices); err != nil {
+		return nil, err
+	}
+	return indices, nil
+}
+
+// GetStockHistory 获取股票历史数据；start、end 为零值时使用服务端默认范围，
+// interval 为空时返回原始数据点 Data，否则返回聚合 K 线 Bars（如 1m、5m、1h、1d）
+func (c *Client) GetStockHistory(ctx context.Context, symbol string, start, end time.Time, interval string) (*apitypes.HistoricalResponse, error) {
+	query := url.Values{}
+	if !start.IsZero() {
+		query.Set("start", start.Format(time.RFC3339))
+	}
+	if !end.IsZero() {
+		query.Set("end", end.Format(time.RFC3339))
+	}
+	if interval != "" {
+		query.Set("interval", interval)
+	}
+
+	var history apitypes.HistoricalResponse
+	if _, err := c.get(ctx, "/api/v1/stocks/"+url.PathEscape(symbol)+"/history", query, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// get 发送 GET 请求并把响应体解码到 out，按重试策略重试，返回最后一次响应的响应头
+func (c *Client) get(ctx context.Context, path string, query url.Values, out interface{}) (http.Header, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	backoff := c.retry.Backoff
+	for attempt := 0; ; attempt++ {
+		header, body, err := c.do(ctx, target)
+		if err == nil {
+			return header, decodeBody(header, body, out)
+		}
+
+		wait, retryable := c.retryDelay(err, backoff)
+		if !retryable || attempt >= c.retry.MaxRetries || ctx.Err() != nil {
+			return header, err
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return header, err
+		}
+		backoff *= 2
+	}
+}
+
+// do 发送一次请求，非 2xx 响应返回 *APIError
+func (c *Client) do(ctx context.Context, target string) (http.Header, []byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set(apiKeyHeader, c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request %s: %w", req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.Header, nil, fmt.Errorf("read response %s: %w", req.URL.Path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Header, nil, newAPIError(resp.StatusCode, resp.Header, body)
+	}
+	return resp.Header, body, nil
+}
+
+// retryDelay 返回重试前的等待时间：429 使用 Retry-After，可重试的状态码和网络错误使用退避时间
+func (c *Client) retryDelay(err error, backoff time.Duration) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// 网络错误；调用方取消的请求由调用处的 ctx.Err() 判断
+		return backoff, true
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		wait := apiErr.RetryAfter
+		if wait <= 0 {
+			wait = backoff
+		}
+		if c.retry.MaxWait > 0 && wait > c.retry.MaxWait {
+			return 0, false
+		}
+		return wait, true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if c.retry.MaxWait > 0 {
+			backoff = min(backoff, c.retry.MaxWait)
+		}
+		return backoff, true
+	default:
+		return 0, false
+	}
+}
+
+// decodeBody 解码成功响应；休市时服务端返回 200 和带 market_closed 的错误响应，转换为 ErrMarketClosed
+func decodeBody(header http.Header, body []byte, out interface{}) error {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' && bytes.Contains(trimmed, []byte(`"market_closed"`)) {
+		var response apitypes.ErrorResponse
+		if json.Unmarshal(trimmed, &response) == nil && response.MarketClosed {
+			return &APIError{StatusCode: http.StatusOK, Response: response}
+		}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response (%s): %w", header.Get("Content-Type"), err)
+	}
+	return nil
+}
+
+// parseRetryAfter 解析秒数或 HTTP 日期格式的 Retry-After
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
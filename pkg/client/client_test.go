@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/apitypes"
+	apperrors "stocksub/pkg/error"
+)
+
+// newTestClient 连接 handler 的客户端，等待时间记录到 waits 而不是真正休眠
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) (*Client, *[]time.Duration) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c := New(append([]Option{WithBaseURL(server.URL + "/")}, opts...)...)
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return c, &waits
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestClient_GetStock(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/stocks/600000", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+		w.Header().Set("X-Data-Trace-ID", "trace-1")
+		writeJSON(w, http.StatusOK, apitypes.StockResponse{Symbol: "600000", Name: "浦发银行", Price: 10.5, Volume: 1000})
+	}, WithAPIKey("secret"))
+
+	stock, err := c.GetStock(context.Background(), "600000")
+	require.NoError(t, err)
+	assert.Equal(t, "浦发银行", stock.Name)
+	assert.Equal(t, 10.5, stock.Price)
+	assert.Equal(t, "trace-1", stock.TraceID)
+}
+
+func TestClient_GetStocks(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/stocks", r.URL.Path)
+		if r.URL.Query().Get("symbols") == "" {
+			writeJSON(w, http.StatusOK, []apitypes.StockResponse{{Symbol: "000001"}, {Symbol: "600000"}})
+			return
+		}
+		assert.Equal(t, "600000,000001,999999", r.URL.Query().Get("symbols"))
+		writeJSON(w, http.StatusOK, apitypes.BatchStocksResponse{
+			Data:    []apitypes.StockResponse{{Symbol: "600000"}, {Symbol: "000001"}},
+			Missing: []string{"999999"},
+		})
+	})
+
+	batch, err := c.GetStocks(context.Background(), "600000", "000001", "999999")
+	require.NoError(t, err)
+	require.Len(t, batch.Data, 2)
+	assert.Equal(t, "600000", batch.Data[0].Symbol)
+	assert.Equal(t, []string{"999999"}, batch.Missing)
+
+	all, err := c.GetStocks(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all.Data, 2)
+	assert.Empty(t, all.Missing)
+}
+
+func TestClient_GetIndices(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/indices", r.URL.Path)
+		writeJSON(w, http.StatusOK, []apitypes.IndexResponse{{Symbol: "sh000001", Name: "上证指数", Value: 3200.12}})
+	})
+
+	indices, err := c.GetIndices(context.Background())
+	require.NoError(t, err)
+	require.Len(t, indices, 1)
+	assert.Equal(t, 3200.12, indices[0].Value)
+}
+
+func TestClient_GetStockHistory(t *testing.T) {
+	start := time.Date(2025, 8, 20, 9, 30, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/stocks/600000/history", r.URL.Path)
+		query := r.URL.Query()
+		assert.Equal(t, start.Format(time.RFC3339), query.Get("start"))
+		assert.Equal(t, end.Format(time.RFC3339), query.Get("end"))
+		assert.Equal(t, "5m", query.Get("interval"))
+		writeJSON(w, http.StatusOK, apitypes.HistoricalResponse{
+			Symbol: "600000", Start: start, End: end, Interval: "5m",
+			Bars: []apitypes.HistoricalBar{{Timestamp: start, Open: 10, High: 11, Low: 9.5, Close: 10.8, Volume: 500}},
+		})
+	})
+
+	history, err := c.GetStockHistory(context.Background(), "600000", start, end, "5m")
+	require.NoError(t, err)
+	require.Len(t, history.Bars, 1)
+	assert.Equal(t, 10.8, history.Bars[0].Close)
+	assert.True(t, history.Start.Equal(start))
+}
+
+func TestClient_GetStockHistoryOmitsZeroRange(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.RawQuery)
+		writeJSON(w, http.StatusOK, apitypes.HistoricalResponse{Symbol: "600000"})
+	})
+
+	_, err := c.GetStockHistory(context.Background(), "600000", time.Time{}, time.Time{}, "")
+	require.NoError(t, err)
+}
+
+func TestClient_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response apitypes.ErrorResponse
+		want     error
+		code     apperrors.ErrorCode
+	}{
+		{"symbol not found", http.StatusNotFound, apitypes.ErrorResponse{Error: "not_found", Code: apperrors.CodeSymbolNotFound}, ErrNotFound, apperrors.CodeSymbolNotFound},
+		{"unauthorized", http.StatusUnauthorized, apitypes.ErrorResponse{Error: "unauthorized"}, ErrUnauthorized, ""},
+		{"bad request", http.StatusBadRequest, apitypes.ErrorResponse{Error: "invalid_interval"}, ErrBadRequest, ""},
+		{"upstream throttled", http.StatusServiceUnavailable, apitypes.ErrorResponse{Error: "upstream_throttled", Code: apperrors.CodeUpstreamThrottled}, ErrUnavailable, apperrors.CodeUpstreamThrottled},
+		{"timeout", http.StatusGatewayTimeout, apitypes.ErrorResponse{Error: "timeout", Code: apperrors.CodeNetworkTimeout}, ErrTimeout, apperrors.CodeNetworkTimeout},
+		{"internal", http.StatusInternalServerError, apitypes.ErrorResponse{Error: "internal_error"}, ErrServer, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, tt.status, tt.response)
+			}, WithRetryPolicy(RetryPolicy{}))
+
+			_, err := c.GetStock(context.Background(), "600000")
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.want)
+			assert.Equal(t, tt.code, apperrors.Code(err))
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			assert.Equal(t, tt.status, apiErr.StatusCode)
+			assert.Equal(t, tt.response.Error, apiErr.Response.Error)
+		})
+	}
+}
+
+func TestClient_NonJSONErrorBody(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html>bad gateway</html>"))
+	}, WithRetryPolicy(RetryPolicy{}))
+
+	_, err := c.GetIndices(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrServer)
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "bad_gateway", apiErr.Response.Error)
+	assert.Contains(t, apiErr.Response.Message, "bad gateway")
+}
+
+func TestClient_MarketClosed(t *testing.T) {
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, apitypes.ErrorResponse{Error: "market_closed", Code: apperrors.CodeMarketClosed, MarketClosed: true})
+	})
+
+	_, err := c.GetStock(context.Background(), "600000")
+	assert.ErrorIs(t, err, ErrMarketClosed)
+	assert.True(t, apperrors.Is(err, apperrors.CodeMarketClosed))
+}
+
+func TestClient_RetriesRateLimitedWithRetryAfter(t *testing.T) {
+	calls := 0
+	c, waits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.Header().Set("Retry-After", "2")
+			writeJSON(w, http.StatusTooManyRequests, apitypes.ErrorResponse{Error: "rate_limited", Code: apperrors.CodeRateLimited})
+			return
+		}
+		writeJSON(w, http.StatusOK, []apitypes.IndexResponse{{Symbol: "sh000001"}})
+	})
+
+	indices, err := c.GetIndices(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, indices, 1)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{2 * time.Second, 2 * time.Second}, *waits)
+}
+
+func TestClient_RetriesExhausted(t *testing.T) {
+	calls := 0
+	c, waits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeJSON(w, http.StatusServiceUnavailable, apitypes.ErrorResponse{Error: "unavailable"})
+	}, WithRetryPolicy(RetryPolicy{MaxRetries: 2, Backoff: 100 * time.Millisecond, MaxWait: 150 * time.Millisecond}))
+
+	_, err := c.GetStock(context.Background(), "600000")
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 150 * time.Millisecond}, *waits, "退避时间翻倍且不超过 MaxWait")
+}
+
+func TestClient_RetryAfterBeyondMaxWaitFailsFast(t *testing.T) {
+	calls := 0
+	c, waits := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "60")
+		writeJSON(w, http.StatusTooManyRequests, apitypes.ErrorResponse{Error: "rate_limited", Code: apperrors.CodeRateLimited})
+	})
+
+	_, err := c.GetStock(context.Background(), "600000")
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, *waits)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, time.Minute, apiErr.RetryAfter)
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	c, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeJSON(w, http.StatusNotFound, apitypes.ErrorResponse{Error: "not_found", Code: apperrors.CodeSymbolNotFound})
+	})
+
+	_, err := c.GetStock(context.Background(), "999999")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, calls)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter(now.Add(-time.Second).Format(http.TimeFormat), now))
+	assert.Zero(t, parseRetryAfter("soon", now))
+	assert.Zero(t, parseRetryAfter("", now))
+}
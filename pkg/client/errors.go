@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"stocksub/pkg/apitypes"
+	apperrors "stocksub/pkg/error"
+)
+
+// 按错误代码或状态码归类的错误，用 errors.Is 判断
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrTimeout      = errors.New("timeout")
+	ErrUnavailable  = errors.New("service unavailable")
+	ErrMarketClosed = errors.New("market closed")
+	ErrServer       = errors.New("server error")
+)
+
+// codeErrors 服务端错误代码对应的错误，优先于状态码
+var codeErrors = map[apperrors.ErrorCode]error{
+	apperrors.CodeSymbolNotFound:    ErrNotFound,
+	apperrors.CodeRateLimited:       ErrRateLimited,
+	apperrors.CodeUpstreamThrottled: ErrUnavailable,
+	apperrors.CodeNetworkTimeout:    ErrTimeout,
+	apperrors.CodeMarketClosed:      ErrMarketClosed,
+}
+
+// statusErrors 没有错误代码时按状态码归类
+var statusErrors = map[int]error{
+	http.StatusBadRequest:         ErrBadRequest,
+	http.StatusUnauthorized:       ErrUnauthorized,
+	http.StatusForbidden:          ErrUnauthorized,
+	http.StatusNotFound:           ErrNotFound,
+	http.StatusTooManyRequests:    ErrRateLimited,
+	http.StatusServiceUnavailable: ErrUnavailable,
+	http.StatusGatewayTimeout:     ErrTimeout,
+}
+
+// APIError 服务端返回的错误响应。errors.Is 可以与 ErrNotFound 等比较，
+// 带错误代码时 apperrors.Is(err, apperrors.CodeSymbolNotFound) 同样成立
+type APIError struct {
+	StatusCode int
+	Response   apitypes.ErrorResponse
+	RetryAfter time.Duration // 429 响应的 Retry-After
+}
+
+// newAPIError 解析错误响应体，不是 ErrorResponse 时（如代理返回的 HTML）以响应体开头作为 Message
+func newAPIError(status int, header http.Header, body []byte) *APIError {
+	e := &APIError{StatusCode: status, RetryAfter: parseRetryAfter(header.Get("Retry-After"), time.Now())}
+	if err := json.Unmarshal(body, &e.Response); err != nil || e.Response.Error == "" {
+		text := strings.TrimSpace(string(body[:min(len(body), maxErrorBody)]))
+		e.Response = apitypes.ErrorResponse{Error: strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_")), Message: text}
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("api error %d %s", e.StatusCode, e.Response.Error)
+	if e.Response.Code != "" {
+		msg += " (" + string(e.Response.Code) + ")"
+	}
+	if e.Response.Message != "" {
+		msg += ": " + e.Response.Message
+	}
+	return msg
+}
+
+// kind 返回错误归类，无法归类的 5xx 为 ErrServer
+func (e *APIError) kind() error {
+	if err, ok := codeErrors[e.Response.Code]; ok {
+		return err
+	}
+	if err, ok := statusErrors[e.StatusCode]; ok {
+		return err
+	}
+	if e.StatusCode >= 500 {
+		return ErrServer
+	}
+	return nil
+}
+
+// Unwrap 返回错误归类和带错误代码的 apperrors.BaseError
+func (e *APIError) Unwrap() []error {
+	var errs []error
+	if kind := e.kind(); kind != nil {
+		errs = append(errs, kind)
+	}
+	if e.Response.Code != "" {
+		errs = append(errs, apperrors.NewError(e.Response.Code, e.Response.Message))
+	}
+	return errs
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Validator 收集配置检查发现的全部问题，Err 把它们汇总为一个错误，
+// 供 api_server 和各 collector 的 Config.Validate 使用。field 为配置文件中的键路径，如 server.port
+type Validator struct {
+	errs []error
+}
+
+// Addf 记录一个问题
+func (v *Validator) Addf(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+// Port 检查端口为 1-65535 的数字
+func (v *Validator) Port(field, port string) {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		v.Addf("%s: invalid port %q, must be a number between 1 and 65535", field, port)
+	}
+}
+
+// Addr 检查 host:port 形式的地址，host 可以为空（监听所有地址）
+func (v *Validator) Addr(field, addr string) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		v.Addf("%s: invalid address %q: %v", field, addr, err)
+		return
+	}
+	v.Port(field, port)
+}
+
+// URL 检查 http 或 https 地址
+func (v *Validator) URL(field, raw string) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		v.Addf("%s: invalid url %q: %v", field, raw, err)
+		return
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.Addf("%s: invalid url %q, must be http(s)://host[:port]", field, raw)
+	}
+}
+
+// OneOf 检查取值属于 allowed
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.Addf("%s: invalid value %q, must be one of %s", field, value, strings.Join(allowed, ", "))
+}
+
+// NotEmpty 检查字符串非空
+func (v *Validator) NotEmpty(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.Addf("%s: must not be empty", field)
+	}
+}
+
+// Positive 检查数值大于 0
+func (v *Validator) Positive(field string, value int64) {
+	if value <= 0 {
+		v.Addf("%s: must be positive, got %d", field, value)
+	}
+}
+
+// NonNegative 检查数值不小于 0
+func (v *Validator) NonNegative(field string, value int64) {
+	if value < 0 {
+		v.Addf("%s: must not be negative, got %d", field, value)
+	}
+}
+
+// PositiveDuration 检查时长大于 0
+func (v *Validator) PositiveDuration(field string, d time.Duration) {
+	if d <= 0 {
+		v.Addf("%s: must be a positive duration, got %v", field, d)
+	}
+}
+
+// NonNegativeDuration 检查时长不小于 0，0 通常表示关闭对应功能
+func (v *Validator) NonNegativeDuration(field string, d time.Duration) {
+	if d < 0 {
+		v.Addf("%s: must not be negative, got %v", field, d)
+	}
+}
+
+// Err 汇总全部问题，没有问题时返回 nil
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid config (%d problems):\n%w", len(v.errs), errors.Join(v.errs...))
+}
+
+// redactedValue 替换敏感配置值的占位符
+const redactedValue = "******"
+
+// sensitiveKeys 值需要隐藏的配置键（小写，viper 的键均为小写）
+var sensitiveKeys = map[string]bool{
+	"password": true,
+	"token":    true,
+	"secret":   true,
+	"key":      true, // api_keys[].key
+	"api_key":  true,
+}
+
+// RedactSettings 返回 settings（如 viper.AllSettings()）的副本，敏感键的非空值替换为 ******，
+// 空值保留以便看出未配置
+func RedactSettings(settings map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		redacted[key] = redactValue(key, value)
+	}
+	return redacted
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return RedactSettings(v)
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = redactValue(key, item)
+		}
+		return items
+	}
+	if sensitiveKeys[strings.ToLower(key)] && fmt.Sprint(value) != "" {
+		return redactedValue
+	}
+	return value
+}
+
+// WriteRedacted 以 YAML 格式写出隐藏敏感值后的配置，用于 --check-config
+func WriteRedacted(w io.Writer, settings map[string]interface{}) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(RedactSettings(settings)); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return encoder.Close()
+}
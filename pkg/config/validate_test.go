@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_NoProblems(t *testing.T) {
+	var v Validator
+	v.Port("server.port", "8080")
+	v.Addr("redis.addr", "localhost:6379")
+	v.Addr("metrics.addr", ":9101")
+	v.URL("influxdb.url", "http://localhost:8086")
+	v.OneOf("server.mode", "release", "debug", "release", "test")
+	v.NotEmpty("influxdb.org", "stocksub")
+	v.Positive("cache.max_size", 1)
+	v.NonNegative("websocket.max_connections", 0)
+	v.PositiveDuration("cache.default_ttl", time.Second)
+	v.NonNegativeDuration("timeouts.default", 0)
+
+	assert.NoError(t, v.Err())
+}
+
+func TestValidator_EachCheckReportsField(t *testing.T) {
+	for name, check := range map[string]func(v *Validator){
+		"port not a number":  func(v *Validator) { v.Port("server.port", "http") },
+		"port out of range":  func(v *Validator) { v.Port("server.port", "70000") },
+		"port zero":          func(v *Validator) { v.Port("server.port", "0") },
+		"addr missing port":  func(v *Validator) { v.Addr("redis.addr", "localhost") },
+		"addr bad port":      func(v *Validator) { v.Addr("redis.addr", "localhost:abc") },
+		"url without scheme": func(v *Validator) { v.URL("influxdb.url", "localhost:8086") },
+		"url wrong scheme":   func(v *Validator) { v.URL("influxdb.url", "ftp://localhost") },
+		"url unparsable":     func(v *Validator) { v.URL("influxdb.url", "http://[::1") },
+		"value not allowed":  func(v *Validator) { v.OneOf("server.mode", "prod", "debug", "release") },
+		"empty string":       func(v *Validator) { v.NotEmpty("influxdb.org", "  ") },
+		"zero not positive":  func(v *Validator) { v.Positive("cache.max_size", 0) },
+		"negative number":    func(v *Validator) { v.NonNegative("websocket.max_connections", -1) },
+		"zero duration":      func(v *Validator) { v.PositiveDuration("cache.default_ttl", 0) },
+		"negative duration":  func(v *Validator) { v.NonNegativeDuration("timeouts.default", -time.Second) },
+		"custom problem":     func(v *Validator) { v.Addf("staleness.degraded_percent: out of range") },
+	} {
+		var v Validator
+		check(&v)
+		err := v.Err()
+		require.Error(t, err, name)
+		assert.Contains(t, err.Error(), "(1 problems)", name)
+	}
+}
+
+func TestValidator_ErrListsAllProblems(t *testing.T) {
+	var v Validator
+	v.Port("server.port", "0")
+	v.NotEmpty("influxdb.bucket", "")
+	v.PositiveDuration("cache.stock_ttl", -time.Second)
+
+	err := v.Err()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid config (3 problems)")
+	assert.Contains(t, err.Error(), "server.port")
+	assert.Contains(t, err.Error(), "influxdb.bucket")
+	assert.Contains(t, err.Error(), "cache.stock_ttl")
+}
+
+func TestRedactSettings(t *testing.T) {
+	settings := map[string]interface{}{
+		"redis": map[string]interface{}{"addr": "localhost:6379", "password": "s3cret"},
+		"influxdb": map[string]interface{}{
+			"url":   "http://localhost:8086",
+			"token": "",
+		},
+		"api_keys": []interface{}{
+			map[string]interface{}{"key": "abc123", "name": "dashboard", "rate_limit": 60},
+		},
+		"auth": map[string]interface{}{"enabled": true},
+	}
+
+	redacted := RedactSettings(settings)
+
+	redis := redacted["redis"].(map[string]interface{})
+	assert.Equal(t, "******", redis["password"])
+	assert.Equal(t, "localhost:6379", redis["addr"])
+	assert.Equal(t, "", redacted["influxdb"].(map[string]interface{})["token"], "空值保留以便看出未配置")
+	key := redacted["api_keys"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "******", key["key"])
+	assert.Equal(t, "dashboard", key["name"])
+	assert.Equal(t, 60, key["rate_limit"])
+	assert.Equal(t, true, redacted["auth"].(map[string]interface{})["enabled"])
+
+	// 不修改原配置
+	assert.Equal(t, "s3cret", settings["redis"].(map[string]interface{})["password"])
+}
+
+func TestWriteRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteRedacted(&buf, map[string]interface{}{
+		"influxdb": map[string]interface{}{"token": "my-token", "org": "stocksub"},
+	}))
+
+	out := buf.String()
+	assert.Contains(t, out, "influxdb:\n  org: stocksub\n  token: '******'\n")
+	assert.NotContains(t, out, "my-token")
+}
@@ -0,0 +1,435 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// Client 消费循环用到的 Redis Streams 命令，*redis.Client 满足该接口
+type Client interface {
+	XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd
+	XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd
+	XPendingExt(ctx context.Context, a *redis.XPendingExtArgs) *redis.XPendingExtCmd
+	XClaim(ctx context.Context, a *redis.XClaimArgs) *redis.XMessageSliceCmd
+	XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+	XRange(ctx context.Context, stream, start, stop string) *redis.XMessageSliceCmd
+}
+
+// Handler 处理单条消息，返回错误时消息保留在 PEL 中等待重试
+type Handler func(ctx context.Context, stream string, msg redis.XMessage) error
+
+// ErrAckDeferred 处理器返回该错误表示消息已接收但尚未持久化：消息留在 PEL 中且不参与重试，
+// 持久化后由 Ack 确认，无法持久化时由 Release 交还给重试流程
+var ErrAckDeferred = errors.New("consumer: ack deferred")
+
+// Config 消费者配置
+type Config struct {
+	Group            string        `mapstructure:"group"`
+	Name             string        `mapstructure:"name"`
+	Streams          []string      `mapstructure:"streams"`
+	MaxRetries       int           `mapstructure:"max_retries"`        // 最大投递次数，达到后移入死信流
+	RetryBackoff     time.Duration `mapstructure:"retry_backoff"`      // 首次重试的等待时间，之后按次数翻倍
+	ClaimIdle        time.Duration `mapstructure:"claim_idle"`         // 启动时认领其他消费者超过该空闲时间的消息
+	DeadLetterPrefix string        `mapstructure:"dead_letter_prefix"` // 死信流前缀，完整名称为 <prefix><原始流>
+	DrainTimeout     time.Duration `mapstructure:"drain_timeout"`      // 停止时等待当前批次处理完成的最长时间
+}
+
+const (
+	defaultMaxRetries       = 3
+	defaultRetryBackoff     = time.Second
+	defaultClaimIdle        = 5 * time.Minute
+	defaultDeadLetterPrefix = "stream:deadletter:"
+	defaultDrainTimeout     = 10 * time.Second
+
+	readCount    = 10
+	readBlock    = time.Second
+	pendingBatch = 100
+	pausePoll    = time.Second
+)
+
+// StreamConsumer 基于消费者组的 Redis Streams 消费循环
+// 处理失败的消息按指数退避重新认领，投递次数达到 MaxRetries 后写入死信流并确认原消息，
+// 保证 PEL 不会无限增长。
+type StreamConsumer struct {
+	client  Client
+	config  Config
+	handler Handler
+	logger  *logrus.Logger
+	now     func() time.Time
+	paused  func() bool
+
+	lastRetry time.Time
+	lastRead  atomic.Int64 // 消费循环最近一次完成读取或暂停检查的时间（UnixNano）
+
+	deferredMu sync.Mutex
+	deferred   map[string]bool // 正在处理或延迟确认的消息，键为 <流>/<ID>，retryPending 跳过这些消息
+}
+
+// New 创建消费者，未设置的重试参数使用默认值
+func New(client Client, config Config, handler Handler, logger *logrus.Logger) *StreamConsumer {
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.ClaimIdle <= 0 {
+		config.ClaimIdle = defaultClaimIdle
+	}
+	if config.DeadLetterPrefix == "" {
+		config.DeadLetterPrefix = defaultDeadLetterPrefix
+	}
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = defaultDrainTimeout
+	}
+	return &StreamConsumer{
+		client:   client,
+		config:   config,
+		handler:  handler,
+		logger:   logger,
+		now:      time.Now,
+		deferred: make(map[string]bool),
+	}
+}
+
+// Config 返回补全默认值后的配置
+func (s *StreamConsumer) Config() Config {
+	return s.config
+}
+
+// DeadLetterStream 返回原始流对应的死信流名称
+func (s *StreamConsumer) DeadLetterStream(stream string) string {
+	return s.config.DeadLetterPrefix + stream
+}
+
+// LastReadAt 返回消费循环最近一次完成读取的时间，背压暂停期间按暂停检查的时间计算。
+// 循环卡住时该时间不再更新，可用于存活检查；尚未开始读取时返回零值。
+func (s *StreamConsumer) LastReadAt() time.Time {
+	nanos := s.lastRead.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// SetPauseCheck 设置背压检查，返回 true 时暂停读取和重试，消息保留在流中
+func (s *StreamConsumer) SetPauseCheck(paused func() bool) {
+	s.paused = paused
+}
+
+// CreateGroups 为所有流创建消费者组，组已存在时忽略
+func (s *StreamConsumer) CreateGroups(ctx context.Context) error {
+	for _, stream := range s.config.Streams {
+		err := s.client.XGroupCreateMkStream(ctx, stream, s.config.Group, "0").Err()
+		if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group for stream %s: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+// Run 先认领失效消费者遗留的消息，然后持续消费直到 ctx 取消
+// ctx 取消后不再发起新的读取，已读到的批次继续处理和确认，最多等待 DrainTimeout；
+// 超时未处理的消息留在 PEL 中，由之后的认领流程接管。
+func (s *StreamConsumer) Run(ctx context.Context) {
+	work, cancelWork := context.WithCancel(context.Background())
+	defer cancelWork()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-work.Done():
+			return
+		}
+		timer := time.NewTimer(s.config.DrainTimeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			s.logger.WithField("drain_timeout", s.config.DrainTimeout).Warn("Drain timeout exceeded, leaving in-flight messages pending")
+			cancelWork()
+		case <-work.Done():
+		}
+	}()
+
+	s.claimStale(work)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if s.paused != nil && s.paused() {
+			s.lastRead.Store(s.now().UnixNano())
+			select {
+			case <-ctx.Done():
+			case <-time.After(pausePoll):
+			}
+			continue
+		}
+
+		if err := s.readNew(ctx, work); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.WithError(err).Error("Failed to read messages from Redis streams")
+			time.Sleep(time.Second)
+			continue
+		}
+		s.lastRead.Store(s.now().UnixNano())
+
+		if ctx.Err() == nil && s.now().Sub(s.lastRetry) >= s.config.RetryBackoff {
+			s.retryPending(work)
+			s.lastRetry = s.now()
+		}
+	}
+}
+
+// readNew 读取并处理尚未投递过的新消息，ctx 控制读取，work 控制处理和确认
+func (s *StreamConsumer) readNew(ctx, work context.Context) error {
+	// XREADGROUP 要求先列出全部流名，再列出对应的起始 ID
+	streams := make([]string, 0, len(s.config.Streams)*2)
+	streams = append(streams, s.config.Streams...)
+	for range s.config.Streams {
+		streams = append(streams, ">")
+	}
+
+	result, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.config.Group,
+		Consumer: s.config.Name,
+		Streams:  streams,
+		Count:    readCount,
+		Block:    readBlock,
+	}).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+
+	for _, stream := range result {
+		for _, msg := range stream.Messages {
+			if work.Err() != nil {
+				return nil
+			}
+			s.handle(work, stream.Stream, msg, 1)
+		}
+	}
+	return nil
+}
+
+// retryPending 重新认领本消费者已超过退避时间的待确认消息
+func (s *StreamConsumer) retryPending(ctx context.Context) {
+	for _, stream := range s.config.Streams {
+		pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   stream,
+			Group:    s.config.Group,
+			Start:    "-",
+			End:      "+",
+			Count:    pendingBatch,
+			Consumer: s.config.Name,
+		}).Result()
+		if err != nil {
+			s.logger.WithError(err).WithField("stream", stream).Error("Failed to list pending messages")
+			continue
+		}
+
+		for _, entry := range pending {
+			if s.isDeferred(stream, entry.ID) {
+				continue
+			}
+			wait := s.backoff(entry.RetryCount)
+			if entry.Idle < wait {
+				continue
+			}
+			s.claim(ctx, stream, []redis.XPendingExt{entry}, wait)
+		}
+	}
+}
+
+// claimStale 认领所有消费者中空闲超过 ClaimIdle 的消息，用于接管已下线消费者的 PEL
+func (s *StreamConsumer) claimStale(ctx context.Context) {
+	for _, stream := range s.config.Streams {
+		pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  s.config.Group,
+			Idle:   s.config.ClaimIdle,
+			Start:  "-",
+			End:    "+",
+			Count:  pendingBatch,
+		}).Result()
+		if err != nil {
+			s.logger.WithError(err).WithField("stream", stream).Error("Failed to list stale pending messages")
+			continue
+		}
+		if len(pending) == 0 {
+			continue
+		}
+
+		s.logger.WithFields(logrus.Fields{
+			"stream": stream,
+			"count":  len(pending),
+		}).Info("Claiming stale pending messages")
+		s.claim(ctx, stream, pending, s.config.ClaimIdle)
+	}
+}
+
+// claim 将消息认领到当前消费者并处理，原消息已被删除（如流被裁剪）的条目直接确认
+func (s *StreamConsumer) claim(ctx context.Context, stream string, entries []redis.XPendingExt, minIdle time.Duration) {
+	ids := make([]string, 0, len(entries))
+	attempts := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ID)
+		// XCLAIM 会使投递次数加一
+		attempts[entry.ID] = entry.RetryCount + 1
+	}
+
+	messages, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    s.config.Group,
+		Consumer: s.config.Name,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		s.logger.WithError(err).WithField("stream", stream).Error("Failed to claim pending messages")
+		return
+	}
+
+	claimed := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		claimed[msg.ID] = true
+		if ctx.Err() != nil {
+			continue
+		}
+		s.handle(ctx, stream, msg, attempts[msg.ID])
+	}
+
+	// 未认领到的条目可能已被其他消费者接管，只有原消息确实不存在时才确认
+	for _, id := range ids {
+		if claimed[id] {
+			continue
+		}
+		exists, err := s.client.XRange(ctx, stream, id, id).Result()
+		if err == nil && len(exists) == 0 {
+			s.ack(ctx, stream, id)
+		}
+	}
+}
+
+// handle 处理消息，成功时确认，失败且达到最大投递次数时移入死信流
+// 处理器返回 ErrAckDeferred 时不确认，等待 Ack 或 Release
+func (s *StreamConsumer) handle(ctx context.Context, stream string, msg redis.XMessage, attempts int64) {
+	// 先登记再调用处理器，处理器返回前就完成的 Ack 或 Release 会清除登记
+	s.setDeferred(stream, msg.ID, true)
+	err := s.handler(ctx, stream, msg)
+	if errors.Is(err, ErrAckDeferred) {
+		return
+	}
+	s.setDeferred(stream, msg.ID, false)
+	if err == nil {
+		s.ack(ctx, stream, msg.ID)
+		return
+	}
+
+	fields := logrus.Fields{
+		"stream":     stream,
+		"message_id": msg.ID,
+		"attempts":   attempts,
+	}
+	if attempts < int64(s.config.MaxRetries) {
+		s.logger.WithError(err).WithFields(fields).Warn("Failed to process message, will retry")
+		return
+	}
+
+	s.logger.WithError(err).WithFields(fields).Error("Failed to process message, moving to dead-letter stream")
+	if dlqErr := s.deadLetter(ctx, stream, msg, attempts, err); dlqErr != nil {
+		// 写入死信流失败时不确认，下次重试时再尝试
+		s.logger.WithError(dlqErr).WithFields(fields).Error("Failed to write dead-letter message")
+		return
+	}
+	s.ack(ctx, stream, msg.ID)
+}
+
+// deadLetter 将原消息字段连同失败原因写入死信流
+func (s *StreamConsumer) deadLetter(ctx context.Context, stream string, msg redis.XMessage, attempts int64, cause error) error {
+	values := make(map[string]interface{}, len(msg.Values)+5)
+	for k, v := range msg.Values {
+		values[k] = v
+	}
+	values["original_stream"] = stream
+	values["original_id"] = msg.ID
+	values["error"] = cause.Error()
+	values["attempts"] = attempts
+	values["failed_at"] = s.now().Unix()
+
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.DeadLetterStream(stream),
+		Values: values,
+	}).Err()
+}
+
+// Ack 确认延迟确认的消息
+func (s *StreamConsumer) Ack(ctx context.Context, stream string, ids ...string) {
+	for _, id := range ids {
+		s.setDeferred(stream, id, false)
+	}
+	s.ack(ctx, stream, ids...)
+}
+
+// Release 放弃延迟确认，消息留在 PEL 中按退避时间重新投递
+func (s *StreamConsumer) Release(stream string, ids ...string) {
+	for _, id := range ids {
+		s.setDeferred(stream, id, false)
+	}
+}
+
+func (s *StreamConsumer) setDeferred(stream, id string, deferred bool) {
+	s.deferredMu.Lock()
+	defer s.deferredMu.Unlock()
+	if deferred {
+		s.deferred[stream+"/"+id] = true
+	} else {
+		delete(s.deferred, stream+"/"+id)
+	}
+}
+
+func (s *StreamConsumer) isDeferred(stream, id string) bool {
+	s.deferredMu.Lock()
+	defer s.deferredMu.Unlock()
+	return s.deferred[stream+"/"+id]
+}
+
+func (s *StreamConsumer) ack(ctx context.Context, stream string, ids ...string) {
+	if err := s.client.XAck(ctx, stream, s.config.Group, ids...).Err(); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{
+			"stream":      stream,
+			"message_ids": ids,
+		}).Error("Failed to acknowledge message")
+	}
+}
+
+// backoff 返回第 deliveries 次投递失败后再次认领前需要等待的时间
+func (s *StreamConsumer) backoff(deliveries int64) time.Duration {
+	if deliveries < 1 {
+		deliveries = 1
+	}
+	wait := s.config.RetryBackoff
+	for i := int64(1); i < deliveries && wait < s.config.ClaimIdle; i++ {
+		wait *= 2
+	}
+	if wait > s.config.ClaimIdle {
+		wait = s.config.ClaimIdle
+	}
+	return wait
+}
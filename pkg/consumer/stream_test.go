@@ -0,0 +1,445 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePending struct {
+	consumer   string
+	deliveries int64
+	delivered  time.Time
+}
+
+// fakeStreams 单消费者组的内存版 Redis Streams，实现 PEL、投递计数和空闲时间
+type fakeStreams struct {
+	now      func() time.Time
+	messages map[string][]redis.XMessage
+	cursor   map[string]int
+	pending  map[string]map[string]*fakePending
+	seq      int
+}
+
+func newFakeStreams(now func() time.Time) *fakeStreams {
+	return &fakeStreams{
+		now:      now,
+		messages: make(map[string][]redis.XMessage),
+		cursor:   make(map[string]int),
+		pending:  make(map[string]map[string]*fakePending),
+	}
+}
+
+func (f *fakeStreams) add(stream string, values map[string]interface{}) string {
+	f.seq++
+	id := fmt.Sprintf("%d-0", f.seq)
+	f.messages[stream] = append(f.messages[stream], redis.XMessage{ID: id, Values: values})
+	return id
+}
+
+func (f *fakeStreams) find(stream, id string) (redis.XMessage, bool) {
+	for _, msg := range f.messages[stream] {
+		if msg.ID == id {
+			return msg, true
+		}
+	}
+	return redis.XMessage{}, false
+}
+
+func (f *fakeStreams) XGroupCreateMkStream(ctx context.Context, stream, group, start string) *redis.StatusCmd {
+	if f.pending[stream] == nil {
+		f.pending[stream] = make(map[string]*fakePending)
+	}
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeStreams) XReadGroup(ctx context.Context, a *redis.XReadGroupArgs) *redis.XStreamSliceCmd {
+	var result []redis.XStream
+	for _, stream := range a.Streams[:len(a.Streams)/2] {
+		var batch []redis.XMessage
+		for f.cursor[stream] < len(f.messages[stream]) && int64(len(batch)) < a.Count {
+			msg := f.messages[stream][f.cursor[stream]]
+			f.cursor[stream]++
+			f.pending[stream][msg.ID] = &fakePending{consumer: a.Consumer, deliveries: 1, delivered: f.now()}
+			batch = append(batch, msg)
+		}
+		if len(batch) > 0 {
+			result = append(result, redis.XStream{Stream: stream, Messages: batch})
+		}
+	}
+	if len(result) == 0 {
+		return redis.NewXStreamSliceCmdResult(nil, redis.Nil)
+	}
+	return redis.NewXStreamSliceCmdResult(result, nil)
+}
+
+func (f *fakeStreams) XPendingExt(ctx context.Context, a *redis.XPendingExtArgs) *redis.XPendingExtCmd {
+	var result []redis.XPendingExt
+	for id, entry := range f.pending[a.Stream] {
+		idle := f.now().Sub(entry.delivered)
+		if a.Consumer != "" && entry.consumer != a.Consumer || idle < a.Idle {
+			continue
+		}
+		result = append(result, redis.XPendingExt{ID: id, Consumer: entry.consumer, Idle: idle, RetryCount: entry.deliveries})
+	}
+	cmd := redis.NewXPendingExtCmd(ctx)
+	cmd.SetVal(result)
+	return cmd
+}
+
+func (f *fakeStreams) XClaim(ctx context.Context, a *redis.XClaimArgs) *redis.XMessageSliceCmd {
+	var result []redis.XMessage
+	for _, id := range a.Messages {
+		entry, ok := f.pending[a.Stream][id]
+		if !ok || f.now().Sub(entry.delivered) < a.MinIdle {
+			continue
+		}
+		msg, ok := f.find(a.Stream, id)
+		if !ok {
+			continue
+		}
+		entry.consumer = a.Consumer
+		entry.deliveries++
+		entry.delivered = f.now()
+		result = append(result, msg)
+	}
+	return redis.NewXMessageSliceCmdResult(result, nil)
+}
+
+func (f *fakeStreams) XAck(ctx context.Context, stream, group string, ids ...string) *redis.IntCmd {
+	for _, id := range ids {
+		delete(f.pending[stream], id)
+	}
+	return redis.NewIntResult(int64(len(ids)), nil)
+}
+
+func (f *fakeStreams) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	values := a.Values.(map[string]interface{})
+	return redis.NewStringResult(f.add(a.Stream, values), nil)
+}
+
+func (f *fakeStreams) XRange(ctx context.Context, stream, start, stop string) *redis.XMessageSliceCmd {
+	var result []redis.XMessage
+	if msg, ok := f.find(stream, start); ok && start == stop {
+		result = append(result, msg)
+	}
+	return redis.NewXMessageSliceCmdResult(result, nil)
+}
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestConsumer(t *testing.T, handler Handler) (*StreamConsumer, *fakeStreams, *testClock) {
+	t.Helper()
+	clock := &testClock{t: time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)}
+	streams := newFakeStreams(clock.now)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := New(streams, Config{
+		Group:   "collectors",
+		Name:    "collector_1",
+		Streams: []string{"stream:stock:realtime"},
+	}, handler, logger)
+	c.now = clock.now
+	require.NoError(t, c.CreateGroups(context.Background()))
+	return c, streams, clock
+}
+
+func TestStreamConsumer_PoisonMessageMovesToDeadLetter(t *testing.T) {
+	attempts := 0
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		attempts++
+		return errors.New("failed to unmarshal message")
+	})
+	ctx := context.Background()
+	id := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{not json"})
+
+	require.NoError(t, c.readNew(ctx, ctx))
+	assert.Equal(t, 1, attempts)
+	assert.Len(t, streams.pending["stream:stock:realtime"], 1, "failed message stays pending")
+
+	// 退避时间未到不重试
+	c.retryPending(ctx)
+	assert.Equal(t, 1, attempts)
+
+	clock.advance(time.Second)
+	c.retryPending(ctx)
+	assert.Equal(t, 2, attempts)
+
+	// 第二次失败后退避时间翻倍
+	clock.advance(time.Second)
+	c.retryPending(ctx)
+	assert.Equal(t, 2, attempts)
+	clock.advance(time.Second)
+	c.retryPending(ctx)
+	assert.Equal(t, 3, attempts)
+
+	assert.Empty(t, streams.pending["stream:stock:realtime"], "PEL must be empty after dead-lettering")
+	dead := streams.messages["stream:deadletter:stream:stock:realtime"]
+	require.Len(t, dead, 1)
+	assert.Equal(t, "{not json", dead[0].Values["data"])
+	assert.Equal(t, id, dead[0].Values["original_id"])
+	assert.Equal(t, "stream:stock:realtime", dead[0].Values["original_stream"])
+	assert.Equal(t, "failed to unmarshal message", dead[0].Values["error"])
+	assert.Equal(t, int64(3), dead[0].Values["attempts"])
+
+	clock.advance(time.Hour)
+	c.retryPending(ctx)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestStreamConsumer_TransientFailureRecovers(t *testing.T) {
+	attempts := 0
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("redis pipeline failed")
+		}
+		return nil
+	})
+	ctx := context.Background()
+	streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+
+	require.NoError(t, c.readNew(ctx, ctx))
+	clock.advance(time.Second)
+	c.retryPending(ctx)
+
+	assert.Equal(t, 2, attempts)
+	assert.Empty(t, streams.pending["stream:stock:realtime"])
+	assert.Empty(t, streams.messages["stream:deadletter:stream:stock:realtime"])
+}
+
+func TestStreamConsumer_DeferredAckWaitsForAckOrRelease(t *testing.T) {
+	attempts := 0
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		attempts++
+		return ErrAckDeferred
+	})
+	ctx := context.Background()
+	first := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	second := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+
+	require.NoError(t, c.readNew(ctx, ctx))
+	assert.Len(t, streams.pending["stream:stock:realtime"], 2, "延迟确认的消息留在 PEL 中")
+
+	// 等待确认期间不重试
+	clock.advance(time.Minute)
+	c.retryPending(ctx)
+	assert.Equal(t, 2, attempts)
+
+	c.Ack(ctx, "stream:stock:realtime", first)
+	assert.NotContains(t, streams.pending["stream:stock:realtime"], first)
+
+	// 交还的消息按退避时间重新投递
+	c.Release("stream:stock:realtime", second)
+	c.retryPending(ctx)
+	assert.Equal(t, 3, attempts)
+	assert.Contains(t, streams.pending["stream:stock:realtime"], second)
+	assert.Empty(t, streams.messages["stream:deadletter:stream:stock:realtime"])
+}
+
+func TestStreamConsumer_ClaimStaleFromDeadConsumer(t *testing.T) {
+	var handled []string
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		handled = append(handled, msg.ID)
+		return nil
+	})
+	ctx := context.Background()
+
+	stale := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	streams.pending["stream:stock:realtime"][stale] = &fakePending{consumer: "collector_dead", deliveries: 1, delivered: clock.now()}
+	streams.cursor["stream:stock:realtime"] = 1
+
+	clock.advance(time.Minute)
+	recent := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	streams.pending["stream:stock:realtime"][recent] = &fakePending{consumer: "collector_2", deliveries: 1, delivered: clock.now()}
+	streams.cursor["stream:stock:realtime"] = 2
+
+	clock.advance(4*time.Minute + 30*time.Second)
+	c.claimStale(ctx)
+
+	assert.Equal(t, []string{stale}, handled, "only messages idle longer than claim_idle are claimed")
+	assert.NotContains(t, streams.pending["stream:stock:realtime"], stale)
+	assert.Contains(t, streams.pending["stream:stock:realtime"], recent)
+}
+
+func TestStreamConsumer_StaleMessageAtRetryLimitIsDeadLettered(t *testing.T) {
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		return errors.New("checksum mismatch")
+	})
+	ctx := context.Background()
+
+	id := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	streams.pending["stream:stock:realtime"][id] = &fakePending{consumer: "collector_dead", deliveries: 2, delivered: clock.now()}
+	streams.cursor["stream:stock:realtime"] = 1
+
+	clock.advance(10 * time.Minute)
+	c.claimStale(ctx)
+
+	assert.Empty(t, streams.pending["stream:stock:realtime"])
+	require.Len(t, streams.messages["stream:deadletter:stream:stock:realtime"], 1)
+}
+
+func TestStreamConsumer_AcksTrimmedPendingEntries(t *testing.T) {
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		return nil
+	})
+	streams.pending["stream:stock:realtime"]["99-0"] = &fakePending{consumer: "collector_dead", deliveries: 1, delivered: clock.now()}
+
+	clock.advance(10 * time.Minute)
+	c.claimStale(context.Background())
+
+	assert.Empty(t, streams.pending["stream:stock:realtime"])
+}
+
+func TestStreamConsumer_Backoff(t *testing.T) {
+	c := New(nil, Config{RetryBackoff: time.Second, ClaimIdle: 5 * time.Second}, nil, logrus.New())
+
+	assert.Equal(t, time.Second, c.backoff(0))
+	assert.Equal(t, time.Second, c.backoff(1))
+	assert.Equal(t, 2*time.Second, c.backoff(2))
+	assert.Equal(t, 4*time.Second, c.backoff(3))
+	assert.Equal(t, 5*time.Second, c.backoff(4), "backoff is capped at claim_idle")
+	assert.Equal(t, 5*time.Second, c.backoff(100))
+}
+
+func TestStreamConsumer_DoesNotAckEntriesClaimedElsewhere(t *testing.T) {
+	c, streams, clock := newTestConsumer(t, func(ctx context.Context, stream string, msg redis.XMessage) error {
+		return nil
+	})
+	id := streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	streams.pending["stream:stock:realtime"][id] = &fakePending{consumer: "collector_2", deliveries: 1, delivered: clock.now()}
+	streams.cursor["stream:stock:realtime"] = 1
+
+	// 列出 PEL 之后、认领之前被其他消费者重新认领
+	entries := []redis.XPendingExt{{ID: id, Consumer: "collector_dead", Idle: 10 * time.Minute, RetryCount: 1}}
+	c.claim(context.Background(), "stream:stock:realtime", entries, 5*time.Minute)
+
+	assert.Contains(t, streams.pending["stream:stock:realtime"], id)
+}
+
+func TestStreamConsumer_ShutdownDrainsCurrentBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []string
+	c, streams, _ := newTestConsumer(t, func(work context.Context, stream string, msg redis.XMessage) error {
+		// 第一条消息处理期间收到停止信号
+		cancel()
+		time.Sleep(5 * time.Millisecond)
+		if work.Err() != nil {
+			return work.Err()
+		}
+		handled = append(handled, msg.ID)
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after shutdown")
+	}
+
+	assert.Len(t, handled, 5, "every message in the read batch is processed")
+	assert.Empty(t, streams.pending["stream:stock:realtime"], "no message in the read batch is left unacked")
+}
+
+func TestStreamConsumer_ShutdownStopsAtDrainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, streams, _ := newTestConsumer(t, func(work context.Context, stream string, msg redis.XMessage) error {
+		cancel()
+		<-work.Done()
+		return work.Err()
+	})
+	c.config.DrainTimeout = 20 * time.Millisecond
+	for i := 0; i < 3; i++ {
+		streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after drain timeout")
+	}
+
+	assert.Len(t, streams.pending["stream:stock:realtime"], 3, "unfinished messages stay pending for later claiming")
+}
+
+func TestStreamConsumer_LastReadAt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, streams, clock := newTestConsumer(t, func(work context.Context, stream string, msg redis.XMessage) error {
+		cancel()
+		return nil
+	})
+	assert.True(t, c.LastReadAt().IsZero(), "zero before the loop reads")
+
+	streams.add("stream:stock:realtime", map[string]interface{}{"data": "{}"})
+	c.Run(ctx)
+	assert.Equal(t, clock.now(), c.LastReadAt().UTC())
+}
+
+func TestStreamConsumer_ShutdownMidBatchLeavesNoPendingInRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	const stream = "stream:stock:realtime"
+	for i := 0; i < 5; i++ {
+		require.NoError(t, client.XAdd(context.Background(), &redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"data": "{}"}}).Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []string
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := New(client, Config{Group: "collectors", Name: "collector_1", Streams: []string{stream}},
+		func(work context.Context, stream string, msg redis.XMessage) error {
+			// 批次中第一条消息处理期间收到停止信号
+			cancel()
+			if work.Err() != nil {
+				return work.Err()
+			}
+			handled = append(handled, msg.ID)
+			return nil
+		}, logger)
+	require.NoError(t, c.CreateGroups(context.Background()))
+
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after shutdown")
+	}
+
+	assert.Len(t, handled, 5, "every message in the read batch is processed")
+	pending, err := client.XPending(context.Background(), stream, "collectors").Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count, "XPENDING is empty after shutdown")
+}
@@ -0,0 +1,216 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// TrimClient 流维护用到的 Redis 命令，*redis.Client 满足该接口
+type TrimClient interface {
+	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+	XPending(ctx context.Context, stream, group string) *redis.XPendingCmd
+	XTrimMinIDApprox(ctx context.Context, key string, minID string, limit int64) *redis.IntCmd
+}
+
+// GroupProgress 消费者组在流上的进度
+type GroupProgress struct {
+	Name            string
+	LastDeliveredID string
+	Pending         int64
+	OldestPendingID string // 没有待确认消息时为空
+}
+
+// floor 该组仍可能需要的最早 ID：有待确认消息时为最早的待确认 ID，否则为最后投递的 ID
+func (g GroupProgress) floor() string {
+	if g.Pending > 0 && g.OldestPendingID != "" {
+		return g.OldestPendingID
+	}
+	return g.LastDeliveredID
+}
+
+// TrimResult 一次裁剪的结果
+type TrimResult struct {
+	Stream      string
+	RetentionID string // 按保留时长计算的 MINID
+	MinID       string // 实际使用的 MINID，受消费者组进度限制时小于 RetentionID
+	LagLimited  bool   // MINID 被消费者组进度限制
+	Trimmed     int64
+}
+
+// GroupsProgress 返回流上所有消费者组的进度，流不存在时返回空列表
+//
+// go-redis v8 的 XInfoGroups 只能解析 Redis 7 之前的 4 个字段（Redis 7 增加了 entries-read 和 lag），
+// 这里用 Do 发送原始命令并按键名解析。
+func GroupsProgress(ctx context.Context, client TrimClient, stream string) ([]GroupProgress, error) {
+	reply, err := client.Do(ctx, "XINFO", "GROUPS", stream).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read consumer groups of %s: %w", stream, err)
+	}
+	items, _ := reply.([]interface{})
+
+	groups := make([]GroupProgress, 0, len(items))
+	for _, item := range items {
+		fields, _ := item.([]interface{})
+		var group GroupProgress
+		for i := 0; i+1 < len(fields); i += 2 {
+			key, _ := fields[i].(string)
+			switch key {
+			case "name":
+				group.Name, _ = fields[i+1].(string)
+			case "last-delivered-id":
+				group.LastDeliveredID, _ = fields[i+1].(string)
+			case "pending":
+				group.Pending, _ = fields[i+1].(int64)
+			}
+		}
+		if group.Pending > 0 {
+			pending, err := client.XPending(ctx, stream, group.Name).Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read pending entries of %s/%s: %w", stream, group.Name, err)
+			}
+			group.OldestPendingID = pending.Lower
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// TrimMinID 计算裁剪使用的 MINID：早于 now-retention 的条目可以删除，
+// 但不超过各消费者组仍需要的最早 ID（最早的待确认消息或最后投递的 ID）；force 时忽略消费者组进度。
+// 返回的 bool 表示结果是否被消费者组进度限制。
+func TrimMinID(retention time.Duration, now time.Time, groups []GroupProgress, force bool) (string, bool) {
+	minID := fmt.Sprintf("%d-0", now.Add(-retention).UnixMilli())
+	if force {
+		return minID, false
+	}
+	limited := false
+	for _, group := range groups {
+		if floor := group.floor(); compareStreamIDs(floor, minID) < 0 {
+			minID, limited = floor, true
+		}
+	}
+	return minID, limited
+}
+
+// TrimStream 按保留时长裁剪流，默认不删除任何消费者组尚未确认或尚未投递的条目
+func TrimStream(ctx context.Context, client TrimClient, stream string, retention time.Duration, now time.Time, force bool) (TrimResult, error) {
+	result := TrimResult{Stream: stream, RetentionID: fmt.Sprintf("%d-0", now.Add(-retention).UnixMilli())}
+	groups, err := GroupsProgress(ctx, client, stream)
+	if err != nil {
+		return result, err
+	}
+	result.MinID, result.LagLimited = TrimMinID(retention, now, groups, force)
+
+	// MINID ~ 只删除整块过期的宏节点，可能比精确裁剪少删一些条目，但不会越过 MinID
+	trimmed, err := client.XTrimMinIDApprox(ctx, stream, result.MinID, 0).Result()
+	if err != nil {
+		return result, fmt.Errorf("failed to trim stream %s: %w", stream, err)
+	}
+	result.Trimmed = trimmed
+	return result, nil
+}
+
+// compareStreamIDs 比较两个 "毫秒-序号" 形式的流 ID，格式无效的部分按 0 处理
+func compareStreamIDs(a, b string) int {
+	am, as := splitStreamID(a)
+	bm, bs := splitStreamID(b)
+	switch {
+	case am != bm:
+		if am < bm {
+			return -1
+		}
+		return 1
+	case as != bs:
+		if as < bs {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitStreamID(id string) (uint64, uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msPart, 10, 64)
+	seq, _ := strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
+
+// JanitorConfig 流清理配置
+type JanitorConfig struct {
+	Streams   []string      `mapstructure:"streams"`
+	Retention time.Duration `mapstructure:"retention"` // 条目保留时长
+	Interval  time.Duration `mapstructure:"interval"`  // 清理间隔
+	Force     bool          `mapstructure:"force"`     // 忽略消费者组进度，可能删除尚未确认的消息
+}
+
+const (
+	defaultJanitorRetention = 24 * time.Hour
+	defaultJanitorInterval  = 10 * time.Minute
+)
+
+// Janitor 定期按保留时长裁剪流
+type Janitor struct {
+	client TrimClient
+	config JanitorConfig
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewJanitor 创建流清理器，未设置的保留时长和间隔使用默认值
+func NewJanitor(client TrimClient, config JanitorConfig, logger *logrus.Logger) *Janitor {
+	if config.Retention <= 0 {
+		config.Retention = defaultJanitorRetention
+	}
+	if config.Interval <= 0 {
+		config.Interval = defaultJanitorInterval
+	}
+	return &Janitor{client: client, config: config, logger: logger, now: time.Now}
+}
+
+// TrimOnce 裁剪所有流一次，单个流失败时记录日志并继续，返回成功裁剪的结果
+func (j *Janitor) TrimOnce(ctx context.Context) []TrimResult {
+	results := make([]TrimResult, 0, len(j.config.Streams))
+	for _, stream := range j.config.Streams {
+		result, err := TrimStream(ctx, j.client, stream, j.config.Retention, j.now(), j.config.Force)
+		if err != nil {
+			j.logger.WithError(err).WithField("stream", stream).Error("Failed to trim stream")
+			continue
+		}
+		entry := j.logger.WithFields(logrus.Fields{
+			"stream":  stream,
+			"min_id":  result.MinID,
+			"trimmed": result.Trimmed,
+		})
+		if result.LagLimited {
+			entry.WithField("retention_id", result.RetentionID).Warn("Stream trim limited by consumer group lag")
+		} else {
+			entry.Info("Stream trimmed")
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Run 立即清理一次，之后按 Interval 定期清理直到 ctx 取消
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+	for {
+		j.TrimOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
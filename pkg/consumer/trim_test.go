@@ -0,0 +1,124 @@
+package consumer
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const trimStream = "stream:stock:realtime"
+
+// trimNow 保留 6 秒时，早于 4000-0 的条目过期
+var trimNow = time.UnixMilli(10000)
+
+// newTrimClient 启动 miniredis 并写入 ID 为 1000-0 到 5000-0 的条目
+func newTrimClient(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	for _, id := range []string{"1000-0", "2000-0", "3000-0", "4000-0", "5000-0"} {
+		require.NoError(t, client.XAdd(context.Background(), &redis.XAddArgs{Stream: trimStream, ID: id, Values: map[string]interface{}{"data": id}}).Err())
+	}
+	return client
+}
+
+// readGroup 创建消费者组读取 count 条消息，并确认其中前 ack 条
+func readGroup(t *testing.T, client *redis.Client, group string, count, ack int) {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, client.XGroupCreate(ctx, trimStream, group, "0").Err())
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: group, Consumer: "c1", Streams: []string{trimStream, ">"}, Count: int64(count),
+	}).Result()
+	require.NoError(t, err)
+	for _, msg := range streams[0].Messages[:ack] {
+		require.NoError(t, client.XAck(ctx, trimStream, group, msg.ID).Err())
+	}
+}
+
+func TestTrimMinID_LagAware(t *testing.T) {
+	groups := []GroupProgress{
+		{Name: "fast", LastDeliveredID: "5000-0"},
+		{Name: "slow", LastDeliveredID: "3000-0", Pending: 2, OldestPendingID: "2000-1"},
+	}
+
+	minID, limited := TrimMinID(6*time.Second, trimNow, groups, false)
+	assert.Equal(t, "2000-1", minID, "不越过最早的待确认消息")
+	assert.True(t, limited)
+
+	minID, limited = TrimMinID(6*time.Second, trimNow, groups[:1], false)
+	assert.Equal(t, "4000-0", minID, "消费者组已越过保留时长")
+	assert.False(t, limited)
+
+	minID, limited = TrimMinID(6*time.Second, trimNow, groups, true)
+	assert.Equal(t, "4000-0", minID, "force 忽略消费者组进度")
+	assert.False(t, limited)
+
+	minID, _ = TrimMinID(time.Second, trimNow, []GroupProgress{{Name: "new", LastDeliveredID: "0-0"}}, false)
+	assert.Equal(t, "0-0", minID, "尚未读取的组阻止任何裁剪")
+
+	assert.Equal(t, -1, compareStreamIDs("999-9", "1000-0"))
+	assert.Equal(t, 1, compareStreamIDs("1000-2", "1000-1"))
+	assert.Equal(t, 0, compareStreamIDs("1000-0", "1000-0"))
+}
+
+func TestTrimStream_RespectsConsumerGroups(t *testing.T) {
+	ctx := context.Background()
+
+	client := newTrimClient(t)
+	result, err := TrimStream(ctx, client, trimStream, 6*time.Second, trimNow, false)
+	require.NoError(t, err)
+	assert.Equal(t, TrimResult{Stream: trimStream, RetentionID: "4000-0", MinID: "4000-0", Trimmed: 3}, result, "没有消费者组时按保留时长裁剪")
+
+	client = newTrimClient(t)
+	readGroup(t, client, "fast", 5, 5)
+	readGroup(t, client, "slow", 2, 2)
+	result, err = TrimStream(ctx, client, trimStream, 6*time.Second, trimNow, false)
+	require.NoError(t, err)
+	assert.Equal(t, "2000-0", result.MinID, "不越过最后投递的 ID")
+	assert.True(t, result.LagLimited)
+	assert.Equal(t, int64(1), result.Trimmed)
+
+	client = newTrimClient(t)
+	readGroup(t, client, "pending", 3, 0)
+	groups, err := GroupsProgress(ctx, client, trimStream)
+	require.NoError(t, err)
+	assert.Equal(t, []GroupProgress{{Name: "pending", LastDeliveredID: "3000-0", Pending: 3, OldestPendingID: "1000-0"}}, groups)
+	result, err = TrimStream(ctx, client, trimStream, 6*time.Second, trimNow, false)
+	require.NoError(t, err)
+	assert.Zero(t, result.Trimmed, "待确认的消息不会被删除")
+
+	result, err = TrimStream(ctx, client, trimStream, 6*time.Second, trimNow, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Trimmed)
+	assert.Equal(t, int64(2), client.XLen(ctx, trimStream).Val())
+
+	result, err = TrimStream(ctx, client, "stream:missing", time.Hour, trimNow, false)
+	require.NoError(t, err, "流不存在时不报错")
+	assert.Zero(t, result.Trimmed)
+}
+
+func TestJanitor_TrimOnce(t *testing.T) {
+	client := newTrimClient(t)
+	readGroup(t, client, "slow", 2, 2)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	janitor := NewJanitor(client, JanitorConfig{Streams: []string{trimStream, "stream:index:realtime"}, Retention: 6 * time.Second}, logger)
+	janitor.now = func() time.Time { return trimNow }
+	assert.Equal(t, defaultJanitorInterval, janitor.config.Interval)
+
+	results := janitor.TrimOnce(context.Background())
+	require.Len(t, results, 2)
+	assert.Equal(t, int64(1), results[0].Trimmed)
+	assert.True(t, results[0].LagLimited)
+	assert.Zero(t, results[1].Trimmed)
+}
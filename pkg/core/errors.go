@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	apperrors "stocksub/pkg/error"
+)
+
+// ErrTimeout 提供商调用超过了规定的时限
+var ErrTimeout = errors.New("provider call timeout")
+
+// HTTPStatusError 上游接口返回了非 200 状态码
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+// Error 实现 error 接口
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP status error: %d", e.StatusCode)
+}
+
+// NewHTTPStatusError 创建状态码错误，429 和 403 附加 UpstreamThrottled 代码
+func NewHTTPStatusError(statusCode int) error {
+	err := &HTTPStatusError{StatusCode: statusCode}
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusForbidden {
+		return apperrors.Wrap(apperrors.CodeUpstreamThrottled, err, "status", statusCode)
+	}
+	return err
+}
+
+// WrapRequestError 为发送请求失败的错误附加代码，超时附加 NetworkTimeout，其余原样返回
+func WrapRequestError(err error) error {
+	if isTimeout(err) {
+		return apperrors.Wrap(apperrors.CodeNetworkTimeout, err)
+	}
+	return err
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTimeout)
+}
+
+// IsRetryable 判断错误是否为可重试的临时错误
+// 超时、5xx/429 状态码、连接重置/拒绝、意外 EOF 视为可重试；
+// 调用方取消 (context.Canceled)、4xx 和解析错误等不可重试。
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	if isTimeout(err) {
+		return true
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.EOF):
+		return true
+	}
+
+	// 兜底：被 fmt.Errorf("%v") 丢失类型信息的网络错误
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "timeout")
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apperrors "stocksub/pkg/error"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"5xx", fmt.Errorf("fetch: %w", &HTTPStatusError{StatusCode: 503}), true},
+		{"429", &HTTPStatusError{StatusCode: 429}, true},
+		{"4xx", &HTTPStatusError{StatusCode: 404}, false},
+		{"请求超时", fmt.Errorf("HTTP request failed: %w", context.DeadlineExceeded), true},
+		{"网络超时", &net.OpError{Op: "read", Err: timeoutError{}}, true},
+		{"连接重置", fmt.Errorf("HTTP request failed: %w", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{"意外 EOF", fmt.Errorf("read response failed: %w", io.ErrUnexpectedEOF), true},
+		{"丢失类型的连接重置", errors.New("read tcp 1.2.3.4:80: connection reset by peer"), true},
+		{"调用方取消", fmt.Errorf("HTTP request failed: %w", context.Canceled), false},
+		{"解析错误", errors.New("empty response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryable(tt.err))
+		})
+	}
+}
+
+func TestNewHTTPStatusError_Codes(t *testing.T) {
+	for status, want := range map[int]apperrors.ErrorCode{429: apperrors.CodeUpstreamThrottled, 403: apperrors.CodeUpstreamThrottled, 502: ""} {
+		err := fmt.Errorf("fetch: %w", NewHTTPStatusError(status))
+		assert.Equal(t, want, apperrors.Code(err), "status %d", status)
+		var statusErr *HTTPStatusError
+		assert.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, status, statusErr.StatusCode)
+	}
+	assert.True(t, IsRetryable(NewHTTPStatusError(429)), "附加代码不影响重试判断")
+}
+
+func TestWrapRequestError(t *testing.T) {
+	timeout := WrapRequestError(fmt.Errorf("HTTP request failed: %w", context.DeadlineExceeded))
+	assert.True(t, apperrors.Is(timeout, apperrors.CodeNetworkTimeout))
+	assert.ErrorIs(t, timeout, context.DeadlineExceeded)
+	assert.True(t, apperrors.Is(WrapRequestError(&net.OpError{Op: "read", Err: timeoutError{}}), apperrors.CodeNetworkTimeout))
+
+	reset := fmt.Errorf("HTTP request failed: %w", syscall.ECONNRESET)
+	assert.Same(t, reset, WrapRequestError(reset), "非超时错误原样返回")
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o deadline reached" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPClientConfig 行情提供商共享 HTTP 客户端的连接池和传输层配置
+type HTTPClientConfig struct {
+	MaxIdleConns        int           `yaml:"max_idle_conns" mapstructure:"max_idle_conns"`                   // 所有主机的最大空闲连接数
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host" mapstructure:"max_idle_conns_per_host"` // 每个主机的最大空闲连接数
+	MaxConnsPerHost     int           `yaml:"max_conns_per_host" mapstructure:"max_conns_per_host"`           // 每个主机的最大连接数，0 表示不限制
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout" mapstructure:"idle_conn_timeout"`             // 空闲连接保留时间
+	DialTimeout         time.Duration `yaml:"dial_timeout" mapstructure:"dial_timeout"`                       // 建立连接（含 DNS 解析）的超时时间
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout" mapstructure:"tls_handshake_timeout"`     // TLS 握手超时时间
+	ProxyURL            string        `yaml:"proxy_url" mapstructure:"proxy_url"`                             // 代理地址，为空时使用环境变量中的代理配置
+	UserAgents          []string      `yaml:"user_agents" mapstructure:"user_agents"`                         // 轮换使用的 User-Agent 列表
+	TLSConfig           *tls.Config   `yaml:"-" mapstructure:"-"`                                             // 自定义 TLS 配置
+}
+
+// DefaultHTTPClientConfig 返回默认的 HTTP 客户端配置
+func DefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		MaxConnsPerHost:     10,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         5 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+		UserAgents:          []string{"StockSub/1.0"},
+	}
+}
+
+// HTTPConnStats 连接复用统计
+type HTTPConnStats struct {
+	Requests    int64 `json:"requests"`     // 发出的请求数
+	ReusedConns int64 `json:"reused_conns"` // 复用已有连接的请求数
+	IdleHits    int64 `json:"idle_hits"`    // 从空闲连接池取得连接的请求数
+	NewConns    int64 `json:"new_conns"`    // 新建连接的请求数
+}
+
+// HTTPClient 多个提供商共享的 HTTP 客户端，负责连接池、User-Agent 轮换和连接复用统计。
+// 请求超时由调用方通过 context 控制，客户端本身不设置超时。
+type HTTPClient struct {
+	client     *http.Client
+	userAgents []string
+	nextAgent  uint64
+
+	requests int64
+	reused   int64
+	idleHits int64
+	newConns int64
+}
+
+// NewHTTPClient 根据配置创建 HTTP 客户端，代理地址无效时返回错误
+func NewHTTPClient(config HTTPClientConfig) (*HTTPClient, error) {
+	proxy := http.ProxyFromEnvironment
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q", config.ProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{Timeout: config.DialTimeout, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:               proxy,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        config.MaxIdleConns,
+		MaxIdleConnsPerHost: config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     config.MaxConnsPerHost,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		TLSHandshakeTimeout: config.TLSHandshakeTimeout,
+		TLSClientConfig:     config.TLSConfig,
+	}
+
+	return &HTTPClient{
+		client:     &http.Client{Transport: transport},
+		userAgents: append([]string(nil), config.UserAgents...),
+	}, nil
+}
+
+var (
+	sharedHTTPClient     *HTTPClient
+	sharedHTTPClientOnce sync.Once
+)
+
+// SharedHTTPClient 返回使用默认配置的进程级共享客户端，未指定客户端的提供商共用它的连接池
+func SharedHTTPClient() *HTTPClient {
+	sharedHTTPClientOnce.Do(func() {
+		// 默认配置不含代理地址，不会返回错误
+		sharedHTTPClient, _ = NewHTTPClient(DefaultHTTPClientConfig())
+	})
+	return sharedHTTPClient
+}
+
+// Do 发送请求。请求未设置 User-Agent 时按配置列表轮换填充，并记录连接是否复用。
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" && len(c.userAgents) > 0 {
+		index := (atomic.AddUint64(&c.nextAgent, 1) - 1) % uint64(len(c.userAgents))
+		req.Header.Set("User-Agent", c.userAgents[index])
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&c.reused, 1)
+			} else {
+				atomic.AddInt64(&c.newConns, 1)
+			}
+			if info.WasIdle {
+				atomic.AddInt64(&c.idleHits, 1)
+			}
+		},
+	}
+	atomic.AddInt64(&c.requests, 1)
+	return c.client.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// Stats 返回连接复用统计
+func (c *HTTPClient) Stats() HTTPConnStats {
+	return HTTPConnStats{
+		Requests:    atomic.LoadInt64(&c.requests),
+		ReusedConns: atomic.LoadInt64(&c.reused),
+		IdleHits:    atomic.LoadInt64(&c.idleHits),
+		NewConns:    atomic.LoadInt64(&c.newConns),
+	}
+}
+
+// CloseIdleConnections 关闭空闲连接
+func (c *HTTPClient) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *HTTPClient, url string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestNewHTTPClient_TransportSettings(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	client, err := NewHTTPClient(HTTPClientConfig{
+		MaxIdleConns:        20,
+		MaxIdleConnsPerHost: 4,
+		MaxConnsPerHost:     8,
+		IdleConnTimeout:     time.Minute,
+		DialTimeout:         time.Second,
+		TLSHandshakeTimeout: 2 * time.Second,
+		ProxyURL:            "http://proxy.local:3128",
+		TLSConfig:           tlsConfig,
+	})
+	require.NoError(t, err)
+
+	transport := client.client.Transport.(*http.Transport)
+	assert.Equal(t, 20, transport.MaxIdleConns)
+	assert.Equal(t, 4, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 8, transport.MaxConnsPerHost)
+	assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+	assert.Same(t, tlsConfig, transport.TLSClientConfig)
+	assert.Zero(t, client.client.Timeout, "超时由请求的 context 控制")
+
+	req, _ := http.NewRequest(http.MethodGet, "http://qt.example/q=sh600000", nil)
+	proxyURL, err := transport.Proxy(req)
+	require.NoError(t, err)
+	assert.Equal(t, "proxy.local:3128", proxyURL.Host)
+
+	_, err = NewHTTPClient(HTTPClientConfig{ProxyURL: "::bad"})
+	assert.Error(t, err)
+}
+
+func TestHTTPClient_ProxyUsed(t *testing.T) {
+	// 代理收到的是目标地址的完整 URL
+	var gotURL string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		_, _ = io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	config := DefaultHTTPClientConfig()
+	config.ProxyURL = proxy.URL
+	client, err := NewHTTPClient(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, "via proxy", get(t, client, "http://quotes.invalid/q=sh600000"))
+	assert.Equal(t, "http://quotes.invalid/q=sh600000", gotURL)
+}
+
+func TestHTTPClient_RotatesUserAgents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.UserAgent())
+	}))
+	defer server.Close()
+
+	config := DefaultHTTPClientConfig()
+	config.UserAgents = []string{"agent-a", "agent-b"}
+	client, err := NewHTTPClient(config)
+	require.NoError(t, err)
+
+	assert.Equal(t, "agent-a", get(t, client, server.URL))
+	assert.Equal(t, "agent-b", get(t, client, server.URL))
+	assert.Equal(t, "agent-a", get(t, client, server.URL))
+
+	// 请求自带的 User-Agent 不被覆盖
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", "custom")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "custom", string(body))
+}
+
+func TestHTTPClient_ConcurrentRequestsReuseConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	const workers, perWorker = 4, 10
+	config := DefaultHTTPClientConfig()
+	config.MaxIdleConnsPerHost = workers
+	config.MaxConnsPerHost = workers
+	client, err := NewHTTPClient(config)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				get(t, client, server.URL)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := client.Stats()
+	assert.Equal(t, int64(workers*perWorker), stats.Requests)
+	assert.LessOrEqual(t, stats.NewConns, int64(workers), "连接数不超过连接池上限")
+	assert.Equal(t, stats.Requests, stats.NewConns+stats.ReusedConns)
+
+	// 请求结束后连接回到空闲池，下一次请求直接取用
+	get(t, client, server.URL)
+	after := client.Stats()
+	assert.Equal(t, stats.NewConns, after.NewConns)
+	assert.Equal(t, stats.IdleHits+1, after.IdleHits)
+}
@@ -59,10 +59,22 @@ type StockData struct {
 	LimitUp      float64 `json:"limit_up"`      // 涨停价
 	LimitDown    float64 `json:"limit_down"`    // 跌停价
 
+	// 扩展信息
+	TradingStatus string  `json:"trading_status"` // 交易状态，取值见 StatusTrading 等常量
+	Week52High    float64 `json:"week52_high"`    // 52周最高价
+	Week52Low     float64 `json:"week52_low"`     // 52周最低价
+
 	// 时间信息
 	Timestamp time.Time `json:"timestamp"` // 时间戳
 }
 
+// 股票交易状态
+const (
+	StatusTrading   = "trading"   // 正常交易
+	StatusSuspended = "suspended" // 停牌
+	StatusDelisted  = "delisted"  // 退市
+)
+
 // IndexData 指数数据结构
 type IndexData struct {
 	Symbol        string  `json:"symbol"`         // 指数代码
@@ -90,12 +102,35 @@ type HistoricalData struct {
 
 // Query 定义了在存储层进行数据查询的条件。
 type Query struct {
-	Symbols   []string  `json:"symbols"`    // 目标股票代码
-	StartTime time.Time `json:"start_time"` // 查询的开始时间
-	EndTime   time.Time `json:"end_time"`   // 查询的结束时间
-	Fields    []string  `json:"fields"`     // 需要返回的字段
-	Limit     int       `json:"limit"`      // 返回记录的最大数量
-	Offset    int       `json:"offset"`     // 返回记录的偏移量
+	Symbols    []string    `json:"symbols"`    // 目标股票代码
+	StartTime  time.Time   `json:"start_time"` // 查询的开始时间
+	EndTime    time.Time   `json:"end_time"`   // 查询的结束时间
+	Fields     []string    `json:"fields"`     // 需要返回的字段
+	Limit      int         `json:"limit"`      // 返回记录的最大数量
+	Offset     int         `json:"offset"`     // 返回记录的偏移量
+	Conditions []Condition `json:"conditions"` // 字段条件，多个条件之间为 AND 关系
+	SortBy     string      `json:"sort_by"`    // 排序字段，为空时保持存储顺序
+	SortDesc   bool        `json:"sort_desc"`  // 是否按降序排序
+}
+
+// ConditionOp 查询条件的比较运算符
+type ConditionOp string
+
+const (
+	OpEq  ConditionOp = "="
+	OpNe  ConditionOp = "!="
+	OpGt  ConditionOp = ">"
+	OpLt  ConditionOp = "<"
+	OpGte ConditionOp = ">="
+	OpLte ConditionOp = "<="
+	OpIn  ConditionOp = "IN" // Value 为候选值切片
+)
+
+// Condition 定义了对单个字段的比较条件，字段缺失的记录不满足任何条件。
+type Condition struct {
+	Field string      `json:"field"` // 字段名
+	Op    ConditionOp `json:"op"`    // 比较运算符
+	Value interface{} `json:"value"` // 比较值
 }
 
 // Record 代表一条通用的、可被存储的数据记录。
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	PricePrecision   = 3 // 价格保留的小数位数
+	PercentPrecision = 2 // 百分比保留的小数位数
+	AmountPrecision  = 2 // 成交额、市值等金额保留的小数位数
+)
+
+// RoundHalfUp 四舍五入到 decimals 位小数，0.5 远离零进位（-1.005 → -1.01），舍入为零的负数返回 0。
+//
+// 按 float64 的最短十进制表示取舍，1.005 的二进制值略小于 1.005，但仍然进位到 1.01；
+// decimals 小于 0、NaN 和 ±Inf 原样返回。
+func RoundHalfUp(v float64, decimals int) float64 {
+	if decimals < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+
+	abs := math.Abs(v)
+	digits := strconv.FormatFloat(abs, 'f', -1, 64)
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if len(fracPart) <= decimals {
+		return v
+	}
+
+	rounded, err := strconv.ParseFloat(intPart+"."+fracPart[:decimals], 64)
+	if err != nil {
+		return v
+	}
+	if fracPart[decimals] >= '5' {
+		// 加一个最小单位后按 decimals 位重新格式化，消除加法引入的误差
+		rounded, _ = strconv.ParseFloat(strconv.FormatFloat(rounded+math.Pow10(-decimals), 'f', decimals, 64), 64)
+	}
+	if v < 0 && rounded != 0 {
+		return -rounded
+	}
+	return rounded
+}
+
+// FormatDecimal 四舍五入到 decimals 位小数后格式化，不使用科学计数法，去掉末尾的 0
+func FormatDecimal(v float64, decimals int) string {
+	return strconv.FormatFloat(RoundHalfUp(v, decimals), 'f', -1, 64)
+}
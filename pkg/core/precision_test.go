@@ -0,0 +1,48 @@
+package core
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundHalfUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    float64
+		decimals int
+		want     float64
+	}{
+		{"浮点误差", 10.500000000000002, 3, 10.5},
+		{"x.005 进位", 1.005, 2, 1.01},
+		{"x.0005 进位", 2.0005, 3, 2.001},
+		{"负数远离零进位", -1.005, 2, -1.01},
+		{"负数舍去", -1.004, 2, -1},
+		{"进位到整数", 9.995, 2, 10},
+		{"进位跨多位", 0.9999, 3, 1},
+		{"位数不足不变", 12.3, 3, 12.3},
+		{"零位小数", 2.5, 0, 3},
+		{"负零位小数", -2.5, 0, -3},
+		{"大数", 123456789012.345, 2, 123456789012.35},
+		{"零", 0, 2, 0},
+		{"负位数不处理", 1.23456, -1, 1.23456},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, RoundHalfUp(tt.value, tt.decimals))
+		})
+	}
+
+	assert.False(t, math.Signbit(RoundHalfUp(-0.001, 2)), "不产生 -0")
+	assert.True(t, math.IsNaN(RoundHalfUp(math.NaN(), 2)))
+	assert.True(t, math.IsInf(RoundHalfUp(math.Inf(-1), 2), -1))
+}
+
+func TestFormatDecimal(t *testing.T) {
+	assert.Equal(t, "10.5", FormatDecimal(10.500000000000002, PricePrecision))
+	assert.Equal(t, "-1.01", FormatDecimal(-1.005, PercentPrecision))
+	assert.Equal(t, "320000000000", FormatDecimal(3.2e11, AmountPrecision), "不使用科学计数法")
+	assert.Equal(t, "0", FormatDecimal(1e-7, PricePrecision))
+	assert.Equal(t, "12", FormatDecimal(12.000, PricePrecision))
+}
@@ -0,0 +1,265 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Market 证券所属市场
+type Market string
+
+const (
+	MarketSH Market = "SH" // 上海证券交易所
+	MarketSZ Market = "SZ" // 深圳证券交易所
+	MarketBJ Market = "BJ" // 北京证券交易所
+	MarketHK Market = "HK" // 香港交易所
+	MarketUS Market = "US" // 美股
+)
+
+// ErrInvalidSymbol 无法识别的证券代码
+var ErrInvalidSymbol = errors.New("invalid symbol")
+
+// Symbol 带市场信息的证券代码，通过 ParseSymbol 创建
+//
+// 规范形式为 <代码>.<市场>，如 600000.SH、000001.SH（上证指数）、399001.SZ、00700.HK、AAPL.US，
+// Redis 键和 InfluxDB 标签都使用规范形式。
+type Symbol struct {
+	Code   string // A股和指数为 6 位数字，港股为 5 位数字，美股为大写代码
+	Market Market
+	index  bool
+}
+
+// prefixMarkets 代码前缀中的市场名称
+var prefixMarkets = map[string]Market{
+	"SH": MarketSH,
+	"SZ": MarketSZ,
+	"BJ": MarketBJ,
+	"HK": MarketHK,
+	"US": MarketUS,
+}
+
+// marketAliases 交易所后缀中的市场名称，SS 是雅虎财经对上交所的写法
+var marketAliases = map[string]Market{
+	"SH": MarketSH,
+	"SS": MarketSH,
+	"SZ": MarketSZ,
+	"BJ": MarketBJ,
+	"HK": MarketHK,
+	"US": MarketUS,
+}
+
+// ParseSymbol 识别证券代码的市场并转换为 Symbol，支持以下写法（不区分大小写）：
+//
+//   - 不带市场的 A股代码 600000、300750、830799，按号段判断交易所；
+//     不带市场的 000xxx 视为深市股票，上证指数必须写成 sh000001 或 000001.SH
+//   - 市场前缀 sh600000、sz399001、bj430047、hk00700、usAAPL，以及新浪的 rt_hk00700、gb_aapl
+//   - 交易所后缀 600000.SH、600000.SS、0700.HK、AAPL.US
+//   - 不带市场的 5 位数字视为港股，纯字母（可带 .B 等类别后缀）视为美股
+//
+// 港股代码补齐到 5 位。显式指定的市场优先于号段规则，399xxx 以外的深市代码不会被识别为指数。
+func ParseSymbol(s string) (Symbol, error) {
+	raw := strings.TrimSpace(s)
+	if raw == "" {
+		return Symbol{}, fmt.Errorf("%w: empty", ErrInvalidSymbol)
+	}
+	upper := strings.ToUpper(raw)
+
+	switch {
+	case strings.HasPrefix(upper, "RT_HK"):
+		return newSymbol(MarketHK, upper[len("RT_HK"):], raw)
+	case strings.HasPrefix(upper, "GB_"):
+		// 新浪美股代码用 $ 代替类别分隔符，如 gb_brk$b
+		return newSymbol(MarketUS, strings.ReplaceAll(upper[len("GB_"):], "$", "."), raw)
+	}
+
+	// 交易所后缀，不是市场名称的后缀（如 BRK.B）保留在代码中
+	if dot := strings.LastIndex(upper, "."); dot > 0 {
+		if market, ok := marketAliases[upper[dot+1:]]; ok {
+			return newSymbol(market, upper[:dot], raw)
+		}
+	}
+
+	// 市场前缀：数字代码不区分大小写（sh600000、SZ000001）；字母代码要求小写前缀加大写代码（usAAPL、hkHSI），
+	// 避免把 USB、HKIT 这样的美股代码误认为带前缀
+	if len(raw) > 2 {
+		if market, ok := prefixMarkets[upper[:2]]; ok {
+			code := upper[2:]
+			lowerPrefix := raw[:2] == strings.ToLower(raw[:2]) && raw[2:] == code
+			switch {
+			case isDigits(code) && market != MarketUS,
+				lowerPrefix && market == MarketHK && isLetters(code),
+				lowerPrefix && market == MarketUS && isUSTicker(code):
+				return newSymbol(market, code, raw)
+			}
+		}
+	}
+
+	switch {
+	case len(upper) == 6 && isDigits(upper):
+		market, ok := aShareMarket(upper)
+		if !ok {
+			return Symbol{}, fmt.Errorf("%w: unknown A-share code range %q", ErrInvalidSymbol, raw)
+		}
+		return newSymbol(market, upper, raw)
+	case len(upper) == 5 && isDigits(upper):
+		return newSymbol(MarketHK, upper, raw)
+	case isUSTicker(upper):
+		return newSymbol(MarketUS, upper, raw)
+	}
+	return Symbol{}, fmt.Errorf("%w: %q", ErrInvalidSymbol, raw)
+}
+
+// MustParseSymbol 与 ParseSymbol 相同，无法识别时 panic，用于常量和测试
+func MustParseSymbol(s string) Symbol {
+	symbol, err := ParseSymbol(s)
+	if err != nil {
+		panic(err)
+	}
+	return symbol
+}
+
+// NormalizeSymbol 返回代码的规范形式，无法识别的代码去除首尾空白后原样返回
+func NormalizeSymbol(s string) string {
+	if symbol, err := ParseSymbol(s); err == nil {
+		return symbol.Canonical()
+	}
+	return strings.TrimSpace(s)
+}
+
+// newSymbol 校验代码格式并判断是否为指数
+func newSymbol(market Market, code, raw string) (Symbol, error) {
+	switch market {
+	case MarketSH, MarketSZ, MarketBJ:
+		if len(code) != 6 || !isDigits(code) {
+			return Symbol{}, fmt.Errorf("%w: %s code must be 6 digits: %q", ErrInvalidSymbol, market, raw)
+		}
+	case MarketHK:
+		switch {
+		case isDigits(code) && len(code) <= 5:
+			code = strings.Repeat("0", 5-len(code)) + code
+		case isLetters(code):
+			// 恒生指数等以字母表示，如 hkHSI
+			return Symbol{Code: code, Market: market, index: true}, nil
+		default:
+			return Symbol{}, fmt.Errorf("%w: HK code must be up to 5 digits: %q", ErrInvalidSymbol, raw)
+		}
+	case MarketUS:
+		if !isUSTicker(code) {
+			return Symbol{}, fmt.Errorf("%w: invalid US ticker %q", ErrInvalidSymbol, raw)
+		}
+	}
+	return Symbol{Code: code, Market: market, index: isIndexCode(market, code)}, nil
+}
+
+// aShareMarket 按号段判断不带市场的 6 位代码所属交易所
+//
+// 上交所: 6xxxxx 主板和科创板（688/689），5xxxxx 基金，900xxx B股；
+// 深交所: 0xxxxx 主板，300/301 创业板，1xxxxx 基金，2xxxxx B股，399xxx 指数；
+// 北交所: 4xxxxx、8xxxxx 以及新号段 920xxx。
+func aShareMarket(code string) (Market, bool) {
+	switch {
+	case strings.HasPrefix(code, "920"):
+		return MarketBJ, true
+	case code[0] == '5' || code[0] == '6' || code[0] == '9':
+		return MarketSH, true
+	case code[0] == '0' || code[0] == '1' || code[0] == '2' || code[0] == '3':
+		return MarketSZ, true
+	case code[0] == '4' || code[0] == '8':
+		return MarketBJ, true
+	}
+	return "", false
+}
+
+// isIndexCode 上证指数为 000xxx，深证指数为 399xxx，北证指数为 899xxx
+func isIndexCode(market Market, code string) bool {
+	switch market {
+	case MarketSH:
+		return strings.HasPrefix(code, "000")
+	case MarketSZ:
+		return strings.HasPrefix(code, "399")
+	case MarketBJ:
+		return strings.HasPrefix(code, "899")
+	}
+	return false
+}
+
+// Canonical 返回规范形式，如 600000.SH、00700.HK、AAPL.US
+func (s Symbol) Canonical() string {
+	return s.Code + "." + string(s.Market)
+}
+
+// String 返回规范形式
+func (s Symbol) String() string {
+	return s.Canonical()
+}
+
+// TencentFormat 返回腾讯行情使用的形式，如 sh600000、hk00700、usAAPL
+func (s Symbol) TencentFormat() string {
+	if s.Market == MarketUS {
+		return "us" + s.Code
+	}
+	return strings.ToLower(string(s.Market)) + s.Code
+}
+
+// SinaFormat 返回新浪行情使用的形式，如 sh600000、hk00700、gb_aapl（类别分隔符写作 $）
+func (s Symbol) SinaFormat() string {
+	if s.Market == MarketUS {
+		return "gb_" + strings.ToLower(strings.ReplaceAll(s.Code, ".", "$"))
+	}
+	return strings.ToLower(string(s.Market)) + s.Code
+}
+
+// ExchangeSuffixFormat 返回交易所后缀形式，如 600000.SH、00700.HK；美股通常不带后缀，返回 AAPL
+func (s Symbol) ExchangeSuffixFormat() string {
+	if s.Market == MarketUS {
+		return s.Code
+	}
+	return s.Canonical()
+}
+
+// Legacy 返回引入规范形式之前 Redis 键和 InfluxDB 标签使用的形式：
+// A股股票和港股、美股为不带市场的代码，A股指数为 sh000001 这样的带前缀形式
+func (s Symbol) Legacy() string {
+	if s.IsAShare() && s.index {
+		return s.TencentFormat()
+	}
+	return s.Code
+}
+
+// IsIndex 是否为指数
+func (s Symbol) IsIndex() bool {
+	return s.index
+}
+
+// IsAShare 是否为沪深北三个交易所的代码（含指数）
+func (s Symbol) IsAShare() bool {
+	return s.Market == MarketSH || s.Market == MarketSZ || s.Market == MarketBJ
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isUSTicker 大写字母开头，由字母组成，可带一个 .X 类别后缀（如 BRK.B），最长 5 个字母
+func isUSTicker(s string) bool {
+	base, class, hasClass := strings.Cut(s, ".")
+	if !isLetters(base) || len(base) > 5 {
+		return false
+	}
+	return !hasClass || isLetters(class) && len(class) <= 2
+}
@@ -0,0 +1,160 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSymbol(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		canonical string
+		index     bool
+	}{
+		// 不带市场的 A股代码按号段判断
+		{"上海主板", "600000", "600000.SH", false},
+		{"上海主板 601", "601398", "601398.SH", false},
+		{"科创板 688", "688981", "688981.SH", false},
+		{"科创板 689 存托凭证", "689009", "689009.SH", false},
+		{"上海基金", "510300", "510300.SH", false},
+		{"上海 B股", "900901", "900901.SH", false},
+		{"深圳主板", "000001", "000001.SZ", false},
+		{"深圳中小板", "002594", "002594.SZ", false},
+		{"创业板 300", "300750", "300750.SZ", false},
+		{"创业板 301", "301001", "301001.SZ", false},
+		{"深圳基金", "159915", "159915.SZ", false},
+		{"深圳 B股", "200002", "200002.SZ", false},
+		{"深证成指", "399001", "399001.SZ", true},
+		{"北交所 43", "430047", "430047.BJ", false},
+		{"北交所 83", "830799", "830799.BJ", false},
+		{"北交所 87", "871981", "871981.BJ", false},
+		{"北交所新号段 920", "920002", "920002.BJ", false},
+		{"北证50", "bj899050", "899050.BJ", true},
+
+		// 市场前缀
+		{"腾讯前缀", "sh600000", "600000.SH", false},
+		{"大写前缀", "SZ000001", "000001.SZ", false},
+		{"上证指数", "sh000001", "000001.SH", true},
+		{"沪深300 上证代码", "sh000300", "000300.SH", true},
+		{"深市 000 股票不是指数", "sz000001", "000001.SZ", false},
+		{"上海前缀的股票", "sh600036", "600036.SH", false},
+		{"北交所前缀", "bj430047", "430047.BJ", false},
+
+		// 交易所后缀
+		{"后缀", "600000.SH", "600000.SH", false},
+		{"小写后缀", "000001.sz", "000001.SZ", false},
+		{"雅虎上交所后缀", "600000.SS", "600000.SH", false},
+		{"后缀形式的上证指数", "000001.SH", "000001.SH", true},
+		{"后缀形式的深市股票", "000300.SZ", "000300.SZ", false},
+		{"北交所后缀", "830799.BJ", "830799.BJ", false},
+
+		// 港股
+		{"5 位港股", "00700", "00700.HK", false},
+		{"港股前缀", "hk00700", "00700.HK", false},
+		{"新浪港股", "rt_hk00700", "00700.HK", false},
+		{"4 位港股后缀", "0700.HK", "00700.HK", false},
+		{"港股补零", "hk700", "00700.HK", false},
+		{"恒生指数", "hkHSI", "HSI.HK", true},
+
+		// 美股
+		{"美股代码", "AAPL", "AAPL.US", false},
+		{"小写美股", "aapl", "AAPL.US", false},
+		{"腾讯美股", "usAAPL", "AAPL.US", false},
+		{"新浪美股", "gb_aapl", "AAPL.US", false},
+		{"美股后缀", "AAPL.US", "AAPL.US", false},
+		{"类别股", "BRK.B", "BRK.B.US", false},
+		{"新浪类别股", "gb_brk$b", "BRK.B.US", false},
+		{"US 开头的美股不是前缀", "USB", "USB.US", false},
+		{"SH 开头的美股不是前缀", "SHOP", "SHOP.US", false},
+		{"HK 开头的美股不是前缀", "HKIT", "HKIT.US", false},
+
+		{"首尾空白", "  600000 ", "600000.SH", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			symbol, err := ParseSymbol(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.canonical, symbol.Canonical())
+			assert.Equal(t, tt.index, symbol.IsIndex())
+
+			again, err := ParseSymbol(symbol.Canonical())
+			require.NoError(t, err, "规范形式可以再次解析")
+			assert.Equal(t, symbol, again)
+		})
+	}
+}
+
+func TestParseSymbol_Invalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"   ",
+		"700000",  // 未分配号段
+		"1234567", // 7 位数字
+		"60000a",  // 数字夹字母
+		"sh60000", // 前缀后不足 6 位
+		"sh6000001",
+		"600000.SZX",
+		"123456.HK", // 港股超过 5 位
+		"ABCDEF",    // 美股代码超过 5 个字母
+		"浦发银行",
+		"sh00000x",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseSymbol(input)
+			assert.ErrorIs(t, err, ErrInvalidSymbol)
+		})
+	}
+}
+
+func TestSymbol_Formats(t *testing.T) {
+	tests := []struct {
+		input    string
+		tencent  string
+		sina     string
+		exchange string
+		legacy   string
+	}{
+		{"600000", "sh600000", "sh600000", "600000.SH", "600000"},
+		{"000001.SZ", "sz000001", "sz000001", "000001.SZ", "000001"},
+		{"sh000001", "sh000001", "sh000001", "000001.SH", "sh000001"},
+		{"399006", "sz399006", "sz399006", "399006.SZ", "sz399006"},
+		{"830799", "bj830799", "bj830799", "830799.BJ", "830799"},
+		{"0700.HK", "hk00700", "hk00700", "00700.HK", "00700"},
+		{"AAPL", "usAAPL", "gb_aapl", "AAPL", "AAPL"},
+		{"BRK.B", "usBRK.B", "gb_brk$b", "BRK.B", "BRK.B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			symbol := MustParseSymbol(tt.input)
+			assert.Equal(t, tt.tencent, symbol.TencentFormat())
+			assert.Equal(t, tt.sina, symbol.SinaFormat())
+			assert.Equal(t, tt.exchange, symbol.ExchangeSuffixFormat())
+			assert.Equal(t, tt.legacy, symbol.Legacy())
+			assert.Equal(t, symbol.Canonical(), symbol.String())
+
+			for _, form := range []string{symbol.TencentFormat(), symbol.SinaFormat(), symbol.ExchangeSuffixFormat()} {
+				parsed, err := ParseSymbol(form)
+				require.NoError(t, err, form)
+				assert.Equal(t, symbol.Canonical(), parsed.Canonical(), "%s 解析回同一代码", form)
+			}
+		})
+	}
+}
+
+func TestSymbol_IsAShare(t *testing.T) {
+	assert.True(t, MustParseSymbol("600000").IsAShare())
+	assert.True(t, MustParseSymbol("sh000001").IsAShare())
+	assert.True(t, MustParseSymbol("430047").IsAShare())
+	assert.False(t, MustParseSymbol("00700").IsAShare())
+	assert.False(t, MustParseSymbol("AAPL").IsAShare())
+}
+
+func TestNormalizeSymbol(t *testing.T) {
+	assert.Equal(t, "600000.SH", NormalizeSymbol("600000"))
+	assert.Equal(t, "000001.SH", NormalizeSymbol("sh000001"))
+	assert.Equal(t, "unknown-1", NormalizeSymbol(" unknown-1 "), "无法识别的代码原样返回")
+	assert.Panics(t, func() { MustParseSymbol("700000") })
+}
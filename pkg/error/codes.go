@@ -0,0 +1,98 @@
+package error
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 跨提供商和采集器共用的错误代码
+const (
+	// CodeRateLimited 本地限流拒绝了请求
+	CodeRateLimited ErrorCode = "RATE_LIMITED"
+	// CodeNetworkTimeout 网络请求超时
+	CodeNetworkTimeout ErrorCode = "NETWORK_TIMEOUT"
+	// CodeParseFailure 响应或存储的数据无法解析
+	CodeParseFailure ErrorCode = "PARSE_FAILURE"
+	// CodeMarketClosed 当前不在交易时段
+	CodeMarketClosed ErrorCode = "MARKET_CLOSED"
+	// CodeSymbolNotFound 代码不存在或没有数据
+	CodeSymbolNotFound ErrorCode = "SYMBOL_NOT_FOUND"
+	// CodeUpstreamThrottled 上游接口限流或拒绝访问（429/403）
+	CodeUpstreamThrottled ErrorCode = "UPSTREAM_THROTTLED"
+	// CodeChecksumMismatch 消息校验和不匹配
+	CodeChecksumMismatch ErrorCode = "CHECKSUM_MISMATCH"
+)
+
+// Wrap 用错误代码包装 err，fields 为成对的键值，作为结构化上下文附加到错误上。
+// 错误信息沿用 err 的信息；err 为 nil 时返回 nil。
+func Wrap(code ErrorCode, err error, fields ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	e := &BaseError{
+		Code:      code,
+		Cause:     err,
+		Timestamp: time.Now(),
+		Context:   make(map[string]interface{}, len(fields)/2),
+	}
+	for i := 0; i < len(fields); i += 2 {
+		key := fmt.Sprint(fields[i])
+		if i+1 == len(fields) {
+			e.Context[key] = nil // 缺少值的键
+			break
+		}
+		e.Context[key] = fields[i+1]
+	}
+	return e
+}
+
+// coded 带错误代码的错误，包括嵌入 BaseError 的 StorageError、CacheError 等
+type coded interface {
+	error
+	GetCode() ErrorCode
+}
+
+// GetCode 返回错误代码
+func (e *BaseError) GetCode() ErrorCode {
+	return e.Code
+}
+
+// Is 判断错误链（包括 %w 包装）中是否有指定代码的错误
+func Is(err error, code ErrorCode) bool {
+	for err != nil {
+		var c coded
+		if !errors.As(err, &c) {
+			return false
+		}
+		if c.GetCode() == code {
+			return true
+		}
+		err = errors.Unwrap(c)
+	}
+	return false
+}
+
+// Code 返回错误链中最外层的错误代码，没有代码时返回空字符串
+func Code(err error) ErrorCode {
+	var c coded
+	if errors.As(err, &c) {
+		return c.GetCode()
+	}
+	return ""
+}
+
+// MarshalJSON 输出 API 错误响应使用的字段，不包含原始错误和调用栈
+func (e *BaseError) MarshalJSON() ([]byte, error) {
+	message := e.Message
+	if message == "" && e.Cause != nil {
+		message = e.Cause.Error()
+	}
+	return json.Marshal(struct {
+		Code      ErrorCode              `json:"code"`
+		Message   string                 `json:"message"`
+		Context   map[string]interface{} `json:"context,omitempty"`
+		Timestamp time.Time              `json:"timestamp"`
+	}{e.Code, message, e.Context, e.Timestamp})
+}
@@ -0,0 +1,70 @@
+package error
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// embeddedError 模拟 StorageError 等嵌入 BaseError 的错误类型
+type embeddedError struct {
+	BaseError
+}
+
+func TestWrap_CarriesCodeAndFields(t *testing.T) {
+	cause := errors.New("i/o timeout")
+	err := Wrap(CodeNetworkTimeout, cause, "symbol", "600000", "attempt", 2, "dangling")
+
+	var base *BaseError
+	require.ErrorAs(t, err, &base)
+	assert.Equal(t, CodeNetworkTimeout, base.Code)
+	assert.Equal(t, map[string]interface{}{"symbol": "600000", "attempt": 2, "dangling": nil}, base.Context)
+	assert.Equal(t, "NETWORK_TIMEOUT: i/o timeout", err.Error())
+	assert.ErrorIs(t, err, cause)
+
+	assert.NoError(t, Wrap(CodeParseFailure, nil))
+}
+
+func TestIs_ThroughWrapChains(t *testing.T) {
+	inner := Wrap(CodeChecksumMismatch, errors.New("bad checksum"))
+	outer := fmt.Errorf("consume message: %w", Wrap(CodeParseFailure, fmt.Errorf("decode: %w", inner)))
+
+	assert.True(t, Is(outer, CodeParseFailure))
+	assert.True(t, Is(outer, CodeChecksumMismatch), "内层的代码也能匹配")
+	assert.False(t, Is(outer, CodeMarketClosed))
+	assert.Equal(t, CodeParseFailure, Code(outer), "Code 返回最外层代码")
+
+	plain := errors.New("plain")
+	assert.False(t, Is(plain, CodeParseFailure))
+	assert.Empty(t, Code(plain))
+	assert.False(t, Is(nil, CodeParseFailure))
+	assert.Empty(t, Code(nil))
+
+	embedded := fmt.Errorf("save: %w", &embeddedError{BaseError: *NewError(CodeRateLimited, "too fast")})
+	assert.True(t, Is(embedded, CodeRateLimited), "嵌入 BaseError 的类型也能匹配")
+	assert.Equal(t, CodeRateLimited, Code(embedded))
+}
+
+func TestBaseError_MarshalJSON(t *testing.T) {
+	err := Wrap(CodeSymbolNotFound, errors.New("no data for 600000"), "symbol", "600000")
+	err.(*BaseError).Stack = []string{"main.go:1"}
+
+	data, jsonErr := json.Marshal(err)
+	require.NoError(t, jsonErr)
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "SYMBOL_NOT_FOUND", body["code"])
+	assert.Equal(t, "no data for 600000", body["message"], "没有 Message 时使用原始错误信息")
+	assert.Equal(t, map[string]interface{}{"symbol": "600000"}, body["context"])
+	assert.Contains(t, body, "timestamp")
+	assert.NotContains(t, body, "stack")
+
+	data, jsonErr = json.Marshal(NewError(CodeMarketClosed, "当前不在交易时段"))
+	require.NoError(t, jsonErr)
+	assert.Contains(t, string(data), `"message":"当前不在交易时段"`)
+	assert.NotContains(t, string(data), "context", "空上下文省略")
+}
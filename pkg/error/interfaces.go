@@ -30,6 +30,9 @@ func NewError(code ErrorCode, message string) *BaseError {
 
 // Error 实现 error 接口
 func (e *BaseError) Error() string {
+	if e.Message == "" && e.Cause != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.Cause)
+	}
 	if e.Cause != nil {
 		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
 	}
@@ -0,0 +1,208 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gopkg.in/yaml.v3"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+// 导出格式和压缩方式
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// AllSymbols symbols 配置为该值时导出 Redis 代码集合中的全部股票
+const AllSymbols = "all"
+
+// dateLayout 分区目录和配置中日期的格式
+const dateLayout = "2006-01-02"
+
+// Config 导出任务配置，对应 config/exporter.yaml
+type Config struct {
+	// Symbols 股票代码列表，配置为 all 时读取 SymbolsKey 集合
+	Symbols    SymbolList `yaml:"symbols"`
+	SymbolsKey string     `yaml:"symbols_key"` // redis_collector 维护的代码集合，默认 latest:symbols:stock
+	StartDate  string     `yaml:"start_date"`  // 起始交易日（YYYY-MM-DD），为空时为昨天
+	EndDate    string     `yaml:"end_date"`    // 结束交易日（含），为空时与 StartDate 相同
+	Timezone   string     `yaml:"timezone"`    // 划分交易日使用的时区，默认 Asia/Shanghai
+	Namespace  string     `yaml:"namespace"`   // 命名空间，需与 influxdb_collector 一致；SymbolsKey 同样按命名空间加前缀
+
+	Format      string `yaml:"format"`      // csv 或 parquet
+	Compression string `yaml:"compression"` // none 或 gzip，gzip 时文件名追加 .gz
+	OutputDir   string `yaml:"output_dir"`  // 输出目录，文件写入 <output_dir>/<date>/<symbol>.<ext>
+	Concurrency int    `yaml:"concurrency"` // 同时导出的股票数
+
+	InfluxDB InfluxDBConfig `yaml:"influxdb"`
+	Redis    RedisConfig    `yaml:"redis"`
+
+	// Progress 每个文件导出、跳过或失败后调用，可以为 nil
+	Progress func(Progress) `yaml:"-"`
+}
+
+// InfluxDBConfig 行情数据所在的 InfluxDB
+type InfluxDBConfig struct {
+	URL         string `yaml:"url"`
+	Token       string `yaml:"token"` // 为空时读取 INFLUXDB_TOKEN
+	Org         string `yaml:"org"`
+	Bucket      string `yaml:"bucket"`
+	Measurement string `yaml:"measurement"` // 默认 stock_realtime
+}
+
+// RedisConfig symbols 为 all 时读取代码集合使用的 Redis
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// SymbolList 股票代码列表，YAML 中可以写为列表或字符串 all
+type SymbolList []string
+
+// UnmarshalYAML 同时接受 symbols: all 和 symbols: [600000, 000001]
+func (l *SymbolList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = SymbolList{node.Value}
+		return nil
+	}
+	var symbols []string
+	if err := node.Decode(&symbols); err != nil {
+		return err
+	}
+	*l = symbols
+	return nil
+}
+
+// All 是否导出代码集合中的全部股票
+func (l SymbolList) All() bool {
+	return len(l) == 1 && strings.EqualFold(l[0], AllSymbols)
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		SymbolsKey:  "latest:symbols:stock",
+		Timezone:    "Asia/Shanghai",
+		Format:      FormatCSV,
+		Compression: CompressionGzip,
+		OutputDir:   "data/export",
+		Concurrency: 4,
+		InfluxDB: InfluxDBConfig{
+			URL:         "http://localhost:8086",
+			Org:         "stocksub",
+			Bucket:      "stock_data",
+			Measurement: "stock_realtime",
+		},
+		Redis: RedisConfig{Addr: "localhost:6379"},
+	}
+}
+
+// LoadConfig 读取 YAML 配置，未配置的项使用默认值
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	return config, config.Validate()
+}
+
+// Validate 检查配置
+func (c *Config) Validate() error {
+	if len(c.Symbols) == 0 {
+		return fmt.Errorf("symbols 不能为空，导出全部股票时配置为 all")
+	}
+	if c.Format != FormatCSV && c.Format != FormatParquet {
+		return fmt.Errorf("不支持的导出格式 %q，可选 csv、parquet", c.Format)
+	}
+	if c.Compression != CompressionNone && c.Compression != CompressionGzip {
+		return fmt.Errorf("不支持的压缩方式 %q，可选 none、gzip", c.Compression)
+	}
+	if c.OutputDir == "" {
+		return fmt.Errorf("output_dir 不能为空")
+	}
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("concurrency 必须大于 0")
+	}
+	if err := message.ValidateNamespace(c.Namespace); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("无效的时区 %q: %w", c.Timezone, err)
+	}
+	_, err := c.Dates(time.Now())
+	return err
+}
+
+// Dates 返回需要导出的交易日（当天 0 点，按 Timezone），now 用于计算默认的昨天
+func (c *Config) Dates(now time.Time) ([]time.Time, error) {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now = now.In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, loc)
+	if c.StartDate != "" {
+		if start, err = time.ParseInLocation(dateLayout, c.StartDate, loc); err != nil {
+			return nil, fmt.Errorf("无效的 start_date %q: %w", c.StartDate, err)
+		}
+	}
+	end := start
+	if c.EndDate != "" {
+		if end, err = time.ParseInLocation(dateLayout, c.EndDate, loc); err != nil {
+			return nil, fmt.Errorf("无效的 end_date %q: %w", c.EndDate, err)
+		}
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end_date %s 早于 start_date %s", end.Format(dateLayout), start.Format(dateLayout))
+	}
+
+	var dates []time.Time
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		dates = append(dates, day)
+	}
+	return dates, nil
+}
+
+// ResolveSymbols 返回需要导出的规范形式代码（如 600000.SH），去重并排序；
+// symbols 为 all 时读取 Redis 中的代码集合，rdb 仅在这种情况下使用
+func ResolveSymbols(ctx context.Context, rdb redis.Cmdable, config Config) ([]string, error) {
+	symbols := []string(config.Symbols)
+	if config.Symbols.All() {
+		key := message.NamespaceKey(config.Namespace, config.SymbolsKey)
+		members, err := rdb.SMembers(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("读取代码集合 %s 失败: %w", key, err)
+		}
+		symbols = members
+	}
+
+	seen := make(map[string]bool, len(symbols))
+	result := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		canonical := core.NormalizeSymbol(symbol)
+		if canonical == "" || seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		result = append(result, canonical)
+	}
+	sort.Strings(result)
+	return result, nil
+}
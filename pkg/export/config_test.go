@@ -0,0 +1,85 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+symbols: all
+start_date: "2025-08-18"
+end_date: "2025-08-20"
+format: parquet
+compression: none
+concurrency: 8
+influxdb:
+  bucket: archive
+`), 0644))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.True(t, config.Symbols.All())
+	assert.Equal(t, FormatParquet, config.Format)
+	assert.Equal(t, 8, config.Concurrency)
+	assert.Equal(t, "archive", config.InfluxDB.Bucket)
+	assert.Equal(t, "stock_realtime", config.InfluxDB.Measurement, "未配置的项使用默认值")
+	assert.Equal(t, "data/export", config.OutputDir)
+
+	dates, err := config.Dates(time.Now())
+	require.NoError(t, err)
+	require.Len(t, dates, 3)
+	assert.Equal(t, "2025-08-20", dates[2].Format(dateLayout))
+
+	require.NoError(t, os.WriteFile(path, []byte("symbols: [600000, \"000001\"]\nformat: xlsx\n"), 0644))
+	_, err = LoadConfig(path)
+	assert.ErrorContains(t, err, "xlsx")
+}
+
+func TestConfig_DatesDefaultsToYesterday(t *testing.T) {
+	config := DefaultConfig()
+	// 上海时间 2025-08-21 01:00，UTC 仍是 8 月 20 日
+	now := time.Date(2025, 8, 20, 17, 0, 0, 0, time.UTC)
+
+	dates, err := config.Dates(now)
+	require.NoError(t, err)
+	require.Len(t, dates, 1)
+	assert.Equal(t, "2025-08-20", dates[0].Format(dateLayout))
+	assert.Equal(t, time.Date(2025, 8, 19, 16, 0, 0, 0, time.UTC), dates[0].UTC())
+
+	config.StartDate, config.EndDate = "2025-08-20", "2025-08-19"
+	_, err = config.Dates(now)
+	assert.Error(t, err)
+}
+
+func TestResolveSymbols(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	mr.SAdd("latest:symbols:stock", "600000.SH", "000001.SZ", "600000")
+
+	config := DefaultConfig()
+	config.Symbols = SymbolList{AllSymbols}
+	symbols, err := ResolveSymbols(context.Background(), rdb, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000001.SZ", "600000.SH"}, symbols, "旧格式成员合并为规范形式")
+
+	mr.SAdd("staging:latest:symbols:stock", "600519.SH")
+	config.Namespace = "staging"
+	symbols, err = ResolveSymbols(context.Background(), rdb, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600519.SH"}, symbols, "命名空间下读取带前缀的代码集合")
+
+	config.Symbols = SymbolList{"sh600519", "600519"}
+	symbols, err = ResolveSymbols(context.Background(), nil, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600519.SH"}, symbols)
+}
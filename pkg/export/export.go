@@ -0,0 +1,395 @@
+// Package export 将 InfluxDB 中的逐笔行情按交易日和股票导出为 CSV 或 Parquet 归档
+//
+// 每个交易日一个分区目录 <output_dir>/<date>/，每只股票一个文件，目录中的 manifest.json
+// 记录文件、行数和 SHA-256 校验和；重新执行时跳过校验和与清单一致的文件，可以在中断后续跑。
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+	"stocksub/pkg/storage"
+)
+
+// ManifestFile 分区目录中的清单文件名
+const ManifestFile = "manifest.json"
+
+// Manifest 一个交易日分区的导出清单
+type Manifest struct {
+	Date        string          `json:"date"`
+	Measurement string          `json:"measurement"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Files       []ManifestEntry `json:"files"` // 按代码排序
+}
+
+// ManifestEntry 一只股票的导出结果，当天没有数据时 File 为空
+type ManifestEntry struct {
+	Symbol string `json:"symbol"`
+	File   string `json:"file,omitempty"` // 相对分区目录的文件名
+	Rows   int    `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Progress 导出进度
+type Progress struct {
+	Date     string
+	Symbol   string
+	Done     int // 已完成（含跳过和失败）的文件数
+	Total    int
+	Rows     int // 本文件的行数
+	Skipped  bool
+	Err      error
+	Duration time.Duration
+}
+
+// Failure 导出失败的文件
+type Failure struct {
+	Date   string
+	Symbol string
+	Err    error
+}
+
+// Result 导出结果
+type Result struct {
+	Exported int // 本次写入的文件数（含当天没有数据的股票）
+	Skipped  int // 校验和与清单一致而跳过的文件数
+	Rows     int // 本次写入的行数
+	Failures []Failure
+}
+
+// Run 按交易日依次导出，同一交易日内最多 Concurrency 只股票并发查询和写入。
+// 每个交易日结束后（包括被取消时）写入清单；有文件失败时返回汇总错误，Result 中包含全部失败
+func Run(ctx context.Context, queryAPI api.QueryAPI, symbols []string, dates []time.Time, config Config) (Result, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.InfluxDB.Measurement == "" {
+		config.InfluxDB.Measurement = "stock_realtime"
+	}
+	e := &exporter{queryAPI: queryAPI, config: config, total: len(symbols) * len(dates)}
+
+	for _, date := range dates {
+		if err := ctx.Err(); err != nil {
+			return e.result, err
+		}
+		if err := e.exportDate(ctx, date, symbols); err != nil {
+			return e.result, err
+		}
+	}
+
+	if n := len(e.result.Failures); n > 0 {
+		names := make([]string, n)
+		for i, failure := range e.result.Failures {
+			names[i] = failure.Date + "/" + failure.Symbol
+		}
+		return e.result, fmt.Errorf("%d 个文件导出失败: %s", n, strings.Join(names, ", "))
+	}
+	return e.result, nil
+}
+
+type exporter struct {
+	queryAPI api.QueryAPI
+	config   Config
+	total    int
+
+	mu     sync.Mutex
+	done   int
+	result Result
+}
+
+// exportDate 导出一个交易日的全部股票并写入清单
+func (e *exporter) exportDate(ctx context.Context, date time.Time, symbols []string) error {
+	day := date.Format(dateLayout)
+	dir := filepath.Join(e.config.OutputDir, day)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建分区目录失败: %w", err)
+	}
+
+	previous, err := readManifest(dir)
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]ManifestEntry, len(symbols))
+	for _, entry := range previous.Files {
+		entries[entry.Symbol] = entry
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < e.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbol := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				e.exportSymbol(ctx, dir, day, date, symbol, entries)
+			}
+		}()
+	}
+dispatch:
+	for _, symbol := range symbols {
+		select {
+		case jobs <- symbol:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	manifest := Manifest{Date: day, Measurement: e.config.InfluxDB.Measurement, GeneratedAt: time.Now().UTC()}
+	for _, entry := range entries {
+		manifest.Files = append(manifest.Files, entry)
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Symbol < manifest.Files[j].Symbol })
+	if err := writeManifest(dir, manifest); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// exportSymbol 导出一只股票一天的数据，结果写入 entries
+func (e *exporter) exportSymbol(ctx context.Context, dir, day string, date time.Time, symbol string, entries map[string]ManifestEntry) {
+	start := time.Now()
+	name := symbol + e.extension()
+
+	e.mu.Lock()
+	previous, exists := entries[symbol]
+	e.mu.Unlock()
+
+	var (
+		entry   ManifestEntry
+		skipped bool
+		err     error
+	)
+	if exists && matchesManifest(dir, name, previous) {
+		entry, skipped = previous, true
+	} else {
+		entry, err = e.writeSymbol(ctx, dir, name, date, symbol)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case err != nil:
+		e.result.Failures = append(e.result.Failures, Failure{Date: day, Symbol: symbol, Err: err})
+		if ctx.Err() == nil {
+			// 失败的文件需要重新导出，不沿用旧的清单记录
+			delete(entries, symbol)
+		}
+	case skipped:
+		e.result.Skipped++
+	default:
+		entries[symbol] = entry
+		e.result.Exported++
+		e.result.Rows += entry.Rows
+	}
+	e.done++
+	if e.config.Progress != nil {
+		e.config.Progress(Progress{
+			Date: day, Symbol: symbol, Done: e.done, Total: e.total,
+			Rows: entry.Rows, Skipped: skipped, Err: err, Duration: time.Since(start),
+		})
+	}
+}
+
+// writeSymbol 查询并序列化一只股票一天的数据，先写入临时文件再重命名，中断时不会留下不完整的文件
+func (e *exporter) writeSymbol(ctx context.Context, dir, name string, date time.Time, symbol string) (ManifestEntry, error) {
+	entry := ManifestEntry{Symbol: symbol}
+	records, err := e.query(ctx, date, symbol)
+	if err != nil {
+		return entry, err
+	}
+	if len(records) == 0 {
+		return entry, nil
+	}
+
+	format := storage.FormatCSV
+	if e.config.Format == FormatParquet {
+		format = storage.FormatParquet
+	}
+	data, err := storage.NewStructuredDataSerializer(format).SerializeMultiple(records)
+	if err != nil {
+		return entry, fmt.Errorf("序列化失败: %w", err)
+	}
+
+	path := filepath.Join(dir, name)
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return entry, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(tmp, hash)}
+	if err := e.writeData(counter, data); err != nil {
+		tmp.Close()
+		return entry, fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return entry, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return entry, err
+	}
+
+	entry.File = name
+	entry.Rows = len(records)
+	entry.Bytes = counter.n
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return entry, nil
+}
+
+func (e *exporter) writeData(w io.Writer, data []byte) error {
+	if e.config.Compression != CompressionGzip {
+		_, err := w.Write(data)
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// query 逐行读取 Flux 查询结果并转换为 StockDataSchema 的 StructuredData
+func (e *exporter) query(ctx context.Context, date time.Time, symbol string) ([]*storage.StructuredData, error) {
+	flux := buildQuery(e.config.InfluxDB.Bucket, e.config.InfluxDB.Measurement, e.config.Namespace, symbol, date, date.AddDate(0, 0, 1))
+	result, err := e.queryAPI.Query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("查询InfluxDB失败: %w", err)
+	}
+	defer result.Close()
+
+	var records []*storage.StructuredData
+	for result.Next() {
+		sd, err := storage.StructuredDataFromRow(storage.StockDataSchema, result.Record().Values())
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", len(records)+1, err)
+		}
+		sd.Values["symbol"] = symbol
+		sd.Values["timestamp"] = sd.Timestamp
+		records = append(records, sd)
+	}
+	if err := result.Err(); err != nil {
+		return nil, fmt.Errorf("读取查询结果失败: %w", err)
+	}
+	return records, nil
+}
+
+// extension 按格式和压缩方式返回文件扩展名，如 .csv.gz
+func (e *exporter) extension() string {
+	ext := "." + e.config.Format
+	if e.config.Compression == CompressionGzip {
+		ext += ".gz"
+	}
+	return ext
+}
+
+// buildQuery 构造返回 [start, stop) 内一只股票全部字段的 Flux 查询，
+// 规范形式和旧版本写入的不带市场的代码合并为一张按时间排序的表，只包含 namespace 命名空间的数据点
+func buildQuery(bucket, measurement, namespace, symbol string, start, stop time.Time) string {
+	candidates := []string{symbol}
+	if parsed, err := core.ParseSymbol(symbol); err == nil && parsed.Legacy() != symbol {
+		candidates = append(candidates, parsed.Legacy())
+	}
+	conditions := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		conditions[i] = fmt.Sprintf("r.symbol == %q", candidate)
+	}
+
+	return fmt.Sprintf(`
+		from(bucket: %q)
+		|> range(start: %s, stop: %s)
+		|> filter(fn: (r) => r._measurement == %q)
+		|> filter(fn: (r) => (%s) and %s)
+		|> pivot(rowKey:["_time"], columnKey: ["_field"], valueColumn: "_value")
+		|> group()
+		|> sort(columns: ["_time"])
+	`, bucket, start.UTC().Format(time.RFC3339), stop.UTC().Format(time.RFC3339), measurement,
+		strings.Join(conditions, " or "), message.NamespaceFluxFilter(namespace))
+}
+
+// matchesManifest 清单中的记录是否仍然有效：文件名一致且磁盘上文件的校验和匹配
+func matchesManifest(dir, name string, entry ManifestEntry) bool {
+	if entry.File == "" {
+		// 当天没有数据
+		return entry.Rows == 0
+	}
+	if entry.File != name {
+		return false
+	}
+	checksum, err := fileChecksum(filepath.Join(dir, name))
+	return err == nil && checksum == entry.SHA256
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readManifest 读取分区目录中的清单，不存在时返回空清单
+func readManifest(dir string) (Manifest, error) {
+	var manifest Manifest
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("解析 %s 失败: %w", filepath.Join(dir, ManifestFile), err)
+	}
+	return manifest, nil
+}
+
+func writeManifest(dir string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "."+ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,300 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/storage"
+)
+
+// fakeQueryAPI 按查询中的代码返回预置的 annotated CSV，并记录最大并发查询数
+type fakeQueryAPI struct {
+	api.QueryAPI
+	rows  map[string]string // 代码到数据行
+	fail  map[string]bool
+	delay time.Duration
+
+	mu       sync.Mutex
+	queries  []string
+	inFlight atomic.Int32
+	maxConc  atomic.Int32
+}
+
+const tickHeader = `#datatype,string,long,dateTime:RFC3339,string,string,string,double,double,double,long
+#group,false,false,false,false,false,false,false,false,false,false
+#default,_result,,,,,,,,,
+,result,table,_time,_measurement,name,symbol,price,change,change_percent,volume
+`
+
+func (f *fakeQueryAPI) Query(ctx context.Context, query string) (*api.QueryTableResult, error) {
+	n := f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+	for {
+		max := f.maxConc.Load()
+		if n <= max || f.maxConc.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(f.delay)
+
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.mu.Unlock()
+
+	for symbol, rows := range f.rows {
+		if strings.Contains(query, fmt.Sprintf("r.symbol == %q", symbol)) {
+			if f.fail[symbol] {
+				return nil, errors.New("query timeout")
+			}
+			return api.NewQueryTableResult(io.NopCloser(strings.NewReader(tickHeader + rows))), nil
+		}
+	}
+	return api.NewQueryTableResult(io.NopCloser(strings.NewReader(tickHeader))), nil
+}
+
+func (f *fakeQueryAPI) queryCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queries)
+}
+
+func newFakeQueryAPI() *fakeQueryAPI {
+	return &fakeQueryAPI{
+		rows: map[string]string{
+			"600000.SH": ",,0,2025-08-20T01:30:03Z,stock_realtime,浦发银行,600000.SH,10.500000000000002,0.1,0.96,1000\n" +
+				",,0,2025-08-20T01:30:06Z,stock_realtime,浦发银行,600000.SH,10.51,0.11,1.06,1200\n",
+			"000001.SZ": ",,0,2025-08-20T01:30:03Z,stock_realtime,平安银行,000001.SZ,12.3,-0.05,-0.4,500\n",
+		},
+		fail: map[string]bool{},
+	}
+}
+
+func testConfig(t *testing.T) Config {
+	config := DefaultConfig()
+	config.Symbols = SymbolList{"600000.SH", "000001.SZ", "600519.SH"}
+	config.StartDate = "2025-08-20"
+	config.OutputDir = t.TempDir()
+	config.Concurrency = 2
+	return config
+}
+
+func runExport(t *testing.T, queryAPI api.QueryAPI, config Config) (Result, error) {
+	t.Helper()
+	dates, err := config.Dates(time.Now())
+	require.NoError(t, err)
+	return Run(context.Background(), queryAPI, []string(config.Symbols), dates, config)
+}
+
+func readGzip(t *testing.T, path string) []byte {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return data
+}
+
+func loadManifest(t *testing.T, dir string) Manifest {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	require.NoError(t, err)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	return manifest
+}
+
+func TestRun_WritesPartitionedCSVAndManifest(t *testing.T) {
+	queryAPI := newFakeQueryAPI()
+	config := testConfig(t)
+
+	result, err := runExport(t, queryAPI, config)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Exported: 3, Rows: 3}, result)
+
+	dir := filepath.Join(config.OutputDir, "2025-08-20")
+	records, err := storage.NewStructuredDataSerializer(storage.FormatCSV).
+		DeserializeMultiple(readGzip(t, filepath.Join(dir, "600000.SH.csv.gz")), storage.StockDataSchema)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "600000.SH", records[0].Values["symbol"])
+	assert.Equal(t, "浦发银行", records[0].Values["name"])
+	assert.Equal(t, 10.5, records[0].Values["price"], "价格按字段精度舍入")
+	assert.Equal(t, int64(1200), records[1].Values["volume"])
+	ts, ok := records[1].Values["timestamp"].(time.Time)
+	require.True(t, ok)
+	assert.True(t, ts.Equal(time.Date(2025, 8, 20, 1, 30, 6, 0, time.UTC)))
+
+	manifest := loadManifest(t, dir)
+	assert.Equal(t, "2025-08-20", manifest.Date)
+	assert.Equal(t, "stock_realtime", manifest.Measurement)
+	require.Len(t, manifest.Files, 3)
+	assert.Equal(t, "000001.SZ", manifest.Files[0].Symbol)
+	assert.Equal(t, 1, manifest.Files[0].Rows)
+	pf := manifest.Files[1]
+	assert.Equal(t, ManifestEntry{Symbol: "600000.SH", File: "600000.SH.csv.gz", Rows: 2, Bytes: pf.Bytes, SHA256: pf.SHA256}, pf)
+	checksum, err := fileChecksum(filepath.Join(dir, pf.File))
+	require.NoError(t, err)
+	assert.Equal(t, checksum, pf.SHA256)
+	assert.Equal(t, ManifestEntry{Symbol: "600519.SH"}, manifest.Files[2], "当天没有数据的股票不写文件")
+	_, err = os.Stat(filepath.Join(dir, "600519.SH.csv.gz"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 查询覆盖上海时区的整个交易日，同时匹配旧格式代码
+	query := queryAPI.queries[0]
+	assert.Contains(t, query, "range(start: 2025-08-19T16:00:00Z, stop: 2025-08-20T16:00:00Z)")
+	assert.Contains(t, query, `r._measurement == "stock_realtime"`)
+	assert.Regexp(t, `\(r.symbol == "\d{6}\.S[HZ]" or r.symbol == "\d{6}"\) and not exists r.namespace`, query,
+		"默认命名空间不读取其他命名空间带标签的数据点")
+}
+
+func TestRun_Parquet(t *testing.T) {
+	config := testConfig(t)
+	config.Symbols = SymbolList{"000001.SZ"}
+	config.Format = FormatParquet
+	config.Compression = CompressionNone
+
+	_, err := runExport(t, newFakeQueryAPI(), config)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(config.OutputDir, "2025-08-20", "000001.SZ.parquet"))
+	require.NoError(t, err)
+	records, err := storage.NewStructuredDataSerializer(storage.FormatParquet).DeserializeMultiple(data, storage.StockDataSchema)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 12.3, records[0].Values["price"])
+}
+
+func TestRun_ResumeSkipsFilesWithMatchingChecksum(t *testing.T) {
+	queryAPI := newFakeQueryAPI()
+	config := testConfig(t)
+	_, err := runExport(t, queryAPI, config)
+	require.NoError(t, err)
+	require.Equal(t, 3, queryAPI.queryCount())
+
+	dir := filepath.Join(config.OutputDir, "2025-08-20")
+	first := loadManifest(t, dir)
+
+	result, err := runExport(t, queryAPI, config)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Skipped: 3}, result)
+	assert.Equal(t, 3, queryAPI.queryCount(), "校验和一致的文件不重新查询")
+
+	// 损坏的文件重新导出
+	path := filepath.Join(dir, "600000.SH.csv.gz")
+	require.NoError(t, os.WriteFile(path, []byte("truncated"), 0644))
+	result, err = runExport(t, queryAPI, config)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Exported: 1, Skipped: 2, Rows: 2}, result)
+	assert.Equal(t, 4, queryAPI.queryCount())
+	assert.Equal(t, first.Files, loadManifest(t, dir).Files)
+}
+
+func TestRun_FailedSymbolsReturnError(t *testing.T) {
+	queryAPI := newFakeQueryAPI()
+	queryAPI.fail["000001.SZ"] = true
+	config := testConfig(t)
+
+	var progress []Progress
+	var mu sync.Mutex
+	config.Progress = func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, p)
+	}
+
+	result, err := runExport(t, queryAPI, config)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2025-08-20/000001.SZ")
+	assert.Equal(t, 2, result.Exported)
+	require.Len(t, result.Failures, 1)
+	assert.Contains(t, result.Failures[0].Err.Error(), "query timeout")
+	assert.Len(t, progress, 3)
+
+	dir := filepath.Join(config.OutputDir, "2025-08-20")
+	manifest := loadManifest(t, dir)
+	require.Len(t, manifest.Files, 2, "失败的股票不写入清单")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasSuffix(entry.Name(), ".tmp"), "不留下临时文件: %s", entry.Name())
+	}
+
+	// 修复后续跑只导出失败的股票
+	queryAPI.fail["000001.SZ"] = false
+	result, err = runExport(t, queryAPI, config)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Exported: 1, Skipped: 2, Rows: 1}, result)
+}
+
+func TestRun_BoundedConcurrency(t *testing.T) {
+	queryAPI := newFakeQueryAPI()
+	queryAPI.delay = 20 * time.Millisecond
+	config := testConfig(t)
+	config.Concurrency = 3
+	config.EndDate = "2025-08-22"
+	for i := 0; i < 10; i++ {
+		config.Symbols = append(config.Symbols, fmt.Sprintf("6001%02d.SH", i))
+	}
+
+	result, err := runExport(t, queryAPI, config)
+	require.NoError(t, err)
+	assert.Equal(t, 13*3, result.Exported)
+	assert.Equal(t, int32(3), queryAPI.maxConc.Load())
+	for _, day := range []string{"2025-08-20", "2025-08-21", "2025-08-22"} {
+		assert.Len(t, loadManifest(t, filepath.Join(config.OutputDir, day)).Files, 13)
+	}
+}
+
+func TestRun_CanceledWritesManifestForCompletedFiles(t *testing.T) {
+	queryAPI := newFakeQueryAPI()
+	config := testConfig(t)
+	config.Concurrency = 1
+	dates, err := config.Dates(time.Now())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	config.Progress = func(p Progress) {
+		if p.Done == 1 {
+			cancel()
+		}
+	}
+	_, err = Run(ctx, queryAPI, []string(config.Symbols), dates, config)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	manifest := loadManifest(t, filepath.Join(config.OutputDir, "2025-08-20"))
+	require.Len(t, manifest.Files, 1)
+	assert.Equal(t, "600000.SH", manifest.Files[0].Symbol)
+}
+
+func TestRun_UncompressedCSVMatchesSerializer(t *testing.T) {
+	config := testConfig(t)
+	config.Symbols = SymbolList{"000001.SZ"}
+	config.Compression = CompressionNone
+
+	_, err := runExport(t, newFakeQueryAPI(), config)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(config.OutputDir, "2025-08-20", "000001.SZ.csv"))
+	require.NoError(t, err)
+	assert.True(t, bytes.Contains(data, []byte("平安银行")))
+	assert.True(t, bytes.Contains(data, []byte("-0.05")))
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Pinger 支持 PING 的 Redis 客户端，*redis.Client 满足该接口
+type Pinger interface {
+	Ping(ctx context.Context) *redis.StatusCmd
+}
+
+// RedisCheck 检查 Redis 是否可以 PING 通
+func RedisCheck(client Pinger) Check {
+	return func(ctx context.Context) error {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("redis ping failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// RunningCheck 检查组件是否处于运行状态，例如调度器
+func RunningCheck(component string, running func() bool) Check {
+	return func(ctx context.Context) error {
+		if !running() {
+			return fmt.Errorf("%s not running", component)
+		}
+		return nil
+	}
+}
+
+// StalenessCheck 检查最近一次活动距今不超过 maxAge，last 返回零值表示尚无活动
+func StalenessCheck(last func() time.Time, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		at := last()
+		if at.IsZero() {
+			return errors.New("no activity yet")
+		}
+		if age := time.Since(at); age > maxAge {
+			return fmt.Errorf("last activity %s ago exceeds %s", age.Truncate(time.Second), maxAge)
+		}
+		return nil
+	}
+}
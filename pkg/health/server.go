@@ -0,0 +1,153 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCheckTimeout 单项检查的默认超时时间
+const DefaultCheckTimeout = 2 * time.Second
+
+// Check 单项健康检查，返回 nil 表示通过
+type Check func(ctx context.Context) error
+
+// namedCheck 带名称的检查项
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Response /healthz 和 /readyz 的响应内容
+type Response struct {
+	Status string            `json:"status"`           // ok 或 unavailable
+	Checks map[string]string `json:"checks,omitempty"` // 各检查项的结果，通过时为 ok
+}
+
+// Server 进程内的健康检查 HTTP 服务。
+//
+// GET /healthz 运行存活检查，供 livenessProbe 判断进程是否卡住；
+// GET /readyz 在 SetReady(true) 之后运行就绪检查，供 readinessProbe 判断初始连接是否建立。
+type Server struct {
+	port         int
+	checkTimeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+	ready     atomic.Bool
+
+	srv *http.Server
+}
+
+// NewServer 创建健康检查服务，port 为 0 时 Start 不监听任何端口
+func NewServer(port int) *Server {
+	return &Server{port: port, checkTimeout: DefaultCheckTimeout}
+}
+
+// SetCheckTimeout 设置单项检查的超时时间
+func (s *Server) SetCheckTimeout(timeout time.Duration) {
+	s.checkTimeout = timeout
+}
+
+// AddLivenessCheck 添加 /healthz 的检查项
+func (s *Server) AddLivenessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.liveness = append(s.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck 添加 /readyz 的检查项
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readiness = append(s.readiness, namedCheck{name: name, check: check})
+}
+
+// SetReady 标记初始化是否完成，完成前 /readyz 始终返回 503
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// Handler 返回健康检查的 HTTP 处理器
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		checks := s.liveness
+		s.mu.RUnlock()
+		s.respond(w, r, checks, nil)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		checks := s.readiness
+		s.mu.RUnlock()
+		var notReady error
+		if !s.ready.Load() {
+			notReady = errors.New("initializing")
+		}
+		s.respond(w, r, checks, notReady)
+	})
+	return mux
+}
+
+// respond 运行检查项并写出结果，任一检查失败时返回 503
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, checks []namedCheck, notReady error) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	resp := Response{Status: "ok", Checks: make(map[string]string, len(checks)+1)}
+	if notReady != nil {
+		resp.Status = "unavailable"
+		resp.Checks["startup"] = notReady.Error()
+	}
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), s.checkTimeout)
+		err := c.check(ctx)
+		cancel()
+		if err != nil {
+			resp.Status = "unavailable"
+			resp.Checks[c.name] = err.Error()
+		} else {
+			resp.Checks[c.name] = "ok"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// Start 在配置的端口上开始监听，port 为 0 时直接返回
+func (s *Server) Start() error {
+	if s.port == 0 {
+		return nil
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+	if err != nil {
+		return fmt.Errorf("health server listen on port %d: %w", s.port, err)
+	}
+	s.srv = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		_ = s.srv.Serve(listener)
+	}()
+	return nil
+}
+
+// Shutdown 停止监听，未启动时直接返回
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// get 请求健康检查接口，返回状态码和解析后的响应
+func get(t *testing.T, s *Server, path string) (int, Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var resp Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestServer_Healthz(t *testing.T) {
+	s := NewServer(0)
+	running := true
+	s.AddLivenessCheck("scheduler", RunningCheck("scheduler", func() bool { return running }))
+
+	code, resp := get(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Response{Status: "ok", Checks: map[string]string{"scheduler": "ok"}}, resp)
+
+	running = false
+	code, resp = get(t, s, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", resp.Status)
+	assert.Equal(t, "scheduler not running", resp.Checks["scheduler"])
+}
+
+func TestServer_ReadyzGatesOnStartup(t *testing.T) {
+	s := NewServer(0)
+	var connErr error
+	s.AddReadinessCheck("redis", func(ctx context.Context) error { return connErr })
+
+	code, resp := get(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "initializing", resp.Checks["startup"])
+
+	s.SetReady(true)
+	code, _ = get(t, s, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	connErr = errors.New("connection refused")
+	code, resp = get(t, s, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", resp.Checks["redis"])
+
+	// 存活检查不受就绪状态影响
+	code, _ = get(t, s, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestServer_CheckTimeout(t *testing.T) {
+	s := NewServer(0)
+	s.SetCheckTimeout(10 * time.Millisecond)
+	s.AddLivenessCheck("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, resp := get(t, s, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), resp.Checks["slow"])
+}
+
+func TestServer_RejectsNonGet(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer(0).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestServer_PortZeroDisabled(t *testing.T) {
+	s := NewServer(0)
+	require.NoError(t, s.Start())
+	assert.Nil(t, s.srv)
+	assert.NoError(t, s.Shutdown(context.Background()))
+}
+
+func TestRedisCheck(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	check := RedisCheck(client)
+	assert.NoError(t, check(context.Background()))
+
+	mr.Close()
+	assert.ErrorContains(t, check(context.Background()), "redis ping failed")
+}
+
+func TestStalenessCheck(t *testing.T) {
+	var last time.Time
+	check := StalenessCheck(func() time.Time { return last }, time.Minute)
+
+	assert.EqualError(t, check(context.Background()), "no activity yet")
+
+	last = time.Now().Add(-30 * time.Second)
+	assert.NoError(t, check(context.Background()))
+
+	last = time.Now().Add(-2 * time.Minute)
+	assert.ErrorContains(t, check(context.Background()), "exceeds 1m0s")
+}
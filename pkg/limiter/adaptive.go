@@ -0,0 +1,131 @@
+package limiter
+
+import "time"
+
+// AdaptiveConfig 自适应采集间隔配置
+type AdaptiveConfig struct {
+	BaseInterval    time.Duration // 正常采集间隔
+	MaxInterval     time.Duration // 退避后的最大间隔
+	Multiplier      float64       // 每次检测到冻结数据时间隔的放大倍数
+	FrozenThreshold int           // 连续相同响应达到该次数视为冻结数据
+	WindowSize      int           // 保留的最近响应数量
+}
+
+// DefaultAdaptiveConfig 返回以 baseInterval 为基础的默认自适应配置
+func DefaultAdaptiveConfig(baseInterval time.Duration) AdaptiveConfig {
+	return AdaptiveConfig{
+		BaseInterval:    baseInterval,
+		MaxInterval:     20 * baseInterval,
+		Multiplier:      2,
+		FrozenThreshold: 5,
+		WindowSize:      20,
+	}
+}
+
+// LimiterStats 自适应限流统计
+type LimiterStats struct {
+	FrozenDetections int64         // 检测到冻结数据的次数
+	BackoffLevel     int           // 当前退避级别，0 表示未退避
+	CurrentInterval  time.Duration // 当前有效采集间隔
+	TotalWaits       int64         // 重试等待及放大间隔的累计次数
+	TotalWaitTime    time.Duration // 超出正常间隔的累计等待时长
+}
+
+// SetAdaptiveConfig 设置自适应间隔配置并重置退避状态
+func (l *IntelligentLimiter) SetAdaptiveConfig(config AdaptiveConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if config.Multiplier <= 1 {
+		config.Multiplier = 2
+	}
+	if config.FrozenThreshold < 2 {
+		config.FrozenThreshold = 2
+	}
+	if config.WindowSize < config.FrozenThreshold {
+		config.WindowSize = config.FrozenThreshold
+	}
+	if config.MaxInterval < config.BaseInterval {
+		config.MaxInterval = config.BaseInterval
+	}
+	l.adaptive = config
+	l.resetAdaptive()
+}
+
+// GetCurrentInterval 返回当前有效的采集间隔
+func (l *IntelligentLimiter) GetCurrentInterval() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.currentInterval()
+}
+
+// GetLimiterStats 返回自适应限流统计
+func (l *IntelligentLimiter) GetLimiterStats() LimiterStats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	stats := l.stats
+	stats.BackoffLevel = l.backoffLevel
+	stats.CurrentInterval = l.currentInterval()
+	return stats
+}
+
+// currentInterval 按退避级别计算间隔（需要持有锁）
+func (l *IntelligentLimiter) currentInterval() time.Duration {
+	interval := float64(l.adaptive.BaseInterval)
+	for i := 0; i < l.backoffLevel; i++ {
+		interval *= l.adaptive.Multiplier
+		if interval >= float64(l.adaptive.MaxInterval) {
+			return l.adaptive.MaxInterval
+		}
+	}
+	return time.Duration(interval)
+}
+
+// observeResponse 记录一次成功响应，交易时段内连续相同的响应放大间隔，数据恢复变化后逐级缩回（需要持有锁）
+func (l *IntelligentLimiter) observeResponse(fingerprint string) {
+	fresh := len(l.window) == 0 || l.window[len(l.window)-1] != fingerprint
+
+	l.window = append(l.window, fingerprint)
+	if len(l.window) > l.adaptive.WindowSize {
+		l.window = l.window[len(l.window)-l.adaptive.WindowSize:]
+	}
+
+	if fresh {
+		if l.backoffLevel > 0 {
+			l.backoffLevel--
+		}
+	} else if l.marketTime.IsTradingTime() && trailingRun(l.window) >= l.adaptive.FrozenThreshold {
+		// 腾讯限流时会重复返回相同的价格和成交量
+		l.stats.FrozenDetections++
+		if l.currentInterval() < l.adaptive.MaxInterval {
+			l.backoffLevel++
+		}
+		// 只保留最后一条，再连续出现 FrozenThreshold 次相同数据才继续放大
+		l.window = l.window[len(l.window)-1:]
+	}
+
+	if extra := l.currentInterval() - l.adaptive.BaseInterval; extra > 0 {
+		l.stats.TotalWaits++
+		l.stats.TotalWaitTime += extra
+	}
+}
+
+// resetAdaptive 清空响应窗口和退避状态（需要持有锁）
+func (l *IntelligentLimiter) resetAdaptive() {
+	l.window = nil
+	l.backoffLevel = 0
+}
+
+// trailingRun 返回窗口末尾连续相同响应的数量
+func trailingRun(window []string) int {
+	if len(window) == 0 {
+		return 0
+	}
+	last := window[len(window)-1]
+	run := 0
+	for i := len(window) - 1; i >= 0 && window[i] == last; i-- {
+		run++
+	}
+	return run
+}
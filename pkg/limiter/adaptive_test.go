@@ -0,0 +1,137 @@
+package limiter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/timing"
+)
+
+// fixedTime 返回固定时间的时间服务
+type fixedTime struct {
+	now time.Time
+}
+
+func (f *fixedTime) Now() time.Time { return f.now }
+
+func newAdaptiveLimiter(t *testing.T, now time.Time) *IntelligentLimiter {
+	t.Helper()
+	l := NewIntelligentLimiter(timing.NewMarketTime(&fixedTime{now: now}))
+	l.SetAdaptiveConfig(AdaptiveConfig{
+		BaseInterval:    time.Second,
+		MaxInterval:     8 * time.Second,
+		Multiplier:      2,
+		FrozenThreshold: 3,
+		WindowSize:      10,
+	})
+	l.InitializeBatch([]string{"600000", "000001"})
+	return l
+}
+
+// feed 依次提交响应，返回每次提交后的采集间隔
+func feed(t *testing.T, l *IntelligentLimiter, responses ...string) []time.Duration {
+	t.Helper()
+	intervals := make([]time.Duration, 0, len(responses))
+	for _, r := range responses {
+		shouldContinue, wait, err := l.RecordResult(nil, []string{r})
+		require.NoError(t, err)
+		require.True(t, shouldContinue)
+		require.Zero(t, wait)
+		intervals = append(intervals, l.GetCurrentInterval())
+	}
+	return intervals
+}
+
+var tradingNow = time.Date(2025, 8, 21, 10, 0, 0, 0, time.Local)
+
+func TestAdaptive_FrozenDataStretchesInterval(t *testing.T) {
+	l := newAdaptiveLimiter(t, tradingNow)
+
+	intervals := feed(t, l, "600000,12.50,100", "600000,12.50,100", "600000,12.50,100")
+	assert.Equal(t, []time.Duration{time.Second, time.Second, 2 * time.Second}, intervals)
+
+	// 持续冻结，连同保留的最后一条凑满 3 次相同数据即再放大一级，直到上限
+	intervals = feed(t, l,
+		"600000,12.50,100", "600000,12.50,100",
+		"600000,12.50,100", "600000,12.50,100",
+		"600000,12.50,100", "600000,12.50,100",
+		"600000,12.50,100", "600000,12.50,100")
+	assert.Equal(t, 4*time.Second, intervals[1])
+	assert.Equal(t, 8*time.Second, intervals[3])
+	assert.Equal(t, 8*time.Second, intervals[7], "不应超过 MaxInterval")
+
+	stats := l.GetLimiterStats()
+	assert.Equal(t, int64(5), stats.FrozenDetections)
+	assert.Equal(t, 3, stats.BackoffLevel)
+	assert.Equal(t, 8*time.Second, stats.CurrentInterval)
+}
+
+func TestAdaptive_FreshDataRecovers(t *testing.T) {
+	l := newAdaptiveLimiter(t, tradingNow)
+
+	frozen := make([]string, 5)
+	for i := range frozen {
+		frozen[i] = "600000,12.50,100"
+	}
+	intervals := feed(t, l, frozen...)
+	require.Equal(t, 4*time.Second, intervals[len(intervals)-1])
+
+	// 数据恢复变化后逐级缩回
+	intervals = feed(t, l, "600000,12.51,120", "600000,12.52,130", "600000,12.53,150")
+	assert.Equal(t, []time.Duration{2 * time.Second, time.Second, time.Second}, intervals)
+
+	stats := l.GetLimiterStats()
+	assert.Equal(t, 0, stats.BackoffLevel)
+	assert.Equal(t, int64(2), stats.FrozenDetections)
+	assert.Equal(t, int64(4), stats.TotalWaits, "放大间隔期间的每轮都计入等待")
+	assert.Equal(t, 6*time.Second, stats.TotalWaitTime)
+}
+
+func TestAdaptive_VolumeChangeIsFresh(t *testing.T) {
+	l := newAdaptiveLimiter(t, tradingNow)
+
+	// 价格不变但成交量变化不是冻结数据
+	responses := make([]string, 10)
+	for i := range responses {
+		responses[i] = fmt.Sprintf("600000,12.50,%d", 100+i)
+	}
+	intervals := feed(t, l, responses...)
+	assert.Equal(t, time.Second, intervals[len(intervals)-1])
+	assert.Zero(t, l.GetLimiterStats().FrozenDetections)
+}
+
+func TestAdaptive_IgnoresIdenticalDataOutsideTradingHours(t *testing.T) {
+	lunch := time.Date(2025, 8, 21, 12, 0, 0, 0, time.Local)
+	l := newAdaptiveLimiter(t, lunch)
+
+	intervals := feed(t, l, "600000,12.50,100", "600000,12.50,100", "600000,12.50,100", "600000,12.50,100")
+	assert.Equal(t, time.Second, intervals[len(intervals)-1], "休市期间数据不变是正常现象")
+	assert.Zero(t, l.GetLimiterStats().FrozenDetections)
+}
+
+func TestAdaptive_InitializeBatchResetsBackoff(t *testing.T) {
+	l := newAdaptiveLimiter(t, tradingNow)
+	feed(t, l, "a", "a", "a")
+	require.Equal(t, 2*time.Second, l.GetCurrentInterval())
+
+	l.InitializeBatch([]string{"600519"})
+	assert.Equal(t, time.Second, l.GetCurrentInterval())
+	assert.Equal(t, int64(1), l.GetLimiterStats().FrozenDetections, "统计在批次之间累计")
+}
+
+func TestAdaptive_NetworkRetryCountsAsWait(t *testing.T) {
+	l := newAdaptiveLimiter(t, tradingNow)
+
+	shouldContinue, wait, err := l.RecordResult(fmt.Errorf("i/o timeout"), nil)
+	require.NoError(t, err)
+	assert.False(t, shouldContinue)
+	assert.Equal(t, RetryBase1, wait)
+
+	stats := l.GetLimiterStats()
+	assert.Equal(t, int64(1), stats.TotalWaits)
+	assert.Equal(t, RetryBase1, stats.TotalWaitTime)
+}
@@ -3,6 +3,8 @@ package limiter
 import (
 	"strings"
 	"time"
+
+	apperrors "stocksub/pkg/error"
 )
 
 // ErrorLevel 定义错误的严重级别
@@ -32,12 +34,21 @@ func NewErrorClassifier() *ErrorClassifier {
 	return &ErrorClassifier{}
 }
 
-// Classify 根据错误内容分类错误级别
+// Classify 根据错误代码分类错误级别，没有代码的错误按错误内容分类
 func (c *ErrorClassifier) Classify(err error) ErrorLevel {
 	if err == nil {
 		return LevelUnknown
 	}
 
+	switch apperrors.Code(err) {
+	case apperrors.CodeMarketClosed:
+		return LevelFatal
+	case apperrors.CodeNetworkTimeout, apperrors.CodeUpstreamThrottled, apperrors.CodeRateLimited:
+		return LevelNetwork
+	case apperrors.CodeParseFailure, apperrors.CodeSymbolNotFound, apperrors.CodeChecksumMismatch:
+		return LevelInvalid
+	}
+
 	msg := strings.ToLower(err.Error())
 
 	// 致命级错误 - 立即终止
@@ -2,10 +2,13 @@ package limiter
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	apperrors "stocksub/pkg/error"
 )
 
 func TestErrorClassification(t *testing.T) {
@@ -32,6 +35,12 @@ func TestErrorClassification(t *testing.T) {
 		{"请求错误", errors.New("HTTP/1.1 400 Bad Request"), LevelInvalid},
 		{"未找到", errors.New("HTTP/1.1 404 Not Found"), LevelInvalid},
 
+		// 错误代码优先于错误内容
+		{"休市", apperrors.NewError(apperrors.CodeMarketClosed, "交易时段已结束"), LevelFatal},
+		{"请求超时", fmt.Errorf("fetch: %w", apperrors.Wrap(apperrors.CodeNetworkTimeout, errors.New("dial tcp: i/o timeout"))), LevelNetwork},
+		{"上游限流", apperrors.Wrap(apperrors.CodeUpstreamThrottled, errors.New("HTTP status error: 403")), LevelNetwork},
+		{"解析失败", apperrors.Wrap(apperrors.CodeParseFailure, errors.New("decode response failed")), LevelInvalid},
+
 		// 未知错误测试
 		{"nil错误", nil, LevelUnknown},
 		{"其他错误", errors.New("some other error"), LevelUnknown},
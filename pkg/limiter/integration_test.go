@@ -5,6 +5,7 @@ package limiter_test
 import (
 	"context"
 	"errors"
+	apperrors "stocksub/pkg/error"
 	"stocksub/pkg/limiter"
 	"stocksub/pkg/timing"
 	"testing"
@@ -95,6 +96,7 @@ func TestIntelligentLimiter_ShouldProceed_WithTimingScenarios(t *testing.T) {
 				assert.NoError(t, err, "正常交易时间不应该有错误")
 			} else {
 				assert.False(t, shouldProceed, scenario.description)
+				assert.True(t, apperrors.Is(err, apperrors.CodeMarketClosed), "非交易时间返回 MarketClosed 错误")
 			}
 		})
 	}
@@ -307,3 +309,27 @@ func TestIntelligentLimiter_CircuitBreaker_WithFatalAndRetryableErrors(t *testin
 		assert.Equal(t, 1, status["retry_count"], "重试计数应增加")
 	})
 }
+
+// TestIntelligentLimiter_TimeUntilNextSession 测试非交易时段的等待时长计算
+func TestIntelligentLimiter_TimeUntilNextSession(t *testing.T) {
+	location := time.FixedZone("CST", 8*3600)
+
+	tests := []struct {
+		name     string
+		mockTime string
+		expected time.Duration
+	}{
+		{"交易时段内无需等待", "2025-08-21 10:00:00", 0},
+		{"午间休市等待下午开盘", "2025-08-21 12:00:00", 57*time.Minute + 30*time.Second},
+		{"国庆休市等待节后开盘", "2025-10-07 15:00:00", 42*time.Hour + 13*time.Minute + 30*time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTime, _ := time.ParseInLocation("2006-01-02 15:04:05", tt.mockTime, location)
+			intelligentLimiter := limiter.NewIntelligentLimiter(timing.NewMarketTime(&MockTimeService{current: mockTime}))
+
+			assert.Equal(t, tt.expected, intelligentLimiter.TimeUntilNextSession())
+		})
+	}
+}
@@ -3,7 +3,11 @@ package limiter
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	apperrors "stocksub/pkg/error"
 	"stocksub/pkg/timing"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -28,8 +32,17 @@ type IntelligentLimiter struct {
 
 	// 安全开关
 	forceStopFlag bool // 强制停止标志
+
+	// 自适应采集间隔
+	adaptive     AdaptiveConfig
+	window       []string // 当前批次最近的响应指纹
+	backoffLevel int      // 冻结数据退避级别
+	stats        LimiterStats
 }
 
+// DefaultBaseInterval 未设置自适应配置时的正常采集间隔
+const DefaultBaseInterval = 3 * time.Second
+
 // NewIntelligentLimiter 创建新的智能熔断器
 func NewIntelligentLimiter(marketTime *timing.MarketTime) *IntelligentLimiter {
 	return &IntelligentLimiter{
@@ -39,6 +52,7 @@ func NewIntelligentLimiter(marketTime *timing.MarketTime) *IntelligentLimiter {
 		lastData:        "",
 		currentBatch:    []string{},
 		isInitialized:   false,
+		adaptive:        DefaultAdaptiveConfig(DefaultBaseInterval),
 	}
 }
 
@@ -55,11 +69,12 @@ func (l *IntelligentLimiter) InitializeBatch(symbols []string) {
 	l.isInitialized = true
 	l.forceStopFlag = false
 	l.tradingEnd = l.marketTime.GetTradingEndTime()
+	l.resetAdaptive()
 
 	// 预检查时间有效性
 	if !l.marketTime.IsTradingTime() {
 		l.forceStopFlag = true
-		l.lastError = errors.New("当前不在交易时段内，自动停止")
+		l.lastError = apperrors.NewError(apperrors.CodeMarketClosed, "当前不在交易时段内，自动停止")
 	}
 }
 
@@ -79,7 +94,7 @@ func (l *IntelligentLimiter) ShouldProceed(ctx context.Context) (bool, error) {
 
 	// 交易时间检查
 	if !l.marketTime.IsTradingTime() {
-		return false, errors.New("交易时段已结束，停止监控")
+		return false, apperrors.NewError(apperrors.CodeMarketClosed, "交易时段已结束，停止监控")
 	}
 
 	// 重试次数检查
@@ -110,6 +125,7 @@ func (l *IntelligentLimiter) RecordResult(err error, data []string) (
 		// 始终记录数据指纹，但只在收盘后才检查一致性
 		if len(data) > 0 {
 			dataFingerprint := l.generateDataFingerprint(data)
+			l.observeResponse(dataFingerprint)
 
 			// 收盘后检查数据一致性（避免数据延迟问题）
 			if l.marketTime.IsAfterTradingEnd() {
@@ -117,7 +133,7 @@ func (l *IntelligentLimiter) RecordResult(err error, data []string) (
 					l.consecutiveSame++
 					if l.consecutiveSame >= 5 {
 						// 数据已稳定5次，可以终止
-						return false, 0, errors.New("收盘后数据已稳定，终止收集")
+						return false, 0, apperrors.NewError(apperrors.CodeMarketClosed, "收盘后数据已稳定，终止收集")
 					}
 				} else {
 					l.consecutiveSame = 1
@@ -147,55 +163,48 @@ func (l *IntelligentLimiter) RecordResult(err error, data []string) (
 	switch level {
 	case LevelFatal: // 致命级错误
 		l.forceStopFlag = true
-		return false, 0, errors.New("致命错误: " + err.Error())
+		return false, 0, fmt.Errorf("致命错误: %w", err)
 
 	case LevelNetwork: // 网络错误，进行重试
 		shouldRetry, waitDuration := l.classifier.GetRetryStrategy(level, l.retryCount)
 
 		if !shouldRetry {
-			return false, 0, errors.New("网络错误重试次数耗尽: " + err.Error())
+			return false, 0, fmt.Errorf("网络错误重试次数耗尽: %w", err)
 		}
 
 		// 检查重试时间是否在有效范围内
 		nextRetryTime := l.marketTime.Now().Add(waitDuration)
 		if !l.classifier.IsRetryAllowedInTime(nextRetryTime, l.tradingEnd) {
-			return false, 0, errors.New("重试时间超出交易时段，终止操作")
+			return false, 0, apperrors.NewError(apperrors.CodeMarketClosed, "重试时间超出交易时段，终止操作")
 		}
 
 		l.retryCount++
+		l.stats.TotalWaits++
+		l.stats.TotalWaitTime += waitDuration
 		return false, waitDuration, nil
 
 	case LevelInvalid, LevelUnknown:
 		// 无效参数或未知错误，不重试
-		return false, 0, errors.New("不可重试错误: " + err.Error())
+		return false, 0, fmt.Errorf("不可重试错误: %w", err)
 
 	default:
-		return false, 0, errors.New("未知错误类型: " + err.Error())
+		return false, 0, fmt.Errorf("未知错误类型: %w", err)
 	}
 }
 
-// generateDataFingerprint 为数据生成简单的指纹标识
+// generateDataFingerprint 为数据生成指纹标识
+// 基于完整内容计算，价格或成交量的任何变化都会产生不同的指纹
 func (l *IntelligentLimiter) generateDataFingerprint(data []string) string {
-	// 简单的数据指纹生成 - 基于字符串内容
 	if len(data) == 0 {
 		return "empty"
 	}
 
-	// 这里使用字符串拼接作为简单指纹
-	// 在生产环境中可以改进为更复杂的校验
-	fingerprint := ""
+	h := fnv.New64a()
 	for _, item := range data {
-		if len(item) > 5 {
-			fingerprint += item[:5] // 取前5个字符
-		} else {
-			fingerprint += item
-		}
-	}
-
-	if len(fingerprint) > 50 {
-		return fingerprint[:50]
+		h.Write([]byte(item))
+		h.Write([]byte{0})
 	}
-	return fingerprint
+	return strconv.FormatUint(h.Sum64(), 16)
 }
 
 // GetStatus 获取熔断器当前状态
@@ -216,7 +225,19 @@ func (l *IntelligentLimiter) GetStatus() map[string]interface{} {
 		"is_after_trading_end": l.marketTime.IsAfterTradingEnd(),
 		"total_requests":       l.totalRequests,
 		"estimated_end":        l.marketTime.GetTradingEndTime(),
+		"next_session_start":   l.marketTime.NextTradingSessionStart(l.marketTime.Now()),
+	}
+}
+
+// TimeUntilNextSession 返回距离下一个交易时段开始的时长，已在交易时段内时返回 0
+// 非交易时段可据此休眠到开盘，节假日和周末一并跳过
+func (l *IntelligentLimiter) TimeUntilNextSession() time.Duration {
+	now := l.marketTime.Now()
+	wait := l.marketTime.NextTradingSessionStart(now).Sub(now)
+	if wait < 0 {
+		return 0
 	}
+	return wait
 }
 
 // IsSafeToContinue 检查是否安全继续
@@ -243,4 +264,6 @@ func (l *IntelligentLimiter) Reset() {
 	l.forceStopFlag = false
 	l.totalRequests = 0
 	l.totalErrors = 0
+	l.stats = LimiterStats{}
+	l.resetAdaptive()
 }
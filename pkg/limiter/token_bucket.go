@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶限流器，每个 interval 补充 requests 个令牌，最多积累 burst 个。
+//
+// Wait 按调用顺序预约令牌：令牌不足时桶内令牌数变为负数，后来的调用者排在前面的之后，
+// 多个使用者共享同一个桶时总请求速率不会超过配置。
+type TokenBucket struct {
+	mu       sync.Mutex
+	burst    float64
+	rate     float64 // 每纳秒补充的令牌数
+	tokens   float64
+	last     time.Time
+	waiters  int
+	requests int
+	interval time.Duration
+}
+
+// NewTokenBucket 创建令牌桶，初始为满；requests、burst 不大于 0 时按 1 处理
+func NewTokenBucket(requests int, interval time.Duration, burst int) *TokenBucket {
+	if requests <= 0 {
+		requests = 1
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	return &TokenBucket{
+		burst:    float64(burst),
+		rate:     float64(requests) / float64(interval),
+		tokens:   float64(burst),
+		last:     time.Now(),
+		requests: requests,
+		interval: interval,
+	}
+}
+
+// refill 按经过的时间补充令牌，调用方持有 mu
+func (b *TokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+float64(elapsed)*b.rate)
+	}
+	b.last = now
+}
+
+// Wait 等待获得一个令牌，ctx 取消时归还预约的令牌并返回错误
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.refill(time.Now())
+	b.tokens--
+	if b.tokens >= 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	delay := time.Duration(-b.tokens / b.rate)
+	b.waiters++
+	b.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		b.mu.Lock()
+		b.waiters--
+		b.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.waiters--
+		b.refill(time.Now())
+		b.tokens = math.Min(b.burst, b.tokens+1)
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Available 返回当前可立即使用的令牌数，有调用者排队时为 0
+func (b *TokenBucket) Available() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return math.Max(0, b.tokens)
+}
+
+// Waiters 返回正在等待令牌的调用者数
+func (b *TokenBucket) Waiters() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.waiters
+}
+
+// Burst 返回令牌桶容量
+func (b *TokenBucket) Burst() int {
+	return int(b.burst)
+}
+
+// Rate 返回补充速率：每个 interval 补充的令牌数
+func (b *TokenBucket) Rate() (requests int, interval time.Duration) {
+	return b.requests, b.interval
+}
@@ -0,0 +1,66 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket_BurstThenSpaced(t *testing.T) {
+	bucket := NewTokenBucket(1, 20*time.Millisecond, 3)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bucket.Wait(ctx))
+	}
+	assert.Less(t, time.Since(start), 10*time.Millisecond, "桶内令牌立即可用")
+	assert.Less(t, bucket.Available(), 1.0)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bucket.Wait(ctx))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 55*time.Millisecond, "之后每 20ms 一个令牌")
+}
+
+func TestTokenBucket_ConcurrentWaitersReserveInOrder(t *testing.T) {
+	bucket := NewTokenBucket(2, 40*time.Millisecond, 1)
+	require.NoError(t, bucket.Wait(context.Background()))
+
+	var mu sync.Mutex
+	var times []time.Time
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, bucket.Wait(context.Background()))
+			mu.Lock()
+			times = append(times, time.Now())
+			mu.Unlock()
+		}()
+	}
+	require.Eventually(t, func() bool { return bucket.Waiters() == 4 }, time.Second, time.Millisecond)
+	wg.Wait()
+
+	// 每 20ms 一个令牌，4 个等待者依次获得
+	require.Len(t, times, 4)
+	assert.GreaterOrEqual(t, times[3].Sub(start), 75*time.Millisecond)
+	assert.Equal(t, 0, bucket.Waiters())
+}
+
+func TestTokenBucket_CanceledWaitReturnsToken(t *testing.T) {
+	bucket := NewTokenBucket(1, time.Hour, 1)
+	require.NoError(t, bucket.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bucket.Wait(ctx), context.DeadlineExceeded)
+	assert.Equal(t, 0, bucket.Waiters())
+	assert.InDelta(t, 0, bucket.Available(), 0.01, "取消的预约归还令牌，不会让后来者多等一个周期")
+}
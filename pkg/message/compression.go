@@ -0,0 +1,140 @@
+package message
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// payload 编码方式，写入 Header.Encoding
+const (
+	EncodingNone = "none"
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// ErrUnsupportedEncoding 未注册的 payload 编码方式
+var ErrUnsupportedEncoding = errors.New("不支持的消息编码")
+
+// PayloadCodec payload 压缩编解码器
+type PayloadCodec interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	// codecs 已注册的编解码器；zstd 需要由引入 zstd 库的程序通过 RegisterCodec 注册
+	codecs = map[string]PayloadCodec{
+		EncodingGzip: gzipCodec{},
+	}
+)
+
+// RegisterCodec 注册或替换指定编码方式的编解码器
+func RegisterCodec(encoding string, codec PayloadCodec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[encoding] = codec
+}
+
+// ValidateEncoding 检查编码方式是否可用，空字符串等同于 none
+func ValidateEncoding(encoding string) error {
+	if isUncompressed(encoding) {
+		return nil
+	}
+	_, err := lookupCodec(encoding)
+	return err
+}
+
+func isUncompressed(encoding string) bool {
+	return encoding == "" || encoding == EncodingNone
+}
+
+func lookupCodec(encoding string) (PayloadCodec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[encoding]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, encoding)
+	}
+	return codec, nil
+}
+
+// ToCompressedJSON 按指定编码压缩 payload 并序列化消息
+// 压缩后的 payload 以 base64 字符串（[]byte 的 JSON 编码）写出；校验和基于未压缩的 payload 计算，
+// 解压后 Validate() 仍然有效。encoding 为空或 none 时与 ToJSON 相同。
+func (m *MessageFormat) ToCompressedJSON(encoding string) (string, error) {
+	if isUncompressed(encoding) {
+		return m.ToJSON()
+	}
+
+	codec, err := lookupCodec(encoding)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(m.Payload)
+	if err != nil {
+		return "", err
+	}
+	compressed, err := codec.Compress(payload)
+	if err != nil {
+		return "", fmt.Errorf("压缩 payload 失败: %w", err)
+	}
+
+	out := MessageFormat{Header: m.Header, Metadata: m.Metadata, Payload: m.Payload}
+	out.Header.Encoding = encoding
+	out.Checksum = out.CalculateChecksum()
+	out.Payload = compressed
+	return out.ToJSON()
+}
+
+// decodePayload 按 Header.Encoding 还原未压缩的 payload JSON
+func decodePayload(encoding string, raw json.RawMessage) (json.RawMessage, error) {
+	if isUncompressed(encoding) {
+		return raw, nil
+	}
+
+	codec, err := lookupCodec(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed []byte
+	if err := json.Unmarshal(raw, &compressed); err != nil {
+		return nil, fmt.Errorf("%w: 压缩的 payload 不是 base64 字符串: %v", ErrInvalidFormat, err)
+	}
+	payload, err := codec.Decompress(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解压 payload 失败: %v", ErrInvalidFormat, err)
+	}
+	return payload, nil
+}
+
+// gzipCodec 基于标准库的 gzip 编解码器
+type gzipCodec struct{}
+
+func (gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
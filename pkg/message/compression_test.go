@@ -0,0 +1,147 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// generateStocks 生成 n 条实时行情数据
+func generateStocks(n int) []StockData {
+	stocks := make([]StockData, n)
+	for i := range stocks {
+		stocks[i] = StockData{
+			Symbol:        fmt.Sprintf("%06d", 600000+i),
+			Name:          "测试股票",
+			Price:         10 + float64(i%100)/10,
+			Change:        0.15,
+			ChangePercent: 1.45,
+			Volume:        int64(1000000 + i),
+			Timestamp:     "2025-08-20T10:00:00Z",
+		}
+	}
+	return stocks
+}
+
+func TestToCompressedJSON_RoundTrip(t *testing.T) {
+	stocks := generateStocks(200)
+	msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", stocks)
+	plain, err := msg.ToJSON()
+	require.NoError(t, err)
+
+	for _, encoding := range []string{"", EncodingNone, EncodingGzip} {
+		t.Run("encoding="+encoding, func(t *testing.T) {
+			data, err := msg.ToCompressedJSON(encoding)
+			require.NoError(t, err)
+			if encoding == EncodingGzip {
+				assert.Less(t, len(data), len(plain)/2, "gzip should shrink repetitive payloads")
+			} else {
+				assert.Equal(t, plain, data)
+			}
+
+			parsed, err := ParseMessage([]byte(data))
+			require.NoError(t, err)
+			assert.Equal(t, stocks, parsed.Payload)
+			assert.Equal(t, msg.Header.MessageID, parsed.Header.MessageID)
+
+			generic, err := FromJSON(data)
+			require.NoError(t, err)
+			assert.NoError(t, generic.Validate(), "checksum covers the uncompressed payload")
+			assert.Len(t, generic.Payload, len(stocks))
+		})
+	}
+
+	assert.Empty(t, msg.Header.Encoding, "compressing does not modify the original message")
+}
+
+func TestToCompressedJSON_UnsupportedEncoding(t *testing.T) {
+	msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", generateStocks(1))
+
+	_, err := msg.ToCompressedJSON(EncodingZstd)
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+	assert.ErrorIs(t, ValidateEncoding("brotli"), ErrUnsupportedEncoding)
+	assert.NoError(t, ValidateEncoding(""))
+	assert.NoError(t, ValidateEncoding(EncodingGzip))
+}
+
+func TestParseMessage_CorruptCompressedPayload(t *testing.T) {
+	msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", generateStocks(3))
+	data, err := msg.ToCompressedJSON(EncodingGzip)
+	require.NoError(t, err)
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &raw))
+	raw["payload"] = "bm90IGd6aXA=" // "not gzip"
+	corrupt, err := json.Marshal(raw)
+	require.NoError(t, err)
+
+	_, err = ParseMessage(corrupt)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+
+	header := raw["header"].(map[string]interface{})
+	header["encoding"] = "brotli"
+	unknown, err := json.Marshal(raw)
+	require.NoError(t, err)
+	_, err = FromJSON(string(unknown))
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+}
+
+// reverseCodec 测试用编解码器，用于验证 RegisterCodec
+type reverseCodec struct{}
+
+func (reverseCodec) Compress(data []byte) ([]byte, error) { return reverse(data), nil }
+
+func (reverseCodec) Decompress(data []byte) ([]byte, error) { return reverse(data), nil }
+
+func reverse(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return out
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("reverse", reverseCodec{})
+	t.Cleanup(func() {
+		codecsMu.Lock()
+		delete(codecs, "reverse")
+		codecsMu.Unlock()
+	})
+
+	msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", generateStocks(2))
+	data, err := msg.ToCompressedJSON("reverse")
+	require.NoError(t, err)
+
+	parsed, err := ParseMessage([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "reverse", parsed.Header.Encoding)
+	assert.Equal(t, msg.Payload, parsed.Payload)
+}
+
+// BenchmarkMessageEncoding 比较不同编码在 100/1000/5000 只股票下的消息大小和往返耗时
+// 运行: go test ./pkg/message -bench MessageEncoding -benchmem
+func BenchmarkMessageEncoding(b *testing.B) {
+	for _, n := range []int{100, 1000, 5000} {
+		msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", generateStocks(n))
+		for _, encoding := range []string{EncodingNone, EncodingGzip} {
+			b.Run(fmt.Sprintf("symbols=%d/encoding=%s", n, encoding), func(b *testing.B) {
+				var size int
+				for i := 0; i < b.N; i++ {
+					data, err := msg.ToCompressedJSON(encoding)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := ParseMessage([]byte(data)); err != nil {
+						b.Fatal(err)
+					}
+					size = len(data)
+				}
+				b.ReportMetric(float64(size), "bytes/msg")
+			})
+		}
+	}
+}
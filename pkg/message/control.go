@@ -0,0 +1,57 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FetchControlStream 按需刷新请求的 Redis Stream，由 api_server 写入、fetcher 消费
+const FetchControlStream = "stream:control:fetch"
+
+// FetchRequest 要求 fetcher 在调度之外立即获取指定股票的实时行情
+type FetchRequest struct {
+	Symbols     []string `json:"symbols"`
+	RequestedBy string   `json:"requestedBy,omitempty"` // 发起请求的 API Key 名称
+	RequestedAt string   `json:"requestedAt"`           // RFC3339 格式
+}
+
+// NewFetchRequest 创建按需刷新请求
+func NewFetchRequest(symbols []string, requestedBy string) *FetchRequest {
+	return &FetchRequest{
+		Symbols:     symbols,
+		RequestedBy: requestedBy,
+		RequestedAt: time.Now().Format(time.RFC3339),
+	}
+}
+
+// ToValues 转换为 XADD 的字段，请求 JSON 放在 data 字段
+func (r *FetchRequest) ToValues() (map[string]interface{}, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fetch request: %w", err)
+	}
+	return map[string]interface{}{"data": string(data)}, nil
+}
+
+// ParseFetchRequest 解析 Stream 条目中的按需刷新请求
+func ParseFetchRequest(values map[string]interface{}) (*FetchRequest, error) {
+	data, ok := values["data"].(string)
+	if !ok {
+		return nil, fmt.Errorf("fetch request has no data field")
+	}
+	var req FetchRequest
+	if err := json.Unmarshal([]byte(data), &req); err != nil {
+		return nil, fmt.Errorf("invalid fetch request: %w", err)
+	}
+	if len(req.Symbols) == 0 {
+		return nil, fmt.Errorf("fetch request has no symbols")
+	}
+	return &req, nil
+}
+
+// RequestTime 返回请求时间，无法解析时返回零值
+func (r *FetchRequest) RequestTime() time.Time {
+	t, _ := time.Parse(time.RFC3339, r.RequestedAt)
+	return t
+}
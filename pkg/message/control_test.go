@@ -0,0 +1,32 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchRequest_RoundTrip(t *testing.T) {
+	req := NewFetchRequest([]string{"600000.SH"}, "support")
+	values, err := req.ToValues()
+	require.NoError(t, err)
+
+	parsed, err := ParseFetchRequest(values)
+	require.NoError(t, err)
+	assert.Equal(t, req, parsed)
+	assert.False(t, parsed.RequestTime().IsZero())
+}
+
+func TestParseFetchRequest_Invalid(t *testing.T) {
+	for name, values := range map[string]map[string]interface{}{
+		"没有 data 字段": {"symbols": "600000"},
+		"无效的 JSON":   {"data": "{"},
+		"没有代码":       {"data": `{"symbols": []}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseFetchRequest(values)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// IdempotencyStore 记录已处理的消息，用于跨重启、多实例的幂等处理
+type IdempotencyStore interface {
+	// Seen 返回消息是否已处理过
+	Seen(ctx context.Context, id string) (bool, error)
+	// Mark 标记消息已处理，在 TTL 内有效
+	Mark(ctx context.Context, id string) error
+}
+
+// DedupeKey 返回消息的去重键：优先使用生产者生成的消息 ID，其次使用校验和
+func (m *MessageFormat) DedupeKey() string {
+	if m.Header.MessageID != "" {
+		return m.Header.MessageID
+	}
+	return m.Checksum
+}
+
+// redisKV RedisIdempotencyStore 用到的命令，*redis.Client 满足该接口
+type redisKV interface {
+	Exists(ctx context.Context, keys ...string) *redis.IntCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+}
+
+// RedisIdempotencyStore 基于 Redis 键（SET ... EX）的去重存储
+type RedisIdempotencyStore struct {
+	client    redisKV
+	keyPrefix string
+	ttl       time.Duration
+}
+
+// NewRedisIdempotencyStore 创建 Redis 去重存储，键为 keyPrefix + id
+func NewRedisIdempotencyStore(client redisKV, keyPrefix string, ttl time.Duration) *RedisIdempotencyStore {
+	if keyPrefix == "" {
+		keyPrefix = "dedupe:"
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &RedisIdempotencyStore{client: client, keyPrefix: keyPrefix, ttl: ttl}
+}
+
+// Seen 实现 IdempotencyStore 接口
+func (s *RedisIdempotencyStore) Seen(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.keyPrefix+id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check dedupe key %s: %w", id, err)
+	}
+	return n > 0, nil
+}
+
+// Mark 实现 IdempotencyStore 接口
+func (s *RedisIdempotencyStore) Mark(ctx context.Context, id string) error {
+	if err := s.client.Set(ctx, s.keyPrefix+id, 1, s.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set dedupe key %s: %w", id, err)
+	}
+	return nil
+}
+
+// MemoryIdempotencyStore 进程内去重存储，适用于测试和单实例部署
+type MemoryIdempotencyStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+// memorySweepInterval 清理过期条目的最小间隔
+const memorySweepInterval = time.Minute
+
+// NewMemoryIdempotencyStore 创建进程内去重存储
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &MemoryIdempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// Seen 实现 IdempotencyStore 接口
+func (s *MemoryIdempotencyStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.entries[id]
+	if !ok {
+		return false, nil
+	}
+	if !s.now().Before(expiresAt) {
+		delete(s.entries, id)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Mark 实现 IdempotencyStore 接口，写入时定期清理过期条目
+func (s *MemoryIdempotencyStore) Mark(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= memorySweepInterval {
+		for k, expiresAt := range s.entries {
+			if !now.Before(expiresAt) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.entries[id] = now.Add(s.ttl)
+	return nil
+}
+
+// Len 返回未过期的条目数
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	n := 0
+	for _, expiresAt := range s.entries {
+		if now.Before(expiresAt) {
+			n++
+		}
+	}
+	return n
+}
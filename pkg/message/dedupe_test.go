@@ -0,0 +1,125 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedisKV 记录写入的键和过期时间
+type fakeRedisKV struct {
+	keys map[string]time.Duration
+	err  error
+}
+
+func (f *fakeRedisKV) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	var n int64
+	for _, k := range keys {
+		if _, ok := f.keys[k]; ok {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, f.err)
+}
+
+func (f *fakeRedisKV) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	if f.err == nil {
+		f.keys[key] = expiration
+	}
+	return redis.NewStatusResult("OK", f.err)
+}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeRedisKV{keys: make(map[string]time.Duration)}
+	store := NewRedisIdempotencyStore(kv, "dedupe:redis_collector:", time.Hour)
+
+	seen, err := store.Seen(ctx, "msg-1")
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	require.NoError(t, store.Mark(ctx, "msg-1"))
+	assert.Equal(t, time.Hour, kv.keys["dedupe:redis_collector:msg-1"])
+
+	seen, err = store.Seen(ctx, "msg-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	// 新实例（例如重启后）共享同一份状态
+	seen, err = NewRedisIdempotencyStore(kv, "dedupe:redis_collector:", time.Hour).Seen(ctx, "msg-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	seen, err = NewRedisIdempotencyStore(kv, "dedupe:influxdb_collector:", time.Hour).Seen(ctx, "msg-1")
+	require.NoError(t, err)
+	assert.False(t, seen, "different prefixes must not share state")
+}
+
+func TestRedisIdempotencyStore_Errors(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeRedisKV{keys: make(map[string]time.Duration), err: errors.New("connection refused")}
+	store := NewRedisIdempotencyStore(kv, "", 0)
+
+	_, err := store.Seen(ctx, "msg-1")
+	assert.Error(t, err)
+	assert.Error(t, store.Mark(ctx, "msg-1"))
+}
+
+func TestMemoryIdempotencyStore_Expiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	store := NewMemoryIdempotencyStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	require.NoError(t, store.Mark(ctx, "msg-1"))
+	seen, err := store.Seen(ctx, "msg-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, store.Mark(ctx, "msg-2"))
+	assert.Equal(t, 2, store.Len())
+
+	now = now.Add(31 * time.Minute)
+	seen, err = store.Seen(ctx, "msg-1")
+	require.NoError(t, err)
+	assert.False(t, seen)
+	assert.Equal(t, 1, store.Len())
+
+	now = now.Add(time.Hour)
+	require.NoError(t, store.Mark(ctx, "msg-3"))
+	assert.Len(t, store.entries, 1, "expired entries are swept on write")
+}
+
+func TestMemoryIdempotencyStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryIdempotencyStore(time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = store.Seen(ctx, "msg")
+				_ = store.Mark(ctx, "msg")
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestMessageFormat_DedupeKey(t *testing.T) {
+	msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", []StockData{{Symbol: "600000"}})
+	assert.Equal(t, msg.Header.MessageID, msg.DedupeKey())
+
+	msg.Header.MessageID = ""
+	assert.Equal(t, msg.Checksum, msg.DedupeKey())
+}
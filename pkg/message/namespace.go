@@ -0,0 +1,56 @@
+package message
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// namespacePattern 命名空间只允许小写字母、数字、下划线和连字符，不能包含冒号以免与键的分隔符混淆
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ValidateNamespace 检查命名空间，空字符串为默认命名空间
+func ValidateNamespace(namespace string) error {
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("invalid namespace %q: must match %s", namespace, namespacePattern)
+	}
+	return nil
+}
+
+// NamespaceKey 返回命名空间下的 Redis 键或键前缀，如 staging:latest:；默认命名空间原样返回
+func NamespaceKey(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return namespace + ":" + key
+}
+
+// NamespaceFluxFilter 返回按命名空间筛选 InfluxDB 数据点的 Flux 条件。默认命名空间写入的点不带 namespace 标签，
+// 因此匹配 not exists r.namespace，避免读到共用 bucket 中其他命名空间的序列
+func NamespaceFluxFilter(namespace string) string {
+	if namespace == "" {
+		return "not exists r.namespace"
+	}
+	return fmt.Sprintf("r.namespace == %q", namespace)
+}
+
+// NamespaceStream 返回命名空间下的 Stream 名称，stream:stock:realtime 变为 stream:<ns>:stock:realtime；
+// 默认命名空间原样返回
+func NamespaceStream(namespace, stream string) string {
+	if namespace == "" {
+		return stream
+	}
+	if rest, ok := strings.CutPrefix(stream, "stream:"); ok {
+		return "stream:" + namespace + ":" + rest
+	}
+	return namespace + ":" + stream
+}
+
+// NamespaceStreams 对每个 Stream 名称应用 NamespaceStream，返回新的切片
+func NamespaceStreams(namespace string, streams []string) []string {
+	namespaced := make([]string, len(streams))
+	for i, stream := range streams {
+		namespaced[i] = NamespaceStream(namespace, stream)
+	}
+	return namespaced
+}
@@ -0,0 +1,34 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	for _, ns := range []string{"", "staging", "eu-1", "tenant_a"} {
+		assert.NoError(t, ValidateNamespace(ns), ns)
+	}
+	for _, ns := range []string{"Staging", "a:b", "-x", "_x", "with space", "abcdefghijklmnopqrstuvwxyz0123456"} {
+		assert.Error(t, ValidateNamespace(ns), ns)
+	}
+}
+
+func TestNamespaceKeysAndStreams(t *testing.T) {
+	// 默认命名空间不改变任何名称
+	assert.Equal(t, "latest:", NamespaceKey("", "latest:"))
+	assert.Equal(t, "stream:control:fetch", NamespaceStream("", FetchControlStream))
+	assert.Equal(t, []string{"stream:stock:realtime", "stream:stock:eod"}, NamespaceStreams("", []string{"stream:stock:realtime", "stream:stock:eod"}))
+
+	assert.Equal(t, "staging:latest:", NamespaceKey("staging", "latest:"))
+	assert.Equal(t, "stream:staging:control:fetch", NamespaceStream("staging", FetchControlStream))
+	assert.Equal(t, "stream:staging:deadletter:", NamespaceStream("staging", "stream:deadletter:"))
+	assert.Equal(t, "staging:custom", NamespaceStream("staging", "custom"))
+	assert.Equal(t, []string{"stream:staging:stock:realtime", "stream:staging:stock:eod"}, NamespaceStreams("staging", []string{"stream:stock:realtime", "stream:stock:eod"}))
+}
+
+func TestNamespaceFluxFilter(t *testing.T) {
+	assert.Equal(t, "not exists r.namespace", NamespaceFluxFilter(""), "默认命名空间排除带标签的序列")
+	assert.Equal(t, `r.namespace == "staging"`, NamespaceFluxFilter("staging"))
+}
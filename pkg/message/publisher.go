@@ -0,0 +1,589 @@
+package message
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 发送队列错误
+var (
+	ErrPublisherFull   = errors.New("发送队列已满")
+	ErrPublisherClosed = errors.New("发送队列已关闭")
+)
+
+// streamAdder Publisher 用到的 Redis 命令，*redis.Client 满足该接口
+type streamAdder interface {
+	XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd
+}
+
+// PublisherConfig 发送队列配置
+type PublisherConfig struct {
+	QueueSize      int           // 内存队列容量，超过后写入溢出文件
+	SpillPath      string        // 溢出文件（JSON lines），为空时队列满后 Publish 返回 ErrPublisherFull
+	InitialBackoff time.Duration // XADD 失败后的首次重试间隔，之后按次数翻倍
+	MaxBackoff     time.Duration // 重试间隔上限
+	WriteTimeout   time.Duration // 单次 XADD 的超时时间
+}
+
+// DefaultPublisherConfig 返回默认的发送队列配置，不写溢出文件
+func DefaultPublisherConfig() PublisherConfig {
+	return PublisherConfig{
+		QueueSize:      10000,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		WriteTimeout:   5 * time.Second,
+	}
+}
+
+// PublisherStats 发送队列的状态和累计计数
+type PublisherStats struct {
+	QueueDepth int   `json:"queue_depth"` // 内存队列中等待发送的消息数
+	SpillDepth int   `json:"spill_depth"` // 溢出文件中等待重放的消息数
+	Published  int64 `json:"published"`   // XADD 成功的消息数，含重放的消息
+	Retries    int64 `json:"retries"`     // XADD 失败后重试的次数
+	Spilled    int64 `json:"spilled"`     // 写入溢出文件的消息数
+	Replayed   int64 `json:"replayed"`    // 从溢出文件重放成功的消息数
+	Rejected   int64 `json:"rejected"`    // 队列已满且没有溢出文件时拒绝的消息数
+	Corrupted  int64 `json:"corrupted"`   // 溢出文件中无法解析而跳过的行数
+}
+
+// spillEntry 发送队列和溢出文件中的一条消息
+type spillEntry struct {
+	Stream string `json:"stream"`
+	Data   string `json:"data"`
+}
+
+// Publisher 带缓冲和重试的 Redis Stream 发布器。Publish 把消息加入内存队列后立即返回，
+// 后台协程按加入顺序逐条 XADD，失败时按指数退避重试同一条消息，因此各 Stream 内的顺序不变。
+// 内存队列满后消息追加到溢出文件，内存队列发送完后把溢出文件改名为 <SpillPath>.replay 并按顺序重放。
+// Close 时未发送的消息写回溢出文件，下次启动后重放；重放中途退出可能导致少量消息重复发送，消费端按消息去重
+type Publisher struct {
+	client streamAdder
+	config PublisherConfig
+
+	mu           sync.Mutex
+	queue        []spillEntry
+	maxLen       map[string]int64 // 各 Stream 的 MAXLEN ~ N
+	spillPending int              // 溢出文件中的消息数，包括正在写入的
+	replay       *spillReader     // 正在重放的文件，没有时为 nil
+	closed       bool
+	stats        PublisherStats
+
+	// spillMu 保护溢出文件的写入，需要同时持有时先获取 mu。enqueue 在释放 mu 之前获取 spillMu，
+	// 写文件时不阻塞其他 Publish 和 Stats，写入顺序仍与入队顺序一致
+	spillMu     sync.Mutex
+	spill       *os.File // 溢出文件的追加句柄，没有溢出时为 nil
+	spillFailed int      // 已计入 spillPending 但写入失败的消息数
+
+	wake chan struct{} // 有新消息时通知发送协程
+	stop chan struct{} // 关闭时通知发送协程退出
+	done chan struct{} // 发送协程已退出
+}
+
+// NewPublisher 创建发布器并启动发送协程；溢出文件中有上次未发送的消息时先重放这些消息
+func NewPublisher(client streamAdder, config PublisherConfig) (*Publisher, error) {
+	defaults := DefaultPublisherConfig()
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaults.QueueSize
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = defaults.InitialBackoff
+	}
+	if config.MaxBackoff < config.InitialBackoff {
+		config.MaxBackoff = max(defaults.MaxBackoff, config.InitialBackoff)
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaults.WriteTimeout
+	}
+
+	p := &Publisher{
+		client: client,
+		config: config,
+		maxLen: make(map[string]int64),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if config.SpillPath != "" {
+		// 上次重放中途退出时 .replay 中的消息比溢出文件更早，合并后整体重放
+		pending, corrupted, err := compactSpill(config.SpillPath, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.spillPending = pending
+		p.stats.Corrupted = int64(corrupted)
+	}
+
+	go p.run()
+	return p, nil
+}
+
+// SetStreamLimits 设置各 Stream 发布时的近似长度上限，0 表示不裁剪
+func (p *Publisher) SetStreamLimits(limits map[string]int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxLen = make(map[string]int64, len(limits))
+	for stream, n := range limits {
+		if n > 0 {
+			p.maxLen[stream] = n
+		}
+	}
+}
+
+// Publish 把消息加入 stream 的发送队列，payload 不压缩
+func (p *Publisher) Publish(ctx context.Context, stream string, msg *MessageFormat) error {
+	return p.PublishEncoded(ctx, stream, msg, EncodingNone)
+}
+
+// PublishEncoded 按 encoding 序列化消息并加入 stream 的发送队列。
+// 返回 nil 表示消息已进入内存队列或溢出文件，实际写入 Redis 由后台协程完成
+func (p *Publisher) PublishEncoded(ctx context.Context, stream string, msg *MessageFormat, encoding string) error {
+	data, err := msg.ToCompressedJSON(encoding)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return p.enqueue(spillEntry{Stream: stream, Data: data})
+}
+
+func (p *Publisher) enqueue(entry spillEntry) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPublisherClosed
+	}
+	// 溢出文件中有消息时新消息也写入溢出文件，保证重放顺序
+	if p.spillPending == 0 && len(p.queue) < p.config.QueueSize {
+		p.queue = append(p.queue, entry)
+		p.mu.Unlock()
+		p.notify()
+		return nil
+	}
+	if p.config.SpillPath == "" {
+		p.stats.Rejected++
+		p.mu.Unlock()
+		return ErrPublisherFull
+	}
+
+	// 先在 mu 内计入溢出文件，再释放 mu 写文件；写入失败时由 reconcileSpill 扣除
+	p.spillPending++
+	p.stats.Spilled++
+	p.spillMu.Lock()
+	p.mu.Unlock()
+	err := p.appendSpill(entry)
+	p.spillMu.Unlock()
+	if err != nil {
+		p.mu.Lock()
+		p.spillMu.Lock()
+		p.reconcileSpill()
+		p.spillMu.Unlock()
+		p.mu.Unlock()
+		return err
+	}
+	p.notify()
+	return nil
+}
+
+// notify 通知发送协程有新消息
+func (p *Publisher) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// appendSpill 追加一条消息到溢出文件，调用方需持有 spillMu
+func (p *Publisher) appendSpill(entry spillEntry) error {
+	line, err := json.Marshal(entry)
+	if err == nil && p.spill == nil {
+		var file *os.File
+		file, err = os.OpenFile(p.config.SpillPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			err = fmt.Errorf("打开溢出文件失败: %w", err)
+		}
+		p.spill = file
+	}
+	if err == nil {
+		if _, err = p.spill.Write(append(line, '\n')); err != nil {
+			err = fmt.Errorf("写入溢出文件失败: %w", err)
+		}
+	}
+	if err != nil {
+		p.spillFailed++
+	}
+	return err
+}
+
+// reconcileSpill 从计数中扣除写入失败的消息，调用方需同时持有 mu 和 spillMu
+func (p *Publisher) reconcileSpill() {
+	p.spillPending -= p.spillFailed
+	p.stats.Spilled -= int64(p.spillFailed)
+	p.spillFailed = 0
+}
+
+// Stats 返回当前的队列深度和累计计数
+func (p *Publisher) Stats() PublisherStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := p.stats
+	stats.QueueDepth = len(p.queue)
+	stats.SpillDepth = p.spillPending
+	if p.replay != nil {
+		stats.SpillDepth += p.replay.pending
+	}
+	return stats
+}
+
+// Close 停止接收新消息，等待队列发送完成或 ctx 结束；未发送的消息写回溢出文件，
+// 没有配置溢出文件时丢弃并返回错误
+func (p *Publisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.mu.Unlock()
+
+	// 通知发送协程在队列为空时退出；ctx 结束时强制退出，正在重试的消息留在队列中
+	p.notify()
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		close(p.stop)
+		<-p.done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// 等待正在进行的溢出文件写入完成
+	p.spillMu.Lock()
+	defer p.spillMu.Unlock()
+	p.reconcileSpill()
+	remaining := p.queue
+	p.queue = nil
+	if p.spill != nil {
+		p.spill.Close()
+		p.spill = nil
+	}
+	if p.config.SpillPath == "" {
+		if len(remaining) > 0 {
+			return fmt.Errorf("关闭时丢弃 %d 条未发送的消息", len(remaining))
+		}
+		return nil
+	}
+
+	// 溢出文件中的顺序：重放剩余部分、内存队列、溢出文件
+	pending, corrupted, err := compactSpill(p.config.SpillPath, p.replay, remaining)
+	if p.replay != nil {
+		p.replay.file.Close()
+		p.replay = nil
+	}
+	p.spillPending = pending
+	p.stats.Corrupted += int64(corrupted)
+	return err
+}
+
+// run 发送协程：依次发送重放文件、内存队列中的消息，都为空时开始重放溢出文件
+func (p *Publisher) run() {
+	defer close(p.done)
+	for {
+		entry, fromReplay, ok, err := p.next()
+		if err != nil {
+			// 读取或改名溢出文件失败时保留文件稍后再试；无法解析的行已在 peek 中跳过
+			select {
+			case <-time.After(p.config.InitialBackoff):
+			case <-p.stop:
+				return
+			}
+			continue
+		}
+		if !ok {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-p.wake:
+			case <-p.stop:
+				return
+			}
+			continue
+		}
+
+		if !p.send(entry) {
+			return
+		}
+		p.ack(fromReplay)
+	}
+}
+
+// next 返回下一条要发送的消息，不从队列中移除
+func (p *Publisher) next() (spillEntry, bool, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.replay != nil {
+		entry, ok, err := p.peekReplay()
+		if err != nil || ok {
+			return entry, true, ok, err
+		}
+		p.closeReplay(true)
+	}
+	if len(p.queue) > 0 {
+		return p.queue[0], false, true, nil
+	}
+	if p.spillPending > 0 && !p.closed {
+		if err := p.startReplay(); err != nil {
+			return spillEntry{}, false, false, err
+		}
+		if p.replay == nil {
+			return spillEntry{}, false, false, nil
+		}
+		entry, ok, err := p.peekReplay()
+		return entry, true, ok, err
+	}
+	return spillEntry{}, false, false, nil
+}
+
+// peekReplay 读取重放文件的下一条消息，并把跳过的损坏行计入统计，调用方需持有 mu
+func (p *Publisher) peekReplay() (spillEntry, bool, error) {
+	entry, ok, err := p.replay.peek()
+	p.stats.Corrupted += int64(p.replay.skipped)
+	p.replay.skipped = 0
+	return entry, ok, err
+}
+
+// startReplay 把溢出文件改名为重放文件并开始读取，之后的新消息可以进入内存队列，调用方需持有 mu。
+// 溢出文件的消息都写入失败时不开始重放，p.replay 保持为 nil
+func (p *Publisher) startReplay() error {
+	p.spillMu.Lock()
+	defer p.spillMu.Unlock()
+	p.reconcileSpill()
+	if p.spillPending == 0 {
+		return nil
+	}
+
+	if p.spill != nil {
+		p.spill.Close()
+		p.spill = nil
+	}
+	replayPath := p.config.SpillPath + ".replay"
+	if err := os.Rename(p.config.SpillPath, replayPath); err != nil {
+		return fmt.Errorf("准备重放溢出文件失败: %w", err)
+	}
+	reader, err := openSpillReader(replayPath, p.spillPending)
+	if err != nil {
+		// 改回原名，避免下次改名覆盖尚未重放的文件
+		os.Rename(replayPath, p.config.SpillPath)
+		return err
+	}
+	p.replay = reader
+	p.spillPending = 0
+	return nil
+}
+
+// closeReplay 关闭重放文件，remove 为 true 时删除文件，调用方需持有 mu
+func (p *Publisher) closeReplay(remove bool) {
+	if p.replay == nil {
+		return
+	}
+	p.replay.file.Close()
+	if remove {
+		os.Remove(p.replay.file.Name())
+	}
+	p.replay = nil
+}
+
+// send 发送一条消息直到成功；收到 stop 时返回 false，消息留在队列中
+func (p *Publisher) send(entry spillEntry) bool {
+	backoff := p.config.InitialBackoff
+	for {
+		p.mu.Lock()
+		maxLen := p.maxLen[entry.Stream]
+		p.mu.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), p.config.WriteTimeout)
+		err := p.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: entry.Stream,
+			MaxLen: maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"data": entry.Data},
+		}).Err()
+		cancel()
+		if err == nil {
+			return true
+		}
+
+		p.mu.Lock()
+		p.stats.Retries++
+		p.mu.Unlock()
+		select {
+		case <-time.After(backoff):
+		case <-p.stop:
+			return false
+		}
+		backoff = min(backoff*2, p.config.MaxBackoff)
+	}
+}
+
+// ack 从队列中移除已发送的消息
+func (p *Publisher) ack(fromReplay bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.Published++
+	if fromReplay {
+		p.replay.advance()
+		p.stats.Replayed++
+		return
+	}
+	p.queue = p.queue[1:]
+}
+
+// spillReader 逐行读取重放文件，peek 的消息在 advance 之前保持不变
+type spillReader struct {
+	file    *os.File
+	reader  *bufio.Reader
+	current *spillEntry
+	pending int // 未发送的消息数
+	skipped int // 无法解析而跳过的行数，由调用方取走后清零
+}
+
+func openSpillReader(path string, pending int) (*spillReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开重放文件失败: %w", err)
+	}
+	return &spillReader{file: file, reader: bufio.NewReader(file), pending: pending}, nil
+}
+
+func (r *spillReader) peek() (spillEntry, bool, error) {
+	if r.current != nil {
+		return *r.current, true, nil
+	}
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var entry spillEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				// 只跳过损坏的一行，继续读取后面的消息
+				r.skipped++
+				if r.pending > 0 {
+					r.pending--
+				}
+				continue
+			}
+			r.current = &entry
+			return entry, true, nil
+		}
+		// 没有换行符的最后一行是写入中途退出留下的，忽略
+		if errors.Is(err, io.EOF) {
+			return spillEntry{}, false, nil
+		}
+		if err != nil {
+			return spillEntry{}, false, err
+		}
+	}
+}
+
+func (r *spillReader) advance() {
+	r.current = nil
+	if r.pending > 0 {
+		r.pending--
+	}
+}
+
+// remaining 返回重放文件中未发送的消息，包括已 peek 但未发送的一条
+func (r *spillReader) remaining() ([]spillEntry, error) {
+	var entries []spillEntry
+	for {
+		entry, ok, err := r.peek()
+		if err != nil || !ok {
+			return entries, err
+		}
+		entries = append(entries, entry)
+		r.current = nil
+	}
+}
+
+// compactSpill 按 重放文件剩余部分（replay 为 nil 时读取 <path>.replay）、queued、溢出文件 的顺序
+// 重写溢出文件并删除重放文件，返回溢出文件中的消息数和跳过的损坏行数
+func compactSpill(path string, replay *spillReader, queued []spillEntry) (int, int, error) {
+	replayPath := path + ".replay"
+	var entries []spillEntry
+	corrupted := 0
+	if replay != nil {
+		rest, err := replay.remaining()
+		corrupted += replay.skipped
+		if err != nil {
+			return 0, corrupted, err
+		}
+		entries = append(entries, rest...)
+	} else if reader, err := openSpillReader(replayPath, 0); err == nil {
+		rest, err := reader.remaining()
+		reader.file.Close()
+		corrupted += reader.skipped
+		if err != nil {
+			return 0, corrupted, err
+		}
+		entries = append(entries, rest...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, corrupted, err
+	}
+	entries = append(entries, queued...)
+
+	if reader, err := openSpillReader(path, 0); err == nil {
+		rest, err := reader.remaining()
+		reader.file.Close()
+		corrupted += reader.skipped
+		if err != nil {
+			return 0, corrupted, err
+		}
+		entries = append(entries, rest...)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, corrupted, err
+	}
+
+	if len(entries) == 0 {
+		os.Remove(path)
+		os.Remove(replayPath)
+		return 0, corrupted, nil
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return 0, corrupted, fmt.Errorf("写入溢出文件失败: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return 0, corrupted, err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return 0, corrupted, fmt.Errorf("写入溢出文件失败: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, corrupted, fmt.Errorf("写入溢出文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, corrupted, fmt.Errorf("写入溢出文件失败: %w", err)
+	}
+	os.Remove(replayPath)
+	return len(entries), corrupted, nil
+}
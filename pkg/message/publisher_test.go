@@ -0,0 +1,210 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPublisherStream = "stream:stock:realtime"
+
+func newTestPublisherClient(t *testing.T, mr *miniredis.Miniredis) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:        mr.Addr(),
+		MaxRetries:  -1,
+		DialTimeout: 100 * time.Millisecond,
+	})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func testPublisherConfig(t *testing.T, queueSize int) PublisherConfig {
+	return PublisherConfig{
+		QueueSize:      queueSize,
+		SpillPath:      filepath.Join(t.TempDir(), "spill.jsonl"),
+		InitialBackoff: 5 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+		WriteTimeout:   100 * time.Millisecond,
+	}
+}
+
+// publishSeq 发布 payload 为连续编号的消息
+func publishSeq(t *testing.T, p *Publisher, from, to int) {
+	for i := from; i < to; i++ {
+		msg := NewMessageFormat("test", "test", "stock_realtime", []StockData{{Symbol: fmt.Sprintf("%06d", i)}})
+		require.NoError(t, p.Publish(context.Background(), testPublisherStream, msg))
+	}
+}
+
+// streamSeq 按 Stream 中的顺序返回消息编号
+func streamSeq(t *testing.T, mr *miniredis.Miniredis) []string {
+	entries, err := mr.Stream(testPublisherStream)
+	require.NoError(t, err)
+	symbols := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		require.Equal(t, "data", entry.Values[0])
+		var msg struct {
+			Payload []StockData `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(entry.Values[1]), &msg))
+		symbols = append(symbols, msg.Payload[0].Symbol)
+	}
+	return symbols
+}
+
+func expectedSeq(n int) []string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("%06d", i)
+	}
+	return symbols
+}
+
+func waitPublished(t *testing.T, p *Publisher, n int64) {
+	require.Eventually(t, func() bool { return p.Stats().Published == n }, 5*time.Second, 5*time.Millisecond,
+		"stats: %+v", p.Stats())
+}
+
+func TestPublisher_RetriesAndReplaysAcrossRedisRestart(t *testing.T) {
+	mr := miniredis.RunT(t)
+	p, err := NewPublisher(newTestPublisherClient(t, mr), testPublisherConfig(t, 5))
+	require.NoError(t, err)
+
+	publishSeq(t, p, 0, 10)
+	waitPublished(t, p, 10)
+
+	// Redis 不可用期间内存队列只能容纳 5 条，其余写入溢出文件
+	mr.Close()
+	publishSeq(t, p, 10, 40)
+	require.Eventually(t, func() bool { return p.Stats().Retries > 0 }, time.Second, 5*time.Millisecond)
+	stats := p.Stats()
+	assert.Equal(t, 5, stats.QueueDepth)
+	assert.Equal(t, 25, stats.SpillDepth)
+	assert.GreaterOrEqual(t, stats.Spilled, int64(25))
+
+	require.NoError(t, mr.Restart())
+	// 重放期间的新消息排在溢出消息之后
+	publishSeq(t, p, 40, 50)
+	waitPublished(t, p, 50)
+
+	assert.Equal(t, expectedSeq(50), streamSeq(t, mr), "没有丢失消息，顺序不变")
+	stats = p.Stats()
+	assert.Equal(t, 0, stats.QueueDepth)
+	assert.Equal(t, 0, stats.SpillDepth)
+	assert.GreaterOrEqual(t, stats.Replayed, int64(25))
+	require.NoError(t, p.Close(context.Background()))
+	assert.NoFileExists(t, p.config.SpillPath)
+}
+
+func TestPublisher_CloseSpillsPendingAndNextPublisherReplays(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := testPublisherConfig(t, 4)
+	p, err := NewPublisher(newTestPublisherClient(t, mr), config)
+	require.NoError(t, err)
+
+	publishSeq(t, p, 0, 3)
+	waitPublished(t, p, 3)
+	mr.Close()
+	publishSeq(t, p, 3, 12)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.NoError(t, p.Close(ctx))
+	assert.Equal(t, 9, p.Stats().SpillDepth)
+	assert.ErrorIs(t, p.Publish(context.Background(), testPublisherStream, &MessageFormat{}), ErrPublisherClosed)
+
+	require.NoError(t, mr.Restart())
+	next, err := NewPublisher(newTestPublisherClient(t, mr), config)
+	require.NoError(t, err)
+	publishSeq(t, next, 12, 15)
+	waitPublished(t, next, 12)
+
+	assert.Equal(t, expectedSeq(15), streamSeq(t, mr))
+	require.NoError(t, next.Close(context.Background()))
+}
+
+func TestPublisher_ReplaySkipsCorruptedLine(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := testPublisherConfig(t, 1)
+	p, err := NewPublisher(newTestPublisherClient(t, mr), config)
+	require.NoError(t, err)
+
+	mr.Close()
+	publishSeq(t, p, 0, 5)
+	require.Eventually(t, func() bool { return p.Stats().SpillDepth == 4 }, time.Second, 5*time.Millisecond)
+
+	// 在溢出文件中间写入一行无法解析的内容
+	file, err := os.OpenFile(config.SpillPath, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString("{not json}\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	publishSeq(t, p, 5, 8)
+
+	require.NoError(t, mr.Restart())
+	waitPublished(t, p, 8)
+	assert.Equal(t, expectedSeq(8), streamSeq(t, mr), "损坏行前后的消息都被重放")
+	assert.Equal(t, int64(1), p.Stats().Corrupted)
+	require.NoError(t, p.Close(context.Background()))
+}
+
+func TestPublisher_StartupSkipsCorruptedSpillLines(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := testPublisherConfig(t, 10)
+	lines := []string{
+		`{"stream":"stream:stock:realtime","data":"a"}`,
+		`garbage`,
+		`{"stream":"stream:stock:realtime","data":"b"}`,
+	}
+	require.NoError(t, os.WriteFile(config.SpillPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644))
+
+	p, err := NewPublisher(newTestPublisherClient(t, mr), config)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), p.Stats().Corrupted)
+	waitPublished(t, p, 2)
+	require.NoError(t, p.Close(context.Background()))
+}
+
+func TestPublisher_RejectsWhenFullWithoutSpill(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := newTestPublisherClient(t, mr)
+	mr.Close()
+	config := testPublisherConfig(t, 2)
+	config.SpillPath = ""
+	p, err := NewPublisher(client, config)
+	require.NoError(t, err)
+
+	publishSeq(t, p, 0, 2)
+	msg := NewMessageFormat("test", "test", "stock_realtime", []StockData{{Symbol: "000002"}})
+	assert.ErrorIs(t, p.Publish(context.Background(), testPublisherStream, msg), ErrPublisherFull)
+	assert.Equal(t, int64(1), p.Stats().Rejected)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorContains(t, p.Close(ctx), "丢弃 2 条")
+}
+
+func TestPublisher_AppliesStreamLimits(t *testing.T) {
+	mr := miniredis.RunT(t)
+	p, err := NewPublisher(newTestPublisherClient(t, mr), testPublisherConfig(t, 100))
+	require.NoError(t, err)
+	p.SetStreamLimits(map[string]int64{testPublisherStream: 5})
+
+	publishSeq(t, p, 0, 20)
+	waitPublished(t, p, 20)
+	require.NoError(t, p.Close(context.Background()))
+
+	entries, err := mr.Stream(testPublisherStream)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(entries), 5)
+}
@@ -1,13 +1,18 @@
 package message
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
 )
 
 // 错误定义
@@ -23,6 +28,10 @@ type MessageHeader struct {
 	Version     string `json:"version"`
 	Producer    string `json:"producer"`
 	ContentType string `json:"contentType"`
+	Encoding    string `json:"encoding,omitempty"` // payload 编码方式，为空表示 none
+	// CorrelationID 生产者为一次任务执行生成的追踪 ID，同一次执行发布的消息相同，
+	// 收集器写入 Redis 哈希的 trace_id 字段和 InfluxDB，api_server 通过 X-Data-Trace-ID 返回
+	CorrelationID string `json:"correlationId,omitempty"`
 }
 
 // MessageMetadata 消息元数据
@@ -50,7 +59,117 @@ type StockData struct {
 	Change        float64 `json:"change"`
 	ChangePercent float64 `json:"changePercent"`
 	Volume        int64   `json:"volume"`
+	Turnover      float64 `json:"turnover"`
 	Timestamp     string  `json:"timestamp"`
+
+	// 5 档买卖盘，下标 0 为买一/卖一；提供商没有盘口数据时为空
+	BidPrices  []float64 `json:"bidPrices,omitempty"`
+	BidVolumes []int64   `json:"bidVolumes,omitempty"`
+	AskPrices  []float64 `json:"askPrices,omitempty"`
+	AskVolumes []int64   `json:"askVolumes,omitempty"`
+}
+
+// DepthLevels 买卖盘档数
+const DepthLevels = 5
+
+// HasDepth 是否带有完整的 5 档买卖盘
+func (s StockData) HasDepth() bool {
+	return len(s.BidPrices) == DepthLevels && len(s.BidVolumes) == DepthLevels &&
+		len(s.AskPrices) == DepthLevels && len(s.AskVolumes) == DepthLevels
+}
+
+// SetOrderBook 复制 core.StockData 的 5 档买卖盘，提供商没有返回盘口数据（买一、卖一均为 0）时保持为空
+func (s *StockData) SetOrderBook(stock core.StockData) {
+	if stock.BidPrice1 == 0 && stock.AskPrice1 == 0 {
+		return
+	}
+	s.BidPrices = []float64{stock.BidPrice1, stock.BidPrice2, stock.BidPrice3, stock.BidPrice4, stock.BidPrice5}
+	s.BidVolumes = []int64{stock.BidVolume1, stock.BidVolume2, stock.BidVolume3, stock.BidVolume4, stock.BidVolume5}
+	s.AskPrices = []float64{stock.AskPrice1, stock.AskPrice2, stock.AskPrice3, stock.AskPrice4, stock.AskPrice5}
+	s.AskVolumes = []int64{stock.AskVolume1, stock.AskVolume2, stock.AskVolume3, stock.AskVolume4, stock.AskVolume5}
+}
+
+// NewStockData 把 core.StockData 转换为消息格式，包含 5 档买卖盘
+func NewStockData(stock core.StockData) StockData {
+	data := StockData{
+		Symbol:        stock.Symbol,
+		Name:          stock.Name,
+		Price:         stock.Price,
+		Change:        stock.Change,
+		ChangePercent: stock.ChangePercent,
+		Volume:        stock.Volume,
+		Turnover:      stock.Turnover,
+		Timestamp:     stock.Timestamp.Format(time.RFC3339),
+	}
+	data.SetOrderBook(stock)
+	return data
+}
+
+// FieldRounder 按字段名舍入浮点值，*storage.DataSchema 按字段定义的 Precision 实现
+type FieldRounder interface {
+	RoundFloat(field string, v float64) float64
+}
+
+// Round 按 StockDataSchema 的字段名舍入价格、涨跌幅、成交额和盘口价格，消除提供商数据中的浮点误差
+func (s *StockData) Round(r FieldRounder) {
+	s.Price = r.RoundFloat("price", s.Price)
+	s.Change = r.RoundFloat("change", s.Change)
+	s.ChangePercent = r.RoundFloat("change_percent", s.ChangePercent)
+	s.Turnover = r.RoundFloat("turnover", s.Turnover)
+	for i := range s.BidPrices {
+		s.BidPrices[i] = r.RoundFloat(fmt.Sprintf("bid_price%d", i+1), s.BidPrices[i])
+	}
+	for i := range s.AskPrices {
+		s.AskPrices[i] = r.RoundFloat(fmt.Sprintf("ask_price%d", i+1), s.AskPrices[i])
+	}
+}
+
+// ToCore 转换为 core.StockData，是 SetOrderBook 的逆操作；无法解析的时间戳保持为零值
+func (s StockData) ToCore() core.StockData {
+	stock := core.StockData{
+		Symbol:        s.Symbol,
+		Name:          s.Name,
+		Price:         s.Price,
+		Change:        s.Change,
+		ChangePercent: s.ChangePercent,
+		Volume:        s.Volume,
+		Turnover:      s.Turnover,
+	}
+	stock.Timestamp, _ = time.Parse(time.RFC3339, s.Timestamp)
+	if s.HasDepth() {
+		stock.BidPrice1, stock.BidPrice2, stock.BidPrice3, stock.BidPrice4, stock.BidPrice5 = s.BidPrices[0], s.BidPrices[1], s.BidPrices[2], s.BidPrices[3], s.BidPrices[4]
+		stock.BidVolume1, stock.BidVolume2, stock.BidVolume3, stock.BidVolume4, stock.BidVolume5 = s.BidVolumes[0], s.BidVolumes[1], s.BidVolumes[2], s.BidVolumes[3], s.BidVolumes[4]
+		stock.AskPrice1, stock.AskPrice2, stock.AskPrice3, stock.AskPrice4, stock.AskPrice5 = s.AskPrices[0], s.AskPrices[1], s.AskPrices[2], s.AskPrices[3], s.AskPrices[4]
+		stock.AskVolume1, stock.AskVolume2, stock.AskVolume3, stock.AskVolume4, stock.AskVolume5 = s.AskVolumes[0], s.AskVolumes[1], s.AskVolumes[2], s.AskVolumes[3], s.AskVolumes[4]
+	}
+	return stock
+}
+
+// DepthFieldNames 买卖盘展开后的字段名，依次为 bid_price1..5、bid_volume1..5、ask_price1..5、ask_volume1..5
+func DepthFieldNames() []string {
+	names := make([]string, 0, 4*DepthLevels)
+	for _, prefix := range []string{"bid_price", "bid_volume", "ask_price", "ask_volume"} {
+		for level := 1; level <= DepthLevels; level++ {
+			names = append(names, fmt.Sprintf("%s%d", prefix, level))
+		}
+	}
+	return names
+}
+
+// DepthFields 将买卖盘展开为 bid_price1..ask_volume5 字段，供 Redis 哈希和 InfluxDB 字段使用；没有盘口数据时返回 nil
+func (s StockData) DepthFields() map[string]interface{} {
+	if !s.HasDepth() {
+		return nil
+	}
+	fields := make(map[string]interface{}, 4*DepthLevels)
+	for i := 0; i < DepthLevels; i++ {
+		level := i + 1
+		fields[fmt.Sprintf("bid_price%d", level)] = s.BidPrices[i]
+		fields[fmt.Sprintf("bid_volume%d", level)] = s.BidVolumes[i]
+		fields[fmt.Sprintf("ask_price%d", level)] = s.AskPrices[i]
+		fields[fmt.Sprintf("ask_volume%d", level)] = s.AskVolumes[i]
+	}
+	return fields
 }
 
 // IndexData 指数数据结构
@@ -60,9 +179,19 @@ type IndexData struct {
 	Value         float64 `json:"value"`
 	Change        float64 `json:"change"`
 	ChangePercent float64 `json:"changePercent"`
+	Volume        int64   `json:"volume"`
+	Turnover      float64 `json:"turnover"`
 	Timestamp     string  `json:"timestamp"`
 }
 
+// Round 舍入指数点位、涨跌幅和成交额，点位使用 price 字段的精度
+func (d *IndexData) Round(r FieldRounder) {
+	d.Value = r.RoundFloat("price", d.Value)
+	d.Change = r.RoundFloat("change", d.Change)
+	d.ChangePercent = r.RoundFloat("change_percent", d.ChangePercent)
+	d.Turnover = r.RoundFloat("turnover", d.Turnover)
+}
+
 // HistoricalDataPoint 历史数据点
 type HistoricalDataPoint struct {
 	Symbol   string    `json:"symbol"`
@@ -75,12 +204,86 @@ type HistoricalDataPoint struct {
 	Turnover float64   `json:"turnover,omitempty"`
 }
 
+// KlineData K线数据结构
+type KlineData struct {
+	Symbol    string  `json:"symbol"`
+	Period    string  `json:"period"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	Volume    int64   `json:"volume"`
+	Turnover  float64 `json:"turnover"`
+	Timestamp string  `json:"timestamp"`
+}
+
+// EODDateLayout 收盘快照交易日的格式
+const EODDateLayout = "2006-01-02"
+
+// eodLocation 交易日按北京时间划分
+var eodLocation = time.FixedZone("CST", 8*3600)
+
+// EODData 收盘快照，每个股票每个交易日一条，由 eod_snapshot 任务在收盘后发布
+type EODData struct {
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name"`
+	Date          string  `json:"date"` // 交易日，格式为 EODDateLayout（北京时间）
+	Open          float64 `json:"open"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Close         float64 `json:"close"`
+	PrevClose     float64 `json:"prevClose"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"changePercent"`
+	Volume        int64   `json:"volume"`
+	Turnover      float64 `json:"turnover"`
+	Timestamp     string  `json:"timestamp"` // 最后一笔行情的时间
+}
+
+// NewEODData 由收盘后的实时行情生成收盘快照，交易日取行情时间所在的北京时间日期，行情时间为零值时使用 now
+func NewEODData(stock core.StockData, now time.Time) EODData {
+	at := stock.Timestamp
+	if at.IsZero() {
+		at = now
+	}
+	return EODData{
+		Symbol:        stock.Symbol,
+		Name:          stock.Name,
+		Date:          EODDate(at),
+		Open:          stock.Open,
+		High:          stock.High,
+		Low:           stock.Low,
+		Close:         stock.Price,
+		PrevClose:     stock.PrevClose,
+		Change:        stock.Change,
+		ChangePercent: stock.ChangePercent,
+		Volume:        stock.Volume,
+		Turnover:      stock.Turnover,
+		Timestamp:     at.Format(time.RFC3339),
+	}
+}
+
+// EODDate 返回时间所在的交易日（北京时间），格式为 EODDateLayout
+func EODDate(t time.Time) string {
+	return t.In(eodLocation).Format(EODDateLayout)
+}
+
+// ParseEODDate 解析 EODDateLayout 格式的交易日，返回该日北京时间零点
+func ParseEODDate(date string) (time.Time, error) {
+	return time.ParseInLocation(EODDateLayout, date, eodLocation)
+}
+
+// TradingDate 解析快照的交易日，返回该日北京时间零点
+func (d EODData) TradingDate() (time.Time, error) {
+	return ParseEODDate(d.Date)
+}
+
 // NewMessageFormat 创建新的消息格式
 func NewMessageFormat(producer, provider, dataType string, payload interface{}) *MessageFormat {
 	header := MessageHeader{
 		MessageID:   uuid.New().String(),
 		Timestamp:   time.Now().Unix(),
-		Version:     "1.0",
+		Version:     CurrentVersion,
 		Producer:    producer,
 		ContentType: "application/json",
 	}
@@ -93,6 +296,10 @@ func NewMessageFormat(producer, provider, dataType string, payload interface{})
 		batchSize = len(p)
 	case []HistoricalDataPoint:
 		batchSize = len(p)
+	case []KlineData:
+		batchSize = len(p)
+	case []EODData:
+		batchSize = len(p)
 	default:
 		batchSize = 1
 	}
@@ -117,11 +324,18 @@ func NewMessageFormat(producer, provider, dataType string, payload interface{})
 
 // CalculateChecksum 计算消息校验和
 func (m *MessageFormat) CalculateChecksum() string {
+	// 消费端反序列化得到的 payload 是 map，字段顺序与生产端的结构体不同，
+	// 先规范化为按键排序的 JSON，保证两端计算结果一致
+	payload, err := canonicalPayload(m.Payload)
+	if err != nil {
+		return ""
+	}
+
 	// 创建消息副本，排除 checksum 字段
 	temp := MessageFormat{
 		Header:   m.Header,
 		Metadata: m.Metadata,
-		Payload:  m.Payload,
+		Payload:  payload,
 	}
 
 	data, err := json.Marshal(temp)
@@ -133,11 +347,27 @@ func (m *MessageFormat) CalculateChecksum() string {
 	return "sha256:" + hex.EncodeToString(hash[:])
 }
 
+// canonicalPayload 将 payload 转换为通用的 JSON 值，数字保留原始文本避免精度损失
+func canonicalPayload(payload interface{}) (interface{}, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
 // Validate 验证消息完整性
 func (m *MessageFormat) Validate() error {
 	expectedChecksum := m.CalculateChecksum()
 	if m.Checksum != expectedChecksum {
-		return ErrInvalidChecksum
+		return apperrors.Wrap(apperrors.CodeChecksumMismatch, ErrInvalidChecksum,
+			"message_id", m.Header.MessageID, "expected", expectedChecksum, "actual", m.Checksum)
 	}
 	return nil
 }
@@ -151,22 +381,50 @@ func (m *MessageFormat) ToJSON() (string, error) {
 	return string(data), nil
 }
 
-// FromJSON 从 JSON 字符串解析消息
+// FromJSON 从 JSON 字符串解析消息，payload 保持通用 JSON 值，主版本不受支持时返回 ErrUnsupportedVersion
+// 压缩的 payload 会按 Header.Encoding 自动解压；需要具体类型的 payload 时使用 ParseMessage
 func FromJSON(jsonStr string) (*MessageFormat, error) {
-	var msg MessageFormat
-	if err := json.Unmarshal([]byte(jsonStr), &msg); err != nil {
+	var raw rawMessage
+	if err := json.Unmarshal([]byte(jsonStr), &raw); err != nil {
+		return nil, err
+	}
+	if _, err := majorVersion(raw.Header.Version); err != nil {
+		return nil, err
+	}
+
+	payload, err := decodePayload(raw.Header.Encoding, raw.Payload)
+	if err != nil {
 		return nil, err
 	}
-	return &msg, nil
+
+	msg := &MessageFormat{
+		Header:   raw.Header,
+		Metadata: raw.Metadata,
+		Checksum: raw.Checksum,
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &msg.Payload); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
 }
 
-// GetStreamName 根据数据类型获取 Redis Stream 名称
-func GetStreamName(dataType string) string {
+// GetStreamName 根据数据类型获取命名空间下的 Redis Stream 名称，默认命名空间为空字符串
+func GetStreamName(dataType, namespace string) string {
+	return NamespaceStream(namespace, streamName(dataType))
+}
+
+func streamName(dataType string) string {
 	switch dataType {
 	case "stock_realtime":
 		return "stream:stock:realtime"
 	case "index_realtime":
 		return "stream:index:realtime"
+	case "stock_kline":
+		return "stream:stock:kline"
+	case "stock_eod":
+		return "stream:stock:eod"
 	case "historical":
 		return "stream:historical"
 	default:
@@ -181,3 +439,9 @@ func (m *MessageFormat) SetMarketInfo(market, tradingSession string) {
 	// 重新计算校验和
 	m.Checksum = m.CalculateChecksum()
 }
+
+// SetCorrelationID 设置追踪 ID 并重新计算校验和
+func (m *MessageFormat) SetCorrelationID(id string) {
+	m.Header.CorrelationID = id
+	m.Checksum = m.CalculateChecksum()
+}
@@ -1,11 +1,15 @@
 package message
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
 )
 
 func TestNewMessageFormat(t *testing.T) {
@@ -54,7 +58,8 @@ func TestMessageFormat_Validate(t *testing.T) {
 	msg.Checksum = "invalid-checksum"
 	err = msg.Validate()
 	assert.Error(t, err)
-	assert.Equal(t, ErrInvalidChecksum, err)
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+	assert.True(t, apperrors.Is(err, apperrors.CodeChecksumMismatch))
 
 	// 恢复校验和
 	msg.Checksum = originalChecksum
@@ -106,8 +111,7 @@ func TestMessageFormat_ToJSON_FromJSON(t *testing.T) {
 	assert.Equal(t, "浦发银行", payloadItem["name"])
 	assert.Equal(t, 10.5, payloadItem["price"])
 
-	// 由于 JSON 序列化会改变数据结构，我们需要重新计算校验和
-	parsedMsg.Checksum = parsedMsg.CalculateChecksum()
+	// 反序列化后的 payload 是 map，校验和仍然一致
 	err = parsedMsg.Validate()
 	assert.NoError(t, err)
 }
@@ -119,16 +123,21 @@ func TestGetStreamName(t *testing.T) {
 	}{
 		{"stock_realtime", "stream:stock:realtime"},
 		{"index_realtime", "stream:index:realtime"},
+		{"stock_kline", "stream:stock:kline"},
+		{"stock_eod", "stream:stock:eod"},
 		{"historical", "stream:historical"},
 		{"unknown_type", "stream:unknown"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.dataType, func(t *testing.T) {
-			streamName := GetStreamName(tt.dataType)
+			streamName := GetStreamName(tt.dataType, "")
 			assert.Equal(t, tt.expectedName, streamName)
 		})
 	}
+
+	assert.Equal(t, "stream:staging:stock:realtime", GetStreamName("stock_realtime", "staging"))
+	assert.Equal(t, "stream:staging:unknown", GetStreamName("unknown_type", "staging"))
 }
 
 func TestMessageFormat_SetMarketInfo(t *testing.T) {
@@ -153,6 +162,22 @@ func TestMessageFormat_SetMarketInfo(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestMessageFormat_SetCorrelationID(t *testing.T) {
+	msg := NewMessageFormat("test-producer", "test-provider", "stock_realtime", []StockData{{Symbol: "600000", Price: 10.0}})
+	originalChecksum := msg.Checksum
+
+	msg.SetCorrelationID("trace-1")
+	assert.NotEqual(t, originalChecksum, msg.Checksum)
+	require.NoError(t, msg.Validate())
+
+	// 追踪 ID 经过序列化和压缩后保留
+	data, err := msg.ToCompressedJSON(EncodingGzip)
+	require.NoError(t, err)
+	parsed, err := ParseMessage([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "trace-1", parsed.Header.CorrelationID)
+}
+
 func TestMessageFormat_BatchSize(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -228,6 +253,79 @@ func TestStockData_Structure(t *testing.T) {
 	assert.Equal(t, "2023-03-15T09:30:00Z", stockData.Timestamp)
 }
 
+func TestStockData_DepthFields(t *testing.T) {
+	assert.Nil(t, StockData{Symbol: "600000"}.DepthFields(), "没有盘口数据")
+	assert.Nil(t, StockData{BidPrices: []float64{10.49}}.DepthFields(), "档数不足")
+
+	stock := StockData{
+		BidPrices:  []float64{10.49, 10.48, 10.47, 10.46, 10.45},
+		BidVolumes: []int64{100, 200, 300, 400, 500},
+		AskPrices:  []float64{10.5, 10.51, 10.52, 10.53, 10.54},
+		AskVolumes: []int64{600, 700, 800, 900, 1000},
+	}
+	fields := stock.DepthFields()
+	require.Len(t, fields, 20)
+	assert.Equal(t, 10.49, fields["bid_price1"])
+	assert.Equal(t, int64(500), fields["bid_volume5"])
+	assert.Equal(t, 10.54, fields["ask_price5"])
+	assert.Equal(t, int64(600), fields["ask_volume1"])
+
+	names := DepthFieldNames()
+	require.Len(t, names, 20)
+	assert.Equal(t, "bid_price1", names[0])
+	assert.Equal(t, "ask_volume5", names[19])
+	for _, name := range names {
+		assert.Contains(t, fields, name)
+	}
+}
+
+func TestStockData_ToCoreRoundTrip(t *testing.T) {
+	ts := time.Date(2025, 8, 21, 10, 30, 0, 0, time.Local)
+	original := core.StockData{
+		Symbol: "600000.SH", Name: "浦发银行", Price: 10.5, Change: 0.1, ChangePercent: 0.96,
+		Volume: 1000, Turnover: 10500, Timestamp: ts,
+		BidPrice1: 10.49, BidVolume1: 100, BidPrice5: 10.45, BidVolume5: 500,
+		AskPrice1: 10.5, AskVolume1: 600, AskPrice5: 10.54, AskVolume5: 1000,
+	}
+	stock := NewStockData(original)
+
+	converted := stock.ToCore()
+	assert.True(t, ts.Equal(converted.Timestamp))
+	converted.Timestamp = original.Timestamp
+	assert.Equal(t, original, converted)
+
+	assert.Zero(t, StockData{Symbol: "600000.SH", Timestamp: "bad"}.ToCore().Timestamp)
+}
+
+// testRounder 价格字段保留 3 位，其他字段保留 2 位
+type testRounder struct{}
+
+func (testRounder) RoundFloat(field string, v float64) float64 {
+	if field == "price" || strings.Contains(field, "_price") {
+		return core.RoundHalfUp(v, core.PricePrecision)
+	}
+	return core.RoundHalfUp(v, core.PercentPrecision)
+}
+
+func TestStockData_Round(t *testing.T) {
+	stock := NewStockData(core.StockData{
+		Symbol: "600000", Price: 10.500000000000002, Change: -0.005, ChangePercent: -1.005, Turnover: 1234.565,
+		BidPrice1: 10.4995, AskPrice1: 10.5005,
+	})
+	stock.Round(testRounder{})
+	assert.Equal(t, 10.5, stock.Price)
+	assert.Equal(t, -0.01, stock.Change)
+	assert.Equal(t, -1.01, stock.ChangePercent)
+	assert.Equal(t, 1234.57, stock.Turnover)
+	assert.Equal(t, 10.5, stock.BidPrices[0])
+	assert.Equal(t, 10.501, stock.AskPrices[0])
+
+	index := IndexData{Symbol: "000001", Value: 3200.1235, ChangePercent: 0.125}
+	index.Round(testRounder{})
+	assert.Equal(t, 3200.124, index.Value, "点位使用价格精度")
+	assert.Equal(t, 0.13, index.ChangePercent)
+}
+
 func TestIndexData_Structure(t *testing.T) {
 	indexData := IndexData{
 		Symbol:        "000001",
@@ -269,3 +367,30 @@ func TestHistoricalDataPoint_Structure(t *testing.T) {
 	assert.Equal(t, int64(1250000), historicalData.Volume)
 	assert.Equal(t, 13125000.0, historicalData.Turnover)
 }
+
+func TestNewEODData_UsesBeijingTradingDate(t *testing.T) {
+	now := time.Date(2025, 8, 20, 7, 5, 0, 0, time.UTC)
+	stock := core.StockData{
+		Symbol: "600000", Name: "浦发银行", Price: 10.5, Change: 0.15, ChangePercent: 1.45,
+		Open: 10.1, High: 10.6, Low: 10, PrevClose: 10.35, Volume: 1250000, Turnover: 13125000,
+		// 北京时间 2025-08-21 00:30，UTC 仍是 08-20
+		Timestamp: time.Date(2025, 8, 20, 16, 30, 0, 0, time.UTC),
+	}
+
+	eod := NewEODData(stock, now)
+	assert.Equal(t, "2025-08-21", eod.Date, "交易日按北京时间划分")
+	assert.Equal(t, 10.5, eod.Close, "收盘价取最新价")
+	assert.Equal(t, 10.35, eod.PrevClose)
+
+	stock.Timestamp = time.Time{}
+	eod = NewEODData(stock, now)
+	assert.Equal(t, "2025-08-20", eod.Date, "行情没有时间时使用当前时间")
+	assert.Equal(t, now.Format(time.RFC3339), eod.Timestamp)
+
+	date, err := eod.TradingDate()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 8, 19, 16, 0, 0, 0, time.UTC), date.UTC(), "交易日为北京时间零点")
+
+	msg := NewMessageFormat("fetcher", "tencent", "stock_eod", []EODData{eod})
+	assert.Equal(t, 1, msg.Metadata.BatchSize)
+}
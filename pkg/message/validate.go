@@ -0,0 +1,171 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultQuarantineStream 未通过校验的行情写入的 Redis Stream
+const DefaultQuarantineStream = "stream:quarantine"
+
+const defaultQuarantineMaxLen = 10000
+
+// 校验规则名称，写入隔离流的 rule 字段和指标标签
+const (
+	RulePricePositive   = "price_positive"
+	RuleChangePercent   = "change_percent"
+	RuleVolumeMonotonic = "volume_monotonic"
+	RuleFutureTimestamp = "future_timestamp"
+)
+
+// ValidationConfig 收集器写入前的行情校验配置
+type ValidationConfig struct {
+	Enabled                 bool          `mapstructure:"enabled"`
+	MaxChangePercent        float64       `mapstructure:"max_change_percent"`         // 涨跌幅绝对值上限（百分比），0 表示不检查
+	RelaxedMaxChangePercent float64       `mapstructure:"relaxed_max_change_percent"` // 匹配 RelaxedPatterns 的股票使用的上限，0 表示不检查
+	RelaxedPatterns         []string      `mapstructure:"relaxed_patterns"`           // 与代码或名称匹配的正则，用于 ST、新股等涨跌幅限制不同的股票
+	MaxFutureSkew           time.Duration `mapstructure:"max_future_skew"`            // 行情时间最多允许超前当前时间多久，0 表示不检查
+	QuarantineStream        string        `mapstructure:"quarantine_stream"`          // 为空时使用 DefaultQuarantineStream
+}
+
+// DefaultValidationConfig 默认校验配置：涨跌幅上限 30%，ST 和新股（名称以 N、C 开头）不限制，行情时间最多超前 60 秒
+func DefaultValidationConfig() ValidationConfig {
+	return ValidationConfig{
+		Enabled:          true,
+		MaxChangePercent: 30,
+		RelaxedPatterns:  []string{"ST", "^[NC]"},
+		MaxFutureSkew:    time.Minute,
+		QuarantineStream: DefaultQuarantineStream,
+	}
+}
+
+// Violation 行情违反的校验规则
+type Violation struct {
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+func (v *Violation) Error() string {
+	return v.Rule + ": " + v.Detail
+}
+
+// PreviousTick 同一股票上一次写入的成交量和行情时间，用于检查日内成交量不减少
+type PreviousTick struct {
+	Volume    int64
+	Timestamp time.Time
+}
+
+// TickValidator 按 ValidationConfig 校验实时行情
+type TickValidator struct {
+	config  ValidationConfig
+	relaxed []*regexp.Regexp
+	now     func() time.Time
+}
+
+// NewTickValidator 创建行情校验器，RelaxedPatterns 中有无效的正则时返回错误
+func NewTickValidator(config ValidationConfig) (*TickValidator, error) {
+	v := &TickValidator{config: config, now: time.Now}
+	for _, pattern := range config.RelaxedPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid relaxed pattern %q: %w", pattern, err)
+		}
+		v.relaxed = append(v.relaxed, re)
+	}
+	return v, nil
+}
+
+// Validate 返回行情违反的第一条规则，全部通过时返回 nil；timestamp 为解析后的行情时间，
+// prev 为同一股票上一次写入的数据，没有时为 nil。不同交易日（北京时间）之间不比较成交量
+func (v *TickValidator) Validate(stock StockData, timestamp time.Time, prev *PreviousTick) *Violation {
+	if !(stock.Price > 0) {
+		return &Violation{Rule: RulePricePositive, Detail: fmt.Sprintf("price %v is not positive", stock.Price)}
+	}
+
+	if bound := v.changePercentBound(stock); bound > 0 && !(math.Abs(stock.ChangePercent) <= bound) {
+		return &Violation{Rule: RuleChangePercent, Detail: fmt.Sprintf("change percent %v exceeds %v", stock.ChangePercent, bound)}
+	}
+
+	if prev != nil && stock.Volume < prev.Volume && EODDate(timestamp) == EODDate(prev.Timestamp) {
+		return &Violation{Rule: RuleVolumeMonotonic, Detail: fmt.Sprintf("volume %d is below previous %d", stock.Volume, prev.Volume)}
+	}
+
+	if skew := v.config.MaxFutureSkew; skew > 0 {
+		if ahead := timestamp.Sub(v.now()); ahead > skew {
+			return &Violation{Rule: RuleFutureTimestamp, Detail: fmt.Sprintf("timestamp %s is %s in the future", stock.Timestamp, ahead.Round(time.Second))}
+		}
+	}
+	return nil
+}
+
+// changePercentBound 返回股票适用的涨跌幅上限，代码或名称匹配 RelaxedPatterns 时使用宽松的上限
+func (v *TickValidator) changePercentBound(stock StockData) float64 {
+	for _, re := range v.relaxed {
+		if re.MatchString(stock.Symbol) || re.MatchString(stock.Name) {
+			return v.config.RelaxedMaxChangePercent
+		}
+	}
+	return v.config.MaxChangePercent
+}
+
+// QuarantineRecord 隔离流中一条未通过校验的行情
+type QuarantineRecord struct {
+	Source        string    `json:"source"` // 写入隔离流的收集器
+	Provider      string    `json:"provider"`
+	TraceID       string    `json:"trace_id,omitempty"`
+	Violation     Violation `json:"violation"`
+	Stock         StockData `json:"stock"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// Quarantine 把未通过校验的行情写入 Redis Stream，流长度近似限制为 10000 条
+type Quarantine struct {
+	client streamAdder
+	stream string
+	source string
+}
+
+// NewQuarantine 创建隔离流写入器，source 为收集器名称，stream 为空时使用 DefaultQuarantineStream
+func NewQuarantine(client streamAdder, stream, source string) *Quarantine {
+	if stream == "" {
+		stream = DefaultQuarantineStream
+	}
+	return &Quarantine{client: client, stream: stream, source: source}
+}
+
+// Add 把一条行情及其违反的规则写入隔离流，字段为 source、symbol、rule 和 JSON 格式的 data
+func (q *Quarantine) Add(ctx context.Context, msgFormat *MessageFormat, stock StockData, violation Violation) error {
+	record := QuarantineRecord{
+		Source:        q.source,
+		Provider:      msgFormat.Metadata.Provider,
+		TraceID:       msgFormat.Header.CorrelationID,
+		Violation:     violation,
+		Stock:         stock,
+		QuarantinedAt: time.Now(),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quarantine record: %w", err)
+	}
+	err = q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.stream,
+		MaxLen: defaultQuarantineMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"source": q.source,
+			"symbol": stock.Symbol,
+			"rule":   violation.Rule,
+			"data":   string(data),
+		},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to add to quarantine stream %s: %w", q.stream, err)
+	}
+	return nil
+}
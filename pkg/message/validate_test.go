@@ -0,0 +1,126 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestValidator(t *testing.T, now time.Time) *TickValidator {
+	v, err := NewTickValidator(DefaultValidationConfig())
+	require.NoError(t, err)
+	v.now = func() time.Time { return now }
+	return v
+}
+
+// violatedRule 返回违反的规则名称，通过校验时为空
+func violatedRule(v *Violation) string {
+	if v == nil {
+		return ""
+	}
+	return v.Rule
+}
+
+func TestTickValidator_PricePositive(t *testing.T) {
+	now := time.Date(2025, 8, 21, 10, 0, 0, 0, eodLocation)
+	v := newTestValidator(t, now)
+
+	assert.Equal(t, "", violatedRule(v.Validate(StockData{Symbol: "600000", Price: 10.5}, now, nil)))
+	assert.Equal(t, RulePricePositive, violatedRule(v.Validate(StockData{Symbol: "600000", Price: 0}, now, nil)))
+	assert.Equal(t, RulePricePositive, violatedRule(v.Validate(StockData{Symbol: "600000", Price: -1}, now, nil)))
+}
+
+func TestTickValidator_ChangePercent(t *testing.T) {
+	now := time.Date(2025, 8, 21, 10, 0, 0, 0, eodLocation)
+	v := newTestValidator(t, now)
+
+	tests := []struct {
+		name  string
+		stock StockData
+		want  string
+	}{
+		{"涨停", StockData{Symbol: "300750", Name: "宁德时代", Price: 10, ChangePercent: 20}, ""},
+		{"等于上限", StockData{Symbol: "830799", Name: "艾融软件", Price: 10, ChangePercent: -30}, ""},
+		{"超过上限", StockData{Symbol: "600000", Name: "浦发银行", Price: 10, ChangePercent: 45}, RuleChangePercent},
+		{"跌幅超过上限", StockData{Symbol: "600000", Name: "浦发银行", Price: 10, ChangePercent: -99}, RuleChangePercent},
+		{"ST 股票不限制", StockData{Symbol: "600001", Name: "*ST 某某", Price: 10, ChangePercent: 45}, ""},
+		{"新股首日不限制", StockData{Symbol: "688999", Name: "N某某", Price: 10, ChangePercent: 250}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, violatedRule(v.Validate(tt.stock, now, nil)))
+		})
+	}
+}
+
+func TestTickValidator_RelaxedBound(t *testing.T) {
+	config := DefaultValidationConfig()
+	config.RelaxedMaxChangePercent = 50
+	v, err := NewTickValidator(config)
+	require.NoError(t, err)
+
+	now := time.Now()
+	assert.Equal(t, "", violatedRule(v.Validate(StockData{Name: "ST 某某", Price: 1, ChangePercent: 45}, now, nil)))
+	assert.Equal(t, RuleChangePercent, violatedRule(v.Validate(StockData{Name: "ST 某某", Price: 1, ChangePercent: 60}, now, nil)))
+
+	_, err = NewTickValidator(ValidationConfig{RelaxedPatterns: []string{"("}})
+	assert.Error(t, err)
+}
+
+func TestTickValidator_VolumeMonotonic(t *testing.T) {
+	now := time.Date(2025, 8, 21, 10, 0, 0, 0, eodLocation)
+	v := newTestValidator(t, now)
+	stock := StockData{Symbol: "600000", Price: 10, Volume: 1000}
+
+	assert.Equal(t, "", violatedRule(v.Validate(stock, now, &PreviousTick{Volume: 1000, Timestamp: now.Add(-3 * time.Second)})))
+	assert.Equal(t, "", violatedRule(v.Validate(stock, now, &PreviousTick{Volume: 900, Timestamp: now.Add(-3 * time.Second)})))
+	assert.Equal(t, RuleVolumeMonotonic, violatedRule(v.Validate(stock, now, &PreviousTick{Volume: 1200, Timestamp: now.Add(-3 * time.Second)})))
+
+	// 前一交易日的成交量不参与比较
+	assert.Equal(t, "", violatedRule(v.Validate(stock, now, &PreviousTick{Volume: 5000000, Timestamp: now.AddDate(0, 0, -1)})))
+}
+
+func TestTickValidator_FutureTimestamp(t *testing.T) {
+	now := time.Date(2025, 8, 21, 10, 0, 0, 0, eodLocation)
+	v := newTestValidator(t, now)
+	stock := StockData{Symbol: "600000", Price: 10}
+
+	assert.Equal(t, "", violatedRule(v.Validate(stock, now.Add(30*time.Second), nil)))
+	violation := v.Validate(stock, now.Add(10*time.Minute), nil)
+	assert.Equal(t, RuleFutureTimestamp, violatedRule(violation))
+	assert.Contains(t, violation.Error(), "10m0s in the future")
+
+	v.config.MaxFutureSkew = 0
+	assert.Equal(t, "", violatedRule(v.Validate(stock, now.Add(10*time.Minute), nil)))
+}
+
+func TestQuarantine_Add(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	msg := NewMessageFormat("fetcher", "tencent", "stock_realtime", nil)
+	msg.Header.CorrelationID = "trace-1"
+	q := NewQuarantine(client, "", "redis_collector")
+	stock := StockData{Symbol: "600000", Price: 0}
+	require.NoError(t, q.Add(context.Background(), msg, stock, Violation{Rule: RulePricePositive, Detail: "price 0 is not positive"}))
+
+	entries, err := client.XRange(context.Background(), DefaultQuarantineStream, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "redis_collector", entries[0].Values["source"])
+	assert.Equal(t, "600000", entries[0].Values["symbol"])
+	assert.Equal(t, RulePricePositive, entries[0].Values["rule"])
+
+	var record QuarantineRecord
+	require.NoError(t, json.Unmarshal([]byte(entries[0].Values["data"].(string)), &record))
+	assert.Equal(t, "tencent", record.Provider)
+	assert.Equal(t, "trace-1", record.TraceID)
+	assert.Equal(t, stock, record.Stock)
+}
@@ -0,0 +1,128 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion 生产者写入消息头的 schema 版本
+// 同一主版本内只允许新增字段：旧消费者忽略未知字段，新消费者对缺失字段使用零值；
+// 不兼容的修改需要升级主版本，并在 payloadDecoders 中增加转换到当前结构体的解码器。
+const CurrentVersion = "1.0"
+
+// ErrUnsupportedVersion 消息的主版本不受支持
+var ErrUnsupportedVersion = errors.New("不支持的消息版本")
+
+// UnsupportedVersionError 携带具体版本号的 ErrUnsupportedVersion
+type UnsupportedVersionError struct {
+	Version string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("%s: %q", ErrUnsupportedVersion.Error(), e.Version)
+}
+
+// Is 使 errors.Is(err, ErrUnsupportedVersion) 成立
+func (e *UnsupportedVersionError) Is(target error) bool {
+	return target == ErrUnsupportedVersion
+}
+
+// payloadDecoder 将某个主版本的 payload 解码为当前版本的结构体
+type payloadDecoder func(dataType string, raw json.RawMessage) (interface{}, error)
+
+// payloadDecoders 按主版本分派的 payload 解码器
+var payloadDecoders = map[int]payloadDecoder{
+	1: decodeV1Payload,
+}
+
+// majorVersion 解析版本号中的主版本，格式为 "<major>.<minor>"
+func majorVersion(version string) (int, error) {
+	majorStr, _, _ := strings.Cut(version, ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return 0, &UnsupportedVersionError{Version: version}
+	}
+	if _, ok := payloadDecoders[major]; !ok {
+		return 0, &UnsupportedVersionError{Version: version}
+	}
+	return major, nil
+}
+
+// rawMessage 保留原始 payload 的消息结构，用于先校验再解码
+type rawMessage struct {
+	Header   MessageHeader   `json:"header"`
+	Metadata MessageMetadata `json:"metadata"`
+	Payload  json.RawMessage `json:"payload"`
+	Checksum string          `json:"checksum"`
+}
+
+// ParseMessage 解析并校验消息，按版本将 payload 解码为当前版本的结构体
+// 返回的 Payload 为 []StockData、[]IndexData、[]KlineData、[]EODData 等具体类型，未知数据类型保持通用 JSON 值。
+// 压缩的 payload 先按 Header.Encoding 解压；校验和在解码前基于原始 payload 验证，解码后丢弃的新增字段不影响校验。
+func ParseMessage(data []byte) (*MessageFormat, error) {
+	var raw rawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+
+	major, err := majorVersion(raw.Header.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	rawPayload, err := decodePayload(raw.Header.Encoding, raw.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &MessageFormat{
+		Header:   raw.Header,
+		Metadata: raw.Metadata,
+		Payload:  rawPayload,
+		Checksum: raw.Checksum,
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+
+	payload, err := payloadDecoders[major](raw.Metadata.DataType, rawPayload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s payload: %v", ErrInvalidFormat, raw.Metadata.DataType, err)
+	}
+	msg.Payload = payload
+	return msg, nil
+}
+
+// decodeV1Payload 解码 1.x 版本的 payload
+func decodeV1Payload(dataType string, raw json.RawMessage) (interface{}, error) {
+	switch dataType {
+	case "stock_realtime":
+		return decodeSlice[StockData](raw)
+	case "index_realtime":
+		return decodeSlice[IndexData](raw)
+	case "stock_kline":
+		return decodeSlice[KlineData](raw)
+	case "stock_eod":
+		return decodeSlice[EODData](raw)
+	case "historical":
+		return decodeSlice[HistoricalDataPoint](raw)
+	default:
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// decodeSlice 将 JSON 数组解码为指定类型的切片
+func decodeSlice[T any](raw json.RawMessage) (interface{}, error) {
+	var v []T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,125 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// v1.0 的实时行情 payload
+const stockPayloadV10 = `[{"symbol":"600000","name":"浦发银行","price":10.5,"change":0.15,"changePercent":1.45,"volume":1250000,"timestamp":"2025-08-20T10:00:00Z"}]`
+
+// 假设的 v1.1：在 v1.0 基础上新增 amplitude、high、low 字段
+const stockPayloadV11 = `[{"symbol":"600000","name":"浦发银行","price":10.5,"change":0.15,"changePercent":1.45,"volume":1250000,"amplitude":2.86,"high":10.6,"low":10.3,"timestamp":"2025-08-20T10:00:00Z"}]`
+
+const klinePayloadV10 = `[{"symbol":"600000","period":"1d","open":10.1,"high":10.4,"low":10,"close":10.3,"volume":123456,"turnover":0,"timestamp":"2025-08-18T00:00:00+08:00"}]`
+
+const eodPayloadV10 = `[{"symbol":"600000","name":"浦发银行","date":"2025-08-20","open":10.1,"high":10.6,"low":10,"close":10.5,"prevClose":10.35,"change":0.15,"changePercent":1.45,"volume":1250000,"turnover":13125000,"timestamp":"2025-08-20T15:00:03+08:00"}]`
+
+// buildFixture 按给定版本和 payload 构造带正确校验和的消息 JSON
+func buildFixture(t *testing.T, version, dataType, payload string) []byte {
+	t.Helper()
+	msg := MessageFormat{
+		Header:   MessageHeader{MessageID: "msg-1", Timestamp: 1755655200, Version: version, Producer: "fetcher", ContentType: "application/json"},
+		Metadata: MessageMetadata{Provider: "tencent", DataType: dataType, BatchSize: 1},
+		Payload:  json.RawMessage(payload),
+	}
+	msg.Checksum = msg.CalculateChecksum()
+	data, err := json.Marshal(msg)
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseMessage_Versions(t *testing.T) {
+	wantStock := []StockData{{
+		Symbol:        "600000",
+		Name:          "浦发银行",
+		Price:         10.5,
+		Change:        0.15,
+		ChangePercent: 1.45,
+		Volume:        1250000,
+		Timestamp:     "2025-08-20T10:00:00Z",
+	}}
+
+	tests := []struct {
+		name        string
+		version     string
+		dataType    string
+		payload     string
+		wantPayload interface{}
+		wantErr     error
+	}{
+		{name: "v1.0 行情", version: "1.0", dataType: "stock_realtime", payload: stockPayloadV10, wantPayload: wantStock},
+		{name: "v1.1 新增字段被忽略", version: "1.1", dataType: "stock_realtime", payload: stockPayloadV11, wantPayload: wantStock},
+		{name: "v1.0 K线", version: "1.0", dataType: "stock_kline", payload: klinePayloadV10, wantPayload: []KlineData{{
+			Symbol: "600000", Period: "1d", Open: 10.1, High: 10.4, Low: 10, Close: 10.3, Volume: 123456, Timestamp: "2025-08-18T00:00:00+08:00",
+		}}},
+		{name: "v1.0 收盘快照", version: "1.0", dataType: "stock_eod", payload: eodPayloadV10, wantPayload: []EODData{{
+			Symbol: "600000", Name: "浦发银行", Date: "2025-08-20", Open: 10.1, High: 10.6, Low: 10, Close: 10.5, PrevClose: 10.35,
+			Change: 0.15, ChangePercent: 1.45, Volume: 1250000, Turnover: 13125000, Timestamp: "2025-08-20T15:00:03+08:00",
+		}}},
+		{name: "未知数据类型保持通用值", version: "1.0", dataType: "custom", payload: `{"a":1}`, wantPayload: map[string]interface{}{"a": float64(1)}},
+		{name: "不支持的主版本", version: "2.0", dataType: "stock_realtime", payload: stockPayloadV10, wantErr: ErrUnsupportedVersion},
+		{name: "缺少版本", version: "", dataType: "stock_realtime", payload: stockPayloadV10, wantErr: ErrUnsupportedVersion},
+		{name: "无效版本", version: "abc", dataType: "stock_realtime", payload: stockPayloadV10, wantErr: ErrUnsupportedVersion},
+		{name: "payload 结构错误", version: "1.0", dataType: "stock_realtime", payload: `{"symbol":"600000"}`, wantErr: ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseMessage(buildFixture(t, tt.version, tt.dataType, tt.payload))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, msg.Header.Version)
+			assert.Equal(t, tt.wantPayload, msg.Payload)
+		})
+	}
+}
+
+func TestParseMessage_ChecksumMismatch(t *testing.T) {
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(buildFixture(t, "1.1", "stock_realtime", stockPayloadV11), &raw))
+	raw["checksum"] = "sha256:0000"
+	data, err := json.Marshal(raw)
+	require.NoError(t, err)
+
+	_, err = ParseMessage(data)
+	assert.ErrorIs(t, err, ErrInvalidChecksum)
+
+	_, err = ParseMessage([]byte("not json"))
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestParseMessage_RoundTripFromProducer(t *testing.T) {
+	original := NewMessageFormat("fetcher", "tencent", "index_realtime", []IndexData{
+		{Symbol: "sh000001", Name: "上证指数", Value: 3200.5, Change: 10.2, ChangePercent: 0.32, Timestamp: "2025-08-20T10:00:00Z"},
+	})
+	assert.Equal(t, CurrentVersion, original.Header.Version)
+
+	data, err := original.ToJSON()
+	require.NoError(t, err)
+
+	msg, err := ParseMessage([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, original.Payload, msg.Payload)
+}
+
+func TestFromJSON_RejectsUnsupportedMajorVersion(t *testing.T) {
+	_, err := FromJSON(string(buildFixture(t, "2.3", "stock_realtime", stockPayloadV10)))
+	require.Error(t, err)
+
+	var versionErr *UnsupportedVersionError
+	require.True(t, errors.As(err, &versionErr))
+	assert.Equal(t, "2.3", versionErr.Version)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	msg, err := FromJSON(string(buildFixture(t, "1.1", "stock_realtime", stockPayloadV11)))
+	require.NoError(t, err)
+	assert.NoError(t, msg.Validate())
+}
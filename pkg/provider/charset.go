@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ErrEncodingFailure 响应内容无法按字符集完整解码
+var ErrEncodingFailure = errors.New("response encoding failure")
+
+// EncodingError 严格模式下解码失败的详细信息，errors.Is(err, ErrEncodingFailure) 为 true
+type EncodingError struct {
+	Charset string // 使用的字符集
+	Offset  int    // 第一个无法解码的字节位置
+}
+
+// Error 实现 error 接口
+func (e *EncodingError) Error() string {
+	return fmt.Sprintf("%v: invalid %s sequence at byte %d", ErrEncodingFailure, e.Charset, e.Offset)
+}
+
+// Is 支持 errors.Is(err, ErrEncodingFailure)
+func (e *EncodingError) Is(target error) bool {
+	return target == ErrEncodingFailure
+}
+
+// utf8BOM UTF-8 字节序标记
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// DecodeBody 将行情接口的响应解码为 UTF-8。
+//
+// 字符集优先取 UTF-8 BOM，其次取 Content-Type 的 charset 参数，都没有时按 GB18030 解码
+// （GBK 和 GB2312 也按其超集 GB18030 解码）。非严格模式下无法解码的字节替换为 U+FFFD；
+// 严格模式下返回 *EncodingError。
+func DecodeBody(body []byte, contentType string, strict bool) (string, error) {
+	if bytes.HasPrefix(body, utf8BOM) {
+		return decodeUTF8(body[len(utf8BOM):], strict)
+	}
+
+	charset := charsetFromContentType(contentType)
+	switch charset {
+	case "utf-8", "utf8":
+		return decodeUTF8(body, strict)
+	case "", "gbk", "gb2312", "gb18030", "x-gbk", "cp936":
+		return decodeWith(simplifiedchinese.GB18030, "gb18030", body, strict)
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		// 未知字符集按行情接口的默认编码处理
+		return decodeWith(simplifiedchinese.GB18030, "gb18030", body, strict)
+	}
+	if name, _ := htmlindex.Name(enc); name == "utf-8" {
+		return decodeUTF8(body, strict)
+	}
+	return decodeWith(enc, charset, body, strict)
+}
+
+// charsetFromContentType 从 Content-Type 中取出小写的 charset 参数
+func charsetFromContentType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(params["charset"]))
+}
+
+// decodeUTF8 校验 UTF-8 内容
+func decodeUTF8(body []byte, strict bool) (string, error) {
+	if utf8.Valid(body) {
+		return string(body), nil
+	}
+	if strict {
+		return "", &EncodingError{Charset: "utf-8", Offset: invalidUTF8Offset(body)}
+	}
+	return strings.ToValidUTF8(string(body), string(utf8.RuneError)), nil
+}
+
+// decodeWith 使用指定编码解码。解码器遇到非法或截断的字节序列时输出 U+FFFD，
+// 严格模式通过重新编码比较原始字节判断是否有信息丢失。
+func decodeWith(enc encoding.Encoding, charset string, body []byte, strict bool) (string, error) {
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		if strict {
+			return "", &EncodingError{Charset: charset, Offset: 0}
+		}
+		return strings.ToValidUTF8(string(body), string(utf8.RuneError)), nil
+	}
+
+	if strict && bytes.ContainsRune(decoded, utf8.RuneError) {
+		encoded, err := enc.NewEncoder().Bytes(decoded)
+		if err != nil || !bytes.Equal(encoded, body) {
+			return "", &EncodingError{Charset: charset, Offset: firstDiff(encoded, body)}
+		}
+	}
+	// GB18030 的 BOM 解码后为 U+FEFF
+	return strings.TrimPrefix(string(decoded), "\uFEFF"), nil
+}
+
+// invalidUTF8Offset 返回第一个非法 UTF-8 序列的位置
+func invalidUTF8Offset(body []byte) int {
+	for offset := 0; offset < len(body); {
+		r, size := utf8.DecodeRune(body[offset:])
+		if r == utf8.RuneError && size <= 1 {
+			return offset
+		}
+		offset += size
+	}
+	return len(body)
+}
+
+// firstDiff 返回两个字节切片第一个不同的位置
+func firstDiff(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// gb18030Bytes 将 UTF-8 文本编码为 GB18030 字节，模拟行情接口的原始响应
+func gb18030Bytes(t *testing.T, s string) []byte {
+	t.Helper()
+	encoded, err := simplifiedchinese.GB18030.NewEncoder().Bytes([]byte(s))
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestDecodeBody_GB18030OnlyCharacters(t *testing.T) {
+	// 𠮷 (U+20BB7) 属于扩展 B 区，只能用 GB18030 的四字节序列表示
+	text := `v_sh600000="1~𠮷野家~600000~10.00";`
+	body := gb18030Bytes(t, text)
+	_, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(text))
+	require.Error(t, err, "fixture 必须包含 GBK 无法表示的字符")
+
+	for _, contentType := range []string{"", "text/html; charset=GBK", "text/html; charset=gb2312", "application/x-javascript; charset=GB18030"} {
+		decoded, err := DecodeBody(body, contentType, true)
+		require.NoError(t, err, contentType)
+		assert.Equal(t, text, decoded, contentType)
+	}
+}
+
+func TestDecodeBody_ContentTypeDetection(t *testing.T) {
+	text := `var hq_str_sh600000="浦发银行,10.00";`
+
+	decoded, err := DecodeBody([]byte(text), "text/plain; charset=UTF-8", true)
+	require.NoError(t, err)
+	assert.Equal(t, text, decoded)
+
+	// 未知字符集和无法解析的 Content-Type 都按 GB18030 处理
+	for _, contentType := range []string{"text/plain; charset=x-unknown", "not a media type;;"} {
+		decoded, err := DecodeBody(gb18030Bytes(t, text), contentType, true)
+		require.NoError(t, err, contentType)
+		assert.Equal(t, text, decoded, contentType)
+	}
+
+	// htmlindex 支持的其他字符集
+	big5, err := DecodeBody([]byte{0xa4, 0xa4}, "text/html; charset=big5", true)
+	require.NoError(t, err)
+	assert.Equal(t, "中", big5)
+}
+
+func TestDecodeBody_BOM(t *testing.T) {
+	text := `v_sz000001="51~平安银行~000001";`
+
+	// UTF-8 BOM 优先于 Content-Type
+	decoded, err := DecodeBody(append([]byte{0xEF, 0xBB, 0xBF}, text...), "text/html; charset=GBK", true)
+	require.NoError(t, err)
+	assert.Equal(t, text, decoded)
+
+	decoded, err = DecodeBody(gb18030Bytes(t, "\uFEFF"+text), "", true)
+	require.NoError(t, err)
+	assert.Equal(t, text, decoded)
+}
+
+func TestDecodeBody_TruncatedMultibyteSequence(t *testing.T) {
+	full := gb18030Bytes(t, `v_sh600000="1~浦发银行~𠮷";`)
+	prefix := gb18030Bytes(t, `v_sh600000="1~浦发银行~`)
+	tests := map[string][]byte{
+		"截断的双字节字符": append(append([]byte{}, prefix...), 0xC6),
+		"截断的四字节字符": full[:len(prefix)+2],
+	}
+
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeBody(body, "text/html; charset=GBK", true)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrEncodingFailure))
+			var encErr *EncodingError
+			require.ErrorAs(t, err, &encErr)
+			assert.Equal(t, "gb18030", encErr.Charset)
+			assert.Equal(t, len(prefix), encErr.Offset)
+
+			// 非严格模式将截断的字节替换为 U+FFFD，前面的内容保持不变
+			decoded, err := DecodeBody(body, "text/html; charset=GBK", false)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(decoded, `v_sh600000="1~浦发银行~`+string(utf8.RuneError)), decoded)
+		})
+	}
+}
+
+func TestDecodeBody_InvalidUTF8(t *testing.T) {
+	body := []byte("浦发银行\xe6\xb5")
+
+	_, err := DecodeBody(body, "text/plain; charset=utf-8", true)
+	var encErr *EncodingError
+	require.ErrorAs(t, err, &encErr)
+	assert.Equal(t, "utf-8", encErr.Charset)
+	assert.Equal(t, len("浦发银行"), encErr.Offset)
+
+	decoded, err := DecodeBody(body, "text/plain; charset=utf-8", false)
+	require.NoError(t, err)
+	assert.Equal(t, "浦发银行"+string(utf8.RuneError), decoded)
+}
+
+func TestDecodeBody_MultibyteAcrossBufferBoundary(t *testing.T) {
+	// 解码器按 4096 字节的缓冲区处理，让多字节字符跨越缓冲区边界
+	for _, pad := range []int{4095, 4094, 4093} {
+		text := strings.Repeat("a", pad) + "浦发银行𠮷" + strings.Repeat("b", 10)
+		body := gb18030Bytes(t, text)
+		require.True(t, bytes.HasPrefix(body, []byte(strings.Repeat("a", pad))))
+
+		decoded, err := DecodeBody(body, "", true)
+		require.NoError(t, err, pad)
+		assert.Equal(t, text, decoded, pad)
+	}
+}
@@ -0,0 +1,189 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// DefaultChunkSize 单次请求的默认股票数量，避免 URL 超过行情接口的长度限制
+const DefaultChunkSize = 60
+
+// RawChunkSeparator 拼接各分片原始响应时使用的分隔符
+const RawChunkSeparator = "\n"
+
+// ChunkOptions 分片请求配置
+type ChunkOptions struct {
+	Size        int           // 每个分片的股票数量，<= 0 时使用 DefaultChunkSize
+	Concurrency int           // 同时进行的分片请求数，<= 1 时顺序请求
+	Interval    time.Duration // 相邻两次分片请求的最小间隔，通常为提供商的频率限制
+}
+
+// ChunkFetchFunc 请求单个分片的函数
+type ChunkFetchFunc func(ctx context.Context, symbols []string) ([]core.StockData, string, error)
+
+// ChunkError 单个分片请求失败的信息
+type ChunkError struct {
+	Index   int      // 分片序号，从 0 开始
+	Symbols []string // 分片包含的股票代码
+	Err     error    // 分片请求返回的错误
+}
+
+// Error 实现 error 接口
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d (%s..%s, %d symbols): %v",
+		e.Index, e.Symbols[0], e.Symbols[len(e.Symbols)-1], len(e.Symbols), e.Err)
+}
+
+// Unwrap 返回分片请求的原始错误
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError 部分分片请求失败，成功分片的数据仍随错误一起返回
+type MultiError struct {
+	Chunks int           // 分片总数
+	Failed []*ChunkError // 失败的分片，按分片序号排序
+}
+
+// Error 实现 error 接口
+func (e *MultiError) Error() string {
+	parts := make([]string, len(e.Failed))
+	for i, failed := range e.Failed {
+		parts[i] = failed.Error()
+	}
+	return fmt.Sprintf("%d of %d chunks failed: %s", len(e.Failed), e.Chunks, strings.Join(parts, "; "))
+}
+
+// Unwrap 返回各分片的错误，支持 errors.Is / errors.As
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, failed := range e.Failed {
+		errs[i] = failed
+	}
+	return errs
+}
+
+// FailedSymbols 返回失败分片包含的全部股票代码
+func (e *MultiError) FailedSymbols() []string {
+	var symbols []string
+	for _, failed := range e.Failed {
+		symbols = append(symbols, failed.Symbols...)
+	}
+	return symbols
+}
+
+// SplitSymbols 按 size 将股票代码切分为多个分片，size <= 0 时使用 DefaultChunkSize
+func SplitSymbols(symbols []string, size int) [][]string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	chunks := make([][]string, 0, (len(symbols)+size-1)/size)
+	for start := 0; start < len(symbols); start += size {
+		end := min(start+size, len(symbols))
+		chunks = append(chunks, symbols[start:end])
+	}
+	return chunks
+}
+
+// FetchInChunks 将股票代码分片请求并按输入顺序合并结果。
+//
+// 只有一个分片时直接返回 fetch 的结果；多个分片中有失败时返回成功分片的数据和 *MultiError，
+// 原始响应按分片顺序以 RawChunkSeparator 拼接。
+func FetchInChunks(ctx context.Context, symbols []string, opts ChunkOptions, fetch ChunkFetchFunc) ([]core.StockData, string, error) {
+	chunks := SplitSymbols(symbols, opts.Size)
+	if len(chunks) <= 1 {
+		return fetch(ctx, symbols)
+	}
+
+	type chunkResult struct {
+		data []core.StockData
+		raw  string
+		err  error
+	}
+	results := make([]chunkResult, len(chunks))
+	spacing := &requestSpacing{interval: opts.Interval}
+
+	workers := max(opts.Concurrency, 1)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(chunks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := spacing.wait(ctx); err != nil {
+					results[i].err = err
+					continue
+				}
+				results[i].data, results[i].raw, results[i].err = fetch(ctx, chunks[i])
+			}
+		}()
+	}
+	for i := range chunks {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var data []core.StockData
+	var raws []string
+	multiErr := &MultiError{Chunks: len(chunks)}
+	for i, result := range results {
+		if result.err != nil {
+			multiErr.Failed = append(multiErr.Failed, &ChunkError{Index: i, Symbols: chunks[i], Err: result.err})
+			continue
+		}
+		data = append(data, result.data...)
+		raws = append(raws, result.raw)
+	}
+	if data == nil {
+		data = []core.StockData{}
+	}
+
+	raw := strings.Join(raws, RawChunkSeparator)
+	if len(multiErr.Failed) > 0 {
+		return data, raw, multiErr
+	}
+	return data, raw, nil
+}
+
+// requestSpacing 保证相邻两次请求的开始时间至少间隔 interval
+type requestSpacing struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait 等待到下一次允许请求的时间，ctx 取消时返回错误
+func (s *requestSpacing) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	start := now
+	if s.next.After(now) {
+		start = s.next
+	}
+	s.next = start.Add(s.interval)
+	s.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
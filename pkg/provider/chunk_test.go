@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// chunkSymbols 生成 n 个连续的股票代码
+func chunkSymbols(n int) []string {
+	symbols := make([]string, n)
+	for i := range symbols {
+		symbols[i] = fmt.Sprintf("%06d", 600000+i)
+	}
+	return symbols
+}
+
+// echoFetch 为每个代码返回一条数据，原始响应为以逗号连接的代码
+func echoFetch(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data := make([]core.StockData, len(symbols))
+	for i, symbol := range symbols {
+		data[i] = core.StockData{Symbol: symbol}
+	}
+	return data, strings.Join(symbols, ","), nil
+}
+
+func dataSymbols(data []core.StockData) []string {
+	symbols := make([]string, len(data))
+	for i, d := range data {
+		symbols[i] = d.Symbol
+	}
+	return symbols
+}
+
+func TestSplitSymbols(t *testing.T) {
+	chunks := SplitSymbols(chunkSymbols(7), 3)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, []string{"600000", "600001", "600002"}, chunks[0])
+	assert.Equal(t, []string{"600006"}, chunks[2])
+
+	assert.Len(t, SplitSymbols(chunkSymbols(DefaultChunkSize+1), 0), 2, "size <= 0 时使用默认分片大小")
+	assert.Empty(t, SplitSymbols(nil, 3))
+}
+
+func TestFetchInChunks_PreservesInputOrder(t *testing.T) {
+	symbols := chunkSymbols(25)
+
+	for _, concurrency := range []int{1, 4} {
+		t.Run(fmt.Sprintf("concurrency=%d", concurrency), func(t *testing.T) {
+			var mu sync.Mutex
+			var requested [][]string
+			fetch := func(ctx context.Context, chunk []string) ([]core.StockData, string, error) {
+				mu.Lock()
+				requested = append(requested, chunk)
+				order := len(requested)
+				mu.Unlock()
+				// 先发出的分片更晚返回，验证合并顺序与完成顺序无关
+				time.Sleep(time.Duration(4-order) * 5 * time.Millisecond)
+				return echoFetch(ctx, chunk)
+			}
+
+			data, raw, err := FetchInChunks(context.Background(), symbols, ChunkOptions{Size: 10, Concurrency: concurrency}, fetch)
+			require.NoError(t, err)
+			assert.Equal(t, symbols, dataSymbols(data))
+			assert.Len(t, requested, 3)
+			assert.Equal(t, strings.Join([]string{
+				strings.Join(symbols[:10], ","),
+				strings.Join(symbols[10:20], ","),
+				strings.Join(symbols[20:], ","),
+			}, RawChunkSeparator), raw)
+		})
+	}
+}
+
+func TestFetchInChunks_PartialFailure(t *testing.T) {
+	symbols := chunkSymbols(30)
+	errUpstream := &core.HTTPStatusError{StatusCode: 502}
+	fetch := func(ctx context.Context, chunk []string) ([]core.StockData, string, error) {
+		if chunk[0] == "600010" {
+			return nil, "", errUpstream
+		}
+		return echoFetch(ctx, chunk)
+	}
+
+	data, raw, err := FetchInChunks(context.Background(), symbols, ChunkOptions{Size: 10}, fetch)
+	require.Error(t, err)
+
+	var multiErr *MultiError
+	require.ErrorAs(t, err, &multiErr)
+	assert.Equal(t, 3, multiErr.Chunks)
+	require.Len(t, multiErr.Failed, 1)
+	assert.Equal(t, 1, multiErr.Failed[0].Index)
+	assert.Equal(t, symbols[10:20], multiErr.FailedSymbols())
+	assert.ErrorIs(t, err, errUpstream)
+	assert.True(t, core.IsRetryable(err), "分片的 5xx 错误仍可被重试装饰器识别")
+	assert.Contains(t, err.Error(), "1 of 3 chunks failed")
+
+	// 成功分片的数据仍然返回
+	assert.Equal(t, append(append([]string{}, symbols[:10]...), symbols[20:]...), dataSymbols(data))
+	assert.Equal(t, strings.Join(symbols[:10], ",")+RawChunkSeparator+strings.Join(symbols[20:], ","), raw)
+}
+
+func TestFetchInChunks_SingleChunkPassesThrough(t *testing.T) {
+	errUpstream := errors.New("upstream down")
+	_, _, err := FetchInChunks(context.Background(), chunkSymbols(5), ChunkOptions{Size: 10},
+		func(ctx context.Context, chunk []string) ([]core.StockData, string, error) {
+			return nil, "", errUpstream
+		})
+	assert.Same(t, errUpstream, err, "只有一个分片时不包装错误")
+}
+
+func TestFetchInChunks_SpacesRequests(t *testing.T) {
+	var mu sync.Mutex
+	var starts []time.Time
+	fetch := func(ctx context.Context, chunk []string) ([]core.StockData, string, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return echoFetch(ctx, chunk)
+	}
+
+	const interval = 20 * time.Millisecond
+	_, _, err := FetchInChunks(context.Background(), chunkSymbols(4), ChunkOptions{Size: 1, Concurrency: 4, Interval: interval}, fetch)
+	require.NoError(t, err)
+	require.Len(t, starts, 4)
+	assert.GreaterOrEqual(t, starts[3].Sub(starts[0]), 3*interval-time.Millisecond, "并发分片之间同样保持请求间隔")
+}
+
+func TestFetchInChunks_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	fetch := func(ctx context.Context, chunk []string) ([]core.StockData, string, error) {
+		calls++
+		cancel()
+		return echoFetch(ctx, chunk)
+	}
+
+	data, _, err := FetchInChunks(ctx, chunkSymbols(3), ChunkOptions{Size: 1, Interval: time.Millisecond}, fetch)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls, "取消后不再发出新的分片请求")
+	assert.Equal(t, []string{"600000"}, dataSymbols(data))
+}
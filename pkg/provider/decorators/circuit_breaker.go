@@ -2,9 +2,12 @@ package decorators
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"stocksub/pkg/core"
 	"stocksub/pkg/provider"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,7 +15,8 @@ import (
 )
 
 // CircuitBreakerProvider 熔断器装饰器
-// 使用 sony/gobreaker 提供熔断功能
+// 使用 sony/gobreaker 提供熔断功能；开启分区后每个分区（默认按市场）维护独立的熔断状态，
+// 一个分区熔断不影响其他分区的请求
 type CircuitBreakerProvider struct {
 	provider.RealtimeStockProvider
 	*provider.BaseDecorator
@@ -20,6 +24,11 @@ type CircuitBreakerProvider struct {
 	cb     *gobreaker.CircuitBreaker
 	config *CircuitBreakerConfig
 
+	// 分区模式下每个分区的熔断器，按需创建
+	partition  PartitionFunc
+	breakersMu sync.Mutex
+	breakers   map[string]*gobreaker.CircuitBreaker
+
 	// 统计信息
 	mu    sync.RWMutex
 	stats CircuitBreakerStats
@@ -33,6 +42,99 @@ type CircuitBreakerConfig struct {
 	Timeout     time.Duration `yaml:"timeout"`       // 熔断器打开后的超时时间
 	ReadyToTrip uint32        `yaml:"ready_to_trip"` // 触发熔断的失败次数阈值
 	Enabled     bool          `yaml:"enabled"`       // 是否启用熔断器
+	Partitioned bool          `yaml:"partitioned"`   // 是否按分区维护独立的熔断状态
+	// Partition 分区函数，为空时按市场分区；设置后即使 Partitioned 为 false 也启用分区
+	Partition PartitionFunc `yaml:"-"`
+}
+
+// PartitionFunc 将一次请求的股票代码划分为多个分区，返回分区名到代码的映射
+type PartitionFunc func(symbols []string) map[string][]string
+
+// OtherPartition 无法识别市场的股票代码所在的分区
+const OtherPartition = "other"
+
+// PartitionByMarket 按代码所属市场（SH、SZ、BJ、HK、US）分区，无法识别的代码归入 OtherPartition
+func PartitionByMarket(symbols []string) map[string][]string {
+	partitions := make(map[string][]string)
+	for _, symbol := range symbols {
+		key := OtherPartition
+		if parsed, err := core.ParseSymbol(symbol); err == nil {
+			key = string(parsed.Market)
+		}
+		partitions[key] = append(partitions[key], symbol)
+	}
+	return partitions
+}
+
+// ErrHalfOpenProbe 分区处于半开状态，本次只请求一个探测代码，其余代码未请求
+var ErrHalfOpenProbe = errors.New("circuit breaker is half-open, only the probe symbol was fetched")
+
+// PartitionError 一个分区被熔断跳过或请求失败
+type PartitionError struct {
+	Partition string          // 分区名
+	Symbols   []string        // 未获取到数据的股票代码
+	State     gobreaker.State // 请求前分区熔断器的状态
+	Err       error           // gobreaker.ErrOpenState、ErrHalfOpenProbe 或下层提供商返回的错误
+}
+
+// Error 实现 error 接口
+func (e *PartitionError) Error() string {
+	return fmt.Sprintf("partition %s (%s, %d symbols): %v", e.Partition, e.State, len(e.Symbols), e.Err)
+}
+
+// Unwrap 返回分区的原始错误
+func (e *PartitionError) Unwrap() error {
+	return e.Err
+}
+
+// Skipped 分区是否因为熔断而没有请求下层提供商
+func (e *PartitionError) Skipped() bool {
+	return errors.Is(e.Err, gobreaker.ErrOpenState) || errors.Is(e.Err, gobreaker.ErrTooManyRequests) ||
+		errors.Is(e.Err, ErrHalfOpenProbe)
+}
+
+// PartialFailureError 分区模式下部分分区被跳过或失败，其余分区的数据仍随错误一起返回
+type PartialFailureError struct {
+	Partitions int               // 本次请求的分区总数
+	Failed     []*PartitionError // 跳过或失败的分区，按分区名排序
+}
+
+// Error 实现 error 接口
+func (e *PartialFailureError) Error() string {
+	parts := make([]string, len(e.Failed))
+	for i, failed := range e.Failed {
+		parts[i] = failed.Error()
+	}
+	return fmt.Sprintf("%d of %d partitions not fetched: %s", len(e.Failed), e.Partitions, strings.Join(parts, "; "))
+}
+
+// Unwrap 返回各分区的错误，支持 errors.Is / errors.As
+func (e *PartialFailureError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, failed := range e.Failed {
+		errs[i] = failed
+	}
+	return errs
+}
+
+// SkippedPartitions 返回因熔断而跳过的分区名
+func (e *PartialFailureError) SkippedPartitions() []string {
+	var names []string
+	for _, failed := range e.Failed {
+		if failed.Skipped() {
+			names = append(names, failed.Partition)
+		}
+	}
+	return names
+}
+
+// FailedSymbols 返回未获取到数据的全部股票代码
+func (e *PartialFailureError) FailedSymbols() []string {
+	var symbols []string
+	for _, failed := range e.Failed {
+		symbols = append(symbols, failed.Symbols...)
+	}
+	return symbols
 }
 
 // CircuitBreakerStats 熔断器统计信息
@@ -61,9 +163,28 @@ func NewCircuitBreakerProvider(stockProvider provider.RealtimeStockProvider, con
 		config = DefaultCircuitBreakerConfig()
 	}
 
+	c := &CircuitBreakerProvider{
+		RealtimeStockProvider: stockProvider,
+		BaseDecorator:         provider.NewBaseDecorator(stockProvider),
+		config:                config,
+		stats:                 CircuitBreakerStats{},
+	}
+	c.cb = newStateLoggingBreaker(config, config.Name)
+	if config.Partitioned || config.Partition != nil {
+		c.partition = config.Partition
+		if c.partition == nil {
+			c.partition = PartitionByMarket
+		}
+		c.breakers = make(map[string]*gobreaker.CircuitBreaker)
+	}
+	return c
+}
+
+// newStateLoggingBreaker 按配置创建熔断器，状态变更时打印日志
+func newStateLoggingBreaker(config *CircuitBreakerConfig, name string) *gobreaker.CircuitBreaker {
 	// 创建 gobreaker 设置
 	settings := gobreaker.Settings{
-		Name:        config.Name,
+		Name:        name,
 		MaxRequests: config.MaxRequests,
 		Interval:    config.Interval,
 		Timeout:     config.Timeout,
@@ -76,14 +197,25 @@ func NewCircuitBreakerProvider(stockProvider provider.RealtimeStockProvider, con
 			fmt.Printf("熔断器 %s 状态从 %v 变更为 %v\n", name, from, to)
 		},
 	}
+	return gobreaker.NewCircuitBreaker(settings)
+}
 
-	return &CircuitBreakerProvider{
-		RealtimeStockProvider: stockProvider,
-		BaseDecorator:         provider.NewBaseDecorator(stockProvider),
-		cb:                    gobreaker.NewCircuitBreaker(settings),
-		config:                config,
-		stats:                 CircuitBreakerStats{},
+// breaker 返回分区的熔断器，不存在时创建
+func (c *CircuitBreakerProvider) breaker(partition string) *gobreaker.CircuitBreaker {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+
+	cb, ok := c.breakers[partition]
+	if !ok {
+		cb = newStateLoggingBreaker(c.config, c.config.Name+"/"+partition)
+		c.breakers[partition] = cb
 	}
+	return cb
+}
+
+// IsPartitioned 是否按分区维护熔断状态
+func (c *CircuitBreakerProvider) IsPartitioned() bool {
+	return c.partition != nil
 }
 
 // IsHealthy 检查健康状态
@@ -92,8 +224,8 @@ func (c *CircuitBreakerProvider) IsHealthy() bool {
 		return c.RealtimeStockProvider.IsHealthy()
 	}
 
-	// 熔断器打开状态视为不健康
-	state := c.cb.State()
+	// 熔断器打开状态视为不健康；分区模式下只有全部分区都打开时才视为不健康
+	state := c.GetState()
 	return state != gobreaker.StateOpen && c.RealtimeStockProvider.IsHealthy()
 }
 
@@ -118,6 +250,15 @@ func (c *CircuitBreakerProvider) FetchStockData(ctx context.Context, symbols []s
 	c.stats.TotalRequests++
 	c.mu.Unlock()
 
+	if c.IsPartitioned() {
+		data, _, err := c.fetchPartitioned(ctx, symbols, func(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+			data, err := c.RealtimeStockProvider.FetchStockData(ctx, symbols)
+			return data, "", err
+		})
+		c.handleResult(err)
+		return data, err
+	}
+
 	// 通过熔断器执行请求
 	result, err := c.cb.Execute(func() (interface{}, error) {
 		return c.RealtimeStockProvider.FetchStockData(ctx, symbols)
@@ -151,6 +292,12 @@ func (c *CircuitBreakerProvider) FetchStockDataWithRaw(ctx context.Context, symb
 	c.stats.TotalRequests++
 	c.mu.Unlock()
 
+	if c.IsPartitioned() {
+		data, raw, err := c.fetchPartitioned(ctx, symbols, c.RealtimeStockProvider.FetchStockDataWithRaw)
+		c.handleResult(err)
+		return data, raw, err
+	}
+
 	// 定义包装结果结构
 	type Result struct {
 		Data []core.StockData
@@ -183,6 +330,79 @@ func (c *CircuitBreakerProvider) FetchStockDataWithRaw(ctx context.Context, symb
 	return res.Data, res.Raw, nil
 }
 
+// fetchPartitioned 按分区拆分请求，每个分区通过自己的熔断器执行：打开的分区直接跳过，
+// 半开的分区只用第一个代码探测，其余分区照常请求。
+//
+// 有分区跳过或失败时返回其余分区的数据和 *PartialFailureError，全部分区都没有数据时 data 为 nil；
+// 原始响应按分区名顺序以 provider.RawChunkSeparator 拼接。
+func (c *CircuitBreakerProvider) fetchPartitioned(ctx context.Context, symbols []string, fetch provider.ChunkFetchFunc) ([]core.StockData, string, error) {
+	type partitionResult struct {
+		Data []core.StockData
+		Raw  string
+	}
+
+	partitions := c.partition(symbols)
+	names := make([]string, 0, len(partitions))
+	for name := range partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data []core.StockData
+	var raws []string
+	fetched := false
+	partialErr := &PartialFailureError{Partitions: len(names)}
+	for _, name := range names {
+		batch := partitions[name]
+		if len(batch) == 0 {
+			continue
+		}
+		cb := c.breaker(name)
+		state := cb.State()
+		if state == gobreaker.StateHalfOpen && len(batch) > 1 {
+			// 半开状态只发送一个代码探测恢复情况，避免整批请求再次压垮异常的分区
+			partialErr.Failed = append(partialErr.Failed, &PartitionError{
+				Partition: name, Symbols: batch[1:], State: state, Err: ErrHalfOpenProbe,
+			})
+			batch = batch[:1]
+		}
+
+		result, err := cb.Execute(func() (interface{}, error) {
+			data, raw, err := fetch(ctx, batch)
+			if err != nil {
+				return nil, err
+			}
+			return partitionResult{Data: data, Raw: raw}, nil
+		})
+		if err != nil {
+			partialErr.Failed = append(partialErr.Failed, &PartitionError{Partition: name, Symbols: batch, State: state, Err: err})
+			continue
+		}
+		res, ok := result.(partitionResult)
+		if !ok {
+			partialErr.Failed = append(partialErr.Failed, &PartitionError{
+				Partition: name, Symbols: batch, State: state, Err: fmt.Errorf("熔断器返回数据类型错误"),
+			})
+			continue
+		}
+		fetched = true
+		data = append(data, res.Data...)
+		raws = append(raws, res.Raw)
+	}
+
+	if len(partialErr.Failed) == 0 {
+		return data, strings.Join(raws, provider.RawChunkSeparator), nil
+	}
+	// 半开分区被拆成了探测和未请求两部分，按分区名重新排序
+	sort.SliceStable(partialErr.Failed, func(i, j int) bool {
+		return partialErr.Failed[i].Partition < partialErr.Failed[j].Partition
+	})
+	if !fetched {
+		return nil, "", partialErr
+	}
+	return data, strings.Join(raws, provider.RawChunkSeparator), partialErr
+}
+
 // handleResult 处理请求结果和更新统计信息
 func (c *CircuitBreakerProvider) handleResult(err error) {
 	c.mu.Lock()
@@ -196,9 +416,48 @@ func (c *CircuitBreakerProvider) handleResult(err error) {
 	}
 }
 
-// GetState 获取熔断器当前状态
+// GetState 获取熔断器当前状态；分区模式下全部分区打开时为打开，
+// 任一分区打开或半开时为半开，否则为关闭
 func (c *CircuitBreakerProvider) GetState() gobreaker.State {
-	return c.cb.State()
+	if !c.IsPartitioned() {
+		return c.cb.State()
+	}
+
+	states := c.GetPartitionStates()
+	if len(states) == 0 {
+		return gobreaker.StateClosed
+	}
+	open := 0
+	degraded := false
+	for _, state := range states {
+		switch state {
+		case gobreaker.StateOpen:
+			open++
+			degraded = true
+		case gobreaker.StateHalfOpen:
+			degraded = true
+		}
+	}
+	switch {
+	case open == len(states):
+		return gobreaker.StateOpen
+	case degraded:
+		return gobreaker.StateHalfOpen
+	default:
+		return gobreaker.StateClosed
+	}
+}
+
+// GetPartitionStates 返回各分区熔断器的状态，未开启分区或还没有请求时为空
+func (c *CircuitBreakerProvider) GetPartitionStates() map[string]gobreaker.State {
+	c.breakersMu.Lock()
+	defer c.breakersMu.Unlock()
+
+	states := make(map[string]gobreaker.State, len(c.breakers))
+	for name, cb := range c.breakers {
+		states[name] = cb.State()
+	}
+	return states
 }
 
 // GetCounts 获取熔断器计数信息
@@ -212,20 +471,15 @@ func (c *CircuitBreakerProvider) GetStatus() map[string]interface{} {
 	defer c.mu.RUnlock()
 
 	counts := c.cb.Counts()
-	state := c.cb.State()
+	state := c.GetState()
 
-	return map[string]interface{}{
+	status := map[string]interface{}{
 		"decorator_type": "CircuitBreaker",
 		"base_provider":  c.RealtimeStockProvider.Name(),
 		"enabled":        c.config.Enabled,
+		"partitioned":    c.IsPartitioned(),
 		"state":          state.String(),
-		"counts": map[string]interface{}{
-			"requests":              counts.Requests,
-			"total_successes":       counts.TotalSuccesses,
-			"total_failures":        counts.TotalFailures,
-			"consecutive_successes": counts.ConsecutiveSuccesses,
-			"consecutive_failures":  counts.ConsecutiveFailures,
-		},
+		"counts":         countsStatus(counts),
 		"stats": map[string]interface{}{
 			"total_requests":      c.stats.TotalRequests,
 			"successful_requests": c.stats.SuccessfulRequest,
@@ -240,6 +494,30 @@ func (c *CircuitBreakerProvider) GetStatus() map[string]interface{} {
 			"ready_to_trip": c.config.ReadyToTrip,
 		},
 	}
+
+	if c.IsPartitioned() {
+		c.breakersMu.Lock()
+		partitions := make(map[string]interface{}, len(c.breakers))
+		for name, cb := range c.breakers {
+			partitions[name] = map[string]interface{}{
+				"state":  cb.State().String(),
+				"counts": countsStatus(cb.Counts()),
+			}
+		}
+		c.breakersMu.Unlock()
+		status["partitions"] = partitions
+	}
+	return status
+}
+
+func countsStatus(counts gobreaker.Counts) map[string]interface{} {
+	return map[string]interface{}{
+		"requests":              counts.Requests,
+		"total_successes":       counts.TotalSuccesses,
+		"total_failures":        counts.TotalFailures,
+		"consecutive_successes": counts.ConsecutiveSuccesses,
+		"consecutive_failures":  counts.ConsecutiveFailures,
+	}
 }
 
 // SetEnabled 设置是否启用熔断器
@@ -261,17 +539,17 @@ func (c *CircuitBreakerProvider) Reset() {
 
 // IsOpen 检查熔断器是否处于打开状态
 func (c *CircuitBreakerProvider) IsOpen() bool {
-	return c.cb.State() == gobreaker.StateOpen
+	return c.GetState() == gobreaker.StateOpen
 }
 
 // IsHalfOpen 检查熔断器是否处于半开状态
 func (c *CircuitBreakerProvider) IsHalfOpen() bool {
-	return c.cb.State() == gobreaker.StateHalfOpen
+	return c.GetState() == gobreaker.StateHalfOpen
 }
 
 // IsClosed 检查熔断器是否处于关闭状态
 func (c *CircuitBreakerProvider) IsClosed() bool {
-	return c.cb.State() == gobreaker.StateClosed
+	return c.GetState() == gobreaker.StateClosed
 }
 
 // --- Historical Provider Support ---
@@ -342,3 +620,79 @@ func (c *CircuitBreakerForHistoricalProvider) FetchHistoricalData(ctx context.Co
 
 	return data, nil
 }
+
+// CircuitBreakerForIndexProvider 是为实时指数数据提供商设计的熔断器装饰器
+type CircuitBreakerForIndexProvider struct {
+	provider.RealtimeIndexProvider
+	*provider.BaseDecorator
+	cb     *gobreaker.CircuitBreaker
+	config *CircuitBreakerConfig
+}
+
+// NewCircuitBreakerForIndexProvider 创建一个新的指数数据熔断器装饰器
+func NewCircuitBreakerForIndexProvider(p provider.RealtimeIndexProvider, config *CircuitBreakerConfig) *CircuitBreakerForIndexProvider {
+	if config == nil {
+		config = DefaultCircuitBreakerConfig()
+		config.Name = "IndexProvider" // 为指数提供商使用不同的名称
+	}
+
+	settings := gobreaker.Settings{
+		Name:        config.Name,
+		MaxRequests: config.MaxRequests,
+		Interval:    config.Interval,
+		Timeout:     config.Timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= config.ReadyToTrip
+		},
+	}
+
+	return &CircuitBreakerForIndexProvider{
+		RealtimeIndexProvider: p,
+		BaseDecorator:         provider.NewBaseDecorator(p),
+		cb:                    gobreaker.NewCircuitBreaker(settings),
+		config:                config,
+	}
+}
+
+func (c *CircuitBreakerForIndexProvider) GetRateLimit() time.Duration {
+	return c.RealtimeIndexProvider.GetRateLimit()
+}
+
+// IsHealthy 熔断器打开状态视为不健康
+func (c *CircuitBreakerForIndexProvider) IsHealthy() bool {
+	if !c.config.Enabled {
+		return c.RealtimeIndexProvider.IsHealthy()
+	}
+	return c.cb.State() != gobreaker.StateOpen && c.RealtimeIndexProvider.IsHealthy()
+}
+
+func (c *CircuitBreakerForIndexProvider) Name() string {
+	return fmt.Sprintf("CircuitBreaker(%s)", c.RealtimeIndexProvider.Name())
+}
+
+// GetState 获取熔断器状态
+func (c *CircuitBreakerForIndexProvider) GetState() gobreaker.State {
+	return c.cb.State()
+}
+
+// FetchIndexData 实现带熔断的指数数据获取
+func (c *CircuitBreakerForIndexProvider) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	if !c.config.Enabled {
+		return c.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+	}
+
+	result, err := c.cb.Execute(func() (interface{}, error) {
+		return c.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := result.([]core.IndexData)
+	if !ok {
+		return nil, fmt.Errorf("熔断器返回指数数据类型错误")
+	}
+
+	return data, nil
+}
@@ -0,0 +1,241 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"stocksub/pkg/testkit/providers"
+)
+
+// TestCircuitBreaker_FullCycleFromScenario 完全由脚本场景驱动熔断器的 关闭→打开→半开→关闭
+func TestCircuitBreaker_FullCycleFromScenario(t *testing.T) {
+	scenario, err := providers.LoadScenarioFromYAML(filepath.Join("..", "..", "testkit", "providers", "testdata", "breaker_cycle.yaml"))
+	require.NoError(t, err)
+	mock := providers.NewMockProvider(providers.MockProviderConfig{})
+	mock.ApplyScenario(scenario)
+
+	cb := NewCircuitBreakerProvider(mock, &CircuitBreakerConfig{
+		Name:        "scenario",
+		MaxRequests: 2,
+		Interval:    time.Minute,
+		Timeout:     50 * time.Millisecond,
+		ReadyToTrip: 3,
+		Enabled:     true,
+	})
+	ctx := context.Background()
+	symbols := []string{"600000"}
+
+	// 场景前 3 步失败，第 3 次失败后熔断
+	for i := 0; i < 3; i++ {
+		assert.True(t, cb.IsClosed(), "第 %d 次请求前应为关闭状态", i+1)
+		_, err := cb.FetchStockData(ctx, symbols)
+		require.Error(t, err)
+	}
+	assert.True(t, cb.IsOpen())
+
+	// 打开状态直接拒绝，请求不会到达 MockProvider，场景不前进
+	_, err = cb.FetchStockData(ctx, symbols)
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.EqualValues(t, 3, mock.GetStats().TotalCalls)
+
+	// 超时后进入半开，场景之后的步骤都成功，MaxRequests 次成功后关闭
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, cb.IsHalfOpen())
+	_, err = cb.FetchStockData(ctx, symbols)
+	require.NoError(t, err)
+	assert.True(t, cb.IsHalfOpen())
+	data, err := cb.FetchStockData(ctx, symbols)
+	require.NoError(t, err)
+	assert.True(t, cb.IsClosed())
+	require.Len(t, data, 1)
+	assert.Equal(t, "600000", data[0].Symbol)
+}
+
+// bjRejectingProvider 请求包含北交所代码时返回错误，记录每次请求的代码
+type bjRejectingProvider struct {
+	MockRealtimeProvider
+	mu       sync.Mutex
+	requests [][]string
+	rejectBJ bool
+	bjErr    error // 拒绝北交所代码时返回的错误，为空时为 invalid symbol
+}
+
+func (p *bjRejectingProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	p.mu.Lock()
+	p.requests = append(p.requests, append([]string(nil), symbols...))
+	reject := p.rejectBJ
+	p.mu.Unlock()
+
+	data := make([]core.StockData, 0, len(symbols))
+	for _, symbol := range symbols {
+		if reject && PartitionByMarket([]string{symbol})["BJ"] != nil {
+			if p.bjErr != nil {
+				return nil, p.bjErr
+			}
+			return nil, errors.New("invalid symbol " + symbol)
+		}
+		data = append(data, core.StockData{Symbol: symbol, Price: 10})
+	}
+	return data, nil
+}
+
+func (p *bjRejectingProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := p.FetchStockData(ctx, symbols)
+	return data, "raw:" + strings.Join(symbols, ","), err
+}
+
+func (p *bjRejectingProvider) lastRequest() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[len(p.requests)-1]
+}
+
+func newPartitionedBreaker(p provider.RealtimeStockProvider) *CircuitBreakerProvider {
+	return NewCircuitBreakerProvider(p, &CircuitBreakerConfig{
+		Name:        "partitioned",
+		MaxRequests: 2,
+		Interval:    time.Minute,
+		Timeout:     50 * time.Millisecond,
+		ReadyToTrip: 2,
+		Enabled:     true,
+		Partitioned: true,
+	})
+}
+
+func symbolsOf(data []core.StockData) []string {
+	symbols := make([]string, len(data))
+	for i, stock := range data {
+		symbols[i] = stock.Symbol
+	}
+	return symbols
+}
+
+func TestPartitionByMarket(t *testing.T) {
+	partitions := PartitionByMarket([]string{"600000", "000001.SZ", "830799", "sh600519", "920001", "hk00700", "???"})
+	assert.Equal(t, map[string][]string{
+		"SH":           {"600000", "sh600519"},
+		"SZ":           {"000001.SZ"},
+		"BJ":           {"830799", "920001"},
+		"HK":           {"hk00700"},
+		OtherPartition: {"???"},
+	}, partitions)
+}
+
+// TestCircuitBreaker_PartitionedSZContinuesWhileBJOpen 北交所分区熔断后，沪深请求照常执行
+func TestCircuitBreaker_PartitionedSZContinuesWhileBJOpen(t *testing.T) {
+	mock := &bjRejectingProvider{rejectBJ: true}
+	cb := newPartitionedBreaker(mock)
+	ctx := context.Background()
+	symbols := []string{"000001", "830799", "600000", "000002", "430047"}
+
+	for i := 0; i < 2; i++ {
+		data, err := cb.FetchStockData(ctx, symbols)
+		var partialErr *PartialFailureError
+		require.ErrorAs(t, err, &partialErr)
+		assert.Equal(t, []string{"600000", "000001", "000002"}, symbolsOf(data))
+		assert.Empty(t, partialErr.SkippedPartitions(), "熔断前北交所分区是请求失败，不是跳过")
+	}
+	states := cb.GetPartitionStates()
+	assert.Equal(t, gobreaker.StateOpen, states["BJ"])
+	assert.Equal(t, gobreaker.StateClosed, states["SZ"])
+	assert.Equal(t, gobreaker.StateClosed, states["SH"])
+	assert.True(t, cb.IsHealthy(), "只有部分分区打开时仍然健康")
+	assert.Equal(t, gobreaker.StateHalfOpen, cb.GetState())
+
+	// 北交所分区打开时不再请求下层提供商，深市请求继续
+	before := len(mock.requests)
+	for i := 0; i < 5; i++ {
+		data, err := cb.FetchStockData(ctx, []string{"000001", "830799"})
+		var partialErr *PartialFailureError
+		require.ErrorAs(t, err, &partialErr)
+		assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+		assert.Equal(t, []string{"BJ"}, partialErr.SkippedPartitions())
+		assert.Equal(t, []string{"830799"}, partialErr.FailedSymbols())
+		assert.Equal(t, []string{"000001"}, symbolsOf(data))
+	}
+	assert.Len(t, mock.requests, before+5)
+	for _, request := range mock.requests[before:] {
+		assert.Equal(t, []string{"000001"}, request)
+	}
+
+	// 只请求打开的分区时没有数据
+	data, err := cb.FetchStockData(ctx, []string{"830799"})
+	assert.Nil(t, data)
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+
+	status := cb.GetStatus()
+	assert.Equal(t, true, status["partitioned"])
+	partitions := status["partitions"].(map[string]interface{})
+	assert.Equal(t, "open", partitions["BJ"].(map[string]interface{})["state"])
+	assert.Equal(t, "closed", partitions["SZ"].(map[string]interface{})["state"])
+}
+
+// TestCircuitBreaker_PartitionedHalfOpenProbesSingleSymbol 半开状态只用一个代码探测，恢复后请求整个分区
+func TestCircuitBreaker_PartitionedHalfOpenProbesSingleSymbol(t *testing.T) {
+	mock := &bjRejectingProvider{rejectBJ: true}
+	cb := newPartitionedBreaker(mock)
+	ctx := context.Background()
+	bj := []string{"830799", "430047", "920001"}
+
+	for i := 0; i < 2; i++ {
+		_, err := cb.FetchStockData(ctx, bj)
+		require.Error(t, err)
+	}
+	require.Equal(t, gobreaker.StateOpen, cb.GetPartitionStates()["BJ"])
+	assert.True(t, cb.IsOpen(), "全部分区打开")
+	assert.False(t, cb.IsHealthy())
+
+	time.Sleep(60 * time.Millisecond)
+	mock.rejectBJ = false
+	for i := 0; i < 2; i++ {
+		require.Equal(t, gobreaker.StateHalfOpen, cb.GetPartitionStates()["BJ"])
+		data, raw, err := cb.FetchStockDataWithRaw(ctx, append([]string{"600000"}, bj...))
+		// 分区按名称顺序执行，BJ 在 SH 之前
+		assert.Equal(t, []string{"830799"}, mock.requests[len(mock.requests)-2], "探测只发送分区中的第一个代码")
+		assert.Equal(t, []string{"830799", "600000"}, symbolsOf(data))
+		assert.Equal(t, "raw:830799\nraw:600000", raw)
+
+		var partialErr *PartialFailureError
+		require.ErrorAs(t, err, &partialErr)
+		assert.ErrorIs(t, err, ErrHalfOpenProbe)
+		require.Len(t, partialErr.Failed, 1)
+		assert.Equal(t, gobreaker.StateHalfOpen, partialErr.Failed[0].State)
+		assert.Equal(t, []string{"430047", "920001"}, partialErr.FailedSymbols())
+	}
+
+	// MaxRequests 次探测成功后关闭，恢复整批请求
+	assert.Equal(t, gobreaker.StateClosed, cb.GetPartitionStates()["BJ"])
+	data, err := cb.FetchStockData(ctx, bj)
+	require.NoError(t, err)
+	assert.Equal(t, bj, symbolsOf(data))
+	assert.Equal(t, bj, mock.lastRequest())
+	assert.True(t, cb.IsClosed())
+}
+
+func TestCircuitBreaker_CustomPartitionFunc(t *testing.T) {
+	mock := &bjRejectingProvider{}
+	cb := NewCircuitBreakerProvider(mock, &CircuitBreakerConfig{
+		Name: "custom", MaxRequests: 1, Interval: time.Minute, Timeout: time.Minute, ReadyToTrip: 1, Enabled: true,
+		Partition: func(symbols []string) map[string][]string {
+			return map[string][]string{"all": symbols}
+		},
+	})
+
+	data, err := cb.FetchStockData(context.Background(), []string{"600000", "830799"})
+	require.NoError(t, err)
+	assert.Len(t, data, 2)
+	assert.Equal(t, []string{"600000", "830799"}, mock.lastRequest())
+	assert.True(t, cb.IsPartitioned())
+	assert.Contains(t, cb.GetPartitionStates(), "all")
+}
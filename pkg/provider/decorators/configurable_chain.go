@@ -5,6 +5,7 @@ import (
 	"stocksub/pkg/provider"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
 )
 
@@ -42,7 +43,7 @@ func (cdc *ConfigurableDecoratorChain) AddDecorator(decoratorConfig provider.Dec
 	cdc.decorators = append(cdc.decorators, decoratorConfig)
 }
 
-// Apply 将装饰器链应用到指定的 RealtimeStockProvider
+// Apply 将装饰器链应用到指定的提供商（实时股票、历史数据或实时指数）
 func (cdc *ConfigurableDecoratorChain) Apply(p provider.Provider) (provider.Provider, error) {
 	// 按优先级排序装饰器
 	sortedDecorators := cdc.getSortedEnabledDecorators(p)
@@ -110,6 +111,12 @@ func CreateDecorator(decoratorType provider.DecoratorType, p provider.Provider,
 		return createFrequencyControlProvider(p, config)
 	case provider.CircuitBreakerType:
 		return createCircuitBreakerProvider(p, config)
+	case provider.RetryType:
+		return createRetryProvider(p, config)
+	case provider.MetricsType:
+		return createMetricsProvider(p, config)
+	case provider.TimeoutType:
+		return createTimeoutProvider(p, config)
 	default:
 		return nil, fmt.Errorf("不支持的装饰器类型: %s", decoratorType)
 	}
@@ -139,6 +146,15 @@ func createFrequencyControlProvider(prov provider.Provider, configMap map[string
 		if enabled, ok := configMap["enabled"].(bool); ok {
 			config.Enabled = enabled
 		}
+		if group, ok := configMap["limiter_group"].(string); ok {
+			config.LimiterGroup = group
+		}
+		if burst, ok := configMap["burst_size"].(int); ok {
+			config.BurstSize = burst
+		}
+		if requests, ok := configMap["requests_per_interval"].(int); ok {
+			config.RequestsPerInterval = requests
+		}
 	}
 	switch p := prov.(type) {
 	case provider.RealtimeStockProvider:
@@ -146,6 +162,8 @@ func createFrequencyControlProvider(prov provider.Provider, configMap map[string
 	case provider.HistoricalProvider:
 		// 历史数据提供商可能不需要频率控制，或者有不同的实现
 		return NewFrequencyControlForHistoricalProvider(p, config), nil
+	case provider.RealtimeIndexProvider:
+		return NewFrequencyControlForIndexProvider(p, config), nil
 	default:
 		return nil, fmt.Errorf("不支持为类型 %T 应用频率控制装饰器", p)
 	}
@@ -179,17 +197,132 @@ func createCircuitBreakerProvider(prov provider.Provider, configMap map[string]i
 		if enabled, ok := configMap["enabled"].(bool); ok {
 			config.Enabled = enabled
 		}
+		if partitioned, ok := configMap["partitioned"].(bool); ok {
+			config.Partitioned = partitioned
+		}
 	}
 	switch p := prov.(type) {
 	case provider.RealtimeStockProvider:
 		return NewCircuitBreakerProvider(p, config), nil
 	case provider.HistoricalProvider:
 		return NewCircuitBreakerForHistoricalProvider(p, config), nil
+	case provider.RealtimeIndexProvider:
+		return NewCircuitBreakerForIndexProvider(p, config), nil
 	default:
 		return nil, fmt.Errorf("不支持为类型 %T 应用熔断器装饰器", p)
 	}
 }
 
+// createRetryProvider 创建重试装饰器
+func createRetryProvider(prov provider.Provider, configMap map[string]interface{}) (provider.Provider, error) {
+	config := DefaultRetryConfig()
+
+	// 解析配置
+	if configMap != nil {
+		if maxAttempts, ok := configMap["max_attempts"].(int); ok {
+			config.MaxAttempts = maxAttempts
+		}
+		if initialBackoff, ok := configMap["initial_backoff"].(string); ok {
+			if duration, err := time.ParseDuration(initialBackoff); err == nil {
+				config.InitialBackoff = duration
+			}
+		}
+		if maxBackoff, ok := configMap["max_backoff"].(string); ok {
+			if duration, err := time.ParseDuration(maxBackoff); err == nil {
+				config.MaxBackoff = duration
+			}
+		}
+		switch multiplier := configMap["multiplier"].(type) {
+		case float64:
+			config.Multiplier = multiplier
+		case int:
+			config.Multiplier = float64(multiplier)
+		}
+		switch jitter := configMap["jitter"].(type) {
+		case float64:
+			config.Jitter = jitter
+		case int:
+			config.Jitter = float64(jitter)
+		}
+		if enabled, ok := configMap["enabled"].(bool); ok {
+			config.Enabled = enabled
+		}
+	}
+	switch p := prov.(type) {
+	case provider.RealtimeStockProvider:
+		return NewRetryProvider(p, config), nil
+	case provider.HistoricalProvider:
+		return NewRetryForHistoricalProvider(p, config), nil
+	case provider.RealtimeIndexProvider:
+		return NewRetryForIndexProvider(p, config), nil
+	default:
+		return nil, fmt.Errorf("不支持为类型 %T 应用重试装饰器", p)
+	}
+}
+
+// createMetricsProvider 创建指标装饰器
+// config 中的 registry 为 prometheus.Registerer 时同时注册 Prometheus 指标
+func createMetricsProvider(prov provider.Provider, configMap map[string]interface{}) (provider.Provider, error) {
+	config := &MetricsConfig{Enabled: true}
+
+	// 解析配置
+	if configMap != nil {
+		if name, ok := configMap["name"].(string); ok {
+			config.Name = name
+		}
+		if registry, ok := configMap["registry"].(prometheus.Registerer); ok {
+			config.Registerer = registry
+		}
+		if enabled, ok := configMap["enabled"].(bool); ok {
+			config.Enabled = enabled
+		}
+	}
+	switch p := prov.(type) {
+	case provider.RealtimeStockProvider:
+		return NewMetricsProvider(p, config)
+	case provider.HistoricalProvider:
+		return NewMetricsForHistoricalProvider(p, config)
+	case provider.RealtimeIndexProvider:
+		return NewMetricsForIndexProvider(p, config)
+	default:
+		return nil, fmt.Errorf("不支持为类型 %T 应用指标装饰器", p)
+	}
+}
+
+// createTimeoutProvider 创建超时装饰器，未配置 timeout 时按提供商类型取默认时限
+func createTimeoutProvider(prov provider.Provider, configMap map[string]interface{}) (provider.Provider, error) {
+	config := &TimeoutConfig{Enabled: true}
+
+	// 解析配置
+	if configMap != nil {
+		if timeout, ok := configMap["timeout"].(string); ok {
+			if duration, err := time.ParseDuration(timeout); err == nil {
+				config.Timeout = duration
+			}
+		}
+		if enabled, ok := configMap["enabled"].(bool); ok {
+			config.Enabled = enabled
+		}
+	}
+
+	defaultTimeout := func(d time.Duration) *TimeoutConfig {
+		if config.Timeout == 0 {
+			config.Timeout = d
+		}
+		return config
+	}
+	switch p := prov.(type) {
+	case provider.RealtimeStockProvider:
+		return NewTimeoutProvider(p, defaultTimeout(DefaultRealtimeTimeout)), nil
+	case provider.HistoricalProvider:
+		return NewTimeoutForHistoricalProvider(p, defaultTimeout(DefaultHistoricalTimeout)), nil
+	case provider.RealtimeIndexProvider:
+		return NewTimeoutForIndexProvider(p, defaultTimeout(DefaultIndexTimeout)), nil
+	default:
+		return nil, fmt.Errorf("不支持为类型 %T 应用超时装饰器", p)
+	}
+}
+
 // CreateDecoratedProvider 便捷方法：使用配置创建完全装饰的提供商
 func CreateDecoratedProvider(stockProvider provider.Provider, config provider.ProviderDecoratorConfig) (provider.Provider, error) {
 	chain := NewConfigurableDecoratorChain()
@@ -209,14 +342,30 @@ func CreateDecoratedProviderFromViper(stockProvider provider.Provider, v *viper.
 }
 
 // DefaultDecoratorConfig 创建默认的装饰器配置
+// 应用顺序：超时 -> 频率控制 -> 重试 -> 熔断器。超时限制每次上游调用，
+// 熔断器对一次完整的重试序列计一次失败
 func DefaultDecoratorConfig() provider.ProviderDecoratorConfig {
 	return provider.ProviderDecoratorConfig{
 		All: []provider.DecoratorConfig{
 			{
-				Type:         provider.CircuitBreakerType,
+				Type:         provider.RetryType,
 				Enabled:      true,
 				Priority:     2,
 				ProviderType: "all",
+				Config: map[string]interface{}{
+					"max_attempts":    3,
+					"initial_backoff": "200ms",
+					"multiplier":      2.0,
+					"max_backoff":     "2s",
+					"jitter":          0.2,
+					"enabled":         true,
+				},
+			},
+			{
+				Type:         provider.CircuitBreakerType,
+				Enabled:      true,
+				Priority:     3,
+				ProviderType: "all",
 				Config: map[string]interface{}{
 					"name":          "StockProvider",
 					"max_requests":  5,
@@ -228,6 +377,15 @@ func DefaultDecoratorConfig() provider.ProviderDecoratorConfig {
 			},
 		},
 		Realtime: []provider.DecoratorConfig{
+			{
+				Type:         provider.TimeoutType,
+				Enabled:      true,
+				Priority:     0,
+				ProviderType: "realtime",
+				Config: map[string]interface{}{
+					"timeout": "5s",
+				},
+			},
 			{
 				Type:         provider.FrequencyControlType,
 				Enabled:      true,
@@ -240,6 +398,28 @@ func DefaultDecoratorConfig() provider.ProviderDecoratorConfig {
 				},
 			},
 		},
+		Historical: []provider.DecoratorConfig{
+			{
+				Type:         provider.TimeoutType,
+				Enabled:      true,
+				Priority:     0,
+				ProviderType: "historical",
+				Config: map[string]interface{}{
+					"timeout": "30s",
+				},
+			},
+		},
+		Index: []provider.DecoratorConfig{
+			{
+				Type:         provider.TimeoutType,
+				Enabled:      true,
+				Priority:     0,
+				ProviderType: "index",
+				Config: map[string]interface{}{
+					"timeout": "5s",
+				},
+			},
+		},
 	}
 }
 
@@ -310,10 +490,19 @@ func TestDecoratorConfig() provider.ProviderDecoratorConfig {
 }
 
 // MonitoringDecoratorConfig 监控环境装饰器配置
-// 为长期监控 (api_monitor) 量身定制的配置
+// 为长期监控 (api_monitor) 量身定制的配置，指标装饰器位于最内层，记录每次上游请求的耗时和错误
 func MonitoringDecoratorConfig() provider.ProviderDecoratorConfig {
 	return provider.ProviderDecoratorConfig{
 		All: []provider.DecoratorConfig{
+			{
+				Type:         provider.MetricsType,
+				Enabled:      true,
+				Priority:     0,
+				ProviderType: "all",
+				Config: map[string]interface{}{
+					"enabled": true,
+				},
+			},
 			{
 				Type:         provider.CircuitBreakerType,
 				Enabled:      true,
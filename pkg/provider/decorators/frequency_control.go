@@ -24,6 +24,10 @@ type FrequencyControlProvider struct {
 	minInterval time.Duration // 最小请求间隔
 	maxRetries  int           // 最大重试次数
 
+	// 令牌桶，为 nil 时按 minInterval 控制间隔；配置了 LimiterGroup 时与同组的装饰器共享
+	group  string
+	bucket *limiter.TokenBucket
+
 	// 运行时状态
 	mu          sync.RWMutex
 	lastRequest time.Time // 上次请求时间
@@ -31,10 +35,63 @@ type FrequencyControlProvider struct {
 }
 
 // FrequencyControlConfig 频率控制配置
+//
+// 只配置 MinInterval 时每个装饰器独立保持最小请求间隔；配置了 BurstSize 或 RequestsPerInterval 时
+// 改为令牌桶：每个 MinInterval 补充 RequestsPerInterval 个令牌，最多积累 BurstSize 个。
+// 配置了 LimiterGroup 时同组的全部装饰器（包括实时、历史和指数）共享一个令牌桶，
+// 令牌桶参数以该组第一个创建的装饰器为准。
 type FrequencyControlConfig struct {
-	MinInterval time.Duration `yaml:"min_interval"` // 最小请求间隔
-	MaxRetries  int           `yaml:"max_retries"`  // 最大重试次数
-	Enabled     bool          `yaml:"enabled"`      // 是否启用
+	MinInterval         time.Duration `yaml:"min_interval"`          // 最小请求间隔，令牌桶模式下为补充周期
+	MaxRetries          int           `yaml:"max_retries"`           // 最大重试次数
+	Enabled             bool          `yaml:"enabled"`               // 是否启用
+	LimiterGroup        string        `yaml:"limiter_group"`         // 共享限流分组，host 表示按上游主机名分组
+	BurstSize           int           `yaml:"burst_size"`            // 令牌桶容量，默认 1
+	RequestsPerInterval int           `yaml:"requests_per_interval"` // 每个 MinInterval 补充的令牌数，默认 1
+}
+
+// LimiterGroupHost limiter_group 配置为该值时按提供商的上游主机名分组，
+// 提供商没有实现 provider.UpstreamHostProvider 时不共享
+const LimiterGroupHost = "host"
+
+// limiterGroups 共享限流分组到令牌桶的映射，进程内全局
+var limiterGroups = struct {
+	sync.Mutex
+	buckets map[string]*limiter.TokenBucket
+}{buckets: make(map[string]*limiter.TokenBucket)}
+
+// frequencyBucket 按配置返回分组名和令牌桶；没有分组也没有配置令牌桶参数时返回 nil，沿用最小间隔
+func frequencyBucket(config *FrequencyControlConfig, p provider.Provider) (string, *limiter.TokenBucket) {
+	group := config.LimiterGroup
+	if group == LimiterGroupHost {
+		group = provider.UpstreamHost(p)
+	}
+	newBucket := func() *limiter.TokenBucket {
+		return limiter.NewTokenBucket(config.RequestsPerInterval, config.MinInterval, config.BurstSize)
+	}
+
+	if group == "" {
+		if config.BurstSize <= 0 && config.RequestsPerInterval <= 0 {
+			return "", nil
+		}
+		return "", newBucket()
+	}
+
+	limiterGroups.Lock()
+	defer limiterGroups.Unlock()
+	bucket, ok := limiterGroups.buckets[group]
+	if !ok {
+		bucket = newBucket()
+		limiterGroups.buckets[group] = bucket
+	}
+	return group, bucket
+}
+
+// waitBucket 等待令牌桶，返回获得令牌的时间
+func waitBucket(ctx context.Context, bucket *limiter.TokenBucket) (time.Time, error) {
+	if err := bucket.Wait(ctx); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now(), nil
 }
 
 // NewFrequencyControlProvider 创建频率控制装饰器
@@ -49,6 +106,7 @@ func NewFrequencyControlProvider(stockProvider provider.RealtimeStockProvider, c
 
 	// 创建市场时间组件
 	marketTime := timing.DefaultMarketTime()
+	group, bucket := frequencyBucket(config, stockProvider)
 
 	return &FrequencyControlProvider{
 		RealtimeStockProvider: stockProvider,
@@ -57,6 +115,8 @@ func NewFrequencyControlProvider(stockProvider provider.RealtimeStockProvider, c
 		marketTime:            marketTime,
 		minInterval:           config.MinInterval,
 		maxRetries:            config.MaxRetries,
+		group:                 group,
+		bucket:                bucket,
 		isActive:              config.Enabled,
 		lastRequest:           time.Time{},
 	}
@@ -209,6 +269,18 @@ func (f *FrequencyControlProvider) fetchWithRawAndRetry(ctx context.Context, sym
 
 // enforceFrequencyLimit 执行频率限制
 func (f *FrequencyControlProvider) enforceFrequencyLimit(ctx context.Context) error {
+	if f.bucket != nil {
+		// 共享令牌桶时不持有 mu 等待，避免阻塞 GetStatus
+		now, err := waitBucket(ctx, f.bucket)
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.lastRequest = now
+		f.mu.Unlock()
+		return nil
+	}
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -232,7 +304,7 @@ func (f *FrequencyControlProvider) enforceFrequencyLimit(ctx context.Context) er
 	return nil
 }
 
-// SetMinInterval 设置最小请求间隔
+// SetMinInterval 设置最小请求间隔，使用令牌桶时不影响令牌补充速率
 func (f *FrequencyControlProvider) SetMinInterval(interval time.Duration) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -265,6 +337,15 @@ func (f *FrequencyControlProvider) GetStatus() map[string]interface{} {
 	status["is_active"] = f.isActive
 	status["last_request"] = f.lastRequest
 	status["base_provider"] = f.RealtimeStockProvider.Name()
+	status["limiter_group"] = f.group
+	if f.bucket != nil {
+		requests, interval := f.bucket.Rate()
+		status["tokens_available"] = f.bucket.Available()
+		status["waiters"] = f.bucket.Waiters()
+		status["burst_size"] = f.bucket.Burst()
+		status["requests_per_interval"] = requests
+		status["refill_interval"] = interval.String()
+	}
 
 	return status
 }
@@ -285,6 +366,8 @@ type FrequencyControlForHistoricalProvider struct {
 	minInterval time.Duration
 	maxRetries  int
 	isActive    bool
+	group       string
+	bucket      *limiter.TokenBucket
 	mu          sync.RWMutex
 	lastRequest time.Time
 }
@@ -298,12 +381,15 @@ func NewFrequencyControlForHistoricalProvider(p provider.HistoricalProvider, con
 			Enabled:     true,
 		}
 	}
+	group, bucket := frequencyBucket(config, p)
 	return &FrequencyControlForHistoricalProvider{
 		HistoricalProvider: p,
 		BaseDecorator:      provider.NewBaseDecorator(p),
 		minInterval:        config.MinInterval,
 		maxRetries:         config.MaxRetries,
 		isActive:           config.Enabled,
+		group:              group,
+		bucket:             bucket,
 	}
 }
 
@@ -335,6 +421,17 @@ func (f *FrequencyControlForHistoricalProvider) FetchHistoricalData(ctx context.
 }
 
 func (f *FrequencyControlForHistoricalProvider) enforceFrequencyLimit(ctx context.Context) error {
+	if f.bucket != nil {
+		now, err := waitBucket(ctx, f.bucket)
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.lastRequest = now
+		f.mu.Unlock()
+		return nil
+	}
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
@@ -362,3 +459,104 @@ func (f *FrequencyControlForHistoricalProvider) IsHealthy() bool {
 func (f *FrequencyControlForHistoricalProvider) Name() string {
 	return fmt.Sprintf("FrequencyControl(%s)", f.HistoricalProvider.Name())
 }
+
+// FrequencyControlForIndexProvider 是为实时指数数据提供商设计的频率控制装饰器
+type FrequencyControlForIndexProvider struct {
+	provider.RealtimeIndexProvider
+	*provider.BaseDecorator
+	minInterval time.Duration
+	maxRetries  int
+	isActive    bool
+	group       string
+	bucket      *limiter.TokenBucket
+	mu          sync.RWMutex
+	lastRequest time.Time
+}
+
+// NewFrequencyControlForIndexProvider 创建一个新的指数数据频率控制装饰器
+func NewFrequencyControlForIndexProvider(p provider.RealtimeIndexProvider, config *FrequencyControlConfig) *FrequencyControlForIndexProvider {
+	if config == nil {
+		config = &FrequencyControlConfig{
+			MinInterval: 500 * time.Millisecond, // 指数数据更新较慢，默认间隔500ms
+			MaxRetries:  3,
+			Enabled:     true,
+		}
+	}
+	group, bucket := frequencyBucket(config, p)
+	return &FrequencyControlForIndexProvider{
+		RealtimeIndexProvider: p,
+		BaseDecorator:         provider.NewBaseDecorator(p),
+		minInterval:           config.MinInterval,
+		maxRetries:            config.MaxRetries,
+		isActive:              config.Enabled,
+		group:                 group,
+		bucket:                bucket,
+	}
+}
+
+// FetchIndexData 实现带频率控制的指数数据获取
+func (f *FrequencyControlForIndexProvider) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	if !f.isActive {
+		return f.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+	}
+
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if err := f.enforceFrequencyLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		data, err := f.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+		if err == nil {
+			return data, nil
+		}
+
+		// 简单的重试逻辑，与历史数据频率控制一致
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond): // 重试前等待
+			continue
+		}
+	}
+	return nil, fmt.Errorf("获取指数数据失败，已达到最大重试次数 (%d)", f.maxRetries)
+}
+
+func (f *FrequencyControlForIndexProvider) enforceFrequencyLimit(ctx context.Context) error {
+	if f.bucket != nil {
+		now, err := waitBucket(ctx, f.bucket)
+		if err != nil {
+			return err
+		}
+		f.mu.Lock()
+		f.lastRequest = now
+		f.mu.Unlock()
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	elapsed := time.Since(f.lastRequest)
+	if elapsed < f.minInterval {
+		waitTime := f.minInterval - elapsed
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitTime):
+		}
+	}
+	f.lastRequest = time.Now()
+	return nil
+}
+
+func (f *FrequencyControlForIndexProvider) GetRateLimit() time.Duration {
+	return f.minInterval
+}
+
+func (f *FrequencyControlForIndexProvider) IsHealthy() bool {
+	return f.RealtimeIndexProvider.IsHealthy()
+}
+
+func (f *FrequencyControlForIndexProvider) Name() string {
+	return fmt.Sprintf("FrequencyControl(%s)", f.RealtimeIndexProvider.Name())
+}
@@ -0,0 +1,192 @@
+package decorators
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/limiter"
+	"stocksub/pkg/provider"
+	"stocksub/pkg/timing"
+)
+
+// tradingClock 固定在交易时段内的时间服务，避免智能限流器在非交易时间拒绝请求
+type tradingClock struct{}
+
+func (tradingClock) Now() time.Time {
+	return time.Date(2025, 8, 20, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+}
+
+// callRecorder 记录所有被装饰提供商的请求时间
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []time.Time
+}
+
+func (r *callRecorder) record() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, time.Now())
+}
+
+// minGap 返回相邻两次请求的最小间隔
+func (r *callRecorder) minGap() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	calls := append([]time.Time(nil), r.calls...)
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Before(calls[j]) })
+	gap := time.Duration(1<<63 - 1)
+	for i := 1; i < len(calls); i++ {
+		gap = min(gap, calls[i].Sub(calls[i-1]))
+	}
+	return gap
+}
+
+// hostProvider 请求同一上游主机的实时提供商
+type hostProvider struct {
+	MockRealtimeProvider
+	host     string
+	recorder *callRecorder
+}
+
+func (p *hostProvider) UpstreamHost() string { return p.host }
+
+func (p *hostProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	p.recorder.record()
+	return []core.StockData{{Symbol: symbols[0]}}, nil
+}
+
+// hostIndexProvider 请求同一上游主机的指数提供商
+type hostIndexProvider struct {
+	MockIndexProvider
+	host     string
+	recorder *callRecorder
+}
+
+func (p *hostIndexProvider) UpstreamHost() string { return p.host }
+
+func (p *hostIndexProvider) FetchIndexData(ctx context.Context, symbols []string) ([]core.IndexData, error) {
+	p.recorder.record()
+	return []core.IndexData{{Symbol: symbols[0]}}, nil
+}
+
+func newTestFrequencyControl(p provider.RealtimeStockProvider, config *FrequencyControlConfig) *FrequencyControlProvider {
+	f := NewFrequencyControlProvider(p, config)
+	f.limiter = limiter.NewIntelligentLimiter(timing.NewMarketTime(tradingClock{}))
+	return f
+}
+
+// runConcurrently 每个函数并发调用 n 次
+func runConcurrently(t *testing.T, n int, fns ...func() error) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(fn func() error) {
+				defer wg.Done()
+				assert.NoError(t, fn())
+			}(fn)
+		}
+	}
+	wg.Wait()
+}
+
+func TestFrequencyControl_SharedGroupSpacesCombinedCalls(t *testing.T) {
+	recorder := &callRecorder{}
+	config := &FrequencyControlConfig{
+		MinInterval: 25 * time.Millisecond, MaxRetries: 0, Enabled: true, LimiterGroup: t.Name(),
+	}
+	realtime := newTestFrequencyControl(&hostProvider{recorder: recorder}, config)
+	index := NewFrequencyControlForIndexProvider(&hostIndexProvider{recorder: recorder}, config)
+	ctx := context.Background()
+
+	start := time.Now()
+	runConcurrently(t, 4,
+		func() error { _, err := realtime.FetchStockData(ctx, []string{"600000"}); return err },
+		func() error { _, err := index.FetchIndexData(ctx, []string{"sh000001"}); return err },
+	)
+
+	require.Len(t, recorder.calls, 8)
+	// 两个装饰器合计每 25ms 一次，第一个请求使用初始令牌
+	assert.GreaterOrEqual(t, recorder.minGap(), 20*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 7*25*time.Millisecond-10*time.Millisecond)
+	assert.Same(t, realtime.bucket, index.bucket)
+
+	status := realtime.GetStatus()
+	assert.Equal(t, t.Name(), status["limiter_group"])
+	assert.Equal(t, 0, status["waiters"])
+	assert.Contains(t, status, "tokens_available")
+}
+
+func TestFrequencyControl_GroupByUpstreamHost(t *testing.T) {
+	recorder := &callRecorder{}
+	host := "qt.example.test." + t.Name()
+	config := &FrequencyControlConfig{
+		MinInterval: 20 * time.Millisecond, Enabled: true, LimiterGroup: LimiterGroupHost,
+	}
+	first := newTestFrequencyControl(&hostProvider{host: host, recorder: recorder}, config)
+	// 外层还有其他装饰器时穿过装饰器查找上游主机
+	second := newTestFrequencyControl(NewTimeoutProvider(&hostProvider{host: host, recorder: recorder}, nil), config)
+	other := newTestFrequencyControl(&hostProvider{host: "other." + host, recorder: &callRecorder{}}, config)
+
+	assert.Equal(t, host, first.group)
+	assert.Same(t, first.bucket, second.bucket)
+	assert.NotSame(t, first.bucket, other.bucket)
+
+	ctx := context.Background()
+	runConcurrently(t, 3,
+		func() error { _, err := first.FetchStockData(ctx, []string{"600000"}); return err },
+		func() error { _, err := second.FetchStockData(ctx, []string{"000001"}); return err },
+	)
+	require.Len(t, recorder.calls, 6)
+	assert.GreaterOrEqual(t, recorder.minGap(), 15*time.Millisecond)
+}
+
+func TestFrequencyControl_BurstAllowance(t *testing.T) {
+	recorder := &callRecorder{}
+	f := newTestFrequencyControl(&hostProvider{recorder: recorder}, &FrequencyControlConfig{
+		MinInterval: 50 * time.Millisecond, Enabled: true, BurstSize: 3, RequestsPerInterval: 1,
+	})
+	assert.Empty(t, f.group, "没有分组时令牌桶不共享")
+	require.NotNil(t, f.bucket)
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := f.FetchStockData(ctx, []string{"600000"})
+		require.NoError(t, err)
+	}
+	assert.Less(t, time.Since(start), 30*time.Millisecond, "突发额度内的请求不等待")
+
+	_, err := f.FetchStockData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	assert.Equal(t, 3, f.GetStatus()["burst_size"])
+}
+
+func TestFrequencyControl_NoGroupKeepsIndependentIntervals(t *testing.T) {
+	recorder := &callRecorder{}
+	config := &FrequencyControlConfig{MinInterval: 100 * time.Millisecond, Enabled: true}
+	first := newTestFrequencyControl(&hostProvider{host: "same.host", recorder: recorder}, config)
+	second := newTestFrequencyControl(&hostProvider{host: "same.host", recorder: recorder}, config)
+	assert.Nil(t, first.bucket)
+
+	ctx := context.Background()
+	start := time.Now()
+	runConcurrently(t, 1,
+		func() error { _, err := first.FetchStockData(ctx, []string{"600000"}); return err },
+		func() error { _, err := second.FetchStockData(ctx, []string{"000001"}); return err },
+	)
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "不同装饰器各自计时")
+
+	status := first.GetStatus()
+	assert.Equal(t, "", status["limiter_group"])
+	assert.NotContains(t, status, "tokens_available")
+}
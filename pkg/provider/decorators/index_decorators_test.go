@@ -0,0 +1,102 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockIndexProvider 是一个用于测试的模拟指数提供商，err 非空时每次请求都失败
+type MockIndexProvider struct {
+	err   error
+	calls int
+}
+
+func (m *MockIndexProvider) Name() string                       { return "mock-index" }
+func (m *MockIndexProvider) IsHealthy() bool                    { return true }
+func (m *MockIndexProvider) GetRateLimit() time.Duration        { return 100 * time.Millisecond }
+func (m *MockIndexProvider) IsIndexSupported(index string) bool { return true }
+func (m *MockIndexProvider) FetchIndexData(ctx context.Context, symbols []string) ([]core.IndexData, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	data := make([]core.IndexData, len(symbols))
+	for i, symbol := range symbols {
+		data[i] = core.IndexData{Symbol: symbol, Value: 3000}
+	}
+	return data, nil
+}
+
+func TestFrequencyControlForIndexProvider_DelaysRequests(t *testing.T) {
+	fc := NewFrequencyControlForIndexProvider(&MockIndexProvider{}, &FrequencyControlConfig{MinInterval: 50 * time.Millisecond, Enabled: true})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		data, err := fc.FetchIndexData(context.Background(), []string{"sh000001"})
+		require.NoError(t, err)
+		assert.Len(t, data, 1)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "second and third requests wait for the min interval")
+	assert.Equal(t, "FrequencyControl(mock-index)", fc.Name())
+	assert.Equal(t, 50*time.Millisecond, fc.GetRateLimit())
+	assert.True(t, fc.IsIndexSupported("sh000001"))
+}
+
+func TestCircuitBreakerForIndexProvider_OpensOnFailures(t *testing.T) {
+	base := &MockIndexProvider{err: errors.New("upstream error")}
+	config := DefaultCircuitBreakerConfig()
+	config.ReadyToTrip = 3
+	cb := NewCircuitBreakerForIndexProvider(base, config)
+
+	for i := 0; i < 3; i++ {
+		_, err := cb.FetchIndexData(context.Background(), []string{"sh000001"})
+		assert.EqualError(t, err, "upstream error")
+	}
+	assert.Equal(t, gobreaker.StateOpen, cb.GetState())
+	assert.False(t, cb.IsHealthy())
+
+	_, err := cb.FetchIndexData(context.Background(), []string{"sh000001"})
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.Equal(t, 3, base.calls, "open breaker short-circuits the request")
+}
+
+func TestCreateDecoratedProvider_IndexProvider(t *testing.T) {
+	config := provider.ProviderDecoratorConfig{
+		Index: []provider.DecoratorConfig{
+			{Type: provider.FrequencyControlType, Enabled: true, Priority: 1, ProviderType: "index", Config: map[string]interface{}{"min_interval_ms": 10}},
+			{Type: provider.CircuitBreakerType, Enabled: true, Priority: 2, ProviderType: "index"},
+		},
+		Realtime: []provider.DecoratorConfig{
+			{Type: provider.TimeoutType, Enabled: true, ProviderType: "realtime"},
+		},
+	}
+
+	chain := NewConfigurableDecoratorChain()
+	chain.LoadFromConfig(config)
+	assert.Equal(t, []provider.DecoratorType{provider.FrequencyControlType, provider.CircuitBreakerType},
+		chain.GetAppliedDecorators(&MockIndexProvider{}))
+
+	decorated, err := chain.Apply(&MockIndexProvider{})
+	require.NoError(t, err)
+	indexProvider, ok := decorated.(provider.RealtimeIndexProvider)
+	require.True(t, ok, "decorated index provider keeps the RealtimeIndexProvider interface")
+	assert.Equal(t, "CircuitBreaker(FrequencyControl(mock-index))", indexProvider.Name())
+
+	data, err := indexProvider.FetchIndexData(context.Background(), []string{"sh000001", "sz399001"})
+	require.NoError(t, err)
+	assert.Len(t, data, 2)
+
+	// 默认配置中 all 类型的装饰器也能应用到指数提供商
+	decorated, err = CreateDecoratedProvider(&MockIndexProvider{}, DefaultDecoratorConfig())
+	require.NoError(t, err)
+	_, ok = decorated.(provider.RealtimeIndexProvider)
+	assert.True(t, ok)
+}
@@ -0,0 +1,411 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsNamespace Prometheus 指标前缀
+const metricsNamespace = "stocksub_provider"
+
+// MetricsConfig 指标装饰器配置
+type MetricsConfig struct {
+	Name       string                `yaml:"name"`    // 指标中的提供商名称，为空时取最内层提供商的 Name()
+	Registerer prometheus.Registerer `yaml:"-"`       // 非空时注册 Prometheus 指标
+	Enabled    bool                  `yaml:"enabled"` // 是否启用
+}
+
+// MethodMetrics 某个方法在某个代码数量区间内的统计
+type MethodMetrics struct {
+	Method       string        `json:"method"`
+	SymbolBucket string        `json:"symbol_bucket"`
+	Requests     int64         `json:"requests"`
+	Errors       int64         `json:"errors"`
+	Partial      int64         `json:"partial"` // 部分代码未获取到、其余数据正常返回的请求，不计入 Errors
+	TotalLatency time.Duration `json:"total_latency"`
+	MaxLatency   time.Duration `json:"max_latency"`
+}
+
+// ErrorRate 错误率
+func (m MethodMetrics) ErrorRate() float64 {
+	if m.Requests == 0 {
+		return 0
+	}
+	return float64(m.Errors) / float64(m.Requests)
+}
+
+// AvgLatency 平均耗时
+func (m MethodMetrics) AvgLatency() time.Duration {
+	if m.Requests == 0 {
+		return 0
+	}
+	return m.TotalLatency / time.Duration(m.Requests)
+}
+
+// MetricsSnapshot 提供商指标快照
+type MetricsSnapshot struct {
+	Provider string          `json:"provider"`
+	Methods  []MethodMetrics `json:"methods"` // 按 Method、SymbolBucket 排序
+}
+
+// Totals 汇总所有方法的请求数、错误数和平均耗时
+func (s MetricsSnapshot) Totals() MethodMetrics {
+	total := MethodMetrics{Method: "all"}
+	for _, m := range s.Methods {
+		total.Requests += m.Requests
+		total.Errors += m.Errors
+		total.Partial += m.Partial
+		total.TotalLatency += m.TotalLatency
+		if m.MaxLatency > total.MaxLatency {
+			total.MaxLatency = m.MaxLatency
+		}
+	}
+	return total
+}
+
+// MetricsReporter 可以导出指标快照的装饰器
+type MetricsReporter interface {
+	GetMetricsSnapshot() MetricsSnapshot
+}
+
+// FindMetricsReporter 沿装饰器链查找指标装饰器，未找到时返回 nil
+func FindMetricsReporter(p provider.Provider) MetricsReporter {
+	for p != nil {
+		if reporter, ok := p.(MetricsReporter); ok {
+			return reporter
+		}
+		decorator, ok := p.(provider.Decorator)
+		if !ok {
+			return nil
+		}
+		p = decorator.GetBaseProvider()
+	}
+	return nil
+}
+
+// symbolBucket 将代码数量归入固定区间，控制标签基数
+func symbolBucket(n int) string {
+	switch {
+	case n <= 1:
+		return "1"
+	case n <= 10:
+		return "2-10"
+	case n <= 100:
+		return "11-100"
+	default:
+		return "100+"
+	}
+}
+
+type metricsKey struct {
+	method string
+	bucket string
+}
+
+// promMetrics 所有指标装饰器共享的 Prometheus 指标
+type promMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	partial  *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// newPromMetrics 在 reg 上注册指标，同一注册表上已注册时复用已有指标
+func newPromMetrics(reg prometheus.Registerer) (*promMetrics, error) {
+	labels := []string{"provider", "method", "symbols"}
+	m := &promMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Total number of provider requests by provider, method and symbol count bucket.",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "errors_total",
+			Help:      "Total number of failed provider requests by provider, method and symbol count bucket.",
+		}, labels),
+		partial: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "partial_failures_total",
+			Help:      "Total number of provider requests that returned data for only part of the symbols.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Provider request latency in seconds by provider, method and symbol count bucket.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+	}
+
+	var err error
+	if m.requests, err = registerOrExisting(reg, m.requests); err != nil {
+		return nil, err
+	}
+	if m.errors, err = registerOrExisting(reg, m.errors); err != nil {
+		return nil, err
+	}
+	if m.partial, err = registerOrExisting(reg, m.partial); err != nil {
+		return nil, err
+	}
+	if m.latency, err = registerOrExisting(reg, m.latency); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func registerOrExisting[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if err := reg.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// metricsRecorder 记录请求次数、错误次数和耗时，由各类型的指标装饰器共用
+type metricsRecorder struct {
+	name    string
+	enabled bool
+	prom    *promMetrics
+
+	mu    sync.Mutex
+	stats map[metricsKey]*MethodMetrics
+}
+
+func newMetricsRecorder(base provider.Provider, config *MetricsConfig) (*metricsRecorder, error) {
+	if config == nil {
+		config = &MetricsConfig{Enabled: true}
+	}
+
+	name := config.Name
+	if name == "" {
+		name = innermostName(base)
+	}
+
+	r := &metricsRecorder{
+		name:    name,
+		enabled: config.Enabled,
+		stats:   make(map[metricsKey]*MethodMetrics),
+	}
+	if config.Registerer != nil {
+		prom, err := newPromMetrics(config.Registerer)
+		if err != nil {
+			return nil, fmt.Errorf("注册提供商指标失败: %w", err)
+		}
+		r.prom = prom
+	}
+	return r, nil
+}
+
+// innermostName 返回装饰器链最内层提供商的名称
+func innermostName(p provider.Provider) string {
+	for {
+		decorator, ok := p.(provider.Decorator)
+		if !ok || decorator.GetBaseProvider() == nil {
+			return p.Name()
+		}
+		p = decorator.GetBaseProvider()
+	}
+}
+
+// observe 记录一次请求，*PartialFailureError 计为部分失败而不是错误
+func (r *metricsRecorder) observe(method string, symbols int, start time.Time, err error) {
+	if !r.enabled {
+		return
+	}
+
+	elapsed := time.Since(start)
+	bucket := symbolBucket(symbols)
+	var partialErr *PartialFailureError
+	partial := errors.As(err, &partialErr)
+
+	r.mu.Lock()
+	key := metricsKey{method: method, bucket: bucket}
+	stats, ok := r.stats[key]
+	if !ok {
+		stats = &MethodMetrics{Method: method, SymbolBucket: bucket}
+		r.stats[key] = stats
+	}
+	stats.Requests++
+	stats.TotalLatency += elapsed
+	if elapsed > stats.MaxLatency {
+		stats.MaxLatency = elapsed
+	}
+	switch {
+	case partial:
+		stats.Partial++
+	case err != nil:
+		stats.Errors++
+	}
+	r.mu.Unlock()
+
+	if r.prom != nil {
+		r.prom.requests.WithLabelValues(r.name, method, bucket).Inc()
+		r.prom.latency.WithLabelValues(r.name, method, bucket).Observe(elapsed.Seconds())
+		switch {
+		case partial:
+			r.prom.partial.WithLabelValues(r.name, method, bucket).Inc()
+		case err != nil:
+			r.prom.errors.WithLabelValues(r.name, method, bucket).Inc()
+		}
+	}
+}
+
+// GetMetricsSnapshot 返回当前的指标快照
+func (r *metricsRecorder) GetMetricsSnapshot() MetricsSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := MetricsSnapshot{Provider: r.name, Methods: make([]MethodMetrics, 0, len(r.stats))}
+	for _, stats := range r.stats {
+		snapshot.Methods = append(snapshot.Methods, *stats)
+	}
+	sort.Slice(snapshot.Methods, func(i, j int) bool {
+		if snapshot.Methods[i].Method != snapshot.Methods[j].Method {
+			return snapshot.Methods[i].Method < snapshot.Methods[j].Method
+		}
+		return snapshot.Methods[i].SymbolBucket < snapshot.Methods[j].SymbolBucket
+	})
+	return snapshot
+}
+
+// MetricsProvider 实时股票数据指标装饰器
+type MetricsProvider struct {
+	provider.RealtimeStockProvider
+	*provider.BaseDecorator
+	*metricsRecorder
+}
+
+// NewMetricsProvider 创建实时股票数据指标装饰器
+func NewMetricsProvider(stockProvider provider.RealtimeStockProvider, config *MetricsConfig) (*MetricsProvider, error) {
+	recorder, err := newMetricsRecorder(stockProvider, config)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricsProvider{
+		RealtimeStockProvider: stockProvider,
+		BaseDecorator:         provider.NewBaseDecorator(stockProvider),
+		metricsRecorder:       recorder,
+	}, nil
+}
+
+// Name 返回装饰器名称
+func (m *MetricsProvider) Name() string {
+	return fmt.Sprintf("Metrics(%s)", m.RealtimeStockProvider.Name())
+}
+
+func (m *MetricsProvider) GetRateLimit() time.Duration {
+	return m.RealtimeStockProvider.GetRateLimit()
+}
+
+func (m *MetricsProvider) IsHealthy() bool {
+	return m.RealtimeStockProvider.IsHealthy()
+}
+
+// FetchStockData 记录指标的股票数据获取
+func (m *MetricsProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	start := time.Now()
+	data, err := m.RealtimeStockProvider.FetchStockData(ctx, symbols)
+	m.observe("FetchStockData", len(symbols), start, err)
+	return data, err
+}
+
+// FetchStockDataWithRaw 记录指标的股票数据获取（包含原始数据）
+func (m *MetricsProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	start := time.Now()
+	data, raw, err := m.RealtimeStockProvider.FetchStockDataWithRaw(ctx, symbols)
+	m.observe("FetchStockDataWithRaw", len(symbols), start, err)
+	return data, raw, err
+}
+
+// MetricsForHistoricalProvider 历史数据指标装饰器
+type MetricsForHistoricalProvider struct {
+	provider.HistoricalProvider
+	*provider.BaseDecorator
+	*metricsRecorder
+}
+
+// NewMetricsForHistoricalProvider 创建历史数据指标装饰器
+func NewMetricsForHistoricalProvider(p provider.HistoricalProvider, config *MetricsConfig) (*MetricsForHistoricalProvider, error) {
+	recorder, err := newMetricsRecorder(p, config)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricsForHistoricalProvider{
+		HistoricalProvider: p,
+		BaseDecorator:      provider.NewBaseDecorator(p),
+		metricsRecorder:    recorder,
+	}, nil
+}
+
+func (m *MetricsForHistoricalProvider) Name() string {
+	return fmt.Sprintf("Metrics(%s)", m.HistoricalProvider.Name())
+}
+
+func (m *MetricsForHistoricalProvider) GetRateLimit() time.Duration {
+	return m.HistoricalProvider.GetRateLimit()
+}
+
+func (m *MetricsForHistoricalProvider) IsHealthy() bool {
+	return m.HistoricalProvider.IsHealthy()
+}
+
+// FetchHistoricalData 记录指标的历史数据获取
+func (m *MetricsForHistoricalProvider) FetchHistoricalData(ctx context.Context, symbol string, start, end time.Time, period string) ([]core.HistoricalData, error) {
+	begin := time.Now()
+	data, err := m.HistoricalProvider.FetchHistoricalData(ctx, symbol, start, end, period)
+	m.observe("FetchHistoricalData", 1, begin, err)
+	return data, err
+}
+
+// MetricsForIndexProvider 实时指数数据指标装饰器
+type MetricsForIndexProvider struct {
+	provider.RealtimeIndexProvider
+	*provider.BaseDecorator
+	*metricsRecorder
+}
+
+// NewMetricsForIndexProvider 创建实时指数数据指标装饰器
+func NewMetricsForIndexProvider(p provider.RealtimeIndexProvider, config *MetricsConfig) (*MetricsForIndexProvider, error) {
+	recorder, err := newMetricsRecorder(p, config)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricsForIndexProvider{
+		RealtimeIndexProvider: p,
+		BaseDecorator:         provider.NewBaseDecorator(p),
+		metricsRecorder:       recorder,
+	}, nil
+}
+
+func (m *MetricsForIndexProvider) Name() string {
+	return fmt.Sprintf("Metrics(%s)", m.RealtimeIndexProvider.Name())
+}
+
+func (m *MetricsForIndexProvider) GetRateLimit() time.Duration {
+	return m.RealtimeIndexProvider.GetRateLimit()
+}
+
+func (m *MetricsForIndexProvider) IsHealthy() bool {
+	return m.RealtimeIndexProvider.IsHealthy()
+}
+
+// FetchIndexData 记录指标的指数数据获取
+func (m *MetricsForIndexProvider) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	start := time.Now()
+	data, err := m.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+	m.observe("FetchIndexData", len(indexSymbols), start, err)
+	return data, err
+}
@@ -0,0 +1,119 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"stocksub/pkg/provider"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsProvider_CountsSuccessAndFailure(t *testing.T) {
+	base := &flakyProvider{failures: 1, err: errors.New("upstream error")}
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetricsProvider(base, &MetricsConfig{Registerer: registry, Enabled: true})
+	require.NoError(t, err)
+
+	_, err = metrics.FetchStockData(context.Background(), []string{"600000"})
+	assert.Error(t, err)
+	_, err = metrics.FetchStockData(context.Background(), []string{"600000"})
+	assert.NoError(t, err)
+	_, _, err = metrics.FetchStockDataWithRaw(context.Background(), []string{"600000", "000001", "000002"})
+	assert.NoError(t, err)
+
+	snapshot := metrics.GetMetricsSnapshot()
+	assert.Equal(t, "mock-realtime", snapshot.Provider)
+	require.Len(t, snapshot.Methods, 2)
+	assert.Equal(t, MethodMetrics{Method: "FetchStockData", SymbolBucket: "1", Requests: 2, Errors: 1},
+		withoutLatency(snapshot.Methods[0]))
+	assert.Equal(t, 0.5, snapshot.Methods[0].ErrorRate())
+	assert.Equal(t, "2-10", snapshot.Methods[1].SymbolBucket)
+
+	totals := snapshot.Totals()
+	assert.Equal(t, int64(3), totals.Requests)
+	assert.Equal(t, int64(1), totals.Errors)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.prom.requests.WithLabelValues("mock-realtime", "FetchStockData", "1")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.prom.errors.WithLabelValues("mock-realtime", "FetchStockData", "1")))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.prom.latency), "one histogram per method and symbol bucket")
+}
+
+func TestMetricsProvider_CountsPartialFailure(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics, err := NewMetricsProvider(newPartitionedBreaker(&bjRejectingProvider{rejectBJ: true}),
+		&MetricsConfig{Name: "tencent", Registerer: registry, Enabled: true})
+	require.NoError(t, err)
+
+	data, err := metrics.FetchStockData(context.Background(), []string{"000001", "830799"})
+	var partialErr *PartialFailureError
+	require.ErrorAs(t, err, &partialErr)
+	assert.Len(t, data, 1, "部分失败时返回已获取的数据")
+
+	totals := metrics.GetMetricsSnapshot().Totals()
+	assert.Equal(t, int64(1), totals.Requests)
+	assert.Equal(t, int64(1), totals.Partial)
+	assert.Zero(t, totals.Errors, "部分失败不计入错误")
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.prom.partial.WithLabelValues("tencent", "FetchStockData", "2-10")))
+	assert.Zero(t, testutil.ToFloat64(metrics.prom.errors.WithLabelValues("tencent", "FetchStockData", "2-10")))
+}
+
+func withoutLatency(m MethodMetrics) MethodMetrics {
+	m.TotalLatency, m.MaxLatency = 0, 0
+	return m
+}
+
+func TestMetricsProvider_SharedRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	realtime, err := NewMetricsProvider(&MockRealtimeProvider{}, &MetricsConfig{Registerer: registry, Enabled: true})
+	require.NoError(t, err)
+	historical, err := NewMetricsForHistoricalProvider(&MockHistoricalProvider{}, &MetricsConfig{Registerer: registry, Enabled: true})
+	require.NoError(t, err, "a second provider reuses the already registered collectors")
+
+	_, err = realtime.FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	_, err = historical.FetchHistoricalData(context.Background(), "600000", time.Now(), time.Now(), "1d")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(realtime.prom.requests.WithLabelValues("mock-historical", "FetchHistoricalData", "1")))
+	assert.Equal(t, 2, testutil.CollectAndCount(realtime.prom.requests))
+}
+
+func TestMetricsProvider_Disabled(t *testing.T) {
+	metrics, err := NewMetricsProvider(&MockRealtimeProvider{}, &MetricsConfig{Enabled: false})
+	require.NoError(t, err)
+
+	_, err = metrics.FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Empty(t, metrics.GetMetricsSnapshot().Methods)
+}
+
+func TestCreateDecorator_MetricsInChain(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	config := provider.ProviderDecoratorConfig{
+		All: []provider.DecoratorConfig{
+			{Type: provider.MetricsType, Enabled: true, Priority: 0, ProviderType: "all", Config: map[string]interface{}{"registry": registry}},
+			{Type: provider.RetryType, Enabled: true, Priority: 1, ProviderType: "all"},
+		},
+	}
+
+	decorated, err := CreateDecoratedProvider(&MockRealtimeProvider{}, config)
+	require.NoError(t, err)
+	reporter := FindMetricsReporter(decorated)
+	require.NotNil(t, reporter, "metrics decorator is found beneath the retry decorator")
+
+	_, err = decorated.(provider.RealtimeStockProvider).FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reporter.GetMetricsSnapshot().Totals().Requests)
+	assert.Equal(t, "mock-realtime", reporter.GetMetricsSnapshot().Provider)
+
+	assert.Nil(t, FindMetricsReporter(&MockRealtimeProvider{}))
+
+	chain := NewConfigurableDecoratorChain()
+	chain.LoadFromConfig(MonitoringDecoratorConfig())
+	assert.Equal(t, provider.MetricsType, chain.GetAppliedDecorators(&MockRealtimeProvider{})[0])
+}
@@ -0,0 +1,231 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"time"
+)
+
+// RetryConfig 重试装饰器配置
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`    // 最大尝试次数（含首次请求）
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // 首次重试前的等待时间
+	Multiplier     float64       `yaml:"multiplier"`      // 退避倍数
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // 单次等待上限
+	Jitter         float64       `yaml:"jitter"`          // 抖动比例 [0,1]，等待时间在 ±Jitter 范围内随机
+	Enabled        bool          `yaml:"enabled"`         // 是否启用
+}
+
+// DefaultRetryConfig 默认重试配置
+func DefaultRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxAttempts:    3,                      // 最多请求3次
+		InitialBackoff: 200 * time.Millisecond, // 首次重试等待200ms
+		Multiplier:     2,                      // 每次翻倍
+		MaxBackoff:     2 * time.Second,        // 最长等待2秒
+		Jitter:         0.2,                    // ±20% 抖动
+		Enabled:        true,                   // 默认启用
+	}
+}
+
+// retrier 指数退避重试逻辑，由各类型的重试装饰器共用
+type retrier struct {
+	config *RetryConfig
+	// sleep 等待 d 或直到 ctx 结束，测试时可替换
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetrier(config *RetryConfig) *retrier {
+	if config == nil {
+		config = DefaultRetryConfig()
+	}
+	return &retrier{config: config, sleep: sleepContext}
+}
+
+// do 执行 fn，遇到可重试错误时按指数退避重试，不可重试的错误立即返回。
+// *PartialFailureError 也立即返回：其余分区的数据已经获取，重试整批会重复请求正常的分区，
+// 失败的代码由调用方（如 FailoverProvider）向其他提供商补齐
+func (r *retrier) do(ctx context.Context, fn func() error) error {
+	if !r.config.Enabled || r.config.MaxAttempts <= 1 {
+		return fn()
+	}
+
+	var err error
+	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		var partialErr *PartialFailureError
+		if err = fn(); err == nil || !core.IsRetryable(err) || errors.As(err, &partialErr) {
+			return err
+		}
+		if attempt == r.config.MaxAttempts {
+			break
+		}
+		if sleepErr := r.sleep(ctx, r.backoff(attempt)); sleepErr != nil {
+			return fmt.Errorf("重试等待被取消: %w (上次错误: %v)", sleepErr, err)
+		}
+	}
+	return fmt.Errorf("已达到最大尝试次数 (%d): %w", r.config.MaxAttempts, err)
+}
+
+// backoff 返回第 attempt 次失败后的等待时间
+func (r *retrier) backoff(attempt int) time.Duration {
+	multiplier := r.config.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(r.config.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if r.config.MaxBackoff > 0 && d > float64(r.config.MaxBackoff) {
+		d = float64(r.config.MaxBackoff)
+	}
+	if r.config.Jitter > 0 {
+		d *= 1 + r.config.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(d)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RetryProvider 实时股票数据重试装饰器
+type RetryProvider struct {
+	provider.RealtimeStockProvider
+	*provider.BaseDecorator
+	*retrier
+}
+
+// NewRetryProvider 创建实时股票数据重试装饰器
+func NewRetryProvider(stockProvider provider.RealtimeStockProvider, config *RetryConfig) *RetryProvider {
+	return &RetryProvider{
+		RealtimeStockProvider: stockProvider,
+		BaseDecorator:         provider.NewBaseDecorator(stockProvider),
+		retrier:               newRetrier(config),
+	}
+}
+
+// Name 返回装饰器名称
+func (r *RetryProvider) Name() string {
+	return fmt.Sprintf("Retry(%s)", r.RealtimeStockProvider.Name())
+}
+
+func (r *RetryProvider) GetRateLimit() time.Duration {
+	return r.RealtimeStockProvider.GetRateLimit()
+}
+
+func (r *RetryProvider) IsHealthy() bool {
+	return r.RealtimeStockProvider.IsHealthy()
+}
+
+// FetchStockData 实现带重试的股票数据获取
+func (r *RetryProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	var data []core.StockData
+	err := r.do(ctx, func() error {
+		var err error
+		data, err = r.RealtimeStockProvider.FetchStockData(ctx, symbols)
+		return err
+	})
+	return data, err
+}
+
+// FetchStockDataWithRaw 实现带重试的股票数据获取（包含原始数据）
+func (r *RetryProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	var (
+		data []core.StockData
+		raw  string
+	)
+	err := r.do(ctx, func() error {
+		var err error
+		data, raw, err = r.RealtimeStockProvider.FetchStockDataWithRaw(ctx, symbols)
+		return err
+	})
+	return data, raw, err
+}
+
+// RetryForHistoricalProvider 历史数据重试装饰器
+type RetryForHistoricalProvider struct {
+	provider.HistoricalProvider
+	*provider.BaseDecorator
+	*retrier
+}
+
+// NewRetryForHistoricalProvider 创建历史数据重试装饰器
+func NewRetryForHistoricalProvider(p provider.HistoricalProvider, config *RetryConfig) *RetryForHistoricalProvider {
+	return &RetryForHistoricalProvider{
+		HistoricalProvider: p,
+		BaseDecorator:      provider.NewBaseDecorator(p),
+		retrier:            newRetrier(config),
+	}
+}
+
+func (r *RetryForHistoricalProvider) Name() string {
+	return fmt.Sprintf("Retry(%s)", r.HistoricalProvider.Name())
+}
+
+func (r *RetryForHistoricalProvider) GetRateLimit() time.Duration {
+	return r.HistoricalProvider.GetRateLimit()
+}
+
+func (r *RetryForHistoricalProvider) IsHealthy() bool {
+	return r.HistoricalProvider.IsHealthy()
+}
+
+// FetchHistoricalData 实现带重试的历史数据获取
+func (r *RetryForHistoricalProvider) FetchHistoricalData(ctx context.Context, symbol string, start, end time.Time, period string) ([]core.HistoricalData, error) {
+	var data []core.HistoricalData
+	err := r.do(ctx, func() error {
+		var err error
+		data, err = r.HistoricalProvider.FetchHistoricalData(ctx, symbol, start, end, period)
+		return err
+	})
+	return data, err
+}
+
+// RetryForIndexProvider 实时指数数据重试装饰器
+type RetryForIndexProvider struct {
+	provider.RealtimeIndexProvider
+	*provider.BaseDecorator
+	*retrier
+}
+
+// NewRetryForIndexProvider 创建实时指数数据重试装饰器
+func NewRetryForIndexProvider(p provider.RealtimeIndexProvider, config *RetryConfig) *RetryForIndexProvider {
+	return &RetryForIndexProvider{
+		RealtimeIndexProvider: p,
+		BaseDecorator:         provider.NewBaseDecorator(p),
+		retrier:               newRetrier(config),
+	}
+}
+
+func (r *RetryForIndexProvider) Name() string {
+	return fmt.Sprintf("Retry(%s)", r.RealtimeIndexProvider.Name())
+}
+
+func (r *RetryForIndexProvider) GetRateLimit() time.Duration {
+	return r.RealtimeIndexProvider.GetRateLimit()
+}
+
+func (r *RetryForIndexProvider) IsHealthy() bool {
+	return r.RealtimeIndexProvider.IsHealthy()
+}
+
+// FetchIndexData 实现带重试的指数数据获取
+func (r *RetryForIndexProvider) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	var data []core.IndexData
+	err := r.do(ctx, func() error {
+		var err error
+		data, err = r.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+		return err
+	})
+	return data, err
+}
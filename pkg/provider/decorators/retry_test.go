@@ -0,0 +1,155 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyProvider 前 failures 次请求返回 err，之后成功
+type flakyProvider struct {
+	MockRealtimeProvider
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyProvider) FetchStockData(ctx context.Context, s []string) ([]core.StockData, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return f.MockRealtimeProvider.FetchStockData(ctx, s)
+}
+
+func (f *flakyProvider) FetchStockDataWithRaw(ctx context.Context, s []string) ([]core.StockData, string, error) {
+	data, err := f.FetchStockData(ctx, s)
+	return data, "raw", err
+}
+
+// newTestRetryProvider 创建不真正等待的重试装饰器，记录每次退避时长
+func newTestRetryProvider(p provider.RealtimeStockProvider, config *RetryConfig) (*RetryProvider, *[]time.Duration) {
+	retry := NewRetryProvider(p, config)
+	var waits []time.Duration
+	retry.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return retry, &waits
+}
+
+func TestRetryProvider_RetriesTransientErrors(t *testing.T) {
+	base := &flakyProvider{failures: 2, err: &core.HTTPStatusError{StatusCode: 502}}
+	config := DefaultRetryConfig()
+	config.Jitter = 0
+	retry, waits := newTestRetryProvider(base, config)
+
+	data, err := retry.FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Len(t, data, 1)
+	assert.Equal(t, 3, base.calls)
+	assert.Equal(t, []time.Duration{200 * time.Millisecond, 400 * time.Millisecond}, *waits)
+	assert.Equal(t, "Retry(mock-realtime)", retry.Name())
+}
+
+func TestRetryProvider_GivesUpAfterMaxAttempts(t *testing.T) {
+	timeout := fmt.Errorf("HTTP request failed: %w", context.DeadlineExceeded)
+	base := &flakyProvider{failures: 10, err: timeout}
+	retry, waits := newTestRetryProvider(base, &RetryConfig{MaxAttempts: 4, InitialBackoff: time.Second, Multiplier: 3, MaxBackoff: 5 * time.Second, Enabled: true})
+
+	_, _, err := retry.FetchStockDataWithRaw(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 4, base.calls)
+	assert.Equal(t, []time.Duration{time.Second, 3 * time.Second, 5 * time.Second}, *waits, "backoff is capped at MaxBackoff")
+}
+
+func TestRetryProvider_NonRetryableReturnsImmediately(t *testing.T) {
+	for _, err := range []error{
+		&core.HTTPStatusError{StatusCode: 404},
+		errors.New("parse response failed"),
+		context.Canceled,
+	} {
+		base := &flakyProvider{failures: 10, err: err}
+		retry, waits := newTestRetryProvider(base, DefaultRetryConfig())
+
+		_, got := retry.FetchStockData(context.Background(), []string{"600000"})
+		assert.Equal(t, err, got)
+		assert.Equal(t, 1, base.calls, err.Error())
+		assert.Empty(t, *waits)
+	}
+}
+
+// TestRetryProvider_PartialFailureReturnsDataWithoutRetry 熔断器部分分区失败时不重试整批，已获取的数据随错误返回
+func TestRetryProvider_PartialFailureReturnsDataWithoutRetry(t *testing.T) {
+	base := &bjRejectingProvider{rejectBJ: true, bjErr: &core.HTTPStatusError{StatusCode: 503}}
+	retry, waits := newTestRetryProvider(newPartitionedBreaker(base), DefaultRetryConfig())
+
+	data, err := retry.FetchStockData(context.Background(), []string{"000001", "830799"})
+	var partialErr *PartialFailureError
+	require.ErrorAs(t, err, &partialErr)
+	assert.Equal(t, []string{"830799"}, partialErr.FailedSymbols())
+	assert.Equal(t, []string{"000001"}, symbolsOf(data))
+	assert.Len(t, base.requests, 2, "每个分区只请求一次")
+	assert.Empty(t, *waits)
+}
+
+func TestRetryProvider_ContextCanceledDuringBackoff(t *testing.T) {
+	base := &flakyProvider{failures: 10, err: &core.HTTPStatusError{StatusCode: 503}}
+	config := DefaultRetryConfig()
+	config.InitialBackoff = time.Hour
+	retry := NewRetryProvider(base, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := retry.FetchStockData(ctx, []string{"600000"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, base.calls)
+}
+
+func TestRetryProvider_Disabled(t *testing.T) {
+	base := &flakyProvider{failures: 1, err: &core.HTTPStatusError{StatusCode: 500}}
+	retry, _ := newTestRetryProvider(base, &RetryConfig{MaxAttempts: 3, Enabled: false})
+
+	_, err := retry.FetchStockData(context.Background(), []string{"600000"})
+	assert.Error(t, err)
+	assert.Equal(t, 1, base.calls)
+}
+
+func TestRetryBackoffJitter(t *testing.T) {
+	r := newRetrier(&RetryConfig{InitialBackoff: 100 * time.Millisecond, Multiplier: 2, Jitter: 0.5, Enabled: true})
+	for i := 0; i < 100; i++ {
+		d := r.backoff(2)
+		assert.GreaterOrEqual(t, d, 100*time.Millisecond)
+		assert.LessOrEqual(t, d, 300*time.Millisecond)
+	}
+}
+
+func TestCreateDecorator_Retry(t *testing.T) {
+	decorated, err := CreateDecorator(provider.RetryType, &MockHistoricalProvider{}, map[string]interface{}{
+		"max_attempts":    5,
+		"initial_backoff": "1s",
+		"multiplier":      3,
+		"jitter":          0.1,
+	})
+	require.NoError(t, err)
+	historical, ok := decorated.(*RetryForHistoricalProvider)
+	require.True(t, ok)
+	assert.Equal(t, 5, historical.config.MaxAttempts)
+	assert.Equal(t, time.Second, historical.config.InitialBackoff)
+	assert.Equal(t, 3.0, historical.config.Multiplier)
+
+	chain := NewConfigurableDecoratorChain()
+	chain.LoadFromConfig(DefaultDecoratorConfig())
+	assert.Equal(t, []provider.DecoratorType{provider.TimeoutType, provider.FrequencyControlType, provider.RetryType, provider.CircuitBreakerType},
+		chain.GetAppliedDecorators(&MockRealtimeProvider{}))
+}
@@ -0,0 +1,215 @@
+package decorators
+
+import (
+	"context"
+	"fmt"
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"time"
+)
+
+// 各类型提供商的默认单次调用时限
+const (
+	DefaultRealtimeTimeout   = 5 * time.Second
+	DefaultHistoricalTimeout = 30 * time.Second
+	DefaultIndexTimeout      = 5 * time.Second
+)
+
+// TimeoutConfig 超时装饰器配置
+type TimeoutConfig struct {
+	Timeout time.Duration `yaml:"timeout"` // 单次调用时限
+	Enabled bool          `yaml:"enabled"` // 是否启用
+}
+
+// callWithTimeout 在独立的时限内执行 fn
+// fn 在单独的 goroutine 中运行，即使底层提供商不响应 ctx 也能按时返回；
+// 超时后取消传给 fn 的 ctx，结果通道带缓冲，fn 返回后 goroutine 即可退出。
+// 调用方 ctx 先结束时返回调用方的错误，自身时限到达时返回包装了 core.ErrTimeout 的错误。
+func callWithTimeout(ctx context.Context, config *TimeoutConfig, method string, fn func(ctx context.Context) error) error {
+	if !config.Enabled || config.Timeout <= 0 {
+		return fn(ctx)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(callCtx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() == nil && callCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("%w: %s 超过 %v: %v", core.ErrTimeout, method, config.Timeout, err)
+		}
+		return err
+	case <-callCtx.Done():
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s 超过 %v", core.ErrTimeout, method, config.Timeout)
+	}
+}
+
+// TimeoutProvider 实时股票数据超时装饰器
+type TimeoutProvider struct {
+	provider.RealtimeStockProvider
+	*provider.BaseDecorator
+	config *TimeoutConfig
+}
+
+// NewTimeoutProvider 创建实时股票数据超时装饰器
+func NewTimeoutProvider(stockProvider provider.RealtimeStockProvider, config *TimeoutConfig) *TimeoutProvider {
+	if config == nil {
+		config = &TimeoutConfig{Timeout: DefaultRealtimeTimeout, Enabled: true}
+	}
+	return &TimeoutProvider{
+		RealtimeStockProvider: stockProvider,
+		BaseDecorator:         provider.NewBaseDecorator(stockProvider),
+		config:                config,
+	}
+}
+
+// Name 返回装饰器名称
+func (t *TimeoutProvider) Name() string {
+	return fmt.Sprintf("Timeout(%s)", t.RealtimeStockProvider.Name())
+}
+
+func (t *TimeoutProvider) GetRateLimit() time.Duration {
+	return t.RealtimeStockProvider.GetRateLimit()
+}
+
+func (t *TimeoutProvider) IsHealthy() bool {
+	return t.RealtimeStockProvider.IsHealthy()
+}
+
+// FetchStockData 实现带时限的股票数据获取
+func (t *TimeoutProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	var data []core.StockData
+	err := callWithTimeout(ctx, t.config, "FetchStockData", func(ctx context.Context) error {
+		result, err := t.RealtimeStockProvider.FetchStockData(ctx, symbols)
+		if err == nil {
+			data = result
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FetchStockDataWithRaw 实现带时限的股票数据获取（包含原始数据）
+func (t *TimeoutProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	type result struct {
+		data []core.StockData
+		raw  string
+	}
+	var res result
+	err := callWithTimeout(ctx, t.config, "FetchStockDataWithRaw", func(ctx context.Context) error {
+		data, raw, err := t.RealtimeStockProvider.FetchStockDataWithRaw(ctx, symbols)
+		if err == nil {
+			res = result{data: data, raw: raw}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return res.data, res.raw, nil
+}
+
+// TimeoutForHistoricalProvider 历史数据超时装饰器
+type TimeoutForHistoricalProvider struct {
+	provider.HistoricalProvider
+	*provider.BaseDecorator
+	config *TimeoutConfig
+}
+
+// NewTimeoutForHistoricalProvider 创建历史数据超时装饰器
+func NewTimeoutForHistoricalProvider(p provider.HistoricalProvider, config *TimeoutConfig) *TimeoutForHistoricalProvider {
+	if config == nil {
+		config = &TimeoutConfig{Timeout: DefaultHistoricalTimeout, Enabled: true}
+	}
+	return &TimeoutForHistoricalProvider{
+		HistoricalProvider: p,
+		BaseDecorator:      provider.NewBaseDecorator(p),
+		config:             config,
+	}
+}
+
+func (t *TimeoutForHistoricalProvider) Name() string {
+	return fmt.Sprintf("Timeout(%s)", t.HistoricalProvider.Name())
+}
+
+func (t *TimeoutForHistoricalProvider) GetRateLimit() time.Duration {
+	return t.HistoricalProvider.GetRateLimit()
+}
+
+func (t *TimeoutForHistoricalProvider) IsHealthy() bool {
+	return t.HistoricalProvider.IsHealthy()
+}
+
+// FetchHistoricalData 实现带时限的历史数据获取
+func (t *TimeoutForHistoricalProvider) FetchHistoricalData(ctx context.Context, symbol string, start, end time.Time, period string) ([]core.HistoricalData, error) {
+	var data []core.HistoricalData
+	err := callWithTimeout(ctx, t.config, "FetchHistoricalData", func(ctx context.Context) error {
+		result, err := t.HistoricalProvider.FetchHistoricalData(ctx, symbol, start, end, period)
+		if err == nil {
+			data = result
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// TimeoutForIndexProvider 实时指数数据超时装饰器
+type TimeoutForIndexProvider struct {
+	provider.RealtimeIndexProvider
+	*provider.BaseDecorator
+	config *TimeoutConfig
+}
+
+// NewTimeoutForIndexProvider 创建实时指数数据超时装饰器
+func NewTimeoutForIndexProvider(p provider.RealtimeIndexProvider, config *TimeoutConfig) *TimeoutForIndexProvider {
+	if config == nil {
+		config = &TimeoutConfig{Timeout: DefaultIndexTimeout, Enabled: true}
+	}
+	return &TimeoutForIndexProvider{
+		RealtimeIndexProvider: p,
+		BaseDecorator:         provider.NewBaseDecorator(p),
+		config:                config,
+	}
+}
+
+func (t *TimeoutForIndexProvider) Name() string {
+	return fmt.Sprintf("Timeout(%s)", t.RealtimeIndexProvider.Name())
+}
+
+func (t *TimeoutForIndexProvider) GetRateLimit() time.Duration {
+	return t.RealtimeIndexProvider.GetRateLimit()
+}
+
+func (t *TimeoutForIndexProvider) IsHealthy() bool {
+	return t.RealtimeIndexProvider.IsHealthy()
+}
+
+// FetchIndexData 实现带时限的指数数据获取
+func (t *TimeoutForIndexProvider) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	var data []core.IndexData
+	err := callWithTimeout(ctx, t.config, "FetchIndexData", func(ctx context.Context) error {
+		result, err := t.RealtimeIndexProvider.FetchIndexData(ctx, indexSymbols)
+		if err == nil {
+			data = result
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,136 @@
+package decorators
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowProvider 每次请求耗时 delay；ignoreContext 为 true 时模拟不响应 ctx 的挂起请求
+type slowProvider struct {
+	MockRealtimeProvider
+	delay         time.Duration
+	ignoreContext bool
+	release       chan struct{}
+}
+
+func (s *slowProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	if s.ignoreContext {
+		<-s.release
+		return nil, errors.New("released")
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(s.delay):
+		return s.MockRealtimeProvider.FetchStockData(ctx, symbols)
+	}
+}
+
+func (s *slowProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := s.FetchStockData(ctx, symbols)
+	return data, "raw", err
+}
+
+func TestTimeoutProvider_ReturnsErrTimeout(t *testing.T) {
+	timeout := NewTimeoutProvider(&slowProvider{delay: time.Hour}, &TimeoutConfig{Timeout: 20 * time.Millisecond, Enabled: true})
+
+	start := time.Now()
+	data, err := timeout.FetchStockData(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, core.ErrTimeout)
+	assert.ErrorIs(t, err, provider.ErrTimeout)
+	assert.True(t, core.IsRetryable(err))
+	assert.Nil(t, data)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestTimeoutProvider_FastCallSucceeds(t *testing.T) {
+	timeout := NewTimeoutProvider(&slowProvider{delay: time.Millisecond}, &TimeoutConfig{Timeout: time.Second, Enabled: true})
+
+	data, raw, err := timeout.FetchStockDataWithRaw(context.Background(), []string{"600000", "000001"})
+	require.NoError(t, err)
+	assert.Len(t, data, 2)
+	assert.Equal(t, "raw", raw)
+}
+
+func TestTimeoutProvider_CallerCancellation(t *testing.T) {
+	timeout := NewTimeoutProvider(&slowProvider{delay: time.Hour}, &TimeoutConfig{Timeout: time.Hour, Enabled: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	_, err := timeout.FetchStockData(ctx, []string{"600000"})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, core.ErrTimeout, "caller cancellation is not reported as a provider timeout")
+}
+
+func TestTimeoutProvider_HangingProviderDoesNotLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// 响应 ctx 的提供商：超时取消后 goroutine 立即退出
+	timeout := NewTimeoutProvider(&slowProvider{delay: time.Hour}, &TimeoutConfig{Timeout: 10 * time.Millisecond, Enabled: true})
+	for i := 0; i < 20; i++ {
+		_, err := timeout.FetchStockData(context.Background(), []string{"600000"})
+		require.ErrorIs(t, err, core.ErrTimeout)
+	}
+	assert.True(t, waitForGoroutines(before, time.Second), "fetch goroutines exit")
+
+	// 不响应 ctx 的提供商：调用按时返回，底层请求结束后 goroutine 退出
+	hanging := &slowProvider{ignoreContext: true, release: make(chan struct{})}
+	timeout = NewTimeoutProvider(hanging, &TimeoutConfig{Timeout: 10 * time.Millisecond, Enabled: true})
+	_, err := timeout.FetchStockData(context.Background(), []string{"600000"})
+	require.ErrorIs(t, err, core.ErrTimeout)
+	close(hanging.release)
+	assert.True(t, waitForGoroutines(before, time.Second), "fetch goroutines exit")
+}
+
+func TestTimeoutProvider_CountedByCircuitBreaker(t *testing.T) {
+	config := DefaultCircuitBreakerConfig()
+	config.ReadyToTrip = 2
+	cb := NewCircuitBreakerProvider(NewTimeoutProvider(&slowProvider{delay: time.Hour}, &TimeoutConfig{Timeout: 5 * time.Millisecond, Enabled: true}), config)
+
+	for i := 0; i < 2; i++ {
+		_, err := cb.FetchStockData(context.Background(), []string{"600000"})
+		assert.ErrorIs(t, err, core.ErrTimeout)
+	}
+	assert.False(t, cb.IsHealthy(), "timeouts trip the breaker")
+}
+
+func TestCreateDecorator_TimeoutDefaults(t *testing.T) {
+	realtime, err := CreateDecorator(provider.TimeoutType, &MockRealtimeProvider{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRealtimeTimeout, realtime.(*TimeoutProvider).config.Timeout)
+
+	historical, err := CreateDecorator(provider.TimeoutType, &MockHistoricalProvider{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultHistoricalTimeout, historical.(*TimeoutForHistoricalProvider).config.Timeout)
+
+	custom, err := CreateDecorator(provider.TimeoutType, &MockHistoricalProvider{}, map[string]interface{}{"timeout": "2m"})
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, custom.(*TimeoutForHistoricalProvider).config.Timeout)
+
+	chain := NewConfigurableDecoratorChain()
+	chain.LoadFromConfig(DefaultDecoratorConfig())
+	assert.Equal(t, []provider.DecoratorType{provider.TimeoutType, provider.RetryType, provider.CircuitBreakerType},
+		chain.GetAppliedDecorators(&MockHistoricalProvider{}))
+}
+
+// waitForGoroutines 等待 goroutine 数量回落到 n 以下
+// 不使用 assert.Eventually，它本身会创建 goroutine
+func waitForGoroutines(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if runtime.NumGoroutine() <= n {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
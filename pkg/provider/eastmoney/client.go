@@ -0,0 +1,304 @@
+package eastmoney
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/logger"
+	"stocksub/pkg/provider"
+)
+
+// Client 东方财富行情数据提供商
+//
+// push2 的 qt/stock/get 接口每次只返回一只股票，但包含五档盘口；多只股票时按 rateLimit 间隔逐只请求。
+type Client struct {
+	httpClient *core.HTTPClient
+	log        *logrus.Entry
+	baseURL    string
+	timeout    time.Duration // 单次请求的超时时间，通过 context 控制
+	rateLimit  time.Duration
+	chunks     provider.ChunkOptions // 每个分片一只股票
+}
+
+var (
+	_ provider.RealtimeStockProvider = (*Client)(nil)
+	_ provider.RealtimeIndexProvider = (*Client)(nil)
+	_ provider.Configurable          = (*Client)(nil)
+	_ provider.Closable              = (*Client)(nil)
+)
+
+// Option 东方财富数据提供商的创建选项
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的 HTTP 客户端，多个提供商可以共享同一个客户端的连接池
+func WithHTTPClient(httpClient *core.HTTPClient) Option {
+	return func(p *Client) {
+		p.httpClient = httpClient
+	}
+}
+
+// WithTimeout 设置单次请求的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Client) {
+		p.timeout = timeout
+	}
+}
+
+// NewClient 创建东方财富数据提供商，未指定 HTTP 客户端时使用进程级共享客户端
+func NewClient(opts ...Option) *Client {
+	p := &Client{
+		httpClient: core.SharedHTTPClient(),
+		log:        logger.WithComponent("EastmoneyProvider"),
+		baseURL:    "https://push2.eastmoney.com/api/qt/stock/get",
+		timeout:    15 * time.Second,
+		rateLimit:  200 * time.Millisecond, // 默认速率限制
+		chunks:     provider.ChunkOptions{Size: 1, Concurrency: 1},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Name 返回提供商名称
+func (p *Client) Name() string {
+	return "eastmoney"
+}
+
+// UpstreamHost 返回接口的主机名，用于按主机共享限流
+func (p *Client) UpstreamHost() string {
+	return provider.URLHost(p.baseURL)
+}
+
+// GetRateLimit 获取请求频率限制
+func (p *Client) GetRateLimit() time.Duration {
+	return p.rateLimit
+}
+
+// IsHealthy 检查提供商健康状态
+func (p *Client) IsHealthy() bool {
+	return p.httpClient != nil
+}
+
+// SetRateLimit 设置请求频率限制
+func (p *Client) SetRateLimit(limit time.Duration) {
+	p.rateLimit = limit
+}
+
+// SetTimeout 设置请求超时时间，通过请求的 context 生效，不影响共享的 HTTP 客户端
+func (p *Client) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// SetMaxRetries (空实现，为了接口兼容性)
+func (p *Client) SetMaxRetries(retries int) {
+	// 重试由装饰器链负责
+}
+
+// SetChunkConcurrency 设置同时进行的请求数，请求开始时间仍按 rateLimit 间隔，默认顺序请求
+func (p *Client) SetChunkConcurrency(concurrency int) {
+	p.chunks.Concurrency = concurrency
+}
+
+// GetStatus 返回提供商状态，包含 HTTP 连接复用统计（共享客户端时为所有使用者的合计）
+func (p *Client) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       p.Name(),
+		"timeout":    p.timeout.String(),
+		"rate_limit": p.rateLimit.String(),
+		"http_conns": p.httpClient.Stats(),
+	}
+}
+
+// Close 关闭提供商，清理资源
+func (p *Client) Close() error {
+	if p.httpClient != nil {
+		p.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// IsSymbolSupported 检查是否支持该股票代码：沪市 6 开头，深市 0、3 开头，北交所 4、8、92 开头
+func (p *Client) IsSymbolSupported(symbol string) bool {
+	_, ok := toSecID(symbol)
+	return ok
+}
+
+// toSecID 将股票代码转换为东方财富的 secid，如 600000 -> 1.600000，000001.SZ -> 0.000001
+// 只支持沪深北的股票号段，B股和基金不支持
+func toSecID(symbol string) (string, bool) {
+	parsed, err := core.ParseSymbol(symbol)
+	if err != nil || parsed.IsIndex() {
+		return "", false
+	}
+	code := parsed.Code
+	switch {
+	case parsed.Market == core.MarketSH && code[0] == '6':
+		return "1." + code, true
+	case parsed.Market == core.MarketSZ && (code[0] == '0' || code[0] == '3'):
+		return "0." + code, true
+	case parsed.Market == core.MarketBJ:
+		// 北交所与深市同属市场 0
+		return "0." + code, true
+	default:
+		return "", false
+	}
+}
+
+// IsIndexSupported 检查是否支持该指数代码，支持上证 000xxx 和深证 399xxx，需带市场前缀或后缀（如 sh000001、399001.SZ）
+func (p *Client) IsIndexSupported(indexSymbol string) bool {
+	_, ok := toIndexSecID(indexSymbol)
+	return ok
+}
+
+// toIndexSecID 将指数代码转换为 secid，如 sh000001 -> 1.000001，399001.SZ -> 0.399001
+func toIndexSecID(indexSymbol string) (string, bool) {
+	parsed, err := core.ParseSymbol(indexSymbol)
+	if err != nil || !parsed.IsIndex() {
+		return "", false
+	}
+	switch parsed.Market {
+	case core.MarketSH:
+		return "1." + parsed.Code, true
+	case core.MarketSZ:
+		return "0." + parsed.Code, true
+	default:
+		return "", false
+	}
+}
+
+// FetchStockData 获取股票数据
+func (p *Client) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	result, _, err := p.FetchStockDataWithRaw(ctx, symbols)
+	return result, err
+}
+
+// FetchStockDataWithRaw 获取股票数据和原始响应
+//
+// 每只股票一个请求，按 rateLimit 间隔发出；部分股票失败时返回成功股票的数据和 *provider.MultiError，
+// 原始响应为各股票的 JSON 以换行拼接。
+func (p *Client) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	if len(symbols) == 0 {
+		return []core.StockData{}, "", nil
+	}
+
+	opts := p.chunks
+	opts.Interval = p.rateLimit
+	return provider.FetchInChunks(ctx, symbols, opts, p.fetchChunk)
+}
+
+// fetchChunk 请求一只股票的行情
+func (p *Client) fetchChunk(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	var results []core.StockData
+	var raws []string
+	for _, symbol := range symbols {
+		secID, ok := toSecID(symbol)
+		if !ok {
+			return nil, "", fmt.Errorf("unsupported symbol: %s", symbol)
+		}
+		body, err := p.get(ctx, secID, stockFields)
+		if err != nil {
+			return nil, "", err
+		}
+		stock, ok, err := parseStockQuote(body)
+		if err != nil {
+			return nil, "", err
+		}
+		if ok {
+			results = append(results, stock)
+		}
+		raws = append(raws, string(body))
+	}
+	return results, strings.Join(raws, provider.RawChunkSeparator), nil
+}
+
+// FetchIndexData 获取指数数据 (实现 provider.RealtimeIndexProvider 接口)，按 rateLimit 间隔逐个请求
+func (p *Client) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	secIDs := make([]string, len(indexSymbols))
+	for i, symbol := range indexSymbols {
+		secID, ok := toIndexSecID(symbol)
+		if !ok {
+			return nil, fmt.Errorf("unsupported index symbol: %s", symbol)
+		}
+		secIDs[i] = secID
+	}
+
+	results := make([]core.IndexData, 0, len(indexSymbols))
+	for i, secID := range secIDs {
+		if i > 0 && p.rateLimit > 0 {
+			timer := time.NewTimer(p.rateLimit)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+		body, err := p.get(ctx, secID, indexFields)
+		if err != nil {
+			return nil, err
+		}
+		index, ok, err := parseIndexQuote(body, indexSymbols[i])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			results = append(results, index)
+		}
+	}
+	return results, nil
+}
+
+// get 请求一个 secid 的行情，返回响应体
+func (p *Client) get(ctx context.Context, secID, fields string) ([]byte, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.buildURL(secID, fields), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Referer", "https://quote.eastmoney.com/")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, core.WrapRequestError(fmt.Errorf("HTTP request failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, core.NewHTTPStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %w", err)
+	}
+	return body, nil
+}
+
+// buildURL 构建 qt/stock/get 请求地址，fltt=2 使价格以小数返回
+func (p *Client) buildURL(secID, fields string) string {
+	query := url.Values{}
+	query.Set("secid", secID)
+	query.Set("fltt", "2")
+	query.Set("invt", "2")
+	query.Set("fields", fields)
+	return p.baseURL + "?" + query.Encode()
+}
+
+// withTimeout 为请求附加超时时间，timeout <= 0 时不限制
+func (p *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
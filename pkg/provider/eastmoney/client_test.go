@@ -0,0 +1,193 @@
+package eastmoney
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+)
+
+func TestToSecID(t *testing.T) {
+	tests := []struct {
+		symbol string
+		want   string
+		ok     bool
+	}{
+		{"600000", "1.600000", true},
+		{"688981", "1.688981", true},
+		{"000001", "0.000001", true},
+		{"300750", "0.300750", true},
+		{"830799", "0.830799", true},
+		{"430047", "0.430047", true},
+		{"920002", "0.920002", true},
+		{"900901", "", false},
+		{"sh600000", "1.600000", true},
+		{"000001.SZ", "0.000001", true},
+		{"301001", "0.301001", true},
+		{"159915", "", false},
+		{"60000", "", false},
+		{"60000a", "", false},
+	}
+	client := NewClient()
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			got, ok := toSecID(tt.symbol)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ok, client.IsSymbolSupported(tt.symbol))
+		})
+	}
+}
+
+func TestToIndexSecID(t *testing.T) {
+	client := NewClient()
+	for symbol, want := range map[string]string{"sh000001": "1.000001", "sh000300": "1.000300", "sz399001": "0.399001", "000300.SH": "1.000300", "399006": "0.399006"} {
+		got, ok := toIndexSecID(symbol)
+		assert.True(t, ok, symbol)
+		assert.Equal(t, want, got)
+		assert.True(t, client.IsIndexSupported(symbol))
+	}
+	for _, symbol := range []string{"sh600000", "sz000001", "bj899050", "000001", "000300.SZ", "sh00000x"} {
+		assert.False(t, client.IsIndexSupported(symbol), symbol)
+	}
+}
+
+// newFixtureServer 按 secid 返回 testdata 中的响应，未配置的 secid 返回 502
+func newFixtureServer(t *testing.T, fixtures map[string]string) (*httptest.Server, func() []*http.Request) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+
+		name, ok := fixtures[r.URL.Query().Get("secid")]
+		if !ok {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write(loadFixture(t, name))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*http.Request(nil), requests...)
+	}
+}
+
+func TestClient_FetchStockDataWithRaw_OneRequestPerSymbol(t *testing.T) {
+	server, requests := newFixtureServer(t, map[string]string{
+		"1.600000": "stock_600000.json",
+		"0.000982": "stock_suspended.json",
+		"0.000999": "not_found.json",
+	})
+
+	client := NewClient()
+	client.baseURL = server.URL + "/api/qt/stock/get"
+	client.SetRateLimit(0)
+	defer client.Close()
+
+	symbols := []string{"600000", "000982", "000999", "300750", "900901"}
+	data, raw, err := client.FetchStockDataWithRaw(context.Background(), symbols)
+
+	var multiErr *provider.MultiError
+	require.ErrorAs(t, err, &multiErr)
+	assert.Equal(t, 5, multiErr.Chunks)
+	assert.Equal(t, []string{"300750", "900901"}, multiErr.FailedSymbols())
+
+	require.Len(t, data, 2, "不存在的代码不返回数据")
+	assert.Equal(t, "600000", data[0].Symbol)
+	assert.Equal(t, "000982", data[1].Symbol)
+	assert.Equal(t, core.StatusSuspended, data[1].TradingStatus)
+	assert.Equal(t, 3, strings.Count(raw, `"rc":0`))
+
+	// 不支持的代码不发请求
+	reqs := requests()
+	require.Len(t, reqs, 4)
+	first := reqs[0].URL.Query()
+	assert.Equal(t, "/api/qt/stock/get", reqs[0].URL.Path)
+	assert.Equal(t, "1.600000", first.Get("secid"))
+	assert.Equal(t, "2", first.Get("fltt"))
+	assert.Contains(t, first.Get("fields"), "f43")
+	assert.Contains(t, first.Get("fields"), "f19")
+	assert.Equal(t, "https://quote.eastmoney.com/", reqs[0].Header.Get("Referer"))
+}
+
+func TestClient_FetchStockData_SpacesRequestsByRateLimit(t *testing.T) {
+	server, requests := newFixtureServer(t, map[string]string{
+		"1.600000": "stock_600000.json",
+		"0.000982": "stock_suspended.json",
+	})
+
+	client := NewClient()
+	client.baseURL = server.URL
+	client.SetRateLimit(30 * time.Millisecond)
+	defer client.Close()
+
+	start := time.Now()
+	data, err := client.FetchStockData(context.Background(), []string{"600000", "000982"})
+	require.NoError(t, err)
+	assert.Len(t, data, 2)
+	assert.Len(t, requests(), 2)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+}
+
+func TestClient_FetchIndexData(t *testing.T) {
+	server, requests := newFixtureServer(t, map[string]string{
+		"1.000001": "index_000001.json",
+		"0.399999": "not_found.json",
+	})
+
+	client := NewClient()
+	client.baseURL = server.URL
+	client.SetRateLimit(0)
+	defer client.Close()
+
+	data, err := client.FetchIndexData(context.Background(), []string{"sh000001", "sz399999"})
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, "sh000001", data[0].Symbol)
+	assert.Equal(t, 3228.06, data[0].Value)
+	assert.Equal(t, "1.000001", requests()[0].URL.Query().Get("secid"))
+	assert.NotContains(t, requests()[0].URL.Query().Get("fields"), "f19", "指数不请求盘口字段")
+
+	_, err = client.FetchIndexData(context.Background(), []string{"sh600000"})
+	assert.ErrorContains(t, err, "unsupported index symbol")
+	assert.Len(t, requests(), 2)
+}
+
+func TestClient_SetTimeout_DoesNotRebuildClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	httpClient, err := core.NewHTTPClient(core.DefaultHTTPClientConfig())
+	require.NoError(t, err)
+	client := NewClient(WithHTTPClient(httpClient))
+	client.baseURL = server.URL
+	client.SetTimeout(20 * time.Millisecond)
+	defer client.Close()
+
+	_, err = client.FetchStockData(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = client.FetchIndexData(context.Background(), []string{"sh000001"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Same(t, httpClient, client.httpClient)
+	assert.Equal(t, int64(2), client.GetStatus()["http_conns"].(core.HTTPConnStats).Requests)
+}
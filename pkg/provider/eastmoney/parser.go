@@ -0,0 +1,235 @@
+package eastmoney
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+)
+
+// qt/stock/get 的字段编号（fltt=2 时价格为小数，成交量和盘口数量单位为手）
+const (
+	fieldPrice         = "f43"  // 最新价
+	fieldHigh          = "f44"  // 最高价
+	fieldLow           = "f45"  // 最低价
+	fieldOpen          = "f46"  // 今开
+	fieldVolume        = "f47"  // 成交量(手)
+	fieldTurnover      = "f48"  // 成交额(元)
+	fieldOuterDisc     = "f49"  // 外盘(手)
+	fieldLimitUp       = "f51"  // 涨停价
+	fieldLimitDown     = "f52"  // 跌停价
+	fieldCode          = "f57"  // 代码
+	fieldName          = "f58"  // 名称
+	fieldPrevClose     = "f60"  // 昨收
+	fieldTimestamp     = "f86"  // 行情时间(Unix 秒)
+	fieldMarketValue   = "f116" // 总市值(元)
+	fieldCirculation   = "f117" // 流通市值(元)
+	fieldInnerDisc     = "f161" // 内盘(手)
+	fieldPE            = "f162" // 市盈率(动)
+	fieldPB            = "f167" // 市净率
+	fieldTurnoverRate  = "f168" // 换手率(%)
+	fieldChange        = "f169" // 涨跌额
+	fieldChangePercent = "f170" // 涨跌幅(%)
+	fieldAmplitude     = "f171" // 振幅(%)
+)
+
+// 五档盘口的价格和数量字段，下标 0 为一档
+var (
+	bidFields = [5][2]string{{"f19", "f20"}, {"f17", "f18"}, {"f15", "f16"}, {"f13", "f14"}, {"f11", "f12"}}
+	askFields = [5][2]string{{"f39", "f40"}, {"f37", "f38"}, {"f35", "f36"}, {"f33", "f34"}, {"f31", "f32"}}
+)
+
+// stockFields 股票行情请求的字段列表
+var stockFields = strings.Join(append(orderBookFieldList(),
+	fieldPrice, fieldHigh, fieldLow, fieldOpen, fieldVolume, fieldTurnover, fieldOuterDisc,
+	fieldLimitUp, fieldLimitDown, fieldCode, fieldName, fieldPrevClose, fieldTimestamp,
+	fieldMarketValue, fieldCirculation, fieldInnerDisc, fieldPE, fieldPB,
+	fieldTurnoverRate, fieldChange, fieldChangePercent, fieldAmplitude,
+), ",")
+
+// orderBookFieldList 返回五档盘口的全部字段
+func orderBookFieldList() []string {
+	var fields []string
+	for _, levels := range [][5][2]string{bidFields, askFields} {
+		for _, level := range levels {
+			fields = append(fields, level[0], level[1])
+		}
+	}
+	return fields
+}
+
+// indexFields 指数行情请求的字段列表
+var indexFields = strings.Join([]string{
+	fieldPrice, fieldVolume, fieldTurnover, fieldCode, fieldName, fieldTimestamp, fieldChange, fieldChangePercent,
+}, ",")
+
+// response qt/stock/get 的响应，代码不存在时 data 为 null
+type response struct {
+	RC   int   `json:"rc"`
+	Data quote `json:"data"`
+}
+
+// quote 行情字段，值可能是数字、字符串，停牌或无数据时为 "-"
+type quote map[string]json.RawMessage
+
+// decodeResponse 解析响应外层，返回行情字段；代码不存在时返回 nil
+func decodeResponse(body []byte) (quote, error) {
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, apperrors.Wrap(apperrors.CodeParseFailure, fmt.Errorf("parse response failed: %w", err))
+	}
+	if resp.RC != 0 {
+		return nil, fmt.Errorf("eastmoney returned rc=%d", resp.RC)
+	}
+	return resp.Data, nil
+}
+
+// float 返回数值字段，字段缺失、为 "-" 或无法解析时返回 false
+func (q quote) float(key string) (float64, bool) {
+	raw, ok := q[key]
+	if !ok || len(raw) == 0 || string(raw) == "null" {
+		return 0, false
+	}
+	text := string(raw)
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return 0, false
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || math.IsNaN(v) {
+		return 0, false
+	}
+	return v, true
+}
+
+// number 返回数值字段，无数据时为 0
+func (q quote) number(key string) float64 {
+	v, _ := q.float(key)
+	return v
+}
+
+// integer 返回整数字段，无数据时为 0
+func (q quote) integer(key string) int64 {
+	return int64(math.Round(q.number(key)))
+}
+
+// text 返回字符串字段，无数据时为空字符串
+func (q quote) text(key string) string {
+	raw, ok := q[key]
+	if !ok {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return strings.Trim(string(raw), `"`)
+	}
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// timestamp 返回行情时间，缺失时使用当前时间
+func (q quote) timestamp() time.Time {
+	if sec, ok := q.float(fieldTimestamp); ok && sec > 0 {
+		return time.Unix(int64(sec), 0)
+	}
+	return time.Now()
+}
+
+// parseStockQuote 解析单只股票的响应，代码不存在时返回 false。
+//
+// 停牌股票的最新价等字段为 "-"，此时最新价取昨收价，涨跌为 0，交易状态为 suspended。
+func parseStockQuote(body []byte) (core.StockData, bool, error) {
+	q, err := decodeResponse(body)
+	if err != nil || q == nil {
+		return core.StockData{}, false, err
+	}
+	symbol := q.text(fieldCode)
+	if symbol == "" {
+		return core.StockData{}, false, nil
+	}
+
+	prevClose := q.number(fieldPrevClose)
+	price, trading := q.float(fieldPrice)
+	status := core.StatusTrading
+	if !trading {
+		price = prevClose
+		status = core.StatusSuspended
+	}
+
+	stock := core.StockData{
+		Symbol:        symbol,
+		Name:          q.text(fieldName),
+		Price:         price,
+		Change:        q.number(fieldChange),
+		ChangePercent: q.number(fieldChangePercent),
+
+		Volume:    q.integer(fieldVolume),
+		Turnover:  q.number(fieldTurnover),
+		Open:      q.number(fieldOpen),
+		High:      q.number(fieldHigh),
+		Low:       q.number(fieldLow),
+		PrevClose: prevClose,
+
+		InnerDisc: q.integer(fieldInnerDisc),
+		OuterDisc: q.integer(fieldOuterDisc),
+
+		TurnoverRate: q.number(fieldTurnoverRate),
+		PE:           q.number(fieldPE),
+		PB:           q.number(fieldPB),
+		Amplitude:    q.number(fieldAmplitude),
+		Circulation:  q.number(fieldCirculation) / 1e8, // 元转换为亿元
+		MarketValue:  q.number(fieldMarketValue) / 1e8,
+		LimitUp:      q.number(fieldLimitUp),
+		LimitDown:    q.number(fieldLimitDown),
+
+		TradingStatus: status,
+		Timestamp:     q.timestamp(),
+	}
+	parseOrderBook(q, &stock)
+	return stock, true, nil
+}
+
+// parseOrderBook 解析五档盘口
+func parseOrderBook(q quote, stock *core.StockData) {
+	bid := func(i int) (float64, int64) { return q.number(bidFields[i][0]), q.integer(bidFields[i][1]) }
+	ask := func(i int) (float64, int64) { return q.number(askFields[i][0]), q.integer(askFields[i][1]) }
+
+	stock.BidPrice1, stock.BidVolume1 = bid(0)
+	stock.BidPrice2, stock.BidVolume2 = bid(1)
+	stock.BidPrice3, stock.BidVolume3 = bid(2)
+	stock.BidPrice4, stock.BidVolume4 = bid(3)
+	stock.BidPrice5, stock.BidVolume5 = bid(4)
+	stock.AskPrice1, stock.AskVolume1 = ask(0)
+	stock.AskPrice2, stock.AskVolume2 = ask(1)
+	stock.AskPrice3, stock.AskVolume3 = ask(2)
+	stock.AskPrice4, stock.AskVolume4 = ask(3)
+	stock.AskPrice5, stock.AskVolume5 = ask(4)
+}
+
+// parseIndexQuote 解析单个指数的响应，symbol 为请求时带市场前缀的指数代码；代码不存在时返回 false
+func parseIndexQuote(body []byte, symbol string) (core.IndexData, bool, error) {
+	q, err := decodeResponse(body)
+	if err != nil || q == nil {
+		return core.IndexData{}, false, err
+	}
+	if q.text(fieldCode) == "" {
+		return core.IndexData{}, false, nil
+	}
+	return core.IndexData{
+		Symbol:        symbol,
+		Name:          q.text(fieldName),
+		Value:         q.number(fieldPrice),
+		Change:        q.number(fieldChange),
+		ChangePercent: q.number(fieldChangePercent),
+		Volume:        q.integer(fieldVolume),
+		Turnover:      q.number(fieldTurnover),
+	}, true, nil
+}
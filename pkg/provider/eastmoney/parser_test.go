@@ -0,0 +1,132 @@
+package eastmoney
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// loadFixture 读取 testdata 中保存的接口响应
+func loadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseStockQuote_Trading(t *testing.T) {
+	stock, ok, err := parseStockQuote(loadFixture(t, "stock_600000.json"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, "600000", stock.Symbol)
+	assert.Equal(t, "浦发银行", stock.Name)
+	assert.Equal(t, 10.53, stock.Price)
+	assert.Equal(t, 0.09, stock.Change)
+	assert.Equal(t, 0.86, stock.ChangePercent)
+	assert.Equal(t, int64(412563), stock.Volume)
+	assert.Equal(t, 434758213.0, stock.Turnover)
+	assert.Equal(t, 10.45, stock.Open)
+	assert.Equal(t, 10.6, stock.High)
+	assert.Equal(t, 10.41, stock.Low)
+	assert.Equal(t, 10.44, stock.PrevClose)
+	assert.Equal(t, int64(196689), stock.InnerDisc)
+	assert.Equal(t, int64(215874), stock.OuterDisc)
+	assert.Equal(t, 0.14, stock.TurnoverRate)
+	assert.Equal(t, 6.12, stock.PE)
+	assert.Equal(t, 0.47, stock.PB)
+	assert.Equal(t, 1.82, stock.Amplitude)
+	assert.InDelta(t, 3092.71338539, stock.MarketValue, 1e-6, "市值以亿元为单位")
+	assert.InDelta(t, 3092.71338539, stock.Circulation, 1e-6)
+	assert.Equal(t, 11.49, stock.LimitUp)
+	assert.Equal(t, 9.4, stock.LimitDown)
+	assert.Equal(t, core.StatusTrading, stock.TradingStatus)
+	assert.Equal(t, time.Unix(1755673202, 0), stock.Timestamp)
+
+	// 五档盘口，一档最靠近最新价
+	assert.Equal(t, [5]float64{10.52, 10.51, 10.5, 10.49, 10.48},
+		[5]float64{stock.BidPrice1, stock.BidPrice2, stock.BidPrice3, stock.BidPrice4, stock.BidPrice5})
+	assert.Equal(t, [5]int64{862, 4102, 2954, 1876, 3210},
+		[5]int64{stock.BidVolume1, stock.BidVolume2, stock.BidVolume3, stock.BidVolume4, stock.BidVolume5})
+	assert.Equal(t, [5]float64{10.53, 10.54, 10.55, 10.56, 10.57},
+		[5]float64{stock.AskPrice1, stock.AskPrice2, stock.AskPrice3, stock.AskPrice4, stock.AskPrice5})
+	assert.Equal(t, [5]int64{745, 1933, 5120, 2480, 3321},
+		[5]int64{stock.AskVolume1, stock.AskVolume2, stock.AskVolume3, stock.AskVolume4, stock.AskVolume5})
+}
+
+func TestParseStockQuote_Suspended(t *testing.T) {
+	stock, ok, err := parseStockQuote(loadFixture(t, "stock_suspended.json"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, "000982", stock.Symbol)
+	assert.Equal(t, "中银绒业", stock.Name)
+	assert.Equal(t, core.StatusSuspended, stock.TradingStatus)
+	assert.Equal(t, 2.31, stock.Price, "停牌时最新价取昨收价")
+	assert.Equal(t, 2.31, stock.PrevClose)
+	assert.Zero(t, stock.Change)
+	assert.Zero(t, stock.ChangePercent)
+	assert.Zero(t, stock.Volume)
+	assert.Zero(t, stock.Open)
+	assert.Zero(t, stock.BidPrice1)
+	assert.Zero(t, stock.AskVolume5)
+	assert.Equal(t, 1.96, stock.PB)
+	assert.InDelta(t, 41.67037648, stock.MarketValue, 1e-6)
+}
+
+func TestParseStockQuote_MissingFields(t *testing.T) {
+	stock, ok, err := parseStockQuote(loadFixture(t, "stock_missing_fields.json"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	assert.Equal(t, "830799", stock.Symbol)
+	assert.Equal(t, 25.32, stock.Price)
+	assert.Equal(t, core.StatusTrading, stock.TradingStatus)
+	assert.Equal(t, 25.31, stock.BidPrice1)
+	assert.Equal(t, int64(58), stock.AskVolume1)
+
+	// 缺失或为 null 的字段按 0 处理，不影响其他字段
+	assert.Zero(t, stock.Open)
+	assert.Zero(t, stock.Change)
+	assert.Zero(t, stock.TurnoverRate)
+	assert.Zero(t, stock.MarketValue)
+	assert.Zero(t, stock.BidPrice2)
+	assert.WithinDuration(t, time.Now(), stock.Timestamp, time.Minute, "缺少行情时间时使用当前时间")
+}
+
+func TestParseStockQuote_NotFoundAndErrors(t *testing.T) {
+	_, ok, err := parseStockQuote(loadFixture(t, "not_found.json"))
+	assert.NoError(t, err)
+	assert.False(t, ok, "代码不存在时 data 为 null")
+
+	_, _, err = parseStockQuote(loadFixture(t, "error_rc.json"))
+	assert.ErrorContains(t, err, "rc=102")
+
+	_, _, err = parseStockQuote([]byte("<html>"))
+	assert.ErrorContains(t, err, "parse response failed")
+}
+
+func TestParseIndexQuote(t *testing.T) {
+	index, ok, err := parseIndexQuote(loadFixture(t, "index_000001.json"), "sh000001")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, core.IndexData{
+		Symbol:        "sh000001",
+		Name:          "上证指数",
+		Value:         3228.06,
+		Change:        -14.35,
+		ChangePercent: -0.44,
+		Volume:        378382512,
+		Turnover:      458416580000,
+	}, index)
+
+	_, ok, err = parseIndexQuote(loadFixture(t, "not_found.json"), "sh000999")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
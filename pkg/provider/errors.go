@@ -1,6 +1,10 @@
 package provider
 
-import "errors"
+import (
+	"errors"
+
+	"stocksub/pkg/core"
+)
 
 // 定义核心错误
 var (
@@ -22,8 +26,8 @@ var (
 	// ErrContextCanceled 上下文被取消错误
 	ErrContextCanceled = errors.New("context canceled")
 
-	// ErrTimeout 请求超时错误
-	ErrTimeout = errors.New("request timeout")
+	// ErrTimeout 请求超时错误，与 core.ErrTimeout 相同
+	ErrTimeout = core.ErrTimeout
 
 	// ErrRateLimitExceeded 频率限制超出错误
 	ErrRateLimitExceeded = errors.New("rate limit exceeded")
@@ -31,3 +35,17 @@ var (
 	// ErrProviderClosed 提供商已关闭错误
 	ErrProviderClosed = errors.New("provider is closed")
 )
+
+// PartialError 部分股票代码未获取到，其余代码的数据随错误一起返回，decorators.PartialFailureError 实现该接口。
+// 调用方应保留返回的数据，把 FailedSymbols 视为缺失的代码
+type PartialError interface {
+	error
+	FailedSymbols() []string
+}
+
+// asPartialError 沿错误链查找 PartialError
+func asPartialError(err error) (PartialError, bool) {
+	var partial PartialError
+	ok := errors.As(err, &partial)
+	return partial, ok
+}
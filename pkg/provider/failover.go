@@ -0,0 +1,237 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// ProviderBatch 某个提供商实际返回的一批数据
+type ProviderBatch struct {
+	Provider string
+	Data     []core.StockData
+	Raw      string // 提供商的原始响应，仅在开启 SetCaptureRaw 时记录
+}
+
+// FailoverResult 故障转移获取结果
+type FailoverResult struct {
+	// Batches 按服务顺序记录各提供商返回的数据，用于准确标注数据来源
+	Batches []ProviderBatch
+	// Missing 所有提供商都未返回的股票代码
+	Missing []string
+}
+
+// Data 合并所有批次的数据
+func (r *FailoverResult) Data() []core.StockData {
+	var data []core.StockData
+	for _, batch := range r.Batches {
+		data = append(data, batch.Data...)
+	}
+	return data
+}
+
+type namedStockProvider struct {
+	name     string
+	provider RealtimeStockProvider
+}
+
+// FailoverProvider 故障转移提供商
+// 按优先级依次尝试多个实时股票提供商，跳过不健康或请求失败的提供商；
+// 开启补齐后，主提供商缺失的股票代码会继续向后续提供商请求。
+type FailoverProvider struct {
+	providers    []namedStockProvider
+	topUpMissing bool
+	captureRaw   bool
+}
+
+// NewFailoverProvider 创建故障转移提供商，names 与 providers 一一对应，按优先级排列
+func NewFailoverProvider(names []string, providers []RealtimeStockProvider) (*FailoverProvider, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("failover requires at least one provider")
+	}
+	if len(names) != len(providers) {
+		return nil, fmt.Errorf("failover names and providers length mismatch: %d != %d", len(names), len(providers))
+	}
+
+	f := &FailoverProvider{}
+	for i, p := range providers {
+		if p == nil {
+			return nil, fmt.Errorf("provider '%s' cannot be nil", names[i])
+		}
+		f.providers = append(f.providers, namedStockProvider{name: names[i], provider: p})
+	}
+	return f, nil
+}
+
+// SetTopUpMissing 设置是否向后续提供商补齐缺失的股票代码
+func (f *FailoverProvider) SetTopUpMissing(enabled bool) {
+	f.topUpMissing = enabled
+}
+
+// SetCaptureRaw 设置是否通过 FetchStockDataWithRaw 获取，并在每批数据中记录原始响应
+func (f *FailoverProvider) SetCaptureRaw(enabled bool) {
+	f.captureRaw = enabled
+}
+
+// Name 返回主提供商名称
+func (f *FailoverProvider) Name() string {
+	return f.providers[0].name
+}
+
+// IsHealthy 任一提供商健康即视为健康
+func (f *FailoverProvider) IsHealthy() bool {
+	for _, p := range f.providers {
+		if p.provider.IsHealthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRateLimit 返回主提供商的频率限制
+func (f *FailoverProvider) GetRateLimit() time.Duration {
+	return f.providers[0].provider.GetRateLimit()
+}
+
+// IsSymbolSupported 任一提供商支持即返回 true
+func (f *FailoverProvider) IsSymbolSupported(symbol string) bool {
+	for _, p := range f.providers {
+		if p.provider.IsSymbolSupported(symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetch 按优先级获取实时数据，返回结果记录每批数据实际来自哪个提供商
+func (f *FailoverProvider) Fetch(ctx context.Context, symbols []string) (*FailoverResult, error) {
+	if len(symbols) == 0 {
+		return nil, ErrEmptySymbols
+	}
+
+	result := &FailoverResult{}
+	pending := symbols
+	served := false
+	var errs []error
+
+	for _, p := range f.providers {
+		if len(pending) == 0 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if !p.provider.IsHealthy() {
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, ErrProviderNotHealthy))
+			continue
+		}
+
+		data, raw, err := f.fetchFrom(ctx, p.provider, pending)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			// 部分失败（如熔断器的部分分区打开）时保留已获取的数据，失败的代码与缺失的代码一起向后续提供商补齐
+			partial, ok := asPartialError(err)
+			if !ok || len(data) == 0 {
+				continue
+			}
+			data = withoutSymbols(data, partial.FailedSymbols())
+		}
+
+		served = true
+		if len(data) > 0 {
+			result.Batches = append(result.Batches, ProviderBatch{Provider: p.name, Data: data, Raw: raw})
+		}
+		pending = missingSymbols(pending, data)
+		if !f.topUpMissing {
+			break
+		}
+	}
+
+	if !served {
+		return nil, fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+	}
+
+	result.Missing = pending
+	return result, nil
+}
+
+// fetchFrom 向单个提供商请求，开启 captureRaw 时同时返回原始响应
+func (f *FailoverProvider) fetchFrom(ctx context.Context, p RealtimeStockProvider, symbols []string) ([]core.StockData, string, error) {
+	if f.captureRaw {
+		return p.FetchStockDataWithRaw(ctx, symbols)
+	}
+	data, err := p.FetchStockData(ctx, symbols)
+	return data, "", err
+}
+
+// FetchStockData 实现 RealtimeStockProvider 接口，返回合并后的数据
+func (f *FailoverProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	result, err := f.Fetch(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data(), nil
+}
+
+// FetchStockDataWithRaw 返回第一个成功的提供商的数据及原始响应，不做补齐；
+// 提供商部分失败时返回其数据和 PartialError
+func (f *FailoverProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	var errs []error
+	for _, p := range f.providers {
+		if !p.provider.IsHealthy() {
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, ErrProviderNotHealthy))
+			continue
+		}
+		data, raw, err := p.provider.FetchStockDataWithRaw(ctx, symbols)
+		if err != nil {
+			if _, ok := asPartialError(err); ok && len(data) > 0 {
+				// 部分失败时数据和错误一起返回，由调用方决定如何处理缺失的代码
+				return data, raw, fmt.Errorf("%s: %w", p.name, err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			continue
+		}
+		return data, raw, nil
+	}
+	return nil, "", fmt.Errorf("all providers failed: %w", errors.Join(errs...))
+}
+
+// missingSymbols 返回 requested 中未出现在 data 里的股票代码，按规范形式比较，
+// 请求 600000.SH 而提供商返回 600000 时视为已获取
+func missingSymbols(requested []string, data []core.StockData) []string {
+	got := make(map[string]struct{}, len(data))
+	for _, stock := range data {
+		got[core.NormalizeSymbol(stock.Symbol)] = struct{}{}
+	}
+
+	var missing []string
+	for _, symbol := range requested {
+		if _, ok := got[core.NormalizeSymbol(symbol)]; !ok {
+			missing = append(missing, symbol)
+		}
+	}
+	return missing
+}
+
+// withoutSymbols 去掉 data 中属于 symbols 的数据，按规范形式比较
+func withoutSymbols(data []core.StockData, symbols []string) []core.StockData {
+	if len(symbols) == 0 {
+		return data
+	}
+	drop := make(map[string]struct{}, len(symbols))
+	for _, symbol := range symbols {
+		drop[core.NormalizeSymbol(symbol)] = struct{}{}
+	}
+
+	kept := make([]core.StockData, 0, len(data))
+	for _, stock := range data {
+		if _, ok := drop[core.NormalizeSymbol(stock.Symbol)]; !ok {
+			kept = append(kept, stock)
+		}
+	}
+	return kept
+}
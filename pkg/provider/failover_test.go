@@ -0,0 +1,204 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// stubStockProvider 测试用实时股票提供商
+type stubStockProvider struct {
+	unhealthy bool
+	err       error
+	omit      map[string]bool
+	calls     int
+}
+
+func (s *stubStockProvider) Name() string                         { return "stub" }
+func (s *stubStockProvider) GetRateLimit() time.Duration          { return time.Second }
+func (s *stubStockProvider) IsHealthy() bool                      { return !s.unhealthy }
+func (s *stubStockProvider) IsSymbolSupported(symbol string) bool { return true }
+
+func (s *stubStockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	var data []core.StockData
+	for _, symbol := range symbols {
+		if !s.omit[symbol] {
+			data = append(data, core.StockData{Symbol: symbol})
+		}
+	}
+	return data, nil
+}
+
+func (s *stubStockProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := s.FetchStockData(ctx, symbols)
+	return data, "raw", err
+}
+
+func newChain(t *testing.T, providers map[string]RealtimeStockProvider, names ...string) *FailoverProvider {
+	t.Helper()
+	m := NewProviderManager()
+	for name, p := range providers {
+		require.NoError(t, m.RegisterRealtimeStockProvider(name, p))
+	}
+	chain, err := m.GetRealtimeStockProviderChain(names...)
+	require.NoError(t, err)
+	return chain
+}
+
+func TestFailoverProvider_FallsBackOnErrorAndUnhealthy(t *testing.T) {
+	primary := &stubStockProvider{err: errors.New("boom")}
+	unhealthy := &stubStockProvider{unhealthy: true}
+	backup := &stubStockProvider{}
+	chain := newChain(t, map[string]RealtimeStockProvider{"a": primary, "b": unhealthy, "c": backup}, "a", "b", "c")
+
+	result, err := chain.Fetch(context.Background(), []string{"600000", "000001"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 1)
+	assert.Equal(t, "c", result.Batches[0].Provider)
+	assert.Len(t, result.Data(), 2)
+	assert.Empty(t, result.Missing)
+	assert.Equal(t, 0, unhealthy.calls, "unhealthy providers are skipped")
+
+	assert.Equal(t, "a", chain.Name())
+	assert.True(t, chain.IsHealthy())
+}
+
+func TestFailoverProvider_AllFail(t *testing.T) {
+	chain := newChain(t, map[string]RealtimeStockProvider{
+		"a": &stubStockProvider{err: errors.New("boom")},
+		"b": &stubStockProvider{unhealthy: true},
+	}, "a", "b")
+
+	_, err := chain.Fetch(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, ErrProviderNotHealthy)
+	assert.ErrorContains(t, err, "boom")
+
+	_, _, err = chain.FetchStockDataWithRaw(context.Background(), []string{"600000"})
+	assert.Error(t, err)
+
+	_, err = chain.Fetch(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrEmptySymbols)
+}
+
+func TestFailoverProvider_TopUpMissing(t *testing.T) {
+	primary := &stubStockProvider{omit: map[string]bool{"000001": true, "000002": true}}
+	backup := &stubStockProvider{omit: map[string]bool{"000002": true}}
+	chain := newChain(t, map[string]RealtimeStockProvider{"a": primary, "b": backup}, "a", "b")
+
+	result, err := chain.Fetch(context.Background(), []string{"600000", "000001", "000002"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 1)
+	assert.Equal(t, []string{"000001", "000002"}, result.Missing)
+	assert.Equal(t, 0, backup.calls)
+
+	chain.SetTopUpMissing(true)
+	result, err = chain.Fetch(context.Background(), []string{"600000", "000001", "000002"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 2)
+	assert.Equal(t, "a", result.Batches[0].Provider)
+	assert.Equal(t, []core.StockData{{Symbol: "000001"}}, result.Batches[1].Data)
+	assert.Equal(t, []string{"000002"}, result.Missing)
+}
+
+// stubPartialError 部分代码未获取到，与 decorators.PartialFailureError 一样实现 PartialError
+type stubPartialError struct{ failed []string }
+
+func (e *stubPartialError) Error() string {
+	return fmt.Sprintf("%d symbols not fetched", len(e.failed))
+}
+func (e *stubPartialError) FailedSymbols() []string { return e.failed }
+
+// partialStockProvider 返回 failed 以外代码的数据，同时返回 *stubPartialError
+type partialStockProvider struct {
+	stubStockProvider
+	failed []string
+}
+
+func (p *partialStockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	p.omit = make(map[string]bool)
+	for _, symbol := range p.failed {
+		p.omit[symbol] = true
+	}
+	data, _ := p.stubStockProvider.FetchStockData(ctx, symbols)
+	return data, fmt.Errorf("partitioned: %w", &stubPartialError{failed: p.failed})
+}
+
+func (p *partialStockProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := p.FetchStockData(ctx, symbols)
+	return data, "raw", err
+}
+
+func TestFailoverProvider_PartialFailureKeepsDataAndTopsUp(t *testing.T) {
+	primary := &partialStockProvider{failed: []string{"830799"}}
+	backup := &stubStockProvider{}
+	chain := newChain(t, map[string]RealtimeStockProvider{"a": primary, "b": backup}, "a", "b")
+
+	result, err := chain.Fetch(context.Background(), []string{"000001", "830799"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 1, "部分失败时保留主提供商的数据")
+	assert.Equal(t, []core.StockData{{Symbol: "000001"}}, result.Batches[0].Data)
+	assert.Equal(t, []string{"830799"}, result.Missing)
+	assert.Equal(t, 0, backup.calls)
+
+	chain.SetTopUpMissing(true)
+	result, err = chain.Fetch(context.Background(), []string{"000001", "830799"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 2)
+	assert.Equal(t, "a", result.Batches[0].Provider)
+	assert.Equal(t, "b", result.Batches[1].Provider)
+	assert.Equal(t, []core.StockData{{Symbol: "830799"}}, result.Batches[1].Data, "失败的代码由备用提供商补齐")
+	assert.Empty(t, result.Missing)
+
+	data, raw, err := chain.FetchStockDataWithRaw(context.Background(), []string{"000001", "830799"})
+	var partial PartialError
+	require.ErrorAs(t, err, &partial)
+	assert.Equal(t, []string{"830799"}, partial.FailedSymbols())
+	assert.Equal(t, []core.StockData{{Symbol: "000001"}}, data)
+	assert.Equal(t, "raw", raw)
+}
+
+func TestFailoverProvider_CaptureRaw(t *testing.T) {
+	primary := &stubStockProvider{omit: map[string]bool{"000001": true}}
+	chain := newChain(t, map[string]RealtimeStockProvider{"a": primary, "b": &stubStockProvider{}}, "a", "b")
+	chain.SetTopUpMissing(true)
+
+	result, err := chain.Fetch(context.Background(), []string{"600000", "000001"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 2)
+	assert.Empty(t, result.Batches[0].Raw, "默认不记录原始响应")
+
+	chain.SetCaptureRaw(true)
+	result, err = chain.Fetch(context.Background(), []string{"600000", "000001"})
+	require.NoError(t, err)
+	require.Len(t, result.Batches, 2)
+	assert.Equal(t, "raw", result.Batches[0].Raw)
+	assert.Equal(t, "raw", result.Batches[1].Raw)
+}
+
+func TestProviderManager_GetRealtimeStockProviderChain(t *testing.T) {
+	m := NewProviderManager()
+	require.NoError(t, m.RegisterRealtimeStockProvider("a", &stubStockProvider{}))
+
+	_, err := m.GetRealtimeStockProviderChain()
+	assert.Error(t, err)
+
+	_, err = m.GetRealtimeStockProviderChain("a", "missing")
+	assert.Error(t, err)
+}
+
+func TestMissingSymbols_ComparesCanonicalForms(t *testing.T) {
+	data := []core.StockData{{Symbol: "600000"}, {Symbol: "sz000001"}}
+	assert.Empty(t, missingSymbols([]string{"600000.SH", "000001"}, data), "不同写法视为同一代码")
+	assert.Equal(t, []string{"000001.SH"}, missingSymbols([]string{"600000", "000001.SH"}, data), "上证指数与平安银行不同")
+}
@@ -0,0 +1,277 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ProviderState 健康检查得出的提供商状态
+type ProviderState string
+
+const (
+	// StateHealthy 最近一次检查通过
+	StateHealthy ProviderState = "healthy"
+	// StateDegraded 连续失败但未达到阈值，仍可分配，但排在健康提供商之后
+	StateDegraded ProviderState = "degraded"
+	// StateUnhealthy 连续失败达到阈值，Get* 不再返回该提供商，直到检查恢复
+	StateUnhealthy ProviderState = "unhealthy"
+)
+
+// HealthEventType 健康状态变化事件类型
+type HealthEventType string
+
+const (
+	// EventProviderDown 提供商变为不健康
+	EventProviderDown HealthEventType = "provider_down"
+	// EventProviderRecovered 不健康的提供商恢复
+	EventProviderRecovered HealthEventType = "provider_recovered"
+)
+
+// HealthEvent 提供商健康状态变化事件
+type HealthEvent struct {
+	Type          HealthEventType
+	Name          string
+	ProviderType  ProviderType
+	FailureStreak int
+	Error         string // 导致状态变化的最近一次检查错误，恢复事件为空
+	Time          time.Time
+}
+
+// ProviderStatus 提供商的健康检查状态
+type ProviderStatus struct {
+	Name          string        `json:"name"`
+	Type          ProviderType  `json:"type"`
+	State         ProviderState `json:"state"`
+	LastCheck     time.Time     `json:"last_check"` // 未检查过时为零值
+	FailureStreak int           `json:"failure_streak"`
+	LastError     string        `json:"last_error,omitempty"`
+}
+
+// HealthCheckConfig 健康检查配置
+type HealthCheckConfig struct {
+	// FailureThreshold 连续失败多少次后标记为不健康，之前为降级
+	FailureThreshold int
+	// ProbeSymbol 非空时对实时股票提供商额外获取该股票作为探测
+	ProbeSymbol string
+	// ProbeIndexSymbol 非空时对实时指数提供商额外获取该指数作为探测
+	ProbeIndexSymbol string
+	// ProbeTimeout 单次探测的超时时间
+	ProbeTimeout time.Duration
+}
+
+// DefaultHealthCheckConfig 默认只调用 IsHealthy()，连续 3 次失败标记为不健康
+func DefaultHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		FailureThreshold: 3,
+		ProbeTimeout:     10 * time.Second,
+	}
+}
+
+// healthEventBuffer 事件通道容量，消费者跟不上时丢弃新事件
+const healthEventBuffer = 64
+
+// healthKey 同一名称可以注册为多种类型的提供商，健康状态按类型分别记录
+type healthKey struct {
+	typ  ProviderType
+	name string
+}
+
+// SetHealthCheckConfig 设置健康检查配置，需在 StartHealthChecks 之前调用
+func (m *ProviderManager) SetHealthCheckConfig(config HealthCheckConfig) {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = DefaultHealthCheckConfig().FailureThreshold
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.healthConfig = config
+}
+
+// HealthEvents 返回健康状态变化事件通道，管理器关闭时通道不会关闭
+func (m *ProviderManager) HealthEvents() <-chan HealthEvent {
+	return m.healthEvents
+}
+
+// StartHealthChecks 立即检查一次，之后每隔 interval 检查所有提供商，重复调用会先停止上一次的检查
+func (m *ProviderManager) StartHealthChecks(interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("health check interval must be positive")
+	}
+	m.StopHealthChecks()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.mu.Lock()
+	m.stopHealth, m.healthDone = cancel, done
+	m.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// StopHealthChecks 停止后台健康检查并等待正在进行的检查结束
+func (m *ProviderManager) StopHealthChecks() {
+	m.mu.Lock()
+	stop, done := m.stopHealth, m.healthDone
+	m.stopHealth, m.healthDone = nil, nil
+	m.mu.Unlock()
+
+	if stop != nil {
+		stop()
+		<-done
+	}
+}
+
+// healthTarget 一次检查的对象
+type healthTarget struct {
+	key   healthKey
+	check func(ctx context.Context) error
+}
+
+// CheckHealth 检查所有已注册的提供商一次并更新状态
+func (m *ProviderManager) CheckHealth(ctx context.Context) {
+	m.mu.RLock()
+	config := m.healthConfig
+	var targets []healthTarget
+	for name, p := range m.realtimeStockProviders {
+		p := p
+		targets = append(targets, healthTarget{healthKey{TypeRealtimeStock, name}, func(ctx context.Context) error {
+			return probe(ctx, p, config.ProbeSymbol, config.ProbeTimeout, func(ctx context.Context, symbol string) error {
+				_, err := p.FetchStockData(ctx, []string{symbol})
+				return err
+			})
+		}})
+	}
+	for name, p := range m.realtimeIndexProviders {
+		p := p
+		targets = append(targets, healthTarget{healthKey{TypeRealtimeIndex, name}, func(ctx context.Context) error {
+			return probe(ctx, p, config.ProbeIndexSymbol, config.ProbeTimeout, func(ctx context.Context, symbol string) error {
+				_, err := p.FetchIndexData(ctx, []string{symbol})
+				return err
+			})
+		}})
+	}
+	for name, p := range m.historicalProviders {
+		p := p
+		targets = append(targets, healthTarget{healthKey{TypeHistorical, name}, func(ctx context.Context) error {
+			return probe(ctx, p, "", 0, nil)
+		}})
+	}
+	m.mu.RUnlock()
+
+	// 探测请求不持有锁，避免慢请求阻塞 Get*
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			return
+		}
+		err := target.check(ctx)
+		if ctx.Err() != nil {
+			return // 停止检查导致的失败不计入
+		}
+		m.recordHealth(target.key, err, time.Now())
+	}
+}
+
+// probe 先调用 IsHealthy()，symbol 非空时再请求一次数据
+func probe(ctx context.Context, p Provider, symbol string, timeout time.Duration, fetch func(context.Context, string) error) error {
+	if !p.IsHealthy() {
+		return fmt.Errorf("IsHealthy() returned false")
+	}
+	if symbol == "" || fetch == nil {
+		return nil
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if err := fetch(ctx, symbol); err != nil {
+		return fmt.Errorf("probe %s failed: %w", symbol, err)
+	}
+	return nil
+}
+
+// recordHealth 记录一次检查结果，进入或离开不健康状态时发送事件
+func (m *ProviderManager) recordHealth(key healthKey, err error, now time.Time) {
+	m.mu.Lock()
+	status, ok := m.health[key]
+	if !ok {
+		// 检查期间已注销
+		m.mu.Unlock()
+		return
+	}
+	previous := status.State
+	status.LastCheck = now
+	if err == nil {
+		status.State = StateHealthy
+		status.FailureStreak = 0
+		status.LastError = ""
+	} else {
+		status.FailureStreak++
+		status.LastError = err.Error()
+		status.State = StateDegraded
+		if status.FailureStreak >= m.healthConfig.FailureThreshold {
+			status.State = StateUnhealthy
+		}
+	}
+	current := *status
+	m.mu.Unlock()
+
+	event := HealthEvent{Name: key.name, ProviderType: key.typ, FailureStreak: current.FailureStreak, Error: current.LastError, Time: now}
+	switch {
+	case previous != StateUnhealthy && current.State == StateUnhealthy:
+		event.Type = EventProviderDown
+	case previous == StateUnhealthy && current.State != StateUnhealthy:
+		event.Type = EventProviderRecovered
+	default:
+		return
+	}
+	select {
+	case m.healthEvents <- event:
+	default:
+	}
+}
+
+// GetProviderStatuses 返回所有提供商的健康状态，按类型和名称排序
+func (m *ProviderManager) GetProviderStatuses() []ProviderStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	statuses := make([]ProviderStatus, 0, len(m.health))
+	for _, status := range m.health {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Type != statuses[j].Type {
+			return statuses[i].Type < statuses[j].Type
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// trackHealth 注册时初始化健康状态，重新注册会重置状态（需要持有锁）
+func (m *ProviderManager) trackHealth(typ ProviderType, name string) {
+	m.health[healthKey{typ, name}] = &ProviderStatus{Name: name, Type: typ, State: StateHealthy}
+}
+
+// stateOf 返回提供商的健康状态，未记录时视为健康（需要持有锁）
+func (m *ProviderManager) stateOf(typ ProviderType, name string) ProviderState {
+	if status, ok := m.health[healthKey{typ, name}]; ok {
+		return status.State
+	}
+	return StateHealthy
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// stubIndexProvider 测试用实时指数提供商
+type stubIndexProvider struct {
+	unhealthy bool
+}
+
+func (s *stubIndexProvider) Name() string                             { return "stub" }
+func (s *stubIndexProvider) GetRateLimit() time.Duration              { return time.Second }
+func (s *stubIndexProvider) IsHealthy() bool                          { return !s.unhealthy }
+func (s *stubIndexProvider) IsIndexSupported(indexSymbol string) bool { return true }
+func (s *stubIndexProvider) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	return nil, nil
+}
+
+// stubHistoricalProvider 测试用历史数据提供商
+type stubHistoricalProvider struct{}
+
+func (s *stubHistoricalProvider) Name() string                  { return "stub" }
+func (s *stubHistoricalProvider) GetRateLimit() time.Duration   { return time.Second }
+func (s *stubHistoricalProvider) IsHealthy() bool               { return true }
+func (s *stubHistoricalProvider) GetSupportedPeriods() []string { return []string{"1d"} }
+func (s *stubHistoricalProvider) FetchHistoricalData(ctx context.Context, symbol string, start, end time.Time, period string) ([]core.HistoricalData, error) {
+	return nil, nil
+}
+
+func newHealthManager(t *testing.T, threshold int) *ProviderManager {
+	t.Helper()
+	m := NewProviderManager()
+	config := DefaultHealthCheckConfig()
+	config.FailureThreshold = threshold
+	m.SetHealthCheckConfig(config)
+	t.Cleanup(func() { _ = m.Close() })
+	return m
+}
+
+func statusOf(t *testing.T, m *ProviderManager, typ ProviderType, name string) ProviderStatus {
+	t.Helper()
+	for _, status := range m.GetProviderStatuses() {
+		if status.Type == typ && status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("no status for %s %s", typ, name)
+	return ProviderStatus{}
+}
+
+// drainEvents 取出通道中已有的事件
+func drainEvents(m *ProviderManager) []HealthEvent {
+	var events []HealthEvent
+	for {
+		select {
+		case event := <-m.HealthEvents():
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestProviderManager_CheckHealth_StateTransitions(t *testing.T) {
+	m := newHealthManager(t, 2)
+	stub := &stubStockProvider{unhealthy: true}
+	require.NoError(t, m.RegisterRealtimeStockProvider("tencent", stub))
+	ctx := context.Background()
+
+	assert.Equal(t, ProviderStatus{Name: "tencent", Type: TypeRealtimeStock, State: StateHealthy}, statusOf(t, m, TypeRealtimeStock, "tencent"),
+		"注册后未检查前视为健康")
+
+	m.CheckHealth(ctx)
+	status := statusOf(t, m, TypeRealtimeStock, "tencent")
+	assert.Equal(t, StateDegraded, status.State)
+	assert.Equal(t, 1, status.FailureStreak)
+	assert.False(t, status.LastCheck.IsZero())
+	assert.Contains(t, status.LastError, "IsHealthy")
+	assert.Empty(t, drainEvents(m), "降级不发送事件")
+	_, err := m.GetRealtimeStockProvider("tencent")
+	assert.NoError(t, err, "降级的提供商仍可分配")
+
+	m.CheckHealth(ctx)
+	assert.Equal(t, StateUnhealthy, statusOf(t, m, TypeRealtimeStock, "tencent").State)
+	events := drainEvents(m)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventProviderDown, events[0].Type)
+	assert.Equal(t, "tencent", events[0].Name)
+	assert.Equal(t, TypeRealtimeStock, events[0].ProviderType)
+	assert.Equal(t, 2, events[0].FailureStreak)
+	_, err = m.GetRealtimeStockProvider("tencent")
+	assert.ErrorIs(t, err, ErrProviderNotHealthy)
+
+	m.CheckHealth(ctx)
+	assert.Equal(t, 3, statusOf(t, m, TypeRealtimeStock, "tencent").FailureStreak)
+	assert.Empty(t, drainEvents(m), "持续不健康不重复发送事件")
+
+	stub.unhealthy = false
+	m.CheckHealth(ctx)
+	status = statusOf(t, m, TypeRealtimeStock, "tencent")
+	assert.Equal(t, StateHealthy, status.State)
+	assert.Zero(t, status.FailureStreak)
+	assert.Empty(t, status.LastError)
+	events = drainEvents(m)
+	require.Len(t, events, 1)
+	assert.Equal(t, EventProviderRecovered, events[0].Type)
+	got, err := m.GetRealtimeStockProvider("tencent")
+	require.NoError(t, err)
+	assert.Same(t, stub, got)
+}
+
+func TestProviderManager_CheckHealth_ProbeFetch(t *testing.T) {
+	m := newHealthManager(t, 1)
+	config := DefaultHealthCheckConfig()
+	config.FailureThreshold = 1
+	config.ProbeSymbol = "600000"
+	m.SetHealthCheckConfig(config)
+
+	stub := &stubStockProvider{err: errors.New("connection reset")}
+	require.NoError(t, m.RegisterRealtimeStockProvider("sina", stub))
+	require.NoError(t, m.RegisterHistoricalProvider("sina", &stubHistoricalProvider{}))
+
+	m.CheckHealth(context.Background())
+	assert.Equal(t, 1, stub.calls, "IsHealthy 为 true 时仍发送探测请求")
+	status := statusOf(t, m, TypeRealtimeStock, "sina")
+	assert.Equal(t, StateUnhealthy, status.State)
+	assert.Contains(t, status.LastError, "probe 600000 failed: connection reset")
+
+	// 同名的历史数据提供商单独记录状态
+	assert.Equal(t, StateHealthy, statusOf(t, m, TypeHistorical, "sina").State)
+	_, err := m.GetHistoricalProvider("sina")
+	assert.NoError(t, err)
+}
+
+func TestProviderManager_Chain_PrefersHealthyProviders(t *testing.T) {
+	m := newHealthManager(t, 2)
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, m.RegisterRealtimeStockProvider(name, &stubStockProvider{}))
+	}
+	failure := errors.New("timeout")
+	now := time.Now()
+	m.recordHealth(healthKey{TypeRealtimeStock, "a"}, failure, now) // 降级
+	m.recordHealth(healthKey{TypeRealtimeStock, "c"}, failure, now)
+	m.recordHealth(healthKey{TypeRealtimeStock, "c"}, failure, now) // 不健康
+
+	chain, err := m.GetRealtimeStockProviderChain("a", "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, "b", chain.Name(), "健康的提供商排在降级的提供商之前")
+	require.Len(t, chain.providers, 2, "不健康的提供商不加入链")
+	assert.Equal(t, "a", chain.providers[1].name)
+
+	_, err = m.GetRealtimeStockProviderChain("c")
+	assert.ErrorIs(t, err, ErrProviderNotHealthy)
+	_, err = m.GetRealtimeStockProviderChain("a", "missing")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestProviderManager_StartHealthChecks_EmitsEvents(t *testing.T) {
+	m := newHealthManager(t, 1)
+	index := &stubIndexProvider{unhealthy: true}
+	require.NoError(t, m.RegisterRealtimeIndexProvider("sina", index))
+	assert.Error(t, m.StartHealthChecks(0))
+	require.NoError(t, m.StartHealthChecks(10*time.Millisecond))
+
+	select {
+	case event := <-m.HealthEvents():
+		assert.Equal(t, EventProviderDown, event.Type)
+		assert.Equal(t, TypeRealtimeIndex, event.ProviderType)
+	case <-time.After(time.Second):
+		t.Fatal("no provider down event")
+	}
+	_, err := m.GetRealtimeIndexProvider("sina")
+	assert.ErrorIs(t, err, ErrProviderNotHealthy)
+
+	m.StopHealthChecks()
+	index.unhealthy = false
+	m.CheckHealth(context.Background())
+	event := <-m.HealthEvents()
+	assert.Equal(t, EventProviderRecovered, event.Type)
+	_, err = m.GetRealtimeIndexProvider("sina")
+	assert.NoError(t, err)
+
+	require.NoError(t, m.UnregisterProvider("sina"))
+	assert.Empty(t, m.GetProviderStatuses())
+}
@@ -143,6 +143,9 @@ type DecoratorType string
 const (
 	FrequencyControlType DecoratorType = "frequency_control"
 	CircuitBreakerType   DecoratorType = "circuit_breaker"
+	RetryType            DecoratorType = "retry"
+	MetricsType          DecoratorType = "metrics"
+	TimeoutType          DecoratorType = "timeout"
 )
 
 // DecoratorConfig 装饰器配置
@@ -1,7 +1,9 @@
 package provider
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -19,6 +21,12 @@ const (
 	TypeLegacy ProviderType = "legacy"
 )
 
+// AutoSelect 设置了选择器后作为 GetRealtimeStockProvider 的名称，在所有已注册的实时股票提供商之间逐次选择
+const AutoSelect = "auto"
+
+// StockSelectorFactory 用多个候选实时股票提供商创建按调用选择的提供商，见 pkg/provider/selector
+type StockSelectorFactory func(names []string, providers []RealtimeStockProvider) (RealtimeStockProvider, error)
+
 // ProviderManager 提供商管理器
 // 支持新旧接口的并存，提供统一的访问接口
 type ProviderManager struct {
@@ -27,6 +35,17 @@ type ProviderManager struct {
 	realtimeIndexProviders map[string]RealtimeIndexProvider
 	historicalProviders    map[string]HistoricalProvider
 
+	// 健康检查状态，见 StartHealthChecks
+	health       map[healthKey]*ProviderStatus
+	healthConfig HealthCheckConfig
+	healthEvents chan HealthEvent
+	stopHealth   context.CancelFunc
+	healthDone   chan struct{}
+
+	// 自动选择，见 SetStockSelector；注册或注销提供商后重新创建
+	stockSelectorFactory StockSelectorFactory
+	stockSelector        RealtimeStockProvider
+
 	mu sync.RWMutex
 }
 
@@ -36,6 +55,9 @@ func NewProviderManager() *ProviderManager {
 		realtimeStockProviders: make(map[string]RealtimeStockProvider),
 		realtimeIndexProviders: make(map[string]RealtimeIndexProvider),
 		historicalProviders:    make(map[string]HistoricalProvider),
+		health:                 make(map[healthKey]*ProviderStatus),
+		healthConfig:           DefaultHealthCheckConfig(),
+		healthEvents:           make(chan HealthEvent, healthEventBuffer),
 	}
 }
 
@@ -52,6 +74,8 @@ func (m *ProviderManager) RegisterRealtimeStockProvider(name string, provider Re
 	defer m.mu.Unlock()
 
 	m.realtimeStockProviders[name] = provider
+	m.trackHealth(TypeRealtimeStock, name)
+	m.stockSelector = nil
 	return nil
 }
 
@@ -68,6 +92,7 @@ func (m *ProviderManager) RegisterRealtimeIndexProvider(name string, provider Re
 	defer m.mu.Unlock()
 
 	m.realtimeIndexProviders[name] = provider
+	m.trackHealth(TypeRealtimeIndex, name)
 	return nil
 }
 
@@ -84,6 +109,7 @@ func (m *ProviderManager) RegisterHistoricalProvider(name string, provider Histo
 	defer m.mu.Unlock()
 
 	m.historicalProviders[name] = provider
+	m.trackHealth(TypeHistorical, name)
 	return nil
 }
 
@@ -113,37 +139,163 @@ func (m *ProviderManager) RegisterProvider(name string, provider interface{}) er
 	return fmt.Errorf("unsupported provider type: %T", provider)
 }
 
-// GetRealtimeStockProvider 获取实时股票数据提供商
+// GetRealtimeStockProvider 获取实时股票数据提供商，被健康检查标记为不健康时返回 ErrProviderNotHealthy。
+// 设置了选择器时 name 可以为 AutoSelect，注册了多个提供商时返回在它们之间逐次选择的提供商。
 func (m *ProviderManager) GetRealtimeStockProvider(name string) (RealtimeStockProvider, error) {
+	if name == AutoSelect {
+		if provider, ok, err := m.autoStockProvider(); ok {
+			return provider, err
+		}
+	}
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	// 先检查新接口提供商
 	if provider, exists := m.realtimeStockProviders[name]; exists {
+		if m.stateOf(TypeRealtimeStock, name) == StateUnhealthy {
+			return nil, fmt.Errorf("realtime stock provider '%s': %w", name, ErrProviderNotHealthy)
+		}
 		return provider, nil
 	}
 
 	return nil, fmt.Errorf("realtime stock provider '%s' not found", name)
 }
 
-// GetRealtimeIndexProvider 获取实时指数数据提供商
+// SetStockSelector 设置多个候选提供商之间的选择器，factory 为 nil 时关闭自动选择
+func (m *ProviderManager) SetStockSelector(factory StockSelectorFactory) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stockSelectorFactory = factory
+	m.stockSelector = nil
+}
+
+// autoStockProvider 返回 AutoSelect 对应的提供商；未设置选择器或有提供商注册为 AutoSelect 时 ok 为 false。
+// 候选按健康、降级、不健康和名称排序，第一个为初始首选；只有一个提供商时直接返回它。
+func (m *ProviderManager) autoStockProvider() (provider RealtimeStockProvider, ok bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, registered := m.realtimeStockProviders[AutoSelect]; registered || m.stockSelectorFactory == nil {
+		return nil, false, nil
+	}
+	if m.stockSelector != nil {
+		return m.stockSelector, true, nil
+	}
+
+	rank := map[ProviderState]int{StateHealthy: 0, StateDegraded: 1, StateUnhealthy: 2}
+	names := make([]string, 0, len(m.realtimeStockProviders))
+	for name := range m.realtimeStockProviders {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := rank[m.stateOf(TypeRealtimeStock, names[i])], rank[m.stateOf(TypeRealtimeStock, names[j])]
+		if ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	switch len(names) {
+	case 0:
+		return nil, true, fmt.Errorf("realtime stock provider '%s': no providers registered", AutoSelect)
+	case 1:
+		if m.stateOf(TypeRealtimeStock, names[0]) == StateUnhealthy {
+			return nil, true, fmt.Errorf("realtime stock provider '%s': %w", names[0], ErrProviderNotHealthy)
+		}
+		return m.realtimeStockProviders[names[0]], true, nil
+	}
+
+	providers := make([]RealtimeStockProvider, len(names))
+	for i, name := range names {
+		providers[i] = &managedStockProvider{RealtimeStockProvider: m.realtimeStockProviders[name], manager: m, name: name}
+	}
+	selector, err := m.stockSelectorFactory(names, providers)
+	if err != nil {
+		return nil, true, fmt.Errorf("create stock selector: %w", err)
+	}
+	m.stockSelector = selector
+	return selector, true, nil
+}
+
+// managedStockProvider 交给选择器的候选提供商，被健康检查标记为不健康时 IsHealthy 返回 false
+type managedStockProvider struct {
+	RealtimeStockProvider
+	manager *ProviderManager
+	name    string
+}
+
+func (p *managedStockProvider) IsHealthy() bool {
+	p.manager.mu.RLock()
+	state := p.manager.stateOf(TypeRealtimeStock, p.name)
+	p.manager.mu.RUnlock()
+	return state != StateUnhealthy && p.RealtimeStockProvider.IsHealthy()
+}
+
+// GetBaseProvider 实现 Decorator 接口
+func (p *managedStockProvider) GetBaseProvider() Provider {
+	return p.RealtimeStockProvider
+}
+
+// GetRealtimeStockProviderChain 按 names 的优先级组装故障转移提供商
+// 第一个名称为主提供商，其余为备用提供商。健康检查标记为不健康的提供商不加入链，
+// 降级的提供商排在健康提供商之后；全部不健康时返回 ErrProviderNotHealthy。
+func (m *ProviderManager) GetRealtimeStockProviderChain(names ...string) (*FailoverProvider, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("provider chain cannot be empty")
+	}
+
+	m.mu.RLock()
+	var healthy, degraded []string
+	for _, name := range names {
+		if _, exists := m.realtimeStockProviders[name]; !exists {
+			m.mu.RUnlock()
+			return nil, fmt.Errorf("realtime stock provider '%s' not found", name)
+		}
+		switch m.stateOf(TypeRealtimeStock, name) {
+		case StateHealthy:
+			healthy = append(healthy, name)
+		case StateDegraded:
+			degraded = append(degraded, name)
+		}
+	}
+	ordered := append(healthy, degraded...)
+	providers := make([]RealtimeStockProvider, len(ordered))
+	for i, name := range ordered {
+		providers[i] = m.realtimeStockProviders[name]
+	}
+	m.mu.RUnlock()
+
+	if len(ordered) == 0 {
+		return nil, fmt.Errorf("realtime stock providers %v: %w", names, ErrProviderNotHealthy)
+	}
+	return NewFailoverProvider(ordered, providers)
+}
+
+// GetRealtimeIndexProvider 获取实时指数数据提供商，被健康检查标记为不健康时返回 ErrProviderNotHealthy
 func (m *ProviderManager) GetRealtimeIndexProvider(name string) (RealtimeIndexProvider, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	if provider, exists := m.realtimeIndexProviders[name]; exists {
+		if m.stateOf(TypeRealtimeIndex, name) == StateUnhealthy {
+			return nil, fmt.Errorf("realtime index provider '%s': %w", name, ErrProviderNotHealthy)
+		}
 		return provider, nil
 	}
 
 	return nil, fmt.Errorf("realtime index provider '%s' not found", name)
 }
 
-// GetHistoricalProvider 获取历史数据提供商
+// GetHistoricalProvider 获取历史数据提供商，被健康检查标记为不健康时返回 ErrProviderNotHealthy
 func (m *ProviderManager) GetHistoricalProvider(name string) (HistoricalProvider, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	if provider, exists := m.historicalProviders[name]; exists {
+		if m.stateOf(TypeHistorical, name) == StateUnhealthy {
+			return nil, fmt.Errorf("historical provider '%s': %w", name, ErrProviderNotHealthy)
+		}
 		return provider, nil
 	}
 
@@ -201,16 +353,20 @@ func (m *ProviderManager) UnregisterProvider(name string) error {
 	// 从各个类别中移除
 	if _, exists := m.realtimeStockProviders[name]; exists {
 		delete(m.realtimeStockProviders, name)
+		delete(m.health, healthKey{TypeRealtimeStock, name})
+		m.stockSelector = nil
 		found = true
 	}
 
 	if _, exists := m.realtimeIndexProviders[name]; exists {
 		delete(m.realtimeIndexProviders, name)
+		delete(m.health, healthKey{TypeRealtimeIndex, name})
 		found = true
 	}
 
 	if _, exists := m.historicalProviders[name]; exists {
 		delete(m.historicalProviders, name)
+		delete(m.health, healthKey{TypeHistorical, name})
 		found = true
 	}
 
@@ -221,8 +377,10 @@ func (m *ProviderManager) UnregisterProvider(name string) error {
 	return nil
 }
 
-// Close 关闭管理器，清理所有提供商资源
+// Close 关闭管理器，停止健康检查并清理所有提供商资源
 func (m *ProviderManager) Close() error {
+	m.StopHealthChecks()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -257,6 +415,8 @@ func (m *ProviderManager) Close() error {
 	m.realtimeStockProviders = make(map[string]RealtimeStockProvider)
 	m.realtimeIndexProviders = make(map[string]RealtimeIndexProvider)
 	m.historicalProviders = make(map[string]HistoricalProvider)
+	m.health = make(map[healthKey]*ProviderStatus)
+	m.stockSelector = nil
 
 	if len(errors) > 0 {
 		return fmt.Errorf("errors occurred while closing providers: %v", errors)
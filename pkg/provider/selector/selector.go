@@ -0,0 +1,395 @@
+// Package selector 在多个实时股票提供商之间按健康度逐次选择
+//
+// Selector 为每个提供商维护延迟和错误率的指数加权移动平均（EWMA），得分最低的提供商成为首选。
+// 首选在 StickyPeriod 内最多切换一次以避免抖动；非首选提供商按 MinShare 分到少量流量，
+// 使其统计保持新鲜；SetPreferred 可以手动固定首选。
+package selector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+)
+
+// Config 选择器配置
+type Config struct {
+	// Alpha EWMA 平滑系数（0, 1]，越大越偏向最近的样本
+	Alpha float64 `yaml:"alpha"`
+	// ErrorPenalty 错误率折算为延迟的系数：得分 = 平均延迟 + 错误率 × ErrorPenalty
+	ErrorPenalty time.Duration `yaml:"error_penalty"`
+	// StickyPeriod 两次自动切换首选之间的最小间隔，首选不健康时立即切换
+	StickyPeriod time.Duration `yaml:"sticky_period"`
+	// MinShare 非首选提供商的最小流量份额，0 表示不分流
+	MinShare float64 `yaml:"min_share"`
+	// ExternalMeasurements 为 true 时不自行测量，由调用方通过 Observe 提供样本（如指标装饰器）
+	ExternalMeasurements bool `yaml:"external_measurements"`
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Alpha:        0.3,
+		ErrorPenalty: 2 * time.Second,
+		StickyPeriod: 30 * time.Second,
+		MinShare:     0.05,
+	}
+}
+
+// ProviderStats 单个提供商的选择统计
+type ProviderStats struct {
+	Name      string        `json:"name"`
+	Healthy   bool          `json:"healthy"`
+	Preferred bool          `json:"preferred"`
+	Calls     int64         `json:"calls"`
+	Errors    int64         `json:"errors"`
+	Samples   int64         `json:"samples"`
+	Latency   time.Duration `json:"latency_ewma"`
+	ErrorRate float64       `json:"error_rate_ewma"`
+	Score     time.Duration `json:"score"`
+	Share     float64       `json:"share"` // 占全部调用的比例
+}
+
+// SelectionStats 选择器统计
+type SelectionStats struct {
+	Preferred  string          `json:"preferred"`
+	Pinned     string          `json:"pinned,omitempty"`
+	Switches   int             `json:"switches"`
+	LastSwitch time.Time       `json:"last_switch"`
+	TotalCalls int64           `json:"total_calls"`
+	Providers  []ProviderStats `json:"providers"`
+}
+
+type candidate struct {
+	name     string
+	provider provider.RealtimeStockProvider
+
+	calls     int64
+	errors    int64
+	samples   int64
+	successes int64
+	latency   float64 // 纳秒
+	errorRate float64
+}
+
+// score 返回得分，没有样本时返回 +Inf
+func (c *candidate) score(penalty time.Duration) float64 {
+	if c.samples == 0 {
+		return math.Inf(1)
+	}
+	return c.latency + c.errorRate*float64(penalty)
+}
+
+// Selector 按健康度逐次选择的实时股票提供商
+type Selector struct {
+	config     Config
+	candidates []*candidate
+	now        func() time.Time
+
+	mu         sync.Mutex
+	preferred  int
+	pinned     int // -1 表示未固定
+	lastSwitch time.Time
+	switches   int
+	sinceProbe int
+	nextProbe  int
+	totalCalls int64
+}
+
+// New 创建选择器，names 与 providers 一一对应，第一个为初始首选；config 为 nil 时使用默认配置
+func New(names []string, providers []provider.RealtimeStockProvider, config *Config) (*Selector, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("selector requires at least one provider")
+	}
+	if len(names) != len(providers) {
+		return nil, fmt.Errorf("selector names and providers length mismatch: %d != %d", len(names), len(providers))
+	}
+	if config == nil {
+		config = DefaultConfig()
+	}
+	cfg := *config
+	if cfg.Alpha <= 0 || cfg.Alpha > 1 {
+		cfg.Alpha = DefaultConfig().Alpha
+	}
+
+	s := &Selector{config: cfg, now: time.Now, pinned: -1}
+	seen := make(map[string]bool, len(names))
+	for i, p := range providers {
+		if p == nil {
+			return nil, fmt.Errorf("provider '%s' cannot be nil", names[i])
+		}
+		if seen[names[i]] {
+			return nil, fmt.Errorf("duplicate provider name '%s'", names[i])
+		}
+		seen[names[i]] = true
+		s.candidates = append(s.candidates, &candidate{name: names[i], provider: p})
+	}
+	s.lastSwitch = s.now()
+	return s, nil
+}
+
+// Factory 返回供 ProviderManager.SetStockSelector 使用的工厂函数
+func Factory(config *Config) provider.StockSelectorFactory {
+	return func(names []string, providers []provider.RealtimeStockProvider) (provider.RealtimeStockProvider, error) {
+		return New(names, providers, config)
+	}
+}
+
+// Name 返回当前首选提供商的名称
+func (s *Selector) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.candidates[s.preferred].name
+}
+
+// IsHealthy 任一提供商健康即视为健康
+func (s *Selector) IsHealthy() bool {
+	for _, c := range s.candidates {
+		if c.provider.IsHealthy() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRateLimit 返回当前首选提供商的频率限制
+func (s *Selector) GetRateLimit() time.Duration {
+	s.mu.Lock()
+	c := s.candidates[s.preferred]
+	s.mu.Unlock()
+	return c.provider.GetRateLimit()
+}
+
+// IsSymbolSupported 任一提供商支持即返回 true
+func (s *Selector) IsSymbolSupported(symbol string) bool {
+	for _, c := range s.candidates {
+		if c.provider.IsSymbolSupported(symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchStockData 选择一个提供商获取实时数据，错误原样返回，不向其他提供商重试
+func (s *Selector) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	c, err := s.pick()
+	if err != nil {
+		return nil, err
+	}
+	start := s.now()
+	data, err := c.provider.FetchStockData(ctx, symbols)
+	s.measure(ctx, c, start, err)
+	return data, err
+}
+
+// FetchStockDataWithRaw 选择一个提供商获取实时数据及原始响应
+func (s *Selector) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	c, err := s.pick()
+	if err != nil {
+		return nil, "", err
+	}
+	start := s.now()
+	data, raw, err := c.provider.FetchStockDataWithRaw(ctx, symbols)
+	s.measure(ctx, c, start, err)
+	return data, raw, err
+}
+
+// SetPreferred 固定首选提供商，固定期间所有请求都发给它（它不健康时恢复自动选择）；
+// name 为空时取消固定
+func (s *Selector) SetPreferred(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name == "" {
+		s.pinned = -1
+		return nil
+	}
+	for i, c := range s.candidates {
+		if c.name == name {
+			s.pinned = i
+			s.switchTo(i, s.now())
+			return nil
+		}
+	}
+	return fmt.Errorf("provider '%s' not found", name)
+}
+
+// Observe 记录一次外部测量的请求结果，用于 ExternalMeasurements 模式
+func (s *Selector) Observe(name string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.candidates {
+		if c.name == name {
+			s.record(c, latency, err)
+			return
+		}
+	}
+}
+
+// GetSelectionStats 返回选择统计，提供商按创建时的顺序排列
+func (s *Selector) GetSelectionStats() SelectionStats {
+	healthy := make([]bool, len(s.candidates))
+	for i, c := range s.candidates {
+		healthy[i] = c.provider.IsHealthy()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := SelectionStats{
+		Preferred:  s.candidates[s.preferred].name,
+		Switches:   s.switches,
+		LastSwitch: s.lastSwitch,
+		TotalCalls: s.totalCalls,
+	}
+	if s.pinned >= 0 {
+		stats.Pinned = s.candidates[s.pinned].name
+	}
+	for i, c := range s.candidates {
+		ps := ProviderStats{
+			Name:      c.name,
+			Healthy:   healthy[i],
+			Preferred: i == s.preferred,
+			Calls:     c.calls,
+			Errors:    c.errors,
+			Samples:   c.samples,
+			Latency:   time.Duration(c.latency),
+			ErrorRate: c.errorRate,
+		}
+		if score := c.score(s.config.ErrorPenalty); !math.IsInf(score, 1) {
+			ps.Score = time.Duration(score)
+		}
+		if s.totalCalls > 0 {
+			ps.Share = float64(c.calls) / float64(s.totalCalls)
+		}
+		stats.Providers = append(stats.Providers, ps)
+	}
+	return stats
+}
+
+// pick 更新首选并选出本次请求使用的提供商
+func (s *Selector) pick() (*candidate, error) {
+	healthy := make([]bool, len(s.candidates))
+	anyHealthy := false
+	for i, c := range s.candidates {
+		healthy[i] = c.provider.IsHealthy()
+		anyHealthy = anyHealthy || healthy[i]
+	}
+	if !anyHealthy {
+		return nil, fmt.Errorf("all selector providers: %w", provider.ErrProviderNotHealthy)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+
+	var chosen int
+	if s.pinned >= 0 && healthy[s.pinned] {
+		chosen = s.pinned
+	} else {
+		s.updatePreferred(healthy, now)
+		chosen = s.preferred
+		if probe := s.probe(healthy); probe >= 0 {
+			chosen = probe
+		}
+	}
+
+	c := s.candidates[chosen]
+	c.calls++
+	s.totalCalls++
+	return c, nil
+}
+
+// updatePreferred 首选不健康时立即切换到得分最低的健康提供商，否则在 StickyPeriod 之后才切换
+func (s *Selector) updatePreferred(healthy []bool, now time.Time) {
+	best := -1
+	for i, c := range s.candidates {
+		if !healthy[i] {
+			continue
+		}
+		if best < 0 || c.score(s.config.ErrorPenalty) < s.candidates[best].score(s.config.ErrorPenalty) {
+			best = i
+		}
+	}
+	if best == s.preferred {
+		return
+	}
+	if !healthy[s.preferred] {
+		s.switchTo(best, now)
+		return
+	}
+	if now.Sub(s.lastSwitch) < s.config.StickyPeriod {
+		return
+	}
+	if s.candidates[best].score(s.config.ErrorPenalty) < s.candidates[s.preferred].score(s.config.ErrorPenalty) {
+		s.switchTo(best, now)
+	}
+}
+
+// probe 每 1/MinShare 次请求把一次分给非首选的健康提供商（轮流），返回 -1 表示本次不分流
+func (s *Selector) probe(healthy []bool) int {
+	if s.config.MinShare <= 0 || len(s.candidates) < 2 {
+		return -1
+	}
+	s.sinceProbe++
+	if float64(s.sinceProbe) < 1/s.config.MinShare {
+		return -1
+	}
+	for range s.candidates {
+		i := s.nextProbe % len(s.candidates)
+		s.nextProbe++
+		if i != s.preferred && healthy[i] {
+			s.sinceProbe = 0
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Selector) switchTo(i int, now time.Time) {
+	if i == s.preferred {
+		return
+	}
+	s.preferred = i
+	s.lastSwitch = now
+	s.switches++
+}
+
+// measure 自行测量模式下记录请求结果，调用方取消的请求不计入
+func (s *Selector) measure(ctx context.Context, c *candidate, start time.Time, err error) {
+	if s.config.ExternalMeasurements || ctx.Err() != nil {
+		return
+	}
+	latency := s.now().Sub(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(c, latency, err)
+}
+
+// record 更新 EWMA，失败的请求只计入错误率，避免快速失败拉低平均延迟（需要持有锁）
+func (s *Selector) record(c *candidate, latency time.Duration, err error) {
+	alpha := s.config.Alpha
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		c.errors++
+	}
+	if c.samples == 0 {
+		c.errorRate = failed
+	} else {
+		c.errorRate += alpha * (failed - c.errorRate)
+	}
+	c.samples++
+
+	if err != nil {
+		return
+	}
+	if c.successes == 0 {
+		c.latency = float64(latency)
+	} else {
+		c.latency += alpha * (float64(latency) - c.latency)
+	}
+	c.successes++
+}
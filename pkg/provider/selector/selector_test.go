@@ -0,0 +1,271 @@
+package selector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+)
+
+// fakeClock 手动推进的时钟，脚本化提供商通过推进时钟模拟请求延迟
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// scriptedProvider 每次请求耗时 latency（设置了 script 时按顺序循环取值），err 非空时返回错误
+type scriptedProvider struct {
+	name    string
+	clock   *fakeClock
+	latency time.Duration
+	script  []time.Duration
+	err     error
+	healthy bool
+	calls   int
+}
+
+func newScripted(name string, clock *fakeClock, latency time.Duration) *scriptedProvider {
+	return &scriptedProvider{name: name, clock: clock, latency: latency, healthy: true}
+}
+
+func (p *scriptedProvider) Name() string                         { return p.name }
+func (p *scriptedProvider) IsHealthy() bool                      { return p.healthy }
+func (p *scriptedProvider) GetRateLimit() time.Duration          { return time.Second }
+func (p *scriptedProvider) IsSymbolSupported(symbol string) bool { return true }
+
+func (p *scriptedProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	latency := p.latency
+	if len(p.script) > 0 {
+		latency = p.script[p.calls%len(p.script)]
+	}
+	p.calls++
+	p.clock.Advance(latency)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return []core.StockData{{Symbol: symbols[0]}}, nil
+}
+
+func (p *scriptedProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := p.FetchStockData(ctx, symbols)
+	return data, p.name, err
+}
+
+func newTestSelector(t *testing.T, clock *fakeClock, config *Config, providers ...*scriptedProvider) *Selector {
+	t.Helper()
+	names := make([]string, len(providers))
+	stockProviders := make([]provider.RealtimeStockProvider, len(providers))
+	for i, p := range providers {
+		names[i], stockProviders[i] = p.name, p
+	}
+	s, err := New(names, stockProviders, config)
+	require.NoError(t, err)
+	s.now = clock.Now
+	s.lastSwitch = clock.Now()
+	return s
+}
+
+// fetchN 每隔 interval 请求一次，共 n 次
+func fetchN(t *testing.T, s *Selector, clock *fakeClock, n int, interval time.Duration) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		_, _ = s.FetchStockData(context.Background(), []string{"600000"})
+		clock.Advance(interval)
+	}
+}
+
+func TestSelector_ShiftsToFasterProviderAfterStickyPeriod(t *testing.T) {
+	clock := newFakeClock()
+	tencent := newScripted("tencent", clock, 20*time.Millisecond)
+	sina := newScripted("sina", clock, 80*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{Alpha: 0.5, ErrorPenalty: time.Second, StickyPeriod: 10 * time.Second, MinShare: 0.1}, tencent, sina)
+
+	fetchN(t, s, clock, 20, time.Second)
+	assert.Equal(t, "tencent", s.Name())
+	assert.Equal(t, 2, sina.calls, "非首选按最小份额获得流量")
+
+	// 腾讯变慢：新浪的探测样本更优，但需要等到粘滞期结束才切换
+	tencent.latency = 300 * time.Millisecond
+	start := clock.Now()
+	for s.Name() == "tencent" {
+		require.Less(t, clock.Now().Sub(start), time.Minute, "选择没有切换")
+		fetchN(t, s, clock, 1, time.Second)
+	}
+	stats := s.GetSelectionStats()
+	assert.Equal(t, 1, stats.Switches)
+	assert.GreaterOrEqual(t, stats.LastSwitch.Sub(start), 0*time.Second)
+
+	sinaCalls := sina.calls
+	fetchN(t, s, clock, 10, time.Second)
+	assert.Equal(t, sinaCalls+9, sina.calls, "切换后新浪承担主要流量")
+}
+
+func TestSelector_ErrorsRaiseScore(t *testing.T) {
+	clock := newFakeClock()
+	tencent := newScripted("tencent", clock, 10*time.Millisecond)
+	sina := newScripted("sina", clock, 50*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{Alpha: 0.5, ErrorPenalty: time.Second, StickyPeriod: 5 * time.Second, MinShare: 0.2}, tencent, sina)
+
+	fetchN(t, s, clock, 10, time.Second)
+	tencent.err = errors.New("connection reset")
+	fetchN(t, s, clock, 10, time.Second)
+
+	stats := s.GetSelectionStats()
+	assert.Equal(t, "sina", stats.Preferred)
+	assert.Greater(t, stats.Providers[0].ErrorRate, 0.5)
+	assert.Equal(t, 10*time.Millisecond, stats.Providers[0].Latency, "失败请求不计入延迟")
+	assert.Greater(t, stats.Providers[0].Errors, int64(0))
+}
+
+func TestSelector_DoesNotFlapWithinStickyPeriod(t *testing.T) {
+	clock := newFakeClock()
+	a := newScripted("a", clock, 10*time.Millisecond)
+	b := newScripted("b", clock, 10*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{Alpha: 1, ErrorPenalty: time.Second, StickyPeriod: 10 * time.Second, MinShare: 0.5}, a, b)
+
+	// 两个提供商的最近样本轮流领先，没有粘滞时每次评估都会切换
+	a.script = []time.Duration{10 * time.Millisecond, 90 * time.Millisecond}
+	b.script = []time.Duration{90 * time.Millisecond, 10 * time.Millisecond}
+	fetchN(t, s, clock, 60, time.Second)
+
+	stats := s.GetSelectionStats()
+	assert.Greater(t, stats.Switches, 0)
+	assert.LessOrEqual(t, stats.Switches, 6, "60 秒内每 10 秒最多切换一次")
+}
+
+func TestSelector_UnhealthyPreferredSwitchesImmediately(t *testing.T) {
+	clock := newFakeClock()
+	tencent := newScripted("tencent", clock, 10*time.Millisecond)
+	sina := newScripted("sina", clock, 50*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{StickyPeriod: time.Hour}, tencent, sina)
+
+	fetchN(t, s, clock, 3, time.Second)
+	tencent.healthy = false
+	fetchN(t, s, clock, 1, time.Second)
+	assert.Equal(t, "sina", s.Name())
+	assert.Equal(t, 1, sina.calls)
+
+	sina.healthy = false
+	_, err := s.FetchStockData(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, provider.ErrProviderNotHealthy)
+}
+
+func TestSelector_SetPreferredPinsTraffic(t *testing.T) {
+	clock := newFakeClock()
+	tencent := newScripted("tencent", clock, 10*time.Millisecond)
+	sina := newScripted("sina", clock, 500*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{StickyPeriod: time.Second, MinShare: 0.5}, tencent, sina)
+
+	assert.Error(t, s.SetPreferred("eastmoney"))
+	require.NoError(t, s.SetPreferred("sina"))
+	fetchN(t, s, clock, 10, 2*time.Second)
+	assert.Equal(t, 0, tencent.calls, "固定期间不分流")
+	assert.Equal(t, "sina", s.GetSelectionStats().Pinned)
+
+	// 固定的提供商不健康时恢复自动选择
+	sina.healthy = false
+	fetchN(t, s, clock, 1, time.Second)
+	assert.Equal(t, 1, tencent.calls)
+	sina.healthy = true
+
+	require.NoError(t, s.SetPreferred(""))
+	fetchN(t, s, clock, 10, 2*time.Second)
+	assert.Equal(t, "tencent", s.Name())
+	assert.Empty(t, s.GetSelectionStats().Pinned)
+}
+
+func TestSelector_ExternalMeasurements(t *testing.T) {
+	clock := newFakeClock()
+	tencent := newScripted("tencent", clock, 10*time.Millisecond)
+	sina := newScripted("sina", clock, 10*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{StickyPeriod: time.Second, ExternalMeasurements: true}, tencent, sina)
+
+	fetchN(t, s, clock, 3, time.Second)
+	assert.Zero(t, s.GetSelectionStats().Providers[0].Samples, "外部测量模式不自行记录")
+
+	s.Observe("tencent", time.Second, nil)
+	s.Observe("sina", 20*time.Millisecond, nil)
+	fetchN(t, s, clock, 1, time.Second)
+	assert.Equal(t, "sina", s.Name())
+}
+
+func TestSelector_StatsShares(t *testing.T) {
+	clock := newFakeClock()
+	tencent := newScripted("tencent", clock, 10*time.Millisecond)
+	sina := newScripted("sina", clock, 50*time.Millisecond)
+	s := newTestSelector(t, clock, &Config{StickyPeriod: time.Minute, MinShare: 0.25}, tencent, sina)
+
+	fetchN(t, s, clock, 20, time.Second)
+	stats := s.GetSelectionStats()
+	require.Len(t, stats.Providers, 2)
+	assert.Equal(t, int64(20), stats.TotalCalls)
+	assert.InDelta(t, 0.75, stats.Providers[0].Share, 0.001)
+	assert.InDelta(t, 0.25, stats.Providers[1].Share, 0.001)
+	assert.True(t, stats.Providers[0].Preferred)
+	assert.Equal(t, 50*time.Millisecond, stats.Providers[1].Score)
+}
+
+func TestProviderManager_AutoSelect(t *testing.T) {
+	clock := newFakeClock()
+	m := provider.NewProviderManager()
+	defer m.Close()
+
+	_, err := m.GetRealtimeStockProvider(provider.AutoSelect)
+	assert.Error(t, err, "未设置选择器时 auto 不是已注册的名称")
+
+	m.SetStockSelector(Factory(&Config{StickyPeriod: time.Minute}))
+	tencent := newScripted("tencent", clock, 10*time.Millisecond)
+	require.NoError(t, m.RegisterRealtimeStockProvider("tencent", tencent))
+
+	single, err := m.GetRealtimeStockProvider(provider.AutoSelect)
+	require.NoError(t, err)
+	assert.Same(t, tencent, single, "只有一个候选时直接返回")
+
+	sina := newScripted("sina", clock, 10*time.Millisecond)
+	require.NoError(t, m.RegisterRealtimeStockProvider("sina", sina))
+	got, err := m.GetRealtimeStockProvider(provider.AutoSelect)
+	require.NoError(t, err)
+	s, ok := got.(*Selector)
+	require.True(t, ok, fmt.Sprintf("%T", got))
+	again, _ := m.GetRealtimeStockProvider(provider.AutoSelect)
+	assert.Same(t, s, again, "统计在多次获取之间保留")
+
+	stats := s.GetSelectionStats()
+	assert.Equal(t, "sina", stats.Preferred, "健康状态相同时按名称排序")
+	assert.Len(t, stats.Providers, 2)
+
+	// 健康检查标记为不健康的候选即使自身报告健康也不再被选择
+	m.SetHealthCheckConfig(provider.HealthCheckConfig{FailureThreshold: 1})
+	sina.healthy = false
+	m.CheckHealth(context.Background())
+	sina.healthy = true
+	_, err = s.FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, sina.calls)
+	assert.Equal(t, 1, tencent.calls)
+	assert.Equal(t, "tencent", s.Name())
+}
@@ -1,30 +1,13 @@
 package sina
 
 import (
-	"io"
 	"strconv"
 	"strings"
 	"time"
 
 	"stocksub/pkg/core"
-
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
 )
 
-// gbkToUtf8 将GBK编码转换为UTF-8
-func gbkToUtf8(gbkStr string) string {
-	if gbkStr == "" {
-		return ""
-	}
-	reader := transform.NewReader(strings.NewReader(gbkStr), simplifiedchinese.GBK.NewDecoder())
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return gbkStr
-	}
-	return string(data)
-}
-
 // parseSinaData 解析新浪返回的数据
 func parseSinaData(data string) []core.StockData {
 	lines := strings.Split(data, ";")
@@ -64,7 +47,7 @@ func parseSinaData(data string) []core.StockData {
 
 		stockData := core.StockData{
 			Symbol:        symbol,
-			Name:          gbkToUtf8(fields[0]),
+			Name:          fields[0],
 			Price:         price,
 			Change:        change,
 			ChangePercent: changePercent,
@@ -126,3 +109,50 @@ func parseTime(dateStr, timeStr string) time.Time {
 	}
 	return ts
 }
+
+// parseSinaIndexData 解析新浪简版指数行情 (list=s_sh000001)
+// 每行格式: var hq_str_s_sh000001="名称,点数,涨跌点数,涨跌幅(%),成交量(手),成交额(万元)";
+func parseSinaIndexData(data string) []core.IndexData {
+	lines := strings.Split(data, ";")
+	results := make([]core.IndexData, 0, len(lines))
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		varPart, dataPart, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		symbol := extractIndexSymbol(varPart)
+		fields := strings.Split(strings.Trim(dataPart, ` "`), ",")
+		// 不存在的代码返回空字符串
+		if symbol == "" || len(fields) < 6 || fields[0] == "" {
+			continue
+		}
+
+		results = append(results, core.IndexData{
+			Symbol:        symbol,
+			Name:          fields[0],
+			Value:         parseFloat(fields[1]),
+			Change:        parseFloat(fields[2]),
+			ChangePercent: parseFloat(fields[3]),
+			Volume:        parseInt(fields[4]),
+			Turnover:      parseFloat(fields[5]) * 10000, // 万元转换为元
+		})
+	}
+
+	return results
+}
+
+// extractIndexSymbol 从变量名中提取带市场前缀的指数代码, e.g., var hq_str_s_sh000001 -> sh000001
+func extractIndexSymbol(rawVar string) string {
+	idx := strings.LastIndex(rawVar, "hq_str_s_")
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimSpace(rawVar[idx+len("hq_str_s_"):])
+}
@@ -0,0 +1,118 @@
+package sina
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"stocksub/pkg/core"
+)
+
+// toGBK 模拟新浪接口返回的 GBK 编码响应
+func toGBK(t *testing.T, s string) string {
+	t.Helper()
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(s)
+	require.NoError(t, err)
+	return encoded
+}
+
+// 抓取的简版指数行情响应：上证指数、深证成指正常交易，sh000999 为不存在的代码，
+// sz399106 为停牌/未开盘时的零成交量数据
+const indexFixture = `var hq_str_s_sh000001="上证指数,3228.0620,-14.3460,-0.44,3783825,45841658";
+var hq_str_s_sz399001="深证成指,10128.2642,35.9286,0.36,516184638,70168232";
+var hq_str_s_sh000999="";
+var hq_str_s_sz399106="深证综指,1980.5100,0.0000,0.00,0,0";
+`
+
+func TestParseSinaIndexData(t *testing.T) {
+	data := parseSinaIndexData(indexFixture)
+	require.Len(t, data, 3)
+
+	assert.Equal(t, core.IndexData{
+		Symbol:        "sh000001",
+		Name:          "上证指数",
+		Value:         3228.062,
+		Change:        -14.346,
+		ChangePercent: -0.44,
+		Volume:        3783825,
+		Turnover:      458416580000,
+	}, data[0])
+
+	assert.Equal(t, "sz399001", data[1].Symbol)
+	assert.Equal(t, "深证成指", data[1].Name)
+	assert.Equal(t, 0.36, data[1].ChangePercent)
+
+	// 零成交量的指数仍然返回，数值字段为 0
+	assert.Equal(t, core.IndexData{Symbol: "sz399106", Name: "深证综指", Value: 1980.51}, data[2])
+}
+
+func TestParseSinaIndexData_MalformedLines(t *testing.T) {
+	raw := `var hq_str_s_sh000001="上证指数,3228.06";
+// comment
+garbage
+var hq_str_sh600000="浦发银行,10.00";
+`
+	assert.Empty(t, parseSinaIndexData(raw))
+}
+
+func TestClient_IsIndexSupported(t *testing.T) {
+	client := NewClient()
+	for symbol, want := range map[string]bool{
+		"sh000001":  true,
+		"sh000300":  true,
+		"sz399001":  true,
+		"sz399006":  true,
+		"sh600000":  false,
+		"sz000001":  false,
+		"000001":    false,
+		"bj899050":  false,
+		"sh00000a":  false,
+		"000001.SH": true,
+		"399001":    true,
+		"000300.SZ": false,
+	} {
+		assert.Equal(t, want, client.IsIndexSupported(symbol), symbol)
+	}
+	assert.Equal(t, client.baseURL+"s_sh000001,s_sz399001", client.buildIndexURL([]string{"000001.SH", "sz399001"}))
+}
+
+func TestClient_AcceptsAnySymbolForm(t *testing.T) {
+	client := NewClient()
+	for _, symbol := range []string{"600000", "600000.SH", "sh600000", "301001", "830799.BJ"} {
+		assert.True(t, client.IsSymbolSupported(symbol), symbol)
+	}
+	for _, symbol := range []string{"sh000001", "00700", "AAPL", "700000"} {
+		assert.False(t, client.IsSymbolSupported(symbol), symbol)
+	}
+	assert.Equal(t, client.baseURL+"sh600000,sz000001,bj830799", client.buildURL([]string{"600000.SH", "000001", "bj830799"}))
+}
+
+func TestClient_FetchIndexData(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_, _ = w.Write([]byte(toGBK(t, indexFixture)))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.baseURL = server.URL + "/list="
+	defer client.Close()
+
+	data, err := client.FetchIndexData(context.Background(), []string{"sh000001", "sz399001"})
+	require.NoError(t, err)
+	assert.Equal(t, "/list=s_sh000001,s_sz399001", gotPath)
+	assert.Len(t, data, 3)
+
+	_, err = client.FetchIndexData(context.Background(), []string{"sh600000"})
+	assert.Error(t, err)
+
+	data, err = client.FetchIndexData(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
 	"stocksub/pkg/logger"
 	"stocksub/pkg/provider"
 )
@@ -18,29 +19,54 @@ import (
 // Client 新浪股票数据提供商
 type Client struct {
 	provider.Provider // 实现核心Provider接口
-	httpClient        *http.Client
-	userAgent         string
+	httpClient        *core.HTTPClient
 	log               *logrus.Entry
 	baseURL           string
+	timeout           time.Duration // 单次请求的超时时间，通过 context 控制
 	rateLimit         time.Duration
+	chunks            provider.ChunkOptions // 股票代码过多时的分片请求配置
+	strictDecoding    bool                  // 响应无法完整解码时返回错误
 }
 
-// NewClient 创建新浪数据提供商
-func NewClient() *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     30 * time.Second,
-			},
-			Timeout: 15 * time.Second,
-		},
-		userAgent: "StockSub/1.0",
-		log:       logger.WithComponent("SinaProvider"),
-		baseURL:   "http://hq.sinajs.cn/list=",
-		rateLimit: 200 * time.Millisecond, // 默认速率限制
+// Option 新浪数据提供商的创建选项
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的 HTTP 客户端，多个提供商可以共享同一个客户端的连接池
+func WithHTTPClient(httpClient *core.HTTPClient) Option {
+	return func(p *Client) {
+		p.httpClient = httpClient
+	}
+}
+
+// WithTimeout 设置单次请求的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Client) {
+		p.timeout = timeout
+	}
+}
+
+// WithStrictDecoding 开启严格解码，响应中存在无法解码的字节时返回 provider.ErrEncodingFailure，
+// 而不是以替换字符继续解析
+func WithStrictDecoding(strict bool) Option {
+	return func(p *Client) {
+		p.strictDecoding = strict
+	}
+}
+
+// NewClient 创建新浪数据提供商，未指定 HTTP 客户端时使用进程级共享客户端
+func NewClient(opts ...Option) *Client {
+	p := &Client{
+		httpClient: core.SharedHTTPClient(),
+		log:        logger.WithComponent("SinaProvider"),
+		baseURL:    "http://hq.sinajs.cn/list=",
+		timeout:    15 * time.Second,
+		rateLimit:  200 * time.Millisecond, // 默认速率限制
+		chunks:     provider.ChunkOptions{Size: provider.DefaultChunkSize, Concurrency: 1},
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Name 返回提供商名称
@@ -48,6 +74,11 @@ func (p *Client) Name() string {
 	return "sina"
 }
 
+// UpstreamHost 返回接口的主机名，用于按主机共享限流
+func (p *Client) UpstreamHost() string {
+	return provider.URLHost(p.baseURL)
+}
+
 // GetRateLimit 获取请求频率限制
 func (p *Client) GetRateLimit() time.Duration {
 	return p.rateLimit
@@ -63,9 +94,30 @@ func (p *Client) SetRateLimit(limit time.Duration) {
 	p.rateLimit = limit
 }
 
-// SetTimeout 设置请求超时时间
+// SetTimeout 设置请求超时时间，通过请求的 context 生效，不影响共享的 HTTP 客户端
 func (p *Client) SetTimeout(timeout time.Duration) {
-	p.httpClient.Timeout = timeout
+	p.timeout = timeout
+}
+
+// GetStatus 返回提供商状态，包含 HTTP 连接复用统计（共享客户端时为所有使用者的合计）
+func (p *Client) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       p.Name(),
+		"timeout":    p.timeout.String(),
+		"rate_limit": p.rateLimit.String(),
+		"chunk_size": p.chunks.Size,
+		"http_conns": p.httpClient.Stats(),
+	}
+}
+
+// SetChunkSize 设置单次请求的最大股票数量，超出时自动拆分为多个请求
+func (p *Client) SetChunkSize(size int) {
+	p.chunks.Size = size
+}
+
+// SetChunkConcurrency 设置同时进行的分片请求数，默认顺序请求
+func (p *Client) SetChunkConcurrency(concurrency int) {
+	p.chunks.Concurrency = concurrency
 }
 
 // SetMaxRetries (空实现，为了接口兼容性)
@@ -81,12 +133,10 @@ func (p *Client) Close() error {
 	return nil
 }
 
-// IsSymbolSupported 检查是否支持该股票代码
+// IsSymbolSupported 检查是否支持该股票代码，接受 core.ParseSymbol 能识别的任意 A股写法
 func (p *Client) IsSymbolSupported(symbol string) bool {
-	if len(symbol) != 6 {
-		return false
-	}
-	return strings.HasPrefix(symbol, "6") || strings.HasPrefix(symbol, "0") || strings.HasPrefix(symbol, "3") || strings.HasPrefix(symbol, "8") || strings.HasPrefix(symbol, "4")
+	parsed, err := core.ParseSymbol(symbol)
+	return err == nil && parsed.IsAShare() && !parsed.IsIndex()
 }
 
 // FetchStockData 获取股票数据
@@ -96,28 +146,39 @@ func (p *Client) FetchStockData(ctx context.Context, symbols []string) ([]core.S
 }
 
 // FetchStockDataWithRaw 获取股票数据和原始响应
+//
+// 股票数量超过分片大小时按 rateLimit 间隔拆分为多个请求，部分分片失败时返回成功分片的数据和 *provider.MultiError。
 func (p *Client) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
 	if len(symbols) == 0 {
 		return []core.StockData{}, "", nil
 	}
 
+	opts := p.chunks
+	opts.Interval = p.rateLimit
+	return provider.FetchInChunks(ctx, symbols, opts, p.fetchChunk)
+}
+
+// fetchChunk 用一个请求获取一组股票的数据和原始响应
+func (p *Client) fetchChunk(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
 	url := p.buildURL(symbols)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, "", fmt.Errorf("create request failed: %w", err)
 	}
 
-	req.Header.Set("User-Agent", p.userAgent)
 	req.Header.Set("Referer", "https://finance.sina.com.cn/")
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("HTTP request failed: %w", err)
+		return nil, "", core.WrapRequestError(fmt.Errorf("HTTP request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("HTTP status error: %d", resp.StatusCode)
+		return nil, "", core.NewHTTPStatusError(resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -125,32 +186,96 @@ func (p *Client) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([
 		return nil, "", fmt.Errorf("read response failed: %w", err)
 	}
 
-	rawData := string(body)
+	rawData, err := provider.DecodeBody(body, resp.Header.Get("Content-Type"), p.strictDecoding)
+	if err != nil {
+		return nil, "", apperrors.Wrap(apperrors.CodeParseFailure, fmt.Errorf("decode response failed: %w", err))
+	}
 	result := parseSinaData(rawData)
 
 	return result, rawData, nil
 }
 
+// withTimeout 为请求附加超时时间，timeout <= 0 时不限制
+func (p *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.timeout)
+}
+
 // buildURL 构建新浪行情URL
 func (p *Client) buildURL(symbols []string) string {
 	var parts []string
 	for _, symbol := range symbols {
-		prefix := p.getMarketPrefix(symbol)
-		parts = append(parts, prefix+symbol)
+		parts = append(parts, sinaCode(symbol))
 	}
 	return p.baseURL + strings.Join(parts, ",")
 }
 
-// getMarketPrefix 根据股票代码获取市场前缀
-func (p *Client) getMarketPrefix(symbol string) string {
-	switch {
-	case strings.HasPrefix(symbol, "6"):
-		return "sh"
-	case strings.HasPrefix(symbol, "0"), strings.HasPrefix(symbol, "3"):
-		return "sz"
-	case strings.HasPrefix(symbol, "8"), strings.HasPrefix(symbol, "4"):
-		return "bj"
-	default:
-		return "sh" // 默认
+// sinaCode 将任意写法的代码转换为新浪格式（如 600000.SH -> sh600000），无法识别时按上海市场处理
+func sinaCode(symbol string) string {
+	if parsed, err := core.ParseSymbol(symbol); err == nil {
+		return parsed.SinaFormat()
+	}
+	return "sh" + symbol
+}
+
+// IsIndexSupported 检查是否支持该指数代码，支持上证 000xxx 和深证 399xxx，需带市场前缀或后缀（如 sh000001、399001.SZ）
+func (p *Client) IsIndexSupported(indexSymbol string) bool {
+	parsed, err := core.ParseSymbol(indexSymbol)
+	return err == nil && parsed.IsIndex() && (parsed.Market == core.MarketSH || parsed.Market == core.MarketSZ)
+}
+
+// FetchIndexData 获取指数数据 (实现 provider.RealtimeIndexProvider 接口)
+func (p *Client) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	if len(indexSymbols) == 0 {
+		return []core.IndexData{}, nil
+	}
+
+	for _, symbol := range indexSymbols {
+		if !p.IsIndexSupported(symbol) {
+			return nil, fmt.Errorf("unsupported index symbol: %s", symbol)
+		}
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	url := p.buildIndexURL(indexSymbols)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+
+	req.Header.Set("Referer", "https://finance.sina.com.cn/")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, core.WrapRequestError(fmt.Errorf("HTTP request failed: %w", err))
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, core.NewHTTPStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %w", err)
+	}
+
+	rawData, err := provider.DecodeBody(body, resp.Header.Get("Content-Type"), p.strictDecoding)
+	if err != nil {
+		return nil, apperrors.Wrap(apperrors.CodeParseFailure, fmt.Errorf("decode response failed: %w", err))
+	}
+	return parseSinaIndexData(rawData), nil
+}
+
+// buildIndexURL 构建新浪简版指数行情URL
+func (p *Client) buildIndexURL(indexSymbols []string) string {
+	parts := make([]string, len(indexSymbols))
+	for i, symbol := range indexSymbols {
+		parts[i] = "s_" + sinaCode(symbol)
+	}
+	return p.baseURL + strings.Join(parts, ",")
 }
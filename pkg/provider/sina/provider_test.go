@@ -0,0 +1,135 @@
+package sina
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+	"stocksub/pkg/provider"
+)
+
+// sinaQuoteLine 生成一条新浪行情，prefixed 为带市场前缀的代码
+func sinaQuoteLine(prefixed string) string {
+	fields := make([]string, 33)
+	for i := range fields {
+		fields[i] = "0"
+	}
+	fields[0] = "Stock"
+	fields[3] = "10.00"
+	fields[30] = "2025-08-20"
+	fields[31] = "15:00:00"
+	return fmt.Sprintf("var hq_str_%s=\"%s\";\n", prefixed, strings.Join(fields, ","))
+}
+
+func TestClient_FetchStockData_SplitsIntoChunks(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbols := strings.Split(strings.TrimPrefix(r.URL.Path, "/list="), ",")
+		mu.Lock()
+		requests = append(requests, symbols)
+		mu.Unlock()
+
+		if symbols[0] == "sz000858" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		for _, symbol := range symbols {
+			_, _ = io.WriteString(w, sinaQuoteLine(symbol))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.baseURL = server.URL + "/list="
+	client.SetRateLimit(0)
+	client.SetChunkSize(2)
+	defer client.Close()
+
+	symbols := []string{"600000", "000001", "300503", "601398", "000858"}
+	data, raw, err := client.FetchStockDataWithRaw(context.Background(), symbols)
+
+	assert.Equal(t, [][]string{{"sh600000", "sz000001"}, {"sz300503", "sh601398"}, {"sz000858"}}, requests)
+
+	var multiErr *provider.MultiError
+	require.ErrorAs(t, err, &multiErr)
+	assert.Equal(t, 3, multiErr.Chunks)
+	assert.Equal(t, []string{"000858"}, multiErr.FailedSymbols())
+
+	got := make([]string, len(data))
+	for i, stock := range data {
+		got[i] = stock.Symbol
+	}
+	assert.Equal(t, symbols[:4], got)
+	assert.Len(t, parseSinaData(raw), 4)
+}
+
+func TestClient_SetTimeout_DoesNotRebuildClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	httpClient, err := core.NewHTTPClient(core.DefaultHTTPClientConfig())
+	require.NoError(t, err)
+	client := NewClient(WithHTTPClient(httpClient))
+	client.baseURL = server.URL + "/list="
+	client.SetTimeout(20 * time.Millisecond)
+	defer client.Close()
+
+	_, err = client.FetchStockData(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, apperrors.Is(err, apperrors.CodeNetworkTimeout))
+	_, err = client.FetchIndexData(context.Background(), []string{"sh000001"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, apperrors.Is(err, apperrors.CodeNetworkTimeout))
+
+	assert.Same(t, httpClient, client.httpClient)
+	assert.Equal(t, int64(2), client.GetStatus()["http_conns"].(core.HTTPConnStats).Requests)
+}
+
+func TestClient_FetchStockDataWithRaw_DecodesCharset(t *testing.T) {
+	line := strings.Replace(sinaQuoteLine("sh600000"), "Stock", "浦发银行", 1)
+	contentType := map[string]string{
+		"/gbk/":  "application/javascript; charset=GB18030",
+		"/utf8/": "application/javascript; charset=utf-8",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for prefix, value := range contentType {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				w.Header().Set("Content-Type", value)
+			}
+		}
+		if strings.HasPrefix(r.URL.Path, "/utf8/") {
+			_, _ = io.WriteString(w, line)
+			return
+		}
+		_, _ = w.Write([]byte(toGBK(t, line)))
+	}))
+	defer server.Close()
+
+	for prefix := range contentType {
+		client := NewClient(WithStrictDecoding(true))
+		client.baseURL = server.URL + prefix + "list="
+		data, raw, err := client.FetchStockDataWithRaw(context.Background(), []string{"600000"})
+		require.NoError(t, err, prefix)
+		assert.Equal(t, line, raw, prefix)
+		require.Len(t, data, 1, prefix)
+		assert.Equal(t, "浦发银行", data[0].Name, prefix)
+		client.Close()
+	}
+}
@@ -2,6 +2,7 @@ package tencent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,43 +11,117 @@ import (
 	"time"
 
 	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
 	"stocksub/pkg/logger"
+	"stocksub/pkg/provider"
 )
 
+// defaultBaseURL 腾讯实时行情接口地址
+const defaultBaseURL = "http://qt.gtimg.cn/q="
+
 // Client 腾讯股票数据提供商 - 简化版
 // 专注于核心数据获取功能，频率控制等横切关注点通过装饰器处理
 type Client struct {
-	httpClient *http.Client
-	userAgent  string
+	httpClient *core.HTTPClient
+	baseURL    string
 	log        *logger.Entry
+	timeout    time.Duration         // 单次请求的超时时间，通过 context 控制
+	rateLimit  time.Duration         // 分片请求之间的最小间隔
+	chunks     provider.ChunkOptions // 股票代码过多时的分片请求配置
+
+	strictDecoding bool // 响应无法完整解码时返回错误
+}
+
+// Option 腾讯数据提供商的创建选项
+type Option func(*Client)
+
+// WithHTTPClient 使用指定的 HTTP 客户端，多个提供商可以共享同一个客户端的连接池
+func WithHTTPClient(httpClient *core.HTTPClient) Option {
+	return func(p *Client) {
+		p.httpClient = httpClient
+	}
 }
 
-// NewClient 创建腾讯数据提供商
-func NewClient() *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     30 * time.Second,
-				DisableKeepAlives:   false,
-				MaxConnsPerHost:     10,
-			},
-			Timeout: 15 * time.Second,
-		},
-		userAgent: "StockSub/1.0",
-		log:       logger.WithComponent("TencentProvider"),
+// WithTimeout 设置单次请求的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Client) {
+		p.timeout = timeout
 	}
 }
 
+// WithStrictDecoding 开启严格解码，响应中存在无法解码的字节时返回 provider.ErrEncodingFailure，
+// 而不是以替换字符继续解析
+func WithStrictDecoding(strict bool) Option {
+	return func(p *Client) {
+		p.strictDecoding = strict
+	}
+}
+
+// NewClient 创建腾讯数据提供商，未指定 HTTP 客户端时使用进程级共享客户端
+func NewClient(opts ...Option) *Client {
+	p := &Client{
+		httpClient: core.SharedHTTPClient(),
+		baseURL:    defaultBaseURL,
+		log:        logger.WithComponent("TencentProvider"),
+		timeout:    15 * time.Second,
+		rateLimit:  200 * time.Millisecond,
+		chunks:     provider.ChunkOptions{Size: provider.DefaultChunkSize, Concurrency: 1},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetBaseURL 设置接口地址，主要用于测试
+func (p *Client) SetBaseURL(baseURL string) {
+	p.baseURL = baseURL
+}
+
+// SetTimeout 设置单次请求的超时时间，不影响共享的 HTTP 客户端
+func (p *Client) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// GetStatus 返回提供商状态，包含 HTTP 连接复用统计（共享客户端时为所有使用者的合计）
+func (p *Client) GetStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       p.Name(),
+		"timeout":    p.timeout.String(),
+		"rate_limit": p.rateLimit.String(),
+		"chunk_size": p.chunks.Size,
+		"http_conns": p.httpClient.Stats(),
+	}
+}
+
+// SetRateLimit 设置分片请求之间的最小间隔
+func (p *Client) SetRateLimit(limit time.Duration) {
+	p.rateLimit = limit
+}
+
+// SetChunkSize 设置单次请求的最大股票数量，超出时自动拆分为多个请求
+func (p *Client) SetChunkSize(size int) {
+	p.chunks.Size = size
+}
+
+// SetChunkConcurrency 设置同时进行的分片请求数，默认顺序请求
+func (p *Client) SetChunkConcurrency(concurrency int) {
+	p.chunks.Concurrency = concurrency
+}
+
 // Name 返回提供商名称
 func (p *Client) Name() string {
 	return "tencent"
 }
 
-// GetRateLimit 获取请求频率限制 (为了接口兼容性提供默认值)
+// UpstreamHost 返回接口的主机名，用于按主机共享限流
+func (p *Client) UpstreamHost() string {
+	return provider.URLHost(p.baseURL)
+}
+
+// GetRateLimit 获取请求频率限制，单次调用之间的频率由装饰器控制，这里只用于分片请求之间的间隔
 func (p *Client) GetRateLimit() time.Duration {
-	return 200 * time.Millisecond // 默认频率限制，实际由装饰器控制
+	return p.rateLimit
 }
 
 // IsHealthy 检查提供商健康状态
@@ -62,15 +137,47 @@ func (p *Client) FetchStockData(ctx context.Context, symbols []string) ([]core.S
 }
 
 // FetchStockDataWithRaw 获取股票数据和原始响应 (实现 core.RealtimeStockProvider 接口)
+//
+// 股票数量超过分片大小时拆分为多个请求，结果按输入顺序合并，原始响应以换行拼接；
+// 部分分片失败时返回成功分片的数据和 *provider.MultiError。
 func (p *Client) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
-	debugMode := os.Getenv("DEBUG") == "1"
-
 	if len(symbols) == 0 {
 		return []core.StockData{}, "", nil
 	}
 
+	opts := p.chunks
+	opts.Interval = p.rateLimit
+	return provider.FetchInChunks(ctx, symbols, opts, p.fetchChunk)
+}
+
+// fetchChunk 用一个请求获取一组股票的数据和原始响应
+func (p *Client) fetchChunk(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	rawData, err := p.fetchRaw(ctx, symbols)
+	if err != nil {
+		return nil, "", err
+	}
+
+	debugMode := os.Getenv("DEBUG") == "1"
+	if debugMode {
+		p.log.Debugf("Parsing response data...")
+	}
+	parseStart := time.Now()
+	result := parseTencentData(rawData)
+	parseTime := time.Since(parseStart)
+
 	if debugMode {
-		p.log.Debugf("Starting FetchStockDataWithRaw for symbols: %v", symbols)
+		p.log.Infof("Parsing completed in %v, parsed %d records", parseTime, len(result))
+	}
+
+	return result, rawData, nil
+}
+
+// fetchRaw 用一个请求获取一组代码的行情，返回解码后的原始响应，股票和指数共用同一接口
+func (p *Client) fetchRaw(ctx context.Context, symbols []string) (string, error) {
+	debugMode := os.Getenv("DEBUG") == "1"
+
+	if debugMode {
+		p.log.Debugf("Starting request for symbols: %v", symbols)
 	}
 
 	url := p.buildURL(symbols)
@@ -78,23 +185,27 @@ func (p *Client) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([
 		p.log.Debugf("Request URL: %s", url)
 	}
 
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
 	requestStart := time.Now()
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("create request failed: %w", err)
+		return "", fmt.Errorf("create request failed: %w", err)
 	}
 
-	req.Header.Set("User-Agent", p.userAgent)
-
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("HTTP request failed: %w", err)
+		return "", core.WrapRequestError(fmt.Errorf("HTTP request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, "", fmt.Errorf("read response failed: %w", err)
+		return "", fmt.Errorf("read response failed: %w", err)
 	}
 
 	requestDuration := time.Since(requestStart)
@@ -104,54 +215,50 @@ func (p *Client) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("HTTP status error: %d", resp.StatusCode)
+		return "", core.NewHTTPStatusError(resp.StatusCode)
 	}
 
 	if len(body) == 0 {
-		return nil, "", fmt.Errorf("empty response")
+		return "", apperrors.Wrap(apperrors.CodeParseFailure, errors.New("empty response"))
 	}
 
-	rawData := string(body)
-
-	if debugMode {
-		p.log.Debugf("Parsing response data...")
-	}
-	parseStart := time.Now()
-	result := parseTencentData(rawData)
-	parseTime := time.Since(parseStart)
-
-	if debugMode {
-		p.log.Infof("Parsing completed in %v, parsed %d records", parseTime, len(result))
+	rawData, err := provider.DecodeBody(body, resp.Header.Get("Content-Type"), p.strictDecoding)
+	if err != nil {
+		return "", apperrors.Wrap(apperrors.CodeParseFailure, fmt.Errorf("decode response failed: %w", err))
 	}
-
-	return result, rawData, nil
+	return rawData, nil
 }
 
-// IsSymbolSupported 检查是否支持该股票代码
+// IsSymbolSupported 检查是否支持该股票代码，接受 core.ParseSymbol 能识别的任意 A股写法
 func (p *Client) IsSymbolSupported(symbol string) bool {
-	if symbol == "" {
-		return false
-	}
+	parsed, err := core.ParseSymbol(symbol)
+	return err == nil && parsed.IsAShare() && !parsed.IsIndex()
+}
 
-	// A股上证
-	if len(symbol) == 6 && (strings.HasPrefix(symbol, "6")) {
-		return true
-	}
+// IsIndexSupported 检查是否支持该指数代码，上证指数需写成 sh000001 或 000001.SH
+func (p *Client) IsIndexSupported(indexSymbol string) bool {
+	parsed, err := core.ParseSymbol(indexSymbol)
+	return err == nil && parsed.IsIndex() && (parsed.Market == core.MarketSH || parsed.Market == core.MarketSZ)
+}
 
-	// A股深证
-	if len(symbol) == 6 && (strings.HasPrefix(symbol, "0") ||
-		strings.HasPrefix(symbol, "300")) {
-		return true
+// FetchIndexData 获取指数数据 (实现 provider.RealtimeIndexProvider 接口)，
+// 与股票使用同一接口，返回的代码为 sh000001 这样带市场前缀的形式
+func (p *Client) FetchIndexData(ctx context.Context, indexSymbols []string) ([]core.IndexData, error) {
+	if len(indexSymbols) == 0 {
+		return []core.IndexData{}, nil
 	}
 
-	// A股北交所
-	if len(symbol) == 6 && (strings.HasPrefix(symbol, "43") ||
-		strings.HasPrefix(symbol, "82") || strings.HasPrefix(symbol, "83") ||
-		strings.HasPrefix(symbol, "87") || strings.HasPrefix(symbol, "920")) {
-		return true
+	for _, symbol := range indexSymbols {
+		if !p.IsIndexSupported(symbol) {
+			return nil, fmt.Errorf("unsupported index symbol: %s", symbol)
+		}
 	}
 
-	return false
+	rawData, err := p.fetchRaw(ctx, indexSymbols)
+	if err != nil {
+		return nil, err
+	}
+	return parseTencentIndexData(rawData), nil
 }
 
 // Close 关闭提供商，清理资源
@@ -166,23 +273,16 @@ func (p *Client) Close() error {
 func (p *Client) buildURL(symbols []string) string {
 	var parts []string
 	for _, symbol := range symbols {
-		prefix := p.getMarketPrefix(symbol)
-		parts = append(parts, prefix+symbol)
+		parts = append(parts, tencentCode(symbol))
 	}
 
-	return "http://qt.gtimg.cn/q=" + strings.Join(parts, ",")
+	return p.baseURL + strings.Join(parts, ",")
 }
 
-// getMarketPrefix 根据股票代码获取市场前缀
-func (p *Client) getMarketPrefix(symbol string) string {
-	switch {
-	case strings.HasPrefix(symbol, "6") || strings.HasPrefix(symbol, "5"):
-		return "sh"
-	case strings.HasPrefix(symbol, "0") || strings.HasPrefix(symbol, "3"):
-		return "sz"
-	case strings.HasPrefix(symbol, "4") || strings.HasPrefix(symbol, "8"):
-		return "bj"
-	default:
-		return "sh" // 默认使用上海市场前缀
+// tencentCode 将任意写法的代码转换为腾讯格式（如 600000.SH -> sh600000），无法识别时按上海市场处理
+func tencentCode(symbol string) string {
+	if parsed, err := core.ParseSymbol(symbol); err == nil {
+		return parsed.TencentFormat()
 	}
+	return "sh" + symbol
 }
@@ -2,11 +2,23 @@ package tencent
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+	"stocksub/pkg/provider"
 )
 
 func TestProvider_FetchStockData(t *testing.T) {
@@ -151,6 +163,11 @@ func TestProvider_IsSymbolSupported(t *testing.T) {
 		{"300503", true, "创业板"},
 		{"688041", true, "科创板"},
 		{"835174", true, "北交所"},
+		{"301001", true, "创业板 301"},
+		{"600000.SH", true, "交易所后缀"},
+		{"sz000001", true, "腾讯前缀"},
+		{"sh000001", false, "指数"},
+		{"00700", false, "港股"},
 		{"", false, "空字符串"},
 		{"12345", false, "5位数字"},
 		{"1234567", false, "7位数字"},
@@ -282,6 +299,11 @@ func TestProvider_BuildURL(t *testing.T) {
 			symbols:  []string{"835174"},
 			expected: "http://qt.gtimg.cn/q=bj835174",
 		},
+		{
+			name:     "交易所后缀",
+			symbols:  []string{"600000.SH", "000001.SZ"},
+			expected: "http://qt.gtimg.cn/q=sh600000,sz000001",
+		},
 	}
 
 	for _, tt := range tests {
@@ -293,31 +315,31 @@ func TestProvider_BuildURL(t *testing.T) {
 	}
 }
 
-func TestProvider_GetMarketPrefix(t *testing.T) {
-	provider := NewClient()
-	defer provider.Close()
-
+func TestTencentCode(t *testing.T) {
 	tests := []struct {
 		symbol   string
 		expected string
 	}{
-		{"600000", "sh"}, // 上海主板
-		{"601398", "sh"}, // 上海主板
-		{"500001", "sh"}, // 以5开头的股票
-		{"000001", "sz"}, // 深圳主板
-		{"002594", "sz"}, // 深圳中小板
-		{"300750", "sz"}, // 创业板
-		{"688041", "sh"}, // 科创板 (6开头)
-		{"835174", "bj"}, // 北交所
-		{"400001", "bj"}, // 以4开头的股票
-		{"12345", "sh"},  // 其他情况，默认上海
-		{"", "sh"},       // 空代码，默认上海
+		{"600000", "sh600000"},    // 上海主板
+		{"601398", "sh601398"},    // 上海主板
+		{"500001", "sh500001"},    // 上海基金
+		{"000001", "sz000001"},    // 深圳主板
+		{"002594", "sz002594"},    // 深圳中小板
+		{"300750", "sz300750"},    // 创业板
+		{"159915", "sz159915"},    // 深圳基金
+		{"688041", "sh688041"},    // 科创板 (6开头)
+		{"835174", "bj835174"},    // 北交所
+		{"400001", "bj400001"},    // 以4开头的股票
+		{"920002", "bj920002"},    // 北交所新号段
+		{"600000.SH", "sh600000"}, // 交易所后缀
+		{"sz000001", "sz000001"},  // 已带前缀
+		{"000001.SH", "sh000001"}, // 上证指数
+		{"700000", "sh700000"},    // 无法识别，默认上海
 	}
 
 	for _, tt := range tests {
 		t.Run("Symbol_"+tt.symbol, func(t *testing.T) {
-			result := provider.getMarketPrefix(tt.symbol)
-			assert.Equal(t, tt.expected, result)
+			assert.Equal(t, tt.expected, tencentCode(tt.symbol))
 		})
 	}
 }
@@ -368,3 +390,256 @@ func TestProvider_MethodChaining(t *testing.T) {
 		assert.Equal(t, result1, result2, "同一个符号多次调用应该返回相同结果")
 	}
 }
+
+// tencentQuoteLine 生成一条字段齐全的腾讯行情，prefixed 为带市场前缀的代码
+func tencentQuoteLine(prefixed string) string {
+	fields := make([]string, MinRequiredFields)
+	for i := range fields {
+		fields[i] = "0"
+	}
+	fields[FieldName] = "Stock " + prefixed
+	fields[FieldSymbol] = prefixed[2:]
+	fields[FieldPrice] = "10.00"
+	fields[FieldTimestamp] = "20250820155202"
+	return fmt.Sprintf("v_%s=\"%s\";\n", prefixed, strings.Join(fields, "~"))
+}
+
+// newChunkTestServer 按请求的代码返回行情，failSymbol 所在的请求返回 500，requests 记录每次请求的代码
+func newChunkTestServer(t *testing.T, failSymbol string) (*httptest.Server, func() [][]string) {
+	var mu sync.Mutex
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbols := strings.Split(strings.TrimPrefix(r.URL.Path, "/q="), ",")
+		mu.Lock()
+		requests = append(requests, symbols)
+		mu.Unlock()
+
+		for _, symbol := range symbols {
+			if symbol == failSymbol {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		for _, symbol := range symbols {
+			_, _ = io.WriteString(w, tencentQuoteLine(symbol))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() [][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]string(nil), requests...)
+	}
+}
+
+func newChunkTestClient(server *httptest.Server, chunkSize int) *Client {
+	client := NewClient()
+	client.SetBaseURL(server.URL + "/q=")
+	client.SetRateLimit(0)
+	client.SetChunkSize(chunkSize)
+	return client
+}
+
+func TestProvider_FetchStockData_SplitsIntoChunks(t *testing.T) {
+	server, requests := newChunkTestServer(t, "")
+	client := newChunkTestClient(server, 3)
+	defer client.Close()
+
+	symbols := []string{"600000", "000001", "300503", "688041", "835174", "601398", "000858"}
+	data, raw, err := client.FetchStockDataWithRaw(context.Background(), symbols)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"sh600000", "sz000001", "sz300503"},
+		{"sh688041", "bj835174", "sh601398"},
+		{"sz000858"},
+	}, requests())
+
+	require.Len(t, data, len(symbols))
+	for i, stock := range data {
+		assert.Equal(t, symbols[i], stock.Symbol, "结果保持输入顺序")
+	}
+	assert.Equal(t, 2, strings.Count(raw, "\n"+provider.RawChunkSeparator), "分片原始响应以分隔符拼接")
+	assert.Len(t, parseTencentData(raw), len(symbols), "拼接后的原始响应仍可解析")
+}
+
+func TestProvider_FetchStockData_PartialChunkFailure(t *testing.T) {
+	server, requests := newChunkTestServer(t, "sh688041")
+	client := newChunkTestClient(server, 2)
+	client.SetChunkConcurrency(3)
+	defer client.Close()
+
+	symbols := []string{"600000", "000001", "688041", "300503", "835174", "601398"}
+	data, err := client.FetchStockData(context.Background(), symbols)
+	require.Error(t, err)
+	assert.Len(t, requests(), 3)
+
+	var multiErr *provider.MultiError
+	require.ErrorAs(t, err, &multiErr)
+	require.Len(t, multiErr.Failed, 1)
+	assert.Equal(t, 1, multiErr.Failed[0].Index)
+	assert.Equal(t, []string{"688041", "300503"}, multiErr.FailedSymbols())
+	var statusErr *core.HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+
+	got := make([]string, len(data))
+	for i, stock := range data {
+		got[i] = stock.Symbol
+	}
+	assert.Equal(t, []string{"600000", "000001", "835174", "601398"}, got)
+}
+
+func TestProvider_SharedHTTPClientReusesConnections(t *testing.T) {
+	server, _ := newChunkTestServer(t, "")
+	httpClient, err := core.NewHTTPClient(core.DefaultHTTPClientConfig())
+	require.NoError(t, err)
+
+	client := NewClient(WithHTTPClient(httpClient))
+	client.SetBaseURL(server.URL + "/q=")
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				_, err := client.FetchStockData(context.Background(), []string{"600000"})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats, ok := client.GetStatus()["http_conns"].(core.HTTPConnStats)
+	require.True(t, ok)
+	assert.Equal(t, int64(20), stats.Requests)
+	assert.LessOrEqual(t, stats.NewConns, int64(4))
+	assert.GreaterOrEqual(t, stats.ReusedConns, int64(16))
+}
+
+func TestProvider_SetTimeout_AppliesPerRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(WithTimeout(time.Minute))
+	client.SetBaseURL(server.URL + "/q=")
+	client.SetTimeout(20 * time.Millisecond)
+	defer client.Close()
+
+	start := time.Now()
+	_, err := client.FetchStockData(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, apperrors.Is(err, apperrors.CodeNetworkTimeout))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, "20ms", client.GetStatus()["timeout"])
+}
+
+func TestProvider_FetchStockDataWithRaw_DecodesGB18030(t *testing.T) {
+	line := strings.Replace(tencentQuoteLine("sh600000"), "Stock sh600000", "𠮷野家", 1)
+	body, err := simplifiedchinese.GB18030.NewEncoder().String(line)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=GBK")
+		_, _ = io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetBaseURL(server.URL + "/q=")
+	defer client.Close()
+
+	data, raw, err := client.FetchStockDataWithRaw(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, line, raw, "原始响应以 UTF-8 返回")
+	require.Len(t, data, 1)
+	assert.Equal(t, "𠮷野家", data[0].Name)
+}
+
+func TestProvider_StrictDecoding_ReturnsEncodingFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		line := tencentQuoteLine("sh600000")
+		// 响应在双字节字符中间被截断
+		_, _ = io.WriteString(w, line[:len(line)-3]+"\xc6")
+	}))
+	defer server.Close()
+
+	lax := NewClient()
+	lax.SetBaseURL(server.URL + "/q=")
+	defer lax.Close()
+	_, raw, err := lax.FetchStockDataWithRaw(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(raw, "\uFFFD"))
+
+	strict := NewClient(WithStrictDecoding(true))
+	strict.SetBaseURL(server.URL + "/q=")
+	defer strict.Close()
+	_, _, err = strict.FetchStockDataWithRaw(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, provider.ErrEncodingFailure)
+	assert.True(t, apperrors.Is(err, apperrors.CodeParseFailure))
+}
+
+func TestProvider_ErrorCodes(t *testing.T) {
+	status := http.StatusTooManyRequests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetBaseURL(server.URL + "/q=")
+	defer client.Close()
+
+	_, err := client.FetchStockData(context.Background(), []string{"600000"})
+	assert.True(t, apperrors.Is(err, apperrors.CodeUpstreamThrottled))
+	var statusErr *core.HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+
+	status = http.StatusBadGateway
+	_, err = client.FetchStockData(context.Background(), []string{"600000"})
+	require.ErrorAs(t, err, &statusErr)
+	assert.Empty(t, apperrors.Code(err), "其他状态码不附加代码")
+
+	status = http.StatusOK
+	_, err = client.FetchStockData(context.Background(), []string{"600000"})
+	assert.True(t, apperrors.Is(err, apperrors.CodeParseFailure), "空响应")
+}
+
+func TestProvider_FetchIndexData(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = strings.TrimPrefix(r.URL.RawQuery, "q=")
+		_, _ = io.WriteString(w, tencentIndexResponse)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.SetBaseURL(server.URL + "/?q=")
+	defer client.Close()
+
+	data, err := client.FetchIndexData(context.Background(), []string{"000001.SH", "sz399001"})
+	require.NoError(t, err)
+	assert.Equal(t, "sh000001,sz399001", query)
+	require.Len(t, data, 2)
+	assert.Equal(t, "sh000001", data[0].Symbol)
+	assert.Equal(t, 3200.50, data[0].Value)
+	assert.Equal(t, int64(456789012), data[0].Volume)
+	assert.Equal(t, 612345678901.0, data[0].Turnover)
+
+	// 不带市场的 000001 是平安银行，不是上证指数
+	_, err = client.FetchIndexData(context.Background(), []string{"000001"})
+	assert.Error(t, err)
+	assert.False(t, client.IsIndexSupported("600000"))
+	assert.True(t, client.IsIndexSupported("sh000300"))
+
+	var _ provider.RealtimeIndexProvider = client
+}
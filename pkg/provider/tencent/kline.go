@@ -0,0 +1,286 @@
+package tencent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+	"stocksub/pkg/logger"
+	"stocksub/pkg/provider"
+)
+
+// defaultKlineBaseURL 腾讯复权K线接口
+const defaultKlineBaseURL = "http://web.ifzq.gtimg.cn/appstock/app/fqkline/get"
+
+// klineMaxCount 单次请求最多返回的K线条数
+const klineMaxCount = 640
+
+// klinePeriods 支持的周期与腾讯接口周期参数的对应关系
+var klinePeriods = map[string]string{
+	"1d": "day",
+	"1w": "week",
+	"1M": "month",
+}
+
+// 复权方式，对应腾讯接口 param 的最后一项
+const (
+	AdjustForward  = "qfq" // 前复权
+	AdjustBackward = "hfq" // 后复权
+	AdjustNone     = ""    // 不复权
+)
+
+// KlineClient 腾讯历史K线数据提供商
+// 与 Client 分开实现，便于装饰器链按 HistoricalProvider 类型进行装饰
+type KlineClient struct {
+	httpClient *http.Client
+	baseURL    string
+	userAgent  string
+	adjust     string
+	rateLimit  time.Duration // 分页请求之间的间隔
+	log        *logger.Entry
+}
+
+// KlineOption 历史K线数据提供商的创建选项
+type KlineOption func(*KlineClient)
+
+// WithAdjust 设置复权方式，默认前复权
+func WithAdjust(adjust string) KlineOption {
+	return func(p *KlineClient) {
+		p.adjust = adjust
+	}
+}
+
+// NewKlineClient 创建腾讯历史K线数据提供商
+func NewKlineClient(opts ...KlineOption) *KlineClient {
+	p := &KlineClient{
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		baseURL:    defaultKlineBaseURL,
+		userAgent:  "StockSub/1.0",
+		adjust:     AdjustForward,
+		rateLimit:  time.Second,
+		log:        logger.WithComponent("TencentKlineProvider"),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// SetBaseURL 设置接口地址，主要用于测试
+func (p *KlineClient) SetBaseURL(baseURL string) {
+	p.baseURL = baseURL
+}
+
+// Name 返回提供商名称
+func (p *KlineClient) Name() string {
+	return "tencent"
+}
+
+// UpstreamHost 返回接口的主机名，用于按主机共享限流
+func (p *KlineClient) UpstreamHost() string {
+	return provider.URLHost(p.baseURL)
+}
+
+// GetRateLimit 获取请求频率限制
+func (p *KlineClient) GetRateLimit() time.Duration {
+	return p.rateLimit
+}
+
+// SetRateLimit 设置分页请求之间的间隔
+func (p *KlineClient) SetRateLimit(limit time.Duration) {
+	p.rateLimit = limit
+}
+
+// IsHealthy 检查提供商健康状态
+func (p *KlineClient) IsHealthy() bool {
+	return p.httpClient != nil
+}
+
+// GetSupportedPeriods 获取支持的时间周期列表
+func (p *KlineClient) GetSupportedPeriods() []string {
+	return []string{"1d", "1w", "1M"}
+}
+
+// Close 关闭提供商，清理资源
+func (p *KlineClient) Close() error {
+	if p.httpClient != nil {
+		p.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// klineResponse fqkline 接口响应，data 以带市场前缀的代码为键
+type klineResponse struct {
+	Code int                                   `json:"code"`
+	Msg  string                                `json:"msg"`
+	Data map[string]map[string]json.RawMessage `json:"data"`
+}
+
+// FetchHistoricalData 获取K线数据 (实现 provider.HistoricalProvider 接口)
+//
+// 接口每次最多返回 end 之前的 klineMaxCount 条，范围更长时以最早一条的前一天为新的 end 向前翻页，
+// 结果按日期升序排列并截取到 [start, end] 内。
+func (p *KlineClient) FetchHistoricalData(ctx context.Context, symbol string, start, end time.Time, period string) ([]core.HistoricalData, error) {
+	tencentPeriod, ok := klinePeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("unsupported period: %s", period)
+	}
+
+	code := tencentCode(symbol)
+	startDay, endDay := klineDay(start), klineDay(end)
+
+	var result []core.HistoricalData
+	pageEnd := endDay
+	for page := 0; ; page++ {
+		if page > 0 && p.rateLimit > 0 {
+			timer := time.NewTimer(p.rateLimit)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		bars, err := p.fetchPage(ctx, symbol, code, tencentPeriod, period, startDay, pageEnd)
+		if err != nil {
+			return nil, err
+		}
+		result = append(sliceKlineRange(bars, startDay, pageEnd), result...)
+
+		// 不足一页或已覆盖 start 时结束；最早一条没有向前推进时也结束，避免接口忽略日期参数时死循环
+		if len(bars) < klineMaxCount || !bars[0].Timestamp.After(startDay) || !bars[0].Timestamp.Before(pageEnd) {
+			break
+		}
+		pageEnd = bars[0].Timestamp.AddDate(0, 0, -1)
+	}
+	if result == nil {
+		result = []core.HistoricalData{}
+	}
+	return result, nil
+}
+
+// fetchPage 请求 [start, end] 内最近的 klineMaxCount 条K线
+func (p *KlineClient) fetchPage(ctx context.Context, symbol, code, tencentPeriod, period string, start, end time.Time) ([]core.HistoricalData, error) {
+	url := fmt.Sprintf("%s?param=%s,%s,%s,%s,%d,%s", p.baseURL, code, tencentPeriod,
+		start.Format("2006-01-02"), end.Format("2006-01-02"), klineMaxCount, p.adjust)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, core.WrapRequestError(fmt.Errorf("HTTP request failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, core.NewHTTPStatusError(resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response failed: %w", err)
+	}
+
+	p.log.Debugf("Kline response for %s %s: %d bytes", code, period, len(body))
+	return parseKlineResponse(body, symbol, code, p.adjust, tencentPeriod, period)
+}
+
+// parseKlineResponse 解析K线响应，每行格式为 [日期, 开盘, 收盘, 最高, 最低, 成交量(手), ...]，结果按日期升序
+func parseKlineResponse(body []byte, symbol, code, adjust, tencentPeriod, period string) ([]core.HistoricalData, error) {
+	var resp klineResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, apperrors.Wrap(apperrors.CodeParseFailure, fmt.Errorf("parse kline response failed: %w", err))
+	}
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("kline API error: code=%d, msg=%s", resp.Code, resp.Msg)
+	}
+
+	series, ok := resp.Data[code]
+	if !ok {
+		return []core.HistoricalData{}, nil
+	}
+
+	// 复权数据放在 qfqday、hfqweek 等键下，部分品种（如指数）只有不复权的 day 键
+	raw, ok := series[adjust+tencentPeriod]
+	if !ok {
+		raw, ok = series[tencentPeriod]
+	}
+	if !ok {
+		return []core.HistoricalData{}, nil
+	}
+
+	var rows [][]interface{}
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		return nil, apperrors.Wrap(apperrors.CodeParseFailure, fmt.Errorf("parse kline rows failed: %w", err))
+	}
+
+	result := make([]core.HistoricalData, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		date, _ := row[0].(string)
+		ts, err := time.ParseInLocation("2006-01-02", date, chinaLocation)
+		if err != nil {
+			continue
+		}
+		result = append(result, core.HistoricalData{
+			Symbol:    symbol,
+			Timestamp: ts,
+			Open:      klineFloat(row[1]),
+			Close:     klineFloat(row[2]),
+			High:      klineFloat(row[3]),
+			Low:       klineFloat(row[4]),
+			Volume:    int64(klineFloat(row[5])),
+			Period:    period,
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Timestamp.Before(result[j].Timestamp) })
+	return result, nil
+}
+
+// sliceKlineRange 截取日期在 [start, end] 内的K线
+func sliceKlineRange(bars []core.HistoricalData, start, end time.Time) []core.HistoricalData {
+	result := make([]core.HistoricalData, 0, len(bars))
+	for _, bar := range bars {
+		if bar.Timestamp.Before(start) || bar.Timestamp.After(end) {
+			continue
+		}
+		result = append(result, bar)
+	}
+	return result
+}
+
+// klineDay 返回 t 在北京时间的日期零点
+func klineDay(t time.Time) time.Time {
+	y, m, d := t.In(chinaLocation).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, chinaLocation)
+}
+
+// chinaLocation K线日期按北京时间解释
+var chinaLocation = time.FixedZone("CST", 8*3600)
+
+// klineFloat 将字符串形式的数值转换为 float64，无法解析时返回 0
+func klineFloat(v interface{}) float64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
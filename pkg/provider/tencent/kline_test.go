@@ -0,0 +1,210 @@
+package tencent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const klineFixture = `{"code":0,"msg":"","data":{"sh600000":{"qfqday":[` +
+	`["2025-08-18","10.10","10.30","10.40","10.00","123456.000"],` +
+	`["2025-08-19","10.30","10.20","10.50","10.10","98765.000"]]}}}`
+
+func TestKlineClient_FetchHistoricalData(t *testing.T) {
+	var gotParam string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotParam = r.URL.Query().Get("param")
+		_, _ = w.Write([]byte(klineFixture))
+	}))
+	defer server.Close()
+
+	client := NewKlineClient()
+	client.SetBaseURL(server.URL)
+	defer client.Close()
+
+	start := time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 8, 19, 0, 0, 0, 0, time.UTC)
+	data, err := client.FetchHistoricalData(context.Background(), "600000", start, end, "1d")
+	require.NoError(t, err)
+
+	assert.Equal(t, "sh600000,day,2025-08-18,2025-08-19,640,qfq", gotParam)
+	require.Len(t, data, 2)
+	assert.Equal(t, "600000", data[0].Symbol)
+	assert.Equal(t, "1d", data[0].Period)
+	assert.Equal(t, 10.10, data[0].Open)
+	assert.Equal(t, 10.30, data[0].Close)
+	assert.Equal(t, 10.40, data[0].High)
+	assert.Equal(t, 10.00, data[0].Low)
+	assert.Equal(t, int64(123456), data[0].Volume)
+	assert.Equal(t, "2025-08-18T00:00:00+08:00", data[0].Timestamp.Format(time.RFC3339))
+
+	_, err = client.FetchHistoricalData(context.Background(), "600000.SH", start, end, "1d")
+	require.NoError(t, err)
+	assert.Equal(t, "sh600000,day,2025-08-18,2025-08-19,640,qfq", gotParam, "交易所后缀转换为腾讯代码")
+}
+
+func TestKlineClient_UnsupportedPeriod(t *testing.T) {
+	client := NewKlineClient()
+	_, err := client.FetchHistoricalData(context.Background(), "600000", time.Now(), time.Now(), "5m")
+	assert.Error(t, err)
+}
+
+func TestParseKlineResponse_FallsBackToUnadjustedSeries(t *testing.T) {
+	body := []byte(`{"code":0,"data":{"sh000001":{"week":[["2025-08-15","3200.1","3250.2","3260.0","3190.5","1000"]]}}}`)
+	data, err := parseKlineResponse(body, "000001", "sh000001", AdjustForward, "week", "1w")
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, 3250.2, data[0].Close)
+
+	data, err = parseKlineResponse([]byte(`{"code":0,"data":{}}`), "600000", "sh600000", AdjustForward, "day", "1d")
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	_, err = parseKlineResponse([]byte(`{"code":-1,"msg":"param error"}`), "600000", "sh600000", AdjustForward, "day", "1d")
+	assert.Error(t, err)
+}
+
+func TestParseKlineResponse_Fixtures(t *testing.T) {
+	tests := []struct {
+		fixture, symbol, code, adjust, tencentPeriod, period string
+		count                                                int
+		first, last                                          string
+		lastClose                                            float64
+	}{
+		{"kline_sh600000_qfqday.json", "600000", "sh600000", AdjustForward, "day", "1d", 5, "2025-07-14", "2025-07-18", 12.90},
+		{"kline_sh600000_hfqweek.json", "600000", "sh600000", AdjustBackward, "week", "1w", 2, "2025-07-11", "2025-07-18", 89.112},
+		{"kline_sz000001_month.json", "000001", "sz000001", AdjustNone, "month", "1M", 2, "2025-05-30", "2025-06-30", 12.73},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			require.NoError(t, err)
+
+			data, err := parseKlineResponse(body, tt.symbol, tt.code, tt.adjust, tt.tencentPeriod, tt.period)
+			require.NoError(t, err)
+			require.Len(t, data, tt.count)
+			assert.Equal(t, tt.first, data[0].Timestamp.Format("2006-01-02"))
+			assert.Equal(t, tt.last, data[len(data)-1].Timestamp.Format("2006-01-02"))
+			assert.Equal(t, tt.lastClose, data[len(data)-1].Close)
+			assert.Equal(t, tt.period, data[0].Period)
+		})
+	}
+
+	// 除权日的行带有分红信息对象，只取前 6 列
+	body, err := os.ReadFile(filepath.Join("testdata", "kline_sh600000_qfqday.json"))
+	require.NoError(t, err)
+	data, err := parseKlineResponse(body, "600000", "sh600000", AdjustForward, "day", "1d")
+	require.NoError(t, err)
+	assert.Equal(t, 12.43, data[1].Close)
+	assert.Equal(t, int64(701288), data[1].Volume)
+}
+
+func TestKlineClient_FetchHistoricalData_AdjustAndRange(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "kline_sh600000_hfqweek.json"))
+	require.NoError(t, err)
+	var gotParam string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotParam = r.URL.Query().Get("param")
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := NewKlineClient(WithAdjust(AdjustBackward))
+	client.SetBaseURL(server.URL)
+	defer client.Close()
+
+	// 接口返回的范围比请求的宽时按 start/end 截取
+	start := time.Date(2025, 7, 14, 0, 0, 0, 0, chinaLocation)
+	end := time.Date(2025, 7, 20, 0, 0, 0, 0, chinaLocation)
+	data, err := client.FetchHistoricalData(context.Background(), "600000", start, end, "1w")
+	require.NoError(t, err)
+	assert.Equal(t, "sh600000,week,2025-07-14,2025-07-20,640,hfq", gotParam)
+	require.Len(t, data, 1)
+	assert.Equal(t, "2025-07-18", data[0].Timestamp.Format("2006-01-02"))
+	assert.Equal(t, 89.112, data[0].Close)
+
+	client = NewKlineClient(WithAdjust(AdjustNone))
+	client.SetBaseURL(server.URL)
+	_, _ = client.FetchHistoricalData(context.Background(), "000001", start, end, "1M")
+	assert.Equal(t, "sz000001,month,2025-07-14,2025-07-20,640,", gotParam)
+}
+
+// klineRangeServer 模拟腾讯接口：返回 [start, end] 内每个工作日一条K线中最近的 count 条
+func klineRangeServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var params []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param := r.URL.Query().Get("param")
+		mu.Lock()
+		params = append(params, param)
+		mu.Unlock()
+
+		parts := strings.Split(param, ",")
+		start, _ := time.Parse("2006-01-02", parts[2])
+		end, _ := time.Parse("2006-01-02", parts[3])
+		var count int
+		_, _ = fmt.Sscanf(parts[4], "%d", &count)
+
+		var rows []string
+		for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+				continue
+			}
+			rows = append(rows, fmt.Sprintf(`["%s","10.00","10.10","10.20","9.90","1000.000"]`, day.Format("2006-01-02")))
+		}
+		if len(rows) > count {
+			rows = rows[len(rows)-count:]
+		}
+		_, _ = fmt.Fprintf(w, `{"code":0,"msg":"","data":{"%s":{"%s%s":[%s]}}}`, parts[0], parts[5], parts[1], strings.Join(rows, ","))
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), params...)
+	}
+}
+
+func TestKlineClient_FetchHistoricalData_PaginatesLongRanges(t *testing.T) {
+	server, params := klineRangeServer(t)
+	client := NewKlineClient()
+	client.SetBaseURL(server.URL)
+	client.SetRateLimit(0)
+	defer client.Close()
+
+	start := time.Date(2022, 1, 3, 0, 0, 0, 0, chinaLocation) // 周一
+	end := time.Date(2025, 6, 30, 0, 0, 0, 0, chinaLocation)
+	data, err := client.FetchHistoricalData(context.Background(), "600000", start, end, "1d")
+	require.NoError(t, err)
+
+	weekdays := 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			weekdays++
+		}
+	}
+	require.Len(t, data, weekdays)
+	assert.Equal(t, "2022-01-03", data[0].Timestamp.Format("2006-01-02"))
+	assert.Equal(t, "2025-06-30", data[len(data)-1].Timestamp.Format("2006-01-02"))
+	for i := 1; i < len(data); i++ {
+		require.True(t, data[i].Timestamp.After(data[i-1].Timestamp), "按日期升序且不重复: %s", data[i].Timestamp)
+	}
+
+	// 每页以上一页最早一条的前一天为 end
+	pages := params()
+	require.Len(t, pages, 2)
+	assert.Equal(t, "sh600000,day,2022-01-03,2025-06-30,640,qfq", pages[0])
+	firstPageStart := data[len(data)-klineMaxCount].Timestamp.AddDate(0, 0, -1).Format("2006-01-02")
+	assert.Equal(t, "sh600000,day,2022-01-03,"+firstPageStart+",640,qfq", pages[1])
+}
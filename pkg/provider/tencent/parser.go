@@ -2,15 +2,11 @@ package tencent
 
 import (
 	"fmt"
-	"io"
 	"strconv"
 	"strings"
 	"time"
 
 	"stocksub/pkg/core"
-
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
 )
 
 // 定义腾讯数据字段索引常量
@@ -55,7 +51,7 @@ const (
 	FieldTurnoverRepeat             // 37 - 成交额(重复)
 	FieldTurnoverRate               // 38 - 换手率
 	FieldPE                         // 39 - 市盈率
-	FieldUnknown40                  // 40 - 空字段
+	FieldTradingStatus              // 40 - 交易状态代码(正常交易时为空)
 	FieldHighRepeat                 // 41 - 最高价(重复)
 	FieldLowRepeat                  // 42 - 最低价(重复)
 	FieldAmplitude                  // 43 - 振幅
@@ -66,22 +62,19 @@ const (
 	FieldLimitDown                  // 48 - 跌停价
 )
 
+// 扩展字段索引，新上市或数据不全的股票可能缺少这些字段
+const (
+	FieldWeek52High = 67 // 67 - 52周最高价
+	FieldWeek52Low  = 68 // 68 - 52周最低价
+)
+
 // 最少需要的字段数量
 const MinRequiredFields = 49
 
-// gbkToUtf8 将GBK编码转换为UTF-8
-func gbkToUtf8(gbkStr string) string {
-	if gbkStr == "" {
-		return ""
-	}
-
-	reader := transform.NewReader(strings.NewReader(gbkStr), simplifiedchinese.GBK.NewDecoder())
-	data, err := io.ReadAll(reader)
-	if err != nil {
-		return gbkStr
-	}
-
-	return string(data)
+// tradingStatusCodes 交易状态代码到 core 交易状态的映射，空代码和未知代码视为正常交易
+var tradingStatusCodes = map[string]string{
+	"S": core.StatusSuspended, // 停牌
+	"D": core.StatusDelisted,  // 退市
 }
 
 // parseTencentData 解析腾讯返回的数据
@@ -116,7 +109,7 @@ func parseTencentData(data string) []core.StockData {
 		stockData := core.StockData{
 			// 基本信息
 			Symbol:        extractSymbol(fields[FieldSymbol]),
-			Name:          gbkToUtf8(fields[FieldName]),
+			Name:          fields[FieldName],
 			Price:         parseFloatWithDefault(fields[FieldPrice]),
 			Change:        parseFloatWithDefault(fields[FieldChange]),
 			ChangePercent: parseFloatWithDefault(fields[FieldChangePercent]),
@@ -166,6 +159,11 @@ func parseTencentData(data string) []core.StockData {
 			LimitUp:      parseFloatWithDefault(fields[FieldLimitUp]),      // 涨停价
 			LimitDown:    parseFloatWithDefault(fields[FieldLimitDown]),    // 跌停价
 
+			// 扩展信息
+			TradingStatus: parseTradingStatus(fields[FieldTradingStatus]),
+			Week52High:    parseFloatWithDefault(fieldAt(fields, FieldWeek52High)),
+			Week52Low:     parseFloatWithDefault(fieldAt(fields, FieldWeek52Low)),
+
 			// 时间信息
 			Timestamp: parseTime(fields[FieldTimestamp]),
 		}
@@ -193,6 +191,43 @@ func parseTencentData(data string) []core.StockData {
 	return results
 }
 
+// MinIndexFields 指数行情最少需要的字段数量，指数没有涨跌停价等股票字段
+const MinIndexFields = FieldPriceVolumeTurnover + 1
+
+// parseTencentIndexData 解析腾讯返回的指数行情，格式与股票相同：
+// v_sh000001="1~上证指数~000001~3200.50~3190.12~...~价格/成交量(手)/成交额(元)~...";
+// 字段中的代码不带市场（000001 同时是平安银行），代码取自变量名
+func parseTencentIndexData(data string) []core.IndexData {
+	lines := strings.Split(strings.TrimSpace(data), ";")
+	results := make([]core.IndexData, 0, len(lines))
+
+	for _, line := range lines {
+		varPart, dataPart, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		symbol := strings.TrimPrefix(strings.TrimSpace(varPart), "v_")
+		fields := strings.Split(strings.Trim(dataPart, `"`), "~")
+		// 不存在的代码返回 v_pv_none_match="1"; 这样的短响应
+		if symbol == "" || len(fields) < MinIndexFields || fields[FieldName] == "" {
+			continue
+		}
+
+		results = append(results, core.IndexData{
+			Symbol:        symbol,
+			Name:          fields[FieldName],
+			Value:         parseFloatWithDefault(fields[FieldPrice]),
+			Change:        parseFloatWithDefault(fields[FieldChange]),
+			ChangePercent: parseFloatWithDefault(fields[FieldChangePercent]),
+			Volume:        parseIntWithDefault(fields[FieldVolume]),
+			Turnover:      parseTurnover(fields[FieldPriceVolumeTurnover]),
+		})
+	}
+
+	return results
+}
+
 // extractSymbol 从股票代码中提取纯符号
 func extractSymbol(rawSymbol string) string {
 	rawSymbol = strings.TrimPrefix(rawSymbol, "sh")
@@ -206,6 +241,22 @@ func extractSymbol(rawSymbol string) string {
 	return rawSymbol
 }
 
+// fieldAt 返回指定索引的字段，字段不存在时返回空字符串
+func fieldAt(fields []string, index int) string {
+	if index >= len(fields) {
+		return ""
+	}
+	return fields[index]
+}
+
+// parseTradingStatus 将交易状态代码转换为 core 交易状态常量
+func parseTradingStatus(code string) string {
+	if status, ok := tradingStatusCodes[strings.TrimSpace(code)]; ok {
+		return status
+	}
+	return core.StatusTrading
+}
+
 // parseFloat 安全解析浮点数，返回错误信息
 func parseFloat(s string) (float64, error) {
 	if s == "" {
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"stocksub/pkg/core"
 )
 
 func TestParseTencentData(t *testing.T) {
@@ -63,6 +65,76 @@ v_sz000858="51~Wuliangye~000858~125.80~124.41~124.42~399865~208277~191588~125.78
 	})
 }
 
+// tencentIndexResponse 上证指数和深证成指的行情，格式与股票相同，没有盘口数据
+const tencentIndexResponse = `v_sh000001="1~SSE Composite~000001~3200.50~3190.12~3191.00~456789012~0~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~~20250820150002~10.38~0.33~3205.88~3188.21~3200.50/456789012/612345678901~456789012~61234568~0.86~~~3205.88~3188.21~0.55~~~0.00~-1~-1";
+v_sz399001="51~SZSE Component~399001~10050.12~10100.00~10098.00~523456789~0~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~~20250820150003~-49.88~-0.49~10110.00~10020.33~10050.12/523456789/723456789012~523456789~72345679~1.12~~~10110.00~10020.33~0.89~~~0.00~-1~-1";`
+
+func TestParseTencentIndexData(t *testing.T) {
+	data := parseTencentIndexData(tencentIndexResponse)
+	if assert.Len(t, data, 2) {
+		assert.Equal(t, core.IndexData{
+			Symbol: "sh000001", Name: "SSE Composite", Value: 3200.50, Change: 10.38, ChangePercent: 0.33,
+			Volume: 456789012, Turnover: 612345678901,
+		}, data[0])
+		assert.Equal(t, "sz399001", data[1].Symbol, "代码取自变量名，保留市场前缀")
+		assert.Equal(t, -0.49, data[1].ChangePercent)
+	}
+
+	// 不存在的代码和字段不足的行被忽略
+	assert.Empty(t, parseTencentIndexData(`v_pv_none_match="1";`))
+	assert.Empty(t, parseTencentIndexData(`v_sh000001="1~SSE Composite~000001~3200.50";`))
+	assert.Empty(t, parseTencentIndexData(""))
+}
+
+func TestParseTencentData_ExtendedFields(t *testing.T) {
+	t.Run("完整字段", func(t *testing.T) {
+		// 截取自 docs/data-api/tengxun/sh600000.md，名称改为英文
+		rawData := `v_sh600000="1~PUFA Bank~600000~13.72~13.69~13.69~603222~288503~314720~13.71~1306~13.70~6592~13.69~5682~13.68~1132~13.67~1144~13.72~2111~13.73~261~13.74~1200~13.75~585~13.76~1137~~20250820155202~0.03~0.22~13.87~13.60~13.72/603222/829302744~603222~82930~0.20~8.65~~13.87~13.60~1.97~4152.73~4152.73~0.61~15.06~12.32~0.80~10562~13.75~6.98~9.18~~~0.74~82930.2744~0.0000~0~~GP-A~38.87~-0.80~2.99~6.08~0.48~14.39~7.78~-0.51~2.93~16.47~30267679579~30267679579~49.94~53.81~30267679579~~~61.41~-0.07~~CNY~0~___D__F__N~13.65~2791";`
+
+		data := parseTencentData(rawData)
+		assert.Len(t, data, 1)
+		assert.Equal(t, core.StatusTrading, data[0].TradingStatus)
+		assert.Equal(t, 14.39, data[0].Week52High)
+		assert.Equal(t, 7.78, data[0].Week52Low)
+	})
+
+	t.Run("停牌股票", func(t *testing.T) {
+		// 停牌期间无成交，价格保持昨收，状态代码为 S
+		rawData := `v_sz000001="51~Suspended Co~000001~11.20~11.20~0.00~0~0~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~0.00~0~~20250820150003~0.00~0.00~0.00~0.00~11.20/0/0~0~0~0.00~8.65~S~13.87~13.60~0.00~4152.73~4152.73~0.61~15.06~12.32~0.80~10562~13.75~6.98~9.18~~~0.74~82930.2744~0.0000~0~~GP-A~38.87~-0.80~2.99~6.08~0.48~12.88~9.05~-0.51~2.93~16.47~30267679579~30267679579~49.94~53.81~30267679579~~~61.41~-0.07~~CNY~0~___D__F__N~13.65~2791";`
+
+		data := parseTencentData(rawData)
+		assert.Len(t, data, 1)
+		stock := data[0]
+		assert.Equal(t, "000001", stock.Symbol)
+		assert.Equal(t, core.StatusSuspended, stock.TradingStatus)
+		assert.Equal(t, 11.20, stock.Price)
+		assert.Equal(t, int64(0), stock.Volume)
+		assert.Equal(t, 12.88, stock.Week52High)
+		assert.Equal(t, 9.05, stock.Week52Low)
+	})
+
+	t.Run("新上市股票缺少扩展字段", func(t *testing.T) {
+		// 上市首日只返回 53 个字段，没有52周最高最低价
+		rawData := `v_sh603999="1~New Listing~603999~26.35~18.30~21.96~603222~288503~314720~13.71~1306~13.70~6592~13.69~5682~13.68~1132~13.67~1144~13.72~2111~13.73~261~13.74~1200~13.75~585~13.76~1137~~20250820155203~0.03~0.22~13.87~13.60~13.72/603222/829302744~603222~82930~0.20~8.65~~13.87~13.60~1.97~4152.73~4152.73~0.61~15.06~12.32~0.80~10562~13.75~6.98";`
+
+		data := parseTencentData(rawData)
+		assert.Len(t, data, 1)
+		stock := data[0]
+		assert.Equal(t, "603999", stock.Symbol)
+		assert.Equal(t, 26.35, stock.Price)
+		assert.Equal(t, core.StatusTrading, stock.TradingStatus)
+		assert.Zero(t, stock.Week52High)
+		assert.Zero(t, stock.Week52Low)
+	})
+}
+
+func TestParseTradingStatus(t *testing.T) {
+	assert.Equal(t, core.StatusTrading, parseTradingStatus(""))
+	assert.Equal(t, core.StatusSuspended, parseTradingStatus("S"))
+	assert.Equal(t, core.StatusDelisted, parseTradingStatus("D"))
+	assert.Equal(t, core.StatusTrading, parseTradingStatus("X"), "未知代码视为正常交易")
+}
+
 func TestParseFloat(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -280,26 +352,6 @@ func TestExtractSymbol(t *testing.T) {
 	}
 }
 
-func TestGbkToUtf8(t *testing.T) {
-	tests := []struct {
-		input string
-		desc  string
-	}{
-		{"测试", "中文字符"},
-		{"Test", "英文字符"},
-		{"", "空字符串"},
-		{"测试Test123", "混合字符"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.desc, func(t *testing.T) {
-			result := gbkToUtf8(tt.input)
-			// 由于GBK转换可能涉及编码问题，这里只验证函数不会panic
-			assert.NotNil(t, result)
-		})
-	}
-}
-
 // 测试字段常量
 func TestFieldConstants(t *testing.T) {
 	// 验证字段常量的值是否正确
@@ -309,6 +361,9 @@ func TestFieldConstants(t *testing.T) {
 	assert.Equal(t, 3, FieldPrice)
 	assert.Equal(t, 30, FieldTimestamp)
 	assert.Equal(t, 48, FieldLimitDown)
+	assert.Equal(t, 40, FieldTradingStatus)
+	assert.Equal(t, 67, FieldWeek52High)
+	assert.Equal(t, 68, FieldWeek52Low)
 
 	// 验证最小字段数
 	assert.Equal(t, 49, MinRequiredFields)
@@ -0,0 +1,35 @@
+package provider
+
+import "net/url"
+
+// UpstreamHostProvider 可选接口，返回提供商请求的上游主机名，用于在多个装饰器之间按主机共享限流
+type UpstreamHostProvider interface {
+	UpstreamHost() string
+}
+
+// UpstreamHost 返回提供商的上游主机名，依次穿过装饰器查找实现了 UpstreamHostProvider 的提供商，
+// 都未实现时返回空字符串
+func UpstreamHost(p Provider) string {
+	for p != nil {
+		if hp, ok := p.(UpstreamHostProvider); ok {
+			if host := hp.UpstreamHost(); host != "" {
+				return host
+			}
+		}
+		decorator, ok := p.(Decorator)
+		if !ok {
+			return ""
+		}
+		p = decorator.GetBaseProvider()
+	}
+	return ""
+}
+
+// URLHost 返回 URL 中的主机名（不含端口），无法解析时返回空字符串
+func URLHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -0,0 +1,255 @@
+// Package refdata 提供指数成分股等参考数据，从本地文件加载并支持热更新
+package refdata
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"stocksub/pkg/core"
+)
+
+// ErrInvalidConstituents 成分股文件内容无效
+var ErrInvalidConstituents = errors.New("invalid constituents")
+
+// Constituent 指数成分股，同时用于 CSV/yaml 文件和 API 响应
+type Constituent struct {
+	Index  string  `json:"index" yaml:"index"`
+	Symbol string  `json:"symbol" yaml:"symbol"`
+	Name   string  `json:"name,omitempty" yaml:"name"`
+	Weight float64 `json:"weight" yaml:"weight"` // 权重，百分比，例如 1.85 表示 1.85%
+	Sector string  `json:"sector" yaml:"sector"` // 所属行业，为空时归入 UnknownSector
+}
+
+// UnknownSector 未填写行业的成分股所属的分组
+const UnknownSector = "未分类"
+
+// constituentsFile yaml 文件格式
+type constituentsFile struct {
+	Constituents []Constituent `yaml:"constituents"`
+}
+
+// LoadConstituents 按扩展名读取 CSV（表头含 symbol、index、weight、sector，name 可选）或 yaml 文件，
+// 代码和指数代码转换为规范形式，同一指数内代码不能重复
+func LoadConstituents(path string) ([]Constituent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var constituents []Constituent
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		constituents, err = decodeCSV(file)
+	case ".yaml", ".yml":
+		var doc constituentsFile
+		if err = yaml.NewDecoder(file).Decode(&doc); err == nil || errors.Is(err, io.EOF) {
+			constituents, err = doc.Constituents, nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported file type %q, expected .csv, .yaml or .yml", ErrInvalidConstituents, filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constituents %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool, len(constituents))
+	for i := range constituents {
+		c := &constituents[i]
+		c.Index = core.NormalizeSymbol(c.Index)
+		c.Symbol = core.NormalizeSymbol(c.Symbol)
+		c.Sector = strings.TrimSpace(c.Sector)
+		if c.Sector == "" {
+			c.Sector = UnknownSector
+		}
+		switch {
+		case c.Index == "" || c.Symbol == "":
+			return nil, fmt.Errorf("%w: entry %d: index and symbol are required", ErrInvalidConstituents, i+1)
+		case c.Weight < 0:
+			return nil, fmt.Errorf("%w: entry %d: weight must not be negative", ErrInvalidConstituents, i+1)
+		case seen[c.Index+"/"+c.Symbol]:
+			return nil, fmt.Errorf("%w: entry %d: duplicate symbol %s in index %s", ErrInvalidConstituents, i+1, c.Symbol, c.Index)
+		}
+		seen[c.Index+"/"+c.Symbol] = true
+	}
+	return constituents, nil
+}
+
+// decodeCSV 按表头列名读取成分股
+func decodeCSV(r io.Reader) ([]Constituent, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, header := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))] = i
+	}
+	for _, required := range []string{"symbol", "index", "weight", "sector"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidConstituents, required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	constituents := make([]Constituent, 0, len(records)-1)
+	for line, record := range records[1:] {
+		weight, err := strconv.ParseFloat(field(record, "weight"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: invalid weight %q", ErrInvalidConstituents, line+2, field(record, "weight"))
+		}
+		constituents = append(constituents, Constituent{
+			Index:  field(record, "index"),
+			Symbol: field(record, "symbol"),
+			Name:   field(record, "name"),
+			Weight: weight,
+			Sector: field(record, "sector"),
+		})
+	}
+	return constituents, nil
+}
+
+// ConstituentStore 从文件加载的成分股，Reload 成功后整体替换，失败时保留上次加载的数据
+type ConstituentStore struct {
+	path string
+
+	mu       sync.RWMutex
+	byIndex  map[string][]Constituent
+	loadedAt time.Time
+	modTime  time.Time // 最近一次成功加载时文件的修改时间
+	badMod   time.Time // 最近一次加载失败时文件的修改时间，文件再次修改前不重复报告
+}
+
+// NewConstituentStore 创建并立即加载成分股文件
+func NewConstituentStore(path string) (*ConstituentStore, error) {
+	s := &ConstituentStore{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload 重新读取成分股文件
+func (s *ConstituentStore) Reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	constituents, err := LoadConstituents(s.path)
+	if err != nil {
+		return err
+	}
+
+	byIndex := make(map[string][]Constituent)
+	for _, c := range constituents {
+		byIndex[c.Index] = append(byIndex[c.Index], c)
+	}
+	for _, members := range byIndex {
+		sort.SliceStable(members, func(i, j int) bool { return members[i].Weight > members[j].Weight })
+	}
+
+	s.mu.Lock()
+	s.byIndex = byIndex
+	s.loadedAt = time.Now()
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// Constituents 返回指数的成分股，按权重从高到低排列；指数代码可以是任意可识别的形式
+func (s *ConstituentStore) Constituents(index string) ([]Constituent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	members, ok := s.byIndex[core.NormalizeSymbol(index)]
+	return append([]Constituent(nil), members...), ok
+}
+
+// Indices 返回已加载的指数代码
+func (s *ConstituentStore) Indices() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	indices := make([]string, 0, len(s.byIndex))
+	for index := range s.byIndex {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices
+}
+
+// Count 返回已加载的成分股总数
+func (s *ConstituentStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for _, members := range s.byIndex {
+		count += len(members)
+	}
+	return count
+}
+
+// LoadedAt 返回最近一次成功加载的时间
+func (s *ConstituentStore) LoadedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loadedAt
+}
+
+// Watch 每隔 interval 检查文件修改时间，变化时重新加载，直到 ctx 取消；加载失败时调用 onError 并保留旧数据
+func (s *ConstituentStore) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reloadIfChanged(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// reloadIfChanged 文件修改时间变化时重新加载
+func (s *ConstituentStore) reloadIfChanged() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	unchanged := info.ModTime().Equal(s.modTime) || info.ModTime().Equal(s.badMod)
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	if err := s.Reload(); err != nil {
+		s.mu.Lock()
+		s.badMod = info.ModTime()
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
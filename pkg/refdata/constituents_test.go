@@ -0,0 +1,108 @@
+package refdata
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConstituents_CSV(t *testing.T) {
+	constituents, err := LoadConstituents("testdata/constituents.csv")
+	require.NoError(t, err)
+	require.Len(t, constituents, 6)
+	assert.Equal(t, Constituent{Index: "000300.SH", Symbol: "600519.SH", Name: "贵州茅台", Weight: 6, Sector: "食品饮料"}, constituents[0])
+	assert.Equal(t, "399006.SZ", constituents[5].Index, "指数代码转换为规范形式")
+}
+
+func TestLoadConstituents_YAML(t *testing.T) {
+	constituents, err := LoadConstituents("testdata/constituents.yaml")
+	require.NoError(t, err)
+	require.Len(t, constituents, 2)
+	assert.Equal(t, "600519.SH", constituents[0].Symbol)
+	assert.Equal(t, "601318.SH", constituents[1].Symbol)
+	assert.Equal(t, UnknownSector, constituents[1].Sector, "未填写行业时归入未分类")
+}
+
+func TestLoadConstituents_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"missing_column.csv": "index,symbol,weight\nsh000300,600519,1\n",
+		"bad_weight.csv":     "index,symbol,weight,sector\nsh000300,600519,abc,银行\n",
+		"negative.csv":       "index,symbol,weight,sector\nsh000300,600519,-1,银行\n",
+		"duplicate.csv":      "index,symbol,weight,sector\nsh000300,600519,1,银行\nsh000300,sh600519,2,银行\n",
+		"no_symbol.yaml":     "constituents:\n  - index: sh000300\n    weight: 1\n",
+		"unknown.json":       "[]",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			_, err := LoadConstituents(path)
+			assert.ErrorIs(t, err, ErrInvalidConstituents)
+		})
+	}
+}
+
+func TestConstituentStore_LookupAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "constituents.csv")
+	content, err := os.ReadFile("testdata/constituents.csv")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	store, err := NewConstituentStore(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"000300.SH", "399006.SZ"}, store.Indices())
+	assert.Equal(t, 6, store.Count())
+
+	members, ok := store.Constituents("sh000300")
+	require.True(t, ok, "任意形式的指数代码都可以查询")
+	require.Len(t, members, 5)
+	assert.Equal(t, "600519.SH", members[0].Symbol, "按权重从高到低排列")
+	_, ok = store.Constituents("sh000905")
+	assert.False(t, ok)
+
+	// 加载失败时保留旧数据
+	require.NoError(t, os.WriteFile(path, []byte("index,symbol\n"), 0o644))
+	assert.ErrorIs(t, store.Reload(), ErrInvalidConstituents)
+	assert.Equal(t, 6, store.Count())
+
+	require.NoError(t, os.WriteFile(path, []byte("index,symbol,weight,sector\nsh000300,600519,100,食品饮料\n"), 0o644))
+	require.NoError(t, store.Reload())
+	assert.Equal(t, []string{"000300.SH"}, store.Indices())
+}
+
+func TestConstituentStore_WatchReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "constituents.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("constituents:\n  - {index: sh000016, symbol: '600519', weight: 10, sector: 食品饮料}\n"), 0o644))
+	store, err := NewConstituentStore(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go store.Watch(ctx, 10*time.Millisecond, func(err error) { errs <- err })
+
+	// 写入无效内容只报告一次错误，旧数据保留
+	bad := time.Now().Add(time.Second)
+	require.NoError(t, os.WriteFile(path, []byte("constituents: [{index: sh000016}]\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, bad, bad))
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrInvalidConstituents)
+	case <-time.After(time.Second):
+		t.Fatal("无效文件应报告错误")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, errs, "文件未再修改时不重复报告")
+	assert.Equal(t, 1, store.Count())
+
+	good := bad.Add(time.Second)
+	require.NoError(t, os.WriteFile(path, []byte("constituents:\n  - {index: sh000016, symbol: '600519', weight: 10}\n  - {index: sh000016, symbol: '601318', weight: 5}\n"), 0o644))
+	require.NoError(t, os.Chtimes(path, good, good))
+	assert.Eventually(t, func() bool { return store.Count() == 2 }, time.Second, 10*time.Millisecond)
+}
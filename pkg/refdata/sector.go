@@ -0,0 +1,70 @@
+package refdata
+
+import "sort"
+
+// SectorAggregate 指数某个行业的成分股统计，涨跌幅和贡献只统计有实时数据的成分股
+type SectorAggregate struct {
+	Sector                string   `json:"sector"`
+	Count                 int      `json:"count"`                   // 成分股数量
+	WithData              int      `json:"with_data"`               // 有实时数据的成分股数量
+	Weight                float64  `json:"weight"`                  // 成分股权重之和，百分比
+	AverageChangePercent  float64  `json:"average_change_percent"`  // 涨跌幅的算术平均
+	WeightedChangePercent float64  `json:"weighted_change_percent"` // 按权重加权的平均涨跌幅
+	Contribution          float64  `json:"contribution"`            // 对指数涨跌幅的贡献（百分点），Σ 权重/100 × 涨跌幅
+	Missing               []string `json:"missing,omitempty"`       // 缺少实时数据的成分股
+}
+
+// SectorSummary 指数的行业分布
+type SectorSummary struct {
+	Sectors      []SectorAggregate `json:"sectors"`      // 按权重从高到低排列
+	Count        int               `json:"count"`        // 成分股数量
+	WithData     int               `json:"with_data"`    // 有实时数据的成分股数量
+	Contribution float64           `json:"contribution"` // 各行业贡献之和，即有数据部分对指数涨跌幅的估计
+}
+
+// AggregateSectors 按行业汇总成分股，changePercent 为成分股代码到最新涨跌幅的映射，不在其中的成分股视为缺少实时数据
+func AggregateSectors(constituents []Constituent, changePercent map[string]float64) SectorSummary {
+	bySector := make(map[string]*SectorAggregate)
+	var order []string
+	dataWeight := make(map[string]float64)
+	summary := SectorSummary{Count: len(constituents)}
+
+	for _, c := range constituents {
+		agg, ok := bySector[c.Sector]
+		if !ok {
+			agg = &SectorAggregate{Sector: c.Sector}
+			bySector[c.Sector] = agg
+			order = append(order, c.Sector)
+		}
+		agg.Count++
+		agg.Weight += c.Weight
+
+		change, ok := changePercent[c.Symbol]
+		if !ok {
+			agg.Missing = append(agg.Missing, c.Symbol)
+			continue
+		}
+		agg.WithData++
+		agg.AverageChangePercent += change
+		agg.Contribution += c.Weight / 100 * change
+		dataWeight[c.Sector] += c.Weight
+	}
+
+	summary.Sectors = make([]SectorAggregate, 0, len(order))
+	for _, sector := range order {
+		agg := bySector[sector]
+		if agg.WithData > 0 {
+			agg.AverageChangePercent /= float64(agg.WithData)
+		}
+		if w := dataWeight[sector]; w > 0 {
+			agg.WeightedChangePercent = agg.Contribution * 100 / w
+		}
+		summary.WithData += agg.WithData
+		summary.Contribution += agg.Contribution
+		summary.Sectors = append(summary.Sectors, *agg)
+	}
+	sort.SliceStable(summary.Sectors, func(i, j int) bool {
+		return summary.Sectors[i].Weight > summary.Sectors[j].Weight
+	})
+	return summary
+}
@@ -0,0 +1,66 @@
+package refdata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateSectors_WeightedMath(t *testing.T) {
+	store, err := NewConstituentStore("testdata/constituents.csv")
+	require.NoError(t, err)
+	members, ok := store.Constituents("sh000300")
+	require.True(t, ok)
+
+	summary := AggregateSectors(members, map[string]float64{
+		"600519.SH": 2.0,  // 6% × 2%  = 0.12
+		"000858.SZ": -1.0, // 2% × -1% = -0.02
+		"600036.SH": 1.0,  // 3% × 1%  = 0.03
+		"601398.SH": -3.0, // 1% × -3% = -0.03
+		// 300750.SZ 缺少实时数据
+	})
+
+	assert.Equal(t, 5, summary.Count)
+	assert.Equal(t, 4, summary.WithData)
+	assert.InDelta(t, 0.10, summary.Contribution, 1e-9)
+	require.Len(t, summary.Sectors, 3)
+
+	food := summary.Sectors[0]
+	assert.Equal(t, "食品饮料", food.Sector, "按权重从高到低排列")
+	assert.Equal(t, 2, food.Count)
+	assert.InDelta(t, 8.0, food.Weight, 1e-9)
+	assert.InDelta(t, 0.5, food.AverageChangePercent, 1e-9)
+	assert.InDelta(t, 0.10, food.Contribution, 1e-9)
+	assert.InDelta(t, 1.25, food.WeightedChangePercent, 1e-9, "(6×2 + 2×-1) / 8")
+
+	bank := summary.Sectors[1]
+	assert.Equal(t, "银行", bank.Sector)
+	assert.InDelta(t, -1.0, bank.AverageChangePercent, 1e-9)
+	assert.InDelta(t, 0.0, bank.Contribution, 1e-9)
+	assert.InDelta(t, 0.0, bank.WeightedChangePercent, 1e-9)
+}
+
+func TestAggregateSectors_MissingRealtimeData(t *testing.T) {
+	members := []Constituent{
+		{Index: "000300.SH", Symbol: "300750.SZ", Weight: 3, Sector: "电力设备"},
+		{Index: "000300.SH", Symbol: "300274.SZ", Weight: 1, Sector: "电力设备"},
+		{Index: "000300.SH", Symbol: "600036.SH", Weight: 2, Sector: "银行"},
+	}
+	summary := AggregateSectors(members, map[string]float64{"300274.SZ": 4})
+
+	require.Len(t, summary.Sectors, 2)
+	power := summary.Sectors[0]
+	assert.Equal(t, 2, power.Count)
+	assert.Equal(t, 1, power.WithData)
+	assert.Equal(t, []string{"300750.SZ"}, power.Missing)
+	assert.InDelta(t, 4.0, power.AverageChangePercent, 1e-9, "缺少数据的成分股不参与平均")
+	assert.InDelta(t, 4.0, power.WeightedChangePercent, 1e-9, "加权平均只按有数据的权重计算")
+	assert.InDelta(t, 0.04, power.Contribution, 1e-9)
+
+	bank := summary.Sectors[1]
+	assert.Zero(t, bank.WithData)
+	assert.Zero(t, bank.AverageChangePercent, "没有数据时为 0")
+	assert.Equal(t, []string{"600036.SH"}, bank.Missing)
+	assert.Equal(t, 1, summary.WithData)
+}
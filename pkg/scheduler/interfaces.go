@@ -14,25 +14,93 @@ type JobConfig struct {
 	Schedule string                 `yaml:"schedule" json:"schedule"`
 	Provider ProviderConfig         `yaml:"provider" json:"provider"`
 	Params   map[string]interface{} `yaml:"params" json:"params"`
-	Output   *OutputConfig          `yaml:"output,omitempty" json:"output,omitempty"`
+	// Output 输出目标列表，每次获取的数据发布到所有目标；兼容只写一个对象的旧配置
+	Output Outputs `yaml:"output,omitempty" json:"output,omitempty"`
+
+	// OverlapPolicy 上一次执行未结束时的处理方式: skip、queue、allow，默认 skip
+	OverlapPolicy OverlapPolicy `yaml:"overlap_policy,omitempty" json:"overlap_policy,omitempty" mapstructure:"overlap_policy"`
+	// Timeout 单次执行的超时时间，超时后取消执行上下文，默认 5 分钟
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Singleton 多个调度节点对同一调度时刻只执行一次，需要调度器设置 JobLocker
+	Singleton bool `yaml:"singleton,omitempty" json:"singleton,omitempty"`
+
+	// TradingHoursOnly 仅在交易时段内执行，非交易时段的触发记为挂起
+	TradingHoursOnly bool `yaml:"trading_hours_only,omitempty" json:"trading_hours_only,omitempty" mapstructure:"trading_hours_only"`
+	// Market 交易时段所属市场，目前仅支持 A-share（默认）
+	Market string `yaml:"market,omitempty" json:"market,omitempty"`
+	// PreOpenGrace 开盘前提前执行的时间窗口
+	PreOpenGrace time.Duration `yaml:"pre_open_grace,omitempty" json:"pre_open_grace,omitempty" mapstructure:"pre_open_grace"`
+	// PostCloseGrace 收盘后继续执行的时间窗口
+	PostCloseGrace time.Duration `yaml:"post_close_grace,omitempty" json:"post_close_grace,omitempty" mapstructure:"post_close_grace"`
+
+	// Debug 调试选项，默认全部关闭
+	Debug DebugConfig `yaml:"debug,omitempty" json:"debug,omitempty"`
 }
 
+// MarketAShare A 股市场
+const MarketAShare = "A-share"
+
+// OverlapPolicy 任务重叠执行策略
+type OverlapPolicy string
+
+const (
+	// OverlapSkip 上一次执行未结束时跳过本次执行
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue 上一次执行结束后立即再执行一次，期间多次触发合并为一次
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapAllow 允许并发执行
+	OverlapAllow OverlapPolicy = "allow"
+)
+
+// DefaultJobTimeout 未配置 timeout 时的单次执行超时时间
+const DefaultJobTimeout = 5 * time.Minute
+
 // ProviderConfig 定义提供商配置
 type ProviderConfig struct {
-	Name string `yaml:"name" json:"name"`
-	Type string `yaml:"type" json:"type"`
+	Name      string   `yaml:"name" json:"name"`
+	Type      string   `yaml:"type" json:"type"`
+	Fallbacks []string `yaml:"fallbacks,omitempty" json:"fallbacks,omitempty"`                 // 备用提供商，主提供商失败时按顺序尝试（仅 RealtimeStock、eod_snapshot）
+	TopUp     bool     `yaml:"top_up,omitempty" json:"top_up,omitempty" mapstructure:"top_up"` // 主提供商缺失部分股票时，向备用提供商补齐
 }
 
+// 输出类型
+const (
+	OutputRedisStream = "redis_stream" // 发布到数据类型对应的 Redis Stream
+	OutputCSVFile     = "csv_file"     // 写入 directory 下的 CSV 文件
+	OutputStdoutJSON  = "stdout_json"  // 每条消息一行 JSON 输出到标准输出
+)
+
 // OutputConfig 定义输出配置
 type OutputConfig struct {
 	Type      string `yaml:"type" json:"type"`
+	Name      string `yaml:"name,omitempty" json:"name,omitempty"` // 统计和日志中使用的名称，默认与 type 相同
 	Directory string `yaml:"directory,omitempty" json:"directory,omitempty"`
 	Stream    string `yaml:"stream,omitempty" json:"stream,omitempty"`
+	Encoding  string `yaml:"encoding,omitempty" json:"encoding,omitempty"` // payload 压缩方式: none、gzip、zstd，默认 none
+}
+
+// SinkName 返回输出在统计和日志中的名称，未设置 type 时按 redis_stream 处理
+func (o OutputConfig) SinkName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	if o.Type == "" {
+		return OutputRedisStream
+	}
+	return o.Type
+}
+
+// StreamConfig 定义发布目标 Redis Stream 的维护参数
+type StreamConfig struct {
+	Name string `yaml:"name" json:"name"`
+	// MaxLen 发布时以 MAXLEN ~ N 近似裁剪，0 表示不限制
+	MaxLen int64 `yaml:"max_len,omitempty" json:"max_len,omitempty" mapstructure:"max_len"`
 }
 
 // JobsConfig 定义整个任务配置文件结构
 type JobsConfig struct {
-	Jobs []JobConfig `yaml:"jobs" json:"jobs"`
+	Jobs    []JobConfig    `yaml:"jobs" json:"jobs"`
+	Streams []StreamConfig `yaml:"streams,omitempty" json:"streams,omitempty"`
 }
 
 // Job 表示一个运行中的任务
@@ -46,6 +114,17 @@ type Job struct {
 	RunCount   int64
 	ErrorCount int64
 	LastError  error
+
+	SkipCount       int64         // 因重叠策略被跳过的执行次数
+	LastRunStart    *time.Time    // 最近一次执行的开始时间
+	LastRunDuration time.Duration // 最近一次完成的执行耗时
+	LockSkipCount   int64         // 因其他节点持有任务锁而跳过的执行次数（skipped_due_to_lock）
+	SuspendedCount  int64         // 因不在交易时段而挂起的执行次数
+	NextActiveTime  *time.Time    // trading_hours_only 任务下一次进入交易窗口的时间
+
+	running  int           // 正在进行的执行数
+	queued   bool          // queue 策略下是否有等待执行的一次
+	schedule cron.Schedule // 解析后的调度表达式，用于计算锁对应的调度时刻
 }
 
 // JobStatus 任务状态
@@ -57,6 +136,8 @@ const (
 	JobStatusStopped  JobStatus = "stopped"
 	JobStatusError    JobStatus = "error"
 	JobStatusDisabled JobStatus = "disabled"
+	// JobStatusSuspended 非交易时段挂起
+	JobStatusSuspended JobStatus = "suspended"
 )
 
 // JobExecutor 任务执行器接口
@@ -75,6 +156,9 @@ type JobScheduler interface {
 	// 停止调度器
 	Stop() error
 
+	// 调度器是否正在运行
+	IsRunning() bool
+
 	// 添加任务
 	AddJob(config JobConfig) error
 
@@ -92,4 +176,7 @@ type JobScheduler interface {
 
 	// 设置任务执行器
 	SetExecutor(executor JobExecutor)
+
+	// 设置分布式任务锁
+	SetLocker(locker JobLocker)
 }
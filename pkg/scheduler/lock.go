@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// lockTriggerDelay 允许的触发延迟，用于把实际触发时间对齐到所属的调度时刻
+	lockTriggerDelay = time.Second
+	// minLockTTL 锁的最短过期时间
+	minLockTTL = time.Second
+)
+
+// JobLocker 分布式任务锁，多个调度节点对同一调度时刻只有一个能获得锁
+type JobLocker interface {
+	// TryLock 尝试获取锁，已被其他节点持有时返回 false
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// RedisJobLocker 基于 Redis SET NX PX 的任务锁
+type RedisJobLocker struct {
+	client redis.Cmdable
+}
+
+// NewRedisJobLocker 创建基于 Redis 的任务锁
+func NewRedisJobLocker(client redis.Cmdable) *RedisJobLocker {
+	return &RedisJobLocker{client: client}
+}
+
+// TryLock 使用 SET NX PX 获取锁，锁不主动释放，到期自动删除
+func (l *RedisJobLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ok, err := l.client.SetNX(ctx, key, time.Now().UnixNano(), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("获取任务锁失败 %s: %w", key, err)
+	}
+	return ok, nil
+}
+
+// jobLockKey 返回任务在某个调度时刻的锁键
+func jobLockKey(jobName string, tick time.Time) string {
+	return fmt.Sprintf("lock:job:%s:%d", jobName, tick.Unix())
+}
+
+// scheduledTick 把实际触发时间对齐到所属的调度时刻，并返回锁的过期时间
+// 锁覆盖到下一个调度时刻，触发较晚的节点也无法在本时刻重复执行
+func scheduledTick(schedule cron.Schedule, now time.Time) (time.Time, time.Duration) {
+	tick := schedule.Next(now.Add(-lockTriggerDelay))
+	ttl := schedule.Next(tick).Sub(tick)
+	if ttl < minLockTTL {
+		ttl = minLockTTL
+	}
+	return tick, ttl
+}
@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingExecutor 统计执行次数的并发安全执行器
+type countingExecutor struct {
+	calls atomic.Int32
+}
+
+func (e *countingExecutor) Execute(ctx context.Context, job *Job) error {
+	e.calls.Add(1)
+	return nil
+}
+
+// errLocker 总是返回错误的任务锁
+type errLocker struct{}
+
+func (errLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return false, errors.New("redis unavailable")
+}
+
+func singletonJobConfig() JobConfig {
+	return JobConfig{
+		Name:      "singleton-job",
+		Enabled:   true,
+		Schedule:  "*/5 * * * * *",
+		Singleton: true,
+		Provider: ProviderConfig{
+			Name: "test-provider",
+			Type: "RealtimeStock",
+		},
+	}
+}
+
+// newLockedScheduler 创建连接到 miniredis 的调度节点
+func newLockedScheduler(t *testing.T, mr *miniredis.Miniredis, config JobConfig) (*DefaultJobScheduler, *countingExecutor) {
+	t.Helper()
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	s := NewJobScheduler()
+	executor := &countingExecutor{}
+	s.SetExecutor(executor)
+	s.SetLocker(NewRedisJobLocker(client))
+	require.NoError(t, s.AddJob(config))
+	return s, executor
+}
+
+func TestRedisJobLocker_TryLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	locker := NewRedisJobLocker(client)
+	ctx := context.Background()
+
+	ok, err := locker.TryLock(ctx, "lock:job:test:1", 5*time.Second)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, mr.TTL("lock:job:test:1"))
+
+	ok, err = locker.TryLock(ctx, "lock:job:test:1", 5*time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 到期后可以再次获取
+	mr.FastForward(5 * time.Second)
+	ok, err = locker.TryLock(ctx, "lock:job:test:1", 5*time.Second)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestScheduledTick(t *testing.T) {
+	schedule, err := scheduleParser.Parse("*/5 * * * * *")
+	require.NoError(t, err)
+
+	tick := time.Date(2025, 8, 20, 10, 0, 5, 0, time.Local)
+	for _, delay := range []time.Duration{0, 10 * time.Millisecond, 800 * time.Millisecond} {
+		got, ttl := scheduledTick(schedule, tick.Add(delay))
+		assert.Equal(t, tick, got, "delay %v", delay)
+		assert.Equal(t, 5*time.Second, ttl)
+	}
+}
+
+func TestJobScheduler_SingletonTwoNodesRace(t *testing.T) {
+	mr := miniredis.RunT(t)
+	nodeA, execA := newLockedScheduler(t, mr, singletonJobConfig())
+	nodeB, execB := newLockedScheduler(t, mr, singletonJobConfig())
+
+	tick := time.Date(2025, 8, 20, 10, 0, 5, 0, time.Local)
+
+	// 两个节点对同一调度时刻同时触发，触发时间略有差异
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		nodeA.runScheduled(nodeA.jobs["singleton-job"], tick.Add(3*time.Millisecond))
+	}()
+	go func() {
+		defer wg.Done()
+		nodeB.runScheduled(nodeB.jobs["singleton-job"], tick.Add(40*time.Millisecond))
+	}()
+	wg.Wait()
+
+	assert.Equal(t, int32(1), execA.calls.Load()+execB.calls.Load(), "同一调度时刻只应执行一次")
+
+	jobA, err := nodeA.GetJob("singleton-job")
+	require.NoError(t, err)
+	jobB, err := nodeB.GetJob("singleton-job")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), jobA.LockSkipCount+jobB.LockSkipCount)
+	assert.Equal(t, int64(1), jobA.RunCount+jobB.RunCount)
+
+	key := jobLockKey("singleton-job", tick)
+	assert.True(t, mr.Exists(key))
+	assert.Equal(t, 5*time.Second, mr.TTL(key))
+
+	// 下一个调度时刻重新竞争
+	next := tick.Add(5 * time.Second)
+	nodeA.runScheduled(nodeA.jobs["singleton-job"], next)
+	nodeB.runScheduled(nodeB.jobs["singleton-job"], next)
+	assert.Equal(t, int32(2), execA.calls.Load()+execB.calls.Load())
+}
+
+func TestJobScheduler_NonSingletonIgnoresLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := singletonJobConfig()
+	config.Singleton = false
+	nodeA, execA := newLockedScheduler(t, mr, config)
+	nodeB, execB := newLockedScheduler(t, mr, config)
+
+	tick := time.Date(2025, 8, 20, 10, 0, 5, 0, time.Local)
+	nodeA.runScheduled(nodeA.jobs["singleton-job"], tick)
+	nodeB.runScheduled(nodeB.jobs["singleton-job"], tick)
+
+	assert.Equal(t, int32(1), execA.calls.Load())
+	assert.Equal(t, int32(1), execB.calls.Load())
+	assert.Empty(t, mr.Keys())
+}
+
+func TestJobScheduler_SingletonLockErrorStillRuns(t *testing.T) {
+	s := NewJobScheduler()
+	executor := &countingExecutor{}
+	s.SetExecutor(executor)
+	s.SetLocker(errLocker{})
+	require.NoError(t, s.AddJob(singletonJobConfig()))
+
+	s.runScheduled(s.jobs["singleton-job"], time.Now())
+
+	assert.Equal(t, int32(1), executor.calls.Load())
+	job, err := s.GetJob("singleton-job")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), job.LockSkipCount)
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"stocksub/pkg/message"
+)
+
+// Outputs 任务的输出目标列表。配置中既可以写列表，也可以只写一个对象（旧格式）
+type Outputs []OutputConfig
+
+// UnmarshalYAML 接受单个对象或列表
+func (o *Outputs) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.MappingNode {
+		var single OutputConfig
+		if err := value.Decode(&single); err != nil {
+			return err
+		}
+		*o = Outputs{single}
+		return nil
+	}
+	var list []OutputConfig
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*o = list
+	return nil
+}
+
+// UnmarshalJSON 接受单个对象或数组
+func (o *Outputs) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var single OutputConfig
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return err
+		}
+		*o = Outputs{single}
+		return nil
+	}
+	var list []OutputConfig
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*o = list
+	return nil
+}
+
+// outputsDecodeHook 让 viper 解码 output 时把单个对象包装成列表
+func outputsDecodeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(Outputs{}) || from.Kind() != reflect.Map {
+		return data, nil
+	}
+	return []interface{}{data}, nil
+}
+
+// decodeHook viper 默认的解码钩子加上 outputsDecodeHook
+func decodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		outputsDecodeHook,
+	))
+}
+
+// validateOutputs 检查输出类型、必填字段和编码，名称不能重复
+func validateOutputs(outputs Outputs) error {
+	names := make(map[string]bool, len(outputs))
+	for i, output := range outputs {
+		switch output.Type {
+		case "", OutputRedisStream, OutputStdoutJSON:
+		case OutputCSVFile:
+			if output.Directory == "" {
+				return fmt.Errorf("第 %d 个输出 csv_file 缺少 directory", i+1)
+			}
+		default:
+			return fmt.Errorf("第 %d 个输出的类型不受支持: %q，可选 %s、%s、%s", i+1, output.Type, OutputRedisStream, OutputCSVFile, OutputStdoutJSON)
+		}
+		if err := message.ValidateEncoding(output.Encoding); err != nil {
+			return fmt.Errorf("输出 %s 的编码无效: %w", output.SinkName(), err)
+		}
+		if names[output.SinkName()] {
+			return fmt.Errorf("输出名称重复: %s，同类型的多个输出需要设置 name", output.SinkName())
+		}
+		names[output.SinkName()] = true
+	}
+	return nil
+}
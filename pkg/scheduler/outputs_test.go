@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestJobScheduler_LoadConfig_Outputs(t *testing.T) {
+	path := writeJobsConfig(t, `
+jobs:
+  - name: "legacy"
+    enabled: false
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    output:
+      type: "redis_stream"
+      encoding: "gzip"
+  - name: "archived"
+    enabled: false
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    output:
+      - type: "redis_stream"
+      - type: "csv_file"
+        directory: "./data/audit"
+      - type: "stdout_json"
+        name: "debug"
+`)
+
+	scheduler := NewJobScheduler()
+	require.NoError(t, scheduler.LoadConfig(path))
+
+	legacy, err := scheduler.GetJob("legacy")
+	require.NoError(t, err)
+	assert.Equal(t, Outputs{{Type: OutputRedisStream, Encoding: "gzip"}}, legacy.Config.Output, "单个对象按一个输出处理")
+
+	archived, err := scheduler.GetJob("archived")
+	require.NoError(t, err)
+	assert.Equal(t, Outputs{
+		{Type: OutputRedisStream},
+		{Type: OutputCSVFile, Directory: "./data/audit"},
+		{Type: OutputStdoutJSON, Name: "debug"},
+	}, archived.Config.Output)
+}
+
+func TestOutputs_Unmarshal(t *testing.T) {
+	var config JobConfig
+	require.NoError(t, yaml.Unmarshal([]byte("output:\n  type: csv_file\n  directory: out\n"), &config))
+	assert.Equal(t, Outputs{{Type: OutputCSVFile, Directory: "out"}}, config.Output)
+
+	require.NoError(t, yaml.Unmarshal([]byte("output:\n  - type: redis_stream\n  - type: stdout_json\n"), &config))
+	assert.Len(t, config.Output, 2)
+
+	var outputs Outputs
+	require.NoError(t, json.Unmarshal([]byte(`{"type": "stdout_json"}`), &outputs))
+	assert.Equal(t, Outputs{{Type: OutputStdoutJSON}}, outputs)
+	require.NoError(t, json.Unmarshal([]byte(`[{"type": "redis_stream"}, {"type": "csv_file", "directory": "out"}]`), &outputs))
+	assert.Len(t, outputs, 2)
+}
+
+func TestValidateOutputs(t *testing.T) {
+	assert.NoError(t, validateOutputs(nil))
+	assert.NoError(t, validateOutputs(Outputs{
+		{Type: OutputRedisStream, Encoding: "gzip"},
+		{Type: OutputCSVFile, Directory: "out"},
+		{Type: OutputCSVFile, Directory: "backup", Name: "backup"},
+	}))
+
+	tests := []struct {
+		name    string
+		outputs Outputs
+		errMsg  string
+	}{
+		{"未知类型", Outputs{{Type: "kafka"}}, "类型不受支持"},
+		{"csv 缺少目录", Outputs{{Type: OutputCSVFile}}, "缺少 directory"},
+		{"无效编码", Outputs{{Type: OutputRedisStream, Encoding: "lz4"}}, "编码无效"},
+		{"名称重复", Outputs{{Type: OutputStdoutJSON}, {Type: OutputStdoutJSON}}, "输出名称重复"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOutputs(tt.outputs)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
+
+func TestValidateConfig_OutputUnknownField(t *testing.T) {
+	problems := validationProblems(t, validJob+`    output:
+      - type: "csv_file"
+        directry: "./data"
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, 14, problems[0].Line)
+	assert.Contains(t, problems[0].Message, `output 中的未知字段 "directry"`)
+}
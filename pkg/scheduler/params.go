@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 历史数据任务参数默认值
+const (
+	DefaultHistoricalPeriod   = "1d"
+	DefaultHistoricalLookback = 30 * 24 * time.Hour
+)
+
+// HistoricalParams 历史数据任务的时间范围和周期参数
+type HistoricalParams struct {
+	Start  time.Time
+	End    time.Time
+	Period string
+}
+
+// SymbolsParam 返回任务代码列表的参数名：实时指数任务使用 index_symbols，未配置时兼容 symbols
+func SymbolsParam(config JobConfig) string {
+	if config.Provider.Type == "RealtimeIndex" {
+		if _, ok := config.Params["index_symbols"]; ok {
+			return "index_symbols"
+		}
+	}
+	return "symbols"
+}
+
+// ParseHistoricalParams 从任务参数中提取 start、end、period
+// start/end 支持日期 (2006-01-02)、RFC3339 时间或相对 now 的偏移 (如 -30d、-12h)；
+// end 缺省为 now，start 缺省为 end 之前 30 天，period 缺省为 1d。
+func ParseHistoricalParams(params map[string]interface{}, now time.Time) (HistoricalParams, error) {
+	result := HistoricalParams{End: now, Period: DefaultHistoricalPeriod}
+
+	if v, ok := params["period"]; ok {
+		period, ok := v.(string)
+		if !ok || period == "" {
+			return result, fmt.Errorf("period 参数必须是非空字符串")
+		}
+		result.Period = period
+	}
+
+	if v, ok := params["end"]; ok {
+		end, err := parseTimeParam(v, now)
+		if err != nil {
+			return result, fmt.Errorf("end 参数无效: %w", err)
+		}
+		result.End = end
+	}
+
+	result.Start = result.End.Add(-DefaultHistoricalLookback)
+	if v, ok := params["start"]; ok {
+		start, err := parseTimeParam(v, now)
+		if err != nil {
+			return result, fmt.Errorf("start 参数无效: %w", err)
+		}
+		result.Start = start
+	}
+
+	if result.Start.After(result.End) {
+		return result, fmt.Errorf("start 不能晚于 end")
+	}
+	return result, nil
+}
+
+// parseTimeParam 解析单个时间参数，YAML 中未加引号的日期会被解析为 time.Time
+func parseTimeParam(v interface{}, now time.Time) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" || s == "now" {
+			return now, nil
+		}
+		if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+			offset, err := parseOffset(s)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return now.Add(offset), nil
+		}
+		if ts, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+			return ts, nil
+		}
+		if ts, err := time.Parse(time.RFC3339, s); err == nil {
+			return ts, nil
+		}
+		return time.Time{}, fmt.Errorf("无法解析时间 '%s'", s)
+	default:
+		return time.Time{}, fmt.Errorf("不支持的时间类型 %T", v)
+	}
+}
+
+// parseOffset 解析相对偏移，在 time.ParseDuration 基础上支持天 (d)
+func parseOffset(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("无效的偏移 '%s'", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("无效的偏移 '%s'", s)
+	}
+	return d, nil
+}
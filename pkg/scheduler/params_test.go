@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHistoricalParams(t *testing.T) {
+	now := time.Date(2025, 8, 20, 16, 0, 0, 0, time.UTC)
+
+	t.Run("默认值", func(t *testing.T) {
+		p, err := ParseHistoricalParams(nil, now)
+		require.NoError(t, err)
+		assert.Equal(t, "1d", p.Period)
+		assert.Equal(t, now, p.End)
+		assert.Equal(t, now.Add(-30*24*time.Hour), p.Start)
+	})
+
+	t.Run("绝对日期", func(t *testing.T) {
+		p, err := ParseHistoricalParams(map[string]interface{}{
+			"start":  "2025-01-02",
+			"end":    "2025-06-30T15:00:00+08:00",
+			"period": "1w",
+		}, now)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), p.Start)
+		assert.Equal(t, "2025-06-30T15:00:00+08:00", p.End.Format(time.RFC3339))
+		assert.Equal(t, "1w", p.Period)
+	})
+
+	t.Run("相对偏移", func(t *testing.T) {
+		p, err := ParseHistoricalParams(map[string]interface{}{"start": "-7d", "end": "-12h"}, now)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(-7*24*time.Hour), p.Start)
+		assert.Equal(t, now.Add(-12*time.Hour), p.End)
+	})
+
+	t.Run("YAML 日期类型", func(t *testing.T) {
+		start := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+		p, err := ParseHistoricalParams(map[string]interface{}{"start": start}, now)
+		require.NoError(t, err)
+		assert.Equal(t, start, p.Start)
+	})
+
+	t.Run("无效参数", func(t *testing.T) {
+		for _, params := range []map[string]interface{}{
+			{"start": "yesterday"},
+			{"end": 20250101},
+			{"period": ""},
+			{"start": "2025-08-21"},
+		} {
+			_, err := ParseHistoricalParams(params, now)
+			assert.Error(t, err, "%v", params)
+		}
+	})
+}
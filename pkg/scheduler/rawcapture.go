@@ -0,0 +1,172 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/message"
+)
+
+const (
+	// RawCaptureKeyPrefix 原始响应采样列表的键前缀，完整键为 debug:raw:<提供商>，最新的在列表头部；
+	// 非默认命名空间下再加 <namespace>: 前缀
+	RawCaptureKeyPrefix = "debug:raw:"
+	// RawCaptureTTL 采样列表的保留时间，每次写入时续期，关闭采集后自动过期
+	RawCaptureTTL = 7 * 24 * time.Hour
+
+	// DefaultRawSampleRate 未配置 sample_rate 时每次执行被采样的概率
+	DefaultRawSampleRate = 0.01
+	// DefaultRawMaxEntries 未配置 max_entries 时每个提供商保留的条数
+	DefaultRawMaxEntries = 100
+	// MaxRawMaxEntries max_entries 的上限
+	MaxRawMaxEntries = 1000
+	// MaxRawCaptureBytes 单条原始响应保存的最大字节数，超出部分截断
+	MaxRawCaptureBytes = 256 << 10
+)
+
+// DebugConfig 任务的调试选项
+type DebugConfig struct {
+	// CaptureRaw 采样保存提供商的原始响应到 debug:raw:<提供商>，仅支持 RealtimeStock 任务
+	CaptureRaw bool `yaml:"capture_raw,omitempty" json:"capture_raw,omitempty" mapstructure:"capture_raw"`
+	// SampleRate 每次执行被采样的概率 (0, 1]，0 使用 DefaultRawSampleRate
+	SampleRate float64 `yaml:"sample_rate,omitempty" json:"sample_rate,omitempty" mapstructure:"sample_rate"`
+	// MaxEntries 每个提供商保留的条数，0 使用 DefaultRawMaxEntries，最多 MaxRawMaxEntries
+	MaxEntries int `yaml:"max_entries,omitempty" json:"max_entries,omitempty" mapstructure:"max_entries"`
+}
+
+func (c DebugConfig) validate(providerType string) error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample_rate 必须在 0 到 1 之间: %v", c.SampleRate)
+	}
+	if c.MaxEntries < 0 || c.MaxEntries > MaxRawMaxEntries {
+		return fmt.Errorf("max_entries 必须在 0 到 %d 之间: %d", MaxRawMaxEntries, c.MaxEntries)
+	}
+	if c.CaptureRaw && providerType != "RealtimeStock" {
+		return fmt.Errorf("capture_raw 仅支持 RealtimeStock 任务")
+	}
+	return nil
+}
+
+// ShouldCapture 按采样率决定本次执行是否采集原始响应，r 为 [0, 1) 的随机数
+func (c DebugConfig) ShouldCapture(r float64) bool {
+	if !c.CaptureRaw {
+		return false
+	}
+	rate := c.SampleRate
+	if rate <= 0 {
+		rate = DefaultRawSampleRate
+	}
+	return r < rate
+}
+
+// RawEntries 返回每个提供商保留的条数
+func (c DebugConfig) RawEntries() int {
+	switch {
+	case c.MaxEntries <= 0:
+		return DefaultRawMaxEntries
+	case c.MaxEntries > MaxRawMaxEntries:
+		return MaxRawMaxEntries
+	default:
+		return c.MaxEntries
+	}
+}
+
+// RawCapture 一条采样的原始响应
+type RawCapture struct {
+	Provider   string    `json:"provider"`
+	Job        string    `json:"job"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	Records    int       `json:"records"`  // 解析出的记录数
+	Checksum   string    `json:"checksum"` // 解析结果 JSON 的 SHA-256，用于比对同一响应的解析是否变化
+	CapturedAt time.Time `json:"captured_at"`
+	Truncated  bool      `json:"truncated,omitempty"` // 原始响应超过 MaxRawCaptureBytes 被截断
+	Raw        string    `json:"raw"`
+}
+
+// NewRawCapture 由原始响应和解析结果创建采样记录，原始响应过长时截断
+func NewRawCapture(provider, job, raw string, data []core.StockData, now time.Time) RawCapture {
+	capture := RawCapture{
+		Provider:   provider,
+		Job:        job,
+		Records:    len(data),
+		Checksum:   parseChecksum(data),
+		CapturedAt: now,
+		Raw:        raw,
+	}
+	if len(raw) > MaxRawCaptureBytes {
+		capture.Raw = strings.ToValidUTF8(raw[:MaxRawCaptureBytes], "")
+		capture.Truncated = true
+	}
+	return capture
+}
+
+func parseChecksum(data []core.StockData) string {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// RawCaptureKey 返回提供商的采样列表键
+func RawCaptureKey(provider string) string {
+	return RawCaptureKeyPrefix + provider
+}
+
+// RawCaptureRecorder 把采样的原始响应写入 Redis 列表
+type RawCaptureRecorder struct {
+	client    redis.Cmdable
+	namespace string
+}
+
+// NewRawCaptureRecorder 创建原始响应采样记录器，namespace 为空时使用默认命名空间
+func NewRawCaptureRecorder(client redis.Cmdable, namespace string) *RawCaptureRecorder {
+	return &RawCaptureRecorder{client: client, namespace: namespace}
+}
+
+// Record 以 LPUSH + LTRIM 写入采样，列表只保留最新的 maxEntries 条（按 DebugConfig.RawEntries 的规则取值）
+func (r *RawCaptureRecorder) Record(ctx context.Context, capture RawCapture, maxEntries int) error {
+	maxEntries = DebugConfig{MaxEntries: maxEntries}.RawEntries()
+	encoded, err := json.Marshal(capture)
+	if err != nil {
+		return fmt.Errorf("编码原始响应采样失败: %w", err)
+	}
+
+	key := message.NamespaceKey(r.namespace, RawCaptureKey(capture.Provider))
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, key, encoded)
+		pipe.LTrim(ctx, key, 0, int64(maxEntries-1))
+		pipe.Expire(ctx, key, RawCaptureTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("写入原始响应采样失败: %w", err)
+	}
+	return nil
+}
+
+// LoadRawCaptures 读取 namespace 下提供商最近的 limit 条采样，最新的在前，无法解析的条目跳过
+func LoadRawCaptures(ctx context.Context, client redis.Cmdable, namespace, provider string, limit int) ([]RawCapture, error) {
+	values, err := client.LRange(ctx, message.NamespaceKey(namespace, RawCaptureKey(provider)), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("读取原始响应采样失败: %w", err)
+	}
+	captures := make([]RawCapture, 0, len(values))
+	for _, value := range values {
+		var capture RawCapture
+		if err := json.Unmarshal([]byte(value), &capture); err != nil {
+			continue
+		}
+		captures = append(captures, capture)
+	}
+	return captures, nil
+}
@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func TestDebugConfig_ShouldCapture(t *testing.T) {
+	assert.False(t, DebugConfig{}.ShouldCapture(0), "默认关闭")
+	assert.False(t, DebugConfig{SampleRate: 1}.ShouldCapture(0), "只设置采样率不开启采集")
+
+	// 未配置采样率时使用 DefaultRawSampleRate
+	defaults := DebugConfig{CaptureRaw: true}
+	assert.True(t, defaults.ShouldCapture(DefaultRawSampleRate/2))
+	assert.False(t, defaults.ShouldCapture(DefaultRawSampleRate))
+
+	always := DebugConfig{CaptureRaw: true, SampleRate: 1}
+	assert.True(t, always.ShouldCapture(0.999))
+}
+
+func TestDebugConfig_SampleRateIsEnforced(t *testing.T) {
+	config := DebugConfig{CaptureRaw: true, SampleRate: 0.1}
+	const runs = 1000
+	captured := 0
+	for i := 0; i < runs; i++ {
+		// 均匀分布在 [0, 1) 的随机数
+		if config.ShouldCapture(float64(i) / runs) {
+			captured++
+		}
+	}
+	assert.Equal(t, 100, captured)
+}
+
+func TestDebugConfig_RawEntries(t *testing.T) {
+	assert.Equal(t, DefaultRawMaxEntries, DebugConfig{}.RawEntries())
+	assert.Equal(t, 5, DebugConfig{MaxEntries: 5}.RawEntries())
+	assert.Equal(t, MaxRawMaxEntries, DebugConfig{MaxEntries: MaxRawMaxEntries + 1}.RawEntries())
+}
+
+func TestCheckJobConfig_ValidatesDebug(t *testing.T) {
+	base := JobConfig{Name: "realtime", Schedule: "*/5 * * * * *", Provider: ProviderConfig{Name: "tencent", Type: "RealtimeStock"}}
+
+	valid := base
+	valid.Debug = DebugConfig{CaptureRaw: true, SampleRate: 0.05, MaxEntries: 20}
+	assert.NoError(t, checkJobConfig(valid))
+
+	for name, debug := range map[string]DebugConfig{
+		"negative sample_rate": {CaptureRaw: true, SampleRate: -0.1},
+		"sample_rate above 1":  {CaptureRaw: true, SampleRate: 1.5},
+		"max_entries too big":  {CaptureRaw: true, MaxEntries: MaxRawMaxEntries + 1},
+		"negative max_entries": {MaxEntries: -1},
+	} {
+		config := base
+		config.Debug = debug
+		assert.ErrorContains(t, checkJobConfig(config), "调试配置无效", name)
+	}
+
+	index := base
+	index.Provider = ProviderConfig{Name: "sina", Type: "RealtimeIndex"}
+	index.Debug = DebugConfig{CaptureRaw: true}
+	assert.ErrorContains(t, checkJobConfig(index), "capture_raw 仅支持 RealtimeStock")
+}
+
+func TestNewRawCapture(t *testing.T) {
+	now := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	data := []core.StockData{{Symbol: "600000", Price: 10.5}}
+
+	capture := NewRawCapture("tencent", "realtime", `v_sh600000="1~浦发银行~600000~10.50"`, data, now)
+	assert.Equal(t, 1, capture.Records)
+	assert.Len(t, capture.Checksum, 64)
+	assert.False(t, capture.Truncated)
+	assert.Equal(t, capture.Checksum, NewRawCapture("tencent", "realtime", "other", data, now).Checksum, "校验和只取决于解析结果")
+	assert.NotEqual(t, capture.Checksum, NewRawCapture("tencent", "realtime", "", []core.StockData{{Symbol: "600000", Price: 10.6}}, now).Checksum)
+
+	long := NewRawCapture("tencent", "realtime", strings.Repeat("浦", MaxRawCaptureBytes), data, now)
+	assert.True(t, long.Truncated)
+	assert.LessOrEqual(t, len(long.Raw), MaxRawCaptureBytes)
+	assert.True(t, strings.HasPrefix(long.Raw, "浦浦"))
+}
+
+func TestRawCaptureRecorder_TrimsToMaxEntries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	recorder := NewRawCaptureRecorder(client, "")
+	ctx := context.Background()
+
+	start := time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		capture := NewRawCapture("tencent", "realtime", fmt.Sprintf("raw-%d", i), nil, start.Add(time.Duration(i)*time.Second))
+		require.NoError(t, recorder.Record(ctx, capture, 5))
+	}
+
+	assert.Equal(t, int64(5), client.LLen(ctx, "debug:raw:tencent").Val())
+	assert.Equal(t, RawCaptureTTL, mr.TTL("debug:raw:tencent"))
+
+	captures, err := LoadRawCaptures(ctx, client, "", "tencent", 3)
+	require.NoError(t, err)
+	require.Len(t, captures, 3)
+	assert.Equal(t, []string{"raw-11", "raw-10", "raw-9"}, []string{captures[0].Raw, captures[1].Raw, captures[2].Raw}, "最新的在前")
+	assert.Equal(t, start.Add(11*time.Second), captures[0].CapturedAt)
+
+	// 未配置 maxEntries 时按默认值裁剪
+	for i := 0; i < DefaultRawMaxEntries+10; i++ {
+		require.NoError(t, recorder.Record(ctx, NewRawCapture("sina", "realtime", "raw", nil, start), 0))
+	}
+	assert.Equal(t, int64(DefaultRawMaxEntries), client.LLen(ctx, "debug:raw:sina").Val())
+
+	captures, err = LoadRawCaptures(ctx, client, "", "eastmoney", 10)
+	require.NoError(t, err)
+	assert.Empty(t, captures)
+}
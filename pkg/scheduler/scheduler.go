@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -11,6 +12,8 @@ import (
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+
+	"stocksub/pkg/timing"
 )
 
 // DefaultJobScheduler 默认任务调度器实现
@@ -18,12 +21,19 @@ type DefaultJobScheduler struct {
 	cron     *cron.Cron
 	jobs     map[string]*Job
 	executor JobExecutor
+	locker   JobLocker
+	streams  []StreamConfig
+	market   *timing.MarketTime
 	mu       sync.RWMutex
 	logger   *logrus.Logger
 	ctx      context.Context
 	cancel   context.CancelFunc
+	running  bool
 }
 
+// scheduleParser 秒级 cron 表达式解析器，与调度器使用的解析规则一致
+var scheduleParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 // NewJobScheduler 创建新的任务调度器
 func NewJobScheduler() *DefaultJobScheduler {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -31,6 +41,7 @@ func NewJobScheduler() *DefaultJobScheduler {
 	return &DefaultJobScheduler{
 		cron:   cron.New(cron.WithSeconds()),
 		jobs:   make(map[string]*Job),
+		market: timing.DefaultMarketTime(),
 		logger: logrus.New(),
 		ctx:    ctx,
 		cancel: cancel,
@@ -56,10 +67,12 @@ func (s *DefaultJobScheduler) LoadConfig(configPath string) error {
 	}
 
 	var config JobsConfig
-	if err := v.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config, decodeHook()); err != nil {
 		return fmt.Errorf("解析配置文件失败: %w", err)
 	}
 
+	s.streams = config.Streams
+
 	// 验证并添加任务
 	for _, jobConfig := range config.Jobs {
 		if err := s.validateJobConfig(jobConfig); err != nil {
@@ -77,6 +90,13 @@ func (s *DefaultJobScheduler) LoadConfig(configPath string) error {
 	return nil
 }
 
+// Streams 返回配置文件中的 Stream 维护参数
+func (s *DefaultJobScheduler) Streams() []StreamConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]StreamConfig(nil), s.streams...)
+}
+
 // Start 启动调度器
 func (s *DefaultJobScheduler) Start() error {
 	s.mu.Lock()
@@ -86,11 +106,21 @@ func (s *DefaultJobScheduler) Start() error {
 		return fmt.Errorf("任务执行器未设置")
 	}
 
+	if s.locker == nil {
+		for _, job := range s.jobs {
+			if job.Config.Singleton && job.Config.Enabled {
+				s.logger.Warnf("未设置任务锁，singleton 任务将在每个节点执行: %s", job.Config.Name)
+			}
+		}
+	}
+
 	s.cron.Start()
+	s.running = true
 	s.logger.Info("任务调度器已启动")
 
 	// 更新任务的下次运行时间
 	s.updateNextRunTimes()
+	s.updateNextActiveTimes(time.Now())
 
 	return nil
 }
@@ -101,6 +131,7 @@ func (s *DefaultJobScheduler) Stop() error {
 	defer s.mu.Unlock()
 
 	s.cancel()
+	s.running = false
 	ctx := s.cron.Stop()
 
 	// 等待所有任务完成
@@ -114,6 +145,13 @@ func (s *DefaultJobScheduler) Stop() error {
 	return nil
 }
 
+// IsRunning 返回调度器是否已启动且尚未停止
+func (s *DefaultJobScheduler) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running
+}
+
 // AddJob 添加任务
 func (s *DefaultJobScheduler) AddJob(config JobConfig) error {
 	s.mu.Lock()
@@ -198,8 +236,20 @@ func (s *DefaultJobScheduler) SetExecutor(executor JobExecutor) {
 	s.executor = executor
 }
 
+// SetLocker 设置分布式任务锁，singleton 任务在定时触发前需要先获取锁
+func (s *DefaultJobScheduler) SetLocker(locker JobLocker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locker = locker
+}
+
 // validateJobConfig 验证任务配置
 func (s *DefaultJobScheduler) validateJobConfig(config JobConfig) error {
+	return checkJobConfig(config)
+}
+
+// checkJobConfig 验证单个任务配置，返回第一个问题
+func checkJobConfig(config JobConfig) error {
 	if config.Name == "" {
 		return fmt.Errorf("任务名称不能为空")
 	}
@@ -209,8 +259,7 @@ func (s *DefaultJobScheduler) validateJobConfig(config JobConfig) error {
 	}
 
 	// 验证 cron 表达式 - 支持秒级调度
-	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-	if _, err := parser.Parse(config.Schedule); err != nil {
+	if _, err := scheduleParser.Parse(config.Schedule); err != nil {
 		return fmt.Errorf("无效的调度表达式 '%s': %w", config.Schedule, err)
 	}
 
@@ -222,6 +271,47 @@ func (s *DefaultJobScheduler) validateJobConfig(config JobConfig) error {
 		return fmt.Errorf("提供商类型不能为空")
 	}
 
+	switch config.OverlapPolicy {
+	case "", OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return fmt.Errorf("任务 '%s' 的重叠策略无效: %q（可选 skip、queue、allow）", config.Name, config.OverlapPolicy)
+	}
+
+	if config.Timeout < 0 {
+		return fmt.Errorf("任务 '%s' 的超时时间不能为负数", config.Name)
+	}
+
+	if config.Market != "" && config.Market != MarketAShare {
+		return fmt.Errorf("任务 '%s' 的市场不受支持: %s", config.Name, config.Market)
+	}
+
+	if config.PreOpenGrace < 0 || config.PostCloseGrace < 0 {
+		return fmt.Errorf("任务 '%s' 的交易时段缓冲不能为负数", config.Name)
+	}
+
+	if len(config.Provider.Fallbacks) > 0 && config.Provider.Type != "RealtimeStock" && config.Provider.Type != "eod_snapshot" {
+		return fmt.Errorf("任务 '%s' 的提供商类型 %s 不支持备用提供商", config.Name, config.Provider.Type)
+	}
+	for _, fallback := range config.Provider.Fallbacks {
+		if fallback == "" || fallback == config.Provider.Name {
+			return fmt.Errorf("任务 '%s' 的备用提供商无效: %q", config.Name, fallback)
+		}
+	}
+
+	if err := config.Debug.validate(config.Provider.Type); err != nil {
+		return fmt.Errorf("任务 '%s' 的调试配置无效: %w", config.Name, err)
+	}
+
+	if err := validateOutputs(config.Output); err != nil {
+		return fmt.Errorf("任务 '%s' 的输出配置无效: %w", config.Name, err)
+	}
+
+	if config.Provider.Type == "Historical" {
+		if _, err := ParseHistoricalParams(config.Params, time.Now()); err != nil {
+			return fmt.Errorf("任务 '%s' 的历史数据参数无效: %w", config.Name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -232,11 +322,17 @@ func (s *DefaultJobScheduler) addJobInternal(config JobConfig) error {
 		return fmt.Errorf("任务已存在: %s", config.Name)
 	}
 
+	schedule, err := scheduleParser.Parse(config.Schedule)
+	if err != nil {
+		return fmt.Errorf("无效的调度表达式 '%s': %w", config.Schedule, err)
+	}
+
 	// 创建任务
 	job := &Job{
-		ID:     uuid.New().String(),
-		Config: config,
-		Status: JobStatusPending,
+		ID:       uuid.New().String(),
+		Config:   config,
+		Status:   JobStatusPending,
+		schedule: schedule,
 	}
 
 	if !config.Enabled {
@@ -248,7 +344,7 @@ func (s *DefaultJobScheduler) addJobInternal(config JobConfig) error {
 
 	// 添加到 cron 调度器
 	entryID, err := s.cron.AddFunc(config.Schedule, func() {
-		s.executeJob(job)
+		s.runScheduled(job, time.Now())
 	})
 	if err != nil {
 		return fmt.Errorf("添加任务到调度器失败: %w", err)
@@ -261,29 +357,131 @@ func (s *DefaultJobScheduler) addJobInternal(config JobConfig) error {
 	return nil
 }
 
-// executeJob 执行任务
+// runScheduled 定时触发任务，singleton 任务只有获得本调度时刻的锁才执行
+// 获取锁出错时仍然执行，避免 Redis 异常导致所有节点都停止采集
+func (s *DefaultJobScheduler) runScheduled(job *Job, now time.Time) {
+	s.mu.RLock()
+	locker := s.locker
+	s.mu.RUnlock()
+
+	if s.suspendOutsideTradingHours(job, now) {
+		return
+	}
+
+	if job.Config.Singleton && locker != nil {
+		tick, ttl := scheduledTick(job.schedule, now)
+		key := jobLockKey(job.Config.Name, tick)
+
+		acquired, err := locker.TryLock(s.ctx, key, ttl)
+		if err != nil {
+			s.logger.WithError(err).Warnf("获取任务锁失败，继续执行: %s", job.Config.Name)
+		} else if !acquired {
+			s.mu.Lock()
+			job.LockSkipCount++
+			s.mu.Unlock()
+			s.logger.Debugf("任务锁已被其他节点持有，跳过本次执行: %s (%s)", job.Config.Name, key)
+			return
+		}
+	}
+
+	s.executeJob(job)
+}
+
+// suspendOutsideTradingHours 检查 trading_hours_only 任务是否在交易窗口内，不在时记为挂起并返回 true
+func (s *DefaultJobScheduler) suspendOutsideTradingHours(job *Job, now time.Time) bool {
+	if !job.Config.TradingHoursOnly {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.market.IsWithinTradingWindow(now, job.Config.PreOpenGrace, job.Config.PostCloseGrace) {
+		job.NextActiveTime = nil
+		return false
+	}
+
+	next := s.market.NextTradingWindowStart(now, job.Config.PreOpenGrace, job.Config.PostCloseGrace)
+	job.NextActiveTime = &next
+	job.SuspendedCount++
+	if job.running == 0 {
+		job.Status = JobStatusSuspended
+	}
+	s.logger.Debugf("非交易时段，任务挂起: %s (下次交易窗口: %s)", job.Config.Name, next.Format(time.RFC3339))
+	return true
+}
+
+// executeJob 按任务的重叠策略执行任务
 func (s *DefaultJobScheduler) executeJob(job *Job) {
 	s.mu.Lock()
-	if job.Status == JobStatusRunning {
+	if job.running > 0 {
+		switch job.Config.OverlapPolicy {
+		case OverlapAllow:
+			// 允许并发执行
+		case OverlapQueue:
+			if job.queued {
+				job.SkipCount++
+				s.mu.Unlock()
+				s.logger.Warnf("任务已有排队的执行，跳过本次执行: %s", job.Config.Name)
+				return
+			}
+			job.queued = true
+			s.mu.Unlock()
+			s.logger.Infof("任务正在运行，本次执行已排队: %s", job.Config.Name)
+			return
+		default:
+			job.SkipCount++
+			s.mu.Unlock()
+			s.logger.Warnf("任务正在运行，跳过本次执行: %s", job.Config.Name)
+			return
+		}
+	}
+	job.running++
+	s.mu.Unlock()
+
+	for {
+		s.runOnce(job)
+
+		s.mu.Lock()
+		if job.queued {
+			job.queued = false
+			s.mu.Unlock()
+			continue
+		}
+		job.running--
 		s.mu.Unlock()
-		s.logger.Warnf("任务正在运行，跳过本次执行: %s", job.Config.Name)
 		return
 	}
+}
+
+// runOnce 在任务超时时间内执行一次任务并记录结果
+func (s *DefaultJobScheduler) runOnce(job *Job) {
+	s.mu.Lock()
 	job.Status = JobStatusRunning
 	now := time.Now()
 	job.LastRun = &now
+	job.LastRunStart = &now
 	job.RunCount++
+	timeout := job.Config.Timeout
 	s.mu.Unlock()
 
+	if timeout <= 0 {
+		timeout = DefaultJobTimeout
+	}
+
 	s.logger.Infof("开始执行任务: %s", job.Config.Name)
 
 	// 执行任务
-	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Minute) // 默认5分钟超时
+	ctx, cancel := context.WithTimeout(s.ctx, timeout)
 	defer cancel()
 
 	err := s.executor.Execute(ctx, job)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("任务执行超时 (%v): %w", timeout, err)
+	}
 
 	s.mu.Lock()
+	job.LastRunDuration = time.Since(now)
 	if err != nil {
 		job.Status = JobStatusError
 		job.LastError = err
@@ -292,11 +490,31 @@ func (s *DefaultJobScheduler) executeJob(job *Job) {
 	} else {
 		job.Status = JobStatusPending
 		job.LastError = nil
-		s.logger.Infof("任务执行成功: %s", job.Config.Name)
+		s.logger.Infof("任务执行成功: %s (耗时 %v)", job.Config.Name, job.LastRunDuration)
+	}
+	if job.running > 1 {
+		// allow 策略下仍有其他执行在进行
+		job.Status = JobStatusRunning
 	}
 	s.mu.Unlock()
 }
 
+// updateNextActiveTimes 更新 trading_hours_only 任务的下次交易窗口时间（需要持有锁）
+func (s *DefaultJobScheduler) updateNextActiveTimes(now time.Time) {
+	for _, job := range s.jobs {
+		if !job.Config.Enabled || !job.Config.TradingHoursOnly {
+			continue
+		}
+		if s.market.IsWithinTradingWindow(now, job.Config.PreOpenGrace, job.Config.PostCloseGrace) {
+			job.NextActiveTime = nil
+			continue
+		}
+		next := s.market.NextTradingWindowStart(now, job.Config.PreOpenGrace, job.Config.PostCloseGrace)
+		job.NextActiveTime = &next
+		job.Status = JobStatusSuspended
+	}
+}
+
 // updateNextRunTimes 更新所有任务的下次运行时间
 func (s *DefaultJobScheduler) updateNextRunTimes() {
 	entries := s.cron.Entries()
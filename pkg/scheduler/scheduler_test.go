@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -27,6 +28,72 @@ func (m *MockJobExecutor) Execute(ctx context.Context, job *Job) error {
 	return nil
 }
 
+// slowJobExecutor 执行阻塞直到 release 被关闭或 ctx 结束的执行器
+type slowJobExecutor struct {
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+	active  atomic.Int32
+	maxSeen atomic.Int32
+}
+
+func newSlowJobExecutor() *slowJobExecutor {
+	return &slowJobExecutor{
+		started: make(chan struct{}, 16),
+		release: make(chan struct{}),
+	}
+}
+
+func (e *slowJobExecutor) Execute(ctx context.Context, job *Job) error {
+	e.calls.Add(1)
+	n := e.active.Add(1)
+	defer e.active.Add(-1)
+	for {
+		max := e.maxSeen.Load()
+		if n <= max || e.maxSeen.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	e.started <- struct{}{}
+
+	select {
+	case <-e.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitJob 轮询直到任务满足条件
+func waitJob(t *testing.T, s *DefaultJobScheduler, name string, cond func(*Job) bool) *Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		job, err := s.GetJob(name)
+		require.NoError(t, err)
+		if cond(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待任务 %s 状态超时: %+v", name, job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func overlapJobConfig(name string, policy OverlapPolicy) JobConfig {
+	return JobConfig{
+		Name:          name,
+		Enabled:       true,
+		Schedule:      "0 0 0 1 1 *",
+		OverlapPolicy: policy,
+		Provider: ProviderConfig{
+			Name: "test-provider",
+			Type: "RealtimeStock",
+		},
+	}
+}
+
 func TestNewJobScheduler(t *testing.T) {
 	scheduler := NewJobScheduler()
 
@@ -123,6 +190,70 @@ jobs:
 	}
 }
 
+func TestJobScheduler_LoadConfig_ProviderFallbacks(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "jobs.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+jobs:
+  - name: "failover-job"
+    enabled: false
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+      fallbacks: ["sina"]
+      top_up: true
+`), 0644))
+
+	scheduler := NewJobScheduler()
+	require.NoError(t, scheduler.LoadConfig(configPath))
+
+	job, err := scheduler.GetJob("failover-job")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sina"}, job.Config.Provider.Fallbacks)
+	assert.True(t, job.Config.Provider.TopUp)
+}
+
+func TestJobScheduler_LoadConfig_Streams(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "jobs.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+jobs: []
+streams:
+  - name: "stream:stock:realtime"
+    max_len: 100000
+  - name: "stream:index:realtime"
+`), 0644))
+
+	scheduler := NewJobScheduler()
+	require.NoError(t, scheduler.LoadConfig(configPath))
+	assert.Equal(t, []StreamConfig{
+		{Name: "stream:stock:realtime", MaxLen: 100000},
+		{Name: "stream:index:realtime"},
+	}, scheduler.Streams())
+}
+
+func TestJobScheduler_LoadConfig_OverlapPolicyAndTimeout(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "jobs.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+jobs:
+  - name: "slow-job"
+    enabled: false
+    schedule: "*/3 * * * * *"
+    overlap_policy: "queue"
+    timeout: "10s"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+`), 0644))
+
+	scheduler := NewJobScheduler()
+	require.NoError(t, scheduler.LoadConfig(configPath))
+
+	job, err := scheduler.GetJob("slow-job")
+	require.NoError(t, err)
+	assert.Equal(t, OverlapQueue, job.Config.OverlapPolicy)
+	assert.Equal(t, 10*time.Second, job.Config.Timeout)
+}
+
 func TestJobScheduler_AddJob(t *testing.T) {
 	scheduler := NewJobScheduler()
 
@@ -291,19 +422,24 @@ func TestJobScheduler_StartStop(t *testing.T) {
 	executor := &MockJobExecutor{}
 	scheduler.SetExecutor(executor)
 
+	assert.False(t, scheduler.IsRunning())
+
 	// 测试启动调度器
 	err := scheduler.Start()
 	assert.NoError(t, err)
+	assert.True(t, scheduler.IsRunning())
 
 	// 测试停止调度器
 	err = scheduler.Stop()
 	assert.NoError(t, err)
+	assert.False(t, scheduler.IsRunning())
 
 	// 测试没有执行器时启动
 	scheduler2 := NewJobScheduler()
 	err = scheduler2.Start()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "任务执行器未设置")
+	assert.False(t, scheduler2.IsRunning())
 }
 
 func TestJobScheduler_validateJobConfig(t *testing.T) {
@@ -374,6 +510,45 @@ func TestJobScheduler_validateJobConfig(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "有效的历史数据任务",
+			config: JobConfig{
+				Name:     "kline-job",
+				Schedule: "0 0 16 * * 1-5",
+				Provider: ProviderConfig{
+					Name: "tencent",
+					Type: "Historical",
+				},
+				Params: map[string]interface{}{"start": "-7d", "period": "1d"},
+			},
+			expectError: false,
+		},
+		{
+			name: "历史数据任务时间参数无效",
+			config: JobConfig{
+				Name:     "kline-job",
+				Schedule: "0 0 16 * * 1-5",
+				Provider: ProviderConfig{
+					Name: "tencent",
+					Type: "Historical",
+				},
+				Params: map[string]interface{}{"start": "yesterday"},
+			},
+			expectError: true,
+		},
+		{
+			name: "无效的输出编码",
+			config: JobConfig{
+				Name:     "test-job",
+				Schedule: "*/5 * * * * *",
+				Provider: ProviderConfig{
+					Name: "test-provider",
+					Type: "RealtimeStock",
+				},
+				Output: Outputs{{Type: "redis_stream", Encoding: "brotli"}},
+			},
+			expectError: true,
+		},
 		{
 			name: "缺少提供商类型",
 			config: JobConfig{
@@ -386,6 +561,99 @@ func TestJobScheduler_validateJobConfig(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "配置备用提供商",
+			config: JobConfig{
+				Name:     "test-job",
+				Schedule: "*/5 * * * * *",
+				Provider: ProviderConfig{
+					Name:      "tencent",
+					Type:      "RealtimeStock",
+					Fallbacks: []string{"sina"},
+					TopUp:     true,
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "备用提供商与主提供商相同",
+			config: JobConfig{
+				Name:     "test-job",
+				Schedule: "*/5 * * * * *",
+				Provider: ProviderConfig{
+					Name:      "tencent",
+					Type:      "RealtimeStock",
+					Fallbacks: []string{"tencent"},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "收盘快照任务配置备用提供商",
+			config: JobConfig{
+				Name:     "test-job",
+				Schedule: "0 5 15 * * 1-5",
+				Provider: ProviderConfig{
+					Name:      "tencent",
+					Type:      "eod_snapshot",
+					Fallbacks: []string{"sina"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "非实时股票任务配置备用提供商",
+			config: JobConfig{
+				Name:     "test-job",
+				Schedule: "*/5 * * * * *",
+				Provider: ProviderConfig{
+					Name:      "sina",
+					Type:      "RealtimeIndex",
+					Fallbacks: []string{"tencent"},
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "配置重叠策略和超时",
+			config: JobConfig{
+				Name:          "test-job",
+				Schedule:      "*/5 * * * * *",
+				OverlapPolicy: OverlapQueue,
+				Timeout:       30 * time.Second,
+				Provider: ProviderConfig{
+					Name: "test-provider",
+					Type: "RealtimeStock",
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "无效的重叠策略",
+			config: JobConfig{
+				Name:          "test-job",
+				Schedule:      "*/5 * * * * *",
+				OverlapPolicy: "parallel",
+				Provider: ProviderConfig{
+					Name: "test-provider",
+					Type: "RealtimeStock",
+				},
+			},
+			expectError: true,
+		},
+		{
+			name: "负数超时",
+			config: JobConfig{
+				Name:     "test-job",
+				Schedule: "*/5 * * * * *",
+				Timeout:  -time.Second,
+				Provider: ProviderConfig{
+					Name: "test-provider",
+					Type: "RealtimeStock",
+				},
+			},
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -400,6 +668,104 @@ func TestJobScheduler_validateJobConfig(t *testing.T) {
 	}
 }
 
+func TestJobScheduler_OverlapSkip(t *testing.T) {
+	scheduler := NewJobScheduler()
+	executor := newSlowJobExecutor()
+	scheduler.SetExecutor(executor)
+	require.NoError(t, scheduler.AddJob(overlapJobConfig("skip-job", "")))
+
+	require.NoError(t, scheduler.RunJob("skip-job"))
+	<-executor.started
+
+	// 运行期间的触发全部跳过
+	scheduler.executeJob(scheduler.jobs["skip-job"])
+	scheduler.executeJob(scheduler.jobs["skip-job"])
+
+	job, err := scheduler.GetJob("skip-job")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusRunning, job.Status)
+	assert.Equal(t, int64(2), job.SkipCount)
+	assert.NotNil(t, job.LastRunStart)
+
+	close(executor.release)
+	job = waitJob(t, scheduler, "skip-job", func(j *Job) bool { return j.Status == JobStatusPending })
+
+	assert.Equal(t, int32(1), executor.calls.Load())
+	assert.Equal(t, int64(1), job.RunCount)
+	assert.Equal(t, int64(2), job.SkipCount)
+	assert.Greater(t, job.LastRunDuration, time.Duration(0))
+	assert.NoError(t, job.LastError)
+}
+
+func TestJobScheduler_OverlapQueue(t *testing.T) {
+	scheduler := NewJobScheduler()
+	executor := newSlowJobExecutor()
+	scheduler.SetExecutor(executor)
+	require.NoError(t, scheduler.AddJob(overlapJobConfig("queue-job", OverlapQueue)))
+
+	require.NoError(t, scheduler.RunJob("queue-job"))
+	<-executor.started
+
+	// 第一次触发排队，后续触发合并为同一次
+	scheduler.executeJob(scheduler.jobs["queue-job"])
+	scheduler.executeJob(scheduler.jobs["queue-job"])
+	scheduler.executeJob(scheduler.jobs["queue-job"])
+
+	job, err := scheduler.GetJob("queue-job")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), job.SkipCount)
+
+	close(executor.release)
+	<-executor.started
+	job = waitJob(t, scheduler, "queue-job", func(j *Job) bool {
+		return j.RunCount == 2 && j.Status == JobStatusPending
+	})
+
+	assert.Equal(t, int32(2), executor.calls.Load())
+	assert.Equal(t, int32(1), executor.maxSeen.Load(), "排队的执行不应与上一次重叠")
+	assert.Equal(t, int64(2), job.SkipCount)
+}
+
+func TestJobScheduler_OverlapAllow(t *testing.T) {
+	scheduler := NewJobScheduler()
+	executor := newSlowJobExecutor()
+	scheduler.SetExecutor(executor)
+	require.NoError(t, scheduler.AddJob(overlapJobConfig("allow-job", OverlapAllow)))
+
+	require.NoError(t, scheduler.RunJob("allow-job"))
+	require.NoError(t, scheduler.RunJob("allow-job"))
+	<-executor.started
+	<-executor.started
+
+	assert.Equal(t, int32(2), executor.maxSeen.Load())
+
+	close(executor.release)
+	job := waitJob(t, scheduler, "allow-job", func(j *Job) bool {
+		return j.Status == JobStatusPending
+	})
+	assert.Equal(t, int64(2), job.RunCount)
+	assert.Equal(t, int64(0), job.SkipCount)
+}
+
+func TestJobScheduler_ExecuteTimeout(t *testing.T) {
+	scheduler := NewJobScheduler()
+	executor := newSlowJobExecutor()
+	scheduler.SetExecutor(executor)
+
+	config := overlapJobConfig("timeout-job", "")
+	config.Timeout = 50 * time.Millisecond
+	require.NoError(t, scheduler.AddJob(config))
+
+	require.NoError(t, scheduler.RunJob("timeout-job"))
+	job := waitJob(t, scheduler, "timeout-job", func(j *Job) bool { return j.Status == JobStatusError })
+
+	require.Error(t, job.LastError)
+	assert.Contains(t, job.LastError.Error(), "任务执行超时")
+	assert.ErrorIs(t, job.LastError, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), job.ErrorCount)
+	assert.GreaterOrEqual(t, job.LastRunDuration, 50*time.Millisecond)
+}
+
 func TestJobScheduler_Integration(t *testing.T) {
 	// 创建临时配置文件
 	tmpDir := t.TempDir()
@@ -459,3 +825,107 @@ jobs:
 	assert.True(t, job.RunCount >= 2, "运行次数应该至少为2")
 	assert.NotNil(t, job.LastRun)
 }
+
+func tradingHoursJobConfig() JobConfig {
+	return JobConfig{
+		Name:             "trading-job",
+		Enabled:          true,
+		Schedule:         "*/3 * * * * *",
+		TradingHoursOnly: true,
+		PreOpenGrace:     5 * time.Minute,
+		Provider: ProviderConfig{
+			Name: "test-provider",
+			Type: "RealtimeStock",
+		},
+	}
+}
+
+func TestJobScheduler_TradingHoursOnlySuspendsOutsideSessions(t *testing.T) {
+	scheduler := NewJobScheduler()
+	executor := newSlowJobExecutor()
+	close(executor.release)
+	scheduler.SetExecutor(executor)
+	require.NoError(t, scheduler.AddJob(tradingHoursJobConfig()))
+	job := scheduler.jobs["trading-job"]
+
+	// 凌晨 2 点（周四）不执行
+	night := time.Date(2025, 8, 21, 2, 0, 0, 0, time.Local)
+	scheduler.runScheduled(job, night)
+
+	status, err := scheduler.GetJob("trading-job")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusSuspended, status.Status)
+	assert.Equal(t, int64(1), status.SuspendedCount)
+	assert.Equal(t, int64(0), status.ErrorCount)
+	require.NotNil(t, status.NextActiveTime)
+	assert.Equal(t, time.Date(2025, 8, 21, 9, 8, 30, 0, time.Local), *status.NextActiveTime)
+
+	// 午间休市不执行，下次交易窗口为下午开盘
+	scheduler.runScheduled(job, time.Date(2025, 8, 21, 12, 0, 0, 0, time.Local))
+	status, err = scheduler.GetJob("trading-job")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), status.SuspendedCount)
+	assert.Equal(t, time.Date(2025, 8, 21, 12, 57, 30, 0, time.Local), *status.NextActiveTime)
+	assert.Equal(t, int32(0), executor.calls.Load())
+
+	// 开盘前缓冲窗口内执行
+	scheduler.runScheduled(job, time.Date(2025, 8, 21, 9, 10, 0, 0, time.Local))
+	status, err = scheduler.GetJob("trading-job")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), executor.calls.Load())
+	assert.Equal(t, JobStatusPending, status.Status)
+	assert.Nil(t, status.NextActiveTime)
+	assert.Equal(t, int64(2), status.SuspendedCount)
+}
+
+func TestJobScheduler_TradingHoursOnlyIgnoredWhenDisabled(t *testing.T) {
+	scheduler := NewJobScheduler()
+	executor := newSlowJobExecutor()
+	close(executor.release)
+	scheduler.SetExecutor(executor)
+
+	config := tradingHoursJobConfig()
+	config.TradingHoursOnly = false
+	require.NoError(t, scheduler.AddJob(config))
+
+	scheduler.runScheduled(scheduler.jobs["trading-job"], time.Date(2025, 8, 23, 2, 0, 0, 0, time.Local))
+	assert.Equal(t, int32(1), executor.calls.Load())
+}
+
+func TestJobScheduler_LoadConfig_TradingHours(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "jobs.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+jobs:
+  - name: "trading-job"
+    enabled: false
+    schedule: "*/3 * * * * *"
+    trading_hours_only: true
+    market: "A-share"
+    pre_open_grace: "5m"
+    post_close_grace: "2m"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+  - name: "us-job"
+    enabled: false
+    schedule: "*/3 * * * * *"
+    trading_hours_only: true
+    market: "NASDAQ"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+`), 0644))
+
+	scheduler := NewJobScheduler()
+	require.NoError(t, scheduler.LoadConfig(configPath))
+
+	job, err := scheduler.GetJob("trading-job")
+	require.NoError(t, err)
+	assert.True(t, job.Config.TradingHoursOnly)
+	assert.Equal(t, MarketAShare, job.Config.Market)
+	assert.Equal(t, 5*time.Minute, job.Config.PreOpenGrace)
+	assert.Equal(t, 2*time.Minute, job.Config.PostCloseGrace)
+
+	_, err = scheduler.GetJob("us-job")
+	assert.Error(t, err, "不支持的市场应被跳过")
+}
@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"stocksub/pkg/message"
+)
+
+const (
+	// JobStatsKeyPrefix 任务发布统计的键前缀，完整键为 stats:job:<任务名>:<yyyymmddHH>，
+	// 非默认命名空间下再加 <namespace>: 前缀
+	JobStatsKeyPrefix = "stats:job:"
+	// ProviderStatsKeyPrefix 提供商发布统计的键前缀，完整键为 stats:provider:<提供商>:<yyyymmddHH>
+	ProviderStatsKeyPrefix = "stats:provider:"
+	// StatsTTL 每小时统计键的保留时间
+	StatsTTL = 48 * time.Hour
+
+	statsJobsKey      = "stats:jobs"      // 有统计数据的任务名集合
+	statsProvidersKey = "stats:providers" // 有统计数据的提供商集合
+	statsHourLayout   = "2006010215"
+)
+
+// 统计哈希的字段名
+const (
+	statsFieldRuns          = "runs"
+	statsFieldFetched       = "fetched"
+	statsFieldPublished     = "published"
+	statsFieldErrors        = "errors"
+	statsFieldDurationMsSum = "duration_ms_sum"
+
+	// 每个输出目标的计数字段为 sink:<名称>:published 和 sink:<名称>:errors
+	statsSinkFieldPrefix    = "sink:"
+	statsSinkFieldPublished = ":published"
+	statsSinkFieldErrors    = ":errors"
+)
+
+// SinkStats 一个输出目标的发布统计
+type SinkStats struct {
+	Published int64 `json:"published"` // 成功写入的记录数
+	Errors    int64 `json:"errors"`    // 写入失败的消息数
+}
+
+// RunStats 一次任务执行的统计
+type RunStats struct {
+	Fetched   int64         // 从提供商获取的记录数
+	Published int64         // 发布到 Stream 的记录数
+	Errors    int64         // 失败次数
+	Duration  time.Duration // 执行耗时
+
+	Sinks map[string]SinkStats // 按输出目标名称的发布统计，只记录在任务统计中
+}
+
+// StatsTotals 一段时间内的统计合计
+type StatsTotals struct {
+	Runs          int64 `json:"runs"`
+	Fetched       int64 `json:"fetched"`
+	Published     int64 `json:"published"`
+	Errors        int64 `json:"errors"`
+	DurationMsSum int64 `json:"duration_ms_sum"`
+
+	Sinks map[string]SinkStats `json:"sinks,omitempty"`
+}
+
+// StatsWindow 最近一小时和最近 24 小时的统计，按整点小时分桶，last_hour 为当前小时桶
+type StatsWindow struct {
+	LastHour StatsTotals `json:"last_hour"`
+	Last24h  StatsTotals `json:"last_24h"`
+}
+
+// StatsSummary 所有任务和提供商的统计汇总
+type StatsSummary struct {
+	GeneratedAt time.Time              `json:"generated_at"`
+	Jobs        map[string]StatsWindow `json:"jobs"`
+	Providers   map[string]StatsWindow `json:"providers"`
+}
+
+// JobStatsKey 返回任务在 hour 所在小时的统计键，小时按 UTC 计算
+func JobStatsKey(job string, hour time.Time) string {
+	return JobStatsKeyPrefix + job + ":" + hour.UTC().Format(statsHourLayout)
+}
+
+// ProviderStatsKey 返回提供商在 hour 所在小时的统计键，小时按 UTC 计算
+func ProviderStatsKey(provider string, hour time.Time) string {
+	return ProviderStatsKeyPrefix + provider + ":" + hour.UTC().Format(statsHourLayout)
+}
+
+// StatsRecorder 把任务执行统计累加到 Redis 的每小时哈希中
+type StatsRecorder struct {
+	client    redis.Cmdable
+	namespace string
+	now       func() time.Time
+}
+
+// NewStatsRecorder 创建统计记录器，namespace 为空时使用默认命名空间
+func NewStatsRecorder(client redis.Cmdable, namespace string) *StatsRecorder {
+	return &StatsRecorder{client: client, namespace: namespace, now: time.Now}
+}
+
+// Record 用一个 pipeline 累加任务和各提供商的统计，并刷新键的过期时间
+func (r *StatsRecorder) Record(ctx context.Context, job string, run RunStats, providers map[string]RunStats) error {
+	now := r.now()
+	pipe := r.client.Pipeline()
+
+	jobKey := message.NamespaceKey(r.namespace, JobStatsKey(job, now))
+	jobsKey := message.NamespaceKey(r.namespace, statsJobsKey)
+	incrRunStats(ctx, pipe, jobKey, run)
+	pipe.Expire(ctx, jobKey, StatsTTL)
+	pipe.SAdd(ctx, jobsKey, job)
+	pipe.Expire(ctx, jobsKey, StatsTTL)
+
+	providersKey := message.NamespaceKey(r.namespace, statsProvidersKey)
+	for name, stats := range providers {
+		key := message.NamespaceKey(r.namespace, ProviderStatsKey(name, now))
+		incrRunStats(ctx, pipe, key, stats)
+		pipe.Expire(ctx, key, StatsTTL)
+		pipe.SAdd(ctx, providersKey, name)
+	}
+	if len(providers) > 0 {
+		pipe.Expire(ctx, providersKey, StatsTTL)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("写入任务统计失败: %w", err)
+	}
+	return nil
+}
+
+// incrRunStats 累加一次执行的各项计数
+func incrRunStats(ctx context.Context, pipe redis.Pipeliner, key string, run RunStats) {
+	pipe.HIncrBy(ctx, key, statsFieldRuns, 1)
+	pipe.HIncrBy(ctx, key, statsFieldFetched, run.Fetched)
+	pipe.HIncrBy(ctx, key, statsFieldPublished, run.Published)
+	pipe.HIncrBy(ctx, key, statsFieldErrors, run.Errors)
+	pipe.HIncrBy(ctx, key, statsFieldDurationMsSum, run.Duration.Milliseconds())
+	for name, sink := range run.Sinks {
+		pipe.HIncrBy(ctx, key, statsSinkFieldPrefix+name+statsSinkFieldPublished, sink.Published)
+		pipe.HIncrBy(ctx, key, statsSinkFieldPrefix+name+statsSinkFieldErrors, sink.Errors)
+	}
+}
+
+// LoadStatsSummary 读取 namespace 下最近 24 个小时桶，汇总每个任务和提供商的统计
+func LoadStatsSummary(ctx context.Context, client redis.Cmdable, namespace string, now time.Time) (*StatsSummary, error) {
+	jobs, err := client.SMembers(ctx, message.NamespaceKey(namespace, statsJobsKey)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("读取任务列表失败: %w", err)
+	}
+	providers, err := client.SMembers(ctx, message.NamespaceKey(namespace, statsProvidersKey)).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("读取提供商列表失败: %w", err)
+	}
+	summary := &StatsSummary{
+		GeneratedAt: now,
+		Jobs:        make(map[string]StatsWindow, len(jobs)),
+		Providers:   make(map[string]StatsWindow, len(providers)),
+	}
+	if len(jobs)+len(providers) == 0 {
+		return summary, nil
+	}
+
+	type bucket struct {
+		cmd     *redis.StringStringMapCmd
+		current bool
+	}
+	pipe := client.Pipeline()
+	jobBuckets := make(map[string][]bucket, len(jobs))
+	providerBuckets := make(map[string][]bucket, len(providers))
+	for h := 0; h < 24; h++ {
+		hour := now.Add(-time.Duration(h) * time.Hour)
+		for _, job := range jobs {
+			jobBuckets[job] = append(jobBuckets[job], bucket{pipe.HGetAll(ctx, message.NamespaceKey(namespace, JobStatsKey(job, hour))), h == 0})
+		}
+		for _, provider := range providers {
+			providerBuckets[provider] = append(providerBuckets[provider], bucket{pipe.HGetAll(ctx, message.NamespaceKey(namespace, ProviderStatsKey(provider, hour))), h == 0})
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("读取任务统计失败: %w", err)
+	}
+
+	aggregate := func(buckets []bucket) StatsWindow {
+		var window StatsWindow
+		for _, b := range buckets {
+			totals := parseStatsTotals(b.cmd.Val())
+			window.Last24h.add(totals)
+			if b.current {
+				window.LastHour.add(totals)
+			}
+		}
+		return window
+	}
+	for job, buckets := range jobBuckets {
+		summary.Jobs[job] = aggregate(buckets)
+	}
+	for provider, buckets := range providerBuckets {
+		summary.Providers[provider] = aggregate(buckets)
+	}
+	return summary, nil
+}
+
+// parseStatsTotals 解析统计哈希，缺失或无效的字段按 0 处理
+func parseStatsTotals(fields map[string]string) StatsTotals {
+	value := func(name string) int64 {
+		n, _ := strconv.ParseInt(fields[name], 10, 64)
+		return n
+	}
+	totals := StatsTotals{
+		Runs:          value(statsFieldRuns),
+		Fetched:       value(statsFieldFetched),
+		Published:     value(statsFieldPublished),
+		Errors:        value(statsFieldErrors),
+		DurationMsSum: value(statsFieldDurationMsSum),
+	}
+	for field := range fields {
+		if !strings.HasPrefix(field, statsSinkFieldPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(field, statsSinkFieldPrefix)
+		var sink SinkStats
+		switch {
+		case strings.HasSuffix(name, statsSinkFieldPublished):
+			name = strings.TrimSuffix(name, statsSinkFieldPublished)
+			sink.Published = value(field)
+		case strings.HasSuffix(name, statsSinkFieldErrors):
+			name = strings.TrimSuffix(name, statsSinkFieldErrors)
+			sink.Errors = value(field)
+		default:
+			continue
+		}
+		totals.addSink(name, sink)
+	}
+	return totals
+}
+
+// add 累加另一段时间的统计
+func (t *StatsTotals) add(other StatsTotals) {
+	t.Runs += other.Runs
+	t.Fetched += other.Fetched
+	t.Published += other.Published
+	t.Errors += other.Errors
+	t.DurationMsSum += other.DurationMsSum
+	for name, sink := range other.Sinks {
+		t.addSink(name, sink)
+	}
+}
+
+// addSink 累加一个输出目标的统计
+func (t *StatsTotals) addSink(name string, sink SinkStats) {
+	if t.Sinks == nil {
+		t.Sinks = make(map[string]SinkStats)
+	}
+	total := t.Sinks[name]
+	total.Published += sink.Published
+	total.Errors += sink.Errors
+	t.Sinks[name] = total
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStatsTestRecorder(t *testing.T) (*StatsRecorder, *redis.Client, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewStatsRecorder(client, ""), client, mr
+}
+
+func TestStatsKeys(t *testing.T) {
+	at := time.Date(2025, 8, 20, 10, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	assert.Equal(t, "stats:job:realtime:2025082002", JobStatsKey("realtime", at))
+	assert.Equal(t, "stats:provider:tencent:2025082002", ProviderStatsKey("tencent", at))
+}
+
+func TestStatsRecorder_RecordIncrementsHourlyHash(t *testing.T) {
+	recorder, client, mr := newStatsTestRecorder(t)
+	now := time.Date(2025, 8, 20, 2, 15, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	run := RunStats{Fetched: 50, Published: 48, Duration: 120 * time.Millisecond}
+	providers := map[string]RunStats{"tencent": {Fetched: 40, Published: 40}, "sina": {Fetched: 10, Published: 8}}
+	require.NoError(t, recorder.Record(ctx, "realtime", run, providers))
+	require.NoError(t, recorder.Record(ctx, "realtime", RunStats{Errors: 1, Duration: 30 * time.Millisecond}, nil))
+
+	fields, err := client.HGetAll(ctx, "stats:job:realtime:2025082002").Result()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"runs":            "2",
+		"fetched":         "50",
+		"published":       "48",
+		"errors":          "1",
+		"duration_ms_sum": "150",
+	}, fields)
+	assert.Equal(t, StatsTTL, mr.TTL("stats:job:realtime:2025082002"))
+
+	sina, err := client.HGetAll(ctx, "stats:provider:sina:2025082002").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "8", sina["published"])
+	assert.Equal(t, StatsTTL, mr.TTL("stats:provider:sina:2025082002"))
+}
+
+func TestStatsRecorder_NamespacedKeys(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	recorder := NewStatsRecorder(client, "staging")
+	now := time.Date(2025, 8, 20, 2, 15, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	require.NoError(t, recorder.Record(context.Background(), "realtime", RunStats{Published: 5}, map[string]RunStats{"tencent": {Published: 5}}))
+	assert.Equal(t, []string{
+		"staging:stats:job:realtime:2025082002",
+		"staging:stats:jobs",
+		"staging:stats:provider:tencent:2025082002",
+		"staging:stats:providers",
+	}, mr.Keys())
+
+	summary, err := LoadStatsSummary(context.Background(), client, "staging", now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), summary.Providers["tencent"].LastHour.Published)
+}
+
+func TestLoadStatsSummary_AggregatesLastHourAnd24h(t *testing.T) {
+	recorder, client, _ := newStatsTestRecorder(t)
+	ctx := context.Background()
+	now := time.Date(2025, 8, 20, 10, 15, 0, 0, time.UTC)
+
+	for _, at := range []time.Time{
+		now,
+		now.Add(-10 * time.Minute), // 同一个小时桶的更早时刻
+		now.Add(-5 * time.Hour),
+		now.Add(-23 * time.Hour),
+		now.Add(-25 * time.Hour), // 超出 24 小时窗口
+	} {
+		recorder.now = func() time.Time { return at }
+		require.NoError(t, recorder.Record(ctx, "realtime", RunStats{Fetched: 10, Published: 10, Duration: time.Second},
+			map[string]RunStats{"tencent": {Fetched: 10, Published: 10}}))
+	}
+	recorder.now = func() time.Time { return now }
+	require.NoError(t, recorder.Record(ctx, "kline", RunStats{Errors: 1}, nil))
+
+	summary, err := LoadStatsSummary(ctx, client, "", now)
+	require.NoError(t, err)
+
+	assert.Equal(t, StatsWindow{
+		LastHour: StatsTotals{Runs: 2, Fetched: 20, Published: 20, DurationMsSum: 2000},
+		Last24h:  StatsTotals{Runs: 4, Fetched: 40, Published: 40, DurationMsSum: 4000},
+	}, summary.Jobs["realtime"])
+	assert.Equal(t, int64(1), summary.Jobs["kline"].LastHour.Errors)
+	assert.Equal(t, int64(40), summary.Providers["tencent"].Last24h.Published)
+	assert.Equal(t, now, summary.GeneratedAt)
+}
+
+func TestStatsRecorder_RecordsPerSinkCounters(t *testing.T) {
+	recorder, client, _ := newStatsTestRecorder(t)
+	ctx := context.Background()
+	now := time.Date(2025, 8, 20, 10, 15, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	sinks := map[string]SinkStats{"redis_stream": {Published: 5}, "csv_file": {Errors: 1}}
+	require.NoError(t, recorder.Record(ctx, "realtime", RunStats{Fetched: 5, Published: 5, Sinks: sinks}, nil))
+	require.NoError(t, recorder.Record(ctx, "realtime", RunStats{Fetched: 5, Published: 5, Sinks: map[string]SinkStats{"redis_stream": {Published: 5}, "csv_file": {Published: 5}}}, nil))
+
+	fields, err := client.HGetAll(ctx, "stats:job:realtime:2025082010").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "10", fields["sink:redis_stream:published"])
+	assert.Equal(t, "1", fields["sink:csv_file:errors"])
+
+	summary, err := LoadStatsSummary(ctx, client, "", now)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SinkStats{
+		"redis_stream": {Published: 10},
+		"csv_file":     {Published: 5, Errors: 1},
+	}, summary.Jobs["realtime"].Last24h.Sinks)
+}
+
+func TestLoadStatsSummary_Empty(t *testing.T) {
+	_, client, _ := newStatsTestRecorder(t)
+
+	summary, err := LoadStatsSummary(context.Background(), client, "", time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, summary.Jobs)
+	assert.Empty(t, summary.Providers)
+}
@@ -0,0 +1,317 @@
+package scheduler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"stocksub/pkg/core"
+)
+
+// KnownProviders 可用的提供商名称，键为任务的提供商类型（RealtimeStock、RealtimeIndex、Historical、eod_snapshot）
+type KnownProviders map[string][]string
+
+// supportedProviderTypes 执行器支持的提供商类型
+var supportedProviderTypes = []string{"RealtimeStock", "RealtimeIndex", "Historical", "eod_snapshot"}
+
+// ConfigProblem 配置文件中的一个问题
+type ConfigProblem struct {
+	Line    int    // 所在行号，无法定位时为 0
+	Job     string // 所属任务名称，与任务无关时为空
+	Message string
+}
+
+// ValidationError 配置文件校验失败，包含所有发现的问题
+type ValidationError struct {
+	Path     string
+	Problems []ConfigProblem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		location := e.Path
+		if p.Line > 0 {
+			location = fmt.Sprintf("%s:%d", e.Path, p.Line)
+		}
+		if p.Job != "" {
+			lines[i] = fmt.Sprintf("%s: 任务 '%s': %s", location, p.Job, p.Message)
+		} else {
+			lines[i] = fmt.Sprintf("%s: %s", location, p.Message)
+		}
+	}
+	return fmt.Sprintf("配置文件 %s 有 %d 个问题:\n%s", e.Path, len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// yamlLinePattern 从 yaml 错误信息中提取行号
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// ValidateConfig 严格校验任务配置文件，一次返回所有问题（*ValidationError）。
+//
+// 除 LoadConfig 的检查外，还会报告未知字段、缺少 enabled、symbols 为空或格式不符；
+// known 不为 nil 时检查提供商名称是否在对应类型中注册。
+func ValidateConfig(path string, known KnownProviders) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	verr := &ValidationError{Path: path}
+
+	// 严格解码：未知字段和类型错误会带行号逐条返回
+	var strict JobsConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&strict); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			verr.add(0, "", err.Error())
+			return verr
+		}
+		for _, msg := range typeErr.Errors {
+			verr.add(0, "", msg)
+		}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		verr.add(0, "", err.Error())
+		return verr
+	}
+	validateStreams(verr, lookup(&root, "streams"))
+
+	jobs := lookup(&root, "jobs")
+	if jobs == nil || jobs.Kind != yaml.SequenceNode || len(jobs.Content) == 0 {
+		verr.add(0, "", "没有配置任何任务 (jobs)")
+		return verr.orNil()
+	}
+
+	names := make(map[string]int)
+	for _, node := range jobs.Content {
+		var config JobConfig
+		if err := node.Decode(&config); err != nil {
+			continue // 类型错误已由严格解码报告
+		}
+		if first, ok := names[config.Name]; ok && config.Name != "" {
+			verr.add(node.Line, config.Name, fmt.Sprintf("任务名称重复，与第 %d 行的任务相同", first))
+		}
+		names[config.Name] = node.Line
+		validateJobNode(verr, node, config, known)
+	}
+	return verr.orNil()
+}
+
+// validateJobNode 检查单个任务，基本字段都有效时再执行 LoadConfig 使用的其余检查
+func validateJobNode(verr *ValidationError, node *yaml.Node, config JobConfig, known KnownProviders) {
+	before := len(verr.Problems)
+	job := config.Name
+	if job == "" {
+		verr.add(node.Line, "", "任务名称不能为空")
+	}
+	if lookup(node, "enabled") == nil {
+		verr.add(node.Line, job, "缺少 enabled 字段（默认 false，任务不会运行）")
+	}
+
+	if config.Schedule == "" {
+		verr.add(node.Line, job, "任务调度表达式不能为空")
+	} else if _, err := scheduleParser.Parse(config.Schedule); err != nil {
+		verr.add(line(node, node.Line, "schedule"), job, fmt.Sprintf("无效的调度表达式 '%s': %v", config.Schedule, err))
+	}
+
+	providerLine := line(node, node.Line, "provider")
+	switch {
+	case config.Provider.Type == "":
+		verr.add(providerLine, job, "提供商类型不能为空")
+	case !contains(supportedProviderTypes, config.Provider.Type):
+		verr.add(line(node, providerLine, "provider", "type"), job,
+			fmt.Sprintf("不支持的提供商类型 %q（可选 %s）", config.Provider.Type, strings.Join(supportedProviderTypes, "、")))
+	}
+	if config.Provider.Name == "" {
+		verr.add(providerLine, job, "提供商名称不能为空")
+	} else if known != nil && contains(supportedProviderTypes, config.Provider.Type) {
+		names := append([]string{config.Provider.Name}, config.Provider.Fallbacks...)
+		for i, name := range names {
+			if name == "" || contains(known[config.Provider.Type], name) {
+				continue
+			}
+			key := "name"
+			if i > 0 {
+				key = "fallbacks"
+			}
+			verr.add(line(node, providerLine, "provider", key), job,
+				fmt.Sprintf("未知的 %s 提供商 %q（可用: %s）", config.Provider.Type, name, strings.Join(known[config.Provider.Type], "、")))
+		}
+	}
+
+	validateSymbols(verr, node, config)
+	validateOutputFields(verr, node, job)
+
+	if len(verr.Problems) == before {
+		if err := checkJobConfig(config); err != nil {
+			verr.add(node.Line, job, err.Error())
+		}
+	}
+}
+
+// outputFields output 中允许的字段
+var outputFields = []string{"type", "name", "directory", "stream", "encoding"}
+
+// validateOutputFields 报告 output 中的未知字段。Outputs 自定义了 UnmarshalYAML，严格解码检查不到其中的字段
+func validateOutputFields(verr *ValidationError, node *yaml.Node, job string) {
+	output := lookup(node, "output")
+	if output == nil {
+		return
+	}
+	items := []*yaml.Node{output}
+	if output.Kind == yaml.SequenceNode {
+		items = output.Content
+	}
+	for _, item := range items {
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(item.Content); i += 2 {
+			if key := item.Content[i]; !contains(outputFields, key.Value) {
+				verr.add(key.Line, job, fmt.Sprintf("output 中的未知字段 %q（可选 %s）", key.Value, strings.Join(outputFields, "、")))
+			}
+		}
+	}
+}
+
+// validateStreams 检查 streams 段：名称不能为空或重复，max_len 不能为负数
+func validateStreams(verr *ValidationError, streams *yaml.Node) {
+	if streams == nil || streams.Kind != yaml.SequenceNode {
+		return // 类型错误已由严格解码报告
+	}
+	names := make(map[string]int)
+	for _, node := range streams.Content {
+		var config StreamConfig
+		if err := node.Decode(&config); err != nil {
+			continue
+		}
+		if config.Name == "" {
+			verr.add(node.Line, "", "streams: 名称不能为空")
+			continue
+		}
+		if first, ok := names[config.Name]; ok {
+			verr.add(node.Line, "", fmt.Sprintf("streams: %s 重复，与第 %d 行相同", config.Name, first))
+		}
+		names[config.Name] = node.Line
+		if config.MaxLen < 0 {
+			verr.add(line(node, node.Line, "max_len"), "", fmt.Sprintf("streams: %s 的 max_len 不能为负数", config.Name))
+		}
+	}
+}
+
+// validateSymbols 检查 params.symbols（指数任务为 params.index_symbols）是非空的字符串列表且代码格式正确
+func validateSymbols(verr *ValidationError, node *yaml.Node, config JobConfig) {
+	job := config.Name
+	key := SymbolsParam(config)
+	param := "params." + key
+	symbolsLine := line(node, line(node, node.Line, "params"), "params", key)
+	raw, ok := config.Params[key]
+	if !ok {
+		verr.add(symbolsLine, job, param+" 不能为空")
+		return
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		verr.add(symbolsLine, job, param+" 必须是字符串列表")
+		return
+	}
+	if len(list) == 0 {
+		verr.add(symbolsLine, job, param+" 不能为空")
+		return
+	}
+
+	index := config.Provider.Type == "RealtimeIndex"
+	var invalid []string
+	for _, item := range list {
+		symbol, ok := item.(string)
+		if !ok {
+			verr.add(symbolsLine, job, fmt.Sprintf("%s 中的 %v 不是字符串（代码需要加引号）", param, item))
+			continue
+		}
+		if !validJobSymbol(symbol, index) {
+			invalid = append(invalid, symbol)
+		}
+	}
+	if len(invalid) > 0 {
+		verr.add(symbolsLine, job, fmt.Sprintf("%s 中的代码格式无效: %s", param, strings.Join(invalid, ", ")))
+	}
+}
+
+// validJobSymbol 代码须为 core.ParseSymbol 能识别的 A股代码（如 600000、sh600000、600000.SH）；
+// 指数任务只接受指数代码，不带市场的 000xxx 是深市股票，上证指数需写成 sh000001 或 000001.SH
+func validJobSymbol(symbol string, index bool) bool {
+	parsed, err := core.ParseSymbol(symbol)
+	if err != nil || !parsed.IsAShare() {
+		return false
+	}
+	return !index || parsed.IsIndex()
+}
+
+// add 记录一个问题，yaml 错误信息中的行号会被提取出来
+func (e *ValidationError) add(line int, job, message string) {
+	if line == 0 {
+		if m := yamlLinePattern.FindStringSubmatch(message); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			message = m[2]
+		}
+	}
+	e.Problems = append(e.Problems, ConfigProblem{Line: line, Job: job, Message: message})
+}
+
+// orNil 没有问题时返回 nil
+func (e *ValidationError) orNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// lookup 按键路径查找映射节点中的值，找不到时返回 nil
+func lookup(node *yaml.Node, keys ...string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// line 返回键路径所在的行号，找不到时返回 fallback
+func line(node *yaml.Node, fallback int, keys ...string) int {
+	if value := lookup(node, keys...); value != nil {
+		return value.Line
+	}
+	return fallback
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKnownProviders 与 fetcher 注册的提供商一致
+var testKnownProviders = KnownProviders{
+	"RealtimeStock": {"tencent", "sina", "eastmoney"},
+	"RealtimeIndex": {"sina", "eastmoney"},
+	"Historical":    {"tencent"},
+	"eod_snapshot":  {"tencent", "sina", "eastmoney"},
+}
+
+// writeJobsConfig 写入临时任务配置文件
+func writeJobsConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jobs.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// validationProblems 校验配置并返回问题列表
+func validationProblems(t *testing.T, content string) []ConfigProblem {
+	t.Helper()
+	err := ValidateConfig(writeJobsConfig(t, content), testKnownProviders)
+	require.Error(t, err)
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	return verr.Problems
+}
+
+const validJob = `jobs:
+  - name: "realtime"
+    enabled: true
+    schedule: "*/5 * 9-11,13-14 * * 1-5"
+    timeout: "30s"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+      fallbacks: ["sina"]
+    params:
+      symbols: ["600000", "sz000001", "600036.SH"]
+`
+
+func TestValidateConfig_Valid(t *testing.T) {
+	assert.NoError(t, ValidateConfig(writeJobsConfig(t, validJob), testKnownProviders))
+	assert.NoError(t, ValidateConfig(filepath.Join("..", "..", "config", "jobs.yaml"), testKnownProviders),
+		"仓库自带的任务配置应通过校验")
+}
+
+func TestValidateConfig_UnknownField(t *testing.T) {
+	problems := validationProblems(t, `jobs:
+  - name: "realtime"
+    enabled: true
+    schedle: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    params:
+      symbols: ["600000"]
+`)
+	require.NotEmpty(t, problems)
+	assert.Equal(t, 4, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "field schedle not found")
+	assert.Contains(t, problems[1].Message, "调度表达式不能为空", "拼错的字段按缺失处理")
+}
+
+func TestValidateConfig_BadCron(t *testing.T) {
+	problems := validationProblems(t, `jobs:
+  - name: "realtime"
+    enabled: true
+    schedule: "*/5 * 9-11 * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    params:
+      symbols: ["600000"]
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, ConfigProblem{Line: 4, Job: "realtime", Message: problems[0].Message}, problems[0])
+	assert.Contains(t, problems[0].Message, "无效的调度表达式 '*/5 * 9-11 * *'")
+}
+
+func TestValidateConfig_Symbols(t *testing.T) {
+	problems := validationProblems(t, `jobs:
+  - name: "empty"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    params:
+      symbols: []
+  - name: "missing"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+  - name: "bad-format"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "sina"
+      type: "RealtimeIndex"
+    params:
+      symbols: ["sh000001", "000001", 600000]
+`)
+	require.Len(t, problems, 4)
+	assert.Equal(t, ConfigProblem{Line: 9, Job: "empty", Message: "params.symbols 不能为空"}, problems[0])
+	assert.Equal(t, ConfigProblem{Line: 10, Job: "missing", Message: "params.symbols 不能为空"}, problems[1])
+	assert.Equal(t, 23, problems[2].Line)
+	assert.Contains(t, problems[2].Message, "600000 不是字符串")
+	assert.Equal(t, ConfigProblem{Line: 23, Job: "bad-format", Message: "params.symbols 中的代码格式无效: 000001"}, problems[3])
+}
+
+func TestValidateConfig_IndexSymbols(t *testing.T) {
+	problems := validationProblems(t, `jobs:
+  - name: "index"
+    enabled: true
+    schedule: "*/10 * * * * *"
+    provider:
+      name: "sina"
+      type: "RealtimeIndex"
+    params:
+      index_symbols: ["sh000001", "000001"]
+  - name: "legacy-index"
+    enabled: true
+    schedule: "*/10 * * * * *"
+    provider:
+      name: "sina"
+      type: "RealtimeIndex"
+    params:
+      symbols: ["sz399001"]
+`)
+	require.Len(t, problems, 1, "未配置 index_symbols 的指数任务兼容 symbols")
+	assert.Equal(t, ConfigProblem{Line: 9, Job: "index", Message: "params.index_symbols 中的代码格式无效: 000001"}, problems[0])
+
+	assert.Equal(t, "index_symbols", SymbolsParam(JobConfig{Provider: ProviderConfig{Type: "RealtimeIndex"}, Params: map[string]interface{}{"index_symbols": nil}}))
+	assert.Equal(t, "symbols", SymbolsParam(JobConfig{Provider: ProviderConfig{Type: "RealtimeStock"}, Params: map[string]interface{}{"index_symbols": nil}}))
+}
+
+func TestValidateConfig_UnknownProvider(t *testing.T) {
+	problems := validationProblems(t, `jobs:
+  - name: "realtime"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencnet"
+      type: "RealtimeStock"
+      fallbacks: ["yahoo"]
+    params:
+      symbols: ["600000"]
+  - name: "index"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeIndex"
+    params:
+      symbols: ["sh000001"]
+  - name: "kline"
+    enabled: true
+    schedule: "0 30 15 * * 1-5"
+    provider:
+      name: "tencent"
+      type: "Kline"
+    params:
+      symbols: ["600000"]
+`)
+	require.Len(t, problems, 4)
+	assert.Equal(t, 6, problems[0].Line)
+	assert.Contains(t, problems[0].Message, `未知的 RealtimeStock 提供商 "tencnet"`)
+	assert.Equal(t, 8, problems[1].Line)
+	assert.Contains(t, problems[1].Message, `"yahoo"`)
+	assert.Equal(t, ConfigProblem{Line: 15, Job: "index", Message: `未知的 RealtimeIndex 提供商 "tencent"（可用: sina、eastmoney）`}, problems[2])
+	assert.Equal(t, 24, problems[3].Line)
+	assert.Contains(t, problems[3].Message, `不支持的提供商类型 "Kline"`)
+
+	// 未提供注册表时不检查提供商名称
+	path := writeJobsConfig(t, `jobs:
+  - name: "realtime"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencnet"
+      type: "RealtimeStock"
+    params:
+      symbols: ["600000"]
+`)
+	assert.NoError(t, ValidateConfig(path, nil))
+}
+
+func TestValidateConfig_ReportsAllProblems(t *testing.T) {
+	path := writeJobsConfig(t, `jobs:
+  - name: "a"
+    schedule: "*/5 * * * * *"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    params:
+      symbols: ["600000"]
+  - name: "a"
+    enabled: true
+    schedule: "*/5 * * * * *"
+    overlap_policy: "drop"
+    provider:
+      name: "tencent"
+      type: "RealtimeStock"
+    params:
+      symbols: ["600000"]
+`)
+	err := ValidateConfig(path, testKnownProviders)
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Problems, 3)
+	assert.Equal(t, ConfigProblem{Line: 2, Job: "a", Message: "缺少 enabled 字段（默认 false，任务不会运行）"}, verr.Problems[0])
+	assert.Equal(t, ConfigProblem{Line: 9, Job: "a", Message: "任务名称重复，与第 2 行的任务相同"}, verr.Problems[1])
+	assert.Contains(t, verr.Problems[2].Message, "重叠策略无效")
+	assert.Contains(t, err.Error(), path+":9: 任务 'a': 任务名称重复")
+
+	assert.Error(t, ValidateConfig(filepath.Join(t.TempDir(), "missing.yaml"), nil))
+
+	problems := validationProblems(t, "jobs: [\n")
+	require.Len(t, problems, 1)
+	assert.Positive(t, problems[0].Line, "yaml 语法错误带行号")
+}
+
+func TestValidateConfig_Streams(t *testing.T) {
+	valid := validJob + `streams:
+  - name: "stream:stock:realtime"
+    max_len: 100000
+`
+	assert.NoError(t, ValidateConfig(writeJobsConfig(t, valid), testKnownProviders))
+
+	problems := validationProblems(t, validJob+`streams:
+  - name: "stream:stock:realtime"
+    max_len: -1
+  - name: "stream:stock:realtime"
+  - max_len: 10
+  - name: "stream:index:realtime"
+    maxlen: 10
+`)
+	require.Len(t, problems, 4)
+	assert.Equal(t, 18, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "maxlen", "未知字段")
+	assert.Equal(t, ConfigProblem{Line: 14, Message: "streams: stream:stock:realtime 的 max_len 不能为负数"}, problems[1])
+	assert.Equal(t, ConfigProblem{Line: 15, Message: "streams: stream:stock:realtime 重复，与第 13 行相同"}, problems[2])
+	assert.Equal(t, ConfigProblem{Line: 16, Message: "streams: 名称不能为空"}, problems[3])
+}
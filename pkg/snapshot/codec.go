@@ -0,0 +1,159 @@
+// Package snapshot 最新行情快照（<prefix>stock:<symbol>）在 Redis 中的编码，redis_collector 写入、api_server 读取共用
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+)
+
+// 快照编码名称，对应 redis_collector 的 storage.codec 配置
+const (
+	CodecHash    = "hash"    // 默认，HSET 写入字符串字段
+	CodecJSON    = "json"    // SET 写入 JSON
+	CodecMsgpack = "msgpack" // SET 写入 msgpack
+)
+
+// CodecMetaKey 相对 key_prefix 的元数据键，值为 redis_collector 当前写入快照使用的编码
+const CodecMetaKey = "meta:codec"
+
+// ErrUnknownCodec 不支持的编码名称
+var ErrUnknownCodec = errors.New("unknown snapshot codec")
+
+// Stock 编码写入的股票快照，字段与哈希格式同名；时间为 Unix 秒，盘口下标 0 为买一/卖一
+type Stock struct {
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name"`
+	Pinyin        string  `json:"pinyin,omitempty"`
+	Price         float64 `json:"price"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent"`
+	Volume        int64   `json:"volume"`
+	Turnover      float64 `json:"turnover"`
+	Timestamp     int64   `json:"timestamp"`
+	Provider      string  `json:"provider"`
+	Market        string  `json:"market"`
+	TraceID       string  `json:"trace_id,omitempty"`
+	UpdatedAt     int64   `json:"updated_at"`
+
+	BidPrices  []float64 `json:"bid_prices,omitempty"`
+	BidVolumes []int64   `json:"bid_volumes,omitempty"`
+	AskPrices  []float64 `json:"ask_prices,omitempty"`
+	AskVolumes []int64   `json:"ask_volumes,omitempty"`
+}
+
+// Codec 快照的二进制编码
+type Codec interface {
+	Name() string
+	Encode(stock *Stock) ([]byte, error)
+	Decode(data []byte, stock *Stock) error
+}
+
+// Lookup 返回编码名称对应的 Codec；CodecHash 和空字符串返回 nil，表示使用哈希格式
+func Lookup(name string) (Codec, error) {
+	switch name {
+	case "", CodecHash:
+		return nil, nil
+	case CodecJSON:
+		return jsonCodec{}, nil
+	case CodecMsgpack:
+		return msgpackCodec{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+	}
+}
+
+// Decode 按内容识别编码并解码：以 { 开头为 JSON，其余按 msgpack 解码
+func Decode(data []byte) (*Stock, error) {
+	var c Codec = msgpackCodec{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		c = jsonCodec{}
+	}
+	var stock Stock
+	if err := c.Decode(data, &stock); err != nil {
+		return nil, fmt.Errorf("failed to decode %s snapshot: %w", c.Name(), err)
+	}
+	return &stock, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecJSON }
+
+func (jsonCodec) Encode(stock *Stock) ([]byte, error) {
+	return json.Marshal(stock)
+}
+
+func (jsonCodec) Decode(data []byte, stock *Stock) error {
+	return json.Unmarshal(data, stock)
+}
+
+// msgpackHandle 按 json 标签编码字段名，配置后只读，可被多个 goroutine 共用
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	return h
+}()
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return CodecMsgpack }
+
+func (msgpackCodec) Encode(stock *Stock) ([]byte, error) {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, msgpackHandle).Encode(stock); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (msgpackCodec) Decode(data []byte, stock *Stock) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(stock)
+}
+
+// IsWrongType 判断 Redis 错误是否为键类型不符（WRONGTYPE），过渡期内同一前缀下哈希和编码格式的键并存
+func IsWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
+
+// Field 返回哈希格式中同名字段的字符串值，用于只读取部分字段的场景；盘口和未知字段返回 false
+func (s *Stock) Field(name string) (string, bool) {
+	switch name {
+	case "symbol":
+		return s.Symbol, true
+	case "name":
+		return s.Name, true
+	case "pinyin":
+		return s.Pinyin, true
+	case "price":
+		return formatFloat(s.Price), true
+	case "change":
+		return formatFloat(s.Change), true
+	case "change_percent":
+		return formatFloat(s.ChangePercent), true
+	case "volume":
+		return strconv.FormatInt(s.Volume, 10), true
+	case "turnover":
+		return formatFloat(s.Turnover), true
+	case "timestamp":
+		return strconv.FormatInt(s.Timestamp, 10), true
+	case "provider":
+		return s.Provider, true
+	case "market":
+		return s.Market, true
+	case "trace_id":
+		return s.TraceID, true
+	case "updated_at":
+		return strconv.FormatInt(s.UpdatedAt, 10), true
+	}
+	return "", false
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
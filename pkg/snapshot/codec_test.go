@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStock() *Stock {
+	return &Stock{
+		Symbol: "600000.SH", Name: "浦发银行", Pinyin: "pfyh", Price: 10.5, Change: 0.15, ChangePercent: 1.45,
+		Volume: 1250000, Turnover: 13125000, Timestamp: 1755655200, Provider: "tencent", Market: "A-share",
+		TraceID: "trace-1", UpdatedAt: 1755655203,
+		BidPrices: []float64{10.49, 10.48, 10.47, 10.46, 10.45}, BidVolumes: []int64{100, 200, 300, 400, 500},
+		AskPrices: []float64{10.5, 10.51, 10.52, 10.53, 10.54}, AskVolumes: []int64{600, 700, 800, 900, 1000},
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	for _, name := range []string{CodecJSON, CodecMsgpack} {
+		t.Run(name, func(t *testing.T) {
+			c, err := Lookup(name)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+			assert.Equal(t, name, c.Name())
+
+			data, err := c.Encode(testStock())
+			require.NoError(t, err)
+
+			// Decode 按内容识别编码
+			decoded, err := Decode(data)
+			require.NoError(t, err)
+			assert.Equal(t, testStock(), decoded)
+		})
+	}
+}
+
+func TestCodecs_OmitsEmptyDepth(t *testing.T) {
+	stock := testStock()
+	stock.BidPrices, stock.BidVolumes, stock.AskPrices, stock.AskVolumes = nil, nil, nil, nil
+
+	c, err := Lookup(CodecMsgpack)
+	require.NoError(t, err)
+	data, err := c.Encode(stock)
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Nil(t, decoded.BidPrices)
+	assert.Equal(t, stock, decoded)
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"", CodecHash} {
+		c, err := Lookup(name)
+		require.NoError(t, err)
+		assert.Nil(t, c, "哈希格式没有 Codec")
+	}
+
+	_, err := Lookup("protobuf")
+	assert.ErrorIs(t, err, ErrUnknownCodec)
+}
+
+func TestDecode_InvalidData(t *testing.T) {
+	_, err := Decode([]byte("{not json"))
+	assert.ErrorContains(t, err, "json")
+
+	_, err = Decode([]byte{0xc1})
+	assert.ErrorContains(t, err, "msgpack")
+}
+
+func TestStock_Field(t *testing.T) {
+	stock := testStock()
+	for field, want := range map[string]string{
+		"symbol": "600000.SH", "price": "10.5", "change_percent": "1.45", "volume": "1250000",
+		"turnover": "13125000", "timestamp": "1755655200", "trace_id": "trace-1", "updated_at": "1755655203",
+	} {
+		got, ok := stock.Field(field)
+		assert.True(t, ok, field)
+		assert.Equal(t, want, got, field)
+	}
+
+	_, ok := stock.Field("bid_price1")
+	assert.False(t, ok)
+}
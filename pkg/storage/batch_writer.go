@@ -15,22 +15,50 @@ type BatchWriter struct {
 	bufferMu    sync.Mutex
 	flushTicker *time.Ticker
 	stopChan    chan struct{}
+	flushCh     chan struct{}  // 异步模式下通知后台刷新协程
+	wg          sync.WaitGroup // 等待后台刷新协程退出
 	config      BatchWriterConfig
 	stats       BatchWriterStats
 	// StructuredData 优化相关字段
 	structuredDataBuffer map[string][]*StructuredData // 按 schema 名称分组的缓存
 	lastSchemaFlush      map[string]time.Time         // 每个 schema 的上次刷新时间
+	// 预写日志相关字段，仅在调用 EnableWAL 后使用
+	journal            *writeJournal
+	walPending         []journalEntry      // 打开日志时发现、尚未由 RecoverFromWAL 重放的记录
+	bufferSeqs         []uint64            // 与 buffer 一一对应的日志序号
+	structuredDataSeqs map[string][]uint64 // 与 structuredDataBuffer 一一对应的日志序号
+	journalPending     map[uint64]struct{} // 已写入日志但尚未确认的序号
 }
 
+// OverflowPolicy 定义了缓冲区达到 MaxBufferSize 后新记录的处理方式。
+type OverflowPolicy string
+
+const (
+	// OverflowBlock 阻塞写入调用，同步刷新缓冲区腾出空间，是默认策略。
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropOldest 丢弃缓冲区中最旧的记录。
+	OverflowDropOldest OverflowPolicy = "drop_oldest"
+	// OverflowDropNewest 丢弃新写入的记录。
+	OverflowDropNewest OverflowPolicy = "drop_newest"
+)
+
+// FlushErrorHandler 在批量写入存储失败时被调用，records 为写入失败的记录。
+// 返回 nil 表示记录已由调用方处理（例如写入备用存储），预写日志中不再保留这些记录。
+// 回调在持有缓冲区锁时执行，不能再调用同一个 BatchWriter 的方法。
+type FlushErrorHandler func(ctx context.Context, records []interface{}, err error) error
+
 // BatchWriterConfig 定义了 BatchWriter 的配置选项。
 type BatchWriterConfig struct {
-	BatchSize                 int           `yaml:"batch_size"`                   // 触发批量写入的批次大小。
-	FlushInterval             time.Duration `yaml:"flush_interval"`               // 定期将缓冲区数据写入存储的时间间隔。
-	MaxBufferSize             int           `yaml:"max_buffer_size"`              // 缓冲区中可容纳的最大记录数，防止内存无限增长。
-	EnableAsync               bool          `yaml:"enable_async"`                 // 是否启用异步写入。如果为true，批量写入将在独立的goroutine中执行。
-	EnableStructuredDataOptim bool          `yaml:"enable_structured_data_optim"` // 是否启用 StructuredData 优化
-	StructuredDataBatchSize   int           `yaml:"structured_data_batch_size"`   // StructuredData 的特别批次大小
-	StructuredDataFlushDelay  time.Duration `yaml:"structured_data_flush_delay"`  // StructuredData 刷新延迟（用于合并同类型数据）
+	BatchSize                 int               `yaml:"batch_size"`                   // 触发批量写入的批次大小。
+	FlushInterval             time.Duration     `yaml:"flush_interval"`               // 定期将缓冲区数据写入存储的时间间隔。
+	MaxBufferSize             int               `yaml:"max_buffer_size"`              // 缓冲区中可容纳的最大记录数，防止内存无限增长。
+	OverflowPolicy            OverflowPolicy    `yaml:"overflow_policy"`              // 缓冲区满时的处理策略：block、drop_oldest、drop_newest，为空时使用 block。
+	OnFlushError              FlushErrorHandler `yaml:"-"`                            // 刷新失败时的回调，可将失败的批次转存到备用存储。
+	EnableAsync               bool              `yaml:"enable_async"`                 // 是否启用异步写入。如果为true，批量写入将在独立的goroutine中执行。
+	EnableStructuredDataOptim bool              `yaml:"enable_structured_data_optim"` // 是否启用 StructuredData 优化
+	StructuredDataBatchSize   int               `yaml:"structured_data_batch_size"`   // StructuredData 的特别批次大小
+	StructuredDataFlushDelay  time.Duration     `yaml:"structured_data_flush_delay"`  // StructuredData 刷新延迟（用于合并同类型数据）
+	Journal                   JournalConfig     `yaml:"journal"`                      // 预写日志配置，供 NewBatchWriterWithJournal 使用
 }
 
 // BatchWriterStats 包含了 BatchWriter 的运行统计信息。
@@ -40,11 +68,19 @@ type BatchWriterStats struct {
 	BufferSize               int       `json:"buffer_size"`                 // 当前缓冲区中的记录数。
 	LastFlush                time.Time `json:"last_flush"`                  // 最后一次成功刷新的时间。
 	FlushErrors              int64     `json:"flush_errors"`                // 刷新（写入）失败的次数。
-	BufferOverflows          int64     `json:"buffer_overflows"`            // 因缓冲区满而导致强制刷新的次数。
+	BufferOverflows          int64     `json:"buffer_overflows"`            // 写入时缓冲区已满的次数。
+	DroppedOldest            int64     `json:"dropped_oldest"`              // 按 drop_oldest 策略丢弃的记录数。
+	DroppedNewest            int64     `json:"dropped_newest"`              // 按 drop_newest 策略丢弃的记录数。
+	FailedRecords            int64     `json:"failed_records"`              // 写入存储失败的记录数。
+	HandledRecords           int64     `json:"handled_records"`             // 写入失败后由 OnFlushError 成功处理的记录数。
 	StructuredDataBatches    int64     `json:"structured_data_batches"`     // StructuredData 的批次数
 	StructuredDataRecords    int64     `json:"structured_data_records"`     // StructuredData 的记录数
 	StructuredDataBufferSize int       `json:"structured_data_buffer_size"` // StructuredData 缓冲区大小
 	StructuredDataFlushes    int64     `json:"structured_data_flushes"`     // StructuredData 专用刷新次数
+	JournalRecovered         int64     `json:"journal_recovered"`           // 启动时从预写日志恢复的记录数
+	JournalRejected          int64     `json:"journal_rejected"`            // 因预写日志已满被拒绝的记录数
+	JournalDiscarded         int64     `json:"journal_discarded"`           // 启动时因写入不完整或损坏而丢弃的日志尾部字节数
+	JournalSize              int64     `json:"journal_size"`                // 当前预写日志文件大小（字节）
 }
 
 // NewBatchWriter 创建一个新的 BatchWriter 实例。
@@ -57,19 +93,159 @@ func NewBatchWriter(storage Storage, config BatchWriterConfig) *BatchWriter {
 		stats:                BatchWriterStats{},
 		structuredDataBuffer: make(map[string][]*StructuredData),
 		lastSchemaFlush:      make(map[string]time.Time),
+		structuredDataSeqs:   make(map[string][]uint64),
+	}
+	bw.start()
+
+	return bw
+}
+
+// NewBatchWriterWithJournal 创建一个启用预写日志的 BatchWriter 实例，
+// 等价于 NewBatchWriter 后依次调用 EnableWAL(config.Journal) 和 RecoverFromWAL(ctx)。
+func NewBatchWriterWithJournal(ctx context.Context, storage Storage, config BatchWriterConfig) (*BatchWriter, error) {
+	bw := NewBatchWriter(storage, config)
+	if err := bw.EnableWAL(config.Journal); err != nil {
+		bw.Close()
+		return nil, err
+	}
+	if _, err := bw.RecoverFromWAL(ctx); err != nil {
+		bw.Close()
+		return nil, err
+	}
+	return bw, nil
+}
+
+// EnableWAL 为 BatchWriter 打开预写日志，必须在第一次 Write 之前调用。
+// 之后每条被接受的记录会先追加到 config.Path 指定的日志文件再进入缓冲区，刷新成功后确认，
+// 因此进程崩溃后未刷新的记录不会丢失。全部确认后日志被清空，否则按 CompactThreshold 压缩重写。
+// 日志中已有的未确认记录不会自动写入存储，需要调用 RecoverFromWAL；
+// 因写入不完整或损坏而跳过尾部时输出包含日志路径、偏移和字节数的警告，字节数同时记录在 GetStats().JournalDiscarded 中。
+func (bw *BatchWriter) EnableWAL(config JournalConfig) error {
+	bw.bufferMu.Lock()
+	defer bw.bufferMu.Unlock()
+
+	if bw.journal != nil {
+		return fmt.Errorf("write-ahead journal already enabled")
+	}
+	if len(bw.buffer) > 0 || bw.structuredDataBufferSize() > 0 {
+		return fmt.Errorf("write-ahead journal must be enabled before the first write")
+	}
+
+	journal, pending, err := openWriteJournal(config)
+	if err != nil {
+		return err
+	}
+
+	bw.journal = journal
+	bw.walPending = pending
+	bw.journalPending = make(map[uint64]struct{})
+	for _, entry := range pending {
+		bw.journalPending[entry.seq] = struct{}{}
+	}
+	bw.config.Journal = config
+	bw.stats.JournalDiscarded = journal.discarded
+	return nil
+}
+
+// RecoverFromWAL 将 EnableWAL 时日志中尚未确认的记录重放到存储后端，返回恢复的记录数。
+// 应在恢复正常写入前调用；重放期间 Write 会被阻塞。中途失败时已写入的记录
+// 已确认，再次调用只会重放剩余记录，因此每条记录恰好恢复一次。
+// 恢复的记录数累计在 GetStats().JournalRecovered 中。
+func (bw *BatchWriter) RecoverFromWAL(ctx context.Context) (int, error) {
+	bw.bufferMu.Lock()
+	defer bw.bufferMu.Unlock()
+
+	if bw.journal == nil {
+		return 0, fmt.Errorf("write-ahead journal is not enabled")
 	}
 
-	if config.FlushInterval > 0 {
-		bw.flushTicker = time.NewTicker(config.FlushInterval)
+	recovered, err := bw.replayJournal(ctx)
+	bw.stats.JournalRecovered += int64(recovered)
+	if err != nil {
+		return recovered, fmt.Errorf("failed to replay journal: %w", err)
+	}
+
+	if len(bw.journalPending) == 0 {
+		if err := bw.journal.Reset(); err != nil {
+			return recovered, err
+		}
+	}
+	return recovered, nil
+}
+
+// start 启动定期刷新和异步刷新的后台协程。
+func (bw *BatchWriter) start() {
+	if bw.config.FlushInterval > 0 {
+		bw.flushTicker = time.NewTicker(bw.config.FlushInterval)
 		go bw.startPeriodicFlush()
 	}
+	if bw.config.EnableAsync {
+		bw.flushCh = make(chan struct{}, 1)
+		bw.wg.Add(1)
+		go bw.runAsyncFlusher()
+	}
+}
 
-	return bw
+// replayJournal 将 walPending 中的记录写入存储并确认，返回恢复的记录数（需要在锁内调用）。
+func (bw *BatchWriter) replayJournal(ctx context.Context) (int, error) {
+	pending := bw.walPending
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	if batchSaver, ok := bw.storage.(interface {
+		BatchSave(context.Context, []interface{}) error
+	}); ok {
+		records := make([]interface{}, len(pending))
+		seqs := make([]uint64, len(pending))
+		for i, entry := range pending {
+			records[i] = entry.record
+			seqs[i] = entry.seq
+		}
+		if err := batchSaver.BatchSave(ctx, records); err != nil {
+			return 0, err
+		}
+		if err := bw.confirmReplayed(seqs...); err != nil {
+			return 0, err
+		}
+		bw.walPending = nil
+		return len(pending), nil
+	}
+
+	for i, entry := range pending {
+		if err := bw.storage.Save(ctx, entry.record); err != nil {
+			bw.walPending = pending[i:]
+			return i, err
+		}
+		// 逐条确认，避免重放中途失败后再次启动时产生重复
+		if err := bw.confirmReplayed(entry.seq); err != nil {
+			bw.walPending = pending[i+1:]
+			return i + 1, err
+		}
+	}
+	bw.walPending = nil
+	return len(pending), nil
+}
+
+// confirmReplayed 确认已重放的记录（需要在锁内调用）。
+func (bw *BatchWriter) confirmReplayed(seqs ...uint64) error {
+	if err := bw.journal.Ack(seqs...); err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		delete(bw.journalPending, seq)
+	}
+	return nil
 }
 
 // Write 将一条数据记录添加到写入缓冲区。
 // 当缓冲区大小达到 BatchSize 时，它会触发一次批量写入操作。
+// 可修改的 *StructuredData 会先生成快照（Freeze），调用方返回后可以继续修改原实例。
 func (bw *BatchWriter) Write(ctx context.Context, data interface{}) error {
+	if sd, ok := data.(*StructuredData); ok {
+		data = sd.Freeze()
+	}
+
 	bw.bufferMu.Lock()
 	defer bw.bufferMu.Unlock()
 
@@ -96,7 +272,14 @@ func (bw *BatchWriter) writeStructuredData(ctx context.Context, data *Structured
 		bw.lastSchemaFlush[schemaName] = time.Now()
 	}
 
+	seq, err := bw.appendJournal(data)
+	if err != nil {
+		return err
+	}
 	bw.structuredDataBuffer[schemaName] = append(bw.structuredDataBuffer[schemaName], data)
+	if bw.journal != nil {
+		bw.structuredDataSeqs[schemaName] = append(bw.structuredDataSeqs[schemaName], seq)
+	}
 
 	// 检查是否需要刷新该 schema 的数据
 	schemaBuffer := bw.structuredDataBuffer[schemaName]
@@ -112,20 +295,35 @@ func (bw *BatchWriter) writeStructuredData(ctx context.Context, data *Structured
 	return nil
 }
 
-// writeRegularData 处理常规数据
+// writeRegularData 处理常规数据，缓冲区已满时按 OverflowPolicy 处理
 func (bw *BatchWriter) writeRegularData(ctx context.Context, data interface{}) error {
 	if len(bw.buffer) >= bw.config.MaxBufferSize {
 		bw.stats.BufferOverflows++
-		if err := bw.flushBuffer(ctx); err != nil {
-			return fmt.Errorf("强制刷新缓冲区失败: %w", err)
+		switch bw.config.OverflowPolicy {
+		case OverflowDropNewest:
+			bw.stats.DroppedNewest++
+			return nil
+		case OverflowDropOldest:
+			bw.dropOldest()
+		default:
+			if err := bw.flushBuffer(ctx); err != nil {
+				return fmt.Errorf("强制刷新缓冲区失败: %w", err)
+			}
 		}
 	}
 
+	seq, err := bw.appendJournal(data)
+	if err != nil {
+		return err
+	}
 	bw.buffer = append(bw.buffer, data)
+	if bw.journal != nil {
+		bw.bufferSeqs = append(bw.bufferSeqs, seq)
+	}
 
 	if len(bw.buffer) >= bw.config.BatchSize {
 		if bw.config.EnableAsync {
-			go bw.asyncFlush(context.Background()) // 使用后台context
+			bw.signalFlush()
 		} else {
 			return bw.flushBuffer(ctx)
 		}
@@ -168,6 +366,8 @@ func (bw *BatchWriter) flushStructuredDataSchema(ctx context.Context, schemaName
 	copy(dataToFlush, schemaBuffer)
 	bw.structuredDataBuffer[schemaName] = bw.structuredDataBuffer[schemaName][:0]
 	bw.lastSchemaFlush[schemaName] = time.Now()
+	seqs := bw.structuredDataSeqs[schemaName]
+	bw.structuredDataSeqs[schemaName] = nil
 
 	// 转换为 interface{} 类型的切片
 	interfaceData := make([]interface{}, len(dataToFlush))
@@ -176,28 +376,30 @@ func (bw *BatchWriter) flushStructuredDataSchema(ctx context.Context, schemaName
 	}
 
 	// 尝试使用 BatchSave
+	written := len(interfaceData)
 	if batchSaver, ok := bw.storage.(interface {
 		BatchSave(context.Context, []interface{}) error
 	}); ok {
 		if err := batchSaver.BatchSave(ctx, interfaceData); err != nil {
 			bw.stats.FlushErrors++
-			return err
+			return bw.handleFlushError(ctx, interfaceData, seqs, err)
 		}
+		bw.ackJournal(seqs...)
 	} else {
 		// 回退到逐个保存
-		for _, data := range dataToFlush {
-			if err := bw.storage.Save(ctx, data); err != nil {
-				bw.stats.FlushErrors++
-				fmt.Printf("BatchWriter StructuredData save error: %v\n", err)
-			}
+		failed, failedSeqs, lastErr := bw.saveEach(ctx, interfaceData, seqs)
+		if len(failed) > 0 {
+			fmt.Printf("BatchWriter StructuredData save error: %v\n", lastErr)
+			bw.handleFlushError(ctx, failed, failedSeqs, lastErr)
 		}
+		written -= len(failed)
 	}
 
 	// 更新统计
 	bw.stats.StructuredDataBatches++
-	bw.stats.StructuredDataRecords += int64(len(dataToFlush))
+	bw.stats.StructuredDataRecords += int64(written)
 	bw.stats.StructuredDataFlushes++
-	bw.stats.TotalRecords += int64(len(dataToFlush))
+	bw.stats.TotalRecords += int64(written)
 	bw.stats.LastFlush = time.Now()
 
 	return nil
@@ -212,37 +414,142 @@ func (bw *BatchWriter) flushBuffer(ctx context.Context) error {
 	dataToFlush := make([]interface{}, len(bw.buffer))
 	copy(dataToFlush, bw.buffer)
 	bw.buffer = bw.buffer[:0]
+	seqs := bw.bufferSeqs
+	bw.bufferSeqs = nil
 
+	written := len(dataToFlush)
 	if batchSaver, ok := bw.storage.(interface {
 		BatchSave(context.Context, []interface{}) error
 	}); ok {
 		if err := batchSaver.BatchSave(ctx, dataToFlush); err != nil {
 			bw.stats.FlushErrors++
-			return err
+			return bw.handleFlushError(ctx, dataToFlush, seqs, err)
 		}
+		bw.ackJournal(seqs...)
 	} else {
-		for _, item := range dataToFlush {
-			if err := bw.storage.Save(ctx, item); err != nil {
-				bw.stats.FlushErrors++
-				fmt.Printf("BatchWriter fallback save error: %v\n", err)
-			}
+		failed, failedSeqs, lastErr := bw.saveEach(ctx, dataToFlush, seqs)
+		if len(failed) > 0 {
+			fmt.Printf("BatchWriter fallback save error: %v\n", lastErr)
+			bw.handleFlushError(ctx, failed, failedSeqs, lastErr)
 		}
+		written -= len(failed)
 	}
 
 	bw.stats.TotalBatches++
-	bw.stats.TotalRecords += int64(len(dataToFlush))
+	bw.stats.TotalRecords += int64(written)
 	bw.stats.LastFlush = time.Now()
 
 	return nil
 }
 
-// asyncFlush 异步执行刷新操作。
-func (bw *BatchWriter) asyncFlush(ctx context.Context) {
-	go func() {
-		bw.bufferMu.Lock()
-		defer bw.bufferMu.Unlock()
-		bw.flushBuffer(ctx)
-	}()
+// saveEach 逐条保存记录并确认日志（需要在锁内调用），返回保存失败的记录、对应的日志序号和最后一个错误。
+func (bw *BatchWriter) saveEach(ctx context.Context, records []interface{}, seqs []uint64) ([]interface{}, []uint64, error) {
+	var failed []interface{}
+	var failedSeqs []uint64
+	var lastErr error
+	for i, item := range records {
+		if err := bw.storage.Save(ctx, item); err != nil {
+			bw.stats.FlushErrors++
+			failed = append(failed, item)
+			if bw.journal != nil {
+				failedSeqs = append(failedSeqs, seqs[i])
+			}
+			lastErr = err
+			continue
+		}
+		if bw.journal != nil {
+			bw.ackJournal(seqs[i])
+		}
+	}
+	return failed, failedSeqs, lastErr
+}
+
+// handleFlushError 将写入失败的记录交给 OnFlushError 处理（需要在锁内调用）。
+// 未配置回调或回调失败时返回原始错误，记录在预写日志中保持未确认状态。
+func (bw *BatchWriter) handleFlushError(ctx context.Context, records []interface{}, seqs []uint64, err error) error {
+	bw.stats.FailedRecords += int64(len(records))
+	if bw.config.OnFlushError == nil {
+		return err
+	}
+	if handlerErr := bw.config.OnFlushError(ctx, records, err); handlerErr != nil {
+		return fmt.Errorf("%w (flush error handler: %v)", err, handlerErr)
+	}
+	bw.stats.HandledRecords += int64(len(records))
+	bw.ackJournal(seqs...)
+	return nil
+}
+
+// dropOldest 丢弃缓冲区中最旧的一条记录（需要在锁内调用），被丢弃的记录不再保留在预写日志中。
+func (bw *BatchWriter) dropOldest() {
+	bw.buffer = append(bw.buffer[:0], bw.buffer[1:]...)
+	if bw.journal != nil {
+		seq := bw.bufferSeqs[0]
+		bw.bufferSeqs = append(bw.bufferSeqs[:0], bw.bufferSeqs[1:]...)
+		bw.ackJournal(seq)
+	}
+	bw.stats.DroppedOldest++
+}
+
+// appendJournal 在记录进入缓冲区前将其追加到预写日志（需要在锁内调用）。
+func (bw *BatchWriter) appendJournal(data interface{}) (uint64, error) {
+	if bw.journal == nil {
+		return 0, nil
+	}
+
+	seq, err := bw.journal.Append(data)
+	if err != nil {
+		if err == ErrJournalFull {
+			bw.stats.JournalRejected++
+		}
+		return 0, err
+	}
+	bw.journalPending[seq] = struct{}{}
+	return seq, nil
+}
+
+// ackJournal 确认记录已写入存储，所有记录都确认后清空日志（需要在锁内调用）。
+// 写入失败的记录保持未确认状态，下次启动时会被重放；期间日志会按 CompactThreshold 自行压缩。
+func (bw *BatchWriter) ackJournal(seqs ...uint64) {
+	if bw.journal == nil || len(seqs) == 0 {
+		return
+	}
+
+	if err := bw.journal.Ack(seqs...); err != nil {
+		fmt.Printf("BatchWriter journal ack error: %v\n", err)
+		return
+	}
+	for _, seq := range seqs {
+		delete(bw.journalPending, seq)
+	}
+
+	if len(bw.journalPending) == 0 {
+		if err := bw.journal.Reset(); err != nil {
+			fmt.Printf("BatchWriter journal reset error: %v\n", err)
+		}
+	}
+}
+
+// signalFlush 通知后台刷新协程，已有未处理的通知时直接返回。
+func (bw *BatchWriter) signalFlush() {
+	select {
+	case bw.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+// runAsyncFlusher 是异步模式下唯一的刷新协程，达到批次大小时不会为每次写入创建新的 goroutine。
+func (bw *BatchWriter) runAsyncFlusher() {
+	defer bw.wg.Done()
+	for {
+		select {
+		case <-bw.flushCh:
+			bw.bufferMu.Lock()
+			bw.flushBuffer(context.Background())
+			bw.bufferMu.Unlock()
+		case <-bw.stopChan:
+			return
+		}
+	}
 }
 
 // startPeriodicFlush 启动一个 goroutine，按固定的时间间隔刷新缓冲区。
@@ -263,8 +570,15 @@ func (bw *BatchWriter) Close() error {
 		bw.flushTicker.Stop()
 	}
 	close(bw.stopChan)
+	bw.wg.Wait()
 
-	return bw.Flush()
+	err := bw.Flush()
+	if bw.journal != nil {
+		if closeErr := bw.journal.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
 // GetStats 返回当前的运行统计信息。
@@ -274,25 +588,34 @@ func (bw *BatchWriter) GetStats() BatchWriterStats {
 
 	stats := bw.stats
 	stats.BufferSize = len(bw.buffer)
+	if bw.journal != nil {
+		stats.JournalSize = bw.journal.Size()
+	}
 
 	// 计算 StructuredData 缓冲区大小
 	if bw.config.EnableStructuredDataOptim {
-		structuredDataBufferSize := 0
-		for _, schemaBuffer := range bw.structuredDataBuffer {
-			structuredDataBufferSize += len(schemaBuffer)
-		}
-		stats.StructuredDataBufferSize = structuredDataBufferSize
+		stats.StructuredDataBufferSize = bw.structuredDataBufferSize()
 	}
 
 	return stats
 }
 
+// structuredDataBufferSize 返回各 schema 缓冲区中的记录总数（需要在锁内调用）。
+func (bw *BatchWriter) structuredDataBufferSize() int {
+	size := 0
+	for _, schemaBuffer := range bw.structuredDataBuffer {
+		size += len(schemaBuffer)
+	}
+	return size
+}
+
 // DefaultBatchWriterConfig 返回一个默认的 BatchWriter 配置实例。
 func DefaultBatchWriterConfig() BatchWriterConfig {
 	return BatchWriterConfig{
 		BatchSize:                 100,
 		FlushInterval:             5 * time.Second,
 		MaxBufferSize:             1000,
+		OverflowPolicy:            OverflowBlock,
 		EnableAsync:               true,
 		EnableStructuredDataOptim: true,
 		StructuredDataBatchSize:   50,              // 更小的批次大小用于更频繁的刷新
@@ -306,6 +629,7 @@ func OptimizedBatchWriterConfig() BatchWriterConfig {
 		BatchSize:                 200,
 		FlushInterval:             3 * time.Second,
 		MaxBufferSize:             2000,
+		OverflowPolicy:            OverflowBlock,
 		EnableAsync:               true,
 		EnableStructuredDataOptim: true,
 		StructuredDataBatchSize:   100,
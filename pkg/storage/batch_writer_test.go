@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// slowBatchStorage 记录批量写入的数据，每批写入前等待 delay，failWith 非空时写入失败
+type slowBatchStorage struct {
+	mu       sync.Mutex
+	saved    []string
+	delay    time.Duration
+	failWith error
+}
+
+func (s *slowBatchStorage) Save(ctx context.Context, data interface{}) error {
+	return s.BatchSave(ctx, []interface{}{data})
+}
+
+func (s *slowBatchStorage) BatchSave(ctx context.Context, dataList []interface{}) error {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failWith != nil {
+		return s.failWith
+	}
+	for _, data := range dataList {
+		s.saved = append(s.saved, data.(core.StockData).Symbol)
+	}
+	return nil
+}
+
+func (s *slowBatchStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (s *slowBatchStorage) Delete(ctx context.Context, query core.Query) error { return nil }
+
+func (s *slowBatchStorage) Close() error { return nil }
+
+func (s *slowBatchStorage) symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.saved...)
+}
+
+func TestBatchWriter_OverflowPolicies_CountsBalance(t *testing.T) {
+	const maxBuffer, producers = 20, 8
+	const produced = maxBuffer * 10
+
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowDropNewest} {
+		t.Run(string(policy), func(t *testing.T) {
+			backend := &slowBatchStorage{delay: 2 * time.Millisecond}
+			bw := NewBatchWriter(backend, BatchWriterConfig{
+				BatchSize:      maxBuffer / 2,
+				MaxBufferSize:  maxBuffer,
+				EnableAsync:    true,
+				OverflowPolicy: policy,
+			})
+
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func(p int) {
+					defer wg.Done()
+					for i := 0; i < produced/producers; i++ {
+						assert.NoError(t, bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("%d-%d", p, i)}))
+						assert.LessOrEqual(t, bw.GetStats().BufferSize, maxBuffer)
+					}
+				}(p)
+			}
+			wg.Wait()
+			require.NoError(t, bw.Close())
+
+			stats := bw.GetStats()
+			written := len(backend.symbols())
+			dropped := stats.DroppedOldest + stats.DroppedNewest
+			assert.Equal(t, int64(produced), int64(written)+dropped)
+			assert.Equal(t, int64(written), stats.TotalRecords)
+
+			switch policy {
+			case OverflowBlock:
+				assert.Zero(t, dropped)
+			case OverflowDropOldest:
+				assert.Positive(t, stats.DroppedOldest)
+				assert.Zero(t, stats.DroppedNewest)
+			case OverflowDropNewest:
+				assert.Positive(t, stats.DroppedNewest)
+				assert.Zero(t, stats.DroppedOldest)
+			}
+		})
+	}
+}
+
+func TestBatchWriter_OverflowPolicies_KeepExpectedRecords(t *testing.T) {
+	write := func(t *testing.T, policy OverflowPolicy) (*slowBatchStorage, BatchWriterStats) {
+		backend := &slowBatchStorage{}
+		// 批次大小大于缓冲区上限，只有溢出策略会生效
+		bw := NewBatchWriter(backend, BatchWriterConfig{BatchSize: 100, MaxBufferSize: 5, OverflowPolicy: policy})
+		for i := 0; i < 12; i++ {
+			require.NoError(t, bw.Write(context.Background(), core.StockData{Symbol: fmt.Sprintf("%02d", i)}))
+		}
+		require.NoError(t, bw.Close())
+		return backend, bw.GetStats()
+	}
+
+	backend, stats := write(t, OverflowDropOldest)
+	assert.Equal(t, []string{"07", "08", "09", "10", "11"}, backend.symbols())
+	assert.Equal(t, int64(7), stats.DroppedOldest)
+
+	backend, stats = write(t, OverflowDropNewest)
+	assert.Equal(t, []string{"00", "01", "02", "03", "04"}, backend.symbols())
+	assert.Equal(t, int64(7), stats.DroppedNewest)
+
+	backend, stats = write(t, "")
+	assert.Len(t, backend.symbols(), 12, "默认阻塞策略同步刷新，不丢弃数据")
+	assert.Equal(t, int64(2), stats.BufferOverflows)
+}
+
+func TestBatchWriter_OnFlushError_RoutesToFallback(t *testing.T) {
+	ctx := context.Background()
+	errBackend := errors.New("backend unavailable")
+	backend := &slowBatchStorage{failWith: errBackend}
+	fallback := &slowBatchStorage{}
+
+	var handled [][]interface{}
+	bw := NewBatchWriter(backend, BatchWriterConfig{
+		BatchSize:     3,
+		MaxBufferSize: 10,
+		OnFlushError: func(ctx context.Context, records []interface{}, err error) error {
+			assert.ErrorIs(t, err, errBackend)
+			handled = append(handled, records)
+			return fallback.BatchSave(ctx, records)
+		},
+	})
+
+	for i := 0; i < 7; i++ {
+		require.NoError(t, bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("60000%d", i)}))
+	}
+	require.NoError(t, bw.Flush())
+
+	assert.Len(t, handled, 3)
+	assert.Equal(t, []string{"600000", "600001", "600002", "600003", "600004", "600005", "600006"}, fallback.symbols())
+	stats := bw.GetStats()
+	assert.Equal(t, int64(3), stats.FlushErrors)
+	assert.Equal(t, int64(7), stats.FailedRecords)
+	assert.Equal(t, int64(7), stats.HandledRecords)
+	assert.Zero(t, stats.TotalRecords)
+
+	// 备用存储也失败时返回原始错误
+	fallback.failWith = errors.New("fallback unavailable")
+	require.NoError(t, bw.Write(ctx, core.StockData{Symbol: "600007"}))
+	err := bw.Flush()
+	assert.ErrorIs(t, err, errBackend)
+	assert.ErrorContains(t, err, "fallback unavailable")
+	require.NoError(t, bw.Close())
+}
+
+func TestBatchWriter_OnFlushError_AcksJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	ctx := context.Background()
+	backend := &slowBatchStorage{failWith: errors.New("backend unavailable")}
+	fallback := NewMemoryStorage(DefaultMemoryStorageConfig())
+	defer fallback.Close()
+
+	config := journalTestConfig(path)
+	config.OnFlushError = func(ctx context.Context, records []interface{}, err error) error {
+		return fallback.BatchSave(ctx, records)
+	}
+	bw, err := NewBatchWriterWithJournal(ctx, backend, config)
+	require.NoError(t, err)
+
+	require.NoError(t, bw.Write(ctx, core.StockData{Symbol: "600000"}))
+	require.NoError(t, bw.Flush())
+	assert.Equal(t, int64(0), bw.GetStats().JournalSize, "由回调处理的记录不再重放")
+	require.NoError(t, bw.Close())
+
+	results, err := fallback.Load(ctx, core.Query{Symbols: []string{"600000"}})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
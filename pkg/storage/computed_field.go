@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// ComputeFunc 计算字段的求值函数，values 中包含已存储的值和已求值的依赖字段，返回 nil 表示无法计算
+type ComputeFunc func(values map[string]interface{}) (interface{}, error)
+
+// MaterializeComputed 将所有未显式赋值的计算字段求值并写入 Values，用于序列化前补全派生列
+//
+// 无法计算（返回 nil）的字段保持缺失，显式存储的值不会被覆盖。
+func (sd *StructuredData) MaterializeComputed() error {
+	if err := sd.checkMutable(""); err != nil {
+		return err
+	}
+	computed := make(map[string]interface{})
+	for _, fieldName := range schemaFieldNames(sd.Schema) {
+		fieldDef := sd.Schema.Fields[fieldName]
+		if fieldDef == nil || fieldDef.Compute == nil {
+			continue
+		}
+		if _, exists := sd.Values[fieldName]; exists {
+			continue
+		}
+		value, err := sd.computeField(fieldName, fieldDef)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			computed[fieldName] = value
+		}
+	}
+
+	for fieldName, value := range computed {
+		sd.Values[fieldName] = value
+	}
+	return nil
+}
+
+// missingFieldValue 字段未存储值时的取值：依次尝试计算函数、默认值，必填字段返回错误
+func (sd *StructuredData) missingFieldValue(fieldName string, fieldDef *FieldDefinition) (interface{}, error) {
+	if fieldDef.Compute != nil {
+		value, err := sd.computeField(fieldName, fieldDef)
+		if err != nil || value != nil {
+			return value, err
+		}
+	}
+	if fieldDef.DefaultValue != nil {
+		return fieldDef.DefaultValue, nil
+	}
+	if fieldDef.Required {
+		return nil, NewStructuredDataError(ErrRequiredFieldMissing, fieldName, "required field missing")
+	}
+	return nil, nil
+}
+
+// computeField 对计算字段求值，依赖的计算字段未存储值时先递归求值（模式已保证无环）
+func (sd *StructuredData) computeField(fieldName string, fieldDef *FieldDefinition) (interface{}, error) {
+	values, copied := sd.Values, false
+	for _, dep := range fieldDef.DependsOn {
+		depDef := sd.Schema.Fields[dep]
+		if depDef == nil || depDef.Compute == nil {
+			continue
+		}
+		if _, exists := sd.Values[dep]; exists {
+			continue
+		}
+		depValue, err := sd.computeField(dep, depDef)
+		if err != nil {
+			return nil, err
+		}
+		if depValue == nil {
+			continue
+		}
+		if !copied {
+			values = make(map[string]interface{}, len(sd.Values)+len(fieldDef.DependsOn))
+			for k, v := range sd.Values {
+				values[k] = v
+			}
+			copied = true
+		}
+		values[dep] = depValue
+	}
+
+	value, err := fieldDef.Compute(values)
+	if err != nil {
+		return nil, NewStructuredDataError(ErrFieldValidationFailed, fieldName, fmt.Sprintf("compute failed: %v", err))
+	}
+	if value != nil && !isValidFieldValue(value, fieldDef) {
+		return nil, NewStructuredDataError(ErrInvalidFieldType, fieldName, fmt.Sprintf("computed value has invalid type %T", value))
+	}
+	return value, nil
+}
+
+// validateComputedFields 检查计算字段的依赖都在模式中定义，且计算字段之间不存在循环依赖
+func validateComputedFields(schema *DataSchema) error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+
+	var visit func(fieldName string, path []string) error
+	visit = func(fieldName string, path []string) error {
+		switch state[fieldName] {
+		case visiting:
+			return NewStructuredDataError(ErrFieldValidationFailed, fieldName, fmt.Sprintf("computed field cycle: %v", append(path, fieldName)))
+		case done:
+			return nil
+		}
+
+		state[fieldName] = visiting
+		for _, dep := range schema.Fields[fieldName].DependsOn {
+			depDef, exists := schema.Fields[dep]
+			if !exists {
+				return NewStructuredDataError(ErrFieldNotFound, fieldName, fmt.Sprintf("computed field depends on unknown field %s", dep))
+			}
+			if depDef.Compute == nil {
+				continue
+			}
+			if err := visit(dep, append(path, fieldName)); err != nil {
+				return err
+			}
+		}
+		state[fieldName] = done
+		return nil
+	}
+
+	for _, fieldName := range schemaFieldNames(schema) {
+		if fieldDef := schema.Fields[fieldName]; fieldDef != nil && fieldDef.Compute != nil {
+			if err := visit(fieldName, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// computeAmplitude 振幅 = (最高价 - 最低价) / 昨收价 * 100，缺少价格时无法计算
+func computeAmplitude(values map[string]interface{}) (interface{}, error) {
+	high, okHigh := values["high"].(float64)
+	low, okLow := values["low"].(float64)
+	prevClose, okPrev := values["prev_close"].(float64)
+	if !okHigh || !okLow || !okPrev || prevClose <= 0 {
+		return nil, nil
+	}
+	return (high - low) / prevClose * 100, nil
+}
+
+// computeTurnover 成交额 = 当前价格 * 成交量，缺少价格或成交量时无法计算
+func computeTurnover(values map[string]interface{}) (interface{}, error) {
+	price, okPrice := values["price"].(float64)
+	volume, okVolume := values["volume"].(int64)
+	if !okPrice || !okVolume {
+		return nil, nil
+	}
+	return price * float64(volume), nil
+}
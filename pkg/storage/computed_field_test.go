@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func newComputedStock(t *testing.T) *StructuredData {
+	t.Helper()
+	sd := NewStructuredData(StockDataSchema)
+	fields := map[string]interface{}{
+		"symbol":     "600000",
+		"name":       "浦发银行",
+		"price":      10.5,
+		"volume":     int64(1000),
+		"high":       10.8,
+		"low":        10.2,
+		"prev_close": 10.0,
+		"timestamp":  time.Date(2025, 8, 21, 10, 30, 0, 0, time.FixedZone("CST", 8*3600)),
+	}
+	for fieldName, value := range fields {
+		require.NoError(t, sd.SetField(fieldName, value))
+	}
+	return sd
+}
+
+func TestComputedField_EvaluatedOnAccess(t *testing.T) {
+	sd := newComputedStock(t)
+
+	turnover, err := sd.GetField("turnover")
+	require.NoError(t, err)
+	assert.Equal(t, 10500.0, turnover)
+
+	amplitude, err := sd.GetFieldSafe("amplitude", FieldTypeFloat64)
+	require.NoError(t, err)
+	assert.InDelta(t, 6.0, amplitude, 1e-9)
+
+	assert.NotContains(t, sd.Values, "turnover", "惰性求值不写入 Values")
+	require.NoError(t, sd.ValidateData())
+	require.NoError(t, sd.ValidateDataComplete())
+}
+
+func TestComputedField_ExplicitValueWins(t *testing.T) {
+	sd := newComputedStock(t)
+	require.NoError(t, sd.SetField("amplitude", 5.5))
+
+	amplitude, err := sd.GetField("amplitude")
+	require.NoError(t, err)
+	assert.Equal(t, 5.5, amplitude, "优先使用行情源提供的值")
+
+	// 依赖缺失时无法计算，返回 nil
+	delete(sd.Values, "prev_close")
+	delete(sd.Values, "amplitude")
+	amplitude, err = sd.GetField("amplitude")
+	require.NoError(t, err)
+	assert.Nil(t, amplitude)
+}
+
+func TestComputedField_StockDataConversion(t *testing.T) {
+	stock := core.StockData{
+		Symbol: "600000", Name: "浦发银行", Price: 10.5, Volume: 1000,
+		High: 10.8, Low: 10.2, PrevClose: 10.0, Timestamp: time.Now(),
+	}
+	sd, err := StockDataToStructuredData(stock)
+	require.NoError(t, err)
+
+	converted, err := StructuredDataToStockData(sd)
+	require.NoError(t, err)
+	assert.Equal(t, 10500.0, converted.Turnover)
+	assert.InDelta(t, 6.0, converted.Amplitude, 1e-9)
+
+	stock.Turnover = 12345.0
+	stock.Amplitude = 3.2
+	sd, err = StockDataToStructuredData(stock)
+	require.NoError(t, err)
+	converted, err = StructuredDataToStockData(sd)
+	require.NoError(t, err)
+	assert.Equal(t, 12345.0, converted.Turnover)
+	assert.Equal(t, 3.2, converted.Amplitude)
+}
+
+func TestComputedField_Serialization(t *testing.T) {
+	sd := newComputedStock(t)
+
+	// CSV 按字段取值，计算列直接输出
+	data, err := NewStructuredDataSerializer(FormatCSV).Serialize(sd)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Contains(t, lines[1], ",1000,10500.00,")
+
+	// JSON 输出存储的 Values，需要先物化
+	jsonSerializer := NewStructuredDataSerializer(FormatJSON)
+	data, err = jsonSerializer.Serialize(sd)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), `"turnover":10500`)
+
+	require.NoError(t, sd.MaterializeComputed())
+	assert.Equal(t, 10500.0, sd.Values["turnover"])
+	assert.InDelta(t, 6.0, sd.Values["amplitude"], 1e-9)
+
+	data, err = jsonSerializer.Serialize(sd)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"turnover":10500`)
+}
+
+func TestComputedField_ChainedDependencies(t *testing.T) {
+	schema := &DataSchema{
+		Name: "chain",
+		Fields: map[string]*FieldDefinition{
+			"a": {Name: "a", Type: FieldTypeFloat64},
+			"b": {Name: "b", Type: FieldTypeFloat64, DependsOn: []string{"a"}, Compute: func(values map[string]interface{}) (interface{}, error) {
+				a, ok := values["a"].(float64)
+				if !ok {
+					return nil, nil
+				}
+				return a * 2, nil
+			}},
+			"c": {Name: "c", Type: FieldTypeFloat64, Required: true, DependsOn: []string{"b"}, Compute: func(values map[string]interface{}) (interface{}, error) {
+				b, ok := values["b"].(float64)
+				if !ok {
+					return nil, nil
+				}
+				return b + 1, nil
+			}},
+		},
+		FieldOrder: []string{"a", "b", "c"},
+	}
+	require.NoError(t, ValidateSchema(schema))
+
+	sd := NewStructuredData(schema)
+	require.NoError(t, sd.ValidateData(), "必填的计算字段无需显式赋值")
+
+	_, err := sd.GetField("c")
+	assert.Error(t, err, "无法计算的必填字段仍视为缺失")
+
+	require.NoError(t, sd.SetField("a", 2.0))
+	c, err := sd.GetField("c")
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, c)
+	assert.NotContains(t, sd.Values, "b", "依赖的计算字段不写入 Values")
+}
+
+func TestComputedField_Errors(t *testing.T) {
+	compute := func(values map[string]interface{}) (interface{}, error) { return 1.0, nil }
+
+	t.Run("cycle", func(t *testing.T) {
+		schema := &DataSchema{
+			Name: "cycle",
+			Fields: map[string]*FieldDefinition{
+				"a": {Name: "a", Type: FieldTypeFloat64, DependsOn: []string{"b"}, Compute: compute},
+				"b": {Name: "b", Type: FieldTypeFloat64, DependsOn: []string{"c"}, Compute: compute},
+				"c": {Name: "c", Type: FieldTypeFloat64, DependsOn: []string{"a"}, Compute: compute},
+			},
+			FieldOrder: []string{"a", "b", "c"},
+		}
+		err := ValidateSchema(schema)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "computed field cycle")
+	})
+
+	t.Run("unknown dependency", func(t *testing.T) {
+		schema := &DataSchema{
+			Name: "unknown",
+			Fields: map[string]*FieldDefinition{
+				"a": {Name: "a", Type: FieldTypeFloat64, DependsOn: []string{"missing"}, Compute: compute},
+			},
+		}
+		assert.Error(t, ValidateSchema(schema))
+	})
+
+	t.Run("compute failure and wrong type", func(t *testing.T) {
+		schema := &DataSchema{
+			Name: "failing",
+			Fields: map[string]*FieldDefinition{
+				"a": {Name: "a", Type: FieldTypeFloat64, Compute: func(map[string]interface{}) (interface{}, error) {
+					return nil, errors.New("boom")
+				}},
+				"b": {Name: "b", Type: FieldTypeFloat64, Compute: func(map[string]interface{}) (interface{}, error) {
+					return "1.0", nil
+				}},
+			},
+		}
+		require.NoError(t, ValidateSchema(schema))
+		sd := NewStructuredData(schema)
+
+		_, err := sd.GetField("a")
+		assert.ErrorContains(t, err, "boom")
+		_, err = sd.GetField("b")
+		assert.ErrorContains(t, err, "invalid type")
+		assert.Error(t, sd.MaterializeComputed())
+		assert.Empty(t, sd.Values)
+	})
+
+	require.NoError(t, ValidateSchema(StockDataSchema))
+}
@@ -2,8 +2,10 @@ package storage
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,15 +16,22 @@ import (
 )
 
 // CSVStorage 实现了 Storage 接口，提供了将测试数据以CSV格式持久化到磁盘的功能。
-// 它支持按日期和类型自动分割文件，并利用资源池来提高性能。
+// 它支持按日期和类型自动分割文件、按小时或天轮转并压缩归档，并利用资源池来提高性能。
 type CSVStorage struct {
 	config      CSVStorageConfig
 	resourceMgr *ResourceManager
 	fileMgr     *FileManager
 	writerCache map[string]*CSVWriterWrapper
+	files       map[string]*csvFile // 写入器键 -> 打开的文件状态
 	mu          sync.RWMutex
+	writeMu     sync.RWMutex // 写入持有读锁，轮转持有写锁，保证同一批数据不会跨文件
+	fileOpsMu   sync.RWMutex // Load 持有读锁，压缩和过期清理持有写锁
+	period      string       // 当前轮转周期
 	serializer  Serializer
+	schemas     map[string]*DataSchema // 记录类型 -> StructuredData 模式，Load 按模式解析 StructuredData 文件
 	stats       CSVStorageStats
+	statsMu     sync.Mutex
+	now         func() time.Time
 }
 
 // CSVStorageConfig 定义了 CSVStorage 的所有可配置选项。
@@ -32,7 +41,12 @@ type CSVStorageConfig struct {
 	DateFormat     string         `yaml:"date_format"`     // 用于生成每日文件名的时间格式。
 	MaxFileSize    int64          `yaml:"max_file_size"`   // 单个CSV文件的最大大小（字节）。
 	RotateInterval time.Duration  `yaml:"rotate_interval"` // 文件轮转的时间间隔。
-	EnableCompress bool           `yaml:"enable_compress"` // 是否对归档的CSV文件启用压缩。
+	EnableCompress bool           `yaml:"enable_compress"` // 是否将轮转完成的CSV文件压缩为 .csv.gz。
+	RotateBy       string         `yaml:"rotate_by"`       // 按时钟轮转文件的周期（none、hour、day），为空时按记录日期分文件。
+	MaxAge         time.Duration  `yaml:"max_age"`         // 已完成文件的最长保留时间，0表示不限制。
+	MaxFiles       int            `yaml:"max_files"`       // 每种记录类型最多保留的已完成文件数，0表示不限制。
+	ArchiveDir     string         `yaml:"archive_dir"`     // 过期文件的归档目录，为空时直接删除。
+	HeaderMode     CSVHeaderMode  `yaml:"header_mode"`     // StructuredData 文件的表头写法（combined、names、descriptions、both），默认 combined。
 	BatchSize      int            `yaml:"batch_size"`      // 批量写入的批次大小。
 	FlushInterval  time.Duration  `yaml:"flush_interval"`  // 定期将缓冲区数据刷新到磁盘的间隔。
 	ResourceConfig ResourceConfig `yaml:"resource_config"` // 底层资源管理器（如缓冲区、写入器）的配置。
@@ -45,6 +59,9 @@ type CSVStorageStats struct {
 	TotalSize     int64         `json:"total_size"`     // 所有文件的总大小（字节）。
 	WriteErrors   int64         `json:"write_errors"`   // 写入失败的次数。
 	BatchWrites   int64         `json:"batch_writes"`   // 完成的批量写入操作次数。
+	RotatedFiles  int64         `json:"rotated_files"`  // 轮转完成的文件数。
+	ExpiredFiles  int64         `json:"expired_files"`  // 因过期被删除或归档的文件数。
+	RotateErrors  int64         `json:"rotate_errors"`  // 轮转、压缩或清理失败的次数。
 	ResourceStats ResourceStats `json:"resource_stats"` // 底层资源的统计信息。
 	LastWrite     time.Time     `json:"last_write"`     // 最后一次写入操作的时间。
 	LastFlush     time.Time     `json:"last_flush"`     // 最后一次刷新到磁盘的时间。
@@ -52,6 +69,14 @@ type CSVStorageStats struct {
 
 // NewCSVStorage 创建并返回一个新的 CSVStorage 实例。
 func NewCSVStorage(config CSVStorageConfig) (*CSVStorage, error) {
+	switch config.RotateBy {
+	case "", RotateNone, RotateHour, RotateDay:
+	default:
+		return nil, fmt.Errorf("不支持的轮转周期: %s", config.RotateBy)
+	}
+	if err := validateHeaderMode(config.HeaderMode); err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(config.Directory, 0755); err != nil {
 		return nil, fmt.Errorf("创建存储目录失败: %w", err)
 	}
@@ -64,8 +89,16 @@ func NewCSVStorage(config CSVStorageConfig) (*CSVStorage, error) {
 		resourceMgr: resourceMgr,
 		fileMgr:     fileMgr,
 		writerCache: make(map[string]*CSVWriterWrapper),
+		files:       make(map[string]*csvFile),
 		serializer:  NewJSONSerializer(),
+		schemas:     map[string]*DataSchema{"structured_" + StockDataSchema.Name: StockDataSchema},
 		stats:       CSVStorageStats{},
+		now:         time.Now,
+	}
+	storage.period = storage.currentPeriod()
+
+	if err := storage.applyRetention(); err != nil {
+		return nil, fmt.Errorf("清理过期文件失败: %w", err)
 	}
 
 	if config.FlushInterval > 0 {
@@ -77,35 +110,43 @@ func NewCSVStorage(config CSVStorageConfig) (*CSVStorage, error) {
 
 // Save 将一条数据记录保存到对应的CSV文件中。
 func (cs *CSVStorage) Save(ctx context.Context, data interface{}) error {
+	cs.rotateIfNeeded()
+
+	cs.writeMu.RLock()
+	defer cs.writeMu.RUnlock()
+
 	record, err := cs.convertToRecord(data)
 	if err != nil {
-		cs.stats.WriteErrors++
+		cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 		return fmt.Errorf("数据转换失败: %w", err)
 	}
 
-	writer, err := cs.getOrCreateWriter(record.Type, record.Date)
+	date := cs.fileDate(record, cs.currentPeriod())
+	writer, err := cs.getOrCreateWriter(record.Type, date)
 	if err != nil {
-		cs.stats.WriteErrors++
+		cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 		return fmt.Errorf("获取写入器失败: %w", err)
 	}
 
 	// 对于 StructuredData，检查是否需要写入表头
 	if strings.HasPrefix(record.Type, "structured_") {
 		if sd, ok := data.(*StructuredData); ok {
-			if err := cs.ensureStructuredDataHeader(writer, record.Type, record.Date, sd.Schema); err != nil {
-				cs.stats.WriteErrors++
+			if err := cs.ensureStructuredDataHeader(writer, record.Type, date, sd.Schema); err != nil {
+				cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 				return fmt.Errorf("写入StructuredData表头失败: %w", err)
 			}
 		}
 	}
 
 	if err := writer.Write(record.Fields); err != nil {
-		cs.stats.WriteErrors++
+		cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 		return fmt.Errorf("写入记录失败: %w", err)
 	}
 
-	cs.stats.TotalRecords++
-	cs.stats.LastWrite = time.Now()
+	cs.updateStats(func(stats *CSVStorageStats) {
+		stats.TotalRecords++
+		stats.LastWrite = time.Now()
+	})
 
 	return nil
 }
@@ -116,17 +157,24 @@ func (cs *CSVStorage) BatchSave(ctx context.Context, dataList []interface{}) err
 		return nil
 	}
 
+	cs.rotateIfNeeded()
+
+	cs.writeMu.RLock()
+	defer cs.writeMu.RUnlock()
+
+	// 同一批数据写入同一个周期的文件
+	period := cs.currentPeriod()
 	groups := make(map[string][][]string)
 	structuredDataSchemas := make(map[string]*DataSchema) // 存储每个组的schema
 
 	for _, data := range dataList {
 		record, err := cs.convertToRecord(data)
 		if err != nil {
-			cs.stats.WriteErrors++
+			cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 			continue
 		}
 
-		key := fmt.Sprintf("%s_%s", record.Type, record.Date)
+		key := fmt.Sprintf("%s_%s", record.Type, cs.fileDate(record, period))
 		groups[key] = append(groups[key], record.Fields)
 
 		// 如果是 StructuredData，保存其 schema
@@ -149,7 +197,7 @@ func (cs *CSVStorage) BatchSave(ctx context.Context, dataList []interface{}) err
 
 		writer, err := cs.getOrCreateWriter(recordType, date)
 		if err != nil {
-			cs.stats.WriteErrors++
+			cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 			continue
 		}
 
@@ -157,29 +205,98 @@ func (cs *CSVStorage) BatchSave(ctx context.Context, dataList []interface{}) err
 		if strings.HasPrefix(recordType, "structured_") {
 			if schema, exists := structuredDataSchemas[key]; exists {
 				if err := cs.ensureStructuredDataHeader(writer, recordType, date, schema); err != nil {
-					cs.stats.WriteErrors++
+					cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 					continue
 				}
 			}
 		}
 
 		if err := writer.WriteAll(records); err != nil {
-			cs.stats.WriteErrors++
+			cs.updateStats(func(stats *CSVStorageStats) { stats.WriteErrors++ })
 			continue
 		}
 
-		cs.stats.TotalRecords += int64(len(records))
+		cs.updateStats(func(stats *CSVStorageStats) { stats.TotalRecords += int64(len(records)) })
 	}
 
-	cs.stats.BatchWrites++
-	cs.stats.LastWrite = time.Now()
+	cs.updateStats(func(stats *CSVStorageStats) {
+		stats.BatchWrites++
+		stats.LastWrite = time.Now()
+	})
 
 	return nil
 }
 
-// Load 根据查询条件从CSV文件加载数据。注意：此功能当前尚未实现。
+// RegisterSchema 登记 StructuredData 模式，Load 据此读取该模式的文件。
+// 写入过的模式和 StockDataSchema 会自动登记
+func (cs *CSVStorage) RegisterSchema(schema *DataSchema) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.schemas["structured_"+schema.Name] = schema
+}
+
+// structuredSchema 返回记录类型登记的模式
+func (cs *CSVStorage) structuredSchema(recordType string) *DataSchema {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.schemas[recordType]
+}
+
+// Load 按股票代码和时间范围从CSV文件加载数据，包括已轮转和压缩的文件。
+// 股票行情返回 core.StockData，StructuredData 返回 *StructuredData（模式未登记的文件跳过），
+// 其它类型返回 JSON 解码后的值。
 func (cs *CSVStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
-	return nil, fmt.Errorf("CSV加载功能待实现")
+	if err := cs.Flush(); err != nil {
+		return nil, fmt.Errorf("刷新缓冲区失败: %w", err)
+	}
+
+	cs.fileOpsMu.RLock()
+	defer cs.fileOpsMu.RUnlock()
+
+	files, err := cs.listDataFiles()
+	if err != nil {
+		return nil, fmt.Errorf("查找CSV文件失败: %w", err)
+	}
+
+	symbols := make(map[string]bool, len(query.Symbols))
+	for _, symbol := range query.Symbols {
+		symbols[symbol] = true
+	}
+
+	var results []interface{}
+	skipped := 0
+	for _, file := range files {
+		var schema *DataSchema
+		if strings.HasPrefix(file.recordType, "structured_") {
+			if schema = cs.structuredSchema(file.recordType); schema == nil {
+				continue
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rows, err := readCSVDataFile(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("读取CSV文件 %s 失败: %w", filepath.Base(file.path), err)
+		}
+		items, err := decodeCSVRows(rows, schema, query, symbols)
+		if err != nil {
+			return nil, fmt.Errorf("解析CSV文件 %s 失败: %w", filepath.Base(file.path), err)
+		}
+		for _, data := range items {
+			if skipped < query.Offset {
+				skipped++
+				continue
+			}
+			results = append(results, data)
+			if query.Limit > 0 && len(results) >= query.Limit {
+				return results, nil
+			}
+		}
+	}
+
+	return results, nil
 }
 
 // Delete 根据查询条件删除CSV文件中的数据。注意：此功能当前尚未实现。
@@ -196,24 +313,27 @@ func (cs *CSVStorage) Close() error {
 		writer.Close()
 	}
 	cs.writerCache = make(map[string]*CSVWriterWrapper)
+	cs.files = make(map[string]*csvFile)
 
 	cs.fileMgr.CloseAll()
 
 	return cs.resourceMgr.Close()
 }
 
-// Flush 将所有内部缓冲区的数据刷新到底层的CSV文件。
+// Flush 将所有内部缓冲区的数据刷新到底层的CSV文件，轮转周期变化时先完成轮转。
 func (cs *CSVStorage) Flush() error {
-	cs.mu.RLock()
-	defer cs.mu.RUnlock()
+	cs.rotateIfNeeded()
 
+	cs.mu.RLock()
 	for _, writer := range cs.writerCache {
 		if err := writer.Flush(); err != nil {
+			cs.mu.RUnlock()
 			return err
 		}
 	}
+	cs.mu.RUnlock()
 
-	cs.stats.LastFlush = time.Now()
+	cs.updateStats(func(stats *CSVStorageStats) { stats.LastFlush = time.Now() })
 	return nil
 }
 
@@ -222,13 +342,23 @@ func (cs *CSVStorage) GetStats() CSVStorageStats {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
+	cs.statsMu.Lock()
 	stats := cs.stats
+	cs.statsMu.Unlock()
 	stats.ResourceStats = cs.resourceMgr.GetStats()
 	stats.TotalFiles = int64(len(cs.writerCache))
 
 	return stats
 }
 
+// updateStats 在锁保护下更新统计信息
+func (cs *CSVStorage) updateStats(update func(stats *CSVStorageStats)) {
+	cs.statsMu.Lock()
+	defer cs.statsMu.Unlock()
+
+	update(&cs.stats)
+}
+
 // convertToRecord 将任意数据转换为内部的 core.Record 格式，以便于存储。
 func (cs *CSVStorage) convertToRecord(data interface{}) (*core.Record, error) {
 	record := &core.Record{
@@ -308,17 +438,25 @@ func (cs *CSVStorage) getOrCreateWriter(recordType, date string) (*CSVWriterWrap
 		return writer, nil
 	}
 
-	filename := fmt.Sprintf("%s_%s_%s.csv", cs.config.FilePrefix, recordType, date)
-	filepath := filepath.Join(cs.config.Directory, filename)
-
-	file, err := cs.fileMgr.OpenFile(filepath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	path := cs.filePath(recordType, date)
+	file, err := cs.fileMgr.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return nil, fmt.Errorf("打开文件失败: %w", err)
 	}
 
-	writer := NewCSVWriterWrapper(file, cs.resourceMgr)
+	state := &csvFile{path: path, date: date, hasHeader: true}
+	stat, err := file.Stat()
+	if err == nil && stat.Size() > 0 {
+		// 续写已有文件（例如进程重启后），先确认表头与当前格式一致
+		if err := cs.prepareExistingFile(file, state, recordType); err != nil {
+			cs.fileMgr.CloseFile(path)
+			return nil, err
+		}
+	}
 
-	if stat, err := file.Stat(); err == nil && stat.Size() == 0 {
+	writer := NewCSVWriterWrapper(file, cs.resourceMgr)
+	if err == nil && stat.Size() == 0 {
+		state.hasHeader = false
 		// 检查是否是 StructuredData 类型，需要特殊处理表头
 		if strings.HasPrefix(recordType, "structured_") {
 			// 对于 StructuredData，表头将在第一次写入数据时处理
@@ -330,14 +468,83 @@ func (cs *CSVStorage) getOrCreateWriter(recordType, date string) (*CSVWriterWrap
 					writer.Close()
 					return nil, fmt.Errorf("写入头部失败: %w", err)
 				}
+				state.hasHeader = true
 			}
 		}
 	}
 
 	cs.writerCache[key] = writer
+	cs.files[key] = state
 	return writer, nil
 }
 
+// prepareExistingFile 校验续写文件的表头，并在最后一行不完整（上次写入中途退出）时补一个换行，
+// 避免新记录与残缺的行拼在一起。StructuredData 的表头要等到拿到模式后再校验。
+func (cs *CSVStorage) prepareExistingFile(file *os.File, state *csvFile, recordType string) error {
+	header, complete, err := readExistingCSVFile(state.path)
+	if err != nil {
+		return fmt.Errorf("读取已有文件失败: %w", err)
+	}
+
+	if strings.HasPrefix(recordType, "structured_") {
+		state.existingHeader = header
+	} else if expected := cs.getCSVHeaders(recordType); !equalHeaders(header, expected) {
+		return headerMismatchError(state.path, header, expected)
+	}
+
+	if !complete {
+		if _, err := file.Write([]byte("\n")); err != nil {
+			return fmt.Errorf("补全最后一行失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// readExistingCSVFile 读取文件的表头行，并返回文件是否以换行结尾
+func readExistingCSVFile(path string) ([]string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, stat.Size()-1); err != nil {
+		return nil, false, err
+	}
+	return header, last[0] == '\n', nil
+}
+
+// equalHeaders 比较两个表头是否完全一致
+func equalHeaders(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// headerMismatchError 续写文件的表头与当前格式不一致
+func headerMismatchError(path string, actual, expected []string) error {
+	return NewStorageError(ErrCSVHeaderMismatch, fmt.Sprintf("existing file %s has header [%s], expected [%s]",
+		filepath.Base(path), strings.Join(actual, ","), strings.Join(expected, ",")))
+}
+
 // getCSVHeaders 返回所有CSV文件统一使用的表头。
 func (cs *CSVStorage) getCSVHeaders(recordType string) []string {
 	// 检查是否是 StructuredData 类型
@@ -396,45 +603,50 @@ func DefaultCSVStorageConfig() CSVStorageConfig {
 	}
 }
 
-// getStructuredDataCSVHeaders 生成带描述的 StructuredData CSV 表头
+// getStructuredDataCSVHeaders 生成 StructuredData CSV 表头的第一行，续写文件时据此校验表头
 func (cs *CSVStorage) getStructuredDataCSVHeaders(schema *DataSchema) []string {
-	headers := make([]string, len(schema.FieldOrder))
-
-	for i, fieldName := range schema.FieldOrder {
-		fieldDef, exists := schema.Fields[fieldName]
-		if !exists {
-			headers[i] = fieldName
-			continue
-		}
-
-		// 格式：中文描述(英文字段名)
-		if fieldDef.Description != "" {
-			headers[i] = fmt.Sprintf("%s(%s)", fieldDef.Description, fieldName)
-		} else {
-			headers[i] = fieldName
-		}
-	}
+	return cs.structuredDataHeaderRows(schema)[0]
+}
 
-	return headers
+// structuredDataHeaderRows 按 HeaderMode 生成 StructuredData 的表头行，both 模式为两行
+func (cs *CSVStorage) structuredDataHeaderRows(schema *DataSchema) [][]string {
+	return csvHeaderRows(schemaCSVColumns(schema, NestedFieldJSON), cs.config.HeaderMode)
 }
 
 // writeStructuredDataHeader 写入 StructuredData 的 CSV 表头
 func (cs *CSVStorage) writeStructuredDataHeader(writer *CSVWriterWrapper, schema *DataSchema) error {
-	headers := cs.getStructuredDataCSVHeaders(schema)
-	return writer.Write(headers)
+	return writer.WriteAll(cs.structuredDataHeaderRows(schema))
 }
 
 // ensureStructuredDataHeader 确保 StructuredData 文件有正确的表头
+//
+// 文件内容先写入缓冲区，不能依据磁盘上的文件大小判断，因此记录每个打开的文件是否已写入表头。
 func (cs *CSVStorage) ensureStructuredDataHeader(writer *CSVWriterWrapper, recordType, date string, schema *DataSchema) error {
-	// 检查文件是否为空（需要写入表头）
-	filename := fmt.Sprintf("%s_%s_%s.csv", cs.config.FilePrefix, recordType, date)
-	filepath := filepath.Join(cs.config.Directory, filename)
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 
-	if stat, err := os.Stat(filepath); err == nil && stat.Size() == 0 {
-		// 文件为空，需要写入表头
-		return cs.writeStructuredDataHeader(writer, schema)
+	cs.schemas[recordType] = schema
+	state, exists := cs.files[fmt.Sprintf("%s_%s", recordType, date)]
+	if !exists {
+		return nil
+	}
+	if state.existingHeader != nil {
+		// 续写的文件只校验一次
+		expected := cs.getStructuredDataCSVHeaders(schema)
+		if !equalHeaders(state.existingHeader, expected) {
+			return headerMismatchError(state.path, state.existingHeader, expected)
+		}
+		state.existingHeader = nil
+	}
+	if state.hasHeader {
+		return nil
 	}
 
+	// 新文件，需要写入表头
+	if err := cs.writeStructuredDataHeader(writer, schema); err != nil {
+		return err
+	}
+	state.hasHeader = true
 	return nil
 }
 
@@ -532,6 +744,11 @@ func (cs *CSVStorage) formatStructuredDataValue(value interface{}, fieldType Fie
 			// 使用上海时区格式化时间：YYYY-MM-DD HH:mm:ss
 			return t.In(timezone).Format("2006-01-02 15:04:05")
 		}
+	case FieldTypeArray, FieldTypeObject:
+		// 嵌套字段整体 JSON 编码
+		if b, err := json.Marshal(value); err == nil {
+			return string(b)
+		}
 	}
 
 	return fmt.Sprintf("%v", value)
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CSVCellError CSV 中一个单元格（或一整行）的解析或验证错误，行列号从 1 开始，Column 为 0 表示整行
+type CSVCellError struct {
+	Row    int    // 文件中的行号，包含表头行
+	Column int    // 列号
+	Header string // 原始表头
+	Value  string // 单元格原文
+	Err    error  // 解析或验证错误，通常是 *StructuredDataError
+}
+
+func (e *CSVCellError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d, column %d (%s): %v", e.Row, e.Column, e.Header, e.Err)
+}
+
+func (e *CSVCellError) Unwrap() error {
+	return e.Err
+}
+
+// CSVDecodeError CSV 反序列化时收集到的全部单元格错误
+type CSVDecodeError struct {
+	Cells []*CSVCellError
+}
+
+// maxReportedCSVCells 错误信息中最多列出的单元格数
+const maxReportedCSVCells = 20
+
+func (e *CSVDecodeError) Error() string {
+	messages := make([]string, 0, min(len(e.Cells), maxReportedCSVCells))
+	for i, cell := range e.Cells {
+		if i == maxReportedCSVCells {
+			messages = append(messages, fmt.Sprintf("... and %d more", len(e.Cells)-maxReportedCSVCells))
+			break
+		}
+		messages = append(messages, cell.Error())
+	}
+	return fmt.Sprintf("%d invalid CSV cells: %s", len(e.Cells), strings.Join(messages, "; "))
+}
+
+// Unwrap 让 errors.Is/As 可以匹配任意单元格的错误
+func (e *CSVDecodeError) Unwrap() []error {
+	errs := make([]error, len(e.Cells))
+	for i, cell := range e.Cells {
+		errs[i] = cell
+	}
+	return errs
+}
+
+// csvTimeLayouts CSV 时间单元格接受的格式，没有时区的格式按序列化器的时区解析
+var csvTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+}
+
+// utf8BOM Excel 等工具导出 CSV 时写在文件开头的字节序标记
+var utf8BOM = []byte("\xEF\xBB\xBF")
@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cellTestSchema 覆盖各类型的测试模式，qty 有默认值
+func cellTestSchema() *DataSchema {
+	return &DataSchema{
+		Name:       "cells",
+		FieldOrder: []string{"code", "qty", "price", "active", "at", "levels"},
+		Fields: map[string]*FieldDefinition{
+			"code":   {Name: "code", Type: FieldTypeString, Required: true},
+			"qty":    {Name: "qty", Type: FieldTypeInt, DefaultValue: int64(-1)},
+			"price":  {Name: "price", Type: FieldTypeFloat64},
+			"active": {Name: "active", Type: FieldTypeBool},
+			"at":     {Name: "at", Type: FieldTypeTime},
+			"levels": {Name: "levels", Type: FieldTypeArray, Required: true, MaxItems: 2,
+				ElementType: &FieldDefinition{Type: FieldTypeFloat64}},
+		},
+	}
+}
+
+const cellTestHeader = "code,qty,price,active,at,levels[0],levels[1]\n"
+
+func TestStructuredDataSerializer_CSVCellTypes(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		row   string
+		field string
+		want  interface{}
+	}{
+		{"int", "A,42,1,true,,1,", "qty", int64(42)},
+		{"negative int", "A,-7,1,true,,1,", "qty", int64(-7)},
+		{"int with spaces", "A, 42 ,1,true,,1,", "qty", int64(42)},
+		{"float", "A,1,10.5,true,,1,", "price", 10.5},
+		{"float exponent", "A,1,1e3,true,,1,", "price", 1000.0},
+		{"bool true", "A,1,1,true,,1,", "active", true},
+		{"bool digit", "A,1,1,0,,1,", "active", false},
+		{"bool upper", "A,1,1,TRUE,,1,", "active", true},
+		{"time default layout", "A,1,1,true,2025-08-21 14:30:00,1,", "at", time.Date(2025, 8, 21, 14, 30, 0, 0, shanghai)},
+		{"time RFC3339", "A,1,1,true,2025-08-21T06:30:00Z,1,", "at", time.Date(2025, 8, 21, 6, 30, 0, 0, time.UTC)},
+		{"time RFC3339 offset", "A,1,1,true,2025-08-21T14:30:00+08:00,1,", "at", time.Date(2025, 8, 21, 6, 30, 0, 0, time.UTC)},
+		{"time without zone", "A,1,1,true,2025-08-21T14:30:00,1,", "at", time.Date(2025, 8, 21, 14, 30, 0, 0, shanghai)},
+		{"time slashes", "A,1,1,true,2025/08/21 14:30:00,1,", "at", time.Date(2025, 8, 21, 14, 30, 0, 0, shanghai)},
+		{"date only", "A,1,1,true,2025-08-21,1,", "at", time.Date(2025, 8, 21, 0, 0, 0, 0, shanghai)},
+		{"fractional seconds", "A,1,1,true,2025-08-21 14:30:00.5,1,", "at", time.Date(2025, 8, 21, 14, 30, 0, 5e8, shanghai)},
+		{"empty optional", "A,,1,true,,1,", "price", 1.0},
+		{"empty optional is nil", "A,1,,true,,1,", "price", nil},
+		{"exploded array", "A,1,1,true,,1.5,2.5", "levels", []interface{}{1.5, 2.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serializer := NewStructuredDataSerializer(FormatCSV)
+			list, err := serializer.DeserializeMultiple([]byte(cellTestHeader+tt.row), cellTestSchema())
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+
+			value := list[0].Values[tt.field]
+			if want, ok := tt.want.(time.Time); ok {
+				got, ok := value.(time.Time)
+				require.True(t, ok, "时间字段解析为 time.Time")
+				assert.True(t, want.Equal(got), "期望 %v，实际 %v", want, got)
+				return
+			}
+			assert.Equal(t, tt.want, value)
+		})
+	}
+}
+
+func TestStructuredDataSerializer_CSVCellErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   string
+		code   string
+		row    int
+		column int
+		text   string
+	}{
+		{"malformed int", "A,4x2,1,true,,1,", string(ErrInvalidFieldType), 2, 2, "failed to parse value '4x2' as int"},
+		{"float in int column", "A,1.5,1,true,,1,", string(ErrInvalidFieldType), 2, 2, "as int"},
+		{"malformed float", "A,1,10..5,true,,1,", string(ErrInvalidFieldType), 2, 3, "as float64"},
+		{"thousands separator", "A,1,\"1,000\",true,,1,", string(ErrInvalidFieldType), 2, 3, "'1,000'"},
+		{"nan float", "A,1,NaN,true,,1,", string(ErrInvalidFieldType), 2, 3, "NaN"},
+		{"bad bool", "A,1,1,yes,,1,", string(ErrInvalidFieldType), 2, 4, "as bool"},
+		{"bad timestamp", "A,1,1,true,21/08/2025,1,", string(ErrInvalidFieldType), 2, 5, "unsupported time format"},
+		{"invalid month", "A,1,1,true,2025-13-01 00:00:00,1,", string(ErrInvalidFieldType), 2, 5, "unsupported time format"},
+		{"empty required", ",1,1,true,,1,", string(ErrRequiredFieldMissing), 2, 1, "required field missing"},
+		{"empty required array", "A,1,1,true,,,", string(ErrRequiredFieldMissing), 2, 6, "required field missing"},
+		{"bad array item", "A,1,1,true,,1,x", string(ErrInvalidFieldType), 2, 7, "'x'"},
+		{"second row", "A,1,1,true,,1,\nB,z,1,true,,1,", string(ErrInvalidFieldType), 3, 2, "'z'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serializer := NewStructuredDataSerializer(FormatCSV)
+			_, err := serializer.DeserializeMultiple([]byte(cellTestHeader+tt.rows), cellTestSchema())
+			require.Error(t, err)
+
+			var decodeErr *CSVDecodeError
+			require.True(t, errors.As(err, &decodeErr), "错误为 *CSVDecodeError: %v", err)
+			require.Len(t, decodeErr.Cells, 1)
+			cell := decodeErr.Cells[0]
+			assert.Equal(t, tt.row, cell.Row)
+			assert.Equal(t, tt.column, cell.Column)
+			assert.Contains(t, err.Error(), tt.text)
+
+			var sdErr *StructuredDataError
+			require.True(t, errors.As(err, &sdErr), "单元格错误可以取出 StructuredDataError")
+			assert.EqualValues(t, tt.code, sdErr.Code)
+		})
+	}
+}
+
+func TestStructuredDataSerializer_CSVAggregatesAllCellErrors(t *testing.T) {
+	data := cellTestHeader +
+		"A,x,1,true,,1,\n" +
+		",1,y,maybe,,1,\n" +
+		"D,1,1,true,,1,"
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	_, err := serializer.DeserializeMultiple([]byte(data), cellTestSchema())
+	require.Error(t, err)
+
+	var decodeErr *CSVDecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	positions := make([][2]int, len(decodeErr.Cells))
+	for i, cell := range decodeErr.Cells {
+		positions[i] = [2]int{cell.Row, cell.Column}
+	}
+	assert.Equal(t, [][2]int{{2, 2}, {3, 1}, {3, 3}, {3, 4}}, positions)
+	assert.Contains(t, err.Error(), "4 invalid CSV cells")
+	assert.Contains(t, err.Error(), "row 3, column 4 (active)")
+}
+
+func TestStructuredDataSerializer_CSVLenientMode(t *testing.T) {
+	data := cellTestHeader +
+		"A,x,1,true,,1,\n" +
+		"B,2,oops,true,bad,1,\n" +
+		"D,3,1,true,,,"
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	serializer.SetLenient(true)
+
+	list, err := serializer.DeserializeMultiple([]byte(data), cellTestSchema())
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+
+	assert.Equal(t, int64(-1), list[0].Values["qty"], "出错的单元格替换为默认值")
+	assert.Nil(t, list[1].Values["price"], "没有默认值时为空")
+	assert.Nil(t, list[1].Values["at"])
+	assert.Equal(t, int64(3), list[2].Values["qty"])
+	assert.Nil(t, list[2].Values["levels"], "必填数组为空时保留为空并记录警告")
+
+	warnings := serializer.Warnings()
+	require.Len(t, warnings, 4)
+	assert.Equal(t, "x", warnings[0].Value)
+	assert.Equal(t, 3, warnings[1].Row)
+	assert.Equal(t, 3, warnings[1].Column)
+	assert.Equal(t, [2]int{4, 6}, [2]int{warnings[3].Row, warnings[3].Column})
+
+	// 单条反序列化同样支持宽松模式
+	sd := NewStructuredData(cellTestSchema())
+	require.NoError(t, serializer.Deserialize([]byte(cellTestHeader+"E,bad,1,true,,1,"), sd))
+	assert.Equal(t, "E", sd.Values["code"])
+	assert.Equal(t, int64(-1), sd.Values["qty"])
+	require.Len(t, serializer.Warnings(), 1)
+}
+
+func TestStructuredDataSerializer_CSVStrictSingleDeserialize(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	sd := NewStructuredData(cellTestSchema())
+	err := serializer.Deserialize([]byte(cellTestHeader+"E,bad,1,maybe,,1,"), sd)
+
+	var decodeErr *CSVDecodeError
+	require.True(t, errors.As(err, &decodeErr), "错误为 *CSVDecodeError: %v", err)
+	assert.Len(t, decodeErr.Cells, 2)
+}
+
+func TestStructuredDataSerializer_CSVWithBOM(t *testing.T) {
+	bom := string(utf8BOM)
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"english header", bom + cellTestHeader + "A,1,1,true,,1,"},
+		{"combined header", bom + "代码(code),数量(qty),price,active,at,levels[0],levels[1]\nA,1,1,true,,1,"},
+		{"with metadata", bom + "# schema=cells version=1\n" + cellTestHeader + "A,1,1,true,,1,"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serializer := NewStructuredDataSerializer(FormatCSV)
+			list, err := serializer.DeserializeMultiple([]byte(tt.data), cellTestSchema())
+			require.NoError(t, err)
+			require.Len(t, list, 1)
+			assert.Equal(t, "A", list[0].Values["code"])
+
+			sd := NewStructuredData(cellTestSchema())
+			require.NoError(t, serializer.Deserialize([]byte(tt.data), sd))
+			assert.Equal(t, "A", sd.Values["code"])
+		})
+	}
+}
+
+func TestCSVDecodeError_TruncatesLongReports(t *testing.T) {
+	cells := make([]*CSVCellError, maxReportedCSVCells+5)
+	for i := range cells {
+		cells[i] = &CSVCellError{Row: i + 2, Column: 1, Header: "code", Err: errors.New("bad")}
+	}
+	err := &CSVDecodeError{Cells: cells}
+	assert.Contains(t, err.Error(), "25 invalid CSV cells")
+	assert.Contains(t, err.Error(), "... and 5 more")
+}
@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// CSVStorage 的文件轮转周期
+const (
+	RotateNone = "none" // 每种记录类型只写一个文件
+	RotateHour = "hour" // 每小时一个文件，如 stocksub_stock_data_2025082510.csv
+	RotateDay  = "day"  // 每天一个文件，如 stocksub_stock_data_20250825.csv
+)
+
+// csvFile 一个打开的CSV文件的状态
+type csvFile struct {
+	path           string
+	date           string
+	hasHeader      bool
+	existingHeader []string // 续写的 StructuredData 文件中尚未校验的表头
+}
+
+// csvDataFile 目录中属于本存储的一个数据文件
+type csvDataFile struct {
+	path       string
+	recordType string
+	start      time.Time // 文件所属周期的开始时间，无法从文件名解析时为零值
+}
+
+// rotateLayout 返回轮转周期在文件名中的时间格式，不按时钟轮转时返回空字符串
+func rotateLayout(rotateBy string) string {
+	switch rotateBy {
+	case RotateHour:
+		return "2006010215"
+	case RotateDay:
+		return "20060102"
+	default:
+		return ""
+	}
+}
+
+// currentPeriod 返回当前时钟所在的轮转周期
+func (cs *CSVStorage) currentPeriod() string {
+	if layout := rotateLayout(cs.config.RotateBy); layout != "" {
+		return cs.now().Format(layout)
+	}
+	return ""
+}
+
+// fileDate 返回记录写入的文件的日期部分，period 为本次写入开始时的轮转周期
+func (cs *CSVStorage) fileDate(record *core.Record, period string) string {
+	switch cs.config.RotateBy {
+	case RotateNone:
+		return ""
+	case RotateHour, RotateDay:
+		return period
+	default:
+		return record.Date
+	}
+}
+
+// filePath 返回记录类型和日期对应的文件路径
+func (cs *CSVStorage) filePath(recordType, date string) string {
+	filename := fmt.Sprintf("%s_%s.csv", cs.config.FilePrefix, recordType)
+	if date != "" {
+		filename = fmt.Sprintf("%s_%s_%s.csv", cs.config.FilePrefix, recordType, date)
+	}
+	return filepath.Join(cs.config.Directory, filename)
+}
+
+// rotateIfNeeded 在时钟进入新的周期时关闭旧周期的文件，然后压缩并清理过期文件。
+//
+// 关闭文件时持有 writeMu 写锁，进行中的 Save 和 BatchSave 完成后才会关闭，
+// 一次写入的数据总是完整地落在同一个文件中。
+func (cs *CSVStorage) rotateIfNeeded() {
+	period := cs.currentPeriod()
+	if period == "" {
+		return
+	}
+	cs.mu.RLock()
+	unchanged := period == cs.period
+	cs.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	cs.writeMu.Lock()
+	cs.mu.Lock()
+	// 等待锁期间时钟可能已进入更新的周期，重新读取
+	period = cs.currentPeriod()
+	if period == cs.period {
+		cs.mu.Unlock()
+		cs.writeMu.Unlock()
+		return
+	}
+	cs.period = period
+
+	var finished []string
+	for key, state := range cs.files {
+		if state.date == period {
+			continue
+		}
+		cs.writerCache[key].Close()
+		cs.fileMgr.CloseFile(state.path)
+		delete(cs.writerCache, key)
+		delete(cs.files, key)
+		finished = append(finished, state.path)
+	}
+	cs.mu.Unlock()
+	cs.writeMu.Unlock()
+
+	// 压缩和清理不阻塞新周期的写入
+	cs.fileOpsMu.Lock()
+	for _, path := range finished {
+		if cs.config.EnableCompress {
+			if err := compressFile(path); err != nil {
+				cs.updateStats(func(stats *CSVStorageStats) { stats.RotateErrors++ })
+				continue
+			}
+		}
+		cs.updateStats(func(stats *CSVStorageStats) { stats.RotatedFiles++ })
+	}
+	cs.fileOpsMu.Unlock()
+
+	if err := cs.applyRetention(); err != nil {
+		cs.updateStats(func(stats *CSVStorageStats) { stats.RotateErrors++ })
+	}
+}
+
+// applyRetention 删除或归档超过 MaxAge 或超出 MaxFiles 的已完成文件，正在写入的文件不受影响
+func (cs *CSVStorage) applyRetention() error {
+	if cs.config.RotateBy == RotateNone || (cs.config.MaxAge <= 0 && cs.config.MaxFiles <= 0) {
+		return nil
+	}
+
+	cs.fileOpsMu.Lock()
+	defer cs.fileOpsMu.Unlock()
+
+	files, err := cs.listDataFiles()
+	if err != nil {
+		return err
+	}
+
+	cs.mu.RLock()
+	active := make(map[string]bool, len(cs.files))
+	for _, state := range cs.files {
+		active[state.path] = true
+	}
+	cs.mu.RUnlock()
+
+	// 按记录类型分组，组内按周期从旧到新排列
+	groups := make(map[string][]csvDataFile)
+	for _, file := range files {
+		if file.start.IsZero() || active[file.path] || active[strings.TrimSuffix(file.path, ".gz")] {
+			continue
+		}
+		groups[file.recordType] = append(groups[file.recordType], file)
+	}
+
+	periodLength := 24 * time.Hour
+	if cs.config.RotateBy == RotateHour {
+		periodLength = time.Hour
+	}
+	now := cs.now()
+
+	for _, group := range groups {
+		for i, file := range group {
+			tooOld := cs.config.MaxAge > 0 && now.Sub(file.start.Add(periodLength)) > cs.config.MaxAge
+			tooMany := cs.config.MaxFiles > 0 && i < len(group)-cs.config.MaxFiles
+			if !tooOld && !tooMany {
+				continue
+			}
+			if err := cs.expireFile(file.path); err != nil {
+				return err
+			}
+			cs.updateStats(func(stats *CSVStorageStats) { stats.ExpiredFiles++ })
+		}
+	}
+	return nil
+}
+
+// expireFile 将过期文件移动到归档目录，未配置归档目录时直接删除
+func (cs *CSVStorage) expireFile(path string) error {
+	if cs.config.ArchiveDir == "" {
+		return os.Remove(path)
+	}
+	if err := os.MkdirAll(cs.config.ArchiveDir, 0755); err != nil {
+		return fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	return os.Rename(path, filepath.Join(cs.config.ArchiveDir, filepath.Base(path)))
+}
+
+// listDataFiles 列出目录中本存储的 .csv 和 .csv.gz 文件，按记录类型和周期排序
+func (cs *CSVStorage) listDataFiles() ([]csvDataFile, error) {
+	entries, err := os.ReadDir(cs.config.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := rotateLayout(cs.config.RotateBy)
+	if cs.config.RotateBy == "" {
+		layout = cs.config.DateFormat
+	}
+
+	prefix := cs.config.FilePrefix + "_"
+	var files []csvDataFile
+	for _, entry := range entries {
+		name := entry.Name()
+		base := strings.TrimSuffix(name, ".gz")
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(base, ".csv") {
+			continue
+		}
+		base = strings.TrimSuffix(base, ".csv")
+
+		file := csvDataFile{path: filepath.Join(cs.config.Directory, name), recordType: strings.TrimPrefix(base, prefix)}
+		if layout != "" {
+			if i := strings.LastIndex(file.recordType, "_"); i >= 0 {
+				if start, err := time.ParseInLocation(layout, file.recordType[i+1:], cs.now().Location()); err == nil {
+					file.recordType, file.start = file.recordType[:i], start
+				}
+			}
+		}
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].recordType != files[j].recordType {
+			return files[i].recordType < files[j].recordType
+		}
+		if !files[i].start.Equal(files[j].start) {
+			return files[i].start.Before(files[j].start)
+		}
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// compressFile 将文件压缩为同名的 .gz 文件并删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := path + ".gz.tmp"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path+".gz"); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// readCSVDataFile 读取CSV文件的所有行，.gz 文件自动解压
+func readCSVDataFile(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	return csvReader.ReadAll()
+}
+
+// decodeCSVRow 解析通用格式（timestamp,type,symbol,data）的一行，返回 false 表示表头或不满足查询条件
+func decodeCSVRow(row []string, query core.Query, symbols map[string]bool) (interface{}, bool, error) {
+	if len(row) != 4 || row[0] == "timestamp" {
+		return nil, false, nil
+	}
+	if len(symbols) > 0 && !symbols[row[2]] {
+		return nil, false, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, row[0])
+	if err != nil {
+		return nil, false, fmt.Errorf("时间格式错误: %w", err)
+	}
+	if !query.StartTime.IsZero() && timestamp.Before(query.StartTime) {
+		return nil, false, nil
+	}
+	if !query.EndTime.IsZero() && timestamp.After(query.EndTime) {
+		return nil, false, nil
+	}
+
+	if row[1] == "stock_data" {
+		var stock core.StockData
+		if err := json.Unmarshal([]byte(row[3]), &stock); err != nil {
+			return nil, false, err
+		}
+		return stock, true, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(row[3]), &data); err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// decodeCSVRows 解析一个文件的全部行，schema 不为 nil 时按 StructuredData 解析（自动识别表头写法）
+func decodeCSVRows(rows [][]string, schema *DataSchema, query core.Query, symbols map[string]bool) ([]interface{}, error) {
+	var results []interface{}
+	if schema == nil {
+		for _, row := range rows {
+			data, ok, err := decodeCSVRow(row, query, symbols)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				results = append(results, data)
+			}
+		}
+		return results, nil
+	}
+
+	if len(rows) < 2 {
+		return nil, nil
+	}
+	list, err := NewStructuredDataSerializer(FormatCSV).structuredDataFromCSVRecords(rows, schema, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, sd := range list {
+		if len(symbols) > 0 {
+			symbol, _ := sd.Values["symbol"].(string)
+			if !symbols[symbol] {
+				continue
+			}
+		}
+		if timestamp, ok := sd.Values["timestamp"].(time.Time); ok {
+			sd.Timestamp = timestamp
+			if !query.StartTime.IsZero() && timestamp.Before(query.StartTime) {
+				continue
+			}
+			if !query.EndTime.IsZero() && timestamp.After(query.EndTime) {
+				continue
+			}
+		}
+		results = append(results, sd)
+	}
+	return results, nil
+}
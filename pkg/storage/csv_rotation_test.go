@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// rotationClock 可并发读取的测试时钟
+type rotationClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *rotationClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *rotationClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newRotatingCSVStorage(t *testing.T, start time.Time, configure func(*CSVStorageConfig)) (*CSVStorage, *rotationClock) {
+	t.Helper()
+	config := DefaultCSVStorageConfig()
+	config.Directory = t.TempDir()
+	config.FlushInterval = 0
+	config.RotateBy = RotateDay
+	if configure != nil {
+		configure(&config)
+	}
+	storage, err := NewCSVStorage(config)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+
+	clock := &rotationClock{t: start}
+	storage.now = clock.now
+	return storage, clock
+}
+
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCSVStorage_RotateByDay_CompressesFinishedFile(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	start := time.Date(2025, 8, 25, 23, 59, 30, 0, cst)
+	storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+		config.EnableCompress = true
+	})
+	ctx := context.Background()
+
+	require.NoError(t, storage.Save(ctx, core.StockData{Symbol: "600000", Price: 10.1, Timestamp: clock.now()}))
+	require.NoError(t, storage.Save(ctx, core.StockData{Symbol: "000001", Price: 12.3, Timestamp: clock.now()}))
+
+	clock.advance(time.Minute)
+	require.NoError(t, storage.BatchSave(ctx, []interface{}{
+		core.StockData{Symbol: "600000", Price: 10.2, Timestamp: clock.now()},
+	}))
+	require.NoError(t, storage.Flush())
+
+	dir := storage.config.Directory
+	assert.Equal(t, []string{"stocksub_stock_data_20250825.csv.gz", "stocksub_stock_data_20250826.csv"}, dirFiles(t, dir))
+	assert.Equal(t, int64(1), storage.GetStats().RotatedFiles)
+
+	// 每个文件都以表头开始
+	for _, name := range dirFiles(t, dir) {
+		rows, err := readCSVDataFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"timestamp", "type", "symbol", "data"}, rows[0], name)
+	}
+	rows, err := readCSVDataFile(filepath.Join(dir, "stocksub_stock_data_20250825.csv.gz"))
+	require.NoError(t, err)
+	assert.Len(t, rows, 3)
+
+	// 跨多个文件按时间范围读取
+	all, err := storage.Load(ctx, core.Query{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, 10.1, all[0].(core.StockData).Price)
+
+	loaded, err := storage.Load(ctx, core.Query{StartTime: start.Add(time.Minute), Symbols: []string{"600000"}})
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, 10.2, loaded[0].(core.StockData).Price)
+
+	loaded, err = storage.Load(ctx, core.Query{EndTime: start, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, "000001", loaded[0].(core.StockData).Symbol)
+}
+
+func TestCSVStorage_RotateByHour_StructuredDataHeader(t *testing.T) {
+	start := time.Date(2025, 8, 25, 10, 59, 0, 0, time.FixedZone("CST", 8*3600))
+	storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+		config.RotateBy = RotateHour
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, storage.Save(ctx, newParquetTestData(t, "600000", clock.now())))
+		require.NoError(t, storage.Save(ctx, newParquetTestData(t, "000001", clock.now())))
+		clock.advance(time.Hour)
+	}
+	require.NoError(t, storage.Close())
+
+	dir := storage.config.Directory
+	names := dirFiles(t, dir)
+	assert.Equal(t, []string{
+		"stocksub_structured_parquet_test_2025082510.csv",
+		"stocksub_structured_parquet_test_2025082511.csv",
+	}, names)
+
+	header := storage.getStructuredDataCSVHeaders(parquetTestSchema)
+	for _, name := range names {
+		rows, err := readCSVDataFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Len(t, rows, 3, "表头只写入一次")
+		assert.Equal(t, header, rows[0])
+	}
+}
+
+func TestCSVStorage_Retention(t *testing.T) {
+	start := time.Date(2025, 8, 25, 9, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	ctx := context.Background()
+
+	t.Run("max files archives oldest", func(t *testing.T) {
+		archiveDir := filepath.Join(t.TempDir(), "archive")
+		storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+			config.RotateBy = RotateHour
+			config.EnableCompress = true
+			config.MaxFiles = 2
+			config.ArchiveDir = archiveDir
+		})
+		for i := 0; i < 5; i++ {
+			require.NoError(t, storage.Save(ctx, core.StockData{Symbol: "600000", Timestamp: clock.now()}))
+			clock.advance(time.Hour)
+		}
+		require.NoError(t, storage.Flush())
+
+		assert.Equal(t, []string{
+			"stocksub_stock_data_2025082512.csv.gz",
+			"stocksub_stock_data_2025082513.csv.gz",
+		}, dirFiles(t, storage.config.Directory), "当前小时尚无数据，只保留最近两个已完成文件")
+		assert.Equal(t, []string{
+			"stocksub_stock_data_2025082509.csv.gz",
+			"stocksub_stock_data_2025082510.csv.gz",
+			"stocksub_stock_data_2025082511.csv.gz",
+		}, dirFiles(t, archiveDir))
+		assert.Equal(t, int64(3), storage.GetStats().ExpiredFiles)
+
+		// 归档的数据不再参与加载
+		loaded, err := storage.Load(ctx, core.Query{})
+		require.NoError(t, err)
+		assert.Len(t, loaded, 2)
+	})
+
+	t.Run("max age deletes expired", func(t *testing.T) {
+		storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+			config.RotateBy = RotateHour
+			config.MaxAge = 2 * time.Hour
+		})
+		for i := 0; i < 4; i++ {
+			require.NoError(t, storage.Save(ctx, core.StockData{Symbol: "600000", Timestamp: clock.now()}))
+			require.NoError(t, storage.Save(ctx, map[string]interface{}{"type": "metric", "symbol": "600000"}))
+			clock.advance(time.Hour)
+		}
+		clock.advance(30 * time.Minute)
+		require.NoError(t, storage.Flush())
+
+		// 13:30 时 10 点的文件结束于 11:00，已超过 2 小时
+		assert.Equal(t, []string{
+			"stocksub_metric_2025082511.csv", "stocksub_metric_2025082512.csv",
+			"stocksub_stock_data_2025082511.csv", "stocksub_stock_data_2025082512.csv",
+		}, dirFiles(t, storage.config.Directory))
+
+		loaded, err := storage.Load(ctx, core.Query{Symbols: []string{"600000"}})
+		require.NoError(t, err)
+		require.Len(t, loaded, 4)
+		metric, ok := loaded[0].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, "metric", metric["type"])
+	})
+
+	t.Run("retention on startup", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, name := range []string{"stocksub_stock_data_20200101.csv.gz", "stocksub_stock_data_20991231.csv", "other.csv"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+		}
+		config := DefaultCSVStorageConfig()
+		config.Directory = dir
+		config.FlushInterval = 0
+		config.RotateBy = RotateDay
+		config.MaxAge = 24 * time.Hour
+		storage, err := NewCSVStorage(config)
+		require.NoError(t, err)
+		defer storage.Close()
+
+		assert.Equal(t, []string{"other.csv", "stocksub_stock_data_20991231.csv"}, dirFiles(t, dir))
+	})
+}
+
+func TestCSVStorage_RotationWithConcurrentBatchWrites(t *testing.T) {
+	start := time.Date(2025, 8, 25, 9, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+		config.RotateBy = RotateHour
+		config.EnableCompress = true
+	})
+	ctx := context.Background()
+
+	const writers, batches, batchSize = 4, 30, 20
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				batch := make([]interface{}, batchSize)
+				for i := range batch {
+					batch[i] = core.StockData{Symbol: fmt.Sprintf("%d-%d", w, b), Timestamp: clock.now()}
+				}
+				assert.NoError(t, storage.BatchSave(ctx, batch))
+			}
+		}(w)
+	}
+	// 写入过程中推进时钟并刷新
+	for i := 0; i < 5; i++ {
+		clock.advance(time.Hour)
+		assert.NoError(t, storage.Flush())
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+	require.NoError(t, storage.Close())
+
+	// 每个文件以表头开始，同一批数据完整地落在同一个文件中，没有残缺行
+	dir := storage.config.Directory
+	batchFiles := make(map[string]map[string]bool)
+	total := 0
+	for _, name := range dirFiles(t, dir) {
+		rows, err := readCSVDataFile(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		require.NotEmpty(t, rows)
+		assert.Equal(t, "timestamp", rows[0][0], name)
+		for _, row := range rows[1:] {
+			require.Len(t, row, 4, name)
+			data, ok, err := decodeCSVRow(row, core.Query{}, nil)
+			require.NoError(t, err, name)
+			require.True(t, ok)
+			symbol := data.(core.StockData).Symbol
+			if batchFiles[symbol] == nil {
+				batchFiles[symbol] = make(map[string]bool)
+			}
+			batchFiles[symbol][name] = true
+			total++
+		}
+	}
+	assert.Equal(t, writers*batches*batchSize, total)
+	for symbol, files := range batchFiles {
+		assert.Len(t, files, 1, "批次 %s 跨文件写入", symbol)
+	}
+	assert.Zero(t, storage.GetStats().RotateErrors)
+}
+
+func TestCSVStorage_InvalidRotateBy(t *testing.T) {
+	config := DefaultCSVStorageConfig()
+	config.Directory = t.TempDir()
+	config.RotateBy = "week"
+	_, err := NewCSVStorage(config)
+	assert.Error(t, err)
+}
+
+func TestCSVStorage_ReopenAppendsWithoutDuplicatingHeader(t *testing.T) {
+	start := time.Date(2025, 8, 25, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// 模拟进程两次启动写入同一周期的同一文件
+	for run := 0; run < 2; run++ {
+		storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+			config.Directory = dir
+		})
+		clock.advance(time.Duration(run) * time.Hour)
+		require.NoError(t, storage.Save(ctx, core.StockData{Symbol: "600000", Price: 10 + float64(run), Timestamp: clock.now()}))
+		require.NoError(t, storage.Save(ctx, core.StockData{Symbol: "000001", Price: 20 + float64(run), Timestamp: clock.now()}))
+		require.NoError(t, storage.Close())
+	}
+
+	rows, err := readCSVDataFile(filepath.Join(dir, "stocksub_stock_data_20250825.csv"))
+	require.NoError(t, err)
+	require.Len(t, rows, 5, "表头只写入一次，两次运行的记录都保留")
+	assert.Equal(t, []string{"timestamp", "type", "symbol", "data"}, rows[0])
+	for _, row := range rows[1:] {
+		assert.Equal(t, "stock_data", row[1])
+	}
+}
+
+func TestCSVStorage_ReopenCompletesTornLine(t *testing.T) {
+	start := time.Date(2025, 8, 25, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	storage, clock := newRotatingCSVStorage(t, start, nil)
+	dir := storage.config.Directory
+	path := filepath.Join(dir, "stocksub_stock_data_20250825.csv")
+	// 上次运行在写最后一行时退出
+	require.NoError(t, os.WriteFile(path, []byte("timestamp,type,symbol,data\n2025-08-25T09:59:00+08:00,stock_da"), 0644))
+
+	require.NoError(t, storage.Save(context.Background(), core.StockData{Symbol: "600000", Price: 10, Timestamp: clock.now()}))
+	require.NoError(t, storage.Close())
+
+	rows, err := readCSVDataFile(path)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "600000", rows[2][2], "新记录不与残缺的行拼接")
+}
+
+func TestCSVStorage_ReopenRejectsHeaderMismatch(t *testing.T) {
+	start := time.Date(2025, 8, 25, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	storage, clock := newRotatingCSVStorage(t, start, nil)
+	dir := storage.config.Directory
+	ctx := context.Background()
+
+	stockPath := filepath.Join(dir, "stocksub_stock_data_20250825.csv")
+	require.NoError(t, os.WriteFile(stockPath, []byte("symbol,price\n600000,10\n"), 0644))
+	err := storage.Save(ctx, core.StockData{Symbol: "600000", Price: 10, Timestamp: clock.now()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CSV_HEADER_MISMATCH")
+
+	structuredPath := filepath.Join(dir, "stocksub_structured_parquet_test_20250825.csv")
+	require.NoError(t, os.WriteFile(structuredPath, []byte("a,b\n1,2\n"), 0644))
+	err = storage.Save(ctx, newParquetTestData(t, "600000", clock.now()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CSV_HEADER_MISMATCH")
+
+	// 已有文件保持不变
+	content, err := os.ReadFile(stockPath)
+	require.NoError(t, err)
+	assert.Equal(t, "symbol,price\n600000,10\n", string(content))
+}
+
+func TestCSVStorage_ReopenStructuredDataKeepsSingleHeader(t *testing.T) {
+	start := time.Date(2025, 8, 25, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	for run := 0; run < 2; run++ {
+		storage, clock := newRotatingCSVStorage(t, start, func(config *CSVStorageConfig) {
+			config.Directory = dir
+		})
+		require.NoError(t, storage.BatchSave(ctx, []interface{}{
+			newParquetTestData(t, "600000", clock.now()),
+			newParquetTestData(t, "000001", clock.now()),
+		}))
+		require.NoError(t, storage.Close())
+	}
+
+	rows, err := readCSVDataFile(filepath.Join(dir, "stocksub_structured_parquet_test_20250825.csv"))
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	header := (&CSVStorage{}).getStructuredDataCSVHeaders(parquetTestSchema)
+	assert.Equal(t, header, rows[0])
+	for _, row := range rows[1:] {
+		assert.NotEqual(t, header, row)
+	}
+}
@@ -21,6 +21,8 @@ const (
 	ErrInvalidFormat error.ErrorCode = "INVALID_FORMAT"
 	// ErrResourceClosed 表示尝试访问已关闭的资源。
 	ErrResourceClosed error.ErrorCode = "RESOURCE_CLOSED"
+	// ErrJournalCapacity 表示预写日志已达到容量上限。
+	ErrJournalCapacity error.ErrorCode = "JOURNAL_FULL"
 
 	ErrInvalidFieldType      error.ErrorCode = "INVALID_FIELD_TYPE"
 	ErrRequiredFieldMissing  error.ErrorCode = "REQUIRED_FIELD_MISSING"
@@ -28,11 +30,13 @@ const (
 	ErrSchemaNotFound        error.ErrorCode = "SCHEMA_NOT_FOUND"
 	ErrCSVHeaderMismatch     error.ErrorCode = "CSV_HEADER_MISMATCH"
 	ErrFieldNotFound         error.ErrorCode = "FIELD_NOT_FOUND"
+	ErrDataFrozen            error.ErrorCode = "DATA_FROZEN"
 )
 
 var (
 	ErrStorageQuotaExceeded = NewStorageError(ErrStorageFull, "storage quota exceeded")
 	ErrSerializationFailed  = NewStorageError(ErrSerializeFailed, "data serialization failed")
+	ErrJournalFull          = NewStorageError(ErrJournalCapacity, "write-ahead journal is full")
 )
 
 type StorageError struct {
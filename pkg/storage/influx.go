@@ -0,0 +1,577 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+
+	"stocksub/pkg/core"
+)
+
+// InfluxDBStorage 实现了 Storage 接口，将 StructuredData 和 core.StockData 写入 InfluxDB。
+//
+// 每个模式对应一个 measurement，字符串字段默认写为 tag，其余字段写为 field；
+// core.StockData 写入 StockMeasurement，与采集器写入的实时行情共用同一个 measurement。
+type InfluxDBStorage struct {
+	config  InfluxDBStorageConfig
+	client  influxClient
+	schemas map[string]*DataSchema // measurement 到模式的映射，用于将查询结果还原为 StructuredData
+	tags    map[string]bool
+	mu      sync.RWMutex
+	stats   InfluxDBStorageStats
+	statsMu sync.Mutex
+}
+
+// InfluxDBStorageConfig 定义了 InfluxDBStorage 的所有可配置选项，连接参数与采集器的 influxdb 配置一致。
+type InfluxDBStorageConfig struct {
+	URL    string `yaml:"url"`    // InfluxDB 服务地址。
+	Token  string `yaml:"token"`  // 访问令牌。
+	Org    string `yaml:"org"`    // 组织名称。
+	Bucket string `yaml:"bucket"` // 写入和查询的 bucket。
+
+	StockMeasurement string        `yaml:"stock_measurement"` // core.StockData 写入的 measurement。
+	StringsAsTags    bool          `yaml:"strings_as_tags"`   // 是否将字符串类型的字段写为 tag。
+	TagFields        []string      `yaml:"tag_fields"`        // 额外写为 tag 的字段，值按字符串写入。
+	BatchSize        int           `yaml:"batch_size"`        // BatchSave 每次请求写入的最大点数。
+	Timeout          time.Duration `yaml:"timeout"`           // 单次请求的超时时间。
+
+	Schemas []*DataSchema `yaml:"-"` // 预先注册的模式，Load 时将对应 measurement 的数据还原为 StructuredData。
+}
+
+// InfluxDBStorageStats 包含了 InfluxDBStorage 的运行统计信息。
+type InfluxDBStorageStats struct {
+	TotalRecords int64     `json:"total_records"` // 已写入的总记录数。
+	TotalBatches int64     `json:"total_batches"` // 已发送的写入请求数。
+	WriteErrors  int64     `json:"write_errors"`  // 写入失败的次数。
+	TotalQueries int64     `json:"total_queries"` // 已执行的查询数。
+	LastWrite    time.Time `json:"last_write"`    // 最后一次成功写入的时间。
+}
+
+// influxClient InfluxDBStorage 使用的 InfluxDB 操作，单元测试中可替换为假实现
+type influxClient interface {
+	WritePoints(ctx context.Context, points ...*write.Point) error
+	Query(ctx context.Context, flux string) ([]map[string]interface{}, error)
+	Delete(ctx context.Context, start, stop time.Time, predicate string) error
+	Close()
+}
+
+// influxAPIClient 基于 influxdb-client-go 的 influxClient 实现
+type influxAPIClient struct {
+	client influxdb2.Client
+	org    string
+	bucket string
+}
+
+func (c *influxAPIClient) WritePoints(ctx context.Context, points ...*write.Point) error {
+	return c.client.WriteAPIBlocking(c.org, c.bucket).WritePoint(ctx, points...)
+}
+
+func (c *influxAPIClient) Query(ctx context.Context, flux string) ([]map[string]interface{}, error) {
+	result, err := c.client.QueryAPI(c.org).Query(ctx, flux)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var rows []map[string]interface{}
+	for result.Next() {
+		rows = append(rows, result.Record().Values())
+	}
+	return rows, result.Err()
+}
+
+func (c *influxAPIClient) Delete(ctx context.Context, start, stop time.Time, predicate string) error {
+	return c.client.DeleteAPI().DeleteWithName(ctx, c.org, c.bucket, start, stop, predicate)
+}
+
+func (c *influxAPIClient) Close() {
+	c.client.Close()
+}
+
+// DefaultInfluxDBStorageConfig 返回一个包含推荐默认值的 InfluxDBStorageConfig。
+func DefaultInfluxDBStorageConfig() InfluxDBStorageConfig {
+	return InfluxDBStorageConfig{
+		URL:              "http://localhost:8086",
+		Org:              "stocksub",
+		Bucket:           "stock_data",
+		StockMeasurement: "stock_realtime",
+		StringsAsTags:    true,
+		BatchSize:        5000,
+		Timeout:          10 * time.Second,
+	}
+}
+
+// NewInfluxDBStorage 创建 InfluxDBStorage 实例，并检查 InfluxDB 服务是否可用。
+func NewInfluxDBStorage(config InfluxDBStorageConfig) (*InfluxDBStorage, error) {
+	if config.URL == "" || config.Org == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("InfluxDB配置缺少 url、org 或 bucket")
+	}
+
+	options := influxdb2.DefaultOptions()
+	if config.Timeout > 0 {
+		options.SetHTTPRequestTimeout(uint(math.Ceil(config.Timeout.Seconds())))
+	}
+	client := influxdb2.NewClientWithOptions(config.URL, config.Token, options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	health, err := client.Health(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("连接InfluxDB失败: %w", err)
+	}
+	if health.Status != "pass" {
+		client.Close()
+		return nil, fmt.Errorf("InfluxDB健康检查失败: %s", health.Status)
+	}
+
+	return newInfluxDBStorage(config, &influxAPIClient{client: client, org: config.Org, bucket: config.Bucket}), nil
+}
+
+// newInfluxDBStorage 使用给定的客户端创建 InfluxDBStorage
+func newInfluxDBStorage(config InfluxDBStorageConfig, client influxClient) *InfluxDBStorage {
+	if config.StockMeasurement == "" {
+		config.StockMeasurement = "stock_realtime"
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 5000
+	}
+
+	storage := &InfluxDBStorage{
+		config:  config,
+		client:  client,
+		schemas: map[string]*DataSchema{config.StockMeasurement: StockDataSchema},
+		tags:    make(map[string]bool, len(config.TagFields)),
+	}
+	for _, field := range config.TagFields {
+		storage.tags[field] = true
+	}
+	for _, schema := range config.Schemas {
+		storage.schemas[schema.Name] = schema
+	}
+	return storage
+}
+
+// Save 写入一条数据，支持 *StructuredData 和 core.StockData。
+func (is *InfluxDBStorage) Save(ctx context.Context, data interface{}) error {
+	return is.BatchSave(ctx, []interface{}{data})
+}
+
+// BatchSave 将多条数据转换为数据点，按 BatchSize 分批写入。
+func (is *InfluxDBStorage) BatchSave(ctx context.Context, dataList []interface{}) error {
+	if len(dataList) == 0 {
+		return nil
+	}
+
+	points := make([]*write.Point, 0, len(dataList))
+	for _, data := range dataList {
+		sd, err := toParquetRecord(data)
+		if err != nil {
+			is.updateStats(func(stats *InfluxDBStorageStats) { stats.WriteErrors++ })
+			return fmt.Errorf("数据转换失败: %w", err)
+		}
+		point, err := is.toPoint(sd)
+		if err != nil {
+			is.updateStats(func(stats *InfluxDBStorageStats) { stats.WriteErrors++ })
+			return fmt.Errorf("数据转换失败: %w", err)
+		}
+		points = append(points, point)
+	}
+
+	for start := 0; start < len(points); start += is.config.BatchSize {
+		end := min(start+is.config.BatchSize, len(points))
+		if err := is.writeBatch(ctx, points[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBatch 发送一次写入请求
+func (is *InfluxDBStorage) writeBatch(ctx context.Context, points []*write.Point) error {
+	if is.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, is.config.Timeout)
+		defer cancel()
+	}
+
+	if err := is.client.WritePoints(ctx, points...); err != nil {
+		is.updateStats(func(stats *InfluxDBStorageStats) { stats.WriteErrors++ })
+		return fmt.Errorf("写入InfluxDB失败: %w", err)
+	}
+	is.updateStats(func(stats *InfluxDBStorageStats) {
+		stats.TotalRecords += int64(len(points))
+		stats.TotalBatches++
+		stats.LastWrite = time.Now()
+	})
+	return nil
+}
+
+// Load 按查询条件生成 Flux 查询，已知模式的 measurement 还原为 *StructuredData，其余返回 map[string]interface{}。
+func (is *InfluxDBStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
+	flux, err := is.buildFluxQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if is.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, is.config.Timeout)
+		defer cancel()
+	}
+	rows, err := is.client.Query(ctx, flux)
+	is.updateStats(func(stats *InfluxDBStorageStats) { stats.TotalQueries++ })
+	if err != nil {
+		return nil, fmt.Errorf("查询InfluxDB失败: %w", err)
+	}
+
+	results := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		record, err := is.fromRow(row)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, record)
+	}
+	if query.Limit <= 0 {
+		results = paginate(results, query.Offset, 0)
+	}
+	return results, nil
+}
+
+// Delete 按股票代码和时间范围删除数据，不支持字段条件；为避免清空整个 bucket，必须指定代码或时间范围。
+func (is *InfluxDBStorage) Delete(ctx context.Context, query core.Query) error {
+	if len(query.Conditions) > 0 {
+		return fmt.Errorf("InfluxDB删除不支持字段条件")
+	}
+	if len(query.Symbols) == 0 && query.StartTime.IsZero() && query.EndTime.IsZero() {
+		return fmt.Errorf("InfluxDB删除必须指定股票代码或时间范围")
+	}
+
+	start, stop := query.StartTime, query.EndTime
+	if start.IsZero() {
+		start = time.Unix(0, 0)
+	}
+	if stop.IsZero() {
+		stop = time.Now()
+	}
+
+	// 删除谓词不支持 OR，每个股票代码单独删除
+	if len(query.Symbols) == 0 {
+		return is.client.Delete(ctx, start, stop, "")
+	}
+	for _, symbol := range query.Symbols {
+		if err := is.client.Delete(ctx, start, stop, fmt.Sprintf("symbol=%s", fluxString(symbol))); err != nil {
+			return fmt.Errorf("删除InfluxDB数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭 InfluxDB 客户端。
+func (is *InfluxDBStorage) Close() error {
+	is.client.Close()
+	return nil
+}
+
+// GetStats 返回当前存储实例的运行统计信息。
+func (is *InfluxDBStorage) GetStats() InfluxDBStorageStats {
+	is.statsMu.Lock()
+	defer is.statsMu.Unlock()
+	return is.stats
+}
+
+func (is *InfluxDBStorage) updateStats(update func(stats *InfluxDBStorageStats)) {
+	is.statsMu.Lock()
+	defer is.statsMu.Unlock()
+	update(&is.stats)
+}
+
+var _ Storage = (*InfluxDBStorage)(nil)
+
+// measurement 返回模式对应的 measurement，并记录模式用于还原查询结果
+func (is *InfluxDBStorage) measurement(schema *DataSchema) string {
+	name := schema.Name
+	if schema == StockDataSchema {
+		name = is.config.StockMeasurement
+	}
+
+	is.mu.RLock()
+	_, known := is.schemas[name]
+	is.mu.RUnlock()
+	if !known {
+		is.mu.Lock()
+		is.schemas[name] = schema
+		is.mu.Unlock()
+	}
+	return name
+}
+
+// isTag 判断字段是否写为 tag
+func (is *InfluxDBStorage) isTag(fieldDef *FieldDefinition) bool {
+	return is.tags[fieldDef.Name] || (is.config.StringsAsTags && fieldDef.Type == FieldTypeString)
+}
+
+// toPoint 将 StructuredData 转换为数据点：字符串按配置写为 tag（空字符串不写入），时间字段写为 RFC3339 字符串，
+// 数组和嵌套对象写为 JSON 字符串
+func (is *InfluxDBStorage) toPoint(sd *StructuredData) (*write.Point, error) {
+	timestamp := sd.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	point := influxdb2.NewPointWithMeasurement(is.measurement(sd.Schema)).SetTime(timestamp)
+
+	for _, fieldName := range schemaFieldNames(sd.Schema) {
+		fieldDef := sd.Schema.Fields[fieldName]
+		value, exists := sd.Values[fieldName]
+		if !exists || value == nil {
+			continue
+		}
+
+		switch v := value.(type) {
+		case time.Time:
+			value = v.Format(time.RFC3339Nano)
+		default:
+			if fieldDef.Type == FieldTypeArray || fieldDef.Type == FieldTypeObject {
+				encoded, err := json.Marshal(v)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", fieldName, err)
+				}
+				value = string(encoded)
+			}
+		}
+
+		if is.isTag(fieldDef) {
+			// InfluxDB 不保存空值的 tag
+			if tag := fmt.Sprint(value); tag != "" {
+				point.AddTag(fieldName, tag)
+			}
+		} else {
+			point.AddField(fieldName, value)
+		}
+	}
+	return point.SortTags(), nil
+}
+
+// fromRow 将 pivot 后的一行查询结果还原为记录
+func (is *InfluxDBStorage) fromRow(row map[string]interface{}) (interface{}, error) {
+	measurement, _ := row["_measurement"].(string)
+	timestamp, _ := row["_time"].(time.Time)
+
+	is.mu.RLock()
+	schema, known := is.schemas[measurement]
+	is.mu.RUnlock()
+
+	if !known {
+		values := make(map[string]interface{}, len(row))
+		for key, value := range row {
+			if !strings.HasPrefix(key, "_") && key != "result" && key != "table" {
+				values[key] = value
+			}
+		}
+		values["measurement"] = measurement
+		values["timestamp"] = timestamp
+		return values, nil
+	}
+
+	return StructuredDataFromRow(schema, row)
+}
+
+// StructuredDataFromRow 按模式将 pivot 后的一行 Flux 查询结果还原为 StructuredData，
+// _time 写入 Timestamp，模式中没有的列忽略
+func StructuredDataFromRow(schema *DataSchema, row map[string]interface{}) (*StructuredData, error) {
+	sd := NewStructuredData(schema)
+	sd.Timestamp, _ = row["_time"].(time.Time)
+	for fieldName, fieldDef := range schema.Fields {
+		raw, exists := row[fieldName]
+		if !exists || raw == nil {
+			continue
+		}
+		value, err := influxValueToField(raw, fieldDef)
+		if err != nil {
+			return nil, fmt.Errorf("解析字段 %s 失败: %w", fieldName, err)
+		}
+		sd.Values[fieldName] = value
+	}
+	return sd, nil
+}
+
+// influxValueToField 按字段类型转换查询结果中的值，tag 总是以字符串返回
+func influxValueToField(raw interface{}, fieldDef *FieldDefinition) (interface{}, error) {
+	str, isString := raw.(string)
+	switch fieldDef.Type {
+	case FieldTypeInt:
+		if isString {
+			return strconv.ParseInt(str, 10, 64)
+		}
+		if f, ok := raw.(float64); ok {
+			return int64(f), nil
+		}
+		if u, ok := raw.(uint64); ok {
+			return int64(u), nil
+		}
+	case FieldTypeFloat64:
+		if isString {
+			return strconv.ParseFloat(str, 64)
+		}
+		if i, ok := raw.(int64); ok {
+			return float64(i), nil
+		}
+	case FieldTypeBool:
+		if isString {
+			return strconv.ParseBool(str)
+		}
+	case FieldTypeTime:
+		if isString {
+			return time.Parse(time.RFC3339Nano, str)
+		}
+	case FieldTypeArray, FieldTypeObject:
+		if isString {
+			decoder := json.NewDecoder(strings.NewReader(str))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+			return coerceJSONValue(value, fieldDef)
+		}
+	}
+	return raw, nil
+}
+
+// buildFluxQuery 根据查询条件生成 Flux 查询。
+//
+// 结果按 _time pivot 为每个数据点一行，字段条件、排序和分页在 pivot 之后执行；
+// EndTime 与其他存储一致按闭区间处理。
+func (is *InfluxDBStorage) buildFluxQuery(query core.Query) (string, error) {
+	start := "0"
+	if !query.StartTime.IsZero() {
+		start = query.StartTime.UTC().Format(time.RFC3339Nano)
+	}
+	stop := "now()"
+	if !query.EndTime.IsZero() {
+		stop = query.EndTime.Add(time.Nanosecond).UTC().Format(time.RFC3339Nano)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "from(bucket: %s)\n", fluxString(is.config.Bucket))
+	fmt.Fprintf(&b, "\t|> range(start: %s, stop: %s)\n", start, stop)
+
+	symbolFilter := ""
+	if len(query.Symbols) > 0 {
+		clauses := make([]string, len(query.Symbols))
+		for i, symbol := range query.Symbols {
+			clauses[i] = fmt.Sprintf("r.symbol == %s", fluxString(symbol))
+		}
+		symbolFilter = fmt.Sprintf("\t|> filter(fn: (r) => %s)\n", strings.Join(clauses, " or "))
+	}
+	// symbol 为 tag 时在 pivot 之前过滤，可以利用 InfluxDB 的索引
+	symbolIsTag := is.config.StringsAsTags || is.tags["symbol"]
+	if symbolIsTag {
+		b.WriteString(symbolFilter)
+	}
+
+	b.WriteString("\t|> pivot(rowKey: [\"_time\"], columnKey: [\"_field\"], valueColumn: \"_value\")\n")
+	b.WriteString("\t|> group()\n")
+	if !symbolIsTag {
+		b.WriteString(symbolFilter)
+	}
+
+	for _, cond := range query.Conditions {
+		predicate, err := fluxCondition(cond)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\t|> filter(fn: (r) => %s)\n", predicate)
+	}
+
+	sortBy, desc := "_time", false
+	if query.SortBy != "" {
+		sortBy, desc = query.SortBy, query.SortDesc
+	}
+	fmt.Fprintf(&b, "\t|> sort(columns: [%s], desc: %t)\n", fluxString(sortBy), desc)
+
+	// 未指定 Limit 时 Flux 无法单独跳过记录，Offset 由 Load 在返回前处理
+	if query.Limit > 0 {
+		fmt.Fprintf(&b, "\t|> limit(n: %d, offset: %d)\n", query.Limit, max(query.Offset, 0))
+	}
+	return b.String(), nil
+}
+
+// fluxCondition 将字段条件转换为 Flux 谓词，数值统一转换为 float 比较，避免整数和浮点字段类型不一致
+func fluxCondition(cond core.Condition) (string, error) {
+	if err := validateConditions([]core.Condition{cond}); err != nil {
+		return "", err
+	}
+	column := fmt.Sprintf("r[%s]", fluxString(cond.Field))
+
+	if cond.Op == core.OpIn {
+		values, _ := toInterfaceSlice(cond.Value)
+		if len(values) == 0 {
+			return "false", nil
+		}
+		literals := make([]string, len(values))
+		numeric := false
+		for i, value := range values {
+			literal, isNumber, err := fluxLiteral(value)
+			if err != nil {
+				return "", fmt.Errorf("condition %s: %w", cond.Field, err)
+			}
+			if i > 0 && isNumber != numeric {
+				return "", fmt.Errorf("condition %s IN requires values of the same type", cond.Field)
+			}
+			literals[i], numeric = literal, isNumber
+		}
+		if numeric {
+			column = fmt.Sprintf("float(v: %s)", column)
+		}
+		return fmt.Sprintf("exists r[%s] and contains(value: %s, set: [%s])", fluxString(cond.Field), column, strings.Join(literals, ", ")), nil
+	}
+
+	literal, numeric, err := fluxLiteral(cond.Value)
+	if err != nil {
+		return "", fmt.Errorf("condition %s: %w", cond.Field, err)
+	}
+	if numeric {
+		column = fmt.Sprintf("float(v: %s)", column)
+	}
+	ops := map[core.ConditionOp]string{
+		core.OpEq: "==", core.OpNe: "!=", core.OpGt: ">", core.OpLt: "<", core.OpGte: ">=", core.OpLte: "<=",
+	}
+	return fmt.Sprintf("exists r[%s] and %s %s %s", fluxString(cond.Field), column, ops[cond.Op], literal), nil
+}
+
+// fluxLiteral 将条件值转换为 Flux 字面量，数值统一写为浮点数
+func fluxLiteral(value interface{}) (string, bool, error) {
+	switch v := value.(type) {
+	case string:
+		return fluxString(v), false, nil
+	case bool:
+		return strconv.FormatBool(v), false, nil
+	}
+	if _, f, _, ok := toNumber(value); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false, fmt.Errorf("value %v is not a finite number", value)
+		}
+		literal := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(literal, ".") {
+			literal += ".0"
+		}
+		return literal, true, nil
+	}
+	return "", false, fmt.Errorf("unsupported value type %T", value)
+}
+
+// fluxString 将字符串转义为 Flux 字符串字面量
+func fluxString(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`)
+	return `"` + replacer.Replace(s) + `"`
+}
@@ -0,0 +1,333 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// fakeInfluxClient 记录写入的数据点和执行的 Flux 查询，查询返回预设的行
+type fakeInfluxClient struct {
+	mu       sync.Mutex
+	batches  [][]*write.Point
+	queries  []string
+	deletes  []string
+	rows     []map[string]interface{}
+	writeErr error
+	closed   bool
+}
+
+func (c *fakeInfluxClient) WritePoints(ctx context.Context, points ...*write.Point) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	c.batches = append(c.batches, points)
+	return nil
+}
+
+func (c *fakeInfluxClient) Query(ctx context.Context, flux string) ([]map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = append(c.queries, flux)
+	return c.rows, nil
+}
+
+func (c *fakeInfluxClient) Delete(ctx context.Context, start, stop time.Time, predicate string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deletes = append(c.deletes, predicate)
+	return nil
+}
+
+func (c *fakeInfluxClient) Close() { c.closed = true }
+
+// points 返回所有写入的数据点
+func (c *fakeInfluxClient) points() []*write.Point {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var points []*write.Point
+	for _, batch := range c.batches {
+		points = append(points, batch...)
+	}
+	return points
+}
+
+func pointTags(point *write.Point) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range point.TagList() {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+func pointFields(point *write.Point) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, field := range point.FieldList() {
+		fields[field.Key] = field.Value
+	}
+	return fields
+}
+
+func newTestInfluxDBStorage(configure func(*InfluxDBStorageConfig)) (*InfluxDBStorage, *fakeInfluxClient) {
+	config := DefaultInfluxDBStorageConfig()
+	if configure != nil {
+		configure(&config)
+	}
+	client := &fakeInfluxClient{}
+	return newInfluxDBStorage(config, client), client
+}
+
+func TestInfluxDBStorage_StockDataPoint(t *testing.T) {
+	storage, client := newTestInfluxDBStorage(nil)
+	ts := time.Date(2025, 8, 25, 10, 30, 0, 0, time.UTC)
+
+	require.NoError(t, storage.Save(context.Background(), core.StockData{
+		Symbol: "600000", Name: "浦发银行", Price: 10.5, Volume: 1200, Timestamp: ts,
+	}))
+
+	points := client.points()
+	require.Len(t, points, 1)
+	point := points[0]
+	assert.Equal(t, "stock_realtime", point.Name())
+	assert.True(t, ts.Equal(point.Time()))
+	assert.Equal(t, map[string]string{"name": "浦发银行", "symbol": "600000"}, pointTags(point))
+	assert.Equal(t, "name", point.TagList()[0].Key, "tag 按名称排序")
+
+	fields := pointFields(point)
+	assert.Equal(t, 10.5, fields["price"])
+	assert.Equal(t, int64(1200), fields["volume"])
+	assert.Equal(t, "2025-08-25T10:30:00Z", fields["timestamp"])
+	assert.NotContains(t, fields, "turnover", "未提供的计算字段不写入")
+	assert.NotContains(t, fields, "symbol")
+}
+
+func TestInfluxDBStorage_StructuredDataPoint(t *testing.T) {
+	ts := time.Date(2025, 8, 25, 10, 30, 0, 0, time.UTC)
+	sd := newParquetTestData(t, "600000", ts)
+	sd.Timestamp = ts
+
+	t.Run("strings as tags", func(t *testing.T) {
+		storage, client := newTestInfluxDBStorage(func(config *InfluxDBStorageConfig) {
+			config.TagFields = []string{"suspended"}
+		})
+		require.NoError(t, storage.Save(context.Background(), sd))
+
+		point := client.points()[0]
+		assert.Equal(t, "parquet_test", point.Name())
+		assert.Equal(t, map[string]string{"suspended": "false", "symbol": "600000"}, pointTags(point))
+		assert.Equal(t, map[string]interface{}{
+			"volume":    int64(1200),
+			"price":     10.55,
+			"timestamp": "2025-08-25T10:30:00Z",
+			"bid":       `[{"price":10.54,"volume":300}]`,
+		}, pointFields(point))
+	})
+
+	t.Run("strings as fields", func(t *testing.T) {
+		storage, client := newTestInfluxDBStorage(func(config *InfluxDBStorageConfig) {
+			config.StringsAsTags = false
+		})
+		require.NoError(t, storage.Save(context.Background(), sd))
+
+		point := client.points()[0]
+		assert.Empty(t, point.TagList())
+		fields := pointFields(point)
+		assert.Equal(t, "600000", fields["symbol"])
+		assert.Equal(t, false, fields["suspended"])
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		storage, client := newTestInfluxDBStorage(nil)
+		assert.Error(t, storage.Save(context.Background(), map[string]interface{}{"symbol": "600000"}))
+		assert.Empty(t, client.points())
+		assert.Equal(t, int64(1), storage.GetStats().WriteErrors)
+	})
+}
+
+func TestInfluxDBStorage_BatchSave(t *testing.T) {
+	storage, client := newTestInfluxDBStorage(func(config *InfluxDBStorageConfig) {
+		config.BatchSize = 2
+	})
+	ctx := context.Background()
+
+	batch := make([]interface{}, 5)
+	for i := range batch {
+		batch[i] = core.StockData{Symbol: "600000", Price: float64(i), Timestamp: time.Unix(int64(i), 0)}
+	}
+	require.NoError(t, storage.BatchSave(ctx, batch))
+
+	require.Len(t, client.batches, 3)
+	assert.Len(t, client.batches[0], 2)
+	assert.Len(t, client.batches[2], 1)
+	stats := storage.GetStats()
+	assert.Equal(t, int64(5), stats.TotalRecords)
+	assert.Equal(t, int64(3), stats.TotalBatches)
+
+	errWrite := errors.New("connection refused")
+	client.writeErr = errWrite
+	err := storage.BatchSave(ctx, batch)
+	assert.ErrorIs(t, err, errWrite)
+	assert.Equal(t, int64(1), storage.GetStats().WriteErrors, "第一批失败后停止写入")
+
+	require.NoError(t, storage.Close())
+	assert.True(t, client.closed)
+}
+
+func TestInfluxDBStorage_BuildFluxQuery(t *testing.T) {
+	storage, _ := newTestInfluxDBStorage(nil)
+	start := time.Date(2025, 8, 25, 9, 30, 0, 0, time.FixedZone("CST", 8*3600))
+
+	flux, err := storage.buildFluxQuery(core.Query{
+		Symbols:   []string{"600000", "000001"},
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		Limit:     10,
+		Offset:    5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `from(bucket: "stock_data")
+	|> range(start: 2025-08-25T01:30:00Z, stop: 2025-08-25T02:30:00.000000001Z)
+	|> filter(fn: (r) => r.symbol == "600000" or r.symbol == "000001")
+	|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+	|> group()
+	|> sort(columns: ["_time"], desc: false)
+	|> limit(n: 10, offset: 5)
+`, flux)
+
+	flux, err = storage.buildFluxQuery(core.Query{})
+	require.NoError(t, err)
+	assert.Contains(t, flux, "range(start: 0, stop: now())")
+	assert.NotContains(t, flux, "filter")
+	assert.NotContains(t, flux, "limit")
+
+	t.Run("conditions and sort", func(t *testing.T) {
+		flux, err := storage.buildFluxQuery(core.Query{
+			Conditions: []core.Condition{
+				{Field: "price", Op: core.OpGte, Value: 10},
+				{Field: "name", Op: core.OpEq, Value: `浦发"银行`},
+				{Field: "volume", Op: core.OpIn, Value: []int64{100, 200}},
+			},
+			SortBy:   "price",
+			SortDesc: true,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, flux, `|> filter(fn: (r) => exists r["price"] and float(v: r["price"]) >= 10.0)`)
+		assert.Contains(t, flux, `|> filter(fn: (r) => exists r["name"] and r["name"] == "浦发\"银行")`)
+		assert.Contains(t, flux, `|> filter(fn: (r) => exists r["volume"] and contains(value: float(v: r["volume"]), set: [100.0, 200.0]))`)
+		assert.Contains(t, flux, `|> sort(columns: ["price"], desc: true)`)
+		assert.Less(t, strings.Index(flux, "pivot"), strings.Index(flux, `r["price"]`), "字段条件在 pivot 之后执行")
+	})
+
+	t.Run("symbol as field", func(t *testing.T) {
+		storage, _ := newTestInfluxDBStorage(func(config *InfluxDBStorageConfig) {
+			config.StringsAsTags = false
+		})
+		flux, err := storage.buildFluxQuery(core.Query{Symbols: []string{"600000"}})
+		require.NoError(t, err)
+		assert.Less(t, strings.Index(flux, "pivot"), strings.Index(flux, "r.symbol"))
+	})
+
+	t.Run("invalid conditions", func(t *testing.T) {
+		for _, cond := range []core.Condition{
+			{Field: "price", Op: "LIKE", Value: 1},
+			{Field: "timestamp", Op: core.OpGt, Value: start},
+			{Field: "volume", Op: core.OpIn, Value: []interface{}{1, "a"}},
+		} {
+			_, err := storage.buildFluxQuery(core.Query{Conditions: []core.Condition{cond}})
+			assert.Error(t, err, cond)
+		}
+	})
+}
+
+func TestInfluxDBStorage_Load(t *testing.T) {
+	ts := time.Date(2025, 8, 25, 10, 30, 0, 0, time.UTC)
+	storage, client := newTestInfluxDBStorage(func(config *InfluxDBStorageConfig) {
+		config.Schemas = []*DataSchema{parquetTestSchema}
+	})
+	client.rows = []map[string]interface{}{
+		{
+			"result": "_result", "table": int64(0), "_measurement": "stock_realtime", "_time": ts,
+			"symbol": "600000", "price": 10.5, "volume": int64(1200), "provider": "tencent",
+		},
+		{
+			"_measurement": "parquet_test", "_time": ts,
+			"symbol": "600000", "volume": int64(1200), "price": 10.55, "suspended": "false",
+			"timestamp": "2025-08-25T10:30:00Z", "bid": `[{"price":10.54,"volume":300}]`,
+		},
+		{"_measurement": "index_realtime", "_time": ts, "symbol": "000001", "value": 3200.5},
+	}
+
+	results, err := storage.Load(context.Background(), core.Query{Symbols: []string{"600000"}})
+	require.NoError(t, err)
+	require.Len(t, client.queries, 1)
+	require.Len(t, results, 3)
+
+	stock, ok := results[0].(*StructuredData)
+	require.True(t, ok)
+	assert.Equal(t, StockDataSchema, stock.Schema)
+	stockData, err := StructuredDataToStockData(stock)
+	require.NoError(t, err)
+	assert.Equal(t, "600000", stockData.Symbol)
+	assert.Equal(t, int64(1200), stockData.Volume)
+	assert.NotContains(t, stock.Values, "provider", "模式中不存在的列被忽略")
+
+	sd := results[1].(*StructuredData)
+	expected := newParquetTestData(t, "600000", ts)
+	assert.Equal(t, expected.Values, sd.Values, "tag 中的布尔值和 JSON 编码的数组按字段类型还原")
+	assert.True(t, ts.Equal(sd.Timestamp))
+
+	assert.Equal(t, map[string]interface{}{
+		"measurement": "index_realtime", "timestamp": ts, "symbol": "000001", "value": 3200.5,
+	}, results[2])
+
+	results, err = storage.Load(context.Background(), core.Query{Offset: 2})
+	require.NoError(t, err)
+	assert.Len(t, results, 1, "未指定 Limit 时在返回前跳过 Offset 条记录")
+	assert.Equal(t, int64(2), storage.GetStats().TotalQueries)
+}
+
+func TestInfluxDBStorage_RoundTripThroughSave(t *testing.T) {
+	storage, client := newTestInfluxDBStorage(nil)
+	sd := newParquetTestData(t, "600000", time.Now())
+	require.NoError(t, storage.Save(context.Background(), sd))
+
+	// Save 过的模式无需预先注册即可还原
+	client.rows = []map[string]interface{}{{"_measurement": "parquet_test", "_time": sd.Timestamp, "symbol": "600000"}}
+	results, err := storage.Load(context.Background(), core.Query{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, parquetTestSchema, results[0].(*StructuredData).Schema)
+}
+
+func TestInfluxDBStorage_Delete(t *testing.T) {
+	storage, client := newTestInfluxDBStorage(nil)
+	ctx := context.Background()
+
+	assert.Error(t, storage.Delete(ctx, core.Query{}), "拒绝清空整个 bucket")
+	assert.Error(t, storage.Delete(ctx, core.Query{
+		Symbols:    []string{"600000"},
+		Conditions: []core.Condition{{Field: "price", Op: core.OpGt, Value: 1}},
+	}))
+	assert.Empty(t, client.deletes)
+
+	require.NoError(t, storage.Delete(ctx, core.Query{Symbols: []string{"600000", "000001"}}))
+	assert.Equal(t, []string{`symbol="600000"`, `symbol="000001"`}, client.deletes)
+}
+
+func TestNewInfluxDBStorage_RequiresConnectionConfig(t *testing.T) {
+	config := DefaultInfluxDBStorageConfig()
+	config.Bucket = ""
+	_, err := NewInfluxDBStorage(config)
+	assert.Error(t, err)
+}
@@ -4,6 +4,8 @@ package storage_test
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"testing"
 	"time"
 
@@ -116,3 +118,53 @@ func TestStorage_InterfaceCompliance_Integration(t *testing.T) {
 
 	t.Log("✅ 所有存储实现都符合Storage接口")
 }
+
+// TestInfluxDBStorage_SaveAndLoad_Integration 测试 InfluxDBStorage 与真实 InfluxDB 的读写，
+// 需要设置 INFLUXDB_URL、INFLUXDB_TOKEN、INFLUXDB_ORG 和 INFLUXDB_BUCKET
+func TestInfluxDBStorage_SaveAndLoad_Integration(t *testing.T) {
+	url := os.Getenv("INFLUXDB_URL")
+	if url == "" {
+		t.Skip("未设置 INFLUXDB_URL，跳过 InfluxDB 集成测试")
+	}
+	cfg := storage.DefaultInfluxDBStorageConfig()
+	cfg.URL = url
+	cfg.Token = os.Getenv("INFLUXDB_TOKEN")
+	if org := os.Getenv("INFLUXDB_ORG"); org != "" {
+		cfg.Org = org
+	}
+	if bucket := os.Getenv("INFLUXDB_BUCKET"); bucket != "" {
+		cfg.Bucket = bucket
+	}
+	// 使用独立的 measurement，避免与采集器写入的数据混在一起
+	cfg.StockMeasurement = "stock_storage_integration"
+
+	influxStorage, err := storage.NewInfluxDBStorage(cfg)
+	require.NoError(t, err)
+	defer influxStorage.Close()
+
+	ctx := context.Background()
+	symbol := fmt.Sprintf("IT%d", time.Now().UnixNano()%1000000)
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	var batch []interface{}
+	for i := 0; i < 3; i++ {
+		batch = append(batch, core.StockData{Symbol: symbol, Price: 10 + float64(i), Volume: 100, Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	require.NoError(t, influxStorage.BatchSave(ctx, batch))
+	defer influxStorage.Delete(ctx, core.Query{Symbols: []string{symbol}, StartTime: start.Add(-time.Second)})
+
+	results, err := influxStorage.Load(ctx, core.Query{Symbols: []string{symbol}, StartTime: start, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	stock, err := storage.StructuredDataToStockData(results[0].(*storage.StructuredData))
+	require.NoError(t, err)
+	assert.Equal(t, symbol, stock.Symbol)
+	assert.Equal(t, 10.0, stock.Price)
+
+	results, err = influxStorage.Load(ctx, core.Query{
+		Symbols:    []string{symbol},
+		StartTime:  start,
+		Conditions: []core.Condition{{Field: "price", Op: core.OpGt, Value: 11}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
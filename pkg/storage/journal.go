@@ -0,0 +1,571 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// JournalSyncPolicy 定义了预写日志的 fsync 策略。
+type JournalSyncPolicy string
+
+const (
+	// JournalSyncAlways 每次追加后立即 fsync，最安全但最慢。
+	JournalSyncAlways JournalSyncPolicy = "always"
+	// JournalSyncEveryN 每追加 N 条记录执行一次 fsync。
+	JournalSyncEveryN JournalSyncPolicy = "every_n"
+	// JournalSyncInterval 按固定时间间隔执行 fsync。
+	JournalSyncInterval JournalSyncPolicy = "interval"
+)
+
+const (
+	journalEntryRecord byte = 1 // 已接受的数据记录
+	journalEntryAck    byte = 2 // 已成功写入存储的确认
+
+	journalHeaderSize = 8     // 4 字节长度 + 4 字节 CRC32
+	journalBodyPrefix = 1 + 8 // 1 字节类型 + 8 字节序号
+
+	defaultJournalCompactThreshold = 4 << 20 // 默认在已确认条目累计 4MB 后压缩
+)
+
+// JournalConfig 定义了 BatchWriter 预写日志的配置选项。
+type JournalConfig struct {
+	Path         string            `yaml:"path"`          // 日志文件路径，为空表示不启用日志。
+	MaxSize      int64             `yaml:"max_size"`      // 日志文件的最大字节数，超过后拒绝写入，0 表示不限制。
+	SyncPolicy   JournalSyncPolicy `yaml:"sync_policy"`   // fsync 策略。
+	SyncEveryN   int               `yaml:"sync_every_n"`  // SyncPolicy 为 every_n 时每多少条记录 fsync 一次。
+	SyncInterval time.Duration     `yaml:"sync_interval"` // SyncPolicy 为 interval 时的 fsync 间隔。
+	Codec        JournalCodec      `yaml:"-"`             // 记录编解码器，为空时使用 JSONJournalCodec。
+	// CompactThreshold 是触发压缩的已确认字节数，压缩时只保留未确认的记录重写日志。
+	// 0 表示使用默认值（4MB，设置了 MaxSize 时不超过其一半）。
+	CompactThreshold int64 `yaml:"compact_threshold"`
+}
+
+// JournalCodec 定义了日志记录与字节流之间的转换。
+type JournalCodec interface {
+	// Encode 将一条待写入的记录编码为字节数组。
+	Encode(record interface{}) ([]byte, error)
+	// Decode 将字节数组还原为可交给 Storage 保存的记录。
+	Decode(payload []byte) (interface{}, error)
+}
+
+// journalEntry 是从日志中恢复出的一条未确认记录。
+type journalEntry struct {
+	seq    uint64
+	record interface{}
+}
+
+// writeJournal 是 BatchWriter 使用的预写日志文件。
+// 文件由若干条目组成，每个条目格式为：长度(4) | CRC32(4) | 类型(1) | 序号(8) | 负载。
+type writeJournal struct {
+	mu        sync.Mutex
+	file      *os.File
+	config    JournalConfig
+	codec     JournalCodec
+	size      int64
+	discarded int64            // 打开时因损坏被丢弃的尾部字节数
+	live      map[uint64]int64 // 未确认记录的序号及其条目字节数
+	liveBytes int64            // 未确认记录条目的总字节数
+	nextSeq   uint64
+	unsynced  int
+	stopChan  chan struct{}
+	wg        sync.WaitGroup
+}
+
+// openWriteJournal 打开（或创建）预写日志，并返回其中尚未确认的记录。
+func openWriteJournal(config JournalConfig) (*writeJournal, []journalEntry, error) {
+	if config.Path == "" {
+		return nil, nil, fmt.Errorf("journal path cannot be empty")
+	}
+	if config.SyncPolicy == "" {
+		config.SyncPolicy = JournalSyncAlways
+	}
+	if config.SyncPolicy == JournalSyncEveryN && config.SyncEveryN <= 0 {
+		return nil, nil, fmt.Errorf("journal sync_every_n must be positive")
+	}
+	if config.SyncPolicy == JournalSyncInterval && config.SyncInterval <= 0 {
+		return nil, nil, fmt.Errorf("journal sync_interval must be positive")
+	}
+	if config.CompactThreshold < 0 {
+		return nil, nil, fmt.Errorf("journal compact_threshold cannot be negative")
+	}
+	if config.CompactThreshold == 0 {
+		config.CompactThreshold = defaultJournalCompactThreshold
+		if config.MaxSize > 0 && config.CompactThreshold > config.MaxSize/2 {
+			config.CompactThreshold = config.MaxSize / 2
+		}
+	}
+	codec := config.Codec
+	if codec == nil {
+		codec = NewJSONJournalCodec()
+	}
+
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create journal directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(config.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	j := &writeJournal{
+		file:     file,
+		config:   config,
+		codec:    codec,
+		live:     make(map[uint64]int64),
+		nextSeq:  1,
+		stopChan: make(chan struct{}),
+	}
+
+	pending, err := j.load()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	if config.SyncPolicy == JournalSyncInterval {
+		j.wg.Add(1)
+		go j.runPeriodicSync()
+	}
+
+	return j, pending, nil
+}
+
+// load 扫描日志文件，截断损坏的尾部，并返回未确认的记录。
+func (j *writeJournal) load() ([]journalEntry, error) {
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	info, err := j.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat journal: %w", err)
+	}
+
+	reader := bufio.NewReader(j.file)
+	records := make(map[uint64]interface{})
+	var order []uint64
+	var offset int64
+
+	for {
+		kind, seq, payload, n, err := readJournalEntry(reader)
+		if err != nil {
+			// 文件末尾或崩溃时写了一半的条目，丢弃其后的内容，字节数同时通过 BatchWriterStats.JournalDiscarded 上报
+			if j.discarded = info.Size() - offset; j.discarded > 0 {
+				fmt.Printf("BatchWriter journal warning: %s: discarding %d corrupted bytes at offset %d: %v\n",
+					j.config.Path, j.discarded, offset, err)
+			}
+			break
+		}
+		offset += n
+
+		if seq >= j.nextSeq {
+			j.nextSeq = seq + 1
+		}
+
+		switch kind {
+		case journalEntryRecord:
+			record, err := j.codec.Decode(payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode journal record %d: %w", seq, err)
+			}
+			records[seq] = record
+			order = append(order, seq)
+			j.trackLive(seq, n)
+		case journalEntryAck:
+			delete(records, seq)
+			j.untrackLive(seq)
+		}
+	}
+
+	if err := j.file.Truncate(offset); err != nil {
+		return nil, fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if _, err := j.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek journal: %w", err)
+	}
+	j.size = offset
+
+	pending := make([]journalEntry, 0, len(records))
+	for _, seq := range order {
+		if record, ok := records[seq]; ok {
+			pending = append(pending, journalEntry{seq: seq, record: record})
+		}
+	}
+	return pending, nil
+}
+
+// readJournalEntry 读取一个条目并校验其完整性，返回读取的字节数。
+func readJournalEntry(r io.Reader) (byte, uint64, []byte, int64, error) {
+	var header [journalHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, 0, nil, 0, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length < journalBodyPrefix {
+		return 0, 0, nil, 0, fmt.Errorf("journal entry too short: %d", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, 0, nil, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != checksum {
+		return 0, 0, nil, 0, fmt.Errorf("journal entry checksum mismatch")
+	}
+
+	seq := binary.BigEndian.Uint64(body[1:journalBodyPrefix])
+	return body[0], seq, body[journalBodyPrefix:], int64(journalHeaderSize) + int64(length), nil
+}
+
+// encodeJournalEntry 将条目编码为带长度前缀和校验和的字节数组。
+func encodeJournalEntry(kind byte, seq uint64, payload []byte) []byte {
+	body := make([]byte, journalBodyPrefix+len(payload))
+	body[0] = kind
+	binary.BigEndian.PutUint64(body[1:journalBodyPrefix], seq)
+	copy(body[journalBodyPrefix:], payload)
+
+	entry := make([]byte, journalHeaderSize+len(body))
+	binary.BigEndian.PutUint32(entry[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(entry[4:8], crc32.ChecksumIEEE(body))
+	copy(entry[journalHeaderSize:], body)
+	return entry
+}
+
+// Append 将一条记录追加到日志，返回分配的序号。
+// 当日志大小超过 MaxSize 时返回 ErrJournalFull。
+func (j *writeJournal) Append(record interface{}) (uint64, error) {
+	payload, err := j.codec.Encode(record)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode journal record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	seq := j.nextSeq
+	entry := encodeJournalEntry(journalEntryRecord, seq, payload)
+	if j.config.MaxSize > 0 && j.size+int64(len(entry)) > j.config.MaxSize && j.size > j.liveBytes {
+		// 已确认的条目仍占着空间，先压缩再判断是否真的写满
+		if err := j.compact(); err != nil {
+			return 0, err
+		}
+	}
+	if j.config.MaxSize > 0 && j.size+int64(len(entry)) > j.config.MaxSize {
+		return 0, ErrJournalFull
+	}
+
+	if err := j.write(entry); err != nil {
+		return 0, err
+	}
+	j.trackLive(seq, int64(len(entry)))
+	j.nextSeq++
+
+	return seq, j.maybeSync()
+}
+
+// Ack 标记记录已成功写入存储。确认条目不受 MaxSize 限制，以保证日志总能被清空。
+// 已确认的字节数超过 CompactThreshold 时会压缩日志，避免仍有记录未确认时文件无限增长。
+func (j *writeJournal) Ack(seqs ...uint64) error {
+	if len(seqs) == 0 {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	buf := make([]byte, 0, len(seqs)*(journalHeaderSize+journalBodyPrefix))
+	for _, seq := range seqs {
+		buf = append(buf, encodeJournalEntry(journalEntryAck, seq, nil)...)
+	}
+	if err := j.write(buf); err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		j.untrackLive(seq)
+	}
+
+	if j.size-j.liveBytes >= j.config.CompactThreshold {
+		return j.compact()
+	}
+	return j.maybeSync()
+}
+
+// Reset 清空日志文件，在所有记录都已确认后调用。
+func (j *writeJournal) Reset() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+	j.size = 0
+	j.unsynced = 0
+	j.live = make(map[uint64]int64)
+	j.liveBytes = 0
+
+	return j.file.Sync()
+}
+
+// compact 将未确认的记录写入临时文件后替换原日志（需要在锁内调用）。
+// 替换通过 rename 完成，压缩中途崩溃时原日志保持完整。
+func (j *writeJournal) compact() error {
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	if _, err := j.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+
+	tmpPath := j.config.Path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted journal: %w", err)
+	}
+
+	size, err := j.copyLive(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, j.config.Path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		// 恢复原文件的写入位置，压缩失败不影响继续追加
+		if _, seekErr := j.file.Seek(j.size, io.SeekStart); seekErr != nil {
+			return fmt.Errorf("failed to seek journal: %w", seekErr)
+		}
+		return fmt.Errorf("failed to compact journal: %w", err)
+	}
+
+	j.file.Close()
+	j.file = tmp
+	j.size = size
+	j.liveBytes = size
+	j.unsynced = 0
+	return nil
+}
+
+// copyLive 从当前日志读取未确认的记录条目并写入 dst，返回写入的字节数（需要在锁内调用）。
+func (j *writeJournal) copyLive(dst io.Writer) (int64, error) {
+	reader := bufio.NewReader(io.LimitReader(j.file, j.size))
+	writer := bufio.NewWriter(dst)
+	var size int64
+	for {
+		kind, seq, payload, _, err := readJournalEntry(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read journal: %w", err)
+		}
+		if _, ok := j.live[seq]; !ok || kind != journalEntryRecord {
+			continue
+		}
+		entry := encodeJournalEntry(kind, seq, payload)
+		if _, err := writer.Write(entry); err != nil {
+			return 0, err
+		}
+		size += int64(len(entry))
+	}
+	return size, writer.Flush()
+}
+
+// trackLive 记录一条未确认的记录条目（需要在锁内调用）。
+func (j *writeJournal) trackLive(seq uint64, n int64) {
+	j.live[seq] = n
+	j.liveBytes += n
+}
+
+// untrackLive 移除已确认的记录条目（需要在锁内调用）。
+func (j *writeJournal) untrackLive(seq uint64) {
+	if n, ok := j.live[seq]; ok {
+		delete(j.live, seq)
+		j.liveBytes -= n
+	}
+}
+
+// Size 返回当前日志文件大小。
+func (j *writeJournal) Size() int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.size
+}
+
+// Close 同步并关闭日志文件。
+func (j *writeJournal) Close() error {
+	close(j.stopChan)
+	j.wg.Wait()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return j.file.Close()
+}
+
+// write 写入原始字节（需要在锁内调用）。
+func (j *writeJournal) write(data []byte) error {
+	n, err := j.file.Write(data)
+	j.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.unsynced++
+	return nil
+}
+
+// maybeSync 根据 fsync 策略决定是否同步（需要在锁内调用）。
+func (j *writeJournal) maybeSync() error {
+	switch j.config.SyncPolicy {
+	case JournalSyncAlways:
+	case JournalSyncEveryN:
+		if j.unsynced < j.config.SyncEveryN {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	j.unsynced = 0
+	return nil
+}
+
+// runPeriodicSync 按固定间隔同步日志文件。
+func (j *writeJournal) runPeriodicSync() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			if j.unsynced > 0 {
+				if err := j.file.Sync(); err == nil {
+					j.unsynced = 0
+				}
+			}
+			j.mu.Unlock()
+		case <-j.stopChan:
+			return
+		}
+	}
+}
+
+// JSONJournalCodec 使用 JSON 编码日志记录，支持 core.StockData 和已注册 schema 的 StructuredData。
+type JSONJournalCodec struct {
+	schemas map[string]*DataSchema
+}
+
+// journalEnvelope 是 JSONJournalCodec 的编码格式。
+type journalEnvelope struct {
+	Kind      string                 `json:"kind"`
+	Schema    string                 `json:"schema,omitempty"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+	Timestamp time.Time              `json:"timestamp,omitempty"`
+	Stock     *core.StockData        `json:"stock,omitempty"`
+	Pointer   bool                   `json:"pointer,omitempty"`
+}
+
+// NewJSONJournalCodec 创建 JSON 日志编解码器，StockDataSchema 默认已注册。
+func NewJSONJournalCodec(schemas ...*DataSchema) *JSONJournalCodec {
+	c := &JSONJournalCodec{schemas: map[string]*DataSchema{StockDataSchema.Name: StockDataSchema}}
+	for _, schema := range schemas {
+		c.schemas[schema.Name] = schema
+	}
+	return c
+}
+
+// Encode 实现 JournalCodec 接口。
+func (c *JSONJournalCodec) Encode(record interface{}) ([]byte, error) {
+	var env journalEnvelope
+
+	switch v := record.(type) {
+	case *StructuredData:
+		if v.Schema == nil {
+			return nil, fmt.Errorf("StructuredData 缺少 schema 定义")
+		}
+		if _, ok := c.schemas[v.Schema.Name]; !ok {
+			return nil, NewStorageError(ErrSchemaNotFound, fmt.Sprintf("schema %s not registered in journal codec", v.Schema.Name))
+		}
+		env = journalEnvelope{Kind: "structured_data", Schema: v.Schema.Name, Values: v.Values, Timestamp: v.Timestamp}
+	case core.StockData:
+		env = journalEnvelope{Kind: "stock_data", Stock: &v}
+	case *core.StockData:
+		env = journalEnvelope{Kind: "stock_data", Stock: v, Pointer: true}
+	default:
+		return nil, fmt.Errorf("unsupported journal record type %T", record)
+	}
+
+	return json.Marshal(env)
+}
+
+// Decode 实现 JournalCodec 接口。
+func (c *JSONJournalCodec) Decode(payload []byte) (interface{}, error) {
+	var env journalEnvelope
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal journal record: %w", err)
+	}
+
+	switch env.Kind {
+	case "structured_data":
+		schema, ok := c.schemas[env.Schema]
+		if !ok {
+			return nil, NewStorageError(ErrSchemaNotFound, fmt.Sprintf("schema %s not registered in journal codec", env.Schema))
+		}
+		sd := NewStructuredData(schema)
+		sd.Timestamp = env.Timestamp
+		for name, raw := range env.Values {
+			fieldDef, ok := schema.Fields[name]
+			if !ok {
+				continue
+			}
+			value, err := coerceJSONValue(raw, fieldDef)
+			if err != nil {
+				return nil, NewStructuredDataError(ErrInvalidFieldType, name, err.Error())
+			}
+			sd.Values[name] = value
+		}
+		return sd, nil
+	case "stock_data":
+		if env.Stock == nil {
+			return nil, fmt.Errorf("journal stock_data record missing payload")
+		}
+		if env.Pointer {
+			return env.Stock, nil
+		}
+		return *env.Stock, nil
+	default:
+		return nil, fmt.Errorf("unknown journal record kind %q", env.Kind)
+	}
+}
@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// errSimulatedCrash 用于模拟进程在刷新过程中被终止
+var errSimulatedCrash = errors.New("simulated crash")
+
+// crashingStorage 只实现逐条 Save 的存储，在第 crashAfter 次保存时模拟进程崩溃
+type crashingStorage struct {
+	mu         sync.Mutex
+	saved      []string
+	crashAfter int
+	reject     string // 保存该代码时返回错误，为空表示不拒绝
+}
+
+func (s *crashingStorage) Save(ctx context.Context, data interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.crashAfter > 0 && len(s.saved) == s.crashAfter {
+		panic(errSimulatedCrash)
+	}
+	sd, ok := data.(core.StockData)
+	if !ok {
+		return fmt.Errorf("unexpected type %T", data)
+	}
+	if s.reject != "" && sd.Symbol == s.reject {
+		return fmt.Errorf("rejected %s", sd.Symbol)
+	}
+	s.saved = append(s.saved, sd.Symbol)
+	return nil
+}
+
+func (s *crashingStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (s *crashingStorage) Delete(ctx context.Context, query core.Query) error { return nil }
+
+func (s *crashingStorage) Close() error { return nil }
+
+func (s *crashingStorage) symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := append([]string(nil), s.saved...)
+	sort.Strings(result)
+	return result
+}
+
+func journalTestConfig(path string) BatchWriterConfig {
+	return BatchWriterConfig{
+		BatchSize:     100,
+		MaxBufferSize: 1000,
+		EnableAsync:   true,
+		Journal: JournalConfig{
+			Path:       path,
+			SyncPolicy: JournalSyncAlways,
+		},
+	}
+}
+
+// simulateCrash 在不刷新缓冲区的情况下终止 BatchWriter
+func simulateCrash(bw *BatchWriter) {
+	if bw.flushTicker != nil {
+		bw.flushTicker.Stop()
+	}
+	close(bw.stopChan)
+	bw.journal.file.Close()
+}
+
+func TestBatchWriter_Journal_RecoversUnflushedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	backend := &crashingStorage{}
+	ctx := context.Background()
+
+	bw, err := NewBatchWriterWithJournal(ctx, backend, journalTestConfig(path))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("60000%d", i), Price: float64(i)}))
+	}
+	simulateCrash(bw)
+	assert.Empty(t, backend.symbols())
+
+	bw, err = NewBatchWriterWithJournal(ctx, backend, journalTestConfig(path))
+	require.NoError(t, err)
+	defer bw.Close()
+
+	assert.Equal(t, int64(5), bw.GetStats().JournalRecovered)
+	assert.Equal(t, []string{"600000", "600001", "600002", "600003", "600004"}, backend.symbols())
+	assert.Equal(t, int64(0), bw.GetStats().JournalSize)
+}
+
+func TestBatchWriter_EnableWAL_RecoverFromWALReplaysExactlyOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	backend := &crashingStorage{}
+	ctx := context.Background()
+	config := journalTestConfig(path)
+
+	bw := NewBatchWriter(backend, config)
+	require.NoError(t, bw.EnableWAL(config.Journal))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("60000%d", i)}))
+	}
+	simulateCrash(bw)
+
+	bw = NewBatchWriter(backend, config)
+	defer bw.Close()
+	require.NoError(t, bw.EnableWAL(config.Journal))
+	assert.Error(t, bw.EnableWAL(config.Journal), "不能重复启用")
+	assert.Empty(t, backend.symbols(), "RecoverFromWAL 之前不应写入存储")
+
+	recovered, err := bw.RecoverFromWAL(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, recovered)
+
+	recovered, err = bw.RecoverFromWAL(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, recovered, "再次调用不应重复重放")
+	assert.Equal(t, []string{"600000", "600001", "600002"}, backend.symbols())
+	assert.Equal(t, int64(3), bw.GetStats().JournalRecovered)
+	assert.Equal(t, int64(0), bw.GetStats().JournalSize)
+}
+
+func TestBatchWriter_RecoverFromWAL_RequiresEnableWAL(t *testing.T) {
+	bw := NewBatchWriter(&crashingStorage{}, journalTestConfig(""))
+	defer bw.Close()
+
+	_, err := bw.RecoverFromWAL(context.Background())
+	assert.Error(t, err)
+}
+
+func TestBatchWriter_Journal_CrashMidFlushReplaysOnlyMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	backend := &crashingStorage{crashAfter: 3}
+	ctx := context.Background()
+
+	bw, err := NewBatchWriterWithJournal(ctx, backend, journalTestConfig(path))
+	require.NoError(t, err)
+
+	var expected []string
+	for i := 0; i < 6; i++ {
+		symbol := fmt.Sprintf("00000%d", i)
+		expected = append(expected, symbol)
+		require.NoError(t, bw.Write(ctx, core.StockData{Symbol: symbol}))
+	}
+
+	// 刷新到第 4 条时进程崩溃
+	assert.PanicsWithValue(t, errSimulatedCrash, func() { _ = bw.Flush() })
+	simulateCrash(bw)
+	assert.Len(t, backend.symbols(), 3)
+
+	backend.crashAfter = 0
+	bw, err = NewBatchWriterWithJournal(ctx, backend, journalTestConfig(path))
+	require.NoError(t, err)
+	defer bw.Close()
+
+	assert.Equal(t, int64(3), bw.GetStats().JournalRecovered)
+	assert.Equal(t, expected, backend.symbols(), "每条记录恰好写入一次")
+}
+
+func TestBatchWriter_Journal_TruncatedAfterSuccessfulFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	ctx := context.Background()
+	ms := NewMemoryStorage(DefaultMemoryStorageConfig())
+	defer ms.Close()
+
+	bw, err := NewBatchWriterWithJournal(ctx, ms, journalTestConfig(path))
+	require.NoError(t, err)
+
+	require.NoError(t, bw.Write(ctx, core.StockData{Symbol: "600000"}))
+	assert.Greater(t, bw.GetStats().JournalSize, int64(0))
+
+	require.NoError(t, bw.Flush())
+	assert.Equal(t, int64(0), bw.GetStats().JournalSize)
+	require.NoError(t, bw.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	// 重新打开后无需恢复任何记录
+	bw, err = NewBatchWriterWithJournal(ctx, ms, journalTestConfig(path))
+	require.NoError(t, err)
+	defer bw.Close()
+	assert.Equal(t, int64(0), bw.GetStats().JournalRecovered)
+}
+
+func TestBatchWriter_Journal_StructuredDataRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	ctx := context.Background()
+	ms := NewMemoryStorage(DefaultMemoryStorageConfig())
+	defer ms.Close()
+
+	config := journalTestConfig(path)
+	config.EnableStructuredDataOptim = true
+	config.StructuredDataBatchSize = 100
+	config.StructuredDataFlushDelay = time.Hour
+
+	bw, err := NewBatchWriterWithJournal(ctx, ms, config)
+	require.NoError(t, err)
+
+	ts := time.Date(2025, 8, 20, 10, 30, 0, 0, time.UTC)
+	sd := NewStructuredData(StockDataSchema)
+	require.NoError(t, sd.SetField("symbol", "600519"))
+	require.NoError(t, sd.SetField("name", "贵州茅台"))
+	require.NoError(t, sd.SetField("price", 1688.5))
+	require.NoError(t, sd.SetField("volume", int64(12345)))
+	require.NoError(t, sd.SetField("timestamp", ts))
+	require.NoError(t, bw.Write(ctx, sd))
+	simulateCrash(bw)
+
+	bw, err = NewBatchWriterWithJournal(ctx, ms, config)
+	require.NoError(t, err)
+	defer bw.Close()
+	assert.Equal(t, int64(1), bw.GetStats().JournalRecovered)
+
+	results, err := ms.QueryBySymbol(ctx, "600519")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1688.5, results[0].Values["price"])
+	assert.Equal(t, int64(12345), results[0].Values["volume"])
+	assert.True(t, ts.Equal(results[0].Values["timestamp"].(time.Time)))
+	assert.NoError(t, results[0].ValidateData())
+}
+
+func TestBatchWriter_Journal_IgnoresTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	backend := &crashingStorage{}
+	ctx := context.Background()
+
+	bw, err := NewBatchWriterWithJournal(ctx, backend, journalTestConfig(path))
+	require.NoError(t, err)
+	require.NoError(t, bw.Write(ctx, core.StockData{Symbol: "600000"}))
+	require.NoError(t, bw.Write(ctx, core.StockData{Symbol: "600036"}))
+	simulateCrash(bw)
+
+	// 模拟写入一半的条目：截掉最后一条记录的尾部
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	bw, err = NewBatchWriterWithJournal(ctx, backend, journalTestConfig(path))
+	require.NoError(t, err)
+	defer bw.Close()
+
+	assert.Equal(t, int64(1), bw.GetStats().JournalRecovered)
+	assert.Positive(t, bw.GetStats().JournalDiscarded, "损坏的尾部被跳过并计数")
+	assert.Equal(t, []string{"600000"}, backend.symbols())
+}
+
+func TestBatchWriter_Journal_RejectsWritesWhenFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	ctx := context.Background()
+
+	config := journalTestConfig(path)
+	config.Journal.MaxSize = 4096
+	bw, err := NewBatchWriterWithJournal(ctx, &crashingStorage{}, config)
+	require.NoError(t, err)
+	defer bw.Close()
+
+	var writeErr error
+	accepted := 0
+	for i := 0; i < 10 && writeErr == nil; i++ {
+		writeErr = bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("60000%d", i)})
+		if writeErr == nil {
+			accepted++
+		}
+	}
+
+	assert.ErrorIs(t, writeErr, ErrJournalFull)
+	assert.Greater(t, accepted, 0)
+	stats := bw.GetStats()
+	assert.Equal(t, int64(1), stats.JournalRejected)
+	assert.Equal(t, accepted, stats.BufferSize, "被拒绝的记录不应进入缓冲区")
+	assert.LessOrEqual(t, stats.JournalSize, int64(4096))
+
+	// 刷新后日志被清空，可以继续写入
+	require.NoError(t, bw.Flush())
+	assert.NoError(t, bw.Write(ctx, core.StockData{Symbol: "000001"}))
+}
+
+func TestBatchWriter_Journal_CompactsWhileRecordStaysUnacked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.journal")
+	ctx := context.Background()
+
+	config := journalTestConfig(path)
+	config.Journal.MaxSize = 4096
+	storage := &crashingStorage{reject: "BAD"}
+	bw, err := NewBatchWriterWithJournal(ctx, storage, config)
+	require.NoError(t, err)
+
+	// BAD 写入失败后一直保持未确认，其余记录持续写入并确认
+	require.NoError(t, bw.Write(ctx, core.StockData{Symbol: "BAD"}))
+	require.NoError(t, bw.Flush())
+	for i := 0; i < 200; i++ {
+		require.NoError(t, bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("%06d", i)}))
+		require.NoError(t, bw.Flush())
+	}
+
+	stats := bw.GetStats()
+	assert.Equal(t, int64(0), stats.JournalRejected, "已确认的条目应被压缩，不应占满日志")
+	assert.LessOrEqual(t, stats.JournalSize, int64(4096))
+	require.NoError(t, bw.Close())
+
+	// 重新打开后只恢复未确认的那一条记录
+	recovered := &crashingStorage{}
+	bw, err = NewBatchWriterWithJournal(ctx, recovered, config)
+	require.NoError(t, err)
+	defer bw.Close()
+	assert.Equal(t, int64(1), bw.GetStats().JournalRecovered)
+	assert.Equal(t, []string{"BAD"}, recovered.symbols())
+}
+
+func TestBatchWriter_Journal_SyncPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		journal     JournalConfig
+		expectError bool
+	}{
+		{name: "每次同步", journal: JournalConfig{SyncPolicy: JournalSyncAlways}},
+		{name: "每N条同步", journal: JournalConfig{SyncPolicy: JournalSyncEveryN, SyncEveryN: 2}},
+		{name: "定时同步", journal: JournalConfig{SyncPolicy: JournalSyncInterval, SyncInterval: 10 * time.Millisecond}},
+		{name: "每N条同步缺少N", journal: JournalConfig{SyncPolicy: JournalSyncEveryN}, expectError: true},
+		{name: "定时同步缺少间隔", journal: JournalConfig{SyncPolicy: JournalSyncInterval}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := journalTestConfig("")
+			config.Journal = tt.journal
+			config.Journal.Path = filepath.Join(t.TempDir(), "writer.journal")
+
+			bw, err := NewBatchWriterWithJournal(ctx, &crashingStorage{}, config)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				require.NoError(t, bw.Write(ctx, core.StockData{Symbol: fmt.Sprintf("60000%d", i)}))
+			}
+			time.Sleep(20 * time.Millisecond)
+			assert.NoError(t, bw.Close())
+		})
+	}
+}
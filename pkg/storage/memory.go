@@ -14,11 +14,12 @@ import (
 // MemoryStorage 是一种完全在内存中实现的 core.Storage 接口。
 // 它用于快速、无I/O的测试，所有数据在程序结束时会丢失。
 type MemoryStorage struct {
-	data    map[string][]interface{}
-	mu      sync.RWMutex
-	indexes map[string]*MemoryIndex
-	config  MemoryStorageConfig
-	stats   MemoryStorageStats
+	data      map[string][]interface{}
+	mu        sync.RWMutex
+	indexes   map[string]*MemoryIndex
+	secondary map[string]*tableIndexes // 表名 -> IndexFields 上的哈希索引
+	config    MemoryStorageConfig
+	stats     MemoryStorageStats
 }
 
 // MemoryStorageConfig 定义了 MemoryStorage 的配置选项。
@@ -27,6 +28,7 @@ type MemoryStorageConfig struct {
 	EnableIndex     bool          `yaml:"enable_index"`     // 是否为数据启用索引以加速查询。
 	TTL             time.Duration `yaml:"ttl"`              // 记录的生存时间。
 	CleanupInterval time.Duration `yaml:"cleanup_interval"` // 清理过期记录的后台任务运行间隔。
+	IndexFields     []string      `yaml:"index_fields"`     // 为 StructuredData 建立哈希索引的字段，Load 的等值和 IN 条件会使用这些索引。
 }
 
 // MemoryStorageStats 包含了 MemoryStorage 的运行统计信息。
@@ -35,6 +37,8 @@ type MemoryStorageStats struct {
 	TotalTables  int       `json:"total_tables"`  // 内部"表"的数量。
 	IndexCount   int       `json:"index_count"`   // 创建的索引数量。
 	LastCleanup  time.Time `json:"last_cleanup"`  // 最后一次清理的时间。
+
+	SecondaryIndexes map[string]SecondaryIndexStats `json:"secondary_indexes,omitempty"` // 各二级索引的大小，键为"表名.字段名"。
 }
 
 // MemoryIndex 为内存中的数据表提供索引功能。
@@ -53,10 +57,11 @@ type TimeRecord struct {
 // NewMemoryStorage 创建一个新的 MemoryStorage 实例。
 func NewMemoryStorage(config MemoryStorageConfig) *MemoryStorage {
 	ms := &MemoryStorage{
-		data:    make(map[string][]interface{}),
-		indexes: make(map[string]*MemoryIndex),
-		config:  config,
-		stats:   MemoryStorageStats{},
+		data:      make(map[string][]interface{}),
+		indexes:   make(map[string]*MemoryIndex),
+		secondary: make(map[string]*tableIndexes),
+		config:    config,
+		stats:     MemoryStorageStats{},
 	}
 
 	if config.CleanupInterval > 0 {
@@ -74,6 +79,7 @@ func (ms *MemoryStorage) Save(ctx context.Context, data interface{}) error {
 	tableName := ms.getTableName(data)
 
 	if len(ms.data[tableName]) >= ms.config.MaxRecords {
+		ms.unindexRecords(tableName, ms.data[tableName][:1])
 		ms.data[tableName] = ms.data[tableName][1:]
 	}
 
@@ -83,31 +89,61 @@ func (ms *MemoryStorage) Save(ctx context.Context, data interface{}) error {
 	if ms.config.EnableIndex {
 		ms.updateIndex(tableName, data, index)
 	}
+	ms.indexRecord(tableName, data)
 
 	ms.stats.TotalRecords++
 	return nil
 }
 
 // Load 从内存中加载数据。
+//
+// 等值或 IN 条件的字段在 IndexFields 中时，先通过哈希索引选出候选记录，否则扫描全表。
+// 指定 SortBy 时在所有匹配结果上排序后再应用 Offset 和 Limit。
 func (ms *MemoryStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
+	if err := validateConditions(query.Conditions); err != nil {
+		return nil, err
+	}
+
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
+	// 不排序时收集到 Offset+Limit 条即可停止
+	want := 0
+	if query.SortBy == "" && query.Limit > 0 {
+		want = query.Offset + query.Limit
+	}
+
 	results := make([]interface{}, 0)
+tables:
+	for tableName, records := range ms.data {
+		if t, exists := ms.secondary[tableName]; exists {
+			if candidates, ok := t.candidates(query.Conditions); ok {
+				for _, sd := range candidates {
+					if ms.matchesQuery(sd, query) {
+						results = append(results, sd)
+						if want > 0 && len(results) >= want {
+							break tables
+						}
+					}
+				}
+				continue
+			}
+		}
 
-	for _, records := range ms.data {
 		for _, record := range records {
 			if ms.matchesQuery(record, query) {
 				results = append(results, record)
-
-				if query.Limit > 0 && len(results) >= query.Limit {
-					return results, nil
+				if want > 0 && len(results) >= want {
+					break tables
 				}
 			}
 		}
 	}
 
-	return results, nil
+	if query.SortBy != "" {
+		sortRecords(results, query.SortBy, query.SortDesc)
+	}
+	return paginate(results, query.Offset, query.Limit), nil
 }
 
 // Delete 从内存中删除数据。
@@ -125,6 +161,9 @@ func (ms *MemoryStorage) Delete(ctx context.Context, query core.Query) error {
 		}
 
 		ms.data[tableName] = newRecords
+		if len(newRecords) != len(records) {
+			ms.rebuildSecondary(tableName)
+		}
 	}
 
 	return nil
@@ -137,6 +176,7 @@ func (ms *MemoryStorage) Close() error {
 
 	ms.data = make(map[string][]interface{})
 	ms.indexes = make(map[string]*MemoryIndex)
+	ms.secondary = make(map[string]*tableIndexes)
 
 	return nil
 }
@@ -185,6 +225,7 @@ func (ms *MemoryStorage) batchSaveToTable(tableName string, tableData []interfac
 		excessCount := totalSize - ms.config.MaxRecords
 		if excessCount >= currentSize {
 			// 新数据太多，只保留最新的
+			delete(ms.secondary, tableName)
 			ms.data[tableName] = ms.data[tableName][:0]
 			keepCount := ms.config.MaxRecords
 			if keepCount > newDataSize {
@@ -193,6 +234,7 @@ func (ms *MemoryStorage) batchSaveToTable(tableName string, tableData []interfac
 			tableData = tableData[newDataSize-keepCount:]
 		} else {
 			// 移除一些旧数据
+			ms.unindexRecords(tableName, ms.data[tableName][:excessCount])
 			ms.data[tableName] = ms.data[tableName][excessCount:]
 		}
 	}
@@ -207,6 +249,9 @@ func (ms *MemoryStorage) batchSaveToTable(tableName string, tableData []interfac
 			ms.updateIndex(tableName, data, startIndex+i)
 		}
 	}
+	for _, data := range tableData {
+		ms.indexRecord(tableName, data)
+	}
 
 	return nil
 }
@@ -234,11 +279,11 @@ func (ms *MemoryStorage) getTableName(data interface{}) string {
 func (ms *MemoryStorage) matchesQuery(record interface{}, query core.Query) bool {
 	// 如果是 StructuredData，使用专门的查询逻辑
 	if structData, ok := record.(*StructuredData); ok {
-		return ms.queryStructuredData(structData, query)
+		return ms.queryStructuredData(structData, query) && matchConditions(structData, query.Conditions)
 	}
 
-	// 对于其他类型，返回 true（保持原有行为）
-	return true
+	// 对于其他类型，没有字段条件时返回 true（保持原有行为）
+	return len(query.Conditions) == 0
 }
 
 // updateIndex 更新指定表的索引信息
@@ -327,6 +372,7 @@ func (ms *MemoryStorage) cleanup() {
 				}
 			}
 			ms.data[tableName] = validData
+			ms.rebuildSecondary(tableName)
 		}
 	}
 
@@ -454,7 +500,7 @@ func (ms *MemoryStorage) queryStructuredData(data *StructuredData, query core.Qu
 }
 
 // GetStats 返回内存存储的统计信息
-// 该方法会获取当前内存存储的状态信息，包括总表数和索引数
+// 该方法会获取当前内存存储的状态信息，包括总表数、索引数和各二级索引的大小
 // 返回值:
 //   - MemoryStorageStats: 包含存储统计信息的结构体，其中TotalTables表示总表数，IndexCount表示索引数
 //
@@ -466,6 +512,12 @@ func (ms *MemoryStorage) GetStats() MemoryStorageStats {
 	stats := ms.stats
 	stats.TotalTables = len(ms.data)
 	stats.IndexCount = len(ms.indexes)
+	if len(ms.secondary) > 0 {
+		stats.SecondaryIndexes = make(map[string]SecondaryIndexStats)
+		for tableName, t := range ms.secondary {
+			t.stats(tableName, stats.SecondaryIndexes)
+		}
+	}
 
 	return stats
 }
@@ -0,0 +1,398 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// SecondaryIndexStats 单个二级索引的统计信息
+type SecondaryIndexStats struct {
+	Keys    int `json:"keys"`    // 不同字段值的数量
+	Entries int `json:"entries"` // 索引的记录数量
+}
+
+// tableIndexes 单个表在 IndexFields 上的哈希索引
+type tableIndexes struct {
+	fields  map[string]*secondaryIndex
+	nextSeq uint64
+}
+
+// secondaryIndex 字段值到记录的哈希索引，每个值下的记录按写入顺序排列
+type secondaryIndex struct {
+	entries map[interface{}][]indexEntry
+	size    int
+}
+
+// indexEntry 索引中的一条记录，seq 为写入序号，用于合并多个值的结果时保持写入顺序
+type indexEntry struct {
+	seq  uint64
+	data *StructuredData
+}
+
+// timeKey 时间类型字段的索引键，避免与整数键混淆
+type timeKey int64
+
+func newTableIndexes(fields []string) *tableIndexes {
+	t := &tableIndexes{fields: make(map[string]*secondaryIndex, len(fields))}
+	for _, field := range fields {
+		t.fields[field] = &secondaryIndex{entries: make(map[interface{}][]indexEntry)}
+	}
+	return t
+}
+
+// add 将记录加入所有字段索引
+func (t *tableIndexes) add(data *StructuredData) {
+	seq := t.nextSeq
+	t.nextSeq++
+	for field, idx := range t.fields {
+		value, ok := recordFieldValue(data, field)
+		if !ok {
+			continue
+		}
+		key, ok := indexKey(value)
+		if !ok {
+			continue
+		}
+		idx.entries[key] = append(idx.entries[key], indexEntry{seq: seq, data: data})
+		idx.size++
+	}
+}
+
+// remove 从所有字段索引中移除记录，被淘汰的旧记录通常位于列表头部
+func (t *tableIndexes) remove(data *StructuredData) {
+	for field, idx := range t.fields {
+		value, ok := recordFieldValue(data, field)
+		if !ok {
+			continue
+		}
+		key, ok := indexKey(value)
+		if !ok {
+			continue
+		}
+		list := idx.entries[key]
+		for i, entry := range list {
+			if entry.data != data {
+				continue
+			}
+			if i == 0 {
+				list = list[1:]
+			} else {
+				list = append(list[:i:i], list[i+1:]...)
+			}
+			idx.size--
+			break
+		}
+		if len(list) == 0 {
+			delete(idx.entries, key)
+		} else {
+			idx.entries[key] = list
+		}
+	}
+}
+
+// candidates 利用等值或 IN 条件选出候选记录，多个条件可用时选择候选最少的索引
+//
+// 返回 false 表示没有可用的索引，需要全表扫描。
+func (t *tableIndexes) candidates(conditions []core.Condition) ([]*StructuredData, bool) {
+	var best []indexEntry
+	found := false
+	for _, cond := range conditions {
+		idx, exists := t.fields[cond.Field]
+		if !exists || (cond.Op != core.OpEq && cond.Op != core.OpIn) {
+			continue
+		}
+
+		var entries []indexEntry
+		if cond.Op == core.OpEq {
+			if key, ok := indexKey(cond.Value); ok {
+				entries = idx.entries[key]
+			}
+		} else {
+			values, _ := toInterfaceSlice(cond.Value)
+			for _, value := range values {
+				if key, ok := indexKey(value); ok {
+					entries = append(entries, idx.entries[key]...)
+				}
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+		}
+
+		if !found || len(entries) < len(best) {
+			best, found = entries, true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	results := make([]*StructuredData, 0, len(best))
+	for i, entry := range best {
+		// IN 中重复的值会产生相同的记录
+		if i > 0 && entry.seq == best[i-1].seq {
+			continue
+		}
+		results = append(results, entry.data)
+	}
+	return results, true
+}
+
+// stats 返回各字段索引的统计信息
+func (t *tableIndexes) stats(tableName string, into map[string]SecondaryIndexStats) {
+	for field, idx := range t.fields {
+		into[tableName+"."+field] = SecondaryIndexStats{Keys: len(idx.entries), Entries: idx.size}
+	}
+}
+
+// indexRecord 将新写入的 StructuredData 加入二级索引（需要持有写锁）
+func (ms *MemoryStorage) indexRecord(tableName string, data interface{}) {
+	sd, ok := data.(*StructuredData)
+	if !ok || len(ms.config.IndexFields) == 0 {
+		return
+	}
+	t, exists := ms.secondary[tableName]
+	if !exists {
+		t = newTableIndexes(ms.config.IndexFields)
+		ms.secondary[tableName] = t
+	}
+	t.add(sd)
+}
+
+// unindexRecords 从二级索引中移除被淘汰的记录（需要持有写锁）
+func (ms *MemoryStorage) unindexRecords(tableName string, records []interface{}) {
+	t, exists := ms.secondary[tableName]
+	if !exists {
+		return
+	}
+	for _, record := range records {
+		if sd, ok := record.(*StructuredData); ok {
+			t.remove(sd)
+		}
+	}
+}
+
+// rebuildSecondary 按表中现有记录重建二级索引，用于删除和过期清理之后（需要持有写锁）
+func (ms *MemoryStorage) rebuildSecondary(tableName string) {
+	if len(ms.config.IndexFields) == 0 {
+		return
+	}
+	delete(ms.secondary, tableName)
+	for _, record := range ms.data[tableName] {
+		ms.indexRecord(tableName, record)
+	}
+}
+
+// validateConditions 检查查询条件的字段和运算符
+func validateConditions(conditions []core.Condition) error {
+	for _, cond := range conditions {
+		if cond.Field == "" {
+			return fmt.Errorf("condition field cannot be empty")
+		}
+		switch cond.Op {
+		case core.OpEq, core.OpNe, core.OpGt, core.OpLt, core.OpGte, core.OpLte:
+		case core.OpIn:
+			if _, ok := toInterfaceSlice(cond.Value); !ok {
+				return fmt.Errorf("condition %s IN requires a slice value, got %T", cond.Field, cond.Value)
+			}
+		default:
+			return fmt.Errorf("unsupported condition operator %q", cond.Op)
+		}
+	}
+	return nil
+}
+
+// matchConditions 检查记录是否满足所有条件
+func matchConditions(data *StructuredData, conditions []core.Condition) bool {
+	for _, cond := range conditions {
+		value, ok := recordFieldValue(data, cond.Field)
+		if !ok || !evalCondition(value, cond) {
+			return false
+		}
+	}
+	return true
+}
+
+// evalCondition 对字段值求条件，类型不可比较时不满足
+func evalCondition(value interface{}, cond core.Condition) bool {
+	if cond.Op == core.OpIn {
+		candidates, _ := toInterfaceSlice(cond.Value)
+		for _, candidate := range candidates {
+			if cmp, ok := compareValues(value, candidate); ok && cmp == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	cmp, ok := compareValues(value, cond.Value)
+	if !ok {
+		return false
+	}
+	switch cond.Op {
+	case core.OpEq:
+		return cmp == 0
+	case core.OpNe:
+		return cmp != 0
+	case core.OpGt:
+		return cmp > 0
+	case core.OpLt:
+		return cmp < 0
+	case core.OpGte:
+		return cmp >= 0
+	case core.OpLte:
+		return cmp <= 0
+	}
+	return false
+}
+
+// recordFieldValue 读取记录的字段值，未存储时使用计算字段或默认值
+func recordFieldValue(data *StructuredData, field string) (interface{}, bool) {
+	if value, exists := data.Values[field]; exists {
+		return value, value != nil
+	}
+	if data.Schema == nil {
+		return nil, false
+	}
+	fieldDef, exists := data.Schema.Fields[field]
+	if !exists || (fieldDef.Compute == nil && fieldDef.DefaultValue == nil) {
+		return nil, false
+	}
+	value, err := data.GetField(field)
+	return value, err == nil && value != nil
+}
+
+// compareValues 比较两个值，整数和浮点数之间可以比较，类型不可比较时返回 false
+func compareValues(a, b interface{}) (int, bool) {
+	if ai, af, aInt, ok := toNumber(a); ok {
+		bi, bf, bInt, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		if aInt && bInt {
+			return compareOrdered(ai, bi), true
+		}
+		if math.IsNaN(af) || math.IsNaN(bf) {
+			return 0, false
+		}
+		return compareOrdered(af, bf), true
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, true
+			case bv:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv), true
+		}
+	}
+	return 0, false
+}
+
+func compareOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// toNumber 将数值转换为 int64 和 float64，isInt 表示原值为整数
+func toNumber(value interface{}) (int64, float64, bool, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), float64(rv.Int()), true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), float64(u), true, true
+		}
+		return 0, float64(rv.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return 0, rv.Float(), false, true
+	}
+	return 0, 0, false, false
+}
+
+// indexKey 将字段值规整为哈希索引键，整数值的浮点数与整数使用相同的键
+func indexKey(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string, bool:
+		return v, true
+	case time.Time:
+		return timeKey(v.UnixNano()), true
+	}
+
+	i, f, isInt, ok := toNumber(value)
+	switch {
+	case !ok:
+		return nil, false
+	case isInt:
+		return i, true
+	case f == math.Trunc(f) && math.Abs(f) < 1<<53:
+		return int64(f), true
+	default:
+		return f, true
+	}
+}
+
+// sortRecords 按字段值稳定排序，缺少该字段的记录始终排在最后
+func sortRecords(records []interface{}, field string, desc bool) {
+	value := func(record interface{}) (interface{}, bool) {
+		if sd, ok := record.(*StructuredData); ok {
+			return recordFieldValue(sd, field)
+		}
+		return nil, false
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		vi, okI := value(records[i])
+		vj, okJ := value(records[j])
+		if !okI || !okJ {
+			return okI && !okJ
+		}
+		cmp, ok := compareValues(vi, vj)
+		if !ok {
+			return false
+		}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
+
+// paginate 按 Offset 和 Limit 截取结果
+func paginate(records []interface{}, offset, limit int) []interface{} {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(records) {
+		return records[:0]
+	}
+	records = records[offset:]
+	if limit > 0 && limit < len(records) {
+		records = records[:limit]
+	}
+	return records
+}
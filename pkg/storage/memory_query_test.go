@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+var memoryQuerySchema = &DataSchema{
+	Name: "memory_query",
+	Fields: map[string]*FieldDefinition{
+		"symbol":    {Name: "symbol", Type: FieldTypeString, Required: true},
+		"volume":    {Name: "volume", Type: FieldTypeInt},
+		"price":     {Name: "price", Type: FieldTypeFloat64},
+		"suspended": {Name: "suspended", Type: FieldTypeBool},
+		"timestamp": {Name: "timestamp", Type: FieldTypeTime, Required: true},
+	},
+	FieldOrder: []string{"symbol", "volume", "price", "suspended", "timestamp"},
+}
+
+var memoryQuerySymbols = []string{"600000", "600036", "000001", "000002", "300750"}
+
+func newMemoryQueryRecord(t testing.TB, i int, rng *rand.Rand) *StructuredData {
+	t.Helper()
+	sd := NewStructuredData(memoryQuerySchema)
+	base := time.Date(2025, 8, 21, 9, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	fields := map[string]interface{}{
+		"symbol":    memoryQuerySymbols[rng.Intn(len(memoryQuerySymbols))],
+		"volume":    int64(rng.Intn(10)),
+		"price":     10 + float64(rng.Intn(10))*0.5,
+		"suspended": rng.Intn(4) == 0,
+		"timestamp": base.Add(time.Duration(i) * time.Second),
+	}
+	for fieldName, value := range fields {
+		if fieldName != "symbol" && fieldName != "timestamp" && rng.Intn(10) == 0 {
+			continue // 保留部分缺失字段
+		}
+		require.NoError(t, sd.SetField(fieldName, value))
+	}
+	return sd
+}
+
+// randomCondition 生成随机条件，数值字段的条件值随机使用整数或浮点数
+func randomCondition(rng *rand.Rand) core.Condition {
+	ops := []core.ConditionOp{core.OpEq, core.OpNe, core.OpGt, core.OpLt, core.OpGte, core.OpLte, core.OpIn}
+	op := ops[rng.Intn(len(ops))]
+	value := func(field string) interface{} {
+		switch field {
+		case "symbol":
+			return memoryQuerySymbols[rng.Intn(len(memoryQuerySymbols))]
+		case "volume":
+			if rng.Intn(2) == 0 {
+				return float64(rng.Intn(10))
+			}
+			return rng.Intn(10)
+		case "price":
+			return 10 + float64(rng.Intn(10))*0.5
+		default:
+			return rng.Intn(2) == 0
+		}
+	}
+
+	fields := []string{"symbol", "volume", "price", "suspended"}
+	field := fields[rng.Intn(len(fields))]
+	if op != core.OpIn {
+		return core.Condition{Field: field, Op: op, Value: value(field)}
+	}
+	values := make([]interface{}, 1+rng.Intn(3))
+	for i := range values {
+		values[i] = value(field)
+	}
+	return core.Condition{Field: field, Op: op, Value: values}
+}
+
+// referenceMatch 暴力求值，与 MemoryStorage 的实现相互独立
+func referenceMatch(sd *StructuredData, conditions []core.Condition) bool {
+	asFloat := func(v interface{}) (float64, bool) {
+		switch n := v.(type) {
+		case int:
+			return float64(n), true
+		case int64:
+			return float64(n), true
+		case float64:
+			return n, true
+		}
+		return 0, false
+	}
+	compare := func(a, b interface{}) (int, bool) {
+		if af, ok := asFloat(a); ok {
+			bf, ok := asFloat(b)
+			switch {
+			case !ok:
+				return 0, false
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+		switch av := a.(type) {
+		case string:
+			bv, ok := b.(string)
+			switch {
+			case !ok:
+				return 0, false
+			case av < bv:
+				return -1, true
+			case av > bv:
+				return 1, true
+			}
+			return 0, true
+		case bool:
+			bv, ok := b.(bool)
+			switch {
+			case !ok:
+				return 0, false
+			case av == bv:
+				return 0, true
+			case !av:
+				return -1, true
+			}
+			return 1, true
+		}
+		return 0, false
+	}
+
+	for _, cond := range conditions {
+		value, exists := sd.Values[cond.Field]
+		if !exists {
+			return false
+		}
+		if cond.Op == core.OpIn {
+			found := false
+			for _, candidate := range cond.Value.([]interface{}) {
+				if cmp, ok := compare(value, candidate); ok && cmp == 0 {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+			continue
+		}
+		cmp, ok := compare(value, cond.Value)
+		if !ok {
+			return false
+		}
+		matched := map[core.ConditionOp]bool{
+			core.OpEq: cmp == 0, core.OpNe: cmp != 0, core.OpGt: cmp > 0,
+			core.OpLt: cmp < 0, core.OpGte: cmp >= 0, core.OpLte: cmp <= 0,
+		}[cond.Op]
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func newMemoryQueryStorage(indexFields ...string) *MemoryStorage {
+	config := DefaultMemoryStorageConfig()
+	config.CleanupInterval = 0
+	config.MaxRecords = 200000
+	config.IndexFields = indexFields
+	return NewMemoryStorage(config)
+}
+
+func TestMemoryStorage_Load_ConditionsMatchBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1788))
+	indexed := newMemoryQueryStorage("symbol", "volume", "suspended")
+	scanned := newMemoryQueryStorage()
+
+	ctx := context.Background()
+	var all []*StructuredData
+	batch := make([]interface{}, 0, 2000)
+	for i := 0; i < 2000; i++ {
+		sd := newMemoryQueryRecord(t, i, rng)
+		all = append(all, sd)
+		batch = append(batch, sd)
+	}
+	require.NoError(t, indexed.BatchSave(ctx, batch))
+	require.NoError(t, scanned.BatchSave(ctx, batch))
+
+	for i := 0; i < 300; i++ {
+		conditions := make([]core.Condition, 1+rng.Intn(3))
+		for j := range conditions {
+			conditions[j] = randomCondition(rng)
+		}
+
+		var expected []interface{}
+		for _, sd := range all {
+			if referenceMatch(sd, conditions) {
+				expected = append(expected, sd)
+			}
+		}
+
+		query := core.Query{Conditions: conditions}
+		got, err := indexed.Load(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, len(expected), len(got), "%v", conditions)
+		assert.Equal(t, expected, append([]interface{}(nil), got...), "索引结果保持写入顺序: %v", conditions)
+
+		got, err = scanned.Load(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, len(expected), len(got), "%v", conditions)
+	}
+}
+
+func TestMemoryStorage_Load_SortAndPaginate(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	ms := newMemoryQueryStorage("symbol")
+	ctx := context.Background()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, ms.Save(ctx, newMemoryQueryRecord(t, i, rng)))
+	}
+
+	query := core.Query{
+		Conditions: []core.Condition{{Field: "symbol", Op: core.OpIn, Value: []string{"600000", "000001"}}},
+		SortBy:     "price",
+		SortDesc:   true,
+	}
+	all, err := ms.Load(ctx, query)
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+
+	sawMissing := false
+	for i, record := range all {
+		sd := record.(*StructuredData)
+		assert.Contains(t, []string{"600000", "000001"}, sd.Values["symbol"])
+		price, ok := sd.Values["price"].(float64)
+		if !ok {
+			sawMissing = true
+			continue
+		}
+		assert.False(t, sawMissing, "缺少排序字段的记录排在最后")
+		if i > 0 {
+			if prev, ok := all[i-1].(*StructuredData).Values["price"].(float64); ok {
+				assert.GreaterOrEqual(t, prev, price)
+			}
+		}
+	}
+
+	query.Offset, query.Limit = 5, 10
+	page, err := ms.Load(ctx, query)
+	require.NoError(t, err)
+	assert.Equal(t, all[5:15], page)
+
+	query.Offset = len(all)
+	page, err = ms.Load(ctx, query)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+
+	// 不排序时按写入顺序分页
+	unsorted, err := ms.Load(ctx, core.Query{Conditions: query.Conditions})
+	require.NoError(t, err)
+	page, err = ms.Load(ctx, core.Query{Conditions: query.Conditions, Offset: 3, Limit: 4})
+	require.NoError(t, err)
+	assert.Equal(t, unsorted[3:7], page)
+}
+
+func TestMemoryStorage_SecondaryIndex_Consistency(t *testing.T) {
+	config := DefaultMemoryStorageConfig()
+	config.CleanupInterval = 0
+	config.MaxRecords = 10
+	config.IndexFields = []string{"symbol"}
+	ms := NewMemoryStorage(config)
+	ctx := context.Background()
+
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 25; i++ {
+		require.NoError(t, ms.Save(ctx, newMemoryQueryRecord(t, i, rng)))
+	}
+	batch := make([]interface{}, 0, 6)
+	for i := 25; i < 31; i++ {
+		batch = append(batch, newMemoryQueryRecord(t, i, rng))
+	}
+	require.NoError(t, ms.BatchSave(ctx, batch))
+
+	stats := ms.GetStats()
+	index := stats.SecondaryIndexes["table_structured_memory_query.symbol"]
+	assert.Equal(t, 10, index.Entries, "淘汰的记录从索引中移除")
+
+	count := func(symbol string) int {
+		records, err := ms.Load(ctx, core.Query{Conditions: []core.Condition{{Field: "symbol", Op: core.OpEq, Value: symbol}}})
+		require.NoError(t, err)
+		return len(records)
+	}
+	total := 0
+	for _, symbol := range memoryQuerySymbols {
+		total += count(symbol)
+	}
+	assert.Equal(t, 10, total)
+
+	require.NoError(t, ms.Delete(ctx, core.Query{Conditions: []core.Condition{{Field: "symbol", Op: core.OpEq, Value: "600000"}}}))
+	assert.Zero(t, count("600000"))
+	remaining, err := ms.Load(ctx, core.Query{})
+	require.NoError(t, err)
+	assert.Equal(t, len(remaining), ms.GetStats().SecondaryIndexes["table_structured_memory_query.symbol"].Entries)
+
+	// 非 StructuredData 没有字段，带条件的查询不匹配
+	require.NoError(t, ms.Save(ctx, core.StockData{Symbol: "600000", Timestamp: time.Now()}))
+	assert.Zero(t, count("600000"))
+}
+
+func TestMemoryStorage_Load_InvalidConditions(t *testing.T) {
+	ms := newMemoryQueryStorage("symbol")
+	ctx := context.Background()
+
+	_, err := ms.Load(ctx, core.Query{Conditions: []core.Condition{{Field: "symbol", Op: "LIKE", Value: "60%"}}})
+	assert.ErrorContains(t, err, "unsupported condition operator")
+
+	_, err = ms.Load(ctx, core.Query{Conditions: []core.Condition{{Field: "symbol", Op: core.OpIn, Value: "600000"}}})
+	assert.Error(t, err)
+
+	_, err = ms.Load(ctx, core.Query{Conditions: []core.Condition{{Op: core.OpEq, Value: "600000"}}})
+	assert.Error(t, err)
+}
+
+func benchmarkMemoryStorageLoad(b *testing.B, indexFields ...string) {
+	ms := newMemoryQueryStorage(indexFields...)
+	ctx := context.Background()
+	batch := make([]interface{}, 0, 100000)
+	for _, sd := range benchmarkRows(b) {
+		batch = append(batch, sd)
+	}
+	if err := ms.BatchSave(ctx, batch); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		query := core.Query{Conditions: []core.Condition{
+			{Field: "symbol", Op: core.OpEq, Value: fmt.Sprintf("60%04d", i%1000)},
+		}}
+		records, err := ms.Load(ctx, query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(records) != 100 {
+			b.Fatalf("expected 100 records, got %d", len(records))
+		}
+	}
+}
+
+// BenchmarkMemoryStorage_LoadIndexed 与 BenchmarkMemoryStorage_LoadScan 对比 10 万条数据上的等值查询
+func BenchmarkMemoryStorage_LoadIndexed(b *testing.B) {
+	benchmarkMemoryStorageLoad(b, "symbol")
+}
+
+func BenchmarkMemoryStorage_LoadScan(b *testing.B) {
+	benchmarkMemoryStorageLoad(b)
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// OrderBookLevelSchema 盘口单档结构
+var OrderBookLevelSchema = &DataSchema{
+	Name:        "order_book_level",
+	Description: "盘口档位",
+	Fields: map[string]*FieldDefinition{
+		"price": {
+			Name:        "price",
+			Type:        FieldTypeFloat64,
+			Description: "价格",
+			Required:    true,
+			Precision:   core.PricePrecision,
+		},
+		"volume": {
+			Name:        "volume",
+			Type:        FieldTypeInt,
+			Description: "数量",
+			Required:    true,
+		},
+	},
+	FieldOrder: []string{"price", "volume"},
+}
+
+// OrderBookSchema 多档盘口数据模式，买卖盘为 {price, volume} 对象数组
+var OrderBookSchema = &DataSchema{
+	Name:        "order_book",
+	Description: "多档盘口数据",
+	Fields: map[string]*FieldDefinition{
+		"symbol": {
+			Name:        "symbol",
+			Type:        FieldTypeString,
+			Description: "股票代码",
+			Required:    true,
+		},
+		"bid": {
+			Name:        "bid",
+			Type:        FieldTypeArray,
+			Description: "买盘",
+			Comment:     "按价格从高到低排列",
+			ElementType: &FieldDefinition{Name: "level", Type: FieldTypeObject, SubSchema: OrderBookLevelSchema},
+			MaxItems:    5,
+		},
+		"ask": {
+			Name:        "ask",
+			Type:        FieldTypeArray,
+			Description: "卖盘",
+			Comment:     "按价格从低到高排列",
+			ElementType: &FieldDefinition{Name: "level", Type: FieldTypeObject, SubSchema: OrderBookLevelSchema},
+			MaxItems:    5,
+		},
+		"timestamp": {
+			Name:        "timestamp",
+			Type:        FieldTypeTime,
+			Description: "数据时间",
+			Required:    true,
+		},
+	},
+	FieldOrder: []string{"symbol", "bid", "ask", "timestamp"},
+}
+
+// StockDataToOrderBook 将股票数据中的 5 档买卖盘转换为 OrderBookSchema 结构化数据
+func StockDataToOrderBook(stockData core.StockData) (*StructuredData, error) {
+	sd := NewStructuredData(OrderBookSchema)
+	sd.Timestamp = stockData.Timestamp
+
+	level := func(price float64, volume int64) map[string]interface{} {
+		return map[string]interface{}{"price": price, "volume": volume}
+	}
+	bid := []interface{}{
+		level(stockData.BidPrice1, stockData.BidVolume1),
+		level(stockData.BidPrice2, stockData.BidVolume2),
+		level(stockData.BidPrice3, stockData.BidVolume3),
+		level(stockData.BidPrice4, stockData.BidVolume4),
+		level(stockData.BidPrice5, stockData.BidVolume5),
+	}
+	ask := []interface{}{
+		level(stockData.AskPrice1, stockData.AskVolume1),
+		level(stockData.AskPrice2, stockData.AskVolume2),
+		level(stockData.AskPrice3, stockData.AskVolume3),
+		level(stockData.AskPrice4, stockData.AskVolume4),
+		level(stockData.AskPrice5, stockData.AskVolume5),
+	}
+
+	fieldMappings := map[string]interface{}{
+		"symbol":    stockData.Symbol,
+		"bid":       bid,
+		"ask":       ask,
+		"timestamp": stockData.Timestamp,
+	}
+	for fieldName, value := range fieldMappings {
+		if err := sd.SetField(fieldName, value); err != nil {
+			return nil, err
+		}
+	}
+	return sd, nil
+}
+
+// isValidFieldValue 检查值是否符合字段定义，数组元素和对象子字段递归检查
+func isValidFieldValue(value interface{}, fieldDef *FieldDefinition) bool {
+	if !isValidFieldType(value, fieldDef.Type) {
+		return false
+	}
+	if value == nil {
+		return true
+	}
+
+	switch fieldDef.Type {
+	case FieldTypeArray:
+		if fieldDef.ElementType == nil {
+			return true
+		}
+		items, _ := toInterfaceSlice(value)
+		for _, item := range items {
+			if !isValidFieldValue(item, fieldDef.ElementType) {
+				return false
+			}
+		}
+	case FieldTypeObject:
+		if fieldDef.SubSchema == nil {
+			return true
+		}
+		for key, subValue := range value.(map[string]interface{}) {
+			subDef, exists := fieldDef.SubSchema.Fields[key]
+			if !exists || !isValidFieldValue(subValue, subDef) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validateNestedDefinition 验证数组元素定义和嵌套对象结构
+func validateNestedDefinition(fieldName string, fieldDef *FieldDefinition) error {
+	switch fieldDef.Type {
+	case FieldTypeArray:
+		elem := fieldDef.ElementType
+		if elem == nil {
+			return NewStructuredDataError(ErrInvalidFieldType, fieldName, "array field requires element type")
+		}
+		if fieldDef.MaxItems < 0 {
+			return NewStructuredDataError(ErrInvalidFieldType, fieldName, "max items cannot be negative")
+		}
+		if elem.Type < FieldTypeString || elem.Type > FieldTypeObject {
+			return NewStructuredDataError(ErrInvalidFieldType, fieldName, "invalid element type")
+		}
+		return validateNestedDefinition(fieldName+"[]", elem)
+	case FieldTypeObject:
+		if fieldDef.SubSchema == nil {
+			return NewStructuredDataError(ErrInvalidFieldType, fieldName, "object field requires sub schema")
+		}
+		return ValidateSchema(fieldDef.SubSchema)
+	}
+	return nil
+}
+
+// validateNestedValue 递归验证数组元素和对象子字段，错误中的字段路径形如 bid[0].price
+func validateNestedValue(fieldName string, value interface{}, fieldDef *FieldDefinition) error {
+	switch fieldDef.Type {
+	case FieldTypeArray:
+		items, _ := toInterfaceSlice(value)
+		if fieldDef.MaxItems > 0 && len(items) > fieldDef.MaxItems {
+			return NewStructuredDataError(ErrFieldValidationFailed, fieldName, fmt.Sprintf("array length %d exceeds max items %d", len(items), fieldDef.MaxItems))
+		}
+		if fieldDef.ElementType == nil {
+			return nil
+		}
+		for i, item := range items {
+			if err := ValidateFieldValue(fmt.Sprintf("%s[%d]", fieldName, i), item, fieldDef.ElementType); err != nil {
+				return err
+			}
+		}
+	case FieldTypeObject:
+		if fieldDef.SubSchema == nil {
+			return nil
+		}
+		obj := value.(map[string]interface{})
+		for _, key := range schemaFieldNames(fieldDef.SubSchema) {
+			if err := ValidateFieldValue(fieldName+"."+key, obj[key], fieldDef.SubSchema.Fields[key]); err != nil {
+				return err
+			}
+		}
+		for key := range obj {
+			if _, exists := fieldDef.SubSchema.Fields[key]; !exists {
+				return NewStructuredDataError(ErrFieldNotFound, fieldName+"."+key, "unknown field in object")
+			}
+		}
+	}
+	return nil
+}
+
+// schemaFieldNames 返回模式的字段顺序，未指定 FieldOrder 时按字段名排序
+func schemaFieldNames(schema *DataSchema) []string {
+	if len(schema.FieldOrder) > 0 {
+		return schema.FieldOrder
+	}
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toInterfaceSlice 将任意切片转换为 []interface{}
+func toInterfaceSlice(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, true
+}
+
+// coerceJSONValue 将 JSON（UseNumber）解码后的值还原为字段定义的类型，数组和对象递归还原
+func coerceJSONValue(raw interface{}, fieldDef *FieldDefinition) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	switch fieldDef.Type {
+	case FieldTypeString:
+		if s, ok := raw.(string); ok {
+			return s, nil
+		}
+	case FieldTypeInt:
+		if n, ok := raw.(json.Number); ok {
+			return n.Int64()
+		}
+	case FieldTypeFloat64:
+		if n, ok := raw.(json.Number); ok {
+			return n.Float64()
+		}
+	case FieldTypeBool:
+		if b, ok := raw.(bool); ok {
+			return b, nil
+		}
+	case FieldTypeTime:
+		if s, ok := raw.(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+	case FieldTypeArray:
+		items, ok := raw.([]interface{})
+		if !ok {
+			break
+		}
+		if fieldDef.ElementType == nil {
+			return items, nil
+		}
+		result := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := coerceJSONValue(item, fieldDef.ElementType)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			result[i] = value
+		}
+		return result, nil
+	case FieldTypeObject:
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			break
+		}
+		if fieldDef.SubSchema == nil {
+			return obj, nil
+		}
+		result := make(map[string]interface{}, len(obj))
+		for key, item := range obj {
+			subDef, exists := fieldDef.SubSchema.Fields[key]
+			if !exists {
+				return nil, fmt.Errorf("unknown field %q in object", key)
+			}
+			value, err := coerceJSONValue(item, subDef)
+			if err != nil {
+				return nil, fmt.Errorf(".%s: %w", key, err)
+			}
+			result[key] = value
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T to %s", raw, fieldDef.Type)
+}
+
+// roundValues 按 schema 中的字段精度返回舍入后的字段值副本，不修改原数据
+func roundValues(values map[string]interface{}, schema *DataSchema) map[string]interface{} {
+	rounded := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		if def, ok := schema.Fields[name]; ok {
+			value = roundFieldValue(value, def)
+		}
+		rounded[name] = value
+	}
+	return rounded
+}
+
+// roundFieldValue 按字段精度舍入浮点值，数组元素和嵌套对象递归舍入
+func roundFieldValue(value interface{}, fieldDef *FieldDefinition) interface{} {
+	switch fieldDef.Type {
+	case FieldTypeFloat64:
+		switch v := value.(type) {
+		case float64:
+			return core.RoundHalfUp(v, fieldDef.DecimalPlaces())
+		case float32:
+			return core.RoundHalfUp(float64(v), fieldDef.DecimalPlaces())
+		}
+	case FieldTypeArray:
+		if fieldDef.ElementType == nil {
+			return value
+		}
+		items, ok := toInterfaceSlice(value)
+		if !ok {
+			return value
+		}
+		rounded := make([]interface{}, len(items))
+		for i, item := range items {
+			rounded[i] = roundFieldValue(item, fieldDef.ElementType)
+		}
+		return rounded
+	case FieldTypeObject:
+		if obj, ok := value.(map[string]interface{}); ok && fieldDef.SubSchema != nil {
+			return roundValues(obj, fieldDef.SubSchema)
+		}
+	}
+	return value
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func orderBookLevel(price float64, volume int64) map[string]interface{} {
+	return map[string]interface{}{"price": price, "volume": volume}
+}
+
+func newTestOrderBook(t *testing.T, bid, ask []interface{}) *StructuredData {
+	t.Helper()
+	sd := NewStructuredData(OrderBookSchema)
+	require.NoError(t, sd.SetField("symbol", "600000"))
+	require.NoError(t, sd.SetField("bid", bid))
+	require.NoError(t, sd.SetField("ask", ask))
+	require.NoError(t, sd.SetField("timestamp", time.Date(2025, 8, 21, 10, 30, 0, 0, time.FixedZone("CST", 8*3600))))
+	return sd
+}
+
+func TestOrderBookSchema_Valid(t *testing.T) {
+	require.NoError(t, ValidateSchema(OrderBookSchema))
+	assert.Equal(t, "array", FieldTypeArray.String())
+	assert.Equal(t, "object", FieldTypeObject.String())
+}
+
+func TestNestedField_SetFieldChecksElements(t *testing.T) {
+	sd := NewStructuredData(OrderBookSchema)
+
+	require.NoError(t, sd.SetField("bid", []interface{}{orderBookLevel(10.5, 100)}))
+	require.NoError(t, sd.SetField("bid", []map[string]interface{}{orderBookLevel(10.5, 100)}), "任意切片类型均可")
+
+	err := sd.SetField("bid", []interface{}{map[string]interface{}{"price": "10.5", "volume": int64(100)}})
+	assert.Error(t, err, "元素字段类型错误")
+
+	err = sd.SetField("bid", []interface{}{map[string]interface{}{"price": 10.5, "qty": int64(100)}})
+	assert.Error(t, err, "元素包含未定义字段")
+
+	err = sd.SetField("bid", orderBookLevel(10.5, 100))
+	assert.Error(t, err, "数组字段不接受对象")
+}
+
+func TestNestedField_ValidateFieldValueRecurses(t *testing.T) {
+	def := OrderBookSchema.Fields["bid"]
+
+	assert.NoError(t, ValidateFieldValue("bid", []interface{}{orderBookLevel(10.5, 100), orderBookLevel(10.4, 200)}, def))
+
+	err := ValidateFieldValue("bid", []interface{}{orderBookLevel(10.5, 100), orderBookLevel(10.4, -1)}, def)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "volume cannot be negative")
+	sdErr, ok := err.(*StructuredDataError)
+	require.True(t, ok)
+	assert.Equal(t, "bid[1].volume", sdErr.Context["field"])
+
+	err = ValidateFieldValue("bid", []interface{}{map[string]interface{}{"price": 10.5}}, def)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "required field missing")
+
+	levels := make([]interface{}, 6)
+	for i := range levels {
+		levels[i] = orderBookLevel(10, 100)
+	}
+	err = ValidateFieldValue("bid", levels, def)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds max items")
+}
+
+func TestNestedField_ValidateFieldDefinition(t *testing.T) {
+	tests := []struct {
+		name string
+		def  *FieldDefinition
+	}{
+		{"array without element type", &FieldDefinition{Name: "f", Type: FieldTypeArray}},
+		{"object without sub schema", &FieldDefinition{Name: "f", Type: FieldTypeObject}},
+		{"negative max items", &FieldDefinition{Name: "f", Type: FieldTypeArray, MaxItems: -1, ElementType: &FieldDefinition{Type: FieldTypeInt}}},
+		{"nested invalid element", &FieldDefinition{Name: "f", Type: FieldTypeArray, ElementType: &FieldDefinition{Type: FieldTypeArray}}},
+		{"invalid sub schema", &FieldDefinition{Name: "f", Type: FieldTypeObject, SubSchema: &DataSchema{Name: "empty"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, ValidateFieldDefinition("f", tt.def))
+		})
+	}
+
+	assert.NoError(t, ValidateFieldDefinition("f", &FieldDefinition{
+		Name: "f", Type: FieldTypeArray, ElementType: &FieldDefinition{Type: FieldTypeFloat64},
+		DefaultValue: []interface{}{1.0, 2.0},
+	}))
+}
+
+func TestStockDataToOrderBook(t *testing.T) {
+	stock := core.StockData{
+		Symbol: "600000", Timestamp: time.Now(),
+		BidPrice1: 10.50, BidVolume1: 100, BidPrice5: 10.46, BidVolume5: 500,
+		AskPrice1: 10.51, AskVolume1: 150,
+	}
+
+	sd, err := StockDataToOrderBook(stock)
+	require.NoError(t, err)
+	require.NoError(t, sd.ValidateDataComplete())
+
+	bid, err := sd.GetField("bid")
+	require.NoError(t, err)
+	levels := bid.([]interface{})
+	require.Len(t, levels, 5)
+	assert.Equal(t, orderBookLevel(10.50, 100), levels[0])
+	assert.Equal(t, orderBookLevel(10.46, 500), levels[4])
+}
+
+func TestStructuredDataSerializer_NestedJSONCell(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	sd := newTestOrderBook(t,
+		[]interface{}{orderBookLevel(10.5, 100), orderBookLevel(10.49, 200)},
+		[]interface{}{orderBookLevel(10.51, 300)})
+
+	data, err := serializer.Serialize(sd)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "股票代码(symbol),买盘(bid),卖盘(ask),数据时间(timestamp)", lines[0])
+	assert.Contains(t, lines[1], `"[{""price"":10.5,""volume"":100},{""price"":10.49,""volume"":200}]"`)
+
+	result := NewStructuredData(OrderBookSchema)
+	require.NoError(t, serializer.Deserialize(data, result))
+	require.NoError(t, result.ValidateDataComplete())
+
+	bid, err := result.GetField("bid")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{orderBookLevel(10.5, 100), orderBookLevel(10.49, 200)}, bid)
+	ask, err := result.GetField("ask")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{orderBookLevel(10.51, 300)}, ask)
+}
+
+func TestStructuredDataSerializer_NestedExplode(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	serializer.SetNestedFieldMode(NestedFieldExplode)
+
+	// 卖盘只有 3 档，剩余列留空
+	sd := newTestOrderBook(t,
+		[]interface{}{
+			orderBookLevel(10.50, 100), orderBookLevel(10.49, 200), orderBookLevel(10.48, 300),
+			orderBookLevel(10.47, 400), orderBookLevel(10.46, 500),
+		},
+		[]interface{}{orderBookLevel(10.51, 150), orderBookLevel(10.52, 250), orderBookLevel(10.53, 350)})
+
+	data, err := serializer.Serialize(sd)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	headers := strings.Split(lines[0], ",")
+	require.Len(t, headers, 1+10+10+1)
+	assert.Equal(t, "买盘价格(bid[0].price)", headers[1])
+	assert.Equal(t, "买盘数量(bid[0].volume)", headers[2])
+	assert.Equal(t, "卖盘数量(ask[4].volume)", headers[20])
+	assert.True(t, strings.HasSuffix(lines[1], ",10.53,350,,,,,2025-08-21 10:30:00"), lines[1])
+
+	// 展开列可以被任意模式的序列化器读取
+	for _, mode := range []NestedFieldMode{NestedFieldExplode, NestedFieldJSON} {
+		reader := NewStructuredDataSerializer(FormatCSV)
+		reader.SetNestedFieldMode(mode)
+
+		result := NewStructuredData(OrderBookSchema)
+		require.NoError(t, reader.Deserialize(data, result))
+		require.NoError(t, result.ValidateDataComplete())
+
+		bid, err := result.GetField("bid")
+		require.NoError(t, err)
+		assert.Len(t, bid, 5)
+		ask, err := result.GetField("ask")
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{orderBookLevel(10.51, 150), orderBookLevel(10.52, 250), orderBookLevel(10.53, 350)}, ask)
+	}
+}
+
+func TestStructuredDataSerializer_NestedExplodeMultiple(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	serializer.SetNestedFieldMode(NestedFieldExplode)
+
+	first := newTestOrderBook(t, []interface{}{orderBookLevel(10.5, 100)}, nil)
+	second := newTestOrderBook(t, []interface{}{orderBookLevel(10.4, 200), orderBookLevel(10.3, 300)}, []interface{}{orderBookLevel(10.6, 50)})
+
+	data, err := serializer.SerializeMultiple([]*StructuredData{first, second})
+	require.NoError(t, err)
+
+	results, err := serializer.DeserializeMultiple(data, OrderBookSchema)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, []interface{}{orderBookLevel(10.5, 100)}, results[0].Values["bid"])
+	assert.Nil(t, results[0].Values["ask"])
+	assert.Equal(t, []interface{}{orderBookLevel(10.4, 200), orderBookLevel(10.3, 300)}, results[1].Values["bid"])
+	assert.Equal(t, []interface{}{orderBookLevel(10.6, 50)}, results[1].Values["ask"])
+}
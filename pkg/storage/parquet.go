@@ -0,0 +1,355 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Parquet 文件级元数据的键
+//
+// parquet-go 暂不支持写入列级元数据，字段描述以 stocksub.column.<字段名>.description 的形式记录在文件元数据中。
+const (
+	parquetSchemaKey        = "stocksub.schema"         // DataSchema 的 JSON 定义
+	parquetSchemaVersionKey = "stocksub.schema_version" // 数据所属的模式版本
+	parquetColumnKeyPrefix  = "stocksub.column."        // 列描述前缀
+)
+
+// parquetReadBatch 每次从行组读取的行数
+const parquetReadBatch = 256
+
+// parquetNode 将字段类型映射为 Parquet 列类型，数组和对象字段以 JSON 字符串存储
+func parquetNode(fieldDef *FieldDefinition) parquet.Node {
+	switch fieldDef.Type {
+	case FieldTypeString:
+		return parquet.String()
+	case FieldTypeInt:
+		return parquet.Int(64)
+	case FieldTypeFloat64:
+		return parquet.Leaf(parquet.DoubleType)
+	case FieldTypeBool:
+		return parquet.Leaf(parquet.BooleanType)
+	case FieldTypeTime:
+		return parquet.Timestamp(parquet.Millisecond)
+	default:
+		return parquet.JSON()
+	}
+}
+
+// parquetSchemaOf 根据 DataSchema 生成 Parquet 模式，所有列均为可选列
+func parquetSchemaOf(schema *DataSchema) *parquet.Schema {
+	group := make(parquet.Group, len(schema.Fields))
+	for fieldName, fieldDef := range schema.Fields {
+		group[fieldName] = parquet.Optional(parquetNode(fieldDef))
+	}
+	return parquet.NewSchema(schema.Name, group)
+}
+
+// parquetMetadata 生成写入文件元数据的模式定义、版本和字段描述
+func parquetMetadata(sd *StructuredData) ([]parquet.WriterOption, error) {
+	schemaJSON, err := json.Marshal(sd.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schema: %w", err)
+	}
+
+	options := []parquet.WriterOption{
+		parquet.Compression(&parquet.Snappy),
+		parquet.KeyValueMetadata(parquetSchemaKey, string(schemaJSON)),
+	}
+	if sd.SchemaVersion > 0 {
+		options = append(options, parquet.KeyValueMetadata(parquetSchemaVersionKey, strconv.Itoa(sd.SchemaVersion)))
+	}
+	for fieldName, fieldDef := range sd.Schema.Fields {
+		if fieldDef.Description != "" {
+			options = append(options, parquet.KeyValueMetadata(parquetColumnKeyPrefix+fieldName+".description", fieldDef.Description))
+		}
+	}
+	return options, nil
+}
+
+// serializeToParquet 序列化为 Parquet 格式
+func (s *StructuredDataSerializer) serializeToParquet(data interface{}) ([]byte, error) {
+	sd, ok := data.(*StructuredData)
+	if !ok {
+		return nil, fmt.Errorf("data must be *StructuredData, got %T", data)
+	}
+	return s.serializeMultipleToParquet([]*StructuredData{sd})
+}
+
+// serializeMultipleToParquet 将多个 StructuredData 写入同一个 Parquet 文件的一个行组
+func (s *StructuredDataSerializer) serializeMultipleToParquet(dataList []*StructuredData) ([]byte, error) {
+	if len(dataList) == 0 {
+		return nil, fmt.Errorf("empty data list")
+	}
+
+	firstData := dataList[0]
+	if firstData.Schema == nil {
+		return nil, fmt.Errorf("schema is required")
+	}
+
+	schema := parquetSchemaOf(firstData.Schema)
+	columns := schema.Columns()
+	rows := make([]parquet.Row, 0, len(dataList))
+	for i, sd := range dataList {
+		if sd.Schema == nil || sd.Schema.Name != firstData.Schema.Name {
+			return nil, fmt.Errorf("item %d: inconsistent schema", i)
+		}
+		if sd.SchemaVersion != firstData.SchemaVersion {
+			return nil, fmt.Errorf("item %d: inconsistent schema version: expected v%d, got v%d", i, firstData.SchemaVersion, sd.SchemaVersion)
+		}
+
+		row := make(parquet.Row, len(columns))
+		for col, path := range columns {
+			fieldName := path[0]
+			value, err := sd.GetField(fieldName)
+			if err != nil || value == nil {
+				row[col] = parquet.NullValue().Level(0, 0, col)
+				continue
+			}
+			pv, err := parquetValue(value, firstData.Schema.Fields[fieldName])
+			if err != nil {
+				return nil, fmt.Errorf("item %d: field %s: %w", i, fieldName, err)
+			}
+			row[col] = pv.Level(0, 1, col)
+		}
+		rows = append(rows, row)
+	}
+
+	options, err := parquetMetadata(firstData)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writer := parquet.NewWriter(&buf, append(options, schema)...)
+	if _, err := writer.WriteRows(rows); err != nil {
+		return nil, fmt.Errorf("failed to write parquet rows: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close parquet writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parquetValue 将字段值转换为 Parquet 值
+func parquetValue(value interface{}, fieldDef *FieldDefinition) (parquet.Value, error) {
+	rv := reflect.ValueOf(value)
+	switch fieldDef.Type {
+	case FieldTypeString:
+		if str, ok := value.(string); ok {
+			return parquet.ByteArrayValue([]byte(str)), nil
+		}
+	case FieldTypeInt:
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return parquet.Int64Value(rv.Int()), nil
+		}
+	case FieldTypeFloat64:
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return parquet.DoubleValue(rv.Float()), nil
+		}
+	case FieldTypeBool:
+		if b, ok := value.(bool); ok {
+			return parquet.BooleanValue(b), nil
+		}
+	case FieldTypeTime:
+		if t, ok := value.(time.Time); ok {
+			return parquet.Int64Value(t.UnixMilli()), nil
+		}
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.ByteArrayValue(encoded), nil
+	}
+	return parquet.Value{}, fmt.Errorf("cannot convert %T to %s", value, fieldDef.Type)
+}
+
+// fromParquetValue 将 Parquet 值还原为字段定义的类型，物理类型不匹配时返回错误
+func (s *StructuredDataSerializer) fromParquetValue(v parquet.Value, fieldDef *FieldDefinition) (interface{}, error) {
+	expected := map[FieldType]parquet.Kind{
+		FieldTypeInt:     parquet.Int64,
+		FieldTypeFloat64: parquet.Double,
+		FieldTypeBool:    parquet.Boolean,
+		FieldTypeTime:    parquet.Int64,
+	}
+	kind, exists := expected[fieldDef.Type]
+	if !exists {
+		kind = parquet.ByteArray
+	}
+	if v.Kind() != kind {
+		return nil, fmt.Errorf("column type %s does not match field type %s", v.Kind(), fieldDef.Type)
+	}
+
+	switch fieldDef.Type {
+	case FieldTypeString:
+		return string(v.ByteArray()), nil
+	case FieldTypeInt:
+		return v.Int64(), nil
+	case FieldTypeFloat64:
+		return v.Double(), nil
+	case FieldTypeBool:
+		return v.Boolean(), nil
+	case FieldTypeTime:
+		return time.UnixMilli(v.Int64()).In(s.timezone), nil
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(v.ByteArray()))
+		decoder.UseNumber()
+		var raw interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		return coerceJSONValue(raw, fieldDef)
+	}
+}
+
+// deserializeFromParquet 从 Parquet 格式反序列化第一行
+func (s *StructuredDataSerializer) deserializeFromParquet(data []byte, target interface{}) error {
+	sd, ok := target.(*StructuredData)
+	if !ok {
+		return fmt.Errorf("target must be *StructuredData, got %T", target)
+	}
+
+	results, err := s.readParquet(data, sd.Schema, 1)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("parquet data contains no rows")
+	}
+	*sd = *results[0]
+	return nil
+}
+
+// deserializeMultipleFromParquet 从 Parquet 格式反序列化所有行组
+func (s *StructuredDataSerializer) deserializeMultipleFromParquet(data []byte, schema *DataSchema) ([]*StructuredData, error) {
+	return s.readParquet(data, schema, 0)
+}
+
+// readParquet 读取 Parquet 数据，schema 为 nil 时使用文件中记录的模式，limit 为 0 表示读取全部行
+func (s *StructuredDataSerializer) readParquet(data []byte, schema *DataSchema, limit int) ([]*StructuredData, error) {
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open parquet data: %w", err)
+	}
+
+	name, version, err := parquetSchemaInfo(file)
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		if schema, err = parquetEmbeddedSchema(file); err != nil {
+			return nil, err
+		}
+	}
+	source, toVersion, err := s.versionedSchema(schema, name, version)
+	if err != nil {
+		return nil, err
+	}
+	if source != schema {
+		schema = source
+	} else {
+		version = schema.Version
+	}
+
+	columns := file.Schema().Columns()
+	for _, path := range columns {
+		if _, exists := schema.Fields[path[0]]; !exists {
+			return nil, NewStructuredDataError(ErrFieldNotFound, path[0], "column not found in schema")
+		}
+	}
+
+	var results []*StructuredData
+	buf := make([]parquet.Row, parquetReadBatch)
+	for _, rowGroup := range file.RowGroups() {
+		rows := rowGroup.Rows()
+		for limit == 0 || len(results) < limit {
+			n, readErr := rows.ReadRows(buf)
+			for _, row := range buf[:n] {
+				sd, err := s.parquetRowToStructuredData(row, columns, schema, version)
+				if err != nil {
+					rows.Close()
+					return nil, fmt.Errorf("row %d: %w", len(results), err)
+				}
+				if err := s.migrateTo(sd, toVersion); err != nil {
+					rows.Close()
+					return nil, fmt.Errorf("row %d: %w", len(results), err)
+				}
+				results = append(results, sd)
+				if limit > 0 && len(results) >= limit {
+					break
+				}
+			}
+			if readErr == io.EOF {
+				break
+			}
+			if readErr != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read parquet rows: %w", readErr)
+			}
+		}
+		rows.Close()
+	}
+	return results, nil
+}
+
+// parquetRowToStructuredData 将一行 Parquet 数据转换为 StructuredData
+func (s *StructuredDataSerializer) parquetRowToStructuredData(row parquet.Row, columns [][]string, schema *DataSchema, version int) (*StructuredData, error) {
+	sd := NewStructuredData(schema)
+	sd.SchemaVersion = version
+
+	for _, v := range row {
+		if v.IsNull() {
+			continue
+		}
+		fieldName := columns[v.Column()][0]
+		fieldDef := schema.Fields[fieldName]
+		value, err := s.fromParquetValue(v, fieldDef)
+		if err != nil {
+			return nil, NewStructuredDataError(ErrInvalidFieldType, fieldName, err.Error())
+		}
+		if err := sd.SetField(fieldName, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if timestamp, ok := sd.Values["timestamp"].(time.Time); ok {
+		sd.Timestamp = timestamp
+	}
+	return sd, nil
+}
+
+// parquetSchemaInfo 读取文件元数据中记录的模式名称和版本
+func parquetSchemaInfo(file *parquet.File) (string, int, error) {
+	name := file.Schema().Name()
+	value, ok := file.Lookup(parquetSchemaVersionKey)
+	if !ok {
+		return name, 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+	return name, version, nil
+}
+
+// parquetEmbeddedSchema 读取文件元数据中记录的 DataSchema（不包含验证器和计算函数）
+func parquetEmbeddedSchema(file *parquet.File) (*DataSchema, error) {
+	value, ok := file.Lookup(parquetSchemaKey)
+	if !ok {
+		return nil, fmt.Errorf("parquet data has no embedded schema")
+	}
+	var schema DataSchema
+	if err := json.Unmarshal([]byte(value), &schema); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embedded schema: %w", err)
+	}
+	return &schema, nil
+}
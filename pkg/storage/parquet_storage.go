@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// ParquetStorage 实现了 Storage 接口，以 Parquet 列式格式持久化 StructuredData。
+// Parquet 文件写出后不可追加，数据按模式和日期缓冲，每次刷新写出一个新的分片文件。
+type ParquetStorage struct {
+	config     ParquetStorageConfig
+	serializer *StructuredDataSerializer
+	buffers    map[string]*parquetBuffer
+	mu         sync.Mutex
+	stats      ParquetStorageStats
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+	closeOnce  sync.Once
+}
+
+// ParquetStorageConfig 定义了 ParquetStorage 的所有可配置选项。
+type ParquetStorageConfig struct {
+	Directory     string        `yaml:"directory"`      // Parquet文件的存储目录。
+	FilePrefix    string        `yaml:"file_prefix"`    // Parquet文件名的前缀。
+	DateFormat    string        `yaml:"date_format"`    // 用于按日期分组文件的时间格式。
+	RowGroupSize  int           `yaml:"row_group_size"` // 单个分片文件的行数，缓冲达到该行数时立即写出。
+	FlushInterval time.Duration `yaml:"flush_interval"` // 定期将缓冲区数据写出为分片文件的间隔。
+}
+
+// ParquetStorageStats 包含了 ParquetStorage 的运行统计信息。
+type ParquetStorageStats struct {
+	TotalRecords int64     `json:"total_records"` // 已写出到文件的总记录数。
+	BufferedRows int64     `json:"buffered_rows"` // 当前缓冲中尚未写出的记录数。
+	TotalFiles   int64     `json:"total_files"`   // 已写出的分片文件数。
+	TotalSize    int64     `json:"total_size"`    // 已写出文件的总大小（字节）。
+	WriteErrors  int64     `json:"write_errors"`  // 写入失败的次数。
+	LastWrite    time.Time `json:"last_write"`    // 最后一次接收数据的时间。
+	LastFlush    time.Time `json:"last_flush"`    // 最后一次写出文件的时间。
+}
+
+// parquetBuffer 同一模式、同一日期的待写出数据
+type parquetBuffer struct {
+	schemaName string
+	date       string
+	rows       []*StructuredData
+}
+
+// DefaultParquetStorageConfig 返回一个包含推荐默认值的 ParquetStorageConfig。
+func DefaultParquetStorageConfig() ParquetStorageConfig {
+	return ParquetStorageConfig{
+		Directory:     "./testdata",
+		FilePrefix:    "stocksub",
+		DateFormat:    "2006-01-02",
+		RowGroupSize:  50000,
+		FlushInterval: time.Minute,
+	}
+}
+
+// NewParquetStorage 创建并返回一个新的 ParquetStorage 实例。
+func NewParquetStorage(config ParquetStorageConfig) (*ParquetStorage, error) {
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+
+	storage := &ParquetStorage{
+		config:     config,
+		serializer: NewStructuredDataSerializer(FormatParquet),
+		buffers:    make(map[string]*parquetBuffer),
+		stopCh:     make(chan struct{}),
+	}
+
+	if config.FlushInterval > 0 {
+		storage.wg.Add(1)
+		go storage.startPeriodicFlush()
+	}
+
+	return storage, nil
+}
+
+// Save 将一条数据放入缓冲，缓冲达到 RowGroupSize 时写出分片文件。
+func (ps *ParquetStorage) Save(ctx context.Context, data interface{}) error {
+	return ps.BatchSave(ctx, []interface{}{data})
+}
+
+// BatchSave 将多条数据放入缓冲，支持 *StructuredData 和 core.StockData。
+func (ps *ParquetStorage) BatchSave(ctx context.Context, dataList []interface{}) error {
+	if len(dataList) == 0 {
+		return nil
+	}
+
+	records := make([]*StructuredData, 0, len(dataList))
+	for _, data := range dataList {
+		sd, err := toParquetRecord(data)
+		if err != nil {
+			ps.mu.Lock()
+			ps.stats.WriteErrors++
+			ps.mu.Unlock()
+			return fmt.Errorf("数据转换失败: %w", err)
+		}
+		records = append(records, sd)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for _, sd := range records {
+		date := sd.Timestamp.In(ps.serializer.timezone).Format(ps.config.DateFormat)
+		key := sd.Schema.Name + "_" + date
+		buf, exists := ps.buffers[key]
+		if !exists {
+			buf = &parquetBuffer{schemaName: sd.Schema.Name, date: date}
+			ps.buffers[key] = buf
+		}
+		buf.rows = append(buf.rows, sd)
+		ps.stats.BufferedRows++
+
+		if ps.config.RowGroupSize > 0 && len(buf.rows) >= ps.config.RowGroupSize {
+			if err := ps.flushBuffer(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	ps.stats.LastWrite = time.Now()
+	return nil
+}
+
+// Load 读取目录中所有分片文件并按查询条件过滤，尚未写出的缓冲数据不包含在内。
+func (ps *ParquetStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
+	files, err := filepath.Glob(filepath.Join(ps.config.Directory, ps.config.FilePrefix+"_*.parquet"))
+	if err != nil {
+		return nil, fmt.Errorf("查找Parquet文件失败: %w", err)
+	}
+	sort.Strings(files)
+
+	symbols := make(map[string]bool, len(query.Symbols))
+	for _, symbol := range query.Symbols {
+		symbols[symbol] = true
+	}
+
+	var results []interface{}
+	skipped := 0
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取Parquet文件失败: %w", err)
+		}
+		dataList, err := ps.serializer.DeserializeMultiple(content, nil)
+		if err != nil {
+			return nil, fmt.Errorf("解析Parquet文件 %s 失败: %w", filepath.Base(file), err)
+		}
+
+		for _, sd := range dataList {
+			if !matchParquetQuery(sd, query, symbols) {
+				continue
+			}
+			if skipped < query.Offset {
+				skipped++
+				continue
+			}
+			results = append(results, sd)
+			if query.Limit > 0 && len(results) >= query.Limit {
+				return results, nil
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// Delete 根据查询条件删除Parquet文件中的数据。注意：此功能当前尚未实现。
+func (ps *ParquetStorage) Delete(ctx context.Context, query core.Query) error {
+	return fmt.Errorf("Parquet删除功能待实现")
+}
+
+// Flush 将所有缓冲数据写出为分片文件。
+func (ps *ParquetStorage) Flush() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.flushAll()
+}
+
+// Close 停止定期刷新并写出所有缓冲数据。
+func (ps *ParquetStorage) Close() error {
+	var err error
+	ps.closeOnce.Do(func() {
+		close(ps.stopCh)
+		ps.wg.Wait()
+		err = ps.Flush()
+	})
+	return err
+}
+
+// GetStats 返回当前存储实例的运行统计信息。
+func (ps *ParquetStorage) GetStats() ParquetStorageStats {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return ps.stats
+}
+
+// flushAll 写出所有缓冲（需要持有锁）
+func (ps *ParquetStorage) flushAll() error {
+	keys := make([]string, 0, len(ps.buffers))
+	for key := range ps.buffers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := ps.flushBuffer(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushBuffer 将一个缓冲写出为分片文件（需要持有锁），失败时保留缓冲以便重试
+func (ps *ParquetStorage) flushBuffer(key string) error {
+	buf := ps.buffers[key]
+	if buf == nil || len(buf.rows) == 0 {
+		return nil
+	}
+
+	content, err := ps.serializer.SerializeMultiple(buf.rows)
+	if err != nil {
+		ps.stats.WriteErrors++
+		return fmt.Errorf("序列化Parquet数据失败: %w", err)
+	}
+
+	fileName := fmt.Sprintf("%s_%s_%s_%d.parquet", ps.config.FilePrefix, buf.schemaName, buf.date, time.Now().UnixNano())
+	path := filepath.Join(ps.config.Directory, fileName)
+	// 先写临时文件再重命名，避免读取到写了一半的文件
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0644); err != nil {
+		ps.stats.WriteErrors++
+		return fmt.Errorf("写入Parquet文件失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		ps.stats.WriteErrors++
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入Parquet文件失败: %w", err)
+	}
+
+	rows := int64(len(buf.rows))
+	delete(ps.buffers, key)
+	ps.stats.TotalRecords += rows
+	ps.stats.BufferedRows -= rows
+	ps.stats.TotalFiles++
+	ps.stats.TotalSize += int64(len(content))
+	ps.stats.LastFlush = time.Now()
+	return nil
+}
+
+// startPeriodicFlush 启动一个后台 goroutine，定期写出缓冲数据。
+func (ps *ParquetStorage) startPeriodicFlush() {
+	defer ps.wg.Done()
+
+	ticker := time.NewTicker(ps.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ps.Flush()
+		case <-ps.stopCh:
+			return
+		}
+	}
+}
+
+// toParquetRecord 将支持的数据类型转换为 StructuredData
+func toParquetRecord(data interface{}) (*StructuredData, error) {
+	switch v := data.(type) {
+	case *StructuredData:
+		if v.Schema == nil {
+			return nil, fmt.Errorf("StructuredData缺少模式定义")
+		}
+		return v, nil
+	case core.StockData:
+		return StockDataToStructuredData(v)
+	case *core.StockData:
+		return StockDataToStructuredData(*v)
+	default:
+		return nil, fmt.Errorf("不支持的数据类型: %T", data)
+	}
+}
+
+// matchParquetQuery 判断数据是否满足查询的股票代码和时间范围
+func matchParquetQuery(sd *StructuredData, query core.Query, symbols map[string]bool) bool {
+	if len(symbols) > 0 {
+		symbol, _ := sd.Values["symbol"].(string)
+		if !symbols[symbol] {
+			return false
+		}
+	}
+	if !query.StartTime.IsZero() && sd.Timestamp.Before(query.StartTime) {
+		return false
+	}
+	if !query.EndTime.IsZero() && sd.Timestamp.After(query.EndTime) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+var parquetTestSchema = &DataSchema{
+	Name: "parquet_test",
+	Fields: map[string]*FieldDefinition{
+		"symbol":    {Name: "symbol", Type: FieldTypeString, Description: "股票代码", Required: true},
+		"volume":    {Name: "volume", Type: FieldTypeInt, Description: "成交量"},
+		"price":     {Name: "price", Type: FieldTypeFloat64, Description: "价格"},
+		"suspended": {Name: "suspended", Type: FieldTypeBool, Description: "是否停牌"},
+		"timestamp": {Name: "timestamp", Type: FieldTypeTime, Description: "数据时间", Required: true},
+		"bid": {
+			Name: "bid", Type: FieldTypeArray, Description: "买盘",
+			ElementType: &FieldDefinition{Name: "level", Type: FieldTypeObject, SubSchema: OrderBookLevelSchema},
+		},
+	},
+	FieldOrder: []string{"symbol", "volume", "price", "suspended", "timestamp", "bid"},
+}
+
+func newParquetTestData(t testing.TB, symbol string, ts time.Time) *StructuredData {
+	t.Helper()
+	sd := NewStructuredData(parquetTestSchema)
+	fields := map[string]interface{}{
+		"symbol":    symbol,
+		"volume":    int64(1200),
+		"price":     10.55,
+		"suspended": false,
+		"timestamp": ts,
+		"bid":       []interface{}{orderBookLevel(10.54, 300)},
+	}
+	for fieldName, value := range fields {
+		require.NoError(t, sd.SetField(fieldName, value))
+	}
+	return sd
+}
+
+func TestStructuredDataSerializer_ParquetRoundTrip(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatParquet)
+	assert.Equal(t, "parquet", FormatParquet.String())
+	assert.Equal(t, "application/vnd.apache.parquet", serializer.MimeType())
+
+	ts := time.Date(2025, 8, 21, 10, 30, 0, 123000000, time.FixedZone("CST", 8*3600))
+	first := newParquetTestData(t, "600000", ts)
+	second := newParquetTestData(t, "000001", ts.Add(time.Second))
+	delete(second.Values, "price")
+
+	data, err := serializer.SerializeMultiple([]*StructuredData{first, second})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("PAR1"), data[:4])
+
+	results, err := serializer.DeserializeMultiple(data, parquetTestSchema)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	assert.Equal(t, "600000", results[0].Values["symbol"])
+	assert.Equal(t, int64(1200), results[0].Values["volume"])
+	assert.Equal(t, 10.55, results[0].Values["price"])
+	assert.Equal(t, false, results[0].Values["suspended"])
+	assert.True(t, ts.Equal(results[0].Values["timestamp"].(time.Time)))
+	assert.True(t, ts.Equal(results[0].Timestamp))
+	assert.Equal(t, []interface{}{orderBookLevel(10.54, 300)}, results[0].Values["bid"])
+	assert.NotContains(t, results[1].Values, "price", "空值列保持缺失")
+
+	single := NewStructuredData(parquetTestSchema)
+	require.NoError(t, serializer.Deserialize(data, single))
+	assert.Equal(t, "600000", single.Values["symbol"])
+}
+
+func TestStructuredDataSerializer_ParquetMetadata(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatParquet)
+	data, err := serializer.Serialize(newParquetTestData(t, "600000", time.Now()))
+	require.NoError(t, err)
+
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	description, ok := file.Lookup("stocksub.column.volume.description")
+	require.True(t, ok)
+	assert.Equal(t, "成交量", description)
+
+	// 物理类型映射
+	for column, kind := range map[string]parquet.Kind{
+		"symbol": parquet.ByteArray, "volume": parquet.Int64, "price": parquet.Double,
+		"suspended": parquet.Boolean, "timestamp": parquet.Int64, "bid": parquet.ByteArray,
+	} {
+		leaf, ok := file.Schema().Lookup(column)
+		require.True(t, ok, column)
+		assert.Equal(t, kind, leaf.Node.Type().Kind(), column)
+	}
+
+	// 未提供模式时使用文件中记录的模式
+	results, err := serializer.DeserializeMultiple(data, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "parquet_test", results[0].Schema.Name)
+	assert.Equal(t, int64(1200), results[0].Values["volume"])
+}
+
+func TestStructuredDataSerializer_ParquetSchemaMismatch(t *testing.T) {
+	serializer := NewStructuredDataSerializer(FormatParquet)
+	data, err := serializer.Serialize(newParquetTestData(t, "600000", time.Now()))
+	require.NoError(t, err)
+
+	// 目标模式缺少文件中的列
+	_, err = serializer.DeserializeMultiple(data, OrderBookSchema)
+	assert.Error(t, err)
+
+	// 列类型与字段类型不一致
+	mismatched := &DataSchema{Name: "parquet_test", Fields: make(map[string]*FieldDefinition)}
+	for name, def := range parquetTestSchema.Fields {
+		mismatched.Fields[name] = def
+	}
+	mismatched.Fields["volume"] = &FieldDefinition{Name: "volume", Type: FieldTypeString}
+	_, err = serializer.DeserializeMultiple(data, mismatched)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match field type")
+
+	_, err = serializer.DeserializeMultiple([]byte("not parquet"), parquetTestSchema)
+	assert.Error(t, err)
+}
+
+func TestStructuredDataSerializer_ParquetAutoMigrate(t *testing.T) {
+	registry := newStockRegistry(t)
+	serializer := NewStructuredDataSerializer(FormatParquet)
+	serializer.SetSchemaRegistry(registry)
+
+	data, err := serializer.Serialize(newStockV1Data(t, registry))
+	require.NoError(t, err)
+
+	results, err := serializer.DeserializeMultiple(data, StockDataSchema)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assertMigratedStock(t, results[0])
+}
+
+func TestParquetStorage_SaveFlushLoad(t *testing.T) {
+	config := DefaultParquetStorageConfig()
+	config.Directory = t.TempDir()
+	config.RowGroupSize = 3
+	config.FlushInterval = 0
+	storage, err := NewParquetStorage(config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	base := time.Date(2025, 8, 21, 10, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	for i := 0; i < 4; i++ {
+		require.NoError(t, storage.Save(ctx, core.StockData{
+			Symbol: fmt.Sprintf("60000%d", i%2), Name: "测试", Price: 10 + float64(i),
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	stats := storage.GetStats()
+	assert.Equal(t, int64(1), stats.TotalFiles, "达到 RowGroupSize 时写出分片")
+	assert.Equal(t, int64(3), stats.TotalRecords)
+	assert.Equal(t, int64(1), stats.BufferedRows)
+
+	loaded, err := storage.Load(ctx, core.Query{})
+	require.NoError(t, err)
+	assert.Len(t, loaded, 3, "未写出的缓冲不参与加载")
+
+	require.NoError(t, storage.Close())
+	require.NoError(t, storage.Close())
+	assert.Equal(t, int64(2), storage.GetStats().TotalFiles)
+
+	entries, err := os.ReadDir(config.Directory)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	loaded, err = storage.Load(ctx, core.Query{Symbols: []string{"600001"}})
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	stock, err := StructuredDataToStockData(loaded[0].(*StructuredData))
+	require.NoError(t, err)
+	assert.Equal(t, 11.0, stock.Price)
+
+	loaded, err = storage.Load(ctx, core.Query{StartTime: base.Add(90 * time.Second), Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, 12.0, loaded[0].(*StructuredData).Values["price"])
+
+	assert.Error(t, storage.Save(ctx, "unsupported"))
+}
+
+// benchmarkRows 生成 10 万条股票行情数据
+func benchmarkRows(b *testing.B) []*StructuredData {
+	b.Helper()
+	base := time.Date(2025, 8, 21, 9, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	rows := make([]*StructuredData, 100000)
+	for i := range rows {
+		price := 10 + float64(i%500)*0.01
+		sd, err := StockDataToStructuredData(core.StockData{
+			Symbol: fmt.Sprintf("60%04d", i%1000), Name: "测试股票", Price: price,
+			Open: price - 0.05, High: price + 0.1, Low: price - 0.1, PrevClose: 10,
+			Volume: int64(100000 + i), BidPrice1: price - 0.01, BidVolume1: 300,
+			AskPrice1: price + 0.01, AskVolume1: 200, Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		rows[i] = sd
+	}
+	return rows
+}
+
+func benchmarkSerializeMultiple(b *testing.B, format SerializationFormat) {
+	rows := benchmarkRows(b)
+	serializer := NewStructuredDataSerializer(format)
+
+	b.ResetTimer()
+	var size int
+	for i := 0; i < b.N; i++ {
+		data, err := serializer.SerializeMultiple(rows)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(data)
+	}
+	b.ReportMetric(float64(size), "file_bytes")
+	b.ReportMetric(float64(len(rows)*b.N)/b.Elapsed().Seconds(), "rows/s")
+}
+
+// BenchmarkSerializeMultiple_CSV 与 BenchmarkSerializeMultiple_Parquet 对比 10 万条数据的文件大小和写入吞吐
+func BenchmarkSerializeMultiple_CSV(b *testing.B) {
+	benchmarkSerializeMultiple(b, FormatCSV)
+}
+
+func BenchmarkSerializeMultiple_Parquet(b *testing.B) {
+	benchmarkSerializeMultiple(b, FormatParquet)
+}
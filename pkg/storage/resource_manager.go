@@ -368,6 +368,14 @@ func (fm *FileManager) OpenFile(filename string, flag int, perm os.FileMode) (*o
 	return file, nil
 }
 
+// CloseFile 关闭指定文件并停止管理
+func (fm *FileManager) CloseFile(filename string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	fm.closeFile(filename)
+}
+
 // closeFile 关闭指定文件
 func (fm *FileManager) closeFile(filename string) {
 	if file, exists := fm.openFiles[filename]; exists {
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExportSchemaDoc 把模式的字段渲染为 Markdown 表格（字段、类型、描述、备注），用于从代码生成文档。
+// 嵌套对象的子字段以 parent.child 形式列出，字段按 FieldOrder 排列
+func ExportSchemaDoc(schema *DataSchema) string {
+	var b strings.Builder
+	b.WriteString("| 字段 | 类型 | 描述 | 备注 |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	writeSchemaDocRows(&b, schema, "")
+	return b.String()
+}
+
+// writeSchemaDocRows 写入模式中每个字段的一行，递归展开嵌套对象
+func writeSchemaDocRows(b *strings.Builder, schema *DataSchema, prefix string) {
+	for _, name := range schemaFieldNames(schema) {
+		def, exists := schema.Fields[name]
+		if !exists {
+			continue
+		}
+		path := prefix + name
+		required := ""
+		if def.Required {
+			required = "（必填）"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s%s | %s |\n",
+			markdownCell(path), fieldTypeDoc(def), markdownCell(def.Description), required, markdownCell(def.Comment))
+		if def.Type == FieldTypeObject && def.SubSchema != nil {
+			writeSchemaDocRows(b, def.SubSchema, path+".")
+		}
+	}
+}
+
+// fieldTypeDoc 字段类型的文档写法，数组带上元素类型，如 array<float64>
+func fieldTypeDoc(def *FieldDefinition) string {
+	if def.Type == FieldTypeArray && def.ElementType != nil {
+		return fmt.Sprintf("array<%s>", fieldTypeDoc(def.ElementType))
+	}
+	return def.Type.String()
+}
+
+// markdownCell 转义表格单元格中的竖线和换行
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportSchemaDoc(t *testing.T) {
+	schema := &DataSchema{
+		Name:       "quote",
+		FieldOrder: []string{"symbol", "bids", "meta"},
+		Fields: map[string]*FieldDefinition{
+			"symbol": {Name: "symbol", Type: FieldTypeString, Description: "代码", Comment: "如 600000|000001", Required: true},
+			"bids": {Name: "bids", Type: FieldTypeArray, Description: "买盘",
+				ElementType: &FieldDefinition{Type: FieldTypeFloat64}},
+			"meta": {Name: "meta", Type: FieldTypeObject, Description: "元数据", SubSchema: &DataSchema{
+				FieldOrder: []string{"source"},
+				Fields:     map[string]*FieldDefinition{"source": {Name: "source", Type: FieldTypeString, Description: "来源"}},
+			}},
+		},
+	}
+
+	doc := ExportSchemaDoc(schema)
+	assert.Equal(t, []string{
+		"| 字段 | 类型 | 描述 | 备注 |",
+		"| --- | --- | --- | --- |",
+		`| symbol | string | 代码（必填） | 如 600000\|000001 |`,
+		"| bids | array<float64> | 买盘 |  |",
+		"| meta | object | 元数据 |  |",
+		"| meta.source | string | 来源 |  |",
+	}, strings.Split(strings.TrimSuffix(doc, "\n"), "\n"))
+
+	stockDoc := ExportSchemaDoc(StockDataSchema)
+	assert.Contains(t, stockDoc, "| symbol | string | 股票代码（必填） | 如600000、000001等 |")
+	assert.Equal(t, len(StockDataSchema.FieldOrder)+2, strings.Count(stockDoc, "\n"))
+}
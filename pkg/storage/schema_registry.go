@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// FieldMapper 版本迁移中对字段值的变换，直接修改 values
+type FieldMapper func(values map[string]interface{}) error
+
+// SchemaRegistry 按名称和版本管理数据模式，并在版本之间迁移 StructuredData
+type SchemaRegistry struct {
+	mu         sync.RWMutex
+	schemas    map[string]map[int]*DataSchema   // 名称 -> 版本 -> 模式
+	migrations map[string]map[int][]FieldMapper // 名称 -> 起始版本 -> 迁移到下一版本的字段映射
+}
+
+// NewSchemaRegistry 创建模式注册表
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		schemas:    make(map[string]map[int]*DataSchema),
+		migrations: make(map[string]map[int][]FieldMapper),
+	}
+}
+
+// Register 注册指定版本的模式，版本号从 1 开始
+func (r *SchemaRegistry) Register(name string, version int, schema *DataSchema) error {
+	if version < 1 {
+		return NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema %s: version must be positive, got %d", name, version))
+	}
+	if err := ValidateSchema(schema); err != nil {
+		return err
+	}
+	if schema.Name != name {
+		return NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema name mismatch: registering %s, schema is %s", name, schema.Name))
+	}
+	if schema.Version != 0 && schema.Version != version {
+		return NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema %s: version mismatch: registering v%d, schema is v%d", name, version, schema.Version))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions, exists := r.schemas[name]
+	if !exists {
+		versions = make(map[int]*DataSchema)
+		r.schemas[name] = versions
+	}
+	if _, exists := versions[version]; exists {
+		return NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema %s v%d already registered", name, version))
+	}
+
+	schema.Version = version
+	versions[version] = schema
+	return nil
+}
+
+// RegisterMigration 注册从 fromVersion 迁移到 fromVersion+1 时依次执行的字段映射
+func (r *SchemaRegistry) RegisterMigration(name string, fromVersion int, mappers ...FieldMapper) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	steps, exists := r.migrations[name]
+	if !exists {
+		steps = make(map[int][]FieldMapper)
+		r.migrations[name] = steps
+	}
+	steps[fromVersion] = append(steps[fromVersion], mappers...)
+}
+
+// Get 获取指定版本的模式
+func (r *SchemaRegistry) Get(name string, version int) (*DataSchema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, exists := r.schemas[name][version]
+	if !exists {
+		return nil, NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema %s v%d not registered", name, version))
+	}
+	return schema, nil
+}
+
+// GetLatest 获取最新版本的模式
+func (r *SchemaRegistry) GetLatest(name string) (*DataSchema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var latest *DataSchema
+	for version, schema := range r.schemas[name] {
+		if latest == nil || version > latest.Version {
+			latest = schema
+		}
+	}
+	if latest == nil {
+		return nil, NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema %s not registered", name))
+	}
+	return latest, nil
+}
+
+// Versions 返回模式已注册的版本，按升序排列
+func (r *SchemaRegistry) Versions(name string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]int, 0, len(r.schemas[name]))
+	for version := range r.schemas[name] {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Migrate 将数据从其记录的版本逐级迁移到 toVersion
+//
+// 每一级执行 RegisterMigration 注册的字段映射，目标模式中不存在的字段被丢弃，
+// 迁移结果需要通过目标模式的 ValidateData 验证，失败时 data 保持不变。只支持升级。
+func (r *SchemaRegistry) Migrate(data *StructuredData, toVersion int) error {
+	name := data.Schema.Name
+	fromVersion := data.SchemaVersion
+	if fromVersion > toVersion {
+		return NewStructuredDataError(ErrSchemaNotFound, "", fmt.Sprintf("schema %s: cannot downgrade from v%d to v%d", name, fromVersion, toVersion))
+	}
+
+	target, err := r.Get(name, toVersion)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]interface{}, len(data.Values))
+	for field, value := range data.Values {
+		values[field] = value
+	}
+
+	r.mu.RLock()
+	steps := r.migrations[name]
+	r.mu.RUnlock()
+
+	for version := fromVersion; version < toVersion; version++ {
+		for _, mapper := range steps[version] {
+			if err := mapper(values); err != nil {
+				return NewStructuredDataError(ErrFieldValidationFailed, "", fmt.Sprintf("schema %s: migrate v%d to v%d: %v", name, version, version+1, err))
+			}
+		}
+	}
+
+	migrated := &StructuredData{
+		Schema:        target,
+		SchemaVersion: toVersion,
+		Values:        make(map[string]interface{}, len(values)),
+		Timestamp:     data.Timestamp,
+	}
+	for field, value := range values {
+		if _, exists := target.Fields[field]; exists {
+			migrated.Values[field] = value
+		}
+	}
+	if err := migrated.ValidateData(); err != nil {
+		return err
+	}
+
+	*data = *migrated
+	return nil
+}
+
+// RenameField 将字段 from 重命名为 to
+func RenameField(from, to string) FieldMapper {
+	return func(values map[string]interface{}) error {
+		if value, exists := values[from]; exists {
+			values[to] = value
+			delete(values, from)
+		}
+		return nil
+	}
+}
+
+// FillDefault 字段缺失或为 nil 时填充默认值
+func FillDefault(field string, value interface{}) FieldMapper {
+	return func(values map[string]interface{}) error {
+		if values[field] == nil {
+			values[field] = value
+		}
+		return nil
+	}
+}
+
+// CoerceField 转换字段值的类型，字段缺失或为 nil 时跳过
+func CoerceField(field string, convert func(interface{}) (interface{}, error)) FieldMapper {
+	return func(values map[string]interface{}) error {
+		value := values[field]
+		if value == nil {
+			return nil
+		}
+		converted, err := convert(value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field, err)
+		}
+		values[field] = converted
+		return nil
+	}
+}
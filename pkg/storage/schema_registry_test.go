@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stockSchemaV1 股票模式 v1：没有振幅字段，名称字段为 stock_name，市场代码为字符串
+func stockSchemaV1() *DataSchema {
+	schema := &DataSchema{
+		Name:        StockDataSchema.Name,
+		Description: StockDataSchema.Description,
+		Fields:      make(map[string]*FieldDefinition),
+	}
+	for _, fieldName := range StockDataSchema.FieldOrder {
+		switch fieldName {
+		case "amplitude":
+			continue
+		case "name":
+			def := *StockDataSchema.Fields[fieldName]
+			def.Name = "stock_name"
+			schema.Fields["stock_name"] = &def
+			schema.FieldOrder = append(schema.FieldOrder, "stock_name")
+			continue
+		case "market_code":
+			def := *StockDataSchema.Fields[fieldName]
+			def.Type = FieldTypeString
+			schema.Fields[fieldName] = &def
+		default:
+			schema.Fields[fieldName] = StockDataSchema.Fields[fieldName]
+		}
+		schema.FieldOrder = append(schema.FieldOrder, fieldName)
+	}
+	return schema
+}
+
+// stockSchemaV2 股票模式 v2：即当前的 StockDataSchema
+func stockSchemaV2() *DataSchema {
+	schema := *StockDataSchema
+	return &schema
+}
+
+// deriveAmplitude 按 (最高-最低)/昨收 计算振幅百分比
+func deriveAmplitude(values map[string]interface{}) error {
+	high, _ := values["high"].(float64)
+	low, _ := values["low"].(float64)
+	prevClose, _ := values["prev_close"].(float64)
+	if prevClose > 0 {
+		values["amplitude"] = (high - low) / prevClose * 100
+	}
+	return nil
+}
+
+func parseMarketCode(value interface{}) (interface{}, error) {
+	return strconv.ParseInt(value.(string), 10, 64)
+}
+
+func newStockRegistry(t *testing.T) *SchemaRegistry {
+	t.Helper()
+	registry := NewSchemaRegistry()
+	require.NoError(t, registry.Register("stock_data", 1, stockSchemaV1()))
+	require.NoError(t, registry.Register("stock_data", 2, stockSchemaV2()))
+	registry.RegisterMigration("stock_data", 1,
+		RenameField("stock_name", "name"),
+		CoerceField("market_code", parseMarketCode),
+		deriveAmplitude,
+		FillDefault("amplitude", 0.0),
+	)
+	return registry
+}
+
+func newStockV1Data(t *testing.T, registry *SchemaRegistry) *StructuredData {
+	t.Helper()
+	schema, err := registry.Get("stock_data", 1)
+	require.NoError(t, err)
+
+	sd := NewStructuredData(schema)
+	fields := map[string]interface{}{
+		"symbol":      "600000",
+		"stock_name":  "浦发银行",
+		"price":       10.5,
+		"market_code": "1",
+		"high":        10.8,
+		"low":         10.2,
+		"prev_close":  10.0,
+		"volume":      int64(123456),
+		"timestamp":   time.Date(2025, 8, 21, 10, 30, 0, 0, time.FixedZone("CST", 8*3600)),
+	}
+	for fieldName, value := range fields {
+		require.NoError(t, sd.SetField(fieldName, value))
+	}
+	return sd
+}
+
+func assertMigratedStock(t *testing.T, sd *StructuredData) {
+	t.Helper()
+	assert.Equal(t, 2, sd.SchemaVersion)
+	assert.Equal(t, 2, sd.Schema.Version)
+	assert.Equal(t, "浦发银行", sd.Values["name"])
+	assert.NotContains(t, sd.Values, "stock_name")
+	assert.Equal(t, int64(1), sd.Values["market_code"])
+	assert.InDelta(t, 6.0, sd.Values["amplitude"], 1e-9)
+	assert.Equal(t, int64(123456), sd.Values["volume"])
+}
+
+func TestSchemaRegistry_RegisterAndGet(t *testing.T) {
+	registry := newStockRegistry(t)
+
+	v1, err := registry.Get("stock_data", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, v1.Version)
+	assert.NotContains(t, v1.Fields, "amplitude")
+
+	latest, err := registry.GetLatest("stock_data")
+	require.NoError(t, err)
+	assert.Equal(t, 2, latest.Version)
+	assert.Contains(t, latest.Fields, "amplitude")
+	assert.Equal(t, []int{1, 2}, registry.Versions("stock_data"))
+	assert.Zero(t, StockDataSchema.Version, "注册副本不应修改预定义模式")
+
+	_, err = registry.Get("stock_data", 3)
+	assert.Error(t, err)
+	_, err = registry.GetLatest("order_book")
+	assert.Error(t, err)
+
+	assert.Error(t, registry.Register("stock_data", 2, stockSchemaV2()), "重复注册")
+	assert.Error(t, registry.Register("stock_data", 0, stockSchemaV2()), "版本号必须为正")
+	assert.Error(t, registry.Register("quotes", 1, stockSchemaV2()), "名称不一致")
+	assert.Error(t, registry.Register("empty", 1, &DataSchema{Name: "empty"}), "无效模式")
+}
+
+func TestSchemaRegistry_MigrateV1ToV2(t *testing.T) {
+	registry := newStockRegistry(t)
+	sd := newStockV1Data(t, registry)
+	assert.Equal(t, 1, sd.SchemaVersion)
+
+	require.NoError(t, registry.Migrate(sd, 2))
+	assertMigratedStock(t, sd)
+	require.NoError(t, sd.ValidateData())
+
+	// 已是目标版本时迁移为空操作
+	require.NoError(t, registry.Migrate(sd, 2))
+	assertMigratedStock(t, sd)
+}
+
+func TestSchemaRegistry_MigrateFillsDefault(t *testing.T) {
+	registry := newStockRegistry(t)
+	sd := newStockV1Data(t, registry)
+	delete(sd.Values, "prev_close")
+
+	require.NoError(t, registry.Migrate(sd, 2))
+	assert.Equal(t, 0.0, sd.Values["amplitude"], "无法计算振幅时填充默认值")
+}
+
+func TestSchemaRegistry_MigrateErrors(t *testing.T) {
+	registry := newStockRegistry(t)
+
+	t.Run("downgrade", func(t *testing.T) {
+		sd := newStockV1Data(t, registry)
+		require.NoError(t, registry.Migrate(sd, 2))
+		assert.Error(t, registry.Migrate(sd, 1))
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		sd := newStockV1Data(t, registry)
+		assert.Error(t, registry.Migrate(sd, 3))
+		assert.Equal(t, 1, sd.SchemaVersion)
+	})
+
+	t.Run("mapper failure leaves data unchanged", func(t *testing.T) {
+		sd := newStockV1Data(t, registry)
+		sd.Values["market_code"] = "SH"
+
+		err := registry.Migrate(sd, 2)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field market_code")
+		assert.Equal(t, 1, sd.SchemaVersion)
+		assert.Equal(t, "浦发银行", sd.Values["stock_name"])
+	})
+
+	t.Run("validation failure leaves data unchanged", func(t *testing.T) {
+		broken := NewSchemaRegistry()
+		require.NoError(t, broken.Register("stock_data", 1, stockSchemaV1()))
+		require.NoError(t, broken.Register("stock_data", 2, stockSchemaV2()))
+		broken.RegisterMigration("stock_data", 1, RenameField("stock_name", "name"))
+
+		sd := newStockV1Data(t, broken)
+		err := broken.Migrate(sd, 2)
+		require.Error(t, err, "market_code 未转换为整数")
+		assert.Equal(t, 1, sd.SchemaVersion)
+	})
+}
+
+func TestStructuredDataSerializer_CSVAutoMigrate(t *testing.T) {
+	registry := newStockRegistry(t)
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	serializer.SetSchemaRegistry(registry)
+
+	data, err := serializer.Serialize(newStockV1Data(t, registry))
+	require.NoError(t, err)
+
+	lines := strings.Split(string(data), "\n")
+	assert.Equal(t, "# schema=stock_data version=1", lines[0])
+	assert.Contains(t, lines[1], "股票名称(stock_name)")
+
+	// 目标为最新模式
+	latest, err := registry.GetLatest("stock_data")
+	require.NoError(t, err)
+	result := NewStructuredData(latest)
+	require.NoError(t, serializer.Deserialize(data, result))
+	assertMigratedStock(t, result)
+
+	// 目标模式未指定版本时迁移到最新版本
+	results, err := serializer.DeserializeMultiple(data, StockDataSchema)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assertMigratedStock(t, results[0])
+
+	// 未设置注册表时元数据行被忽略，按目标模式解析
+	plain := NewStructuredDataSerializer(FormatCSV)
+	v1, err := registry.Get("stock_data", 1)
+	require.NoError(t, err)
+	raw := NewStructuredData(v1)
+	require.NoError(t, plain.Deserialize(data, raw))
+	assert.Equal(t, "1", raw.Values["market_code"])
+}
+
+func TestStructuredDataSerializer_CSVMultipleVersionMismatch(t *testing.T) {
+	registry := newStockRegistry(t)
+	serializer := NewStructuredDataSerializer(FormatCSV)
+
+	v1 := newStockV1Data(t, registry)
+	v2 := newStockV1Data(t, registry)
+	require.NoError(t, registry.Migrate(v2, 2))
+
+	_, err := serializer.SerializeMultiple([]*StructuredData{v1, v2})
+	assert.Error(t, err)
+}
+
+func TestStructuredDataSerializer_JSONAutoMigrate(t *testing.T) {
+	registry := newStockRegistry(t)
+	serializer := NewStructuredDataSerializer(FormatJSON)
+	serializer.SetSchemaRegistry(registry)
+
+	v1Data := newStockV1Data(t, registry)
+	data, err := serializer.Serialize(v1Data)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"schema_version":1`)
+
+	result := NewStructuredData(StockDataSchema)
+	require.NoError(t, serializer.Deserialize(data, result))
+	assertMigratedStock(t, result)
+	assert.Zero(t, StockDataSchema.Version)
+	assert.Contains(t, StockDataSchema.Fields, "amplitude", "反序列化不应修改目标模式")
+
+	list, err := serializer.SerializeMultiple([]*StructuredData{v1Data, newStockV1Data(t, registry)})
+	require.NoError(t, err)
+	results, err := serializer.DeserializeMultiple(list, StockDataSchema)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for i, sd := range results {
+		t.Run(fmt.Sprintf("item %d", i), func(t *testing.T) {
+			assertMigratedStock(t, sd)
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"stocksub/pkg/core"
 )
 
 // SerializationFormat 序列化格式
@@ -16,6 +18,7 @@ type SerializationFormat int
 const (
 	FormatCSV SerializationFormat = iota
 	FormatJSON
+	FormatParquet // 列式存储，适合长时间运行产生的大量数据
 )
 
 // String returns the string representation of SerializationFormat
@@ -25,15 +28,65 @@ func (sf SerializationFormat) String() string {
 		return "csv"
 	case FormatJSON:
 		return "json"
+	case FormatParquet:
+		return "parquet"
 	default:
 		return "unknown"
 	}
 }
 
+// NestedFieldMode 数组和对象字段在 CSV 中的表示方式
+type NestedFieldMode int
+
+const (
+	NestedFieldJSON    NestedFieldMode = iota // 整个字段 JSON 编码到一个单元格
+	NestedFieldExplode                        // 展开为带下标的列，如 bid[0].price
+)
+
+// CSVHeaderMode CSV 表头的写法，读取时自动识别
+type CSVHeaderMode string
+
+const (
+	HeaderCombined     CSVHeaderMode = "combined"     // 中文描述(英文字段名)，默认
+	HeaderNames        CSVHeaderMode = "names"        // 英文字段名
+	HeaderDescriptions CSVHeaderMode = "descriptions" // 中文描述，没有描述或描述重复的列使用默认写法
+	HeaderBoth         CSVHeaderMode = "both"         // 两行表头：第一行英文字段名，第二行中文描述
+)
+
+// validateHeaderMode 检查表头模式，空值等同 combined
+func validateHeaderMode(mode CSVHeaderMode) error {
+	switch mode {
+	case "", HeaderCombined, HeaderNames, HeaderDescriptions, HeaderBoth:
+		return nil
+	default:
+		return fmt.Errorf("unsupported CSV header mode: %q", mode)
+	}
+}
+
 // StructuredDataSerializer 结构化数据序列化器
 type StructuredDataSerializer struct {
-	format   SerializationFormat
-	timezone *time.Location // 时区设置，默认为上海时区
+	format     SerializationFormat
+	timezone   *time.Location  // 时区设置，默认为上海时区
+	nestedMode NestedFieldMode // 嵌套字段的 CSV 表示方式，默认 JSON 单元格
+	headerMode CSVHeaderMode   // CSV 表头的写法，默认 combined
+	registry   *SchemaRegistry // 模式注册表，设置后按数据记录的模式版本解析并自动迁移
+	lenient    bool            // CSV 宽松模式：出错的单元格替换为默认值并记为警告
+	warnings   []*CSVCellError // 宽松模式下最近一次 CSV 反序列化的警告
+}
+
+// csvColumn CSV 列与字段路径的对应关系
+type csvColumn struct {
+	field string           // 顶层字段名
+	path  string           // 列名，展开的嵌套字段形如 bid[0].price
+	steps []pathStep       // 从顶层字段值到该列值的访问路径
+	def   *FieldDefinition // 该列值的字段定义
+	desc  string           // 表头中文描述
+}
+
+// pathStep 嵌套字段访问路径中的一步
+type pathStep struct {
+	key   string // 对象子字段名
+	index int    // 数组下标，key 为空时有效
 }
 
 // NewStructuredDataSerializer 创建新的结构化数据序列化器
@@ -51,6 +104,33 @@ func NewStructuredDataSerializer(format SerializationFormat) *StructuredDataSeri
 	}
 }
 
+// SetNestedFieldMode 设置数组和对象字段在 CSV 中的表示方式
+func (s *StructuredDataSerializer) SetNestedFieldMode(mode NestedFieldMode) {
+	s.nestedMode = mode
+}
+
+// SetHeaderMode 设置写出 CSV 时的表头写法，读取时任何写法都能识别
+func (s *StructuredDataSerializer) SetHeaderMode(mode CSVHeaderMode) {
+	s.headerMode = mode
+}
+
+// SetLenient 设置 CSV 宽松模式。默认严格模式下任何单元格出错都返回汇总全部错误的 *CSVDecodeError；
+// 宽松模式下出错的单元格替换为字段默认值（没有默认值时为空），错误通过 Warnings 取得。
+// 引号不匹配、列数不一致等 CSV 格式错误在两种模式下都直接返回
+func (s *StructuredDataSerializer) SetLenient(lenient bool) {
+	s.lenient = lenient
+}
+
+// Warnings 返回宽松模式下最近一次 CSV 反序列化收集到的单元格错误
+func (s *StructuredDataSerializer) Warnings() []*CSVCellError {
+	return s.warnings
+}
+
+// SetSchemaRegistry 设置模式注册表，反序列化时按元数据中的模式版本选择模式并迁移到目标版本
+func (s *StructuredDataSerializer) SetSchemaRegistry(registry *SchemaRegistry) {
+	s.registry = registry
+}
+
 // Serialize 将 StructuredData 序列化为字节数组
 func (s *StructuredDataSerializer) Serialize(data interface{}) ([]byte, error) {
 	switch s.format {
@@ -58,6 +138,8 @@ func (s *StructuredDataSerializer) Serialize(data interface{}) ([]byte, error) {
 		return s.serializeToCSV(data)
 	case FormatJSON:
 		return s.serializeToJSON(data)
+	case FormatParquet:
+		return s.serializeToParquet(data)
 	default:
 		return nil, fmt.Errorf("unsupported serialization format: %v", s.format)
 	}
@@ -70,6 +152,8 @@ func (s *StructuredDataSerializer) Deserialize(data []byte, target interface{})
 		return s.deserializeFromCSV(data, target)
 	case FormatJSON:
 		return s.deserializeFromJSON(data, target)
+	case FormatParquet:
+		return s.deserializeFromParquet(data, target)
 	default:
 		return fmt.Errorf("unsupported deserialization format: %v", s.format)
 	}
@@ -82,6 +166,8 @@ func (s *StructuredDataSerializer) MimeType() string {
 		return "text/csv"
 	case FormatJSON:
 		return "application/json"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
 	default:
 		return "application/octet-stream"
 	}
@@ -95,11 +181,11 @@ func (s *StructuredDataSerializer) serializeToCSV(data interface{}) ([]byte, err
 	}
 
 	var buf bytes.Buffer
+	writeCSVMetadata(&buf, sd)
 	writer := csv.NewWriter(&buf)
 
 	// 生成CSV表头
-	headers := s.generateCSVHeaders(sd.Schema)
-	if err := writer.Write(headers); err != nil {
+	if err := writer.WriteAll(s.generateCSVHeaderRows(sd.Schema)); err != nil {
 		return nil, fmt.Errorf("failed to write CSV headers: %w", err)
 	}
 
@@ -124,14 +210,20 @@ func (s *StructuredDataSerializer) serializeToJSON(data interface{}) ([]byte, er
 		return nil, fmt.Errorf("data must be *StructuredData, got %T", data)
 	}
 
-	// 创建JSON兼容的结构
+	return json.Marshal(s.jsonEnvelope(sd))
+}
+
+// jsonEnvelope 创建JSON兼容的结构，浮点字段按字段精度舍入，版本化的数据额外记录 schema_version
+func (s *StructuredDataSerializer) jsonEnvelope(sd *StructuredData) map[string]interface{} {
 	jsonData := map[string]interface{}{
 		"schema":    sd.Schema,
-		"values":    sd.Values,
+		"values":    roundValues(sd.Values, sd.Schema),
 		"timestamp": sd.Timestamp.In(s.timezone).Format("2006-01-02 15:04:05"),
 	}
-
-	return json.Marshal(jsonData)
+	if sd.SchemaVersion > 0 {
+		jsonData["schema_version"] = sd.SchemaVersion
+	}
+	return jsonData
 }
 
 // deserializeFromCSV 从CSV格式反序列化
@@ -141,6 +233,16 @@ func (s *StructuredDataSerializer) deserializeFromCSV(data []byte, target interf
 		return fmt.Errorf("target must be *StructuredData, got %T", target)
 	}
 
+	name, version, data := readCSVMetadata(data)
+	source, toVersion, err := s.versionedSchema(sd.Schema, name, version)
+	if err != nil {
+		return err
+	}
+	if source != sd.Schema {
+		sd.Schema = source
+		sd.SchemaVersion = version
+	}
+
 	reader := csv.NewReader(bytes.NewReader(data))
 	records, err := reader.ReadAll()
 	if err != nil {
@@ -152,11 +254,6 @@ func (s *StructuredDataSerializer) deserializeFromCSV(data []byte, target interf
 	}
 
 	headers := records[0]
-	dataRow := records[1]
-
-	if len(headers) != len(dataRow) {
-		return fmt.Errorf("header count (%d) does not match data count (%d)", len(headers), len(dataRow))
-	}
 
 	// 解析表头，提取字段名并验证
 	fieldMapping, err := s.parseAndValidateCSVHeaders(headers, sd.Schema)
@@ -164,24 +261,24 @@ func (s *StructuredDataSerializer) deserializeFromCSV(data []byte, target interf
 		return err
 	}
 
-	// 解析数据行
-	for i, fieldName := range fieldMapping {
-		if fieldName == "" {
-			continue // 跳过无法识别的字段
-		}
+	index := csvColumnIndex(sd.Schema)
+	dataRows := skipDescriptionRow(records[1:], fieldMapping, index)
+	if len(dataRows) == 0 {
+		return fmt.Errorf("CSV data must contain at least header and one data row")
+	}
+	dataRow := dataRows[0]
 
-		fieldDef := sd.Schema.Fields[fieldName]
-		value, err := s.parseCSVValue(dataRow[i], fieldDef.Type)
-		if err != nil {
-			return NewStructuredDataError(ErrInvalidFieldType, fieldName, fmt.Sprintf("failed to parse value '%s': %v", dataRow[i], err))
-		}
+	if len(headers) != len(dataRow) {
+		return fmt.Errorf("header count (%d) does not match data count (%d)", len(headers), len(dataRow))
+	}
 
-		if err := sd.SetField(fieldName, value); err != nil {
-			return err
-		}
+	// 解析数据行
+	rowNumber := len(records) - len(dataRows) + 1
+	if err := s.csvCellsError(s.decodeCSVRow(sd, headers, dataRow, fieldMapping, index, rowNumber)); err != nil {
+		return err
 	}
 
-	return nil
+	return s.migrateTo(sd, toVersion)
 }
 
 // deserializeFromJSON 从JSON格式反序列化
@@ -195,16 +292,19 @@ func (s *StructuredDataSerializer) deserializeFromJSON(data []byte, target inter
 	if err := json.Unmarshal(data, &jsonData); err != nil {
 		return fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
+	expected := sd.Schema
 
-	// 解析schema
+	// 解析schema（解码到新对象，避免覆盖共享的模式定义）
 	if schemaData, exists := jsonData["schema"]; exists {
 		schemaBytes, err := json.Marshal(schemaData)
 		if err != nil {
 			return fmt.Errorf("failed to marshal schema: %w", err)
 		}
-		if err := json.Unmarshal(schemaBytes, &sd.Schema); err != nil {
+		var schema DataSchema
+		if err := json.Unmarshal(schemaBytes, &schema); err != nil {
 			return fmt.Errorf("failed to unmarshal schema: %w", err)
 		}
+		sd.Schema = &schema
 	}
 
 	// 解析values
@@ -223,56 +323,505 @@ func (s *StructuredDataSerializer) deserializeFromJSON(data []byte, target inter
 		}
 	}
 
-	return nil
+	// 记录了模式版本时按注册的模式还原字段类型并迁移
+	version := jsonSchemaVersion(jsonData)
+	sd.SchemaVersion = version
+	if version == 0 || sd.Schema == nil {
+		return nil
+	}
+	source, toVersion, err := s.versionedSchema(expected, sd.Schema.Name, version)
+	if err != nil || toVersion == 0 {
+		return err
+	}
+	values, err := coerceSchemaValues(sd.Values, source)
+	if err != nil {
+		return err
+	}
+	sd.Schema = source
+	sd.Values = values
+	return s.migrateTo(sd, toVersion)
 }
 
-// generateCSVHeaders 生成CSV表头（包含中文描述）
-func (s *StructuredDataSerializer) generateCSVHeaders(schema *DataSchema) []string {
-	headers := make([]string, len(schema.FieldOrder))
+// versionedSchema 根据元数据中记录的模式名称和版本确定解析使用的模式，以及需要迁移到的目标版本
+// 未设置注册表、数据未记录版本或与目标模式名称不同时沿用 target，目标版本为 0 表示不迁移
+func (s *StructuredDataSerializer) versionedSchema(target *DataSchema, name string, version int) (*DataSchema, int, error) {
+	if s.registry == nil || version == 0 || (target != nil && target.Name != name) {
+		return target, 0, nil
+	}
 
-	for i, fieldName := range schema.FieldOrder {
-		fieldDef, exists := schema.Fields[fieldName]
+	source, err := s.registry.Get(name, version)
+	if err != nil {
+		return nil, 0, err
+	}
+	if target != nil && target.Version > 0 {
+		return source, target.Version, nil
+	}
+	latest, err := s.registry.GetLatest(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return source, latest.Version, nil
+}
+
+// migrateTo 将数据迁移到目标版本，toVersion 为 0 或与当前版本相同时不做处理
+func (s *StructuredDataSerializer) migrateTo(sd *StructuredData, toVersion int) error {
+	if toVersion == 0 || toVersion == sd.SchemaVersion {
+		return nil
+	}
+	return s.registry.Migrate(sd, toVersion)
+}
+
+// csvMetadataPrefix CSV 首行模式元数据的前缀，格式为 "# schema=<名称> version=<版本>"
+const csvMetadataPrefix = "# schema="
+
+// writeCSVMetadata 版本化的数据在表头前写入模式元数据行
+func writeCSVMetadata(buf *bytes.Buffer, sd *StructuredData) {
+	if sd.SchemaVersion > 0 {
+		fmt.Fprintf(buf, "%s%s version=%d\n", csvMetadataPrefix, sd.Schema.Name, sd.SchemaVersion)
+	}
+}
+
+// readCSVMetadata 读取并去掉 CSV 首行的模式元数据，没有元数据时原样返回
+func readCSVMetadata(data []byte) (string, int, []byte) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !bytes.HasPrefix(data, []byte(csvMetadataPrefix)) {
+		return "", 0, data
+	}
+
+	line, rest := data, []byte(nil)
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line, rest = data[:i], data[i+1:]
+	}
+
+	var name string
+	var version int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(line)), csvMetadataPrefix+"%s version=%d", &name, &version); err != nil {
+		return "", 0, rest
+	}
+	return name, version, rest
+}
+
+// jsonSchemaVersion 读取 JSON 中记录的 schema_version
+func jsonSchemaVersion(jsonData map[string]interface{}) int {
+	if version, ok := jsonData["schema_version"].(float64); ok {
+		return int(version)
+	}
+	return 0
+}
+
+// jsonSchemaName 读取 JSON 中记录的模式名称
+func jsonSchemaName(jsonData map[string]interface{}) string {
+	if schema, ok := jsonData["schema"].(map[string]interface{}); ok {
+		if name, ok := schema["name"].(string); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// coerceSchemaValues 将 JSON 解码得到的字段值按模式还原为定义的类型，模式中不存在的字段保留原值
+func coerceSchemaValues(values map[string]interface{}, schema *DataSchema) (map[string]interface{}, error) {
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decoded map[string]interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]interface{}, len(decoded))
+	for field, value := range decoded {
+		fieldDef, exists := schema.Fields[field]
 		if !exists {
-			headers[i] = fieldName
+			result[field] = values[field]
 			continue
 		}
+		coerced, err := coerceJSONValue(value, fieldDef)
+		if err != nil {
+			return nil, NewStructuredDataError(ErrInvalidFieldType, field, err.Error())
+		}
+		result[field] = coerced
+	}
+	return result, nil
+}
 
-		// 格式：中文描述(英文字段名)
-		if fieldDef.Description != "" {
-			headers[i] = fmt.Sprintf("%s(%s)", fieldDef.Description, fieldName)
-		} else {
-			headers[i] = fieldName
+// generateCSVHeaders 生成CSV表头的第一行
+func (s *StructuredDataSerializer) generateCSVHeaders(schema *DataSchema) []string {
+	return s.generateCSVHeaderRows(schema)[0]
+}
+
+// generateCSVHeaderRows 按表头模式生成CSV表头，HeaderBoth 时为两行
+func (s *StructuredDataSerializer) generateCSVHeaderRows(schema *DataSchema) [][]string {
+	return csvHeaderRows(s.csvColumns(schema), s.headerMode)
+}
+
+// csvHeaderRows 按表头模式生成列的表头行
+func csvHeaderRows(columns []csvColumn, mode CSVHeaderMode) [][]string {
+	switch mode {
+	case HeaderNames:
+		names := make([]string, len(columns))
+		for i, column := range columns {
+			names[i] = column.path
+		}
+		return [][]string{names}
+	case HeaderDescriptions:
+		// 重复的描述（如展开的数组元素）无法还原出列，这些列保留英文字段名
+		counts := make(map[string]int, len(columns))
+		for _, column := range columns {
+			counts[column.desc]++
+		}
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			if column.desc != "" && counts[column.desc] == 1 {
+				headers[i] = column.desc
+			} else {
+				headers[i] = combinedHeader(column)
+			}
 		}
+		return [][]string{headers}
+	case HeaderBoth:
+		names := make([]string, len(columns))
+		descs := make([]string, len(columns))
+		for i, column := range columns {
+			names[i] = column.path
+			descs[i] = columnDescription(column)
+		}
+		return [][]string{names, descs}
+	default:
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = combinedHeader(column)
+		}
+		return [][]string{headers}
+	}
+}
+
+// combinedHeader 格式：中文描述(英文字段名)，没有描述时为英文字段名
+func combinedHeader(column csvColumn) string {
+	if column.desc != "" {
+		return fmt.Sprintf("%s(%s)", column.desc, column.path)
 	}
+	return column.path
+}
 
-	return headers
+// columnDescription 描述行中的单元格，没有描述时为英文字段名
+func columnDescription(column csvColumn) string {
+	if column.desc != "" {
+		return column.desc
+	}
+	return column.path
+}
+
+// skipDescriptionRow 第一行数据是两行表头中的描述行时跳过它
+func skipDescriptionRow(rows [][]string, fieldMapping []string, index map[string]csvColumn) [][]string {
+	if len(rows) == 0 || len(rows[0]) != len(fieldMapping) {
+		return rows
+	}
+	for i, name := range fieldMapping {
+		if name == "" {
+			continue
+		}
+		if rows[0][i] != columnDescription(index[name]) {
+			return rows
+		}
+	}
+	return rows[1:]
 }
 
 // generateCSVRecord 生成CSV数据行
 func (s *StructuredDataSerializer) generateCSVRecord(sd *StructuredData) []string {
-	record := make([]string, len(sd.Schema.FieldOrder))
+	columns := s.csvColumns(sd.Schema)
+	record := make([]string, len(columns))
 
-	for i, fieldName := range sd.Schema.FieldOrder {
-		value, err := sd.GetField(fieldName)
+	for i, column := range columns {
+		value, err := sd.GetField(column.field)
 		if err != nil || value == nil {
 			record[i] = ""
 			continue
 		}
 
-		fieldDef := sd.Schema.Fields[fieldName]
-		record[i] = s.formatCSVValue(value, fieldDef.Type)
+		record[i] = s.formatCSVValue(lookupPath(value, column.steps), column.def)
 	}
 
 	return record
 }
 
-// formatCSVValue 格式化CSV值
-func (s *StructuredDataSerializer) formatCSVValue(value interface{}, fieldType FieldType) string {
+// csvColumns 按当前嵌套字段模式生成 schema 的 CSV 列
+func (s *StructuredDataSerializer) csvColumns(schema *DataSchema) []csvColumn {
+	return schemaCSVColumns(schema, s.nestedMode)
+}
+
+// schemaCSVColumns 按嵌套字段模式生成 schema 的 CSV 列
+func schemaCSVColumns(schema *DataSchema, nestedMode NestedFieldMode) []csvColumn {
+	columns := make([]csvColumn, 0, len(schema.FieldOrder))
+
+	for _, fieldName := range schema.FieldOrder {
+		fieldDef, exists := schema.Fields[fieldName]
+		if !exists {
+			columns = append(columns, csvColumn{field: fieldName, path: fieldName})
+			continue
+		}
+
+		column := csvColumn{field: fieldName, path: fieldName, def: fieldDef, desc: fieldDef.Description}
+		if nestedMode == NestedFieldExplode {
+			columns = append(columns, explodeColumn(column)...)
+		} else {
+			columns = append(columns, column)
+		}
+	}
+
+	return columns
+}
+
+// csvColumnIndex 返回列名到列定义的索引，同时包含顶层字段和全部展开列，两种模式写出的 CSV 都可以读取
+func csvColumnIndex(schema *DataSchema) map[string]csvColumn {
+	index := make(map[string]csvColumn)
+	for fieldName, fieldDef := range schema.Fields {
+		column := csvColumn{field: fieldName, path: fieldName, def: fieldDef, desc: fieldDef.Description}
+		index[fieldName] = column
+		for _, leaf := range explodeColumn(column) {
+			index[leaf.path] = leaf
+		}
+	}
+	return index
+}
+
+// explodeColumn 将数组和对象列递归展开为叶子列，未设置 MaxItems 的数组保持为 JSON 单元格
+func explodeColumn(column csvColumn) []csvColumn {
+	def := column.def
+	var columns []csvColumn
+
+	switch {
+	case def.Type == FieldTypeArray && def.MaxItems > 0 && def.ElementType != nil:
+		for i := 0; i < def.MaxItems; i++ {
+			child := column.child(fmt.Sprintf("[%d]", i), pathStep{index: i}, def.ElementType)
+			columns = append(columns, explodeColumn(child)...)
+		}
+	case def.Type == FieldTypeObject && def.SubSchema != nil:
+		for _, key := range schemaFieldNames(def.SubSchema) {
+			subDef, exists := def.SubSchema.Fields[key]
+			if !exists {
+				continue
+			}
+			child := column.child("."+key, pathStep{key: key}, subDef)
+			child.desc += child.def.Description
+			columns = append(columns, explodeColumn(child)...)
+		}
+	default:
+		columns = append(columns, column)
+	}
+
+	return columns
+}
+
+// child 返回下一级嵌套字段对应的列
+func (c csvColumn) child(suffix string, step pathStep, def *FieldDefinition) csvColumn {
+	steps := make([]pathStep, len(c.steps), len(c.steps)+1)
+	copy(steps, c.steps)
+	return csvColumn{
+		field: c.field,
+		path:  c.path + suffix,
+		steps: append(steps, step),
+		def:   def,
+		desc:  c.desc,
+	}
+}
+
+// lookupPath 按访问路径取出嵌套值，路径不存在时返回 nil
+func lookupPath(value interface{}, steps []pathStep) interface{} {
+	for _, step := range steps {
+		if step.key != "" {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			value = obj[step.key]
+			continue
+		}
+
+		items, ok := toInterfaceSlice(value)
+		if !ok || step.index >= len(items) {
+			return nil
+		}
+		value = items[step.index]
+	}
+	return value
+}
+
+// setPath 按访问路径写入嵌套值，中间的数组和对象按需创建
+func setPath(container interface{}, steps []pathStep, value interface{}) interface{} {
+	if len(steps) == 0 {
+		return value
+	}
+
+	step := steps[0]
+	if step.key != "" {
+		obj, ok := container.(map[string]interface{})
+		if !ok {
+			obj = make(map[string]interface{})
+		}
+		obj[step.key] = setPath(obj[step.key], steps[1:], value)
+		return obj
+	}
+
+	items, _ := container.([]interface{})
+	for len(items) <= step.index {
+		items = append(items, nil)
+	}
+	items[step.index] = setPath(items[step.index], steps[1:], value)
+	return items
+}
+
+// pruneEmpty 去掉展开列组装结果中的空对象和数组末尾的空元素
+func pruneEmpty(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if pruned := pruneEmpty(item); pruned != nil {
+				v[key] = pruned
+			} else {
+				delete(v, key)
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = pruneEmpty(v[i])
+		}
+		for len(v) > 0 && v[len(v)-1] == nil {
+			v = v[:len(v)-1]
+		}
+		if len(v) == 0 {
+			return nil
+		}
+		return v
+	}
+	return value
+}
+
+// csvRow 解析后的一行 CSV 数据
+type csvRow struct {
+	order   []string               // 顶层字段的出现顺序
+	values  map[string]interface{} // 顶层字段值，展开列已组装回数组和对象
+	columns map[string]int         // 顶层字段首次出现的列下标，用于定位组装后的值的错误
+	failed  map[string]bool        // 有单元格出错的顶层字段
+	cells   []*CSVCellError
+}
+
+// parseCSVRow 解析一行 CSV 数据，每个单元格按字段定义解析并验证，展开列按路径组装回数组和对象。
+// 出错的单元格记入 cells 并替换为字段默认值，严格模式下由调用方丢弃整行
+func (s *StructuredDataSerializer) parseCSVRow(dataRow, headers, columnNames []string, index map[string]csvColumn, rowNumber int) *csvRow {
+	row := &csvRow{
+		values:  make(map[string]interface{}),
+		columns: make(map[string]int),
+		failed:  make(map[string]bool),
+	}
+	exploded := make(map[string]bool)
+	cellError := func(i int, err error) {
+		row.cells = append(row.cells, &CSVCellError{Row: rowNumber, Column: i + 1, Header: headers[i], Value: dataRow[i], Err: err})
+	}
+
+	for i, name := range columnNames {
+		if name == "" {
+			continue // 跳过无法识别的字段
+		}
+
+		column := index[name]
+		if _, seen := row.values[column.field]; !seen {
+			row.order = append(row.order, column.field)
+			row.values[column.field] = nil
+			row.columns[column.field] = i
+		}
+
+		value, err := s.parseCSVCell(dataRow[i], name, column)
+		if err != nil {
+			cellError(i, err)
+			row.failed[column.field] = true
+			value = column.def.DefaultValue
+		}
+
+		if len(column.steps) == 0 {
+			row.values[column.field] = value
+			continue
+		}
+		exploded[column.field] = true
+		if value != nil {
+			row.values[column.field] = setPath(row.values[column.field], column.steps, value)
+		}
+	}
+
+	// 展开列组装后的值整体验证，例如必填的数组全部为空
+	for _, fieldName := range row.order {
+		if !exploded[fieldName] {
+			continue
+		}
+		row.values[fieldName] = pruneEmpty(row.values[fieldName])
+		if row.failed[fieldName] {
+			continue
+		}
+		if err := ValidateFieldValue(fieldName, row.values[fieldName], index[fieldName].def); err != nil {
+			cellError(row.columns[fieldName], err)
+			row.failed[fieldName] = true
+			row.values[fieldName] = index[fieldName].def.DefaultValue
+		}
+	}
+
+	return row
+}
+
+// parseCSVCell 按列的字段定义解析单元格并用 ValidateFieldValue 验证。
+// 空单元格对应 nil，必填字段为空时报错；展开列的空单元格表示数组或对象中不存在的项，由组装后的整体验证检查
+func (s *StructuredDataSerializer) parseCSVCell(raw, name string, column csvColumn) (interface{}, error) {
+	value, err := s.parseCSVField(raw, column.def)
+	if err != nil {
+		return nil, NewStructuredDataError(ErrInvalidFieldType, name,
+			fmt.Sprintf("failed to parse value '%s' as %s: %v", raw, column.def.Type, err))
+	}
+	if value == nil && len(column.steps) > 0 {
+		return nil, nil
+	}
+	if err := ValidateFieldValue(name, value, column.def); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// decodeCSVRow 解析一行数据并写入 sd，返回该行的单元格错误
+func (s *StructuredDataSerializer) decodeCSVRow(sd *StructuredData, headers, dataRow, fieldMapping []string, index map[string]csvColumn, rowNumber int) []*CSVCellError {
+	row := s.parseCSVRow(dataRow, headers, fieldMapping, index, rowNumber)
+	for _, fieldName := range row.order {
+		if err := sd.SetField(fieldName, row.values[fieldName]); err != nil {
+			i := row.columns[fieldName]
+			row.cells = append(row.cells, &CSVCellError{Row: rowNumber, Column: i + 1, Header: headers[i], Value: dataRow[i], Err: err})
+		}
+	}
+	return row.cells
+}
+
+// csvCellsError 严格模式下把单元格错误汇总为 *CSVDecodeError；宽松模式下记为警告，不返回错误
+func (s *StructuredDataSerializer) csvCellsError(cells []*CSVCellError) error {
+	if s.lenient {
+		s.warnings = cells
+		return nil
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+	return &CSVDecodeError{Cells: cells}
+}
+
+// formatCSVValue 格式化CSV值，浮点数按字段精度四舍五入并保留固定位数
+func (s *StructuredDataSerializer) formatCSVValue(value interface{}, fieldDef *FieldDefinition) string {
 	if value == nil {
 		return ""
 	}
 
-	switch fieldType {
+	switch fieldDef.Type {
 	case FieldTypeString:
 		if str, ok := value.(string); ok {
 			return str
@@ -289,9 +838,9 @@ func (s *StructuredDataSerializer) formatCSVValue(value interface{}, fieldType F
 	case FieldTypeFloat64:
 		switch v := value.(type) {
 		case float32:
-			return strconv.FormatFloat(float64(v), 'f', 2, 32)
+			return formatCSVFloat(float64(v), fieldDef.DecimalPlaces())
 		case float64:
-			return strconv.FormatFloat(v, 'f', 2, 64)
+			return formatCSVFloat(v, fieldDef.DecimalPlaces())
 		}
 	case FieldTypeBool:
 		if b, ok := value.(bool); ok {
@@ -302,11 +851,28 @@ func (s *StructuredDataSerializer) formatCSVValue(value interface{}, fieldType F
 			// 使用上海时区格式化时间：YYYY-MM-DD HH:mm:ss
 			return t.In(s.timezone).Format("2006-01-02 15:04:05")
 		}
+	case FieldTypeArray, FieldTypeObject:
+		// 未展开的嵌套字段整体 JSON 编码
+		if b, err := json.Marshal(value); err == nil {
+			return string(b)
+		}
 	}
 
 	return fmt.Sprintf("%v", value)
 }
 
+// formatCSVFloat 四舍五入到 places 位小数；末尾的 0 去掉，但至少保留 DefaultFloatPrecision 位，
+// 保持旧版本两位小数的列格式（10.5 → 10.50，10.123 → 10.123）。places 为 PrecisionExact 时按最短形式输出
+func formatCSVFloat(v float64, places int) string {
+	formatted := strconv.FormatFloat(core.RoundHalfUp(v, places), 'f', places, 64)
+	if places <= DefaultFloatPrecision {
+		return formatted
+	}
+	keep := len(formatted) - places + DefaultFloatPrecision
+	trimmed := strings.TrimRight(formatted[keep:], "0")
+	return formatted[:keep] + trimmed
+}
+
 // parseCSVHeaders 解析CSV表头，提取字段名
 func (s *StructuredDataSerializer) parseCSVHeaders(headers []string) []string {
 	fieldNames := make([]string, len(headers))
@@ -334,7 +900,12 @@ func (s *StructuredDataSerializer) parseCSVHeaders(headers []string) []string {
 func (s *StructuredDataSerializer) parseAndValidateCSVHeaders(headers []string, schema *DataSchema) ([]string, error) {
 	fieldNames := s.parseCSVHeaders(headers)
 
-	// 验证字段是否存在于schema中，并提供详细的错误信息
+	// 验证字段是否存在于schema中（包括展开的嵌套字段列），并提供详细的错误信息
+	index := csvColumnIndex(schema)
+	descriptions := []map[string]string{
+		descriptionIndex(schemaCSVColumns(schema, NestedFieldJSON)),
+		descriptionIndex(schemaCSVColumns(schema, NestedFieldExplode)),
+	}
 	var unknownFields []string
 	var validFields []string
 
@@ -344,7 +915,16 @@ func (s *StructuredDataSerializer) parseAndValidateCSVHeaders(headers []string,
 			continue
 		}
 
-		if _, exists := schema.Fields[fieldName]; !exists {
+		if _, exists := index[fieldName]; !exists {
+			// 只有中文描述的表头，顶层字段优先于展开列
+			if path, ok := descriptions[0][headers[i]]; ok {
+				validFields = append(validFields, path)
+				continue
+			}
+			if path, ok := descriptions[1][headers[i]]; ok {
+				validFields = append(validFields, path)
+				continue
+			}
 			unknownFields = append(unknownFields, fmt.Sprintf("'%s' (from header '%s')", fieldName, headers[i]))
 			validFields = append(validFields, "")
 		} else {
@@ -370,6 +950,24 @@ func (s *StructuredDataSerializer) parseAndValidateCSVHeaders(headers []string,
 	return validFields, nil
 }
 
+// descriptionIndex 中文描述到列名的索引，不包含多个列共用的描述
+func descriptionIndex(columns []csvColumn) map[string]string {
+	descriptions := make(map[string]string, len(columns))
+	ambiguous := make(map[string]bool)
+	for _, column := range columns {
+		if column.desc == "" || ambiguous[column.desc] {
+			continue
+		}
+		if _, exists := descriptions[column.desc]; exists {
+			delete(descriptions, column.desc)
+			ambiguous[column.desc] = true
+			continue
+		}
+		descriptions[column.desc] = column.path
+	}
+	return descriptions
+}
+
 // parseCSVValue 解析CSV值
 func (s *StructuredDataSerializer) parseCSVValue(value string, fieldType FieldType) (interface{}, error) {
 	if value == "" {
@@ -380,19 +978,43 @@ func (s *StructuredDataSerializer) parseCSVValue(value string, fieldType FieldTy
 	case FieldTypeString:
 		return value, nil
 	case FieldTypeInt:
-		return strconv.ParseInt(value, 10, 64)
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
 	case FieldTypeFloat64:
-		return strconv.ParseFloat(value, 64)
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
 	case FieldTypeBool:
-		return strconv.ParseBool(value)
+		return strconv.ParseBool(strings.TrimSpace(value))
 	case FieldTypeTime:
-		// 解析上海时区时间：YYYY-MM-DD HH:mm:ss
-		return time.ParseInLocation("2006-01-02 15:04:05", value, s.timezone)
+		return s.parseCSVTime(strings.TrimSpace(value))
 	default:
 		return value, nil
 	}
 }
 
+// parseCSVTime 依次尝试 csvTimeLayouts，没有时区的时间按序列化器的时区（默认上海）解析
+func (s *StructuredDataSerializer) parseCSVTime(value string) (time.Time, error) {
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, s.timezone); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format, expected one of: %s", strings.Join(csvTimeLayouts, ", "))
+}
+
+// parseCSVField 按字段定义解析CSV值，数组和对象单元格按 JSON 解析
+func (s *StructuredDataSerializer) parseCSVField(value string, fieldDef *FieldDefinition) (interface{}, error) {
+	if value == "" || (fieldDef.Type != FieldTypeArray && fieldDef.Type != FieldTypeObject) {
+		return s.parseCSVValue(value, fieldDef.Type)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return coerceJSONValue(raw, fieldDef)
+}
+
 // DeserializeMultiple 批量反序列化多个 StructuredData
 func (s *StructuredDataSerializer) DeserializeMultiple(data []byte, schema *DataSchema) ([]*StructuredData, error) {
 	switch s.format {
@@ -400,6 +1022,8 @@ func (s *StructuredDataSerializer) DeserializeMultiple(data []byte, schema *Data
 		return s.deserializeMultipleFromCSV(data, schema)
 	case FormatJSON:
 		return s.deserializeMultipleFromJSON(data, schema)
+	case FormatParquet:
+		return s.deserializeMultipleFromParquet(data, schema)
 	default:
 		return nil, fmt.Errorf("unsupported deserialization format: %v", s.format)
 	}
@@ -407,6 +1031,12 @@ func (s *StructuredDataSerializer) DeserializeMultiple(data []byte, schema *Data
 
 // deserializeMultipleFromCSV 从CSV格式批量反序列化
 func (s *StructuredDataSerializer) deserializeMultipleFromCSV(data []byte, schema *DataSchema) ([]*StructuredData, error) {
+	name, version, data := readCSVMetadata(data)
+	schema, toVersion, err := s.versionedSchema(schema, name, version)
+	if err != nil {
+		return nil, err
+	}
+
 	reader := csv.NewReader(bytes.NewReader(data))
 	records, err := reader.ReadAll()
 	if err != nil {
@@ -417,46 +1047,59 @@ func (s *StructuredDataSerializer) deserializeMultipleFromCSV(data []byte, schem
 		return nil, fmt.Errorf("CSV data must contain at least header and one data row")
 	}
 
+	return s.structuredDataFromCSVRecords(records, schema, toVersion)
+}
+
+// structuredDataFromCSVRecords 解析表头和全部数据行，两行表头的描述行会被跳过
+func (s *StructuredDataSerializer) structuredDataFromCSVRecords(records [][]string, schema *DataSchema, toVersion int) ([]*StructuredData, error) {
 	headers := records[0]
-	dataRows := records[1:]
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], string(utf8BOM))
+	}
 
 	// 解析表头，提取字段名并验证
 	fieldMapping, err := s.parseAndValidateCSVHeaders(headers, schema)
 	if err != nil {
 		return nil, err
 	}
+	index := csvColumnIndex(schema)
 
-	// 批量解析数据行
+	// 数据行号从 2 开始，两行表头时从 3 开始
+	firstRow := 2
+	dataRows := skipDescriptionRow(records[1:], fieldMapping, index)
+	if len(dataRows) < len(records)-1 {
+		firstRow = 3
+	}
+
+	// 批量解析数据行，收集全部单元格错误后一起返回
 	result := make([]*StructuredData, 0, len(dataRows))
-	for rowIndex, dataRow := range dataRows {
+	var cells []*CSVCellError
+	for i, dataRow := range dataRows {
+		rowNumber := i + firstRow
 		if len(headers) != len(dataRow) {
-			return nil, fmt.Errorf("row %d: header count (%d) does not match data count (%d)",
-				rowIndex+2, len(headers), len(dataRow))
+			cells = append(cells, &CSVCellError{Row: rowNumber, Err: fmt.Errorf("header count (%d) does not match data count (%d)",
+				len(headers), len(dataRow))})
+			continue
 		}
 
 		sd := NewStructuredData(schema)
-
-		// 解析当前行的数据
-		for i, fieldName := range fieldMapping {
-			if fieldName == "" {
-				continue // 跳过无法识别的字段
-			}
-
-			fieldDef := schema.Fields[fieldName]
-			value, err := s.parseCSVValue(dataRow[i], fieldDef.Type)
-			if err != nil {
-				return nil, NewStructuredDataError(ErrInvalidFieldType, fieldName,
-					fmt.Sprintf("row %d: failed to parse value '%s': %v", rowIndex+2, dataRow[i], err))
+		if rowCells := s.decodeCSVRow(sd, headers, dataRow, fieldMapping, index, rowNumber); len(rowCells) > 0 {
+			cells = append(cells, rowCells...)
+			if !s.lenient {
+				continue
 			}
+		}
 
-			if err := sd.SetField(fieldName, value); err != nil {
-				return nil, fmt.Errorf("row %d: %w", rowIndex+2, err)
-			}
+		if err := s.migrateTo(sd, toVersion); err != nil {
+			return nil, fmt.Errorf("row %d: %w", rowNumber, err)
 		}
 
 		result = append(result, sd)
 	}
 
+	if err := s.csvCellsError(cells); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
@@ -469,11 +1112,22 @@ func (s *StructuredDataSerializer) deserializeMultipleFromJSON(data []byte, sche
 
 	result := make([]*StructuredData, 0, len(jsonDataList))
 	for i, jsonData := range jsonDataList {
-		sd := NewStructuredData(schema)
+		version := jsonSchemaVersion(jsonData)
+		itemSchema, toVersion, err := s.versionedSchema(schema, jsonSchemaName(jsonData), version)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		sd := NewStructuredData(itemSchema)
 
 		// 解析values
 		if valuesData, exists := jsonData["values"]; exists {
 			if values, ok := valuesData.(map[string]interface{}); ok {
+				if toVersion > 0 {
+					sd.SchemaVersion = version
+					if values, err = coerceSchemaValues(values, itemSchema); err != nil {
+						return nil, fmt.Errorf("item %d: %w", i, err)
+					}
+				}
 				for fieldName, value := range values {
 					if err := sd.SetField(fieldName, value); err != nil {
 						return nil, fmt.Errorf("item %d: failed to set field %s: %w", i, fieldName, err)
@@ -500,6 +1154,10 @@ func (s *StructuredDataSerializer) deserializeMultipleFromJSON(data []byte, sche
 			return nil, fmt.Errorf("item %d: %w", i, err)
 		}
 
+		if err := s.migrateTo(sd, toVersion); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+
 		result = append(result, sd)
 	}
 
@@ -517,6 +1175,8 @@ func (s *StructuredDataSerializer) SerializeMultiple(dataList []*StructuredData)
 		return s.serializeMultipleToCSV(dataList)
 	case FormatJSON:
 		return s.serializeMultipleToJSON(dataList)
+	case FormatParquet:
+		return s.serializeMultipleToParquet(dataList)
 	default:
 		return nil, fmt.Errorf("unsupported serialization format: %v", s.format)
 	}
@@ -528,13 +1188,14 @@ func (s *StructuredDataSerializer) serializeMultipleToCSV(dataList []*Structured
 		return []byte{}, nil
 	}
 
+	// 使用第一个数据的schema生成表头
+	firstData := dataList[0]
+
 	var buf bytes.Buffer
+	writeCSVMetadata(&buf, firstData)
 	writer := csv.NewWriter(&buf)
 
-	// 使用第一个数据的schema生成表头
-	firstData := dataList[0]
-	headers := s.generateCSVHeaders(firstData.Schema)
-	if err := writer.Write(headers); err != nil {
+	if err := writer.WriteAll(s.generateCSVHeaderRows(firstData.Schema)); err != nil {
 		return nil, fmt.Errorf("failed to write CSV headers: %w", err)
 	}
 
@@ -544,6 +1205,9 @@ func (s *StructuredDataSerializer) serializeMultipleToCSV(dataList []*Structured
 		if sd.Schema.Name != firstData.Schema.Name {
 			return nil, fmt.Errorf("inconsistent schema: expected %s, got %s", firstData.Schema.Name, sd.Schema.Name)
 		}
+		if sd.SchemaVersion != firstData.SchemaVersion {
+			return nil, fmt.Errorf("inconsistent schema version: expected v%d, got v%d", firstData.SchemaVersion, sd.SchemaVersion)
+		}
 
 		record := s.generateCSVRecord(sd)
 		if err := writer.Write(record); err != nil {
@@ -564,11 +1228,7 @@ func (s *StructuredDataSerializer) serializeMultipleToJSON(dataList []*Structure
 	jsonDataList := make([]map[string]interface{}, len(dataList))
 
 	for i, sd := range dataList {
-		jsonDataList[i] = map[string]interface{}{
-			"schema":    sd.Schema,
-			"values":    sd.Values,
-			"timestamp": sd.Timestamp.In(s.timezone).Format("2006-01-02 15:04:05"),
-		}
+		jsonDataList[i] = s.jsonEnvelope(sd)
 	}
 
 	return json.Marshal(jsonDataList)
@@ -474,6 +474,56 @@ func TestStructuredDataSerializer_TimeZoneHandling(t *testing.T) {
 	assert.True(t, parsedTime.Equal(expectedTime))
 }
 
+func TestStructuredDataSerializer_FieldPrecision(t *testing.T) {
+	sd := NewStructuredData(StockDataSchema)
+	require.NoError(t, sd.SetField("symbol", "600000"))
+	require.NoError(t, sd.SetField("price", 10.500000000000002))
+	require.NoError(t, sd.SetField("change", -0.0005))
+	require.NoError(t, sd.SetField("change_percent", -1.005))
+	require.NoError(t, sd.SetField("turnover", 1234.565))
+	require.NoError(t, sd.SetField("high", 10.1234))
+
+	data, err := NewStructuredDataSerializer(FormatJSON).Serialize(sd)
+	require.NoError(t, err)
+	jsonContent := string(data)
+	assert.Contains(t, jsonContent, `"price":10.5,`, "价格默认保留 3 位")
+	assert.Contains(t, jsonContent, `"change":-0.001`, "负数远离零进位")
+	assert.Contains(t, jsonContent, `"change_percent":-1.01`, "百分比默认保留 2 位")
+	assert.Contains(t, jsonContent, `"turnover":1234.57`)
+	assert.Contains(t, jsonContent, `"high":10.123`)
+	assert.Equal(t, 10.500000000000002, sd.Values["price"], "序列化不修改原数据")
+
+	data, err = NewStructuredDataSerializer(FormatCSV).Serialize(sd)
+	require.NoError(t, err)
+	row := strings.Split(strings.TrimSpace(string(data)), "\n")[1]
+	assert.Contains(t, row, ",10.50,-0.001,-1.01,", "CSV 至少保留两位小数")
+	assert.Contains(t, row, ",10.123,")
+	assert.Contains(t, row, ",1234.57,")
+
+	// 精度可以按字段配置
+	schema := &DataSchema{
+		Name: "precision_test",
+		Fields: map[string]*FieldDefinition{
+			"nav":   {Name: "nav", Type: FieldTypeFloat64, Precision: 4},
+			"ratio": {Name: "ratio", Type: FieldTypeFloat64, Precision: PrecisionExact},
+		},
+		FieldOrder: []string{"nav", "ratio"},
+	}
+	require.NoError(t, ValidateSchema(schema))
+	custom := NewStructuredData(schema)
+	require.NoError(t, custom.SetField("nav", 1.00005))
+	require.NoError(t, custom.SetField("ratio", 0.123456789))
+	data, err = NewStructuredDataSerializer(FormatCSV).Serialize(custom)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "1.0001,0.123456789")
+
+	assert.Equal(t, 10.5, StockDataSchema.RoundFloat("price", 10.500000000000002))
+	assert.Equal(t, 1.23456, StockDataSchema.RoundFloat("unknown", 1.23456), "未定义的字段不舍入")
+
+	assert.Error(t, ValidateFieldDefinition("nav", &FieldDefinition{Name: "nav", Type: FieldTypeInt, Precision: 2}), "只有浮点字段可以设置精度")
+	assert.Error(t, ValidateFieldDefinition("nav", &FieldDefinition{Name: "nav", Type: FieldTypeFloat64, Precision: -2}))
+}
+
 // 辅助函数：创建测试用的 StructuredData
 func createTestStructuredData(t *testing.T) *StructuredData {
 	sd := NewStructuredData(StockDataSchema)
@@ -517,3 +567,74 @@ func createTestStructuredData2(t *testing.T) *StructuredData {
 
 	return sd
 }
+
+func TestStructuredDataSerializer_HeaderModesRoundTrip(t *testing.T) {
+	tests := []struct {
+		mode       CSVHeaderMode
+		headerRows int
+		firstCell  string
+	}{
+		{HeaderCombined, 1, "股票代码(symbol)"},
+		{HeaderNames, 1, "symbol"},
+		{HeaderDescriptions, 1, "股票代码"},
+		{HeaderBoth, 2, "symbol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			serializer := NewStructuredDataSerializer(FormatCSV)
+			serializer.SetHeaderMode(tt.mode)
+
+			data, err := serializer.SerializeMultiple([]*StructuredData{createTestStructuredData(t), createTestStructuredData2(t)})
+			require.NoError(t, err)
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			require.Len(t, lines, tt.headerRows+2)
+			assert.True(t, strings.HasPrefix(lines[0], tt.firstCell), lines[0])
+			if tt.mode == HeaderBoth {
+				assert.True(t, strings.HasPrefix(lines[1], "股票代码,股票名称,当前价格"), lines[1])
+			}
+
+			// 读取端不需要知道写入时的表头写法
+			list, err := NewStructuredDataSerializer(FormatCSV).DeserializeMultiple(data, StockDataSchema)
+			require.NoError(t, err)
+			require.Len(t, list, 2)
+			assert.Equal(t, "600000", list[0].Values["symbol"])
+			assert.Equal(t, "平安银行", list[1].Values["name"])
+			assert.Equal(t, 12.8, list[1].Values["price"])
+			assert.Equal(t, int64(980000), list[1].Values["volume"])
+
+			single, err := serializer.Serialize(createTestStructuredData(t))
+			require.NoError(t, err)
+			sd := NewStructuredData(StockDataSchema)
+			require.NoError(t, serializer.Deserialize(single, sd))
+			assert.Equal(t, "浦发银行", sd.Values["name"])
+			assert.Equal(t, 1.45, sd.Values["change_percent"], "描述中带括号的列也能识别")
+		})
+	}
+}
+
+func TestStructuredDataSerializer_DescriptionHeaderWithExplodedColumns(t *testing.T) {
+	schema := &DataSchema{
+		Name:       "depth",
+		FieldOrder: []string{"symbol", "bids"},
+		Fields: map[string]*FieldDefinition{
+			"symbol": {Name: "symbol", Type: FieldTypeString, Description: "代码"},
+			"bids": {Name: "bids", Type: FieldTypeArray, Description: "买盘", MaxItems: 2,
+				ElementType: &FieldDefinition{Type: FieldTypeFloat64}},
+		},
+	}
+	sd := NewStructuredData(schema)
+	require.NoError(t, sd.SetField("symbol", "600000"))
+	require.NoError(t, sd.SetField("bids", []interface{}{10.49, 10.48}))
+
+	serializer := NewStructuredDataSerializer(FormatCSV)
+	serializer.SetNestedFieldMode(NestedFieldExplode)
+	serializer.SetHeaderMode(HeaderDescriptions)
+	data, err := serializer.Serialize(sd)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "代码,买盘(bids[0]),买盘(bids[1])\n"), "重复的描述保留字段名")
+
+	decoded := NewStructuredData(schema)
+	require.NoError(t, serializer.Deserialize(data, decoded))
+	assert.Equal(t, []interface{}{10.49, 10.48}, decoded.Values["bids"])
+}
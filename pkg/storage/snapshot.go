@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"reflect"
+)
+
+// StructuredData 不是并发安全的：同一个实例只能由一个 goroutine 修改，且修改期间不能被其他 goroutine 读取。
+// 需要交给其他 goroutine（如 BatchWriter 的刷新协程）时，先用 Freeze 取得不可修改的快照，
+// 生产者继续修改自己的工作副本。BatchWriter.Write 收到可修改的实例时会自动生成快照。
+
+// Freeze 返回不可修改的快照，已冻结的实例直接返回自身。
+// 快照深拷贝了 Values，SetField、SetFieldSafe 和 MaterializeComputed 返回 ErrDataFrozen；
+// Values 仍是导出字段，调用方不能直接修改快照的 Values。Schema 在实例间共享，同样视为只读
+func (sd *StructuredData) Freeze() *StructuredData {
+	if sd.frozen {
+		return sd
+	}
+	snapshot := sd.Clone()
+	snapshot.frozen = true
+	return snapshot
+}
+
+// Clone 返回可修改的深拷贝，可用于从快照派生新的工作副本
+func (sd *StructuredData) Clone() *StructuredData {
+	values := make(map[string]interface{}, len(sd.Values))
+	for name, value := range sd.Values {
+		values[name] = deepCopyValue(value)
+	}
+	return &StructuredData{
+		Schema:        sd.Schema,
+		Values:        values,
+		Timestamp:     sd.Timestamp,
+		SchemaVersion: sd.SchemaVersion,
+	}
+}
+
+// IsFrozen 是否为 Freeze 生成的快照
+func (sd *StructuredData) IsFrozen() bool {
+	return sd.frozen
+}
+
+// checkMutable 快照不允许修改
+func (sd *StructuredData) checkMutable(fieldName string) error {
+	if sd.frozen {
+		return NewStructuredDataError(ErrDataFrozen, fieldName, "structured data is frozen, modify a Clone instead")
+	}
+	return nil
+}
+
+// deepCopyValue 深拷贝字段值中的切片和 map，其他值按值复制
+func deepCopyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = deepCopyValue(item)
+		}
+		return items
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = deepCopyValue(item)
+		}
+		return m
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return value
+		}
+		items := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			items.Index(i).Set(deepCopyReflect(rv.Index(i)))
+		}
+		return items.Interface()
+	case reflect.Map:
+		if rv.IsNil() {
+			return value
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), deepCopyReflect(iter.Value()))
+		}
+		return m.Interface()
+	default:
+		return value
+	}
+}
+
+// deepCopyReflect 对切片或 map 的元素做 deepCopyValue，保持元素的静态类型
+func deepCopyReflect(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && v.IsNil() {
+		return v
+	}
+	copied := deepCopyValue(v.Interface())
+	if copied == nil {
+		return reflect.Zero(v.Type())
+	}
+	return reflect.ValueOf(copied).Convert(v.Type())
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func TestStructuredData_CloneIsIndependent(t *testing.T) {
+	sd := NewStructuredData(StockDataSchema)
+	require.NoError(t, sd.SetField("symbol", "600000"))
+	sd.Values["tags"] = []interface{}{"bank", map[string]interface{}{"level": 1}}
+	sd.Values["levels"] = []float64{1.1, 1.2}
+
+	clone := sd.Clone()
+	require.False(t, clone.IsFrozen())
+	require.NoError(t, clone.SetField("symbol", "000001"))
+	clone.Values["tags"].([]interface{})[1].(map[string]interface{})["level"] = 2
+	clone.Values["levels"].([]float64)[0] = 9.9
+
+	assert.Equal(t, "600000", sd.Values["symbol"])
+	assert.Equal(t, 1, sd.Values["tags"].([]interface{})[1].(map[string]interface{})["level"], "嵌套值是深拷贝")
+	assert.Equal(t, 1.1, sd.Values["levels"].([]float64)[0], "类型化切片是深拷贝")
+	assert.Same(t, sd.Schema, clone.Schema, "模式共享")
+}
+
+func TestStructuredData_FrozenRejectsWrites(t *testing.T) {
+	sd := NewStructuredData(StockDataSchema)
+	require.NoError(t, sd.SetField("symbol", "600000"))
+
+	snapshot := sd.Freeze()
+	require.True(t, snapshot.IsFrozen())
+	assert.Same(t, snapshot, snapshot.Freeze(), "已冻结的实例不再复制")
+
+	for _, err := range []error{
+		snapshot.SetField("symbol", "000001"),
+		snapshot.SetFieldSafe("symbol", "000001"),
+		snapshot.MaterializeComputed(),
+	} {
+		require.Error(t, err)
+		assert.Equal(t, ErrDataFrozen, err.(*StructuredDataError).Code)
+	}
+	assert.Equal(t, "600000", snapshot.Values["symbol"])
+
+	// 原实例和快照派生的副本仍可修改
+	require.NoError(t, sd.SetField("symbol", "000001"))
+	assert.Equal(t, "600000", snapshot.Values["symbol"])
+	require.NoError(t, snapshot.Clone().SetField("symbol", "000002"))
+}
+
+// readingStorage 保存时读取 StructuredData 的全部字段，用于 -race 检测
+type readingStorage struct {
+	mu      sync.Mutex
+	symbols []string
+}
+
+func (s *readingStorage) Save(ctx context.Context, data interface{}) error {
+	return s.BatchSave(ctx, []interface{}{data})
+}
+
+func (s *readingStorage) BatchSave(ctx context.Context, dataList []interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, data := range dataList {
+		sd := data.(*StructuredData)
+		for name := range sd.Schema.Fields {
+			_, _ = sd.GetField(name)
+		}
+		s.symbols = append(s.symbols, sd.Values["symbol"].(string))
+	}
+	return nil
+}
+
+func (s *readingStorage) Load(ctx context.Context, query core.Query) ([]interface{}, error) {
+	return nil, nil
+}
+
+func (s *readingStorage) Delete(ctx context.Context, query core.Query) error { return nil }
+
+func (s *readingStorage) Close() error { return nil }
+
+func TestBatchWriter_SnapshotsMutableStructuredData(t *testing.T) {
+	for name, optim := range map[string]bool{"regular": false, "structured_optim": true} {
+		t.Run(name, func(t *testing.T) {
+			backend := &readingStorage{}
+			bw := NewBatchWriter(backend, BatchWriterConfig{
+				BatchSize:                 5,
+				MaxBufferSize:             1000,
+				FlushInterval:             time.Millisecond,
+				EnableAsync:               true,
+				EnableStructuredDataOptim: optim,
+				StructuredDataBatchSize:   5,
+			})
+
+			// 生产者复用同一个工作副本，写入后立即修改，刷新协程读到的是写入时的快照
+			ctx := context.Background()
+			working := NewStructuredData(StockDataSchema)
+			for i := 0; i < 200; i++ {
+				require.NoError(t, working.SetField("symbol", fmt.Sprintf("%06d", i)))
+				require.NoError(t, working.SetField("price", float64(i)))
+				require.NoError(t, bw.Write(ctx, working))
+			}
+			require.NoError(t, bw.Close())
+
+			backend.mu.Lock()
+			defer backend.mu.Unlock()
+			require.Len(t, backend.symbols, 200)
+			for i, symbol := range backend.symbols {
+				assert.Equal(t, fmt.Sprintf("%06d", i), symbol)
+			}
+		})
+	}
+}
@@ -835,3 +835,63 @@ func TestCSVStorage_Save_WithStructuredData_UsesShanghaiTimezone(t *testing.T) {
 	// 验证时间格式为上海时区 (UTC+8)，所以 UTC 10:30 应该显示为 18:30
 	assert.Contains(t, dataRow, "2025-08-24 18:30:00", "Time should be formatted in Shanghai timezone")
 }
+
+func TestCSVStorage_HeaderModeBothLoads(t *testing.T) {
+	config := DefaultCSVStorageConfig()
+	config.Directory = t.TempDir()
+	config.FlushInterval = 0
+	config.HeaderMode = HeaderBoth
+	storage, err := NewCSVStorage(config)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	ctx := context.Background()
+	timestamp := time.Date(2025, 8, 25, 10, 0, 0, 0, time.UTC)
+	for _, stock := range []core.StockData{
+		{Symbol: "600000", Name: "浦发银行", Price: 10.5, Timestamp: timestamp},
+		{Symbol: "000001", Name: "平安银行", Price: 12.3, Timestamp: timestamp.Add(time.Minute)},
+	} {
+		sd, err := StockDataToStructuredData(stock)
+		require.NoError(t, err)
+		require.NoError(t, storage.Save(ctx, sd))
+	}
+	require.NoError(t, storage.Flush())
+
+	files, err := filepath.Glob(filepath.Join(config.Directory, "*structured_stock_data*.csv"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	rows, err := readCSVDataFile(files[0])
+	require.NoError(t, err)
+	require.Len(t, rows, 4, "两行表头加两行数据")
+	assert.Equal(t, "symbol", rows[0][0])
+	assert.Equal(t, "股票代码", rows[1][0])
+
+	loaded, err := storage.Load(ctx, core.Query{})
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	first := loaded[0].(*StructuredData)
+	assert.Equal(t, "浦发银行", first.Values["name"])
+	assert.True(t, timestamp.Equal(first.Timestamp))
+
+	loaded, err = storage.Load(ctx, core.Query{Symbols: []string{"000001"}, StartTime: timestamp.Add(time.Second)})
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, 12.3, loaded[0].(*StructuredData).Values["price"])
+
+	// 重新打开后续写，表头不重复写入，新实例未写入前也能通过内置模式读取
+	require.NoError(t, storage.Close())
+	reopened, err := NewCSVStorage(config)
+	require.NoError(t, err)
+	defer reopened.Close()
+	loaded, err = reopened.Load(ctx, core.Query{})
+	require.NoError(t, err)
+	assert.Len(t, loaded, 2)
+}
+
+func TestNewCSVStorage_RejectsInvalidHeaderMode(t *testing.T) {
+	config := DefaultCSVStorageConfig()
+	config.Directory = t.TempDir()
+	config.HeaderMode = "chinese"
+	_, err := NewCSVStorage(config)
+	assert.ErrorContains(t, err, "header mode")
+}
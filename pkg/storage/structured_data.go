@@ -19,6 +19,8 @@ const (
 	FieldTypeFloat64
 	FieldTypeBool
 	FieldTypeTime
+	FieldTypeArray  // 数组，元素类型由 ElementType 定义
+	FieldTypeObject // 嵌套对象，结构由 SubSchema 定义
 )
 
 // String returns the string representation of FieldType
@@ -34,6 +36,10 @@ func (ft FieldType) String() string {
 		return "bool"
 	case FieldTypeTime:
 		return "time"
+	case FieldTypeArray:
+		return "array"
+	case FieldTypeObject:
+		return "object"
 	default:
 		return "unknown"
 	}
@@ -48,21 +54,62 @@ type FieldDefinition struct {
 	Required     bool                    `json:"required"`      // 是否必填
 	DefaultValue interface{}             `json:"default_value"` // 默认值
 	Validator    func(interface{}) error `json:"-"`             // 验证函数（不序列化）
+
+	ElementType *FieldDefinition `json:"element_type,omitempty"` // 数组元素定义（FieldTypeArray）
+	MaxItems    int              `json:"max_items,omitempty"`    // 数组最大长度，CSV 展开为列时使用，0 表示不限
+	SubSchema   *DataSchema      `json:"sub_schema,omitempty"`   // 嵌套对象结构（FieldTypeObject）
+
+	Precision int `json:"precision,omitempty"` // 浮点字段保留的小数位数，0 使用 DefaultFloatPrecision，PrecisionExact 不舍入
+
+	Compute   ComputeFunc `json:"-"`                    // 计算函数，未存储值时在 GetField 中求值（不序列化）
+	DependsOn []string    `json:"depends_on,omitempty"` // 计算函数依赖的字段，用于检测计算字段之间的循环依赖
+}
+
+const (
+	DefaultFloatPrecision = core.AmountPrecision // 未设置 Precision 的浮点字段保留的小数位数
+	PrecisionExact        = -1                   // 不舍入，按原值序列化
+)
+
+// DecimalPlaces 浮点字段序列化时保留的小数位数，-1 表示不舍入
+func (fd *FieldDefinition) DecimalPlaces() int {
+	switch {
+	case fd.Precision == 0:
+		return DefaultFloatPrecision
+	case fd.Precision < 0:
+		return PrecisionExact
+	default:
+		return fd.Precision
+	}
 }
 
 // DataSchema 数据模式定义
 type DataSchema struct {
-	Name        string                      `json:"name"`        // 模式名称
-	Description string                      `json:"description"` // 模式描述
-	Fields      map[string]*FieldDefinition `json:"fields"`      // 字段定义
-	FieldOrder  []string                    `json:"field_order"` // 字段顺序（用于CSV输出）
+	Name        string                      `json:"name"`              // 模式名称
+	Description string                      `json:"description"`       // 模式描述
+	Fields      map[string]*FieldDefinition `json:"fields"`            // 字段定义
+	FieldOrder  []string                    `json:"field_order"`       // 字段顺序（用于CSV输出）
+	Version     int                         `json:"version,omitempty"` // 模式版本，0 表示未版本化
+}
+
+// RoundFloat 按字段精度四舍五入，未定义的字段和非浮点字段原样返回
+func (s *DataSchema) RoundFloat(field string, v float64) float64 {
+	def, ok := s.Fields[field]
+	if !ok || def.Type != FieldTypeFloat64 {
+		return v
+	}
+	return core.RoundHalfUp(v, def.DecimalPlaces())
 }
 
-// StructuredData 结构化数据，支持动态字段和元数据
+// StructuredData 结构化数据，支持动态字段和元数据。
+// 实例不是并发安全的，跨 goroutine 传递时使用 Freeze 生成的快照，见 snapshot.go
 type StructuredData struct {
 	Schema    *DataSchema            `json:"schema"`    // 数据模式定义
 	Values    map[string]interface{} `json:"values"`    // 字段值存储
 	Timestamp time.Time              `json:"timestamp"` // 数据时间戳
+
+	SchemaVersion int `json:"schema_version,omitempty"` // 数据所属的模式版本
+
+	frozen bool // Freeze 生成的快照，不允许修改
 }
 
 // NewStructuredData 创建并返回一个新的 StructuredData 实例
@@ -77,12 +124,17 @@ type StructuredData struct {
 //	  - Schema: 传入的数据结构模式
 //	  - Values: 初始化的空 map，用于存储键值对数据
 //	  - Timestamp: 当前时间戳，记录实例创建时间
+//	  - SchemaVersion: 模式的版本号
 func NewStructuredData(schema *DataSchema) *StructuredData {
-	return &StructuredData{
+	sd := &StructuredData{
 		Schema:    schema,
 		Values:    make(map[string]interface{}),
 		Timestamp: time.Now(),
 	}
+	if schema != nil {
+		sd.SchemaVersion = schema.Version
+	}
+	return sd
 }
 
 // SetField 设置结构化数据中指定字段的值（类型安全）
@@ -103,13 +155,16 @@ func NewStructuredData(schema *DataSchema) *StructuredData {
 // 3. 如果字段定义了自定义验证器，执行验证
 // 4. 所有验证通过后，将值存储到结构化数据中
 func (sd *StructuredData) SetField(fieldName string, value interface{}) error {
+	if err := sd.checkMutable(fieldName); err != nil {
+		return err
+	}
 	fieldDef, exists := sd.Schema.Fields[fieldName]
 	if !exists {
 		return NewStructuredDataError(ErrFieldNotFound, fieldName, "field not found in schema")
 	}
 
 	// 类型验证
-	if !isValidFieldType(value, fieldDef.Type) {
+	if !isValidFieldValue(value, fieldDef) {
 		return NewStructuredDataError(ErrInvalidFieldType, fieldName, "invalid field type")
 	}
 
@@ -139,14 +194,8 @@ func (sd *StructuredData) GetField(fieldName string) (interface{}, error) {
 
 	value, exists := sd.Values[fieldName]
 	if !exists {
-		// 返回默认值
-		if fieldDef.DefaultValue != nil {
-			return fieldDef.DefaultValue, nil
-		}
-		if fieldDef.Required {
-			return nil, NewStructuredDataError(ErrRequiredFieldMissing, fieldName, "required field missing")
-		}
-		return nil, nil
+		// 返回计算值或默认值
+		return sd.missingFieldValue(fieldName, fieldDef)
 	}
 
 	return value, nil
@@ -165,13 +214,13 @@ func (sd *StructuredData) ValidateData() error {
 	for fieldName, fieldDef := range sd.Schema.Fields {
 		value, exists := sd.Values[fieldName]
 
-		// 检查必填字段
-		if fieldDef.Required && !exists {
+		// 检查必填字段，计算字段不要求显式赋值
+		if fieldDef.Required && !exists && fieldDef.Compute == nil {
 			return NewStructuredDataError(ErrRequiredFieldMissing, fieldName, "required field missing")
 		}
 
 		// 类型验证
-		if exists && !isValidFieldType(value, fieldDef.Type) {
+		if exists && !isValidFieldValue(value, fieldDef) {
 			return NewStructuredDataError(ErrInvalidFieldType, fieldName, "invalid field type")
 		}
 
@@ -211,18 +260,21 @@ var StockDataSchema = &DataSchema{
 			Description: "当前价格",
 			Comment:     "最新成交价格",
 			Required:    true,
+			Precision:   core.PricePrecision,
 		},
 		"change": {
 			Name:        "change",
 			Type:        FieldTypeFloat64,
 			Description: "涨跌额",
 			Comment:     "相对昨收价的涨跌金额",
+			Precision:   core.PricePrecision,
 		},
 		"change_percent": {
 			Name:        "change_percent",
 			Type:        FieldTypeFloat64,
 			Description: "涨跌幅(%)",
 			Comment:     "涨跌幅百分比",
+			Precision:   core.PercentPrecision,
 		},
 		"market_code": {
 			Name:        "market_code",
@@ -241,31 +293,37 @@ var StockDataSchema = &DataSchema{
 			Name:        "turnover",
 			Type:        FieldTypeFloat64,
 			Description: "成交额(元)",
-			Comment:     "累计成交金额",
+			Comment:     "累计成交金额，未提供时按当前价格乘成交量估算",
+			Compute:     computeTurnover,
+			DependsOn:   []string{"price", "volume"},
 		},
 		"open": {
 			Name:        "open",
 			Type:        FieldTypeFloat64,
 			Description: "开盘价",
 			Comment:     "当日开盘价格",
+			Precision:   core.PricePrecision,
 		},
 		"high": {
 			Name:        "high",
 			Type:        FieldTypeFloat64,
 			Description: "最高价",
 			Comment:     "当日最高成交价",
+			Precision:   core.PricePrecision,
 		},
 		"low": {
 			Name:        "low",
 			Type:        FieldTypeFloat64,
 			Description: "最低价",
 			Comment:     "当日最低成交价",
+			Precision:   core.PricePrecision,
 		},
 		"prev_close": {
 			Name:        "prev_close",
 			Type:        FieldTypeFloat64,
 			Description: "昨收价",
 			Comment:     "前一交易日收盘价",
+			Precision:   core.PricePrecision,
 		},
 		// 5档买卖盘数据
 		"bid_price1": {
@@ -273,6 +331,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "买一价",
 			Comment:     "买盘第一档价格",
+			Precision:   core.PricePrecision,
 		},
 		"bid_volume1": {
 			Name:        "bid_volume1",
@@ -285,6 +344,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "买二价",
 			Comment:     "买盘第二档价格",
+			Precision:   core.PricePrecision,
 		},
 		"bid_volume2": {
 			Name:        "bid_volume2",
@@ -297,6 +357,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "买三价",
 			Comment:     "买盘第三档价格",
+			Precision:   core.PricePrecision,
 		},
 		"bid_volume3": {
 			Name:        "bid_volume3",
@@ -309,6 +370,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "买四价",
 			Comment:     "买盘第四档价格",
+			Precision:   core.PricePrecision,
 		},
 		"bid_volume4": {
 			Name:        "bid_volume4",
@@ -321,6 +383,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "买五价",
 			Comment:     "买盘第五档价格",
+			Precision:   core.PricePrecision,
 		},
 		"bid_volume5": {
 			Name:        "bid_volume5",
@@ -333,6 +396,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "卖一价",
 			Comment:     "卖盘第一档价格",
+			Precision:   core.PricePrecision,
 		},
 		"ask_volume1": {
 			Name:        "ask_volume1",
@@ -345,6 +409,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "卖二价",
 			Comment:     "卖盘第二档价格",
+			Precision:   core.PricePrecision,
 		},
 		"ask_volume2": {
 			Name:        "ask_volume2",
@@ -357,6 +422,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "卖三价",
 			Comment:     "卖盘第三档价格",
+			Precision:   core.PricePrecision,
 		},
 		"ask_volume3": {
 			Name:        "ask_volume3",
@@ -369,6 +435,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "卖四价",
 			Comment:     "卖盘第四档价格",
+			Precision:   core.PricePrecision,
 		},
 		"ask_volume4": {
 			Name:        "ask_volume4",
@@ -381,6 +448,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "卖五价",
 			Comment:     "卖盘第五档价格",
+			Precision:   core.PricePrecision,
 		},
 		"ask_volume5": {
 			Name:        "ask_volume5",
@@ -407,6 +475,7 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "换手率",
 			Comment:     "成交量占流通股本的比例",
+			Precision:   core.PercentPrecision,
 		},
 		"pe": {
 			Name:        "pe",
@@ -424,7 +493,10 @@ var StockDataSchema = &DataSchema{
 			Name:        "amplitude",
 			Type:        FieldTypeFloat64,
 			Description: "振幅",
-			Comment:     "最高价与最低价的差值占昨收价的比例",
+			Comment:     "最高价与最低价的差值占昨收价的比例，未提供时由最高价、最低价和昨收价计算",
+			Compute:     computeAmplitude,
+			DependsOn:   []string{"high", "low", "prev_close"},
+			Precision:   core.PercentPrecision,
 		},
 		"circulation": {
 			Name:        "circulation",
@@ -443,12 +515,35 @@ var StockDataSchema = &DataSchema{
 			Type:        FieldTypeFloat64,
 			Description: "涨停价",
 			Comment:     "当日涨停价格",
+			Precision:   core.PricePrecision,
 		},
 		"limit_down": {
 			Name:        "limit_down",
 			Type:        FieldTypeFloat64,
 			Description: "跌停价",
 			Comment:     "当日跌停价格",
+			Precision:   core.PricePrecision,
+		},
+		// 扩展信息
+		"trading_status": {
+			Name:        "trading_status",
+			Type:        FieldTypeString,
+			Description: "交易状态",
+			Comment:     "trading（正常交易）、suspended（停牌）或 delisted（退市）",
+		},
+		"week52_high": {
+			Name:        "week52_high",
+			Type:        FieldTypeFloat64,
+			Description: "52周最高价",
+			Comment:     "最近52周的最高价格",
+			Precision:   core.PricePrecision,
+		},
+		"week52_low": {
+			Name:        "week52_low",
+			Type:        FieldTypeFloat64,
+			Description: "52周最低价",
+			Comment:     "最近52周的最低价格",
+			Precision:   core.PricePrecision,
 		},
 		// 时间信息
 		"timestamp": {
@@ -468,7 +563,7 @@ var StockDataSchema = &DataSchema{
 		"ask_price4", "ask_volume4", "ask_price5", "ask_volume5",
 		"inner_disc", "outer_disc",
 		"turnover_rate", "pe", "pb", "amplitude", "circulation", "market_value",
-		"limit_up", "limit_down", "timestamp",
+		"limit_up", "limit_down", "trading_status", "week52_high", "week52_low", "timestamp",
 	},
 }
 
@@ -534,9 +629,19 @@ func StockDataToStructuredData(stockData core.StockData) (*StructuredData, error
 		"market_value":   stockData.MarketValue,
 		"limit_up":       stockData.LimitUp,
 		"limit_down":     stockData.LimitDown,
+		"trading_status": stockData.TradingStatus,
+		"week52_high":    stockData.Week52High,
+		"week52_low":     stockData.Week52Low,
 		"timestamp":      stockData.Timestamp,
 	}
 
+	// 行情源未提供（为 0）的派生字段留空，由计算函数求值
+	for _, fieldName := range []string{"turnover", "amplitude"} {
+		if fieldMappings[fieldName] == 0.0 {
+			delete(fieldMappings, fieldName)
+		}
+	}
+
 	// 设置字段值
 	for fieldName, value := range fieldMappings {
 		if err := sd.SetField(fieldName, value); err != nil {
@@ -727,6 +832,9 @@ func StructuredDataToStockData(sd *StructuredData) (*core.StockData, error) {
 	assignFloat64(&stockData.MarketValue, "market_value")
 	assignFloat64(&stockData.LimitUp, "limit_up")
 	assignFloat64(&stockData.LimitDown, "limit_down")
+	assignString(&stockData.TradingStatus, "trading_status")
+	assignFloat64(&stockData.Week52High, "week52_high")
+	assignFloat64(&stockData.Week52Low, "week52_low")
 	assignTime(&stockData.Timestamp, "timestamp")
 
 	return stockData, nil
@@ -750,6 +858,9 @@ func StructuredDataToStockData(sd *StructuredData) (*core.StockData, error) {
 //   - 其他验证错误: 参见: @ValidateFieldValue
 //   - 所有字段设置完成后, 你应该使用: ValidateDataComplete 来验证整个数据的合法性
 func (sd *StructuredData) SetFieldSafe(fieldName string, value interface{}) error {
+	if err := sd.checkMutable(fieldName); err != nil {
+		return err
+	}
 	fieldDef, exists := sd.Schema.Fields[fieldName]
 	if !exists {
 		return NewStructuredDataError(ErrFieldNotFound, fieldName, "field not found in schema")
@@ -785,7 +896,10 @@ func (sd *StructuredData) ValidateDataComplete() error {
 
 	// 验证所有字段值
 	for fieldName, fieldDef := range sd.Schema.Fields {
-		value := sd.Values[fieldName]
+		value, exists := sd.Values[fieldName]
+		if !exists && fieldDef.Compute != nil {
+			continue
+		}
 		if err := ValidateFieldValue(fieldName, value, fieldDef); err != nil {
 			return err
 		}
@@ -832,14 +946,8 @@ func (sd *StructuredData) GetFieldSafe(fieldName string, targetType FieldType) (
 
 	value, exists := sd.Values[fieldName]
 	if !exists {
-		// 返回默认值
-		if fieldDef.DefaultValue != nil {
-			return fieldDef.DefaultValue, nil
-		}
-		if fieldDef.Required {
-			return nil, NewStructuredDataError(ErrRequiredFieldMissing, fieldName, "required field missing")
-		}
-		return nil, nil
+		// 返回计算值或默认值
+		return sd.missingFieldValue(fieldName, fieldDef)
 	}
 
 	// 类型匹配检查
@@ -869,6 +977,8 @@ func (sd *StructuredData) GetFieldSafe(fieldName string, targetType FieldType) (
 //   - 浮点数类型 (FieldTypeFloat64): float32, float64
 //   - 布尔值 (FieldTypeBool)
 //   - 时间类型 (FieldTypeTime): time.Time
+//   - 数组类型 (FieldTypeArray): 任意切片，元素由 isValidFieldValue 递归检查
+//   - 对象类型 (FieldTypeObject): map[string]interface{}，子字段由 isValidFieldValue 递归检查
 func isValidFieldType(value interface{}, expectedType FieldType) bool {
 	if value == nil {
 		return true // nil 值总是有效的（可选字段）
@@ -898,6 +1008,12 @@ func isValidFieldType(value interface{}, expectedType FieldType) bool {
 	case FieldTypeTime:
 		_, ok := value.(time.Time)
 		return ok
+	case FieldTypeArray:
+		_, ok := toInterfaceSlice(value)
+		return ok
+	case FieldTypeObject:
+		_, ok := value.(map[string]interface{})
+		return ok
 	default:
 		return false
 	}
@@ -924,6 +1040,7 @@ func isValidFieldType(value interface{}, expectedType FieldType) bool {
 //     - 顺序中的所有字段都必须在schema.Fields中存在
 //     - schema.Fields中的所有字段都必须在字段顺序中指定
 //  5. 所有字段定义必须通过ValidateFieldDefinition验证
+//  6. 计算字段的依赖必须存在，且计算字段之间不能循环依赖
 func ValidateSchema(schema *DataSchema) error {
 	if schema == nil {
 		return NewStructuredDataError(ErrSchemaNotFound, "", "schema cannot be nil")
@@ -962,7 +1079,8 @@ func ValidateSchema(schema *DataSchema) error {
 		}
 	}
 
-	return nil
+	// 验证计算字段的依赖
+	return validateComputedFields(schema)
 }
 
 // ValidateFieldDefinition 验证字段定义的有效性
@@ -982,6 +1100,7 @@ func ValidateSchema(schema *DataSchema) error {
 //  3. 字段名称必须与输入的fieldName一致
 //  4. 字段类型必须在有效范围内
 //  5. 如果存在默认值，默认值类型必须与字段类型匹配
+//  6. Precision 只能用于浮点字段，且不小于 PrecisionExact
 //
 // 注意事项:
 //   - 必填字段(Required=true)可以没有默认值，这样可以强制用户提供值
@@ -999,13 +1118,23 @@ func ValidateFieldDefinition(fieldName string, fieldDef *FieldDefinition) error
 	}
 
 	// 验证字段类型
-	if fieldDef.Type < FieldTypeString || fieldDef.Type > FieldTypeTime {
+	if fieldDef.Type < FieldTypeString || fieldDef.Type > FieldTypeObject {
 		return NewStructuredDataError(ErrInvalidFieldType, fieldName, "invalid field type")
 	}
 
+	// 验证小数位数
+	if fieldDef.Precision != 0 && (fieldDef.Type != FieldTypeFloat64 || fieldDef.Precision < PrecisionExact) {
+		return NewStructuredDataError(ErrInvalidFieldType, fieldName, "invalid precision")
+	}
+
+	// 验证数组元素和嵌套对象定义
+	if err := validateNestedDefinition(fieldName, fieldDef); err != nil {
+		return err
+	}
+
 	// 验证默认值类型
 	if fieldDef.DefaultValue != nil {
-		if !isValidFieldType(fieldDef.DefaultValue, fieldDef.Type) {
+		if !isValidFieldValue(fieldDef.DefaultValue, fieldDef) {
 			return NewStructuredDataError(ErrInvalidFieldType, fieldName, "default value type mismatch")
 		}
 	}
@@ -1035,7 +1164,8 @@ func ValidateFieldDefinition(fieldName string, fieldDef *FieldDefinition) error
 //  3. 如果值为nil且非必填，则验证通过
 //  4. 验证字段值类型
 //  5. 验证数值范围（如果是数值类型）
-//  6. 执行自定义验证（如果定义了验证器）
+//  6. 递归验证数组元素和嵌套对象的子字段
+//  7. 执行自定义验证（如果定义了验证器）
 //
 // 错误类型:
 //   - ErrFieldNotFound: 字段定义未找到
@@ -1067,6 +1197,11 @@ func ValidateFieldValue(fieldName string, value interface{}, fieldDef *FieldDefi
 		return err
 	}
 
+	// 嵌套验证（数组和对象）
+	if err := validateNestedValue(fieldName, value, fieldDef); err != nil {
+		return err
+	}
+
 	// 自定义验证
 	if fieldDef.Validator != nil {
 		if err := fieldDef.Validator(value); err != nil {
@@ -177,6 +177,9 @@ func TestStockDataRoundTripConversion(t *testing.T) {
 		MarketValue:   1200.8,
 		LimitUp:       91.52,
 		LimitDown:     74.88,
+		TradingStatus: core.StatusTrading,
+		Week52High:    98.50,
+		Week52Low:     61.20,
 		Timestamp:     now,
 	}
 
@@ -237,6 +240,9 @@ func TestStockDataRoundTripConversion(t *testing.T) {
 	assert.Equal(t, original.MarketValue, converted.MarketValue)
 	assert.Equal(t, original.LimitUp, converted.LimitUp)
 	assert.Equal(t, original.LimitDown, converted.LimitDown)
+	assert.Equal(t, original.TradingStatus, converted.TradingStatus)
+	assert.Equal(t, original.Week52High, converted.Week52High)
+	assert.Equal(t, original.Week52Low, converted.Week52Low)
 	assert.Equal(t, original.Timestamp, converted.Timestamp)
 }
 
@@ -251,7 +257,7 @@ func TestStockDataSchemaFieldOrder(t *testing.T) {
 		"ask_price4", "ask_volume4", "ask_price5", "ask_volume5",
 		"inner_disc", "outer_disc",
 		"turnover_rate", "pe", "pb", "amplitude", "circulation", "market_value",
-		"limit_up", "limit_down", "timestamp",
+		"limit_up", "limit_down", "trading_status", "week52_high", "week52_low", "timestamp",
 	}
 
 	assert.Equal(t, expectedOrder, StockDataSchema.FieldOrder)
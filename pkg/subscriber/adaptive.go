@@ -0,0 +1,152 @@
+package subscriber
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// 自适应轮询的默认参数
+const (
+	DefaultAdaptiveWindow        = 20   // 计算波动的最近价格变化次数
+	DefaultAdaptiveEvaluateEvery = 10   // 每成功获取多少次重新评估一次间隔
+	DefaultQuietChangePercent    = 0.01 // 平均单次涨跌幅（%）低于该值时放大间隔
+	DefaultVolatileChangePercent = 0.1  // 平均单次涨跌幅（%）高于该值时缩短间隔
+	adaptiveStep                 = 2    // 每次调整间隔的倍数
+	adaptiveEventRatio           = 2.0  // 间隔相对上次通知变化超过该倍数时发出 EventTypeIntervalChanged 事件
+)
+
+// AdaptiveOptions 自适应轮询选项：按最近价格的逐次变化幅度调整轮询间隔，
+// 波动大的股票缩短间隔，波动小的放大间隔，调整范围为 [MinInterval, MaxInterval]
+type AdaptiveOptions struct {
+	Adaptive    bool          // 是否启用自适应轮询
+	MinInterval time.Duration // 自适应间隔下限
+	MaxInterval time.Duration // 自适应间隔上限
+
+	Window                int     // 滚动窗口大小，0 使用 DefaultAdaptiveWindow
+	EvaluateEvery         int     // 重新评估间隔的获取次数，0 使用 DefaultAdaptiveEvaluateEvery
+	QuietChangePercent    float64 // 0 使用 DefaultQuietChangePercent
+	VolatileChangePercent float64 // 0 使用 DefaultVolatileChangePercent
+}
+
+// validate 校验自适应选项，未启用时不检查
+func (o AdaptiveOptions) validate() error {
+	if !o.Adaptive {
+		return nil
+	}
+	if o.MinInterval <= 0 || o.MaxInterval < o.MinInterval {
+		return fmt.Errorf("invalid adaptive interval bounds [%v, %v]", o.MinInterval, o.MaxInterval)
+	}
+	if o.Window < 0 || o.EvaluateEvery < 0 {
+		return fmt.Errorf("adaptive window and evaluate_every cannot be negative")
+	}
+	if o.QuietChangePercent < 0 || o.VolatileChangePercent < 0 {
+		return fmt.Errorf("adaptive change thresholds cannot be negative")
+	}
+	if o.quietChange() >= o.volatileChange() {
+		return fmt.Errorf("adaptive quiet threshold %.4f%% must be below volatile threshold %.4f%%", o.quietChange(), o.volatileChange())
+	}
+	return nil
+}
+
+func (o AdaptiveOptions) window() int {
+	if o.Window > 0 {
+		return o.Window
+	}
+	return DefaultAdaptiveWindow
+}
+
+func (o AdaptiveOptions) evaluateEvery() int {
+	if o.EvaluateEvery > 0 {
+		return o.EvaluateEvery
+	}
+	return DefaultAdaptiveEvaluateEvery
+}
+
+func (o AdaptiveOptions) quietChange() float64 {
+	if o.QuietChangePercent > 0 {
+		return o.QuietChangePercent
+	}
+	return DefaultQuietChangePercent
+}
+
+func (o AdaptiveOptions) volatileChange() float64 {
+	if o.VolatileChangePercent > 0 {
+		return o.VolatileChangePercent
+	}
+	return DefaultVolatileChangePercent
+}
+
+// adaptiveState 自适应轮询的运行状态
+type adaptiveState struct {
+	lastPrice float64       // 上一次获取的价格
+	changes   []float64     // 滚动窗口内逐次涨跌幅的绝对值（%）
+	samples   int           // 上次评估以来记录的变化次数
+	notified  time.Duration // 上次通知的间隔，0 表示订阅时的间隔
+}
+
+// recordAdaptive 记录一次获取到的价格，达到评估次数时按窗口内的平均变化幅度调整 AdaptiveInterval，
+// 间隔相对上次通知变化超过 2 倍时发出 EventTypeIntervalChanged 事件（需要持有写锁）
+func (s *DefaultSubscriber) recordAdaptive(sub *Subscription, data core.StockData, now time.Time) {
+	if !sub.Adaptive || data.Price <= 0 {
+		return
+	}
+	state := &sub.adaptive
+	prev := state.lastPrice
+	state.lastPrice = data.Price
+	if prev <= 0 {
+		return
+	}
+
+	state.changes = append(state.changes, math.Abs(data.Price-prev)/prev*100)
+	if window := sub.window(); len(state.changes) > window {
+		state.changes = state.changes[len(state.changes)-window:]
+	}
+	state.samples++
+	if state.samples < sub.evaluateEvery() {
+		return
+	}
+	state.samples = 0
+
+	var sum float64
+	for _, change := range state.changes {
+		sum += change
+	}
+	mean := sum / float64(len(state.changes))
+
+	current := sub.pollInterval()
+	next := current
+	switch {
+	case mean > sub.volatileChange():
+		next = current / adaptiveStep
+	case mean < sub.quietChange():
+		next = current * adaptiveStep
+	}
+	next = min(max(next, sub.MinInterval), sub.MaxInterval)
+	if next == current {
+		return
+	}
+
+	// 调整后清空窗口，下次评估只使用新间隔下的价格变化
+	sub.AdaptiveInterval = next
+	state.changes = state.changes[:0]
+	s.log.Debugf("Subscription %s adaptive interval %v -> %v (mean change %.4f%%)", sub.Symbol, current, next, mean)
+
+	notified := state.notified
+	if notified == 0 {
+		notified = sub.Interval
+	}
+	ratio := float64(next) / float64(notified)
+	if ratio > adaptiveEventRatio || ratio < 1/adaptiveEventRatio {
+		state.notified = next
+		s.log.Infof("Subscription %s adaptive interval changed from %v to %v", sub.Symbol, notified, next)
+		s.events.Publish(UpdateEvent{
+			Type:     EventTypeIntervalChanged,
+			Symbol:   sub.Symbol,
+			Time:     now,
+			Interval: next,
+		})
+	}
+}
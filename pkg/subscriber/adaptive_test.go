@@ -0,0 +1,219 @@
+package subscriber
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// scriptedPriceProvider 每次获取时按 step 返回每只股票的下一个价格
+type scriptedPriceProvider struct {
+	fakeStockProvider
+	mu     sync.Mutex
+	prices map[string]float64
+	step   map[string]func(price float64, n int) float64
+	calls  map[string]int
+}
+
+func newScriptedPriceProvider() *scriptedPriceProvider {
+	return &scriptedPriceProvider{
+		prices: make(map[string]float64),
+		step:   make(map[string]func(float64, int) float64),
+		calls:  make(map[string]int),
+	}
+}
+
+// script 设置股票的初始价格和价格变化规则
+func (p *scriptedPriceProvider) script(symbol string, price float64, step func(price float64, n int) float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prices[symbol] = price
+	p.step[symbol] = step
+}
+
+func (p *scriptedPriceProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	data := make([]core.StockData, 0, len(symbols))
+	for _, symbol := range symbols {
+		if step := p.step[symbol]; step != nil && p.calls[symbol] > 0 {
+			p.prices[symbol] = step(p.prices[symbol], p.calls[symbol])
+		}
+		p.calls[symbol]++
+		data = append(data, core.StockData{Symbol: symbol, Price: p.prices[symbol], Timestamp: time.Now()})
+	}
+	return data, nil
+}
+
+// 涨跌 1% 交替，平均单次变化约 1%
+func volatileStep(price float64, n int) float64 {
+	if n%2 == 0 {
+		return price / 1.01
+	}
+	return price * 1.01
+}
+
+func flatStep(price float64, n int) float64 { return price }
+
+func adaptiveOptions(min, max time.Duration) DeliveryOptions {
+	return DeliveryOptions{AdaptiveOptions: AdaptiveOptions{Adaptive: true, MinInterval: min, MaxInterval: max, EvaluateEvery: 5}}
+}
+
+func newAdaptiveTestSubscriber(t *testing.T) (*DefaultSubscriber, *scriptedPriceProvider) {
+	t.Helper()
+	provider := newScriptedPriceProvider()
+	s := NewSubscriber(provider)
+	s.ctx = context.Background()
+	return s, provider
+}
+
+// pollIntervals 对股票连续获取 polls 次，返回每次获取后的实际间隔
+func pollIntervals(t *testing.T, s *DefaultSubscriber, symbol string, polls int) []time.Duration {
+	t.Helper()
+	intervals := make([]time.Duration, 0, polls)
+	for i := 0; i < polls; i++ {
+		s.fetchAndNotify([]string{symbol})
+		intervals = append(intervals, subscription(t, s, symbol).EffectiveInterval())
+	}
+	return intervals
+}
+
+func TestAdaptive_VolatileSymbolShortensToMin(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	provider.script("600000", 10, volatileStep)
+	require.NoError(t, s.SubscribeWithOptions("600000", 16*time.Second, noopCallback, adaptiveOptions(2*time.Second, time.Minute)))
+	drainSubscriberEvents(s)
+
+	// 第一次获取没有上一次的价格，之后每 5 次变化评估一次
+	intervals := pollIntervals(t, s, "600000", 21)
+	assert.Equal(t, 16*time.Second, intervals[4])
+	assert.Equal(t, 8*time.Second, intervals[5])
+	assert.Equal(t, 4*time.Second, intervals[10])
+	assert.Equal(t, 2*time.Second, intervals[15])
+	assert.Equal(t, 2*time.Second, intervals[20], "不低于 MinInterval")
+
+	// 16s -> 8s 未超过 2 倍，16s -> 4s 和 4s -> 2s 中只有前者超过
+	changed := eventsOfType(drainSubscriberEvents(s), EventTypeIntervalChanged)
+	require.Len(t, changed, 1)
+	assert.Equal(t, "600000", changed[0].Symbol)
+	assert.Equal(t, 4*time.Second, changed[0].Interval)
+}
+
+func TestAdaptive_QuietSymbolLengthensToMax(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	provider.script("000001", 12.5, flatStep)
+	require.NoError(t, s.SubscribeWithOptions("000001", 4*time.Second, noopCallback, adaptiveOptions(time.Second, 20*time.Second)))
+	drainSubscriberEvents(s)
+
+	intervals := pollIntervals(t, s, "000001", 26)
+	assert.Equal(t, []time.Duration{8 * time.Second, 16 * time.Second, 20 * time.Second, 20 * time.Second, 20 * time.Second},
+		[]time.Duration{intervals[5], intervals[10], intervals[15], intervals[20], intervals[25]}, "不超过 MaxInterval")
+
+	changed := eventsOfType(drainSubscriberEvents(s), EventTypeIntervalChanged)
+	require.Len(t, changed, 1)
+	assert.Equal(t, 16*time.Second, changed[0].Interval)
+}
+
+func TestAdaptive_ModerateSymbolKeepsInterval(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	provider.script("300750", 100, func(price float64, n int) float64 { return price * 1.0005 })
+	require.NoError(t, s.SubscribeWithOptions("300750", 5*time.Second, noopCallback, adaptiveOptions(time.Second, time.Minute)))
+
+	for _, interval := range pollIntervals(t, s, "300750", 20) {
+		assert.Equal(t, 5*time.Second, interval)
+	}
+}
+
+func TestAdaptive_DisabledKeepsInterval(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	provider.script("600000", 10, volatileStep)
+	require.NoError(t, s.Subscribe("600000", 5*time.Second, noopCallback))
+
+	for _, interval := range pollIntervals(t, s, "600000", 20) {
+		assert.Equal(t, 5*time.Second, interval)
+	}
+	assert.Zero(t, subscription(t, s, "600000").AdaptiveInterval)
+}
+
+func TestAdaptive_ValidatesBounds(t *testing.T) {
+	s, _ := newAdaptiveTestSubscriber(t)
+
+	for name, opts := range map[string]DeliveryOptions{
+		"missing bounds":      {AdaptiveOptions: AdaptiveOptions{Adaptive: true}},
+		"inverted bounds":     adaptiveOptions(time.Minute, time.Second),
+		"interval outside":    adaptiveOptions(10*time.Second, time.Minute),
+		"below subscriber":    adaptiveOptions(100*time.Millisecond, time.Minute),
+		"inverted thresholds": {AdaptiveOptions: AdaptiveOptions{Adaptive: true, MinInterval: time.Second, MaxInterval: time.Minute, QuietChangePercent: 0.5, VolatileChangePercent: 0.1}},
+	} {
+		assert.Error(t, s.SubscribeWithOptions("600000", 5*time.Second, noopCallback, opts), name)
+	}
+}
+
+func TestAdaptive_ResubscribeResetsInterval(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	provider.script("000001", 12.5, flatStep)
+	opts := adaptiveOptions(time.Second, time.Minute)
+	require.NoError(t, s.SubscribeWithOptions("000001", 4*time.Second, noopCallback, opts))
+	pollIntervals(t, s, "000001", 6)
+	require.Equal(t, 8*time.Second, subscription(t, s, "000001").EffectiveInterval())
+
+	require.NoError(t, s.SubscribeWithOptions("000001", 4*time.Second, noopCallback, opts))
+	assert.Equal(t, 4*time.Second, subscription(t, s, "000001").EffectiveInterval())
+}
+
+func TestAdaptive_BackoffOverridesAdaptiveInterval(t *testing.T) {
+	s := NewSubscriber(&flakyStockProvider{failures: 3})
+	s.ctx = context.Background()
+	s.SetBackoffPolicy(3, time.Minute)
+	require.NoError(t, s.SubscribeWithOptions("600000", 4*time.Second, noopCallback, adaptiveOptions(time.Second, 20*time.Second)))
+	s.subscriptions["600000"].AdaptiveInterval = 16 * time.Second
+
+	for i := 0; i < 3; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	assert.Equal(t, 32*time.Second, subscription(t, s, "600000").EffectiveInterval(), "退避从自适应间隔开始放大")
+
+	s.fetchAndNotify([]string{"600000"})
+	assert.Equal(t, 16*time.Second, subscription(t, s, "600000").EffectiveInterval(), "恢复后回到自适应间隔")
+}
+
+func TestAdaptive_CoalescesSymbolsWithSameInterval(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	s.SetBatching(500*time.Millisecond, DefaultMaxBatchSize)
+	provider.script("600000", 10, flatStep)
+	provider.script("000001", 12.5, flatStep)
+	opts := adaptiveOptions(time.Second, time.Minute)
+	require.NoError(t, s.SubscribeWithOptions("600000", 2*time.Second, noopCallback, opts))
+	require.NoError(t, s.SubscribeWithOptions("000001", 2*time.Second, noopCallback, opts))
+
+	start := time.Now()
+	require.ElementsMatch(t, []string{"600000", "000001"}, s.dueSymbols(start))
+	for i := 0; i < 6; i++ {
+		s.fetchAndNotify([]string{"600000", "000001"})
+	}
+	require.Equal(t, 4*time.Second, subscription(t, s, "600000").EffectiveInterval())
+	require.Equal(t, 4*time.Second, subscription(t, s, "000001").EffectiveInterval())
+
+	assert.Empty(t, s.dueSymbols(start.Add(2*time.Second)), "按调整后的间隔轮询")
+	assert.ElementsMatch(t, []string{"600000", "000001"}, s.dueSymbols(start.Add(3600*time.Millisecond)), "间隔相同的股票仍合并到一次请求")
+}
+
+func TestManager_StatisticsIncludeEffectiveInterval(t *testing.T) {
+	s, provider := newAdaptiveTestSubscriber(t)
+	provider.script("000001", 12.5, flatStep)
+	manager := NewManager(s)
+	require.NoError(t, manager.SubscribeWithOptions("000001", 4*time.Second, noopCallback, adaptiveOptions(time.Second, time.Minute)))
+	require.NoError(t, manager.Subscribe("600000", 3*time.Second, noopCallback))
+
+	pollIntervals(t, s, "000001", 6)
+
+	stats := manager.GetStatistics()
+	assert.Equal(t, 8*time.Second, stats.SubscriptionStats["000001"].EffectiveInterval)
+	assert.Equal(t, 3*time.Second, stats.SubscriptionStats["600000"].EffectiveInterval)
+}
@@ -0,0 +1,172 @@
+package subscriber
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// flakyStockProvider 前 failures 次调用失败，之后恢复正常
+type flakyStockProvider struct {
+	fakeStockProvider
+	mu       sync.Mutex
+	failures int
+	calls    int
+}
+
+func (p *flakyStockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	p.mu.Lock()
+	p.calls++
+	fail := p.calls <= p.failures
+	p.mu.Unlock()
+
+	if fail {
+		return nil, errors.New("provider unavailable")
+	}
+	return p.fakeStockProvider.FetchStockData(ctx, symbols)
+}
+
+func newBackoffTestSubscriber(t *testing.T, failures int) *DefaultSubscriber {
+	t.Helper()
+	s := NewSubscriber(&flakyStockProvider{failures: failures})
+	s.ctx = context.Background()
+	s.SetBackoffPolicy(3, 20*time.Second)
+	require.NoError(t, s.Subscribe("600000", 2*time.Second, noopCallback))
+	drainSubscriberEvents(s)
+	return s
+}
+
+// drainSubscriberEvents 读取事件通道中当前已缓冲的全部事件
+func drainSubscriberEvents(s *DefaultSubscriber) []UpdateEvent {
+	var events []UpdateEvent
+	for {
+		select {
+		case ev := <-s.defaultEvents:
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func eventsOfType(events []UpdateEvent, eventType EventType) []UpdateEvent {
+	var result []UpdateEvent
+	for _, ev := range events {
+		if ev.Type == eventType {
+			result = append(result, ev)
+		}
+	}
+	return result
+}
+
+func subscription(t *testing.T, s *DefaultSubscriber, symbol string) Subscription {
+	t.Helper()
+	for _, sub := range s.GetSubscriptions() {
+		if sub.Symbol == symbol {
+			return sub
+		}
+	}
+	t.Fatalf("subscription %s not found", symbol)
+	return Subscription{}
+}
+
+func TestSubscriber_BackoffIntervalProgression(t *testing.T) {
+	s := newBackoffTestSubscriber(t, 6)
+
+	var intervals []time.Duration
+	for i := 0; i < 6; i++ {
+		s.fetchAndNotify([]string{"600000"})
+		intervals = append(intervals, subscription(t, s, "600000").EffectiveInterval())
+	}
+
+	// 前两次失败保持原间隔，第三次起按倍数放大，最大 20s
+	assert.Equal(t, []time.Duration{
+		2 * time.Second, 2 * time.Second,
+		4 * time.Second, 8 * time.Second, 16 * time.Second, 20 * time.Second,
+	}, intervals)
+
+	sub := subscription(t, s, "600000")
+	assert.True(t, sub.InBackoff())
+	assert.Equal(t, 6, sub.ConsecutiveErrors)
+
+	events := drainSubscriberEvents(s)
+	assert.Len(t, eventsOfType(events, EventTypeError), 6)
+	degraded := eventsOfType(events, EventTypeDegraded)
+	require.Len(t, degraded, 4)
+	assert.Equal(t, 4*time.Second, degraded[0].Interval)
+	assert.Equal(t, 20*time.Second, degraded[3].Interval)
+	assert.EqualError(t, degraded[0].Error, "provider unavailable")
+
+	// 达到上限后继续失败不再重复发出退避事件
+	s.fetchAndNotify([]string{"600000"})
+	events = drainSubscriberEvents(s)
+	assert.Empty(t, eventsOfType(events, EventTypeDegraded))
+}
+
+func TestSubscriber_BackoffRecoversOnFirstSuccess(t *testing.T) {
+	s := newBackoffTestSubscriber(t, 4)
+
+	for i := 0; i < 4; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	require.Equal(t, 8*time.Second, subscription(t, s, "600000").EffectiveInterval())
+
+	s.fetchAndNotify([]string{"600000"})
+
+	sub := subscription(t, s, "600000")
+	assert.False(t, sub.InBackoff())
+	assert.Zero(t, sub.ConsecutiveErrors)
+	assert.Equal(t, 2*time.Second, sub.EffectiveInterval())
+}
+
+func TestSubscriber_DueSymbolsHonorsBackoffInterval(t *testing.T) {
+	s := newBackoffTestSubscriber(t, 3)
+	start := time.Now()
+
+	require.Equal(t, []string{"600000"}, s.dueSymbols(start))
+	for i := 0; i < 3; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	require.Equal(t, 4*time.Second, subscription(t, s, "600000").EffectiveInterval())
+
+	assert.Empty(t, s.dueSymbols(start.Add(2*time.Second)), "退避中不按原间隔轮询")
+	assert.Equal(t, []string{"600000"}, s.dueSymbols(start.Add(4*time.Second)))
+}
+
+func TestManager_ResubscribeAllResetsBackoff(t *testing.T) {
+	s := NewSubscriber(&flakyStockProvider{failures: 10})
+	s.ctx = context.Background()
+	s.SetBackoffPolicy(2, time.Minute)
+	manager := NewManager(s)
+	require.NoError(t, manager.Subscribe("600000", 2*time.Second, noopCallback))
+	require.NoError(t, manager.Subscribe("000001", 2*time.Second, noopCallback))
+
+	for i := 0; i < 3; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	for _, ev := range drainSubscriberEvents(s) {
+		manager.processEvent(ev)
+	}
+
+	stats := manager.GetStatistics()
+	assert.True(t, stats.SubscriptionStats["600000"].InBackoff)
+	assert.Equal(t, 3, stats.SubscriptionStats["600000"].ConsecutiveErrors)
+	assert.Equal(t, 8*time.Second, stats.SubscriptionStats["600000"].BackoffInterval)
+	assert.False(t, stats.SubscriptionStats["000001"].InBackoff)
+
+	now := time.Now()
+	s.dueSymbols(now)
+	manager.ResubscribeAll()
+
+	stats = manager.GetStatistics()
+	assert.False(t, stats.SubscriptionStats["600000"].InBackoff)
+	assert.Zero(t, stats.SubscriptionStats["600000"].ConsecutiveErrors)
+	assert.ElementsMatch(t, []string{"600000", "000001"}, s.dueSymbols(now), "重置后立即重新获取")
+}
@@ -0,0 +1,243 @@
+package subscriber
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+)
+
+// BatchResult 批量操作中每只股票的结果
+type BatchResult struct {
+	Succeeded []string
+	Failed    map[string]error
+}
+
+func newBatchResult() *BatchResult {
+	return &BatchResult{Failed: make(map[string]error)}
+}
+
+// Err 汇总失败的股票，全部成功时返回 nil
+func (r *BatchResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	symbols := make([]string, 0, len(r.Failed))
+	for symbol := range r.Failed {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	errs := make([]error, 0, len(symbols))
+	for _, symbol := range symbols {
+		errs = append(errs, fmt.Errorf("%s: %w", symbol, r.Failed[symbol]))
+	}
+	return errors.Join(errs...)
+}
+
+// ReplaceResult ReplaceSubscriptions 应用的变更
+type ReplaceResult struct {
+	Added     []string // 新增的订阅
+	Removed   []string // 取消的订阅
+	Updated   []string // 间隔或推送选项变化的订阅
+	Unchanged []string // 无变化的订阅，保留原回调和运行状态
+}
+
+// appliedChange 批量操作中已生效的一项变更，用于回滚
+type appliedChange struct {
+	symbol  string
+	prev    *Subscription // 变更前的订阅，nil 表示本批次新增
+	stats   *SubStats     // 变更前的统计信息
+	removed bool          // 本批次取消的订阅
+}
+
+// SubscribeBatchAtomic 原子地批量订阅：先验证全部请求（代码、间隔、推送选项、批次内重复、
+// 计入新增后的订阅数上限），全部通过后再逐个激活。激活失败时回滚本批次已激活的订阅，
+// 新增的订阅被取消，已有订阅恢复原来的间隔、回调和推送选项
+func (m *Manager) SubscribeBatchAtomic(requests []SubscribeRequest) error {
+	symbols, err := m.validateBatch(requests)
+	if err != nil {
+		return err
+	}
+	if err := m.subscriber.checkCapacity(symbols, nil); err != nil {
+		return fmt.Errorf("batch subscription rejected: %w", err)
+	}
+
+	var applied []appliedChange
+	for _, req := range requests {
+		change, err := m.applySubscribe(req)
+		if err != nil {
+			m.rollback(applied)
+			return fmt.Errorf("failed to subscribe %s, batch rolled back: %w", req.Symbol, err)
+		}
+		applied = append(applied, change)
+	}
+
+	m.commitRecords(requests, nil)
+	return nil
+}
+
+// ReplaceSubscriptions 把通过 Subscribe 系列方法添加的订阅替换为 requests：不在 requests 中的订阅被取消，
+// 新股票被订阅，间隔或推送选项变化的订阅被更新，其他订阅保持不变（回调不比较，保留原回调）。
+// 验证和回滚与 SubscribeBatchAtomic 相同，任何一步失败时订阅恢复到调用前的状态。股票池成员不受影响
+func (m *Manager) ReplaceSubscriptions(requests []SubscribeRequest) (*ReplaceResult, error) {
+	symbols, err := m.validateBatch(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	m.persistMu.Lock()
+	current := make(map[string]SubscriptionRecord, len(m.records))
+	for symbol, record := range m.records {
+		current[symbol] = record
+	}
+	m.persistMu.Unlock()
+
+	result := &ReplaceResult{}
+	desired := make(map[string]bool, len(requests))
+	var changes []SubscribeRequest
+	for _, req := range requests {
+		desired[req.Symbol] = true
+		record, exists := current[req.Symbol]
+		switch {
+		case !exists:
+			result.Added = append(result.Added, req.Symbol)
+			changes = append(changes, req)
+		case record.Interval != req.Interval || !reflect.DeepEqual(record.DeliveryOptions, req.DeliveryOptions):
+			result.Updated = append(result.Updated, req.Symbol)
+			changes = append(changes, req)
+		default:
+			result.Unchanged = append(result.Unchanged, req.Symbol)
+		}
+	}
+	for symbol := range current {
+		if !desired[symbol] {
+			result.Removed = append(result.Removed, symbol)
+		}
+	}
+	sort.Strings(result.Removed)
+
+	if err := m.subscriber.checkCapacity(symbols, result.Removed); err != nil {
+		return nil, fmt.Errorf("replace subscriptions rejected: %w", err)
+	}
+
+	// 先取消再订阅，取消释放的名额可以给新增的订阅使用
+	var applied []appliedChange
+	for _, symbol := range result.Removed {
+		change, err := m.applyUnsubscribe(symbol)
+		if err != nil {
+			m.rollback(applied)
+			return nil, fmt.Errorf("failed to unsubscribe %s, replace rolled back: %w", symbol, err)
+		}
+		applied = append(applied, change)
+	}
+	for _, req := range changes {
+		change, err := m.applySubscribe(req)
+		if err != nil {
+			m.rollback(applied)
+			return nil, fmt.Errorf("failed to subscribe %s, replace rolled back: %w", req.Symbol, err)
+		}
+		applied = append(applied, change)
+	}
+
+	m.commitRecords(changes, result.Removed)
+	log.Printf("[Manager] Replaced subscriptions: %d added, %d removed, %d updated, %d unchanged",
+		len(result.Added), len(result.Removed), len(result.Updated), len(result.Unchanged))
+	return result, nil
+}
+
+// validateBatch 验证批次中的全部请求，返回请求的股票代码；所有问题汇总为一个错误
+func (m *Manager) validateBatch(requests []SubscribeRequest) ([]string, error) {
+	symbols := make([]string, 0, len(requests))
+	seen := make(map[string]bool, len(requests))
+	var errs []error
+	for _, req := range requests {
+		if seen[req.Symbol] {
+			errs = append(errs, fmt.Errorf("duplicate symbol %s in batch", req.Symbol))
+			continue
+		}
+		seen[req.Symbol] = true
+		symbols = append(symbols, req.Symbol)
+		if err := m.subscriber.validateSubscription(req.Symbol, req.Interval, req.Callback, req.DeliveryOptions); err != nil {
+			errs = append(errs, fmt.Errorf("invalid subscription %s: %w", req.Symbol, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("batch validation failed: %w", errors.Join(errs...))
+	}
+	return symbols, nil
+}
+
+// applySubscribe 激活一个订阅并记下变更前的状态
+func (m *Manager) applySubscribe(req SubscribeRequest) (appliedChange, error) {
+	change := m.captureChange(req.Symbol)
+	if err := m.subscribe(req.Symbol, req.Interval, req.Callback, req.DeliveryOptions); err != nil {
+		return appliedChange{}, err
+	}
+	return change, nil
+}
+
+// applyUnsubscribe 取消一个订阅并记下变更前的状态
+func (m *Manager) applyUnsubscribe(symbol string) (appliedChange, error) {
+	change := m.captureChange(symbol)
+	change.removed = true
+	if err := m.unsubscribe(symbol); err != nil {
+		return appliedChange{}, err
+	}
+	return change, nil
+}
+
+func (m *Manager) captureChange(symbol string) appliedChange {
+	change := appliedChange{symbol: symbol}
+	if prev, exists := m.subscriber.lookup(symbol); exists {
+		change.prev = &prev
+	}
+	m.statsMu.RLock()
+	change.stats = m.stats.SubscriptionStats[symbol]
+	m.statsMu.RUnlock()
+	return change
+}
+
+// rollback 按相反顺序撤销已生效的变更
+func (m *Manager) rollback(applied []appliedChange) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		change := applied[i]
+		if change.prev == nil {
+			if err := m.unsubscribe(change.symbol); err != nil {
+				log.Printf("[Manager] Failed to roll back subscription %s: %v", change.symbol, err)
+			}
+			continue
+		}
+
+		prev := change.prev
+		if err := m.subscribe(prev.Symbol, prev.Interval, prev.Callback, prev.DeliveryOptions); err != nil {
+			log.Printf("[Manager] Failed to restore subscription %s: %v", change.symbol, err)
+			continue
+		}
+		if change.stats != nil {
+			m.statsMu.Lock()
+			m.stats.SubscriptionStats[change.symbol] = change.stats
+			m.statsMu.Unlock()
+		}
+	}
+}
+
+// commitRecords 记录批次生效后的订阅并保存一次状态，移除的股票仍属于股票池时改由股票池订阅
+func (m *Manager) commitRecords(requests []SubscribeRequest, removed []string) {
+	if len(requests) == 0 && len(removed) == 0 {
+		return
+	}
+	m.persistMu.Lock()
+	for _, req := range requests {
+		m.records[req.Symbol] = SubscriptionRecord{Symbol: req.Symbol, Interval: req.Interval, DeliveryOptions: req.DeliveryOptions}
+	}
+	for _, symbol := range removed {
+		delete(m.records, symbol)
+	}
+	m.persistMu.Unlock()
+	m.saveStateLogged()
+	for _, symbol := range removed {
+		m.resyncUniverseMember(symbol)
+	}
+}
@@ -0,0 +1,164 @@
+package subscriber
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rejectOnActivateProvider 验证时支持所有代码，reject 中的代码第二次检查（激活时）起不再支持，
+// 模拟验证之后状态发生变化导致激活失败
+type rejectOnActivateProvider struct {
+	fakeStockProvider
+	mu     sync.Mutex
+	reject map[string]bool
+	checks map[string]int
+}
+
+func (p *rejectOnActivateProvider) IsSymbolSupported(symbol string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checks[symbol]++
+	return !(p.reject[symbol] && p.checks[symbol] > 1)
+}
+
+func batchRequests(interval time.Duration, symbols ...string) []SubscribeRequest {
+	requests := make([]SubscribeRequest, len(symbols))
+	for i, symbol := range symbols {
+		requests[i] = SubscribeRequest{Symbol: symbol, Interval: interval, Callback: noopCallback}
+	}
+	return requests
+}
+
+func TestManager_SubscribeBatchAtomic_RejectsWhenExceedingMax(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	s.SetMaxSubscriptions(3)
+	manager := NewManager(s)
+	require.NoError(t, manager.Subscribe("600000", 5*time.Second, noopCallback))
+
+	// 第 3 个新请求使订阅数达到 4，超过上限，整个批次不生效
+	err := manager.SubscribeBatchAtomic(batchRequests(5*time.Second, "000001", "000002", "000003"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maximum subscriptions (3) exceeded")
+	assert.Equal(t, map[string]time.Duration{"600000": 5 * time.Second}, subscribedIntervals(manager))
+	assert.Equal(t, 1, manager.GetStatistics().ActiveSubscriptions)
+
+	// 已订阅的股票不占用新名额
+	require.NoError(t, manager.SubscribeBatchAtomic(batchRequests(5*time.Second, "600000", "000001", "000002")))
+	assert.Len(t, subscribedIntervals(manager), 3)
+	assert.Equal(t, 3, manager.GetStatistics().ActiveSubscriptions)
+}
+
+func TestManager_SubscribeBatchAtomic_ValidatesAllRequestsFirst(t *testing.T) {
+	manager := NewManager(NewSubscriber(&fakeStockProvider{}))
+	requests := append(batchRequests(5*time.Second, "600000", "000001"),
+		SubscribeRequest{Symbol: "000002", Interval: time.Millisecond, Callback: noopCallback},
+		SubscribeRequest{Symbol: "000003", Interval: 5 * time.Second},
+		SubscribeRequest{Symbol: "600000", Interval: 5 * time.Second, Callback: noopCallback},
+	)
+
+	err := manager.SubscribeBatchAtomic(requests)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "000002: interval too short")
+	assert.Contains(t, err.Error(), "000003: callback cannot be nil")
+	assert.Contains(t, err.Error(), "duplicate symbol 600000")
+	assert.Empty(t, subscribedIntervals(manager), "验证失败时不激活任何订阅")
+}
+
+func TestManager_SubscribeBatchAtomic_RollsBackOnActivationFailure(t *testing.T) {
+	provider := &rejectOnActivateProvider{reject: map[string]bool{"000002": true}, checks: map[string]int{}}
+	manager := NewManager(NewSubscriber(provider))
+	require.NoError(t, manager.Subscribe("600000", 5*time.Second, noopCallback))
+	subscribedAt := manager.GetStatistics().SubscriptionStats["600000"].SubscribedAt
+
+	requests := []SubscribeRequest{
+		{Symbol: "600000", Interval: 10 * time.Second, Callback: noopCallback, DeliveryOptions: DeliveryOptions{DeliverMode: DeliverOnChange}},
+		{Symbol: "000001", Interval: 5 * time.Second, Callback: noopCallback},
+		{Symbol: "000002", Interval: 5 * time.Second, Callback: noopCallback},
+	}
+	err := manager.SubscribeBatchAtomic(requests)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to subscribe 000002, batch rolled back")
+
+	assert.Equal(t, map[string]time.Duration{"600000": 5 * time.Second}, subscribedIntervals(manager), "新增的订阅被取消，已有订阅恢复原间隔")
+	assert.Equal(t, DeliverAll, subscription(t, manager.subscriber, "600000").DeliverMode)
+	stats := manager.GetStatistics()
+	assert.Equal(t, 1, stats.ActiveSubscriptions)
+	assert.Equal(t, subscribedAt, stats.SubscriptionStats["600000"].SubscribedAt, "统计信息恢复")
+	assert.Len(t, manager.records, 1)
+	assert.Equal(t, 5*time.Second, manager.records["600000"].Interval)
+}
+
+func TestManager_UnsubscribeBatch_ReportsPerSymbol(t *testing.T) {
+	manager := NewManager(NewSubscriber(&fakeStockProvider{}))
+	require.NoError(t, manager.SubscribeBatchAtomic(batchRequests(5*time.Second, "600000", "000001")))
+
+	result := manager.UnsubscribeBatch([]string{"600000", "999999", "000001"})
+	assert.Equal(t, []string{"600000", "000001"}, result.Succeeded)
+	require.Len(t, result.Failed, 1)
+	assert.Error(t, result.Failed["999999"])
+	assert.ErrorContains(t, result.Err(), "999999")
+	assert.Empty(t, subscribedIntervals(manager))
+	assert.NoError(t, newBatchResult().Err())
+}
+
+func TestManager_ReplaceSubscriptions_AppliesDiff(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	s.SetMaxSubscriptions(3)
+	manager := NewManager(s)
+	require.NoError(t, manager.SubscribeBatchAtomic(batchRequests(5*time.Second, "600000", "000001", "000002")))
+
+	// 订阅数已满，先取消再订阅使新增的股票可以使用释放的名额
+	desired := append(batchRequests(5*time.Second, "600000", "600036"),
+		SubscribeRequest{Symbol: "000001", Interval: 10 * time.Second, Callback: noopCallback})
+	result, err := manager.ReplaceSubscriptions(desired)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"600036"}, result.Added)
+	assert.Equal(t, []string{"000002"}, result.Removed)
+	assert.Equal(t, []string{"000001"}, result.Updated)
+	assert.Equal(t, []string{"600000"}, result.Unchanged)
+
+	assert.Equal(t, map[string]time.Duration{
+		"600000": 5 * time.Second,
+		"600036": 5 * time.Second,
+		"000001": 10 * time.Second,
+	}, subscribedIntervals(manager))
+	assert.Equal(t, 3, manager.GetStatistics().ActiveSubscriptions)
+	assert.Len(t, manager.records, 3)
+	assert.Equal(t, 10*time.Second, manager.records["000001"].Interval)
+
+	// 再次应用相同配置没有变更
+	result, err = manager.ReplaceSubscriptions(desired)
+	require.NoError(t, err)
+	assert.Empty(t, result.Added)
+	assert.Empty(t, result.Removed)
+	assert.Empty(t, result.Updated)
+	assert.Len(t, result.Unchanged, 3)
+}
+
+func TestManager_ReplaceSubscriptions_RollsBack(t *testing.T) {
+	provider := &rejectOnActivateProvider{reject: map[string]bool{"600036": true}, checks: map[string]int{}}
+	manager := NewManager(NewSubscriber(provider))
+	require.NoError(t, manager.SubscribeBatchAtomic(batchRequests(5*time.Second, "600000", "000001")))
+
+	_, err := manager.ReplaceSubscriptions(append(batchRequests(5*time.Second, "600036"),
+		SubscribeRequest{Symbol: "600000", Interval: 10 * time.Second, Callback: noopCallback}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "replace rolled back")
+
+	assert.Equal(t, map[string]time.Duration{
+		"600000": 5 * time.Second,
+		"000001": 5 * time.Second,
+	}, subscribedIntervals(manager), "取消的订阅恢复，更新的订阅恢复原间隔")
+	assert.Equal(t, 2, manager.GetStatistics().ActiveSubscriptions)
+	assert.Len(t, manager.records, 2)
+
+	// 超过上限的替换在生效前被拒绝
+	manager.subscriber.SetMaxSubscriptions(2)
+	_, err = manager.ReplaceSubscriptions(batchRequests(5*time.Second, "600000", "000001", "000002"))
+	assert.ErrorContains(t, err, "maximum subscriptions (2) exceeded")
+	assert.Len(t, subscribedIntervals(manager), 2)
+}
@@ -0,0 +1,123 @@
+package subscriber
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchingTestSubscriber(t *testing.T, window time.Duration) *DefaultSubscriber {
+	t.Helper()
+	s := NewSubscriber(&fakeStockProvider{})
+	s.ctx = context.Background()
+	s.SetBatching(window, DefaultMaxBatchSize)
+	return s
+}
+
+func TestSubscriber_DueSymbolsCoalescesWithinWindow(t *testing.T) {
+	start := time.Now()
+
+	for _, tc := range []struct {
+		name   string
+		window time.Duration
+		want   []string
+	}{
+		{name: "without window", window: 0, want: []string{"600000"}},
+		{name: "with window", window: 500 * time.Millisecond, want: []string{"600000", "000001"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newBatchingTestSubscriber(t, tc.window)
+			require.NoError(t, s.Subscribe("600000", 5*time.Second, noopCallback))
+			require.Equal(t, []string{"600000"}, s.dueSymbols(start))
+
+			require.NoError(t, s.Subscribe("000001", 5*time.Second, noopCallback))
+			require.Equal(t, []string{"000001"}, s.dueSymbols(start.Add(300*time.Millisecond)))
+
+			assert.ElementsMatch(t, tc.want, s.dueSymbols(start.Add(5*time.Second)))
+		})
+	}
+}
+
+func TestSubscriber_CoalescingHonorsInterval(t *testing.T) {
+	s := newBatchingTestSubscriber(t, 500*time.Millisecond)
+	require.NoError(t, s.Subscribe("600000", 2*time.Second, noopCallback))
+	require.NoError(t, s.Subscribe("000001", 4*time.Second, noopCallback))
+
+	// 模拟 60 秒内每秒一次、带有毫秒级抖动的 ticker
+	start := time.Now()
+	fetches := map[string]int{}
+	calls := 0
+	for i := 0; i < 60; i++ {
+		jitter := time.Duration(i%3) * 5 * time.Millisecond
+		due := s.dueSymbols(start.Add(time.Duration(i)*time.Second - jitter))
+		if len(due) > 0 {
+			calls++
+		}
+		for _, symbol := range due {
+			fetches[symbol]++
+		}
+	}
+
+	assert.Equal(t, 30, fetches["600000"], "2s 订阅不应因抖动错过轮询")
+	assert.Equal(t, 15, fetches["000001"], "4s 订阅不应因合并而更频繁获取")
+	assert.Equal(t, 30, calls, "4s 订阅的获取全部并入 2s 订阅的请求")
+}
+
+func TestSplitBatches(t *testing.T) {
+	symbols := []string{"a", "b", "c", "d", "e"}
+
+	assert.Equal(t, [][]string{symbols}, splitBatches(symbols, 60))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, splitBatches(symbols, 2))
+
+	// 拆分后的批次互不影响
+	batches := splitBatches(symbols, 2)
+	batches[0] = append(batches[0], "x")
+	assert.Equal(t, []string{"c", "d"}, batches[1])
+}
+
+func TestSubscriber_BatchErrorFansOut(t *testing.T) {
+	s := NewSubscriber(&flakyStockProvider{failures: 1})
+	s.ctx = context.Background()
+	symbols := []string{"600000", "000001", "300750"}
+	for _, symbol := range symbols {
+		require.NoError(t, s.Subscribe(symbol, 5*time.Second, noopCallback))
+	}
+	drainSubscriberEvents(s)
+
+	s.fetchAndNotify(symbols)
+
+	errs := eventsOfType(drainSubscriberEvents(s), EventTypeError)
+	require.Len(t, errs, len(symbols))
+	got := make([]string, 0, len(errs))
+	for _, ev := range errs {
+		assert.EqualError(t, ev.Error, "provider unavailable")
+		got = append(got, ev.Symbol)
+	}
+	assert.ElementsMatch(t, symbols, got)
+
+	for _, symbol := range symbols {
+		assert.Equal(t, 1, subscription(t, s, symbol).ConsecutiveErrors)
+	}
+}
+
+func TestManager_StatisticsIncludeBatchStats(t *testing.T) {
+	s := newBatchingTestSubscriber(t, 500*time.Millisecond)
+	manager := NewManager(s)
+	symbols := []string{"600000", "000001", "300750", "600519"}
+	for _, symbol := range symbols {
+		require.NoError(t, manager.Subscribe(symbol, 5*time.Second, noopCallback))
+	}
+
+	s.fetchAndNotify(symbols)
+	s.fetchAndNotify(symbols[:2])
+
+	stats := manager.GetStatistics()
+	require.NotNil(t, stats.BatchStats)
+	assert.Equal(t, int64(2), stats.BatchStats.ProviderCalls)
+	assert.Equal(t, int64(6), stats.BatchStats.SymbolsFetched)
+	assert.Equal(t, int64(4), stats.BatchStats.CallsSaved)
+	assert.InDelta(t, 3.0, stats.BatchStats.AverageBatchSize, 1e-9)
+}
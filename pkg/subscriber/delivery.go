@@ -0,0 +1,107 @@
+package subscriber
+
+import (
+	"fmt"
+	"time"
+
+	"stocksub/pkg/core"
+)
+
+// DeliverMode 数据推送模式
+type DeliverMode int
+
+const (
+	DeliverAll      DeliverMode = iota // 每次获取都推送
+	DeliverOnChange                    // 仅在比较字段变化时推送
+)
+
+// CompareField OnChange 模式下参与比较的字段
+type CompareField string
+
+const (
+	CompareFieldPrice    CompareField = "price"    // 当前价格
+	CompareFieldVolume   CompareField = "volume"   // 成交量
+	CompareFieldTurnover CompareField = "turnover" // 成交额
+	CompareFieldBidAsk   CompareField = "bid_ask"  // 5 档买卖盘价格和数量
+)
+
+// DefaultCompareFields OnChange 模式默认比较的字段
+var DefaultCompareFields = []CompareField{CompareFieldPrice, CompareFieldVolume, CompareFieldBidAsk}
+
+// DeliveryOptions 订阅的推送选项
+type DeliveryOptions struct {
+	DeliverMode   DeliverMode    // 推送模式，默认 DeliverAll
+	CompareFields []CompareField // OnChange 模式比较的字段，为空时使用 DefaultCompareFields
+	Heartbeat     time.Duration  // OnChange 模式下数据未变化时至少每隔该时长推送一次，0 表示不强制推送
+
+	AdaptiveOptions // 自适应轮询选项，零值为按订阅间隔固定轮询
+}
+
+// validate 校验推送选项
+func (o DeliveryOptions) validate() error {
+	switch o.DeliverMode {
+	case DeliverAll, DeliverOnChange:
+	default:
+		return fmt.Errorf("unknown deliver mode %d", o.DeliverMode)
+	}
+	if o.Heartbeat < 0 {
+		return fmt.Errorf("heartbeat cannot be negative")
+	}
+	for _, field := range o.CompareFields {
+		if _, ok := fieldComparators[field]; !ok {
+			return fmt.Errorf("unknown compare field %q", field)
+		}
+	}
+	return o.AdaptiveOptions.validate()
+}
+
+// fieldComparators 各比较字段的相等判断
+var fieldComparators = map[CompareField]func(a, b *core.StockData) bool{
+	CompareFieldPrice: func(a, b *core.StockData) bool {
+		return a.Price == b.Price
+	},
+	CompareFieldVolume: func(a, b *core.StockData) bool {
+		return a.Volume == b.Volume
+	},
+	CompareFieldTurnover: func(a, b *core.StockData) bool {
+		return a.Turnover == b.Turnover
+	},
+	CompareFieldBidAsk: func(a, b *core.StockData) bool {
+		return a.BidPrice1 == b.BidPrice1 && a.BidVolume1 == b.BidVolume1 &&
+			a.BidPrice2 == b.BidPrice2 && a.BidVolume2 == b.BidVolume2 &&
+			a.BidPrice3 == b.BidPrice3 && a.BidVolume3 == b.BidVolume3 &&
+			a.BidPrice4 == b.BidPrice4 && a.BidVolume4 == b.BidVolume4 &&
+			a.BidPrice5 == b.BidPrice5 && a.BidVolume5 == b.BidVolume5 &&
+			a.AskPrice1 == b.AskPrice1 && a.AskVolume1 == b.AskVolume1 &&
+			a.AskPrice2 == b.AskPrice2 && a.AskVolume2 == b.AskVolume2 &&
+			a.AskPrice3 == b.AskPrice3 && a.AskVolume3 == b.AskVolume3 &&
+			a.AskPrice4 == b.AskPrice4 && a.AskVolume4 == b.AskVolume4 &&
+			a.AskPrice5 == b.AskPrice5 && a.AskVolume5 == b.AskVolume5
+	},
+}
+
+// shouldDeliver 判断本次数据是否需要推送给回调，需要推送时记录为最近一次推送（需要持有写锁）
+func (sub *Subscription) shouldDeliver(data core.StockData, now time.Time) bool {
+	deliver := sub.DeliverMode != DeliverOnChange ||
+		sub.lastDelivered == nil ||
+		(sub.Heartbeat > 0 && now.Sub(sub.lastDeliveredAt) >= sub.Heartbeat) ||
+		dataChanged(sub.lastDelivered, &data, sub.CompareFields)
+	if deliver {
+		sub.lastDelivered = &data
+		sub.lastDeliveredAt = now
+	}
+	return deliver
+}
+
+// dataChanged 比较两次数据在指定字段上是否有变化
+func dataChanged(prev, next *core.StockData, fields []CompareField) bool {
+	if len(fields) == 0 {
+		fields = DefaultCompareFields
+	}
+	for _, field := range fields {
+		if equal, ok := fieldComparators[field]; ok && !equal(prev, next) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package subscriber
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func TestSubscriber_OnChangeSuppressesIdenticalData(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	s.ctx = context.Background()
+
+	var calls atomic.Int32
+	callback := func(data core.StockData) error {
+		calls.Add(1)
+		return nil
+	}
+	require.NoError(t, s.SubscribeWithOptions("600000", 5*time.Second, callback, DeliveryOptions{DeliverMode: DeliverOnChange}))
+	drainSubscriberEvents(s)
+
+	for i := 0; i < 5; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load(), "首次之后数据未变化不应再回调")
+	assert.Equal(t, int64(4), subscription(t, s, "600000").SuppressedCount)
+	assert.Len(t, eventsOfType(drainSubscriberEvents(s), EventTypeSuppressed), 4)
+}
+
+func TestSubscriber_DeliverAllByDefault(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	s.ctx = context.Background()
+
+	var calls atomic.Int32
+	require.NoError(t, s.Subscribe("600000", 5*time.Second, func(data core.StockData) error {
+		calls.Add(1)
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	assert.Eventually(t, func() bool { return calls.Load() == 3 }, time.Second, 10*time.Millisecond)
+	assert.Zero(t, subscription(t, s, "600000").SuppressedCount)
+}
+
+func TestSubscription_ShouldDeliver(t *testing.T) {
+	now := time.Now()
+	base := core.StockData{Symbol: "600000", Price: 10, Volume: 100, BidPrice1: 9.99, BidVolume1: 50}
+
+	t.Run("default fields", func(t *testing.T) {
+		sub := &Subscription{DeliveryOptions: DeliveryOptions{DeliverMode: DeliverOnChange}}
+		require.True(t, sub.shouldDeliver(base, now))
+
+		same := base
+		same.Timestamp = now.Add(time.Second)
+		assert.False(t, sub.shouldDeliver(same, now.Add(time.Second)), "时间戳变化不算数据变化")
+
+		bid := base
+		bid.BidVolume1 = 60
+		assert.True(t, sub.shouldDeliver(bid, now.Add(2*time.Second)), "盘口变化需要推送")
+		assert.False(t, sub.shouldDeliver(bid, now.Add(3*time.Second)))
+	})
+
+	t.Run("custom fields", func(t *testing.T) {
+		sub := &Subscription{DeliveryOptions: DeliveryOptions{
+			DeliverMode:   DeliverOnChange,
+			CompareFields: []CompareField{CompareFieldPrice},
+		}}
+		require.True(t, sub.shouldDeliver(base, now))
+
+		volume := base
+		volume.Volume = 200
+		assert.False(t, sub.shouldDeliver(volume, now.Add(time.Second)), "只比较价格")
+
+		price := volume
+		price.Price = 10.01
+		assert.True(t, sub.shouldDeliver(price, now.Add(2*time.Second)))
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		sub := &Subscription{DeliveryOptions: DeliveryOptions{
+			DeliverMode: DeliverOnChange,
+			Heartbeat:   time.Minute,
+		}}
+		require.True(t, sub.shouldDeliver(base, now))
+		assert.False(t, sub.shouldDeliver(base, now.Add(59*time.Second)))
+		assert.True(t, sub.shouldDeliver(base, now.Add(time.Minute)), "数据未变化也按心跳推送")
+		assert.False(t, sub.shouldDeliver(base, now.Add(90*time.Second)), "心跳从上次推送重新计时")
+	})
+}
+
+func TestSubscriber_SubscribeWithOptionsValidates(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+
+	err := s.SubscribeWithOptions("600000", 5*time.Second, noopCallback, DeliveryOptions{
+		DeliverMode:   DeliverOnChange,
+		CompareFields: []CompareField{"open_interest"},
+	})
+	assert.EqualError(t, err, `unknown compare field "open_interest"`)
+
+	err = s.SubscribeWithOptions("600000", 5*time.Second, noopCallback, DeliveryOptions{DeliverMode: DeliverMode(9)})
+	assert.Error(t, err)
+
+	err = s.SubscribeWithOptions("600000", 5*time.Second, noopCallback, DeliveryOptions{Heartbeat: -time.Second})
+	assert.Error(t, err)
+	assert.Empty(t, s.GetSubscriptions())
+}
+
+func TestManager_SuppressedDeliveriesKeepSubscriptionHealthy(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	s.ctx = context.Background()
+	manager := NewManager(s)
+	require.NoError(t, manager.SubscribeBatch([]SubscribeRequest{{
+		Symbol:          "600000",
+		Interval:        5 * time.Second,
+		Callback:        noopCallback,
+		DeliveryOptions: DeliveryOptions{DeliverMode: DeliverOnChange},
+	}}))
+	drainSubscriberEvents(s)
+
+	for i := 0; i < 3; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	require.Eventually(t, func() bool {
+		for _, ev := range drainSubscriberEvents(s) {
+			manager.processEvent(ev)
+		}
+		return manager.GetStatistics().SubscriptionStats["600000"].DataPointCount == 1
+	}, time.Second, 10*time.Millisecond)
+
+	stats := manager.GetStatistics()
+	subStats := stats.SubscriptionStats["600000"]
+	assert.Equal(t, int64(2), subStats.SuppressedCount)
+	assert.Equal(t, int64(2), stats.TotalSuppressed)
+	assert.True(t, subStats.IsHealthy)
+	assert.WithinDuration(t, time.Now(), subStats.LastDataTime, time.Second)
+}
@@ -0,0 +1,198 @@
+package subscriber
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultEventBuffer 事件消费者默认的缓冲区大小
+const DefaultEventBuffer = 1000
+
+// EventConsumerOptions 事件消费者选项
+type EventConsumerOptions struct {
+	Name       string      // 消费者名称，仅用于统计
+	Buffer     int         // 缓冲区大小，0 使用事件总线的默认值
+	EventTypes []EventType // 接收的事件类型，为空时接收全部事件
+}
+
+// EventBusStats 事件总线统计
+type EventBusStats struct {
+	Published int64                `json:"published"` // 发布的事件总数
+	Consumers []EventConsumerStats `json:"consumers"`
+}
+
+// EventConsumerStats 单个事件消费者统计
+type EventConsumerStats struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name,omitempty"`
+	Buffer    int    `json:"buffer"`
+	Pending   int    `json:"pending"`   // 缓冲区中尚未读取的事件数
+	Delivered int64  `json:"delivered"` // 写入缓冲区的事件数
+	Dropped   int64  `json:"dropped"`   // 缓冲区满时丢弃的最旧事件数
+}
+
+// eventConsumer 一个事件消费者，mu 保证丢弃最旧事件和写入新事件之间没有其他发送方插入
+type eventConsumer struct {
+	id     int
+	name   string
+	ch     chan UpdateEvent
+	filter map[EventType]bool // nil 表示接收全部事件
+
+	mu        sync.Mutex
+	delivered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// accepts 消费者是否接收该类型的事件
+func (c *eventConsumer) accepts(eventType EventType) bool {
+	return c.filter == nil || c.filter[eventType]
+}
+
+// offer 写入事件，缓冲区满时丢弃最旧的一条，不会阻塞
+func (c *eventConsumer) offer(event UpdateEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case c.ch <- event:
+		c.delivered.Add(1)
+		return
+	default:
+	}
+
+	select {
+	case <-c.ch:
+		c.dropped.Add(1)
+	default:
+		// 消费者刚好读走了事件
+	}
+	// 所有发送方都持有 mu，此时缓冲区至少有一个空位
+	c.ch <- event
+	c.delivered.Add(1)
+}
+
+// EventBus 订阅器事件总线，每个消费者拥有独立的缓冲通道；
+// 发布不会因为消费者读取缓慢而阻塞，缓冲区满时丢弃该消费者最旧的事件并计数
+type EventBus struct {
+	mu            sync.RWMutex
+	consumers     map[<-chan UpdateEvent]*eventConsumer
+	nextID        int
+	defaultBuffer int
+	closed        bool
+
+	published atomic.Int64
+}
+
+// NewEventBus 创建事件总线，buffer 为消费者默认的缓冲区大小，不大于 0 时使用 DefaultEventBuffer
+func NewEventBus(buffer int) *EventBus {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	return &EventBus{
+		consumers:     make(map[<-chan UpdateEvent]*eventConsumer),
+		defaultBuffer: buffer,
+	}
+}
+
+// Subscribe 注册一个使用默认缓冲区的消费者，eventTypes 为空时接收全部事件
+func (b *EventBus) Subscribe(eventTypes ...EventType) <-chan UpdateEvent {
+	return b.SubscribeWithOptions(EventConsumerOptions{EventTypes: eventTypes})
+}
+
+// SubscribeWithOptions 按选项注册消费者；事件总线已关闭时返回已关闭的通道
+func (b *EventBus) SubscribeWithOptions(opts EventConsumerOptions) <-chan UpdateEvent {
+	buffer := opts.Buffer
+	if buffer <= 0 {
+		buffer = b.defaultBuffer
+	}
+	consumer := &eventConsumer{
+		name: opts.Name,
+		ch:   make(chan UpdateEvent, buffer),
+	}
+	if len(opts.EventTypes) > 0 {
+		consumer.filter = make(map[EventType]bool, len(opts.EventTypes))
+		for _, eventType := range opts.EventTypes {
+			consumer.filter[eventType] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(consumer.ch)
+		return consumer.ch
+	}
+	b.nextID++
+	consumer.id = b.nextID
+	b.consumers[consumer.ch] = consumer
+	return consumer.ch
+}
+
+// Unsubscribe 注销消费者并关闭其通道，未注册的通道忽略
+func (b *EventBus) Unsubscribe(ch <-chan UpdateEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	consumer, ok := b.consumers[ch]
+	if !ok {
+		return
+	}
+	delete(b.consumers, ch)
+	close(consumer.ch)
+}
+
+// Publish 把事件分发给接收该类型的全部消费者，不会阻塞；事件总线关闭后忽略
+func (b *EventBus) Publish(event UpdateEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+	b.published.Add(1)
+	for _, consumer := range b.consumers {
+		if consumer.accepts(event.Type) {
+			consumer.offer(event)
+		}
+	}
+}
+
+// Close 关闭事件总线和全部消费者的通道，之后发布的事件被忽略
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch, consumer := range b.consumers {
+		delete(b.consumers, ch)
+		close(consumer.ch)
+	}
+}
+
+// Stats 返回事件总线统计，消费者按注册顺序排列
+func (b *EventBus) Stats() EventBusStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := EventBusStats{
+		Published: b.published.Load(),
+		Consumers: make([]EventConsumerStats, 0, len(b.consumers)),
+	}
+	for _, consumer := range b.consumers {
+		stats.Consumers = append(stats.Consumers, EventConsumerStats{
+			ID:        consumer.id,
+			Name:      consumer.name,
+			Buffer:    cap(consumer.ch),
+			Pending:   len(consumer.ch),
+			Delivered: consumer.delivered.Load(),
+			Dropped:   consumer.dropped.Load(),
+		})
+	}
+	sort.Slice(stats.Consumers, func(i, j int) bool { return stats.Consumers[i].ID < stats.Consumers[j].ID })
+	return stats
+}
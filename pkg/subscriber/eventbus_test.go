@@ -0,0 +1,174 @@
+package subscriber
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+func seqEvent(i int) UpdateEvent {
+	return UpdateEvent{Type: EventTypeData, Data: &core.StockData{Volume: int64(i)}}
+}
+
+func TestEventBus_DropsOldestWhenFull(t *testing.T) {
+	bus := NewEventBus(0)
+	ch := bus.SubscribeWithOptions(EventConsumerOptions{Name: "slow", Buffer: 10})
+
+	for i := 0; i < 100; i++ {
+		bus.Publish(seqEvent(i))
+	}
+
+	stats := bus.Stats()
+	assert.Equal(t, int64(100), stats.Published)
+	require.Len(t, stats.Consumers, 1)
+	assert.Equal(t, EventConsumerStats{ID: 1, Name: "slow", Buffer: 10, Pending: 10, Delivered: 100, Dropped: 90}, stats.Consumers[0])
+
+	// 缓冲区中保留最新的 10 个事件
+	for i := 90; i < 100; i++ {
+		assert.Equal(t, int64(i), (<-ch).Data.Volume)
+	}
+}
+
+func TestEventBus_FiltersByEventType(t *testing.T) {
+	bus := NewEventBus(10)
+	all := bus.Subscribe()
+	errs := bus.Subscribe(EventTypeError, EventTypeDegraded)
+
+	bus.Publish(UpdateEvent{Type: EventTypeData, Symbol: "600000"})
+	bus.Publish(UpdateEvent{Type: EventTypeError, Symbol: "000001"})
+
+	assert.Len(t, all, 2)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "000001", (<-errs).Symbol)
+	assert.Equal(t, int64(1), bus.Stats().Consumers[1].Delivered)
+}
+
+func TestEventBus_UnsubscribeAndClose(t *testing.T) {
+	bus := NewEventBus(10)
+	first := bus.Subscribe()
+	second := bus.Subscribe()
+
+	bus.Publish(UpdateEvent{Type: EventTypeData})
+	bus.Unsubscribe(first)
+	bus.Unsubscribe(first)
+	<-first
+	_, ok := <-first
+	assert.False(t, ok, "注销后通道关闭")
+	require.Len(t, bus.Stats().Consumers, 1)
+
+	bus.Close()
+	bus.Publish(UpdateEvent{Type: EventTypeData})
+	<-second
+	_, ok = <-second
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), bus.Stats().Published, "关闭后发布被忽略")
+
+	_, ok = <-bus.Subscribe()
+	assert.False(t, ok, "关闭后注册返回已关闭的通道")
+}
+
+func TestEventBus_SlowConsumerDoesNotBlockPublishers(t *testing.T) {
+	bus := NewEventBus(0)
+	fast := bus.SubscribeWithOptions(EventConsumerOptions{Name: "fast", Buffer: 4000})
+	slow := bus.SubscribeWithOptions(EventConsumerOptions{Name: "slow", Buffer: 8})
+
+	var slowReceived atomic.Int64
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		for range slow {
+			slowReceived.Add(1)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	const publishers, perPublisher = 8, 250
+	start := time.Now()
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perPublisher; i++ {
+				bus.Publish(seqEvent(p*perPublisher + i))
+			}
+		}(p)
+	}
+	wg.Wait()
+	// 慢消费者按 5ms 一个读取 2000 个事件需要 10s，发布方不能等待它
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	total := int64(publishers * perPublisher)
+	assert.Len(t, fast, int(total), "快消费者没有丢弃")
+
+	stats := bus.Stats()
+	require.Len(t, stats.Consumers, 2)
+	slowStats := stats.Consumers[1]
+	assert.Equal(t, total, stats.Published)
+	assert.Equal(t, total, slowStats.Delivered)
+	assert.Greater(t, slowStats.Dropped, int64(0))
+
+	bus.Unsubscribe(slow)
+	<-slowDone
+	assert.Equal(t, total, slowStats.Dropped+slowReceived.Load(), "丢弃数与读取数之和等于写入数")
+}
+
+func TestSubscriber_StuckEventConsumerDoesNotStallDataPath(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	s.ctx = context.Background()
+	// 从不读取的消费者，缓冲区只有 1
+	s.Events().SubscribeWithOptions(EventConsumerOptions{Name: "stuck", Buffer: 1})
+
+	var delivered atomic.Int64
+	require.NoError(t, s.Subscribe("600000", time.Second, func(core.StockData) error {
+		delivered.Add(1)
+		return nil
+	}))
+
+	const rounds = 50
+	for i := 0; i < rounds; i++ {
+		s.fetchAndNotify([]string{"600000"})
+	}
+	require.Eventually(t, func() bool { return delivered.Load() == rounds }, 2*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return s.Events().Stats().Published == rounds+1 }, 2*time.Second, time.Millisecond)
+
+	stats := s.Events().Stats()
+	require.Len(t, stats.Consumers, 2)
+	assert.Equal(t, "default", stats.Consumers[0].Name)
+	stuck := stats.Consumers[1]
+	assert.Equal(t, "stuck", stuck.Name)
+	assert.Equal(t, 1, stuck.Pending)
+	assert.Equal(t, int64(rounds+1), stuck.Delivered, "订阅成功事件和每轮的数据事件")
+	assert.Equal(t, int64(rounds), stuck.Dropped)
+}
+
+func TestManager_StatisticsIncludeEventBusStats(t *testing.T) {
+	s := NewSubscriber(&fakeStockProvider{})
+	manager := NewManager(s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, manager.Start(ctx))
+	defer manager.Stop()
+	require.NoError(t, manager.Subscribe("600000", time.Hour, noopCallback))
+
+	s.fetchAndNotify([]string{"600000"})
+	// 管理器只接收数据、错误、跳过和退避事件，订阅成功事件不写入它的缓冲区
+	require.Eventually(t, func() bool {
+		stats := manager.GetStatistics()
+		return stats.TotalDataPoints >= 1 && len(stats.EventBus.Consumers) == 2 &&
+			stats.EventBus.Consumers[1].Delivered == stats.TotalDataPoints
+	}, 2*time.Second, time.Millisecond)
+
+	stats := manager.GetStatistics()
+	assert.Equal(t, "default", stats.EventBus.Consumers[0].Name)
+	assert.Equal(t, "manager", stats.EventBus.Consumers[1].Name)
+	assert.Equal(t, int64(0), stats.EventBus.Consumers[1].Dropped)
+	assert.GreaterOrEqual(t, stats.EventBus.Published, stats.TotalDataPoints+1)
+}
@@ -15,6 +15,39 @@ type Subscription struct {
 	Interval time.Duration // 订阅间隔
 	Callback CallbackFunc  // 回调函数
 	Active   bool          // 是否激活
+
+	ConsecutiveErrors int           // 连续获取失败次数
+	BackoffInterval   time.Duration // 退避状态下的轮询间隔，0 表示未退避
+	AdaptiveInterval  time.Duration // 自适应模式调整后的轮询间隔，0 表示未启用或尚未调整
+
+	DeliveryOptions       // 推送选项
+	SuppressedCount int64 // OnChange 模式下因数据未变化而跳过的推送次数
+
+	lastFetch       time.Time       // 最近一次发起获取的时间
+	lastDelivered   *core.StockData // 最近一次推送给回调的数据
+	lastDeliveredAt time.Time       // 最近一次推送的时间
+	adaptive        adaptiveState   // 自适应轮询状态
+}
+
+// InBackoff 是否处于退避状态
+func (s Subscription) InBackoff() bool {
+	return s.BackoffInterval > 0
+}
+
+// EffectiveInterval 返回当前实际的轮询间隔，退避状态下为退避间隔
+func (s Subscription) EffectiveInterval() time.Duration {
+	if s.InBackoff() {
+		return s.BackoffInterval
+	}
+	return s.pollInterval()
+}
+
+// pollInterval 返回不考虑退避时的轮询间隔，自适应模式下为调整后的间隔
+func (s Subscription) pollInterval() time.Duration {
+	if s.AdaptiveInterval > 0 {
+		return s.AdaptiveInterval
+	}
+	return s.Interval
 }
 
 // CallbackFunc 数据回调函数类型
@@ -27,16 +60,21 @@ type UpdateEvent struct {
 	Data   *core.StockData `json:"data,omitempty"`
 	Error  error           `json:"error,omitempty"`
 	Time   time.Time       `json:"timestamp"`
+
+	Interval time.Duration `json:"interval,omitempty"` // EventTypeDegraded 事件的退避轮询间隔，EventTypeIntervalChanged 事件的新间隔
 }
 
 // EventType 事件类型
 type EventType int
 
 const (
-	EventTypeData         EventType = iota // 数据更新
-	EventTypeError                         // 错误事件
-	EventTypeSubscribed                    // 订阅成功
-	EventTypeUnsubscribed                  // 取消订阅
+	EventTypeData            EventType = iota // 数据更新
+	EventTypeError                            // 错误事件
+	EventTypeSubscribed                       // 订阅成功
+	EventTypeUnsubscribed                     // 取消订阅
+	EventTypeDegraded                         // 连续失败进入退避
+	EventTypeSuppressed                       // 数据未变化，跳过推送
+	EventTypeIntervalChanged                  // 自适应轮询间隔变化超过 2 倍
 )
 
 // Subscriber 订阅器接口
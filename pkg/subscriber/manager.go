@@ -14,6 +14,16 @@ type Manager struct {
 	config     *ManagerConfig
 	stats      *Statistics
 	statsMu    sync.RWMutex
+
+	universeResolver UniverseResolver
+	universes        map[string]*universeSubscription
+	universeOwned    map[string]time.Duration // 由股票池持有的底层订阅及其生效间隔，不含直接订阅
+	universeMu       sync.RWMutex
+
+	store           SubscriptionStore             // 订阅状态存储，nil 表示不持久化
+	callbackFactory CallbackFactory               // 恢复订阅时构造回调
+	records         map[string]SubscriptionRecord // 需要持久化的订阅，不含股票池成员
+	persistMu       sync.Mutex
 }
 
 // ManagerConfig 管理器配置
@@ -22,18 +32,24 @@ type ManagerConfig struct {
 	HealthCheckInterval time.Duration // 健康检查间隔
 	MaxFailures         int           // 最大失败次数
 	FailureWindow       time.Duration // 失败窗口时间
+
+	UniverseRefreshInterval time.Duration // 股票池成员刷新间隔
 }
 
 // Statistics 统计信息
 type Statistics struct {
-	TotalSubscriptions  int                  `json:"total_subscriptions"`
-	ActiveSubscriptions int                  `json:"active_subscriptions"`
-	TotalDataPoints     int64                `json:"total_data_points"`
-	TotalErrors         int64                `json:"total_errors"`
-	SubscriptionStats   map[string]*SubStats `json:"subscription_stats"`
-	ProviderStats       *ProviderStats       `json:"provider_stats"`
-	StartTime           time.Time            `json:"start_time"`
-	LastUpdateTime      time.Time            `json:"last_update_time"`
+	TotalSubscriptions  int                       `json:"total_subscriptions"`
+	ActiveSubscriptions int                       `json:"active_subscriptions"`
+	TotalDataPoints     int64                     `json:"total_data_points"`
+	TotalErrors         int64                     `json:"total_errors"`
+	TotalSuppressed     int64                     `json:"total_suppressed"`
+	SubscriptionStats   map[string]*SubStats      `json:"subscription_stats"`
+	UniverseStats       map[string]*UniverseStats `json:"universe_stats"`
+	ProviderStats       *ProviderStats            `json:"provider_stats"`
+	BatchStats          *BatchStats               `json:"batch_stats"`
+	EventBus            *EventBusStats            `json:"event_bus"`
+	StartTime           time.Time                 `json:"start_time"`
+	LastUpdateTime      time.Time                 `json:"last_update_time"`
 }
 
 // SubStats 单个订阅统计
@@ -47,6 +63,13 @@ type SubStats struct {
 	LastError       string        `json:"last_error,omitempty"`
 	LastErrorTime   time.Time     `json:"last_error_time,omitempty"`
 	IsHealthy       bool          `json:"is_healthy"`
+
+	ConsecutiveErrors int           `json:"consecutive_errors"`
+	InBackoff         bool          `json:"in_backoff"`
+	BackoffInterval   time.Duration `json:"backoff_interval,omitempty"`
+	EffectiveInterval time.Duration `json:"effective_interval"` // 当前实际的轮询间隔，含退避和自适应调整
+
+	SuppressedCount int64 `json:"suppressed_count"` // OnChange 模式下跳过的推送次数
 }
 
 // ProviderStats 提供商统计
@@ -66,28 +89,49 @@ func NewManager(subscriber *DefaultSubscriber) *Manager {
 		HealthCheckInterval: 30 * time.Second,
 		MaxFailures:         5,
 		FailureWindow:       5 * time.Minute,
+
+		UniverseRefreshInterval: 1 * time.Minute,
 	}
 
 	stats := &Statistics{
 		SubscriptionStats: make(map[string]*SubStats),
+		UniverseStats:     make(map[string]*UniverseStats),
 		ProviderStats:     &ProviderStats{},
 		StartTime:         time.Now(),
 	}
 
 	return &Manager{
-		subscriber: subscriber,
-		config:     config,
-		stats:      stats,
+		subscriber:    subscriber,
+		config:        config,
+		stats:         stats,
+		universes:     make(map[string]*universeSubscription),
+		universeOwned: make(map[string]time.Duration),
+		records:       make(map[string]SubscriptionRecord),
 	}
 }
 
 // Start 启动管理器
 func (m *Manager) Start(ctx context.Context) error {
+	// 先注册事件消费者，恢复订阅产生的事件同样计入统计
+	events := m.subscriber.Events().SubscribeWithOptions(EventConsumerOptions{
+		Name:       "manager",
+		EventTypes: []EventType{EventTypeData, EventTypeError, EventTypeSuppressed, EventTypeDegraded},
+	})
+
 	// 启动订阅器
 	if err := m.subscriber.Start(ctx); err != nil {
+		m.subscriber.Events().Unsubscribe(events)
 		return fmt.Errorf("failed to start subscriber: %w", err)
 	}
 
+	// 恢复上次保存的订阅
+	m.persistMu.Lock()
+	factory := m.callbackFactory
+	m.persistMu.Unlock()
+	if _, err := m.RestoreState(ctx, factory); err != nil {
+		log.Printf("[Manager] Failed to restore subscriptions: %v", err)
+	}
+
 	// 启动统计收集
 	go m.runStatisticsCollector(ctx)
 
@@ -95,7 +139,10 @@ func (m *Manager) Start(ctx context.Context) error {
 	go m.runHealthChecker(ctx)
 
 	// 启动事件处理
-	go m.runEventProcessor(ctx)
+	go m.runEventProcessor(ctx, events)
+
+	// 启动股票池成员监听
+	go m.runUniverseWatcher(ctx)
 
 	log.Printf("[Manager] Started with config: AutoRestart=%v, HealthCheckInterval=%v",
 		m.config.AutoRestart, m.config.HealthCheckInterval)
@@ -103,35 +150,63 @@ func (m *Manager) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop 停止管理器
+// Stop 停止管理器，启用持久化时先保存订阅状态
 func (m *Manager) Stop() error {
+	m.saveStateLogged()
 	return m.subscriber.Stop()
 }
 
 // Subscribe 订阅股票（增强版）
 func (m *Manager) Subscribe(symbol string, interval time.Duration, callback CallbackFunc) error {
-	err := m.subscriber.Subscribe(symbol, interval, callback)
+	return m.SubscribeWithOptions(symbol, interval, callback, DeliveryOptions{})
+}
+
+// SubscribeWithOptions 按指定推送选项订阅股票
+// 启用持久化时以空回调名称保存，恢复时 CallbackFactory 收到的名称为空
+func (m *Manager) SubscribeWithOptions(symbol string, interval time.Duration, callback CallbackFunc, opts DeliveryOptions) error {
+	if err := m.subscribe(symbol, interval, callback, opts); err != nil {
+		return err
+	}
+	m.recordSubscription(SubscriptionRecord{Symbol: symbol, Interval: interval, DeliveryOptions: opts})
+	return nil
+}
+
+// subscribe 订阅股票并初始化统计信息，不保存订阅状态
+func (m *Manager) subscribe(symbol string, interval time.Duration, callback CallbackFunc, opts DeliveryOptions) error {
+	err := m.subscriber.SubscribeWithOptions(symbol, interval, callback, opts)
 	if err != nil {
 		return err
 	}
 
-	// 初始化统计信息
+	// 初始化统计信息，更新已有订阅时不重复计数
 	m.statsMu.Lock()
+	if _, exists := m.stats.SubscriptionStats[symbol]; !exists {
+		m.stats.TotalSubscriptions++
+		m.stats.ActiveSubscriptions++
+	}
 	m.stats.SubscriptionStats[symbol] = &SubStats{
 		Symbol:       symbol,
 		SubscribedAt: time.Now(),
 		IsHealthy:    true,
 	}
-	m.stats.TotalSubscriptions++
-	m.stats.ActiveSubscriptions++
 	m.statsMu.Unlock()
 
 	log.Printf("[Manager] Successfully subscribed to %s with interval %v", symbol, interval)
 	return nil
 }
 
-// Unsubscribe 取消订阅（增强版）
+// Unsubscribe 取消订阅（增强版），股票仍属于已订阅的股票池时改由股票池继续订阅
 func (m *Manager) Unsubscribe(symbol string) error {
+	if err := m.unsubscribe(symbol); err != nil {
+		return err
+	}
+	m.forgetSubscription(symbol)
+	m.resyncUniverseMember(symbol)
+	return nil
+}
+
+// unsubscribe 取消订阅并清理统计信息，不保存订阅状态
+func (m *Manager) unsubscribe(symbol string) error {
 	err := m.subscriber.Unsubscribe(symbol)
 	if err != nil {
 		return err
@@ -154,7 +229,7 @@ func (m *Manager) SubscribeBatch(requests []SubscribeRequest) error {
 	var errors []error
 
 	for _, req := range requests {
-		if err := m.Subscribe(req.Symbol, req.Interval, req.Callback); err != nil {
+		if err := m.SubscribeWithOptions(req.Symbol, req.Interval, req.Callback, req.DeliveryOptions); err != nil {
 			errors = append(errors, fmt.Errorf("failed to subscribe %s: %w", req.Symbol, err))
 		}
 	}
@@ -166,21 +241,17 @@ func (m *Manager) SubscribeBatch(requests []SubscribeRequest) error {
 	return nil
 }
 
-// UnsubscribeBatch 批量取消订阅
-func (m *Manager) UnsubscribeBatch(symbols []string) error {
-	var errors []error
-
+// UnsubscribeBatch 批量取消订阅，逐个执行并返回每只股票的结果
+func (m *Manager) UnsubscribeBatch(symbols []string) *BatchResult {
+	result := newBatchResult()
 	for _, symbol := range symbols {
 		if err := m.Unsubscribe(symbol); err != nil {
-			errors = append(errors, fmt.Errorf("failed to unsubscribe %s: %w", symbol, err))
+			result.Failed[symbol] = err
+			continue
 		}
+		result.Succeeded = append(result.Succeeded, symbol)
 	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf("batch unsubscription failed: %v", errors)
-	}
-
-	return nil
+	return result
 }
 
 // GetStatistics 获取统计信息
@@ -195,15 +266,50 @@ func (m *Manager) GetStatistics() Statistics {
 		statsCopy := *v
 		stats.SubscriptionStats[k] = &statsCopy
 	}
+	stats.UniverseStats = make(map[string]*UniverseStats)
+	for k, v := range m.stats.UniverseStats {
+		universeCopy := *v
+		stats.UniverseStats[k] = &universeCopy
+	}
 
 	if m.stats.ProviderStats != nil {
 		providerStats := *m.stats.ProviderStats
 		stats.ProviderStats = &providerStats
 	}
 
+	batchStats := m.subscriber.GetBatchStats()
+	stats.BatchStats = &batchStats
+	eventBusStats := m.subscriber.Events().Stats()
+	stats.EventBus = &eventBusStats
+
+	// 退避状态和轮询间隔以订阅器为准
+	for _, sub := range m.subscriber.GetSubscriptions() {
+		if subStats, exists := stats.SubscriptionStats[sub.Symbol]; exists {
+			subStats.ConsecutiveErrors = sub.ConsecutiveErrors
+			subStats.InBackoff = sub.InBackoff()
+			subStats.BackoffInterval = sub.BackoffInterval
+			subStats.EffectiveInterval = sub.EffectiveInterval()
+		}
+	}
+
 	return stats
 }
 
+// ResubscribeAll 强制重置所有订阅的退避状态，下一个周期立即重新获取
+func (m *Manager) ResubscribeAll() {
+	reset := m.subscriber.ResetBackoff()
+
+	m.statsMu.Lock()
+	for _, stats := range m.stats.SubscriptionStats {
+		stats.ConsecutiveErrors = 0
+		stats.InBackoff = false
+		stats.BackoffInterval = 0
+	}
+	m.statsMu.Unlock()
+
+	log.Printf("[Manager] Resubscribed all subscriptions, %d recovered from backoff", reset)
+}
+
 // GetSubscriptions 获取订阅列表
 func (m *Manager) GetSubscriptions() []Subscription {
 	return m.subscriber.GetSubscriptions()
@@ -239,15 +345,15 @@ func (m *Manager) runHealthChecker(ctx context.Context) {
 	}
 }
 
-// runEventProcessor 运行事件处理器
-func (m *Manager) runEventProcessor(ctx context.Context) {
-	eventChan := m.subscriber.GetEventChannel()
+// runEventProcessor 运行事件处理器，退出时注销事件消费者
+func (m *Manager) runEventProcessor(ctx context.Context, events <-chan UpdateEvent) {
+	defer m.subscriber.Events().Unsubscribe(events)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case event, ok := <-eventChan:
+		case event, ok := <-events:
 			if !ok {
 				return
 			}
@@ -271,6 +377,8 @@ func (m *Manager) processEvent(event UpdateEvent) {
 		stats.DataPointCount++
 		stats.LastDataTime = event.Time
 		stats.IsHealthy = true
+		stats.InBackoff = false
+		stats.BackoffInterval = 0
 		m.stats.TotalDataPoints++
 
 	case EventTypeError:
@@ -282,10 +390,23 @@ func (m *Manager) processEvent(event UpdateEvent) {
 		}
 		m.stats.TotalErrors++
 
-		// 自动重启逻辑
-		if m.config.AutoRestart && stats.ErrorCount >= int64(m.config.MaxFailures) {
+		// 自动重启逻辑，退避中的订阅由订阅器按退避间隔继续轮询，不再重启
+		if m.config.AutoRestart && stats.ErrorCount >= int64(m.config.MaxFailures) && !stats.InBackoff {
 			go m.attemptRestart(event.Symbol)
 		}
+
+	case EventTypeSuppressed:
+		// 数据未变化但获取成功，同样视为健康
+		stats.SuppressedCount++
+		stats.LastDataTime = event.Time
+		stats.IsHealthy = true
+		m.stats.TotalSuppressed++
+
+	case EventTypeDegraded:
+		stats.IsHealthy = false
+		stats.InBackoff = true
+		stats.BackoffInterval = event.Interval
+		log.Printf("[Manager] Subscription %s degraded, polling every %v", event.Symbol, event.Interval)
 	}
 
 	m.stats.LastUpdateTime = event.Time
@@ -347,7 +468,7 @@ func (m *Manager) attemptRestart(symbol string) {
 
 	time.Sleep(1 * time.Second) // 短暂等待
 
-	if err := m.subscriber.Subscribe(symbol, targetSub.Interval, targetSub.Callback); err != nil {
+	if err := m.subscriber.SubscribeWithOptions(symbol, targetSub.Interval, targetSub.Callback, targetSub.DeliveryOptions); err != nil {
 		log.Printf("[Manager] Failed to restart subscription for %s: %v", symbol, err)
 		return
 	}
@@ -369,4 +490,6 @@ type SubscribeRequest struct {
 	Symbol   string
 	Interval time.Duration
 	Callback CallbackFunc
+
+	DeliveryOptions // 推送选项，零值为每次获取都推送
 }
@@ -0,0 +1,289 @@
+package subscriber
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// subscriptionStateVersion 订阅状态的格式版本
+const subscriptionStateVersion = 1
+
+// SubscriptionRecord 持久化的单个订阅，回调函数无法序列化，只保存注册时使用的回调名称
+type SubscriptionRecord struct {
+	Symbol          string          `json:"symbol"`
+	Interval        time.Duration   `json:"interval"`
+	Callback        string          `json:"callback,omitempty"` // 恢复时传给 CallbackFactory 的回调名称
+	DeliveryOptions DeliveryOptions `json:"delivery_options"`
+}
+
+// SubscriptionState 持久化的订阅状态
+type SubscriptionState struct {
+	Version       int                  `json:"version"`
+	SavedAt       time.Time            `json:"saved_at"`
+	Subscriptions []SubscriptionRecord `json:"subscriptions"`
+}
+
+// SubscriptionStore 订阅状态存储
+type SubscriptionStore interface {
+	// Load 读取上次保存的状态，没有保存过时返回 nil
+	Load(ctx context.Context) (*SubscriptionState, error)
+
+	// Save 覆盖保存当前状态
+	Save(ctx context.Context, state *SubscriptionState) error
+}
+
+// CallbackFactory 按订阅时记录的回调名称重新构造回调，用于重启后恢复订阅
+type CallbackFactory func(name, symbol string) (CallbackFunc, error)
+
+// errCorruptState 保存的状态无法解析
+var errCorruptState = errors.New("corrupt subscription state")
+
+// decodeSubscriptionState 解析保存的状态
+func decodeSubscriptionState(data []byte) (*SubscriptionState, error) {
+	var state SubscriptionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%w: %v", errCorruptState, err)
+	}
+	if state.Version != subscriptionStateVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errCorruptState, state.Version)
+	}
+	return &state, nil
+}
+
+// FileSubscriptionStore 将订阅状态保存为 JSON 文件
+type FileSubscriptionStore struct {
+	path string
+	now  func() time.Time
+}
+
+// NewFileSubscriptionStore 创建文件存储，目录不存在时在首次保存时创建
+func NewFileSubscriptionStore(path string) *FileSubscriptionStore {
+	return &FileSubscriptionStore{path: path, now: time.Now}
+}
+
+// Load 实现 SubscriptionStore 接口
+// 文件无法解析时重命名为 <path>.corrupt-<时间戳> 备份并返回 nil，不影响启动
+func (s *FileSubscriptionStore) Load(ctx context.Context) (*SubscriptionState, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscription state %s: %w", s.path, err)
+	}
+
+	state, err := decodeSubscriptionState(data)
+	if err != nil {
+		backup := fmt.Sprintf("%s.corrupt-%s", s.path, s.now().Format("20060102150405"))
+		if renameErr := os.Rename(s.path, backup); renameErr != nil {
+			return nil, fmt.Errorf("failed to back up corrupt subscription state %s: %w", s.path, renameErr)
+		}
+		log.Printf("[Manager] Ignoring corrupt subscription state %s (%v), backed up to %s", s.path, err, backup)
+		return nil, nil
+	}
+	return state, nil
+}
+
+// Save 实现 SubscriptionStore 接口，先写入临时文件再重命名，避免中途退出留下不完整的文件
+func (s *FileSubscriptionStore) Save(ctx context.Context, state *SubscriptionState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode subscription state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", s.path, err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write subscription state %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace subscription state %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// DefaultSubscriptionStateKey Redis 订阅状态存储的默认键
+const DefaultSubscriptionStateKey = "subscriber:state"
+
+// RedisSubscriptionStore 将订阅状态以 JSON 字符串保存在 Redis 中
+type RedisSubscriptionStore struct {
+	client *redis.Client
+	key    string
+	now    func() time.Time
+}
+
+// NewRedisSubscriptionStore 创建 Redis 存储，key 为空时使用 DefaultSubscriptionStateKey
+func NewRedisSubscriptionStore(client *redis.Client, key string) *RedisSubscriptionStore {
+	if key == "" {
+		key = DefaultSubscriptionStateKey
+	}
+	return &RedisSubscriptionStore{client: client, key: key, now: time.Now}
+}
+
+// Load 实现 SubscriptionStore 接口
+// 值无法解析时重命名为 <key>:corrupt:<时间戳> 备份并返回 nil，不影响启动
+func (s *RedisSubscriptionStore) Load(ctx context.Context) (*SubscriptionState, error) {
+	data, err := s.client.Get(ctx, s.key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscription state %s: %w", s.key, err)
+	}
+
+	state, err := decodeSubscriptionState(data)
+	if err != nil {
+		backup := fmt.Sprintf("%s:corrupt:%s", s.key, s.now().Format("20060102150405"))
+		if renameErr := s.client.Rename(ctx, s.key, backup).Err(); renameErr != nil {
+			return nil, fmt.Errorf("failed to back up corrupt subscription state %s: %w", s.key, renameErr)
+		}
+		log.Printf("[Manager] Ignoring corrupt subscription state %s (%v), backed up to %s", s.key, err, backup)
+		return nil, nil
+	}
+	return state, nil
+}
+
+// Save 实现 SubscriptionStore 接口
+func (s *RedisSubscriptionStore) Save(ctx context.Context, state *SubscriptionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscription state: %w", err)
+	}
+	if err := s.client.Set(ctx, s.key, data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save subscription state %s: %w", s.key, err)
+	}
+	return nil
+}
+
+// SetSubscriptionStore 启用订阅持久化：每次订阅、取消订阅和 Stop 时保存状态，Start 时用 factory 恢复上次的订阅
+// 股票池成员由 SubscribeUniverse 管理，不会单独保存
+func (m *Manager) SetSubscriptionStore(store SubscriptionStore, factory CallbackFactory) {
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	m.store = store
+	m.callbackFactory = factory
+}
+
+// SubscribeNamed 订阅股票，回调由 CallbackFactory 按名称构造；名称随订阅一起保存，重启后用同一名称恢复
+func (m *Manager) SubscribeNamed(symbol string, interval time.Duration, callbackName string, opts DeliveryOptions) error {
+	m.persistMu.Lock()
+	factory := m.callbackFactory
+	m.persistMu.Unlock()
+	if factory == nil {
+		return fmt.Errorf("no callback factory registered, call SetSubscriptionStore first")
+	}
+
+	callback, err := factory(callbackName, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to resolve callback %q for %s: %w", callbackName, symbol, err)
+	}
+	if err := m.subscribe(symbol, interval, callback, opts); err != nil {
+		return err
+	}
+	m.recordSubscription(SubscriptionRecord{Symbol: symbol, Interval: interval, Callback: callbackName, DeliveryOptions: opts})
+	return nil
+}
+
+// SaveState 保存当前通过 Subscribe 系列方法添加的订阅，未设置存储时不做任何事
+func (m *Manager) SaveState() error {
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	if m.store == nil {
+		return nil
+	}
+
+	state := &SubscriptionState{
+		Version:       subscriptionStateVersion,
+		SavedAt:       time.Now(),
+		Subscriptions: make([]SubscriptionRecord, 0, len(m.records)),
+	}
+	for _, record := range m.records {
+		state.Subscriptions = append(state.Subscriptions, record)
+	}
+	sort.Slice(state.Subscriptions, func(i, j int) bool {
+		return state.Subscriptions[i].Symbol < state.Subscriptions[j].Symbol
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.store.Save(ctx, state)
+}
+
+// RestoreState 从存储中恢复订阅，回调由 factory 按保存的回调名称构造
+// 单个订阅恢复失败时记录日志并继续，返回成功恢复的数量
+func (m *Manager) RestoreState(ctx context.Context, factory CallbackFactory) (int, error) {
+	m.persistMu.Lock()
+	store := m.store
+	m.persistMu.Unlock()
+	if store == nil {
+		return 0, nil
+	}
+	if factory == nil {
+		return 0, fmt.Errorf("callback factory cannot be nil")
+	}
+
+	state, err := store.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if state == nil {
+		return 0, nil
+	}
+
+	restored := 0
+	for _, record := range state.Subscriptions {
+		callback, err := factory(record.Callback, record.Symbol)
+		if err != nil {
+			log.Printf("[Manager] Failed to restore subscription %s: callback %q: %v", record.Symbol, record.Callback, err)
+			continue
+		}
+		if err := m.subscribe(record.Symbol, record.Interval, callback, record.DeliveryOptions); err != nil {
+			log.Printf("[Manager] Failed to restore subscription %s: %v", record.Symbol, err)
+			continue
+		}
+		m.persistMu.Lock()
+		m.records[record.Symbol] = record
+		m.persistMu.Unlock()
+		restored++
+	}
+
+	log.Printf("[Manager] Restored %d/%d subscriptions", restored, len(state.Subscriptions))
+	return restored, nil
+}
+
+// recordSubscription 记录订阅并保存状态，保存失败只记录日志
+func (m *Manager) recordSubscription(record SubscriptionRecord) {
+	m.persistMu.Lock()
+	m.records[record.Symbol] = record
+	m.persistMu.Unlock()
+	m.saveStateLogged()
+}
+
+// forgetSubscription 移除订阅记录并保存状态
+func (m *Manager) forgetSubscription(symbol string) {
+	m.persistMu.Lock()
+	_, ok := m.records[symbol]
+	delete(m.records, symbol)
+	m.persistMu.Unlock()
+	if ok {
+		m.saveStateLogged()
+	}
+}
+
+func (m *Manager) saveStateLogged() {
+	if err := m.SaveState(); err != nil {
+		log.Printf("[Manager] Failed to save subscription state: %v", err)
+	}
+}
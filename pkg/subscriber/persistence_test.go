@@ -0,0 +1,155 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// namedCallbacks 记录恢复时请求的回调名称
+func namedCallbacks(requested map[string]string) CallbackFactory {
+	return func(name, symbol string) (CallbackFunc, error) {
+		if name == "missing" {
+			return nil, fmt.Errorf("unknown callback %q", name)
+		}
+		requested[symbol] = name
+		return noopCallback, nil
+	}
+}
+
+func newPersistentTestManager(store SubscriptionStore, factory CallbackFactory) *Manager {
+	manager := NewManager(NewSubscriber(&fakeStockProvider{}))
+	manager.SetSubscriptionStore(store, factory)
+	return manager
+}
+
+func TestManager_RestoresSubscriptionsAfterRestart(t *testing.T) {
+	for name, newStore := range map[string]func(t *testing.T) SubscriptionStore{
+		"file": func(t *testing.T) SubscriptionStore {
+			return NewFileSubscriptionStore(filepath.Join(t.TempDir(), "state", "subscriptions.json"))
+		},
+		"redis": func(t *testing.T) SubscriptionStore {
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			t.Cleanup(func() { client.Close() })
+			return NewRedisSubscriptionStore(client, "")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+
+			first := newPersistentTestManager(store, namedCallbacks(make(map[string]string)))
+			ctx, cancel := context.WithCancel(context.Background())
+			require.NoError(t, first.Start(ctx))
+			require.NoError(t, first.Subscribe("600000", 5*time.Second, noopCallback))
+			require.NoError(t, first.SubscribeNamed("000001", 10*time.Second, "alerts", DeliveryOptions{DeliverMode: DeliverOnChange}))
+			require.NoError(t, first.SubscribeNamed("600519", 30*time.Second, "archive", DeliveryOptions{}))
+			require.NoError(t, first.SubscribeNamed("601398", time.Minute, "archive", DeliveryOptions{}))
+			require.NoError(t, first.Unsubscribe("601398"))
+			require.NoError(t, first.Stop())
+			cancel()
+
+			requested := make(map[string]string)
+			second := newPersistentTestManager(store, namedCallbacks(requested))
+			ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+			require.NoError(t, second.Start(ctx))
+			defer second.Stop()
+
+			assert.Equal(t, map[string]time.Duration{
+				"600000": 5 * time.Second,
+				"000001": 10 * time.Second,
+				"600519": 30 * time.Second,
+			}, subscribedIntervals(second))
+			for _, sub := range second.GetSubscriptions() {
+				assert.True(t, sub.Active, sub.Symbol)
+				if sub.Symbol == "000001" {
+					assert.Equal(t, DeliverOnChange, sub.DeliverMode)
+				}
+			}
+			assert.Equal(t, map[string]string{"600000": "", "000001": "alerts", "600519": "archive"}, requested)
+			assert.Equal(t, 3, second.GetStatistics().ActiveSubscriptions)
+		})
+	}
+}
+
+func TestManager_RestoreSkipsUnresolvableCallbacks(t *testing.T) {
+	store := NewFileSubscriptionStore(filepath.Join(t.TempDir(), "subscriptions.json"))
+	require.NoError(t, store.Save(context.Background(), &SubscriptionState{
+		Version: subscriptionStateVersion,
+		Subscriptions: []SubscriptionRecord{
+			{Symbol: "600000", Interval: 5 * time.Second},
+			{Symbol: "000001", Interval: 5 * time.Second, Callback: "missing"},
+		},
+	}))
+
+	manager := newPersistentTestManager(store, namedCallbacks(map[string]string{}))
+	restored, err := manager.RestoreState(context.Background(), manager.callbackFactory)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+	assert.Equal(t, []string{"600000"}, subscribedSymbols(manager))
+}
+
+func TestManager_UniverseMembersAreNotPersisted(t *testing.T) {
+	store := NewFileSubscriptionStore(filepath.Join(t.TempDir(), "subscriptions.json"))
+	manager, _ := newUniverseTestManager(map[string][]string{"sample": {"600000", "000001"}})
+	manager.SetSubscriptionStore(store, namedCallbacks(map[string]string{}))
+
+	require.NoError(t, manager.SubscribeUniverse("sample", 5*time.Second, func(core.StockData) error { return nil }))
+	require.NoError(t, manager.Subscribe("600519", 5*time.Second, noopCallback))
+
+	state, err := store.Load(context.Background())
+	require.NoError(t, err)
+	require.Len(t, state.Subscriptions, 1)
+	assert.Equal(t, "600519", state.Subscriptions[0].Symbol)
+}
+
+func TestFileSubscriptionStore_BacksUpCorruptState(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "subscriptions.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 1, "subscriptions": [`), 0o644))
+
+	store := NewFileSubscriptionStore(path)
+	store.now = func() time.Time { return time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC) }
+
+	manager := newPersistentTestManager(store, namedCallbacks(map[string]string{}))
+	require.NoError(t, manager.Start(context.Background()), "损坏的状态文件不影响启动")
+	defer manager.Stop()
+	assert.Empty(t, manager.GetSubscriptions())
+
+	backup, err := os.ReadFile(path + ".corrupt-20250820100000")
+	require.NoError(t, err)
+	assert.Equal(t, `{"version": 1, "subscriptions": [`, string(backup))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "损坏的文件已移走")
+
+	state, err := store.Load(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestRedisSubscriptionStore_BacksUpCorruptState(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	require.NoError(t, mr.Set("subscriber:state", `{"version": 99}`))
+
+	store := NewRedisSubscriptionStore(client, "")
+	store.now = func() time.Time { return time.Date(2025, 8, 20, 10, 0, 0, 0, time.UTC) }
+	state, err := store.Load(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, state)
+	assert.False(t, mr.Exists("subscriber:state"))
+	backup, err := mr.Get("subscriber:state:corrupt:20250820100000")
+	require.NoError(t, err)
+	assert.Equal(t, `{"version": 99}`, backup)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"stocksub/pkg/core"
@@ -18,7 +19,8 @@ type DefaultSubscriber struct {
 	provider      provider.RealtimeStockProvider
 	subscriptions map[string]*Subscription
 	subsMu        sync.RWMutex
-	eventChan     chan UpdateEvent
+	events        *EventBus
+	defaultEvents <-chan UpdateEvent // GetEventChannel 返回的默认消费者
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
@@ -26,81 +28,178 @@ type DefaultSubscriber struct {
 	minInterval   time.Duration
 	maxInterval   time.Duration
 	log           *logrus.Entry
+
+	failureThreshold int           // 连续失败达到该次数后进入退避
+	maxBackoff       time.Duration // 退避轮询间隔上限
+
+	coalesceWindow time.Duration // 合并窗口：下次获取时间落在窗口内的订阅提前并入本轮请求
+	maxBatchSize   int           // 单次提供商调用的最大股票数量
+
+	providerCalls  atomic.Int64 // 提供商调用次数
+	symbolsFetched atomic.Int64 // 请求的股票数量累计
+}
+
+// BatchStats 批量获取统计
+type BatchStats struct {
+	ProviderCalls    int64   `json:"provider_calls"`
+	SymbolsFetched   int64   `json:"symbols_fetched"`
+	CallsSaved       int64   `json:"calls_saved"` // 相比每个股票单独请求节省的调用次数
+	AverageBatchSize float64 `json:"average_batch_size"`
 }
 
+// 默认退避策略
+const (
+	DefaultFailureThreshold = 3
+	DefaultMaxBackoff       = 10 * time.Minute
+	backoffMultiplier       = 2
+
+	DefaultMaxBatchSize = 60 // 腾讯接口单次请求最多约 60 个股票
+)
+
 // NewSubscriber 创建新的订阅器
 func NewSubscriber(provider provider.RealtimeStockProvider) *DefaultSubscriber {
-	return &DefaultSubscriber{
+	s := &DefaultSubscriber{
 		provider:      provider,
 		subscriptions: make(map[string]*Subscription),
-		eventChan:     make(chan UpdateEvent, 1000),
 		maxSubs:       100,
 		minInterval:   1 * time.Second,
 		maxInterval:   1 * time.Hour,
 		log:           logger.WithComponent("Subscriber"),
+
+		failureThreshold: DefaultFailureThreshold,
+		maxBackoff:       DefaultMaxBackoff,
+
+		maxBatchSize: DefaultMaxBatchSize,
 	}
+	s.events = NewEventBus(DefaultEventBuffer)
+	s.defaultEvents = s.events.SubscribeWithOptions(EventConsumerOptions{Name: "default"})
+	return s
 }
 
 // Subscribe 订阅股票
 func (s *DefaultSubscriber) Subscribe(symbol string, interval time.Duration, callback CallbackFunc) error {
-	if symbol == "" {
-		return fmt.Errorf("symbol cannot be empty")
-	}
-
-	if callback == nil {
-		return fmt.Errorf("callback cannot be nil")
-	}
-
-	if interval < s.minInterval {
-		return fmt.Errorf("interval too short, minimum is %v", s.minInterval)
-	}
-
-	if interval > s.maxInterval {
-		return fmt.Errorf("interval too long, maximum is %v", s.maxInterval)
-	}
+	return s.SubscribeWithOptions(symbol, interval, callback, DeliveryOptions{})
+}
 
-	if !s.provider.IsSymbolSupported(symbol) {
-		return fmt.Errorf("symbol %s is not supported by provider %s", symbol, s.provider.Name())
+// SubscribeWithOptions 按指定推送选项订阅股票
+func (s *DefaultSubscriber) SubscribeWithOptions(symbol string, interval time.Duration, callback CallbackFunc, opts DeliveryOptions) error {
+	if err := s.validateSubscription(symbol, interval, callback, opts); err != nil {
+		return err
 	}
 
 	s.subsMu.Lock()
 	defer s.subsMu.Unlock()
 
-	if len(s.subscriptions) >= s.maxSubs {
+	existing, exists := s.subscriptions[symbol]
+	if !exists && len(s.subscriptions) >= s.maxSubs {
 		return fmt.Errorf("maximum subscriptions (%d) reached", s.maxSubs)
 	}
 
 	// 如果已存在订阅，更新它
-	if existing, exists := s.subscriptions[symbol]; exists {
+	if exists {
 		existing.Interval = interval
 		existing.Callback = callback
 		existing.Active = true
+		existing.ConsecutiveErrors = 0
+		existing.BackoffInterval = 0
+		existing.AdaptiveInterval = 0
+		existing.DeliveryOptions = opts
+		existing.lastDelivered = nil
+		existing.adaptive = adaptiveState{}
 		s.log.Infof("Updated subscription for %s with interval %v", symbol, interval)
 	} else {
 		s.subscriptions[symbol] = &Subscription{
-			Symbol:   symbol,
-			Interval: interval,
-			Callback: callback,
-			Active:   true,
+			Symbol:          symbol,
+			Interval:        interval,
+			Callback:        callback,
+			Active:          true,
+			DeliveryOptions: opts,
 		}
 		s.log.Infof("Added subscription for %s with interval %v", symbol, interval)
 	}
 
 	// 发送订阅成功事件
-	select {
-	case s.eventChan <- UpdateEvent{
+	s.events.Publish(UpdateEvent{
 		Type:   EventTypeSubscribed,
 		Symbol: symbol,
 		Time:   time.Now(),
-	}:
-	default:
-		s.log.Infof("Warning: event channel full, dropping subscription event for %s", symbol)
-	}
+	})
 
 	return nil
 }
 
 // Unsubscribe 取消订阅
+// validateSubscription 检查订阅参数：代码、回调、间隔范围、推送选项以及提供商是否支持该代码
+func (s *DefaultSubscriber) validateSubscription(symbol string, interval time.Duration, callback CallbackFunc, opts DeliveryOptions) error {
+	if symbol == "" {
+		return fmt.Errorf("symbol cannot be empty")
+	}
+
+	if callback == nil {
+		return fmt.Errorf("callback cannot be nil")
+	}
+
+	if interval < s.minInterval {
+		return fmt.Errorf("interval too short, minimum is %v", s.minInterval)
+	}
+
+	if interval > s.maxInterval {
+		return fmt.Errorf("interval too long, maximum is %v", s.maxInterval)
+	}
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	if opts.Adaptive {
+		if opts.MinInterval < s.minInterval || opts.MaxInterval > s.maxInterval {
+			return fmt.Errorf("adaptive interval bounds [%v, %v] exceed limits [%v, %v]", opts.MinInterval, opts.MaxInterval, s.minInterval, s.maxInterval)
+		}
+		if interval < opts.MinInterval || interval > opts.MaxInterval {
+			return fmt.Errorf("interval %v outside adaptive bounds [%v, %v]", interval, opts.MinInterval, opts.MaxInterval)
+		}
+	}
+
+	if !s.provider.IsSymbolSupported(symbol) {
+		return fmt.Errorf("symbol %s is not supported by provider %s", symbol, s.provider.Name())
+	}
+	return nil
+}
+
+// checkCapacity 检查新增 add、移除 remove 之后订阅数是否超过上限，已订阅的 add 不占用新名额
+func (s *DefaultSubscriber) checkCapacity(add, remove []string) error {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+
+	count := len(s.subscriptions)
+	for _, symbol := range remove {
+		if _, exists := s.subscriptions[symbol]; exists {
+			count--
+		}
+	}
+	for _, symbol := range add {
+		if _, exists := s.subscriptions[symbol]; !exists {
+			count++
+		}
+	}
+	if count > s.maxSubs {
+		return fmt.Errorf("maximum subscriptions (%d) exceeded: batch would result in %d subscriptions", s.maxSubs, count)
+	}
+	return nil
+}
+
+// lookup 返回股票订阅的副本
+func (s *DefaultSubscriber) lookup(symbol string) (Subscription, bool) {
+	s.subsMu.RLock()
+	defer s.subsMu.RUnlock()
+
+	sub, exists := s.subscriptions[symbol]
+	if !exists {
+		return Subscription{}, false
+	}
+	return *sub, true
+}
+
 func (s *DefaultSubscriber) Unsubscribe(symbol string) error {
 	s.subsMu.Lock()
 	defer s.subsMu.Unlock()
@@ -113,15 +212,11 @@ func (s *DefaultSubscriber) Unsubscribe(symbol string) error {
 	s.log.Infof("Removed subscription for %s", symbol)
 
 	// 发送取消订阅事件
-	select {
-	case s.eventChan <- UpdateEvent{
+	s.events.Publish(UpdateEvent{
 		Type:   EventTypeUnsubscribed,
 		Symbol: symbol,
 		Time:   time.Now(),
-	}:
-	default:
-		s.log.Infof("Warning: event channel full, dropping unsubscription event for %s", symbol)
-	}
+	})
 
 	return nil
 }
@@ -143,7 +238,7 @@ func (s *DefaultSubscriber) Stop() error {
 		s.cancel()
 	}
 	s.wg.Wait()
-	close(s.eventChan)
+	s.events.Close()
 	s.log.Infof("Stopped")
 	return nil
 }
@@ -183,9 +278,16 @@ func (s *DefaultSubscriber) SetProvider(provider provider.RealtimeStockProvider)
 	s.log.Infof("Provider changed to: %s", provider.Name())
 }
 
-// GetEventChannel 获取事件通道
+// Events 返回订阅器的事件总线，每个消费者通过 Subscribe 获得独立的缓冲通道
+func (s *DefaultSubscriber) Events() *EventBus {
+	return s.events
+}
+
+// GetEventChannel 获取默认消费者的事件通道，创建订阅器时注册，缓冲区满时丢弃最旧的事件
+//
+// Deprecated: 多个 goroutine 读取同一个通道会互相抢走事件，使用 Events().Subscribe 注册独立的消费者。
 func (s *DefaultSubscriber) GetEventChannel() <-chan UpdateEvent {
-	return s.eventChan
+	return s.defaultEvents
 }
 
 // SetMaxSubscriptions 设置最大订阅数
@@ -199,6 +301,138 @@ func (s *DefaultSubscriber) SetIntervalLimits(min, max time.Duration) {
 	s.maxInterval = max
 }
 
+// SetBackoffPolicy 设置失败退避策略：连续失败 threshold 次后轮询间隔按倍数放大，最大不超过 maxBackoff
+func (s *DefaultSubscriber) SetBackoffPolicy(threshold int, maxBackoff time.Duration) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	s.failureThreshold = threshold
+	s.maxBackoff = maxBackoff
+}
+
+// SetBatching 设置批量获取策略：下次获取时间落在 window 内的订阅合并到同一次请求，每次请求最多 maxBatchSize 个股票
+func (s *DefaultSubscriber) SetBatching(window time.Duration, maxBatchSize int) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if window < 0 {
+		window = 0
+	}
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultMaxBatchSize
+	}
+	s.coalesceWindow = window
+	s.maxBatchSize = maxBatchSize
+}
+
+// GetBatchStats 返回批量获取统计
+func (s *DefaultSubscriber) GetBatchStats() BatchStats {
+	stats := BatchStats{
+		ProviderCalls:  s.providerCalls.Load(),
+		SymbolsFetched: s.symbolsFetched.Load(),
+	}
+	stats.CallsSaved = stats.SymbolsFetched - stats.ProviderCalls
+	if stats.ProviderCalls > 0 {
+		stats.AverageBatchSize = float64(stats.SymbolsFetched) / float64(stats.ProviderCalls)
+	}
+	return stats
+}
+
+// ResetBackoff 重置所有订阅的失败计数和退避状态，并在下一个周期立即获取，返回被重置的退避订阅数
+func (s *DefaultSubscriber) ResetBackoff() int {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	reset := 0
+	for _, sub := range s.subscriptions {
+		if sub.InBackoff() {
+			reset++
+		}
+		sub.ConsecutiveErrors = 0
+		sub.BackoffInterval = 0
+		sub.lastFetch = time.Time{}
+	}
+	return reset
+}
+
+// dueSymbols 返回到达轮询时间的股票代码，并记录本次获取时间
+// 退避中的订阅按 BackoffInterval 轮询；下次获取时间落在合并窗口内的订阅提前并入本轮，
+// 提前获取时按计划时间而不是实际时间记录，保证平均轮询间隔不变
+func (s *DefaultSubscriber) dueSymbols(now time.Time) []string {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	var symbols []string
+	for symbol, sub := range s.subscriptions {
+		if !sub.Active {
+			continue
+		}
+		if sub.lastFetch.IsZero() {
+			symbols = append(symbols, symbol)
+			sub.lastFetch = now
+			continue
+		}
+
+		interval := sub.EffectiveInterval()
+		elapsed := now.Sub(sub.lastFetch)
+		switch {
+		case elapsed >= interval:
+			symbols = append(symbols, symbol)
+			sub.lastFetch = now
+		case elapsed+s.coalesceWindow >= interval:
+			symbols = append(symbols, symbol)
+			sub.lastFetch = sub.lastFetch.Add(interval)
+		}
+	}
+	return symbols
+}
+
+// splitBatches 按最大批量拆分股票列表
+func splitBatches(symbols []string, size int) [][]string {
+	if size <= 0 || len(symbols) <= size {
+		return [][]string{symbols}
+	}
+	batches := make([][]string, 0, (len(symbols)+size-1)/size)
+	for len(symbols) > size {
+		batches = append(batches, symbols[:size:size])
+		symbols = symbols[size:]
+	}
+	return append(batches, symbols)
+}
+
+// recordFailure 记录一次获取失败，连续失败达到阈值后放大轮询间隔并发出 EventTypeDegraded 事件（需要持有写锁）
+func (s *DefaultSubscriber) recordFailure(sub *Subscription, err error) {
+	sub.ConsecutiveErrors++
+	if s.failureThreshold <= 0 || sub.ConsecutiveErrors < s.failureThreshold {
+		return
+	}
+
+	next := sub.EffectiveInterval() * backoffMultiplier
+	if s.maxBackoff > 0 && next > s.maxBackoff {
+		next = s.maxBackoff
+	}
+	if next <= sub.BackoffInterval || next <= sub.pollInterval() {
+		return // 已达上限
+	}
+	sub.BackoffInterval = next
+	s.log.Warnf("Subscription %s failed %d times in a row, backing off to %v: %v", sub.Symbol, sub.ConsecutiveErrors, next, err)
+
+	s.events.Publish(UpdateEvent{
+		Type:     EventTypeDegraded,
+		Symbol:   sub.Symbol,
+		Error:    err,
+		Time:     time.Now(),
+		Interval: next,
+	})
+}
+
+// recordSuccess 获取成功后清除失败计数并恢复退避前的轮询间隔（需要持有写锁）
+func (s *DefaultSubscriber) recordSuccess(sub *Subscription) {
+	if sub.InBackoff() {
+		s.log.Infof("Subscription %s recovered, interval reset to %v", sub.Symbol, sub.pollInterval())
+	}
+	sub.ConsecutiveErrors = 0
+	sub.BackoffInterval = 0
+}
+
 // runSubscriptions 是订阅服务的核心运行方法，负责管理所有股票数据的订阅和更新
 func (s *DefaultSubscriber) runSubscriptions() {
 	// defer 关键字：确保函数退出时执行 s.wg.Done()
@@ -217,10 +451,6 @@ func (s *DefaultSubscriber) runSubscriptions() {
 	// 这是 Go 中资源管理的最佳实践
 	defer ticker.Stop()
 
-	// map 数据结构：记录每个股票代码的最后获取时间
-	// key: 股票代码(string), value: 最后获取时间(time.Time)
-	lastFetchTime := make(map[string]time.Time)
-
 	s.log.Infof("Starting main subscription loop with 1s ticker")
 
 	// 无限循环：这是 Go 中事件驱动编程的常见模式
@@ -243,48 +473,24 @@ func (s *DefaultSubscriber) runSubscriptions() {
 			s.log.Debugf("Ticker fired at %v", now.Format("15:04:05.000"))
 
 			// === 核心业务逻辑：检查哪些订阅需要更新数据 ===
-
-			// sync.RWMutex 读写锁：允许多个读操作并发，但写操作独占
-			// RLock() 获取读锁，用于安全地遍历 subscriptions map
-			s.subsMu.RLock()
-
-			// 声明切片存储需要获取数据的股票代码
-			// []string 是字符串切片，Go 中的动态数组
-			var symbolsToFetch []string
-
-			// range 遍历 map：for key, value := range map
-			// s.subscriptions 存储所有的订阅信息
-			for symbol, sub := range s.subscriptions {
-				// 检查订阅是否激活
-				if !sub.Active {
-					continue // 跳过未激活的订阅
-				}
-
-				// 检查是否需要获取新数据
-				lastFetch, exists := lastFetchTime[symbol]
-
-				// 条件判断：
-				// 1. 如果从未获取过数据 (!exists)
-				// 2. 或者距离上次获取的时间 >= 订阅间隔 (now.Sub(lastFetch) >= sub.Interval)
-				// 则需要获取新数据
-				if !exists || now.Sub(lastFetch) >= sub.Interval {
-					// append() 是 Go 内置函数，用于向切片添加元素
-					symbolsToFetch = append(symbolsToFetch, symbol)
-					// 更新最后获取时间 ??
-					lastFetchTime[symbol] = now
-				}
-			}
-			// RUnlock() 释放读锁，允许其他 goroutine 进行读写操作
-			s.subsMu.RUnlock()
+			// 从未获取过、或距离上次获取已超过有效间隔（退避中为退避间隔）的订阅需要获取新数据
+			symbolsToFetch := s.dueSymbols(now)
 
 			// 如果有需要获取数据的股票
 			if len(symbolsToFetch) > 0 {
 				s.log.Infof("Need to fetch data for symbols: %v", symbolsToFetch)
 
+				// 超过单次请求上限时拆分为多批
+				s.subsMu.RLock()
+				batchSize := s.maxBatchSize
+				s.subsMu.RUnlock()
+
 				// go 关键字：启动新的 goroutine（轻量级线程）
 				// 这是 Go 的核心特性 - 并发编程
 				// 异步执行数据获取，不阻塞主循环
-				go s.fetchAndNotify(symbolsToFetch)
+				for _, batch := range splitBatches(symbolsToFetch, batchSize) {
+					go s.fetchAndNotify(batch)
+				}
 			} else {
 				s.log.Infof("No symbols need updating at this time")
 			}
@@ -326,6 +532,8 @@ func (s *DefaultSubscriber) fetchAndNotify(symbols []string) {
 	// === 第二步：调用数据提供商 API 获取数据 ===
 	// 记录开始时间，用于计算 API 调用耗时（性能监控）
 	start := time.Now()
+	s.providerCalls.Add(1)
+	s.symbolsFetched.Add(int64(len(symbols)))
 
 	// 调用提供商的 FetchData 方法批量获取股票数据
 	// 这里是多态调用：s.provider 实现了 Provider 接口
@@ -346,6 +554,15 @@ func (s *DefaultSubscriber) fetchAndNotify(symbols []string) {
 		for _, symbol := range symbols {
 			s.notifyError(symbol, err)
 		}
+
+		// 记录每个订阅的连续失败次数，达到阈值后进入退避
+		s.subsMu.Lock()
+		for _, symbol := range symbols {
+			if sub, exists := s.subscriptions[symbol]; exists {
+				s.recordFailure(sub, err)
+			}
+		}
+		s.subsMu.Unlock()
 		return // 提前返回，不继续处理数据
 	}
 
@@ -364,9 +581,10 @@ func (s *DefaultSubscriber) fetchAndNotify(symbols []string) {
 	}
 
 	// === 第五步：分发数据给订阅者 ===
-	// 获取读锁，保护对 subscriptions map 的并发访问
-	// 这里只需要读锁因为我们只是读取订阅信息，不修改
-	s.subsMu.RLock()
+	// 获取写锁，保护对 subscriptions map 的并发访问
+	// 分发数据的同时需要更新每个订阅的失败计数和退避状态
+	s.subsMu.Lock()
+	now := time.Now()
 
 	// 遍历本次请求的所有股票代码
 	for _, symbol := range symbols {
@@ -380,79 +598,91 @@ func (s *DefaultSubscriber) fetchAndNotify(symbols []string) {
 				// 1. 回调函数执行时间长不会影响其他股票的处理
 				// 2. 回调函数中的 panic 不会影响当前 goroutine
 				// 3. 多个股票的回调可以并发执行，提高效率
-				go s.notifyCallback(sub, stockData)
+				s.recordSuccess(sub)
+				s.recordAdaptive(sub, stockData, now)
+				// OnChange 模式下数据未变化时跳过回调，仅发出 EventTypeSuppressed 事件维持健康状态
+				if sub.shouldDeliver(stockData, now) {
+					// 在锁内取出回调，重新订阅可能同时替换 sub.Callback
+					go s.notifyCallback(symbol, sub.Callback, stockData)
+				} else {
+					sub.SuppressedCount++
+					s.notifySuppressed(symbol, now)
+				}
 			} else {
 				// 数据缺失处理：API 返回成功但没有包含某个股票的数据
 				// 这种情况可能发生在：
 				// - 股票代码错误或已停牌
 				// - 数据提供商暂时无法获取该股票数据
 				// - API 响应格式异常
-				go s.notifyError(symbol, fmt.Errorf("no data received for symbol %s", symbol))
+				missingErr := fmt.Errorf("no data received for symbol %s", symbol)
+				go s.notifyError(symbol, missingErr)
+				s.recordFailure(sub, missingErr)
 			}
 		}
 		// 如果订阅不存在或已停用，则跳过该股票
 		// 这是正常情况，不需要记录错误
 	}
 
-	// 释放读锁，允许其他 goroutine 进行读写操作
-	s.subsMu.RUnlock()
+	// 释放写锁，允许其他 goroutine 进行读写操作
+	s.subsMu.Unlock()
 }
 
 // notifyCallback 通知回调函数
 // 功能：
-//   - 对订阅 sub 执行其回调函数 Callback，传入最新的数据 data。
+//   - 对标的 symbol 执行调用方在 subsMu 内取出的回调 callback，传入最新的数据 data。
 //   - 保证回调执行的健壮性：从 panic 中恢复并记录日志；如果回调返回 error，记录并通过事件通道发出错误事件。
-//   - 在回调完成后，尝试通过事件通道发出一条数据更新事件（非阻塞发送，通道满则丢弃以避免阻塞业务线程）。
+//   - 在回调完成后，通过事件总线发出一条数据更新事件（非阻塞发送，消费者缓冲区满时丢弃其最旧的事件）。
 //
 // 设计要点：
 //  1. panic 恢复：任何第三方/业务回调都可能产生 panic；使用 defer + recover 保证不会影响订阅循环或其他 goroutine。
 //  2. 错误分流：回调返回的 error 会被转化为 EventTypeError 事件发送（非阻塞），便于统一上报与监控。
-//  3. 事件发送策略：采用 select 非阻塞写入 eventChan。若通道已满，优先保证主流程不卡顿，因此静默丢弃。
-//     如需“必达”语义，应在上层增加更大的缓冲/专用事件处理器/或重试与丢弃统计。
+//  3. 事件发送策略：EventBus.Publish 不会阻塞。消费者缓冲区已满时丢弃该消费者最旧的事件并计入 Dropped，
+//     优先保证主流程不卡顿；丢弃数量可通过 Manager.GetStatistics().EventBus 观测。
 //  4. 时序说明：本方法通常在独立 goroutine 中调用（见 fetchAndNotify 中的 go s.notifyCallback），
-//     因此内部不得产生长时间阻塞操作（例如：同步写满通道）。
-func (s *DefaultSubscriber) notifyCallback(sub *Subscription, data core.StockData) {
+//     因此内部不得产生长时间阻塞操作（例如：同步写满通道），也不得在锁外读取 Subscription 的字段。
+func (s *DefaultSubscriber) notifyCallback(symbol string, callback CallbackFunc, data core.StockData) {
 	// 1) 保护区：确保回调产生的任何 panic 不会蔓延至系统其他部分
 	defer func() {
 		if r := recover(); r != nil {
 			// 这里使用 Infof 记录；若需更高告警等级，可在日志配置中调整
-			s.log.Infof("Callback panic for %s: %v", sub.Symbol, r)
+			s.log.Infof("Callback panic for %s: %v", symbol, r)
 		}
 	}()
 
 	// 2) 执行业务回调：回调若返回错误，既记录日志也发出错误事件，便于上层统一感知
 	// 注意：这里不对错误进行重试，由上层策略（如 Manager）或回调方自行决定
-	if err := sub.Callback(data); err != nil {
-		s.log.Infof("Callback error for %s: %v", sub.Symbol, err)
-		// 非阻塞错误通知：若通道满则丢弃，避免阻塞当前 goroutine
-		s.notifyError(sub.Symbol, err)
+	if err := callback(data); err != nil {
+		s.log.Infof("Callback error for %s: %v", symbol, err)
+		// 非阻塞错误通知，避免阻塞当前 goroutine
+		s.notifyError(symbol, err)
 	}
 
 	// 3) 发送数据更新事件：
 	//    - 无论回调是否返回错误，都会尝试发送数据事件（便于消费者同时获得数据与错误上下文）
-	//    - 非阻塞发送：当 eventChan 已满时直接丢弃，保障系统整体吞吐不被背压影响
-	select {
-	case s.eventChan <- UpdateEvent{
+	//    - 非阻塞发送：读取缓慢的消费者只会丢失自己最旧的事件，不会形成背压
+	s.events.Publish(UpdateEvent{
 		Type:   EventTypeData, // 事件类型：数据更新
-		Symbol: sub.Symbol,    // 标的代码
+		Symbol: symbol,        // 标的代码
 		Data:   &data,         // 本次推送的数据（指针，避免大对象复制）
 		Time:   time.Now(),    // 事件时间戳（用于下游统计/排序）
-	}:
-	default:
-		// 事件通道满，丢弃事件（如需观测丢弃比例，可在此处增加计数器或 debug 日志）
-	}
+	})
+}
+
+// notifySuppressed 通知本次数据因未变化而未推送
+func (s *DefaultSubscriber) notifySuppressed(symbol string, now time.Time) {
+	s.events.Publish(UpdateEvent{
+		Type:   EventTypeSuppressed,
+		Symbol: symbol,
+		Time:   now,
+	})
 }
 
 // notifyError 通知错误
 func (s *DefaultSubscriber) notifyError(symbol string, err error) {
-	select {
-	case s.eventChan <- UpdateEvent{
+	s.events.Publish(UpdateEvent{
 		Type:   EventTypeError,
 		Symbol: symbol,
 		Error:  err,
 		Time:   time.Now(),
-	}:
-	default:
-		// 事件通道满，丢弃事件
-	}
+	})
 }
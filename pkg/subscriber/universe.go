@@ -0,0 +1,396 @@
+package subscriber
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// UniverseResolver 股票池成员解析器
+// 根据股票池名称（如 "csi300_sample"）解析出当前的成员股票代码
+type UniverseResolver interface {
+	// Resolve 返回股票池当前的成员列表
+	Resolve(ctx context.Context, name string) ([]string, error)
+}
+
+// UniverseStats 单个股票池订阅统计
+type UniverseStats struct {
+	Name          string        `json:"name"`
+	Interval      time.Duration `json:"interval"`
+	MemberCount   int           `json:"member_count"`
+	AddedCount    int64         `json:"added_count"`
+	RemovedCount  int64         `json:"removed_count"`
+	LastRefresh   time.Time     `json:"last_refresh"`
+	LastError     string        `json:"last_error,omitempty"`
+	LastErrorTime time.Time     `json:"last_error_time,omitempty"`
+}
+
+// universeSubscription 股票池订阅信息
+type universeSubscription struct {
+	name     string
+	interval time.Duration
+	callback CallbackFunc
+	members  map[string]struct{}
+}
+
+// StaticUniverseResolver 基于静态配置的股票池解析器
+// 成员可以在运行时通过 Set 更新，适用于配置文件驱动的股票池和测试
+type StaticUniverseResolver struct {
+	mu        sync.RWMutex
+	universes map[string][]string
+}
+
+// NewStaticUniverseResolver 创建静态股票池解析器
+func NewStaticUniverseResolver(universes map[string][]string) *StaticUniverseResolver {
+	r := &StaticUniverseResolver{universes: make(map[string][]string)}
+	for name, members := range universes {
+		r.Set(name, members)
+	}
+	return r
+}
+
+// Set 设置股票池成员
+func (r *StaticUniverseResolver) Set(name string, members []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.universes[name] = append([]string(nil), members...)
+}
+
+// Resolve 实现 UniverseResolver 接口
+func (r *StaticUniverseResolver) Resolve(ctx context.Context, name string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members, ok := r.universes[name]
+	if !ok {
+		return nil, fmt.Errorf("universe %s not found", name)
+	}
+	return append([]string(nil), members...), nil
+}
+
+// RedisUniverseResolver 基于 Redis Set 的股票池解析器
+// 每个股票池对应一个 Set，键为 keyPrefix + name，例如 "universe:csi300_sample"
+type RedisUniverseResolver struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+// NewRedisUniverseResolver 创建 Redis 股票池解析器，keyPrefix 为空时使用 "universe:"
+func NewRedisUniverseResolver(client *redis.Client, keyPrefix string) *RedisUniverseResolver {
+	if keyPrefix == "" {
+		keyPrefix = "universe:"
+	}
+	return &RedisUniverseResolver{client: client, keyPrefix: keyPrefix}
+}
+
+// Resolve 实现 UniverseResolver 接口
+func (r *RedisUniverseResolver) Resolve(ctx context.Context, name string) ([]string, error) {
+	key := r.keyPrefix + name
+	members, err := r.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve universe %s from %s: %w", name, key, err)
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// SetUniverseResolver 设置股票池解析器
+func (m *Manager) SetUniverseResolver(resolver UniverseResolver) {
+	m.universeMu.Lock()
+	defer m.universeMu.Unlock()
+	m.universeResolver = resolver
+}
+
+// SubscribeUniverse 订阅股票池中的全部股票
+// 订阅后会定期重新解析成员：新增成员自动订阅，移除成员自动取消订阅。
+// 同一股票属于多个股票池时使用最短的订阅间隔，回调按股票池依次触发。
+// 已通过 Subscribe 直接订阅的股票保持直接订阅的间隔和回调，股票池不接管也不取消它。
+func (m *Manager) SubscribeUniverse(name string, interval time.Duration, callback CallbackFunc) error {
+	if name == "" {
+		return fmt.Errorf("universe name cannot be empty")
+	}
+	if callback == nil {
+		return fmt.Errorf("callback cannot be nil")
+	}
+	if interval < m.subscriber.minInterval || interval > m.subscriber.maxInterval {
+		return fmt.Errorf("interval %v out of range [%v, %v]", interval, m.subscriber.minInterval, m.subscriber.maxInterval)
+	}
+
+	m.universeMu.Lock()
+	defer m.universeMu.Unlock()
+
+	if m.universeResolver == nil {
+		return fmt.Errorf("universe resolver not configured")
+	}
+	if _, exists := m.universes[name]; exists {
+		return fmt.Errorf("universe %s already subscribed", name)
+	}
+
+	members, err := m.universeResolver.Resolve(context.Background(), name)
+	if err != nil {
+		return err
+	}
+
+	m.universes[name] = &universeSubscription{
+		name:     name,
+		interval: interval,
+		callback: callback,
+		members:  make(map[string]struct{}),
+	}
+	m.statsMu.Lock()
+	m.stats.UniverseStats[name] = &UniverseStats{Name: name, Interval: interval}
+	m.statsMu.Unlock()
+
+	m.applyUniverseMembers(name, members)
+
+	log.Printf("[Manager] Subscribed to universe %s with %d members, interval %v", name, len(members), interval)
+	return nil
+}
+
+// UnsubscribeUniverse 取消股票池订阅
+// 仅被该股票池持有的股票会被取消订阅，其余股票按剩余股票池重新计算间隔
+func (m *Manager) UnsubscribeUniverse(name string) error {
+	m.universeMu.Lock()
+	defer m.universeMu.Unlock()
+
+	if _, exists := m.universes[name]; !exists {
+		return fmt.Errorf("universe %s not subscribed", name)
+	}
+
+	m.applyUniverseMembers(name, nil)
+	delete(m.universes, name)
+
+	m.statsMu.Lock()
+	delete(m.stats.UniverseStats, name)
+	m.statsMu.Unlock()
+
+	log.Printf("[Manager] Unsubscribed from universe %s", name)
+	return nil
+}
+
+// RefreshUniverses 重新解析全部已订阅股票池的成员并同步订阅集合
+func (m *Manager) RefreshUniverses(ctx context.Context) error {
+	m.universeMu.Lock()
+	defer m.universeMu.Unlock()
+
+	if m.universeResolver == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range m.sortedUniverseNames() {
+		members, err := m.universeResolver.Resolve(ctx, name)
+		if err != nil {
+			// 解析失败时保留原有成员，避免因临时故障取消全部订阅
+			m.statsMu.Lock()
+			if us, ok := m.stats.UniverseStats[name]; ok {
+				us.LastError = err.Error()
+				us.LastErrorTime = time.Now()
+			}
+			m.statsMu.Unlock()
+			errs = append(errs, err)
+			continue
+		}
+		m.applyUniverseMembers(name, members)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("universe refresh failed: %v", errs)
+	}
+	return nil
+}
+
+// applyUniverseMembers 将股票池成员更新为 members，并同步底层订阅（调用方需持有 universeMu）
+func (m *Manager) applyUniverseMembers(name string, members []string) {
+	us := m.universes[name]
+
+	next := make(map[string]struct{}, len(members))
+	for _, symbol := range members {
+		if symbol != "" {
+			next[symbol] = struct{}{}
+		}
+	}
+
+	var added, removed []string
+	for symbol := range next {
+		if _, ok := us.members[symbol]; !ok {
+			added = append(added, symbol)
+		}
+	}
+	for symbol := range us.members {
+		if _, ok := next[symbol]; !ok {
+			removed = append(removed, symbol)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	var addedCount, removedCount int64
+	for _, symbol := range removed {
+		delete(us.members, symbol)
+		if m.syncUniverseSymbol(symbol) {
+			removedCount++
+		}
+	}
+	for _, symbol := range added {
+		us.members[symbol] = struct{}{}
+		if m.syncUniverseSymbol(symbol) {
+			addedCount++
+		} else {
+			delete(us.members, symbol)
+		}
+	}
+
+	m.statsMu.Lock()
+	if stats, ok := m.stats.UniverseStats[name]; ok {
+		stats.MemberCount = len(us.members)
+		stats.AddedCount += addedCount
+		stats.RemovedCount += removedCount
+		stats.LastRefresh = time.Now()
+		stats.LastError = ""
+	}
+	m.statsMu.Unlock()
+
+	if len(added) > 0 || len(removed) > 0 {
+		log.Printf("[Manager] Universe %s membership changed: +%v -%v", name, added, removed)
+	}
+}
+
+// syncUniverseSymbol 根据持有该股票的股票池重新订阅或取消订阅（调用方需持有 universeMu）
+// 直接订阅的股票（m.records 中的股票）以直接订阅为准，股票池不会覆盖其回调，也不会取消它；
+// 生效间隔未变化时不重新订阅，避免重置订阅统计。返回值表示底层订阅操作是否成功
+func (m *Manager) syncUniverseSymbol(symbol string) bool {
+	var owners []string
+	var interval time.Duration
+	for _, name := range m.sortedUniverseNames() {
+		us := m.universes[name]
+		if _, ok := us.members[symbol]; !ok {
+			continue
+		}
+		owners = append(owners, name)
+		if interval == 0 || us.interval < interval {
+			interval = us.interval
+		}
+	}
+
+	if m.isDirectSubscription(symbol) {
+		delete(m.universeOwned, symbol)
+		if len(owners) > 0 {
+			log.Printf("[Manager] Universe member %s is directly subscribed, keeping the direct subscription", symbol)
+		}
+		return true
+	}
+
+	current, owned := m.universeOwned[symbol]
+	if len(owners) == 0 {
+		if !owned {
+			return true
+		}
+		if err := m.unsubscribe(symbol); err != nil {
+			log.Printf("[Manager] Failed to unsubscribe universe member %s: %v", symbol, err)
+			return false
+		}
+		delete(m.universeOwned, symbol)
+		return true
+	}
+
+	if len(owners) > 1 {
+		log.Printf("[Manager] Symbol %s belongs to universes %v, using shortest interval %v", symbol, owners, interval)
+	}
+	if owned && current == interval {
+		// 回调按当前成员分发，间隔不变时无需更新底层订阅
+		return true
+	}
+
+	if err := m.subscribe(symbol, interval, m.universeCallback(symbol), DeliveryOptions{}); err != nil {
+		log.Printf("[Manager] Failed to subscribe universe member %s: %v", symbol, err)
+		return false
+	}
+	m.universeOwned[symbol] = interval
+	return true
+}
+
+// isDirectSubscription 股票是否通过 Subscribe 系列方法直接订阅
+func (m *Manager) isDirectSubscription(symbol string) bool {
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+	_, ok := m.records[symbol]
+	return ok
+}
+
+// resyncUniverseMember 底层订阅被取消后，股票仍属于某个股票池时改由股票池重新订阅
+func (m *Manager) resyncUniverseMember(symbol string) {
+	m.universeMu.Lock()
+	defer m.universeMu.Unlock()
+	delete(m.universeOwned, symbol)
+	for _, us := range m.universes {
+		if _, ok := us.members[symbol]; ok {
+			m.syncUniverseSymbol(symbol)
+			return
+		}
+	}
+}
+
+// universeCallback 构造股票池成员的回调，将数据分发给所有持有该股票的股票池
+func (m *Manager) universeCallback(symbol string) CallbackFunc {
+	return func(data core.StockData) error {
+		m.universeMu.RLock()
+		var callbacks []CallbackFunc
+		for _, name := range m.sortedUniverseNames() {
+			us := m.universes[name]
+			if _, ok := us.members[symbol]; ok {
+				callbacks = append(callbacks, us.callback)
+			}
+		}
+		m.universeMu.RUnlock()
+
+		var errs []error
+		for _, cb := range callbacks {
+			if err := cb(data); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("universe callback failed for %s: %v", symbol, errs)
+		}
+		return nil
+	}
+}
+
+// sortedUniverseNames 返回排序后的股票池名称（调用方需持有 universeMu）
+func (m *Manager) sortedUniverseNames() []string {
+	names := make([]string, 0, len(m.universes))
+	for name := range m.universes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runUniverseWatcher 定期重新解析股票池成员
+func (m *Manager) runUniverseWatcher(ctx context.Context) {
+	if m.config.UniverseRefreshInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(m.config.UniverseRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.RefreshUniverses(ctx); err != nil {
+				log.Printf("[Manager] %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package subscriber
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// fakeStockProvider 测试用实时数据提供商，支持所有股票代码
+type fakeStockProvider struct{}
+
+func (p *fakeStockProvider) Name() string                { return "fake" }
+func (p *fakeStockProvider) IsHealthy() bool             { return true }
+func (p *fakeStockProvider) GetRateLimit() time.Duration { return 0 }
+func (p *fakeStockProvider) IsSymbolSupported(symbol string) bool {
+	return symbol != ""
+}
+
+func (p *fakeStockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	data := make([]core.StockData, 0, len(symbols))
+	for _, symbol := range symbols {
+		data = append(data, core.StockData{Symbol: symbol, Price: 10, Timestamp: time.Now()})
+	}
+	return data, nil
+}
+
+func (p *fakeStockProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := p.FetchStockData(ctx, symbols)
+	return data, "", err
+}
+
+func newUniverseTestManager(universes map[string][]string) (*Manager, *StaticUniverseResolver) {
+	resolver := NewStaticUniverseResolver(universes)
+	manager := NewManager(NewSubscriber(&fakeStockProvider{}))
+	manager.SetUniverseResolver(resolver)
+	return manager, resolver
+}
+
+func subscribedIntervals(m *Manager) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+	for _, sub := range m.GetSubscriptions() {
+		result[sub.Symbol] = sub.Interval
+	}
+	return result
+}
+
+func subscribedSymbols(m *Manager) []string {
+	var symbols []string
+	for symbol := range subscribedIntervals(m) {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// drainEvents 读取事件通道中当前已缓冲的全部事件
+func drainEvents(m *Manager) []UpdateEvent {
+	var events []UpdateEvent
+	ch := m.subscriber.GetEventChannel()
+	for {
+		select {
+		case ev := <-ch:
+			events = append(events, ev)
+		default:
+			return events
+		}
+	}
+}
+
+func noopCallback(data core.StockData) error { return nil }
+
+func TestManager_SubscribeUniverse_ConvergesOnMembershipChange(t *testing.T) {
+	manager, resolver := newUniverseTestManager(map[string][]string{
+		"csi300_sample": {"600000", "000001", "600519"},
+	})
+
+	require.NoError(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, noopCallback))
+	assert.Equal(t, []string{"000001", "600000", "600519"}, subscribedSymbols(manager))
+	drainEvents(manager)
+
+	resolver.Set("csi300_sample", []string{"600000", "600519", "300750"})
+	require.NoError(t, manager.RefreshUniverses(context.Background()))
+
+	assert.Equal(t, []string{"300750", "600000", "600519"}, subscribedSymbols(manager))
+
+	var subscribed, unsubscribed []string
+	for _, ev := range drainEvents(manager) {
+		switch ev.Type {
+		case EventTypeSubscribed:
+			subscribed = append(subscribed, ev.Symbol)
+		case EventTypeUnsubscribed:
+			unsubscribed = append(unsubscribed, ev.Symbol)
+		}
+	}
+	assert.Equal(t, []string{"300750"}, subscribed)
+	assert.Equal(t, []string{"000001"}, unsubscribed)
+
+	stats := manager.GetStatistics()
+	us, ok := stats.UniverseStats["csi300_sample"]
+	require.True(t, ok)
+	assert.Equal(t, 3, us.MemberCount)
+	assert.Equal(t, int64(4), us.AddedCount)
+	assert.Equal(t, int64(1), us.RemovedCount)
+}
+
+func TestManager_SubscribeUniverse_ShortestIntervalWins(t *testing.T) {
+	manager, resolver := newUniverseTestManager(map[string][]string{
+		"slow": {"600000", "000001"},
+		"fast": {"600000"},
+	})
+
+	require.NoError(t, manager.SubscribeUniverse("slow", 10*time.Second, noopCallback))
+	require.NoError(t, manager.SubscribeUniverse("fast", 2*time.Second, noopCallback))
+
+	intervals := subscribedIntervals(manager)
+	assert.Equal(t, 2*time.Second, intervals["600000"])
+	assert.Equal(t, 10*time.Second, intervals["000001"])
+
+	// 从快速股票池移除后应回退到剩余股票池的间隔
+	resolver.Set("fast", nil)
+	require.NoError(t, manager.RefreshUniverses(context.Background()))
+
+	intervals = subscribedIntervals(manager)
+	assert.Equal(t, 10*time.Second, intervals["600000"])
+	assert.Len(t, intervals, 2)
+}
+
+func TestManager_SubscribeUniverse_CallbackFanOut(t *testing.T) {
+	manager, _ := newUniverseTestManager(map[string][]string{
+		"a": {"600000"},
+		"b": {"600000"},
+	})
+
+	var calls []string
+	require.NoError(t, manager.SubscribeUniverse("a", 5*time.Second, func(data core.StockData) error {
+		calls = append(calls, "a:"+data.Symbol)
+		return nil
+	}))
+	require.NoError(t, manager.SubscribeUniverse("b", 5*time.Second, func(data core.StockData) error {
+		calls = append(calls, "b:"+data.Symbol)
+		return nil
+	}))
+
+	subs := manager.GetSubscriptions()
+	require.Len(t, subs, 1)
+	require.NoError(t, subs[0].Callback(core.StockData{Symbol: "600000"}))
+	assert.Equal(t, []string{"a:600000", "b:600000"}, calls)
+}
+
+func TestManager_UnsubscribeUniverse_KeepsSharedMembers(t *testing.T) {
+	manager, _ := newUniverseTestManager(map[string][]string{
+		"a": {"600000", "000001"},
+		"b": {"600000"},
+	})
+
+	require.NoError(t, manager.SubscribeUniverse("a", 5*time.Second, noopCallback))
+	require.NoError(t, manager.SubscribeUniverse("b", 5*time.Second, noopCallback))
+	require.NoError(t, manager.UnsubscribeUniverse("a"))
+
+	assert.Equal(t, []string{"600000"}, subscribedSymbols(manager))
+	_, ok := manager.GetStatistics().UniverseStats["a"]
+	assert.False(t, ok)
+}
+
+func TestManager_SubscribeUniverse_Errors(t *testing.T) {
+	manager := NewManager(NewSubscriber(&fakeStockProvider{}))
+	assert.Error(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, noopCallback), "未配置解析器")
+
+	manager, _ = newUniverseTestManager(map[string][]string{"csi300_sample": {"600000"}})
+	assert.Error(t, manager.SubscribeUniverse("unknown", 5*time.Second, noopCallback))
+	assert.Error(t, manager.SubscribeUniverse("csi300_sample", 0, noopCallback))
+	assert.Error(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, nil))
+
+	require.NoError(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, noopCallback))
+	assert.Error(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, noopCallback), "重复订阅")
+}
+
+func TestManager_SubscribeUniverse_KeepsDirectSubscription(t *testing.T) {
+	manager, resolver := newUniverseTestManager(map[string][]string{
+		"csi300_sample": {"600000", "000001"},
+	})
+
+	var direct []string
+	require.NoError(t, manager.Subscribe("600000", 2*time.Second, func(data core.StockData) error {
+		direct = append(direct, data.Symbol)
+		return nil
+	}))
+	require.NoError(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, noopCallback))
+
+	intervals := subscribedIntervals(manager)
+	assert.Equal(t, 2*time.Second, intervals["600000"], "股票池不覆盖直接订阅的间隔")
+	assert.Equal(t, 5*time.Second, intervals["000001"])
+	sub, ok := manager.subscriber.lookup("600000")
+	require.True(t, ok)
+	require.NoError(t, sub.Callback(core.StockData{Symbol: "600000"}))
+	assert.Equal(t, []string{"600000"}, direct, "股票池不覆盖直接订阅的回调")
+
+	// 重新计算成员但间隔不变时不重新订阅，订阅统计保持不变
+	before := manager.GetStatistics().SubscriptionStats["000001"].SubscribedAt
+	resolver.Set("csi300_sample", []string{"600000", "000001", "600519"})
+	require.NoError(t, manager.RefreshUniverses(context.Background()))
+	assert.Equal(t, before, manager.GetStatistics().SubscriptionStats["000001"].SubscribedAt)
+
+	// 股票池移除成员时不取消直接订阅
+	resolver.Set("csi300_sample", []string{"000001"})
+	require.NoError(t, manager.RefreshUniverses(context.Background()))
+	assert.Equal(t, []string{"000001", "600000"}, subscribedSymbols(manager))
+	assert.Equal(t, 2*time.Second, subscribedIntervals(manager)["600000"])
+
+	require.NoError(t, manager.UnsubscribeUniverse("csi300_sample"))
+	assert.Equal(t, []string{"600000"}, subscribedSymbols(manager))
+
+	// 取消直接订阅后，仍属于股票池的股票改由股票池订阅
+	require.NoError(t, manager.SubscribeUniverse("csi300_sample", 5*time.Second, noopCallback))
+	resolver.Set("csi300_sample", []string{"000001", "600000"})
+	require.NoError(t, manager.RefreshUniverses(context.Background()))
+	require.NoError(t, manager.Unsubscribe("600000"))
+	assert.Equal(t, 5*time.Second, subscribedIntervals(manager)["600000"])
+}
@@ -64,6 +64,9 @@ type ProviderConfig struct {
 	RateLimitQPS    int               `json:"rate_limit_qps" yaml:"rate_limit_qps"`     // 每秒请求速率限制 (QPS)。
 	UserAgent       string            `json:"user_agent" yaml:"user_agent"`             // 发起HTTP请求时使用的User-Agent。
 	Headers         map[string]string `json:"headers" yaml:"headers"`                   // 附加到HTTP请求中的自定义头部。
+	Mode            string            `json:"mode" yaml:"mode"`                         // 数据来源模式：live（直接请求）、record（请求并录制 fixture）、replay（只回放 fixture）。
+	FixtureDir      string            `json:"fixture_dir" yaml:"fixture_dir"`           // record 和 replay 模式下的 fixture 目录。
+	FallThrough     bool              `json:"fall_through" yaml:"fall_through"`         // replay 模式下没有匹配的 fixture 时是否改为真实请求。
 }
 
 // PerformanceConfig 定义了与性能相关的配置。
@@ -160,6 +163,8 @@ func DefaultConfig() *Config {
 			RateLimitQPS:    100,
 			UserAgent:       "stocksub-testkit/1.0",
 			Headers:         make(map[string]string),
+			Mode:            "live",
+			FixtureDir:      "./testdata/fixtures",
 		},
 		Performance: PerformanceConfig{
 			WorkerCount:     2,                 // 测试环境：2个工作协程（原4）
@@ -310,6 +315,12 @@ func (c *Config) Validate() error {
 	if c.Provider.RateLimitQPS < 0 {
 		return fmt.Errorf("provider rate_limit_qps cannot be negative")
 	}
+	if c.Provider.Mode != "" && !isValidProviderMode(c.Provider.Mode) {
+		return fmt.Errorf("invalid provider mode: %s, must be one of: live, record, replay", c.Provider.Mode)
+	}
+	if (c.Provider.Mode == "record" || c.Provider.Mode == "replay") && c.Provider.FixtureDir == "" {
+		return fmt.Errorf("provider fixture_dir is required in %s mode", c.Provider.Mode)
+	}
 
 	// 验证性能配置
 	if c.Performance.WorkerCount <= 0 {
@@ -365,6 +376,11 @@ func isValidProviderType(t string) bool {
 	return validTypes[t]
 }
 
+func isValidProviderMode(mode string) bool {
+	validModes := map[string]bool{"live": true, "record": true, "replay": true}
+	return validModes[mode]
+}
+
 func isValidLogLevel(level string) bool {
 	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	return validLevels[level]
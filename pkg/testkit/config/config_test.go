@@ -105,6 +105,19 @@ func TestConfigValidation(t *testing.T) {
 	if err := invalidConfig.Validate(); err == nil {
 		t.Error("ErrorRate out of range should fail validation")
 	}
+
+	// 测试Provider模式验证
+	invalidConfig = config.Clone()
+	invalidConfig.Provider.Mode = "invalid"
+	if err := invalidConfig.Validate(); err == nil {
+		t.Error("Invalid provider mode should fail validation")
+	}
+	invalidConfig = config.Clone()
+	invalidConfig.Provider.Mode = "replay"
+	invalidConfig.Provider.FixtureDir = ""
+	if err := invalidConfig.Validate(); err == nil {
+		t.Error("Replay mode without fixture_dir should fail validation")
+	}
 }
 
 func TestConfigClone(t *testing.T) {
@@ -8,6 +8,8 @@ import (
 
 	"stocksub/pkg/cache"
 	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+	"stocksub/pkg/provider/tencent"
 	"stocksub/pkg/storage"
 	"stocksub/pkg/testkit"
 	"stocksub/pkg/testkit/config"
@@ -84,9 +86,7 @@ func NewTestDataManager(cfg *config.Config) testkit.TestDataManager {
 	}
 
 	// 创建Provider层
-	providerFactory := providers.NewProviderFactory(cacheLayer)
-	cachedProviderConfig := providers.DefaultCachedProviderConfig()
-	providerLayer := providerFactory.CreateCachedProvider(cachedProviderConfig)
+	providerLayer := newProviderLayer(cfg, cacheLayer)
 
 	return &testDataManager{
 		config:       cfg,
@@ -99,6 +99,53 @@ func NewTestDataManager(cfg *config.Config) testkit.TestDataManager {
 	}
 }
 
+// newProviderLayer 根据 cfg.Provider.Mode 创建Provider层。
+// record 和 replay 模式下由 RecordingProvider 录制或回放腾讯接口的响应，且不回退到Mock数据，
+// 这样 fixture 缺失时测试会直接失败，而不是悄悄拿到随机数据。
+func newProviderLayer(cfg *config.Config, cacheLayer cache.Cache) *providers.CachedProvider {
+	cachedProviderConfig := providers.DefaultCachedProviderConfig()
+	mode := cfg.Provider.Mode
+	if mode == "" || mode == providers.ModeLive {
+		return providers.NewProviderFactory(cacheLayer).CreateCachedProvider(cachedProviderConfig)
+	}
+
+	cachedProviderConfig.EnableMockFallback = false
+	var inner provider.RealtimeStockProvider
+	if mode == providers.ModeRecord || cfg.Provider.FallThrough {
+		inner = tencent.NewClient()
+	}
+	if mode == providers.ModeReplay {
+		// 回放结果是确定的，重试没有意义
+		cachedProviderConfig.MaxRetries = 0
+	}
+
+	var realProvider providers.RealProvider
+	recording, err := providers.NewRecordingProvider(inner, providers.RecordingConfig{
+		Mode:        mode,
+		Dir:         cfg.Provider.FixtureDir,
+		FallThrough: cfg.Provider.FallThrough,
+	})
+	if err != nil {
+		realProvider = failedProvider{err: err}
+	} else {
+		realProvider = recording
+	}
+	return providers.NewCachedProvider(realProvider, cacheLayer, cachedProviderConfig)
+}
+
+// failedProvider 在 RecordingProvider 无法创建（如 fixture 目录不存在）时使用，每次请求都返回创建时的错误。
+type failedProvider struct {
+	err error
+}
+
+func (p failedProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	return nil, p.err
+}
+
+func (p failedProvider) Close() error {
+	return nil
+}
+
 // GetStockData 实现了 testkit.TestDataManager 接口的 GetStockData 方法。
 func (tdm *testDataManager) GetStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
 	startTime := time.Now()
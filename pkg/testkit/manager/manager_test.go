@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/testkit/config"
+	"stocksub/pkg/testkit/providers"
+)
+
+// writeFixture 写入一个回放用的 fixture
+func writeFixture(t *testing.T, dir string, symbols []string, data []core.StockData) {
+	t.Helper()
+	content, err := json.Marshal(providers.Fixture{
+		Version:  providers.FixtureVersion,
+		Provider: "tencent",
+		Symbols:  symbols,
+		Data:     data,
+	})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, symbols[0]+".json"), content, 0o644))
+}
+
+func replayConfig(dir string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Storage.Type = "memory"
+	cfg.Provider.Mode = providers.ModeReplay
+	cfg.Provider.FixtureDir = dir
+	return cfg
+}
+
+func TestTestDataManager_ReplayMode(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, []string{"600000"}, []core.StockData{{Symbol: "600000", Name: "浦发银行", Price: 8.5}})
+
+	tdm := NewTestDataManager(replayConfig(dir))
+	defer tdm.Close()
+
+	ctx := context.Background()
+	data, err := tdm.GetStockData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	require.Len(t, data, 1)
+	assert.Equal(t, "浦发银行", data[0].Name)
+	assert.Equal(t, 8.5, data[0].Price)
+
+	_, err = tdm.GetStockData(ctx, []string{"000001"})
+	assert.ErrorIs(t, err, providers.ErrFixtureNotFound, "回放未命中时不回退到Mock数据")
+}
+
+func TestTestDataManager_ReplayModeMissingFixtureDir(t *testing.T) {
+	tdm := NewTestDataManager(replayConfig(filepath.Join(t.TempDir(), "missing")))
+	defer tdm.Close()
+
+	_, err := tdm.GetStockData(context.Background(), []string{"600000"})
+	assert.ErrorContains(t, err, "fixture directory")
+}
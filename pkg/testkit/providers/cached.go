@@ -12,9 +12,15 @@ import (
 	"stocksub/pkg/testkit"
 )
 
+// RealProvider CachedProvider 背后的真实数据源，如 TencentProviderWrapper 或 RecordingProvider
+type RealProvider interface {
+	FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error)
+	Close() error
+}
+
 // CachedProvider 缓存包装的Provider
 type CachedProvider struct {
-	realProvider *TencentProviderWrapper
+	realProvider RealProvider
 	mockProvider *MockProvider
 	cache        cache.Cache
 	mu           sync.RWMutex
@@ -45,7 +51,7 @@ type CachedProviderStats struct {
 }
 
 // NewCachedProvider 创建缓存Provider
-func NewCachedProvider(realProvider *TencentProviderWrapper, cache cache.Cache, config CachedProviderConfig) *CachedProvider {
+func NewCachedProvider(realProvider RealProvider, cache cache.Cache, config CachedProviderConfig) *CachedProvider {
 	// 创建Mock Provider
 	mockConfig := DefaultMockProviderConfig()
 	mockProvider := NewMockProvider(mockConfig)
@@ -23,6 +23,10 @@ type MockProvider struct {
 	config       MockProviderConfig
 	stats        MockProviderStats
 	generator    *DataGenerator
+	scripts      map[string]*script        // 按股票代码的脚本场景，"*" 用于没有单独脚本的股票
+	drifted      map[string]core.StockData // 脚本场景中每只股票按价格变动累积后的数据
+	frozen       map[string]core.StockData // 冻结模式下每只股票首次返回的数据
+	rand         *rand.Rand                // 错误注入和随机延迟使用的随机源，受 mu 保护
 }
 
 // MockProviderConfig Mock Provider配置
@@ -34,6 +38,10 @@ type MockProviderConfig struct {
 	MaxRandomDelay  time.Duration `yaml:"max_random_delay"` // 最大随机延迟
 	EnableDataGen   bool          `yaml:"enable_data_gen"`  // 是否启用数据生成
 	DataGenConfig   DataGenConfig `yaml:"data_gen_config"`  // 数据生成配置
+
+	ErrorRate         float64 `yaml:"error_rate"`         // 每次调用返回 ErrInjected 的概率，0-1
+	DelayDistribution string  `yaml:"delay_distribution"` // 随机延迟的分布：uniform（默认）、normal、exponential
+	FrozenData        bool    `yaml:"frozen_data"`        // 冻结模式：每只股票始终返回首次的数据，模拟行情停止更新
 }
 
 // MockProviderStats Mock Provider统计
@@ -76,6 +84,10 @@ func NewMockProvider(config MockProviderConfig) *MockProvider {
 		config:    config,
 		stats:     MockProviderStats{},
 		generator: NewDataGenerator(config.DataGenConfig),
+		scripts:   make(map[string]*script),
+		drifted:   make(map[string]core.StockData),
+		frozen:    make(map[string]core.StockData),
+		rand:      rand.New(rand.NewSource(randomSeed(config.DataGenConfig.RandomSeed))),
 	}
 
 	if config.EnableRecording {
@@ -126,7 +138,7 @@ func (mp *MockProvider) FetchData(ctx context.Context, symbols []string) ([]core
 	mp.stats.LastCall = startTime
 
 	// 应用延迟
-	if err := mp.applyDelay(); err != nil {
+	if err := mp.applyDelay(ctx); err != nil {
 		atomic.AddInt64(&mp.stats.FailedCalls, 1)
 		return nil, err
 	}
@@ -139,6 +151,16 @@ func (mp *MockProvider) FetchData(ctx context.Context, symbols []string) ([]core
 	var result []core.StockData
 	var err error
 
+	// 错误注入和脚本场景优先于静态数据
+	if mp.injectError() {
+		err = ErrInjected
+		goto end
+	}
+	if mp.hasScript(symbols) {
+		result, err = mp.runScript(ctx, symbols)
+		goto end
+	}
+
 	// 优先检查SetMockData提供的数据
 	result, err = mp.getMockData(symbols)
 	if err == nil && len(result) > 0 {
@@ -161,6 +183,9 @@ func (mp *MockProvider) FetchData(ctx context.Context, symbols []string) ([]core
 	}
 
 end:
+	if err == nil && mp.isFrozen() {
+		result = mp.freeze(result)
+	}
 	duration := time.Since(startTime)
 
 	// 记录调用
@@ -207,8 +232,8 @@ func (mp *MockProvider) SetMockData(symbols []string, data []core.StockData) {
 	}
 }
 
-// SetScenario 设置当前场景
-func (mp *MockProvider) SetScenario(scenarioName string) error {
+// UseScenario 切换到已添加的具名场景
+func (mp *MockProvider) UseScenario(scenarioName string) error {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
@@ -265,6 +290,9 @@ func (mp *MockProvider) Close() error {
 	mp.enabled = false
 	mp.scenarios = make(map[string]*testkit.MockScenario)
 	mp.mockData = make(map[string][]core.StockData)
+	mp.scripts = make(map[string]*script)
+	mp.drifted = make(map[string]core.StockData)
+	mp.frozen = make(map[string]core.StockData)
 
 	return nil
 }
@@ -352,20 +380,40 @@ func (mp *MockProvider) getMockData(symbols []string) ([]core.StockData, error)
 	return []core.StockData{}, nil
 }
 
-// applyDelay 应用延迟
-func (mp *MockProvider) applyDelay() error {
+// applyDelay 应用默认延迟和随机延迟，ctx 取消时提前返回
+func (mp *MockProvider) applyDelay(ctx context.Context) error {
 	delay := mp.config.DefaultDelay
 
 	if mp.config.RandomDelay && mp.config.MaxRandomDelay > 0 {
-		randomDelay := time.Duration(rand.Int63n(int64(mp.config.MaxRandomDelay)))
-		delay += randomDelay
+		delay += mp.randomDelay()
 	}
 
-	if delay > 0 {
-		time.Sleep(delay)
+	return sleepContext(ctx, delay)
+}
+
+// randomDelay 按 DelayDistribution 生成 [0, MaxRandomDelay) 内的抖动
+// normal 以 MaxRandomDelay/2 为均值、MaxRandomDelay/6 为标准差；exponential 以 MaxRandomDelay/4 为均值，长尾截断在上限
+func (mp *MockProvider) randomDelay() time.Duration {
+	max := float64(mp.config.MaxRandomDelay)
+
+	mp.mu.Lock()
+	var delay float64
+	switch mp.config.DelayDistribution {
+	case "normal":
+		delay = max/2 + mp.rand.NormFloat64()*max/6
+	case "exponential":
+		delay = mp.rand.ExpFloat64() * max / 4
+	default:
+		delay = mp.rand.Float64() * max
 	}
+	mp.mu.Unlock()
 
-	return nil
+	if delay < 0 {
+		delay = 0
+	} else if delay >= max {
+		delay = max - 1
+	}
+	return time.Duration(delay)
 }
 
 // updateAverageDelay 更新平均延迟
@@ -523,15 +571,18 @@ type ChangeRange struct {
 
 // NewDataGenerator 创建数据生成器
 func NewDataGenerator(config DataGenConfig) *DataGenerator {
-	seed := config.RandomSeed
-	if seed == 0 {
-		seed = time.Now().UnixNano()
-	}
-
 	return &DataGenerator{
 		config: config,
-		rand:   rand.New(rand.NewSource(seed)),
+		rand:   rand.New(rand.NewSource(randomSeed(config.RandomSeed))),
+	}
+}
+
+// randomSeed 未配置随机种子时使用当前时间
+func randomSeed(seed int64) int64 {
+	if seed == 0 {
+		return time.Now().UnixNano()
 	}
+	return seed
 }
 
 // GenerateStockData 生成股票数据
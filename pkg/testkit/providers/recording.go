@@ -0,0 +1,304 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stocksub/pkg/core"
+	"stocksub/pkg/provider"
+)
+
+// 录制回放模式
+const (
+	ModeLive   = "live"   // 直接调用被包装的提供商
+	ModeRecord = "record" // 调用被包装的提供商，并把每次响应写成 fixture
+	ModeReplay = "replay" // 只从 fixture 返回响应，不发起网络请求
+)
+
+// FixtureVersion 当前的 fixture 格式版本，格式有不兼容变化时递增
+const FixtureVersion = 1
+
+// ErrFixtureNotFound 回放模式下请求的股票组合没有对应的 fixture
+var ErrFixtureNotFound = errors.New("recording provider: no fixture for request")
+
+// Fixture 一次调用的录制结果，每个文件保存一个
+type Fixture struct {
+	Version    int              `json:"version"`
+	Provider   string           `json:"provider"`
+	Symbols    []string         `json:"symbols"`
+	Raw        string           `json:"raw"`
+	Data       []core.StockData `json:"data"`
+	RecordedAt time.Time        `json:"recorded_at"`
+}
+
+// RecordingConfig 录制回放配置
+type RecordingConfig struct {
+	Mode        string // live、record 或 replay，为空时等同 live
+	Dir         string // fixture 目录
+	FallThrough bool   // 回放未命中时调用被包装的提供商，而不是返回 ErrFixtureNotFound
+}
+
+// RecordingProvider 录制回放装饰器：record 模式下把真实响应保存为 fixture，
+// replay 模式下按股票组合（忽略顺序）返回 fixture，使集成测试可以离线、确定地运行
+type RecordingProvider struct {
+	provider.RealtimeStockProvider
+	config RecordingConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	fixtures map[string][]*Fixture // 按股票组合索引，同一组合按录制顺序依次回放
+	replayed map[string]int
+	seq      int
+}
+
+// NewRecordingProvider 创建录制回放装饰器，replay 模式下立即加载目录中的全部 fixture。
+// replay 模式且不允许回落时 inner 可以为 nil
+func NewRecordingProvider(inner provider.RealtimeStockProvider, config RecordingConfig) (*RecordingProvider, error) {
+	if config.Mode == "" {
+		config.Mode = ModeLive
+	}
+	r := &RecordingProvider{
+		RealtimeStockProvider: inner,
+		config:                config,
+		now:                   time.Now,
+		fixtures:              make(map[string][]*Fixture),
+		replayed:              make(map[string]int),
+	}
+
+	switch config.Mode {
+	case ModeLive:
+	case ModeRecord:
+		if config.Dir == "" {
+			return nil, fmt.Errorf("record mode requires a fixture directory")
+		}
+		if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create fixture directory %s: %w", config.Dir, err)
+		}
+	case ModeReplay:
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid recording mode %q, must be one of: live, record, replay", config.Mode)
+	}
+
+	if inner == nil && (config.Mode != ModeReplay || config.FallThrough) {
+		return nil, fmt.Errorf("%s mode requires a provider to wrap", config.Mode)
+	}
+	return r, nil
+}
+
+// Name 返回被包装的提供商名称，回放且未包装提供商时返回 "replay"
+func (r *RecordingProvider) Name() string {
+	if r.RealtimeStockProvider == nil {
+		return "replay"
+	}
+	return r.RealtimeStockProvider.Name()
+}
+
+// GetRateLimit 回放不需要限速
+func (r *RecordingProvider) GetRateLimit() time.Duration {
+	if r.config.Mode == ModeReplay || r.RealtimeStockProvider == nil {
+		return 0
+	}
+	return r.RealtimeStockProvider.GetRateLimit()
+}
+
+// IsHealthy 回放模式始终健康
+func (r *RecordingProvider) IsHealthy() bool {
+	if r.config.Mode == ModeReplay || r.RealtimeStockProvider == nil {
+		return true
+	}
+	return r.RealtimeStockProvider.IsHealthy()
+}
+
+// IsSymbolSupported 回放且未包装提供商时只支持 fixture 中出现过的股票
+func (r *RecordingProvider) IsSymbolSupported(symbol string) bool {
+	if r.RealtimeStockProvider != nil {
+		return r.RealtimeStockProvider.IsSymbolSupported(symbol)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, fixtures := range r.fixtures {
+		for _, s := range fixtures[0].Symbols {
+			if s == symbol {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FetchStockData 实现 provider.RealtimeStockProvider
+func (r *RecordingProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	data, _, err := r.FetchStockDataWithRaw(ctx, symbols)
+	return data, err
+}
+
+// FetchStockDataWithRaw 按模式直接调用、录制或回放
+func (r *RecordingProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	switch r.config.Mode {
+	case ModeRecord:
+		data, raw, err := r.RealtimeStockProvider.FetchStockDataWithRaw(ctx, symbols)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := r.record(symbols, raw, data); err != nil {
+			return nil, "", err
+		}
+		return data, raw, nil
+	case ModeReplay:
+		if fixture := r.next(symbols); fixture != nil {
+			return orderBySymbols(fixture.Data, symbols), fixture.Raw, nil
+		}
+		if r.config.FallThrough {
+			return r.RealtimeStockProvider.FetchStockDataWithRaw(ctx, symbols)
+		}
+		return nil, "", fmt.Errorf("%w: %v", ErrFixtureNotFound, symbols)
+	default:
+		return r.RealtimeStockProvider.FetchStockDataWithRaw(ctx, symbols)
+	}
+}
+
+// Close 关闭被包装的提供商
+func (r *RecordingProvider) Close() error {
+	if closer, ok := r.RealtimeStockProvider.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// record 把一次调用写入 <提供商>-<组合哈希>-<录制时间>-<序号>.json
+func (r *RecordingProvider) record(symbols []string, raw string, data []core.StockData) error {
+	fixture := &Fixture{
+		Version:    FixtureVersion,
+		Provider:   r.Name(),
+		Symbols:    append([]string(nil), symbols...),
+		Raw:        raw,
+		Data:       data,
+		RecordedAt: r.now(),
+	}
+	content, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode fixture: %w", err)
+	}
+
+	r.mu.Lock()
+	r.seq++
+	name := fmt.Sprintf("%s-%s-%d-%04d.json", fixture.Provider, symbolSetHash(symbols), fixture.RecordedAt.UnixNano(), r.seq)
+	r.mu.Unlock()
+
+	path := filepath.Join(r.config.Dir, name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture %s: %w", path, err)
+	}
+	return nil
+}
+
+// load 加载目录中的全部 fixture，同一组合按录制时间排序
+func (r *RecordingProvider) load() error {
+	if _, err := os.Stat(r.config.Dir); err != nil {
+		return fmt.Errorf("fixture directory %s: %w", r.config.Dir, err)
+	}
+	paths, err := filepath.Glob(filepath.Join(r.config.Dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list fixtures in %s: %w", r.config.Dir, err)
+	}
+
+	for _, path := range paths {
+		fixture, err := LoadFixture(path)
+		if err != nil {
+			return err
+		}
+		key := symbolSetKey(fixture.Symbols)
+		r.fixtures[key] = append(r.fixtures[key], fixture)
+	}
+	for _, fixtures := range r.fixtures {
+		sort.SliceStable(fixtures, func(i, j int) bool {
+			return fixtures[i].RecordedAt.Before(fixtures[j].RecordedAt)
+		})
+	}
+	return nil
+}
+
+// next 返回该组合的下一个 fixture，录制的次数用完后重复最后一个
+func (r *RecordingProvider) next(symbols []string) *Fixture {
+	key := symbolSetKey(symbols)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fixtures := r.fixtures[key]
+	if len(fixtures) == 0 {
+		return nil
+	}
+	i := min(r.replayed[key], len(fixtures)-1)
+	r.replayed[key]++
+	return fixtures[i]
+}
+
+// LoadFixture 读取单个 fixture 文件，拒绝更新版本的格式
+func LoadFixture(path string) (*Fixture, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %w", path, err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+	if fixture.Version < 1 || fixture.Version > FixtureVersion {
+		return nil, fmt.Errorf("fixture %s has unsupported version %d, expected 1-%d", path, fixture.Version, FixtureVersion)
+	}
+	if len(fixture.Symbols) == 0 {
+		return nil, fmt.Errorf("fixture %s has no symbols", path)
+	}
+	return &fixture, nil
+}
+
+// symbolSetKey 忽略顺序和重复的股票组合键
+func symbolSetKey(symbols []string) string {
+	set := make(map[string]struct{}, len(symbols))
+	for _, symbol := range symbols {
+		set[symbol] = struct{}{}
+	}
+	unique := make([]string, 0, len(set))
+	for symbol := range set {
+		unique = append(unique, symbol)
+	}
+	sort.Strings(unique)
+	return strings.Join(unique, ",")
+}
+
+// symbolSetHash 组合键的短哈希，用于文件名
+func symbolSetHash(symbols []string) string {
+	h := fnv.New32a()
+	h.Write([]byte(symbolSetKey(symbols)))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// orderBySymbols 按请求顺序排列回放的数据，数据中缺少请求的股票时保持录制顺序
+func orderBySymbols(data []core.StockData, symbols []string) []core.StockData {
+	bySymbol := make(map[string]core.StockData, len(data))
+	for _, d := range data {
+		bySymbol[d.Symbol] = d
+	}
+	ordered := make([]core.StockData, 0, len(symbols))
+	for _, symbol := range symbols {
+		d, ok := bySymbol[symbol]
+		if !ok {
+			return append([]core.StockData(nil), data...)
+		}
+		ordered = append(ordered, d)
+	}
+	return ordered
+}
@@ -0,0 +1,139 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+)
+
+// rawStub 返回可预测原始响应的提供商，price 每次调用加 1
+type rawStub struct {
+	calls int
+}
+
+func (s *rawStub) Name() string                         { return "stub" }
+func (s *rawStub) GetRateLimit() time.Duration          { return time.Second }
+func (s *rawStub) IsHealthy() bool                      { return true }
+func (s *rawStub) IsSymbolSupported(symbol string) bool { return true }
+
+func (s *rawStub) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	data, _, err := s.FetchStockDataWithRaw(ctx, symbols)
+	return data, err
+}
+
+func (s *rawStub) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	s.calls++
+	data := make([]core.StockData, 0, len(symbols))
+	raw := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		price := float64(s.calls)
+		data = append(data, core.StockData{Symbol: symbol, Price: price})
+		raw = append(raw, fmt.Sprintf("v_%s=\"%v\";", symbol, price))
+	}
+	return data, strings.Join(raw, "\n"), nil
+}
+
+func TestRecordingProvider_RecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	stub := &rawStub{}
+	recorder, err := NewRecordingProvider(stub, RecordingConfig{Mode: ModeRecord, Dir: dir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = recorder.FetchStockData(ctx, []string{"600000", "000001"})
+	require.NoError(t, err)
+	_, raw, err := recorder.FetchStockDataWithRaw(ctx, []string{"600000", "000001"})
+	require.NoError(t, err)
+	assert.Contains(t, raw, `v_600000="2";`)
+	_, err = recorder.FetchStockData(ctx, []string{"600036"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 3, "每次调用一个文件")
+	fixture, err := LoadFixture(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, FixtureVersion, fixture.Version)
+	assert.Equal(t, "stub", fixture.Provider)
+	assert.False(t, fixture.RecordedAt.IsZero())
+
+	replayer, err := NewRecordingProvider(nil, RecordingConfig{Mode: ModeReplay, Dir: dir})
+	require.NoError(t, err)
+
+	// 按股票组合匹配，忽略顺序，返回顺序跟随请求
+	data, raw, err := replayer.FetchStockDataWithRaw(ctx, []string{"000001", "600000"})
+	require.NoError(t, err)
+	assert.Equal(t, []core.StockData{{Symbol: "000001", Price: 1}, {Symbol: "600000", Price: 1}}, data)
+	assert.Contains(t, raw, `v_600000="1";`)
+
+	// 同一组合按录制顺序回放，用完后重复最后一个
+	for _, want := range []float64{2, 2} {
+		data, err = replayer.FetchStockData(ctx, []string{"600000", "000001"})
+		require.NoError(t, err)
+		assert.Equal(t, want, data[0].Price)
+	}
+
+	data, err = replayer.FetchStockData(ctx, []string{"600036"})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, data[0].Price)
+
+	_, err = replayer.FetchStockData(ctx, []string{"600519"})
+	assert.ErrorIs(t, err, ErrFixtureNotFound)
+	assert.Equal(t, 3, stub.calls, "回放不调用被包装的提供商")
+	assert.True(t, replayer.IsSymbolSupported("600036"))
+	assert.False(t, replayer.IsSymbolSupported("600519"))
+}
+
+func TestRecordingProvider_ReplayFallThrough(t *testing.T) {
+	stub := &rawStub{}
+	replayer, err := NewRecordingProvider(stub, RecordingConfig{Mode: ModeReplay, Dir: t.TempDir(), FallThrough: true})
+	require.NoError(t, err)
+
+	data, err := replayer.FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, "600000", data[0].Symbol)
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestRecordingProvider_LiveModePassesThrough(t *testing.T) {
+	dir := t.TempDir()
+	stub := &rawStub{}
+	live, err := NewRecordingProvider(stub, RecordingConfig{Dir: dir})
+	require.NoError(t, err)
+
+	_, err = live.FetchStockData(context.Background(), []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stub.calls)
+	assert.Equal(t, time.Second, live.GetRateLimit())
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Empty(t, files, "live 模式不录制")
+}
+
+func TestNewRecordingProvider_RejectsInvalidSetup(t *testing.T) {
+	dir := t.TempDir()
+	future, err := json.Marshal(Fixture{Version: FixtureVersion + 1, Symbols: []string{"600000"}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "future.json"), future, 0o644))
+
+	_, err = NewRecordingProvider(nil, RecordingConfig{Mode: ModeReplay, Dir: dir})
+	assert.ErrorContains(t, err, "unsupported version")
+
+	_, err = NewRecordingProvider(nil, RecordingConfig{Mode: ModeReplay, Dir: filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+
+	_, err = NewRecordingProvider(nil, RecordingConfig{Mode: ModeRecord, Dir: dir})
+	assert.Error(t, err, "record 模式需要被包装的提供商")
+
+	_, err = NewRecordingProvider(&rawStub{}, RecordingConfig{Mode: "rewind", Dir: dir})
+	assert.Error(t, err)
+}
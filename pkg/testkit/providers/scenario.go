@@ -0,0 +1,323 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+)
+
+// ErrInjected 按 ErrorRate 随机注入的错误
+var ErrInjected = errors.New("mock provider: injected error")
+
+// wildcardSymbol 脚本场景中匹配所有没有单独脚本的股票
+const wildcardSymbol = "*"
+
+// Step 脚本场景中的一步，每次 FetchData 调用推进一步
+type Step struct {
+	Delay      time.Duration // 本次调用的响应延迟
+	Err        error         // 本次调用返回的错误，nil 表示成功
+	PriceDelta float64       // 成功时价格相对上一次的变动
+}
+
+// script 单个股票（或通配符）的脚本及当前位置
+type script struct {
+	steps []Step
+	pos   int
+}
+
+// next 返回当前步骤并前进，步骤用完后返回零值：无延迟、无错误、价格不变
+func (s *script) next() Step {
+	if s == nil || s.pos >= len(s.steps) {
+		return Step{}
+	}
+	step := s.steps[s.pos]
+	s.pos++
+	return step
+}
+
+// Scenario 可在测试间共享的脚本场景
+type Scenario struct {
+	Name        string
+	Description string
+	ErrorRate   float64
+	FrozenData  bool
+	Steps       map[string][]Step // 按股票代码的步骤，"*" 用于没有单独脚本的股票
+}
+
+// scenarioFile 场景 YAML 文件的结构
+type scenarioFile struct {
+	Name        string                `yaml:"name"`
+	Description string                `yaml:"description"`
+	ErrorRate   float64               `yaml:"error_rate"`
+	FrozenData  bool                  `yaml:"frozen_data"`
+	Symbols     map[string][]stepSpec `yaml:"symbols"`
+}
+
+// stepSpec YAML 中的一步，repeat 表示连续重复的次数
+type stepSpec struct {
+	Delay      time.Duration `yaml:"delay"`
+	Error      string        `yaml:"error"`
+	ErrorCode  string        `yaml:"error_code"` // 设置时返回带该错误码的 apperrors.BaseError
+	PriceDelta float64       `yaml:"price_delta"`
+	Repeat     int           `yaml:"repeat"`
+}
+
+// LoadScenarioFromYAML 从 YAML 文件加载脚本场景
+func LoadScenarioFromYAML(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario %s: %w", path, err)
+	}
+
+	var file scenarioFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", path, err)
+	}
+	if file.ErrorRate < 0 || file.ErrorRate > 1 {
+		return nil, fmt.Errorf("scenario %s: error_rate must be between 0 and 1, got %v", path, file.ErrorRate)
+	}
+
+	scenario := &Scenario{
+		Name:        file.Name,
+		Description: file.Description,
+		ErrorRate:   file.ErrorRate,
+		FrozenData:  file.FrozenData,
+		Steps:       make(map[string][]Step, len(file.Symbols)),
+	}
+	for symbol, specs := range file.Symbols {
+		for i, spec := range specs {
+			if spec.Repeat < 0 {
+				return nil, fmt.Errorf("scenario %s: %s step %d: repeat must not be negative", path, symbol, i+1)
+			}
+			step := spec.toStep()
+			for n := 0; n < max(spec.Repeat, 1); n++ {
+				scenario.Steps[symbol] = append(scenario.Steps[symbol], step)
+			}
+		}
+	}
+	return scenario, nil
+}
+
+func (s stepSpec) toStep() Step {
+	step := Step{Delay: s.Delay, PriceDelta: s.PriceDelta}
+	switch {
+	case s.ErrorCode != "":
+		msg := s.Error
+		if msg == "" {
+			msg = s.ErrorCode
+		}
+		step.Err = apperrors.NewError(apperrors.ErrorCode(s.ErrorCode), msg)
+	case s.Error != "":
+		step.Err = errors.New(s.Error)
+	}
+	return step
+}
+
+// SetScenario 为股票设置脚本，每次 FetchData 推进一步；symbol 为 "*" 时用于所有没有单独脚本的股票。
+// steps 为空时移除该股票的脚本
+func (mp *MockProvider) SetScenario(symbol string, steps []Step) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	delete(mp.drifted, symbol)
+	if len(steps) == 0 {
+		delete(mp.scripts, symbol)
+		return
+	}
+	mp.scripts[symbol] = &script{steps: append([]Step(nil), steps...)}
+}
+
+// ClearScenarios 移除所有脚本及累积的价格变动
+func (mp *MockProvider) ClearScenarios() {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.scripts = make(map[string]*script)
+	mp.drifted = make(map[string]core.StockData)
+}
+
+// ApplyScenario 用场景替换当前的所有脚本，并应用场景的错误率和冻结模式
+func (mp *MockProvider) ApplyScenario(scenario *Scenario) {
+	mp.mu.Lock()
+	mp.scripts = make(map[string]*script, len(scenario.Steps))
+	mp.drifted = make(map[string]core.StockData)
+	for symbol, steps := range scenario.Steps {
+		if len(steps) > 0 {
+			mp.scripts[symbol] = &script{steps: append([]Step(nil), steps...)}
+		}
+	}
+	mp.mu.Unlock()
+
+	mp.SetErrorRate(scenario.ErrorRate)
+	mp.SetFrozenData(scenario.FrozenData)
+}
+
+// SetErrorRate 设置每次调用返回 ErrInjected 的概率
+func (mp *MockProvider) SetErrorRate(rate float64) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.config.ErrorRate = rate
+}
+
+// SetFrozenData 开启或关闭冻结模式，关闭时清除已冻结的数据
+func (mp *MockProvider) SetFrozenData(frozen bool) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.config.FrozenData = frozen
+	if !frozen {
+		mp.frozen = make(map[string]core.StockData)
+	}
+}
+
+// FetchStockData 实现 provider.RealtimeStockProvider
+func (mp *MockProvider) FetchStockData(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	return mp.FetchData(ctx, symbols)
+}
+
+// FetchStockDataWithRaw 实现 provider.RealtimeStockProvider，Mock 没有原始响应
+func (mp *MockProvider) FetchStockDataWithRaw(ctx context.Context, symbols []string) ([]core.StockData, string, error) {
+	data, err := mp.FetchData(ctx, symbols)
+	return data, "", err
+}
+
+// injectError 按 ErrorRate 决定本次调用是否注入错误
+func (mp *MockProvider) injectError() bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	rate := mp.config.ErrorRate
+	return rate > 0 && mp.rand.Float64() < rate
+}
+
+// hasScript 请求的股票中是否有脚本覆盖的
+func (mp *MockProvider) hasScript(symbols []string) bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	if _, ok := mp.scripts[wildcardSymbol]; ok {
+		return true
+	}
+	for _, symbol := range symbols {
+		if _, ok := mp.scripts[symbol]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// runScript 为每只股票推进一步，延迟取各步骤的最大值，任一步骤有错误则整次调用失败。
+// 通配符脚本每次调用只推进一步，与请求中没有单独脚本的股票数量无关
+func (mp *MockProvider) runScript(ctx context.Context, symbols []string) ([]core.StockData, error) {
+	mp.mu.Lock()
+	steps := make([]Step, len(symbols))
+	var wildcard *Step
+	for i, symbol := range symbols {
+		if s, ok := mp.scripts[symbol]; ok {
+			steps[i] = s.next()
+			continue
+		}
+		if wildcard == nil {
+			step := mp.scripts[wildcardSymbol].next()
+			wildcard = &step
+		}
+		steps[i] = *wildcard
+	}
+	mp.mu.Unlock()
+
+	var delay time.Duration
+	var stepErr error
+	for _, step := range steps {
+		delay = max(delay, step.Delay)
+		if stepErr == nil {
+			stepErr = step.Err
+		}
+	}
+	if err := sleepContext(ctx, delay); err != nil {
+		return nil, err
+	}
+	if stepErr != nil {
+		return nil, stepErr
+	}
+
+	result := make([]core.StockData, 0, len(symbols))
+	for i, symbol := range symbols {
+		result = append(result, mp.drift(symbol, steps[i].PriceDelta))
+	}
+	return result, nil
+}
+
+// drift 在该股票上一次的数据上应用价格变动，首次使用 SetMockData 的数据或生成的数据
+func (mp *MockProvider) drift(symbol string, delta float64) core.StockData {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	data, ok := mp.drifted[symbol]
+	if !ok {
+		if mocked := mp.mockData[symbol]; len(mocked) > 0 {
+			data = mocked[0]
+		} else {
+			data = mp.generator.generateSingleStock(symbol)
+		}
+	}
+
+	data.Price += delta
+	if data.PrevClose > 0 {
+		data.Change = data.Price - data.PrevClose
+		data.ChangePercent = data.Change / data.PrevClose * 100
+	}
+	data.High = max(data.High, data.Price)
+	data.Low = min(data.Low, data.Price)
+	data.Timestamp = time.Now()
+
+	mp.drifted[symbol] = data
+	return data
+}
+
+// isFrozen 是否处于冻结模式
+func (mp *MockProvider) isFrozen() bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.config.FrozenData
+}
+
+// freeze 用每只股票首次返回的数据替换本次结果，使连续调用返回完全相同的值
+func (mp *MockProvider) freeze(result []core.StockData) []core.StockData {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	frozen := make([]core.StockData, len(result))
+	for i, data := range result {
+		if first, ok := mp.frozen[data.Symbol]; ok {
+			data = first
+		} else {
+			mp.frozen[data.Symbol] = data
+		}
+		frozen[i] = data
+	}
+	return frozen
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回 ctx 的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"stocksub/pkg/core"
+	apperrors "stocksub/pkg/error"
+)
+
+// newScenarioProvider 无延迟、无数据生成的 MockProvider，随机源固定
+func newScenarioProvider() *MockProvider {
+	return NewMockProvider(MockProviderConfig{DataGenConfig: DataGenConfig{RandomSeed: 42}})
+}
+
+func TestSetScenario_AdvancesOneStepPerCall(t *testing.T) {
+	mp := newScenarioProvider()
+	mp.SetMockData([]string{"600000"}, []core.StockData{{Symbol: "600000", Price: 10, PrevClose: 10, High: 10, Low: 10}})
+	boom := errors.New("boom")
+	mp.SetScenario("600000", []Step{{PriceDelta: 0.5}, {Err: boom}, {PriceDelta: -1}})
+
+	ctx := context.Background()
+	data, err := mp.FetchData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	assert.InDelta(t, 10.5, data[0].Price, 1e-9)
+	assert.InDelta(t, 0.5, data[0].Change, 1e-9)
+	assert.InDelta(t, 10.5, data[0].High, 1e-9)
+
+	_, err = mp.FetchData(ctx, []string{"600000"})
+	assert.ErrorIs(t, err, boom)
+
+	data, err = mp.FetchData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	assert.InDelta(t, 9.5, data[0].Price, 1e-9, "失败的步骤不改变价格")
+	assert.InDelta(t, 9.5, data[0].Low, 1e-9)
+
+	data, err = mp.FetchData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	assert.InDelta(t, 9.5, data[0].Price, 1e-9, "步骤用完后保持最后的价格")
+
+	mp.SetScenario("600000", nil)
+	data, err = mp.FetchData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	assert.InDelta(t, 10.0, data[0].Price, 1e-9, "移除脚本后回到静态数据")
+}
+
+func TestSetScenario_WildcardAdvancesOncePerCall(t *testing.T) {
+	mp := newScenarioProvider()
+	mp.SetScenario("*", []Step{{Err: errors.New("down")}, {PriceDelta: 1}})
+	mp.SetScenario("000001", []Step{{PriceDelta: 2}, {PriceDelta: 2}})
+
+	ctx := context.Background()
+	_, err := mp.FetchData(ctx, []string{"600000", "600036", "000001"})
+	require.Error(t, err, "任一步骤失败则整次调用失败")
+
+	data, err := mp.FetchData(ctx, []string{"600000", "600036", "000001"})
+	require.NoError(t, err)
+	require.Len(t, data, 3)
+	assert.Equal(t, []string{"600000", "600036", "000001"}, []string{data[0].Symbol, data[1].Symbol, data[2].Symbol})
+
+	first := data[0].Price
+	data, err = mp.FetchData(ctx, []string{"600000"})
+	require.NoError(t, err)
+	assert.Equal(t, first, data[0].Price, "通配符脚本已用完，价格不再变化")
+}
+
+func TestSetScenario_DelayRespectsContext(t *testing.T) {
+	mp := newScenarioProvider()
+	mp.SetScenario("600000", []Step{{Delay: time.Second}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := mp.FetchData(ctx, []string{"600000"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestErrorRate_InjectsErrors(t *testing.T) {
+	mp := newScenarioProvider()
+	mp.SetScenario("*", []Step{{}})
+	mp.SetErrorRate(1)
+	_, err := mp.FetchData(context.Background(), []string{"600000"})
+	assert.ErrorIs(t, err, ErrInjected)
+
+	mp.SetErrorRate(0.5)
+	failures := 0
+	for i := 0; i < 200; i++ {
+		if _, err := mp.FetchData(context.Background(), []string{"600000"}); err != nil {
+			failures++
+		}
+	}
+	assert.InDelta(t, 100, failures, 30)
+	assert.EqualValues(t, failures+1, mp.GetStats().FailedCalls)
+}
+
+func TestFrozenData_RepeatsIdenticalValues(t *testing.T) {
+	config := MockProviderConfig{EnableDataGen: true, DataGenConfig: DataGenConfig{RandomSeed: 7}}
+	mp := NewMockProvider(config)
+	require.NoError(t, mp.UseScenario("normal"))
+	mp.SetScenario("600000", []Step{{PriceDelta: 1}, {PriceDelta: 1}})
+	mp.SetFrozenData(true)
+
+	ctx := context.Background()
+	symbols := []string{"600000", "000001"}
+	first, err := mp.FetchData(ctx, symbols)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		next, err := mp.FetchData(ctx, symbols)
+		require.NoError(t, err)
+		assert.Equal(t, first, next, "冻结模式下价格和时间戳都不变")
+	}
+
+	mp.SetFrozenData(false)
+	next, err := mp.FetchData(ctx, symbols)
+	require.NoError(t, err)
+	assert.Equal(t, first[0].Price+1, next[0].Price, "关闭冻结后继续按脚本变动")
+}
+
+func TestRandomDelay_StaysWithinBounds(t *testing.T) {
+	for _, distribution := range []string{"", "uniform", "normal", "exponential"} {
+		t.Run(distribution, func(t *testing.T) {
+			mp := NewMockProvider(MockProviderConfig{
+				RandomDelay:       true,
+				MaxRandomDelay:    100 * time.Millisecond,
+				DelayDistribution: distribution,
+				DataGenConfig:     DataGenConfig{RandomSeed: 1},
+			})
+			var total time.Duration
+			for i := 0; i < 1000; i++ {
+				d := mp.randomDelay()
+				require.GreaterOrEqual(t, d, time.Duration(0))
+				require.Less(t, d, 100*time.Millisecond)
+				total += d
+			}
+			mean := total / 1000
+			if distribution == "exponential" {
+				assert.InDelta(t, float64(25*time.Millisecond), float64(mean), float64(5*time.Millisecond))
+			} else {
+				assert.InDelta(t, float64(50*time.Millisecond), float64(mean), float64(5*time.Millisecond))
+			}
+		})
+	}
+}
+
+func TestLoadScenarioFromYAML(t *testing.T) {
+	scenario, err := LoadScenarioFromYAML(filepath.Join("testdata", "breaker_cycle.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "breaker_cycle", scenario.Name)
+
+	steps := scenario.Steps["*"]
+	require.Len(t, steps, 6, "repeat 展开为连续的步骤")
+	for _, step := range steps[:3] {
+		assert.True(t, apperrors.Is(step.Err, apperrors.CodeNetworkTimeout))
+		assert.Equal(t, 5*time.Millisecond, step.Delay)
+	}
+	for _, step := range steps[3:] {
+		assert.NoError(t, step.Err)
+		assert.Equal(t, 0.1, step.PriceDelta)
+	}
+
+	mp := newScenarioProvider()
+	mp.ApplyScenario(scenario)
+	_, err = mp.FetchData(context.Background(), []string{"600000"})
+	assert.True(t, apperrors.Is(err, apperrors.CodeNetworkTimeout))
+}
+
+func TestLoadScenarioFromYAML_RejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"negative_repeat.yaml": "symbols:\n  \"*\":\n    - error: x\n      repeat: -1\n",
+		"bad_rate.yaml":        "error_rate: 1.5\n",
+		"bad_yaml.yaml":        "symbols: [\n",
+	}
+	for name, content := range cases {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := LoadScenarioFromYAML(path)
+		assert.Error(t, err, name)
+	}
+
+	_, err := LoadScenarioFromYAML(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,94 @@
+package timing
+
+import (
+	"fmt"
+	"time"
+)
+
+// dateLayout 节假日日历使用的日期格式
+const dateLayout = "2006-01-02"
+
+// defaultCNHolidays 沪深交易所公布的工作日休市日期
+// 来源：上交所/深交所年度休市安排公告，新年度公告发布后需在此追加
+var defaultCNHolidays = []string{
+	// 2025 元旦、春节、清明节、劳动节、端午节、国庆节及中秋节
+	"2025-01-01",
+	"2025-01-28", "2025-01-29", "2025-01-30", "2025-01-31", "2025-02-03", "2025-02-04",
+	"2025-04-04",
+	"2025-05-01", "2025-05-02", "2025-05-05",
+	"2025-06-02",
+	"2025-10-01", "2025-10-02", "2025-10-03", "2025-10-06", "2025-10-07", "2025-10-08",
+
+	// 2026 元旦、春节、清明节、劳动节、端午节、中秋节、国庆节
+	"2026-01-01", "2026-01-02",
+	"2026-02-16", "2026-02-17", "2026-02-18", "2026-02-19", "2026-02-20", "2026-02-23",
+	"2026-04-06",
+	"2026-05-01", "2026-05-04", "2026-05-05",
+	"2026-06-19",
+	"2026-09-25",
+	"2026-10-01", "2026-10-02", "2026-10-05", "2026-10-06", "2026-10-07",
+}
+
+// defaultCNMakeupDays 周末开市的交易日
+// A 股调休上班的周末不开市，默认日历为空，仅为其他市场或特殊安排保留
+var defaultCNMakeupDays = []string{}
+
+// LoadHolidays 添加休市日期
+func (m *MarketTime) LoadHolidays(dates []time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range dates {
+		m.holidays[d.Format(dateLayout)] = true
+	}
+}
+
+// LoadHolidayStrings 添加 2006-01-02 格式的休市日期
+func (m *MarketTime) LoadHolidayStrings(dates []string) error {
+	parsed, err := parseDates(dates)
+	if err != nil {
+		return err
+	}
+	m.LoadHolidays(parsed)
+	return nil
+}
+
+// LoadMakeupDays 添加周末开市的交易日
+func (m *MarketTime) LoadMakeupDays(dates []time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range dates {
+		m.makeupDays[d.Format(dateLayout)] = true
+	}
+}
+
+// LoadMakeupDayStrings 添加 2006-01-02 格式的周末开市交易日
+func (m *MarketTime) LoadMakeupDayStrings(dates []string) error {
+	parsed, err := parseDates(dates)
+	if err != nil {
+		return err
+	}
+	m.LoadMakeupDays(parsed)
+	return nil
+}
+
+// parseDates 解析 2006-01-02 格式的日期列表
+func parseDates(dates []string) ([]time.Time, error) {
+	parsed := make([]time.Time, 0, len(dates))
+	for _, s := range dates {
+		d, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的日期 %q: %w", s, err)
+		}
+		parsed = append(parsed, d)
+	}
+	return parsed, nil
+}
+
+// mustParseDates 解析内置日历，格式错误视为编程错误
+func mustParseDates(dates []string) []time.Time {
+	parsed, err := parseDates(dates)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
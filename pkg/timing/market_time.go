@@ -1,6 +1,7 @@
 package timing
 
 import (
+	"sync"
 	"time"
 )
 
@@ -19,13 +20,22 @@ func (s *SystemTimeService) Now() time.Time {
 // MarketTime 提供市场交易时间检测功能
 type MarketTime struct {
 	timeService TimeService
+
+	mu         sync.RWMutex
+	holidays   map[string]bool // 工作日休市日期
+	makeupDays map[string]bool // 周末开市日期
 }
 
-// NewMarketTime 创建新的市场时间检测器
+// NewMarketTime 创建新的市场时间检测器，默认加载内置的 A 股休市日历
 func NewMarketTime(timeService TimeService) *MarketTime {
-	return &MarketTime{
+	m := &MarketTime{
 		timeService: timeService,
+		holidays:    make(map[string]bool),
+		makeupDays:  make(map[string]bool),
 	}
+	m.LoadHolidays(mustParseDates(defaultCNHolidays))
+	m.LoadMakeupDays(mustParseDates(defaultCNMakeupDays))
+	return m
 }
 
 // DefaultMarketTime 使用系统时间的默认市场时间检测器
@@ -38,65 +48,207 @@ func (m *MarketTime) Now() time.Time {
 	return m.timeService.Now()
 }
 
+// 交易时段边界（含前后缓冲）
+// 上午交易时段: 09:13:30 - 11:30:10
+// 下午交易时段: 12:57:30 - 15:00:10
+const (
+	morningStart   = "09:13:30"
+	morningEnd     = "11:30:10"
+	afternoonStart = "12:57:30"
+	afternoonEnd   = "15:00:10"
+)
+
+// 交易时段名称，用于消息元数据
+const (
+	SessionMorning    = "morning"
+	SessionLunchBreak = "lunch_break"
+	SessionAfternoon  = "afternoon"
+	SessionClosed     = "closed"
+)
+
 // IsTradingTime 判断当前是否在交易时段
 func (m *MarketTime) IsTradingTime() bool {
-	now := m.timeService.Now()
+	return m.IsTradingTimeAt(m.timeService.Now())
+}
+
+// IsTradingTimeAt 判断指定时间是否在交易时段
+func (m *MarketTime) IsTradingTimeAt(t time.Time) bool {
+	session := m.TradingSessionAt(t)
+	return session == SessionMorning || session == SessionAfternoon
+}
+
+// TradingSession 返回当前所处的交易时段
+func (m *MarketTime) TradingSession() string {
+	return m.TradingSessionAt(m.timeService.Now())
+}
 
+// TradingSessionAt 返回指定时间所处的交易时段: morning、lunch_break、afternoon、closed
+func (m *MarketTime) TradingSessionAt(t time.Time) string {
 	// 周末不交易
-	if !m.IsTradingDay(now) {
+	if !m.IsTradingDay(t) {
+		return SessionClosed
+	}
+
+	currentTime := t.Format("15:04:05")
+	switch {
+	case currentTime >= morningStart && currentTime <= morningEnd:
+		return SessionMorning
+	case currentTime > morningEnd && currentTime < afternoonStart:
+		return SessionLunchBreak
+	case currentTime >= afternoonStart && currentTime <= afternoonEnd:
+		return SessionAfternoon
+	default:
+		return SessionClosed
+	}
+}
+
+// IsWithinTradingWindow 判断指定时间是否在交易时段内，开盘前 preOpen、收盘后 postClose 也视为在窗口内
+// 午间休市不受缓冲影响
+func (m *MarketTime) IsWithinTradingWindow(t time.Time, preOpen, postClose time.Duration) bool {
+	if !m.IsTradingDay(t) {
 		return false
 	}
 
-	// 上午交易时段: 09:13:30 - 11:30:10
-	// 下午交易时段: 12:57:30 - 15:00:10
-	currentTime := now.Format("15:04:05")
+	windowStart := clockOn(t, morningStart).Add(-preOpen)
+	windowEnd := clockOn(t, afternoonEnd).Add(postClose)
+	if t.Before(windowStart) || t.After(windowEnd) {
+		return false
+	}
+	return m.TradingSessionAt(t) != SessionLunchBreak
+}
 
-	morningStart := "09:13:30"
-	morningEnd := "11:30:10"
+// NextTradingWindowStart 返回指定时间之后（含）交易窗口的开始时间，已在窗口内时返回 t
+func (m *MarketTime) NextTradingWindowStart(t time.Time, preOpen, postClose time.Duration) time.Time {
+	if m.IsWithinTradingWindow(t, preOpen, postClose) {
+		return t
+	}
 
-	afternoonStart := "12:57:30"
-	afternoonEnd := "15:00:10"
+	if m.IsTradingDay(t) {
+		if windowStart := clockOn(t, morningStart).Add(-preOpen); t.Before(windowStart) {
+			return windowStart
+		}
+		if m.TradingSessionAt(t) == SessionLunchBreak {
+			return clockOn(t, afternoonStart)
+		}
+	}
+
+	return clockOn(m.NextTradingDay(t), morningStart).Add(-preOpen)
+}
 
-	return (currentTime >= morningStart && currentTime <= morningEnd) ||
-		(currentTime >= afternoonStart && currentTime <= afternoonEnd)
+// clockOn 返回 day 当天的 clock（格式 15:04:05）时刻
+func clockOn(day time.Time, clock string) time.Time {
+	c, _ := time.Parse("15:04:05", clock)
+	return time.Date(day.Year(), day.Month(), day.Day(), c.Hour(), c.Minute(), c.Second(), 0, day.Location())
 }
 
-// IsTradingDay 判断是否是交易日（周一到周五）
+// IsTradingDay 判断是否是交易日：周一到周五且不在休市日历中，或为周末开市日
 func (m *MarketTime) IsTradingDay(t time.Time) bool {
+	date := t.Format(dateLayout)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.makeupDays[date] {
+		return true
+	}
+	if m.holidays[date] {
+		return false
+	}
 	weekday := t.Weekday()
 	return weekday >= time.Monday && weekday <= time.Friday
 }
 
-// GetNextTradingDayStart 获取下一个交易日的开始时间
-func (m *MarketTime) GetNextTradingDayStart() time.Time {
-	now := m.timeService.Now()
-	todayMorning := time.Date(now.Year(), now.Month(), now.Day(), 9, 13, 30, 0, now.Location())
+// NextTradingDay 返回 from 之后（不含当天）的第一个交易日零点
+func (m *MarketTime) NextTradingDay(from time.Time) time.Time {
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for {
+		day = day.AddDate(0, 0, 1)
+		if m.IsTradingDay(day) {
+			return day
+		}
+	}
+}
 
-	// 如果是周末，跳到下周
-	if !m.IsTradingDay(now) {
-		daysUntilNext := 0
-		switch now.Weekday() {
-		case time.Saturday:
-			daysUntilNext = 2
-		case time.Sunday:
-			daysUntilNext = 1
-		default:
-			daysUntilNext = 0
+// NextTradingSessionStart 返回 from 之后（含）的下一个交易时段开始时间，已在交易时段内时返回 from
+func (m *MarketTime) NextTradingSessionStart(from time.Time) time.Time {
+	return m.NextTradingWindowStart(from, 0, 0)
+}
+
+// LastTradingTime 返回 t 之前（含）最后一个处于交易时段的时刻：交易时段内返回 t，
+// 午间休市返回上午收盘时间，收盘后和非交易日返回最近一个交易日的收盘时间
+func (m *MarketTime) LastTradingTime(t time.Time) time.Time {
+	if m.IsTradingTimeAt(t) {
+		return t
+	}
+
+	if m.IsTradingDay(t) {
+		if end := clockOn(t, afternoonEnd); t.After(end) {
+			return end
+		}
+		if m.TradingSessionAt(t) == SessionLunchBreak {
+			return clockOn(t, morningEnd)
 		}
-		return todayMorning.AddDate(0, 0, daysUntilNext)
 	}
 
-	// 如果今天已经过了交易时间，跳到明天
-	currentTime := now.Format("15:04:05")
-	if currentTime > "15:00:10" {
-		// 如果今天是周五，跳到周一
-		if now.Weekday() == time.Friday {
-			return todayMorning.AddDate(0, 0, 3)
+	return clockOn(m.PrevTradingDay(t), afternoonEnd)
+}
+
+// PrevTradingDay 返回 from 之前（不含当天）的最后一个交易日零点
+func (m *MarketTime) PrevTradingDay(from time.Time) time.Time {
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for {
+		day = day.AddDate(0, 0, -1)
+		if m.IsTradingDay(day) {
+			return day
 		}
-		return todayMorning.AddDate(0, 0, 1)
 	}
+}
+
+// TradingPeriod 一段连续的交易时段 [Start, End)
+type TradingPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration 交易时段的长度
+func (p TradingPeriod) Duration() time.Duration {
+	return p.End.Sub(p.Start)
+}
 
-	return todayMorning
+// TradingPeriods 按时间顺序返回与 [from, to) 相交的上午和下午交易时段，首尾截断到 from、to；
+// 交易时段按 from 所在时区划分
+func (m *MarketTime) TradingPeriods(from, to time.Time) []TradingPeriod {
+	var periods []TradingPeriod
+	to = to.In(from.Location())
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !m.IsTradingDay(day) {
+			continue
+		}
+		for _, session := range [][2]string{{morningStart, morningEnd}, {afternoonStart, afternoonEnd}} {
+			start, end := clockOn(day, session[0]), clockOn(day, session[1])
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if start.Before(end) {
+				periods = append(periods, TradingPeriod{Start: start, End: end})
+			}
+		}
+	}
+	return periods
+}
+
+// GetNextTradingDayStart 获取下一个交易日的开始时间
+func (m *MarketTime) GetNextTradingDayStart() time.Time {
+	now := m.timeService.Now()
+
+	// 今天是交易日且未收盘时返回今天的开盘时间
+	if m.IsTradingDay(now) && now.Format("15:04:05") <= afternoonEnd {
+		return clockOn(now, morningStart)
+	}
+	return clockOn(m.NextTradingDay(now), morningStart)
 }
 
 // GetTradingEndTime 获取当天交易结束时间
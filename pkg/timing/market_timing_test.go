@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MockTimeService 模拟时间服务
@@ -164,8 +165,9 @@ func TestMarketTiming_GetNextTradingDayStart(t *testing.T) {
 		{"周日上午", "2025-08-24 10:00:00", "2025-08-25 09:13:30"},    // 下周一
 		{"月末", "2025-08-29 16:00:00", "2025-09-01 09:13:30"},      // 8月29日是周五，过了交易时间，下一个交易日是下周一(9月1日)
 		{"月初", "2025-09-01 09:00:00", "2025-09-01 09:13:30"},
-		{"年末", "2025-12-31 16:00:00", "2026-01-01 09:13:30"}, // 下一年
-		{"年初", "2026-01-01 09:00:00", "2026-01-01 09:13:30"},
+		{"年末", "2025-12-31 16:00:00", "2026-01-05 09:13:30"}, // 下一年，元旦休市至1月2日
+		{"年初", "2026-01-01 09:00:00", "2026-01-05 09:13:30"},
+		{"国庆休市", "2025-10-01 10:00:00", "2025-10-09 09:13:30"},
 	}
 
 	for _, tt := range tests {
@@ -215,3 +217,226 @@ func TestMarketTiming_GetTradingEndTime(t *testing.T) {
 		})
 	}
 }
+
+func TestMarketTiming_TradingSessionAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		mockTime string
+		expected string
+	}{
+		{"开盘前", "2025-08-21 09:00:00", SessionClosed},
+		{"上午", "2025-08-21 10:00:00", SessionMorning},
+		{"午间休市", "2025-08-21 12:00:00", SessionLunchBreak},
+		{"下午", "2025-08-21 14:00:00", SessionAfternoon},
+		{"收盘后", "2025-08-21 15:30:00", SessionClosed},
+		{"周六", "2025-08-23 10:00:00", SessionClosed},
+	}
+
+	mt := DefaultMarketTime()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTime, _ := time.Parse("2006-01-02 15:04:05", tt.mockTime)
+			assert.Equal(t, tt.expected, mt.TradingSessionAt(mockTime))
+		})
+	}
+}
+
+func TestMarketTiming_IsWithinTradingWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		mockTime string
+		expected bool
+	}{
+		{"开盘缓冲前-09:08:29", "2025-08-21 09:08:29", false},
+		{"开盘缓冲内-09:08:30", "2025-08-21 09:08:30", true},
+		{"午间休市", "2025-08-21 12:00:00", false},
+		{"收盘缓冲内-15:02:10", "2025-08-21 15:02:10", true},
+		{"收盘缓冲后-15:02:11", "2025-08-21 15:02:11", false},
+		{"周日", "2025-08-24 10:00:00", false},
+	}
+
+	mt := DefaultMarketTime()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTime, _ := time.Parse("2006-01-02 15:04:05", tt.mockTime)
+			assert.Equal(t, tt.expected, mt.IsWithinTradingWindow(mockTime, 5*time.Minute, 2*time.Minute))
+		})
+	}
+}
+
+func TestMarketTiming_NextTradingWindowStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		mockTime string
+		expected string
+	}{
+		{"交易中返回当前时间", "2025-08-21 10:00:00", "2025-08-21 10:00:00"},
+		{"当天开盘前", "2025-08-21 02:00:00", "2025-08-21 09:08:30"},
+		{"午间休市", "2025-08-21 12:00:00", "2025-08-21 12:57:30"},
+		{"收盘后到次日", "2025-08-21 16:00:00", "2025-08-22 09:08:30"},
+		{"周五收盘后到周一", "2025-08-22 16:00:00", "2025-08-25 09:08:30"},
+		{"周六到周一", "2025-08-23 10:00:00", "2025-08-25 09:08:30"},
+	}
+
+	mt := DefaultMarketTime()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockTime, _ := time.Parse("2006-01-02 15:04:05", tt.mockTime)
+			expected, _ := time.Parse("2006-01-02 15:04:05", tt.expected)
+			assert.Equal(t, expected, mt.NextTradingWindowStart(mockTime, 5*time.Minute, 0))
+		})
+	}
+}
+
+func TestMarketTiming_HolidayCalendar(t *testing.T) {
+	mt := DefaultMarketTime()
+
+	tests := []struct {
+		name     string
+		date     string
+		expected bool
+	}{
+		{"国庆节-休市", "2025-10-08", false},
+		{"国庆节后首个交易日", "2025-10-09", true},
+		{"春节-休市", "2026-02-18", false},
+		{"调休上班的周六-A股不开市", "2025-09-28", false},
+		{"元旦-休市", "2026-01-01", false},
+		{"普通工作日", "2026-01-05", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, _ := time.Parse("2006-01-02", tt.date)
+			assert.Equal(t, tt.expected, mt.IsTradingDay(date))
+		})
+	}
+
+	// 节假日当天即使在交易时段的钟点也不交易
+	holiday, _ := time.Parse("2006-01-02 15:04:05", "2025-10-08 10:00:00")
+	assert.False(t, NewMarketTime(&MockTimeService{current: holiday}).IsTradingTime())
+}
+
+func TestMarketTiming_LoadHolidaysAndMakeupDays(t *testing.T) {
+	mt := DefaultMarketTime()
+
+	require.NoError(t, mt.LoadHolidayStrings([]string{"2027-01-01"}))
+	require.NoError(t, mt.LoadMakeupDayStrings([]string{"2027-01-09"})) // 周六
+	assert.Error(t, mt.LoadHolidayStrings([]string{"2027/01/04"}))
+
+	holiday, _ := time.Parse("2006-01-02", "2027-01-01")
+	makeup, _ := time.Parse("2006-01-02", "2027-01-09")
+	assert.False(t, mt.IsTradingDay(holiday))
+	assert.True(t, mt.IsTradingDay(makeup), "周末开市日应为交易日")
+
+	extra := time.Date(2027, 2, 8, 0, 0, 0, 0, time.UTC)
+	mt.LoadHolidays([]time.Time{extra})
+	assert.False(t, mt.IsTradingDay(extra))
+
+	// 周五之后的下一个交易日是周末开市日
+	friday := time.Date(2027, 1, 8, 16, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2027, 1, 9, 0, 0, 0, 0, time.UTC), mt.NextTradingDay(friday))
+	assert.Equal(t, time.Date(2027, 1, 9, 9, 13, 30, 0, time.UTC), mt.NextTradingSessionStart(friday))
+}
+
+func TestMarketTiming_NextTradingDay(t *testing.T) {
+	mt := DefaultMarketTime()
+
+	tests := []struct {
+		name     string
+		from     string
+		expected string
+	}{
+		{"普通工作日", "2025-08-21", "2025-08-22"},
+		{"周五到周一", "2025-08-22", "2025-08-25"},
+		{"国庆前一天", "2025-09-30", "2025-10-09"},
+		{"跨年", "2025-12-31", "2026-01-05"},
+		{"春节前", "2026-02-13", "2026-02-24"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, _ := time.Parse("2006-01-02", tt.from)
+			expected, _ := time.Parse("2006-01-02", tt.expected)
+			assert.Equal(t, expected, mt.NextTradingDay(from))
+		})
+	}
+}
+
+func TestMarketTiming_NextTradingSessionStart(t *testing.T) {
+	mt := DefaultMarketTime()
+
+	tests := []struct {
+		name     string
+		from     string
+		expected string
+	}{
+		{"交易中", "2025-08-21 10:00:00", "2025-08-21 10:00:00"},
+		{"开盘前", "2025-08-21 08:00:00", "2025-08-21 09:13:30"},
+		{"午间休市", "2025-08-21 12:00:00", "2025-08-21 12:57:30"},
+		{"收盘后", "2025-08-21 15:30:00", "2025-08-22 09:13:30"},
+		{"跨年休市", "2025-12-31 15:30:00", "2026-01-05 09:13:30"},
+		{"国庆期间", "2025-10-03 10:00:00", "2025-10-09 09:13:30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, _ := time.Parse("2006-01-02 15:04:05", tt.from)
+			expected, _ := time.Parse("2006-01-02 15:04:05", tt.expected)
+			assert.Equal(t, expected, mt.NextTradingSessionStart(from))
+		})
+	}
+}
+
+func TestMarketTiming_LastTradingTime(t *testing.T) {
+	mt := DefaultMarketTime()
+
+	tests := []struct {
+		name     string
+		at       string
+		expected string
+	}{
+		{"交易中", "2025-08-21 10:00:00", "2025-08-21 10:00:00"},
+		{"午间休市", "2025-08-21 12:00:00", "2025-08-21 11:30:10"},
+		{"收盘后", "2025-08-21 20:00:00", "2025-08-21 15:00:10"},
+		{"凌晨", "2025-08-22 03:00:00", "2025-08-21 15:00:10"},
+		{"开盘前", "2025-08-22 09:00:00", "2025-08-21 15:00:10"},
+		{"周末", "2025-08-24 10:00:00", "2025-08-22 15:00:10"},
+		{"国庆后第一天开盘前", "2025-10-09 08:00:00", "2025-09-30 15:00:10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, _ := time.Parse("2006-01-02 15:04:05", tt.at)
+			expected, _ := time.Parse("2006-01-02 15:04:05", tt.expected)
+			assert.Equal(t, expected, mt.LastTradingTime(at))
+		})
+	}
+}
+
+func TestMarketTiming_TradingPeriods(t *testing.T) {
+	mt := DefaultMarketTime()
+	parse := func(value string) time.Time {
+		parsed, _ := time.Parse("2006-01-02 15:04:05", value)
+		return parsed
+	}
+
+	// 周五 10:00 到下周一 10:00，跨周末只包含两个交易日
+	periods := mt.TradingPeriods(parse("2025-08-22 10:00:00"), parse("2025-08-25 10:00:00"))
+	require.Len(t, periods, 3)
+	assert.Equal(t, TradingPeriod{Start: parse("2025-08-22 10:00:00"), End: parse("2025-08-22 11:30:10")}, periods[0])
+	assert.Equal(t, TradingPeriod{Start: parse("2025-08-22 12:57:30"), End: parse("2025-08-22 15:00:10")}, periods[1])
+	assert.Equal(t, TradingPeriod{Start: parse("2025-08-25 09:13:30"), End: parse("2025-08-25 10:00:00")}, periods[2])
+
+	assert.Empty(t, mt.TradingPeriods(parse("2025-08-21 12:00:00"), parse("2025-08-21 12:30:00")), "午间休市")
+	assert.Empty(t, mt.TradingPeriods(parse("2025-10-01 00:00:00"), parse("2025-10-08 23:59:59")), "国庆休市")
+	assert.Equal(t, 2*time.Hour+16*time.Minute+40*time.Second+2*time.Hour+2*time.Minute+40*time.Second,
+		sumPeriods(mt.TradingPeriods(parse("2025-08-21 00:00:00"), parse("2025-08-22 00:00:00"))))
+}
+
+func sumPeriods(periods []TradingPeriod) time.Duration {
+	var total time.Duration
+	for _, p := range periods {
+		total += p.Duration()
+	}
+	return total
+}